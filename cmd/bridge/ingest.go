@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+)
+
+// persistedEventType is the messages.persisted envelope event type.
+const persistedEventType = "MessagePersisted"
+
+// ingestSink submits bridged messages through Ingest's PersistMessage, the
+// same path Gateway takes for client messages.
+type ingestSink struct {
+	client messagingv1.IngestServiceClient
+}
+
+func (s ingestSink) Submit(ctx context.Context, msg app.InboundMessage) error {
+	_, err := s.client.PersistMessage(ctx, &messagingv1.PersistMessageRequest{
+		ChatId:           msg.ChatID.String(),
+		SenderId:         msg.SenderID.String(),
+		ClientMessageId:  msg.ClientMessageID,
+		ContentType:      messagingv1.ContentType_CONTENT_TYPE_TEXT,
+		Content:          msg.Content.Body(),
+		ServerReceivedAt: &messagingv1.Timestamp{Millis: msg.ReceivedAt.UnixMilli()},
+	})
+	if err != nil {
+		return fmt.Errorf("ingest: persist message: %w", err)
+	}
+	return nil
+}
+
+// persistedRegistry returns the schemas the outbound relay decodes.
+func persistedRegistry() (*events.Registry, error) {
+	reg := events.NewRegistry()
+	err := reg.Register(events.Schema{
+		Type:    persistedEventType,
+		Version: 1,
+		New:     func() proto.Message { return &messagingv1.MessagePersistedEvent{} },
+	})
+	return reg, err
+}
+
+// decodePersisted decodes messages.persisted records for the relay. Only
+// text content is carried over; other messages decode with empty content,
+// which the bridge skips.
+func decodePersisted(reg *events.Registry) func([]byte) (app.PlatformMessage, error) {
+	return func(value []byte) (app.PlatformMessage, error) {
+		ev, err := reg.Decode(value)
+		if err != nil {
+			return app.PlatformMessage{}, err
+		}
+		persisted, ok := ev.Payload.(*messagingv1.MessagePersistedEvent)
+		if !ok {
+			return app.PlatformMessage{}, fmt.Errorf("%s: unexpected payload %T", persistedEventType, ev.Payload)
+		}
+		m := persisted.GetMessage()
+
+		chatID, err := domain.NewChatID(m.GetChatId())
+		if err != nil {
+			return app.PlatformMessage{}, fmt.Errorf("%s: chat_id: %w", persistedEventType, err)
+		}
+		msg := app.PlatformMessage{
+			MessageID:       m.GetMessageId(),
+			ChatID:          chatID,
+			ClientMessageID: m.GetClientMessageId(),
+		}
+		if m.GetSenderId() != "" { // system messages have no sender
+			if msg.SenderID, err = domain.NewUserID(m.GetSenderId()); err != nil {
+				return app.PlatformMessage{}, fmt.Errorf("%s: sender_id: %w", persistedEventType, err)
+			}
+		}
+		if m.GetContentType() == messagingv1.ContentType_CONTENT_TYPE_TEXT {
+			if msg.Content, err = domain.NewMessageContent(domain.ContentTypeText, m.GetContent()); err != nil {
+				return app.PlatformMessage{}, fmt.Errorf("%s: content: %w", persistedEventType, err)
+			}
+		}
+		return msg, nil
+	}
+}
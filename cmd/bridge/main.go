@@ -0,0 +1,28 @@
+// Package main is the entrypoint for the bridge service.
+// The bridge federates platform chats with rooms on other protocols.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	return server.Run(ctx, server.Params{
+		Name:           "bridge",
+		PortFromConfig: func(cfg *config.Config) int { return cfg.Bridge.HTTPPort },
+		Setup:          setup,
+	}, server.Listeners{})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/port"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+// persistedTopic carries every persisted message (ADR-011).
+const persistedTopic = "messages.persisted"
+
+// setup is the bridge service composition root. Each configured bridge gets
+// its inbound endpoint on the HTTP mux and its own consumer group on
+// messages.persisted for the outbound relay.
+func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	cfg := deps.Config
+	logger := deps.Logger
+
+	if !cfg.Bridge.Matrix.Enabled() {
+		logger.InfoContext(ctx, "no bridges configured")
+		return nil, nil
+	}
+	m := cfg.Bridge.Matrix
+	rooms, err := app.ParseRoomMappings(m.Rooms)
+	if err != nil {
+		return nil, fmt.Errorf("bridge setup: matrix: %w", err)
+	}
+
+	// 1. Ingest, where inbound messages are persisted.
+	conn, err := grpc.NewClient(cfg.Bridge.IngestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("bridge setup: ingest client: %w", err)
+	}
+
+	// 2. The Matrix bridge and its homeserver transaction endpoint.
+	matrix, err := app.NewBridge(app.BridgeConfig{
+		Name:  "matrix",
+		Rooms: rooms,
+		Remote: adapter.NewMatrixClient(adapter.MatrixConfig{
+			Homeserver:   m.Homeserver,
+			ASToken:      m.ASToken,
+			ServerName:   m.ServerName,
+			PuppetPrefix: m.PuppetPrefix,
+			Client:       &http.Client{},
+		}),
+		Sink:   ingestSink{client: messagingv1.NewIngestServiceClient(conn)},
+		Clock:  domain.RealClock{},
+		Logger: observability.Subsystem(logger, "bridge/matrix"),
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("bridge setup: matrix: %w", err)
+	}
+	// Homeservers batch events into transactions larger than the default
+	// body cap; the handler enforces MaxBridgeTransactionBytes itself.
+	deps.Route(port.MatrixTransactionsPath, server.RouteLimits{
+		Timeout: deps.Limits.Standard.Timeout,
+		MaxBody: domain.MaxBridgeTransactionBytes,
+	}, port.MatrixTransactionHandler(matrix, m.HSToken))
+
+	// 3. Outbound relay. Stopped on cleanup; an unfinished batch is
+	// redelivered to the next consumer.
+	registry, err := persistedRegistry()
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("bridge setup: event registry: %w", err)
+	}
+	consumer, err := kafka.NewClient(kafka.Config{
+		Brokers:  cfg.Kafka.Brokers,
+		ClientID: cfg.Kafka.ClientID,
+		Group:    "bridge-" + matrix.Name(),
+		Topics:   []string{cfg.Residency.Topic(persistedTopic)},
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("bridge setup: kafka: %w", err)
+	}
+	deps.OnWarmup("kafka", 0, consumer.Ping)
+	relay := port.NewRelayConsumer(port.RelayConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodePersisted(registry),
+		Relay:    matrix,
+		Logger:   observability.Subsystem(logger, "bridge/matrix"),
+	})
+	relayCtx, stopRelay := context.WithCancel(context.WithoutCancel(ctx))
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		if err := relay.Run(relayCtx); err != nil {
+			logger.ErrorContext(relayCtx, "matrix relay stopped", "error", err)
+		}
+	}()
+
+	logger.InfoContext(ctx, "bridge initialized", "bridge", matrix.Name(), "rooms", len(rooms))
+
+	cleanup := func(_ context.Context) error {
+		stopRelay()
+		<-relayDone
+		consumer.Close()
+		return conn.Close()
+	}
+	return cleanup, nil
+}
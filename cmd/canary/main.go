@@ -0,0 +1,29 @@
+// Package main is the entrypoint for the canary service.
+// The canary continuously runs the user journey against a deployment and
+// exports per-step pass/fail and latency metrics for alerting.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	return server.Run(ctx, server.Params{
+		Name:           "canary",
+		PortFromConfig: func(cfg *config.Config) int { return cfg.Canary.HTTPPort },
+		Setup:          setup,
+	}, server.Listeners{})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+// setup is the canary composition root. The canary reads OTPs from the
+// Redis Chat Mgmt writes them to, so it must share Chat Mgmt's REDIS_*
+// settings, and its numbers must be listed in CHATMGMT_OTPSINK_NUMBERS.
+func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	cfg := deps.Config
+	logger := deps.Logger
+	c := cfg.Canary
+
+	if !c.Enabled() {
+		logger.InfoContext(ctx, "canary not configured")
+		return nil, nil
+	}
+	sender, err := canaryUser(c.SenderPhone)
+	if err != nil {
+		return nil, fmt.Errorf("canary setup: sender: %w", err)
+	}
+	receiver, err := canaryUser(c.ReceiverPhone)
+	if err != nil {
+		return nil, fmt.Errorf("canary setup: receiver: %w", err)
+	}
+	chatID, err := domain.NewChatID(c.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("canary setup: chat: %w", err)
+	}
+
+	redisClient := redis.NewClient(redis.Config{
+		Addr:         cfg.Redis.Addr,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+	})
+	deps.OnWarmup("redis", 0, redisClient.Ping)
+
+	canary := app.NewCanary(app.CanaryConfig{
+		Auth:        adapter.NewAuthClient(c.ChatMgmtURL, &http.Client{}),
+		Sink:        adapter.NewOTPSinkReader(redisClient.RDB),
+		Gateway:     adapter.NewGatewayClient(c.GatewayURL),
+		Sender:      sender,
+		Receiver:    receiver,
+		ChatID:      chatID,
+		Interval:    c.Interval,
+		StepTimeout: c.StepTimeout,
+		Clock:       domain.RealClock{},
+		Logger:      observability.Subsystem(logger, "canary/app"),
+	})
+
+	// Stopped on cleanup; a journey in flight is cancelled and its users
+	// logged out.
+	runCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		canary.Run(runCtx)
+	}()
+
+	logger.InfoContext(ctx, "canary initialized", "chat_id", chatID.String(), "interval", c.Interval)
+
+	cleanup := func(_ context.Context) error {
+		stop()
+		<-done
+		return redisClient.Close()
+	}
+	return cleanup, nil
+}
+
+// canaryUser returns the canary account for a sink number. Its device ID
+// is derived from the number, so every login replaces the previous
+// session of the same device instead of adding one.
+func canaryUser(phone string) (app.User, error) {
+	p, err := domain.NewPhoneNumber(phone)
+	if err != nil {
+		return app.User{}, err
+	}
+	deviceID, err := domain.NewDeviceID(uuid.NewSHA1(uuid.NameSpaceURL, []byte("canary:"+p.String())).String())
+	if err != nil {
+		return app.User{}, err
+	}
+	return app.User{Phone: p, DeviceID: deviceID}, nil
+}
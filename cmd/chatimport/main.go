@@ -0,0 +1,156 @@
+// Package main is chatimport, an operator tool that imports a conversation
+// from a WhatsApp or Telegram chat export into an empty platform chat.
+//
+//	chatimport -chat <chat-id> -participants people.json "WhatsApp Chat with Family.zip"
+//
+// The participants file maps names as the export shows them to platform
+// user IDs ({"Alice": "<user-id>"}); anyone not listed is imported as a
+// placeholder sender. Messages keep their original timestamps and are
+// written at -rate per second. Use -dry-run to check an export and its
+// participant mapping without writing. Re-running an import is safe.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	chat := flag.String("chat", "", "platform chat ID to import into (required)")
+	participantsFile := flag.String("participants", "", "JSON file mapping export names to platform user IDs")
+	tz := flag.String("tz", "UTC", "time zone of the export's local timestamps (IANA name)")
+	dateOrder := flag.String("date-order", adapter.DateOrderAuto, "WhatsApp date order: auto, dmy or mdy")
+	rate := flag.Int("rate", domain.ConversationImportRatePerSecond, "messages written per second")
+	dryRun := flag.Bool("dry-run", false, "parse and map the export without writing")
+	messages := flag.String("messages", "messages_v2", "sharded messages table")
+	chats := flag.String("chats", "chats", "chats table holding each chat's shard count")
+	counters := flag.String("counters", "chat_counters", "chat sequence counters table")
+	keys := flag.String("keys", "chat_keys", "chat data keys table, used when MESSAGES_KMSKEYID is set")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		return errors.New("usage: chatimport -chat <chat-id> [flags] <export file>")
+	}
+
+	chatID, err := domain.NewChatID(*chat)
+	if err != nil {
+		return fmt.Errorf("-chat: %w", err)
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return fmt.Errorf("-tz: %w", err)
+	}
+	participants, err := readParticipants(*participantsFile)
+	if err != nil {
+		return err
+	}
+	export, err := adapter.ReadChatExport(flag.Arg(0), adapter.ChatExportOptions{Location: loc, DateOrder: *dateOrder})
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	if *dryRun {
+		msgs, res, err := app.NewConversationImporter(app.ConversationImporterConfig{}).Plan(chatID, export, participants)
+		if err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "dry run",
+			"format", export.Format, "title", export.Title,
+			"exported", res.Exported, "importable", len(msgs), "skipped", res.Skipped,
+			"placeholders", res.Placeholders)
+		return nil
+	}
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	client, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("create dynamo client: %w", err)
+	}
+	var sealer msgcrypt.Sealer
+	if cfg.Messages.Enabled() {
+		keyring, err := msgcrypt.NewAWS(client, *keys, msgcrypt.Config{
+			KeyID:       cfg.Messages.KMSKeyID,
+			RotateAfter: cfg.Messages.KeyRotation,
+			CacheTTL:    cfg.Messages.KeyCacheTTL,
+		})
+		if err != nil {
+			return fmt.Errorf("create keyring: %w", err)
+		}
+		sealer = keyring
+	}
+	ids, err := domain.NewMessageIDGenerator(cfg.Ingest.MessageIDScheme, domain.RealClock{})
+	if err != nil {
+		return fmt.Errorf("create message ID generator: %w", err)
+	}
+	importer := app.NewConversationImporter(app.ConversationImporterConfig{
+		Store:         adapter.NewImportedMessageStore(client.DB, *messages, *chats, *counters, sealer, domain.RealClock{}),
+		Logger:        logger,
+		MessageIDs:    ids,
+		RatePerSecond: *rate,
+	})
+
+	res, err := importer.Import(ctx, chatID, export, participants)
+	if err != nil {
+		return err
+	}
+	logger.InfoContext(ctx, "import complete",
+		"chat_id", chatID.String(), "written", res.Written, "existing", res.Existing,
+		"skipped", res.Skipped, "placeholders", res.Placeholders)
+	return nil
+}
+
+// readParticipants reads the export-name to user ID mapping; an empty name
+// means no mapping.
+func readParticipants(name string) (map[string]domain.UserID, error) {
+	if name == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(name) //nolint:gosec // operator-supplied path
+	if err != nil {
+		return nil, fmt.Errorf("read participants: %w", err)
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decode participants: %w", err)
+	}
+	out := make(map[string]domain.UserID, len(raw))
+	for exportName, id := range raw {
+		userID, err := domain.NewUserID(id)
+		if err != nil {
+			return nil, fmt.Errorf("participant %q: %w", exportName, err)
+		}
+		out[exportName] = userID
+	}
+	return out, nil
+}
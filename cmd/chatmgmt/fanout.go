@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// fanoutFeed delivers new feed entries through Fanout's
+// PublishNotification, which sends them to the user's connected devices
+// as notification frames. Fanout's status errors are turned back into
+// domain errors.
+type fanoutFeed struct {
+	client messagingv1.FanoutServiceClient
+}
+
+func (f fanoutFeed) PublishFeedEntry(ctx context.Context, entry domain.FeedEntry) error {
+	_, err := f.client.PublishNotification(ctx, &messagingv1.PublishNotificationRequest{
+		UserId:         entry.UserID,
+		NotificationId: entry.EntryID,
+		Kind:           string(entry.Kind),
+		ChatId:         entry.ChatID,
+		ActorId:        entry.ActorID,
+		Params:         entry.Params,
+		CreatedAt:      &messagingv1.Timestamp{Millis: entry.CreatedAt.UnixMilli()},
+	})
+	if err != nil {
+		return errmap.FromGRPCStatus(err)
+	}
+	return nil
+}
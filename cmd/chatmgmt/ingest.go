@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// ingestSystemPoster posts system messages through Ingest's
+// PostSystemMessage, so they are sequenced and delivered like any other
+// message. Ingest's status errors are turned back into domain errors.
+type ingestSystemPoster struct {
+	client messagingv1.IngestServiceClient
+}
+
+func (p ingestSystemPoster) PostSystemMessage(ctx context.Context, chatID, key string, msg domain.SystemMessage) error {
+	_, err := p.client.PostSystemMessage(ctx, &messagingv1.PostSystemMessageRequest{
+		ChatId: chatID,
+		Key:    key,
+		Message: &messagingv1.SystemMessage{
+			Event:    string(msg.Event),
+			ActorId:  msg.ActorID,
+			TargetId: msg.TargetID,
+			Params:   msg.Params,
+		},
+	})
+	if err != nil {
+		return errmap.FromGRPCStatus(err)
+	}
+	return nil
+}
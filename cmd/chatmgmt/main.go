@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	_ "time/tzdata" // DND schedules use IANA zones; scratch images have no zoneinfo
 
 	"github.com/aelexs/realtime-messaging-platform/internal/config"
 	"github.com/aelexs/realtime-messaging-platform/internal/server"
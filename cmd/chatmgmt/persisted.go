@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+)
+
+// persistedEventType is the messages.persisted envelope event type.
+const persistedEventType = "MessagePersisted"
+
+// persistedTopic carries every persisted message (ADR-011).
+const persistedTopic = "messages.persisted"
+
+// persistedRegistry returns the schemas the mention consumer decodes.
+func persistedRegistry() (*events.Registry, error) {
+	reg := events.NewRegistry()
+	err := reg.Register(events.Schema{
+		Type:    persistedEventType,
+		Version: 1,
+		New:     func() proto.Message { return &messagingv1.MessagePersistedEvent{} },
+	})
+	return reg, err
+}
+
+// decodePersisted decodes messages.persisted records into the messages
+// the mention service reads. Only text messages carry their text.
+func decodePersisted(reg *events.Registry) func([]byte) (app.PostedMessage, error) {
+	return func(value []byte) (app.PostedMessage, error) {
+		ev, err := reg.Decode(value)
+		if err != nil {
+			return app.PostedMessage{}, err
+		}
+		persisted, ok := ev.Payload.(*messagingv1.MessagePersistedEvent)
+		if !ok {
+			return app.PostedMessage{}, fmt.Errorf("%s: unexpected payload %T", persistedEventType, ev.Payload)
+		}
+		m := persisted.GetMessage()
+		msg := app.PostedMessage{
+			MessageID: m.GetMessageId(),
+			ChatID:    m.GetChatId(),
+			SenderID:  m.GetSenderId(),
+			Sequence:  m.GetSequence(),
+		}
+		switch m.GetContentType() {
+		case messagingv1.ContentType_CONTENT_TYPE_TEXT:
+			msg.ContentType, msg.Text = domain.ContentTypeText, m.GetContent()
+		case messagingv1.ContentType_CONTENT_TYPE_SYSTEM:
+			msg.ContentType = domain.ContentTypeSystem
+		default:
+			return app.PostedMessage{}, fmt.Errorf("%s: unsupported content type %s", persistedEventType, m.GetContentType())
+		}
+		return msg, nil
+	}
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/translate"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
 	apiv1 "github.com/aelexs/realtime-messaging-platform/api/v1"
 	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/analytics"
 	"github.com/aelexs/realtime-messaging-platform/internal/auth"
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
@@ -20,30 +25,43 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/config"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/ipscreen"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
 	"github.com/aelexs/realtime-messaging-platform/internal/redis"
 	"github.com/aelexs/realtime-messaging-platform/internal/server"
 )
 
 // Table names match the LocalStack init script (scripts/localstack-init.sh).
 const (
-	otpRequestsTable = "otp_requests"
-	usersTable       = "users"
-	sessionsTable    = "sessions"
+	otpRequestsTable    = "otp_requests"
+	usersTable          = "users"
+	sessionsTable       = "sessions"
+	deviceTokensTable   = "device_tokens"
+	notificationsTable  = "notifications"
+	tokenLineageTable   = "token_lineage"
+	entitlementsTable   = "entitlements"
+	ssoConnectionsTable = "sso_connections"
+	ssoIdentitiesTable  = "sso_identities"
+	scimResourcesTable  = "scim_resources"
+	chatKeysTable       = "chat_keys"
+	chatsTable          = "chats"
+	membershipsTable    = "chat_memberships" // also holds pending join requests
+	messagesTable       = "messages_v2"
+	chatEventsTable     = "chat_events"
 )
 
-// JWT issuer/audience match the domain convention.
-const (
-	jwtIssuer   = "messaging-platform"
-	jwtAudience = "messaging-api"
-)
-
-// devPepper is the HMAC pepper used in local development.
-// Production uses a value from AWS Secrets Manager (TF-1 follow-up).
-var devPepper = []byte("local-dev-pepper-32-bytes-ok!!")
+// mentionsGroup is the consumer group reading messages.persisted for
+// mentions. Every replica joins it, so each message is read once.
+const mentionsGroup = "chatmgmt-mentions"
 
 // setup is the chatmgmt service composition root. It creates infrastructure
-// clients, adapters, the auth service, and registers gRPC + grpc-gateway handlers.
-func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+// clients, adapters, the auth and chat services, and registers gRPC +
+// grpc-gateway handlers. It also consumes messages.persisted to add
+// mentions to the mentioned members' notification feeds.
+func setup(ctx context.Context, deps server.SetupDeps) (_ func(context.Context) error, err error) {
 	cfg := deps.Config
 	logger := deps.Logger
 
@@ -64,112 +82,566 @@ func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) er
 		ReadTimeout:  cfg.Redis.Timeout,
 		WriteTimeout: cfg.Redis.Timeout,
 	})
+	// A failed step below releases what the steps before it opened, newest
+	// first; once setup succeeds, cleanup owns them.
+	var release []func()
+	defer func() {
+		if err != nil {
+			for _, f := range slices.Backward(release) {
+				f()
+			}
+		}
+	}()
+	release = append(release, func() { _ = redisClient.Close() })
+
+	deps.OnWarmup("dynamodb", 0, func(ctx context.Context) error {
+		return dynamoClient.Warmup(ctx, otpRequestsTable, usersTable, sessionsTable, deviceTokensTable, notificationsTable, tokenLineageTable,
+			chatsTable, membershipsTable, messagesTable)
+	})
+	deps.OnWarmup("redis", 0, redisClient.Ping)
 
-	// 2. Adapters.
+	// 2. Adapters. Users' personal data is encrypted once PII_KMSKEYID is set;
+	// phones are found by their blind index.
+	var fields *msgcrypt.FieldCipher
+	if cfg.PII.Enabled() {
+		fields, err = msgcrypt.NewAWSFieldCipher(dynamoClient, chatKeysTable, msgcrypt.UsersKeyScope, cfg.PII.KMSKeyID, cfg.PII.KeyCacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("chatmgmt setup: create field cipher: %w", err)
+		}
+	}
+	phones := msgcrypt.NewBlindIndex(cfg.PII.PhoneIndexKey())
 	clock := domain.RealClock{}
 	otpStore := adapter.NewOTPStore(dynamoClient.DB, otpRequestsTable, clock)
-	userStore := adapter.NewUserStore(dynamoClient.DB, usersTable)
+	userStore := adapter.NewUserStore(dynamoClient.DB, usersTable, fields, phones, cfg.PII.LegacyPhoneLookup)
 	sessionStore := adapter.NewSessionStore(dynamoClient.DB, sessionsTable, clock)
-	transactor := adapter.NewTransactor(dynamoClient.DB, otpRequestsTable, usersTable, sessionsTable)
+	transactor := adapter.NewTransactor(dynamoClient.DB, otpRequestsTable, usersTable, sessionsTable, fields, phones)
 	rateLimiter := adapter.NewRateLimiter(redisClient.RDB)
+	verifyLimiter := adapter.NewOTPVerifyLimiter(redisClient.RDB)
 	revocationStore := adapter.NewRevocationStore(redisClient.RDB)
+	pushTokenStore := adapter.NewPushTokenStore(dynamoClient.DB, deviceTokensTable)
+	feedStore := adapter.NewFeedStore(dynamoClient.DB, notificationsTable)
+	lineageStore := adapter.NewTokenLineageStore(dynamoClient.DB, tokenLineageTable)
+
+	// AWS clients other than DynamoDB use AWS_REGION and, for LocalStack,
+	// AWS_ENDPOINT, with the DynamoDB client's credentials.
+	awsCfg := aws.Config{Region: cfg.AWS.Region, Credentials: dynamoClient.DB.Options().Credentials}
+	if cfg.AWS.Endpoint != "" {
+		awsCfg.BaseEndpoint = aws.String(cfg.AWS.Endpoint)
+	}
 
 	// 3. Key store + SMS provider (environment-dependent).
-	keyStore, err := createKeyStore(cfg, logger)
+	keyStore, err := createKeyStore(ctx, awsCfg, logger)
 	if err != nil {
 		return nil, fmt.Errorf("chatmgmt setup: create key store: %w", err)
 	}
 
 	smsProvider := createSMSProvider(cfg, logger)
+	sinkPhones, err := cfg.ChatMgmt.OTPSink.Phones()
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: %w", err)
+	}
+	if len(sinkPhones) > 0 {
+		// The synthetic canary reads its OTPs from Redis instead of SMS.
+		logger.Info("OTP sink enabled", slog.Int("numbers", len(sinkPhones)))
+		smsProvider = adapter.NewOTPSinkProvider(smsProvider, redisClient.RDB, sinkPhones)
+	}
 
 	// 4. Auth core.
 	minter := auth.NewMinter(auth.MinterConfig{
 		KeyStore:  keyStore,
-		AccessTTL: domain.AccessTokenLifetime,
-		Issuer:    jwtIssuer,
-		Audience:  jwtAudience,
+		AccessTTL: cfg.Auth.Access.TTL,
+		Issuer:    cfg.Auth.Issuer,
+		Audience:  cfg.Auth.Audience,
 		Clock:     clock,
 	})
 	validator := auth.NewValidator(auth.ValidatorConfig{
 		KeyStore: keyStore,
-		Issuer:   jwtIssuer,
-		Audience: jwtAudience,
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
 		Clock:    clock,
 	})
 
-	// 5. Auth service.
+	// 5. Auth service. The IP screener runs on the static policy alone
+	// until a reputation provider is configured.
+	ipPolicy, err := domain.NewIPPolicy(cfg.ChatMgmt.IP.Policy())
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: ip policy: %w", err)
+	}
+	ipScreener := ipscreen.New(ipscreen.Config{
+		Policy: ipPolicy,
+		Logger: observability.Subsystem(logger, "chatmgmt/ipscreen"),
+	})
+
+	// Load already validated the OTP formats.
+	otpPolicy, err := domain.NewOTPPolicy(cfg.ChatMgmt.OTP.Policy())
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: otp policy: %w", err)
+	}
+
+	// Product analytics, off unless ANALYTICS_TOPIC is set. The emitter
+	// flushes what it has queued on cleanup.
+	emitter, stopAnalytics, err := startAnalytics(ctx, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: %w", err)
+	}
+	release = append(release, stopAnalytics)
+	var sessionAnalytics app.SessionAnalytics
+	if emitter != nil {
+		sessionAnalytics = emitter
+	}
+
+	ssoStore := adapter.NewSSOStore(dynamoClient.DB, ssoConnectionsTable, ssoIdentitiesTable, usersTable, fields)
+	scimStore := adapter.NewSCIMStore(dynamoClient.DB, scimResourcesTable, usersTable, fields)
+
+	// Phone hashes and OTP MACs are keyed by AUTH_PEPPERS, a development
+	// pepper when unset.
+	peppers, err := auth.NewPepperRing(cfg.Auth.PepperVersion, cfg.Auth.PepperKeys())
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: %w", err)
+	}
+
+	// Refresh tokens are hashed with Argon2id once AUTH_REFRESHHASH_ALGORITHM
+	// selects it; SHA-256 hashes keep verifying either way.
+	var refreshHasher *auth.RefreshHasher
+	if rh := cfg.Auth.RefreshHash; rh.Argon2() {
+		refreshHasher = auth.NewRefreshHasher(auth.Argon2Params{Memory: rh.Memory, Iterations: rh.Iterations, Parallelism: rh.Parallelism})
+	}
+
 	authSvc := app.NewAuthService(app.AuthServiceConfig{
 		OTPStore:        otpStore,
 		UserStore:       userStore,
 		SessionStore:    sessionStore,
 		Transactor:      transactor,
 		RateLimiter:     rateLimiter,
+		VerifyLimiter:   verifyLimiter,
 		RevocationStore: revocationStore,
+		PushTokenStore:  pushTokenStore,
+		LineageStore:    lineageStore,
 		SMSProvider:     smsProvider,
 		Minter:          minter,
 		Validator:       validator,
 		Clock:           clock,
-		Pepper:          devPepper,
-		Logger:          logger,
+		Peppers:         peppers,
+		Logger:          observability.Subsystem(logger, "chatmgmt/auth"),
+		RefreshTTL:      cfg.Auth.Refresh.TTL,
+		RefreshHasher:   refreshHasher,
+		IdleTimeout:     cfg.Auth.Session.IdleTimeout,
+		IPRateLimit:     cfg.ChatMgmt.RateLimit.IP,
+		SubnetRateLimit: cfg.ChatMgmt.RateLimit.Subnet,
+		PhonePolicy: domain.NewPhonePolicy(domain.PhonePolicyConfig{
+			Allow:          cfg.ChatMgmt.Phone.Allow,
+			Deny:           cfg.ChatMgmt.Phone.Deny,
+			AllowFixedLine: cfg.ChatMgmt.Phone.FixedLine,
+		}),
+		OTPPolicy:  otpPolicy,
+		IPScreener: ipScreener,
+		// Canary events go to logs and security_honeypot_events_total until
+		// an audit sink exists.
+		HoneypotPolicy: domain.NewHoneypotPolicy(domain.HoneypotPolicyConfig{
+			Prefixes: cfg.ChatMgmt.Honeypot.Prefixes,
+			Numbers:  cfg.ChatMgmt.Honeypot.Numbers,
+		}),
+		Analytics: sessionAnalytics,
+		SSO:       ssoStore,
+		IDTokens:  auth.NewOIDCVerifier(auth.OIDCVerifierConfig{Clock: clock}),
+		Members:   scimStore,
+		Region:    cfg.Residency.DataRegion(),
+	})
+
+	// New feed entries reach the user's connected devices as notification
+	// frames, delivered through Fanout. Offline devices pick them up by
+	// listing.
+	fanoutConn, err := grpc.NewClient(cfg.ChatMgmt.FanoutAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: fanout client: %w", err)
+	}
+	release = append(release, func() { _ = fanoutConn.Close() })
+	feedSvc := app.NewFeedService(app.FeedServiceConfig{
+		Store:     feedStore,
+		Publisher: fanoutFeed{client: messagingv1.NewFanoutServiceClient(fanoutConn)},
+		Validator: validator,
+		Clock:     clock,
+		Logger:    observability.Subsystem(logger, "chatmgmt/feed"),
+	})
+
+	// Entitlements change only through signed webhooks, so billing stays
+	// off until the provider's signing secret is configured. Without it
+	// groups have no size cap.
+	var billingSvc *app.BillingService
+	var entitlements app.EntitlementReader
+	if cfg.Billing.Enabled() {
+		secrets := make([][]byte, 0, len(cfg.Billing.WebhookSecrets))
+		for _, s := range cfg.Billing.WebhookSecrets {
+			secrets = append(secrets, []byte(s))
+		}
+		billingSvc = app.NewBillingService(app.BillingServiceConfig{
+			Store:          adapter.NewEntitlementStore(dynamoClient.DB, entitlementsTable),
+			WebhookSecrets: secrets,
+			Clock:          clock,
+			Logger:         observability.Subsystem(logger, "chatmgmt/billing"),
+		})
+		entitlements = billingSvc
+	}
+
+	// 6. Chat services. Settings and membership writes append to the chat
+	// event log in the same transaction. Encrypted messages are opened with
+	// the chat keyring once MESSAGES_KMSKEYID is set. Settings, join and
+	// ownership changes are announced in the chat by system messages,
+	// posted through Ingest.
+	chatSettingsStore := adapter.NewChatSettingsStore(dynamoClient.DB, chatsTable, chatEventsTable)
+	memberRoles := adapter.NewMemberRoleStore(dynamoClient.DB, membershipsTable)
+	membershipStore := adapter.NewMembershipStore(dynamoClient.DB, membershipsTable, chatEventsTable)
+	var opener msgcrypt.Opener
+	if cfg.Messages.Enabled() {
+		keyring, err := msgcrypt.NewAWS(dynamoClient, chatKeysTable, msgcrypt.Config{
+			KeyID:       cfg.Messages.KMSKeyID,
+			RotateAfter: cfg.Messages.KeyRotation,
+			CacheTTL:    cfg.Messages.KeyCacheTTL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("chatmgmt setup: create keyring: %w", err)
+		}
+		opener = keyring
+	}
+	messageStore := adapter.NewMessageStore(dynamoClient.DB, messagesTable, chatsTable, opener)
+	ingestConn, err := grpc.NewClient(cfg.ChatMgmt.IngestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: ingest client: %w", err)
+	}
+	release = append(release, func() { _ = ingestConn.Close() })
+	system := ingestSystemPoster{client: messagingv1.NewIngestServiceClient(ingestConn)}
+
+	historySvc := app.NewHistoryService(app.HistoryServiceConfig{
+		Messages:  messageStore,
+		Members:   memberRoles,
+		Validator: validator,
+		Clock:     clock,
+		Logger:    observability.Subsystem(logger, "chatmgmt/history"),
+	})
+	settingsSvc := app.NewChatSettingsService(app.ChatSettingsServiceConfig{
+		Store:     chatSettingsStore,
+		Roles:     memberRoles,
+		System:    system,
+		Validator: validator,
+		Clock:     clock,
+		Logger:    observability.Subsystem(logger, "chatmgmt/settings"),
+	})
+	joinRequestSvc := app.NewJoinRequestService(app.JoinRequestServiceConfig{
+		Store:        adapter.NewJoinRequestStore(dynamoClient.DB, membershipsTable, chatEventsTable),
+		Settings:     chatSettingsStore,
+		Roles:        memberRoles,
+		Admins:       memberRoles,
+		Feed:         feedSvc,
+		System:       system,
+		Validator:    validator,
+		Clock:        clock,
+		Logger:       observability.Subsystem(logger, "chatmgmt/joinrequests"),
+		Members:      membershipStore,
+		Entitlements: entitlements,
+	})
+	ownershipSvc := app.NewOwnershipService(app.OwnershipServiceConfig{
+		Store:     membershipStore,
+		Roles:     memberRoles,
+		System:    system,
+		Validator: validator,
+		Clock:     clock,
+		Logger:    observability.Subsystem(logger, "chatmgmt/ownership"),
+	})
+
+	// Mentions: text messages read from messages.persisted add a mention
+	// entry to the feed of each member they mention (<@user_id>).
+	registry, err := persistedRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: event registry: %w", err)
+	}
+	mentionsConsumer, err := kafka.NewClient(kafka.Config{
+		Brokers:  cfg.Kafka.Brokers,
+		ClientID: cfg.Kafka.ClientID,
+		Group:    mentionsGroup,
+		Topics:   []string{cfg.Residency.Topic(persistedTopic)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: kafka mentions: %w", err)
+	}
+	release = append(release, mentionsConsumer.Close)
+	mentions := port.NewMentionConsumer(port.MentionConsumerConfig{
+		Consumer: mentionsConsumer,
+		Decode:   decodePersisted(registry),
+		Notify: app.NewMentionService(app.MentionServiceConfig{
+			Roles:  memberRoles,
+			Feed:   feedSvc,
+			Logger: observability.Subsystem(logger, "chatmgmt/mentions"),
+		}),
+		Logger: observability.Subsystem(logger, "chatmgmt/mentions"),
+	})
+
+	// Chat event history and compaction.
+	chatEventsSvc := app.NewChatHistoryService(app.ChatHistoryServiceConfig{
+		Store:  adapter.NewChatEventStore(dynamoClient.DB, chatEventsTable),
+		Clock:  clock,
+		Logger: observability.Subsystem(logger, "chatmgmt/chatevents"),
+	})
+	translator := adapter.NewAWSTranslator(translate.NewFromConfig(awsCfg, func(o *translate.Options) {
+		o.HTTPClient = &http.Client{Timeout: domain.TranslateTimeout}
+	}))
+	translationSvc := app.NewTranslationService(app.TranslationServiceConfig{
+		Messages:    messageStore,
+		Members:     memberRoles,
+		Translator:  translator,
+		Cache:       adapter.NewTranslationCache(redisClient.RDB),
+		Preferences: userStore,
+		RateLimiter: rateLimiter,
+		Validator:   validator,
+		Logger:      observability.Subsystem(logger, "chatmgmt/translation"),
+	})
+	smsFallbackSvc := app.NewSMSFallbackService(app.SMSFallbackServiceConfig{
+		Store:     userStore,
+		Validator: validator,
+	})
+	privacySvc := app.NewPrivacySettingsService(app.PrivacySettingsServiceConfig{
+		Store:     userStore,
+		Validator: validator,
+	})
+	dndSvc := app.NewDNDScheduleService(app.DNDScheduleServiceConfig{
+		Store:     userStore,
+		Validator: validator,
 	})
 
-	// 6. Register gRPC + grpc-gateway.
-	handler := port.NewAuthHandler(authSvc)
+	// Bulk user import from the legacy system. Manifests stream through the
+	// S3 client's default HTTP client, which has no overall timeout,
+	// because a large manifest streams for hours at the import rate.
+	importSvc := app.NewUserImportService(app.UserImportServiceConfig{
+		Users:    userStore,
+		Importer: transactor,
+		Members:  adapter.NewMemberImporter(dynamoClient.DB, chatsTable, membershipsTable, chatEventsTable),
+		Clock:    clock,
+		Logger:   observability.Subsystem(logger, "chatmgmt/import"),
+	})
+	// LocalStack serves buckets path-style only.
+	manifests := adapter.NewS3ManifestReader(s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = awsCfg.BaseEndpoint != nil
+	}))
+
+	// SCIM provisioning. Deprovisioning revokes sessions, hands owned chats
+	// to a successor and leaves the rest.
+	scimSvc := app.NewSCIMService(app.SCIMServiceConfig{
+		Store:       scimStore,
+		Connections: ssoStore,
+		Sessions:    authSvc,
+		Chats:       ownershipSvc,
+		Memberships: membershipStore,
+		Region:      cfg.Residency.DataRegion(),
+		Clock:       clock,
+		Logger:      observability.Subsystem(logger, "chatmgmt/scim"),
+	})
+
+	// 7. Register gRPC + grpc-gateway.
+	// Load already validated the trusted proxy ranges.
+	clientIPs, err := domain.NewClientIPResolver(cfg.ChatMgmt.IP.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: trusted proxies: %w", err)
+	}
+	handler := port.NewAuthHandler(authSvc, clientIPs)
 	messagingv1.RegisterAuthServiceServer(deps.GRPCServer, handler)
+	notificationHandler := port.NewNotificationHandler(feedSvc)
+	messagingv1.RegisterNotificationServiceServer(deps.GRPCServer, notificationHandler)
+	chatHandler := port.NewChatHandler(historySvc, settingsSvc, joinRequestSvc, ownershipSvc, translationSvc, smsFallbackSvc, privacySvc, dndSvc)
+	messagingv1.RegisterChatMgmtServiceServer(deps.GRPCServer, chatHandler)
 
 	gwMux := runtime.NewServeMux(
 		runtime.WithIncomingHeaderMatcher(customHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithErrorHandler(errmap.GatewayErrorHandler),
 	)
 	if err := messagingv1.RegisterAuthServiceHandlerServer(ctx, gwMux, handler); err != nil {
 		return nil, fmt.Errorf("chatmgmt setup: register grpc-gateway: %w", err)
 	}
+	if err := messagingv1.RegisterNotificationServiceHandlerServer(ctx, gwMux, notificationHandler); err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: register notification grpc-gateway: %w", err)
+	}
+	if err := messagingv1.RegisterChatMgmtServiceHandlerServer(ctx, gwMux, chatHandler); err != nil {
+		return nil, fmt.Errorf("chatmgmt setup: register chat grpc-gateway: %w", err)
+	}
+	// REST calls share the Redis limiter with the OTP limits. The OpenAPI
+	// spec, error catalog and admin surfaces are not throttled.
+	throttle := func(h http.Handler) http.Handler {
+		return port.ThrottleMiddleware(port.ThrottleConfig{
+			Limiter:   rateLimiter,
+			Tokens:    validator,
+			ClientIPs: clientIPs,
+			PerIP:     cfg.ChatMgmt.Throttle.IP,
+			PerUser:   cfg.ChatMgmt.Throttle.User,
+			Window:    cfg.ChatMgmt.Throttle.Window,
+		}, h)
+	}
 	deps.HTTPMux.Handle("GET /v1/openapi.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		if _, err := w.Write(apiv1.Spec); err != nil {
 			logger.ErrorContext(r.Context(), "write openapi spec", slog.String("error", err.Error()))
 		}
 	}))
-	deps.HTTPMux.Handle("/", gwMux)
+	deps.HTTPMux.Handle("GET /v1/errors", errmap.CatalogHandler())
+	deps.HTTPMux.Handle("/admin/token-lineage", port.TokenLineageAdminHandler(authSvc))
+	deps.HTTPMux.Handle("/admin/chats/history", port.ChatHistoryAdminHandler(chatEventsSvc))
+	// Imports read an uploaded manifest of any size and stream NDJSON
+	// progress for as long as it takes, so neither is bounded.
+	deps.Route("/admin/users/import", server.RouteLimits{}, port.UserImportAdminHandler(importSvc, manifests))
+	deps.HTTPMux.Handle("/admin/keys", port.KeysAdminHandler(keyStore))
+	deps.HTTPMux.Handle("/admin/workspaces/sso", port.SSOConnectionAdminHandler(authSvc))
+	deps.HTTPMux.Handle("/v1/auth/sso", throttle(port.SSOLoginHandler(authSvc, clientIPs)))
+	deps.HTTPMux.Handle("/admin/workspaces/scim-token", port.SCIMTokenAdminHandler(scimSvc))
+	// SCIM requests carry the workspace's SCIM token, not the admin token.
+	deps.HTTPMux.Handle("/scim/v2/", port.SCIMHandler(scimSvc))
+	directorySvc := app.NewUserDirectoryService(app.UserDirectoryServiceConfig{
+		Directory: userStore,
+		Clock:     clock,
+		Logger:    observability.Subsystem(logger, "chatmgmt/admin"),
+	})
+	deps.HTTPMux.Handle("/admin/users", port.UserDirectoryAdminHandler(directorySvc))
+	deps.HTTPMux.Handle("/admin/users/flag", port.UserFlagAdminHandler(directorySvc))
+	if billingSvc != nil {
+		deps.HTTPMux.Handle("/webhooks/billing", port.BillingWebhookHandler(billingSvc))
+	}
+	deps.HTTPMux.Handle("/", throttle(gwMux))
+
+	// 8. Background loops, started once nothing above can fail.
+	// Idle-session expiry (ADR-015) deletes conditionally and compaction
+	// is conditional on the folded events, so every replica runs both.
+	// The mention consumer shares its group across replicas. They stop on
+	// cleanup.
+	sweepCtx, stopSweep := context.WithCancel(context.WithoutCancel(ctx))
+	sweepDone := make(chan struct{})
+	go func() {
+		defer close(sweepDone)
+		authSvc.RunIdleSweeper(sweepCtx, 0)
+	}()
+	compactCtx, stopCompact := context.WithCancel(context.WithoutCancel(ctx))
+	compactDone := make(chan struct{})
+	go func() {
+		defer close(compactDone)
+		chatEventsSvc.RunCompactor(compactCtx, 0)
+	}()
+	mentionsCtx, stopMentions := context.WithCancel(context.WithoutCancel(ctx))
+	mentionsDone := make(chan struct{})
+	go func() {
+		defer close(mentionsDone)
+		if err := mentions.Run(mentionsCtx); err != nil {
+			logger.ErrorContext(mentionsCtx, "mention consumer stopped", "error", err)
+		}
+	}()
 
-	logger.InfoContext(ctx, "chatmgmt auth service initialized")
+	logger.InfoContext(ctx, "chatmgmt services initialized",
+		slog.String("data_region", string(cfg.Residency.DataRegion())))
 
 	cleanup := func(_ context.Context) error {
+		stopSweep()
+		<-sweepDone
+		stopCompact()
+		<-compactDone
+		stopMentions()
+		<-mentionsDone
+		mentionsConsumer.Close()
 		authSvc.Wait()
+		stopAnalytics()
+		_ = ingestConn.Close()
+		_ = fanoutConn.Close()
 		return redisClient.Close()
 	}
 
 	return cleanup, nil
 }
 
-// createKeyStore returns the appropriate key store for the environment.
-// Local: generates an ephemeral RSA key pair (no AWS dependency).
-// Production: loads from AWS Secrets Manager + SSM (not yet wired — TF-1 follow-up).
-func createKeyStore(cfg *config.Config, logger *slog.Logger) (auth.KeyStore, error) {
-	if cfg.IsLocal() {
-		key, err := rsa.GenerateKey(rand.Reader, 2048)
+// startAnalytics creates the analytics emitter and runs it until the
+// returned stop function is called. It returns a nil emitter and a no-op
+// stop when analytics is disabled.
+func startAnalytics(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*analytics.Emitter, func(), error) {
+	if !cfg.Analytics.Enabled() {
+		return nil, func() {}, nil
+	}
+
+	// Load already validated the overrides.
+	rates := make(map[string]float64, len(cfg.Analytics.SampleRates))
+	for _, entry := range cfg.Analytics.SampleRates {
+		event, rate, err := config.ParseSampleRateOverride(entry)
 		if err != nil {
-			return nil, fmt.Errorf("generate dev RSA key: %w", err)
+			return nil, nil, err
 		}
-		logger.Info("using ephemeral RSA key for local development", slog.String("key_id", "dev-key-001"))
-		return auth.NewStaticKeyStore(key, "dev-key-001"), nil
+		rates[event] = rate
 	}
 
-	// TODO(TF-1): Wire AWSKeyStore for production.
-	return nil, fmt.Errorf("production key store not yet implemented (TF-1)")
+	producer, err := kafka.NewClient(kafka.Config{
+		Brokers:        cfg.Kafka.Brokers,
+		ClientID:       cfg.Kafka.ClientID,
+		ProduceTimeout: domain.KafkaProduceTimeout,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("analytics kafka: %w", err)
+	}
+	emitter, err := analytics.New(analytics.Config{
+		Producer:     producer,
+		Topic:        cfg.Residency.Topic(cfg.Analytics.Topic),
+		PseudonymKey: []byte(cfg.Analytics.PseudonymKey),
+		SampleRate:   cfg.Analytics.SampleRate,
+		Rates:        rates,
+		Logger:       observability.Subsystem(logger, "chatmgmt/analytics"),
+	})
+	if err != nil {
+		producer.Close()
+		return nil, nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = emitter.Run(runCtx)
+	}()
+	logger.InfoContext(ctx, "analytics enabled", slog.String("topic", cfg.Residency.Topic(cfg.Analytics.Topic)))
+
+	return emitter, func() {
+		cancel()
+		<-done
+		producer.Close()
+	}, nil
+}
+
+// createKeyStore loads the token signing keys from Secrets Manager and
+// their public halves from SSM Parameter Store (ADR-015). The Gateway
+// verifies access tokens against the same public keys; LocalStack
+// provisions a development key (scripts/localstack-init.sh).
+func createKeyStore(ctx context.Context, awsCfg aws.Config, logger *slog.Logger) (auth.KeyStore, error) {
+	keyStore, err := adapter.NewAWSKeyStoreFromConfig(ctx, awsCfg, domain.RealClock{})
+	if err != nil {
+		return nil, err
+	}
+	signing, _ := keyStore.KeyIDs()
+	logger.Info("loaded token signing keys", slog.String("key_id", signing))
+	return keyStore, nil
 }
 
 // customHeaderMatcher forwards application-specific HTTP headers as gRPC metadata.
 // Headers not matched here fall through to grpc-gateway's default matcher, which
-// handles standard headers like Authorization.
+// handles standard headers like Authorization. X-Forwarded-For is not matched:
+// grpc-gateway forwards it itself with the HTTP peer address appended.
 func customHeaderMatcher(key string) (string, bool) {
 	switch strings.ToLower(key) {
-	case "x-device-id", "x-forwarded-for":
+	case "x-device-id":
 		return key, true
 	default:
 		return runtime.DefaultHeaderMatcher(key)
 	}
 }
 
+// outgoingHeaderMatcher sends "etag" header metadata set by a handler as
+// the ETag response header, so server.ConditionalGET can answer
+// If-None-Match. Other metadata keeps grpc-gateway's Grpc-Metadata- prefix.
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == "etag" {
+		return "ETag", true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
 // createSMSProvider returns the appropriate SMS provider for the environment.
 // Local: logs OTPs instead of sending real SMS.
 // Production: uses Amazon SNS (not yet wired — TF-1 follow-up).
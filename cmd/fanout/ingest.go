@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// ingestSink submits SMS replies through Ingest's PersistMessage, the same
+// path Gateway takes for client messages.
+type ingestSink struct {
+	client messagingv1.IngestServiceClient
+}
+
+func (s ingestSink) Submit(ctx context.Context, msg app.SMSReplySubmission) error {
+	_, err := s.client.PersistMessage(ctx, &messagingv1.PersistMessageRequest{
+		ChatId:           msg.ChatID.String(),
+		SenderId:         msg.SenderID.String(),
+		ClientMessageId:  msg.ClientMessageID,
+		ContentType:      messagingv1.ContentType_CONTENT_TYPE_TEXT,
+		Content:          msg.Content.Body(),
+		ServerReceivedAt: &messagingv1.Timestamp{Millis: msg.ReceivedAt.UnixMilli()},
+	})
+	if err != nil {
+		return fmt.Errorf("ingest: persist message: %w", err)
+	}
+	return nil
+}
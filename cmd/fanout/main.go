@@ -1,11 +1,15 @@
 // Package main is the entrypoint for the Fanout service.
-// Fanout consumes from Kafka and delivers messages to connected clients via Gateway.
+// Fanout consumes from Kafka and delivers messages to connected clients via Gateway,
+// pushes them to offline users and texts them to those who opted in to SMS
+// fallback, posting their SMS replies back through Ingest, and delivers the Gateway's activity signals under
+// users' privacy settings.
 package main
 
 import (
 	"context"
 	"fmt"
 	"os"
+	_ "time/tzdata" // DND schedules use IANA zones; scratch images have no zoneinfo
 
 	"github.com/aelexs/realtime-messaging-platform/internal/config"
 	"github.com/aelexs/realtime-messaging-platform/internal/server"
@@ -21,7 +25,9 @@ func main() {
 
 func run(ctx context.Context) error {
 	return server.Run(ctx, server.Params{
-		Name:           "fanout",
-		PortFromConfig: func(cfg *config.Config) int { return cfg.Fanout.HTTPPort },
+		Name:               "fanout",
+		PortFromConfig:     func(cfg *config.Config) int { return cfg.Fanout.HTTPPort },
+		GRPCPortFromConfig: func(cfg *config.Config) int { return cfg.Fanout.GRPCPort },
+		Setup:              setup,
 	}, server.Listeners{})
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// persistedEventType is the messages.persisted envelope event type.
+const persistedEventType = "MessagePersisted"
+
+// persistedTopic carries every persisted message (ADR-011).
+const persistedTopic = "messages.persisted"
+
+// persistedRegistry returns the schemas the delivery consumer decodes.
+func persistedRegistry() (*events.Registry, error) {
+	reg := events.NewRegistry()
+	err := reg.Register(events.Schema{
+		Type:    persistedEventType,
+		Version: 1,
+		New:     func() proto.Message { return &messagingv1.MessagePersistedEvent{} },
+	})
+	return reg, err
+}
+
+// decodePersisted decodes messages.persisted records into the wire form
+// the Gateway delivers to clients.
+func decodePersisted(reg *events.Registry) func([]byte) (protocol.Message, error) {
+	return func(value []byte) (protocol.Message, error) {
+		ev, err := reg.Decode(value)
+		if err != nil {
+			return protocol.Message{}, err
+		}
+		persisted, ok := ev.Payload.(*messagingv1.MessagePersistedEvent)
+		if !ok {
+			return protocol.Message{}, fmt.Errorf("%s: unexpected payload %T", persistedEventType, ev.Payload)
+		}
+		m := persisted.GetMessage()
+		contentType, err := contentTypeFromProto(m.GetContentType())
+		if err != nil {
+			return protocol.Message{}, fmt.Errorf("%s: %w", persistedEventType, err)
+		}
+		return protocol.Message{
+			MessageID:        m.GetMessageId(),
+			ChatID:           m.GetChatId(),
+			SenderID:         m.GetSenderId(),
+			ClientMessageID:  m.GetClientMessageId(),
+			Sequence:         m.GetSequence(),
+			ContentType:      string(contentType),
+			Content:          m.GetContent(),
+			CreatedAt:        m.GetCreatedAt().GetMillis(),
+			ServerReceivedAt: m.GetServerReceivedAt().GetMillis(),
+		}, nil
+	}
+}
+
+// contentTypeFromProto maps the proto enum to a domain content type.
+func contentTypeFromProto(ct messagingv1.ContentType) (domain.ContentType, error) {
+	switch ct {
+	case messagingv1.ContentType_CONTENT_TYPE_TEXT:
+		return domain.ContentTypeText, nil
+	case messagingv1.ContentType_CONTENT_TYPE_SYSTEM:
+		return domain.ContentTypeSystem, nil
+	default:
+		return "", fmt.Errorf("unsupported content type %s", ct)
+	}
+}
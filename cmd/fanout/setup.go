@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/port"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+)
+
+// membershipsTable is owned by Chat Mgmt; Fanout only reads it.
+const membershipsTable = "chat_memberships"
+
+// usersTable is owned by Chat Mgmt; Fanout reads privacy settings,
+// do-not-disturb schedules and SMS fallback settings, and turns SMS
+// fallback off for users who reply STOP.
+const usersTable = "users"
+
+// sessionsTable is owned by Chat Mgmt; SMS fallback reads users' last
+// activity from it.
+const sessionsTable = "sessions"
+
+// chatKeysTable holds the wrapped data keys users' personal data is
+// encrypted under.
+const chatKeysTable = "chat_keys"
+
+// deviceTokensTable is written by Chat Mgmt's token registration; Fanout
+// reads it and prunes tokens the push services reject.
+const deviceTokensTable = "device_tokens"
+
+// deliveryGroup is the consumer group delivering persisted messages to
+// the Gateways (ADR-002 §3.3).
+const deliveryGroup = "fanout-workers"
+
+// pushGroup is the consumer group notifying offline recipients of
+// persisted messages by push and SMS. It has its own offsets, so a paused
+// or slow notification path never holds delivery back.
+const pushGroup = "fanout-push"
+
+// setup is the fanout service composition root. It consumes
+// messages.persisted to deliver each message to the Gateways its
+// recipients are connected to, behind pause controls served on
+// /admin/consumers, monitors the delivery group's lag, and serves
+// PublishSignal to deliver the Gateways' activity signals under the
+// users' privacy settings. With a VAPID key or an SMS origination number
+// set it also consumes messages.persisted in a second group to notify
+// offline recipients: pushes are held during their do-not-disturb hours,
+// and SMS go to users who opted in to SMS fallback, whose replies are
+// posted back through Ingest.
+func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	cfg := deps.Config
+	logger := deps.Logger
+
+	// 1. Infrastructure clients.
+	dynamoClient, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fanout setup: create dynamo client: %w", err)
+	}
+
+	redisClient := redis.NewClient(redis.Config{
+		Addr:         cfg.Redis.Addr,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+	})
+
+	// 2. Consumer pause controls, for the consumers this process runs.
+	// Push runs only with a VAPID key: Web Push is the one platform with
+	// a provider. The push consumer also carries SMS fallback.
+	consumers := []string{app.ConsumerDelivery}
+	var vapidKey *ecdsa.PrivateKey
+	if cfg.Fanout.WebPush.Enabled() {
+		vapidKey, err = adapter.ParseVAPIDKey(cfg.Fanout.WebPush.VAPIDKey)
+		if err != nil {
+			_ = redisClient.Close()
+			return nil, fmt.Errorf("fanout setup: %w", err)
+		}
+	}
+	smsEnabled := cfg.Fanout.SMS.Enabled()
+	if vapidKey != nil || smsEnabled {
+		consumers = append(consumers, app.ConsumerPush)
+	}
+	control, err := app.NewConsumerControl(app.ConsumerControlConfig{
+		Logger:    observability.Subsystem(logger, "fanout/consumers"),
+		Consumers: consumers,
+		Paused:    cfg.Fanout.PausedConsumers,
+	})
+	if err != nil {
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("fanout setup: consumer control: %w", err)
+	}
+	deps.HTTPMux.Handle("/admin/consumers", port.ConsumerControlAdminHandler(control))
+
+	// 3. Delivery (ADR-002 §3.3, §3.4). Recipients are read from
+	// chat_memberships, their Gateways from the Redis routing table, and
+	// each Gateway gets the message on its delivery channel. Deliveries
+	// wait in per-partition priority lanes, shared with the activity
+	// signals below, so typing and presence bursts never hold back
+	// messages (ADR-009 §2).
+	registry, err := persistedRegistry()
+	if err != nil {
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("fanout setup: event registry: %w", err)
+	}
+	consumer, err := kafka.NewClient(kafka.Config{
+		Brokers:  cfg.Kafka.Brokers,
+		ClientID: cfg.Kafka.ClientID,
+		Group:    deliveryGroup,
+		Topics:   []string{cfg.Residency.Topic(persistedTopic)},
+	})
+	if err != nil {
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("fanout setup: kafka: %w", err)
+	}
+	deps.OnWarmup("kafka", 0, consumer.Ping)
+	memberships := adapter.NewMembershipStore(dynamoClient.DB, membershipsTable)
+	routeTable := adapter.NewRouteTable(redisClient.RDB, domain.RealClock{})
+	lanes := app.NewDeliveryLanes(app.DeliveryLanesConfig{
+		Gateways: adapter.NewGatewayChannels(redisClient.RDB),
+		Logger:   observability.Subsystem(logger, "fanout/delivery"),
+	})
+	delivery := port.NewDeliveryConsumer(port.DeliveryConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodePersisted(registry),
+		Lanes:    lanes,
+		Resolve: app.NewDispatcher(app.DispatcherConfig{
+			Members: memberships,
+			Routes:  routeTable,
+			Logger:  observability.Subsystem(logger, "fanout/delivery"),
+		}),
+		Logger:    observability.Subsystem(logger, "fanout/delivery"),
+		Control:   control,
+		Objective: slo.Deliver,
+	})
+
+	// 4. Activity signals (ADR-006 §3.3). Typing and read receipts go to
+	// the chat's other members, presence to everyone sharing a chat with
+	// the user; privacy settings are read from users and enforced before
+	// anything reaches a Gateway. New feed entries from ChatMgmt go to
+	// their user's connected devices the same way.
+	signals := app.NewSignalDispatcher(app.SignalDispatcherConfig{
+		Members:  memberships,
+		Contacts: memberships,
+		Privacy:  app.NewPrivacyFilter(adapter.NewPrivacyStore(dynamoClient.DB, usersTable)),
+		Routes:   routeTable,
+		Gateways: lanes,
+		Logger:   observability.Subsystem(logger, "fanout/signals"),
+	})
+	notifications := app.NewNotificationDispatcher(app.NotificationDispatcherConfig{
+		Routes:   routeTable,
+		Gateways: lanes,
+		Logger:   observability.Subsystem(logger, "fanout/notifications"),
+	})
+	messagingv1.RegisterFanoutServiceServer(deps.GRPCServer, port.NewSignalHandler(signals, notifications))
+
+	// 5. Consumer lag (ADR-012): metrics and alerts for the delivery
+	// group, and this instance's assignments on /admin/consumer-lag.
+	lag, err := app.NewLagMonitor(app.LagMonitorConfig{
+		Source: adapter.NewKafkaLagSource(consumer),
+		Logger: observability.Subsystem(logger, "fanout/lag"),
+	})
+	if err != nil {
+		consumer.Close()
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("fanout setup: lag monitor: %w", err)
+	}
+	deps.HTTPMux.Handle("/admin/consumer-lag", port.ConsumerLagAdminHandler(lag))
+
+	// 6. Push. Recipients with no Gateway route get a push
+	// on each of their registered devices; tokens the push service
+	// rejects are pruned. Pushes in a user's do-not-disturb hours are held
+	// and summarized when they end, and bursty chats collapse into one
+	// push per window. Held and coalesced pushes live in memory.
+	var push *app.PushDispatcher
+	if vapidKey != nil {
+		webPush, err := adapter.NewWebPushProvider(adapter.WebPushConfig{
+			Client:   &http.Client{Timeout: domain.PushTimeout},
+			VAPIDKey: vapidKey,
+			Subject:  cfg.Fanout.WebPush.Subject,
+		})
+		if err != nil {
+			_ = lag.Close()
+			consumer.Close()
+			_ = redisClient.Close()
+			return nil, fmt.Errorf("fanout setup: %w", err)
+		}
+		push = app.NewPushDispatcher(app.PushDispatcherConfig{
+			Sender: app.NewTokenSender(app.TokenSenderConfig{
+				Tokens:    adapter.NewPushTokenStore(dynamoClient.DB, deviceTokensTable),
+				Providers: map[domain.PushPlatform]app.PushProvider{domain.PushPlatformWebPush: webPush},
+				Logger:    observability.Subsystem(logger, "fanout/push"),
+			}),
+			Schedules: adapter.NewDNDStore(dynamoClient.DB, usersTable),
+			Clock:     domain.RealClock{},
+			Logger:    observability.Subsystem(logger, "fanout/push"),
+			Control:   control,
+		})
+	}
+
+	// 7. SMS fallback. Text messages are texted through SNS to offline
+	// recipients who opted in and have been inactive past their
+	// threshold, within daily caps kept in Redis. Each SMS makes its chat
+	// the phone's reply route, and replies arriving on the reply topic are
+	// posted there through Ingest. Phones and names encrypted at rest are
+	// read with the users' field cipher.
+	var sms *app.SMSFallback
+	var smsUsers *adapter.SMSUserStore
+	var ingestConn *grpc.ClientConn
+	if smsEnabled {
+		var fields *msgcrypt.FieldCipher
+		if cfg.PII.Enabled() {
+			fields, err = msgcrypt.NewAWSFieldCipher(dynamoClient, chatKeysTable, msgcrypt.UsersKeyScope, cfg.PII.KMSKeyID, cfg.PII.KeyCacheTTL)
+			if err != nil {
+				_ = lag.Close()
+				consumer.Close()
+				_ = redisClient.Close()
+				return nil, fmt.Errorf("fanout setup: create field cipher: %w", err)
+			}
+		}
+		smsUsers = adapter.NewSMSUserStore(dynamoClient.DB, usersTable, sessionsTable, fields,
+			msgcrypt.NewBlindIndex(cfg.PII.PhoneIndexKey()), cfg.PII.LegacyPhoneLookup)
+		replyRoutes := adapter.NewSMSReplyRoutes(redisClient.RDB)
+
+		// SNS uses AWS_REGION and, for LocalStack, AWS_ENDPOINT, with the
+		// DynamoDB client's credentials.
+		awsCfg := aws.Config{Region: cfg.AWS.Region, Credentials: dynamoClient.DB.Options().Credentials}
+		if cfg.AWS.Endpoint != "" {
+			awsCfg.BaseEndpoint = aws.String(cfg.AWS.Endpoint)
+		}
+		sms = app.NewSMSFallback(app.SMSFallbackConfig{
+			Recipients: smsUsers,
+			Sender:     adapter.NewSNSSMSSender(sns.NewFromConfig(awsCfg), cfg.Fanout.SMS.OriginationNumber),
+			Budget:     adapter.NewSMSBudget(redisClient.RDB),
+			Routes:     replyRoutes,
+			Logger:     observability.Subsystem(logger, "fanout/sms"),
+		})
+
+		if cfg.Fanout.SMS.ReplyTopicARN != "" {
+			ingestConn, err = grpc.NewClient(cfg.Fanout.IngestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				_ = lag.Close()
+				consumer.Close()
+				_ = redisClient.Close()
+				return nil, fmt.Errorf("fanout setup: ingest client: %w", err)
+			}
+			deps.HTTPMux.Handle(port.SMSReplyPath, port.SMSReplyWebhook(port.SMSWebhookConfig{
+				Replies: app.NewSMSReplyService(app.SMSReplyServiceConfig{
+					Accounts: smsUsers,
+					Routes:   replyRoutes,
+					Members:  memberships,
+					Sink:     ingestSink{client: messagingv1.NewIngestServiceClient(ingestConn)},
+					Logger:   observability.Subsystem(logger, "fanout/sms"),
+				}),
+				TopicARN: cfg.Fanout.SMS.ReplyTopicARN,
+			}))
+		}
+	}
+
+	// 8. Offline notifications: a second consumer of messages.persisted
+	// hands each message's offline recipients to push and SMS fallback.
+	var pushConsumer *kafka.Client
+	var offline *port.DeliveryConsumer
+	if push != nil || sms != nil {
+		pushConsumer, err = kafka.NewClient(kafka.Config{
+			Brokers:  cfg.Kafka.Brokers,
+			ClientID: cfg.Kafka.ClientID,
+			Group:    pushGroup,
+			Topics:   []string{cfg.Residency.Topic(persistedTopic)},
+		})
+		if err != nil {
+			if ingestConn != nil {
+				_ = ingestConn.Close()
+			}
+			_ = lag.Close()
+			consumer.Close()
+			_ = redisClient.Close()
+			return nil, fmt.Errorf("fanout setup: kafka push: %w", err)
+		}
+		notifier := app.OfflineNotifierConfig{
+			Members: memberships,
+			Routes:  routeTable,
+			Logger:  observability.Subsystem(logger, "fanout/push"),
+		}
+		// Typed nils would pass the notifier's nil checks.
+		if push != nil {
+			notifier.Push = push
+		}
+		if sms != nil {
+			notifier.SMS, notifier.Names = sms, smsUsers
+		}
+		offline = port.NewDeliveryConsumer(port.DeliveryConsumerConfig{
+			Consumer: pushConsumer,
+			Decode:   decodePersisted(registry),
+			Dispatch: app.NewOfflineNotifier(notifier),
+			Logger:   observability.Subsystem(logger, "fanout/push"),
+			Control:  control,
+			Name:     app.ConsumerPush,
+		})
+	}
+
+	// 9. Background loops, started once nothing above can fail. Each
+	// stops on cleanup; an unfinished delivery batch is redelivered to the
+	// next consumer.
+	bgCtx, stopBackground := context.WithCancel(context.WithoutCancel(ctx))
+	var background sync.WaitGroup
+	run := func(loop func(context.Context)) {
+		background.Add(1)
+		go func() { defer background.Done(); loop(bgCtx) }()
+	}
+	run(func(ctx context.Context) {
+		if err := delivery.Run(ctx); err != nil {
+			logger.ErrorContext(ctx, "delivery consumer stopped", "error", err)
+		}
+	})
+	run(lanes.Run)
+	run(func(ctx context.Context) { lag.Run(ctx, 0) })
+	if offline != nil {
+		run(func(ctx context.Context) {
+			if err := offline.Run(ctx); err != nil {
+				logger.ErrorContext(ctx, "push consumer stopped", "error", err)
+			}
+		})
+	}
+	if push != nil {
+		run(func(ctx context.Context) { push.Run(ctx, 0) })
+	}
+
+	logger.InfoContext(ctx, "fanout initialized",
+		"push", push != nil, "sms_fallback", sms != nil, "sms_replies", ingestConn != nil)
+
+	cleanup := func(_ context.Context) error {
+		stopBackground()
+		background.Wait()
+		_ = lag.Close()
+		consumer.Close()
+		if pushConsumer != nil {
+			pushConsumer.Close()
+		}
+		if ingestConn != nil {
+			_ = ingestConn.Close()
+		}
+		return redisClient.Close()
+	}
+	return cleanup, nil
+}
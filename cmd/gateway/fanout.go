@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// fanoutSignals publishes activity signals through Fanout's PublishSignal.
+// Fanout's status errors are turned back into domain errors, so a
+// non-member gets the same error frame it would for a local rejection.
+type fanoutSignals struct {
+	client messagingv1.FanoutServiceClient
+}
+
+func (s fanoutSignals) PublishSignal(ctx context.Context, sig app.Signal) error {
+	pb := &messagingv1.PublishSignalRequest{UserId: sig.UserID}
+	switch sig.Kind {
+	case domain.SignalTyping:
+		pb.Signal = &messagingv1.PublishSignalRequest_Typing{Typing: &messagingv1.TypingSignal{
+			ChatId: sig.ChatID,
+			Active: sig.Active,
+		}}
+	case domain.SignalReadReceipt:
+		pb.Signal = &messagingv1.PublishSignalRequest_ReadReceipt{ReadReceipt: &messagingv1.ReadReceiptSignal{
+			ChatId:   sig.ChatID,
+			Sequence: sig.Sequence,
+		}}
+	case domain.SignalLastSeen:
+		presence := &messagingv1.PresenceSignal{Online: sig.Online}
+		if !sig.Online && !sig.LastSeenAt.IsZero() {
+			presence.LastSeenAt = &messagingv1.Timestamp{Millis: sig.LastSeenAt.UnixMilli()}
+		}
+		pb.Signal = &messagingv1.PublishSignalRequest_Presence{Presence: presence}
+	default:
+		return fmt.Errorf("publish signal: unknown signal %d", sig.Kind)
+	}
+	if _, err := s.client.PublishSignal(ctx, pb); err != nil {
+		return errmap.FromGRPCStatus(err)
+	}
+	return nil
+}
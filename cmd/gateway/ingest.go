@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// ingestSender persists client messages through Ingest's PersistMessage.
+// Ingest's status errors are turned back into domain errors, so the client
+// gets the same error frame it would for a local rejection.
+type ingestSender struct {
+	client messagingv1.IngestServiceClient
+}
+
+func (s ingestSender) SendMessage(ctx context.Context, req app.SendRequest) (app.SendResult, error) {
+	pb := &messagingv1.PersistMessageRequest{
+		ChatId:          req.ChatID,
+		SenderId:        req.SenderID,
+		ClientMessageId: req.ClientMessageID,
+		ContentType:     messagingv1.ContentType_CONTENT_TYPE_TEXT,
+		Content:         req.Content.Body(),
+	}
+	if !req.ReceivedAt.IsZero() {
+		pb.ServerReceivedAt = &messagingv1.Timestamp{Millis: req.ReceivedAt.UnixMilli()}
+	}
+	resp, err := s.client.PersistMessage(ctx, pb)
+	if err != nil {
+		return app.SendResult{}, errmap.FromGRPCStatus(err)
+	}
+	return app.SendResult{
+		MessageID: resp.GetMessageId(),
+		Sequence:  resp.GetSequence(),
+		CreatedAt: domain.FromMillis(resp.GetCreatedAt().GetMillis()),
+		Duplicate: resp.GetIsDuplicate(),
+	}, nil
+}
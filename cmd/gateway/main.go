@@ -21,7 +21,9 @@ func main() {
 
 func run(ctx context.Context) error {
 	return server.Run(ctx, server.Params{
-		Name:           "gateway",
-		PortFromConfig: func(cfg *config.Config) int { return cfg.Gateway.HTTPPort },
+		Name:               "gateway",
+		PortFromConfig:     func(cfg *config.Config) int { return cfg.Gateway.HTTPPort },
+		GRPCPortFromConfig: func(cfg *config.Config) int { return cfg.Gateway.GRPCPort },
+		Setup:              setup,
 	}, server.Listeners{})
 }
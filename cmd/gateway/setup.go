@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	chatmgmtadapter "github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/port"
+	"github.com/aelexs/realtime-messaging-platform/internal/ipscreen"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// sessionsTable is owned by Chat Mgmt; the Gateway only writes
+// last_active_at.
+const sessionsTable = "sessions"
+
+// deliveryStateTable checkpoints each device's delivery cursors.
+const deliveryStateTable = "delivery_state"
+
+// membershipsTable is owned by Chat Mgmt; the Gateway only reads it.
+const membershipsTable = "chat_memberships"
+
+// usersTable is owned by Chat Mgmt; the Gateway only reads account ages.
+const usersTable = "users"
+
+// Tables syncs are served from. Ingest writes messages and sequences, Chat
+// Mgmt owns chats; the Gateway only reads them.
+const (
+	messagesTable = "messages_v2"
+	chatsTable    = "chats"
+	countersTable = "chat_counters"
+	chatKeysTable = "chat_keys"
+)
+
+// setup is the gateway service composition root. It creates the connection
+// registry shared by all client transports, routes Fanout deliveries to
+// it, starts admission control, session activity reporting, delivery
+// cursor persistence and connection quota renewal, screens client messages
+// for spam and persists them through Ingest, publishes typing, read
+// receipts and presence through Fanout, answers sync requests from
+// the message tables, routes chunked attachment uploads when a bucket is
+// set, serves client sessions over WebSocket and the gRPC Connect stream,
+// and registers the coordinated connection drain that runs on shutdown.
+func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	cfg := deps.Config
+	logger := deps.Logger
+
+	// 1. Infrastructure clients.
+	dynamoClient, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gateway setup: create dynamo client: %w", err)
+	}
+
+	redisClient := redis.NewClient(redis.Config{
+		Addr:         cfg.Redis.Addr,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+	})
+
+	// AWS clients other than DynamoDB use AWS_REGION and, for LocalStack,
+	// AWS_ENDPOINT, with the DynamoDB client's credentials.
+	awsCfg := aws.Config{Region: cfg.AWS.Region, Credentials: dynamoClient.DB.Options().Credentials}
+	if cfg.AWS.Endpoint != "" {
+		awsCfg.BaseEndpoint = aws.String(cfg.AWS.Endpoint)
+	}
+
+	// 2. Connection registry + drain coordination (ADR-014 §4.1).
+	// The pod hostname identifies this instance as a drain slot holder.
+	instanceID, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("gateway setup: resolve instance ID: %w", err)
+	}
+
+	registry := app.NewRegistry()
+	drainer := app.NewDrainCoordinator(app.DrainCoordinatorConfig{
+		Registry:   registry,
+		Semaphore:  adapter.NewDrainSemaphore(redisClient.RDB, cfg.Gateway.Drain.Slots, domain.RealClock{}),
+		Logger:     observability.Subsystem(logger, "gateway/drain"),
+		InstanceID: instanceID,
+		Wait:       cfg.Gateway.Drain.Wait,
+	})
+	deps.OnDrain(drainer.Drain)
+
+	// Delivery routing (ADR-002 §3.4, ADR-010 §1.6). Connected users are
+	// advertised in the Redis routing table, renewed every heartbeat
+	// interval, and Fanout publishes their messages and activity signals
+	// to this instance's delivery channel. Users going online and offline
+	// are announced through Fanout, which applies their privacy settings.
+	fanoutConn, err := grpc.NewClient(cfg.Gateway.FanoutAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("gateway setup: fanout client: %w", err)
+	}
+	signals := fanoutSignals{client: messagingv1.NewFanoutServiceClient(fanoutConn)}
+	routes := app.NewDeliveryRouter(app.DeliveryRouterConfig{
+		Registry:   registry,
+		Routes:     adapter.NewRouteTable(redisClient.RDB, domain.RealClock{}),
+		InstanceID: instanceID,
+		Logger:     observability.Subsystem(logger, "gateway/delivery"),
+		Signals:    signals,
+		Clock:      domain.RealClock{},
+	})
+	deliveries := adapter.NewDeliverySubscriber(redisClient.RDB, instanceID, observability.Subsystem(logger, "gateway/delivery"))
+
+	// 3. Admission control (ADR-009 §2). The session manager refuses
+	// connections and sheds ephemeral frames with it; sampling stops on
+	// cleanup.
+	admission := app.NewAdmissionController(app.AdmissionControllerConfig{
+		Sampler:       adapter.NewRuntimeSampler(),
+		Logger:        observability.Subsystem(logger, "gateway/admission"),
+		MaxCPU:        cfg.Gateway.Admission.MaxCPU,
+		MaxMemory:     cfg.Gateway.Admission.MaxMemory,
+		MaxGoroutines: cfg.Gateway.Admission.MaxGoroutines,
+		ShedRatio:     cfg.Gateway.Admission.ShedRatio,
+	})
+
+	// 4. Session activity (ADR-015). Sessions report connects and
+	// heartbeats; last-active times are batched into the Chat Mgmt
+	// sessions table, and the final flush runs on cleanup.
+	activity := app.NewActivityTracker(app.ActivityTrackerConfig{
+		Store:  adapter.NewSessionActivityStore(dynamoClient.DB, sessionsTable),
+		Clock:  domain.RealClock{},
+		Logger: observability.Subsystem(logger, "gateway/activity"),
+	})
+
+	// 5. Delivery cursors (ADR-007 §2.7). Sessions record each device's
+	// acks; they are written to Redis every second and checkpointed to
+	// DynamoDB every minute, and the final write runs on cleanup.
+	cursors := app.NewCursorTracker(app.CursorTrackerConfig{
+		Cache:      adapter.NewCursorCache(redisClient.RDB, domain.DeliveryCursorTTL),
+		Checkpoint: adapter.NewDeliveryCursorStore(dynamoClient.DB, deliveryStateTable),
+		Logger:     observability.Subsystem(logger, "gateway/cursors"),
+	})
+
+	// 6. Connection quotas (ADR-009 §3). Each connection leases a slot per
+	// user, device and client address in Redis; held leases are renewed
+	// every heartbeat interval until cleanup.
+	quotas := app.NewConnectionQuotas(app.ConnectionQuotasConfig{
+		Store:  adapter.NewConnectionLeases(redisClient.RDB, domain.RealClock{}),
+		Logger: observability.Subsystem(logger, "gateway/quotas"),
+		Limits: app.ConnectionLimits{
+			PerUser:   cfg.Gateway.Quota.PerUser,
+			PerDevice: cfg.Gateway.Quota.PerDevice,
+			PerIP:     cfg.Gateway.Quota.PerIP,
+		},
+	})
+
+	// 7. Frame handlers. send_message frames are screened by the spam
+	// heuristics, validated here and persisted through Ingest. Spam
+	// decisions are logged as gateway.spam_decision and idle senders are
+	// forgotten every minute. sync_request frames are answered from the
+	// message tables Ingest writes, starting no later than the device's
+	// delivery cursor; encrypted messages are opened with the
+	// chat keyring once MESSAGES_KMSKEYID is set. typing and receipt
+	// frames are published through Fanout. Chunked attachment uploads (ADR-005 §3.13)
+	// are enabled by an upload bucket: parts are stored in S3 and progress
+	// in Redis, so an upload resumes on any pod. Only WebSocket clients can
+	// negotiate uploads; the Connect stream has no upload frames. Every
+	// user is capped at domain.MaxAttachmentSize until entitlements reach
+	// the Gateway.
+	var opener msgcrypt.Opener
+	if cfg.Messages.Enabled() {
+		keyring, err := msgcrypt.NewAWS(dynamoClient, chatKeysTable, msgcrypt.Config{
+			KeyID:       cfg.Messages.KMSKeyID,
+			RotateAfter: cfg.Messages.KeyRotation,
+			CacheTTL:    cfg.Messages.KeyCacheTTL,
+		})
+		if err != nil {
+			_ = fanoutConn.Close()
+			return nil, fmt.Errorf("gateway setup: create keyring: %w", err)
+		}
+		opener = keyring
+	}
+	members := adapter.NewMembershipStore(dynamoClient.DB, membershipsTable)
+	ingestConn, err := grpc.NewClient(cfg.Gateway.IngestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		_ = fanoutConn.Close()
+		return nil, fmt.Errorf("gateway setup: ingest client: %w", err)
+	}
+	spam := app.NewSpamGuard(app.SpamGuardConfig{
+		Accounts: adapter.NewAccountAgeStore(dynamoClient.DB, usersTable),
+		Clock:    domain.RealClock{},
+		Logger:   observability.Subsystem(logger, "gateway/spam"),
+	})
+	handlers := map[protocol.FrameType]app.FrameHandler{
+		protocol.FrameTypeSendMessage: port.MeasureSLO(slo.SendMessage,
+			spam.Wrap(app.NewSendHandler(ingestSender{client: messagingv1.NewIngestServiceClient(ingestConn)}))),
+		protocol.FrameTypeSyncRequest: app.NewSyncHandler(app.SyncHandlerConfig{
+			Store:   adapter.NewSyncStore(dynamoClient.DB, messagesTable, chatsTable, countersTable, opener),
+			Members: members,
+			Cursors: cursors,
+		}),
+	}
+	signalHandler := app.NewSignalHandler(signals, observability.Subsystem(logger, "gateway/signals"))
+	handlers[protocol.FrameTypeTyping] = signalHandler
+	handlers[protocol.FrameTypeReceipt] = signalHandler
+	uploadsEnabled := cfg.Gateway.Upload.Bucket != ""
+	if uploadsEnabled {
+		// LocalStack serves buckets path-style only.
+		attachments := s3.NewFromConfig(awsCfg, func(o *s3.Options) { o.UsePathStyle = awsCfg.BaseEndpoint != nil })
+		uploads := app.NewUploadHandler(app.UploadHandlerConfig{
+			Store:    adapter.NewS3Attachments(attachments, cfg.Gateway.Upload.Bucket),
+			Sessions: adapter.NewUploadSessions(redisClient.RDB, domain.UploadSessionTTL),
+			Members:  members,
+			Logger:   observability.Subsystem(logger, "gateway/uploads"),
+		})
+		handlers[protocol.FrameTypeUploadBegin] = uploads
+		handlers[protocol.FrameTypeUploadChunk] = uploads
+		handlers[protocol.FrameTypeUploadCommit] = uploads
+	}
+
+	// 8. Client sessions over WebSocket and the gRPC Connect stream
+	// (ADR-005). Access tokens are verified against the keys Chat Mgmt
+	// signs them with, and checked against its revocations in Redis on
+	// every connect, so a cached token is refused once revoked.
+	// Overloaded pods refuse upgrades before any work. Unacked messages
+	// of a closed connection are resent only if the device reconnects to
+	// this pod within GATEWAY_ACK_RETENTION; otherwise it catches up
+	// with sync.
+	keyStore, err := chatmgmtadapter.NewAWSKeyStoreFromConfig(ctx, awsCfg, domain.RealClock{})
+	if err != nil {
+		_ = ingestConn.Close()
+		_ = fanoutConn.Close()
+		return nil, fmt.Errorf("gateway setup: load token keys: %w", err)
+	}
+	validator := auth.NewValidator(auth.ValidatorConfig{
+		KeyStore: keyStore,
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		Clock:    domain.RealClock{},
+	})
+	var tokenCache *app.TokenCache
+	if cfg.Gateway.AuthCache.Entries > 0 {
+		tokenCache = app.NewTokenCache(cfg.Gateway.AuthCache.Entries, domain.RealClock{})
+	}
+	authenticator := app.NewTokenAuthenticator(validator, tokenCache,
+		chatmgmtadapter.NewRevocationStore(redisClient.RDB))
+	// Connections are read by a goroutine each or, with reader mode epoll,
+	// by a shared worker pool (Linux only). The pool stops on cleanup.
+	var reader app.ReadScheduler
+	var epoll *adapter.EpollReader
+	if cfg.Gateway.Reader.Mode == "epoll" {
+		epoll, err = adapter.NewEpollReader(cfg.Gateway.Reader.Workers, domain.EpollFrameTimeout)
+		if err != nil {
+			_ = ingestConn.Close()
+			_ = fanoutConn.Close()
+			return nil, fmt.Errorf("gateway setup: %w", err)
+		}
+		reader = epoll
+	}
+	sessions := app.NewSessionManager(app.SessionManagerConfig{
+		Registry:      registry,
+		Authenticator: authenticator,
+		Admission:     admission,
+		Quotas:        quotas,
+		Routes:        routes,
+		Activity:      activity,
+		Resend:        app.NewResendBuffer(cfg.Gateway.Ack.Retention),
+		Cursors:       cursors,
+		Reader:        reader,
+		Clock:         domain.RealClock{},
+		Logger:        observability.Subsystem(logger, "gateway/session"),
+		Handlers:      handlers,
+		ErrorFrame:    errmap.ToProtocolError,
+		Reconnect: &protocol.ReconnectPolicy{
+			MinBackoffMs: cfg.Gateway.Reconnect.MinBackoff.Milliseconds(),
+			MaxBackoffMs: cfg.Gateway.Reconnect.MaxBackoff.Milliseconds(),
+			Jitter:       cfg.Gateway.Reconnect.Jitter,
+			RetryBudget:  cfg.Gateway.Reconnect.Budget,
+		},
+		AccessTokenTTL:  cfg.Auth.Access.TTL,
+		RefreshTokenTTL: cfg.Auth.Refresh.TTL,
+		AckWindow:       cfg.Gateway.Ack.Window,
+		MaxBatchFrames:  cfg.Gateway.Batch.MaxFrames,
+		MaxBatchDelay:   cfg.Gateway.Batch.MaxDelay,
+	})
+	// Load already validated the trusted proxy ranges and IP lists.
+	// Upgrades from blocked ranges are refused before admission.
+	clientIPs, err := domain.NewClientIPResolver(cfg.Gateway.IP.TrustedProxies)
+	if err != nil {
+		_ = ingestConn.Close()
+		_ = fanoutConn.Close()
+		return nil, fmt.Errorf("gateway setup: trusted proxies: %w", err)
+	}
+	ipPolicy, err := domain.NewIPPolicy(cfg.Gateway.IP.Policy())
+	if err != nil {
+		_ = ingestConn.Close()
+		_ = fanoutConn.Close()
+		return nil, fmt.Errorf("gateway setup: ip policy: %w", err)
+	}
+	ipScreener := ipscreen.New(ipscreen.Config{
+		Policy: ipPolicy,
+		Logger: observability.Subsystem(logger, "gateway/ipscreen"),
+	})
+	deps.Route("GET "+port.WebSocketPath, deps.Limits.Streaming,
+		port.IPScreenMiddleware(ipScreener, clientIPs,
+			port.AdmissionMiddleware(admission, port.NewWebSocketHandler(sessions, clientIPs))))
+	messagingv1.RegisterConnectionServiceServer(deps.GRPCServer, port.NewConnectHandler(sessions))
+
+	// 9. Background loops, started once nothing above can fail. Each
+	// stops on cleanup.
+	bgCtx, stopBackground := context.WithCancel(context.WithoutCancel(ctx))
+	var background sync.WaitGroup
+	run := func(loop func(context.Context)) {
+		background.Add(1)
+		go func() { defer background.Done(); loop(bgCtx) }()
+	}
+	run(admission.Run)
+	run(activity.Run)
+	run(cursors.Run)
+	run(quotas.Run)
+	run(spam.Run)
+	run(routes.Run)
+	run(func(ctx context.Context) { deliveries.Run(ctx, routes.Deliver) })
+	if epoll != nil {
+		run(func(ctx context.Context) {
+			if err := epoll.Run(ctx); err != nil {
+				logger.ErrorContext(ctx, "epoll reader stopped", "error", err)
+			}
+		})
+	}
+
+	logger.InfoContext(ctx, "gateway initialized", "instance_id", instanceID, "uploads", uploadsEnabled)
+
+	cleanup := func(_ context.Context) error {
+		stopBackground()
+		background.Wait()
+		if epoll != nil {
+			_ = epoll.Close()
+		}
+		_ = ingestConn.Close()
+		_ = fanoutConn.Close()
+		return redisClient.Close()
+	}
+
+	return cleanup, nil
+}
@@ -21,7 +21,9 @@ func main() {
 
 func run(ctx context.Context) error {
 	return server.Run(ctx, server.Params{
-		Name:           "ingest",
-		PortFromConfig: func(cfg *config.Config) int { return cfg.Ingest.HTTPPort },
+		Name:               "ingest",
+		PortFromConfig:     func(cfg *config.Config) int { return cfg.Ingest.HTTPPort },
+		GRPCPortFromConfig: func(cfg *config.Config) int { return cfg.Ingest.GRPCPort },
+		Setup:              setup,
 	}, server.Listeners{})
 }
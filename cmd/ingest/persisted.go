@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+// persistedEventType is the messages.persisted envelope event type.
+const persistedEventType = "MessagePersisted"
+
+// persistedRegistry returns the schemas Ingest produces.
+func persistedRegistry() (*events.Registry, error) {
+	reg := events.NewRegistry()
+	err := reg.Register(events.Schema{
+		Type:    persistedEventType,
+		Version: 1,
+		New:     func() proto.Message { return &messagingv1.MessagePersistedEvent{} },
+	})
+	return reg, err
+}
+
+// persistedPublisher produces MessagePersisted events keyed by chat ID, so
+// each chat's messages stay ordered within a partition (ADR-011).
+type persistedPublisher struct {
+	producer kafka.Producer
+	encoder  *events.Encoder
+	topic    string
+	clock    domain.Clock
+}
+
+func (p persistedPublisher) Publish(ctx context.Context, req app.PersistRequest, res app.PersistResult) error {
+	msg := &messagingv1.Message{
+		MessageId:       res.MessageID.String(),
+		ChatId:          req.ChatID.String(),
+		SenderId:        req.SenderID.String(),
+		ClientMessageId: req.ClientMessageID,
+		Sequence:        res.Sequence,
+		ContentType:     contentTypeToProto(req.Content.ContentType()),
+		Content:         req.Content.Body(),
+		CreatedAt:       &messagingv1.Timestamp{Millis: res.CreatedAt.UnixMilli()},
+	}
+	if !req.ReceivedAt.IsZero() {
+		msg.ServerReceivedAt = &messagingv1.Timestamp{Millis: req.ReceivedAt.UnixMilli()}
+	}
+	env, err := p.encoder.Encode(ctx, req.ChatID.String(), &messagingv1.MessagePersistedEvent{
+		Message:   msg,
+		EventTime: &messagingv1.Timestamp{Millis: domain.NowUTCMillis(p.clock)},
+	})
+	if err != nil {
+		return fmt.Errorf("publish persisted: %w", err)
+	}
+	return p.producer.Produce(ctx, &kafka.Record{
+		Topic: p.topic,
+		Key:   []byte(req.ChatID.String()),
+		Value: env.Marshal(),
+	})
+}
+
+// contentTypeToProto maps a domain content type to the proto enum.
+func contentTypeToProto(ct domain.ContentType) messagingv1.ContentType {
+	switch ct {
+	case domain.ContentTypeText:
+		return messagingv1.ContentType_CONTENT_TYPE_TEXT
+	case domain.ContentTypeSystem:
+		return messagingv1.ContentType_CONTENT_TYPE_SYSTEM
+	default:
+		return messagingv1.ContentType_CONTENT_TYPE_UNSPECIFIED
+	}
+}
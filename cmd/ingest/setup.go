@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/analytics"
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/port"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+// Table names match the LocalStack init script (scripts/localstack-init.sh).
+const (
+	messagesTable    = "messages_v2"
+	chatsTable       = "chats"
+	countersTable    = "chat_counters"
+	idempotencyTable = "idempotency_keys"
+	membershipsTable = "chat_memberships" // owned by Chat Mgmt; read only
+	chatKeysTable    = "chat_keys"
+)
+
+// persistedTopic carries every persisted message (ADR-011).
+const persistedTopic = "messages.persisted"
+
+// setup is the ingest service composition root. It builds the persister
+// chain behind PersistMessage: analytics reporting, then slow mode, then
+// optional shadow traffic, then the ADR-004 persist flow, which stores
+// messages in DynamoDB and publishes them to messages.persisted.
+func setup(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	cfg := deps.Config
+	logger := deps.Logger
+	clock := domain.RealClock{}
+
+	// 1. Infrastructure clients.
+	dynamoClient, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ingest setup: create dynamo client: %w", err)
+	}
+	redisClient := redis.NewClient(redis.Config{
+		Addr:         cfg.Redis.Addr,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		ReadTimeout:  cfg.Redis.Timeout,
+		WriteTimeout: cfg.Redis.Timeout,
+	})
+	deps.OnWarmup("dynamodb", 0, func(ctx context.Context) error {
+		return dynamoClient.Warmup(ctx, messagesTable, chatsTable, countersTable, idempotencyTable, membershipsTable)
+	})
+	deps.OnWarmup("redis", 0, redisClient.Ping)
+
+	// 2. Persist flow (ADR-004). Content is sealed with the chat keyring
+	// once MESSAGES_KMSKEYID is set; new message IDs follow
+	// INGEST_MESSAGEIDSCHEME.
+	var sealer msgcrypt.Sealer
+	if cfg.Messages.Enabled() {
+		keyring, err := msgcrypt.NewAWS(dynamoClient, chatKeysTable, msgcrypt.Config{
+			KeyID:       cfg.Messages.KMSKeyID,
+			RotateAfter: cfg.Messages.KeyRotation,
+			CacheTTL:    cfg.Messages.KeyCacheTTL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ingest setup: create keyring: %w", err)
+		}
+		sealer = keyring
+	}
+	ids, err := domain.NewMessageIDGenerator(cfg.Ingest.MessageIDScheme, clock)
+	if err != nil {
+		return nil, fmt.Errorf("ingest setup: message IDs: %w", err)
+	}
+	registry, err := persistedRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("ingest setup: event registry: %w", err)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("ingest setup: resolve producer ID: %w", err)
+	}
+	producer, err := kafka.NewClient(kafka.Config{
+		Brokers:        cfg.Kafka.Brokers,
+		ClientID:       cfg.Kafka.ClientID,
+		ProduceTimeout: domain.KafkaProduceTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ingest setup: create kafka client: %w", err)
+	}
+	deps.OnWarmup("kafka", 0, producer.Ping)
+	members := adapter.NewMembershipStore(dynamoClient.DB, membershipsTable)
+	store := adapter.NewPersistStore(dynamoClient.DB, messagesTable, chatsTable, countersTable, idempotencyTable, sealer, clock)
+	var persister app.Persister = app.NewPersistService(app.PersistServiceConfig{
+		Idempotency: store,
+		Members:     members,
+		Sequences:   store,
+		Messages:    store,
+		Publisher: persistedPublisher{
+			producer: producer,
+			encoder: events.NewEncoder(events.EncoderConfig{
+				Registry:   registry,
+				Clock:      clock,
+				ProducerID: "ingest-" + hostname,
+			}),
+			topic: cfg.Residency.Topic(persistedTopic),
+			clock: clock,
+		},
+		Clock:      clock,
+		MessageIDs: ids,
+	})
+
+	// 3. Shadow traffic, off unless INGEST_SHADOW_PERCENT is set. Sampled
+	// chats are also persisted, unpublished, to the shadow tables and the
+	// results compared. Cleanup waits for in-flight shadow writes.
+	var shadow *app.ShadowPersister
+	if sc := cfg.Ingest.Shadow; sc.Enabled() {
+		deps.OnWarmup("dynamodb-shadow", 0, func(ctx context.Context) error {
+			return dynamoClient.Warmup(ctx, sc.MessagesTable, sc.CountersTable, sc.IdempotencyTable)
+		})
+		shadowStore := adapter.NewPersistStore(dynamoClient.DB, sc.MessagesTable, chatsTable, sc.CountersTable, sc.IdempotencyTable, sealer, clock)
+		shadow = app.NewShadowPersister(app.ShadowPersisterConfig{
+			Primary: persister,
+			Shadow: app.NewPersistService(app.PersistServiceConfig{
+				Idempotency: shadowStore,
+				Members:     members,
+				Sequences:   shadowStore,
+				Messages:    shadowStore,
+				Clock:       clock,
+				MessageIDs:  ids,
+			}),
+			Percent:     sc.Percent,
+			Timeout:     sc.Timeout,
+			MaxInFlight: sc.MaxInFlight,
+			Logger:      observability.Subsystem(logger, "ingest/shadow"),
+		})
+		persister = shadow
+	}
+
+	// 4. Slow mode (ADR-009), in front of the persist flow so a rejected
+	// message takes no sequence. Last posts are tracked in Redis.
+	persister = app.NewSlowModePersister(app.SlowModePersisterConfig{
+		Next:     persister,
+		Policies: adapter.NewChatPolicyStore(dynamoClient.DB, chatsTable, membershipsTable),
+		Store:    adapter.NewSlowModeStore(redisClient.RDB),
+		Logger:   observability.Subsystem(logger, "ingest/slowmode"),
+	})
+
+	// 5. Product analytics, off unless ANALYTICS_TOPIC is set. The emitter
+	// flushes what it has queued on cleanup.
+	emitter, stopAnalytics, err := startAnalytics(ctx, cfg, logger)
+	if err != nil {
+		producer.Close()
+		return nil, fmt.Errorf("ingest setup: %w", err)
+	}
+	if emitter != nil {
+		persister = app.NewAnalyticsPersister(persister, emitter)
+	}
+
+	// 6. Register gRPC.
+	messagingv1.RegisterIngestServiceServer(deps.GRPCServer, port.NewIngestHandler(persister))
+
+	logger.InfoContext(ctx, "ingest initialized",
+		slog.String("message_id_scheme", string(cfg.Ingest.MessageIDScheme)),
+		slog.Bool("sealed", sealer != nil),
+		slog.Float64("shadow_percent", cfg.Ingest.Shadow.Percent))
+
+	cleanup := func(_ context.Context) error {
+		if shadow != nil {
+			shadow.Close()
+		}
+		stopAnalytics()
+		producer.Close()
+		return redisClient.Close()
+	}
+	return cleanup, nil
+}
+
+// startAnalytics creates the analytics emitter and runs it until the
+// returned stop function is called. It returns a nil emitter and a no-op
+// stop when analytics is disabled.
+func startAnalytics(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*analytics.Emitter, func(), error) {
+	if !cfg.Analytics.Enabled() {
+		return nil, func() {}, nil
+	}
+
+	// Load already validated the overrides.
+	rates := make(map[string]float64, len(cfg.Analytics.SampleRates))
+	for _, entry := range cfg.Analytics.SampleRates {
+		event, rate, err := config.ParseSampleRateOverride(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		rates[event] = rate
+	}
+
+	producer, err := kafka.NewClient(kafka.Config{
+		Brokers:        cfg.Kafka.Brokers,
+		ClientID:       cfg.Kafka.ClientID,
+		ProduceTimeout: domain.KafkaProduceTimeout,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("analytics kafka: %w", err)
+	}
+	emitter, err := analytics.New(analytics.Config{
+		Producer:     producer,
+		Topic:        cfg.Residency.Topic(cfg.Analytics.Topic),
+		PseudonymKey: []byte(cfg.Analytics.PseudonymKey),
+		SampleRate:   cfg.Analytics.SampleRate,
+		Rates:        rates,
+		Logger:       observability.Subsystem(logger, "ingest/analytics"),
+	})
+	if err != nil {
+		producer.Close()
+		return nil, nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = emitter.Run(runCtx)
+	}()
+	logger.InfoContext(ctx, "analytics enabled", slog.String("topic", cfg.Residency.Topic(cfg.Analytics.Topic)))
+
+	return emitter, func() {
+		cancel()
+		<-done
+		producer.Close()
+	}, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+)
+
+// maxResponseBytes bounds a response body msgctl reads.
+const maxResponseBytes = 1 << 20
+
+// health probes /healthz and /readyz of every service. A service that
+// cannot be reached is reported, not treated as a failure of the command.
+func (c *ctl) health(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	for _, svc := range c.services {
+		for _, path := range []string{"/healthz", "/readyz"} {
+			status, body, err := c.get(ctx, svc.url+path, false)
+			if err != nil {
+				fmt.Fprintf(tw, "%s\t%s\t-\t%v\n", svc.name, path, err)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", svc.name, path, status, strings.TrimSpace(string(body)))
+		}
+	}
+	return tw.Flush()
+}
+
+// keysResponse is the body of chatmgmt's GET /admin/keys.
+type keysResponse struct {
+	SigningKeyID string   `json:"signing_key_id"`
+	KeyIDs       []string `json:"key_ids"`
+}
+
+// tokenView is what keys and session show of an access token. The token is
+// decoded without verifying its signature.
+type tokenView struct {
+	KeyID     string `json:"kid"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	Issuer    string `json:"iss"`
+	IssuedAt  string `json:"iat,omitempty"`
+	ExpiresAt string `json:"exp,omitempty"`
+	Expired   bool   `json:"expired"`
+	// Accepted is whether chatmgmt verifies tokens signed with KeyID.
+	Accepted *bool `json:"accepted,omitempty"`
+}
+
+// keys shows the key IDs chatmgmt signs and verifies with and, given an
+// access token, whether the token's kid is among them.
+func (c *ctl) keys(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	var tok tokenView
+	if len(args) == 1 {
+		var err error
+		if tok, err = decodeToken(args[0]); err != nil {
+			return err
+		}
+	}
+	base, err := c.serviceURL("chatmgmt")
+	if err != nil {
+		return err
+	}
+	var keys keysResponse
+	if err := c.adminGet(ctx, base+"/admin/keys", &keys); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return c.print(keys)
+	}
+
+	accepted := slices.Contains(keys.KeyIDs, tok.KeyID)
+	tok.Accepted = &accepted
+	return c.print(struct {
+		keysResponse
+		Token tokenView `json:"token"`
+	}{keys, tok})
+}
+
+// decodeToken reads an access token's header and claims without verifying
+// it; msgctl holds no keys.
+func decodeToken(raw string) (tokenView, error) {
+	var claims auth.Claims
+	tok, _, err := jwt.NewParser().ParseUnverified(raw, &claims)
+	if err != nil {
+		return tokenView{}, fmt.Errorf("decode token: %w", err)
+	}
+	view := tokenView{Subject: claims.Subject, SessionID: claims.SessionID, Issuer: claims.Issuer}
+	view.KeyID, _ = tok.Header["kid"].(string)
+	if claims.IssuedAt != nil {
+		view.IssuedAt = claims.IssuedAt.UTC().Format(time.RFC3339)
+	}
+	if claims.ExpiresAt != nil {
+		view.ExpiresAt = claims.ExpiresAt.UTC().Format(time.RFC3339)
+		view.Expired = time.Now().After(claims.ExpiresAt.Time)
+	}
+	return view, nil
+}
+
+func (c *ctl) serviceURL(name string) (string, error) {
+	for _, svc := range c.services {
+		if svc.name == name {
+			return svc.url, nil
+		}
+	}
+	return "", fmt.Errorf("no %s entry in -services", name)
+}
+
+// adminGet fetches an /admin endpoint and decodes its JSON body into v.
+func (c *ctl) adminGet(ctx context.Context, url string, v any) error {
+	status, body, err := c.get(ctx, url, true)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		if status == http.StatusUnauthorized {
+			return fmt.Errorf("GET %s: unauthorized; check -token", url)
+		}
+		return fmt.Errorf("GET %s: status %d: %s", url, status, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("GET %s: decode response: %w", url, err)
+	}
+	return nil
+}
+
+func (c *ctl) get(ctx context.Context, url string, admin bool) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if admin && c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return 0, nil, fmt.Errorf("GET %s: read response: %w", url, err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// print writes v as indented JSON.
+func (c *ctl) print(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
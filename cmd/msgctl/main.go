@@ -0,0 +1,225 @@
+// Package main is msgctl, an operator CLI for inspecting a running
+// deployment while debugging an incident.
+//
+//	msgctl [flags] <command> [args]
+//	msgctl [flags]                  # interactive prompt
+//
+// Commands:
+//
+//	health                          /healthz and /readyz of every service
+//	keys [<access-token>]           JWT key IDs; with a token, whether its kid is accepted
+//	session <session-id|token>      a session record
+//	sync <user-id>                  a user's sessions, chats and unread notifications
+//	tail <chat-id> [<after-seq>]    print a chat's messages and follow new ones
+//	send <chat-id> <sender-id> <text...>  persist a test message through Ingest
+//
+// Calls to /admin endpoints send -token (default $MSGCTL_TOKEN) as a
+// bearer token; it must match the services' ADMINTOKEN. session, sync and
+// tail read DynamoDB directly with the operator's AWS credentials,
+// configured like the services (DYNAMODB_ENDPOINT, AWS_REGION). Output is
+// JSON so it can be piped to jq.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+)
+
+// defaultServices are the services' HTTP listeners in the local stack.
+const defaultServices = "gateway=http://localhost:8080,ingest=http://localhost:8081," +
+	"fanout=http://localhost:8082,chatmgmt=http://localhost:8083"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	token := flag.String("token", os.Getenv("MSGCTL_TOKEN"), "admin bearer token (default $MSGCTL_TOKEN)")
+	services := flag.String("services", defaultServices, "name=base-url of each service's HTTP listener")
+	ingestAddr := flag.String("ingest", "localhost:9091", "Ingest gRPC address, for send")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of each HTTP or gRPC call")
+	tables := storeTables{}
+	flag.StringVar(&tables.sessions, "sessions", "sessions", "sessions table")
+	flag.StringVar(&tables.memberships, "memberships", "chat_memberships", "chat memberships table")
+	flag.StringVar(&tables.notifications, "notifications", "notifications", "notifications table")
+	flag.StringVar(&tables.messages, "messages", "messages_v2", "sharded messages table")
+	flag.StringVar(&tables.chats, "chats", "chats", "chats table holding each chat's shard count")
+	flag.StringVar(&tables.keys, "keys", "chat_keys", "chat data keys table for reading encrypted messages")
+	flag.StringVar(&tables.events, "events", "chat_events", "chat settings and membership event log table")
+	flag.Parse()
+
+	svcs, err := parseServices(*services)
+	if err != nil {
+		return fmt.Errorf("-services: %w", err)
+	}
+	c := &ctl{
+		out:        os.Stdout,
+		token:      *token,
+		services:   svcs,
+		ingestAddr: *ingestAddr,
+		timeout:    *timeout,
+		http:       &http.Client{Timeout: *timeout},
+		tables:     tables,
+	}
+	defer c.close()
+
+	if flag.NArg() == 0 {
+		return c.repl(ctx, os.Stdin)
+	}
+	// Ctrl-C stops a one-shot command, such as tail, cleanly.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	return c.exec(ctx, flag.Args())
+}
+
+// service is one entry of -services.
+type service struct {
+	name string
+	url  string
+}
+
+func parseServices(raw string) ([]service, error) {
+	var svcs []service
+	for entry := range strings.SplitSeq(raw, ",") {
+		name, url, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("entry %q must be name=url", entry)
+		}
+		svcs = append(svcs, service{name: name, url: strings.TrimSuffix(url, "/")})
+	}
+	return svcs, nil
+}
+
+// ctl holds the connections commands share. Store and Ingest clients are
+// created on first use so that health and keys work without AWS
+// credentials.
+type ctl struct {
+	out        io.Writer
+	token      string
+	services   []service
+	ingestAddr string
+	timeout    time.Duration
+	http       *http.Client
+	tables     storeTables
+
+	stores     *stores
+	ingestConn *grpc.ClientConn
+	ingest     messagingv1.IngestServiceClient
+}
+
+// command is a msgctl subcommand.
+type command struct {
+	usage string
+	run   func(c *ctl, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"health":  {"health", (*ctl).health},
+	"history": {"history <chat-id> [<rfc3339-time>]", (*ctl).history},
+	"keys":    {"keys [<access-token>]", (*ctl).keys},
+	"session": {"session <session-id|access-token>", (*ctl).session},
+	"sync":    {"sync <user-id>", (*ctl).sync},
+	"tail":    {"tail <chat-id> [<after-sequence>]", (*ctl).tail},
+	"send":    {"send <chat-id> <sender-id> <text...>", (*ctl).send},
+}
+
+// errUsage reports a command called with the wrong arguments.
+var errUsage = errors.New("usage")
+
+func (c *ctl) exec(ctx context.Context, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q; run help", args[0])
+	}
+	err := cmd.run(c, ctx, args[1:])
+	if errors.Is(err, errUsage) {
+		return fmt.Errorf("usage: %s", cmd.usage)
+	}
+	return err
+}
+
+// repl reads commands from in until EOF or exit. A failed command is
+// reported and the prompt continues; Ctrl-C stops the running command.
+func (c *ctl) repl(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(c.out, "msgctl> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(c.out)
+			return scanner.Err()
+		}
+		args := strings.Fields(scanner.Text())
+		switch {
+		case len(args) == 0:
+			continue
+		case args[0] == "exit" || args[0] == "quit":
+			return nil
+		case args[0] == "help":
+			c.help()
+			continue
+		}
+
+		cmdCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		err := c.exec(cmdCtx, args)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(c.out, "error: %v\n", err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (c *ctl) help() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(c.out, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(c.out, "  exit")
+}
+
+// ingestClient dials Ingest on first use.
+func (c *ctl) ingestClient() (messagingv1.IngestServiceClient, error) {
+	if c.ingest != nil {
+		return c.ingest, nil
+	}
+	conn, err := grpc.NewClient(c.ingestAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("dial ingest: %w", err)
+	}
+	c.ingestConn = conn
+	c.ingest = messagingv1.NewIngestServiceClient(conn)
+	return c.ingest, nil
+}
+
+func (c *ctl) close() {
+	if c.ingestConn != nil {
+		_ = c.ingestConn.Close()
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// send persists a text message through Ingest's PersistMessage, the path
+// Gateway takes for client messages, so it is sequenced, fanned out and
+// delivered like any other. Ingest applies its usual checks: the sender
+// must be a member allowed to post.
+func (c *ctl) send(ctx context.Context, args []string) error {
+	if len(args) < 3 {
+		return errUsage
+	}
+	chatID, err := domain.NewChatID(args[0])
+	if err != nil {
+		return fmt.Errorf("chat-id: %w", err)
+	}
+	senderID, err := domain.NewUserID(args[1])
+	if err != nil {
+		return fmt.Errorf("sender-id: %w", err)
+	}
+	content, err := domain.NewMessageContent(domain.ContentTypeText, strings.Join(args[2:], " "))
+	if err != nil {
+		return fmt.Errorf("text: %w", err)
+	}
+	client, err := c.ingestClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := client.PersistMessage(ctx, &messagingv1.PersistMessageRequest{
+		ChatId:           chatID.String(),
+		SenderId:         senderID.String(),
+		ClientMessageId:  uuid.NewString(),
+		ContentType:      messagingv1.ContentType_CONTENT_TYPE_TEXT,
+		Content:          content.Body(),
+		ServerReceivedAt: &messagingv1.Timestamp{Millis: time.Now().UnixMilli()},
+	})
+	if err != nil {
+		return fmt.Errorf("ingest: persist message: %w", err)
+	}
+	return c.print(struct {
+		MessageID string `json:"message_id"`
+		Sequence  uint64 `json:"sequence"`
+	}{resp.GetMessageId(), resp.GetSequence()})
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+const (
+	// tailPageSize is how many messages tail reads per query.
+	tailPageSize = 100
+	// tailPollInterval is how often tail checks for new messages once it
+	// has caught up.
+	tailPollInterval = time.Second
+	// syncUnreadLimit bounds the unread notifications sync lists.
+	syncUnreadLimit = 50
+)
+
+// storeTables are the DynamoDB tables msgctl reads.
+type storeTables struct {
+	sessions      string
+	memberships   string
+	notifications string
+	messages      string
+	chats         string
+	keys          string
+	events        string
+}
+
+// stores are the chatmgmt adapters msgctl reads through.
+type stores struct {
+	sessions      *adapter.SessionStore
+	memberships   *adapter.MembershipStore
+	notifications *adapter.FeedStore
+	messages      *adapter.MessageStore
+	events        *adapter.ChatEventStore
+}
+
+// openStores connects to DynamoDB on first use.
+func (c *ctl) openStores(ctx context.Context) (*stores, error) {
+	if c.stores != nil {
+		return c.stores, nil
+	}
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	client, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create dynamo client: %w", err)
+	}
+	// Reading needs no KMS key ID: the wrapped data keys name theirs.
+	keyring, err := msgcrypt.NewAWS(client, c.tables.keys, msgcrypt.Config{
+		KeyID:    cfg.Messages.KMSKeyID,
+		CacheTTL: cfg.Messages.KeyCacheTTL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create keyring: %w", err)
+	}
+	c.stores = &stores{
+		sessions:      adapter.NewSessionStore(client.DB, c.tables.sessions, domain.RealClock{}),
+		memberships:   adapter.NewMembershipStore(client.DB, c.tables.memberships, c.tables.events),
+		notifications: adapter.NewFeedStore(client.DB, c.tables.notifications),
+		messages:      adapter.NewMessageStore(client.DB, c.tables.messages, c.tables.chats, keyring),
+		events:        adapter.NewChatEventStore(client.DB, c.tables.events),
+	}
+	return c.stores, nil
+}
+
+// sessionView is a session record without its refresh token hashes.
+type sessionView struct {
+	SessionID       string `json:"session_id"`
+	UserID          string `json:"user_id"`
+	DeviceID        string `json:"device_id"`
+	FamilyID        string `json:"family_id,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	ExpiresAt       string `json:"expires_at"`
+	LastActiveAt    string `json:"last_active_at,omitempty"`
+	TokenGeneration int64  `json:"token_generation"`
+}
+
+func toSessionView(r app.SessionRecord) sessionView {
+	return sessionView{
+		SessionID:       r.SessionID,
+		UserID:          r.UserID,
+		DeviceID:        r.DeviceID,
+		FamilyID:        r.FamilyID,
+		CreatedAt:       r.CreatedAt.String(),
+		ExpiresAt:       r.ExpiresAt.String(),
+		LastActiveAt:    r.LastActiveAt.String(),
+		TokenGeneration: r.TokenGeneration,
+	}
+}
+
+// session shows a session by ID, or the session an access token belongs
+// to along with the token's claims.
+func (c *ctl) session(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	sessionID, tok := args[0], (*tokenView)(nil)
+	if strings.Count(args[0], ".") == 2 {
+		view, err := decodeToken(args[0])
+		if err != nil {
+			return err
+		}
+		sessionID, tok = view.SessionID, &view
+	}
+
+	s, err := c.openStores(ctx)
+	if err != nil {
+		return err
+	}
+	rec, err := s.sessions.GetByID(ctx, sessionID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("session %s not found; it was logged out, evicted or has expired", sessionID)
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(struct {
+		Session sessionView `json:"session"`
+		Token   *tokenView  `json:"token,omitempty"`
+	}{toSessionView(*rec), tok})
+}
+
+type membershipView struct {
+	ChatID   string `json:"chat_id"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joined_at,omitempty"`
+}
+
+type notificationView struct {
+	EntryID   string            `json:"id"`
+	Kind      string            `json:"kind"`
+	ChatID    string            `json:"chat_id,omitempty"`
+	ActorID   string            `json:"actor_id,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+	CreatedAt string            `json:"created_at"`
+}
+
+// sync dumps the server-side state a user's clients sync against: their
+// sessions, the chats they are in, and their unread notifications.
+func (c *ctl) sync(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	userID := args[0]
+	s, err := c.openStores(ctx)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := s.sessions.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	chats, err := s.memberships.ListUserChats(ctx, userID)
+	if err != nil {
+		return err
+	}
+	unread, err := s.notifications.ListFeedEntries(ctx, userID, "", syncUnreadLimit, true)
+	if err != nil {
+		return err
+	}
+
+	out := struct {
+		UserID   string             `json:"user_id"`
+		Sessions []sessionView      `json:"sessions"`
+		Chats    []membershipView   `json:"chats"`
+		Unread   []notificationView `json:"unread_notifications"`
+	}{UserID: userID, Sessions: []sessionView{}, Chats: []membershipView{}, Unread: []notificationView{}}
+	for _, r := range sessions {
+		out.Sessions = append(out.Sessions, toSessionView(r))
+	}
+	for _, m := range chats {
+		v := membershipView{ChatID: m.ChatID, Role: string(m.Role)}
+		if !m.JoinedAt.IsZero() {
+			v.JoinedAt = m.JoinedAt.UTC().Format(time.RFC3339)
+		}
+		out.Chats = append(out.Chats, v)
+	}
+	for _, e := range unread {
+		out.Unread = append(out.Unread, notificationView{
+			EntryID:   e.EntryID,
+			Kind:      string(e.Kind),
+			ChatID:    e.ChatID,
+			ActorID:   e.ActorID,
+			Params:    e.Params,
+			CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return c.print(out)
+}
+
+type chatEventView struct {
+	EventID         string                       `json:"event_id"`
+	Type            string                       `json:"type"`
+	ActorID         string                       `json:"actor_id,omitempty"`
+	At              string                       `json:"at"`
+	Settings        *domain.ChatSettings         `json:"settings,omitempty"`
+	SettingsVersion int64                        `json:"settings_version,omitempty"`
+	UserID          string                       `json:"user_id,omitempty"`
+	Role            string                       `json:"role,omitempty"`
+	SuccessorID     string                       `json:"successor_id,omitempty"`
+	Members         map[string]domain.MemberRole `json:"members,omitempty"`
+}
+
+// history prints a chat's settings and membership events up to a point in
+// time, and the state they leave the chat in. Without a time it reads the
+// whole log.
+func (c *ctl) history(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	chatID := args[0]
+	var at time.Time
+	if len(args) == 2 {
+		var err error
+		if at, err = time.Parse(time.RFC3339, args[1]); err != nil {
+			return fmt.Errorf("time: %w", err)
+		}
+	}
+	s, err := c.openStores(ctx)
+	if err != nil {
+		return err
+	}
+
+	events, err := s.events.ListChatEvents(ctx, chatID, at)
+	if err != nil {
+		return err
+	}
+	state := domain.ReplayChatEvents(events)
+	out := struct {
+		ChatID          string                       `json:"chat_id"`
+		Events          []chatEventView              `json:"events"`
+		Settings        *domain.ChatSettings         `json:"settings,omitempty"`
+		SettingsVersion int64                        `json:"settings_version,omitempty"`
+		Members         map[string]domain.MemberRole `json:"members"`
+	}{ChatID: chatID, Events: []chatEventView{}, Settings: state.Settings, SettingsVersion: state.SettingsVersion, Members: state.Members}
+	for _, e := range events {
+		out.Events = append(out.Events, chatEventView{
+			EventID:         e.EventID,
+			Type:            string(e.Type),
+			ActorID:         e.ActorID,
+			At:              e.At.UTC().Format(time.RFC3339),
+			Settings:        e.Settings,
+			SettingsVersion: e.SettingsVersion,
+			UserID:          e.UserID,
+			Role:            string(e.Role),
+			SuccessorID:     e.SuccessorID,
+			Members:         e.Members,
+		})
+	}
+	return c.print(out)
+}
+
+type messageView struct {
+	Sequence        uint64 `json:"sequence"`
+	MessageID       string `json:"message_id"`
+	SenderID        string `json:"sender_id,omitempty"`
+	ClientMessageID string `json:"client_message_id"`
+	ContentType     string `json:"content_type"`
+	Content         string `json:"content"`
+	CreatedAt       string `json:"created_at"`
+}
+
+// tail prints a chat's messages after a sequence, one JSON object per line,
+// then polls for new ones until interrupted. Without a sequence it starts
+// at the beginning of the chat.
+func (c *ctl) tail(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	chatID := args[0]
+	var after uint64
+	if len(args) == 2 {
+		var err error
+		if after, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			return fmt.Errorf("after-sequence: %w", err)
+		}
+	}
+	s, err := c.openStores(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(c.out)
+	for {
+		msgs, err := s.messages.ListAfter(ctx, chatID, after, tailPageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for _, m := range msgs {
+			if err := enc.Encode(messageView{
+				Sequence:        m.Sequence,
+				MessageID:       m.MessageID,
+				SenderID:        m.SenderID,
+				ClientMessageID: m.ClientMessageID,
+				ContentType:     string(m.ContentType),
+				Content:         m.Content,
+				CreatedAt:       m.CreatedAt.UTC().Format(time.RFC3339Nano),
+			}); err != nil {
+				return err
+			}
+			after = m.Sequence
+		}
+		if len(msgs) == tailPageSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(tailPollInterval):
+		}
+	}
+}
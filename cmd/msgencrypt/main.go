@@ -0,0 +1,102 @@
+// Package main is msgencrypt, an operator tool that encrypts the content of
+// messages written in plaintext, before MESSAGES_KMSKEYID was set or by a
+// writer without it.
+//
+// Chat IDs are read one per line from standard input:
+//
+//	MESSAGES_KMSKEYID=alias/messages msgencrypt < chats.txt
+//
+// With -rotate, each chat first gets a new data key, e.g. after a suspected
+// key exposure; content sealed earlier keeps its key. Re-running is safe;
+// sealed messages are skipped.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	rotate := flag.Bool("rotate", false, "give each chat a new data key before encrypting")
+	messages := flag.String("messages", "messages_v2", "sharded messages table")
+	chats := flag.String("chats", "chats", "chats table holding each chat's shard count")
+	keys := flag.String("keys", "chat_keys", "chat data keys table")
+	flag.Parse()
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if !cfg.Messages.Enabled() {
+		return errors.New("MESSAGES_KMSKEYID is not set")
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	client, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("create dynamo client: %w", err)
+	}
+	keyring, err := msgcrypt.NewAWS(client, *keys, msgcrypt.Config{
+		KeyID:       cfg.Messages.KMSKeyID,
+		RotateAfter: cfg.Messages.KeyRotation,
+		CacheTTL:    cfg.Messages.KeyCacheTTL,
+	})
+	if err != nil {
+		return fmt.Errorf("create keyring: %w", err)
+	}
+	encryptor := adapter.NewMessageEncryptor(client.DB, *messages, *chats, keyring)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	total := 0
+	for scanner.Scan() {
+		chatID := strings.TrimSpace(scanner.Text())
+		if chatID == "" {
+			continue
+		}
+		if *rotate {
+			version, err := keyring.Rotate(ctx, chatID)
+			if err != nil {
+				return fmt.Errorf("rotate chat %s: %w", chatID, err)
+			}
+			logger.InfoContext(ctx, "chat key rotated", "chat_id", chatID, "key_version", version)
+		}
+		sealed, err := encryptor.EncryptChat(ctx, chatID)
+		if err != nil {
+			return fmt.Errorf("encrypt chat %s after %d messages: %w", chatID, sealed, err)
+		}
+		total += sealed
+		logger.InfoContext(ctx, "chat encrypted", "chat_id", chatID, "sealed", sealed)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read chat IDs: %w", err)
+	}
+
+	logger.InfoContext(ctx, "encryption complete", "sealed", total)
+	return nil
+}
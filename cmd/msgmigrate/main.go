@@ -0,0 +1,80 @@
+// Package main is msgmigrate, an operator tool that copies chats from the v1
+// messages table into the sharded messages_v2 table (ADR-007 §6).
+//
+// Chat IDs are read one per line from standard input:
+//
+//	msgmigrate -shards 8 < hot-chats.txt
+//
+// Run it while Ingest shadows writes to messages_v2 so messages sent during
+// the copy are not missed. Re-running is safe; copied messages are skipped.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/adapter"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	shards := flag.Int("shards", 1, "message shards for each migrated chat")
+	source := flag.String("source", "messages", "v1 messages table")
+	target := flag.String("target", "messages_v2", "sharded messages table")
+	chats := flag.String("chats", "chats", "chats table holding each chat's shard count")
+	flag.Parse()
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	client, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("create dynamo client: %w", err)
+	}
+	migrator := adapter.NewMessageMigrator(client.DB, *source, *target, *chats)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	total := 0
+	for scanner.Scan() {
+		chatID := strings.TrimSpace(scanner.Text())
+		if chatID == "" {
+			continue
+		}
+		copied, err := migrator.MigrateChat(ctx, chatID, *shards)
+		if err != nil {
+			return fmt.Errorf("migrate chat %s after %d messages: %w", chatID, copied, err)
+		}
+		total += copied
+		logger.InfoContext(ctx, "chat migrated", "chat_id", chatID, "shards", *shards, "copied", copied)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read chat IDs: %w", err)
+	}
+
+	logger.InfoContext(ctx, "migration complete", "copied", total)
+	return nil
+}
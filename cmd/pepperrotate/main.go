@@ -0,0 +1,97 @@
+// Package main is pepperrotate, an operator tool for rotating the peppers
+// that key phone hashes and OTP MACs (AUTH_PEPPERS). It reads the current
+// configuration and prints the AUTH_PEPPERS and AUTH_PEPPERVERSION values
+// for each step; deploying them is left to the operator.
+//
+// Rotation runbook:
+//
+//  1. pepperrotate -add generates a new version. Deploy the AUTH_PEPPERS it
+//     prints with AUTH_PEPPERVERSION unchanged, so every instance can
+//     verify the new version before any instance issues under it.
+//  2. Deploy AUTH_PEPPERVERSION set to the new version. New OTPs use it;
+//     OTPs already issued verify under their own version.
+//  3. Once the new version has been current for domain.PepperRetireAfter,
+//     pepperrotate -retire prints AUTH_PEPPERS without the old versions.
+//     Deploy it.
+//
+// Phone rate limits and lockouts restart under the new phone hash at step
+// 2. Without flags, pepperrotate prints the configured versions.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	add := flag.Bool("add", false, "generate a new pepper version")
+	retire := flag.Bool("retire", false, "drop every version but the current one")
+	flag.Parse()
+	if *add && *retire {
+		return errors.New("-add and -retire are separate steps")
+	}
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	peppers := cfg.Auth.PepperKeys()
+	current := cfg.Auth.PepperVersion
+	versions := slices.Sorted(maps.Keys(peppers))
+
+	switch {
+	case *add:
+		next := versions[len(versions)-1] + 1
+		secret := make([]byte, domain.MinAuthPepperBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("generate pepper: %w", err)
+		}
+		peppers[next] = []byte(base64.RawURLEncoding.EncodeToString(secret))
+		fmt.Printf("AUTH_PEPPERS=%s\n", formatPeppers(peppers))
+		fmt.Printf("AUTH_PEPPERVERSION=%d\n", current)
+		fmt.Fprintf(os.Stderr, "deploy these, then set AUTH_PEPPERVERSION=%d\n", next)
+	case *retire:
+		if len(peppers) == 1 {
+			return fmt.Errorf("only version %d is configured", current)
+		}
+		fmt.Printf("AUTH_PEPPERS=%s\n", formatPeppers(map[int][]byte{current: peppers[current]}))
+		fmt.Printf("AUTH_PEPPERVERSION=%d\n", current)
+		fmt.Fprintf(os.Stderr, "deploy only once version %d has been current for %s\n", current, domain.PepperRetireAfter)
+	default:
+		fmt.Printf("current version: %d\n", current)
+		fmt.Printf("versions: %v\n", versions)
+	}
+	return nil
+}
+
+// formatPeppers renders peppers as an AUTH_PEPPERS value, oldest first.
+func formatPeppers(peppers map[int][]byte) string {
+	entries := make([]string, 0, len(peppers))
+	for _, version := range slices.Sorted(maps.Keys(peppers)) {
+		entries = append(entries, strconv.Itoa(version)+"="+string(peppers[version]))
+	}
+	return strings.Join(entries, ",")
+}
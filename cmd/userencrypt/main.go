@@ -0,0 +1,87 @@
+// Package main is userencrypt, an operator tool that encrypts users' phone
+// numbers and display names: those written in plaintext before
+// PII_KMSKEYID was set, and those under a data key since rotated. It also
+// writes the phone blind index of users created before it existed; without
+// PII_KMSKEYID it only does that.
+//
+//	PII_KMSKEYID=alias/pii PII_PHONEINDEXPEPPER=... userencrypt
+//
+// With -rotate, the users table first gets a new data key, e.g. after a
+// suspected key exposure or on a schedule; every user is then moved to it.
+// Until a run completes, phone lookups query each key version still in
+// use. Re-running is safe; users already current are skipped. Once a run
+// completes, PII_LEGACYPHONELOOKUP can be turned off.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) error {
+	rotate := flag.Bool("rotate", false, "give the users table a new data key before encrypting")
+	users := flag.String("users", "users", "users table")
+	keys := flag.String("keys", "chat_keys", "data keys table")
+	flag.Parse()
+
+	cfg, err := config.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *rotate && !cfg.PII.Enabled() {
+		return errors.New("-rotate needs PII_KMSKEYID")
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	client, err := dynamo.NewClient(ctx, dynamo.Config{
+		Endpoint: cfg.DynamoDB.Endpoint,
+		Region:   cfg.AWS.Region,
+		Timeout:  cfg.DynamoDB.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("create dynamo client: %w", err)
+	}
+	var fields *msgcrypt.FieldCipher
+	if cfg.PII.Enabled() {
+		fields, err = msgcrypt.NewAWSFieldCipher(client, *keys, msgcrypt.UsersKeyScope, cfg.PII.KMSKeyID, cfg.PII.KeyCacheTTL)
+		if err != nil {
+			return fmt.Errorf("create field cipher: %w", err)
+		}
+	}
+	phones := msgcrypt.NewBlindIndex(cfg.PII.PhoneIndexKey())
+
+	if *rotate {
+		version, err := fields.Rotate(ctx)
+		if err != nil {
+			return fmt.Errorf("rotate users key: %w", err)
+		}
+		logger.InfoContext(ctx, "users key rotated", "key_version", version)
+	}
+
+	encrypted, err := adapter.NewUserEncryptor(client.DB, *users, fields, phones).EncryptUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("encrypt users after %d: %w", encrypted, err)
+	}
+	logger.InfoContext(ctx, "encryption complete", "encrypted", encrypted)
+	return nil
+}
@@ -0,0 +1,444 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// authDB holds the Chat Mgmt auth tables in memory. The store types below
+// share it so the transactor can update several tables atomically, as the
+// DynamoDB transactions do.
+type authDB struct {
+	mu       sync.Mutex
+	otps     map[string]app.OTPRecord     // by phone hash
+	users    map[string]app.UserRecord    // by user ID
+	phones   map[string]string            // phone number -> user ID
+	sessions map[string]app.SessionRecord // by session ID
+	clock    domain.Clock
+}
+
+func newAuthDB(clock domain.Clock) *authDB {
+	return &authDB{
+		otps:     make(map[string]app.OTPRecord),
+		users:    make(map[string]app.UserRecord),
+		phones:   make(map[string]string),
+		sessions: make(map[string]app.SessionRecord),
+		clock:    clock,
+	}
+}
+
+// Compile-time checks.
+var (
+	_ app.OTPStore       = otpStore{}
+	_ app.UserStore      = userStore{}
+	_ app.SessionStore   = sessionStore{}
+	_ app.AuthTransactor = transactor{}
+)
+
+type otpStore struct{ db *authDB }
+
+// CreateOTP succeeds unless a pending, unexpired OTP exists for the phone.
+func (s otpStore) CreateOTP(_ context.Context, record app.OTPRecord) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if old, ok := s.db.otps[record.PhoneHash]; ok && old.Status != "verified" && !s.db.expired(old.ExpiresAt) {
+		return fmt.Errorf("otp store: create: %w", domain.ErrAlreadyExists)
+	}
+	s.db.otps[record.PhoneHash] = record
+	return nil
+}
+
+func (s otpStore) GetOTP(_ context.Context, phoneHash string) (*app.OTPRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	record, ok := s.db.otps[phoneHash]
+	if !ok {
+		return nil, fmt.Errorf("otp store: get: %w", domain.ErrNotFound)
+	}
+	return &record, nil
+}
+
+// ResendOTP applies while the OTP is pending, unexpired, and at the
+// expected resend count.
+func (s otpStore) ResendOTP(_ context.Context, phoneHash string, resend app.OTPResend) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	record, ok := s.db.otps[phoneHash]
+	if !ok || record.Status != "pending" || s.db.expired(record.ExpiresAt) || record.ResendCount != resend.ExpectedResendCount {
+		return fmt.Errorf("otp store: resend: %w", domain.ErrVersionConflict)
+	}
+	record.OTPMAC = resend.OTPMAC
+	record.CodeParams = resend.CodeParams
+	record.ResendCount = resend.ResendCount
+	record.ResendAt = resend.ResendAt
+	s.db.otps[phoneHash] = record
+	return nil
+}
+
+func (s otpStore) IncrementAttempts(_ context.Context, phoneHash string, limit int) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	record, ok := s.db.otps[phoneHash]
+	if !ok {
+		return 0, fmt.Errorf("otp store: increment: %w", domain.ErrNotFound)
+	}
+	if record.AttemptCount >= limit {
+		return record.AttemptCount, fmt.Errorf("otp store: increment: %w", domain.ErrRateLimited)
+	}
+	record.AttemptCount++
+	s.db.otps[phoneHash] = record
+	return record.AttemptCount, nil
+}
+
+type userStore struct{ db *authDB }
+
+func (s userStore) GetByID(_ context.Context, userID string) (*app.UserRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	user, ok := s.db.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user store: get: %w", domain.ErrNotFound)
+	}
+	return &user, nil
+}
+
+func (s userStore) FindByPhone(_ context.Context, phone string) (*app.UserRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	userID, ok := s.db.phones[phone]
+	if !ok {
+		return nil, fmt.Errorf("user store: find by phone: %w", domain.ErrNotFound)
+	}
+	user := s.db.users[userID]
+	return &user, nil
+}
+
+type sessionStore struct{ db *authDB }
+
+func (s sessionStore) Create(_ context.Context, session app.SessionRecord) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if _, ok := s.db.sessions[session.SessionID]; ok {
+		return fmt.Errorf("session store: create: %w", domain.ErrAlreadyExists)
+	}
+	s.db.sessions[session.SessionID] = session
+	return nil
+}
+
+func (s sessionStore) GetByID(_ context.Context, sessionID string) (*app.SessionRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session store: get: %w", domain.ErrNotFound)
+	}
+	return &session, nil
+}
+
+func (s sessionStore) ListByUser(_ context.Context, userID string) ([]app.SessionRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var out []app.SessionRecord
+	for _, session := range s.db.sessions {
+		if session.UserID == userID {
+			out = append(out, session)
+		}
+	}
+	return out, nil
+}
+
+func (s sessionStore) GetByUserAndDevice(_ context.Context, userID, deviceID string) ([]app.SessionRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var out []app.SessionRecord
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.DeviceID == deviceID {
+			out = append(out, session)
+		}
+	}
+	return out, nil
+}
+
+func (s sessionStore) Update(_ context.Context, sessionID string, update app.SessionUpdate) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.sessions[sessionID]
+	if !ok || session.TokenGeneration != update.ExpectedGeneration {
+		return fmt.Errorf("session store: update: %w", domain.ErrVersionConflict)
+	}
+	session.RefreshTokenHash = update.RefreshTokenHash
+	session.PrevTokenHash = update.PrevTokenHash
+	session.ExpiresAt = update.ExpiresAt
+	session.LastActiveAt = update.LastActiveAt
+	session.TokenGeneration = update.TokenGeneration
+	session.TTL = update.TTL
+	s.db.sessions[sessionID] = session
+	return nil
+}
+
+func (s sessionStore) Delete(_ context.Context, sessionID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	delete(s.db.sessions, sessionID)
+	return nil
+}
+
+func (s sessionStore) ListIdle(_ context.Context, cutoff domain.TimestampMS) ([]app.SessionRecord, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	var out []app.SessionRecord
+	for _, session := range s.db.sessions {
+		if idle(session, cutoff) {
+			out = append(out, session)
+		}
+	}
+	return out, nil
+}
+
+func (s sessionStore) DeleteIdle(_ context.Context, sessionID string, cutoff domain.TimestampMS) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	session, ok := s.db.sessions[sessionID]
+	if !ok || !idle(session, cutoff) {
+		return fmt.Errorf("session store: delete idle: %w", domain.ErrVersionConflict)
+	}
+	delete(s.db.sessions, sessionID)
+	return nil
+}
+
+// idle reports whether session was last active before cutoff; a session
+// never reported active counts from its creation.
+func idle(session app.SessionRecord, cutoff domain.TimestampMS) bool {
+	if !session.LastActiveAt.IsZero() {
+		return session.LastActiveAt.Before(cutoff)
+	}
+	return session.CreatedAt.Before(cutoff)
+}
+
+type transactor struct{ db *authDB }
+
+func (t transactor) VerifyOTPAndCreateUser(_ context.Context, p app.RegistrationParams) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	if err := t.db.checkOTP(p.PhoneHash, p.OTPExpiresAt, p.OTPMAC); err != nil {
+		return err
+	}
+	if _, ok := t.db.users[p.UserID]; ok {
+		return fmt.Errorf("transactor: create user: %w", domain.ErrAlreadyExists)
+	}
+	if _, ok := t.db.phones[p.PhoneNumber]; ok {
+		return fmt.Errorf("transactor: create user: phone taken: %w", domain.ErrAlreadyExists)
+	}
+	t.db.markVerified(p.PhoneHash)
+	t.db.users[p.UserID] = app.UserRecord{UserID: p.UserID, PhoneNumber: p.PhoneNumber, CreatedAt: p.Now, UpdatedAt: p.Now}
+	t.db.phones[p.PhoneNumber] = p.UserID
+	t.db.sessions[p.SessionID] = app.SessionRecord{
+		SessionID:        p.SessionID,
+		UserID:           p.UserID,
+		DeviceID:         p.DeviceID,
+		FamilyID:         p.FamilyID,
+		RefreshTokenHash: p.RefreshTokenHash,
+		CreatedAt:        p.Now,
+		ExpiresAt:        p.SessionExpiresAt,
+		TokenGeneration:  1,
+		TTL:              p.SessionTTL,
+	}
+	return nil
+}
+
+func (t transactor) VerifyOTPAndCreateSession(_ context.Context, p app.LoginParams) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	if err := t.db.checkOTP(p.PhoneHash, p.OTPExpiresAt, p.OTPMAC); err != nil {
+		return err
+	}
+	if _, ok := t.db.sessions[p.SessionID]; ok {
+		return fmt.Errorf("transactor: create session: %w", domain.ErrAlreadyExists)
+	}
+	if err := t.db.checkEvictions(p.UserID, p.EvictSessionIDs); err != nil {
+		return err
+	}
+	t.db.markVerified(p.PhoneHash)
+	t.db.evict(p.EvictSessionIDs)
+	t.db.sessions[p.SessionID] = app.SessionRecord{
+		SessionID:        p.SessionID,
+		UserID:           p.UserID,
+		DeviceID:         p.DeviceID,
+		FamilyID:         p.FamilyID,
+		RefreshTokenHash: p.RefreshTokenHash,
+		CreatedAt:        p.CreatedAt,
+		ExpiresAt:        p.SessionExpiresAt,
+		TokenGeneration:  1,
+		TTL:              p.SessionTTL,
+	}
+	return nil
+}
+
+func (t transactor) EvictAndCreateSession(_ context.Context, session app.SessionRecord, evictSessionIDs []string) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	if _, ok := t.db.sessions[session.SessionID]; ok {
+		return fmt.Errorf("transactor: create session: %w", domain.ErrAlreadyExists)
+	}
+	if err := t.db.checkEvictions(session.UserID, evictSessionIDs); err != nil {
+		return err
+	}
+	t.db.evict(evictSessionIDs)
+	session.TokenGeneration = 1
+	session.PrevTokenHash = ""
+	t.db.sessions[session.SessionID] = session
+	return nil
+}
+
+// checkEvictions is the session delete condition: each evicted session
+// still belongs to userID. The caller holds mu.
+func (db *authDB) checkEvictions(userID string, sessionIDs []string) error {
+	for _, id := range sessionIDs {
+		if sess, ok := db.sessions[id]; !ok || sess.UserID != userID {
+			return fmt.Errorf("transactor: evict session: %w", domain.ErrVersionConflict)
+		}
+	}
+	return nil
+}
+
+// evict deletes the sessions. The caller holds mu.
+func (db *authDB) evict(sessionIDs []string) {
+	for _, id := range sessionIDs {
+		delete(db.sessions, id)
+	}
+}
+
+// checkOTP is the OTP condition of both transactions: the OTP is still the
+// pending one the caller verified. The caller holds mu.
+func (db *authDB) checkOTP(phoneHash string, expiresAt domain.TimestampMS, mac string) error {
+	record, ok := db.otps[phoneHash]
+	if !ok || record.Status != "pending" || record.ExpiresAt != expiresAt || record.OTPMAC != mac {
+		return fmt.Errorf("transactor: otp condition failed: %w", domain.ErrAlreadyExists)
+	}
+	return nil
+}
+
+func (db *authDB) markVerified(phoneHash string) {
+	record := db.otps[phoneHash]
+	record.Status = "verified"
+	db.otps[phoneHash] = record
+}
+
+func (db *authDB) expired(expiresAt domain.TimestampMS) bool {
+	return expiresAt.Before(domain.TimestampNow(db.clock))
+}
+
+// authAPI serves the Chat Mgmt auth routes in the JSON shape grpc-gateway
+// renders them (lowerCamelCase responses). This tree builds without the
+// generated gRPC code, so the harness mounts these handlers in its place.
+type authAPI struct {
+	svc *app.AuthService
+}
+
+func (a *authAPI) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /v1/auth/otp/request", a.requestOTP)
+	mux.HandleFunc("POST /v1/auth/otp/verify", a.verifyOTP)
+	mux.HandleFunc("POST /v1/auth/tokens/refresh", a.refreshTokens)
+	mux.HandleFunc("POST /v1/auth/logout", a.logout)
+}
+
+func (a *authAPI) requestOTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if _, err := a.svc.RequestOTP(r.Context(), req.PhoneNumber, clientIP(r)); err != nil {
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (a *authAPI) verifyOTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+		OTP         string `json:"otp"`
+		DeviceID    string `json:"device_id"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	res, err := a.svc.VerifyOTP(r.Context(), req.PhoneNumber, req.OTP, req.DeviceID, clientIP(r))
+	if err != nil {
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	var resp struct {
+		User struct {
+			UserID string `json:"userId"`
+		} `json:"user"`
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	resp.User.UserID = res.User.UserID
+	resp.AccessToken = res.AccessToken
+	resp.RefreshToken = res.RefreshToken
+	writeJSON(w, resp)
+}
+
+func (a *authAPI) refreshTokens(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	res, err := a.svc.RefreshTokens(r.Context(), bearer(r), req.RefreshToken, r.Header.Get("X-Device-Id"), clientIP(r))
+	if err != nil {
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	writeJSON(w, struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}{res.AccessToken, res.RefreshToken})
+}
+
+func (a *authAPI) logout(w http.ResponseWriter, r *http.Request) {
+	if err := a.svc.Logout(r.Context(), bearer(r)); err != nil {
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		errmap.WriteProblem(w, r, domain.NewValidationError("body", "is not valid JSON"))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func bearer(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
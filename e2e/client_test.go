@@ -0,0 +1,364 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// waitTimeout bounds every wait for a reply from the system.
+const waitTimeout = 5 * time.Second
+
+// smsInbox is the phone of every test user: Chat Mgmt sends OTPs to it.
+type smsInbox struct {
+	mu    sync.Mutex
+	codes map[string]chan string
+}
+
+var _ auth.SMSProvider = (*smsInbox)(nil)
+
+func newSMSInbox() *smsInbox {
+	return &smsInbox{codes: make(map[string]chan string)}
+}
+
+func (i *smsInbox) SendOTP(_ context.Context, phone, otp string) error {
+	i.inbox(phone) <- otp
+	return nil
+}
+
+func (i *smsInbox) inbox(phone string) chan string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ch, ok := i.codes[phone]
+	if !ok {
+		ch = make(chan string, 4)
+		i.codes[phone] = ch
+	}
+	return ch
+}
+
+// await returns the next OTP sent to phone.
+func (i *smsInbox) await(t *testing.T, phone string) string {
+	t.Helper()
+	select {
+	case code := <-i.inbox(phone):
+		return code
+	case <-time.After(waitTimeout):
+		t.Fatalf("no OTP sent to %s", phone)
+		return ""
+	}
+}
+
+// user is a logged-in test user on one device.
+type user struct {
+	phone        string
+	deviceID     string
+	userID       string
+	accessToken  string
+	refreshToken string
+}
+
+// apiError is an error reply from the auth API.
+type apiError struct {
+	status int
+	code   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.status, e.code)
+}
+
+// login requests an OTP for phone, reads it from the inbox and verifies it
+// from a new device.
+func login(t *testing.T, phone string) *user {
+	t.Helper()
+	require.NoError(t, authCall("/v1/auth/otp/request", nil, map[string]string{"phone_number": phone}, nil))
+	code := sys.sms.await(t, phone)
+
+	u := &user{phone: phone, deviceID: domain.GenerateDeviceID().String()}
+	var resp struct {
+		User struct {
+			UserID string `json:"userId"`
+		} `json:"user"`
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	require.NoError(t, authCall("/v1/auth/otp/verify", nil, map[string]string{
+		"phone_number": phone,
+		"otp":          code,
+		"device_id":    u.deviceID,
+	}, &resp))
+	u.userID, u.accessToken, u.refreshToken = resp.User.UserID, resp.AccessToken, resp.RefreshToken
+	return u
+}
+
+// refresh rotates u's tokens.
+func (u *user) refresh() error {
+	var resp struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := authCall("/v1/auth/tokens/refresh", u, map[string]string{"refresh_token": u.refreshToken}, &resp); err != nil {
+		return err
+	}
+	u.accessToken, u.refreshToken = resp.AccessToken, resp.RefreshToken
+	return nil
+}
+
+func (u *user) logout() error {
+	return authCall("/v1/auth/logout", u, map[string]string{"refresh_token": u.refreshToken}, nil)
+}
+
+// authCall POSTs in to a Chat Mgmt auth route, as u when set, and decodes
+// a 200 reply into out when set. Other replies return an *apiError.
+func authCall(path string, u *user, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, sys.chatmgmtURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u != nil {
+		req.Header.Set("Authorization", "Bearer "+u.accessToken)
+		req.Header.Set("X-Device-Id", u.deviceID)
+	}
+	resp, err := sys.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var p errmap.Problem
+		_ = json.Unmarshal(payload, &p)
+		return &apiError{status: resp.StatusCode, code: p.Code}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(payload, out)
+}
+
+// newChat creates a chat with the given members.
+func newChat(members ...*user) string {
+	chatID := domain.GenerateChatID().String()
+	for _, m := range members {
+		sys.members.add(chatID, m.userID)
+	}
+	return chatID
+}
+
+// conn is a client WebSocket connection to the Gateway. A reader goroutine
+// answers pings and queues every other frame.
+type conn struct {
+	t       *testing.T
+	ws      *websocket.Conn
+	ack     protocol.ConnectionAck
+	frames  chan *protocol.Frame
+	pending []*protocol.Frame // read while waiting for another frame
+	writeMu sync.Mutex
+	done    chan struct{}
+}
+
+// dial connects as u and waits for connection_ack. A refused connection
+// returns the connection_closing reason.
+func dial(t *testing.T, u *user) (*conn, error) {
+	t.Helper()
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(sys.gatewayURL, "http")+"/v1/ws", "http://e2e.invalid")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Header = http.Header{
+		"Authorization": {"Bearer " + u.accessToken},
+		"X-Device-Id":   {u.deviceID},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws.MaxPayloadBytes = maxFrameBytes
+
+	_ = ws.SetReadDeadline(time.Now().Add(waitTimeout))
+	var first protocol.Frame
+	if err := websocket.JSON.Receive(ws, &first); err != nil {
+		_ = ws.Close()
+		return nil, fmt.Errorf("read connection_ack: %w", err)
+	}
+	_ = ws.SetReadDeadline(time.Time{})
+	if first.Type != protocol.FrameTypeConnectionAck {
+		_ = ws.Close()
+		var closing protocol.ConnectionClosing
+		_ = first.ParsePayload(&closing)
+		return nil, fmt.Errorf("%s: %d %s", first.Type, closing.Code, closing.Reason)
+	}
+
+	c := &conn{t: t, ws: ws, frames: make(chan *protocol.Frame, 256), done: make(chan struct{})}
+	require.NoError(t, first.ParsePayload(&c.ack))
+	go c.read()
+	t.Cleanup(c.close)
+	return c, nil
+}
+
+// mustDial connects as u.
+func mustDial(t *testing.T, u *user) *conn {
+	t.Helper()
+	c, err := dial(t, u)
+	require.NoError(t, err)
+	return c
+}
+
+func (c *conn) read() {
+	defer close(c.done)
+	defer close(c.frames)
+	for {
+		var f protocol.Frame
+		if err := websocket.JSON.Receive(c.ws, &f); err != nil {
+			return
+		}
+		if f.Type == protocol.FrameTypePing {
+			var ping protocol.Ping
+			_ = f.ParsePayload(&ping)
+			_ = c.write(protocol.FrameTypePong, protocol.Pong{Timestamp: ping.Timestamp})
+			continue
+		}
+		c.frames <- &f
+	}
+}
+
+func (c *conn) write(t protocol.FrameType, payload any) error {
+	f, err := protocol.NewFrame(t, payload)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return protocol.WriteFrame(c.ws, f)
+}
+
+func (c *conn) close() {
+	_ = c.ws.Close()
+	<-c.done
+}
+
+// send sends a text message without waiting for its ack.
+func (c *conn) send(chatID, clientMessageID, text string) {
+	c.t.Helper()
+	require.NoError(c.t, c.write(protocol.FrameTypeSendMessage, protocol.SendMessage{
+		ChatID:          chatID,
+		ClientMessageID: clientMessageID,
+		ContentType:     string(domain.ContentTypeText),
+		Content:         text,
+	}))
+}
+
+// next returns the first frame, queued or still to come, that match
+// accepts, leaving the others queued.
+func (c *conn) next(what string, match func(*protocol.Frame) bool) *protocol.Frame {
+	c.t.Helper()
+	for i, f := range c.pending {
+		if match(f) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return f
+		}
+	}
+	timeout := time.After(waitTimeout)
+	for {
+		select {
+		case f, ok := <-c.frames:
+			if !ok {
+				c.t.Fatalf("connection closed waiting for %s", what)
+			}
+			if match(f) {
+				return f
+			}
+			c.pending = append(c.pending, f)
+		case <-timeout:
+			c.t.Fatalf("no %s within %s", what, waitTimeout)
+		}
+	}
+}
+
+// awaitAck returns the ack of clientMessageID, failing on an error frame.
+func (c *conn) awaitAck(clientMessageID string) protocol.SendMessageAck {
+	c.t.Helper()
+	f := c.next("send_message_ack "+clientMessageID, func(f *protocol.Frame) bool {
+		return f.Type == protocol.FrameTypeSendMessageAck || f.Type == protocol.FrameTypeError
+	})
+	if f.Type == protocol.FrameTypeError {
+		c.t.Fatalf("error frame waiting for ack of %s: %s", clientMessageID, f.Payload)
+	}
+	var ack protocol.SendMessageAck
+	require.NoError(c.t, f.ParsePayload(&ack))
+	require.Equal(c.t, clientMessageID, ack.ClientMessageID, "acks arrive in send order")
+	return ack
+}
+
+// awaitError returns the next error frame.
+func (c *conn) awaitError() protocol.Error {
+	c.t.Helper()
+	f := c.next("error", func(f *protocol.Frame) bool { return f.Type == protocol.FrameTypeError })
+	var e protocol.Error
+	require.NoError(c.t, f.ParsePayload(&e))
+	return e
+}
+
+// awaitMessages returns the next n messages delivered in chatID, in
+// delivery order.
+func (c *conn) awaitMessages(chatID string, n int) []protocol.Message {
+	c.t.Helper()
+	out := make([]protocol.Message, 0, n)
+	for len(out) < n {
+		f := c.next("message in "+chatID, func(f *protocol.Frame) bool {
+			if f.Type != protocol.FrameTypeMessage {
+				return false
+			}
+			var msg protocol.Message
+			return f.ParsePayload(&msg) == nil && msg.ChatID == chatID
+		})
+		var msg protocol.Message
+		require.NoError(c.t, f.ParsePayload(&msg))
+		out = append(out, msg)
+	}
+	return out
+}
+
+// sync requests the messages after lastAcked in chatID.
+func (c *conn) sync(chatID string, lastAcked uint64) protocol.SyncResponse {
+	c.t.Helper()
+	require.NoError(c.t, c.write(protocol.FrameTypeSyncRequest, protocol.SyncRequest{
+		ChatID:            chatID,
+		LastAckedSequence: lastAcked,
+	}))
+	f := c.next("sync_response", func(f *protocol.Frame) bool {
+		return f.Type == protocol.FrameTypeSyncResponse || f.Type == protocol.FrameTypeError
+	})
+	if f.Type == protocol.FrameTypeError {
+		c.t.Fatalf("sync %s: %s", chatID, f.Payload)
+	}
+	var resp protocol.SyncResponse
+	require.NoError(c.t, f.ParsePayload(&resp))
+	return resp
+}
@@ -0,0 +1,13 @@
+// Package e2e runs end-to-end tests across Chat Mgmt, Gateway, Ingest and
+// Fanout. The tests boot all four services in one process with
+// server.Run on port-0 listeners, backed by in-memory stores, miniredis
+// and an in-memory Kafka bus, and drive them the way a client does: log in
+// over HTTP, connect over WebSocket, send and receive frames.
+//
+// Every span the services start is recorded, so a test can follow one
+// message through the services by its trace ID.
+//
+// Run with:
+//
+//	go test ./e2e/...
+package e2e
@@ -0,0 +1,240 @@
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/websocket"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Every login comes from the same client IP and counts against the per-IP
+// OTP limit, so tests share alice and bob and log in others only when they
+// need a session of their own.
+var (
+	pairOnce   sync.Once
+	alice, bob *user
+)
+
+func pair(t *testing.T) (*user, *user) {
+	t.Helper()
+	pairOnce.Do(func() {
+		alice = login(t, "+14155550100")
+		bob = login(t, "+14155550101")
+	})
+	require.NotNil(t, bob, "login failed in an earlier test")
+	return alice, bob
+}
+
+func TestMessageFlow_OrderedDeliveryAndAcks(t *testing.T) {
+	alice, bob := pair(t)
+	chatID := newChat(alice, bob)
+	bobConn := mustDial(t, bob)
+	aliceConn := mustDial(t, alice)
+
+	// Pipelined sends: sequences follow send order without waiting on acks.
+	const n = 10
+	for i := range n {
+		aliceConn.send(chatID, fmt.Sprintf("m-%d", i), fmt.Sprintf("hello %d", i))
+	}
+	for i := range n {
+		ack := aliceConn.awaitAck(fmt.Sprintf("m-%d", i))
+		assert.Equal(t, uint64(i+1), ack.Sequence, "ack %d", i)
+		assert.NotEmpty(t, ack.MessageID)
+	}
+
+	got := bobConn.awaitMessages(chatID, n)
+	for i, msg := range got {
+		assert.Equal(t, uint64(i+1), msg.Sequence, "delivery %d", i)
+		assert.Equal(t, fmt.Sprintf("m-%d", i), msg.ClientMessageID)
+		assert.Equal(t, alice.userID, msg.SenderID)
+		assert.Equal(t, fmt.Sprintf("hello %d", i), msg.Content)
+	}
+	// The sender's own devices receive the messages too.
+	assert.Len(t, aliceConn.awaitMessages(chatID, n), n)
+}
+
+func TestMessageFlow_DuplicateSendIsIdempotent(t *testing.T) {
+	alice, bob := pair(t)
+	chatID := newChat(alice, bob)
+	bobConn := mustDial(t, bob)
+	aliceConn := mustDial(t, alice)
+
+	aliceConn.send(chatID, "retry-me", "first")
+	first := aliceConn.awaitAck("retry-me")
+	// A client that lost the ack resends with the same client message ID.
+	aliceConn.send(chatID, "retry-me", "first")
+	again := aliceConn.awaitAck("retry-me")
+	aliceConn.send(chatID, "after", "second")
+	after := aliceConn.awaitAck("after")
+
+	assert.Equal(t, first.MessageID, again.MessageID)
+	assert.Equal(t, first.Sequence, again.Sequence)
+	assert.Equal(t, first.Sequence+1, after.Sequence, "the retry does not take a sequence")
+
+	got := bobConn.awaitMessages(chatID, 2)
+	assert.Equal(t, []string{"retry-me", "after"}, []string{got[0].ClientMessageID, got[1].ClientMessageID},
+		"the retry is not delivered again")
+}
+
+func TestMessageFlow_NonMemberIsRejected(t *testing.T) {
+	alice, _ := pair(t)
+	mallory := login(t, "+14155550120")
+	chatID := newChat(alice)
+	malloryConn := mustDial(t, mallory)
+
+	malloryConn.send(chatID, "intruder", "hi")
+	assert.Equal(t, "NOT_MEMBER", malloryConn.awaitError().Code, "send")
+
+	require.NoError(t, malloryConn.write(protocol.FrameTypeSyncRequest, protocol.SyncRequest{ChatID: chatID}))
+	assert.Equal(t, "NOT_MEMBER", malloryConn.awaitError().Code, "sync")
+
+	_, persisted := sys.messages.message(chatID, 1)
+	assert.False(t, persisted)
+}
+
+func TestGateway_ClosesOnInvalidInput(t *testing.T) {
+	alice, _ := pair(t)
+	tests := []struct {
+		name string
+		send func(*websocket.Conn) error
+		want int
+	}{
+		{"binary message", func(ws *websocket.Conn) error {
+			return websocket.Message.Send(ws, []byte(`{"type":"ping"}`))
+		}, errmap.CloseInvalidMessage},
+		{"malformed frame", func(ws *websocket.Conn) error {
+			return websocket.Message.Send(ws, `{"type":`)
+		}, errmap.CloseInvalidMessage},
+		{"nested too deeply", func(ws *websocket.Conn) error {
+			return websocket.Message.Send(ws, `{"type":"ping","payload":`+strings.Repeat("[", 64)+strings.Repeat("]", 64)+`}`)
+		}, errmap.CloseInvalidMessage},
+		{"oversized frame", func(ws *websocket.Conn) error {
+			return websocket.Message.Send(ws, `{"type":"ping","payload":"`+strings.Repeat("a", domain.MaxInboundFrameSize)+`"}`)
+		}, errmap.CloseMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustDial(t, alice)
+			c.writeMu.Lock()
+			err := tt.send(c.ws)
+			c.writeMu.Unlock()
+			require.NoError(t, err)
+
+			f := c.next("connection_closing", func(f *protocol.Frame) bool {
+				return f.Type == protocol.FrameTypeConnectionClosing
+			})
+			var closing protocol.ConnectionClosing
+			require.NoError(t, f.ParsePayload(&closing))
+			assert.Equal(t, tt.want, closing.Code, closing.Reason)
+		})
+	}
+}
+
+func TestMessageFlow_TraceSpansEveryService(t *testing.T) {
+	alice, bob := pair(t)
+	chatID := newChat(alice, bob)
+	bobConn := mustDial(t, bob)
+	aliceConn := mustDial(t, alice)
+
+	aliceConn.send(chatID, "traced", "follow me")
+	aliceConn.awaitAck("traced")
+	bobConn.awaitMessages(chatID, 1)
+
+	// Every hop of the message, from the send_message frame to the delivery
+	// into the receiver's connection, is in the trace of the sender's
+	// session. Spans are read as started: the last hops may still be ending.
+	session := findSpan(t, "gateway.session", func(s trace.ReadOnlySpan) bool {
+		return hasAttribute(s, "connection.id", aliceConn.ack.ConnectionID)
+	})
+	traceID := session.SpanContext().TraceID()
+	inTrace := func(s trace.ReadOnlySpan) bool { return s.SpanContext().TraceID() == traceID }
+
+	send := findSpan(t, "gateway.send_message", inTrace)
+	persist := findSpan(t, "ingest.persist", inTrace)
+	process := findSpan(t, "kafka.process", inTrace)
+	deliver := findSpan(t, "fanout.deliver", inTrace)
+	receive := findSpan(t, "gateway.deliver", inTrace)
+
+	assert.Equal(t, session.SpanContext().SpanID(), send.Parent().SpanID())
+	assert.Equal(t, send.SpanContext().SpanID(), persist.Parent().SpanID(), "ingest continues the gateway call")
+	assert.True(t, persist.Parent().IsRemote())
+	assert.Equal(t, persist.SpanContext().SpanID(), process.Parent().SpanID(), "fanout continues the produce")
+	assert.Equal(t, oteltrace.SpanKindConsumer, process.SpanKind())
+	assert.Equal(t, process.SpanContext().SpanID(), deliver.Parent().SpanID())
+	assert.Equal(t, deliver.SpanContext().SpanID(), receive.Parent().SpanID(), "the gateway continues the delivery")
+}
+
+func TestSync_CatchesUpAfterReconnect(t *testing.T) {
+	alice, bob := pair(t)
+	chatID := newChat(alice, bob)
+	aliceConn := mustDial(t, alice)
+
+	// Bob is offline while Alice sends.
+	for i := range 3 {
+		id := fmt.Sprintf("offline-%d", i)
+		aliceConn.send(chatID, id, "while you were away")
+		aliceConn.awaitAck(id)
+	}
+
+	bobConn := mustDial(t, bob)
+	resp := bobConn.sync(chatID, 1)
+
+	require.Len(t, resp.Messages, 2, "only the messages after the last ack")
+	assert.Equal(t, uint64(2), resp.Messages[0].Sequence)
+	assert.Equal(t, uint64(3), resp.Messages[1].Sequence)
+	assert.False(t, resp.HasMore)
+	assert.Nil(t, resp.Gap)
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	carol := login(t, "+14155550150")
+	mustDial(t, carol).close()
+
+	require.NoError(t, carol.logout())
+
+	// The Gateway refuses the revoked access token...
+	_, err := dial(t, carol)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unauthorized")
+
+	// ...and Chat Mgmt no longer rotates the session's tokens.
+	err = carol.refresh()
+	var apiErr *apiError
+	require.True(t, errors.As(err, &apiErr), "got %v", err)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.status)
+	assert.Equal(t, "SESSION_REVOKED", apiErr.code)
+}
+
+// findSpan returns the first started span named name that match accepts.
+func findSpan(t *testing.T, name string, match func(trace.ReadOnlySpan) bool) trace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range spans.Started() {
+		if s.Name() == name && match(s) {
+			return s
+		}
+	}
+	t.Fatalf("no %s span", name)
+	return nil
+}
+
+func hasAttribute(s trace.ReadOnlySpan, key, value string) bool {
+	for _, kv := range s.Attributes() {
+		if string(kv.Key) == key && kv.Value.AsString() == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	chatmgmtadapter "github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	chatmgmtapp "github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	gatewayapp "github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+const (
+	issuer   = "messaging-platform"
+	audience = "messaging-api"
+	keyID    = "e2e-key-001"
+
+	// startTimeout bounds how long the services take to report ready.
+	startTimeout = 10 * time.Second
+)
+
+var pepper = []byte("e2e-pepper-32-bytes-long-ok!!!!!")
+
+// spans records every span started in the process. It is installed as the
+// global tracer provider before any service starts, so the package tracers
+// of every service report to it rather than to the provider server.Run
+// installs for each service.
+var spans = tracetest.NewSpanRecorder()
+
+// sys is the running system under test, shared by every test.
+var sys *system
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var err error
+	if sys, err = start(); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	if err := sys.stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+		code = max(code, 1)
+	}
+	os.Exit(code)
+}
+
+// system is the four services and the state they share: the Redis that
+// Chat Mgmt and the Gateway use, the membership table, the message log and
+// the Kafka bus from Ingest to Fanout. Anything a service would reach over
+// the network it reaches over its peers' HTTP listeners.
+type system struct {
+	redis    *miniredis.Miniredis
+	rdb      *redisclient.Client
+	clock    domain.Clock
+	keys     auth.KeyStore
+	sms      *smsInbox
+	members  *members
+	messages *messageLog
+	consumer *kafkatest.Consumer
+	client   *http.Client
+
+	chatmgmtURL string
+	gatewayURL  string
+	ingestURL   string
+	fanoutURL   string
+
+	cancel context.CancelFunc
+	errs   chan error
+}
+
+// service is one service to boot: its Setup and where its URL goes.
+type service struct {
+	name  string
+	url   *string
+	setup func(context.Context, server.SetupDeps) (func(context.Context) error, error)
+}
+
+// start boots the services on port-0 listeners and waits until each
+// reports ready.
+func start() (*system, error) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		return nil, fmt.Errorf("start miniredis: %w", err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		mr.Close()
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+
+	clock := domain.RealClock{}
+	s := &system{
+		redis: mr,
+		rdb: redisclient.NewClient(redisclient.Config{
+			Addr:         mr.Addr(),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}),
+		clock:    clock,
+		keys:     auth.NewStaticKeyStore(key, keyID),
+		sms:      newSMSInbox(),
+		members:  newMembers(),
+		messages: newMessageLog(clock),
+		consumer: kafkatest.NewConsumer(),
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+	services := []service{
+		{"chatmgmt", &s.chatmgmtURL, s.setupChatMgmt},
+		{"gateway", &s.gatewayURL, s.setupGateway},
+		{"ingest", &s.ingestURL, s.setupIngest},
+		{"fanout", &s.fanoutURL, s.setupFanout},
+	}
+
+	// Listen first so every service knows its peers' URLs before any starts.
+	lns := make([]net.Listener, len(services))
+	for i, svc := range services {
+		if lns[i], err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			closeAll(lns[:i])
+			s.close()
+			return nil, fmt.Errorf("listen %s: %w", svc.name, err)
+		}
+		*svc.url = "http://" + lns[i].Addr().String()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.errs = make(chan error, len(services))
+	for i, svc := range services {
+		go func() {
+			err := server.Run(ctx, server.Params{
+				Name:           svc.name,
+				PortFromConfig: func(*config.Config) int { return 0 },
+				Setup:          svc.setup,
+			}, server.Listeners{HTTP: lns[i]})
+			if err != nil {
+				err = fmt.Errorf("%s: %w", svc.name, err)
+			}
+			s.errs <- err
+		}()
+	}
+
+	for _, svc := range services {
+		if err := s.awaitReady(*svc.url); err != nil {
+			return nil, errors.Join(fmt.Errorf("%s: %w", svc.name, err), s.stop())
+		}
+	}
+	return s, nil
+}
+
+// stop shuts every service down and returns their errors.
+func (s *system) stop() error {
+	s.cancel()
+	var errs []error
+	for range cap(s.errs) {
+		errs = append(errs, <-s.errs)
+	}
+	s.close()
+	return errors.Join(errs...)
+}
+
+func (s *system) close() {
+	_ = s.rdb.Close()
+	s.redis.Close()
+}
+
+func (s *system) awaitReady(url string) error {
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		resp, err := s.client.Get(url + "/readyz")
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("not ready after %s", startTimeout)
+}
+
+func closeAll(lns []net.Listener) {
+	for _, ln := range lns {
+		_ = ln.Close()
+	}
+}
+
+func (s *system) validator() *auth.Validator {
+	return auth.NewValidator(auth.ValidatorConfig{KeyStore: s.keys, Issuer: issuer, Audience: audience, Clock: s.clock})
+}
+
+// setupChatMgmt serves the auth API over in-memory tables, with rate
+// limits and revocations in Redis. OTPs go to the SMS inbox.
+func (s *system) setupChatMgmt(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	db := newAuthDB(s.clock)
+	peppers, err := auth.NewPepperRing(1, map[int][]byte{1: pepper})
+	if err != nil {
+		return nil, err
+	}
+	svc := chatmgmtapp.NewAuthService(chatmgmtapp.AuthServiceConfig{
+		OTPStore:        otpStore{db: db},
+		UserStore:       userStore{db: db},
+		SessionStore:    sessionStore{db: db},
+		Transactor:      transactor{db: db},
+		RateLimiter:     chatmgmtadapter.NewRateLimiter(s.rdb.RDB),
+		VerifyLimiter:   chatmgmtadapter.NewOTPVerifyLimiter(s.rdb.RDB),
+		RevocationStore: chatmgmtadapter.NewRevocationStore(s.rdb.RDB),
+		SMSProvider:     s.sms,
+		Minter: auth.NewMinter(auth.MinterConfig{
+			KeyStore:  s.keys,
+			AccessTTL: domain.AccessTokenLifetime,
+			Issuer:    issuer,
+			Audience:  audience,
+			Clock:     s.clock,
+		}),
+		Validator: s.validator(),
+		Clock:     s.clock,
+		Peppers:   peppers,
+		Logger:    observability.Subsystem(deps.Logger, "chatmgmt/auth"),
+	})
+	(&authAPI{svc: svc}).register(deps.HTTPMux)
+
+	return func(context.Context) error {
+		svc.Wait()
+		return nil
+	}, nil
+}
+
+// setupGateway runs client sessions over WebSocket. Sessions end at the
+// start of shutdown, as the drain coordinator ends them in production.
+func (s *system) setupGateway(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	registry := gatewayapp.NewRegistry()
+	sessions := gatewayapp.NewSessionManager(gatewayapp.SessionManagerConfig{
+		Registry: registry,
+		Authenticator: &revocationAuthenticator{
+			validator:   s.validator(),
+			revocations: chatmgmtadapter.NewRevocationStore(s.rdb.RDB),
+		},
+		Clock:  s.clock,
+		Logger: observability.Subsystem(deps.Logger, "gateway/session"),
+		Handlers: map[protocol.FrameType]gatewayapp.FrameHandler{
+			protocol.FrameTypeSendMessage: &sendHandler{ingestURL: s.ingestURL, client: s.client},
+			protocol.FrameTypeSyncRequest: gatewayapp.NewSyncHandler(gatewayapp.SyncHandlerConfig{
+				Store:   s.messages,
+				Members: s.members,
+			}),
+		},
+		ErrorFrame: errmap.ToProtocolError,
+	})
+
+	sessionCtx, endSessions := context.WithCancel(context.WithoutCancel(ctx))
+	deps.OnDrain(func(context.Context) error {
+		endSessions()
+		return nil
+	})
+	(&gatewayAPI{sessions: sessions, registry: registry, ctx: sessionCtx}).register(deps.HTTPMux)
+
+	return func(context.Context) error {
+		endSessions()
+		return nil
+	}, nil
+}
+
+// setupIngest persists into the message log and publishes to the bus.
+func (s *system) setupIngest(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	(&ingestAPI{members: s.members, log: s.messages, producer: &bus{consumer: s.consumer}}).register(deps.HTTPMux)
+	return nil, nil
+}
+
+// setupFanout consumes the bus until shutdown.
+func (s *system) setupFanout(ctx context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+	f := &fanout{
+		consumer:   s.consumer,
+		members:    s.members,
+		log:        s.messages,
+		gatewayURL: s.gatewayURL,
+		client:     s.client,
+		logger:     observability.Subsystem(deps.Logger, "fanout"),
+	}
+	runCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.run(runCtx)
+	}()
+
+	return func(context.Context) error {
+		stop()
+		s.consumer.Close()
+		<-done
+		return nil
+	}, nil
+}
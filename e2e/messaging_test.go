@@ -0,0 +1,573 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/websocket"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	chatmgmtapp "github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	gatewayapp "github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	ingestapp "github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// tracer starts the spans of the harness glue, so a trace shows each hop
+// between services even where the production transport is not wired.
+var tracer = otel.Tracer("e2e")
+
+// persistedTopic carries persisted messages from Ingest to Fanout.
+const persistedTopic = "messages.persisted"
+
+// maxFrameBytes bounds a frame either side of the WebSocket reads.
+const maxFrameBytes = 1 << 20
+
+// members is the chat membership table, shared by Ingest (may the sender
+// post) and Fanout and the Gateway (who receives).
+type members struct {
+	mu    sync.RWMutex
+	chats map[string]map[string]struct{}
+}
+
+var _ gatewayapp.MembershipChecker = (*members)(nil)
+
+func newMembers() *members {
+	return &members{chats: make(map[string]map[string]struct{})}
+}
+
+func (m *members) add(chatID string, userIDs ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	set, ok := m.chats[chatID]
+	if !ok {
+		set = make(map[string]struct{})
+		m.chats[chatID] = set
+	}
+	for _, id := range userIDs {
+		set[id] = struct{}{}
+	}
+}
+
+func (m *members) IsMember(_ context.Context, chatID, userID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.chats[chatID][userID]
+	return ok, nil
+}
+
+func (m *members) list(chatID string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(m.chats[chatID]))
+	for id := range m.chats[chatID] {
+		out = append(out, id)
+	}
+	return out
+}
+
+// messageLog is the messages table: Ingest persists into it, the Gateway
+// syncs from it. Sequences are per chat and start at 1; a repeated client
+// message ID returns the first result, as the DynamoDB persister does.
+type messageLog struct {
+	mu    sync.Mutex
+	chats map[string]*chatLog
+	clock domain.Clock
+}
+
+type chatLog struct {
+	messages []protocol.Message // index i holds sequence i+1
+	byClient map[string]int     // client message ID -> index
+}
+
+// Compile-time checks.
+var (
+	_ ingestapp.Persister  = (*messageLog)(nil)
+	_ gatewayapp.SyncStore = (*messageLog)(nil)
+)
+
+func newMessageLog(clock domain.Clock) *messageLog {
+	return &messageLog{chats: make(map[string]*chatLog), clock: clock}
+}
+
+func (l *messageLog) Persist(_ context.Context, req ingestapp.PersistRequest) (ingestapp.PersistResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	chat, ok := l.chats[req.ChatID.String()]
+	if !ok {
+		chat = &chatLog{byClient: make(map[string]int)}
+		l.chats[req.ChatID.String()] = chat
+	}
+	if i, ok := chat.byClient[req.ClientMessageID]; ok {
+		msg := chat.messages[i]
+		return ingestapp.PersistResult{
+			MessageID: domain.MustMessageID(msg.MessageID),
+			Sequence:  msg.Sequence,
+			CreatedAt: time.UnixMilli(msg.CreatedAt),
+			Duplicate: true,
+		}, nil
+	}
+
+	now := l.clock.Now()
+	msg := protocol.Message{
+		MessageID:        domain.GenerateMessageID().String(),
+		ChatID:           req.ChatID.String(),
+		SenderID:         req.SenderID.String(),
+		ClientMessageID:  req.ClientMessageID,
+		Sequence:         uint64(len(chat.messages)) + 1,
+		ContentType:      string(req.Content.ContentType()),
+		Content:          req.Content.Body(),
+		CreatedAt:        now.UnixMilli(),
+		ServerReceivedAt: req.ReceivedAt.UnixMilli(),
+	}
+	chat.byClient[req.ClientMessageID] = len(chat.messages)
+	chat.messages = append(chat.messages, msg)
+	return ingestapp.PersistResult{
+		MessageID: domain.MustMessageID(msg.MessageID),
+		Sequence:  msg.Sequence,
+		CreatedAt: time.UnixMilli(msg.CreatedAt),
+	}, nil
+}
+
+func (l *messageLog) Chat(_ context.Context, chatID string) (protocol.ChatSnapshot, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var latest uint64
+	if chat, ok := l.chats[chatID]; ok {
+		latest = uint64(len(chat.messages))
+	}
+	return protocol.ChatSnapshot{ChatType: "group", LatestSequence: latest}, nil
+}
+
+func (l *messageLog) ListAfter(_ context.Context, chatID string, after uint64, limit int) ([]protocol.Message, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	chat, ok := l.chats[chatID]
+	if !ok || after >= uint64(len(chat.messages)) {
+		return nil, nil
+	}
+	end := min(int(after)+limit, len(chat.messages))
+	return append([]protocol.Message(nil), chat.messages[after:end]...), nil
+}
+
+// message returns the persisted message with sequence seq, for Fanout.
+func (l *messageLog) message(chatID string, seq uint64) (protocol.Message, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	chat, ok := l.chats[chatID]
+	if !ok || seq == 0 || seq > uint64(len(chat.messages)) {
+		return protocol.Message{}, false
+	}
+	return chat.messages[seq-1], true
+}
+
+// bus is the in-memory Kafka between Ingest and Fanout: a record produced
+// is pushed straight to the consumer, trace context included.
+type bus struct {
+	consumer *kafkatest.Consumer
+}
+
+var _ kafka.Producer = (*bus)(nil)
+
+func (b *bus) Produce(ctx context.Context, records ...*kafka.Record) error {
+	for _, r := range records {
+		kafka.InjectTraceContext(ctx, r)
+	}
+	b.consumer.Push(records...)
+	return nil
+}
+
+// persistRequest is the body of Ingest's persist route, the JSON form of
+// PersistMessage that the Gateway calls.
+type persistRequest struct {
+	ChatID          string `json:"chat_id"`
+	SenderID        string `json:"sender_id"`
+	ClientMessageID string `json:"client_message_id"`
+	ContentType     string `json:"content_type"`
+	Content         string `json:"content"`
+	ReceivedAt      int64  `json:"received_at"` // Unix millis, Gateway clock
+}
+
+// persistedEvent is the value of a messages.persisted record. Fanout reads
+// the message itself from the log by sequence.
+type persistedEvent struct {
+	ChatID   string `json:"chat_id"`
+	Sequence uint64 `json:"sequence"`
+}
+
+// ingestAPI is the Ingest persist flow (ADR-004): check the sender may
+// post, persist, and publish the new message for Fanout. Duplicates are
+// acknowledged with the original sequence and not published again.
+type ingestAPI struct {
+	members  *members
+	log      ingestapp.Persister
+	producer kafka.Producer
+}
+
+func (a *ingestAPI) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /internal/persist", a.persist)
+}
+
+func (a *ingestAPI) persist(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "ingest.persist", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	var req persistRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	ack, err := a.handle(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	span.SetAttributes(attribute.Int64("message.sequence", int64(ack.Sequence))) //nolint:gosec // sequences fit in int64
+	writeJSON(w, ack)
+}
+
+func (a *ingestAPI) handle(ctx context.Context, req persistRequest) (protocol.SendMessageAck, error) {
+	chatID, err := domain.NewChatID(req.ChatID)
+	if err != nil {
+		return protocol.SendMessageAck{}, err
+	}
+	senderID, err := domain.NewUserID(req.SenderID)
+	if err != nil {
+		return protocol.SendMessageAck{}, err
+	}
+	content, err := domain.NewMessageContent(domain.ContentType(req.ContentType), req.Content)
+	if err != nil {
+		return protocol.SendMessageAck{}, err
+	}
+	if ok, _ := a.members.IsMember(ctx, req.ChatID, req.SenderID); !ok {
+		return protocol.SendMessageAck{}, fmt.Errorf("persist to %s: %w", req.ChatID, domain.ErrNotMember)
+	}
+
+	res, err := a.log.Persist(ctx, ingestapp.PersistRequest{
+		ChatID:          chatID,
+		SenderID:        senderID,
+		ClientMessageID: req.ClientMessageID,
+		Content:         content,
+		ReceivedAt:      domain.ServerTimeFromMillis(req.ReceivedAt),
+	})
+	if err != nil {
+		return protocol.SendMessageAck{}, err
+	}
+	ack := protocol.SendMessageAck{
+		ClientMessageID:  req.ClientMessageID,
+		MessageID:        res.MessageID.String(),
+		Sequence:         res.Sequence,
+		CreatedAt:        res.CreatedAt.UnixMilli(),
+		ServerReceivedAt: req.ReceivedAt,
+	}
+	if res.Duplicate {
+		return ack, nil
+	}
+
+	value, err := json.Marshal(persistedEvent{ChatID: req.ChatID, Sequence: res.Sequence})
+	if err != nil {
+		return protocol.SendMessageAck{}, err
+	}
+	if err := a.producer.Produce(ctx, &kafka.Record{
+		Topic: persistedTopic,
+		Key:   []byte(req.ChatID),
+		Value: value,
+	}); err != nil {
+		return protocol.SendMessageAck{}, fmt.Errorf("publish: %w", err)
+	}
+	return ack, nil
+}
+
+// deliverRequest is the body of the Gateway's deliver route: a message for
+// every connection of the listed users.
+type deliverRequest struct {
+	UserIDs []string         `json:"user_ids"`
+	Message protocol.Message `json:"message"`
+}
+
+// fanout consumes messages.persisted and delivers each message to every
+// member of its chat through the Gateway, committing once delivered.
+type fanout struct {
+	consumer   *kafkatest.Consumer
+	members    *members
+	log        *messageLog
+	gatewayURL string
+	client     *http.Client
+	logger     *slog.Logger
+}
+
+func (f *fanout) run(ctx context.Context) {
+	for {
+		records, err := f.consumer.Poll(ctx)
+		if err != nil {
+			return
+		}
+		for _, r := range records {
+			f.process(ctx, r)
+		}
+		_ = f.consumer.Commit(ctx, records...)
+	}
+}
+
+func (f *fanout) process(ctx context.Context, r *kafka.Record) {
+	ctx, span := kafka.StartConsumeSpan(ctx, r)
+	defer span.End()
+
+	var ev persistedEvent
+	if err := json.Unmarshal(r.Value, &ev); err != nil {
+		span.RecordError(err)
+		return
+	}
+	msg, ok := f.log.message(ev.ChatID, ev.Sequence)
+	if !ok {
+		span.RecordError(fmt.Errorf("message %s/%d: %w", ev.ChatID, ev.Sequence, domain.ErrNotFound))
+		return
+	}
+	if err := f.deliver(ctx, deliverRequest{UserIDs: f.members.list(ev.ChatID), Message: msg}); err != nil {
+		span.RecordError(err)
+		f.logger.WarnContext(ctx, "fanout delivery failed", slog.String("error", err.Error()))
+	}
+}
+
+func (f *fanout) deliver(ctx context.Context, req deliverRequest) error {
+	ctx, span := tracer.Start(ctx, "fanout.deliver", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	return post(ctx, f.client, f.gatewayURL+"/internal/deliver", req, nil)
+}
+
+// gatewayAPI is the Gateway's client endpoint, GET /v1/ws, and the route
+// Fanout delivers through. Both are harness glue: this tree has the
+// session layer but not the WebSocket port or the delivery consumer.
+type gatewayAPI struct {
+	sessions *gatewayapp.SessionManager
+	registry *gatewayapp.Registry
+	ctx      context.Context // ends client sessions on shutdown
+}
+
+func (a *gatewayAPI) register(mux *http.ServeMux) {
+	mux.Handle("GET /v1/ws", websocket.Handler(a.serveWS))
+	mux.HandleFunc("POST /internal/deliver", a.deliver)
+}
+
+func (a *gatewayAPI) serveWS(ws *websocket.Conn) {
+	ws.MaxPayloadBytes = maxFrameBytes
+	// The HTTP server's read and write timeouts still apply to the hijacked
+	// connection; a session outlives them.
+	_ = ws.SetDeadline(time.Time{})
+	r := ws.Request()
+	err := a.sessions.Serve(a.ctx, &wsTransport{ws: ws}, bearer(r), r.Header.Get("X-Device-Id"))
+	if err == nil || a.ctx.Err() != nil {
+		return
+	}
+	closing := errmap.ToWebSocketClose(err)
+	f, encErr := protocol.NewFrame(protocol.FrameTypeConnectionClosing, protocol.ConnectionClosing{
+		Reason: closing.Reason,
+		Code:   closing.Code,
+	})
+	if encErr == nil {
+		_ = protocol.WriteFrame(ws, f)
+	}
+}
+
+func (a *gatewayAPI) deliver(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	_, span := tracer.Start(ctx, "gateway.deliver", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	var req deliverRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, req.Message)
+	if err != nil {
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	delivered := 0
+	for _, userID := range req.UserIDs {
+		for _, c := range a.registry.UserConnections(userID) {
+			if c.Enqueue(f) == nil {
+				delivered++
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("delivery.connections", delivered))
+	writeJSON(w, struct{}{})
+}
+
+// wsTransport adapts a WebSocket connection to gatewayapp.Transport. Recv
+// does not observe ctx; the handler closes the socket when Serve returns.
+type wsTransport struct {
+	ws *websocket.Conn
+}
+
+func (t *wsTransport) Send(_ context.Context, f *protocol.Frame) error {
+	return protocol.WriteFrame(t.ws, f)
+}
+
+func (t *wsTransport) Recv(_ context.Context) (*protocol.Frame, error) {
+	var f *protocol.Frame
+	if err := clientFrames.Receive(t.ws, &f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// clientFrames decodes client messages as the Gateway does, with its limits
+// and its refusal of binary messages.
+var clientFrames = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		kind := gatewayapp.TextMessage
+		if payloadType == websocket.BinaryFrame {
+			kind = gatewayapp.BinaryMessage
+		}
+		f, err := gatewayapp.DecodeClientFrame(kind, data)
+		if err != nil {
+			return err
+		}
+		*v.(**protocol.Frame) = f
+		return nil
+	},
+}
+
+// sendHandler answers send_message frames: it asks Ingest to persist the
+// message and queues the ack. The call carries the session's trace.
+type sendHandler struct {
+	ingestURL string
+	client    *http.Client
+}
+
+func (h *sendHandler) HandleFrame(ctx context.Context, c *gatewayapp.Connection, f *protocol.Frame) error {
+	ctx, span := tracer.Start(ctx, "gateway.send_message", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	var msg protocol.SendMessage
+	if err := f.ParsePayload(&msg); err != nil {
+		return domain.NewValidationError("payload", "is not a valid send_message")
+	}
+	var ack protocol.SendMessageAck
+	if err := post(ctx, h.client, h.ingestURL+"/internal/persist", persistRequest{
+		ChatID:          msg.ChatID,
+		SenderID:        c.Identity().UserID,
+		ClientMessageID: msg.ClientMessageID,
+		ContentType:     msg.ContentType,
+		Content:         msg.Content,
+		ReceivedAt:      gatewayapp.ReceivedAt(ctx).UnixMilli(),
+	}, &ack); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	frame, err := protocol.NewFrame(protocol.FrameTypeSendMessageAck, ack)
+	if err != nil {
+		return fmt.Errorf("encode send_message_ack: %w", err)
+	}
+	return c.Enqueue(frame)
+}
+
+// revocationAuthenticator authenticates access tokens and refuses those
+// revoked at logout or issued before a user-wide revocation. It reads the revocation store Chat Mgmt writes.
+type revocationAuthenticator struct {
+	validator   *auth.Validator
+	revocations chatmgmtapp.RevocationStore
+}
+
+var _ gatewayapp.Authenticator = (*revocationAuthenticator)(nil)
+
+func (a *revocationAuthenticator) Authenticate(ctx context.Context, accessToken string) (gatewayapp.Identity, error) {
+	claims, err := a.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		return gatewayapp.Identity{}, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	revoked, err := a.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return gatewayapp.Identity{}, err
+	}
+	if revoked {
+		return gatewayapp.Identity{}, fmt.Errorf("token %s: %w", claims.ID, domain.ErrUnauthorized)
+	}
+	if claims.IssuedAt != nil {
+		revoked, err = a.revocations.IsUserRevoked(ctx, claims.Subject, claims.IssuedAt.Time)
+		if err != nil {
+			return gatewayapp.Identity{}, err
+		}
+		if revoked {
+			return gatewayapp.Identity{}, fmt.Errorf("user %s: %w", claims.Subject, domain.ErrUnauthorized)
+		}
+	}
+	identity := gatewayapp.Identity{UserID: claims.Subject, SessionID: claims.SessionID}
+	if claims.ExpiresAt != nil {
+		identity.AccessTokenExpiry = claims.ExpiresAt.Time
+	}
+	return identity, nil
+}
+
+// post sends in as JSON with the trace context of ctx and decodes a 200
+// reply into out when set. An error reply is mapped back to its domain
+// error by problem code, so it reaches the client unchanged.
+func post(ctx context.Context, client *http.Client, url string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, maxFrameBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var p errmap.Problem
+		_ = json.Unmarshal(payload, &p)
+		return problemError(p)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(payload, out)
+}
+
+// problemErrors maps the problem codes the harness routes return back to
+// domain errors.
+var problemErrors = map[string]error{
+	"NOT_MEMBER":           domain.ErrNotMember,
+	"NOT_FOUND":            domain.ErrNotFound,
+	"INVALID_ARGUMENT":     domain.ErrInvalidInput,
+	"INVALID_CONTENT_TYPE": domain.ErrInvalidContentType,
+	"MESSAGE_TOO_LARGE":    domain.ErrMessageTooLarge,
+	"EMPTY_ID":             domain.ErrEmptyID,
+	"INVALID_ID":           domain.ErrInvalidID,
+}
+
+func problemError(p errmap.Problem) error {
+	if err, ok := problemErrors[p.Code]; ok {
+		return fmt.Errorf("%s: %w", p.Detail, err)
+	}
+	return errors.New(p.Title + ": " + p.Detail)
+}
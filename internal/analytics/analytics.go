@@ -0,0 +1,277 @@
+// Package analytics emits anonymized product events to a Kafka analytics
+// topic. Events carry pseudonyms instead of user and chat IDs, bucketed
+// sizes instead of exact ones, and never message content, so the topic
+// can feed product dashboards without becoming a second copy of user data.
+//
+// Analytics is not the audit log: audit records say who did what, with
+// real identities, and are never sampled or dropped. Analytics events are
+// sampled per user and are best effort: a full buffer or a failed produce
+// loses events rather than slowing a request.
+package analytics
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+// Event names.
+const (
+	EventMessageSent    = "message_sent"
+	EventSessionStarted = "session_started"
+)
+
+// EventHeader is the record header carrying the event name, so consumers
+// can route records without decoding them.
+const EventHeader = "event"
+
+var eventsTotal metric.Int64Counter
+
+func init() {
+	eventsTotal, _ = otel.Meter("analytics").Int64Counter("analytics_events_total",
+		metric.WithDescription("Analytics events by event and outcome (emitted, sampled_out, dropped, failed)"))
+}
+
+// Config holds the dependencies for Emitter.
+type Config struct {
+	Producer kafka.Producer
+	Topic    string
+	// PseudonymKey keys the HMAC that replaces user and chat IDs. It must
+	// be at least domain.MinAnalyticsPseudonymKeyBytes long. Rotating it
+	// unlinks every earlier pseudonym.
+	PseudonymKey []byte
+	// SampleRate is the share of users, in [0, 1], whose events are kept.
+	// Rates overrides it per event name. Sampling is by user, so a sampled
+	// user's events are all kept.
+	SampleRate float64
+	Rates      map[string]float64
+	// BufferSize bounds queued events. Zero defaults to
+	// domain.AnalyticsBufferEvents.
+	BufferSize int
+	Clock      domain.Clock
+	Logger     *slog.Logger
+}
+
+// Emitter queues analytics events and produces them from Run. Emit calls
+// never block. Safe for concurrent use.
+type Emitter struct {
+	producer kafka.Producer
+	topic    string
+	key      []byte
+	rate     float64
+	rates    map[string]float64
+	clock    domain.Clock
+	logger   *slog.Logger
+
+	queue chan *kafka.Record
+}
+
+// New creates an Emitter. Start Run to produce queued events.
+func New(cfg Config) (*Emitter, error) {
+	if cfg.Topic == "" {
+		return nil, errors.New("analytics: topic is required")
+	}
+	if len(cfg.PseudonymKey) < domain.MinAnalyticsPseudonymKeyBytes {
+		return nil, fmt.Errorf("analytics: pseudonym key must be at least %d bytes", domain.MinAnalyticsPseudonymKeyBytes)
+	}
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = domain.AnalyticsBufferEvents
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Emitter{
+		producer: cfg.Producer,
+		topic:    cfg.Topic,
+		key:      cfg.PseudonymKey,
+		rate:     cfg.SampleRate,
+		rates:    cfg.Rates,
+		clock:    clock,
+		logger:   logger,
+		queue:    make(chan *kafka.Record, size),
+	}, nil
+}
+
+// record is the JSON value of an analytics record. The Kafka key is the
+// actor, so one user's events stay in order.
+type record struct {
+	Event string            `json:"event"`
+	Actor string            `json:"actor"`
+	Chat  string            `json:"chat,omitempty"`
+	Props map[string]string `json:"props,omitempty"`
+	At    string            `json:"at"`
+}
+
+// MessageSent records a message persisted for senderID in chatID. Only
+// the content type and a size bucket are kept.
+func (e *Emitter) MessageSent(ctx context.Context, senderID, chatID, contentType string, size int) {
+	e.emit(ctx, EventMessageSent, senderID, chatID, map[string]string{
+		"content_type": contentType,
+		"size":         sizeBucket(size),
+	})
+}
+
+// SessionStarted records a login by userID; newUser marks a registration.
+func (e *Emitter) SessionStarted(ctx context.Context, userID string, newUser bool) {
+	e.emit(ctx, EventSessionStarted, userID, "", map[string]string{
+		"new_user": strconv.FormatBool(newUser),
+	})
+}
+
+func (e *Emitter) emit(ctx context.Context, event, userID, chatID string, props map[string]string) {
+	sum := e.mac("user", userID)
+	actor := base64.RawURLEncoding.EncodeToString(sum)
+	if !e.sampled(event, sum) {
+		count(ctx, event, "sampled_out")
+		return
+	}
+	rec := record{
+		Event: event,
+		Actor: actor,
+		Props: props,
+		At:    domain.TimestampNow(e.clock).String(),
+	}
+	if chatID != "" {
+		rec.Chat = base64.RawURLEncoding.EncodeToString(e.mac("chat", chatID))
+	}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		count(ctx, event, "failed")
+		return
+	}
+
+	r := &kafka.Record{Topic: e.topic, Key: []byte(actor), Value: value}
+	kafka.SetHeader(r, EventHeader, event)
+	select {
+	case e.queue <- r:
+	default:
+		count(ctx, event, "dropped")
+	}
+}
+
+// mac returns the pseudonym bytes for id: a stable stand-in that cannot be
+// linked back to id without the key. The kind keeps a user and a chat
+// with the same ID from sharing a pseudonym.
+func (e *Emitter) mac(kind, id string) []byte {
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write([]byte(kind + ":" + id))
+	return mac.Sum(nil)[:16]
+}
+
+// sampled reports whether the user with pseudonym sum falls within
+// event's sample rate. The pseudonym is uniformly distributed, so its
+// leading bytes serve as the sampling hash.
+func (e *Emitter) sampled(event string, sum []byte) bool {
+	rate, ok := e.rates[event]
+	if !ok {
+		rate = e.rate
+	}
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(sum))/math.MaxUint64 < rate
+}
+
+// Run produces queued events in batches until ctx is done, then flushes
+// what is left within domain.KafkaProduceTimeout. Produce failures are logged and the batch is dropped.
+func (e *Emitter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(domain.AnalyticsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*kafka.Record, 0, domain.AnalyticsBatchEvents)
+	for {
+		select {
+		case <-ctx.Done():
+			e.drain(&batch)
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), domain.KafkaProduceTimeout)
+			e.flush(flushCtx, batch)
+			cancel()
+			return nil
+		case rec := <-e.queue:
+			batch = append(batch, rec)
+			if len(batch) == domain.AnalyticsBatchEvents {
+				batch = e.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = e.flush(ctx, batch)
+		}
+	}
+}
+
+// drain moves every queued event into batch.
+func (e *Emitter) drain(batch *[]*kafka.Record) {
+	for {
+		select {
+		case rec := <-e.queue:
+			*batch = append(*batch, rec)
+		default:
+			return
+		}
+	}
+}
+
+// flush produces batch and returns it emptied for reuse.
+func (e *Emitter) flush(ctx context.Context, batch []*kafka.Record) []*kafka.Record {
+	if len(batch) == 0 {
+		return batch
+	}
+	for start := 0; start < len(batch); start += domain.AnalyticsBatchEvents {
+		chunk := batch[start:min(start+domain.AnalyticsBatchEvents, len(batch))]
+		outcome := "emitted"
+		if err := e.producer.Produce(ctx, chunk...); err != nil {
+			outcome = "failed"
+			e.logger.WarnContext(ctx, "analytics produce failed", "events", len(chunk), "error", err)
+		}
+		for _, rec := range chunk {
+			name, _ := kafka.Header(rec, EventHeader)
+			count(ctx, name, outcome)
+		}
+	}
+	return batch[:0]
+}
+
+func count(ctx context.Context, event, outcome string) {
+	eventsTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("event", event),
+		attribute.String("outcome", outcome),
+	))
+}
+
+// sizeBucket coarsens a message size so it cannot fingerprint a message.
+func sizeBucket(size int) string {
+	switch {
+	case size < 100:
+		return "<100"
+	case size < 1000:
+		return "<1k"
+	case size < 10_000:
+		return "<10k"
+	default:
+		return ">=10k"
+	}
+}
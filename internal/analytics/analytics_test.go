@@ -0,0 +1,169 @@
+package analytics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/analytics"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+)
+
+var testKey = bytes.Repeat([]byte("k"), 32)
+
+type wireEvent struct {
+	Event string            `json:"event"`
+	Actor string            `json:"actor"`
+	Chat  string            `json:"chat"`
+	Props map[string]string `json:"props"`
+	At    string            `json:"at"`
+}
+
+func newEmitter(t *testing.T, producer kafka.Producer, mutate func(*analytics.Config)) *analytics.Emitter {
+	t.Helper()
+	cfg := analytics.Config{
+		Producer:     producer,
+		Topic:        "analytics.events",
+		PseudonymKey: testKey,
+		SampleRate:   1,
+		Clock:        domaintest.NewFakeClock(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)),
+	}
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	e, err := analytics.New(cfg)
+	require.NoError(t, err)
+	return e
+}
+
+// run emits through e, then stops Run so it flushes, and returns what was
+// produced.
+func run(t *testing.T, e *analytics.Emitter, producer *kafkatest.Producer, emit func()) []*kafka.Record {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	emit()
+	go func() { done <- e.Run(ctx) }()
+	cancel()
+	require.NoError(t, <-done)
+	return producer.Records()
+}
+
+func decode(t *testing.T, rec *kafka.Record) wireEvent {
+	t.Helper()
+	var ev wireEvent
+	require.NoError(t, json.Unmarshal(rec.Value, &ev))
+	return ev
+}
+
+func TestEmitter_Pseudonymizes(t *testing.T) {
+	producer := kafkatest.NewProducer()
+	e := newEmitter(t, producer, nil)
+
+	records := run(t, e, producer, func() {
+		e.MessageSent(context.Background(), "user-1", "chat-1", "text", 420)
+		e.MessageSent(context.Background(), "user-1", "chat-1", "image", 20_000)
+		e.SessionStarted(context.Background(), "user-1", true)
+	})
+
+	require.Len(t, records, 3)
+	sent, again, started := decode(t, records[0]), decode(t, records[1]), decode(t, records[2])
+
+	assert.Equal(t, analytics.EventMessageSent, sent.Event)
+	assert.Equal(t, map[string]string{"content_type": "text", "size": "<1k"}, sent.Props)
+	assert.Equal(t, "2026-10-15T12:00:00.000Z", sent.At)
+	assert.Equal(t, map[string]string{"content_type": "image", "size": ">=10k"}, again.Props)
+	assert.Equal(t, map[string]string{"new_user": "true"}, started.Props)
+	assert.Empty(t, started.Chat)
+
+	assert.Equal(t, sent.Actor, again.Actor, "pseudonyms are stable")
+	assert.Equal(t, sent.Chat, again.Chat)
+	assert.NotEqual(t, sent.Actor, sent.Chat)
+	for _, rec := range records {
+		assert.Equal(t, "analytics.events", rec.Topic)
+		assert.Equal(t, sent.Actor, string(rec.Key))
+		assert.NotContains(t, string(rec.Value), "user-1")
+		assert.NotContains(t, string(rec.Value), "chat-1")
+	}
+	name, ok := kafka.Header(records[0], analytics.EventHeader)
+	require.True(t, ok)
+	assert.Equal(t, analytics.EventMessageSent, name)
+}
+
+func TestEmitter_PseudonymsDependOnKey(t *testing.T) {
+	producerA, producerB := kafkatest.NewProducer(), kafkatest.NewProducer()
+	a := newEmitter(t, producerA, nil)
+	b := newEmitter(t, producerB, func(c *analytics.Config) { c.PseudonymKey = bytes.Repeat([]byte("r"), 32) })
+
+	recA := run(t, a, producerA, func() { a.SessionStarted(context.Background(), "user-1", false) })
+	recB := run(t, b, producerB, func() { b.SessionStarted(context.Background(), "user-1", false) })
+
+	assert.NotEqual(t, decode(t, recA[0]).Actor, decode(t, recB[0]).Actor)
+}
+
+func TestEmitter_Sampling(t *testing.T) {
+	producer := kafkatest.NewProducer()
+	e := newEmitter(t, producer, func(c *analytics.Config) {
+		c.SampleRate = 0.5
+		c.Rates = map[string]float64{analytics.EventSessionStarted: 0}
+	})
+
+	records := run(t, e, producer, func() {
+		for i := range 1000 {
+			user := fmt.Sprintf("user-%d", i)
+			e.SessionStarted(context.Background(), user, false)
+			e.MessageSent(context.Background(), user, "chat-1", "text", 10)
+			e.MessageSent(context.Background(), user, "chat-2", "text", 10)
+		}
+	})
+
+	perActor := map[string]int{}
+	for _, rec := range records {
+		ev := decode(t, rec)
+		assert.NotEqual(t, analytics.EventSessionStarted, ev.Event, "overridden to zero")
+		perActor[ev.Actor]++
+	}
+	assert.InDelta(t, 500, len(perActor), 75)
+	for actor, n := range perActor {
+		assert.Equal(t, 2, n, "a sampled user keeps all events: %s", actor)
+	}
+}
+
+func TestEmitter_DropsWhenBufferFull(t *testing.T) {
+	producer := kafkatest.NewProducer()
+	e := newEmitter(t, producer, func(c *analytics.Config) { c.BufferSize = 2 })
+
+	records := run(t, e, producer, func() {
+		for range 5 {
+			e.SessionStarted(context.Background(), "user-1", false)
+		}
+	})
+
+	assert.Len(t, records, 2)
+}
+
+func TestEmitter_ProduceFailureIsNotFatal(t *testing.T) {
+	producer := kafkatest.NewProducer()
+	producer.FailWith(assert.AnError)
+	e := newEmitter(t, producer, nil)
+
+	records := run(t, e, producer, func() { e.SessionStarted(context.Background(), "user-1", false) })
+
+	assert.Empty(t, records)
+}
+
+func TestNew_Validates(t *testing.T) {
+	_, err := analytics.New(analytics.Config{PseudonymKey: testKey})
+	assert.ErrorContains(t, err, "topic is required")
+
+	_, err = analytics.New(analytics.Config{Topic: "analytics.events", PseudonymKey: []byte("short")})
+	assert.ErrorContains(t, err, "pseudonym key")
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+// Every comparison of a secret, or of a value derived from one, goes
+// through these helpers so that none returns early at the first differing
+// byte and leaks how much of a guess was right.
+
+// ConstantTimeEqualHash reports whether two encoded hashes, MACs or
+// random tokens are equal, in time that depends only on their lengths.
+// Those lengths are fixed by the encoding, so they reveal nothing.
+func ConstantTimeEqualHash(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// ConstantTimeEqualBytes is ConstantTimeEqualHash for raw digests.
+func ConstantTimeEqualBytes(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// ConstantTimeEqualSecret reports whether presented equals secret, a
+// shared secret such as an admin or homeserver token. Both are compared
+// as SHA-256 digests, so the time taken reveals neither the secret's
+// content nor its length.
+func ConstantTimeEqualSecret(presented, secret string) bool {
+	got := sha256.Sum256([]byte(presented))
+	want := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(got[:], want[:]) == 1
+}
+
+// VerifyHMACSHA256 reports whether mac is the HMAC-SHA256 under key of
+// the concatenated message parts.
+func VerifyHMACSHA256(key, mac []byte, message ...[]byte) bool {
+	h := hmac.New(sha256.New, key)
+	for _, part := range message {
+		h.Write(part)
+	}
+	return hmac.Equal(mac, h.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package auth_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/timingcheck"
+)
+
+func TestConstantTimeEqual(t *testing.T) {
+	assert.True(t, auth.ConstantTimeEqualHash("abc", "abc"))
+	assert.False(t, auth.ConstantTimeEqualHash("abc", "abd"))
+	assert.False(t, auth.ConstantTimeEqualHash("abc", "abcd"))
+	assert.True(t, auth.ConstantTimeEqualBytes([]byte{1, 2}, []byte{1, 2}))
+	assert.False(t, auth.ConstantTimeEqualBytes([]byte{1, 2}, []byte{1}))
+
+	assert.True(t, auth.ConstantTimeEqualSecret("s3cret", "s3cret"))
+	assert.False(t, auth.ConstantTimeEqualSecret("s3cre", "s3cret"))
+	assert.False(t, auth.ConstantTimeEqualSecret("", "s3cret"))
+}
+
+func TestVerifyHMACSHA256(t *testing.T) {
+	key := []byte("key")
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("1700000000.payload"))
+	sum := mac.Sum(nil)
+
+	assert.True(t, auth.VerifyHMACSHA256(key, sum, []byte("1700000000."), []byte("payload")))
+	assert.False(t, auth.VerifyHMACSHA256(key, sum, []byte("1700000001."), []byte("payload")))
+	assert.False(t, auth.VerifyHMACSHA256([]byte("other"), sum, []byte("1700000000.payload")))
+	assert.False(t, auth.VerifyHMACSHA256(key, sum[:16], []byte("1700000000.payload")))
+}
+
+// TestVerifyOTPMAC_ConstantTime checks that a wrong code takes as long to
+// reject when its MAC differs from the stored one in the first character
+// as in the last, so timing reveals nothing about the stored MAC.
+func TestVerifyOTPMAC_ConstantTime(t *testing.T) {
+	const (
+		phone     = "+14155552671"
+		expiresAt = "2026-01-01T00:05:00Z"
+		params    = "len=6;alphabet=0123456789;ttl=300"
+	)
+	ring, err := auth.NewPepperRing(1, map[int][]byte{1: []byte("timing-pepper-32-bytes-long-sec!")})
+	assert.NoError(t, err)
+	hash := ring.HashPhone(phone)
+	candidate := ring.ComputeOTPMAC("000000", hash, expiresAt, params)
+
+	// Stored MACs sharing all but the first or all but the last character
+	// with the wrong code's MAC.
+	flip := func(c byte) byte {
+		if c == '0' {
+			return '1'
+		}
+		return '0'
+	}
+	first := []byte(candidate)
+	first[3] = flip(first[3]) // after the "v1:" prefix
+	last := []byte(candidate)
+	last[len(last)-1] = flip(last[len(last)-1])
+	stored := [2]string{string(first), string(last)}
+
+	timingcheck.AssertConstantTime(t, func(class int) {
+		ring.VerifyOTPMAC("000000", hash, expiresAt, params, stored[class])
+	})
+}
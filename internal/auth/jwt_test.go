@@ -11,9 +11,10 @@ import (
 
 	"github.com/aelexs/realtime-messaging-platform/internal/auth"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/allocbudget"
 )
 
-func newTestMinterAndValidator(t *testing.T) (*auth.Minter, *auth.Validator, *auth.StaticKeyStore, *domaintest.FakeClock) {
+func newTestMinterAndValidator(t testing.TB) (*auth.Minter, *auth.Validator, *auth.StaticKeyStore, *domaintest.FakeClock) {
 	t.Helper()
 	key := generateTestKey(t)
 	keyID := "test-key-001"
@@ -285,3 +286,26 @@ func TestValidateIgnoreExpiry(t *testing.T) {
 		assert.Equal(t, "user_123", claims.Subject)
 	})
 }
+
+func TestValidateAccessToken_AllocBudget(t *testing.T) {
+	minter, validator, _, _ := newTestMinterAndValidator(t)
+	result, err := minter.MintAccessToken("user_123", "sess_456")
+	require.NoError(t, err)
+
+	allocbudget.Check(t, allocbudget.Budget{Allocs: 60, RaceAllocs: 72}, func() {
+		_, _ = validator.ValidateAccessToken(result.Token)
+	})
+}
+
+func BenchmarkValidateAccessToken(b *testing.B) {
+	minter, validator, _, _ := newTestMinterAndValidator(b)
+	result, err := minter.MintAccessToken("user_123", "sess_456")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := validator.ValidateAccessToken(result.Token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package auth
 import (
 	"crypto/rsa"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 )
 
@@ -15,6 +17,10 @@ type KeyStore interface {
 
 	// PublicKey returns the public key for the given key ID.
 	PublicKey(kid string) (*rsa.PublicKey, error)
+
+	// KeyIDs returns the current signing key ID and every key ID tokens
+	// are verified against, sorted. Operators use it to check rotations.
+	KeyIDs() (signing string, verification []string)
 }
 
 // StaticKeyStore is a KeyStore backed by in-memory keys. Use for testing only.
@@ -57,6 +63,13 @@ func (s *StaticKeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	return pk, nil
 }
 
+// KeyIDs returns the signing key ID and the IDs of every public key.
+func (s *StaticKeyStore) KeyIDs() (string, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keyID, slices.Sorted(maps.Keys(s.publicKeys))
+}
+
 // AddPublicKey adds a public key for testing key rotation scenarios.
 func (s *StaticKeyStore) AddPublicKey(kid string, key *rsa.PublicKey) {
 	s.mu.Lock()
@@ -40,6 +40,12 @@ func TestStaticKeyStore(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, &key2.PublicKey, pk)
 	})
+
+	t.Run("KeyIDs lists the signing and verification key IDs", func(t *testing.T) {
+		signing, verification := store.KeyIDs()
+		assert.Equal(t, keyID, signing)
+		assert.Equal(t, []string{"key-002", keyID}, verification)
+	})
 }
 
 func TestStaticKeyStore_NilKey(t *testing.T) {
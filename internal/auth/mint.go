@@ -46,6 +46,11 @@ func NewMinter(cfg MinterConfig) *Minter {
 	}
 }
 
+// AccessTTL returns the lifetime of the access tokens m mints.
+func (m *Minter) AccessTTL() time.Duration {
+	return m.accessTTL
+}
+
 // MintAccessToken creates a signed RS256 JWT access token for the given
 // user and session. Returns the signed token string, JTI, and expiration.
 func (m *Minter) MintAccessToken(userID, sessionID string) (MintResult, error) {
@@ -14,7 +14,7 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
 )
 
-func generateTestKey(t *testing.T) *rsa.PrivateKey {
+func generateTestKey(t testing.TB) *rsa.PrivateKey {
 	t.Helper()
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	require.NoError(t, err)
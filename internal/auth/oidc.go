@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// HTTPDoer is the HTTP client an OIDCVerifier fetches provider metadata
+// with. *http.Client satisfies it.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// IDTokenClaims are the OpenID Connect ID token claims workspace SSO reads.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+}
+
+// OIDCVerifierConfig holds configuration for creating an OIDCVerifier.
+type OIDCVerifierConfig struct {
+	// Client fetches discovery documents and key sets. Nil uses an
+	// http.Client with a domain.SSOFetchTimeout timeout.
+	Client HTTPDoer
+	Clock  domain.Clock
+}
+
+// OIDCVerifier validates ID tokens from any number of OpenID Connect
+// providers. Each issuer's signing keys are discovered from its
+// /.well-known/openid-configuration and cached for
+// domain.SSOMetadataCacheTTL; a token signed with an unknown key ID
+// refetches them, at most once per domain.SSOKeyRefreshInterval, so a
+// provider's key rotation is picked up without a restart.
+type OIDCVerifier struct {
+	client HTTPDoer
+	clock  domain.Clock
+
+	mu        sync.Mutex
+	providers map[string]*oidcKeySet
+}
+
+// oidcKeySet is one issuer's cached signing keys.
+type oidcKeySet struct {
+	keys      map[string]any // *rsa.PublicKey or *ecdsa.PublicKey by kid
+	fetchedAt time.Time
+}
+
+// NewOIDCVerifier creates an OIDCVerifier.
+func NewOIDCVerifier(cfg OIDCVerifierConfig) *OIDCVerifier {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: domain.SSOFetchTimeout}
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	return &OIDCVerifier{client: client, clock: clock, providers: make(map[string]*oidcKeySet)}
+}
+
+// VerifyIDToken validates rawToken as an ID token that issuer issued to
+// clientID for the login that sent nonce. A token that fails validation
+// returns an error wrapping domain.ErrUnauthorized; a provider that cannot
+// be reached returns one wrapping domain.ErrUnavailable.
+func (v *OIDCVerifier) VerifyIDToken(ctx context.Context, issuer, clientID, rawToken, nonce string) (*IDTokenClaims, error) {
+	var claims IDTokenClaims
+	_, err := jwt.ParseWithClaims(rawToken, &claims,
+		func(token *jwt.Token) (any, error) { return v.keyFunc(ctx, issuer, token) },
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithTimeFunc(v.clock.Now),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, domain.ErrUnavailable) {
+			return nil, fmt.Errorf("verify id token: %w", err)
+		}
+		return nil, fmt.Errorf("verify id token: %w", errors.Join(err, domain.ErrUnauthorized))
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("verify id token: missing sub: %w", domain.ErrUnauthorized)
+	}
+	if claims.IssuedAt == nil || v.clock.Now().Sub(claims.IssuedAt.Time) > domain.SSOMaxIDTokenAge {
+		return nil, fmt.Errorf("verify id token: issued too long ago: %w", domain.ErrUnauthorized)
+	}
+	if nonce == "" || !ConstantTimeEqualHash(claims.Nonce, nonce) {
+		return nil, fmt.Errorf("verify id token: nonce mismatch: %w", domain.ErrUnauthorized)
+	}
+	return &claims, nil
+}
+
+// keyFunc resolves the token's verification key from issuer's key set.
+func (v *OIDCVerifier) keyFunc(ctx context.Context, issuer string, token *jwt.Token) (any, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, fmt.Errorf("missing or invalid kid in token header")
+	}
+
+	now := v.clock.Now()
+	v.mu.Lock()
+	set := v.providers[issuer]
+	v.mu.Unlock()
+
+	if set != nil && now.Sub(set.fetchedAt) < domain.SSOMetadataCacheTTL {
+		if key, ok := set.keys[kid]; ok {
+			return key, nil
+		}
+		if now.Sub(set.fetchedAt) < domain.SSOKeyRefreshInterval {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+	}
+
+	keys, err := v.fetchKeys(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s: %w", issuer, errors.Join(err, domain.ErrUnavailable))
+	}
+	v.mu.Lock()
+	v.providers[issuer] = &oidcKeySet{keys: keys, fetchedAt: now}
+	v.mu.Unlock()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+// fetchKeys discovers issuer's jwks_uri and fetches its signing keys.
+func (v *OIDCVerifier) fetchKeys(ctx context.Context, issuer string) (map[string]any, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	// A provider may only vouch for itself (OpenID Connect Discovery §4.3).
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match", discovery.Issuer)
+	}
+	jwksURL, err := url.Parse(discovery.JWKSURI)
+	if err != nil || jwksURL.Host == "" {
+		return nil, fmt.Errorf("discovery: invalid jwks_uri %q", discovery.JWKSURI)
+	}
+	if issuerURL, _ := url.Parse(issuer); jwksURL.Scheme != "https" && jwksURL.Scheme != issuerURL.Scheme {
+		return nil, fmt.Errorf("discovery: jwks_uri must use https")
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL.String(), &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue // unsupported key types are ignored, not fatal
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks: no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, rawURL string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, domain.MaxSSOMetadataBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > domain.MaxSSOMetadataBytes {
+		return fmt.Errorf("GET %s: response too large", rawURL)
+	}
+	return json.Unmarshal(body, dst)
+}
+
+// jsonWebKey is an RFC 7517 key; only RSA and P-256 EC keys are used.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwk %s: invalid exponent", k.Kid)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("jwk %s: RSA key shorter than 2048 bits", k.Kid)
+		}
+		return key, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Encoded as the uncompressed point 0x04 || X || Y, which
+		// ParseUncompressedPublicKey rejects if it is off the curve.
+		point := make([]byte, 0, 65)
+		point = append(point, 4)
+		point = append(point, leftPad(x, 32)...)
+		point = append(point, leftPad(y, 32)...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	default:
+		return nil, fmt.Errorf("jwk %s: unsupported key type %q", k.Kid, k.Kty)
+	}
+}
+
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,168 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+// fakeIdP serves an OpenID Connect discovery document and a key set.
+type fakeIdP struct {
+	server   *httptest.Server
+	keys     []map[string]string
+	jwksHits atomic.Int32
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	idp := &fakeIdP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   idp.server.URL,
+			"jwks_uri": idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		idp.jwksHits.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": idp.keys})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (p *fakeIdP) addRSA(kid string, key *rsa.PublicKey) {
+	p.keys = append(p.keys, map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	})
+}
+
+func (p *fakeIdP) addEC(kid string, key *ecdsa.PublicKey) {
+	point, _ := key.Bytes()
+	p.keys = append(p.keys, map[string]string{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": b64(point[1:33]), "y": b64(point[33:]),
+	})
+}
+
+func TestOIDCVerifier_VerifyIDToken(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	clock := domaintest.NewFakeClock(now)
+	idp := newFakeIdP(t)
+	rsaKey := generateTestKey(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	idp.addRSA("rsa-1", &rsaKey.PublicKey)
+	idp.addEC("ec-1", &ecKey.PublicKey)
+
+	verifier := auth.NewOIDCVerifier(auth.OIDCVerifierConfig{Clock: clock})
+	issuer := idp.server.URL
+
+	sign := func(method jwt.SigningMethod, kid string, key any, edit func(*auth.IDTokenClaims)) string {
+		claims := auth.IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer,
+				Subject:   "idp-subject-1",
+				Audience:  jwt.ClaimStrings{"messaging"},
+				IssuedAt:  jwt.NewNumericDate(clock.Now()),
+				ExpiresAt: jwt.NewNumericDate(clock.Now().Add(5 * time.Minute)),
+			},
+			Email:         "ada@example.com",
+			EmailVerified: true,
+			Nonce:         "nonce-1",
+		}
+		if edit != nil {
+			edit(&claims)
+		}
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+	verify := func(raw string) (*auth.IDTokenClaims, error) {
+		return verifier.VerifyIDToken(context.Background(), issuer, "messaging", raw, "nonce-1")
+	}
+
+	t.Run("RS256 and ES256 tokens verify", func(t *testing.T) {
+		claims, err := verify(sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, nil))
+		require.NoError(t, err)
+		assert.Equal(t, "idp-subject-1", claims.Subject)
+		assert.Equal(t, "ada@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+
+		_, err = verify(sign(jwt.SigningMethodES256, "ec-1", ecKey, nil))
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), idp.jwksHits.Load(), "keys are cached")
+	})
+
+	rejected := []struct {
+		name string
+		edit func(*auth.IDTokenClaims)
+	}{
+		{name: "wrong audience", edit: func(c *auth.IDTokenClaims) { c.Audience = jwt.ClaimStrings{"other"} }},
+		{name: "wrong issuer", edit: func(c *auth.IDTokenClaims) { c.Issuer = "https://evil.example.com" }},
+		{name: "wrong nonce", edit: func(c *auth.IDTokenClaims) { c.Nonce = "nonce-2" }},
+		{name: "expired", edit: func(c *auth.IDTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Second)) }},
+		{name: "missing subject", edit: func(c *auth.IDTokenClaims) { c.Subject = "" }},
+		{name: "issued too long ago", edit: func(c *auth.IDTokenClaims) {
+			c.IssuedAt = jwt.NewNumericDate(now.Add(-domain.SSOMaxIDTokenAge - time.Minute))
+		}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verify(sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, tt.edit))
+			assert.ErrorIs(t, err, domain.ErrUnauthorized)
+		})
+	}
+
+	t.Run("token signed by another key", func(t *testing.T) {
+		_, err := verify(sign(jwt.SigningMethodRS256, "rsa-1", generateTestKey(t), nil))
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("rotated key is fetched once the refresh interval passes", func(t *testing.T) {
+		rotated := generateTestKey(t)
+		idp.addRSA("rsa-2", &rotated.PublicKey)
+		raw := sign(jwt.SigningMethodRS256, "rsa-2", rotated, nil)
+
+		_, err := verify(raw)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+		assert.Equal(t, int32(1), idp.jwksHits.Load())
+
+		clock.Advance(domain.SSOKeyRefreshInterval)
+		raw = sign(jwt.SigningMethodRS256, "rsa-2", rotated, nil)
+		_, err = verify(raw)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), idp.jwksHits.Load())
+	})
+
+	t.Run("unreachable provider is unavailable", func(t *testing.T) {
+		_, err := verifier.VerifyIDToken(context.Background(), "http://127.0.0.1:1", "messaging",
+			sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, func(c *auth.IDTokenClaims) { c.Issuer = "http://127.0.0.1:1" }), "nonce-1")
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+		assert.NotErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
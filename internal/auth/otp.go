@@ -4,7 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/subtle"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -23,6 +23,22 @@ func GenerateOTP() (string, error) {
 	return fmt.Sprintf("%06d", n.Int64()), nil
 }
 
+// GenerateCode generates a cryptographically random code of length
+// characters drawn uniformly from alphabet. Each character is drawn with
+// rejection sampling, so no character is favored.
+func GenerateCode(length int, alphabet string) (string, error) {
+	n := big.NewInt(int64(len(alphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", fmt.Errorf("generate OTP: %w", err)
+		}
+		code[i] = alphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
+
 // HashPhone returns the SHA-256 hex digest of an E.164 phone number.
 // Used as the partition key in the otp_requests table (ADR-015 §1.1).
 func HashPhone(phone string) string {
@@ -41,9 +57,32 @@ func ComputeOTPMAC(pepper []byte, otp, phoneHash, expiresAt string) string {
 	return hex.EncodeToString(mac.Sum(nil))
 }
 
+// ComputePolicyOTPMAC is ComputeOTPMAC with the code format bound in:
+// HMAC-SHA256(pepper, tag || otp || phoneHash || expiresAt || params), each
+// field length-prefixed. params is domain.OTPCodeFormat.Params(); binding it
+// means a code issued under a strict format never verifies if the record is
+// read back under a weaker one. The tag keeps these MACs disjoint from
+// ComputeOTPMAC's.
+func ComputePolicyOTPMAC(pepper []byte, otp, phoneHash, expiresAt, params string) string {
+	mac := hmac.New(sha256.New, pepper)
+	for _, field := range []string{"otp-mac-v2", otp, phoneHash, expiresAt, params} {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+		mac.Write(n[:])
+		mac.Write([]byte(field))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPolicyOTPMAC is VerifyOTPMAC for MACs from ComputePolicyOTPMAC.
+func VerifyPolicyOTPMAC(pepper []byte, otpCandidate, phoneHash, expiresAt, params, storedMAC string) bool {
+	candidateMAC := ComputePolicyOTPMAC(pepper, otpCandidate, phoneHash, expiresAt, params)
+	return ConstantTimeEqualHash(candidateMAC, storedMAC)
+}
+
 // VerifyOTPMAC verifies an OTP candidate against a stored MAC using
 // constant-time comparison to prevent timing side-channels (ADR-015 §1.4).
 func VerifyOTPMAC(pepper []byte, otpCandidate, phoneHash, expiresAt, storedMAC string) bool {
 	candidateMAC := ComputeOTPMAC(pepper, otpCandidate, phoneHash, expiresAt)
-	return subtle.ConstantTimeCompare([]byte(candidateMAC), []byte(storedMAC)) == 1
+	return ConstantTimeEqualHash(candidateMAC, storedMAC)
 }
@@ -120,3 +120,39 @@ func TestVerifyOTPMAC(t *testing.T) {
 		assert.False(t, auth.VerifyOTPMAC(wrongPepper, "123456", "phonehash", "2026-01-01T00:05:00Z", storedMAC))
 	})
 }
+
+func TestGenerateCode(t *testing.T) {
+	const alphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+	code, err := auth.GenerateCode(8, alphabet)
+
+	require.NoError(t, err)
+	assert.Regexp(t, `^[2-9A-HJKMNP-Z]{8}$`, code)
+}
+
+func TestComputePolicyOTPMAC(t *testing.T) {
+	pepper := []byte("test-pepper-32-bytes-long-secret")
+	const (
+		expiresAt = "2026-01-01T00:05:00Z"
+		params    = "len=8;alphabet=0123456789;ttl=120"
+	)
+	mac := auth.ComputePolicyOTPMAC(pepper, "12345678", "phonehash", expiresAt, params)
+
+	t.Run("verifies under the issuing params", func(t *testing.T) {
+		assert.True(t, auth.VerifyPolicyOTPMAC(pepper, "12345678", "phonehash", expiresAt, params, mac))
+	})
+
+	t.Run("rejects under other params", func(t *testing.T) {
+		weaker := "len=6;alphabet=0123456789;ttl=300"
+		assert.False(t, auth.VerifyPolicyOTPMAC(pepper, "12345678", "phonehash", expiresAt, weaker, mac))
+	})
+
+	t.Run("disjoint from the unbound MAC", func(t *testing.T) {
+		assert.NotEqual(t, auth.ComputeOTPMAC(pepper, "12345678", "phonehash", expiresAt), mac)
+	})
+
+	t.Run("field boundaries are unambiguous", func(t *testing.T) {
+		shifted := auth.ComputePolicyOTPMAC(pepper, "1234567", "8phonehash", expiresAt, params)
+		assert.NotEqual(t, mac, shifted)
+	})
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PepperRing holds the versioned peppers keying phone hashes and OTP MACs,
+// so the pepper can rotate without breaking OTPs already issued. New
+// hashes and MACs use the current version and carry it as a "v<N>:"
+// prefix; verification accepts every version still in the ring.
+//
+// Version 0 is the scheme from before peppers were versioned: an
+// unpeppered HashPhone and unprefixed MACs under the version 0 pepper.
+type PepperRing struct {
+	current int
+	peppers map[int][]byte
+}
+
+// NewPepperRing creates a PepperRing issuing under version current. Every
+// pepper must be non-empty, and current must be one of them.
+func NewPepperRing(current int, peppers map[int][]byte) (*PepperRing, error) {
+	for version, pepper := range peppers {
+		if version < 0 {
+			return nil, fmt.Errorf("pepper ring: negative version %d", version)
+		}
+		if len(pepper) == 0 {
+			return nil, fmt.Errorf("pepper ring: version %d is empty", version)
+		}
+	}
+	if _, ok := peppers[current]; !ok {
+		return nil, fmt.Errorf("pepper ring: current version %d has no pepper", current)
+	}
+	return &PepperRing{current: current, peppers: maps.Clone(peppers)}, nil
+}
+
+// Current returns the version new hashes and MACs use.
+func (r *PepperRing) Current() int { return r.current }
+
+// Versions returns every version in the ring, newest first.
+func (r *PepperRing) Versions() []int {
+	versions := slices.Sorted(maps.Keys(r.peppers))
+	slices.Reverse(versions)
+	return versions
+}
+
+// HashPhone returns the phone hash of an E.164 phone number under the
+// current version.
+func (r *PepperRing) HashPhone(phone string) string {
+	return r.hashPhone(r.current, phone)
+}
+
+// PhoneHashes returns the phone's hash under every version, the current
+// one first, for finding records written before a rotation.
+func (r *PepperRing) PhoneHashes(phone string) []string {
+	hashes := []string{r.HashPhone(phone)}
+	for _, version := range r.Versions() {
+		if version != r.current {
+			hashes = append(hashes, r.hashPhone(version, phone))
+		}
+	}
+	return hashes
+}
+
+func (r *PepperRing) hashPhone(version int, phone string) string {
+	if version == 0 {
+		return HashPhone(phone)
+	}
+	mac := hmac.New(sha256.New, r.peppers[version])
+	mac.Write([]byte("phone-hash"))
+	mac.Write([]byte{0})
+	mac.Write([]byte(phone))
+	return versionPrefix(version) + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ComputeOTPMAC computes ComputePolicyOTPMAC under the current version.
+func (r *PepperRing) ComputeOTPMAC(otp, phoneHash, expiresAt, params string) string {
+	mac := ComputePolicyOTPMAC(r.peppers[r.current], otp, phoneHash, expiresAt, params)
+	if r.current == 0 {
+		return mac
+	}
+	return versionPrefix(r.current) + mac
+}
+
+// VerifyOTPMAC verifies an OTP candidate against a stored MAC under the
+// version it was issued with. An empty params verifies a MAC from before
+// code policies (VerifyOTPMAC). MACs under versions no longer in the ring
+// never verify.
+func (r *PepperRing) VerifyOTPMAC(otpCandidate, phoneHash, expiresAt, params, storedMAC string) bool {
+	version, mac, ok := splitVersion(storedMAC)
+	if !ok {
+		return false
+	}
+	pepper, ok := r.peppers[version]
+	if !ok {
+		return false
+	}
+	if params == "" {
+		return VerifyOTPMAC(pepper, otpCandidate, phoneHash, expiresAt, mac)
+	}
+	return VerifyPolicyOTPMAC(pepper, otpCandidate, phoneHash, expiresAt, params, mac)
+}
+
+// PepperVersion returns the version a phone hash or OTP MAC was computed
+// under, 0 for one from before peppers were versioned.
+func PepperVersion(value string) (int, error) {
+	version, _, ok := splitVersion(value)
+	if !ok {
+		return 0, fmt.Errorf("malformed pepper version in %q", value)
+	}
+	return version, nil
+}
+
+func versionPrefix(version int) string {
+	return "v" + strconv.Itoa(version) + ":"
+}
+
+// splitVersion splits a "v<N>:" prefixed value. Unprefixed values, which
+// are plain hex, are version 0.
+func splitVersion(value string) (int, string, bool) {
+	if !strings.HasPrefix(value, "v") {
+		return 0, value, true
+	}
+	prefix, rest, ok := strings.Cut(value[1:], ":")
+	if !ok {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version < 1 {
+		return 0, "", false
+	}
+	return version, rest, true
+}
@@ -0,0 +1,71 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+)
+
+func TestPepperRing(t *testing.T) {
+	const (
+		phone     = "+14155552671"
+		expiresAt = "2026-01-01T00:05:00Z"
+		params    = "len=6;alphabet=0123456789;ttl=300"
+	)
+	legacy := []byte("legacy-pepper-32-bytes-long-sec!")
+	old, err := auth.NewPepperRing(0, map[int][]byte{0: legacy})
+	require.NoError(t, err)
+	rotated, err := auth.NewPepperRing(1, map[int][]byte{0: legacy, 1: []byte("new-pepper-32-bytes-long-secret!")})
+	require.NoError(t, err)
+
+	t.Run("version 0 is the unversioned scheme", func(t *testing.T) {
+		assert.Equal(t, auth.HashPhone(phone), old.HashPhone(phone))
+		mac := old.ComputeOTPMAC("123456", old.HashPhone(phone), expiresAt, params)
+		assert.Equal(t, auth.ComputePolicyOTPMAC(legacy, "123456", old.HashPhone(phone), expiresAt, params), mac)
+	})
+
+	t.Run("new hashes and MACs carry the current version", func(t *testing.T) {
+		hash := rotated.HashPhone(phone)
+		assert.Regexp(t, `^v1:[0-9a-f]{64}$`, hash)
+		mac := rotated.ComputeOTPMAC("123456", hash, expiresAt, params)
+		version, err := auth.PepperVersion(mac)
+		require.NoError(t, err)
+		assert.Equal(t, 1, version)
+		assert.True(t, rotated.VerifyOTPMAC("123456", hash, expiresAt, params, mac))
+		assert.False(t, rotated.VerifyOTPMAC("654321", hash, expiresAt, params, mac))
+	})
+
+	t.Run("finds records from before the rotation", func(t *testing.T) {
+		assert.Equal(t, []string{rotated.HashPhone(phone), old.HashPhone(phone)}, rotated.PhoneHashes(phone))
+
+		hash := old.HashPhone(phone)
+		assert.True(t, rotated.VerifyOTPMAC("123456", hash, expiresAt, params, old.ComputeOTPMAC("123456", hash, expiresAt, params)))
+		legacyMAC := auth.ComputeOTPMAC(legacy, "123456", hash, expiresAt)
+		assert.True(t, rotated.VerifyOTPMAC("123456", hash, expiresAt, "", legacyMAC), "MACs from before code policies")
+	})
+
+	t.Run("retired versions never verify", func(t *testing.T) {
+		hash := rotated.HashPhone(phone)
+		mac := rotated.ComputeOTPMAC("123456", hash, expiresAt, params)
+		assert.False(t, old.VerifyOTPMAC("123456", hash, expiresAt, params, mac))
+		assert.False(t, rotated.VerifyOTPMAC("123456", hash, expiresAt, params, "v7:"+mac[3:]))
+		assert.False(t, rotated.VerifyOTPMAC("123456", hash, expiresAt, params, "vx:"+mac[3:]))
+	})
+
+	t.Run("versions newest first", func(t *testing.T) {
+		assert.Equal(t, []int{1, 0}, rotated.Versions())
+		assert.Equal(t, 1, rotated.Current())
+	})
+
+	t.Run("invalid rings", func(t *testing.T) {
+		_, err := auth.NewPepperRing(2, map[int][]byte{1: legacy})
+		require.Error(t, err)
+		_, err = auth.NewPepperRing(1, map[int][]byte{1: nil})
+		require.Error(t, err)
+		_, err = auth.NewPepperRing(-1, map[int][]byte{-1: legacy})
+		require.Error(t, err)
+	})
+}
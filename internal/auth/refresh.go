@@ -3,10 +3,13 @@ package auth
 import (
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 )
 
 const refreshTokenBytes = 32
@@ -32,5 +35,93 @@ func HashRefreshToken(token string) string {
 // using constant-time comparison.
 func ValidateRefreshHash(token, storedHash string) bool {
 	candidateHash := HashRefreshToken(token)
-	return subtle.ConstantTimeCompare([]byte(candidateHash), []byte(storedHash)) == 1
+	return ConstantTimeEqualHash(candidateHash, storedHash)
+}
+
+// Argon2Params are the Argon2id cost parameters for refresh token hashes.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2SaltBytes = 16
+	argon2KeyBytes  = 32
+	argon2Prefix    = "$argon2id$"
+)
+
+// RefreshHasher hashes refresh tokens for storage with Argon2id, so a
+// leaked sessions table cannot be brute-forced at SHA-256 speed. Hashes
+// are PHC strings carrying their salt and parameters, so changing the
+// parameters leaves existing hashes verifiable.
+//
+// Verify also accepts SHA-256 hashes from HashRefreshToken; sessions move
+// to Argon2id at their next refresh. A nil *RefreshHasher hashes with
+// SHA-256.
+type RefreshHasher struct {
+	params Argon2Params
+}
+
+// NewRefreshHasher creates a RefreshHasher hashing with params.
+func NewRefreshHasher(params Argon2Params) *RefreshHasher {
+	return &RefreshHasher{params: params}
+}
+
+// Hash returns the hash of token to store.
+func (h *RefreshHasher) Hash(token string) (string, error) {
+	if h == nil {
+		return HashRefreshToken(token), nil
+	}
+	salt := make([]byte, argon2SaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("hash refresh token: %w", err)
+	}
+	p := h.params
+	key := argon2.IDKey([]byte(token), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyBytes)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether token matches storedHash, an Argon2id or SHA-256
+// hash, in constant time.
+func (h *RefreshHasher) Verify(token, storedHash string) bool {
+	if !strings.HasPrefix(storedHash, argon2Prefix) {
+		return ValidateRefreshHash(token, storedHash)
+	}
+	p, salt, key, err := parseArgon2Hash(storedHash)
+	if err != nil {
+		return false
+	}
+	candidate := argon2.IDKey([]byte(token), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	return ConstantTimeEqualBytes(candidate, key)
+}
+
+// parseArgon2Hash splits a "$argon2id$v=19$m=..,t=..,p=..$salt$key" hash.
+func parseArgon2Hash(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	fields := strings.Split(strings.TrimPrefix(hash, argon2Prefix), "$")
+	if len(fields) != 4 {
+		return p, nil, nil, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(fields[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(fields[1], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, errors.New("malformed argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[3])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, errors.New("malformed argon2id key")
+	}
+	return p, salt, key, nil
 }
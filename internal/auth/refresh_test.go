@@ -60,3 +60,57 @@ func TestValidateRefreshHash(t *testing.T) {
 		assert.False(t, auth.ValidateRefreshHash("", hash))
 	})
 }
+
+func TestRefreshHasher(t *testing.T) {
+	const token = "dGhpcyBpcyBhIHJlZnJlc2ggdG9rZW4AAAA"
+	hasher := auth.NewRefreshHasher(auth.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+
+	t.Run("argon2id hashes verify", func(t *testing.T) {
+		hash, err := hasher.Hash(token)
+		require.NoError(t, err)
+
+		assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+$`, hash)
+		assert.True(t, hasher.Verify(token, hash))
+		assert.False(t, hasher.Verify("wrong-token", hash))
+	})
+
+	t.Run("salted", func(t *testing.T) {
+		h1, err := hasher.Hash(token)
+		require.NoError(t, err)
+		h2, err := hasher.Hash(token)
+		require.NoError(t, err)
+		assert.NotEqual(t, h1, h2)
+	})
+
+	t.Run("hashes under earlier parameters still verify", func(t *testing.T) {
+		old := auth.NewRefreshHasher(auth.Argon2Params{Memory: 32, Iterations: 2, Parallelism: 1})
+		hash, err := old.Hash(token)
+		require.NoError(t, err)
+		assert.True(t, hasher.Verify(token, hash))
+	})
+
+	t.Run("SHA-256 hashes from before the migration verify", func(t *testing.T) {
+		assert.True(t, hasher.Verify(token, auth.HashRefreshToken(token)))
+		assert.False(t, hasher.Verify("wrong-token", auth.HashRefreshToken(token)))
+	})
+
+	t.Run("malformed hashes reject", func(t *testing.T) {
+		for _, hash := range []string{
+			"$argon2id$",
+			"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5",
+			"$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+			"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5",
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+		} {
+			assert.False(t, hasher.Verify(token, hash), hash)
+		}
+	})
+
+	t.Run("nil hashes with SHA-256", func(t *testing.T) {
+		var sha *auth.RefreshHasher
+		hash, err := sha.Hash(token)
+		require.NoError(t, err)
+		assert.Equal(t, auth.HashRefreshToken(token), hash)
+		assert.True(t, sha.Verify(token, hash))
+	})
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	scimTokenBytes  = 32
+	scimTokenPrefix = "scim_"
+)
+
+// GenerateSCIMToken generates a workspace's SCIM bearer token. The prefix
+// lets secret scanners recognise a leaked token.
+func GenerateSCIMToken() (string, error) {
+	b := make([]byte, scimTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate scim token: %w", err)
+	}
+	return scimTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashSCIMToken returns the SHA-256 hex digest of a SCIM token. Only the
+// hash is stored.
+func HashSCIMToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// ValidateSCIMToken verifies a SCIM token against its stored hash using
+// constant-time comparison. An empty hash matches nothing.
+func ValidateSCIMToken(token, storedHash string) bool {
+	if storedHash == "" {
+		return false
+	}
+	candidateHash := HashSCIMToken(token)
+	return ConstantTimeEqualHash(candidateHash, storedHash)
+}
@@ -0,0 +1,28 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+)
+
+func TestSCIMToken(t *testing.T) {
+	token, err := auth.GenerateSCIMToken()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "scim_"))
+	assert.Len(t, token, len("scim_")+43)
+
+	other, err := auth.GenerateSCIMToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	hash := auth.HashSCIMToken(token)
+	assert.Len(t, hash, 64)
+	assert.True(t, auth.ValidateSCIMToken(token, hash))
+	assert.False(t, auth.ValidateSCIMToken(other, hash))
+	assert.False(t, auth.ValidateSCIMToken("", ""), "an unset hash matches nothing")
+}
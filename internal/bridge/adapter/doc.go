@@ -0,0 +1,7 @@
+// Package adapter contains implementations of interfaces defined in app.
+// The Matrix application service client lives here.
+package adapter
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("bridge/adapter")
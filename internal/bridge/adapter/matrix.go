@@ -0,0 +1,206 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Compile-time check: MatrixClient satisfies app.Remote.
+var _ app.Remote = (*MatrixClient)(nil)
+
+// matrixMaxResponseBytes bounds the response body read; client-server API
+// replies to the calls made here are a few hundred bytes.
+const matrixMaxResponseBytes = 1 << 20
+
+// httpDoer is the subset of *http.Client used by MatrixClient.
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// MatrixConfig configures a MatrixClient.
+type MatrixConfig struct {
+	// Homeserver is the client-server API base URL, e.g.
+	// "https://matrix.example.org".
+	Homeserver string
+	// ASToken is the application service's as_token from its registration.
+	ASToken string
+	// ServerName is the homeserver's server name, e.g. "example.org".
+	ServerName string
+	// PuppetPrefix starts the localpart of every user the bridge posts as
+	// (e.g. "platform_"). The registration must claim it as an exclusive
+	// user namespace.
+	PuppetPrefix string
+	Client       httpDoer
+}
+
+// MatrixClient posts platform messages into Matrix rooms as an application
+// service. Each platform sender gets a puppet user on the homeserver, which
+// is registered and joined to a room the first time it posts there. Rooms
+// must let the puppets join, by being public or by inviting the namespace.
+type MatrixClient struct {
+	homeserver string
+	asToken    string
+	serverName string
+	prefix     string
+	client     httpDoer
+
+	// joined holds puppet\x00room pairs known to be joined. It grows with
+	// the number of distinct senders in bridged chats.
+	joined sync.Map
+}
+
+// NewMatrixClient creates a MatrixClient.
+func NewMatrixClient(cfg MatrixConfig) *MatrixClient {
+	return &MatrixClient{
+		homeserver: strings.TrimSuffix(cfg.Homeserver, "/"),
+		asToken:    cfg.ASToken,
+		serverName: cfg.ServerName,
+		prefix:     cfg.PuppetPrefix,
+		client:     cfg.Client,
+	}
+}
+
+type matrixError struct {
+	ErrCode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+// PuppetUserID returns the Matrix user the bridge posts as for userID.
+func (c *MatrixClient) PuppetUserID(userID domain.UserID) string {
+	return "@" + c.prefix + userID.String() + ":" + c.serverName
+}
+
+// IsBridgeUser reports whether sender is one of the bridge's puppets.
+func (c *MatrixClient) IsBridgeUser(sender string) bool {
+	return strings.HasPrefix(sender, "@"+c.prefix) && strings.HasSuffix(sender, ":"+c.serverName)
+}
+
+// Send posts msg as an m.text message from the sender's puppet. msg.TxnID
+// is the Matrix transaction ID, so a retried send yields one event.
+func (c *MatrixClient) Send(ctx context.Context, msg app.RemoteMessage) error {
+	ctx, span := tracer.Start(ctx, "matrix.send")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("rpc.system", "matrix"),
+		attribute.String("rpc.method", "m.room.message"),
+	)
+
+	if err := c.send(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("matrix: send to %s: %w", msg.Room, err)
+	}
+	return nil
+}
+
+func (c *MatrixClient) send(ctx context.Context, msg app.RemoteMessage) error {
+	puppet := c.PuppetUserID(msg.SenderID)
+	key := puppet + "\x00" + msg.Room
+	if _, ok := c.joined.Load(key); !ok {
+		if err := c.register(ctx, puppet); err != nil {
+			return err
+		}
+		if err := c.join(ctx, puppet, msg.Room); err != nil {
+			return err
+		}
+		c.joined.Store(key, struct{}{})
+	}
+
+	path := "/_matrix/client/v3/rooms/" + url.PathEscape(msg.Room) +
+		"/send/m.room.message/" + url.PathEscape(msg.TxnID)
+	status, mErr, err := c.do(ctx, http.MethodPut, path, puppet, map[string]string{
+		"msgtype": "m.text",
+		"body":    msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	if status == http.StatusForbidden {
+		// The puppet was kicked or left; rejoin on the next attempt.
+		c.joined.Delete(key)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("send: status %d: %s: %s", status, mErr.ErrCode, mErr.Error)
+	}
+	return nil
+}
+
+// register creates the puppet user; it already existing is success.
+func (c *MatrixClient) register(ctx context.Context, puppet string) error {
+	localpart := strings.TrimSuffix(strings.TrimPrefix(puppet, "@"), ":"+c.serverName)
+	status, mErr, err := c.do(ctx, http.MethodPost, "/_matrix/client/v3/register", "", map[string]string{
+		"type":     "m.login.application_service",
+		"username": localpart,
+	})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && mErr.ErrCode != "M_USER_IN_USE" {
+		return fmt.Errorf("register %s: status %d: %s: %s", puppet, status, mErr.ErrCode, mErr.Error)
+	}
+	return nil
+}
+
+// join joins the puppet to room; joining a room already joined succeeds.
+func (c *MatrixClient) join(ctx context.Context, puppet, room string) error {
+	status, mErr, err := c.do(ctx, http.MethodPost, "/_matrix/client/v3/join/"+url.PathEscape(room), puppet, struct{}{})
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("join %s as %s: status %d: %s: %s", room, puppet, status, mErr.ErrCode, mErr.Error)
+	}
+	return nil
+}
+
+// do sends a client-server API request as the application service,
+// masquerading as asUser when set. It returns the status and, for a non-200
+// reply, the Matrix error.
+func (c *MatrixClient) do(ctx context.Context, method, path, asUser string, in any) (int, matrixError, error) {
+	ctx, cancel := context.WithTimeout(ctx, domain.BridgeRequestTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return 0, matrixError{}, fmt.Errorf("marshal request: %w", err)
+	}
+	target := c.homeserver + path
+	if asUser != "" {
+		target += "?user_id=" + url.QueryEscape(asUser)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, matrixError{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.asToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, matrixError{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, matrixMaxResponseBytes))
+	if err != nil {
+		return 0, matrixError{}, fmt.Errorf("read response: %w", err)
+	}
+	var mErr matrixError
+	if resp.StatusCode != http.StatusOK {
+		_ = json.Unmarshal(payload, &mErr)
+	}
+	return resp.StatusCode, mErr, nil
+}
@@ -0,0 +1,127 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+const testSender = "33333333-3333-4333-8333-333333333333"
+
+// fakeHomeserver records the client-server API calls it receives.
+type fakeHomeserver struct {
+	mu    sync.Mutex
+	calls []string // method path?query
+	// reply overrides the response for a "METHOD path" key.
+	reply map[string]func(w http.ResponseWriter)
+}
+
+func (h *fakeHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.calls = append(h.calls, r.Method+" "+r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+	reply := h.reply[r.Method+" "+r.URL.EscapedPath()]
+	h.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer as-secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if reply != nil {
+		reply(w)
+		return
+	}
+	_, _ = w.Write([]byte(`{}`))
+}
+
+func newTestMatrixClient(t *testing.T, hs *fakeHomeserver) *MatrixClient {
+	t.Helper()
+	srv := httptest.NewServer(hs)
+	t.Cleanup(srv.Close)
+	return NewMatrixClient(MatrixConfig{
+		Homeserver:   srv.URL + "/",
+		ASToken:      "as-secret",
+		ServerName:   "example.org",
+		PuppetPrefix: "platform_",
+		Client:       srv.Client(),
+	})
+}
+
+func matrixErr(status int, code string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(matrixError{ErrCode: code, Error: code})
+	}
+}
+
+func TestMatrixClient_Send(t *testing.T) {
+	ctx := context.Background()
+	msg := app.RemoteMessage{Room: "!a:example.org", TxnID: "m1", SenderID: domain.MustUserID(testSender), Text: "hello"}
+	puppet := "user_id=%40platform_" + testSender + "%3Aexample.org"
+
+	t.Run("registers and joins the puppet once", func(t *testing.T) {
+		hs := &fakeHomeserver{}
+		c := newTestMatrixClient(t, hs)
+
+		require.NoError(t, c.Send(ctx, msg))
+		msg2 := msg
+		msg2.TxnID = "m2"
+		require.NoError(t, c.Send(ctx, msg2))
+
+		assert.Equal(t, []string{
+			"POST /_matrix/client/v3/register?",
+			"POST /_matrix/client/v3/join/%21a:example.org?" + puppet,
+			"PUT /_matrix/client/v3/rooms/%21a:example.org/send/m.room.message/m1?" + puppet,
+			"PUT /_matrix/client/v3/rooms/%21a:example.org/send/m.room.message/m2?" + puppet,
+		}, hs.calls)
+	})
+
+	t.Run("existing puppet is not an error", func(t *testing.T) {
+		hs := &fakeHomeserver{reply: map[string]func(http.ResponseWriter){
+			"POST /_matrix/client/v3/register": matrixErr(http.StatusBadRequest, "M_USER_IN_USE"),
+		}}
+		c := newTestMatrixClient(t, hs)
+
+		require.NoError(t, c.Send(ctx, msg))
+	})
+
+	t.Run("forbidden send rejoins on retry", func(t *testing.T) {
+		hs := &fakeHomeserver{reply: map[string]func(http.ResponseWriter){
+			"PUT /_matrix/client/v3/rooms/%21a:example.org/send/m.room.message/m1": matrixErr(http.StatusForbidden, "M_FORBIDDEN"),
+		}}
+		c := newTestMatrixClient(t, hs)
+
+		require.ErrorContains(t, c.Send(ctx, msg), "M_FORBIDDEN")
+		require.Error(t, c.Send(ctx, msg))
+
+		assert.Len(t, hs.calls, 6, "register, join and send on both attempts")
+	})
+
+	t.Run("join failure is returned", func(t *testing.T) {
+		hs := &fakeHomeserver{reply: map[string]func(http.ResponseWriter){
+			"POST /_matrix/client/v3/join/%21a:example.org": matrixErr(http.StatusForbidden, "M_FORBIDDEN"),
+		}}
+		c := newTestMatrixClient(t, hs)
+
+		err := c.Send(ctx, msg)
+
+		require.ErrorContains(t, err, "join !a:example.org")
+		assert.Len(t, hs.calls, 2, "no send without a join")
+	})
+}
+
+func TestMatrixClient_IsBridgeUser(t *testing.T) {
+	c := NewMatrixClient(MatrixConfig{ServerName: "example.org", PuppetPrefix: "platform_"})
+
+	assert.True(t, c.IsBridgeUser(c.PuppetUserID(domain.MustUserID(testSender))))
+	assert.False(t, c.IsBridgeUser("@alice:example.org"))
+	assert.False(t, c.IsBridgeUser("@platform_x:other.org"), "same prefix on another server")
+}
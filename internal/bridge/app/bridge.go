@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var bridgeMessagesTotal metric.Int64Counter
+
+func init() {
+	bridgeMessagesTotal, _ = otel.Meter("bridge/app").Int64Counter("bridge_messages_total",
+		metric.WithDescription("Bridged messages by bridge, direction (inbound, outbound) and result (relayed, unmapped, looped, skipped)"))
+}
+
+// Bridge message results.
+const (
+	resultRelayed  = "relayed"
+	resultUnmapped = "unmapped" // chat or room not bridged
+	resultLooped   = "looped"   // came from this bridge; sending it back would echo it
+	resultSkipped  = "skipped"  // no text the other side can carry
+)
+
+// PlatformMessage is a message persisted on the platform, as the outbound
+// relay reads it from messages.persisted.
+type PlatformMessage struct {
+	MessageID       string
+	ChatID          domain.ChatID
+	SenderID        domain.UserID
+	ClientMessageID string
+	Content         domain.MessageContent
+}
+
+// RemoteMessage is a platform message to post in a remote room.
+type RemoteMessage struct {
+	Room string
+	// TxnID is unique per platform message. Remotes use it to make a
+	// retried send idempotent.
+	TxnID    string
+	SenderID domain.UserID
+	Text     string
+}
+
+// RemoteEvent is a message posted in a remote room.
+type RemoteEvent struct {
+	EventID string // unique on the remote server
+	Room    string
+	Sender  string // the sender's ID on the remote protocol
+	Text    string
+}
+
+// Remote is the bridge's client for the remote protocol.
+type Remote interface {
+	// Send posts msg in its room as an identity standing for the platform
+	// sender.
+	Send(ctx context.Context, msg RemoteMessage) error
+	// IsBridgeUser reports whether sender is an identity the bridge posts
+	// as, so its events are echoes of platform messages.
+	IsBridgeUser(sender string) bool
+}
+
+// InboundMessage is a remote message for the ingest pipeline.
+type InboundMessage struct {
+	ChatID          domain.ChatID
+	SenderID        domain.UserID
+	ClientMessageID string
+	Content         domain.MessageContent
+	ReceivedAt      domain.ServerTime
+}
+
+// MessageSink hands bridged messages to Ingest, which persists them like
+// any other message. Submitting a ClientMessageID that is already stored
+// succeeds without a second message.
+type MessageSink interface {
+	Submit(ctx context.Context, msg InboundMessage) error
+}
+
+// RoomMapping pairs a platform chat with a remote room.
+type RoomMapping struct {
+	ChatID domain.ChatID
+	Room   string
+}
+
+// ParseRoomMappings parses chat=room entries, e.g.
+// "5f0c…=!abc:example.org" for a Matrix room.
+func ParseRoomMappings(entries []string) ([]RoomMapping, error) {
+	out := make([]RoomMapping, 0, len(entries))
+	for _, e := range entries {
+		chat, room, ok := strings.Cut(strings.TrimSpace(e), "=")
+		if !ok || room == "" {
+			return nil, domain.NewValidationError("rooms", fmt.Sprintf("%q is not chat=room", e))
+		}
+		chatID, err := domain.NewChatID(chat)
+		if err != nil {
+			return nil, fmt.Errorf("rooms: %q: %w", e, err)
+		}
+		out = append(out, RoomMapping{ChatID: chatID, Room: room})
+	}
+	return out, nil
+}
+
+// BridgeConfig configures one bridge.
+type BridgeConfig struct {
+	// Name identifies the bridge in bridged user and message IDs, logs and
+	// metrics (e.g. "matrix"). Changing it gives every remote user a new
+	// platform identity.
+	Name   string
+	Rooms  []RoomMapping
+	Remote Remote
+	Sink   MessageSink
+	Clock  domain.Clock
+	Logger *slog.Logger // nil uses slog.Default
+}
+
+// Bridge relays messages between platform chats and the rooms they are
+// mapped to on a remote protocol. Each chat maps to at most one room and
+// each room to at most one chat.
+//
+// Remote users post as bridged identities (domain.BridgedUserID) that have
+// no platform account. Their messages enter Ingest with an idempotency key
+// derived from the remote event ID, so a redelivered event is stored once.
+//
+// Loops are broken on both sides: Relay drops messages this bridge brought
+// in, recognised by their idempotency key, and Receive drops events sent by
+// the bridge's own remote identities. Messages another bridge brought in
+// are relayed, so a chat can be bridged to several protocols at once.
+type Bridge struct {
+	name   string
+	remote Remote
+	sink   MessageSink
+	clock  domain.Clock
+	logger *slog.Logger
+
+	toRoom map[domain.ChatID]string
+	toChat map[string]domain.ChatID
+}
+
+// NewBridge creates a Bridge. It fails on an invalid name or a chat or room
+// mapped twice.
+func NewBridge(cfg BridgeConfig) (*Bridge, error) {
+	if err := domain.ValidateBridgeName(cfg.Name); err != nil {
+		return nil, err
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	b := &Bridge{
+		name:   cfg.Name,
+		remote: cfg.Remote,
+		sink:   cfg.Sink,
+		clock:  clock,
+		logger: logger.With("bridge", cfg.Name),
+		toRoom: make(map[domain.ChatID]string, len(cfg.Rooms)),
+		toChat: make(map[string]domain.ChatID, len(cfg.Rooms)),
+	}
+	for _, m := range cfg.Rooms {
+		if _, dup := b.toRoom[m.ChatID]; dup {
+			return nil, domain.NewValidationError("rooms", fmt.Sprintf("chat %s is mapped twice", m.ChatID))
+		}
+		if _, dup := b.toChat[m.Room]; dup {
+			return nil, domain.NewValidationError("rooms", fmt.Sprintf("room %s is mapped twice", m.Room))
+		}
+		b.toRoom[m.ChatID] = m.Room
+		b.toChat[m.Room] = m.ChatID
+	}
+	return b, nil
+}
+
+// Name returns the bridge's name.
+func (b *Bridge) Name() string { return b.name }
+
+// Relay posts msg in the remote room its chat is mapped to. Messages in
+// chats that are not bridged, system messages and messages this bridge
+// brought in are dropped. An error means the send failed and is worth
+// retrying.
+func (b *Bridge) Relay(ctx context.Context, msg PlatformMessage) error {
+	room, ok := b.toRoom[msg.ChatID]
+	switch {
+	case !ok:
+		b.count(ctx, "outbound", resultUnmapped)
+		return nil
+	case domain.IsBridgedFrom(msg.ClientMessageID, b.name):
+		b.count(ctx, "outbound", resultLooped)
+		return nil
+	case msg.Content.ContentType() != domain.ContentTypeText:
+		b.count(ctx, "outbound", resultSkipped)
+		return nil
+	}
+
+	err := b.remote.Send(ctx, RemoteMessage{
+		Room:     room,
+		TxnID:    msg.MessageID,
+		SenderID: msg.SenderID,
+		Text:     msg.Content.Body(),
+	})
+	if err != nil {
+		return fmt.Errorf("bridge %s: relay message %s to %s: %w", b.name, msg.MessageID, room, err)
+	}
+	b.count(ctx, "outbound", resultRelayed)
+	return nil
+}
+
+// Receive submits a remote event to Ingest as a message from the sender's
+// bridged identity. Events in rooms that are not bridged, events the bridge
+// sent itself and events with no storable text are dropped. An error means
+// the event was not stored and should be redelivered.
+func (b *Bridge) Receive(ctx context.Context, ev RemoteEvent) error {
+	chatID, ok := b.toChat[ev.Room]
+	switch {
+	case !ok:
+		b.count(ctx, "inbound", resultUnmapped)
+		return nil
+	case b.remote.IsBridgeUser(ev.Sender):
+		b.count(ctx, "inbound", resultLooped)
+		return nil
+	}
+
+	content, err := domain.NewMessageContent(domain.ContentTypeText, ev.Text)
+	clientMessageID := domain.BridgeClientMessageID(b.name, ev.EventID)
+	if err == nil && (ev.EventID == "" || len(clientMessageID) > domain.MaxClientMessageIDLength) {
+		err = domain.NewValidationError("event_id", fmt.Sprintf("%q cannot key a message", ev.EventID))
+	}
+	if err != nil {
+		b.logger.WarnContext(ctx, "bridge.inbound_skipped",
+			"room", ev.Room, "event_id", ev.EventID, "error", err)
+		b.count(ctx, "inbound", resultSkipped)
+		return nil
+	}
+
+	err = b.sink.Submit(ctx, InboundMessage{
+		ChatID:          chatID,
+		SenderID:        domain.BridgedUserID(b.name, ev.Sender),
+		ClientMessageID: clientMessageID,
+		Content:         content,
+		ReceivedAt:      domain.ServerNow(b.clock),
+	})
+	if err != nil {
+		return fmt.Errorf("bridge %s: submit event %s from %s: %w", b.name, ev.EventID, ev.Room, err)
+	}
+	b.count(ctx, "inbound", resultRelayed)
+	return nil
+}
+
+func (b *Bridge) count(ctx context.Context, direction, result string) {
+	bridgeMessagesTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("bridge", b.name),
+		attribute.String("direction", direction),
+		attribute.String("result", result)))
+}
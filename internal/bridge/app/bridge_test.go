@@ -0,0 +1,196 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+const (
+	chatA = "11111111-1111-4111-8111-111111111111"
+	chatB = "22222222-2222-4222-8222-222222222222"
+	roomA = "!a:example.org"
+)
+
+type fakeRemote struct {
+	sent []app.RemoteMessage
+	err  error
+}
+
+func (r *fakeRemote) Send(_ context.Context, msg app.RemoteMessage) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func (r *fakeRemote) IsBridgeUser(sender string) bool {
+	return strings.HasPrefix(sender, "@platform_")
+}
+
+type fakeSink struct {
+	got []app.InboundMessage
+	err error
+}
+
+func (s *fakeSink) Submit(_ context.Context, msg app.InboundMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.got = append(s.got, msg)
+	return nil
+}
+
+func newBridge(t *testing.T, remote *fakeRemote, sink *fakeSink) *app.Bridge {
+	t.Helper()
+	b, err := app.NewBridge(app.BridgeConfig{
+		Name:   "matrix",
+		Rooms:  []app.RoomMapping{{ChatID: domain.MustChatID(chatA), Room: roomA}},
+		Remote: remote,
+		Sink:   sink,
+		Clock:  domaintest.NewFakeClock(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)),
+	})
+	require.NoError(t, err)
+	return b
+}
+
+func TestParseRoomMappings(t *testing.T) {
+	got, err := app.ParseRoomMappings([]string{chatA + "=" + roomA, " " + chatB + "=!b:example.org"})
+	require.NoError(t, err)
+	assert.Equal(t, []app.RoomMapping{
+		{ChatID: domain.MustChatID(chatA), Room: roomA},
+		{ChatID: domain.MustChatID(chatB), Room: "!b:example.org"},
+	}, got)
+
+	for _, bad := range []string{chatA, chatA + "=", "not-a-chat=" + roomA} {
+		_, err := app.ParseRoomMappings([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNewBridge(t *testing.T) {
+	_, err := app.NewBridge(app.BridgeConfig{Name: "Matrix"})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput, "invalid name")
+
+	_, err = app.NewBridge(app.BridgeConfig{Name: "matrix", Rooms: []app.RoomMapping{
+		{ChatID: domain.MustChatID(chatA), Room: roomA},
+		{ChatID: domain.MustChatID(chatB), Room: roomA},
+	}})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput, "room mapped twice")
+
+	_, err = app.NewBridge(app.BridgeConfig{Name: "matrix", Rooms: []app.RoomMapping{
+		{ChatID: domain.MustChatID(chatA), Room: roomA},
+		{ChatID: domain.MustChatID(chatA), Room: "!b:example.org"},
+	}})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput, "chat mapped twice")
+}
+
+func TestBridge_Relay(t *testing.T) {
+	ctx := context.Background()
+	sender := domain.MustUserID("33333333-3333-4333-8333-333333333333")
+	text := func(s string) domain.MessageContent { return domain.MustMessageContent(domain.ContentTypeText, s) }
+
+	t.Run("posts text messages in the mapped room", func(t *testing.T) {
+		remote := &fakeRemote{}
+		b := newBridge(t, remote, &fakeSink{})
+
+		err := b.Relay(ctx, app.PlatformMessage{
+			MessageID: "m1", ChatID: domain.MustChatID(chatA), SenderID: sender,
+			ClientMessageID: "c1", Content: text("hello"),
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []app.RemoteMessage{{Room: roomA, TxnID: "m1", SenderID: sender, Text: "hello"}}, remote.sent)
+	})
+
+	t.Run("drops what it should not send", func(t *testing.T) {
+		system, err := domain.SystemMessage{Event: domain.SystemEventChatRenamed}.Content()
+		require.NoError(t, err)
+		remote := &fakeRemote{}
+		b := newBridge(t, remote, &fakeSink{})
+
+		for name, msg := range map[string]app.PlatformMessage{
+			"unmapped chat": {MessageID: "m1", ChatID: domain.MustChatID(chatB), ClientMessageID: "c1", Content: text("hi")},
+			"own echo":      {MessageID: "m2", ChatID: domain.MustChatID(chatA), ClientMessageID: domain.BridgeClientMessageID("matrix", "$ev1"), Content: text("hi")},
+			"system":        {MessageID: "m3", ChatID: domain.MustChatID(chatA), ClientMessageID: domain.SystemClientMessageID("k"), Content: system},
+		} {
+			require.NoError(t, b.Relay(ctx, msg), name)
+		}
+		assert.Empty(t, remote.sent)
+	})
+
+	t.Run("relays messages another bridge brought in", func(t *testing.T) {
+		remote := &fakeRemote{}
+		b := newBridge(t, remote, &fakeSink{})
+
+		err := b.Relay(ctx, app.PlatformMessage{
+			MessageID: "m1", ChatID: domain.MustChatID(chatA),
+			ClientMessageID: domain.BridgeClientMessageID("xmpp", "id1"), Content: text("hi"),
+		})
+
+		require.NoError(t, err)
+		assert.Len(t, remote.sent, 1)
+	})
+
+	t.Run("send failure is returned for retry", func(t *testing.T) {
+		b := newBridge(t, &fakeRemote{err: errors.New("homeserver down")}, &fakeSink{})
+
+		err := b.Relay(ctx, app.PlatformMessage{MessageID: "m1", ChatID: domain.MustChatID(chatA), Content: text("hi")})
+
+		assert.ErrorContains(t, err, "homeserver down")
+	})
+}
+
+func TestBridge_Receive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("submits as the bridged identity", func(t *testing.T) {
+		sink := &fakeSink{}
+		b := newBridge(t, &fakeRemote{}, sink)
+
+		err := b.Receive(ctx, app.RemoteEvent{EventID: "$ev1", Room: roomA, Sender: "@alice:example.org", Text: "hi"})
+
+		require.NoError(t, err)
+		require.Len(t, sink.got, 1)
+		got := sink.got[0]
+		assert.Equal(t, domain.MustChatID(chatA), got.ChatID)
+		assert.Equal(t, domain.BridgedUserID("matrix", "@alice:example.org"), got.SenderID)
+		assert.Equal(t, domain.BridgeClientMessageID("matrix", "$ev1"), got.ClientMessageID)
+		assert.Equal(t, "hi", got.Content.Body())
+		assert.Equal(t, time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC), got.ReceivedAt.Time())
+	})
+
+	t.Run("drops what it should not submit", func(t *testing.T) {
+		sink := &fakeSink{}
+		b := newBridge(t, &fakeRemote{}, sink)
+
+		for name, ev := range map[string]app.RemoteEvent{
+			"unmapped room": {EventID: "$1", Room: "!other:example.org", Sender: "@alice:example.org", Text: "hi"},
+			"own echo":      {EventID: "$2", Room: roomA, Sender: "@platform_abc:example.org", Text: "hi"},
+			"empty text":    {EventID: "$3", Room: roomA, Sender: "@alice:example.org", Text: ""},
+			"too large":     {EventID: "$4", Room: roomA, Sender: "@alice:example.org", Text: strings.Repeat("a", domain.MaxMessageSize+1)},
+			"no event ID":   {Room: roomA, Sender: "@alice:example.org", Text: "hi"},
+		} {
+			require.NoError(t, b.Receive(ctx, ev), name)
+		}
+		assert.Empty(t, sink.got)
+	})
+
+	t.Run("sink failure is returned for redelivery", func(t *testing.T) {
+		b := newBridge(t, &fakeRemote{}, &fakeSink{err: domain.ErrUnavailable})
+
+		err := b.Receive(ctx, app.RemoteEvent{EventID: "$ev1", Room: roomA, Sender: "@alice:example.org", Text: "hi"})
+
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+}
@@ -0,0 +1,3 @@
+// Package app contains federation bridge use cases. Room mapping, bridged
+// identities and loop prevention live here.
+package app
@@ -0,0 +1,3 @@
+// Package port contains entry points into the bridge service. The Matrix
+// transaction endpoint and the messages.persisted consumer live here.
+package port
@@ -0,0 +1,125 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// MatrixTransactionsPath is where a Matrix homeserver pushes events to the
+// application service, followed by the transaction ID.
+const MatrixTransactionsPath = "/_matrix/app/v1/transactions/"
+
+// Receiver is the subset of app.Bridge the inbound endpoints need.
+type Receiver interface {
+	Receive(ctx context.Context, ev app.RemoteEvent) error
+}
+
+// matrixTransaction is the body of a homeserver transaction. Only the event
+// fields the bridge reads are decoded.
+type matrixTransaction struct {
+	Events []matrixEvent `json:"events"`
+}
+
+type matrixEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id"`
+	RoomID  string `json:"room_id"`
+	Sender  string `json:"sender"`
+	Content struct {
+		MsgType   string `json:"msgtype"`
+		Body      string `json:"body"`
+		RelatesTo struct {
+			RelType string `json:"rel_type"`
+		} `json:"m.relates_to"`
+	} `json:"content"`
+}
+
+// MatrixTransactionHandler receives the events a Matrix homeserver pushes
+// to the bridge's application service:
+//
+//	PUT /_matrix/app/v1/transactions/{txnId}
+//
+// The homeserver authenticates with the registration's hs_token. Text,
+// notice and emote messages are passed to recv; other events, and edits,
+// which platform messages cannot express, are ignored. If recv fails the
+// reply is 500 and the homeserver retries the whole transaction; events
+// already stored are deduplicated by their idempotency keys.
+func MatrixTransactionHandler(recv Receiver, hsToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.Header().Set("Allow", http.MethodPut)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, MatrixTransactionsPath) || r.URL.Path == MatrixTransactionsPath {
+			writeMatrixError(w, http.StatusNotFound, "M_NOT_FOUND", "no transaction ID")
+			return
+		}
+
+		token := r.URL.Query().Get("access_token") // pre-v1.4 homeservers
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+		switch {
+		case token == "":
+			writeMatrixError(w, http.StatusUnauthorized, "M_UNAUTHORIZED", "missing hs_token")
+			return
+		case !auth.ConstantTimeEqualSecret(token, hsToken):
+			writeMatrixError(w, http.StatusForbidden, "M_FORBIDDEN", "invalid hs_token")
+			return
+		}
+
+		var txn matrixTransaction
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, domain.MaxBridgeTransactionBytes)).Decode(&txn); err != nil {
+			writeMatrixError(w, http.StatusBadRequest, "M_BAD_JSON", "decode transaction")
+			return
+		}
+
+		for _, e := range txn.Events {
+			ev, ok := remoteEvent(e)
+			if !ok {
+				continue
+			}
+			if err := recv.Receive(r.Context(), ev); err != nil {
+				observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "matrix transaction failed",
+					"transaction", strings.TrimPrefix(r.URL.Path, MatrixTransactionsPath),
+					"event_id", e.EventID, "error", err)
+				writeMatrixError(w, http.StatusInternalServerError, "M_UNKNOWN", "event not stored; retry the transaction")
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	})
+}
+
+// remoteEvent converts a room message event; ok is false for events the
+// bridge ignores.
+func remoteEvent(e matrixEvent) (app.RemoteEvent, bool) {
+	if e.Type != "m.room.message" || e.Content.RelatesTo.RelType == "m.replace" {
+		return app.RemoteEvent{}, false
+	}
+	text := e.Content.Body
+	switch e.Content.MsgType {
+	case "m.text", "m.notice":
+	case "m.emote":
+		text = "* " + text
+	default:
+		return app.RemoteEvent{}, false
+	}
+	return app.RemoteEvent{EventID: e.EventID, Room: e.RoomID, Sender: e.Sender, Text: text}, true
+}
+
+func writeMatrixError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"errcode": code, "error": msg})
+}
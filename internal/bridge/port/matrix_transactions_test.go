@@ -0,0 +1,105 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type fakeReceiver struct {
+	got []app.RemoteEvent
+	err error
+}
+
+func (f *fakeReceiver) Receive(_ context.Context, ev app.RemoteEvent) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.got = append(f.got, ev)
+	return nil
+}
+
+const testTransaction = `{"events": [
+	{"type": "m.room.message", "event_id": "$1", "room_id": "!a:example.org", "sender": "@alice:example.org",
+	 "content": {"msgtype": "m.text", "body": "hi"}},
+	{"type": "m.room.message", "event_id": "$2", "room_id": "!a:example.org", "sender": "@alice:example.org",
+	 "content": {"msgtype": "m.emote", "body": "waves"}},
+	{"type": "m.room.message", "event_id": "$3", "room_id": "!a:example.org", "sender": "@alice:example.org",
+	 "content": {"msgtype": "m.text", "body": "* hi!", "m.new_content": {"msgtype": "m.text", "body": "hi!"},
+	             "m.relates_to": {"rel_type": "m.replace", "event_id": "$1"}}},
+	{"type": "m.room.message", "event_id": "$4", "room_id": "!a:example.org", "sender": "@alice:example.org",
+	 "content": {"msgtype": "m.image", "body": "cat.png"}},
+	{"type": "m.room.member", "event_id": "$5", "room_id": "!a:example.org", "sender": "@bob:example.org",
+	 "content": {"membership": "join"}}
+]}`
+
+func TestMatrixTransactionHandler(t *testing.T) {
+	put := func(h http.Handler, path, auth, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("passes message events to the bridge", func(t *testing.T) {
+		recv := &fakeReceiver{}
+
+		rec := put(MatrixTransactionHandler(recv, "hs-secret"), MatrixTransactionsPath+"txn1", "hs-secret", testTransaction)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{}`, rec.Body.String())
+		assert.Equal(t, []app.RemoteEvent{
+			{EventID: "$1", Room: "!a:example.org", Sender: "@alice:example.org", Text: "hi"},
+			{EventID: "$2", Room: "!a:example.org", Sender: "@alice:example.org", Text: "* waves"},
+		}, recv.got)
+	})
+
+	t.Run("legacy access_token query", func(t *testing.T) {
+		recv := &fakeReceiver{}
+
+		rec := put(MatrixTransactionHandler(recv, "hs-secret"), MatrixTransactionsPath+"txn1?access_token=hs-secret", "", `{"events": []}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("rejects a missing or wrong token", func(t *testing.T) {
+		recv := &fakeReceiver{}
+		h := MatrixTransactionHandler(recv, "hs-secret")
+
+		assert.Equal(t, http.StatusUnauthorized, put(h, MatrixTransactionsPath+"txn1", "", testTransaction).Code)
+		rec := put(h, MatrixTransactionsPath+"txn1", "wrong", testTransaction)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "M_FORBIDDEN")
+		assert.Empty(t, recv.got)
+	})
+
+	t.Run("failure asks for the transaction again", func(t *testing.T) {
+		rec := put(MatrixTransactionHandler(&fakeReceiver{err: domain.ErrUnavailable}, "hs-secret"),
+			MatrixTransactionsPath+"txn1", "hs-secret", testTransaction)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		h := MatrixTransactionHandler(&fakeReceiver{}, "hs-secret")
+
+		assert.Equal(t, http.StatusBadRequest, put(h, MatrixTransactionsPath+"txn1", "hs-secret", `{"events":`).Code)
+		assert.Equal(t, http.StatusNotFound, put(h, MatrixTransactionsPath, "hs-secret", `{"events": []}`).Code)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MatrixTransactionsPath+"txn1", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodPut, rec.Header().Get("Allow"))
+	})
+}
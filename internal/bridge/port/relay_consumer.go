@@ -0,0 +1,133 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// Relayer is the subset of app.Bridge the outbound consumer needs.
+type Relayer interface {
+	Relay(ctx context.Context, msg app.PlatformMessage) error
+}
+
+// PersistedMessageDecoder decodes the value of a messages.persisted record.
+type PersistedMessageDecoder func(value []byte) (app.PlatformMessage, error)
+
+// RelayConsumerConfig holds the dependencies for RelayConsumer.
+type RelayConsumerConfig struct {
+	Consumer kafka.Consumer
+	Decode   PersistedMessageDecoder
+	Relay    Relayer
+	Logger   *slog.Logger // nil uses slog.Default
+
+	// Backoff is the wait before retrying a failed relay, doubling with
+	// each attempt. Zero defaults to domain.BridgeRelayBackoff.
+	Backoff time.Duration
+}
+
+// RelayConsumer feeds messages.persisted to a bridge's outbound relay.
+// Records are relayed in order and committed a batch at a time, so a
+// restart resends at most one batch; remotes deduplicate the resends by
+// transaction ID. A record that cannot be decoded, or that the remote
+// refuses domain.BridgeRelayAttempts times, is logged and skipped.
+type RelayConsumer struct {
+	consumer kafka.Consumer
+	decode   PersistedMessageDecoder
+	relay    Relayer
+	logger   *slog.Logger
+	backoff  time.Duration
+}
+
+// NewRelayConsumer creates a RelayConsumer.
+func NewRelayConsumer(cfg RelayConsumerConfig) *RelayConsumer {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	backoff := cfg.Backoff
+	if backoff <= 0 {
+		backoff = domain.BridgeRelayBackoff
+	}
+	return &RelayConsumer{
+		consumer: cfg.Consumer,
+		decode:   cfg.Decode,
+		relay:    cfg.Relay,
+		logger:   logger,
+		backoff:  backoff,
+	}
+}
+
+// Run relays records until ctx is done or the consumer is closed. An
+// unfinished batch is left uncommitted.
+func (c *RelayConsumer) Run(ctx context.Context) error {
+	for {
+		records, err := c.consumer.Poll(ctx)
+		if errors.Is(err, kafka.ErrClientClosed) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("bridge relay: poll: %w", err)
+		}
+		for _, r := range records {
+			c.process(ctx, r)
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+		if err := c.consumer.Commit(ctx, records...); err != nil {
+			return fmt.Errorf("bridge relay: commit: %w", err)
+		}
+	}
+}
+
+// process relays one record, retrying failures with backoff. A panic is
+// recovered and the record skipped, so it cannot stop the loop.
+func (c *RelayConsumer) process(ctx context.Context, r *kafka.Record) {
+	defer observability.Recover(ctx, "kafka_consumer")
+	ctx, span := kafka.StartConsumeSpan(ctx, r)
+	defer span.End()
+
+	msg, err := c.decode(r.Value)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.WarnContext(ctx, "bridge.relay_undecodable",
+			"partition", r.Partition, "offset", r.Offset, "error", err)
+		return
+	}
+
+	wait := c.backoff
+	for attempt := 1; ; attempt++ {
+		err = c.relay.Relay(ctx, msg)
+		if err == nil {
+			return
+		}
+		if attempt == domain.BridgeRelayAttempts {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	c.logger.ErrorContext(ctx, "bridge.relay_dropped",
+		"message_id", msg.MessageID, "chat_id", msg.ChatID.String(),
+		"attempts", domain.BridgeRelayAttempts, "error", err)
+}
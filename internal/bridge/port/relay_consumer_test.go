@@ -0,0 +1,129 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/bridge/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+)
+
+type fakeRelayer struct {
+	mu       sync.Mutex
+	relayed  []string
+	attempts map[string]int
+	failing  map[string]int // message ID to failures before success
+}
+
+func (f *fakeRelayer) Relay(_ context.Context, msg app.PlatformMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts[msg.MessageID]++
+	if f.attempts[msg.MessageID] <= f.failing[msg.MessageID] {
+		return errors.New("homeserver down")
+	}
+	f.relayed = append(f.relayed, msg.MessageID)
+	return nil
+}
+
+// decodeID decodes records whose value is the message ID.
+func decodeID(value []byte) (app.PlatformMessage, error) {
+	if len(value) == 0 {
+		return app.PlatformMessage{}, errors.New("empty record")
+	}
+	return app.PlatformMessage{MessageID: string(value), ChatID: domain.MustChatID("11111111-1111-4111-8111-111111111111")}, nil
+}
+
+func TestRelayConsumer_Run(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	relayer := &fakeRelayer{
+		attempts: map[string]int{},
+		failing:  map[string]int{"m2": 1, "m3": domain.BridgeRelayAttempts},
+	}
+	c := NewRelayConsumer(RelayConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodeID,
+		Relay:    relayer,
+		Backoff:  time.Millisecond,
+	})
+
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")},
+		&kafka.Record{Topic: "messages.persisted", Value: nil},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m2")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m3")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m4")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 5 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"m1", "m2", "m4"}, relayer.relayed, "in order; undecodable and refused records skipped")
+	assert.Equal(t, 2, relayer.attempts["m2"], "retried once")
+	assert.Equal(t, domain.BridgeRelayAttempts, relayer.attempts["m3"])
+}
+
+func TestRelayConsumer_RunStopsWithoutCommitting(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	relayer := &fakeRelayer{attempts: map[string]int{}, failing: map[string]int{"m1": domain.BridgeRelayAttempts}}
+	c := NewRelayConsumer(RelayConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodeID,
+		Relay:    relayer,
+		Backoff:  time.Hour,
+	})
+	consumer.Push(&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+	require.Eventually(t, func() bool {
+		relayer.mu.Lock()
+		defer relayer.mu.Unlock()
+		return relayer.attempts["m1"] == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+
+	require.NoError(t, <-done)
+	assert.Empty(t, consumer.Committed(), "an interrupted batch is redelivered")
+}
+
+func TestRelayConsumer_RunRecoversPanics(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	relayer := &fakeRelayer{attempts: map[string]int{}}
+	c := NewRelayConsumer(RelayConsumerConfig{
+		Consumer: consumer,
+		Decode: func(value []byte) (app.PlatformMessage, error) {
+			if string(value) == "malformed" {
+				panic("index out of range")
+			}
+			return decodeID(value)
+		},
+		Relay:   relayer,
+		Backoff: time.Millisecond,
+	})
+
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("malformed")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m2")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 2 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"m2"}, relayer.relayed, "the panicking record is skipped")
+}
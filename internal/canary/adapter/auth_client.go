@@ -0,0 +1,142 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Compile-time check: AuthClient satisfies app.AuthAPI.
+var _ app.AuthAPI = (*AuthClient)(nil)
+
+// authMaxResponseBytes bounds the response body read; auth replies are a
+// few kilobytes at most.
+const authMaxResponseBytes = 1 << 20
+
+// httpDoer is the subset of *http.Client used by AuthClient.
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// AuthClient calls Chat Mgmt's REST auth API, as a mobile client would.
+type AuthClient struct {
+	baseURL string
+	client  httpDoer
+}
+
+// NewAuthClient creates an AuthClient for the API at baseURL, e.g.
+// "https://api.example.com".
+func NewAuthClient(baseURL string, client httpDoer) *AuthClient {
+	return &AuthClient{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// verifyOTPResponse is the grpc-gateway rendering of VerifyOTPResponse,
+// which uses the proto JSON (lowerCamelCase) field names.
+type verifyOTPResponse struct {
+	User struct {
+		UserID string `json:"userId"`
+	} `json:"user"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// gatewayError is the grpc-gateway error body.
+type gatewayError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RequestOTP asks Chat Mgmt to send an OTP to phone.
+func (c *AuthClient) RequestOTP(ctx context.Context, phone domain.PhoneNumber) error {
+	return c.call(ctx, "/v1/auth/otp/request", "", map[string]string{
+		"phone_number": phone.String(),
+	}, nil)
+}
+
+// VerifyOTP logs in with otp and returns the new session.
+func (c *AuthClient) VerifyOTP(ctx context.Context, phone domain.PhoneNumber, otp string, deviceID domain.DeviceID) (app.Session, error) {
+	var resp verifyOTPResponse
+	if err := c.call(ctx, "/v1/auth/otp/verify", "", map[string]string{
+		"phone_number": phone.String(),
+		"otp":          otp,
+		"device_id":    deviceID.String(),
+	}, &resp); err != nil {
+		return app.Session{}, err
+	}
+	if resp.AccessToken == "" {
+		return app.Session{}, fmt.Errorf("auth: verify otp: response has no access token")
+	}
+	return app.Session{UserID: resp.User.UserID, AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}, nil
+}
+
+// Logout revokes s.
+func (c *AuthClient) Logout(ctx context.Context, s app.Session) error {
+	return c.call(ctx, "/v1/auth/logout", s.AccessToken, map[string]string{
+		"refresh_token": s.RefreshToken,
+	}, nil)
+}
+
+// call POSTs in to path and decodes a 200 reply into out when set.
+func (c *AuthClient) call(ctx context.Context, path, accessToken string, in, out any) error {
+	ctx, span := tracer.Start(ctx, "chatmgmt.auth")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("http.request.method", http.MethodPost),
+		attribute.String("url.path", path),
+	)
+
+	if err := c.do(ctx, path, accessToken, in, out); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("auth: %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *AuthClient) do(ctx context.Context, path, accessToken string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, authMaxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var gwErr gatewayError
+		_ = json.Unmarshal(payload, &gwErr)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, gwErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
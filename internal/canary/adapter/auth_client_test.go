@@ -0,0 +1,72 @@
+package adapter_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+const (
+	testPhone  = "+14155550100"
+	testDevice = "aaaaaaaa-aaaa-4aaa-8aaa-aaaaaaaaaaaa"
+)
+
+func TestAuthClient(t *testing.T) {
+	var got []map[string]string
+	var logoutAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		got = append(got, body)
+		switch r.URL.Path {
+		case "/v1/auth/otp/request":
+			_, _ = w.Write([]byte(`{"expiresAt":{"millis":"1"},"retryAfterSeconds":60}`))
+		case "/v1/auth/otp/verify":
+			if body["otp"] != "123456" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"code":16,"message":"invalid OTP"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"user":{"userId":"u1"},"sessionId":"s1","accessToken":"at","refreshToken":"rt"}`))
+		case "/v1/auth/logout":
+			logoutAuth = r.Header.Get("Authorization")
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client := adapter.NewAuthClient(srv.URL+"/", srv.Client())
+	ctx := context.Background()
+	phone := domain.MustPhoneNumber(testPhone)
+
+	require.NoError(t, client.RequestOTP(ctx, phone))
+
+	s, err := client.VerifyOTP(ctx, phone, "123456", domain.MustDeviceID(testDevice))
+	require.NoError(t, err)
+	assert.Equal(t, app.Session{UserID: "u1", AccessToken: "at", RefreshToken: "rt"}, s)
+
+	_, err = client.VerifyOTP(ctx, phone, "000000", domain.MustDeviceID(testDevice))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401: invalid OTP")
+
+	require.NoError(t, client.Logout(ctx, s))
+	assert.Equal(t, "Bearer at", logoutAuth)
+
+	assert.Equal(t, []map[string]string{
+		{"phone_number": testPhone},
+		{"phone_number": testPhone, "otp": "123456", "device_id": testDevice},
+		{"phone_number": testPhone, "otp": "000000", "device_id": testDevice},
+		{"refresh_token": "rt"},
+	}, got)
+}
@@ -0,0 +1,8 @@
+// Package adapter contains implementations of interfaces defined in app.
+// The Chat Mgmt REST client, the Redis OTP sink reader and the Gateway
+// WebSocket client live here.
+package adapter
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("canary/adapter")
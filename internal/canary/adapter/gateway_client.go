@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/net/websocket"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Compile-time checks.
+var (
+	_ app.Gateway    = (*GatewayClient)(nil)
+	_ app.Connection = (*gatewayConn)(nil)
+)
+
+// gatewayMaxFrameBytes bounds a frame the canary reads.
+const gatewayMaxFrameBytes = 1 << 20
+
+// GatewayClient connects to the Gateway's WebSocket endpoint the way a
+// client app does (ADR-005): the access token and device ID are sent as
+// headers on the upgrade request.
+type GatewayClient struct {
+	url    string
+	origin string
+}
+
+// NewGatewayClient creates a GatewayClient for the WebSocket URL, e.g.
+// "wss://gateway.example.com/v1/ws".
+func NewGatewayClient(url string) *GatewayClient {
+	return &GatewayClient{url: url, origin: "https://canary.invalid"}
+}
+
+// Connect dials the Gateway and waits for connection_ack.
+func (g *GatewayClient) Connect(ctx context.Context, accessToken string, deviceID domain.DeviceID) (app.Connection, error) {
+	ctx, span := tracer.Start(ctx, "gateway.connect")
+	defer span.End()
+	span.SetAttributes(attribute.String("url.full", g.url))
+
+	c, err := g.connect(ctx, accessToken, deviceID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("gateway: connect: %w", err)
+	}
+	return c, nil
+}
+
+func (g *GatewayClient) connect(ctx context.Context, accessToken string, deviceID domain.DeviceID) (*gatewayConn, error) {
+	cfg, err := websocket.NewConfig(g.url, g.origin)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Header = http.Header{
+		"Authorization": {"Bearer " + accessToken},
+		"X-Device-Id":   {deviceID.String()},
+	}
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws.MaxPayloadBytes = gatewayMaxFrameBytes
+
+	// The ack is the first frame; read it before the reader loop starts.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = ws.SetReadDeadline(deadline)
+	}
+	var first protocol.Frame
+	if err := websocket.JSON.Receive(ws, &first); err != nil {
+		_ = ws.Close()
+		return nil, fmt.Errorf("read connection_ack: %w", err)
+	}
+	if first.Type != protocol.FrameTypeConnectionAck {
+		_ = ws.Close()
+		return nil, fmt.Errorf("first frame is %s, not connection_ack: %w", first.Type, frameError(&first))
+	}
+	_ = ws.SetReadDeadline(time.Time{})
+
+	c := &gatewayConn{
+		ws:   ws,
+		acks: make(chan protocol.SendMessageAck, 8),
+		msgs: make(chan protocol.Message, 64),
+		errs: make(chan protocol.Error, 8),
+		done: make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+// gatewayConn is an open Gateway connection. A reader goroutine answers
+// pings and routes acks, messages and errors to the waiting call.
+type gatewayConn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	acks chan protocol.SendMessageAck
+	msgs chan protocol.Message
+	errs chan protocol.Error
+
+	done    chan struct{} // closed when the reader stops
+	readErr error         // why the reader stopped; read after done
+}
+
+func (c *gatewayConn) read() {
+	defer close(c.done)
+	for {
+		var f protocol.Frame
+		if err := websocket.JSON.Receive(c.ws, &f); err != nil {
+			c.readErr = err
+			return
+		}
+		switch f.Type {
+		case protocol.FrameTypePing:
+			var ping protocol.Ping
+			_ = f.ParsePayload(&ping)
+			_ = c.write(protocol.FrameTypePong, protocol.Pong{Timestamp: ping.Timestamp})
+		case protocol.FrameTypeSendMessageAck:
+			var ack protocol.SendMessageAck
+			if f.ParsePayload(&ack) == nil {
+				offer(c.acks, ack)
+			}
+		case protocol.FrameTypeMessage:
+			var msg protocol.Message
+			if f.ParsePayload(&msg) == nil {
+				offer(c.msgs, msg)
+			}
+		case protocol.FrameTypeError:
+			var e protocol.Error
+			if f.ParsePayload(&e) == nil {
+				offer(c.errs, e)
+			}
+		case protocol.FrameTypeConnectionClosing:
+			c.readErr = frameError(&f)
+			return
+		}
+	}
+}
+
+// offer delivers v unless ch is full. The canary only waits for its own
+// frames, so a backlog of others is dropped rather than stalling pongs.
+func offer[T any](ch chan T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+func (c *gatewayConn) write(t protocol.FrameType, payload any) error {
+	f, err := protocol.NewFrame(t, payload)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return protocol.WriteFrame(c.ws, f)
+}
+
+// Send sends a text message and waits for its ack.
+func (c *gatewayConn) Send(ctx context.Context, chatID domain.ChatID, clientMessageID, text string) (uint64, error) {
+	if err := c.write(protocol.FrameTypeSendMessage, protocol.SendMessage{
+		ChatID:          chatID.String(),
+		ClientMessageID: clientMessageID,
+		ContentType:     string(domain.ContentTypeText),
+		Content:         text,
+		ClientTimestamp: time.Now().UnixMilli(),
+	}); err != nil {
+		return 0, fmt.Errorf("gateway: send: %w", err)
+	}
+	for {
+		select {
+		case ack := <-c.acks:
+			if ack.ClientMessageID == clientMessageID {
+				return ack.Sequence, nil
+			}
+		case e := <-c.errs:
+			return 0, fmt.Errorf("gateway: send: %s: %s", e.Code, e.Message)
+		case <-c.done:
+			return 0, fmt.Errorf("gateway: send: connection closed: %w", c.readErr)
+		case <-ctx.Done():
+			return 0, fmt.Errorf("gateway: send: no ack: %w", ctx.Err())
+		}
+	}
+}
+
+// AwaitMessage waits for the message with clientMessageID in chatID.
+func (c *gatewayConn) AwaitMessage(ctx context.Context, chatID domain.ChatID, clientMessageID string) error {
+	for {
+		select {
+		case msg := <-c.msgs:
+			if msg.ChatID == chatID.String() && msg.ClientMessageID == clientMessageID {
+				return nil
+			}
+		case <-c.done:
+			return fmt.Errorf("gateway: receive: connection closed: %w", c.readErr)
+		case <-ctx.Done():
+			return fmt.Errorf("gateway: receive: not delivered: %w", ctx.Err())
+		}
+	}
+}
+
+// Close closes the connection and waits for the reader to stop.
+func (c *gatewayConn) Close() error {
+	err := c.ws.Close()
+	<-c.done
+	return err
+}
+
+// frameError describes a connection_closing or error frame.
+func frameError(f *protocol.Frame) error {
+	var body struct {
+		Code    json.RawMessage `json:"code"`
+		Reason  string          `json:"reason"`
+		Message string          `json:"message"`
+	}
+	_ = f.ParsePayload(&body)
+	msg := body.Reason
+	if msg == "" {
+		msg = body.Message
+	}
+	return errors.New(string(f.Type) + ": " + string(body.Code) + " " + msg)
+}
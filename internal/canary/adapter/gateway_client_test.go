@@ -0,0 +1,124 @@
+package adapter_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+const testChat = "11111111-1111-4111-8111-111111111111"
+
+// fakeGateway acks every send_message, or rejects it when reject is set,
+// and echoes accepted messages back to the sender. It pings first and
+// requires the pong before serving sends.
+func fakeGateway(t *testing.T, reject bool) *httptest.Server {
+	t.Helper()
+	send := func(ws *websocket.Conn, ft protocol.FrameType, payload any) {
+		f, err := protocol.NewFrame(ft, payload)
+		require.NoError(t, err)
+		require.NoError(t, websocket.JSON.Send(ws, f))
+	}
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		h := ws.Request().Header
+		if h.Get("Authorization") != "Bearer at" || h.Get("X-Device-ID") != testDevice {
+			send(ws, protocol.FrameTypeConnectionClosing, protocol.ConnectionClosing{Reason: "unauthorized", Code: 4001})
+			return
+		}
+		send(ws, protocol.FrameTypeConnectionAck, protocol.ConnectionAck{ConnectionID: "c1"})
+		send(ws, protocol.FrameTypePing, protocol.Ping{Timestamp: 42})
+
+		for {
+			var f protocol.Frame
+			if err := websocket.JSON.Receive(ws, &f); err != nil {
+				return
+			}
+			switch f.Type {
+			case protocol.FrameTypePong:
+				var pong protocol.Pong
+				require.NoError(t, f.ParsePayload(&pong))
+				assert.Equal(t, int64(42), pong.Timestamp)
+			case protocol.FrameTypeSendMessage:
+				var sm protocol.SendMessage
+				require.NoError(t, f.ParsePayload(&sm))
+				if reject {
+					send(ws, protocol.FrameTypeError, protocol.Error{Code: "NOT_MEMBER", Message: "not a member"})
+					continue
+				}
+				send(ws, protocol.FrameTypeSendMessageAck, protocol.SendMessageAck{ClientMessageID: sm.ClientMessageID, Sequence: 7})
+				send(ws, protocol.FrameTypeMessage, protocol.Message{ChatID: sm.ChatID, ClientMessageID: sm.ClientMessageID, Sequence: 7})
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/ws"
+}
+
+func TestGatewayClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := adapter.NewGatewayClient(wsURL(fakeGateway(t, false)))
+	chatID := domain.MustChatID(testChat)
+
+	conn, err := client.Connect(ctx, "at", domain.MustDeviceID(testDevice))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	seq, err := conn.Send(ctx, chatID, "cm-1", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), seq)
+	require.NoError(t, conn.AwaitMessage(ctx, chatID, "cm-1"))
+}
+
+func TestGatewayClient_SendRejected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := adapter.NewGatewayClient(wsURL(fakeGateway(t, true)))
+
+	conn, err := client.Connect(ctx, "at", domain.MustDeviceID(testDevice))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = conn.Send(ctx, domain.MustChatID(testChat), "cm-1", "hello")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NOT_MEMBER")
+}
+
+func TestGatewayClient_ConnectRejected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := adapter.NewGatewayClient(wsURL(fakeGateway(t, false)))
+
+	_, err := client.Connect(ctx, "expired", domain.MustDeviceID(testDevice))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unauthorized")
+}
+
+func TestGatewayClient_MessageNotDelivered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := adapter.NewGatewayClient(wsURL(fakeGateway(t, false)))
+	conn, err := client.Connect(ctx, "at", domain.MustDeviceID(testDevice))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer waitCancel()
+	err = conn.AwaitMessage(waitCtx, domain.MustChatID(testChat), "never-sent")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// Compile-time check: OTPSinkReader satisfies app.OTPSink.
+var _ app.OTPSink = (*OTPSinkReader)(nil)
+
+// otpSinkPollInterval is how often AwaitCode checks for the code. Chat
+// Mgmt sends OTPs asynchronously, so the code lands shortly after
+// RequestOTP returns.
+const otpSinkPollInterval = 100 * time.Millisecond
+
+// OTPSinkReader reads the OTPs Chat Mgmt writes for sink numbers (see
+// domain.OTPSinkKey). It must use the Redis Chat Mgmt writes to.
+type OTPSinkReader struct {
+	cmd redisclient.Cmdable
+}
+
+// NewOTPSinkReader creates an OTPSinkReader.
+func NewOTPSinkReader(cmd redisclient.Cmdable) *OTPSinkReader {
+	return &OTPSinkReader{cmd: cmd}
+}
+
+// AwaitCode polls for the OTP of phone until it is written or ctx is done.
+func (r *OTPSinkReader) AwaitCode(ctx context.Context, phone domain.PhoneNumber) (string, error) {
+	ctx, span := tracer.Start(ctx, "redis.otp_sink.await")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "GET"),
+	)
+
+	key := domain.OTPSinkKey(phone.String())
+	ticker := time.NewTicker(otpSinkPollInterval)
+	defer ticker.Stop()
+	for {
+		code, err := r.cmd.Get(ctx, key).Result()
+		switch {
+		case err == nil:
+			return code, nil
+		case !errors.Is(err, redis.Nil):
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return "", fmt.Errorf("otp sink: get: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("otp sink: no code for %s: %w", phone, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clear deletes the OTP of phone.
+func (r *OTPSinkReader) Clear(ctx context.Context, phone domain.PhoneNumber) error {
+	ctx, span := tracer.Start(ctx, "redis.otp_sink.clear")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "DEL"),
+	)
+
+	if err := r.cmd.Del(ctx, domain.OTPSinkKey(phone.String())).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("otp sink: clear: %w", err)
+	}
+	return nil
+}
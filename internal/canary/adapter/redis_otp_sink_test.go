@@ -0,0 +1,57 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func TestOTPSinkReader(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() { require.NoError(t, client.Close()) })
+
+	reader := adapter.NewOTPSinkReader(client.RDB)
+	phone := domain.MustPhoneNumber(testPhone)
+	key := domain.OTPSinkKey(testPhone)
+
+	t.Run("waits for the code", func(t *testing.T) {
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			_ = mr.Set(key, "123456")
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		code, err := reader.AwaitCode(ctx, phone)
+
+		require.NoError(t, err)
+		assert.Equal(t, "123456", code)
+	})
+
+	t.Run("clear deletes the code", func(t *testing.T) {
+		require.NoError(t, reader.Clear(context.Background(), phone))
+		assert.False(t, mr.Exists(key))
+	})
+
+	t.Run("no code before the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+		defer cancel()
+
+		_, err := reader.AwaitCode(ctx, phone)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
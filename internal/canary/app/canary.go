@@ -0,0 +1,299 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	canaryStepsTotal      metric.Int64Counter
+	canaryStepDuration    metric.Float64Histogram
+	canaryJourneysTotal   metric.Int64Counter
+	canaryJourneyDuration metric.Float64Histogram
+)
+
+func init() {
+	m := otel.Meter("canary/app")
+	canaryStepsTotal, _ = m.Int64Counter("canary_steps_total",
+		metric.WithDescription("Canary journey steps by step and result (ok, error)"))
+	canaryStepDuration, _ = m.Float64Histogram("canary_step_duration_seconds",
+		metric.WithDescription("Canary step latency by step and result; receive is measured from the start of send"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10))
+	canaryJourneysTotal, _ = m.Int64Counter("canary_journeys_total",
+		metric.WithDescription("Canary journeys by result (ok, error)"))
+	canaryJourneyDuration, _ = m.Float64Histogram("canary_journey_duration_seconds",
+		metric.WithDescription("Canary journey latency by result"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.5, 1, 2.5, 5, 10, 25, 50, 100))
+}
+
+// Journey steps, in the order they run. A failed step ends the journey;
+// logout still runs for every user that logged in.
+const (
+	StepRequestOTP = "request_otp"
+	StepReadOTP    = "read_otp"
+	StepVerifyOTP  = "verify_otp"
+	StepConnect    = "connect"
+	StepSend       = "send"
+	StepReceive    = "receive"
+	StepLogout     = "logout"
+)
+
+// Step results.
+const (
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+// Session is a logged-in canary user.
+type Session struct {
+	UserID       string
+	AccessToken  string
+	RefreshToken string
+}
+
+// AuthAPI is Chat Mgmt's public auth API.
+type AuthAPI interface {
+	RequestOTP(ctx context.Context, phone domain.PhoneNumber) error
+	VerifyOTP(ctx context.Context, phone domain.PhoneNumber, otp string, deviceID domain.DeviceID) (Session, error)
+	Logout(ctx context.Context, s Session) error
+}
+
+// OTPSink reads the OTPs Chat Mgmt writes for sink numbers.
+type OTPSink interface {
+	// AwaitCode returns the pending OTP of phone, waiting until Chat Mgmt
+	// has written it or ctx is done.
+	AwaitCode(ctx context.Context, phone domain.PhoneNumber) (string, error)
+	// Clear removes the OTP of phone once it has been used.
+	Clear(ctx context.Context, phone domain.PhoneNumber) error
+}
+
+// Gateway opens WebSocket connections to the Gateway.
+type Gateway interface {
+	// Connect opens an authenticated connection and waits for the
+	// connection_ack. ctx bounds the handshake only; the connection stays
+	// open until Close.
+	Connect(ctx context.Context, accessToken string, deviceID domain.DeviceID) (Connection, error)
+}
+
+// Connection is an open Gateway connection.
+type Connection interface {
+	// Send sends a text message and waits for its send_message_ack,
+	// returning the assigned sequence.
+	Send(ctx context.Context, chatID domain.ChatID, clientMessageID, text string) (uint64, error)
+	// AwaitMessage waits for the message with clientMessageID in chatID to
+	// be delivered.
+	AwaitMessage(ctx context.Context, chatID domain.ChatID, clientMessageID string) error
+	Close() error
+}
+
+// User is a canary account, identified by its sink number. DeviceID
+// should be stable so each login replaces the previous session of the
+// device rather than adding one.
+type User struct {
+	Phone    domain.PhoneNumber
+	DeviceID domain.DeviceID
+}
+
+// CanaryConfig configures a Canary.
+type CanaryConfig struct {
+	Auth    AuthAPI
+	Sink    OTPSink
+	Gateway Gateway
+	// Sender and Receiver must both be members of ChatID, and Sender must be
+	// allowed to post in it.
+	Sender   User
+	Receiver User
+	ChatID   domain.ChatID
+	// Interval is the time between journey starts.
+	Interval time.Duration
+	// StepTimeout bounds each step.
+	StepTimeout time.Duration
+	Clock       domain.Clock
+	Logger      *slog.Logger // nil uses slog.Default
+}
+
+// Canary runs the user journey against a deployment: both users log in
+// with an OTP read from the sink, connect to the Gateway, and the sender
+// sends a message the receiver must get. Every step exports its result and
+// latency, so an alert can name the step that broke.
+type Canary struct {
+	auth        AuthAPI
+	sink        OTPSink
+	gateway     Gateway
+	sender      User
+	receiver    User
+	chatID      domain.ChatID
+	interval    time.Duration
+	stepTimeout time.Duration
+	clock       domain.Clock
+	logger      *slog.Logger
+}
+
+// NewCanary creates a Canary.
+func NewCanary(cfg CanaryConfig) *Canary {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Canary{
+		auth:        cfg.Auth,
+		sink:        cfg.Sink,
+		gateway:     cfg.Gateway,
+		sender:      cfg.Sender,
+		receiver:    cfg.Receiver,
+		chatID:      cfg.ChatID,
+		interval:    cfg.Interval,
+		stepTimeout: cfg.StepTimeout,
+		clock:       clock,
+		logger:      logger,
+	}
+}
+
+// Run runs a journey immediately and then every interval until ctx is
+// done. A failed journey is logged; the next one starts on schedule.
+func (c *Canary) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.RunJourney(ctx); err != nil && ctx.Err() == nil {
+			c.logger.WarnContext(ctx, "canary journey failed", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunJourney runs one journey and records its result.
+func (c *Canary) RunJourney(ctx context.Context) error {
+	start := c.clock.Now()
+	err := c.journey(ctx)
+
+	attrs := metric.WithAttributes(attribute.String("result", result(err)))
+	canaryJourneysTotal.Add(ctx, 1, attrs)
+	canaryJourneyDuration.Record(ctx, c.clock.Now().Sub(start).Seconds(), attrs)
+	return err
+}
+
+func (c *Canary) journey(ctx context.Context) (err error) {
+	sender, err := c.login(ctx, c.sender)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, c.logout(ctx, sender)) }()
+	receiver, err := c.login(ctx, c.receiver)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, c.logout(ctx, receiver)) }()
+
+	// The receiver connects first so the message cannot be delivered before
+	// it is listening.
+	var recvConn, sendConn Connection
+	if err := c.step(ctx, StepConnect, func(ctx context.Context) (err error) {
+		recvConn, err = c.gateway.Connect(ctx, receiver.AccessToken, c.receiver.DeviceID)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer func() { _ = recvConn.Close() }()
+	if err := c.step(ctx, StepConnect, func(ctx context.Context) (err error) {
+		sendConn, err = c.gateway.Connect(ctx, sender.AccessToken, c.sender.DeviceID)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer func() { _ = sendConn.Close() }()
+
+	clientMessageID := uuid.NewString()
+	sent := c.clock.Now()
+	if err := c.step(ctx, StepSend, func(ctx context.Context) error {
+		_, err := sendConn.Send(ctx, c.chatID, clientMessageID, "canary "+clientMessageID)
+		return err
+	}); err != nil {
+		return err
+	}
+	return c.stepSince(ctx, StepReceive, sent, func(ctx context.Context) error {
+		return recvConn.AwaitMessage(ctx, c.chatID, clientMessageID)
+	})
+}
+
+// login requests an OTP for u, reads it from the sink and verifies it. The
+// code is cleared once used so the next journey never reads a stale one.
+func (c *Canary) login(ctx context.Context, u User) (Session, error) {
+	if err := c.step(ctx, StepRequestOTP, func(ctx context.Context) error {
+		return c.auth.RequestOTP(ctx, u.Phone)
+	}); err != nil {
+		return Session{}, err
+	}
+	var code string
+	if err := c.step(ctx, StepReadOTP, func(ctx context.Context) (err error) {
+		code, err = c.sink.AwaitCode(ctx, u.Phone)
+		return err
+	}); err != nil {
+		return Session{}, err
+	}
+	var s Session
+	if err := c.step(ctx, StepVerifyOTP, func(ctx context.Context) (err error) {
+		if s, err = c.auth.VerifyOTP(ctx, u.Phone, code, u.DeviceID); err != nil {
+			return err
+		}
+		return c.sink.Clear(ctx, u.Phone)
+	}); err != nil {
+		return Session{}, err
+	}
+	return s, nil
+}
+
+// logout ends s even when the journey was cancelled, so canary sessions do
+// not pile up until they are evicted.
+func (c *Canary) logout(ctx context.Context, s Session) error {
+	return c.step(context.WithoutCancel(ctx), StepLogout, func(ctx context.Context) error {
+		return c.auth.Logout(ctx, s)
+	})
+}
+
+func (c *Canary) step(ctx context.Context, name string, fn func(context.Context) error) error {
+	return c.stepSince(ctx, name, c.clock.Now(), fn)
+}
+
+// stepSince runs fn under the step timeout and records the step's result
+// and its latency measured from start.
+func (c *Canary) stepSince(ctx context.Context, name string, start time.Time, fn func(context.Context) error) error {
+	stepCtx, cancel := context.WithTimeout(ctx, c.stepTimeout)
+	err := fn(stepCtx)
+	cancel()
+
+	attrs := metric.WithAttributes(attribute.String("step", name), attribute.String("result", result(err)))
+	canaryStepsTotal.Add(ctx, 1, attrs)
+	canaryStepDuration.Record(ctx, c.clock.Now().Sub(start).Seconds(), attrs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
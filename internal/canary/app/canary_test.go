@@ -0,0 +1,235 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/canary/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+const (
+	chatID         = "11111111-1111-4111-8111-111111111111"
+	senderPhone    = "+14155550100"
+	receiverPhone  = "+14155550101"
+	senderDevice   = "aaaaaaaa-aaaa-4aaa-8aaa-aaaaaaaaaaaa"
+	receiverDevice = "bbbbbbbb-bbbb-4bbb-8bbb-bbbbbbbbbbbb"
+)
+
+// fakeAuth issues a session per phone, tokens named after the phone.
+type fakeAuth struct {
+	mu         sync.Mutex
+	sink       *fakeSink
+	requestErr error
+	noCode     bool // the OTP is never written to the sink
+	verifyErr  error
+	requested  []string
+	loggedOut  []string
+}
+
+func (a *fakeAuth) RequestOTP(_ context.Context, phone domain.PhoneNumber) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.requestErr != nil {
+		return a.requestErr
+	}
+	a.requested = append(a.requested, phone.String())
+	if !a.noCode {
+		a.sink.put(phone.String(), "123456")
+	}
+	return nil
+}
+
+func (a *fakeAuth) VerifyOTP(_ context.Context, phone domain.PhoneNumber, otp string, _ domain.DeviceID) (app.Session, error) {
+	if a.verifyErr != nil {
+		return app.Session{}, a.verifyErr
+	}
+	if otp != "123456" {
+		return app.Session{}, domain.ErrInvalidOTP
+	}
+	return app.Session{UserID: "user-" + phone.String(), AccessToken: "access-" + phone.String()}, nil
+}
+
+func (a *fakeAuth) Logout(_ context.Context, s app.Session) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loggedOut = append(a.loggedOut, s.UserID)
+	return nil
+}
+
+type fakeSink struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (s *fakeSink) put(phone, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[phone] = code
+}
+
+func (s *fakeSink) AwaitCode(ctx context.Context, phone domain.PhoneNumber) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.codes[phone.String()]
+	if !ok {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return code, nil
+}
+
+func (s *fakeSink) Clear(_ context.Context, phone domain.PhoneNumber) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.codes, phone.String())
+	return nil
+}
+
+// fakeGateway delivers every sent message to every connection unless drop
+// is set.
+type fakeGateway struct {
+	mu        sync.Mutex
+	conns     []*fakeConn
+	connected []string
+	drop      bool
+}
+
+func (g *fakeGateway) Connect(_ context.Context, accessToken string, _ domain.DeviceID) (app.Connection, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c := &fakeConn{gw: g, inbox: make(chan string, 8)}
+	g.conns = append(g.conns, c)
+	g.connected = append(g.connected, accessToken)
+	return c, nil
+}
+
+type fakeConn struct {
+	gw     *fakeGateway
+	inbox  chan string
+	closed bool
+}
+
+func (c *fakeConn) Send(_ context.Context, _ domain.ChatID, clientMessageID, _ string) (uint64, error) {
+	c.gw.mu.Lock()
+	defer c.gw.mu.Unlock()
+	if !c.gw.drop {
+		for _, conn := range c.gw.conns {
+			conn.inbox <- clientMessageID
+		}
+	}
+	return 1, nil
+}
+
+func (c *fakeConn) AwaitMessage(ctx context.Context, _ domain.ChatID, clientMessageID string) error {
+	for {
+		select {
+		case id := <-c.inbox:
+			if id == clientMessageID {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newCanary(auth *fakeAuth, gw *fakeGateway) *app.Canary {
+	return app.NewCanary(app.CanaryConfig{
+		Auth:        auth,
+		Sink:        auth.sink,
+		Gateway:     gw,
+		Sender:      app.User{Phone: domain.MustPhoneNumber(senderPhone), DeviceID: domain.MustDeviceID(senderDevice)},
+		Receiver:    app.User{Phone: domain.MustPhoneNumber(receiverPhone), DeviceID: domain.MustDeviceID(receiverDevice)},
+		ChatID:      domain.MustChatID(chatID),
+		Interval:    time.Hour,
+		StepTimeout: 50 * time.Millisecond,
+	})
+}
+
+func newFakes() (*fakeAuth, *fakeGateway) {
+	return &fakeAuth{sink: &fakeSink{codes: map[string]string{}}}, &fakeGateway{}
+}
+
+func TestRunJourney(t *testing.T) {
+	auth, gw := newFakes()
+
+	err := newCanary(auth, gw).RunJourney(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{senderPhone, receiverPhone}, auth.requested)
+	assert.Equal(t, []string{"access-" + receiverPhone, "access-" + senderPhone}, gw.connected,
+		"receiver connects first")
+	assert.ElementsMatch(t, []string{"user-" + senderPhone, "user-" + receiverPhone}, auth.loggedOut)
+	assert.Empty(t, auth.sink.codes, "used codes are cleared")
+	for _, c := range gw.conns {
+		assert.True(t, c.closed)
+	}
+}
+
+func TestRunJourney_Failures(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(*fakeAuth, *fakeGateway)
+		wantStep   string
+		wantLogout int
+	}{
+		{
+			name:     "otp request rejected",
+			setup:    func(a *fakeAuth, _ *fakeGateway) { a.requestErr = domain.ErrPhoneRateLimited },
+			wantStep: app.StepRequestOTP,
+		},
+		{
+			name:     "otp never arrives",
+			setup:    func(a *fakeAuth, _ *fakeGateway) { a.noCode = true },
+			wantStep: app.StepReadOTP,
+		},
+		{
+			name:     "verification fails",
+			setup:    func(a *fakeAuth, _ *fakeGateway) { a.verifyErr = domain.ErrInvalidOTP },
+			wantStep: app.StepVerifyOTP,
+		},
+		{
+			name:       "message not delivered",
+			setup:      func(_ *fakeAuth, g *fakeGateway) { g.drop = true },
+			wantStep:   app.StepReceive,
+			wantLogout: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, gw := newFakes()
+			tt.setup(auth, gw)
+
+			err := newCanary(auth, gw).RunJourney(context.Background())
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantStep+":")
+			assert.Len(t, auth.loggedOut, tt.wantLogout)
+		})
+	}
+}
+
+func TestRunJourney_LogsOutAfterCancel(t *testing.T) {
+	auth, gw := newFakes()
+	gw.drop = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := newCanary(auth, gw).RunJourney(ctx)
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Len(t, auth.loggedOut, 2, "logout runs on a cancelled journey")
+}
@@ -0,0 +1,3 @@
+// Package app contains the synthetic canary: the user journey it runs
+// against a deployment and the per-step metrics it exports for alerting.
+package app
@@ -5,19 +5,44 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	awsssm "github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/aelexs/realtime-messaging-platform/internal/auth"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 )
 
+var (
+	publicKeyRefreshes   metric.Int64Counter
+	publicKeyStaleServes metric.Int64Counter
+
+	refreshFetchedAttr   = metric.WithAttributes(attribute.String("result", "fetched"))
+	refreshCoalescedAttr = metric.WithAttributes(attribute.String("result", "coalesced"))
+)
+
+func init() {
+	publicKeyRefreshes, _ = otel.Meter("chatmgmt/adapter").Int64Counter("auth_public_key_refreshes_total",
+		metric.WithDescription("Public key cache refreshes, by result (fetched, coalesced into a concurrent fetch)"))
+	publicKeyStaleServes, _ = otel.Meter("chatmgmt/adapter").Int64Counter("auth_public_key_stale_serves_total",
+		metric.WithDescription("Public keys served from an expired cache because SSM could not be reached"))
+}
+
 // smClient is the narrow consumer-defined interface for Secrets Manager operations.
 type smClient interface {
 	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
@@ -37,7 +62,10 @@ var _ auth.KeyStore = (*AWSKeyStore)(nil)
 //
 // The signing key is eagerly loaded at construction time per ADR-015: the service
 // MUST NOT start without a signing key. Public keys are cached with a configurable
-// TTL (default 300s per TBD-PR1-1) and refreshed lazily on read.
+// TTL (default 300s per TBD-PR1-1) and refreshed lazily on read. Concurrent
+// refreshes share one SSM load. If a refresh fails, cached keys keep being
+// served for up to staleMaxAge after they were loaded while the refresh is
+// retried in the background, so an SSM outage does not fail every token.
 type AWSKeyStore struct {
 	sm    smClient
 	ssm   ssmClient
@@ -51,6 +79,12 @@ type AWSKeyStore struct {
 	lastUnknownKidRefresh time.Time
 	cacheTTL              time.Duration
 	kidCooldown           time.Duration
+	throttleBackoff       time.Duration
+	staleMaxAge           time.Duration
+	staleRetryBackoff     time.Duration
+
+	refreshGroup singleflight.Group
+	revalidating atomic.Bool
 }
 
 const (
@@ -69,6 +103,24 @@ const (
 
 	// defaultKidCooldown is the cooldown between unknown kid SSM refreshes per TBD-PR1-1 (30s).
 	defaultKidCooldown = 30 * time.Second
+
+	// ssmMaxResultsPerPage is the largest page GetParametersByPath returns.
+	ssmMaxResultsPerPage = 10
+
+	// defaultThrottleBackoff is the wait before retrying a throttled call,
+	// doubling with each attempt, up to throttleAttempts calls in all.
+	defaultThrottleBackoff = 100 * time.Millisecond
+	throttleAttempts       = 5
+
+	// defaultStaleMaxAge is how long after loading cached public keys may be
+	// served when SSM cannot be reached. Past it, a key removed from SSM
+	// would be trusted too long.
+	defaultStaleMaxAge = 1 * time.Hour
+
+	// defaultStaleRetryBackoff is the wait before the first background
+	// refresh retry, doubling up to maxStaleRetryBackoff.
+	defaultStaleRetryBackoff = 1 * time.Second
+	maxStaleRetryBackoff     = 30 * time.Second
 )
 
 // NewAWSKeyStore creates an AWSKeyStore and eagerly loads all keys from AWS.
@@ -78,15 +130,24 @@ const (
 //  1. Fetches the current key ID from SSM
 //  2. Fetches the private signing key from Secrets Manager
 //  3. Parses the PEM-encoded private key
-//  4. Loads all public keys from SSM
+//  4. Loads all public keys from SSM, page by page
 //  5. Parses each PEM-encoded public key
 //
-// Returns an error if any step fails. Per ADR-015, the service MUST NOT start
-// without a valid signing key.
+// Steps 2-3 and 4-5 are independent and run concurrently; both finish
+// before the constructor returns. Throttled AWS calls are retried with
+// backoff. Returns an error if any step fails. Per ADR-015, the service
+// MUST NOT start without a valid signing key.
 func NewAWSKeyStore(ctx context.Context, sm smClient, ssm ssmClient, clock domain.Clock) (*AWSKeyStore, error) {
+	backoff := defaultThrottleBackoff
+
 	// Step 1: Fetch current key ID from SSM.
-	keyIDOutput, err := ssm.GetParameter(ctx, &awsssm.GetParameterInput{
-		Name: aws.String(ssmCurrentKeyIDPath),
+	var keyIDOutput *awsssm.GetParameterOutput
+	err := retryThrottled(ctx, backoff, func() error {
+		var err error
+		keyIDOutput, err = ssm.GetParameter(ctx, &awsssm.GetParameterInput{
+			Name: aws.String(ssmCurrentKeyIDPath),
+		})
+		return err
 	})
 	if err != nil {
 		return nil, fmt.Errorf("fetching current key ID from SSM: %w", err)
@@ -96,28 +157,26 @@ func NewAWSKeyStore(ctx context.Context, sm smClient, ssm ssmClient, clock domai
 	}
 	currentKeyID := *keyIDOutput.Parameter.Value
 
-	// Step 2: Fetch private signing key from Secrets Manager.
-	secretName := smSigningKeyPrefix + currentKeyID
-	secretOutput, err := sm.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
+	var (
+		privateKey *rsa.PrivateKey
+		publicKeys map[string]*rsa.PublicKey
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		privateKey, err = loadSigningKey(gctx, sm, currentKeyID, backoff)
+		return err
 	})
-	if err != nil {
-		return nil, fmt.Errorf("fetching signing key %q from Secrets Manager: %w", secretName, err)
-	}
-	if secretOutput.SecretString == nil {
-		return nil, fmt.Errorf("signing key %q has no secret string", secretName)
-	}
-
-	// Step 3: Parse PEM-encoded private key.
-	privateKey, err := parseRSAPrivateKey(*secretOutput.SecretString)
-	if err != nil {
-		return nil, fmt.Errorf("parsing private key for key ID %q: %w", currentKeyID, err)
-	}
-
-	// Step 4: Load all public keys from SSM.
-	publicKeys, err := loadPublicKeysFromSSM(ctx, ssm)
-	if err != nil {
-		return nil, fmt.Errorf("loading public keys from SSM: %w", err)
+	g.Go(func() error {
+		var err error
+		publicKeys, err = loadPublicKeysFromSSM(gctx, ssm, backoff)
+		if err != nil {
+			return fmt.Errorf("loading public keys from SSM: %w", err)
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
 	return &AWSKeyStore{
@@ -130,9 +189,45 @@ func NewAWSKeyStore(ctx context.Context, sm smClient, ssm ssmClient, clock domai
 		publicKeysLoadedAt: clock.Now(),
 		cacheTTL:           defaultCacheTTL,
 		kidCooldown:        defaultKidCooldown,
+		throttleBackoff:    backoff,
+		staleMaxAge:        defaultStaleMaxAge,
+		staleRetryBackoff:  defaultStaleRetryBackoff,
 	}, nil
 }
 
+// NewAWSKeyStoreFromConfig creates an AWSKeyStore with Secrets Manager and
+// SSM clients for the region, credentials, and optional BaseEndpoint (e.g.
+// LocalStack) in cfg.
+func NewAWSKeyStoreFromConfig(ctx context.Context, cfg aws.Config, clock domain.Clock) (*AWSKeyStore, error) {
+	return NewAWSKeyStore(ctx, secretsmanager.NewFromConfig(cfg), awsssm.NewFromConfig(cfg), clock)
+}
+
+// loadSigningKey fetches the private signing key for keyID from Secrets
+// Manager (step 2) and parses it (step 3).
+func loadSigningKey(ctx context.Context, sm smClient, keyID string, backoff time.Duration) (*rsa.PrivateKey, error) {
+	secretName := smSigningKeyPrefix + keyID
+	var secretOutput *secretsmanager.GetSecretValueOutput
+	err := retryThrottled(ctx, backoff, func() error {
+		var err error
+		secretOutput, err = sm.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(secretName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching signing key %q from Secrets Manager: %w", secretName, err)
+	}
+	if secretOutput.SecretString == nil {
+		return nil, fmt.Errorf("signing key %q has no secret string", secretName)
+	}
+
+	privateKey, err := parseRSAPrivateKey(*secretOutput.SecretString)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key for key ID %q: %w", keyID, err)
+	}
+	return privateKey, nil
+}
+
 // SigningKey returns the current private signing key and its key ID.
 // Thread-safe via RLock.
 func (ks *AWSKeyStore) SigningKey() (*rsa.PrivateKey, string, error) {
@@ -150,6 +245,9 @@ func (ks *AWSKeyStore) SigningKey() (*rsa.PrivateKey, string, error) {
 // Cache strategy (per TBD-PR1-1):
 //   - If kid is found and cache is fresh, return immediately.
 //   - If cache is expired (age > cacheTTL), refresh all public keys inline.
+//     If that fails and the cache is younger than staleMaxAge, serve the
+//     cached key and retry the refresh in the background; until the retry
+//     succeeds, expired lookups serve the cache without refreshing inline.
 //   - If kid is not found and cooldown is expired, do a single SSM refresh.
 //   - If kid is still not found after refresh, return an error.
 //
@@ -161,7 +259,8 @@ func (ks *AWSKeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	// Fast path: RLock check.
 	ks.mu.RLock()
 	now := ks.clock.Now()
-	cacheExpired := now.Sub(ks.publicKeysLoadedAt) > ks.cacheTTL
+	loadedAt := ks.publicKeysLoadedAt
+	cacheExpired := now.Sub(loadedAt) > ks.cacheTTL
 
 	if !cacheExpired {
 		if pk, ok := ks.publicKeys[kid]; ok {
@@ -173,8 +272,20 @@ func (ks *AWSKeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 
 	// Slow path: cache expired or kid not found — need refresh.
 	if cacheExpired {
-		if err := ks.refreshPublicKeys(context.Background()); err != nil {
-			return nil, fmt.Errorf("refreshing public keys (cache expired): %w", err)
+		if ks.revalidating.Load() {
+			if pk, ok := ks.staleKey(kid, now); ok {
+				return pk, nil
+			}
+		}
+		if err := ks.refreshPublicKeys(context.Background(), loadedAt); err != nil {
+			pk, ok := ks.staleKey(kid, now)
+			if !ok {
+				return nil, fmt.Errorf("refreshing public keys (cache expired): %w", err)
+			}
+			if ks.revalidating.CompareAndSwap(false, true) {
+				go ks.revalidate(loadedAt)
+			}
+			return pk, nil
 		}
 
 		ks.mu.RLock()
@@ -196,7 +307,7 @@ func (ks *AWSKeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	}
 
 	// Single SSM refresh for unknown kid with cooldown update.
-	if err := ks.refreshPublicKeysWithCooldown(context.Background()); err != nil {
+	if err := ks.refreshPublicKeysWithCooldown(context.Background(), loadedAt); err != nil {
 		return nil, fmt.Errorf("refreshing public keys (unknown kid %q): %w", kid, err)
 	}
 
@@ -210,65 +321,177 @@ func (ks *AWSKeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	return pk, nil
 }
 
-// refreshPublicKeys fetches all public keys from SSM and updates the cache.
-// Acquires write Lock.
-func (ks *AWSKeyStore) refreshPublicKeys(ctx context.Context) error {
-	publicKeys, err := loadPublicKeysFromSSM(ctx, ks.ssm)
-	if err != nil {
-		return fmt.Errorf("loading public keys from SSM: %w", err)
+// KeyIDs returns the current signing key ID and the IDs of the cached
+// public keys. It does not refresh the cache, so it shows what tokens are
+// being verified against right now.
+func (ks *AWSKeyStore) KeyIDs() (string, []string) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.currentKeyID, slices.Sorted(maps.Keys(ks.publicKeys))
+}
+
+// staleKey returns the cached key for kid if the cache, though expired, is
+// still young enough to serve.
+func (ks *AWSKeyStore) staleKey(kid string, now time.Time) (*rsa.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if now.Sub(ks.publicKeysLoadedAt) > ks.staleMaxAge {
+		return nil, false
+	}
+	pk, ok := ks.publicKeys[kid]
+	if ok {
+		publicKeyStaleServes.Add(context.Background(), 1)
 	}
+	return pk, ok
+}
 
-	ks.mu.Lock()
-	defer ks.mu.Unlock()
+// revalidate retries a failed refresh of the cache loaded at seen with
+// backoff until it succeeds or the cache is too old to serve, after which
+// lookups go back to refreshing inline. It runs on its own goroutine, at
+// most one at a time.
+func (ks *AWSKeyStore) revalidate(seen time.Time) {
+	defer ks.revalidating.Store(false)
+
+	wait := ks.staleRetryBackoff
+	for {
+		time.Sleep(wait)
+		if err := ks.refreshPublicKeys(context.Background(), seen); err == nil {
+			return
+		}
+		if ks.clock.Now().Sub(seen) > ks.staleMaxAge {
+			return
+		}
+		wait = min(wait*2, maxStaleRetryBackoff)
+	}
+}
 
-	ks.publicKeys = publicKeys
-	ks.publicKeysLoadedAt = ks.clock.Now()
-	return nil
+// refreshPublicKeys fetches all public keys from SSM and updates the cache,
+// unless it has been refreshed since the caller saw it loaded at seen. A
+// caller arriving while another refresh is in flight waits for it and
+// shares its result rather than loading again. Acquires write Lock.
+func (ks *AWSKeyStore) refreshPublicKeys(ctx context.Context, seen time.Time) error {
+	fetched := false
+	_, err, _ := ks.refreshGroup.Do("public-keys", func() (any, error) {
+		// A concurrent refresh may have finished between the caller's
+		// check and Do.
+		ks.mu.RLock()
+		refreshed := ks.publicKeysLoadedAt.After(seen)
+		ks.mu.RUnlock()
+		if refreshed {
+			return nil, nil
+		}
+		fetched = true
+		publicKeys, err := loadPublicKeysFromSSM(ctx, ks.ssm, ks.throttleBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("loading public keys from SSM: %w", err)
+		}
+
+		ks.mu.Lock()
+		defer ks.mu.Unlock()
+
+		ks.publicKeys = publicKeys
+		ks.publicKeysLoadedAt = ks.clock.Now()
+		return nil, nil
+	})
+	if fetched {
+		publicKeyRefreshes.Add(ctx, 1, refreshFetchedAttr)
+	} else {
+		publicKeyRefreshes.Add(ctx, 1, refreshCoalescedAttr)
+	}
+	return err
 }
 
 // refreshPublicKeysWithCooldown refreshes public keys and updates the unknown kid cooldown.
 // Acquires write Lock.
-func (ks *AWSKeyStore) refreshPublicKeysWithCooldown(ctx context.Context) error {
-	publicKeys, err := loadPublicKeysFromSSM(ctx, ks.ssm)
-	if err != nil {
-		return fmt.Errorf("loading public keys from SSM: %w", err)
+func (ks *AWSKeyStore) refreshPublicKeysWithCooldown(ctx context.Context, seen time.Time) error {
+	if err := ks.refreshPublicKeys(ctx, seen); err != nil {
+		return err
 	}
 
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
 
-	ks.publicKeys = publicKeys
-	ks.publicKeysLoadedAt = ks.clock.Now()
 	ks.lastUnknownKidRefresh = ks.clock.Now()
 	return nil
 }
 
-// loadPublicKeysFromSSM fetches all public key parameters under the SSM path prefix
-// and parses each into an *rsa.PublicKey. The key ID is derived from the parameter
-// name by trimming the path prefix.
-func loadPublicKeysFromSSM(ctx context.Context, client ssmClient) (map[string]*rsa.PublicKey, error) {
-	output, err := client.GetParametersByPath(ctx, &awsssm.GetParametersByPathInput{
-		Path:      aws.String(ssmPublicKeysPathPrefix),
-		Recursive: aws.Bool(true),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("GetParametersByPath %q: %w", ssmPublicKeysPathPrefix, err)
+// loadPublicKeysFromSSM fetches all public key parameters under the SSM path prefix,
+// following NextToken across pages, and parses each into an *rsa.PublicKey. The key
+// ID is derived from the parameter name by trimming the path prefix. Pages are
+// fetched in order since each token comes from the previous page; a throttled page
+// is retried with backoff.
+func loadPublicKeysFromSSM(ctx context.Context, client ssmClient, backoff time.Duration) (map[string]*rsa.PublicKey, error) {
+	publicKeys := make(map[string]*rsa.PublicKey)
+	var nextToken *string
+	for {
+		var output *awsssm.GetParametersByPathOutput
+		err := retryThrottled(ctx, backoff, func() error {
+			var err error
+			output, err = client.GetParametersByPath(ctx, &awsssm.GetParametersByPathInput{
+				Path:       aws.String(ssmPublicKeysPathPrefix),
+				Recursive:  aws.Bool(true),
+				MaxResults: aws.Int32(ssmMaxResultsPerPage),
+				NextToken:  nextToken,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("GetParametersByPath %q: %w", ssmPublicKeysPathPrefix, err)
+		}
+
+		for _, param := range output.Parameters {
+			if param.Name == nil || param.Value == nil {
+				continue
+			}
+			kid := strings.TrimPrefix(*param.Name, ssmPublicKeysPathPrefix)
+			pk, err := parseRSAPublicKey(*param.Value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing public key for kid %q: %w", kid, err)
+			}
+			publicKeys[kid] = pk
+		}
+
+		if aws.ToString(output.NextToken) == "" {
+			return publicKeys, nil
+		}
+		nextToken = output.NextToken
 	}
+}
 
-	publicKeys := make(map[string]*rsa.PublicKey, len(output.Parameters))
-	for _, param := range output.Parameters {
-		if param.Name == nil || param.Value == nil {
-			continue
+// retryThrottled calls fn until it succeeds, fails with an error other than
+// throttling, or has been throttled throttleAttempts times. The wait between
+// attempts starts at backoff and doubles.
+func retryThrottled(ctx context.Context, backoff time.Duration, fn func() error) error {
+	wait := backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isThrottled(err) || attempt == throttleAttempts {
+			return err
 		}
-		kid := strings.TrimPrefix(*param.Name, ssmPublicKeysPathPrefix)
-		pk, err := parseRSAPublicKey(*param.Value)
-		if err != nil {
-			return nil, fmt.Errorf("parsing public key for kid %q: %w", kid, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
 		}
-		publicKeys[kid] = pk
+		wait *= 2
 	}
+}
 
-	return publicKeys, nil
+// isThrottled reports whether err is an AWS throttling error. SSM and
+// Secrets Manager both report throttling as ThrottlingException.
+func isThrottled(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "ThrottlingException", "TooManyRequestsException", "RequestLimitExceeded":
+		return true
+	}
+	return false
 }
 
 // parseRSAPrivateKey parses a PEM-encoded RSA private key. It supports both
@@ -7,6 +7,8 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	awsssm "github.com/aws/aws-sdk-go-v2/service/ssm"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -174,6 +177,179 @@ func TestNewAWSKeyStore(t *testing.T) {
 	})
 }
 
+func TestAWSKeyStore_KeyIDs(t *testing.T) {
+	// Arrange
+	_, privPEM, pubPEM1 := testKeyPair(t)
+	_, _, pubPEM2 := testKeyPair(t)
+	sm, ssmStub := newValidStubs(t, "key-b", privPEM, pubPEM1)
+	ssmStub.getParametersByPathFn = func(_ context.Context, _ *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+		return &awsssm.GetParametersByPathOutput{
+			Parameters: []ssmtypes.Parameter{
+				{Name: aws.String(ssmPublicKeysPathPrefix + "key-b"), Value: aws.String(pubPEM1)},
+				{Name: aws.String(ssmPublicKeysPathPrefix + "key-a"), Value: aws.String(pubPEM2)},
+			},
+		}, nil
+	}
+	clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	ks, err := NewAWSKeyStore(context.Background(), sm, ssmStub, clock)
+	require.NoError(t, err)
+
+	// Act
+	signing, verification := ks.KeyIDs()
+
+	// Assert
+	assert.Equal(t, "key-b", signing)
+	assert.Equal(t, []string{"key-a", "key-b"}, verification)
+}
+
+func TestLoadPublicKeysFromSSM_Pagination(t *testing.T) {
+	// Arrange: 25 keys served ten to a page, as SSM does.
+	const total = 25
+	_, _, pubPEM := testKeyPair(t)
+	var params []ssmtypes.Parameter
+	for i := range total {
+		params = append(params, ssmtypes.Parameter{
+			Name:  aws.String(fmt.Sprintf("%skey-%02d", ssmPublicKeysPathPrefix, i)),
+			Value: aws.String(pubPEM),
+		})
+	}
+	var tokens []string
+	ssmStub := &stubSSMClient{
+		getParametersByPathFn: func(_ context.Context, in *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+			tokens = append(tokens, aws.ToString(in.NextToken))
+			start := 0
+			if in.NextToken != nil {
+				_, err := fmt.Sscanf(*in.NextToken, "page-%d", &start)
+				require.NoError(t, err)
+			}
+			end := min(start+int(aws.ToInt32(in.MaxResults)), total)
+			out := &awsssm.GetParametersByPathOutput{Parameters: params[start:end]}
+			if end < total {
+				out.NextToken = aws.String(fmt.Sprintf("page-%d", end))
+			}
+			return out, nil
+		},
+	}
+
+	// Act
+	keys, err := loadPublicKeysFromSSM(context.Background(), ssmStub, time.Millisecond)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, keys, total)
+	assert.Contains(t, keys, "key-24")
+	assert.Equal(t, []string{"", "page-10", "page-20"}, tokens)
+}
+
+func TestLoadPublicKeysFromSSM_Throttling(t *testing.T) {
+	_, _, pubPEM := testKeyPair(t)
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+
+	t.Run("retries a throttled page", func(t *testing.T) {
+		// Arrange: the second page is throttled twice.
+		calls := 0
+		ssmStub := &stubSSMClient{
+			getParametersByPathFn: func(_ context.Context, in *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+				calls++
+				if in.NextToken == nil {
+					return &awsssm.GetParametersByPathOutput{
+						Parameters: []ssmtypes.Parameter{{Name: aws.String(ssmPublicKeysPathPrefix + "key-1"), Value: aws.String(pubPEM)}},
+						NextToken:  aws.String("next"),
+					}, nil
+				}
+				if calls <= 3 {
+					return nil, throttled
+				}
+				return &awsssm.GetParametersByPathOutput{
+					Parameters: []ssmtypes.Parameter{{Name: aws.String(ssmPublicKeysPathPrefix + "key-2"), Value: aws.String(pubPEM)}},
+				}, nil
+			},
+		}
+
+		// Act
+		keys, err := loadPublicKeysFromSSM(context.Background(), ssmStub, time.Millisecond)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("gives up after the attempt limit", func(t *testing.T) {
+		// Arrange
+		ssmStub := &stubSSMClient{
+			getParametersByPathFn: func(_ context.Context, _ *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+				return nil, throttled
+			},
+		}
+
+		// Act
+		_, err := loadPublicKeysFromSSM(context.Background(), ssmStub, time.Millisecond)
+
+		// Assert
+		require.ErrorIs(t, err, throttled)
+		assert.Equal(t, throttleAttempts, ssmStub.getParametersByPathCallCount)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		// Arrange
+		ssmStub := &stubSSMClient{
+			getParametersByPathFn: func(_ context.Context, _ *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+				return nil, &smithy.GenericAPIError{Code: "AccessDeniedException"}
+			},
+		}
+
+		// Act
+		_, err := loadPublicKeysFromSSM(context.Background(), ssmStub, time.Millisecond)
+
+		// Assert
+		require.Error(t, err)
+		assert.Equal(t, 1, ssmStub.getParametersByPathCallCount)
+	})
+
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		ssmStub := &stubSSMClient{
+			getParametersByPathFn: func(_ context.Context, _ *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+				cancel()
+				return nil, throttled
+			},
+		}
+
+		// Act
+		_, err := loadPublicKeysFromSSM(ctx, ssmStub, time.Hour)
+
+		// Assert
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, ssmStub.getParametersByPathCallCount)
+	})
+}
+
+func TestNewAWSKeyStore_RetriesThrottledSigningKey(t *testing.T) {
+	// Arrange
+	expectedKey, privPEM, pubPEM := testKeyPair(t)
+	sm, ssmStub := newValidStubs(t, "key-1", privPEM, pubPEM)
+	valid := sm.getSecretValueFn
+	calls := 0
+	sm.getSecretValueFn = func(ctx context.Context, in *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+		calls++
+		if calls == 1 {
+			return nil, &smithy.GenericAPIError{Code: "ThrottlingException"}
+		}
+		return valid(ctx, in, optFns...)
+	}
+	clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+
+	// Act
+	ks, err := NewAWSKeyStore(context.Background(), sm, ssmStub, clock)
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, expectedKey.Equal(ks.privateKey))
+	assert.Equal(t, 2, calls)
+}
+
 func TestNewAWSKeyStore_Errors(t *testing.T) {
 	_, validPrivPEM, _ := testKeyPair(t)
 
@@ -569,6 +745,132 @@ func TestAWSKeyStore_PublicKey(t *testing.T) {
 	})
 }
 
+func TestAWSKeyStore_PublicKey_ConcurrentRefresh(t *testing.T) {
+	// Arrange
+	expectedKey, privPEM, pubPEM := testKeyPair(t)
+	keyID := "key-001"
+	sm, ssmStub := newValidStubs(t, keyID, privPEM, pubPEM)
+	clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	ks, err := NewAWSKeyStore(context.Background(), sm, ssmStub, clock)
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := ssmStub.getParametersByPathFn
+	ssmStub.getParametersByPathFn = func(ctx context.Context, in *awsssm.GetParametersByPathInput, optFns ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+		calls.Add(1)
+		<-release
+		return load(ctx, in, optFns...)
+	}
+	clock.Advance(301 * time.Second)
+
+	// Act: every caller finds the cache expired at once.
+	var wg sync.WaitGroup
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pk, err := ks.PublicKey(keyID)
+			assert.NoError(t, err)
+			assert.True(t, expectedKey.PublicKey.Equal(pk))
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Assert
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestAWSKeyStore_PublicKey_StaleWhileRevalidate(t *testing.T) {
+	// newStore returns a key store whose SSM path load fails while down is
+	// set, and serves newPubPEM as key-002 once it recovers.
+	newStore := func(t *testing.T) (*AWSKeyStore, *domaintest.FakeClock, *atomic.Bool, *atomic.Int32, *rsa.PrivateKey) {
+		t.Helper()
+		_, privPEM, pubPEM := testKeyPair(t)
+		newKey, _, newPubPEM := testKeyPair(t)
+		sm, ssmStub := newValidStubs(t, "key-001", privPEM, pubPEM)
+		clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+		ks, err := NewAWSKeyStore(context.Background(), sm, ssmStub, clock)
+		require.NoError(t, err)
+		ks.staleRetryBackoff = time.Millisecond
+
+		var down atomic.Bool
+		var calls atomic.Int32
+		ssmStub.getParametersByPathFn = func(_ context.Context, _ *awsssm.GetParametersByPathInput, _ ...func(*awsssm.Options)) (*awsssm.GetParametersByPathOutput, error) {
+			calls.Add(1)
+			if down.Load() {
+				return nil, fmt.Errorf("ssm unavailable")
+			}
+			return &awsssm.GetParametersByPathOutput{
+				Parameters: []ssmtypes.Parameter{
+					{Name: aws.String(ssmPublicKeysPathPrefix + "key-001"), Value: aws.String(pubPEM)},
+					{Name: aws.String(ssmPublicKeysPathPrefix + "key-002"), Value: aws.String(newPubPEM)},
+				},
+			}, nil
+		}
+		return ks, clock, &down, &calls, newKey
+	}
+
+	t.Run("serves expired keys while SSM is down and recovers in the background", func(t *testing.T) {
+		// Arrange
+		ks, clock, down, _, newKey := newStore(t)
+		down.Store(true)
+		clock.Advance(301 * time.Second)
+
+		// Act
+		pk, err := ks.PublicKey("key-001")
+
+		// Assert
+		require.NoError(t, err)
+		assert.NotNil(t, pk)
+
+		down.Store(false)
+		require.Eventually(t, func() bool { return !ks.revalidating.Load() }, time.Second, time.Millisecond)
+		pk2, err := ks.PublicKey("key-002")
+		require.NoError(t, err)
+		assert.True(t, newKey.PublicKey.Equal(pk2))
+	})
+
+	t.Run("does not refresh inline while revalidating", func(t *testing.T) {
+		// Arrange
+		ks, clock, down, calls, _ := newStore(t)
+		ks.staleRetryBackoff = time.Hour // hold the background retry
+		down.Store(true)
+		clock.Advance(301 * time.Second)
+		_, err := ks.PublicKey("key-001")
+		require.NoError(t, err)
+		require.True(t, ks.revalidating.Load())
+		before := calls.Load()
+
+		// Act
+		for range 10 {
+			_, err = ks.PublicKey("key-001")
+			require.NoError(t, err)
+		}
+
+		// Assert
+		assert.Equal(t, before, calls.Load())
+	})
+
+	t.Run("fails once the cache is too old to serve", func(t *testing.T) {
+		// Arrange
+		ks, clock, down, _, _ := newStore(t)
+		down.Store(true)
+		clock.Advance(defaultStaleMaxAge + time.Second)
+
+		// Act
+		pk, err := ks.PublicKey("key-001")
+
+		// Assert
+		require.Error(t, err)
+		assert.Nil(t, pk)
+		assert.Contains(t, err.Error(), "cache expired")
+		assert.False(t, ks.revalidating.Load())
+	})
+}
+
 func TestParseRSAPrivateKey(t *testing.T) {
 	t.Run("PKCS1 format", func(t *testing.T) {
 		// Arrange
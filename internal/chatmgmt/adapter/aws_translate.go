@@ -0,0 +1,63 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/translate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Compile-time check: AWSTranslator satisfies app.Translator.
+var _ app.Translator = (*AWSTranslator)(nil)
+
+// translateAPI is a narrow, consumer-defined interface for the subset of
+// Amazon Translate operations required by the translator. The real
+// *translate.Client satisfies it.
+type translateAPI interface {
+	TranslateText(ctx context.Context, params *translate.TranslateTextInput, optFns ...func(*translate.Options)) (*translate.TranslateTextOutput, error)
+}
+
+// AWSTranslator translates text with Amazon Translate.
+type AWSTranslator struct {
+	client translateAPI
+}
+
+// NewAWSTranslator creates an AWSTranslator.
+func NewAWSTranslator(client translateAPI) *AWSTranslator {
+	return &AWSTranslator{client: client}
+}
+
+// Translate translates text into targetLanguage, letting the service
+// detect the source language.
+func (t *AWSTranslator) Translate(ctx context.Context, text, targetLanguage string) (domain.Translation, error) {
+	ctx, span := tracer.Start(ctx, "translate.text")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("rpc.system", "aws-api"),
+		attribute.String("rpc.service", "Translate"),
+		attribute.String("rpc.method", "TranslateText"),
+	)
+
+	out, err := t.client.TranslateText(ctx, &translate.TranslateTextInput{
+		Text:               aws.String(text),
+		SourceLanguageCode: aws.String("auto"),
+		TargetLanguageCode: aws.String(targetLanguage),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, fmt.Errorf("aws translate: translate text: %w", err)
+	}
+
+	return domain.Translation{
+		Text:           aws.ToString(out.TranslatedText),
+		SourceLanguage: aws.ToString(out.SourceLanguageCode),
+		TargetLanguage: aws.ToString(out.TargetLanguageCode),
+	}, nil
+}
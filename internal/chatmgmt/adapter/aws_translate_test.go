@@ -0,0 +1,57 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/translate"
+	translatetypes "github.com/aws/aws-sdk-go-v2/service/translate/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// recordingTranslateAPI records the last TranslateText input and fails
+// with err.
+type recordingTranslateAPI struct {
+	input *translate.TranslateTextInput
+	err   error
+}
+
+func (a *recordingTranslateAPI) TranslateText(_ context.Context, in *translate.TranslateTextInput, _ ...func(*translate.Options)) (*translate.TranslateTextOutput, error) {
+	a.input = in
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &translate.TranslateTextOutput{
+		TranslatedText:     aws.String("hola"),
+		SourceLanguageCode: aws.String("en"),
+		TargetLanguageCode: aws.String("es"),
+	}, nil
+}
+
+func TestAWSTranslator_Translate(t *testing.T) {
+	t.Run("detects the source language", func(t *testing.T) {
+		api := &recordingTranslateAPI{}
+
+		tr, err := NewAWSTranslator(api).Translate(context.Background(), "hello", "es")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.Translation{Text: "hola", SourceLanguage: "en", TargetLanguage: "es"}, tr)
+		assert.Equal(t, "hello", aws.ToString(api.input.Text))
+		assert.Equal(t, "auto", aws.ToString(api.input.SourceLanguageCode))
+		assert.Equal(t, "es", aws.ToString(api.input.TargetLanguageCode))
+	})
+
+	t.Run("surfaces service errors", func(t *testing.T) {
+		apiErr := &translatetypes.UnsupportedLanguagePairException{Message: aws.String("no")}
+
+		_, err := NewAWSTranslator(&recordingTranslateAPI{err: apiErr}).Translate(context.Background(), "hello", "xx")
+
+		var unsupported *translatetypes.UnsupportedLanguagePairException
+		require.ErrorAs(t, err, &unsupported)
+		assert.Contains(t, err.Error(), "aws translate: translate text:")
+	})
+}
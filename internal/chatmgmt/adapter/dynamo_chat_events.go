@@ -0,0 +1,319 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: ChatEventStore satisfies app.ChatEventStore.
+var _ app.ChatEventStore = (*ChatEventStore)(nil)
+
+// chatEventDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the chat event store.
+type chatEventDynamoDB interface {
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error)
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+}
+
+// chatEventItem is a chat_events item (PK chat_id, SK event_id). A chat's
+// events form one item collection that sorts by time.
+type chatEventItem struct {
+	ChatID          string             `dynamodbav:"chat_id"`
+	EventID         string             `dynamodbav:"event_id"`
+	Type            string             `dynamodbav:"type"`
+	ActorID         string             `dynamodbav:"actor_id,omitempty"`
+	OccurredAt      string             `dynamodbav:"occurred_at"`
+	Settings        *chatEventSettings `dynamodbav:"settings,omitempty"`
+	SettingsVersion int64              `dynamodbav:"settings_version,omitempty"`
+	UserID          string             `dynamodbav:"user_id,omitempty"`
+	Role            string             `dynamodbav:"role,omitempty"`
+	SuccessorID     string             `dynamodbav:"successor_id,omitempty"`
+	Members         map[string]string  `dynamodbav:"members,omitempty"`
+}
+
+// chatEventSettings is the settings map of a chat_events item.
+type chatEventSettings struct {
+	Name             string `dynamodbav:"name"`
+	AvatarURL        string `dynamodbav:"avatar_url,omitempty"`
+	Description      string `dynamodbav:"description,omitempty"`
+	WhoCanPost       string `dynamodbav:"who_can_post"`
+	WhoCanAddMembers string `dynamodbav:"who_can_add_members"`
+	SlowModeSeconds  int    `dynamodbav:"slow_mode_seconds,omitempty"`
+}
+
+func toChatEventItem(e domain.ChatEvent) chatEventItem {
+	item := chatEventItem{
+		ChatID:          e.ChatID,
+		EventID:         e.EventID,
+		Type:            string(e.Type),
+		ActorID:         e.ActorID,
+		OccurredAt:      e.At.UTC().Format(time.RFC3339),
+		SettingsVersion: e.SettingsVersion,
+		UserID:          e.UserID,
+		Role:            string(e.Role),
+		SuccessorID:     e.SuccessorID,
+	}
+	if s := e.Settings; s != nil {
+		item.Settings = &chatEventSettings{
+			Name:             s.Name,
+			AvatarURL:        s.AvatarURL,
+			Description:      s.Description,
+			WhoCanPost:       string(s.WhoCanPost),
+			WhoCanAddMembers: string(s.WhoCanAddMembers),
+			SlowModeSeconds:  s.SlowModeSeconds,
+		}
+	}
+	if e.Type == domain.ChatEventSnapshot {
+		item.Members = make(map[string]string, len(e.Members))
+		for userID, role := range e.Members {
+			item.Members[userID] = string(role)
+		}
+	}
+	return item
+}
+
+func fromChatEventItem(item chatEventItem) domain.ChatEvent {
+	// occurred_at is informational; the event ID carries the time too.
+	at, _ := time.Parse(time.RFC3339, item.OccurredAt)
+	e := domain.ChatEvent{
+		ChatID:          item.ChatID,
+		EventID:         item.EventID,
+		Type:            domain.ChatEventType(item.Type),
+		ActorID:         item.ActorID,
+		At:              at,
+		SettingsVersion: item.SettingsVersion,
+		UserID:          item.UserID,
+		Role:            domain.MemberRole(item.Role),
+		SuccessorID:     item.SuccessorID,
+	}
+	if s := item.Settings; s != nil {
+		e.Settings = &domain.ChatSettings{
+			Name:             s.Name,
+			AvatarURL:        s.AvatarURL,
+			Description:      s.Description,
+			WhoCanPost:       domain.PermissionPolicy(s.WhoCanPost),
+			WhoCanAddMembers: domain.PermissionPolicy(s.WhoCanAddMembers),
+			SlowModeSeconds:  s.SlowModeSeconds,
+		}
+	}
+	if item.Members != nil {
+		e.Members = make(map[string]domain.MemberRole, len(item.Members))
+		for userID, role := range item.Members {
+			e.Members[userID] = domain.MemberRole(role)
+		}
+	}
+	return e
+}
+
+// newChatEvent returns an event of type typ for chatID stamped with at and
+// a fresh event ID.
+func newChatEvent(chatID string, typ domain.ChatEventType, actorID string, at time.Time) domain.ChatEvent {
+	return domain.ChatEvent{
+		ChatID:  chatID,
+		EventID: domain.NewChatEventID(at),
+		Type:    typ,
+		ActorID: actorID,
+		At:      at,
+	}
+}
+
+// buildChatEventPut creates a TransactWriteItem that appends e to the
+// event log in tableName. Stores add it to the transaction that makes the
+// change, so the log never misses or invents one.
+func buildChatEventPut(tableName *string, e domain.ChatEvent) dynamo.TransactWriteItem {
+	condExpr := "attribute_not_exists(event_id)"
+	item, _ := dynamo.MarshalMap(toChatEventItem(e))
+	return dynamo.TransactWriteItem{
+		Put: &dynamo.Put{
+			TableName:           tableName,
+			Item:                item,
+			ConditionExpression: &condExpr,
+		},
+	}
+}
+
+// ChatEventStore reads and compacts the chat_events table. Events are
+// appended by the stores that make the changes they record.
+type ChatEventStore struct {
+	db        chatEventDynamoDB
+	tableName string
+}
+
+// NewChatEventStore creates a ChatEventStore backed by the given DynamoDB
+// client.
+func NewChatEventStore(db chatEventDynamoDB, tableName string) *ChatEventStore {
+	return &ChatEventStore{db: db, tableName: tableName}
+}
+
+// ListChatEvents returns chatID's events in log order, up to and including
+// those made at until; a zero until returns them all. The query is
+// strongly consistent so compaction folds every event it lists.
+func (s *ChatEventStore) ListChatEvents(ctx context.Context, chatID string, until time.Time) ([]domain.ChatEvent, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_events.list")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "chat_id = :cid"
+	values := map[string]dynamo.AttributeValue{
+		":cid": &dynamo.AttributeValueMemberS{Value: chatID},
+	}
+	if !until.IsZero() {
+		keyExpr += " AND event_id <= :until"
+		values[":until"] = &dynamo.AttributeValueMemberS{Value: domain.LastChatEventID(until)}
+	}
+	consistentRead := true
+	input := &dynamo.QueryInput{
+		TableName:                 &s.tableName,
+		KeyConditionExpression:    &keyExpr,
+		ExpressionAttributeValues: values,
+		ConsistentRead:            &consistentRead,
+	}
+
+	var events []domain.ChatEvent
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("chat event store: list: %w", err)
+		}
+		out, err := s.db.Query(ctx, input)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("chat event store: list: %w", err)
+		}
+		for _, av := range out.Items {
+			var item chatEventItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("chat event store: unmarshal event: %w", err)
+			}
+			events = append(events, fromChatEventItem(item))
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return events, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// ListCompactableChats scans for chats with events other than a snapshot
+// made before cutoff, and returns each chat once. It reads the whole
+// table, so it is meant for the periodic compactor only.
+func (s *ChatEventStore) ListCompactableChats(ctx context.Context, cutoff time.Time) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_events.list_compactable")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Scan"),
+	)
+
+	filter := "event_id <= :cutoff AND #type <> :snapshot"
+	projection := "chat_id"
+	input := &dynamo.ScanInput{
+		TableName:            &s.tableName,
+		FilterExpression:     &filter,
+		ProjectionExpression: &projection,
+		// type is a DynamoDB reserved word.
+		ExpressionAttributeNames: map[string]string{"#type": "type"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":cutoff":   &dynamo.AttributeValueMemberS{Value: domain.LastChatEventID(cutoff)},
+			":snapshot": &dynamo.AttributeValueMemberS{Value: string(domain.ChatEventSnapshot)},
+		},
+	}
+
+	var (
+		chats []string
+		seen  = make(map[string]bool)
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("chat event store: list compactable: %w", err)
+		}
+		out, err := s.db.Scan(ctx, input)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("chat event store: list compactable: %w", err)
+		}
+		for _, av := range out.Items {
+			var item chatEventItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("chat event store: unmarshal event: %w", err)
+			}
+			if !seen[item.ChatID] {
+				seen[item.ChatID] = true
+				chats = append(chats, item.ChatID)
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return chats, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// CompactChatEvents replaces folded, a prefix of a chat's log, with
+// snapshot. The snapshot overwrites the last folded event, which shares
+// its ID, and the others are deleted after it. A compaction interrupted
+// part way leaves events before the snapshot that the snapshot already
+// accounts for; replay discards them and the next run deletes them.
+// Returns domain.ErrVersionConflict if another compactor removed the last
+// folded event first.
+func (s *ChatEventStore) CompactChatEvents(ctx context.Context, snapshot domain.ChatEvent, folded []domain.ChatEvent) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_events.compact")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+		attribute.Int("chat_events.folded", len(folded)),
+	)
+
+	condExpr := "attribute_exists(event_id)"
+	item, _ := dynamo.MarshalMap(toChatEventItem(snapshot))
+	_, err := s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &s.tableName,
+		Item:                item,
+		ConditionExpression: &condExpr,
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("chat event store: compact: %w", domain.ErrVersionConflict)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("chat event store: compact: %w", err)
+	}
+
+	for _, e := range folded {
+		if e.EventID == snapshot.EventID {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("chat event store: compact: %w", err)
+		}
+		_, err := s.db.DeleteItem(ctx, &dynamo.DeleteItemInput{
+			TableName: &s.tableName,
+			Key: map[string]dynamo.AttributeValue{
+				"chat_id":  &dynamo.AttributeValueMemberS{Value: snapshot.ChatID},
+				"event_id": &dynamo.AttributeValueMemberS{Value: e.EventID},
+			},
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("chat event store: compact: delete event %s: %w", e.EventID, err)
+		}
+	}
+	return nil
+}
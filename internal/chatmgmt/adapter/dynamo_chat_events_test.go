@@ -0,0 +1,224 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements chatEventDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubChatEventDynamo struct {
+	queryFn      func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	scanFn       func(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error)
+	putItemFn    func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	deleteItemFn func(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+}
+
+func (s *stubChatEventDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+func (s *stubChatEventDynamo) Scan(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+	return s.scanFn(ctx, params, optFns...)
+}
+
+func (s *stubChatEventDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+func (s *stubChatEventDynamo) DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+	return s.deleteItemFn(ctx, params, optFns...)
+}
+
+var _ chatEventDynamoDB = (*stubChatEventDynamo)(nil)
+
+// chatEventAV decodes the event a store appended in a transaction.
+func chatEventAV(t *testing.T, item dynamo.TransactWriteItem) chatEventItem {
+	t.Helper()
+	require.NotNil(t, item.Put)
+	assert.Equal(t, "chat_events", *item.Put.TableName)
+	assert.Equal(t, "attribute_not_exists(event_id)", *item.Put.ConditionExpression)
+	var event chatEventItem
+	require.NoError(t, dynamo.UnmarshalMap(item.Put.Item, &event))
+	assert.Len(t, event.EventID, 26)
+	return event
+}
+
+// ---------------------------------------------------------------------------
+// Tests — item mapping
+// ---------------------------------------------------------------------------
+
+func TestChatEventItem_RoundTrip(t *testing.T) {
+	settings := domain.DefaultChatSettings("Team")
+	settings.SlowModeSeconds = 30
+	events := []domain.ChatEvent{
+		{
+			ChatID: "chat-001", EventID: "01J0000000000000000000000A", Type: domain.ChatEventSettingsChanged,
+			ActorID: "user-001", At: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+			Settings: &settings, SettingsVersion: 4,
+		},
+		{
+			ChatID: "chat-001", EventID: "01J0000000000000000000000B", Type: domain.ChatEventSnapshot,
+			At:       time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+			Settings: &settings, SettingsVersion: 4,
+			Members: map[string]domain.MemberRole{"user-001": domain.MemberRoleOwner},
+		},
+	}
+
+	for _, e := range events {
+		av, err := dynamo.MarshalMap(toChatEventItem(e))
+		require.NoError(t, err)
+		var item chatEventItem
+		require.NoError(t, dynamo.UnmarshalMap(av, &item))
+
+		assert.Equal(t, e, fromChatEventItem(item))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ListChatEvents
+// ---------------------------------------------------------------------------
+
+func TestChatEventStore_ListChatEvents(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("follows pages up to a point in time", func(t *testing.T) {
+		var calls int
+		store := NewChatEventStore(&stubChatEventDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				calls++
+				assert.Equal(t, "chat_id = :cid AND event_id <= :until", *params.KeyConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: domain.LastChatEventID(at)}, params.ExpressionAttributeValues[":until"])
+				assert.True(t, *params.ConsistentRead)
+				item, err := dynamo.MarshalMap(chatEventItem{ChatID: "chat-001", EventID: "01", Type: "member_removed", UserID: "user-00" + string(rune('0'+calls))})
+				require.NoError(t, err)
+				if calls == 1 {
+					return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item}, LastEvaluatedKey: item}, nil
+				}
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item}}, nil
+			},
+		}, "chat_events")
+
+		events, err := store.ListChatEvents(ctx, "chat-001", at)
+
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, "user-002", events[1].UserID)
+	})
+
+	t.Run("zero time reads the whole log", func(t *testing.T) {
+		store := NewChatEventStore(&stubChatEventDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				assert.Equal(t, "chat_id = :cid", *params.KeyConditionExpression)
+				return &dynamo.QueryOutput{}, nil
+			},
+		}, "chat_events")
+
+		events, err := store.ListChatEvents(ctx, "chat-001", time.Time{})
+
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewChatEventStore(&stubChatEventDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_events")
+
+		_, err := store.ListChatEvents(ctx, "chat-001", at)
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ListCompactableChats
+// ---------------------------------------------------------------------------
+
+func TestChatEventStore_ListCompactableChats(t *testing.T) {
+	cutoff := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	store := NewChatEventStore(&stubChatEventDynamo{
+		scanFn: func(_ context.Context, params *dynamo.ScanInput, _ ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+			assert.Equal(t, "event_id <= :cutoff AND #type <> :snapshot", *params.FilterExpression)
+			assert.Equal(t, &dynamo.AttributeValueMemberS{Value: domain.LastChatEventID(cutoff)}, params.ExpressionAttributeValues[":cutoff"])
+			chat := func(id string) map[string]dynamo.AttributeValue {
+				return map[string]dynamo.AttributeValue{"chat_id": &dynamo.AttributeValueMemberS{Value: id}}
+			}
+			if params.ExclusiveStartKey == nil {
+				return &dynamo.ScanOutput{Items: []map[string]dynamo.AttributeValue{chat("chat-001"), chat("chat-001")}, LastEvaluatedKey: chat("chat-001")}, nil
+			}
+			return &dynamo.ScanOutput{Items: []map[string]dynamo.AttributeValue{chat("chat-001"), chat("chat-002")}}, nil
+		},
+	}, "chat_events")
+
+	chats, err := store.ListCompactableChats(context.Background(), cutoff)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"chat-001", "chat-002"}, chats)
+}
+
+// ---------------------------------------------------------------------------
+// Tests — CompactChatEvents
+// ---------------------------------------------------------------------------
+
+func TestChatEventStore_CompactChatEvents(t *testing.T) {
+	ctx := context.Background()
+	folded := []domain.ChatEvent{{EventID: "01"}, {EventID: "02"}, {EventID: "03"}}
+	snapshot := domain.ChatEvent{ChatID: "chat-001", EventID: "03", Type: domain.ChatEventSnapshot}
+
+	t.Run("overwrites the last event and deletes the rest", func(t *testing.T) {
+		var deleted []string
+		store := NewChatEventStore(&stubChatEventDynamo{
+			putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				assert.Equal(t, "attribute_exists(event_id)", *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "03"}, params.Item["event_id"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "snapshot"}, params.Item["type"])
+				assert.Empty(t, deleted, "snapshot is written before anything is deleted")
+				return &dynamo.PutItemOutput{}, nil
+			},
+			deleteItemFn: func(_ context.Context, params *dynamo.DeleteItemInput, _ ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+				deleted = append(deleted, params.Key["event_id"].(*dynamo.AttributeValueMemberS).Value)
+				return &dynamo.DeleteItemOutput{}, nil
+			},
+		}, "chat_events")
+
+		require.NoError(t, store.CompactChatEvents(ctx, snapshot, folded))
+		assert.Equal(t, []string{"01", "02"}, deleted)
+	})
+
+	t.Run("already compacted", func(t *testing.T) {
+		store := NewChatEventStore(&stubChatEventDynamo{
+			putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "chat_events")
+
+		assert.ErrorIs(t, store.CompactChatEvents(ctx, snapshot, folded), domain.ErrVersionConflict)
+	})
+
+	t.Run("delete failure", func(t *testing.T) {
+		store := NewChatEventStore(&stubChatEventDynamo{
+			putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return &dynamo.PutItemOutput{}, nil
+			},
+			deleteItemFn: func(context.Context, *dynamo.DeleteItemInput, ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_events")
+
+		assert.ErrorContains(t, store.CompactChatEvents(ctx, snapshot, folded), "throttled")
+	})
+}
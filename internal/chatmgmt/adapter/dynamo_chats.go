@@ -0,0 +1,328 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time checks.
+var (
+	_ app.ChatSettingsStore = (*ChatSettingsStore)(nil)
+	_ app.MemberRoleReader  = (*MemberRoleStore)(nil)
+	_ app.ChatAdminLister   = (*MemberRoleStore)(nil)
+	_ app.MembershipChecker = (*MemberRoleStore)(nil)
+)
+
+// chatDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the chat settings and member role stores.
+type chatDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// chatSettingsItem is the settings projection of a chats table item (PK
+// chat_id). Chats written before settings existed have no
+// settings_version and no policies; they read as version 1 with the
+// defaults.
+type chatSettingsItem struct {
+	ChatID           string `dynamodbav:"chat_id"`
+	ChatType         string `dynamodbav:"chat_type"`
+	Name             string `dynamodbav:"name"`
+	AvatarURL        string `dynamodbav:"avatar_url,omitempty"`
+	Description      string `dynamodbav:"description,omitempty"`
+	WhoCanPost       string `dynamodbav:"who_can_post,omitempty"`
+	WhoCanAddMembers string `dynamodbav:"who_can_add_members,omitempty"`
+	SlowModeSeconds  int    `dynamodbav:"slow_mode_seconds,omitempty"`
+	SettingsVersion  int64  `dynamodbav:"settings_version,omitempty"`
+	UpdatedAt        string `dynamodbav:"updated_at"`
+}
+
+// ChatSettingsStore reads and conditionally updates the settings
+// attributes of chats table items. Other chat attributes are untouched.
+// Every update appends a settings_changed event to the chat event log.
+type ChatSettingsStore struct {
+	db          chatDynamoDB
+	tableName   string
+	eventsTable string
+}
+
+// NewChatSettingsStore creates a ChatSettingsStore backed by the given
+// DynamoDB client.
+func NewChatSettingsStore(db chatDynamoDB, tableName, eventsTable string) *ChatSettingsStore {
+	return &ChatSettingsStore{db: db, tableName: tableName, eventsTable: eventsTable}
+}
+
+// GetChatSettings reads a chat's settings with a strongly consistent read,
+// so the version it returns is current for the conditional write that
+// follows. Returns domain.ErrNotFound for an unknown chat.
+func (s *ChatSettingsStore) GetChatSettings(ctx context.Context, chatID string) (app.ChatSettingsRecord, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chats.get_settings")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	consistentRead := true
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+		ConsistentRead: &consistentRead,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.ChatSettingsRecord{}, fmt.Errorf("chat settings store: get: %w", err)
+	}
+	if out.Item == nil {
+		return app.ChatSettingsRecord{}, fmt.Errorf("chat settings store: get: %w", domain.ErrNotFound)
+	}
+
+	var item chatSettingsItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.ChatSettingsRecord{}, fmt.Errorf("chat settings store: unmarshal chat: %w", err)
+	}
+
+	settings := domain.DefaultChatSettings(item.Name)
+	settings.AvatarURL = item.AvatarURL
+	settings.Description = item.Description
+	settings.SlowModeSeconds = item.SlowModeSeconds
+	if item.WhoCanPost != "" {
+		settings.WhoCanPost = domain.PermissionPolicy(item.WhoCanPost)
+	}
+	if item.WhoCanAddMembers != "" {
+		settings.WhoCanAddMembers = domain.PermissionPolicy(item.WhoCanAddMembers)
+	}
+	version := item.SettingsVersion
+	if version == 0 {
+		version = 1
+	}
+	// updated_at is informational; an unparsable value reads as zero.
+	updatedAt, _ := time.Parse(time.RFC3339, item.UpdatedAt)
+
+	return app.ChatSettingsRecord{
+		ChatID:    item.ChatID,
+		ChatType:  domain.ChatType(item.ChatType),
+		Settings:  settings,
+		Version:   version,
+		UpdatedAt: updatedAt,
+	}, nil
+}
+
+// PutChatSettings writes settings as version expectedVersion+1, conditional
+// on the item existing at expectedVersion, in one TransactWriteItems:
+//
+//	[0] settings update on chats
+//	[1] settings_changed event by actorID on chat_events
+//
+// A failed condition is reported as domain.ErrVersionConflict.
+func (s *ChatSettingsStore) PutChatSettings(
+	ctx context.Context, chatID string, settings domain.ChatSettings, expectedVersion int64, actorID string, updatedAt time.Time,
+) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chats.put_settings")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+		attribute.Int64("chat.settings_version", expectedVersion),
+	)
+
+	update := "SET #name = :name, avatar_url = :avatar, description = :desc, " +
+		"who_can_post = :post, who_can_add_members = :add, slow_mode_seconds = :slow, " +
+		"settings_version = :next, updated_at = :at"
+	// An item without settings_version is at version 1.
+	cond := "attribute_exists(chat_id) AND settings_version = :expected"
+	if expectedVersion == 1 {
+		cond = "attribute_exists(chat_id) AND (attribute_not_exists(settings_version) OR settings_version = :expected)"
+	}
+
+	event := newChatEvent(chatID, domain.ChatEventSettingsChanged, actorID, updatedAt)
+	event.Settings, event.SettingsVersion = &settings, expectedVersion+1
+
+	settingsUpdate := dynamo.Update{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+		UpdateExpression:    &update,
+		ConditionExpression: &cond,
+		// name is a DynamoDB reserved word.
+		ExpressionAttributeNames: map[string]string{"#name": "name"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":name":     &dynamo.AttributeValueMemberS{Value: settings.Name},
+			":avatar":   &dynamo.AttributeValueMemberS{Value: settings.AvatarURL},
+			":desc":     &dynamo.AttributeValueMemberS{Value: settings.Description},
+			":post":     &dynamo.AttributeValueMemberS{Value: string(settings.WhoCanPost)},
+			":add":      &dynamo.AttributeValueMemberS{Value: string(settings.WhoCanAddMembers)},
+			":slow":     &dynamo.AttributeValueMemberN{Value: strconv.Itoa(settings.SlowModeSeconds)},
+			":next":     &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion+1, 10)},
+			":expected": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
+			":at":       &dynamo.AttributeValueMemberS{Value: updatedAt.UTC().Format(time.RFC3339)},
+		},
+	}
+	_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{Update: &settingsUpdate},
+			buildChatEventPut(&s.eventsTable, event),
+		},
+	})
+	if err != nil {
+		if reasons, ok := dynamo.IsTransactionCanceledException(err); ok && len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed" {
+			return fmt.Errorf("chat settings store: put: %w", domain.ErrVersionConflict)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("chat settings store: put: %w", err)
+	}
+	return nil
+}
+
+// memberRoleItem is the role projection of a chat_memberships item (PK
+// chat_id, SK user_id). Pending join requests share the table with status
+// set and no role.
+type memberRoleItem struct {
+	Role   string `dynamodbav:"role"`
+	Status string `dynamodbav:"status"`
+}
+
+// MemberRoleStore reads member roles from the chat_memberships table.
+type MemberRoleStore struct {
+	db        chatDynamoDB
+	tableName string
+}
+
+// NewMemberRoleStore creates a MemberRoleStore backed by the given DynamoDB
+// client.
+func NewMemberRoleStore(db chatDynamoDB, tableName string) *MemberRoleStore {
+	return &MemberRoleStore{db: db, tableName: tableName}
+}
+
+// MemberRole returns userID's role in chatID, or domain.ErrNotMember. A
+// pending join request is not a membership. The read is strongly
+// consistent so a just-demoted admin cannot act on a stale role.
+func (s *MemberRoleStore) MemberRole(ctx context.Context, chatID, userID string) (domain.MemberRole, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.get_role")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	consistentRead := true
+	projection := "#role, #status"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ConsistentRead:           &consistentRead,
+		ProjectionExpression:     &projection,
+		ExpressionAttributeNames: map[string]string{"#role": "role", "#status": "status"},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("member role store: get: %w", err)
+	}
+	if out.Item == nil {
+		return "", fmt.Errorf("member role store: get: %w", domain.ErrNotMember)
+	}
+
+	var item memberRoleItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("member role store: unmarshal membership: %w", err)
+	}
+	if item.Status == membershipStatusPending {
+		return "", fmt.Errorf("member role store: get: %w", domain.ErrNotMember)
+	}
+	return domain.MemberRole(item.Role), nil
+}
+
+// IsMember reports whether userID is a member of chatID. A pending join
+// request is not a membership.
+func (s *MemberRoleStore) IsMember(ctx context.Context, chatID, userID string) (bool, error) {
+	_, err := s.MemberRole(ctx, chatID, userID)
+	if errors.Is(err, domain.ErrNotMember) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListAdmins returns the user IDs of chatID's admins and owner. Roles are
+// filtered server-side, so the query reads the whole member partition.
+func (s *MemberRoleStore) ListAdmins(ctx context.Context, chatID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.list_admins")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "chat_id = :cid"
+	filter := "#role IN (:admin, :owner)"
+	projection := "user_id"
+
+	var (
+		admins   []string
+		startKey map[string]dynamo.AttributeValue
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("member role store: list admins: %w", err)
+		}
+
+		out, err := s.db.Query(ctx, &dynamo.QueryInput{
+			TableName:                &s.tableName,
+			KeyConditionExpression:   &keyExpr,
+			FilterExpression:         &filter,
+			ProjectionExpression:     &projection,
+			ExpressionAttributeNames: map[string]string{"#role": "role"},
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":cid":   &dynamo.AttributeValueMemberS{Value: chatID},
+				":admin": &dynamo.AttributeValueMemberS{Value: string(domain.MemberRoleAdmin)},
+				":owner": &dynamo.AttributeValueMemberS{Value: string(domain.MemberRoleOwner)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("member role store: list admins: %w", err)
+		}
+
+		for _, av := range out.Items {
+			var item struct {
+				UserID string `dynamodbav:"user_id"`
+			}
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("member role store: unmarshal membership: %w", err)
+			}
+			admins = append(admins, item.UserID)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return admins, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
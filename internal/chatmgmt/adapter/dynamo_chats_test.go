@@ -0,0 +1,312 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements chatDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubChatDynamo struct {
+	getItemFn  func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	queryFn    func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	transactFn func(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+func (s *stubChatDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubChatDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+func (s *stubChatDynamo) TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	return s.transactFn(ctx, params, optFns...)
+}
+
+var _ chatDynamoDB = (*stubChatDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests — GetChatSettings
+// ---------------------------------------------------------------------------
+
+func TestChatSettingsStore_GetChatSettings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads settings and version", func(t *testing.T) {
+		av, err := dynamo.MarshalMap(chatSettingsItem{
+			ChatID: "chat-001", ChatType: "group", Name: "Team",
+			Description: "hi", WhoCanPost: "admins", WhoCanAddMembers: "everyone",
+			SlowModeSeconds: 30, SettingsVersion: 4, UpdatedAt: "2026-02-10T12:00:00Z",
+		})
+		require.NoError(t, err)
+		store := NewChatSettingsStore(&stubChatDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, "chats", *params.TableName)
+				assert.True(t, *params.ConsistentRead)
+				return &dynamo.GetItemOutput{Item: av}, nil
+			},
+		}, "chats", "chat_events")
+
+		rec, err := store.GetChatSettings(ctx, "chat-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.ChatTypeGroup, rec.ChatType)
+		assert.Equal(t, int64(4), rec.Version)
+		assert.Equal(t, domain.PermissionAdmins, rec.Settings.WhoCanPost)
+		assert.Equal(t, domain.PermissionEveryone, rec.Settings.WhoCanAddMembers)
+		assert.Equal(t, 30, rec.Settings.SlowModeSeconds)
+		assert.Equal(t, time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC), rec.UpdatedAt)
+	})
+
+	t.Run("chat without settings reads as defaults at version 1", func(t *testing.T) {
+		av, err := dynamo.MarshalMap(chatSettingsItem{ChatID: "chat-001", ChatType: "group", Name: "Team"})
+		require.NoError(t, err)
+		store := NewChatSettingsStore(&stubChatDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{Item: av}, nil
+			},
+		}, "chats", "chat_events")
+
+		rec, err := store.GetChatSettings(ctx, "chat-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), rec.Version)
+		assert.Equal(t, domain.DefaultChatSettings("Team"), rec.Settings)
+	})
+
+	t.Run("unknown chat", func(t *testing.T) {
+		store := NewChatSettingsStore(&stubChatDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, "chats", "chat_events")
+
+		_, err := store.GetChatSettings(ctx, "chat-001")
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — PutChatSettings
+// ---------------------------------------------------------------------------
+
+func TestChatSettingsStore_PutChatSettings(t *testing.T) {
+	ctx := context.Background()
+	settings := domain.DefaultChatSettings("Launch")
+	at := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expected  int64
+		dbErr     error
+		wantCond  string
+		wantErr   error
+		errSubstr string
+	}{
+		{
+			name:     "first update accepts a chat without a version",
+			expected: 1,
+			wantCond: "attribute_exists(chat_id) AND (attribute_not_exists(settings_version) OR settings_version = :expected)",
+		},
+		{
+			name:     "later update requires the exact version",
+			expected: 3,
+			wantCond: "attribute_exists(chat_id) AND settings_version = :expected",
+		},
+		{
+			name:     "condition failure is a version conflict",
+			expected: 3,
+			dbErr:    dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None"),
+			wantCond: "attribute_exists(chat_id) AND settings_version = :expected",
+			wantErr:  domain.ErrVersionConflict,
+		},
+		{
+			name:      "other errors are wrapped",
+			expected:  3,
+			dbErr:     errors.New("throttled"),
+			wantCond:  "attribute_exists(chat_id) AND settings_version = :expected",
+			errSubstr: "throttled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []dynamo.TransactWriteItem
+			store := NewChatSettingsStore(&stubChatDynamo{
+				transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+					items = params.TransactItems
+					return &dynamo.TransactWriteItemsOutput{}, tt.dbErr
+				},
+			}, "chats", "chat_events")
+
+			err := store.PutChatSettings(ctx, "chat-001", settings, tt.expected, "user-001", at)
+
+			require.Len(t, items, 2)
+			got := items[0].Update
+			assert.Equal(t, "chats", *got.TableName)
+			assert.Equal(t, tt.wantCond, *got.ConditionExpression)
+			assert.Equal(t, "name", got.ExpressionAttributeNames["#name"])
+			assert.Equal(t, &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(tt.expected+1, 10)}, got.ExpressionAttributeValues[":next"])
+
+			var event chatEventItem
+			require.NoError(t, dynamo.UnmarshalMap(items[1].Put.Item, &event))
+			assert.Equal(t, "chat_events", *items[1].Put.TableName)
+			assert.Equal(t, "settings_changed", event.Type)
+			assert.Equal(t, "user-001", event.ActorID)
+			assert.Equal(t, tt.expected+1, event.SettingsVersion)
+			assert.Equal(t, "Launch", event.Settings.Name)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.errSubstr != "":
+				assert.ErrorContains(t, err, tt.errSubstr)
+				assert.NotErrorIs(t, err, domain.ErrVersionConflict)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tests — MemberRole
+// ---------------------------------------------------------------------------
+
+func TestMemberRoleStore_MemberRole(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the stored role", func(t *testing.T) {
+		store := NewMemberRoleStore(&stubChatDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, "chat_memberships", *params.TableName)
+				assert.Len(t, params.Key, 2)
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"role": &dynamo.AttributeValueMemberS{Value: "admin"},
+				}}, nil
+			},
+		}, "chat_memberships")
+
+		role, err := store.MemberRole(ctx, "chat-001", "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.MemberRoleAdmin, role)
+	})
+
+	t.Run("no membership", func(t *testing.T) {
+		store := NewMemberRoleStore(&stubChatDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, "chat_memberships")
+
+		_, err := store.MemberRole(ctx, "chat-001", "user-001")
+
+		assert.ErrorIs(t, err, domain.ErrNotMember)
+	})
+
+	t.Run("pending join request is not a membership", func(t *testing.T) {
+		store := NewMemberRoleStore(&stubChatDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"status": &dynamo.AttributeValueMemberS{Value: "pending"},
+				}}, nil
+			},
+		}, "chat_memberships")
+
+		_, err := store.MemberRole(ctx, "chat-001", "user-001")
+
+		assert.ErrorIs(t, err, domain.ErrNotMember)
+	})
+}
+
+func TestMemberRoleStore_IsMember(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		item    map[string]dynamo.AttributeValue
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{name: "member", item: map[string]dynamo.AttributeValue{"role": &dynamo.AttributeValueMemberS{Value: "member"}}, want: true},
+		{name: "no membership"},
+		{name: "pending join request", item: map[string]dynamo.AttributeValue{"status": &dynamo.AttributeValueMemberS{Value: "pending"}}},
+		{name: "read failure", err: errors.New("throttled"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemberRoleStore(&stubChatDynamo{
+				getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+					return &dynamo.GetItemOutput{Item: tt.item}, tt.err
+				},
+			}, "chat_memberships")
+
+			ok, err := store.IsMember(ctx, "chat-001", "user-001")
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ListAdmins
+// ---------------------------------------------------------------------------
+
+func TestMemberRoleStore_ListAdmins(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("follows pages", func(t *testing.T) {
+		var calls int
+		store := NewMemberRoleStore(&stubChatDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				calls++
+				assert.Equal(t, "#role IN (:admin, :owner)", *params.FilterExpression)
+				user := func(id string) map[string]dynamo.AttributeValue {
+					return map[string]dynamo.AttributeValue{"user_id": &dynamo.AttributeValueMemberS{Value: id}}
+				}
+				if params.ExclusiveStartKey == nil {
+					return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{user("user-001")}, LastEvaluatedKey: user("user-001")}, nil
+				}
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{user("user-007")}}, nil
+			},
+		}, "chat_memberships")
+
+		admins, err := store.ListAdmins(ctx, "chat-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user-001", "user-007"}, admins)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewMemberRoleStore(&stubChatDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_memberships")
+
+		_, err := store.ListAdmins(ctx, "chat-001")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
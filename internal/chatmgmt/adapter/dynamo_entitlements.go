@@ -0,0 +1,167 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: EntitlementStore satisfies app.EntitlementStore.
+var _ app.EntitlementStore = (*EntitlementStore)(nil)
+
+// entitlementDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the entitlement store.
+type entitlementDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+}
+
+// entitlementItem is an entitlements item (PK subject, as kind#id). Times
+// are RFC 3339 with milliseconds, so event_at compares as a string.
+type entitlementItem struct {
+	Subject            string `dynamodbav:"subject"`
+	SubjectKind        string `dynamodbav:"subject_kind"`
+	SubjectID          string `dynamodbav:"subject_id"`
+	Tier               string `dynamodbav:"tier"`
+	MaxGroupSize       int    `dynamodbav:"max_group_size,omitempty"`
+	MaxAttachmentBytes int64  `dynamodbav:"max_attachment_bytes,omitempty"`
+	PaidUntil          string `dynamodbav:"paid_until,omitempty"`
+	EventID            string `dynamodbav:"event_id"`
+	EventAt            string `dynamodbav:"event_at"`
+	UpdatedAt          string `dynamodbav:"updated_at"`
+}
+
+func toEntitlementItem(e domain.Entitlement) entitlementItem {
+	item := entitlementItem{
+		Subject:            e.Subject.String(),
+		SubjectKind:        string(e.Subject.Kind),
+		SubjectID:          e.Subject.ID,
+		Tier:               string(e.Tier),
+		MaxGroupSize:       e.Limits.MaxGroupSize,
+		MaxAttachmentBytes: e.Limits.MaxAttachmentSize,
+		EventID:            e.EventID,
+		EventAt:            domain.NewTimestampMS(e.EventAt).String(),
+		UpdatedAt:          domain.NewTimestampMS(e.UpdatedAt).String(),
+	}
+	if !e.PaidUntil.IsZero() {
+		item.PaidUntil = domain.NewTimestampMS(e.PaidUntil).String()
+	}
+	return item
+}
+
+func (i entitlementItem) toDomain() (domain.Entitlement, error) {
+	var times [3]time.Time
+	for n, s := range []string{i.PaidUntil, i.EventAt, i.UpdatedAt} {
+		ts, err := domain.ParseTimestamp(s)
+		if err != nil {
+			return domain.Entitlement{}, fmt.Errorf("entitlement %s: %w", i.Subject, err)
+		}
+		times[n] = ts.Time()
+	}
+	return domain.Entitlement{
+		Subject:   domain.EntitlementSubject{Kind: domain.SubjectKind(i.SubjectKind), ID: i.SubjectID},
+		Tier:      domain.Tier(i.Tier),
+		Limits:    domain.Limits{MaxGroupSize: i.MaxGroupSize, MaxAttachmentSize: i.MaxAttachmentBytes},
+		PaidUntil: times[0],
+		EventID:   i.EventID,
+		EventAt:   times[1],
+		UpdatedAt: times[2],
+	}, nil
+}
+
+// EntitlementStore persists billing entitlements in the entitlements
+// table, one item per user or workspace.
+type EntitlementStore struct {
+	db        entitlementDynamoDB
+	tableName string
+}
+
+// NewEntitlementStore creates an EntitlementStore backed by the given
+// DynamoDB client.
+func NewEntitlementStore(db entitlementDynamoDB, tableName string) *EntitlementStore {
+	return &EntitlementStore{db: db, tableName: tableName}
+}
+
+// GetEntitlement reads subject's entitlement with a strongly consistent
+// read, so a limit check right after a webhook sees it. Returns
+// domain.ErrNotFound if there is none.
+func (s *EntitlementStore) GetEntitlement(ctx context.Context, subject domain.EntitlementSubject) (domain.Entitlement, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.entitlements.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	consistentRead := true
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"subject": &dynamo.AttributeValueMemberS{Value: subject.String()},
+		},
+		ConsistentRead: &consistentRead,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Entitlement{}, fmt.Errorf("entitlement store: get: %w", err)
+	}
+	if out.Item == nil {
+		return domain.Entitlement{}, fmt.Errorf("entitlement store: get: %w", domain.ErrNotFound)
+	}
+
+	var item entitlementItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		return domain.Entitlement{}, fmt.Errorf("entitlement store: unmarshal: %w", err)
+	}
+	ent, err := item.toDomain()
+	if err != nil {
+		return domain.Entitlement{}, fmt.Errorf("entitlement store: get: %w", err)
+	}
+	return ent, nil
+}
+
+// PutEntitlement replaces subject's entitlement, conditional on the stored
+// one being from an earlier event. Returns domain.ErrVersionConflict
+// otherwise, including for a redelivery of the stored event.
+func (s *EntitlementStore) PutEntitlement(ctx context.Context, ent domain.Entitlement) error {
+	ctx, span := tracer.Start(ctx, "dynamo.entitlements.put")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	item := toEntitlementItem(ent)
+	av, err := dynamo.MarshalMap(item)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("entitlement store: marshal: %w", err)
+	}
+	cond := "attribute_not_exists(subject) OR event_at < :event_at"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &s.tableName,
+		Item:                av,
+		ConditionExpression: &cond,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":event_at": &dynamo.AttributeValueMemberS{Value: item.EventAt},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("entitlement store: put: %w", domain.ErrVersionConflict)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("entitlement store: put: %w", err)
+	}
+	return nil
+}
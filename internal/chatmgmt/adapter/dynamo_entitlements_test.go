@@ -0,0 +1,89 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements entitlementDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubEntitlementDynamo struct {
+	getItemFn func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	putItemFn func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+}
+
+func (s *stubEntitlementDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubEntitlementDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+var _ entitlementDynamoDB = (*stubEntitlementDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestEntitlementStore_RoundTrip(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	ent := domain.Entitlement{
+		Subject:   domain.EntitlementSubject{Kind: domain.SubjectWorkspace, ID: "ws-001"},
+		Tier:      domain.TierPremium,
+		Limits:    domain.Limits{MaxGroupSize: 5000},
+		PaidUntil: at.Add(30 * 24 * time.Hour),
+		EventID:   "evt_1",
+		EventAt:   at,
+		UpdatedAt: at.Add(time.Second),
+	}
+
+	var stored map[string]dynamo.AttributeValue
+	store := NewEntitlementStore(&stubEntitlementDynamo{
+		putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+			assert.Equal(t, "entitlements", *params.TableName)
+			assert.Equal(t, "attribute_not_exists(subject) OR event_at < :event_at", *params.ConditionExpression)
+			assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-10-15T12:00:00.000Z"}, params.ExpressionAttributeValues[":event_at"])
+			assert.NotContains(t, params.Item, "max_attachment_bytes")
+			stored = params.Item
+			return &dynamo.PutItemOutput{}, nil
+		},
+		getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "workspace#ws-001"}, params.Key["subject"])
+			assert.True(t, *params.ConsistentRead)
+			return &dynamo.GetItemOutput{Item: stored}, nil
+		},
+	}, "entitlements")
+
+	require.NoError(t, store.PutEntitlement(context.Background(), ent))
+	got, err := store.GetEntitlement(context.Background(), ent.Subject)
+
+	require.NoError(t, err)
+	assert.Equal(t, ent, got)
+}
+
+func TestEntitlementStore_Errors(t *testing.T) {
+	store := NewEntitlementStore(&stubEntitlementDynamo{
+		getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			return &dynamo.GetItemOutput{}, nil
+		},
+		putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+			return nil, dynamo.ErrConditionalCheckFailed()
+		},
+	}, "entitlements")
+
+	_, err := store.GetEntitlement(context.Background(), domain.UserSubject("user-001"))
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	err = store.PutEntitlement(context.Background(), domain.Entitlement{Subject: domain.UserSubject("user-001"), Tier: domain.TierFree})
+	assert.ErrorIs(t, err, domain.ErrVersionConflict)
+}
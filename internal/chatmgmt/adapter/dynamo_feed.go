@@ -0,0 +1,230 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: FeedStore satisfies app.FeedStore.
+var _ app.FeedStore = (*FeedStore)(nil)
+
+// feedDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the feed store.
+type feedDynamoDB interface {
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+// feedItem is the DynamoDB item shape for the notifications table (PK
+// user_id, SK notification_id). read_at is absent while unread.
+type feedItem struct {
+	UserID         string            `dynamodbav:"user_id"`
+	NotificationID string            `dynamodbav:"notification_id"`
+	Kind           string            `dynamodbav:"kind"`
+	ChatID         string            `dynamodbav:"chat_id,omitempty"`
+	ActorID        string            `dynamodbav:"actor_id,omitempty"`
+	Params         map[string]string `dynamodbav:"params,omitempty"`
+	CreatedAt      string            `dynamodbav:"created_at"`
+	ReadAt         string            `dynamodbav:"read_at,omitempty"`
+	TTL            int64             `dynamodbav:"ttl"`
+}
+
+// fromFeedItem converts a DynamoDB item to a domain.FeedEntry.
+func fromFeedItem(item feedItem) (domain.FeedEntry, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	if err != nil {
+		return domain.FeedEntry{}, fmt.Errorf("parse created_at: %w", err)
+	}
+	var readAt time.Time
+	if item.ReadAt != "" {
+		if readAt, err = time.Parse(time.RFC3339Nano, item.ReadAt); err != nil {
+			return domain.FeedEntry{}, fmt.Errorf("parse read_at: %w", err)
+		}
+	}
+	return domain.FeedEntry{
+		UserID:    item.UserID,
+		EntryID:   item.NotificationID,
+		Kind:      domain.FeedKind(item.Kind),
+		ChatID:    item.ChatID,
+		ActorID:   item.ActorID,
+		Params:    item.Params,
+		CreatedAt: createdAt,
+		ReadAt:    readAt,
+	}, nil
+}
+
+// FeedStore persists notification feeds in the notifications table.
+type FeedStore struct {
+	db        feedDynamoDB
+	tableName string
+}
+
+// NewFeedStore creates a FeedStore backed by the given DynamoDB client.
+func NewFeedStore(db feedDynamoDB, tableName string) *FeedStore {
+	return &FeedStore{db: db, tableName: tableName}
+}
+
+// PutFeedEntry writes a new entry. DynamoDB TTL removes it
+// domain.FeedRetention after creation.
+func (s *FeedStore) PutFeedEntry(ctx context.Context, entry domain.FeedEntry) error {
+	ctx, span := tracer.Start(ctx, "dynamo.notifications.put")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(feedItem{
+		UserID:         entry.UserID,
+		NotificationID: entry.EntryID,
+		Kind:           string(entry.Kind),
+		ChatID:         entry.ChatID,
+		ActorID:        entry.ActorID,
+		Params:         entry.Params,
+		CreatedAt:      entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		TTL:            entry.CreatedAt.Add(domain.FeedRetention).Unix(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("feed store: marshal entry: %w", err)
+	}
+
+	if _, err := s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName: &s.tableName,
+		Item:      av,
+	}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("feed store: put: %w", err)
+	}
+	return nil
+}
+
+// ListFeedEntries returns up to limit of the user's entries before beforeID,
+// newest first. With unreadOnly, read entries are filtered server-side;
+// because DynamoDB applies Limit before the filter, the query continues
+// until the page is full or the partition is exhausted.
+func (s *FeedStore) ListFeedEntries(
+	ctx context.Context, userID, beforeID string, limit int, unreadOnly bool,
+) ([]domain.FeedEntry, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.notifications.list")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid"
+	values := map[string]dynamo.AttributeValue{
+		":uid": &dynamo.AttributeValueMemberS{Value: userID},
+	}
+	if beforeID != "" {
+		keyExpr += " AND notification_id < :before"
+		values[":before"] = &dynamo.AttributeValueMemberS{Value: beforeID}
+	}
+	var filterExpr *string
+	if unreadOnly {
+		f := "attribute_not_exists(read_at)"
+		filterExpr = &f
+	}
+	scanForward := false
+	pageLimit := int32(limit)
+
+	var (
+		entries  []domain.FeedEntry
+		startKey map[string]dynamo.AttributeValue
+	)
+	for {
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("feed store: list: %w", err)
+		}
+
+		out, err := s.db.Query(ctx, &dynamo.QueryInput{
+			TableName:                 &s.tableName,
+			KeyConditionExpression:    &keyExpr,
+			FilterExpression:          filterExpr,
+			ExpressionAttributeValues: values,
+			ScanIndexForward:          &scanForward,
+			Limit:                     &pageLimit,
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("feed store: list: %w", err)
+		}
+
+		for _, av := range out.Items {
+			var item feedItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("feed store: unmarshal entry: %w", err)
+			}
+			entry, err := fromFeedItem(item)
+			if err != nil {
+				return nil, fmt.Errorf("feed store: %w", err)
+			}
+			entries = append(entries, entry)
+			if len(entries) == limit {
+				return entries, nil
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+// MarkFeedEntriesRead sets read_at on each of the user's entries in ids.
+// The first acknowledgement wins; IDs without an entry are skipped.
+func (s *FeedStore) MarkFeedEntriesRead(ctx context.Context, userID string, ids []string, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.notifications.mark_read")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+		attribute.Int("notifications.count", len(ids)),
+	)
+
+	update := "SET read_at = if_not_exists(read_at, :at)"
+	cond := "attribute_exists(notification_id)"
+	readAt := at.UTC().Format(time.RFC3339Nano)
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("feed store: mark read: %w", err)
+		}
+		_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+			TableName: &s.tableName,
+			Key: map[string]dynamo.AttributeValue{
+				"user_id":         &dynamo.AttributeValueMemberS{Value: userID},
+				"notification_id": &dynamo.AttributeValueMemberS{Value: id},
+			},
+			UpdateExpression:    &update,
+			ConditionExpression: &cond,
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":at": &dynamo.AttributeValueMemberS{Value: readAt},
+			},
+		})
+		if dynamo.IsConditionalCheckFailed(err) {
+			continue
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("feed store: mark read: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — an in-memory notifications table implementing feedDynamoDB. Query
+// applies Limit before the filter, as DynamoDB does.
+// ---------------------------------------------------------------------------
+
+type fakeFeedDynamo struct {
+	items   map[string]feedItem // by notification_id; single user
+	queries int
+	err     error
+}
+
+func (f *fakeFeedDynamo) PutItem(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var item feedItem
+	if err := dynamo.UnmarshalMap(params.Item, &item); err != nil {
+		return nil, err
+	}
+	f.items[item.NotificationID] = item
+	return &dynamo.PutItemOutput{}, nil
+}
+
+func (f *fakeFeedDynamo) Query(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.queries++
+	before := ""
+	if v, ok := params.ExpressionAttributeValues[":before"]; ok {
+		before = v.(*dynamo.AttributeValueMemberS).Value
+	}
+	if params.ExclusiveStartKey != nil {
+		before = params.ExclusiveStartKey["notification_id"].(*dynamo.AttributeValueMemberS).Value
+	}
+
+	ids := make([]string, 0, len(f.items))
+	for id := range f.items {
+		if before == "" || id < before {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int { return strings.Compare(b, a) })
+
+	out := &dynamo.QueryOutput{}
+	for i, id := range ids {
+		if i == int(*params.Limit) {
+			out.LastEvaluatedKey = map[string]dynamo.AttributeValue{
+				"notification_id": &dynamo.AttributeValueMemberS{Value: ids[i-1]},
+			}
+			break
+		}
+		item := f.items[id]
+		if params.FilterExpression != nil && item.ReadAt != "" {
+			continue
+		}
+		av, err := dynamo.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, av)
+	}
+	return out, nil
+}
+
+func (f *fakeFeedDynamo) UpdateItem(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	id := params.Key["notification_id"].(*dynamo.AttributeValueMemberS).Value
+	item, ok := f.items[id]
+	if !ok {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	if item.ReadAt == "" {
+		item.ReadAt = params.ExpressionAttributeValues[":at"].(*dynamo.AttributeValueMemberS).Value
+		f.items[id] = item
+	}
+	return &dynamo.UpdateItemOutput{}, nil
+}
+
+var _ feedDynamoDB = (*fakeFeedDynamo)(nil)
+
+const notificationsTable = "notifications"
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+var feedStart = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+// seedFeed stores n entries for alice one second apart, returning their IDs
+// oldest first.
+func seedFeed(t *testing.T, store *FeedStore, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range n {
+		ids[i] = domain.GenerateFeedEntryID()
+		time.Sleep(time.Millisecond) // distinct UUIDv7 timestamps
+		require.NoError(t, store.PutFeedEntry(context.Background(), domain.FeedEntry{
+			UserID:    "alice",
+			EntryID:   ids[i],
+			Kind:      domain.FeedKindMention,
+			CreatedAt: feedStart.Add(time.Duration(i) * time.Second),
+		}))
+	}
+	return ids
+}
+
+func TestFeedStore_PutFeedEntry(t *testing.T) {
+	db := &fakeFeedDynamo{items: map[string]feedItem{}}
+	store := NewFeedStore(db, notificationsTable)
+
+	require.NoError(t, store.PutFeedEntry(context.Background(), domain.FeedEntry{
+		UserID:    "alice",
+		EntryID:   "n1",
+		Kind:      domain.FeedKindInvite,
+		ChatID:    "chat-1",
+		ActorID:   "bob",
+		Params:    map[string]string{"chat_name": "Work"},
+		CreatedAt: feedStart,
+	}))
+
+	item := db.items["n1"]
+	assert.Equal(t, "invite", item.Kind)
+	assert.Equal(t, map[string]string{"chat_name": "Work"}, item.Params)
+	assert.Empty(t, item.ReadAt)
+	assert.Equal(t, feedStart.Add(domain.FeedRetention).Unix(), item.TTL)
+}
+
+func TestFeedStore_ListFeedEntries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("newest first, before cursor", func(t *testing.T) {
+		store := NewFeedStore(&fakeFeedDynamo{items: map[string]feedItem{}}, notificationsTable)
+		ids := seedFeed(t, store, 3)
+
+		got, err := store.ListFeedEntries(ctx, "alice", ids[2], 10, false)
+
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, ids[1], got[0].EntryID)
+		assert.Equal(t, ids[0], got[1].EntryID)
+		assert.Equal(t, feedStart.Add(time.Second), got[0].CreatedAt)
+		assert.True(t, got[0].Unread())
+	})
+
+	t.Run("unread only fills the page across queries", func(t *testing.T) {
+		db := &fakeFeedDynamo{items: map[string]feedItem{}}
+		store := NewFeedStore(db, notificationsTable)
+		ids := seedFeed(t, store, 4)
+		require.NoError(t, store.MarkFeedEntriesRead(ctx, "alice", []string{ids[3], ids[2]}, feedStart))
+
+		got, err := store.ListFeedEntries(ctx, "alice", "", 2, true)
+
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, ids[1], got[0].EntryID)
+		assert.Equal(t, ids[0], got[1].EntryID)
+		assert.Equal(t, 2, db.queries)
+	})
+
+	t.Run("query failure", func(t *testing.T) {
+		store := NewFeedStore(&fakeFeedDynamo{err: errors.New("throttled")}, notificationsTable)
+
+		_, err := store.ListFeedEntries(ctx, "alice", "", 10, false)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "feed store: list")
+	})
+}
+
+func TestFeedStore_MarkFeedEntriesRead(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("first read time wins, unknown ids skipped", func(t *testing.T) {
+		db := &fakeFeedDynamo{items: map[string]feedItem{}}
+		store := NewFeedStore(db, notificationsTable)
+		ids := seedFeed(t, store, 1)
+
+		require.NoError(t, store.MarkFeedEntriesRead(ctx, "alice", []string{ids[0], "missing"}, feedStart))
+		require.NoError(t, store.MarkFeedEntriesRead(ctx, "alice", ids, feedStart.Add(time.Hour)))
+
+		got, err := store.ListFeedEntries(ctx, "alice", "", 10, false)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, feedStart, got[0].ReadAt)
+		assert.NotContains(t, db.items, "missing")
+	})
+
+	t.Run("update failure", func(t *testing.T) {
+		store := NewFeedStore(&fakeFeedDynamo{err: errors.New("throttled")}, notificationsTable)
+
+		err := store.MarkFeedEntriesRead(ctx, "alice", []string{"n1"}, feedStart)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "feed store: mark read")
+	})
+}
@@ -0,0 +1,292 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: JoinRequestStore satisfies app.JoinRequestStore.
+var _ app.JoinRequestStore = (*JoinRequestStore)(nil)
+
+// membershipStatusPending marks a chat_memberships item as a join request
+// rather than a membership.
+const membershipStatusPending = "pending"
+
+// joinRequestDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the join request store.
+type joinRequestDynamoDB interface {
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// joinRequestItem is a pending chat_memberships item (PK chat_id, SK
+// user_id). It has no role, so role lookups never mistake it for a member.
+// Timestamps are RFC 3339 in UTC so expires_at compares lexically in
+// condition expressions.
+type joinRequestItem struct {
+	ChatID      string `dynamodbav:"chat_id"`
+	UserID      string `dynamodbav:"user_id"`
+	Status      string `dynamodbav:"status"`
+	Note        string `dynamodbav:"note,omitempty"`
+	RequestedAt string `dynamodbav:"requested_at"`
+	ExpiresAt   string `dynamodbav:"expires_at"`
+	TTL         int64  `dynamodbav:"ttl"`
+}
+
+func toJoinRequestItem(r domain.JoinRequest) joinRequestItem {
+	return joinRequestItem{
+		ChatID:      r.ChatID,
+		UserID:      r.UserID,
+		Status:      membershipStatusPending,
+		Note:        r.Note,
+		RequestedAt: r.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:   r.ExpiresAt.UTC().Format(time.RFC3339),
+		TTL:         r.ExpiresAt.Unix(),
+	}
+}
+
+func fromJoinRequestItem(item joinRequestItem) (domain.JoinRequest, error) {
+	createdAt, err := time.Parse(time.RFC3339, item.RequestedAt)
+	if err != nil {
+		return domain.JoinRequest{}, fmt.Errorf("parse requested_at: %w", err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, item.ExpiresAt)
+	if err != nil {
+		return domain.JoinRequest{}, fmt.Errorf("parse expires_at: %w", err)
+	}
+	return domain.JoinRequest{
+		ChatID:    item.ChatID,
+		UserID:    item.UserID,
+		Note:      item.Note,
+		CreatedAt: createdAt,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// JoinRequestStore keeps join requests as pending items in the
+// chat_memberships table. DynamoDB TTL removes expired requests
+// eventually; until then every operation treats them as absent. Approvals
+// append a member_joined event to the chat event log.
+type JoinRequestStore struct {
+	db          joinRequestDynamoDB
+	tableName   string
+	eventsTable string
+}
+
+// NewJoinRequestStore creates a JoinRequestStore backed by the given
+// DynamoDB client.
+func NewJoinRequestStore(db joinRequestDynamoDB, tableName, eventsTable string) *JoinRequestStore {
+	return &JoinRequestStore{db: db, tableName: tableName, eventsTable: eventsTable}
+}
+
+// CreateJoinRequest writes req unless the user already has a membership or
+// a live request, which is reported as domain.ErrAlreadyExists.
+func (s *JoinRequestStore) CreateJoinRequest(ctx context.Context, req domain.JoinRequest) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.create_join_request")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(toJoinRequestItem(req))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("join request store: marshal item: %w", err)
+	}
+
+	cond := "attribute_not_exists(chat_id) OR (#status = :pending AND expires_at <= :now)"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:                &s.tableName,
+		Item:                     av,
+		ConditionExpression:      &cond,
+		ExpressionAttributeNames: map[string]string{"#status": "status"}, // reserved word
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":pending": &dynamo.AttributeValueMemberS{Value: membershipStatusPending},
+			":now":     &dynamo.AttributeValueMemberS{Value: req.CreatedAt.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("join request store: create: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("join request store: create: %w", err)
+	}
+	return nil
+}
+
+// ListJoinRequests returns up to limit live requests for chatID after
+// afterUserID, in user ID order. Members are filtered server-side;
+// because DynamoDB applies Limit before the filter, the query continues
+// until the page is full or the partition is exhausted.
+func (s *JoinRequestStore) ListJoinRequests(
+	ctx context.Context, chatID, afterUserID string, limit int, now time.Time,
+) ([]domain.JoinRequest, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.list_join_requests")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "chat_id = :cid"
+	values := map[string]dynamo.AttributeValue{
+		":cid":     &dynamo.AttributeValueMemberS{Value: chatID},
+		":pending": &dynamo.AttributeValueMemberS{Value: membershipStatusPending},
+		":now":     &dynamo.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+	}
+	if afterUserID != "" {
+		keyExpr += " AND user_id > :after"
+		values[":after"] = &dynamo.AttributeValueMemberS{Value: afterUserID}
+	}
+	filter := "#status = :pending AND expires_at > :now"
+	pageLimit := int32(limit)
+
+	var (
+		reqs     []domain.JoinRequest
+		startKey map[string]dynamo.AttributeValue
+	)
+	for {
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("join request store: list: %w", err)
+		}
+
+		out, err := s.db.Query(ctx, &dynamo.QueryInput{
+			TableName:                 &s.tableName,
+			KeyConditionExpression:    &keyExpr,
+			FilterExpression:          &filter,
+			ExpressionAttributeNames:  map[string]string{"#status": "status"},
+			ExpressionAttributeValues: values,
+			Limit:                     &pageLimit,
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("join request store: list: %w", err)
+		}
+
+		for _, av := range out.Items {
+			var item joinRequestItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("join request store: unmarshal request: %w", err)
+			}
+			req, err := fromJoinRequestItem(item)
+			if err != nil {
+				return nil, fmt.Errorf("join request store: %w", err)
+			}
+			reqs = append(reqs, req)
+			if len(reqs) == limit {
+				return reqs, nil
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return reqs, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+// ApproveJoinRequest turns a live request into a member membership in
+// place, dropping the request attributes and the TTL, in one
+// TransactWriteItems:
+//
+//	[0] request → membership update on chat_memberships
+//	[1] member_joined event by actorID on chat_events
+//
+// A missing, decided or expired request is reported as
+// domain.ErrNotFound.
+func (s *JoinRequestStore) ApproveJoinRequest(ctx context.Context, chatID, userID, actorID string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.approve_join_request")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	update := "SET #role = :member, joined_at = :now REMOVE #status, note, requested_at, expires_at, #ttl"
+	cond := "#status = :pending AND expires_at > :now"
+	event := newChatEvent(chatID, domain.ChatEventMemberJoined, actorID, now)
+	event.UserID, event.Role = userID, domain.MemberRoleMember
+	approve := dynamo.Update{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    &update,
+		ConditionExpression: &cond,
+		// role, status and ttl are DynamoDB reserved words.
+		ExpressionAttributeNames: map[string]string{"#role": "role", "#status": "status", "#ttl": "ttl"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":member":  &dynamo.AttributeValueMemberS{Value: string(domain.MemberRoleMember)},
+			":pending": &dynamo.AttributeValueMemberS{Value: membershipStatusPending},
+			":now":     &dynamo.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+	}
+	_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{Update: &approve},
+			buildChatEventPut(&s.eventsTable, event),
+		},
+	})
+	if err != nil {
+		if reasons, ok := dynamo.IsTransactionCanceledException(err); ok && len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed" {
+			return fmt.Errorf("join request store: approve: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("join request store: approve: %w", err)
+	}
+	return nil
+}
+
+// DeleteJoinRequest removes a live request. The condition keeps it from
+// ever deleting a membership; a missing, decided or expired request is
+// reported as domain.ErrNotFound.
+func (s *JoinRequestStore) DeleteJoinRequest(ctx context.Context, chatID, userID string, now time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.delete_join_request")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "DeleteItem"),
+	)
+
+	cond := "#status = :pending AND expires_at > :now"
+	_, err := s.db.DeleteItem(ctx, &dynamo.DeleteItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ConditionExpression:      &cond,
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":pending": &dynamo.AttributeValueMemberS{Value: membershipStatusPending},
+			":now":     &dynamo.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("join request store: delete: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("join request store: delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements joinRequestDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubJoinRequestDynamo struct {
+	putItemFn    func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	queryFn      func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	deleteItemFn func(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+	transactFn   func(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+func (s *stubJoinRequestDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+func (s *stubJoinRequestDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+func (s *stubJoinRequestDynamo) DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+	return s.deleteItemFn(ctx, params, optFns...)
+}
+
+func (s *stubJoinRequestDynamo) TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	return s.transactFn(ctx, params, optFns...)
+}
+
+var _ joinRequestDynamoDB = (*stubJoinRequestDynamo)(nil)
+
+var joinRequestNow = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+// ---------------------------------------------------------------------------
+// Tests — CreateJoinRequest
+// ---------------------------------------------------------------------------
+
+func TestJoinRequestStore_CreateJoinRequest(t *testing.T) {
+	ctx := context.Background()
+	req, err := domain.NewJoinRequest("chat-001", "user-001", "hi", joinRequestNow)
+	require.NoError(t, err)
+
+	t.Run("writes a pending item with TTL", func(t *testing.T) {
+		var got joinRequestItem
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				require.NoError(t, dynamo.UnmarshalMap(params.Item, &got))
+				assert.Contains(t, *params.ConditionExpression, "attribute_not_exists(chat_id)")
+				return &dynamo.PutItemOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		require.NoError(t, store.CreateJoinRequest(ctx, req))
+		assert.Equal(t, "pending", got.Status)
+		assert.Equal(t, "hi", got.Note)
+		assert.Equal(t, "2026-03-09T12:00:00Z", got.ExpiresAt)
+		assert.Equal(t, req.ExpiresAt.Unix(), got.TTL)
+	})
+
+	t.Run("existing membership or live request", func(t *testing.T) {
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.ErrorIs(t, store.CreateJoinRequest(ctx, req), domain.ErrAlreadyExists)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ListJoinRequests
+// ---------------------------------------------------------------------------
+
+func TestJoinRequestStore_ListJoinRequests(t *testing.T) {
+	ctx := context.Background()
+	item := func(userID string) map[string]dynamo.AttributeValue {
+		req, _ := domain.NewJoinRequest("chat-001", userID, "", joinRequestNow)
+		av, err := dynamo.MarshalMap(toJoinRequestItem(req))
+		require.NoError(t, err)
+		return av
+	}
+
+	t.Run("continues past filtered pages until full", func(t *testing.T) {
+		var calls int
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				calls++
+				assert.Equal(t, "chat_id = :cid AND user_id > :after", *params.KeyConditionExpression)
+				if calls == 1 {
+					return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item("user-002")}, LastEvaluatedKey: item("user-002")}, nil
+				}
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item("user-003"), item("user-004")}}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		reqs, err := store.ListJoinRequests(ctx, "chat-001", "user-001", 2, joinRequestNow)
+
+		require.NoError(t, err)
+		require.Len(t, reqs, 2)
+		assert.Equal(t, "user-002", reqs[0].UserID)
+		assert.Equal(t, "user-003", reqs[1].UserID)
+		assert.Equal(t, joinRequestNow, reqs[0].CreatedAt)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_memberships", "chat_events")
+
+		_, err := store.ListJoinRequests(ctx, "chat-001", "", 10, joinRequestNow)
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ApproveJoinRequest / DeleteJoinRequest
+// ---------------------------------------------------------------------------
+
+func TestJoinRequestStore_ApproveJoinRequest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("promotes the pending item and records the join", func(t *testing.T) {
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+				update := params.TransactItems[0].Update
+				assert.Contains(t, *update.UpdateExpression, "REMOVE #status")
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "member"}, update.ExpressionAttributeValues[":member"])
+				event := chatEventAV(t, params.TransactItems[1])
+				assert.Equal(t, "member_joined", event.Type)
+				assert.Equal(t, "user-001", event.UserID)
+				assert.Equal(t, "user-002", event.ActorID)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.NoError(t, store.ApproveJoinRequest(ctx, "chat-001", "user-001", "user-002", joinRequestNow))
+	})
+
+	t.Run("no live request", func(t *testing.T) {
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			transactFn: func(context.Context, *dynamo.TransactWriteItemsInput, ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.ErrorIs(t, store.ApproveJoinRequest(ctx, "chat-001", "user-001", "user-002", joinRequestNow), domain.ErrNotFound)
+	})
+}
+
+func TestJoinRequestStore_DeleteJoinRequest(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deletes only pending items", func(t *testing.T) {
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			deleteItemFn: func(_ context.Context, params *dynamo.DeleteItemInput, _ ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+				assert.Equal(t, "#status = :pending AND expires_at > :now", *params.ConditionExpression)
+				return &dynamo.DeleteItemOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.NoError(t, store.DeleteJoinRequest(ctx, "chat-001", "user-001", joinRequestNow))
+	})
+
+	t.Run("no live request", func(t *testing.T) {
+		store := NewJoinRequestStore(&stubJoinRequestDynamo{
+			deleteItemFn: func(context.Context, *dynamo.DeleteItemInput, ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.ErrorIs(t, store.DeleteJoinRequest(ctx, "chat-001", "user-001", joinRequestNow), domain.ErrNotFound)
+	})
+}
@@ -0,0 +1,95 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: MemberImporter satisfies app.ChatMemberImporter.
+var _ app.ChatMemberImporter = (*MemberImporter)(nil)
+
+// MemberImporter adds users migrated by bulk import to chats.
+type MemberImporter struct {
+	db               txDynamoDB
+	chatsTable       string
+	membershipsTable string
+	eventsTable      string
+}
+
+// NewMemberImporter creates a MemberImporter backed by the given DynamoDB
+// client.
+func NewMemberImporter(db txDynamoDB, chatsTable, membershipsTable, eventsTable string) *MemberImporter {
+	return &MemberImporter{
+		db:               db,
+		chatsTable:       chatsTable,
+		membershipsTable: membershipsTable,
+		eventsTable:      eventsTable,
+	}
+}
+
+// AddImportedMember makes userID a member of chatID in one
+// TransactWriteItems:
+//
+//	[0] chat exists (condition check on chats)
+//	[1] membership upsert on chat_memberships
+//	[2] member_joined event on chat_events
+//
+// The upsert keeps an existing role and joined_at, so re-running an import
+// never demotes an admin, and turns a pending join request into a
+// membership; replaying the event keeps an existing role too. Returns
+// domain.ErrNotFound if the chat does not exist.
+func (m *MemberImporter) AddImportedMember(ctx context.Context, chatID, userID string, joinedAt time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.add_imported_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	chatExists := "attribute_exists(chat_id)"
+	update := "SET #role = if_not_exists(#role, :member), joined_at = if_not_exists(joined_at, :now) " +
+		"REMOVE #status, note, requested_at, expires_at, #ttl"
+	event := newChatEvent(chatID, domain.ChatEventMemberJoined, "", joinedAt)
+	event.UserID, event.Role = userID, domain.MemberRoleMember
+	_, err := m.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{ConditionCheck: &dynamo.ConditionCheck{
+				TableName:           &m.chatsTable,
+				Key:                 map[string]dynamo.AttributeValue{"chat_id": &dynamo.AttributeValueMemberS{Value: chatID}},
+				ConditionExpression: &chatExists,
+			}},
+			{Update: &dynamo.Update{
+				TableName: &m.membershipsTable,
+				Key: map[string]dynamo.AttributeValue{
+					"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+					"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+				},
+				UpdateExpression: &update,
+				// role, status and ttl are DynamoDB reserved words.
+				ExpressionAttributeNames: map[string]string{"#role": "role", "#status": "status", "#ttl": "ttl"},
+				ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+					":member": roleValue(domain.MemberRoleMember),
+					":now":    &dynamo.AttributeValueMemberS{Value: joinedAt.UTC().Format(time.RFC3339)},
+				},
+			}},
+			buildChatEventPut(&m.eventsTable, event),
+		},
+	})
+	if err != nil {
+		if reasons, ok := dynamo.IsTransactionCanceledException(err); ok && len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed" {
+			return fmt.Errorf("member importer: chat %s: %w", chatID, domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("member importer: add member: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+func TestMemberImporter_AddImportedMember(t *testing.T) {
+	joinedAt := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("checks the chat and upserts the membership", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 3)
+
+				check := params.TransactItems[0].ConditionCheck
+				require.NotNil(t, check)
+				assert.Equal(t, "chats", *check.TableName)
+				assert.Equal(t, "attribute_exists(chat_id)", *check.ConditionExpression)
+
+				update := params.TransactItems[1].Update
+				require.NotNil(t, update)
+				assert.Equal(t, "chat_memberships", *update.TableName)
+				assert.Contains(t, *update.UpdateExpression, "#role = if_not_exists(#role, :member)")
+				assert.Contains(t, *update.UpdateExpression, "REMOVE #status")
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "user-1"}, update.Key["user_id"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-02-10T12:00:00Z"}, update.ExpressionAttributeValues[":now"])
+
+				event := chatEventAV(t, params.TransactItems[2])
+				assert.Equal(t, "member_joined", event.Type)
+				assert.Equal(t, "user-1", event.UserID)
+				assert.Equal(t, "2026-02-10T12:00:00Z", event.OccurredAt)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+
+		err := NewMemberImporter(stub, "chats", "chat_memberships", "chat_events").AddImportedMember(context.Background(), "chat-1", "user-1", joinedAt)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("missing chat - returns ErrNotFound", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
+			},
+		}
+
+		err := NewMemberImporter(stub, "chats", "chat_memberships", "chat_events").AddImportedMember(context.Background(), "chat-1", "user-1", joinedAt)
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("other errors are wrapped", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}
+
+		err := NewMemberImporter(stub, "chats", "chat_memberships", "chat_events").AddImportedMember(context.Background(), "chat-1", "user-1", joinedAt)
+
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrNotFound)
+		assert.Contains(t, err.Error(), "throttled")
+	})
+}
@@ -0,0 +1,431 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time checks: MembershipStore satisfies app.MembershipStore and
+// app.MembershipRemover.
+var (
+	_ app.MembershipStore   = (*MembershipStore)(nil)
+	_ app.MembershipRemover = (*MembershipStore)(nil)
+)
+
+// membershipDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the membership store.
+type membershipDynamoDB interface {
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// memberItem is a chat_memberships item (PK chat_id, SK user_id).
+type memberItem struct {
+	ChatID   string `dynamodbav:"chat_id"`
+	UserID   string `dynamodbav:"user_id"`
+	Role     string `dynamodbav:"role"`
+	JoinedAt string `dynamodbav:"joined_at"`
+}
+
+// MembershipStore changes roles in the chat_memberships table. Writes that
+// touch the owner are single transactions conditioned on the roles the
+// service read, so a chat never has zero or two owners. Every write
+// appends its event to the chat event log in the same transaction.
+type MembershipStore struct {
+	db          membershipDynamoDB
+	tableName   string
+	indexName   string
+	eventsTable string
+}
+
+// NewMembershipStore creates a MembershipStore backed by the given DynamoDB
+// client.
+func NewMembershipStore(db membershipDynamoDB, tableName, eventsTable string) *MembershipStore {
+	return &MembershipStore{
+		db:          db,
+		tableName:   tableName,
+		indexName:   "user_chats-index",
+		eventsTable: eventsTable,
+	}
+}
+
+// ListMembers returns every member of chatID. Pending join requests are
+// filtered server-side.
+func (s *MembershipStore) ListMembers(ctx context.Context, chatID string) ([]domain.ChatMember, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.list_members")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "chat_id = :cid"
+	filter := "attribute_not_exists(#status)"
+	var members []domain.ChatMember
+	err := s.queryAll(ctx, &dynamo.QueryInput{
+		TableName:                &s.tableName,
+		KeyConditionExpression:   &keyExpr,
+		FilterExpression:         &filter,
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":cid": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+	}, func(item memberItem) {
+		// joined_at is informational; an unparsable value reads as zero
+		// and ranks first for succession.
+		joinedAt, _ := time.Parse(time.RFC3339, item.JoinedAt)
+		members = append(members, domain.ChatMember{
+			UserID:   item.UserID,
+			Role:     domain.MemberRole(item.Role),
+			JoinedAt: joinedAt,
+		})
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("membership store: list members: %w", err)
+	}
+	return members, nil
+}
+
+// ListUserChats returns every chat userID is a member of via the
+// user_chats-index GSI, which is eventually consistent. Pending join
+// requests are filtered server-side.
+func (s *MembershipStore) ListUserChats(ctx context.Context, userID string) ([]domain.Membership, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.list_user_chats")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid"
+	filter := "attribute_not_exists(#status)"
+	var chats []domain.Membership
+	err := s.queryAll(ctx, &dynamo.QueryInput{
+		TableName:                &s.tableName,
+		IndexName:                &s.indexName,
+		KeyConditionExpression:   &keyExpr,
+		FilterExpression:         &filter,
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":uid": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+	}, func(item memberItem) {
+		joinedAt, _ := time.Parse(time.RFC3339, item.JoinedAt)
+		chats = append(chats, domain.Membership{
+			ChatID:   item.ChatID,
+			Role:     domain.MemberRole(item.Role),
+			JoinedAt: joinedAt,
+		})
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("membership store: list user chats: %w", err)
+	}
+	return chats, nil
+}
+
+// ListOwnedChats returns the chats userID owns via the user_chats-index
+// GSI. The index is eventually consistent, so a chat transferred moments
+// ago may still be listed; the conditional writes that follow catch it.
+func (s *MembershipStore) ListOwnedChats(ctx context.Context, userID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.list_owned_chats")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid"
+	filter := "#role = :owner"
+	var chats []string
+	err := s.queryAll(ctx, &dynamo.QueryInput{
+		TableName:                &s.tableName,
+		IndexName:                &s.indexName,
+		KeyConditionExpression:   &keyExpr,
+		FilterExpression:         &filter,
+		ExpressionAttributeNames: map[string]string{"#role": "role"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":uid":   &dynamo.AttributeValueMemberS{Value: userID},
+			":owner": roleValue(domain.MemberRoleOwner),
+		},
+	}, func(item memberItem) {
+		chats = append(chats, item.ChatID)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("membership store: list owned chats: %w", err)
+	}
+	return chats, nil
+}
+
+// SetMemberRole sets userID's role, conditional on them being a non-owner
+// member, in one TransactWriteItems:
+//
+//	[0] userID's role update
+//	[1] role_changed event by actorID
+//
+// A failed condition is reported as domain.ErrVersionConflict.
+func (s *MembershipStore) SetMemberRole(ctx context.Context, chatID, userID string, role domain.MemberRole, actorID string, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.set_member_role")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	event := newChatEvent(chatID, domain.ChatEventRoleChanged, actorID, at)
+	event.UserID, event.Role = userID, role
+	_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			s.buildRoleUpdate(chatID, userID, role, "#role IN (:admin, :member)",
+				map[string]dynamo.AttributeValue{
+					":admin":  roleValue(domain.MemberRoleAdmin),
+					":member": roleValue(domain.MemberRoleMember),
+				}),
+			buildChatEventPut(&s.eventsTable, event),
+		},
+	})
+	if err != nil {
+		txErr := classifyMembershipTxError(err, "set role", "member_update", "event_append")
+		span.RecordError(txErr)
+		span.SetStatus(codes.Error, txErr.Error())
+		return txErr
+	}
+	return nil
+}
+
+// TransferOwnership executes a 3-item TransactWriteItems:
+//
+//	[0] fromUserID owner → admin, conditional on still being the owner
+//	[1] toUserID admin/member → owner, conditional on still being a member
+//	[2] ownership_transferred event by fromUserID
+//
+// Returns domain.ErrVersionConflict if either condition fails.
+func (s *MembershipStore) TransferOwnership(ctx context.Context, chatID, fromUserID, toUserID string, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.transfer_ownership")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	event := newChatEvent(chatID, domain.ChatEventOwnershipTransferred, fromUserID, at)
+	event.UserID, event.SuccessorID = fromUserID, toUserID
+	_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			s.buildRoleUpdate(chatID, fromUserID, domain.MemberRoleAdmin, "#role = :owner",
+				map[string]dynamo.AttributeValue{":owner": roleValue(domain.MemberRoleOwner)}),
+			s.buildPromoteToOwner(chatID, toUserID),
+			buildChatEventPut(&s.eventsTable, event),
+		},
+	})
+	if err != nil {
+		txErr := classifyMembershipTxError(err, "transfer ownership", "owner_demote", "member_promote", "event_append")
+		span.RecordError(txErr)
+		span.SetStatus(codes.Error, txErr.Error())
+		return txErr
+	}
+	return nil
+}
+
+// ReplaceOwner removes ownerID's membership and promotes successorID in
+// one TransactWriteItems:
+//
+//	[0] delete ownerID, conditional on still being the owner
+//	[1] successorID admin/member → owner, conditional on still being a member
+//	[2] owner_removed event
+//
+// With an empty successorID the promotion is left out. Returns
+// domain.ErrVersionConflict if a condition fails.
+func (s *MembershipStore) ReplaceOwner(ctx context.Context, chatID, ownerID, successorID string, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.replace_owner")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	cond := "#role = :owner"
+	items := []dynamo.TransactWriteItem{{Delete: &dynamo.Delete{
+		TableName:                &s.tableName,
+		Key:                      membershipKey(chatID, ownerID),
+		ConditionExpression:      &cond,
+		ExpressionAttributeNames: map[string]string{"#role": "role"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":owner": roleValue(domain.MemberRoleOwner),
+		},
+	}}}
+	itemNames := []string{"owner_delete"}
+	if successorID != "" {
+		items = append(items, s.buildPromoteToOwner(chatID, successorID))
+		itemNames = append(itemNames, "successor_promote")
+	}
+	event := newChatEvent(chatID, domain.ChatEventOwnerRemoved, "", at)
+	event.UserID, event.SuccessorID = ownerID, successorID
+	items = append(items, buildChatEventPut(&s.eventsTable, event))
+	itemNames = append(itemNames, "event_append")
+
+	_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		txErr := classifyMembershipTxError(err, "replace owner", itemNames...)
+		span.RecordError(txErr)
+		span.SetStatus(codes.Error, txErr.Error())
+		return txErr
+	}
+	return nil
+}
+
+// RemoveUserMemberships deletes every membership of userID except the
+// chats they own, and returns how many it deleted. Each delete is
+// conditional on the user not being the owner, so a chat handed to them
+// since the listing keeps its owner, and is a transaction with its
+// member_removed event. Pending join requests are left to expire.
+func (s *MembershipStore) RemoveUserMemberships(ctx context.Context, userID string, at time.Time) (int, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.remove_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	chats, err := s.ListUserChats(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	cond := "attribute_exists(chat_id) AND #role <> :owner"
+	var removed int
+	for _, m := range chats {
+		if m.Role == domain.MemberRoleOwner {
+			continue
+		}
+		event := newChatEvent(m.ChatID, domain.ChatEventMemberRemoved, "", at)
+		event.UserID = userID
+		_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+			TransactItems: []dynamo.TransactWriteItem{
+				{Delete: &dynamo.Delete{
+					TableName:                &s.tableName,
+					Key:                      membershipKey(m.ChatID, userID),
+					ConditionExpression:      &cond,
+					ExpressionAttributeNames: map[string]string{"#role": "role"},
+					ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+						":owner": roleValue(domain.MemberRoleOwner),
+					},
+				}},
+				buildChatEventPut(&s.eventsTable, event),
+			},
+		})
+		if reasons, ok := dynamo.IsTransactionCanceledException(err); ok && len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed" {
+			continue
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return removed, fmt.Errorf("membership store: remove user from chat %s: %w", m.ChatID, err)
+		}
+		removed++
+	}
+	span.SetAttributes(attribute.Int("memberships.removed", removed))
+	return removed, nil
+}
+
+// buildPromoteToOwner creates a TransactWriteItem that makes userID the
+// owner, conditional on them still being an admin or member.
+func (s *MembershipStore) buildPromoteToOwner(chatID, userID string) dynamo.TransactWriteItem {
+	return s.buildRoleUpdate(chatID, userID, domain.MemberRoleOwner, "#role IN (:admin, :member)",
+		map[string]dynamo.AttributeValue{
+			":admin":  roleValue(domain.MemberRoleAdmin),
+			":member": roleValue(domain.MemberRoleMember),
+		})
+}
+
+// buildRoleUpdate creates a TransactWriteItem that sets userID's role
+// under cond, whose placeholders are bound in condValues.
+func (s *MembershipStore) buildRoleUpdate(
+	chatID, userID string, role domain.MemberRole, cond string, condValues map[string]dynamo.AttributeValue,
+) dynamo.TransactWriteItem {
+	update := "SET #role = :role"
+	condValues[":role"] = roleValue(role)
+	return dynamo.TransactWriteItem{
+		Update: &dynamo.Update{
+			TableName:                 &s.tableName,
+			Key:                       membershipKey(chatID, userID),
+			UpdateExpression:          &update,
+			ConditionExpression:       &cond,
+			ExpressionAttributeNames:  map[string]string{"#role": "role"},
+			ExpressionAttributeValues: condValues,
+		},
+	}
+}
+
+// queryAll runs in to completion, passing each item to fn.
+func (s *MembershipStore) queryAll(ctx context.Context, in *dynamo.QueryInput, fn func(memberItem)) error {
+	for {
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out, err := s.db.Query(ctx, in)
+		if err != nil {
+			return err
+		}
+		for _, av := range out.Items {
+			var item memberItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return fmt.Errorf("unmarshal membership: %w", err)
+			}
+			fn(item)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// membershipKey returns the primary key of a chat_memberships item.
+func membershipKey(chatID, userID string) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{
+		"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+		"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+	}
+}
+
+// roleValue returns role as an expression attribute value.
+func roleValue(role domain.MemberRole) dynamo.AttributeValue {
+	return &dynamo.AttributeValueMemberS{Value: string(role)}
+}
+
+// classifyMembershipTxError maps a failed condition in a membership
+// transaction to domain.ErrVersionConflict, naming the item that failed.
+func classifyMembershipTxError(err error, op string, itemNames ...string) error {
+	reasons, ok := dynamo.IsTransactionCanceledException(err)
+	if !ok {
+		return fmt.Errorf("membership store: %s: %w", op, err)
+	}
+	for i, reason := range reasons {
+		if reason == "ConditionalCheckFailed" {
+			name := "unknown"
+			if i < len(itemNames) {
+				name = itemNames[i]
+			}
+			return fmt.Errorf("membership store: %s: item %d (%s) condition failed: %w",
+				op, i, name, domain.ErrVersionConflict)
+		}
+	}
+	return fmt.Errorf("membership store: %s: transaction canceled: %w", op, err)
+}
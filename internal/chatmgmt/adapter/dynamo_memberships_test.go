@@ -0,0 +1,309 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements membershipDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubMembershipDynamo struct {
+	queryFn    func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	transactFn func(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+func (s *stubMembershipDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+func (s *stubMembershipDynamo) TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	return s.transactFn(ctx, params, optFns...)
+}
+
+var _ membershipDynamoDB = (*stubMembershipDynamo)(nil)
+
+func memberAV(t *testing.T, item memberItem) map[string]dynamo.AttributeValue {
+	t.Helper()
+	av, err := dynamo.MarshalMap(item)
+	require.NoError(t, err)
+	return av
+}
+
+// ---------------------------------------------------------------------------
+// Tests — queries
+// ---------------------------------------------------------------------------
+
+func TestMembershipStore_ListMembers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("follows pages and parses joined_at", func(t *testing.T) {
+		var calls int
+		store := NewMembershipStore(&stubMembershipDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				calls++
+				assert.Equal(t, "attribute_not_exists(#status)", *params.FilterExpression)
+				if calls == 1 {
+					item := memberAV(t, memberItem{ChatID: "chat-001", UserID: "user-001", Role: "owner", JoinedAt: "2026-03-02T12:00:00Z"})
+					return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item}, LastEvaluatedKey: item}, nil
+				}
+				item := memberAV(t, memberItem{ChatID: "chat-001", UserID: "user-002", Role: "member"})
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item}}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		members, err := store.ListMembers(ctx, "chat-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, []domain.ChatMember{
+			{UserID: "user-001", Role: domain.MemberRoleOwner, JoinedAt: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+			{UserID: "user-002", Role: domain.MemberRoleMember},
+		}, members)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_memberships", "chat_events")
+
+		_, err := store.ListMembers(ctx, "chat-001")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
+
+func TestMembershipStore_ListUserChats(t *testing.T) {
+	store := NewMembershipStore(&stubMembershipDynamo{
+		queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			assert.Equal(t, "user_chats-index", *params.IndexName)
+			assert.Equal(t, "attribute_not_exists(#status)", *params.FilterExpression)
+			return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+				memberAV(t, memberItem{ChatID: "chat-001", UserID: "user-001", Role: "owner", JoinedAt: "2026-03-01T00:00:00Z"}),
+				memberAV(t, memberItem{ChatID: "chat-002", UserID: "user-001", Role: "member", JoinedAt: "2026-03-02T00:00:00Z"}),
+			}}, nil
+		},
+	}, "chat_memberships", "chat_events")
+
+	chats, err := store.ListUserChats(context.Background(), "user-001")
+
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Membership{
+		{ChatID: "chat-001", Role: domain.MemberRoleOwner, JoinedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{ChatID: "chat-002", Role: domain.MemberRoleMember, JoinedAt: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+	}, chats)
+}
+
+func TestMembershipStore_ListOwnedChats(t *testing.T) {
+	store := NewMembershipStore(&stubMembershipDynamo{
+		queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			assert.Equal(t, "user_chats-index", *params.IndexName)
+			assert.Equal(t, "#role = :owner", *params.FilterExpression)
+			return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+				memberAV(t, memberItem{ChatID: "chat-001", UserID: "user-001", Role: "owner"}),
+			}}, nil
+		},
+	}, "chat_memberships", "chat_events")
+
+	chats, err := store.ListOwnedChats(context.Background(), "user-001")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"chat-001"}, chats)
+}
+
+// ---------------------------------------------------------------------------
+// Tests — writes
+// ---------------------------------------------------------------------------
+
+func TestMembershipStore_SetMemberRole(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("never matches the owner and records the change", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+				update := params.TransactItems[0].Update
+				assert.Equal(t, "#role IN (:admin, :member)", *update.ConditionExpression)
+				assert.Equal(t, roleValue(domain.MemberRoleAdmin), update.ExpressionAttributeValues[":role"])
+				event := chatEventAV(t, params.TransactItems[1])
+				assert.Equal(t, "role_changed", event.Type)
+				assert.Equal(t, "user-001", event.ActorID)
+				assert.Equal(t, "user-002", event.UserID)
+				assert.Equal(t, "admin", event.Role)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.NoError(t, store.SetMemberRole(ctx, "chat-001", "user-002", domain.MemberRoleAdmin, "user-001", at))
+	})
+
+	t.Run("condition failure is a version conflict", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(context.Context, *dynamo.TransactWriteItemsInput, ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
+			},
+		}, "chat_memberships", "chat_events")
+
+		err := store.SetMemberRole(ctx, "chat-001", "user-001", domain.MemberRoleMember, "user-002", at)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+	})
+}
+
+func TestMembershipStore_TransferOwnership(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("demotes, promotes and records in one transaction", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 3)
+				demote, promote := params.TransactItems[0].Update, params.TransactItems[1].Update
+				assert.Equal(t, "#role = :owner", *demote.ConditionExpression)
+				assert.Equal(t, roleValue(domain.MemberRoleAdmin), demote.ExpressionAttributeValues[":role"])
+				assert.Equal(t, "#role IN (:admin, :member)", *promote.ConditionExpression)
+				assert.Equal(t, roleValue(domain.MemberRoleOwner), promote.ExpressionAttributeValues[":role"])
+				assert.Equal(t, membershipKey("chat-001", "user-002"), promote.Key)
+				event := chatEventAV(t, params.TransactItems[2])
+				assert.Equal(t, "ownership_transferred", event.Type)
+				assert.Equal(t, "user-001", event.UserID)
+				assert.Equal(t, "user-002", event.SuccessorID)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.NoError(t, store.TransferOwnership(ctx, "chat-001", "user-001", "user-002", at))
+	})
+
+	t.Run("cancelled transaction is a version conflict", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(context.Context, *dynamo.TransactWriteItemsInput, ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("None", "ConditionalCheckFailed", "None")
+			},
+		}, "chat_memberships", "chat_events")
+
+		err := store.TransferOwnership(ctx, "chat-001", "user-001", "user-002", at)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.ErrorContains(t, err, "member_promote")
+	})
+}
+
+func TestMembershipStore_ReplaceOwner(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("deletes the owner and promotes the successor", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 3)
+				require.NotNil(t, params.TransactItems[0].Delete)
+				assert.Equal(t, "#role = :owner", *params.TransactItems[0].Delete.ConditionExpression)
+				require.NotNil(t, params.TransactItems[1].Update)
+				event := chatEventAV(t, params.TransactItems[2])
+				assert.Equal(t, "owner_removed", event.Type)
+				assert.Empty(t, event.ActorID)
+				assert.Equal(t, "user-002", event.SuccessorID)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		assert.NoError(t, store.ReplaceOwner(ctx, "chat-001", "user-001", "user-002", at))
+	})
+
+	t.Run("without a successor only deletes", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+				assert.Equal(t, "#role = :owner", *params.TransactItems[0].Delete.ConditionExpression)
+				event := chatEventAV(t, params.TransactItems[1])
+				assert.Equal(t, "user-001", event.UserID)
+				assert.Empty(t, event.SuccessorID)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		require.NoError(t, store.ReplaceOwner(ctx, "chat-001", "user-001", "", at))
+	})
+
+	t.Run("owner already changed", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			transactFn: func(context.Context, *dynamo.TransactWriteItemsInput, ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
+			},
+		}, "chat_memberships", "chat_events")
+
+		err := store.ReplaceOwner(ctx, "chat-001", "user-001", "", at)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.ErrorContains(t, err, "owner_delete")
+	})
+}
+
+func TestMembershipStore_RemoveUserMemberships(t *testing.T) {
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("deletes non-owner memberships conditionally", func(t *testing.T) {
+		var deleted []string
+		store := NewMembershipStore(&stubMembershipDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+					memberAV(t, memberItem{ChatID: "chat-owned", UserID: "user-001", Role: "owner"}),
+					memberAV(t, memberItem{ChatID: "chat-admin", UserID: "user-001", Role: "admin"}),
+					memberAV(t, memberItem{ChatID: "chat-promoted", UserID: "user-001", Role: "member"}),
+					memberAV(t, memberItem{ChatID: "chat-member", UserID: "user-001", Role: "member"}),
+				}}, nil
+			},
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+				del := params.TransactItems[0].Delete
+				assert.Equal(t, "attribute_exists(chat_id) AND #role <> :owner", *del.ConditionExpression)
+				chatID := del.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+				event := chatEventAV(t, params.TransactItems[1])
+				assert.Equal(t, chatID, event.ChatID)
+				assert.Equal(t, "member_removed", event.Type)
+				if chatID == "chat-promoted" {
+					// Handed to the user after the listing.
+					return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
+				}
+				deleted = append(deleted, chatID)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "chat_memberships", "chat_events")
+
+		removed, err := store.RemoveUserMemberships(context.Background(), "user-001", at)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, removed)
+		assert.Equal(t, []string{"chat-admin", "chat-member"}, deleted)
+	})
+
+	t.Run("delete failure stops and reports progress", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+					memberAV(t, memberItem{ChatID: "chat-001", UserID: "user-001", Role: "member"}),
+				}}, nil
+			},
+			transactFn: func(context.Context, *dynamo.TransactWriteItemsInput, ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_memberships", "chat_events")
+
+		removed, err := store.RemoveUserMemberships(context.Background(), "user-001", at)
+
+		assert.ErrorContains(t, err, "throttled")
+		assert.Zero(t, removed)
+	})
+}
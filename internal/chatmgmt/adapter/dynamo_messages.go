@@ -0,0 +1,224 @@
+package adapter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// Compile-time check: MessageStore satisfies app.MessageStore.
+var _ app.MessageStore = (*MessageStore)(nil)
+
+// messageDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the message store.
+type messageDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+// messageItem is the DynamoDB item shape for the messages_v2 table. PK is
+// domain.MessagePartitionKey of the chat's shard for Sequence.
+type messageItem struct {
+	PK              string `dynamodbav:"pk"`
+	Sequence        uint64 `dynamodbav:"sequence"`
+	ChatID          string `dynamodbav:"chat_id"`
+	MessageID       string `dynamodbav:"message_id"`
+	SenderID        string `dynamodbav:"sender_id"`
+	ClientMessageID string `dynamodbav:"client_message_id"`
+	Content         string `dynamodbav:"content"`
+	ContentType     string `dynamodbav:"content_type"`
+	CreatedAt       string `dynamodbav:"created_at"`
+
+	// ContentKey and SealedContent replace Content on encrypted messages.
+	ContentKey    int    `dynamodbav:"content_key"`
+	SealedContent []byte `dynamodbav:"sealed_content"`
+}
+
+// fromMessageItem converts a DynamoDB item to an app.MessageRecord.
+func fromMessageItem(item messageItem) (app.MessageRecord, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	if err != nil {
+		return app.MessageRecord{}, fmt.Errorf("parse created_at: %w", err)
+	}
+	return app.MessageRecord{
+		MessageID:       item.MessageID,
+		ChatID:          item.ChatID,
+		SenderID:        item.SenderID,
+		ClientMessageID: item.ClientMessageID,
+		Sequence:        item.Sequence,
+		ContentType:     domain.ContentType(item.ContentType),
+		Content:         item.Content,
+		CreatedAt:       createdAt,
+	}, nil
+}
+
+// MessageStore reads chat messages from the sharded messages_v2 table. A
+// chat's shard count comes from the message_shards attribute of its chats
+// record; a chat without one has a single shard.
+type MessageStore struct {
+	db            messageDynamoDB
+	messagesTable string
+	chatsTable    string
+	opener        msgcrypt.Opener
+}
+
+// NewMessageStore creates a MessageStore backed by the given DynamoDB
+// client. Encrypted content is opened with opener; with a nil opener,
+// reading an encrypted message fails.
+func NewMessageStore(db messageDynamoDB, messagesTable, chatsTable string, opener msgcrypt.Opener) *MessageStore {
+	return &MessageStore{
+		db:            db,
+		messagesTable: messagesTable,
+		chatsTable:    chatsTable,
+		opener:        opener,
+	}
+}
+
+// ListAfter returns up to limit messages with sequence > afterSequence, in
+// ascending sequence order. Each shard is read, page by page, until it has
+// yielded limit messages past afterSequence or has none left, and the
+// results are merged, which always contains the overall first limit. A page
+// can stop short of its Limit when it reaches DynamoDB's 1 MB cap.
+func (s *MessageStore) ListAfter(ctx context.Context, chatID string, afterSequence uint64, limit int) ([]app.MessageRecord, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.list_after")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem+Query"),
+	)
+
+	shards, err := s.shards(ctx, chatID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("messages.shards", shards))
+
+	keyExpr := "pk = :pk AND #seq > :after"
+	after := strconv.FormatUint(afterSequence, 10)
+	consistentRead := true
+
+	var messages []app.MessageRecord
+	for shard := range shards {
+		var startKey map[string]dynamo.AttributeValue
+		for read := 0; read < limit; {
+			// Check context between queries per 04_CONTEXT.
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("message store: list after: %w", err)
+			}
+
+			pageLimit := int32(limit - read)
+			out, err := s.db.Query(ctx, &dynamo.QueryInput{
+				TableName:              &s.messagesTable,
+				KeyConditionExpression: &keyExpr,
+				ExpressionAttributeNames: map[string]string{
+					"#seq": "sequence",
+				},
+				ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+					":pk":    &dynamo.AttributeValueMemberS{Value: domain.MessagePartitionKey(chatID, shard)},
+					":after": &dynamo.AttributeValueMemberN{Value: after},
+				},
+				Limit:             &pageLimit,
+				ConsistentRead:    &consistentRead,
+				ExclusiveStartKey: startKey,
+			})
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, fmt.Errorf("message store: query shard %d: %w", shard, err)
+			}
+
+			for _, av := range out.Items {
+				var item messageItem
+				if err := dynamo.UnmarshalMap(av, &item); err != nil {
+					return nil, fmt.Errorf("message store: unmarshal message: %w", err)
+				}
+				if err := s.open(ctx, &item); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+					return nil, fmt.Errorf("message store: %w", err)
+				}
+				rec, err := fromMessageItem(item)
+				if err != nil {
+					return nil, fmt.Errorf("message store: %w", err)
+				}
+				messages = append(messages, rec)
+			}
+			read += len(out.Items)
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			startKey = out.LastEvaluatedKey
+		}
+	}
+
+	slices.SortFunc(messages, func(a, b app.MessageRecord) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// open replaces an encrypted item's sealed content with its plaintext.
+func (s *MessageStore) open(ctx context.Context, item *messageItem) error {
+	if item.ContentKey == 0 {
+		return nil
+	}
+	if s.opener == nil {
+		return fmt.Errorf("message %s/%d is encrypted and no keyring is configured", item.ChatID, item.Sequence)
+	}
+	plaintext, err := s.opener.Open(ctx, item.ChatID, item.MessageID, msgcrypt.Sealed{
+		KeyVersion: item.ContentKey,
+		Ciphertext: item.SealedContent,
+	})
+	if err != nil {
+		return err
+	}
+	item.Content = string(plaintext)
+	return nil
+}
+
+// shards returns the chat's message shard count.
+func (s *MessageStore) shards(ctx context.Context, chatID string) (int, error) {
+	projection := "message_shards"
+	consistentRead := true
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.chatsTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+		ProjectionExpression: &projection,
+		ConsistentRead:       &consistentRead,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("message store: get shard count: %w", err)
+	}
+
+	var chat struct {
+		MessageShards int `dynamodbav:"message_shards"`
+	}
+	if err := dynamo.UnmarshalMap(out.Item, &chat); err != nil {
+		return 0, fmt.Errorf("message store: unmarshal shard count: %w", err)
+	}
+	if chat.MessageShards == 0 {
+		return 1, nil
+	}
+	if !domain.IsValidMessageShards(chat.MessageShards) {
+		return 0, fmt.Errorf("message store: chat %s has %d message shards, want [1, %d]",
+			chatID, chat.MessageShards, domain.MaxMessageShards)
+	}
+	return chat.MessageShards, nil
+}
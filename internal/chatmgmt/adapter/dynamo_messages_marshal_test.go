@@ -0,0 +1,47 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/allocbudget"
+)
+
+// testMessageItem is a typical plaintext message as history and sync read
+// it. Every message read back goes through UnmarshalMap once.
+var testMessageItem = messageItem{
+	PK:              "01HQX5K3Z9Y8W7V6U5T4S3R2Q0#shard0",
+	Sequence:        4242,
+	ChatID:          "01HQX5K3Z9Y8W7V6U5T4S3R2Q0",
+	MessageID:       "01HQX5K3Z9Y8W7V6U5T4S3R2Q1",
+	SenderID:        "user-001",
+	ClientMessageID: "5f1c7f3e-8a8e-4b7a-9d51-0c5b8c2c9d3e",
+	Content:         "see you at eight",
+	ContentType:     "text/plain",
+	CreatedAt:       "2026-01-15T12:00:00.000Z",
+}
+
+func TestMessageItem_UnmarshalAllocBudget(t *testing.T) {
+	av, err := dynamo.MarshalMap(testMessageItem)
+	require.NoError(t, err)
+
+	allocbudget.Check(t, allocbudget.Budget{Allocs: 5, RaceAllocs: 5}, func() {
+		var m messageItem
+		_ = dynamo.UnmarshalMap(av, &m)
+	})
+}
+
+func BenchmarkUnmarshalMessage(b *testing.B) {
+	av, err := dynamo.MarshalMap(testMessageItem)
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		var m messageItem
+		if err := dynamo.UnmarshalMap(av, &m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — an in-memory messages_v2 and chats table implementing messageDynamoDB.
+// ---------------------------------------------------------------------------
+
+type fakeMessageDynamo struct {
+	shards   map[string]int           // chat_id -> message_shards; absent means unset
+	messages map[string][]messageItem // pk -> items in ascending sequence
+	queries  []string                 // pk of each Query, in order
+	queryErr error
+	pageSize int // most items per Query page, as DynamoDB's 1 MB cap would; 0 is unbounded
+}
+
+func (f *fakeMessageDynamo) GetItem(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	chatID := params.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+	n, ok := f.shards[chatID]
+	if !ok {
+		return &dynamo.GetItemOutput{}, nil
+	}
+	return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+		"message_shards": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(n)},
+	}}, nil
+}
+
+func (f *fakeMessageDynamo) Query(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	if f.queryErr != nil {
+		return nil, f.queryErr
+	}
+	pk := params.ExpressionAttributeValues[":pk"].(*dynamo.AttributeValueMemberS).Value
+	after, err := strconv.ParseUint(params.ExpressionAttributeValues[":after"].(*dynamo.AttributeValueMemberN).Value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if start, ok := params.ExclusiveStartKey["sequence"].(*dynamo.AttributeValueMemberN); ok {
+		if after, err = strconv.ParseUint(start.Value, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	f.queries = append(f.queries, pk)
+
+	var (
+		out  dynamo.QueryOutput
+		last messageItem
+	)
+	for _, item := range f.messages[pk] {
+		if item.Sequence <= after {
+			continue
+		}
+		full := params.Limit != nil && len(out.Items) == int(*params.Limit)
+		if full || (f.pageSize > 0 && len(out.Items) == f.pageSize) {
+			out.LastEvaluatedKey = map[string]dynamo.AttributeValue{
+				"pk":       &dynamo.AttributeValueMemberS{Value: pk},
+				"sequence": &dynamo.AttributeValueMemberN{Value: strconv.FormatUint(last.Sequence, 10)},
+			}
+			break
+		}
+		av, err := dynamo.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, av)
+		last = item
+	}
+	return &out, nil
+}
+
+var _ messageDynamoDB = (*fakeMessageDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+const testChatID = "chat-1"
+
+// newFakeMessages stores sequences 1..count of testChatID across shards.
+func newFakeMessages(shards int, count uint64) *fakeMessageDynamo {
+	f := &fakeMessageDynamo{shards: map[string]int{}, messages: map[string][]messageItem{}}
+	if shards > 0 {
+		f.shards[testChatID] = shards
+	}
+	for seq := uint64(1); seq <= count; seq++ {
+		pk := domain.MessagePartitionKey(testChatID, domain.MessageShard(seq, shards))
+		f.messages[pk] = append(f.messages[pk], messageItem{
+			PK:          pk,
+			Sequence:    seq,
+			ChatID:      testChatID,
+			MessageID:   "msg-" + strconv.FormatUint(seq, 10),
+			ContentType: string(domain.ContentTypeText),
+			Content:     "hello",
+			CreatedAt:   "2026-02-10T12:00:00Z",
+		})
+	}
+	return f
+}
+
+func sequences(messages []app.MessageRecord) []uint64 {
+	seqs := make([]uint64, len(messages))
+	for i, m := range messages {
+		seqs[i] = m.Sequence
+	}
+	return seqs
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ListAfter
+// ---------------------------------------------------------------------------
+
+func TestMessageStore_ListAfter(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		shards      int
+		count       uint64
+		after       uint64
+		limit       int
+		pageSize    int
+		wantSeqs    []uint64
+		wantQueries int
+	}{
+		{name: "unsharded chat", shards: 0, count: 5, after: 2, limit: 10, wantSeqs: []uint64{3, 4, 5}, wantQueries: 1},
+		{name: "merges shards by sequence", shards: 4, count: 10, after: 3, limit: 10, wantSeqs: []uint64{4, 5, 6, 7, 8, 9, 10}, wantQueries: 4},
+		{name: "limit applies across shards", shards: 3, count: 20, after: 0, limit: 4, wantSeqs: []uint64{1, 2, 3, 4}, wantQueries: 3},
+		{name: "past the end", shards: 2, count: 4, after: 4, limit: 10, wantSeqs: []uint64{}, wantQueries: 2},
+		{name: "pages a shard until limit", shards: 0, count: 10, after: 0, limit: 5, pageSize: 2, wantSeqs: []uint64{1, 2, 3, 4, 5}, wantQueries: 3},
+		{name: "pages every shard", shards: 2, count: 12, after: 2, limit: 6, pageSize: 2, wantSeqs: []uint64{3, 4, 5, 6, 7, 8}, wantQueries: 6},
+		{name: "pages a shard to its end", shards: 0, count: 3, after: 0, limit: 10, pageSize: 2, wantSeqs: []uint64{1, 2, 3}, wantQueries: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeMessages(tt.shards, tt.count)
+			db.pageSize = tt.pageSize
+			store := NewMessageStore(db, "messages_v2", "chats", nil)
+
+			got, err := store.ListAfter(ctx, testChatID, tt.after, tt.limit)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSeqs, sequences(got))
+			assert.Len(t, db.queries, tt.wantQueries)
+		})
+	}
+}
+
+func TestMessageStore_ListAfter_ParsesRecord(t *testing.T) {
+	db := newFakeMessages(2, 1)
+	store := NewMessageStore(db, "messages_v2", "chats", nil)
+
+	got, err := store.ListAfter(context.Background(), testChatID, 0, 10)
+
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, app.MessageRecord{
+		MessageID:   "msg-1",
+		ChatID:      testChatID,
+		Sequence:    1,
+		ContentType: domain.ContentTypeText,
+		Content:     "hello",
+		CreatedAt:   time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC),
+	}, got[0])
+	assert.True(t, slices.Contains(db.queries, domain.MessagePartitionKey(testChatID, 1)))
+}
+
+// stubOpener "opens" content sealed as "<chat>/<message>:<plaintext>".
+type stubOpener struct{}
+
+func (stubOpener) Open(_ context.Context, chatID, messageID string, sealed msgcrypt.Sealed) ([]byte, error) {
+	plaintext, ok := strings.CutPrefix(string(sealed.Ciphertext), chatID+"/"+messageID+":")
+	if !ok || sealed.KeyVersion != 1 {
+		return nil, errors.New("content does not authenticate")
+	}
+	return []byte(plaintext), nil
+}
+
+func TestMessageStore_ListAfter_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeMessages(0, 2)
+	pk := domain.MessagePartitionKey(testChatID, 0)
+	db.messages[pk][1].Content = ""
+	db.messages[pk][1].ContentKey = 1
+	db.messages[pk][1].SealedContent = []byte(testChatID + "/msg-2:secret")
+
+	got, err := NewMessageStore(db, "messages_v2", "chats", stubOpener{}).ListAfter(ctx, testChatID, 0, 10)
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "hello", got[0].Content, "plaintext rows read as before")
+	assert.Equal(t, "secret", got[1].Content)
+
+	t.Run("without an opener", func(t *testing.T) {
+		_, err := NewMessageStore(db, "messages_v2", "chats", nil).ListAfter(ctx, testChatID, 0, 10)
+
+		assert.ErrorContains(t, err, "is encrypted")
+	})
+
+	t.Run("content that does not open", func(t *testing.T) {
+		db.messages[pk][1].SealedContent = []byte(testChatID + "/msg-1:secret")
+
+		_, err := NewMessageStore(db, "messages_v2", "chats", stubOpener{}).ListAfter(ctx, testChatID, 0, 10)
+
+		assert.ErrorContains(t, err, "does not authenticate")
+	})
+}
+
+func TestMessageStore_ListAfter_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("query failure", func(t *testing.T) {
+		db := newFakeMessages(2, 4)
+		db.queryErr = errors.New("throttled")
+		store := NewMessageStore(db, "messages_v2", "chats", nil)
+
+		_, err := store.ListAfter(ctx, testChatID, 0, 10)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "query shard 0")
+	})
+
+	t.Run("shard count out of range", func(t *testing.T) {
+		db := newFakeMessages(1, 0)
+		db.shards[testChatID] = domain.MaxMessageShards + 1
+		store := NewMessageStore(db, "messages_v2", "chats", nil)
+
+		_, err := store.ListAfter(ctx, testChatID, 0, 10)
+
+		require.Error(t, err)
+		assert.Empty(t, db.queries)
+	})
+}
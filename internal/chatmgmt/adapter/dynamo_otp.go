@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strconv"
-	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -33,11 +32,14 @@ type otpItem struct {
 	PhoneHash     string `dynamodbav:"phone_hash"`
 	OTPMAC        string `dynamodbav:"otp_mac"`
 	OTPCiphertext string `dynamodbav:"otp_ciphertext"`
+	CodeParams    string `dynamodbav:"code_params,omitempty"`
 	CreatedAt     string `dynamodbav:"created_at"`
 	ExpiresAt     string `dynamodbav:"expires_at"`
 	AttemptCount  int    `dynamodbav:"attempt_count"`
 	Status        string `dynamodbav:"status"`
 	TTL           int64  `dynamodbav:"ttl"`
+	ResendCount   int    `dynamodbav:"resend_count"`
+	ResendAt      string `dynamodbav:"resend_at,omitempty"`
 }
 
 // toOTPItem converts an app.OTPRecord to the DynamoDB item shape.
@@ -46,26 +48,44 @@ func toOTPItem(r app.OTPRecord) otpItem {
 		PhoneHash:     r.PhoneHash,
 		OTPMAC:        r.OTPMAC,
 		OTPCiphertext: r.OTPCiphertext,
-		CreatedAt:     r.CreatedAt,
-		ExpiresAt:     r.ExpiresAt,
+		CodeParams:    r.CodeParams,
+		CreatedAt:     r.CreatedAt.String(),
+		ExpiresAt:     r.ExpiresAt.String(),
 		AttemptCount:  r.AttemptCount,
 		Status:        r.Status,
 		TTL:           r.TTL,
+		ResendCount:   r.ResendCount,
+		ResendAt:      r.ResendAt.String(),
 	}
 }
 
 // fromOTPItem converts a DynamoDB item to an app.OTPRecord.
-func fromOTPItem(item otpItem) *app.OTPRecord {
+func fromOTPItem(item otpItem) (*app.OTPRecord, error) {
+	createdAt, err := domain.ParseTimestamp(item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	expiresAt, err := domain.ParseTimestamp(item.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse expires_at: %w", err)
+	}
+	resendAt, err := domain.ParseTimestamp(item.ResendAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse resend_at: %w", err)
+	}
 	return &app.OTPRecord{
 		PhoneHash:     item.PhoneHash,
 		OTPMAC:        item.OTPMAC,
 		OTPCiphertext: item.OTPCiphertext,
-		CreatedAt:     item.CreatedAt,
-		ExpiresAt:     item.ExpiresAt,
+		CodeParams:    item.CodeParams,
+		CreatedAt:     createdAt,
+		ExpiresAt:     expiresAt,
 		Status:        item.Status,
 		AttemptCount:  item.AttemptCount,
 		TTL:           item.TTL,
-	}
+		ResendCount:   item.ResendCount,
+		ResendAt:      resendAt,
+	}, nil
 }
 
 // OTPStore persists OTP records in DynamoDB.
@@ -110,7 +130,7 @@ func (s *OTPStore) CreateOTP(ctx context.Context, record app.OTPRecord) error {
 		return fmt.Errorf("otp store: marshal item: %w", err)
 	}
 
-	now := s.clock.Now().UTC().Format(time.RFC3339)
+	now := domain.TimestampNow(s.clock).String()
 
 	condExpr := "attribute_not_exists(phone_hash) OR #st = :verified OR #ea < :now"
 
@@ -175,12 +195,74 @@ func (s *OTPStore) GetOTP(ctx context.Context, phoneHash string) (*app.OTPRecord
 		return nil, fmt.Errorf("otp store: unmarshal otp: %w", err)
 	}
 
-	return fromOTPItem(item), nil
+	record, err := fromOTPItem(item)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("otp store: %w", err)
+	}
+	return record, nil
+}
+
+// ResendOTP replaces the code of a pending, unexpired OTP and records the
+// resend. The update is conditional on the resend count the caller read,
+// so of two concurrent resends only one applies; the other, like a resend
+// of a code already used or expired, receives domain.ErrVersionConflict.
+// Records from before resend backoff have no resend_count and match a
+// count of zero.
+func (s *OTPStore) ResendOTP(ctx context.Context, phoneHash string, resend app.OTPResend) error {
+	ctx, span := tracer.Start(ctx, "dynamo.otp.resend")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	updateExpr := "SET otp_mac = :mac, code_params = :params, resend_count = :n, resend_at = :at"
+	condExpr := "#st = :pending AND #ea > :now AND resend_count = :expected"
+	if resend.ExpectedResendCount == 0 {
+		condExpr = "#st = :pending AND #ea > :now AND (resend_count = :expected OR attribute_not_exists(resend_count))"
+	}
+
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"phone_hash": &dynamo.AttributeValueMemberS{Value: phoneHash},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeNames: map[string]string{
+			"#st": "status",
+			"#ea": "expires_at",
+		},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":mac":      &dynamo.AttributeValueMemberS{Value: resend.OTPMAC},
+			":params":   &dynamo.AttributeValueMemberS{Value: resend.CodeParams},
+			":n":        &dynamo.AttributeValueMemberN{Value: strconv.Itoa(resend.ResendCount)},
+			":at":       &dynamo.AttributeValueMemberS{Value: resend.ResendAt.String()},
+			":pending":  &dynamo.AttributeValueMemberS{Value: "pending"},
+			":now":      &dynamo.AttributeValueMemberS{Value: domain.TimestampNow(s.clock).String()},
+			":expected": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(resend.ExpectedResendCount)},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("otp store: resend otp: %w", domain.ErrVersionConflict)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("otp store: resend otp: %w", err)
+	}
+
+	return nil
 }
 
 // IncrementAttempts atomically increments the attempt_count attribute for
-// the OTP record identified by phoneHash.
-func (s *OTPStore) IncrementAttempts(ctx context.Context, phoneHash string) error {
+// the OTP record identified by phoneHash and returns the new count. The
+// update is conditional on attempt_count < limit, so concurrent failures
+// cannot push the count past limit: once it is reached the caller receives
+// domain.ErrRateLimited, or domain.ErrNotFound if the record is gone.
+func (s *OTPStore) IncrementAttempts(ctx context.Context, phoneHash string, limit int) (int, error) {
 	ctx, span := tracer.Start(ctx, "dynamo.otp.increment_attempts")
 	defer span.End()
 	span.SetAttributes(
@@ -189,22 +271,40 @@ func (s *OTPStore) IncrementAttempts(ctx context.Context, phoneHash string) erro
 	)
 
 	updateExpr := "SET attempt_count = attempt_count + :one"
+	condExpr := "attempt_count < :max"
 
-	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+	out, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
 		TableName: &s.tableName,
 		Key: map[string]dynamo.AttributeValue{
 			"phone_hash": &dynamo.AttributeValueMemberS{Value: phoneHash},
 		},
-		UpdateExpression: &updateExpr,
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
 		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
 			":one": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(1)},
+			":max": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(limit)},
 		},
+		ReturnValues:                        dynamo.ReturnValueUpdatedNew,
+		ReturnValuesOnConditionCheckFailure: dynamo.ReturnAllOldOnConditionCheckFailure,
 	})
+	if item, ok := dynamo.ConditionalCheckFailedItem(err); ok {
+		span.SetAttributes(attribute.Bool("otp.attempts_exhausted", len(item) > 0))
+		if len(item) == 0 {
+			return 0, fmt.Errorf("otp store: increment attempts: %w", domain.ErrNotFound)
+		}
+		return limit, fmt.Errorf("otp store: increment attempts: %w", domain.ErrRateLimited)
+	}
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return fmt.Errorf("otp store: increment attempts: %w", err)
+		return 0, fmt.Errorf("otp store: increment attempts: %w", err)
 	}
 
-	return nil
+	var updated struct {
+		AttemptCount int `dynamodbav:"attempt_count"`
+	}
+	if err := dynamo.UnmarshalMap(out.Attributes, &updated); err != nil {
+		return 0, fmt.Errorf("otp store: increment attempts: unmarshal: %w", err)
+	}
+	return updated.AttemptCount, nil
 }
@@ -55,8 +55,8 @@ func sampleRecord() app.OTPRecord {
 		PhoneHash:     "abc123hash",
 		OTPMAC:        "mac-value",
 		OTPCiphertext: "encrypted-otp",
-		CreatedAt:     "2026-02-10T12:00:00Z",
-		ExpiresAt:     "2026-02-10T12:05:00Z",
+		CreatedAt:     domain.NewTimestampMS(fixedTime()),
+		ExpiresAt:     domain.NewTimestampMS(fixedTime().Add(5 * time.Minute)),
 		AttemptCount:  0,
 		Status:        "pending",
 		TTL:           fixedTime().Add(1 * time.Hour).Unix(),
@@ -187,8 +187,8 @@ func TestGetOTP(t *testing.T) {
 				PhoneHash:     "abc123hash",
 				OTPMAC:        "mac-value",
 				OTPCiphertext: "encrypted-otp",
-				CreatedAt:     "2026-02-10T12:00:00Z",
-				ExpiresAt:     "2026-02-10T12:05:00Z",
+				CreatedAt:     domain.NewTimestampMS(fixedTime()),
+				ExpiresAt:     domain.NewTimestampMS(fixedTime().Add(5 * time.Minute)),
 				AttemptCount:  2,
 				Status:        "pending",
 				TTL:           fixedTime().Add(1 * time.Hour).Unix(),
@@ -246,6 +246,78 @@ func TestGetOTP(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Tests — ResendOTP
+// ---------------------------------------------------------------------------
+
+func TestResendOTP(t *testing.T) {
+	resend := app.OTPResend{
+		OTPMAC:              "new-mac",
+		CodeParams:          "len=6;alphabet=0123456789;ttl=300",
+		ResendCount:         2,
+		ResendAt:            domain.NewTimestampMS(fixedTime().Add(2 * time.Minute)),
+		ExpectedResendCount: 1,
+	}
+
+	t.Run("success - replaces the code conditional on the resend count", func(t *testing.T) {
+		store := NewOTPStore(&stubOTPDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, testTable, *params.TableName)
+				assert.Equal(t, "abc123hash", params.Key["phone_hash"].(*dynamo.AttributeValueMemberS).Value)
+				assert.Equal(t, "SET otp_mac = :mac, code_params = :params, resend_count = :n, resend_at = :at", *params.UpdateExpression)
+				assert.Equal(t, "#st = :pending AND #ea > :now AND resend_count = :expected", *params.ConditionExpression)
+
+				values := params.ExpressionAttributeValues
+				assert.Equal(t, "new-mac", values[":mac"].(*dynamo.AttributeValueMemberS).Value)
+				assert.Equal(t, "2", values[":n"].(*dynamo.AttributeValueMemberN).Value)
+				assert.Equal(t, "1", values[":expected"].(*dynamo.AttributeValueMemberN).Value)
+				assert.Equal(t, resend.ResendAt.String(), values[":at"].(*dynamo.AttributeValueMemberS).Value)
+				assert.Equal(t, domain.NewTimestampMS(fixedTime()).String(), values[":now"].(*dynamo.AttributeValueMemberS).Value)
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, testTable, domaintest.NewFakeClock(fixedTime()))
+
+		require.NoError(t, store.ResendOTP(context.Background(), "abc123hash", resend))
+	})
+
+	t.Run("first resend - matches records without a resend count", func(t *testing.T) {
+		store := NewOTPStore(&stubOTPDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Contains(t, *params.ConditionExpression, "attribute_not_exists(resend_count)")
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, testTable, domaintest.NewFakeClock(fixedTime()))
+
+		first := resend
+		first.ResendCount, first.ExpectedResendCount = 1, 0
+		require.NoError(t, store.ResendOTP(context.Background(), "abc123hash", first))
+	})
+
+	t.Run("condition failed - ErrVersionConflict", func(t *testing.T) {
+		store := NewOTPStore(&stubOTPDynamo{
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, testTable, domaintest.NewFakeClock(fixedTime()))
+
+		err := store.ResendOTP(context.Background(), "abc123hash", resend)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+	})
+
+	t.Run("dynamo error - wraps with context", func(t *testing.T) {
+		store := NewOTPStore(&stubOTPDynamo{
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, errors.New("internal server error")
+			},
+		}, testTable, domaintest.NewFakeClock(fixedTime()))
+
+		err := store.ResendOTP(context.Background(), "abc123hash", resend)
+
+		assert.EqualError(t, err, "otp store: resend otp: internal server error")
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Tests — IncrementAttempts
 // ---------------------------------------------------------------------------
@@ -254,7 +326,9 @@ func TestIncrementAttempts(t *testing.T) {
 	tests := []struct {
 		name         string
 		updateItemFn func(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+		wantCount    int
 		wantErr      bool
+		wantErrIs    error
 		errSubstr    string
 	}{
 		{
@@ -277,8 +351,39 @@ func TestIncrementAttempts(t *testing.T) {
 				require.True(t, ok)
 				assert.Equal(t, "1", oneVal.Value)
 
-				return &dynamo.UpdateItemOutput{}, nil
+				// Verify the increment is capped at the limit.
+				require.NotNil(t, params.ConditionExpression)
+				assert.Equal(t, "attempt_count < :max", *params.ConditionExpression)
+				maxVal, ok := params.ExpressionAttributeValues[":max"].(*dynamo.AttributeValueMemberN)
+				require.True(t, ok)
+				assert.Equal(t, "5", maxVal.Value)
+				assert.Equal(t, dynamo.ReturnValueUpdatedNew, params.ReturnValues)
+
+				return &dynamo.UpdateItemOutput{Attributes: map[string]dynamo.AttributeValue{
+					"attempt_count": &dynamo.AttributeValueMemberN{Value: "3"},
+				}}, nil
+			},
+			wantCount: 3,
+		},
+		{
+			name: "limit reached - ErrRateLimited",
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailedWithItem(map[string]dynamo.AttributeValue{
+					"phone_hash":    &dynamo.AttributeValueMemberS{Value: "abc123hash"},
+					"attempt_count": &dynamo.AttributeValueMemberN{Value: "5"},
+				})
 			},
+			wantCount: 5,
+			wantErr:   true,
+			wantErrIs: domain.ErrRateLimited,
+		},
+		{
+			name: "record gone - ErrNotFound",
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+			wantErr:   true,
+			wantErrIs: domain.ErrNotFound,
 		},
 		{
 			name: "dynamo error - wraps with context",
@@ -297,11 +402,15 @@ func TestIncrementAttempts(t *testing.T) {
 				updateItemFn: tt.updateItemFn,
 			}, testTable, clock)
 
-			err := store.IncrementAttempts(context.Background(), "abc123hash")
+			count, err := store.IncrementAttempts(context.Background(), "abc123hash", 5)
 
+			assert.Equal(t, tt.wantCount, count)
 			if tt.wantErr {
 				require.Error(t, err)
 				assert.Contains(t, err.Error(), tt.errSubstr)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(t, err, tt.wantErrIs)
+				}
 				return
 			}
 
@@ -309,3 +418,35 @@ func TestIncrementAttempts(t *testing.T) {
 		})
 	}
 }
+
+func TestOTPItem_CodeParams(t *testing.T) {
+	legacy, err := dynamo.MarshalMap(toOTPItem(sampleRecord()))
+	require.NoError(t, err)
+	assert.NotContains(t, legacy, "code_params", "pre-policy records keep their original shape")
+
+	rec := sampleRecord()
+	rec.CodeParams = "len=8;alphabet=0123456789;ttl=180"
+	av, err := dynamo.MarshalMap(toOTPItem(rec))
+	require.NoError(t, err)
+
+	var item otpItem
+	require.NoError(t, dynamo.UnmarshalMap(av, &item))
+	got, err := fromOTPItem(item)
+	require.NoError(t, err)
+	assert.Equal(t, rec.CodeParams, got.CodeParams)
+}
+
+func TestOTPItem_Resend(t *testing.T) {
+	rec := sampleRecord()
+	rec.ResendCount = 1
+	rec.ResendAt = domain.NewTimestampMS(fixedTime().Add(time.Minute))
+	av, err := dynamo.MarshalMap(toOTPItem(rec))
+	require.NoError(t, err)
+
+	var item otpItem
+	require.NoError(t, dynamo.UnmarshalMap(av, &item))
+	got, err := fromOTPItem(item)
+	require.NoError(t, err)
+	assert.Equal(t, rec.ResendCount, got.ResendCount)
+	assert.Equal(t, rec.ResendAt, got.ResendAt)
+}
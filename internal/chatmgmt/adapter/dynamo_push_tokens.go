@@ -0,0 +1,83 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: PushTokenStore satisfies app.PushTokenStore.
+var _ app.PushTokenStore = (*PushTokenStore)(nil)
+
+// pushTokenDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the push token store.
+type pushTokenDynamoDB interface {
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+}
+
+// pushTokenItem is the DynamoDB item shape for the device_tokens table
+// (PK user_id, SK device_id).
+type pushTokenItem struct {
+	UserID    string `dynamodbav:"user_id"`
+	DeviceID  string `dynamodbav:"device_id"`
+	Platform  string `dynamodbav:"platform"`
+	Token     string `dynamodbav:"token"`
+	P256DH    string `dynamodbav:"p256dh,omitempty"`
+	Auth      string `dynamodbav:"auth,omitempty"`
+	UpdatedAt string `dynamodbav:"updated_at"`
+}
+
+// PushTokenStore writes device push tokens to the device_tokens table.
+type PushTokenStore struct {
+	db        pushTokenDynamoDB
+	tableName string
+}
+
+// NewPushTokenStore creates a PushTokenStore backed by the given DynamoDB client.
+func NewPushTokenStore(db pushTokenDynamoDB, tableName string) *PushTokenStore {
+	return &PushTokenStore{db: db, tableName: tableName}
+}
+
+// PutPushToken creates or replaces the device's token. The write is
+// unconditional: the latest registration from a device always wins.
+func (s *PushTokenStore) PutPushToken(ctx context.Context, token domain.DeviceToken) error {
+	ctx, span := tracer.Start(ctx, "dynamo.device_tokens.put")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(pushTokenItem{
+		UserID:    token.UserID,
+		DeviceID:  token.DeviceID,
+		Platform:  string(token.Platform),
+		Token:     token.Token,
+		P256DH:    token.P256DH,
+		Auth:      token.Auth,
+		UpdatedAt: token.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("push token store: marshal token: %w", err)
+	}
+
+	if _, err := s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName: &s.tableName,
+		Item:      av,
+	}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("push token store: put: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements pushTokenDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubPushTokenDynamo struct {
+	putItemFn func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+}
+
+func (s *stubPushTokenDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+var _ pushTokenDynamoDB = (*stubPushTokenDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestPushTokenStore_PutPushToken(t *testing.T) {
+	ctx := context.Background()
+	token := domain.DeviceToken{
+		UserID:    "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+		DeviceID:  "dddddddd-eeee-ffff-0000-111111111111",
+		Platform:  domain.PushPlatformFCM,
+		Token:     "fcm-token",
+		UpdatedAt: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("writes the item without a condition", func(t *testing.T) {
+		var got *dynamo.PutItemInput
+		store := NewPushTokenStore(&stubPushTokenDynamo{
+			putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				got = params
+				return &dynamo.PutItemOutput{}, nil
+			},
+		}, "device_tokens")
+
+		require.NoError(t, store.PutPushToken(ctx, token))
+
+		require.NotNil(t, got)
+		assert.Equal(t, "device_tokens", *got.TableName)
+		assert.Nil(t, got.ConditionExpression, "the latest registration wins")
+
+		var item pushTokenItem
+		require.NoError(t, dynamo.UnmarshalMap(got.Item, &item))
+		assert.Equal(t, pushTokenItem{
+			UserID:    token.UserID,
+			DeviceID:  token.DeviceID,
+			Platform:  "fcm",
+			Token:     "fcm-token",
+			UpdatedAt: "2026-03-02T12:00:00Z",
+		}, item)
+	})
+
+	t.Run("put failure", func(t *testing.T) {
+		store := NewPushTokenStore(&stubPushTokenDynamo{
+			putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "device_tokens")
+
+		err := store.PutPushToken(ctx, token)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "push token store: put")
+	})
+}
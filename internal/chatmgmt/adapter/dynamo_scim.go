@@ -0,0 +1,602 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// Compile-time check: SCIMStore satisfies app.SCIMStore.
+var _ app.SCIMStore = (*SCIMStore)(nil)
+
+// Sort key prefixes of the scim_resources table.
+const (
+	scimMemberPrefix   = "user#"
+	scimGroupPrefix    = "group#"
+	scimUserNamePrefix = "username#"
+)
+
+// scimDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the SCIM store.
+type scimDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// scimMemberItem is a scim_resources member item (PK workspace_id, SK
+// resource "user#<user_id>").
+type scimMemberItem struct {
+	WorkspaceID string `dynamodbav:"workspace_id"`
+	Resource    string `dynamodbav:"resource"`
+	UserID      string `dynamodbav:"user_id"`
+	UserName    string `dynamodbav:"user_name"`
+	ExternalID  string `dynamodbav:"external_id,omitempty"`
+	DisplayName string `dynamodbav:"display_name,omitempty"`
+	Active      bool   `dynamodbav:"active"`
+	CreatedAt   string `dynamodbav:"created_at"`
+	UpdatedAt   string `dynamodbav:"updated_at"`
+}
+
+// scimUserNameItem reserves a normalized userName in its workspace (SK
+// "username#<name>") for the member that holds it.
+type scimUserNameItem struct {
+	WorkspaceID string `dynamodbav:"workspace_id"`
+	Resource    string `dynamodbav:"resource"`
+	UserID      string `dynamodbav:"user_id"`
+}
+
+// scimGroupItem is a scim_resources group item (SK "group#<group_id>").
+type scimGroupItem struct {
+	WorkspaceID string   `dynamodbav:"workspace_id"`
+	Resource    string   `dynamodbav:"resource"`
+	GroupID     string   `dynamodbav:"group_id"`
+	DisplayName string   `dynamodbav:"display_name"`
+	ExternalID  string   `dynamodbav:"external_id,omitempty"`
+	MemberIDs   []string `dynamodbav:"member_ids,omitempty"`
+	Version     int64    `dynamodbav:"version"`
+	CreatedAt   string   `dynamodbav:"created_at"`
+	UpdatedAt   string   `dynamodbav:"updated_at"`
+}
+
+// SCIMStore persists SCIM-provisioned members and groups in the
+// scim_resources table, one partition per workspace. Members' accounts
+// are written to the users table alongside them.
+type SCIMStore struct {
+	db         scimDynamoDB
+	tableName  string
+	usersTable string
+	fields     *msgcrypt.FieldCipher
+}
+
+// NewSCIMStore creates a SCIMStore backed by the given DynamoDB client.
+// Members' personal data in the users table is encrypted with fields, or
+// stored in plaintext if it is nil.
+func NewSCIMStore(db scimDynamoDB, tableName, usersTable string, fields *msgcrypt.FieldCipher) *SCIMStore {
+	return &SCIMStore{db: db, tableName: tableName, usersTable: usersTable, fields: fields}
+}
+
+// GetMember reads a member with a strongly consistent read. Returns
+// domain.ErrNotFound if there is none.
+func (s *SCIMStore) GetMember(ctx context.Context, workspaceID, userID string) (*domain.WorkspaceMember, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.get_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	var item scimMemberItem
+	if err := s.get(ctx, workspaceID, scimMemberPrefix+userID, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim store: get member: %w", err)
+	}
+	return fromSCIMMemberItem(item)
+}
+
+// FindMemberByUserName reads the member holding userName, ignoring case.
+// Returns domain.ErrNotFound if nobody holds it.
+func (s *SCIMStore) FindMemberByUserName(ctx context.Context, workspaceID, userName string) (*domain.WorkspaceMember, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.find_member_by_user_name")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	var item scimUserNameItem
+	if err := s.get(ctx, workspaceID, scimUserNameKey(userName), &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim store: find member: %w", err)
+	}
+	return s.GetMember(ctx, workspaceID, item.UserID)
+}
+
+// ListMembers returns every member of the workspace in user ID order.
+func (s *SCIMStore) ListMembers(ctx context.Context, workspaceID string) ([]domain.WorkspaceMember, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.list_members")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	var members []domain.WorkspaceMember
+	err := s.queryPrefix(ctx, workspaceID, scimMemberPrefix, func(av map[string]dynamo.AttributeValue) error {
+		var item scimMemberItem
+		if err := dynamo.UnmarshalMap(av, &item); err != nil {
+			return fmt.Errorf("unmarshal member: %w", err)
+		}
+		m, err := fromSCIMMemberItem(item)
+		if err != nil {
+			return err
+		}
+		members = append(members, *m)
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim store: list members: %w", err)
+	}
+	return members, nil
+}
+
+// CreateMember executes a 3-item TransactWriteItems:
+//
+//	[0] memberPut — the member item
+//	[1] userNamePut — reserves the normalized userName
+//	[2] userPut — creates the phone-less user in users table
+//
+// Returns domain.ErrAlreadyExists if the userName is taken.
+func (s *SCIMStore) CreateMember(ctx context.Context, member domain.WorkspaceMember, user app.UserRecord) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.create_scim_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	memberAV, err := dynamo.MarshalMap(toSCIMMemberItem(member))
+	if err != nil {
+		return fmt.Errorf("scim store: marshal member: %w", err)
+	}
+	userNameAV, err := s.userNameItem(member)
+	if err != nil {
+		return err
+	}
+	item, err := encryptedUserItem(ctx, s.fields, user)
+	if err != nil {
+		return fmt.Errorf("scim store: %w", err)
+	}
+	userAV, err := dynamo.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("scim store: marshal user: %w", err)
+	}
+	resourceCond := "attribute_not_exists(#resource)"
+	userCond := "attribute_not_exists(user_id)"
+	names := map[string]string{"#resource": "resource"}
+
+	_, err = s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{Put: &dynamo.Put{TableName: &s.tableName, Item: memberAV, ConditionExpression: &resourceCond, ExpressionAttributeNames: names}},
+			{Put: &dynamo.Put{TableName: &s.tableName, Item: userNameAV, ConditionExpression: &resourceCond, ExpressionAttributeNames: names}},
+			{Put: &dynamo.Put{TableName: &s.usersTable, Item: userAV, ConditionExpression: &userCond}},
+		},
+	})
+	if err != nil {
+		if scimConditionFailed(err, 1) {
+			return fmt.Errorf("scim store: create member: userName taken: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim store: create member: %w", err)
+	}
+	return nil
+}
+
+// UpdateMember executes a TransactWriteItems of:
+//
+//	[0] memberPut — replaces the member, conditional on it existing
+//	[1] userUpdate — copies the display name to the users table
+//	[2] userNameDelete — frees prevUserName (only if the userName changed)
+//	[3] userNamePut — reserves the new userName (only if it changed)
+//
+// Returns domain.ErrNotFound if the member is gone and
+// domain.ErrAlreadyExists if the new userName is taken.
+func (s *SCIMStore) UpdateMember(ctx context.Context, member domain.WorkspaceMember, prevUserName string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.update_scim_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	memberAV, err := dynamo.MarshalMap(toSCIMMemberItem(member))
+	if err != nil {
+		return fmt.Errorf("scim store: marshal member: %w", err)
+	}
+	displayName, err := s.fields.Encrypt(ctx, displayNameField, member.DisplayName)
+	if err != nil {
+		return fmt.Errorf("scim store: %w", err)
+	}
+	existsCond := "attribute_exists(#resource)"
+	notExistsCond := "attribute_not_exists(#resource)"
+	names := map[string]string{"#resource": "resource"}
+	userUpdate := "SET display_name = :name, updated_at = :updated"
+	userCond := "attribute_exists(user_id)"
+
+	items := []dynamo.TransactWriteItem{
+		{Put: &dynamo.Put{TableName: &s.tableName, Item: memberAV, ConditionExpression: &existsCond, ExpressionAttributeNames: names}},
+		{Update: &dynamo.Update{
+			TableName:           &s.usersTable,
+			Key:                 map[string]dynamo.AttributeValue{"user_id": &dynamo.AttributeValueMemberS{Value: member.UserID}},
+			UpdateExpression:    &userUpdate,
+			ConditionExpression: &userCond,
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":name":    &dynamo.AttributeValueMemberS{Value: displayName},
+				":updated": &dynamo.AttributeValueMemberS{Value: domain.NewTimestampMS(member.UpdatedAt).String()},
+			},
+		}},
+	}
+	if scimUserNameKey(prevUserName) != scimUserNameKey(member.UserName) {
+		userNameAV, err := s.userNameItem(member)
+		if err != nil {
+			return err
+		}
+		items = append(items,
+			dynamo.TransactWriteItem{Delete: &dynamo.Delete{TableName: &s.tableName, Key: s.key(member.WorkspaceID, scimUserNameKey(prevUserName))}},
+			dynamo.TransactWriteItem{Put: &dynamo.Put{TableName: &s.tableName, Item: userNameAV, ConditionExpression: &notExistsCond, ExpressionAttributeNames: names}},
+		)
+	}
+
+	_, err = s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		switch {
+		case scimConditionFailed(err, 0):
+			return fmt.Errorf("scim store: update member: %w", domain.ErrNotFound)
+		case scimConditionFailed(err, 3):
+			return fmt.Errorf("scim store: update member: userName taken: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim store: update member: %w", err)
+	}
+	return nil
+}
+
+// DeleteMember deletes the member and its userName reservation in one
+// transaction. The users table item stays.
+func (s *SCIMStore) DeleteMember(ctx context.Context, member domain.WorkspaceMember) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.delete_scim_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	_, err := s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{Delete: &dynamo.Delete{TableName: &s.tableName, Key: s.key(member.WorkspaceID, scimMemberPrefix+member.UserID)}},
+			{Delete: &dynamo.Delete{TableName: &s.tableName, Key: s.key(member.WorkspaceID, scimUserNameKey(member.UserName))}},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim store: delete member: %w", err)
+	}
+	return nil
+}
+
+// GetGroup reads a group with a strongly consistent read. Returns
+// domain.ErrNotFound if there is none.
+func (s *SCIMStore) GetGroup(ctx context.Context, workspaceID, groupID string) (*domain.WorkspaceGroup, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.get_group")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	var item scimGroupItem
+	if err := s.get(ctx, workspaceID, scimGroupPrefix+groupID, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim store: get group: %w", err)
+	}
+	return fromSCIMGroupItem(item)
+}
+
+// ListGroups returns every group of the workspace in group ID order.
+func (s *SCIMStore) ListGroups(ctx context.Context, workspaceID string) ([]domain.WorkspaceGroup, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.list_groups")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	var groups []domain.WorkspaceGroup
+	err := s.queryPrefix(ctx, workspaceID, scimGroupPrefix, func(av map[string]dynamo.AttributeValue) error {
+		var item scimGroupItem
+		if err := dynamo.UnmarshalMap(av, &item); err != nil {
+			return fmt.Errorf("unmarshal group: %w", err)
+		}
+		g, err := fromSCIMGroupItem(item)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, *g)
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim store: list groups: %w", err)
+	}
+	return groups, nil
+}
+
+// CreateGroup puts a new group, conditional on its ID being unused.
+func (s *SCIMStore) CreateGroup(ctx context.Context, group domain.WorkspaceGroup) error {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.create_group")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(toSCIMGroupItem(group))
+	if err != nil {
+		return fmt.Errorf("scim store: marshal group: %w", err)
+	}
+	cond := "attribute_not_exists(#resource)"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:                &s.tableName,
+		Item:                     av,
+		ConditionExpression:      &cond,
+		ExpressionAttributeNames: map[string]string{"#resource": "resource"},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("scim store: create group: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim store: create group: %w", err)
+	}
+	return nil
+}
+
+// UpdateGroup replaces the group at group.Version with version+1.
+// Returns domain.ErrVersionConflict if the stored version differs or the
+// group is gone.
+func (s *SCIMStore) UpdateGroup(ctx context.Context, group domain.WorkspaceGroup) error {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.update_group")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	item := toSCIMGroupItem(group)
+	item.Version = group.Version + 1
+	av, err := dynamo.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("scim store: marshal group: %w", err)
+	}
+	cond := "version = :version"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &s.tableName,
+		Item:                av,
+		ConditionExpression: &cond,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":version": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(group.Version, 10)},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("scim store: update group: %w", domain.ErrVersionConflict)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim store: update group: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group. Returns domain.ErrNotFound if there is
+// none.
+func (s *SCIMStore) DeleteGroup(ctx context.Context, workspaceID, groupID string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.scim.delete_group")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "DeleteItem"),
+	)
+
+	cond := "attribute_exists(#resource)"
+	_, err := s.db.DeleteItem(ctx, &dynamo.DeleteItemInput{
+		TableName:                &s.tableName,
+		Key:                      s.key(workspaceID, scimGroupPrefix+groupID),
+		ConditionExpression:      &cond,
+		ExpressionAttributeNames: map[string]string{"#resource": "resource"},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("scim store: delete group: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim store: delete group: %w", err)
+	}
+	return nil
+}
+
+// get reads one item with a strongly consistent read into dst.
+func (s *SCIMStore) get(ctx context.Context, workspaceID, resource string, dst any) error {
+	consistentRead := true
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName:      &s.tableName,
+		Key:            s.key(workspaceID, resource),
+		ConsistentRead: &consistentRead,
+	})
+	if err != nil {
+		return err
+	}
+	if out.Item == nil {
+		return domain.ErrNotFound
+	}
+	if err := dynamo.UnmarshalMap(out.Item, dst); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+	return nil
+}
+
+// queryPrefix passes every item of the workspace whose resource starts
+// with prefix to fn, following pages.
+func (s *SCIMStore) queryPrefix(ctx context.Context, workspaceID, prefix string, fn func(map[string]dynamo.AttributeValue) error) error {
+	keyExpr := "workspace_id = :ws AND begins_with(#resource, :prefix)"
+	consistentRead := true
+	in := &dynamo.QueryInput{
+		TableName:                &s.tableName,
+		KeyConditionExpression:   &keyExpr,
+		ExpressionAttributeNames: map[string]string{"#resource": "resource"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":ws":     &dynamo.AttributeValueMemberS{Value: workspaceID},
+			":prefix": &dynamo.AttributeValueMemberS{Value: prefix},
+		},
+		ConsistentRead: &consistentRead,
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out, err := s.db.Query(ctx, in)
+		if err != nil {
+			return err
+		}
+		for _, av := range out.Items {
+			if err := fn(av); err != nil {
+				return err
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func (s *SCIMStore) key(workspaceID, resource string) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{
+		"workspace_id": &dynamo.AttributeValueMemberS{Value: workspaceID},
+		"resource":     &dynamo.AttributeValueMemberS{Value: resource},
+	}
+}
+
+func (s *SCIMStore) userNameItem(member domain.WorkspaceMember) (map[string]dynamo.AttributeValue, error) {
+	av, err := dynamo.MarshalMap(scimUserNameItem{
+		WorkspaceID: member.WorkspaceID,
+		Resource:    scimUserNameKey(member.UserName),
+		UserID:      member.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scim store: marshal userName: %w", err)
+	}
+	return av, nil
+}
+
+func scimUserNameKey(userName string) string {
+	return scimUserNamePrefix + domain.NormalizeUserName(userName)
+}
+
+// scimConditionFailed reports whether err is a canceled transaction whose
+// item i failed its condition.
+func scimConditionFailed(err error, i int) bool {
+	reasons, ok := dynamo.IsTransactionCanceledException(err)
+	return ok && i < len(reasons) && reasons[i] == "ConditionalCheckFailed"
+}
+
+func toSCIMMemberItem(m domain.WorkspaceMember) scimMemberItem {
+	return scimMemberItem{
+		WorkspaceID: m.WorkspaceID,
+		Resource:    scimMemberPrefix + m.UserID,
+		UserID:      m.UserID,
+		UserName:    m.UserName,
+		ExternalID:  m.ExternalID,
+		DisplayName: m.DisplayName,
+		Active:      m.Active,
+		CreatedAt:   domain.NewTimestampMS(m.CreatedAt).String(),
+		UpdatedAt:   domain.NewTimestampMS(m.UpdatedAt).String(),
+	}
+}
+
+func fromSCIMMemberItem(item scimMemberItem) (*domain.WorkspaceMember, error) {
+	createdAt, err := domain.ParseTimestamp(item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	updatedAt, err := domain.ParseTimestamp(item.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &domain.WorkspaceMember{
+		WorkspaceID: item.WorkspaceID,
+		UserID:      item.UserID,
+		UserName:    item.UserName,
+		ExternalID:  item.ExternalID,
+		DisplayName: item.DisplayName,
+		Active:      item.Active,
+		CreatedAt:   createdAt.Time(),
+		UpdatedAt:   updatedAt.Time(),
+	}, nil
+}
+
+func toSCIMGroupItem(g domain.WorkspaceGroup) scimGroupItem {
+	return scimGroupItem{
+		WorkspaceID: g.WorkspaceID,
+		Resource:    scimGroupPrefix + g.GroupID,
+		GroupID:     g.GroupID,
+		DisplayName: g.DisplayName,
+		ExternalID:  g.ExternalID,
+		MemberIDs:   g.MemberIDs,
+		Version:     g.Version,
+		CreatedAt:   domain.NewTimestampMS(g.CreatedAt).String(),
+		UpdatedAt:   domain.NewTimestampMS(g.UpdatedAt).String(),
+	}
+}
+
+func fromSCIMGroupItem(item scimGroupItem) (*domain.WorkspaceGroup, error) {
+	createdAt, err := domain.ParseTimestamp(item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	updatedAt, err := domain.ParseTimestamp(item.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	return &domain.WorkspaceGroup{
+		WorkspaceID: item.WorkspaceID,
+		GroupID:     item.GroupID,
+		DisplayName: item.DisplayName,
+		ExternalID:  item.ExternalID,
+		MemberIDs:   item.MemberIDs,
+		Version:     item.Version,
+		CreatedAt:   createdAt.Time(),
+		UpdatedAt:   updatedAt.Time(),
+	}, nil
+}
@@ -0,0 +1,262 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements scimDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubSCIMDynamo struct {
+	getItemFn    func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	putItemFn    func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	deleteItemFn func(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+	queryFn      func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	transactFn   func(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+func (s *stubSCIMDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubSCIMDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+func (s *stubSCIMDynamo) DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+	return s.deleteItemFn(ctx, params, optFns...)
+}
+
+func (s *stubSCIMDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+func (s *stubSCIMDynamo) TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	return s.transactFn(ctx, params, optFns...)
+}
+
+var _ scimDynamoDB = (*stubSCIMDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func testSCIMMember() domain.WorkspaceMember {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	return domain.WorkspaceMember{
+		WorkspaceID: "ws-001",
+		UserID:      "user-001",
+		UserName:    "Alice@Example.com",
+		ExternalID:  "ext-1",
+		DisplayName: "Alice",
+		Active:      true,
+		CreatedAt:   at,
+		UpdatedAt:   at,
+	}
+}
+
+func TestSCIMStore_MemberRoundTrip(t *testing.T) {
+	member := testSCIMMember()
+	items := map[string]map[string]dynamo.AttributeValue{}
+
+	store := NewSCIMStore(&stubSCIMDynamo{
+		transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+			require.Len(t, params.TransactItems, 3)
+			assert.Equal(t, "users", *params.TransactItems[2].Put.TableName)
+			for _, ti := range params.TransactItems[:2] {
+				assert.Equal(t, "scim_resources", *ti.Put.TableName)
+				items[ti.Put.Item["resource"].(*dynamo.AttributeValueMemberS).Value] = ti.Put.Item
+			}
+			return &dynamo.TransactWriteItemsOutput{}, nil
+		},
+		getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			assert.True(t, *params.ConsistentRead)
+			return &dynamo.GetItemOutput{Item: items[params.Key["resource"].(*dynamo.AttributeValueMemberS).Value]}, nil
+		},
+	}, "scim_resources", "users", nil)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateMember(ctx, member, app.UserRecord{UserID: member.UserID, DisplayName: member.DisplayName}))
+	assert.Contains(t, items, "username#alice@example.com")
+
+	got, err := store.FindMemberByUserName(ctx, "ws-001", " ALICE@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, member, *got)
+
+	_, err = store.GetMember(ctx, "ws-001", "user-404")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestSCIMStore_CreateMember_UserNameTaken(t *testing.T) {
+	store := NewSCIMStore(&stubSCIMDynamo{
+		transactFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+			return nil, dynamo.ErrTransactionCanceled("None", "ConditionalCheckFailed", "None")
+		},
+	}, "scim_resources", "users", nil)
+
+	err := store.CreateMember(context.Background(), testSCIMMember(), app.UserRecord{UserID: "user-001"})
+
+	assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+}
+
+func TestSCIMStore_UpdateMember(t *testing.T) {
+	tests := []struct {
+		name         string
+		prevUserName string
+		txErr        error
+		wantItems    int
+		wantErr      error
+	}{
+		{name: "same userName", prevUserName: "alice@example.com", wantItems: 2},
+		{name: "renamed", prevUserName: "alice@old.example.com", wantItems: 4},
+		{
+			name:         "member gone",
+			prevUserName: "alice@example.com",
+			txErr:        dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None"),
+			wantItems:    2,
+			wantErr:      domain.ErrNotFound,
+		},
+		{
+			name:         "new userName taken",
+			prevUserName: "alice@old.example.com",
+			txErr:        dynamo.ErrTransactionCanceled("None", "None", "None", "ConditionalCheckFailed"),
+			wantItems:    4,
+			wantErr:      domain.ErrAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSCIMStore(&stubSCIMDynamo{
+				transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+					require.Len(t, params.TransactItems, tt.wantItems)
+					update := params.TransactItems[1].Update
+					assert.Equal(t, "users", *update.TableName)
+					assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "Alice"}, update.ExpressionAttributeValues[":name"])
+					if tt.wantItems == 4 {
+						assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "username#alice@old.example.com"},
+							params.TransactItems[2].Delete.Key["resource"])
+					}
+					return &dynamo.TransactWriteItemsOutput{}, tt.txErr
+				},
+			}, "scim_resources", "users", nil)
+
+			err := store.UpdateMember(context.Background(), testSCIMMember(), tt.prevUserName)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSCIMStore_ListMembers_Paginates(t *testing.T) {
+	page1, err := dynamo.MarshalMap(toSCIMMemberItem(testSCIMMember()))
+	require.NoError(t, err)
+	second := testSCIMMember()
+	second.UserID = "user-002"
+	page2, err := dynamo.MarshalMap(toSCIMMemberItem(second))
+	require.NoError(t, err)
+
+	calls := 0
+	store := NewSCIMStore(&stubSCIMDynamo{
+		queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			calls++
+			assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "user#"}, params.ExpressionAttributeValues[":prefix"])
+			if calls == 1 {
+				assert.Nil(t, params.ExclusiveStartKey)
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{page1}, LastEvaluatedKey: page1}, nil
+			}
+			assert.NotNil(t, params.ExclusiveStartKey)
+			return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{page2}}, nil
+		},
+	}, "scim_resources", "users", nil)
+
+	members, err := store.ListMembers(context.Background(), "ws-001")
+
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "user-002", members[1].UserID)
+}
+
+func TestSCIMStore_UpdateGroup(t *testing.T) {
+	group := domain.WorkspaceGroup{
+		WorkspaceID: "ws-001",
+		GroupID:     "group-001",
+		DisplayName: "Engineering",
+		MemberIDs:   []string{"user-001"},
+		Version:     3,
+		CreatedAt:   time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("writes next version", func(t *testing.T) {
+		store := NewSCIMStore(&stubSCIMDynamo{
+			putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				assert.Equal(t, "version = :version", *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "3"}, params.ExpressionAttributeValues[":version"])
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "4"}, params.Item["version"])
+				return &dynamo.PutItemOutput{}, nil
+			},
+		}, "scim_resources", "users", nil)
+
+		assert.NoError(t, store.UpdateGroup(context.Background(), group))
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		store := NewSCIMStore(&stubSCIMDynamo{
+			putItemFn: func(_ context.Context, _ *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "scim_resources", "users", nil)
+
+		assert.ErrorIs(t, store.UpdateGroup(context.Background(), group), domain.ErrVersionConflict)
+	})
+}
+
+func TestSCIMStore_DeleteGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "deleted"},
+		{name: "missing", err: dynamo.ErrConditionalCheckFailed(), wantErr: domain.ErrNotFound},
+		{name: "dynamo error", err: errors.New("throttled")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSCIMStore(&stubSCIMDynamo{
+				deleteItemFn: func(_ context.Context, params *dynamo.DeleteItemInput, _ ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+					assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "group#group-001"}, params.Key["resource"])
+					return &dynamo.DeleteItemOutput{}, tt.err
+				},
+			}, "scim_resources", "users", nil)
+
+			err := store.DeleteGroup(context.Background(), "ws-001", "group-001")
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.err != nil:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strconv"
-	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -25,18 +24,27 @@ type sessionDynamoDB interface {
 	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
 	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
 	DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error)
 }
 
+// idleSessionCondition matches sessions last active before :cutoff. Sessions
+// the Gateway never reported count from creation. Timestamps in
+// domain.TimestampLayout compare correctly as strings.
+const idleSessionCondition = "last_active_at < :cutoff OR (attribute_not_exists(last_active_at) AND created_at < :cutoff)"
+
 // sessionItem is the DynamoDB item shape for the sessions table.
 type sessionItem struct {
 	SessionID        string `dynamodbav:"session_id"`
 	UserID           string `dynamodbav:"user_id"`
 	DeviceID         string `dynamodbav:"device_id"`
+	FamilyID         string `dynamodbav:"token_family,omitempty"`
 	RefreshTokenHash string `dynamodbav:"refresh_token_hash"`
 	TokenGeneration  int64  `dynamodbav:"token_generation"`
 	PrevTokenHash    string `dynamodbav:"prev_token_hash"`
 	CreatedAt        string `dynamodbav:"created_at"`
 	ExpiresAt        string `dynamodbav:"expires_at"`
+	LastActiveAt     string `dynamodbav:"last_active_at,omitempty"`
+	MaxExpiresAt     string `dynamodbav:"max_expires_at,omitempty"`
 	TTL              int64  `dynamodbav:"ttl"`
 }
 
@@ -46,45 +54,69 @@ func toSessionItem(r app.SessionRecord) sessionItem {
 		SessionID:        r.SessionID,
 		UserID:           r.UserID,
 		DeviceID:         r.DeviceID,
+		FamilyID:         r.FamilyID,
 		RefreshTokenHash: r.RefreshTokenHash,
 		TokenGeneration:  r.TokenGeneration,
 		PrevTokenHash:    r.PrevTokenHash,
-		CreatedAt:        r.CreatedAt,
-		ExpiresAt:        r.ExpiresAt,
+		CreatedAt:        r.CreatedAt.String(),
+		ExpiresAt:        r.ExpiresAt.String(),
+		LastActiveAt:     r.LastActiveAt.String(),
+		MaxExpiresAt:     r.MaxExpiresAt.String(),
 		TTL:              r.TTL,
 	}
 }
 
 // fromSessionItem converts a DynamoDB item to an app.SessionRecord.
-func fromSessionItem(item sessionItem) *app.SessionRecord {
+func fromSessionItem(item sessionItem) (*app.SessionRecord, error) {
+	createdAt, err := domain.ParseTimestamp(item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	expiresAt, err := domain.ParseTimestamp(item.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse expires_at: %w", err)
+	}
+	lastActiveAt, err := domain.ParseTimestamp(item.LastActiveAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse last_active_at: %w", err)
+	}
+	maxExpiresAt, err := domain.ParseTimestamp(item.MaxExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse max_expires_at: %w", err)
+	}
 	return &app.SessionRecord{
 		SessionID:        item.SessionID,
 		UserID:           item.UserID,
 		DeviceID:         item.DeviceID,
+		FamilyID:         item.FamilyID,
 		RefreshTokenHash: item.RefreshTokenHash,
 		PrevTokenHash:    item.PrevTokenHash,
-		CreatedAt:        item.CreatedAt,
-		ExpiresAt:        item.ExpiresAt,
+		CreatedAt:        createdAt,
+		ExpiresAt:        expiresAt,
+		LastActiveAt:     lastActiveAt,
+		MaxExpiresAt:     maxExpiresAt,
 		TokenGeneration:  item.TokenGeneration,
 		TTL:              item.TTL,
-	}
+	}, nil
 }
 
 // SessionStore persists session records in DynamoDB.
 type SessionStore struct {
-	db        sessionDynamoDB
-	tableName string
-	indexName string
-	clock     domain.Clock
+	db              sessionDynamoDB
+	tableName       string
+	indexName       string
+	deviceIndexName string
+	clock           domain.Clock
 }
 
 // NewSessionStore creates a SessionStore backed by the given DynamoDB client.
 func NewSessionStore(db sessionDynamoDB, tableName string, clock domain.Clock) *SessionStore {
 	return &SessionStore{
-		db:        db,
-		tableName: tableName,
-		indexName: "user_sessions-index",
-		clock:     clock,
+		db:              db,
+		tableName:       tableName,
+		indexName:       "user_sessions-index",
+		deviceIndexName: "user_device-index",
+		clock:           clock,
 	}
 }
 
@@ -171,7 +203,7 @@ func (s *SessionStore) ListByUser(ctx context.Context, userID string) ([]app.Ses
 
 	keyExpr := "user_id = :uid"
 	filterExpr := "expires_at > :now"
-	now := s.clock.Now().UTC().Format(time.RFC3339)
+	now := domain.TimestampNow(s.clock).String()
 
 	out, err := s.db.Query(ctx, &dynamo.QueryInput{
 		TableName:              &s.tableName,
@@ -189,20 +221,48 @@ func (s *SessionStore) ListByUser(ctx context.Context, userID string) ([]app.Ses
 		return nil, fmt.Errorf("session store: list by user: %w", err)
 	}
 
-	sessions := make([]app.SessionRecord, 0, len(out.Items))
-	for _, item := range out.Items {
-		rec, err := s.unmarshalSession(item)
-		if err != nil {
-			return nil, err
-		}
-		sessions = append(sessions, *rec)
+	return s.unmarshalSessions(out.Items)
+}
+
+// GetByUserAndDevice retrieves the user's active sessions on deviceID via
+// the user_device-index GSI, keyed (user_id, device_id). A device normally
+// has at most one.
+func (s *SessionStore) GetByUserAndDevice(ctx context.Context, userID, deviceID string) ([]app.SessionRecord, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.sessions.get_by_user_and_device")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid AND device_id = :did"
+	filterExpr := "expires_at > :now"
+	now := domain.TimestampNow(s.clock).String()
+
+	out, err := s.db.Query(ctx, &dynamo.QueryInput{
+		TableName:              &s.tableName,
+		IndexName:              &s.deviceIndexName,
+		KeyConditionExpression: &keyExpr,
+		FilterExpression:       &filterExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":uid": &dynamo.AttributeValueMemberS{Value: userID},
+			":did": &dynamo.AttributeValueMemberS{Value: deviceID},
+			":now": &dynamo.AttributeValueMemberS{Value: now},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("session store: get by user and device: %w", err)
 	}
 
-	return sessions, nil
+	return s.unmarshalSessions(out.Items)
 }
 
 // Update applies a SessionUpdate to the session identified by sessionID.
 // Used for refresh token rotation: new hash, bumped generation, prev_token_hash.
+// Returns domain.ErrVersionConflict unless the session is still at
+// updates.ExpectedGeneration.
 func (s *SessionStore) Update(ctx context.Context, sessionID string, updates app.SessionUpdate) error {
 	ctx, span := tracer.Start(ctx, "dynamo.sessions.update")
 	defer span.End()
@@ -212,25 +272,41 @@ func (s *SessionStore) Update(ctx context.Context, sessionID string, updates app
 	)
 
 	updateExpr := "SET refresh_token_hash = :rth, token_generation = :gen, prev_token_hash = :pth, expires_at = :ea, #ttl = :ttl"
+	values := map[string]dynamo.AttributeValue{
+		":rth": &dynamo.AttributeValueMemberS{Value: updates.RefreshTokenHash},
+		":gen": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(updates.TokenGeneration, 10)},
+		":pth": &dynamo.AttributeValueMemberS{Value: updates.PrevTokenHash},
+		":ea":  &dynamo.AttributeValueMemberS{Value: updates.ExpiresAt.String()},
+		":ttl": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(updates.TTL, 10)},
+	}
+	if !updates.LastActiveAt.IsZero() {
+		// The Gateway writes last_active_at too. A refresh is always "now",
+		// so an unconditional SET never moves it meaningfully backwards.
+		updateExpr += ", last_active_at = :la"
+		values[":la"] = &dynamo.AttributeValueMemberS{Value: updates.LastActiveAt.String()}
+	}
+
+	// The condition also fails for a deleted session, so a rotation
+	// racing a logout cannot recreate it.
+	condExpr := "token_generation = :expected"
+	values[":expected"] = &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(updates.ExpectedGeneration, 10)}
 
 	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
 		TableName: &s.tableName,
 		Key: map[string]dynamo.AttributeValue{
 			"session_id": &dynamo.AttributeValueMemberS{Value: sessionID},
 		},
-		UpdateExpression: &updateExpr,
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
 		ExpressionAttributeNames: map[string]string{
 			"#ttl": "ttl",
 		},
-		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
-			":rth": &dynamo.AttributeValueMemberS{Value: updates.RefreshTokenHash},
-			":gen": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(updates.TokenGeneration, 10)},
-			":pth": &dynamo.AttributeValueMemberS{Value: updates.PrevTokenHash},
-			":ea":  &dynamo.AttributeValueMemberS{Value: updates.ExpiresAt},
-			":ttl": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(updates.TTL, 10)},
-		},
+		ExpressionAttributeValues: values,
 	})
 	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("session store: update: %w", domain.ErrVersionConflict)
+		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("session store: update: %w", err)
@@ -263,6 +339,96 @@ func (s *SessionStore) Delete(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+// ListIdle scans for sessions last active before cutoff. It reads the whole
+// table, so it is meant for the periodic idle sweep only.
+func (s *SessionStore) ListIdle(ctx context.Context, cutoff domain.TimestampMS) ([]app.SessionRecord, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.sessions.list_idle")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Scan"),
+	)
+
+	filterExpr := idleSessionCondition
+	input := &dynamo.ScanInput{
+		TableName:        &s.tableName,
+		FilterExpression: &filterExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":cutoff": &dynamo.AttributeValueMemberS{Value: cutoff.String()},
+		},
+	}
+
+	var sessions []app.SessionRecord
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("session store: list idle: %w", err)
+		}
+		out, err := s.db.Scan(ctx, input)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("session store: list idle: %w", err)
+		}
+		for _, item := range out.Items {
+			rec, err := s.unmarshalSession(item)
+			if err != nil {
+				return nil, err
+			}
+			sessions = append(sessions, *rec)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return sessions, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// DeleteIdle deletes the session if it is still idle at cutoff. Returns
+// domain.ErrVersionConflict when the session was used since, or is gone.
+func (s *SessionStore) DeleteIdle(ctx context.Context, sessionID string, cutoff domain.TimestampMS) error {
+	ctx, span := tracer.Start(ctx, "dynamo.sessions.delete_idle")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "DeleteItem"),
+	)
+
+	condExpr := idleSessionCondition
+	_, err := s.db.DeleteItem(ctx, &dynamo.DeleteItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"session_id": &dynamo.AttributeValueMemberS{Value: sessionID},
+		},
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":cutoff": &dynamo.AttributeValueMemberS{Value: cutoff.String()},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("session store: delete idle: %w", domain.ErrVersionConflict)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("session store: delete idle: %w", err)
+	}
+
+	return nil
+}
+
+// unmarshalSessions converts query result items into app.SessionRecords.
+func (s *SessionStore) unmarshalSessions(items []map[string]dynamo.AttributeValue) ([]app.SessionRecord, error) {
+	sessions := make([]app.SessionRecord, 0, len(items))
+	for _, item := range items {
+		rec, err := s.unmarshalSession(item)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *rec)
+	}
+	return sessions, nil
+}
+
 // unmarshalSession converts a DynamoDB attribute map into an app.SessionRecord.
 func (s *SessionStore) unmarshalSession(item map[string]dynamo.AttributeValue) (*app.SessionRecord, error) {
 	var si sessionItem
@@ -270,5 +436,9 @@ func (s *SessionStore) unmarshalSession(item map[string]dynamo.AttributeValue) (
 		return nil, fmt.Errorf("session store: unmarshal session: %w", err)
 	}
 
-	return fromSessionItem(si), nil
+	rec, err := fromSessionItem(si)
+	if err != nil {
+		return nil, fmt.Errorf("session store: %w", err)
+	}
+	return rec, nil
 }
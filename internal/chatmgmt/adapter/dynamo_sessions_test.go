@@ -25,6 +25,7 @@ type stubSessionDynamo struct {
 	queryFn      func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
 	updateItemFn func(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
 	deleteItemFn func(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+	scanFn       func(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error)
 }
 
 func (s *stubSessionDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
@@ -47,6 +48,10 @@ func (s *stubSessionDynamo) DeleteItem(ctx context.Context, params *dynamo.Delet
 	return s.deleteItemFn(ctx, params, optFns...)
 }
 
+func (s *stubSessionDynamo) Scan(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+	return s.scanFn(ctx, params, optFns...)
+}
+
 var _ sessionDynamoDB = (*stubSessionDynamo)(nil)
 
 // ---------------------------------------------------------------------------
@@ -81,8 +86,8 @@ func sampleSessionRecord() app.SessionRecord {
 		DeviceID:         si.DeviceID,
 		RefreshTokenHash: si.RefreshTokenHash,
 		PrevTokenHash:    si.PrevTokenHash,
-		CreatedAt:        si.CreatedAt,
-		ExpiresAt:        si.ExpiresAt,
+		CreatedAt:        domain.NewTimestampMS(sessionFixedTime()),
+		ExpiresAt:        domain.NewTimestampMS(sessionFixedTime().Add(30 * 24 * time.Hour)),
 		TokenGeneration:  si.TokenGeneration,
 		TTL:              si.TTL,
 	}
@@ -274,6 +279,46 @@ func TestSessionStore_ListByUser(t *testing.T) {
 	})
 }
 
+func TestSessionStore_GetByUserAndDevice(t *testing.T) {
+	t.Run("success - queries the device index", func(t *testing.T) {
+		item := sampleSessionItem()
+		av, err := dynamo.MarshalMap(item)
+		require.NoError(t, err)
+
+		clock := domaintest.NewFakeClock(sessionFixedTime())
+		store := NewSessionStore(&stubSessionDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				assert.Equal(t, sessionsTable, *params.TableName)
+				assert.Equal(t, "user_device-index", *params.IndexName)
+				assert.Equal(t, "user_id = :uid AND device_id = :did", *params.KeyConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: item.DeviceID}, params.ExpressionAttributeValues[":did"])
+				require.NotNil(t, params.FilterExpression)
+				assert.Contains(t, *params.FilterExpression, "expires_at > :now")
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{av}}, nil
+			},
+		}, sessionsTable, clock)
+
+		sessions, err := store.GetByUserAndDevice(context.Background(), item.UserID, item.DeviceID)
+
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, item.SessionID, sessions[0].SessionID)
+	})
+
+	t.Run("query error - wraps with context", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(sessionFixedTime())
+		store := NewSessionStore(&stubSessionDynamo{
+			queryFn: func(_ context.Context, _ *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("timeout")
+			},
+		}, sessionsTable, clock)
+
+		_, err := store.GetByUserAndDevice(context.Background(), "user-id", "device-id")
+
+		assert.ErrorContains(t, err, "session store: get by user and device: timeout")
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Tests — Update
 // ---------------------------------------------------------------------------
@@ -291,21 +336,53 @@ func TestSessionStore_Update(t *testing.T) {
 				assert.Contains(t, *params.UpdateExpression, "refresh_token_hash = :rth")
 				assert.Contains(t, *params.UpdateExpression, "token_generation = :gen")
 				assert.Contains(t, *params.UpdateExpression, "prev_token_hash = :pth")
+				require.NotNil(t, params.ConditionExpression)
+				assert.Equal(t, "token_generation = :expected", *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "1"}, params.ExpressionAttributeValues[":expected"])
 				return &dynamo.UpdateItemOutput{}, nil
 			},
 		}, sessionsTable, clock)
 
 		err := store.Update(context.Background(), "session-abc", app.SessionUpdate{
-			RefreshTokenHash: "new-hash",
-			PrevTokenHash:    "old-hash",
-			ExpiresAt:        "2026-03-12T12:00:00Z",
-			TokenGeneration:  2,
-			TTL:              sessionFixedTime().Add(30 * 24 * time.Hour).Unix(),
+			RefreshTokenHash:   "new-hash",
+			PrevTokenHash:      "old-hash",
+			ExpiresAt:          domain.NewTimestampMS(sessionFixedTime().Add(30 * 24 * time.Hour)),
+			TokenGeneration:    2,
+			TTL:                sessionFixedTime().Add(30 * 24 * time.Hour).Unix(),
+			ExpectedGeneration: 1,
 		})
 
 		require.NoError(t, err)
 	})
 
+	t.Run("generation moved on - returns ErrVersionConflict", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(sessionFixedTime())
+		store := NewSessionStore(&stubSessionDynamo{
+			updateItemFn: func(_ context.Context, _ *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, sessionsTable, clock)
+
+		err := store.Update(context.Background(), "session-abc", app.SessionUpdate{TokenGeneration: 2, ExpectedGeneration: 1})
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+	})
+
+	t.Run("sets last_active_at when given", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(sessionFixedTime())
+		store := NewSessionStore(&stubSessionDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Contains(t, *params.UpdateExpression, "last_active_at = :la")
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-02-10T12:00:00.000Z"}, params.ExpressionAttributeValues[":la"])
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, sessionsTable, clock)
+
+		err := store.Update(context.Background(), "session-abc", app.SessionUpdate{LastActiveAt: domain.NewTimestampMS(sessionFixedTime())})
+
+		require.NoError(t, err)
+	})
+
 	t.Run("dynamo error - wraps with context", func(t *testing.T) {
 		clock := domaintest.NewFakeClock(sessionFixedTime())
 		store := NewSessionStore(&stubSessionDynamo{
@@ -357,3 +434,81 @@ func TestSessionStore_Delete(t *testing.T) {
 		assert.Contains(t, err.Error(), "session store: delete: access denied")
 	})
 }
+
+// ---------------------------------------------------------------------------
+// Tests — idle sweep
+// ---------------------------------------------------------------------------
+
+var idleCutoff = domain.NewTimestampMS(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+func TestSessionStore_ListIdle(t *testing.T) {
+	t.Run("follows scan pages", func(t *testing.T) {
+		first := sampleSessionItem()
+		second := sampleSessionItem()
+		second.SessionID = "second"
+		second.LastActiveAt = "2025-12-01T00:00:00Z"
+		firstAV, err := dynamo.MarshalMap(first)
+		require.NoError(t, err)
+		secondAV, err := dynamo.MarshalMap(second)
+		require.NoError(t, err)
+
+		var calls int
+		store := NewSessionStore(&stubSessionDynamo{
+			scanFn: func(_ context.Context, params *dynamo.ScanInput, _ ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+				calls++
+				assert.Equal(t, idleSessionCondition, *params.FilterExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-01-01T00:00:00.000Z"}, params.ExpressionAttributeValues[":cutoff"])
+				if calls == 1 {
+					return &dynamo.ScanOutput{Items: []map[string]dynamo.AttributeValue{firstAV}, LastEvaluatedKey: firstAV}, nil
+				}
+				assert.Equal(t, firstAV, params.ExclusiveStartKey)
+				return &dynamo.ScanOutput{Items: []map[string]dynamo.AttributeValue{secondAV}}, nil
+			},
+		}, sessionsTable, domaintest.NewFakeClock(sessionFixedTime()))
+
+		sessions, err := store.ListIdle(context.Background(), idleCutoff)
+
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, sampleSessionRecord(), sessions[0])
+		assert.Equal(t, "2025-12-01T00:00:00.000Z", sessions[1].LastActiveAt.String(), "legacy whole-second timestamps still parse")
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewSessionStore(&stubSessionDynamo{
+			scanFn: func(context.Context, *dynamo.ScanInput, ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, sessionsTable, domaintest.NewFakeClock(sessionFixedTime()))
+
+		_, err := store.ListIdle(context.Background(), idleCutoff)
+
+		assert.ErrorContains(t, err, "session store: list idle: throttled")
+	})
+}
+
+func TestSessionStore_DeleteIdle(t *testing.T) {
+	t.Run("deletes only if still idle", func(t *testing.T) {
+		store := NewSessionStore(&stubSessionDynamo{
+			deleteItemFn: func(_ context.Context, params *dynamo.DeleteItemInput, _ ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+				assert.Equal(t, idleSessionCondition, *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "session-abc"}, params.Key["session_id"])
+				return &dynamo.DeleteItemOutput{}, nil
+			},
+		}, sessionsTable, domaintest.NewFakeClock(sessionFixedTime()))
+
+		assert.NoError(t, store.DeleteIdle(context.Background(), "session-abc", idleCutoff))
+	})
+
+	t.Run("active since the scan is a version conflict", func(t *testing.T) {
+		store := NewSessionStore(&stubSessionDynamo{
+			deleteItemFn: func(context.Context, *dynamo.DeleteItemInput, ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, sessionsTable, domaintest.NewFakeClock(sessionFixedTime()))
+
+		err := store.DeleteIdle(context.Background(), "session-abc", idleCutoff)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+	})
+}
@@ -0,0 +1,276 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// Compile-time check: SSOStore satisfies app.SSOStore.
+var _ app.SSOStore = (*SSOStore)(nil)
+
+// ssoDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the SSO store.
+type ssoDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// ssoConnectionItem is an sso_connections item (PK workspace_id).
+type ssoConnectionItem struct {
+	WorkspaceID          string   `dynamodbav:"workspace_id"`
+	Issuer               string   `dynamodbav:"issuer"`
+	ClientID             string   `dynamodbav:"client_id"`
+	AllowedDomains       []string `dynamodbav:"allowed_domains,omitempty"`
+	MaxSessionTTLSeconds int64    `dynamodbav:"max_session_ttl_seconds,omitempty"`
+	SCIMTokenHash        string   `dynamodbav:"scim_token_hash,omitempty"`
+	DataRegion           string   `dynamodbav:"data_region,omitempty"`
+	UpdatedAt            string   `dynamodbav:"updated_at"`
+}
+
+// ssoIdentityItem is an sso_identities item (PK identity, as
+// workspace_id#subject) linking an IdP subject to its user.
+type ssoIdentityItem struct {
+	Identity    string `dynamodbav:"identity"`
+	WorkspaceID string `dynamodbav:"workspace_id"`
+	Subject     string `dynamodbav:"subject"`
+	UserID      string `dynamodbav:"user_id"`
+	CreatedAt   string `dynamodbav:"created_at"`
+}
+
+func ssoIdentityKey(workspaceID, subject string) string {
+	return workspaceID + "#" + subject
+}
+
+// SSOStore persists workspace SSO connections and IdP subject links.
+// Provisioned users are written to the users table alongside their link.
+type SSOStore struct {
+	db               ssoDynamoDB
+	connectionsTable string
+	identitiesTable  string
+	usersTable       string
+	fields           *msgcrypt.FieldCipher
+}
+
+// NewSSOStore creates an SSOStore backed by the given DynamoDB client.
+// Provisioned users' personal data is encrypted with fields, or stored in
+// plaintext if it is nil.
+func NewSSOStore(db ssoDynamoDB, connectionsTable, identitiesTable, usersTable string, fields *msgcrypt.FieldCipher) *SSOStore {
+	return &SSOStore{
+		db:               db,
+		connectionsTable: connectionsTable,
+		identitiesTable:  identitiesTable,
+		usersTable:       usersTable,
+		fields:           fields,
+	}
+}
+
+// GetConnection reads the workspace's SSO connection. Returns
+// domain.ErrNotFound if there is none.
+func (s *SSOStore) GetConnection(ctx context.Context, workspaceID string) (domain.SSOConnection, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.sso.get_connection")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.connectionsTable,
+		Key: map[string]dynamo.AttributeValue{
+			"workspace_id": &dynamo.AttributeValueMemberS{Value: workspaceID},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.SSOConnection{}, fmt.Errorf("sso store: get connection: %w", err)
+	}
+	if out.Item == nil {
+		return domain.SSOConnection{}, fmt.Errorf("sso store: get connection: %w", domain.ErrNotFound)
+	}
+
+	var item ssoConnectionItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		return domain.SSOConnection{}, fmt.Errorf("sso store: unmarshal: %w", err)
+	}
+	updatedAt, err := domain.ParseTimestamp(item.UpdatedAt)
+	if err != nil {
+		return domain.SSOConnection{}, fmt.Errorf("sso store: parse updated_at: %w", err)
+	}
+	return domain.SSOConnection{
+		WorkspaceID:    item.WorkspaceID,
+		Issuer:         item.Issuer,
+		ClientID:       item.ClientID,
+		AllowedDomains: item.AllowedDomains,
+		MaxSessionTTL:  time.Duration(item.MaxSessionTTLSeconds) * time.Second,
+		SCIMTokenHash:  item.SCIMTokenHash,
+		DataRegion:     domain.DataRegion(item.DataRegion),
+		UpdatedAt:      updatedAt.Time(),
+	}, nil
+}
+
+// PutConnection creates or replaces the workspace's SSO connection.
+func (s *SSOStore) PutConnection(ctx context.Context, conn domain.SSOConnection) error {
+	ctx, span := tracer.Start(ctx, "dynamo.sso.put_connection")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(ssoConnectionItem{
+		WorkspaceID:          conn.WorkspaceID,
+		Issuer:               conn.Issuer,
+		ClientID:             conn.ClientID,
+		AllowedDomains:       conn.AllowedDomains,
+		MaxSessionTTLSeconds: int64(conn.MaxSessionTTL / time.Second),
+		SCIMTokenHash:        conn.SCIMTokenHash,
+		DataRegion:           string(conn.DataRegion),
+		UpdatedAt:            domain.NewTimestampMS(conn.UpdatedAt).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("sso store: marshal: %w", err)
+	}
+	if _, err := s.db.PutItem(ctx, &dynamo.PutItemInput{TableName: &s.connectionsTable, Item: av}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("sso store: put connection: %w", err)
+	}
+	return nil
+}
+
+// FindIdentity returns the user linked to subject in the workspace, using
+// a strongly consistent read so a just-provisioned link is found. Returns
+// domain.ErrNotFound if the subject has never signed in.
+func (s *SSOStore) FindIdentity(ctx context.Context, workspaceID, subject string) (string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.sso.find_identity")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	consistentRead := true
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.identitiesTable,
+		Key: map[string]dynamo.AttributeValue{
+			"identity": &dynamo.AttributeValueMemberS{Value: ssoIdentityKey(workspaceID, subject)},
+		},
+		ConsistentRead: &consistentRead,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("sso store: find identity: %w", err)
+	}
+	if out.Item == nil {
+		return "", fmt.Errorf("sso store: find identity: %w", domain.ErrNotFound)
+	}
+
+	var item ssoIdentityItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		return "", fmt.Errorf("sso store: unmarshal: %w", err)
+	}
+	return item.UserID, nil
+}
+
+// ProvisionUser executes a 2-item TransactWriteItems for a first SSO
+// sign-in. The two items are:
+//
+//	[0] identityPut — links the subject to the user in sso_identities
+//	[1] userPut — creates the phone-less user in users table
+//
+// Returns domain.ErrAlreadyExists if the subject is already linked.
+func (s *SSOStore) ProvisionUser(ctx context.Context, workspaceID, subject string, user app.UserRecord) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.provision_sso_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	identity, err := dynamo.MarshalMap(ssoIdentityItem{
+		Identity:    ssoIdentityKey(workspaceID, subject),
+		WorkspaceID: workspaceID,
+		Subject:     subject,
+		UserID:      user.UserID,
+		CreatedAt:   user.CreatedAt.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("sso store: marshal identity: %w", err)
+	}
+	item, err := encryptedUserItem(ctx, s.fields, user)
+	if err != nil {
+		return fmt.Errorf("sso store: %w", err)
+	}
+	userAV, err := dynamo.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("sso store: marshal user: %w", err)
+	}
+	identityCond := "attribute_not_exists(identity)"
+	userCond := "attribute_not_exists(user_id)"
+
+	_, err = s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{Put: &dynamo.Put{TableName: &s.identitiesTable, Item: identity, ConditionExpression: &identityCond}},
+			{Put: &dynamo.Put{TableName: &s.usersTable, Item: userAV, ConditionExpression: &userCond}},
+		},
+	})
+	if err != nil {
+		if reasons, ok := dynamo.IsTransactionCanceledException(err); ok && len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed" {
+			return fmt.Errorf("sso store: provision user: identity exists: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("sso store: provision user: %w", err)
+	}
+	return nil
+}
+
+// LinkIdentity links subject to an existing user, as when a SCIM-managed
+// workspace's member signs in for the first time. Returns
+// domain.ErrAlreadyExists if the subject is already linked.
+func (s *SSOStore) LinkIdentity(ctx context.Context, workspaceID, subject, userID string, at domain.TimestampMS) error {
+	ctx, span := tracer.Start(ctx, "dynamo.sso.link_identity")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(ssoIdentityItem{
+		Identity:    ssoIdentityKey(workspaceID, subject),
+		WorkspaceID: workspaceID,
+		Subject:     subject,
+		UserID:      userID,
+		CreatedAt:   at.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("sso store: marshal identity: %w", err)
+	}
+	cond := "attribute_not_exists(identity)"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &s.identitiesTable,
+		Item:                av,
+		ConditionExpression: &cond,
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("sso store: link identity: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("sso store: link identity: %w", err)
+	}
+	return nil
+}
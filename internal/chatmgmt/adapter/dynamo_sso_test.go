@@ -0,0 +1,165 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements ssoDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubSSODynamo struct {
+	getItemFn  func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	putItemFn  func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	transactFn func(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+func (s *stubSSODynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubSSODynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+func (s *stubSSODynamo) TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	return s.transactFn(ctx, params, optFns...)
+}
+
+var _ ssoDynamoDB = (*stubSSODynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestSSOStore_ConnectionRoundTrip(t *testing.T) {
+	conn := domain.SSOConnection{
+		WorkspaceID:    "ws-001",
+		Issuer:         "https://idp.example.com",
+		ClientID:       "messaging",
+		AllowedDomains: []string{"example.com"},
+		MaxSessionTTL:  8 * time.Hour,
+		SCIMTokenHash:  "ab12cd",
+		DataRegion:     domain.RegionEU,
+		UpdatedAt:      time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	}
+
+	var stored map[string]dynamo.AttributeValue
+	store := NewSSOStore(&stubSSODynamo{
+		putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+			assert.Equal(t, "sso_connections", *params.TableName)
+			assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "28800"}, params.Item["max_session_ttl_seconds"])
+			assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "eu"}, params.Item["data_region"])
+			stored = params.Item
+			return &dynamo.PutItemOutput{}, nil
+		},
+		getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "ws-001"}, params.Key["workspace_id"])
+			return &dynamo.GetItemOutput{Item: stored}, nil
+		},
+	}, "sso_connections", "sso_identities", "users", nil)
+
+	require.NoError(t, store.PutConnection(context.Background(), conn))
+	got, err := store.GetConnection(context.Background(), "ws-001")
+
+	require.NoError(t, err)
+	assert.Equal(t, conn, got)
+}
+
+func TestSSOStore_FindIdentity(t *testing.T) {
+	store := NewSSOStore(&stubSSODynamo{
+		getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			assert.Equal(t, "sso_identities", *params.TableName)
+			assert.True(t, *params.ConsistentRead)
+			if params.Key["identity"].(*dynamo.AttributeValueMemberS).Value != "ws-001#sub-1" {
+				return &dynamo.GetItemOutput{}, nil
+			}
+			return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+				"identity": &dynamo.AttributeValueMemberS{Value: "ws-001#sub-1"},
+				"user_id":  &dynamo.AttributeValueMemberS{Value: "user-001"},
+			}}, nil
+		},
+	}, "sso_connections", "sso_identities", "users", nil)
+
+	userID, err := store.FindIdentity(context.Background(), "ws-001", "sub-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-001", userID)
+
+	_, err = store.FindIdentity(context.Background(), "ws-001", "sub-2")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestSSOStore_ProvisionUser(t *testing.T) {
+	user := app.UserRecord{
+		UserID:      "user-001",
+		DisplayName: "Ada",
+		CreatedAt:   domain.NewTimestampMS(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)),
+	}
+
+	t.Run("writes the link and a phone-less user", func(t *testing.T) {
+		store := NewSSOStore(&stubSSODynamo{
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+				identity, userPut := params.TransactItems[0].Put, params.TransactItems[1].Put
+				assert.Equal(t, "sso_identities", *identity.TableName)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "ws-001#sub-1"}, identity.Item["identity"])
+				assert.Equal(t, "attribute_not_exists(identity)", *identity.ConditionExpression)
+				assert.Equal(t, "users", *userPut.TableName)
+				assert.NotContains(t, userPut.Item, "phone_number", "phone-less users stay out of phone_number-index")
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}, "sso_connections", "sso_identities", "users", nil)
+
+		require.NoError(t, store.ProvisionUser(context.Background(), "ws-001", "sub-1", user))
+	})
+
+	t.Run("already linked subject: ErrAlreadyExists", func(t *testing.T) {
+		store := NewSSOStore(&stubSSODynamo{
+			transactFn: func(context.Context, *dynamo.TransactWriteItemsInput, ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "")
+			},
+		}, "sso_connections", "sso_identities", "users", nil)
+
+		err := store.ProvisionUser(context.Background(), "ws-001", "sub-1", user)
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+}
+
+func TestSSOStore_LinkIdentity(t *testing.T) {
+	at := domain.NewTimestampMS(time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+
+	t.Run("puts the link conditionally", func(t *testing.T) {
+		store := NewSSOStore(&stubSSODynamo{
+			putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				assert.Equal(t, "sso_identities", *params.TableName)
+				assert.Equal(t, "attribute_not_exists(identity)", *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "ws-001#sub-1"}, params.Item["identity"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "user-001"}, params.Item["user_id"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: at.String()}, params.Item["created_at"])
+				return &dynamo.PutItemOutput{}, nil
+			},
+		}, "sso_connections", "sso_identities", "users", nil)
+
+		require.NoError(t, store.LinkIdentity(context.Background(), "ws-001", "sub-1", "user-001", at))
+	})
+
+	t.Run("already linked subject: ErrAlreadyExists", func(t *testing.T) {
+		store := NewSSOStore(&stubSSODynamo{
+			putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "sso_connections", "sso_identities", "users", nil)
+
+		err := store.LinkIdentity(context.Background(), "ws-001", "sub-1", "user-001", at)
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+}
@@ -0,0 +1,248 @@
+package adapter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: TokenLineageStore satisfies app.TokenLineageStore.
+var _ app.TokenLineageStore = (*TokenLineageStore)(nil)
+
+// lineageDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the token lineage store.
+type lineageDynamoDB interface {
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+// lineageItem is a token_lineage item (PK family_id, SK generation).
+type lineageItem struct {
+	FamilyID      string `dynamodbav:"family_id"`
+	Generation    int64  `dynamodbav:"generation"`
+	SessionID     string `dynamodbav:"session_id"`
+	UserID        string `dynamodbav:"user_id"`
+	DeviceID      string `dynamodbav:"device_id"`
+	ClientIP      string `dynamodbav:"client_ip,omitempty"`
+	Event         string `dynamodbav:"event"`
+	IssuedAt      string `dynamodbav:"issued_at"`
+	ReusedAt      string `dynamodbav:"reused_at,omitempty"`
+	ReuseDeviceID string `dynamodbav:"reuse_device_id,omitempty"`
+	ReuseClientIP string `dynamodbav:"reuse_client_ip,omitempty"`
+	TTL           int64  `dynamodbav:"ttl"`
+}
+
+// TokenLineageStore persists refresh token generations in the token_lineage
+// table. Items are written once per generation and only ever amended with
+// reuse details, so they survive the session they describe until TTL.
+type TokenLineageStore struct {
+	db        lineageDynamoDB
+	tableName string
+	indexName string
+}
+
+// NewTokenLineageStore creates a TokenLineageStore backed by the given
+// DynamoDB client.
+func NewTokenLineageStore(db lineageDynamoDB, tableName string) *TokenLineageStore {
+	return &TokenLineageStore{db: db, tableName: tableName, indexName: "user_lineage-index"}
+}
+
+// Append writes one generation. Returns domain.ErrAlreadyExists if the
+// generation is already recorded.
+func (s *TokenLineageStore) Append(ctx context.Context, entry app.TokenLineageEntry) error {
+	ctx, span := tracer.Start(ctx, "dynamo.token_lineage.append")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(toLineageItem(entry))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("token lineage store: marshal: %w", err)
+	}
+	cond := "attribute_not_exists(family_id)"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &s.tableName,
+		Item:                av,
+		ConditionExpression: &cond,
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("token lineage store: append: %w", domain.ErrAlreadyExists)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("token lineage store: append: %w", err)
+	}
+	return nil
+}
+
+// MarkReused records a replay of generation's token. Only the first replay
+// is kept; later ones return domain.ErrAlreadyExists. A generation with no
+// lineage item (issued before tracking) returns domain.ErrNotFound.
+func (s *TokenLineageStore) MarkReused(ctx context.Context, familyID string, generation int64, reuse app.TokenReuse) error {
+	ctx, span := tracer.Start(ctx, "dynamo.token_lineage.mark_reused")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	update := "SET reused_at = :at, reuse_device_id = :dev, reuse_client_ip = :ip"
+	cond := "attribute_exists(family_id)"
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName:           &s.tableName,
+		Key:                 lineageKey(familyID, generation),
+		UpdateExpression:    &update,
+		ConditionExpression: &cond,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":at":  &dynamo.AttributeValueMemberS{Value: reuse.At},
+			":dev": &dynamo.AttributeValueMemberS{Value: reuse.DeviceID},
+			":ip":  &dynamo.AttributeValueMemberS{Value: reuse.ClientIP},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("token lineage store: mark reused: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("token lineage store: mark reused: %w", err)
+	}
+	return nil
+}
+
+// ListFamily returns a family's generations in order.
+func (s *TokenLineageStore) ListFamily(ctx context.Context, familyID string) ([]app.TokenLineageEntry, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.token_lineage.list_family")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "family_id = :fid"
+	entries, err := s.queryAll(ctx, &dynamo.QueryInput{
+		TableName:              &s.tableName,
+		KeyConditionExpression: &keyExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":fid": &dynamo.AttributeValueMemberS{Value: familyID},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("token lineage store: list family: %w", err)
+	}
+	return entries, nil
+}
+
+// ListByUser returns every retained generation of the user's families via
+// the user_lineage-index GSI, ordered by family then generation.
+func (s *TokenLineageStore) ListByUser(ctx context.Context, userID string) ([]app.TokenLineageEntry, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.token_lineage.list_by_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid"
+	entries, err := s.queryAll(ctx, &dynamo.QueryInput{
+		TableName:              &s.tableName,
+		IndexName:              &s.indexName,
+		KeyConditionExpression: &keyExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":uid": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("token lineage store: list by user: %w", err)
+	}
+	slices.SortFunc(entries, func(a, b app.TokenLineageEntry) int {
+		return cmp.Or(cmp.Compare(a.FamilyID, b.FamilyID), cmp.Compare(a.Generation, b.Generation))
+	})
+	return entries, nil
+}
+
+func (s *TokenLineageStore) queryAll(ctx context.Context, in *dynamo.QueryInput) ([]app.TokenLineageEntry, error) {
+	var entries []app.TokenLineageEntry
+	for {
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out, err := s.db.Query(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		for _, av := range out.Items {
+			var item lineageItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("unmarshal lineage: %w", err)
+			}
+			entries = append(entries, fromLineageItem(item))
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func toLineageItem(e app.TokenLineageEntry) lineageItem {
+	return lineageItem{
+		FamilyID:      e.FamilyID,
+		Generation:    e.Generation,
+		SessionID:     e.SessionID,
+		UserID:        e.UserID,
+		DeviceID:      e.DeviceID,
+		ClientIP:      e.ClientIP,
+		Event:         e.Event,
+		IssuedAt:      e.IssuedAt,
+		ReusedAt:      e.ReusedAt,
+		ReuseDeviceID: e.ReuseDeviceID,
+		ReuseClientIP: e.ReuseClientIP,
+		TTL:           e.TTL,
+	}
+}
+
+func fromLineageItem(item lineageItem) app.TokenLineageEntry {
+	return app.TokenLineageEntry{
+		FamilyID:      item.FamilyID,
+		Generation:    item.Generation,
+		SessionID:     item.SessionID,
+		UserID:        item.UserID,
+		DeviceID:      item.DeviceID,
+		ClientIP:      item.ClientIP,
+		Event:         item.Event,
+		IssuedAt:      item.IssuedAt,
+		ReusedAt:      item.ReusedAt,
+		ReuseDeviceID: item.ReuseDeviceID,
+		ReuseClientIP: item.ReuseClientIP,
+		TTL:           item.TTL,
+	}
+}
+
+// lineageKey returns the primary key of a token_lineage item.
+func lineageKey(familyID string, generation int64) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{
+		"family_id":  &dynamo.AttributeValueMemberS{Value: familyID},
+		"generation": &dynamo.AttributeValueMemberN{Value: strconv.FormatInt(generation, 10)},
+	}
+}
@@ -0,0 +1,152 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements lineageDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubLineageDynamo struct {
+	putItemFn    func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	updateItemFn func(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+	queryFn      func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+func (s *stubLineageDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+func (s *stubLineageDynamo) UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	return s.updateItemFn(ctx, params, optFns...)
+}
+
+func (s *stubLineageDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+var _ lineageDynamoDB = (*stubLineageDynamo)(nil)
+
+func lineageAV(t *testing.T, e app.TokenLineageEntry) map[string]dynamo.AttributeValue {
+	t.Helper()
+	av, err := dynamo.MarshalMap(toLineageItem(e))
+	require.NoError(t, err)
+	return av
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestTokenLineageStore_Append(t *testing.T) {
+	entry := app.TokenLineageEntry{
+		FamilyID: "family-001", Generation: 2, SessionID: "sess-001", UserID: "user-001",
+		DeviceID: "device-001", ClientIP: "203.0.113.10", Event: app.LineageRefresh,
+		IssuedAt: "2026-03-02T12:00:00Z", TTL: 1780000000,
+	}
+
+	t.Run("writes once per generation", func(t *testing.T) {
+		store := NewTokenLineageStore(&stubLineageDynamo{
+			putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				assert.Equal(t, "token_lineage", *params.TableName)
+				assert.Equal(t, "attribute_not_exists(family_id)", *params.ConditionExpression)
+				assert.Equal(t, lineageAV(t, entry), params.Item)
+				assert.NotContains(t, params.Item, "reused_at")
+				return &dynamo.PutItemOutput{}, nil
+			},
+		}, "token_lineage")
+
+		assert.NoError(t, store.Append(context.Background(), entry))
+	})
+
+	t.Run("duplicate generation", func(t *testing.T) {
+		store := NewTokenLineageStore(&stubLineageDynamo{
+			putItemFn: func(context.Context, *dynamo.PutItemInput, ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "token_lineage")
+
+		assert.ErrorIs(t, store.Append(context.Background(), entry), domain.ErrAlreadyExists)
+	})
+}
+
+func TestTokenLineageStore_MarkReused(t *testing.T) {
+	reuse := app.TokenReuse{At: "2026-03-02T13:00:00Z", DeviceID: "device-001", ClientIP: "192.0.2.66"}
+
+	t.Run("amends the generation", func(t *testing.T) {
+		store := NewTokenLineageStore(&stubLineageDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, lineageKey("family-001", 2), params.Key)
+				assert.Equal(t, "attribute_exists(family_id)", *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "192.0.2.66"}, params.ExpressionAttributeValues[":ip"])
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, "token_lineage")
+
+		assert.NoError(t, store.MarkReused(context.Background(), "family-001", 2, reuse))
+	})
+
+	t.Run("untracked generation", func(t *testing.T) {
+		store := NewTokenLineageStore(&stubLineageDynamo{
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "token_lineage")
+
+		assert.ErrorIs(t, store.MarkReused(context.Background(), "sess-legacy", 4, reuse), domain.ErrNotFound)
+	})
+}
+
+func TestTokenLineageStore_ListByUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("follows pages and orders by family then generation", func(t *testing.T) {
+		var calls int
+		store := NewTokenLineageStore(&stubLineageDynamo{
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				calls++
+				assert.Equal(t, "user_lineage-index", *params.IndexName)
+				if calls == 1 {
+					item := lineageAV(t, app.TokenLineageEntry{FamilyID: "family-b", Generation: 1, UserID: "user-001"})
+					return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+						lineageAV(t, app.TokenLineageEntry{FamilyID: "family-a", Generation: 2, UserID: "user-001"}),
+						item,
+					}, LastEvaluatedKey: item}, nil
+				}
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+					lineageAV(t, app.TokenLineageEntry{FamilyID: "family-a", Generation: 1, UserID: "user-001"}),
+				}}, nil
+			},
+		}, "token_lineage")
+
+		entries, err := store.ListByUser(ctx, "user-001")
+
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, []string{"family-a", "family-a", "family-b"},
+			[]string{entries[0].FamilyID, entries[1].FamilyID, entries[2].FamilyID})
+		assert.Equal(t, int64(1), entries[0].Generation)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewTokenLineageStore(&stubLineageDynamo{
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "token_lineage")
+
+		_, err := store.ListFamily(ctx, "family-001")
+
+		assert.ErrorContains(t, err, "token lineage store: list family: throttled")
+	})
+}
@@ -3,6 +3,7 @@ package adapter
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -10,10 +11,15 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
 )
 
-// Compile-time check: Transactor satisfies app.AuthTransactor.
-var _ app.AuthTransactor = (*Transactor)(nil)
+// Compile-time checks: Transactor satisfies app.AuthTransactor and
+// app.UserImportStore.
+var (
+	_ app.AuthTransactor  = (*Transactor)(nil)
+	_ app.UserImportStore = (*Transactor)(nil)
+)
 
 // txDynamoDB is a narrow, consumer-defined interface for DynamoDB transaction
 // operations. The *dynamodb.Client satisfies this interface.
@@ -25,20 +31,31 @@ type txDynamoDB interface {
 // Each method maps to a specific ADR-015 transaction:
 //   - VerifyOTPAndCreateUser: §5.1 — new-user registration
 //   - VerifyOTPAndCreateSession: §5.2 — existing-user login
+//   - EvictAndCreateSession: §5.2 without the OTP, for SSO login
+//
+// CreateImportedUser writes the §5.1 user and phone sentinel items for
+// users migrated by bulk import, who have no OTP or session yet.
 type Transactor struct {
 	db            txDynamoDB
 	otpTable      string
 	usersTable    string
 	sessionsTable string
+	fields        *msgcrypt.FieldCipher
+	phones        *msgcrypt.BlindIndex
 }
 
 // NewTransactor creates a Transactor backed by the given DynamoDB client.
-func NewTransactor(db txDynamoDB, otpTable, usersTable, sessionsTable string) *Transactor {
+// Users' phone numbers and display names are encrypted with fields, or
+// stored in plaintext if it is nil; phones derives their phone_index blind
+// index, which also keys the phone sentinel.
+func NewTransactor(db txDynamoDB, otpTable, usersTable, sessionsTable string, fields *msgcrypt.FieldCipher, phones *msgcrypt.BlindIndex) *Transactor {
 	return &Transactor{
 		db:            db,
 		otpTable:      otpTable,
 		usersTable:    usersTable,
 		sessionsTable: sessionsTable,
+		fields:        fields,
+		phones:        phones,
 	}
 }
 
@@ -60,12 +77,32 @@ func (t *Transactor) VerifyOTPAndCreateUser(ctx context.Context, p app.Registrat
 		attribute.String("db.operation", "TransactWriteItems"),
 	)
 
+	user, err := t.userItem(ctx, app.UserRecord{
+		UserID:      p.UserID,
+		PhoneNumber: p.PhoneNumber,
+		CreatedAt:   p.Now,
+		UpdatedAt:   p.Now,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("verify otp and create user: %w", err)
+	}
 	otpUpdate := t.buildOTPVerifyUpdate(p.PhoneHash, p.OTPExpiresAt, p.OTPMAC)
-	userPut := t.buildUserPut(p.UserID, p.PhoneNumber, p.Now)
-	phoneSentinelPut := t.buildPhoneSentinelPut(p.PhoneNumber, p.UserID)
-	sessionPut := t.buildSessionPut(p.SessionID, p.UserID, p.DeviceID, p.RefreshTokenHash, p.Now, p.SessionExpiresAt, p.SessionTTL)
+	userPut := t.buildUserPut(user)
+	phoneSentinelPut := phoneSentinelPut(t.usersTable, user.PhoneIndex, p.UserID)
+	sessionPut := t.buildSessionPut(sessionItem{
+		SessionID:        p.SessionID,
+		UserID:           p.UserID,
+		DeviceID:         p.DeviceID,
+		FamilyID:         p.FamilyID,
+		RefreshTokenHash: p.RefreshTokenHash,
+		CreatedAt:        p.Now.String(),
+		ExpiresAt:        p.SessionExpiresAt.String(),
+		TTL:              p.SessionTTL,
+	})
 
-	_, err := t.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+	_, err = t.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
 		TransactItems: []dynamo.TransactWriteItem{
 			otpUpdate,
 			userPut,
@@ -84,13 +121,15 @@ func (t *Transactor) VerifyOTPAndCreateUser(ctx context.Context, p app.Registrat
 	return nil
 }
 
-// VerifyOTPAndCreateSession executes a 2-item TransactWriteItems for
-// existing-user login (ADR-015 §5.2). The two items are:
+// VerifyOTPAndCreateSession executes a TransactWriteItems for existing-user
+// login (ADR-015 §5.2). The items are:
 //
 //	[0] otpUpdate — marks the OTP as verified in otp_requests
 //	[1] sessionPut — creates a new session in sessions table
+//	[2…] sessionDelete — deletes each of p.EvictSessionIDs
 //
-// Returns domain.ErrAlreadyExists if the session already exists.
+// Returns domain.ErrAlreadyExists if the session already exists, and
+// domain.ErrVersionConflict if an evicted session no longer exists.
 func (t *Transactor) VerifyOTPAndCreateSession(ctx context.Context, p app.LoginParams) error {
 	ctx, span := tracer.Start(ctx, "dynamo.tx.verify_otp_create_session")
 	defer span.End()
@@ -100,17 +139,97 @@ func (t *Transactor) VerifyOTPAndCreateSession(ctx context.Context, p app.LoginP
 	)
 
 	otpUpdate := t.buildOTPVerifyUpdate(p.PhoneHash, p.OTPExpiresAt, p.OTPMAC)
-	sessionPut := t.buildSessionPut(p.SessionID, p.UserID, p.DeviceID, p.RefreshTokenHash, p.CreatedAt, p.SessionExpiresAt, p.SessionTTL)
+	sessionPut := t.buildSessionPut(sessionItem{
+		SessionID:        p.SessionID,
+		UserID:           p.UserID,
+		DeviceID:         p.DeviceID,
+		FamilyID:         p.FamilyID,
+		RefreshTokenHash: p.RefreshTokenHash,
+		CreatedAt:        p.CreatedAt.String(),
+		ExpiresAt:        p.SessionExpiresAt.String(),
+		TTL:              p.SessionTTL,
+	})
+
+	items := []dynamo.TransactWriteItem{otpUpdate, sessionPut}
+	names := []string{"otp_update", "session_put"}
+	items, names = t.appendSessionDeletes(items, names, p.UserID, p.EvictSessionIDs)
 
 	_, err := t.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		txErr := t.classifyTxError(err, "verify otp and create session", names...)
+		span.RecordError(txErr)
+		span.SetStatus(codes.Error, txErr.Error())
+		return txErr
+	}
+
+	return nil
+}
+
+// EvictAndCreateSession executes a TransactWriteItems that creates session
+// and deletes the sessions it replaces. The items are:
+//
+//	[0] sessionPut — creates the session in sessions table
+//	[1…] sessionDelete — deletes each of evictSessionIDs
+//
+// Returns domain.ErrAlreadyExists if the session already exists, and
+// domain.ErrVersionConflict if an evicted session no longer exists.
+func (t *Transactor) EvictAndCreateSession(ctx context.Context, session app.SessionRecord, evictSessionIDs []string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.evict_create_session")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	items := []dynamo.TransactWriteItem{t.buildSessionPut(toSessionItem(session))}
+	names := []string{"session_put"}
+	items, names = t.appendSessionDeletes(items, names, session.UserID, evictSessionIDs)
+
+	_, err := t.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		txErr := t.classifyTxError(err, "evict and create session", names...)
+		span.RecordError(txErr)
+		span.SetStatus(codes.Error, txErr.Error())
+		return txErr
+	}
+
+	return nil
+}
+
+// CreateImportedUser executes a 2-item TransactWriteItems for a user
+// migrated by bulk import. The two items are:
+//
+//	[0] userPut — creates the user in users table
+//	[1] phoneSentinelPut — ensures phone uniqueness (condition check)
+//
+// Returns domain.ErrAlreadyExists if the user or phone sentinel already
+// exists.
+func (t *Transactor) CreateImportedUser(ctx context.Context, user app.UserRecord) error {
+	ctx, span := tracer.Start(ctx, "dynamo.tx.create_imported_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "TransactWriteItems"),
+	)
+
+	item, err := t.userItem(ctx, user)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("create imported user: %w", err)
+	}
+	_, err = t.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
 		TransactItems: []dynamo.TransactWriteItem{
-			otpUpdate,
-			sessionPut,
+			t.buildUserPut(item),
+			phoneSentinelPut(t.usersTable, item.PhoneIndex, user.UserID),
 		},
 	})
 	if err != nil {
-		txErr := t.classifyTxError(err, "verify otp and create session",
-			"otp_update", "session_put")
+		txErr := t.classifyTxError(err, "create imported user", "user_put", "phone_sentinel")
 		span.RecordError(txErr)
 		span.SetStatus(codes.Error, txErr.Error())
 		return txErr
@@ -120,9 +239,12 @@ func (t *Transactor) VerifyOTPAndCreateSession(ctx context.Context, p app.LoginP
 }
 
 // buildOTPVerifyUpdate creates a TransactWriteItem that marks an OTP as verified.
-func (t *Transactor) buildOTPVerifyUpdate(phoneHash, expiresAt, otpMAC string) dynamo.TransactWriteItem {
+//
+// OTPs issued before expires_at carried milliseconds store it as whole-second
+// RFC 3339, so the condition accepts either spelling of the same instant.
+func (t *Transactor) buildOTPVerifyUpdate(phoneHash string, expiresAt domain.TimestampMS, otpMAC string) dynamo.TransactWriteItem {
 	updateExpr := "SET #st = :verified"
-	condExpr := "#st = :pending AND expires_at = :ea AND otp_mac = :mac"
+	condExpr := "#st = :pending AND expires_at IN (:ea, :ea_legacy) AND otp_mac = :mac"
 	return dynamo.TransactWriteItem{
 		Update: &dynamo.Update{
 			TableName: &t.otpTable,
@@ -135,25 +257,20 @@ func (t *Transactor) buildOTPVerifyUpdate(phoneHash, expiresAt, otpMAC string) d
 				"#st": "status",
 			},
 			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
-				":verified": &dynamo.AttributeValueMemberS{Value: "verified"},
-				":pending":  &dynamo.AttributeValueMemberS{Value: "pending"},
-				":ea":       &dynamo.AttributeValueMemberS{Value: expiresAt},
-				":mac":      &dynamo.AttributeValueMemberS{Value: otpMAC},
+				":verified":  &dynamo.AttributeValueMemberS{Value: "verified"},
+				":pending":   &dynamo.AttributeValueMemberS{Value: "pending"},
+				":ea":        &dynamo.AttributeValueMemberS{Value: expiresAt.String()},
+				":ea_legacy": &dynamo.AttributeValueMemberS{Value: expiresAt.Time().Format(time.RFC3339)},
+				":mac":       &dynamo.AttributeValueMemberS{Value: otpMAC},
 			},
 		},
 	}
 }
 
 // buildUserPut creates a TransactWriteItem that inserts a new user.
-func (t *Transactor) buildUserPut(userID, phoneNumber, now string) dynamo.TransactWriteItem {
+func (t *Transactor) buildUserPut(user userItem) dynamo.TransactWriteItem {
 	condExpr := "attribute_not_exists(user_id)"
-	item, _ := dynamo.MarshalMap(userItem{
-		UserID:      userID,
-		PhoneNumber: phoneNumber,
-		DisplayName: "",
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	})
+	item, _ := dynamo.MarshalMap(user)
 	return dynamo.TransactWriteItem{
 		Put: &dynamo.Put{
 			TableName:           &t.usersTable,
@@ -163,37 +280,47 @@ func (t *Transactor) buildUserPut(userID, phoneNumber, now string) dynamo.Transa
 	}
 }
 
-// buildPhoneSentinelPut creates a TransactWriteItem that enforces phone uniqueness.
-func (t *Transactor) buildPhoneSentinelPut(phoneNumber, userID string) dynamo.TransactWriteItem {
+// userItem converts u to its users table item: personal data encrypted,
+// and the phone's blind index derived.
+func (t *Transactor) userItem(ctx context.Context, u app.UserRecord) (userItem, error) {
+	item, err := encryptedUserItem(ctx, t.fields, u)
+	if err != nil {
+		return userItem{}, err
+	}
+	item.PhoneIndex = t.phones.Token(phoneField, u.PhoneNumber)
+	return item, nil
+}
+
+// phoneSentinelPrefix prefixes the user_id of the users table items that
+// reserve a phone number. Sentinels are keyed by the phone's blind index;
+// ones written before phone_index existed are keyed by the phone as the
+// user stored it.
+const phoneSentinelPrefix = "phone#"
+
+// phoneSentinelPut creates a TransactWriteItem that enforces phone
+// uniqueness, reserving the phone with blind index phoneIndex for userID.
+func phoneSentinelPut(usersTable, phoneIndex, userID string) dynamo.TransactWriteItem {
 	condExpr := "attribute_not_exists(user_id)"
 	item := map[string]dynamo.AttributeValue{
-		"user_id":      &dynamo.AttributeValueMemberS{Value: "phone#" + phoneNumber},
-		"phone_number": &dynamo.AttributeValueMemberS{Value: phoneNumber},
-		"owner_id":     &dynamo.AttributeValueMemberS{Value: userID},
+		"user_id":  &dynamo.AttributeValueMemberS{Value: phoneSentinelPrefix + phoneIndex},
+		"owner_id": &dynamo.AttributeValueMemberS{Value: userID},
 	}
 	return dynamo.TransactWriteItem{
 		Put: &dynamo.Put{
-			TableName:           &t.usersTable,
+			TableName:           &usersTable,
 			Item:                item,
 			ConditionExpression: &condExpr,
 		},
 	}
 }
 
-// buildSessionPut creates a TransactWriteItem that inserts a new session.
-func (t *Transactor) buildSessionPut(sessionID, userID, deviceID, refreshTokenHash, createdAt, expiresAt string, ttl int64) dynamo.TransactWriteItem {
+// buildSessionPut creates a TransactWriteItem that inserts a new session at
+// token generation 1.
+func (t *Transactor) buildSessionPut(session sessionItem) dynamo.TransactWriteItem {
 	condExpr := "attribute_not_exists(session_id)"
-	item, _ := dynamo.MarshalMap(sessionItem{
-		SessionID:        sessionID,
-		UserID:           userID,
-		DeviceID:         deviceID,
-		RefreshTokenHash: refreshTokenHash,
-		TokenGeneration:  1,
-		PrevTokenHash:    "",
-		CreatedAt:        createdAt,
-		ExpiresAt:        expiresAt,
-		TTL:              ttl,
-	})
+	session.TokenGeneration = 1
+	session.PrevTokenHash = ""
+	item, _ := dynamo.MarshalMap(session)
 	return dynamo.TransactWriteItem{
 		Put: &dynamo.Put{
 			TableName:           &t.sessionsTable,
@@ -203,9 +330,37 @@ func (t *Transactor) buildSessionPut(sessionID, userID, deviceID, refreshTokenHa
 	}
 }
 
+// sessionDeleteItem names the items that delete evicted sessions.
+const sessionDeleteItem = "session_delete"
+
+// appendSessionDeletes appends a delete of each of sessionIDs to items,
+// and its name to names. Each delete is conditioned on the session still
+// belonging to userID, so a login never acts on a session that another
+// login or logout has already removed.
+func (t *Transactor) appendSessionDeletes(items []dynamo.TransactWriteItem, names []string, userID string, sessionIDs []string) ([]dynamo.TransactWriteItem, []string) {
+	condExpr := "user_id = :uid"
+	for _, id := range sessionIDs {
+		items = append(items, dynamo.TransactWriteItem{
+			Delete: &dynamo.Delete{
+				TableName: &t.sessionsTable,
+				Key: map[string]dynamo.AttributeValue{
+					"session_id": &dynamo.AttributeValueMemberS{Value: id},
+				},
+				ConditionExpression: &condExpr,
+				ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+					":uid": &dynamo.AttributeValueMemberS{Value: userID},
+				},
+			},
+		})
+		names = append(names, sessionDeleteItem)
+	}
+	return items, names
+}
+
 // classifyTxError inspects a TransactWriteItems error and wraps it with
 // context. For TransactionCanceledException it checks each cancellation
-// reason and maps ConditionalCheckFailed to domain.ErrAlreadyExists.
+// reason and maps ConditionalCheckFailed to domain.ErrAlreadyExists, or to
+// domain.ErrVersionConflict for a session delete.
 func (t *Transactor) classifyTxError(err error, op string, itemNames ...string) error {
 	reasons, ok := dynamo.IsTransactionCanceledException(err)
 	if !ok {
@@ -218,8 +373,12 @@ func (t *Transactor) classifyTxError(err error, op string, itemNames ...string)
 			if i < len(itemNames) {
 				name = itemNames[i]
 			}
+			sentinel := domain.ErrAlreadyExists
+			if name == sessionDeleteItem {
+				sentinel = domain.ErrVersionConflict
+			}
 			return fmt.Errorf("transactor: %s: item %d (%s) condition failed: %w",
-				op, i, name, domain.ErrAlreadyExists)
+				op, i, name, sentinel)
 		}
 	}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,6 +12,7 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt/msgcrypttest"
 )
 
 // ---------------------------------------------------------------------------
@@ -40,15 +42,16 @@ const (
 func sampleRegistrationParams() app.RegistrationParams {
 	return app.RegistrationParams{
 		PhoneHash:        "sha256-phone-hash",
-		OTPExpiresAt:     "2026-02-10T12:05:00Z",
+		OTPExpiresAt:     domain.NewTimestampMS(fixedTime().Add(5 * time.Minute)),
 		OTPMAC:           "hmac-abc123",
 		UserID:           "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
 		PhoneNumber:      "+15551234567",
-		Now:              "2026-02-10T12:00:00Z",
+		Now:              domain.NewTimestampMS(fixedTime()),
 		SessionID:        "11111111-2222-3333-4444-555555555555",
+		FamilyID:         "ffffffff-0000-1111-2222-333333333333",
 		DeviceID:         "dddddddd-eeee-ffff-0000-111111111111",
 		RefreshTokenHash: "hash-refresh-abc",
-		SessionExpiresAt: "2026-03-12T12:00:00Z",
+		SessionExpiresAt: domain.NewTimestampMS(fixedTime().Add(30 * 24 * time.Hour)),
 		SessionTTL:       1741608000,
 	}
 }
@@ -56,14 +59,14 @@ func sampleRegistrationParams() app.RegistrationParams {
 func sampleLoginParams() app.LoginParams {
 	return app.LoginParams{
 		PhoneHash:        "sha256-phone-hash",
-		OTPExpiresAt:     "2026-02-10T12:05:00Z",
+		OTPExpiresAt:     domain.NewTimestampMS(fixedTime().Add(5 * time.Minute)),
 		OTPMAC:           "hmac-abc123",
 		SessionID:        "11111111-2222-3333-4444-555555555555",
 		UserID:           "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
 		DeviceID:         "dddddddd-eeee-ffff-0000-111111111111",
 		RefreshTokenHash: "hash-refresh-abc",
-		CreatedAt:        "2026-02-10T12:00:00Z",
-		SessionExpiresAt: "2026-03-12T12:00:00Z",
+		CreatedAt:        domain.NewTimestampMS(fixedTime()),
+		SessionExpiresAt: domain.NewTimestampMS(fixedTime().Add(30 * 24 * time.Hour)),
 		SessionTTL:       1741608000,
 	}
 }
@@ -97,13 +100,36 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				return &dynamo.TransactWriteItemsOutput{}, nil
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), sampleRegistrationParams())
 
 		require.NoError(t, err)
 	})
 
+	t.Run("encrypted fields - user and sentinel carry the blind index", func(t *testing.T) {
+		p := sampleRegistrationParams()
+		fields := msgcrypttest.NewFieldCipher(t)
+		want, err := fields.EncryptSearchable(context.Background(), phoneField, p.PhoneNumber)
+		require.NoError(t, err)
+		index := testPhones.Token(phoneField, p.PhoneNumber)
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				user := params.TransactItems[1].Put.Item
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: want}, user["phone_number"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: index}, user["phone_index"])
+
+				sentinel := params.TransactItems[2].Put.Item
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "phone#" + index}, sentinel["user_id"])
+				assert.NotContains(t, sentinel, "phone_number", "sentinels stay out of the phone indexes")
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, fields, testPhones)
+
+		require.NoError(t, tx.VerifyOTPAndCreateUser(context.Background(), p))
+	})
+
 	t.Run("otp update - verifies condition and key", func(t *testing.T) {
 		p := sampleRegistrationParams()
 		stub := &stubTxDynamo{
@@ -121,10 +147,15 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				assert.Contains(t, *otpUpdate.ConditionExpression, "#st = :pending")
 				assert.Contains(t, *otpUpdate.ConditionExpression, "otp_mac = :mac")
 
+				// Expiry matches in the current and the pre-millisecond layout.
+				assert.Contains(t, *otpUpdate.ConditionExpression, "expires_at IN (:ea, :ea_legacy)")
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-02-10T12:05:00.000Z"}, otpUpdate.ExpressionAttributeValues[":ea"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-02-10T12:05:00Z"}, otpUpdate.ExpressionAttributeValues[":ea_legacy"])
+
 				return &dynamo.TransactWriteItemsOutput{}, nil
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), p)
 
@@ -145,7 +176,7 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				return &dynamo.TransactWriteItemsOutput{}, nil
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), p)
 
@@ -162,11 +193,14 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				assert.Contains(t, *sessionPut.ConditionExpression, "attribute_not_exists(session_id)")
 				assert.Contains(t, sessionPut.Item, "session_id")
 				assert.Contains(t, sessionPut.Item, "refresh_token_hash")
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "2026-03-12T12:00:00.000Z"}, sessionPut.Item["expires_at"])
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: p.FamilyID}, sessionPut.Item["token_family"])
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "1"}, sessionPut.Item["token_generation"])
 
 				return &dynamo.TransactWriteItemsOutput{}, nil
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), p)
 
@@ -179,7 +213,7 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				return nil, dynamo.ErrTransactionCanceled("None", "ConditionalCheckFailed", "None", "None")
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), sampleRegistrationParams())
 
@@ -194,7 +228,7 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				return nil, dynamo.ErrTransactionCanceled("None", "None", "ConditionalCheckFailed", "None")
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), sampleRegistrationParams())
 
@@ -209,7 +243,7 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				return nil, errors.New("service unavailable")
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), sampleRegistrationParams())
 
@@ -223,7 +257,7 @@ func TestTransactor_VerifyOTPAndCreateUser(t *testing.T) {
 				return nil, dynamo.ErrTransactionCanceled("None", "None", "None", "None")
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateUser(context.Background(), sampleRegistrationParams())
 
@@ -254,7 +288,7 @@ func TestTransactor_VerifyOTPAndCreateSession(t *testing.T) {
 				return &dynamo.TransactWriteItemsOutput{}, nil
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateSession(context.Background(), sampleLoginParams())
 
@@ -267,7 +301,7 @@ func TestTransactor_VerifyOTPAndCreateSession(t *testing.T) {
 				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateSession(context.Background(), sampleLoginParams())
 
@@ -282,11 +316,173 @@ func TestTransactor_VerifyOTPAndCreateSession(t *testing.T) {
 				return nil, errors.New("network error")
 			},
 		}
-		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable)
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
 
 		err := tx.VerifyOTPAndCreateSession(context.Background(), sampleLoginParams())
 
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "transactor: verify otp and create session: network error")
 	})
+
+	t.Run("evictions - deletes each session in the same transaction", func(t *testing.T) {
+		p := sampleLoginParams()
+		p.EvictSessionIDs = []string{"old-1", "old-2"}
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 4)
+				for i, id := range p.EvictSessionIDs {
+					del := params.TransactItems[2+i].Delete
+					require.NotNil(t, del)
+					assert.Equal(t, txSessionsTable, *del.TableName)
+					assert.Equal(t, id, del.Key["session_id"].(*dynamo.AttributeValueMemberS).Value)
+					assert.Equal(t, "user_id = :uid", *del.ConditionExpression)
+					assert.Equal(t, p.UserID, del.ExpressionAttributeValues[":uid"].(*dynamo.AttributeValueMemberS).Value)
+				}
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		require.NoError(t, tx.VerifyOTPAndCreateSession(context.Background(), p))
+	})
+
+	t.Run("evicted session gone - returns ErrVersionConflict", func(t *testing.T) {
+		p := sampleLoginParams()
+		p.EvictSessionIDs = []string{"old-1"}
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("None", "None", "ConditionalCheckFailed")
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		err := tx.VerifyOTPAndCreateSession(context.Background(), p)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.Contains(t, err.Error(), "session_delete")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — EvictAndCreateSession
+// ---------------------------------------------------------------------------
+
+func TestTransactor_EvictAndCreateSession(t *testing.T) {
+	session := app.SessionRecord{
+		SessionID:        "11111111-2222-3333-4444-555555555555",
+		UserID:           "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+		DeviceID:         "dddddddd-eeee-ffff-0000-111111111111",
+		RefreshTokenHash: "hash-refresh-abc",
+		CreatedAt:        domain.NewTimestampMS(fixedTime()),
+		ExpiresAt:        domain.NewTimestampMS(fixedTime().Add(8 * time.Hour)),
+		MaxExpiresAt:     domain.NewTimestampMS(fixedTime().Add(8 * time.Hour)),
+		TTL:              1741608000,
+	}
+
+	t.Run("success - puts the session and deletes the evicted ones", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+
+				put := params.TransactItems[0].Put
+				require.NotNil(t, put)
+				assert.Equal(t, txSessionsTable, *put.TableName)
+				assert.Equal(t, "attribute_not_exists(session_id)", *put.ConditionExpression)
+				assert.Equal(t, session.MaxExpiresAt.String(), put.Item["max_expires_at"].(*dynamo.AttributeValueMemberS).Value)
+				assert.Equal(t, "1", put.Item["token_generation"].(*dynamo.AttributeValueMemberN).Value)
+
+				del := params.TransactItems[1].Delete
+				require.NotNil(t, del)
+				assert.Equal(t, "old-1", del.Key["session_id"].(*dynamo.AttributeValueMemberS).Value)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		require.NoError(t, tx.EvictAndCreateSession(context.Background(), session, []string{"old-1"}))
+	})
+
+	t.Run("session exists - returns ErrAlreadyExists", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None")
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		err := tx.EvictAndCreateSession(context.Background(), session, []string{"old-1"})
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+
+	t.Run("evicted session gone - returns ErrVersionConflict", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("None", "ConditionalCheckFailed")
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		err := tx.EvictAndCreateSession(context.Background(), session, []string{"old-1"})
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — CreateImportedUser
+// ---------------------------------------------------------------------------
+
+func TestTransactor_CreateImportedUser(t *testing.T) {
+	user := app.UserRecord{
+		UserID:      "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+		PhoneNumber: "+15551234567",
+		DisplayName: "Ada",
+		CreatedAt:   domain.NewTimestampMS(fixedTime()),
+		UpdatedAt:   domain.NewTimestampMS(fixedTime()),
+	}
+
+	t.Run("success - writes user and phone sentinel", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 2)
+
+				userPut := params.TransactItems[0].Put
+				require.NotNil(t, userPut)
+				assert.Equal(t, txUsersTable, *userPut.TableName)
+				assert.Equal(t, "attribute_not_exists(user_id)", *userPut.ConditionExpression)
+				name, ok := userPut.Item["display_name"].(*dynamo.AttributeValueMemberS)
+				require.True(t, ok)
+				assert.Equal(t, "Ada", name.Value)
+
+				sentinel := params.TransactItems[1].Put
+				require.NotNil(t, sentinel)
+				pk, ok := sentinel.Item["user_id"].(*dynamo.AttributeValueMemberS)
+				require.True(t, ok)
+				assert.Equal(t, "phone#"+testPhones.Token(phoneField, "+15551234567"), pk.Value)
+				owner, ok := sentinel.Item["owner_id"].(*dynamo.AttributeValueMemberS)
+				require.True(t, ok)
+				assert.Equal(t, user.UserID, owner.Value)
+
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		require.NoError(t, tx.CreateImportedUser(context.Background(), user))
+	})
+
+	t.Run("phone taken - returns ErrAlreadyExists", func(t *testing.T) {
+		stub := &stubTxDynamo{
+			transactWriteItemsFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("None", "ConditionalCheckFailed")
+			},
+		}
+		tx := NewTransactor(stub, txOTPTable, txUsersTable, txSessionsTable, nil, testPhones)
+
+		err := tx.CreateImportedUser(context.Background(), user)
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+		assert.Contains(t, err.Error(), "phone_sentinel")
+	})
 }
@@ -0,0 +1,272 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: UserStore satisfies app.UserDirectory.
+var _ app.UserDirectory = (*UserStore)(nil)
+
+// User directory GSIs (ADR-007 §2.4). Each is sorted by created_at, so a
+// listing is a newest-first range query on one or more partitions.
+const (
+	usersMonthIndex   = "created_month-index"
+	usersCountryIndex = "phone_country-index"
+	usersFlaggedIndex = "flagged-index" // sparse: flagged users only
+
+	// userFlagged is the only flag_status value; unflagged users have no
+	// flag_status and so are absent from usersFlaggedIndex.
+	userFlagged = "flagged"
+)
+
+// createdMonth returns the created_month partition for a creation time.
+func createdMonth(t domain.TimestampMS) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time().Format("2006-01")
+}
+
+// userQuery is the index, partition key and partitions (newest first) a
+// directory listing reads.
+type userQuery struct {
+	index      string
+	partKey    string
+	partitions []string
+	filter     string
+	values     map[string]dynamo.AttributeValue
+}
+
+// planUserQuery picks the narrowest index for filter. The flagged index is
+// small, so a country filter on it is applied as a filter expression;
+// otherwise the country index serves country filters and the month index
+// serves the rest, one month partition at a time.
+func planUserQuery(filter app.UserFilter) userQuery {
+	switch {
+	case filter.FlaggedOnly:
+		q := userQuery{index: usersFlaggedIndex, partKey: "flag_status", partitions: []string{userFlagged}}
+		if filter.CallingCode != "" {
+			q.filter = "phone_country = :cc"
+			q.values = map[string]dynamo.AttributeValue{
+				":cc": &dynamo.AttributeValueMemberS{Value: filter.CallingCode},
+			}
+		}
+		return q
+	case filter.CallingCode != "":
+		return userQuery{index: usersCountryIndex, partKey: "phone_country", partitions: []string{filter.CallingCode}}
+	default:
+		return userQuery{
+			index:      usersMonthIndex,
+			partKey:    "created_month",
+			partitions: monthsDescending(filter.CreatedFrom, filter.CreatedTo),
+		}
+	}
+}
+
+// monthsDescending returns the created_month partitions overlapping
+// [from, to), newest first.
+func monthsDescending(from, to domain.TimestampMS) []string {
+	last := to.Add(-time.Millisecond).Time()
+	first := from.Time()
+	month := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, time.UTC)
+	stop := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var months []string
+	for !month.Before(stop) {
+		months = append(months, month.Format("2006-01"))
+		month = month.AddDate(0, -1, 0)
+	}
+	return months
+}
+
+// ListUsers returns up to limit users matching filter, newest first, after
+// the position after. A listing resumes with an ExclusiveStartKey rebuilt
+// from after, and continues through partitions and pages until it is full
+// or the window is exhausted.
+//
+// Users created before the directory attributes were introduced are not in
+// the directory GSIs until backfilled.
+func (s *UserStore) ListUsers(
+	ctx context.Context, filter app.UserFilter, after *app.UserPosition, limit int,
+) ([]app.UserRecord, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.list_users")
+	defer span.End()
+
+	q := planUserQuery(filter)
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+		attribute.String("db.dynamodb.index", q.index),
+	)
+
+	keyExpr := "#pk = :pk AND created_at BETWEEN :from AND :to"
+	var filterExpr *string
+	if q.filter != "" {
+		filterExpr = &q.filter
+	}
+
+	partitions := q.partitions
+	var startKey map[string]dynamo.AttributeValue
+	if after != nil {
+		afterPart := partitions[0]
+		if q.index == usersMonthIndex {
+			afterPart = createdMonth(after.CreatedAt)
+		}
+		for len(partitions) > 0 && partitions[0] > afterPart {
+			partitions = partitions[1:]
+		}
+		if len(partitions) > 0 && partitions[0] == afterPart {
+			startKey = map[string]dynamo.AttributeValue{
+				"user_id":    &dynamo.AttributeValueMemberS{Value: after.UserID},
+				"created_at": &dynamo.AttributeValueMemberS{Value: after.CreatedAt.String()},
+				q.partKey:    &dynamo.AttributeValueMemberS{Value: afterPart},
+			}
+		}
+	}
+
+	var users []app.UserRecord
+	for _, part := range partitions {
+		values := map[string]dynamo.AttributeValue{
+			":pk":   &dynamo.AttributeValueMemberS{Value: part},
+			":from": &dynamo.AttributeValueMemberS{Value: filter.CreatedFrom.String()},
+			":to":   &dynamo.AttributeValueMemberS{Value: filter.CreatedTo.Add(-time.Millisecond).String()},
+		}
+		for k, v := range q.values {
+			values[k] = v
+		}
+
+		for {
+			// Check context between pages per 04_CONTEXT.
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("user store: list users: %w", err)
+			}
+
+			pageLimit := int32(limit - len(users))
+			scanForward := false
+			out, err := s.db.Query(ctx, &dynamo.QueryInput{
+				TableName:                 &s.tableName,
+				IndexName:                 &q.index,
+				KeyConditionExpression:    &keyExpr,
+				FilterExpression:          filterExpr,
+				ExpressionAttributeNames:  map[string]string{"#pk": q.partKey},
+				ExpressionAttributeValues: values,
+				ScanIndexForward:          &scanForward,
+				Limit:                     &pageLimit,
+				ExclusiveStartKey:         startKey,
+			})
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, fmt.Errorf("user store: list users: %w", err)
+			}
+
+			for _, av := range out.Items {
+				var item userItem
+				if err := dynamo.UnmarshalMap(av, &item); err != nil {
+					return nil, fmt.Errorf("user store: unmarshal user: %w", err)
+				}
+				if err := decryptUserItem(ctx, s.fields, &item); err != nil {
+					return nil, fmt.Errorf("user store: %w", err)
+				}
+				user, err := fromUserItem(item)
+				if err != nil {
+					return nil, fmt.Errorf("user store: %w", err)
+				}
+				users = append(users, *user)
+				if len(users) == limit {
+					return users, nil
+				}
+			}
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			startKey = out.LastEvaluatedKey
+		}
+		startKey = nil
+	}
+	return users, nil
+}
+
+// userFlagCondition matches real users: phone sentinels share the users
+// table but have no created_at.
+const userFlagCondition = "attribute_exists(created_at)"
+
+// FlagUser flags userID for review, which adds it to the flagged index.
+// Returns domain.ErrNotFound when the user does not exist.
+func (s *UserStore) FlagUser(ctx context.Context, userID, reason string, at domain.TimestampMS) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.flag_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	condExpr := userFlagCondition
+	updateExpr := "SET flag_status = :flagged, flag_reason = :reason, flagged_at = :at"
+
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":flagged": &dynamo.AttributeValueMemberS{Value: userFlagged},
+			":reason":  &dynamo.AttributeValueMemberS{Value: reason},
+			":at":      &dynamo.AttributeValueMemberS{Value: at.String()},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("user store: flag user: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("user store: flag user: %w", err)
+	}
+
+	return nil
+}
+
+// UnflagUser clears userID's flag, which removes it from the flagged
+// index. Returns domain.ErrNotFound when the user does not exist.
+func (s *UserStore) UnflagUser(ctx context.Context, userID string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.unflag_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	condExpr := userFlagCondition
+	updateExpr := "REMOVE flag_status, flag_reason, flagged_at"
+
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("user store: unflag user: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("user store: unflag user: %w", err)
+	}
+
+	return nil
+}
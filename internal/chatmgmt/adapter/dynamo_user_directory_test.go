@@ -0,0 +1,229 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+func directoryItem(t *testing.T, userID, createdAt string) map[string]dynamo.AttributeValue {
+	t.Helper()
+	av, err := dynamo.MarshalMap(userItem{
+		UserID:      userID,
+		PhoneNumber: "+447700900123",
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	})
+	require.NoError(t, err)
+	return av
+}
+
+func directoryWindow(from, to string) app.UserFilter {
+	f, _ := domain.ParseTimestamp(from)
+	t, _ := domain.ParseTimestamp(to)
+	return app.UserFilter{CreatedFrom: f, CreatedTo: t}
+}
+
+func TestToUserItem(t *testing.T) {
+	created := domain.NewTimestampMS(time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC))
+
+	t.Run("derives the directory keys", func(t *testing.T) {
+		item := toUserItem(app.UserRecord{UserID: "u1", PhoneNumber: "+447700900123", CreatedAt: created, UpdatedAt: created})
+
+		assert.Equal(t, "2026-03", item.CreatedMonth)
+		assert.Equal(t, "44", item.PhoneCountry)
+		assert.Empty(t, item.FlagStatus)
+
+		av, err := dynamo.MarshalMap(item)
+		require.NoError(t, err)
+		assert.NotContains(t, av, "flag_status", "unflagged users stay out of the sparse index")
+	})
+
+	t.Run("flagged user", func(t *testing.T) {
+		item := toUserItem(app.UserRecord{UserID: "u1", PhoneNumber: "+15551234567", CreatedAt: created,
+			FlagReason: "spam", FlaggedAt: created})
+
+		assert.Equal(t, "1", item.PhoneCountry)
+		assert.Equal(t, userFlagged, item.FlagStatus)
+		assert.Equal(t, "2026-03-31T23:59:59.000Z", item.FlaggedAt)
+	})
+
+	t.Run("unparseable phone has no country", func(t *testing.T) {
+		assert.Empty(t, toUserItem(app.UserRecord{UserID: "u1", PhoneNumber: "+999", CreatedAt: created}).PhoneCountry)
+	})
+}
+
+func TestPlanUserQuery(t *testing.T) {
+	window := directoryWindow("2025-12-20T00:00:00.000Z", "2026-02-01T00:00:00.000Z")
+
+	byMonth := planUserQuery(window)
+	assert.Equal(t, usersMonthIndex, byMonth.index)
+	assert.Equal(t, []string{"2026-01", "2025-12"}, byMonth.partitions, "end is exclusive")
+
+	window.CallingCode = "44"
+	byCountry := planUserQuery(window)
+	assert.Equal(t, usersCountryIndex, byCountry.index)
+	assert.Equal(t, []string{"44"}, byCountry.partitions)
+	assert.Empty(t, byCountry.filter)
+
+	window.FlaggedOnly = true
+	flagged := planUserQuery(window)
+	assert.Equal(t, usersFlaggedIndex, flagged.index)
+	assert.Equal(t, []string{userFlagged}, flagged.partitions)
+	assert.Equal(t, "phone_country = :cc", flagged.filter)
+}
+
+func TestUserStore_ListUsers(t *testing.T) {
+	t.Run("walks month partitions newest first", func(t *testing.T) {
+		var calls []*dynamo.QueryInput
+		stub := &stubUserDynamo{queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			calls = append(calls, params)
+			part := params.ExpressionAttributeValues[":pk"].(*dynamo.AttributeValueMemberS).Value
+			switch {
+			case part == "2026-02":
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+					directoryItem(t, "u3", "2026-02-03T00:00:00.000Z"),
+				}}, nil
+			case part == "2026-01" && params.ExclusiveStartKey == nil:
+				return &dynamo.QueryOutput{
+					Items:            []map[string]dynamo.AttributeValue{directoryItem(t, "u2", "2026-01-20T00:00:00.000Z")},
+					LastEvaluatedKey: map[string]dynamo.AttributeValue{"user_id": &dynamo.AttributeValueMemberS{Value: "u2"}},
+				}, nil
+			default:
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+					directoryItem(t, "u1", "2026-01-10T00:00:00.000Z"),
+				}}, nil
+			}
+		}}
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
+
+		users, err := store.ListUsers(context.Background(),
+			directoryWindow("2026-01-01T00:00:00.000Z", "2026-02-10T00:00:00.000Z"), nil, 3)
+
+		require.NoError(t, err)
+		require.Len(t, users, 3)
+		assert.Equal(t, []string{"u3", "u2", "u1"}, []string{users[0].UserID, users[1].UserID, users[2].UserID})
+		require.Len(t, calls, 3)
+
+		first := calls[0]
+		assert.Equal(t, usersMonthIndex, *first.IndexName)
+		assert.Equal(t, "#pk = :pk AND created_at BETWEEN :from AND :to", *first.KeyConditionExpression)
+		assert.Equal(t, "created_month", first.ExpressionAttributeNames["#pk"])
+		assert.False(t, *first.ScanIndexForward)
+		assert.Equal(t, int32(3), *first.Limit)
+		assert.Equal(t, "2026-01-01T00:00:00.000Z", first.ExpressionAttributeValues[":from"].(*dynamo.AttributeValueMemberS).Value)
+		assert.Equal(t, "2026-02-09T23:59:59.999Z", first.ExpressionAttributeValues[":to"].(*dynamo.AttributeValueMemberS).Value)
+		assert.Nil(t, first.FilterExpression)
+
+		assert.Equal(t, int32(2), *calls[1].Limit, "later queries ask only for what the page still needs")
+		assert.NotNil(t, calls[2].ExclusiveStartKey)
+	})
+
+	t.Run("resumes after the cursor position", func(t *testing.T) {
+		var calls []*dynamo.QueryInput
+		stub := &stubUserDynamo{queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			calls = append(calls, params)
+			return &dynamo.QueryOutput{}, nil
+		}}
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
+		after := &app.UserPosition{
+			CreatedAt: domain.NewTimestampMS(time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)),
+			UserID:    "u2",
+		}
+
+		users, err := store.ListUsers(context.Background(),
+			directoryWindow("2025-12-01T00:00:00.000Z", "2026-02-10T00:00:00.000Z"), after, 10)
+
+		require.NoError(t, err)
+		assert.Empty(t, users)
+		require.Len(t, calls, 2, "the newer February partition is skipped")
+		assert.Equal(t, "2026-01", calls[0].ExpressionAttributeValues[":pk"].(*dynamo.AttributeValueMemberS).Value)
+		assert.Equal(t, map[string]dynamo.AttributeValue{
+			"user_id":       &dynamo.AttributeValueMemberS{Value: "u2"},
+			"created_at":    &dynamo.AttributeValueMemberS{Value: "2026-01-20T00:00:00.000Z"},
+			"created_month": &dynamo.AttributeValueMemberS{Value: "2026-01"},
+		}, calls[0].ExclusiveStartKey)
+		assert.Equal(t, "2025-12", calls[1].ExpressionAttributeValues[":pk"].(*dynamo.AttributeValueMemberS).Value)
+		assert.Nil(t, calls[1].ExclusiveStartKey)
+	})
+
+	t.Run("flagged with country filter", func(t *testing.T) {
+		var got *dynamo.QueryInput
+		stub := &stubUserDynamo{queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			got = params
+			return &dynamo.QueryOutput{}, nil
+		}}
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
+		filter := directoryWindow("2026-01-01T00:00:00.000Z", "2026-02-01T00:00:00.000Z")
+		filter.FlaggedOnly, filter.CallingCode = true, "44"
+
+		_, err := store.ListUsers(context.Background(), filter, nil, 10)
+
+		require.NoError(t, err)
+		assert.Equal(t, usersFlaggedIndex, *got.IndexName)
+		assert.Equal(t, "flag_status", got.ExpressionAttributeNames["#pk"])
+		assert.Equal(t, "phone_country = :cc", *got.FilterExpression)
+		assert.Equal(t, "44", got.ExpressionAttributeValues[":cc"].(*dynamo.AttributeValueMemberS).Value)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		stub := &stubUserDynamo{queryFn: func(_ context.Context, _ *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			return nil, errors.New("throttled")
+		}}
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
+
+		_, err := store.ListUsers(context.Background(),
+			directoryWindow("2026-01-01T00:00:00.000Z", "2026-02-01T00:00:00.000Z"), nil, 10)
+
+		assert.ErrorContains(t, err, "user store: list users: throttled")
+	})
+}
+
+func TestUserStore_FlagUser(t *testing.T) {
+	at := domain.NewTimestampMS(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	t.Run("sets the flag attributes", func(t *testing.T) {
+		var got *dynamo.UpdateItemInput
+		stub := &stubUserDynamo{updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+			got = params
+			return &dynamo.UpdateItemOutput{}, nil
+		}}
+
+		require.NoError(t, NewUserStore(stub, usersTable, nil, testPhones, true).FlagUser(context.Background(), "u1", "spam", at))
+
+		assert.Equal(t, "SET flag_status = :flagged, flag_reason = :reason, flagged_at = :at", *got.UpdateExpression)
+		assert.Equal(t, "attribute_exists(created_at)", *got.ConditionExpression)
+		assert.Equal(t, "2026-03-01T09:00:00.000Z", got.ExpressionAttributeValues[":at"].(*dynamo.AttributeValueMemberS).Value)
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		stub := &stubUserDynamo{updateFn: func(_ context.Context, _ *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+			return nil, dynamo.ErrConditionalCheckFailed()
+		}}
+
+		err := NewUserStore(stub, usersTable, nil, testPhones, true).FlagUser(context.Background(), "u1", "spam", at)
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestUserStore_UnflagUser(t *testing.T) {
+	var got *dynamo.UpdateItemInput
+	stub := &stubUserDynamo{updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+		got = params
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}}
+
+	err := NewUserStore(stub, usersTable, nil, testPhones, true).UnflagUser(context.Background(), "u1")
+
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Equal(t, "REMOVE flag_status, flag_reason, flagged_at", *got.UpdateExpression)
+}
@@ -3,6 +3,8 @@ package adapter
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -10,51 +12,158 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
 )
 
-// Compile-time check: UserStore satisfies app.UserStore.
-var _ app.UserStore = (*UserStore)(nil)
+// Compile-time checks: UserStore satisfies app.UserStore,
+// app.LanguagePreferenceStore, app.SMSFallbackStore,
+// app.PrivacySettingsStore and app.DNDScheduleStore.
+var (
+	_ app.UserStore               = (*UserStore)(nil)
+	_ app.LanguagePreferenceStore = (*UserStore)(nil)
+	_ app.SMSFallbackStore        = (*UserStore)(nil)
+	_ app.PrivacySettingsStore    = (*UserStore)(nil)
+	_ app.DNDScheduleStore        = (*UserStore)(nil)
+)
 
 // userDynamoDB is a narrow, consumer-defined interface for DynamoDB operations
 // required by the user store. The *dynamodb.Client satisfies this interface.
 type userDynamoDB interface {
 	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
 	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
 }
 
 // userItem is the DynamoDB item shape for the users table.
 type userItem struct {
 	UserID      string `dynamodbav:"user_id"`
-	PhoneNumber string `dynamodbav:"phone_number"`
+	PhoneNumber string `dynamodbav:"phone_number,omitempty"` // unset for SSO users, so they stay out of phone_number-index
+	PhoneIndex  string `dynamodbav:"phone_index,omitempty"`  // blind index of the phone number, keying phone_index-index
 	DisplayName string `dynamodbav:"display_name"`
 	CreatedAt   string `dynamodbav:"created_at"`
 	UpdatedAt   string `dynamodbav:"updated_at"`
+
+	// Partition keys of the user directory GSIs, derived on write.
+	CreatedMonth string `dynamodbav:"created_month,omitempty"`
+	PhoneCountry string `dynamodbav:"phone_country,omitempty"`
+	FlagStatus   string `dynamodbav:"flag_status,omitempty"`
+	FlagReason   string `dynamodbav:"flag_reason,omitempty"`
+	FlaggedAt    string `dynamodbav:"flagged_at,omitempty"`
+}
+
+// toUserItem converts an app.UserRecord to its DynamoDB item, deriving the
+// user directory index keys. A phone number that does not parse leaves
+// phone_country unset, so the user is only listed without a country filter.
+func toUserItem(u app.UserRecord) userItem {
+	item := userItem{
+		UserID:       u.UserID,
+		PhoneNumber:  u.PhoneNumber,
+		DisplayName:  u.DisplayName,
+		CreatedAt:    u.CreatedAt.String(),
+		UpdatedAt:    u.UpdatedAt.String(),
+		CreatedMonth: createdMonth(u.CreatedAt),
+		FlagReason:   u.FlagReason,
+		FlaggedAt:    u.FlaggedAt.String(),
+	}
+	if phone, err := domain.NewPhoneNumber(u.PhoneNumber); err == nil {
+		item.PhoneCountry = phone.CallingCode()
+	}
+	if u.FlagReason != "" {
+		item.FlagStatus = userFlagged
+	}
+	return item
+}
+
+// Attribute names of the users table's encrypted fields. Encrypted values
+// are bound to their attribute.
+const (
+	phoneField       = "phone_number"
+	displayNameField = "display_name"
+)
+
+// The phone number indexes of the users table. phone_index-index is keyed
+// by the phone's blind index; legacy phone_number-index by the phone as
+// stored, and is kept only until phone_index is backfilled.
+const (
+	phoneIndexField      = "phone_index"
+	phoneIndexName       = "phone_index-index"
+	legacyPhoneIndexName = "phone_number-index"
+)
+
+// encryptedUserItem converts u to its DynamoDB item with its personal data
+// encrypted by fields: the phone number searchably, so legacy
+// phone_number-index still finds it, and the display name with a fresh
+// nonce. A nil fields leaves them in plaintext.
+func encryptedUserItem(ctx context.Context, fields *msgcrypt.FieldCipher, u app.UserRecord) (userItem, error) {
+	item := toUserItem(u)
+	var err error
+	if item.PhoneNumber, err = fields.EncryptSearchable(ctx, phoneField, item.PhoneNumber); err != nil {
+		return userItem{}, err
+	}
+	if item.DisplayName, err = fields.Encrypt(ctx, displayNameField, item.DisplayName); err != nil {
+		return userItem{}, err
+	}
+	return item, nil
+}
+
+// decryptUserItem replaces item's encrypted personal data with its
+// plaintext. Plaintext values, from before encryption was turned on, are
+// kept as they are.
+func decryptUserItem(ctx context.Context, fields *msgcrypt.FieldCipher, item *userItem) error {
+	var err error
+	if item.PhoneNumber, err = fields.Decrypt(ctx, phoneField, item.PhoneNumber); err != nil {
+		return err
+	}
+	item.DisplayName, err = fields.Decrypt(ctx, displayNameField, item.DisplayName)
+	return err
 }
 
 // fromUserItem converts a DynamoDB item to an app.UserRecord.
-func fromUserItem(item userItem) *app.UserRecord {
+func fromUserItem(item userItem) (*app.UserRecord, error) {
+	createdAt, err := domain.ParseTimestamp(item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse created_at: %w", err)
+	}
+	updatedAt, err := domain.ParseTimestamp(item.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse updated_at: %w", err)
+	}
+	flaggedAt, err := domain.ParseTimestamp(item.FlaggedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse flagged_at: %w", err)
+	}
 	return &app.UserRecord{
 		UserID:      item.UserID,
 		PhoneNumber: item.PhoneNumber,
 		DisplayName: item.DisplayName,
-		CreatedAt:   item.CreatedAt,
-		UpdatedAt:   item.UpdatedAt,
-	}
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		FlagReason:  item.FlagReason,
+		FlaggedAt:   flaggedAt,
+	}, nil
 }
 
 // UserStore persists user records in DynamoDB.
 type UserStore struct {
-	db        userDynamoDB
-	tableName string
-	indexName string
+	db           userDynamoDB
+	tableName    string
+	fields       *msgcrypt.FieldCipher
+	phones       *msgcrypt.BlindIndex
+	legacyLookup bool
 }
 
 // NewUserStore creates a UserStore backed by the given DynamoDB client.
-func NewUserStore(db userDynamoDB, tableName string) *UserStore {
+// Phone numbers and display names are encrypted with fields, or stored in
+// plaintext if it is nil; phones derives the phone_index blind index. With
+// legacyLookup, FindByPhone also searches phone_number-index, for users
+// phone_index has not been backfilled for.
+func NewUserStore(db userDynamoDB, tableName string, fields *msgcrypt.FieldCipher, phones *msgcrypt.BlindIndex, legacyLookup bool) *UserStore {
 	return &UserStore{
-		db:        db,
-		tableName: tableName,
-		indexName: "phone_number-index",
+		db:           db,
+		tableName:    tableName,
+		fields:       fields,
+		phones:       phones,
+		legacyLookup: legacyLookup,
 	}
 }
 
@@ -93,14 +202,29 @@ func (s *UserStore) GetByID(ctx context.Context, userID string) (*app.UserRecord
 		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("user store: unmarshal user: %w", err)
 	}
+	if err := decryptUserItem(ctx, s.fields, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("user store: %w", err)
+	}
 
-	return fromUserItem(item), nil
+	user, err := fromUserItem(item)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("user store: %w", err)
+	}
+	return user, nil
 }
 
-// FindByPhone looks up a user by phone number via the phone_number-index GSI,
-// then fetches the full record with a consistent GetItem read.
+// FindByPhone looks up a user by phone number via the phone_index-index
+// GSI, then fetches the full record with a consistent GetItem read.
 // Returns domain.ErrNotFound when no user exists for the given phone number.
 //
+// With legacy lookup on, users written before phone_index existed are then
+// searched for in phone_number-index: for the number's encryption under
+// each key version, newest first, and then for the plaintext number.
+//
 // Per 04_CONTEXT_AND_LIFECYCLE: checks ctx.Err() between the Query and GetItem
 // steps to honour cancellation between multi-step operations.
 func (s *UserStore) FindByPhone(ctx context.Context, phoneNumber string) (*app.UserRecord, error) {
@@ -111,42 +235,509 @@ func (s *UserStore) FindByPhone(ctx context.Context, phoneNumber string) (*app.U
 		attribute.String("db.operation", "Query+GetItem"),
 	)
 
-	keyExpr := "phone_number = :phone"
+	queries := []phoneQuery{{
+		index:     phoneIndexName,
+		attribute: phoneIndexField,
+		value:     s.phones.Token(phoneField, phoneNumber),
+	}}
+	if s.legacyLookup {
+		values, err := s.fields.SearchValues(ctx, phoneField, phoneNumber)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("user store: find by phone: %w", err)
+		}
+		for _, value := range values {
+			queries = append(queries, phoneQuery{index: legacyPhoneIndexName, attribute: phoneField, value: value})
+		}
+	}
+
+	var userID string
+	for _, q := range queries {
+		var err error
+		if userID, err = s.queryPhone(ctx, q); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("user store: find by phone query: %w", err)
+		}
+		if userID != "" {
+			break
+		}
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("user store: find by phone: %w", domain.ErrNotFound)
+	}
 
-	queryOut, err := s.db.Query(ctx, &dynamo.QueryInput{
+	// Check context between multi-step operations per 04_CONTEXT.
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("user store: find by phone: %w", err)
+	}
+
+	return s.GetByID(ctx, userID)
+}
+
+// phoneQuery is one index lookup FindByPhone tries.
+type phoneQuery struct {
+	index     string
+	attribute string
+	value     string
+}
+
+// queryPhone returns the ID of the user q finds, or "" if there is none.
+// Legacy phone sentinels, which phone_number-index also holds, are
+// filtered out.
+func (s *UserStore) queryPhone(ctx context.Context, q phoneQuery) (string, error) {
+	keyExpr := q.attribute + " = :phone"
+	filterExpr := "NOT begins_with(user_id, :sentinel)"
+	out, err := s.db.Query(ctx, &dynamo.QueryInput{
 		TableName:              &s.tableName,
-		IndexName:              &s.indexName,
+		IndexName:              &q.index,
 		KeyConditionExpression: &keyExpr,
+		FilterExpression:       &filterExpr,
 		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
-			":phone": &dynamo.AttributeValueMemberS{Value: phoneNumber},
+			":phone":    &dynamo.AttributeValueMemberS{Value: q.value},
+			":sentinel": &dynamo.AttributeValueMemberS{Value: phoneSentinelPrefix},
 		},
 	})
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-		return nil, fmt.Errorf("user store: find by phone query: %w", err)
+		return "", err
 	}
-
-	if len(queryOut.Items) == 0 {
-		return nil, fmt.Errorf("user store: find by phone: %w", domain.ErrNotFound)
+	if len(out.Items) == 0 {
+		return "", nil
 	}
 
 	// Extract user_id from the GSI projection.
 	var projected struct {
 		UserID string `dynamodbav:"user_id"`
 	}
-	if err := dynamo.UnmarshalMap(queryOut.Items[0], &projected); err != nil {
+	if err := dynamo.UnmarshalMap(out.Items[0], &projected); err != nil {
+		return "", fmt.Errorf("unmarshal gsi projection: %w", err)
+	}
+	return projected.UserID, nil
+}
+
+// PreferredLanguage returns the user's preferred translation language, or
+// "" when none is set.
+func (s *UserStore) PreferredLanguage(ctx context.Context, userID string) (string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.preferred_language")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "preferred_language"
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return nil, fmt.Errorf("user store: unmarshal gsi projection: %w", err)
+		return "", fmt.Errorf("user store: preferred language: %w", err)
 	}
 
-	// Check context between multi-step operations per 04_CONTEXT.
-	if err := ctx.Err(); err != nil {
+	var item struct {
+		PreferredLanguage string `dynamodbav:"preferred_language"`
+	}
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return nil, fmt.Errorf("user store: find by phone: %w", err)
+		return "", fmt.Errorf("user store: unmarshal preferred language: %w", err)
+	}
+
+	return item.PreferredLanguage, nil
+}
+
+// SetPreferredLanguage stores the user's preferred translation language;
+// an empty language removes it. Returns domain.ErrNotFound when the user
+// does not exist.
+func (s *UserStore) SetPreferredLanguage(ctx context.Context, userID, language string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.set_preferred_language")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	condExpr := "attribute_exists(user_id)"
+	updateExpr := "REMOVE preferred_language"
+	var values map[string]dynamo.AttributeValue
+	if language != "" {
+		updateExpr = "SET preferred_language = :lang"
+		values = map[string]dynamo.AttributeValue{
+			":lang": &dynamo.AttributeValueMemberS{Value: language},
+		}
+	}
+
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:          &updateExpr,
+		ConditionExpression:       &condExpr,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("user store: set preferred language: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("user store: set preferred language: %w", err)
+	}
+
+	return nil
+}
+
+// SMSFallback returns the user's SMS fallback setting. Absent attributes
+// read as off with the default threshold.
+func (s *UserStore) SMSFallback(ctx context.Context, userID string) (domain.SMSFallback, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.sms_fallback")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "sms_fallback_enabled, sms_fallback_offline_after, settings_version"
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.SMSFallback{}, fmt.Errorf("user store: sms fallback: %w", err)
+	}
+
+	var item smsFallbackItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.SMSFallback{}, fmt.Errorf("user store: unmarshal sms fallback: %w", err)
+	}
+
+	return domain.SMSFallback{
+		Enabled:      item.Enabled,
+		OfflineAfter: time.Duration(item.OfflineAfter) * time.Second,
+		Version:      item.Version,
+	}, nil
+}
+
+// SetSMSFallback stores the user's SMS fallback setting and bumps their
+// settings version. The threshold is stored in whole seconds; zero removes
+// it so the default applies. Returns
+// domain.ErrNotFound when the user does not exist.
+func (s *UserStore) SetSMSFallback(ctx context.Context, userID string, setting domain.SMSFallback) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.set_sms_fallback")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	condExpr := "attribute_exists(user_id)"
+	updateExpr := "SET sms_fallback_enabled = :on REMOVE sms_fallback_offline_after ADD settings_version :one"
+	values := map[string]dynamo.AttributeValue{
+		":on":  &dynamo.AttributeValueMemberBOOL{Value: setting.Enabled},
+		":one": &dynamo.AttributeValueMemberN{Value: "1"},
+	}
+	if setting.OfflineAfter > 0 {
+		updateExpr = "SET sms_fallback_enabled = :on, sms_fallback_offline_after = :after ADD settings_version :one"
+		values[":after"] = &dynamo.AttributeValueMemberN{
+			Value: strconv.FormatInt(int64(setting.OfflineAfter/time.Second), 10),
+		}
+	}
+
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:          &updateExpr,
+		ConditionExpression:       &condExpr,
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("user store: set sms fallback: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("user store: set sms fallback: %w", err)
+	}
+
+	return nil
+}
+
+// PrivacySettings returns the user's activity-sharing settings. Absent
+// attributes read as false, so a user who never changed them shares
+// everything.
+func (s *UserStore) PrivacySettings(ctx context.Context, userID string) (domain.PrivacySettings, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.privacy_settings")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "hide_read_receipts, hide_typing, hide_last_seen, settings_version"
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.PrivacySettings{}, fmt.Errorf("user store: privacy settings: %w", err)
+	}
+
+	var item privacyItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.PrivacySettings{}, fmt.Errorf("user store: unmarshal privacy settings: %w", err)
 	}
 
-	return s.GetByID(ctx, projected.UserID)
+	return domain.PrivacySettings{
+		HideReadReceipts: item.HideReadReceipts,
+		HideTyping:       item.HideTyping,
+		HideLastSeen:     item.HideLastSeen,
+		Version:          item.Version,
+	}, nil
+}
+
+// SetPrivacySettings replaces the user's activity-sharing settings and
+// bumps their settings version. Returns domain.ErrNotFound when the user
+// does not exist.
+func (s *UserStore) SetPrivacySettings(ctx context.Context, userID string, settings domain.PrivacySettings) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.set_privacy_settings")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	condExpr := "attribute_exists(user_id)"
+	updateExpr := "SET hide_read_receipts = :receipts, hide_typing = :typing, hide_last_seen = :last_seen ADD settings_version :one"
+
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":receipts":  &dynamo.AttributeValueMemberBOOL{Value: settings.HideReadReceipts},
+			":typing":    &dynamo.AttributeValueMemberBOOL{Value: settings.HideTyping},
+			":last_seen": &dynamo.AttributeValueMemberBOOL{Value: settings.HideLastSeen},
+			":one":       &dynamo.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("user store: set privacy settings: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("user store: set privacy settings: %w", err)
+	}
+
+	return nil
+}
+
+// DNDSchedule returns the user's do-not-disturb schedule. A user who never
+// set one gets the zero schedule, which holds nothing.
+func (s *UserStore) DNDSchedule(ctx context.Context, userID string) (domain.DNDSchedule, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.dnd_schedule")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "dnd, settings_version"
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.DNDSchedule{}, fmt.Errorf("user store: dnd schedule: %w", err)
+	}
+
+	var item dndItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.DNDSchedule{}, fmt.Errorf("user store: unmarshal dnd schedule: %w", err)
+	}
+	schedule, err := item.DND.schedule()
+	if err != nil {
+		return domain.DNDSchedule{}, fmt.Errorf("user store: dnd schedule: %w", err)
+	}
+	schedule.Version = item.Version
+	return schedule, nil
+}
+
+// SetDNDSchedule replaces the user's do-not-disturb schedule and bumps
+// their settings version. Returns domain.ErrNotFound when the user does not
+// exist.
+func (s *UserStore) SetDNDSchedule(ctx context.Context, userID string, schedule domain.DNDSchedule) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.set_dnd_schedule")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	dnd, err := dynamo.MarshalMap(toDNDAttr(schedule))
+	if err != nil {
+		return fmt.Errorf("user store: marshal dnd schedule: %w", err)
+	}
+	condExpr := "attribute_exists(user_id)"
+	updateExpr := "SET dnd = :dnd ADD settings_version :one"
+
+	_, err = s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":dnd": &dynamo.AttributeValueMemberM{Value: dnd},
+			":one": &dynamo.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return fmt.Errorf("user store: set dnd schedule: %w", domain.ErrNotFound)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("user store: set dnd schedule: %w", err)
+	}
+
+	return nil
+}
+
+// dndItem is the projection of a users item holding the do-not-disturb
+// schedule. Fanout reads the same attribute.
+type dndItem struct {
+	DND     *dndAttr `dynamodbav:"dnd"`
+	Version int64    `dynamodbav:"settings_version"`
+}
+
+// dndAttr is the dnd map attribute. Windows are minutes after local
+// midnight; the time zone is an IANA name, empty for UTC.
+type dndAttr struct {
+	TimeZone string                 `dynamodbav:"time_zone,omitempty"`
+	Windows  []quietWindowAttr      `dynamodbav:"windows,omitempty"`
+	Chats    map[string]chatDNDAttr `dynamodbav:"chats,omitempty"`
+}
+
+type quietWindowAttr struct {
+	Start int `dynamodbav:"start"`
+	End   int `dynamodbav:"end"`
+}
+
+type chatDNDAttr struct {
+	Bypass  bool              `dynamodbav:"bypass,omitempty"`
+	Windows []quietWindowAttr `dynamodbav:"windows,omitempty"`
+}
+
+// toDNDAttr converts a schedule to its stored form.
+func toDNDAttr(s domain.DNDSchedule) dndAttr {
+	a := dndAttr{Windows: toQuietWindowAttrs(s.Windows)}
+	if s.Location != nil && s.Location != time.UTC {
+		a.TimeZone = s.Location.String()
+	}
+	if len(s.Chats) > 0 {
+		a.Chats = make(map[string]chatDNDAttr, len(s.Chats))
+		for chatID, o := range s.Chats {
+			a.Chats[chatID] = chatDNDAttr{Bypass: o.Bypass, Windows: toQuietWindowAttrs(o.Windows)}
+		}
+	}
+	return a
+}
+
+// schedule converts the stored form back; nil is the zero schedule.
+func (a *dndAttr) schedule() (domain.DNDSchedule, error) {
+	if a == nil {
+		return domain.DNDSchedule{}, nil
+	}
+	loc, err := domain.LoadDNDLocation(a.TimeZone)
+	if err != nil {
+		return domain.DNDSchedule{}, err
+	}
+	s := domain.DNDSchedule{Location: loc, Windows: fromQuietWindowAttrs(a.Windows)}
+	if len(a.Chats) > 0 {
+		s.Chats = make(map[string]domain.ChatDND, len(a.Chats))
+		for chatID, o := range a.Chats {
+			s.Chats[chatID] = domain.ChatDND{Bypass: o.Bypass, Windows: fromQuietWindowAttrs(o.Windows)}
+		}
+	}
+	return s, nil
+}
+
+func toQuietWindowAttrs(ws []domain.QuietWindow) []quietWindowAttr {
+	if len(ws) == 0 {
+		return nil
+	}
+	out := make([]quietWindowAttr, len(ws))
+	for i, w := range ws {
+		out[i] = quietWindowAttr{Start: w.Start, End: w.End}
+	}
+	return out
+}
+
+func fromQuietWindowAttrs(ws []quietWindowAttr) []domain.QuietWindow {
+	if len(ws) == 0 {
+		return nil
+	}
+	out := make([]domain.QuietWindow, len(ws))
+	for i, w := range ws {
+		out[i] = domain.QuietWindow{Start: w.Start, End: w.End}
+	}
+	return out
+}
+
+// privacyItem is the projection of a users item holding the privacy
+// settings. Fanout reads the same attributes.
+type privacyItem struct {
+	HideReadReceipts bool  `dynamodbav:"hide_read_receipts"`
+	HideTyping       bool  `dynamodbav:"hide_typing"`
+	HideLastSeen     bool  `dynamodbav:"hide_last_seen"`
+	Version          int64 `dynamodbav:"settings_version"`
+}
+
+// smsFallbackItem is the projection of a users item holding the SMS
+// fallback setting. Fanout reads the same attributes.
+type smsFallbackItem struct {
+	Enabled      bool  `dynamodbav:"sms_fallback_enabled"`
+	OfflineAfter int64 `dynamodbav:"sms_fallback_offline_after"`
+	Version      int64 `dynamodbav:"settings_version"`
 }
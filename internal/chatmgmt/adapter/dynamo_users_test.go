@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,6 +12,8 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt/msgcrypttest"
 )
 
 // ---------------------------------------------------------------------------
@@ -20,6 +23,7 @@ import (
 type stubUserDynamo struct {
 	getItemFn func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
 	queryFn   func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	updateFn  func(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
 }
 
 func (s *stubUserDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
@@ -30,6 +34,10 @@ func (s *stubUserDynamo) Query(ctx context.Context, params *dynamo.QueryInput, o
 	return s.queryFn(ctx, params, optFns...)
 }
 
+func (s *stubUserDynamo) UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	return s.updateFn(ctx, params, optFns...)
+}
+
 var _ userDynamoDB = (*stubUserDynamo)(nil)
 
 // ---------------------------------------------------------------------------
@@ -38,6 +46,9 @@ var _ userDynamoDB = (*stubUserDynamo)(nil)
 
 const usersTable = "users"
 
+// testPhones derives phone blind indexes in tests.
+var testPhones = msgcrypt.NewBlindIndex([]byte("test-pepper"))
+
 func sampleUserItem() userItem {
 	return userItem{
 		UserID:      "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
@@ -76,8 +87,8 @@ func TestUserStore_GetByID(t *testing.T) {
 				UserID:      "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
 				PhoneNumber: "+15551234567",
 				DisplayName: "Test User",
-				CreatedAt:   "2026-02-10T12:00:00Z",
-				UpdatedAt:   "2026-02-10T12:00:00Z",
+				CreatedAt:   domain.NewTimestampMS(fixedTime()),
+				UpdatedAt:   domain.NewTimestampMS(fixedTime()),
 			},
 		},
 		{
@@ -98,7 +109,7 @@ func TestUserStore_GetByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			store := NewUserStore(&stubUserDynamo{getItemFn: tt.getItemFn}, usersTable)
+			store := NewUserStore(&stubUserDynamo{getItemFn: tt.getItemFn}, usersTable, nil, testPhones, true)
 
 			rec, err := store.GetByID(context.Background(), "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee")
 
@@ -138,8 +149,10 @@ func TestUserStore_FindByPhone(t *testing.T) {
 			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
 				assert.Equal(t, usersTable, *params.TableName)
 				assert.NotNil(t, params.IndexName)
-				assert.Equal(t, "phone_number-index", *params.IndexName)
-				assert.Contains(t, *params.KeyConditionExpression, "phone_number = :phone")
+				assert.Equal(t, "phone_index-index", *params.IndexName)
+				assert.Contains(t, *params.KeyConditionExpression, "phone_index = :phone")
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: testPhones.Token(phoneField, "+15551234567")},
+					params.ExpressionAttributeValues[":phone"])
 
 				// Return projected item with just user_id.
 				projected, marshalErr := dynamo.MarshalMap(struct {
@@ -158,7 +171,7 @@ func TestUserStore_FindByPhone(t *testing.T) {
 			},
 		}
 
-		store := NewUserStore(stub, usersTable)
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
 
 		rec, err := store.FindByPhone(context.Background(), "+15551234567")
 
@@ -174,7 +187,7 @@ func TestUserStore_FindByPhone(t *testing.T) {
 				return &dynamo.QueryOutput{Items: nil}, nil
 			},
 		}
-		store := NewUserStore(stub, usersTable)
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
 
 		rec, err := store.FindByPhone(context.Background(), "+15559999999")
 
@@ -189,7 +202,7 @@ func TestUserStore_FindByPhone(t *testing.T) {
 				return nil, errors.New("throttled")
 			},
 		}
-		store := NewUserStore(stub, usersTable)
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
 
 		rec, err := store.FindByPhone(context.Background(), "+15551234567")
 
@@ -218,7 +231,7 @@ func TestUserStore_FindByPhone(t *testing.T) {
 				return nil, nil
 			},
 		}
-		store := NewUserStore(stub, usersTable)
+		store := NewUserStore(stub, usersTable, nil, testPhones, true)
 
 		_, err := store.FindByPhone(ctx, "+15551234567")
 
@@ -226,3 +239,361 @@ func TestUserStore_FindByPhone(t *testing.T) {
 		assert.ErrorIs(t, err, context.Canceled)
 	})
 }
+
+// ---------------------------------------------------------------------------
+// Tests — field encryption
+// ---------------------------------------------------------------------------
+
+func TestUserStore_EncryptedFields(t *testing.T) {
+	ctx := context.Background()
+	fields := msgcrypttest.NewFieldCipher(t)
+	rec := app.UserRecord{
+		UserID:      "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee",
+		PhoneNumber: "+15551234567",
+		DisplayName: "Test User",
+		CreatedAt:   domain.NewTimestampMS(time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)),
+		UpdatedAt:   domain.NewTimestampMS(time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)),
+	}
+	item, err := encryptedUserItem(ctx, fields, rec)
+	require.NoError(t, err)
+	assert.NotContains(t, item.PhoneNumber, "5551234567")
+	assert.NotContains(t, item.DisplayName, "Test User")
+	av, err := dynamo.MarshalMap(item)
+	require.NoError(t, err)
+
+	var queried []string
+	stub := &stubUserDynamo{
+		queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			value := params.ExpressionAttributeValues[":phone"].(*dynamo.AttributeValueMemberS).Value
+			queried = append(queried, value)
+			if value != item.PhoneNumber {
+				return &dynamo.QueryOutput{}, nil
+			}
+			return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{av}}, nil
+		},
+		getItemFn: func(_ context.Context, _ *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			return &dynamo.GetItemOutput{Item: av}, nil
+		},
+	}
+
+	t.Run("reads decrypt", func(t *testing.T) {
+		got, err := NewUserStore(stub, usersTable, fields, testPhones, true).GetByID(ctx, rec.UserID)
+
+		require.NoError(t, err)
+		assert.Equal(t, rec.PhoneNumber, got.PhoneNumber)
+		assert.Equal(t, rec.DisplayName, got.DisplayName)
+	})
+
+	t.Run("legacy lookup finds by phone under an older key version", func(t *testing.T) {
+		_, err := fields.Rotate(ctx)
+		require.NoError(t, err)
+		queried = nil
+
+		got, err := NewUserStore(stub, usersTable, fields, testPhones, true).FindByPhone(ctx, rec.PhoneNumber)
+
+		require.NoError(t, err)
+		assert.Equal(t, rec.PhoneNumber, got.PhoneNumber)
+		require.Len(t, queried, 3, "blind index, newest version, then the one the user is under")
+		assert.Equal(t, testPhones.Token(phoneField, rec.PhoneNumber), queried[0])
+		assert.Equal(t, item.PhoneNumber, queried[2])
+	})
+
+	t.Run("legacy lookup falls back to plaintext", func(t *testing.T) {
+		queried = nil
+
+		_, err := NewUserStore(stub, usersTable, fields, testPhones, true).FindByPhone(ctx, "+15559999999")
+
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		require.Len(t, queried, 4)
+		assert.Equal(t, "+15559999999", queried[3])
+	})
+
+	t.Run("without legacy lookup only the blind index is searched", func(t *testing.T) {
+		queried = nil
+
+		_, err := NewUserStore(stub, usersTable, fields, testPhones, false).FindByPhone(ctx, rec.PhoneNumber)
+
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		assert.Equal(t, []string{testPhones.Token(phoneField, rec.PhoneNumber)}, queried)
+	})
+
+	t.Run("encrypted item without a cipher", func(t *testing.T) {
+		_, err := NewUserStore(stub, usersTable, nil, testPhones, true).GetByID(ctx, rec.UserID)
+
+		require.Error(t, err)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — preferred language
+// ---------------------------------------------------------------------------
+
+func TestUserStore_PreferredLanguage(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, "preferred_language", *params.ProjectionExpression)
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"preferred_language": &dynamo.AttributeValueMemberS{Value: "pt-BR"},
+				}}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		lang, err := store.PreferredLanguage(context.Background(), "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, "pt-BR", lang)
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		lang, err := store.PreferredLanguage(context.Background(), "user-001")
+
+		require.NoError(t, err)
+		assert.Empty(t, lang)
+	})
+}
+
+func TestUserStore_SetPreferredLanguage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sets the attribute", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "SET preferred_language = :lang", *params.UpdateExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "de"}, params.ExpressionAttributeValues[":lang"])
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.NoError(t, store.SetPreferredLanguage(ctx, "user-001", "de"))
+	})
+
+	t.Run("empty removes the attribute", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "REMOVE preferred_language", *params.UpdateExpression)
+				assert.Nil(t, params.ExpressionAttributeValues)
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.NoError(t, store.SetPreferredLanguage(ctx, "user-001", ""))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.ErrorIs(t, store.SetPreferredLanguage(ctx, "user-404", "de"), domain.ErrNotFound)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — SMS fallback
+// ---------------------------------------------------------------------------
+
+func TestUserStore_SMSFallback(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"sms_fallback_enabled":       &dynamo.AttributeValueMemberBOOL{Value: true},
+					"sms_fallback_offline_after": &dynamo.AttributeValueMemberN{Value: "3600"},
+					"settings_version":           &dynamo.AttributeValueMemberN{Value: "4"},
+				}}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		got, err := store.SMSFallback(context.Background(), "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.SMSFallback{Enabled: true, OfflineAfter: time.Hour, Version: 4}, got)
+	})
+
+	t.Run("unset reads as off", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		got, err := store.SMSFallback(context.Background(), "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.SMSFallback{}, got)
+	})
+}
+
+func TestUserStore_SetSMSFallback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stores the threshold in seconds", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "SET sms_fallback_enabled = :on, sms_fallback_offline_after = :after ADD settings_version :one", *params.UpdateExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "1800"}, params.ExpressionAttributeValues[":after"])
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.NoError(t, store.SetSMSFallback(ctx, "user-001", domain.SMSFallback{Enabled: true, OfflineAfter: 30 * time.Minute}))
+	})
+
+	t.Run("default threshold removes the attribute", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "SET sms_fallback_enabled = :on REMOVE sms_fallback_offline_after ADD settings_version :one", *params.UpdateExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberBOOL{Value: false}, params.ExpressionAttributeValues[":on"])
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.NoError(t, store.SetSMSFallback(ctx, "user-001", domain.SMSFallback{}))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.ErrorIs(t, store.SetSMSFallback(ctx, "user-404", domain.SMSFallback{Enabled: true}), domain.ErrNotFound)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — privacy settings
+// ---------------------------------------------------------------------------
+
+func TestUserStore_PrivacySettings(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"hide_read_receipts": &dynamo.AttributeValueMemberBOOL{Value: true},
+					"hide_last_seen":     &dynamo.AttributeValueMemberBOOL{Value: true},
+					"settings_version":   &dynamo.AttributeValueMemberN{Value: "2"},
+				}}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		got, err := store.PrivacySettings(context.Background(), "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.PrivacySettings{HideReadReceipts: true, HideLastSeen: true, Version: 2}, got)
+	})
+
+	t.Run("unset shares everything", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		got, err := store.PrivacySettings(context.Background(), "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.PrivacySettings{}, got)
+	})
+}
+
+func TestUserStore_SetPrivacySettings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replaces every setting", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "SET hide_read_receipts = :receipts, hide_typing = :typing, hide_last_seen = :last_seen ADD settings_version :one", *params.UpdateExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberBOOL{Value: false}, params.ExpressionAttributeValues[":receipts"])
+				assert.Equal(t, &dynamo.AttributeValueMemberBOOL{Value: true}, params.ExpressionAttributeValues[":typing"])
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "1"}, params.ExpressionAttributeValues[":one"])
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.NoError(t, store.SetPrivacySettings(ctx, "user-001", domain.PrivacySettings{HideTyping: true}))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.ErrorIs(t, store.SetPrivacySettings(ctx, "user-404", domain.PrivacySettings{}), domain.ErrNotFound)
+	})
+}
+
+func TestUserStore_DNDSchedule(t *testing.T) {
+	ctx := context.Background()
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	require.NoError(t, err)
+	schedule := domain.DNDSchedule{
+		Location: madrid,
+		Windows:  []domain.QuietWindow{{Start: 22 * 60, End: 7 * 60}},
+		Chats: map[string]domain.ChatDND{
+			"chat-1": {Bypass: true},
+			"chat-2": {Windows: []domain.QuietWindow{{Start: 9 * 60, End: 17 * 60}}},
+		},
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		var stored dynamo.AttributeValue
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "SET dnd = :dnd ADD settings_version :one", *params.UpdateExpression)
+				stored = params.ExpressionAttributeValues[":dnd"]
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, "dnd, settings_version", *params.ProjectionExpression)
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"dnd":              stored,
+					"settings_version": &dynamo.AttributeValueMemberN{Value: "1"},
+				}}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		require.NoError(t, store.SetDNDSchedule(ctx, "user-001", schedule))
+		got, err := store.DNDSchedule(ctx, "user-001")
+
+		require.NoError(t, err)
+		want := schedule
+		want.Version = 1
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("unset holds nothing", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, usersTable, nil, testPhones, true)
+
+		got, err := store.DNDSchedule(ctx, "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.DNDSchedule{}, got)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		store := NewUserStore(&stubUserDynamo{
+			updateFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, usersTable, nil, testPhones, true)
+
+		assert.ErrorIs(t, store.SetDNDSchedule(ctx, "user-404", schedule), domain.ErrNotFound)
+	})
+}
@@ -0,0 +1,58 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// Compile-time check: OTPSinkProvider satisfies auth.SMSProvider.
+var _ auth.SMSProvider = (*OTPSinkProvider)(nil)
+
+// OTPSinkProvider is an auth.SMSProvider that writes the OTPs of sink
+// numbers to Redis for the synthetic canary instead of sending them, and
+// passes every other number to next. Sink numbers must be numbers no real
+// user owns.
+type OTPSinkProvider struct {
+	next    auth.SMSProvider
+	cmd     redisclient.Cmdable
+	numbers map[string]struct{}
+}
+
+// NewOTPSinkProvider creates an OTPSinkProvider for the given E.164 sink
+// numbers.
+func NewOTPSinkProvider(next auth.SMSProvider, cmd redisclient.Cmdable, numbers []domain.PhoneNumber) *OTPSinkProvider {
+	p := &OTPSinkProvider{next: next, cmd: cmd, numbers: make(map[string]struct{}, len(numbers))}
+	for _, n := range numbers {
+		p.numbers[n.String()] = struct{}{}
+	}
+	return p
+}
+
+// SendOTP stores the OTP of a sink number under domain.OTPSinkKey, or
+// sends it through next.
+func (p *OTPSinkProvider) SendOTP(ctx context.Context, phone, otp string) error {
+	if _, ok := p.numbers[phone]; !ok {
+		return p.next.SendOTP(ctx, phone, otp)
+	}
+
+	ctx, span := tracer.Start(ctx, "redis.otp_sink.store")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "SET"),
+	)
+
+	if err := p.cmd.Set(ctx, domain.OTPSinkKey(phone), otp, domain.OTPMaxValidity).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("otp sink: store: %w", err)
+	}
+	return nil
+}
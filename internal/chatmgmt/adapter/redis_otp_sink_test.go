@@ -0,0 +1,56 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+type recordingSMSProvider struct {
+	sent map[string]string
+}
+
+func (p *recordingSMSProvider) SendOTP(_ context.Context, phone, otp string) error {
+	p.sent[phone] = otp
+	return nil
+}
+
+func TestOTPSinkProvider(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() { require.NoError(t, client.Close()) })
+
+	sink := domain.MustPhoneNumber("+14155550100")
+	next := &recordingSMSProvider{sent: map[string]string{}}
+	provider := adapter.NewOTPSinkProvider(next, client.RDB, []domain.PhoneNumber{sink})
+	ctx := context.Background()
+
+	t.Run("sink number is stored, not sent", func(t *testing.T) {
+		require.NoError(t, provider.SendOTP(ctx, sink.String(), "123456"))
+
+		got, err := mr.Get(domain.OTPSinkKey(sink.String()))
+		require.NoError(t, err)
+		assert.Equal(t, "123456", got)
+		assert.Equal(t, domain.OTPMaxValidity, mr.TTL(domain.OTPSinkKey(sink.String())))
+		assert.Empty(t, next.sent)
+	})
+
+	t.Run("other numbers are sent", func(t *testing.T) {
+		require.NoError(t, provider.SendOTP(ctx, "+14155550199", "654321"))
+
+		assert.Equal(t, map[string]string{"+14155550199": "654321"}, next.sent)
+		assert.False(t, mr.Exists(domain.OTPSinkKey("+14155550199")))
+	})
+}
@@ -0,0 +1,127 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+const (
+	// otpVerifyPrefix is the key prefix for a phone's verification state.
+	// Key pattern: otp_verify_state:phone:{phone_hash}, a hash holding
+	// attempts and window_until (the attempt window) and locked_until
+	// (the lockout), as Unix seconds.
+	otpVerifyPrefix = "otp_verify_state:phone:"
+
+	// legacyOTPLockoutPrefix is the lockout key from before the state was
+	// consolidated. Admit still honours it; it can go once every such key
+	// has expired, domain.OTPLockoutDuration after the rollout.
+	legacyOTPLockoutPrefix = "otp_lockout:phone:"
+)
+
+// otpAdmitScript checks the lockout and counts the attempt in one step, so
+// concurrent attempts cannot pass the check before any of them is counted.
+// Times come from the Redis clock, which every instance shares. Refused
+// attempts are not counted. Returns an app.OTPVerifyAdmission.
+//
+// KEYS[1] state hash, KEYS[2] legacy lockout key
+// ARGV[1] limit, ARGV[2] window seconds
+const otpAdmitScript = `
+local now = tonumber(redis.call('TIME')[1])
+if redis.call('EXISTS', KEYS[2]) == 1 then
+  return 2
+end
+local state = redis.call('HMGET', KEYS[1], 'attempts', 'window_until', 'locked_until')
+local locked_until = tonumber(state[3] or '0')
+if locked_until > now then
+  return 2
+end
+local attempts = tonumber(state[1] or '0')
+local window_until = tonumber(state[2] or '0')
+if window_until <= now then
+  attempts = 0
+  window_until = now + tonumber(ARGV[2])
+end
+if attempts >= tonumber(ARGV[1]) then
+  return 1
+end
+redis.call('HSET', KEYS[1], 'attempts', attempts + 1, 'window_until', window_until)
+redis.call('EXPIREAT', KEYS[1], math.max(window_until, locked_until))
+return 0
+`
+
+// otpLockScript sets the lockout, keeping the hash alive until both the
+// lockout and the attempt window have passed.
+//
+// KEYS[1] state hash
+// ARGV[1] lockout seconds
+const otpLockScript = `
+local now = tonumber(redis.call('TIME')[1])
+local locked_until = now + tonumber(ARGV[1])
+local window_until = tonumber(redis.call('HGET', KEYS[1], 'window_until') or '0')
+redis.call('HSET', KEYS[1], 'locked_until', locked_until)
+redis.call('EXPIREAT', KEYS[1], math.max(window_until, locked_until))
+return 1
+`
+
+// Compile-time check: OTPVerifyLimiter satisfies app.OTPVerifyLimiter.
+var _ app.OTPVerifyLimiter = (*OTPVerifyLimiter)(nil)
+
+// OTPVerifyLimiter implements app.OTPVerifyLimiter backed by one Redis hash
+// per phone. Redis errors are returned with a lockout decision (fail-closed
+// per ADR-013).
+type OTPVerifyLimiter struct {
+	cmd redisclient.Cmdable
+}
+
+// NewOTPVerifyLimiter creates an OTPVerifyLimiter that uses cmd for Redis
+// operations.
+func NewOTPVerifyLimiter(cmd redisclient.Cmdable) *OTPVerifyLimiter {
+	return &OTPVerifyLimiter{cmd: cmd}
+}
+
+// Admit refuses the attempt if the phone is locked out or has made limit
+// attempts in the current window of windowSeconds, and otherwise counts
+// it, in a single round trip.
+func (l *OTPVerifyLimiter) Admit(ctx context.Context, phoneHash string, limit, windowSeconds int) (app.OTPVerifyAdmission, error) {
+	ctx, span := tracer.Start(ctx, "redis.otp_verify.admit")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	keys := []string{otpVerifyPrefix + phoneHash, legacyOTPLockoutPrefix + phoneHash}
+	result, err := l.cmd.Eval(ctx, otpAdmitScript, keys, limit, windowSeconds).Int64()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.OTPVerifyLockedOut, fmt.Errorf("admit OTP verify %q: %w", phoneHash, err)
+	}
+
+	return app.OTPVerifyAdmission(result), nil
+}
+
+// Lock locks the phone out of verification for ttlSeconds.
+func (l *OTPVerifyLimiter) Lock(ctx context.Context, phoneHash string, ttlSeconds int) error {
+	ctx, span := tracer.Start(ctx, "redis.otp_verify.lock")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	err := l.cmd.Eval(ctx, otpLockScript, []string{otpVerifyPrefix + phoneHash}, ttlSeconds).Err()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("lock OTP verify %q: %w", phoneHash, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestOTPVerifyLimiter(t *testing.T) (*adapter.OTPVerifyLimiter, *miniredis.Miniredis, func(time.Duration)) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	// advance moves both the clock scripts read and key expiry.
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		mr.SetTime(now)
+		mr.FastForward(d)
+	}
+	return adapter.NewOTPVerifyLimiter(client.RDB), mr, advance
+}
+
+func TestOTPVerifyLimiter_Admit(t *testing.T) {
+	t.Run("admits up to the limit, then rate limits", func(t *testing.T) {
+		l, _, _ := newTestOTPVerifyLimiter(t)
+		ctx := context.Background()
+
+		for i := range 3 {
+			got, err := l.Admit(ctx, "abc", 3, 900)
+			require.NoError(t, err)
+			assert.Equal(t, app.OTPVerifyAdmitted, got, "attempt %d", i+1)
+		}
+
+		got, err := l.Admit(ctx, "abc", 3, 900)
+		require.NoError(t, err)
+		assert.Equal(t, app.OTPVerifyRateLimited, got)
+	})
+
+	t.Run("keeps one hash that expires with the window", func(t *testing.T) {
+		l, mr, _ := newTestOTPVerifyLimiter(t)
+
+		_, err := l.Admit(context.Background(), "abc", 3, 900)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"otp_verify_state:phone:abc"}, mr.Keys())
+		assert.Equal(t, "1", mr.HGet("otp_verify_state:phone:abc", "attempts"))
+		assert.Equal(t, 900*time.Second, mr.TTL("otp_verify_state:phone:abc"))
+	})
+
+	t.Run("window restarts once it has passed", func(t *testing.T) {
+		l, _, advance := newTestOTPVerifyLimiter(t)
+		ctx := context.Background()
+
+		_, err := l.Admit(ctx, "abc", 1, 60)
+		require.NoError(t, err)
+		got, err := l.Admit(ctx, "abc", 1, 60)
+		require.NoError(t, err)
+		require.Equal(t, app.OTPVerifyRateLimited, got)
+
+		advance(61 * time.Second)
+
+		got, err = l.Admit(ctx, "abc", 1, 60)
+		require.NoError(t, err)
+		assert.Equal(t, app.OTPVerifyAdmitted, got)
+	})
+
+	t.Run("locked out phones are refused without counting", func(t *testing.T) {
+		l, mr, advance := newTestOTPVerifyLimiter(t)
+		ctx := context.Background()
+
+		_, err := l.Admit(ctx, "abc", 5, 60)
+		require.NoError(t, err)
+		require.NoError(t, l.Lock(ctx, "abc", 900))
+
+		got, err := l.Admit(ctx, "abc", 5, 60)
+		require.NoError(t, err)
+		assert.Equal(t, app.OTPVerifyLockedOut, got)
+		assert.Equal(t, "1", mr.HGet("otp_verify_state:phone:abc", "attempts"))
+		assert.Equal(t, 900*time.Second, mr.TTL("otp_verify_state:phone:abc"), "the hash outlives the window while locked")
+
+		advance(901 * time.Second)
+
+		got, err = l.Admit(ctx, "abc", 5, 60)
+		require.NoError(t, err)
+		assert.Equal(t, app.OTPVerifyAdmitted, got)
+	})
+
+	t.Run("honours lockouts set before consolidation", func(t *testing.T) {
+		l, mr, _ := newTestOTPVerifyLimiter(t)
+		require.NoError(t, mr.Set("otp_lockout:phone:abc", "1"))
+
+		got, err := l.Admit(context.Background(), "abc", 5, 60)
+		require.NoError(t, err)
+		assert.Equal(t, app.OTPVerifyLockedOut, got)
+	})
+
+	t.Run("phones are independent", func(t *testing.T) {
+		l, _, _ := newTestOTPVerifyLimiter(t)
+		ctx := context.Background()
+		require.NoError(t, l.Lock(ctx, "abc", 900))
+
+		got, err := l.Admit(ctx, "def", 5, 60)
+		require.NoError(t, err)
+		assert.Equal(t, app.OTPVerifyAdmitted, got)
+	})
+
+	t.Run("Redis error fails closed", func(t *testing.T) {
+		l, mr, _ := newTestOTPVerifyLimiter(t)
+		mr.Close()
+
+		got, err := l.Admit(context.Background(), "abc", 5, 60)
+		require.Error(t, err)
+		assert.Equal(t, app.OTPVerifyLockedOut, got)
+	})
+}
@@ -24,6 +24,16 @@ end
 return count
 `
 
+// hitScript counts a request like rateLimitScript and also returns the
+// seconds left in the window, for clients told when it resets.
+const hitScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+  redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return {count, redis.call('TTL', KEYS[1])}
+`
+
 // Compile-time check: RateLimiter satisfies app.RateLimiter.
 var _ app.RateLimiter = (*RateLimiter)(nil)
 
@@ -62,44 +72,28 @@ func (r *RateLimiter) CheckAndIncrement(ctx context.Context, key string, limit,
 	return count <= int64(limit), nil
 }
 
-// CheckLockout checks whether a lockout key exists in Redis.
-// Returns (true, nil) if the key exists (user is locked out), (false, nil)
-// if no lockout is active, and (true, err) on Redis failure (fail-closed
-// per ADR-013: treat error as locked).
-func (r *RateLimiter) CheckLockout(ctx context.Context, key string) (bool, error) {
-	ctx, span := tracer.Start(ctx, "redis.ratelimit.check_lockout")
+// Hit counts one request against key's fixed window of windowSeconds and
+// returns the count so far and the time until the window resets. Unlike
+// CheckAndIncrement it leaves the limit, and the fail mode, to the caller.
+func (r *RateLimiter) Hit(ctx context.Context, key string, windowSeconds int) (int, time.Duration, error) {
+	ctx, span := tracer.Start(ctx, "redis.ratelimit.hit")
 	defer span.End()
 	span.SetAttributes(
 		attribute.String("db.system", "redis"),
-		attribute.String("db.operation", "EXISTS"),
+		attribute.String("db.operation", "EVAL"),
 	)
 
-	result, err := r.cmd.Exists(ctx, key).Result()
+	res, err := r.cmd.Eval(ctx, hitScript, []string{key}, windowSeconds).Int64Slice()
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return true, fmt.Errorf("lockout check %q: %w", key, err)
+		return 0, 0, fmt.Errorf("rate limit hit %q: %w", key, err)
 	}
 
-	return result > 0, nil
-}
-
-// SetLockout sets a lockout key in Redis with the given TTL.
-// The key signals that a user has been locked out for the specified duration.
-func (r *RateLimiter) SetLockout(ctx context.Context, key string, ttlSeconds int) error {
-	ctx, span := tracer.Start(ctx, "redis.ratelimit.set_lockout")
-	defer span.End()
-	span.SetAttributes(
-		attribute.String("db.system", "redis"),
-		attribute.String("db.operation", "SET"),
-	)
-
-	err := r.cmd.Set(ctx, key, "1", time.Duration(ttlSeconds)*time.Second).Err()
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-		return fmt.Errorf("set lockout %q: %w", key, err)
+	count, ttl := res[0], res[1]
+	if ttl < 0 {
+		// A key left without an expiry still resets within one window.
+		ttl = int64(windowSeconds)
 	}
-
-	return nil
+	return int(count), time.Duration(ttl) * time.Second, nil
 }
@@ -136,74 +136,42 @@ func TestRateLimiter_CheckAndIncrement(t *testing.T) {
 	})
 }
 
-func TestRateLimiter_CheckLockout(t *testing.T) {
-	t.Run("returns false when no lockout exists", func(t *testing.T) {
-		rl, _ := newTestRateLimiter(t)
-		ctx := context.Background()
-
-		locked, err := rl.CheckLockout(ctx, "otp_lockout:phone:abc")
-
-		require.NoError(t, err)
-		assert.False(t, locked, "should not be locked when key does not exist")
-	})
-
-	t.Run("returns true when lockout is active", func(t *testing.T) {
+func TestRateLimiter_Hit(t *testing.T) {
+	t.Run("counts requests and reports the window reset", func(t *testing.T) {
 		rl, mr := newTestRateLimiter(t)
 		ctx := context.Background()
-		key := "otp_lockout:phone:def"
-
-		require.NoError(t, mr.Set(key, "1"))
-
-		locked, err := rl.CheckLockout(ctx, key)
 
+		count, reset, err := rl.Hit(ctx, "http_req:ip:203.0.113.7", 60)
 		require.NoError(t, err)
-		assert.True(t, locked, "should be locked when key exists")
-	})
-
-	t.Run("returns false after lockout expires", func(t *testing.T) {
-		rl, mr := newTestRateLimiter(t)
-		ctx := context.Background()
-		key := "otp_lockout:phone:ghi"
-
-		require.NoError(t, mr.Set(key, "1"))
-		mr.SetTTL(key, 60*time.Second)
-
-		// Fast-forward past the TTL.
-		mr.FastForward(61 * time.Second)
-
-		locked, err := rl.CheckLockout(ctx, key)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 60*time.Second, reset)
 
+		mr.FastForward(20 * time.Second)
+		count, reset, err = rl.Hit(ctx, "http_req:ip:203.0.113.7", 60)
 		require.NoError(t, err)
-		assert.False(t, locked, "lockout should expire after TTL")
+		assert.Equal(t, 2, count)
+		assert.Equal(t, 40*time.Second, reset, "the window is fixed from the first request")
 	})
-}
 
-func TestRateLimiter_SetLockout(t *testing.T) {
-	t.Run("creates lockout key with TTL", func(t *testing.T) {
+	t.Run("window restarts after it expires", func(t *testing.T) {
 		rl, mr := newTestRateLimiter(t)
 		ctx := context.Background()
-		key := "otp_lockout:phone:abc"
 
-		err := rl.SetLockout(ctx, key, 900)
+		_, _, err := rl.Hit(ctx, "http_req:user:u1", 60)
+		require.NoError(t, err)
+		mr.FastForward(61 * time.Second)
 
+		count, _, err := rl.Hit(ctx, "http_req:user:u1", 60)
 		require.NoError(t, err)
-		assert.True(t, mr.Exists(key), "lockout key should exist")
-		val, getErr := mr.Get(key)
-		require.NoError(t, getErr)
-		assert.Equal(t, "1", val, "lockout value should be '1'")
-		assert.Equal(t, 900*time.Second, mr.TTL(key), "TTL should match ttlSeconds")
+		assert.Equal(t, 1, count)
 	})
 
-	t.Run("lockout expires after TTL", func(t *testing.T) {
+	t.Run("Redis failure returns error", func(t *testing.T) {
 		rl, mr := newTestRateLimiter(t)
-		ctx := context.Background()
-		key := "otp_lockout:phone:def"
+		mr.Close()
 
-		err := rl.SetLockout(ctx, key, 60)
-		require.NoError(t, err)
-
-		mr.FastForward(61 * time.Second)
+		_, _, err := rl.Hit(context.Background(), "http_req:ip:203.0.113.7", 60)
 
-		assert.False(t, mr.Exists(key), "lockout key should expire after TTL")
+		require.Error(t, err)
 	})
 }
@@ -2,9 +2,11 @@ package adapter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 
@@ -14,15 +16,13 @@ import (
 
 const (
 	// revokedJTIPrefix is the Redis key prefix for revoked JTI entries.
-	// Key pattern: revoked_jti:{jti} per ADR-015 §6.2.
+	// Key pattern: revoked_jti:{jti} per ADR-015 §6.2, holding the reason.
 	revokedJTIPrefix = "revoked_jti:"
 
-	// revokedJTITTL is the fixed TTL for revoked JTI entries.
-	// Set to 3600 seconds (= max access token lifetime of 60 minutes)
-	// per PR1-DECISIONS §Revocation TTLs. Fixed rather than dynamic
-	// (exp - now) for uniform handling across all revocation paths
-	// including admin-initiated revocations.
-	revokedJTITTL = 3600 * time.Second
+	// revokedUserPrefix is the Redis key prefix for user-wide revocations.
+	// Key pattern: revoked_user:{user_id}, a hash holding reason and
+	// revoked_at, the Unix second up to which issued tokens are revoked.
+	revokedUserPrefix = "revoked_user:"
 )
 
 // Compile-time check: RevocationStore satisfies app.RevocationStore.
@@ -31,6 +31,8 @@ var _ app.RevocationStore = (*RevocationStore)(nil)
 // RevocationStore implements JTI revocation backed by Redis.
 // All methods follow the fail-closed policy from ADR-013: Redis errors
 // on reads result in treating the token as revoked (deny access).
+// Entries expire with the tokens they cover, so the store holds only
+// revocations that still matter.
 type RevocationStore struct {
 	cmd redisclient.Cmdable
 }
@@ -40,19 +42,20 @@ func NewRevocationStore(cmd redisclient.Cmdable) *RevocationStore {
 	return &RevocationStore{cmd: cmd}
 }
 
-// Revoke marks a JTI as revoked by setting a key with fixed 3600s TTL.
-// Written by Chat Mgmt Service on logout, session revoke, and reuse
-// detection per ADR-015 §6.2.
-func (s *RevocationStore) Revoke(ctx context.Context, jti string) error {
+// Revoke marks a JTI as revoked for r.Reason until r.ExpiresAt. Written
+// by Chat Mgmt Service on logout, session revoke, and reuse detection per
+// ADR-015 §6.2.
+func (s *RevocationStore) Revoke(ctx context.Context, jti string, r app.Revocation) error {
 	ctx, span := tracer.Start(ctx, "redis.revocation.revoke")
 	defer span.End()
 	span.SetAttributes(
 		attribute.String("db.system", "redis"),
 		attribute.String("db.operation", "SET"),
+		attribute.String("revocation.reason", r.Reason),
 	)
 
 	key := revokedJTIPrefix + jti
-	err := s.cmd.Set(ctx, key, "1", revokedJTITTL).Err()
+	err := s.cmd.SetArgs(ctx, key, r.Reason, redis.SetArgs{ExpireAt: r.ExpiresAt}).Err()
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -84,3 +87,55 @@ func (s *RevocationStore) IsRevoked(ctx context.Context, jti string) (bool, erro
 
 	return result > 0, nil
 }
+
+// RevokeAllForUser revokes every token issued to userID up to
+// r.RevokedAt, replacing any earlier user-wide revocation, until
+// r.ExpiresAt. Used by logout-everywhere and account removal.
+func (s *RevocationStore) RevokeAllForUser(ctx context.Context, userID string, r app.Revocation) error {
+	ctx, span := tracer.Start(ctx, "redis.revocation.revoke_all_for_user")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "MULTI"),
+		attribute.String("revocation.reason", r.Reason),
+	)
+
+	key := revokedUserPrefix + userID
+	_, err := s.cmd.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "reason", r.Reason, "revoked_at", r.RevokedAt.Unix())
+		pipe.ExpireAt(ctx, key, r.ExpiresAt)
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("revoke tokens of user %q: %w", userID, err)
+	}
+
+	return nil
+}
+
+// IsUserRevoked reports whether a token issued to userID at issuedAt was
+// revoked by RevokeAllForUser. Token issue times have one-second
+// resolution, so a token issued in the second of the revocation counts as
+// revoked. Fails closed like IsRevoked.
+func (s *RevocationStore) IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	ctx, span := tracer.Start(ctx, "redis.revocation.is_user_revoked")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "HGET"),
+	)
+
+	revokedAt, err := s.cmd.HGet(ctx, revokedUserPrefix+userID, "revoked_at").Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return true, fmt.Errorf("check revocation of user %q: %w", userID, err)
+	}
+
+	return issuedAt.Unix() <= revokedAt, nil
+}
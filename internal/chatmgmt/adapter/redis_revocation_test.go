@@ -2,6 +2,7 @@ package adapter_test
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
@@ -10,13 +11,18 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
 	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
 )
 
+// revocationNow is the Redis clock in revocation tests.
+var revocationNow = time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+
 func newTestRevocationStore(t *testing.T) (*adapter.RevocationStore, *miniredis.Miniredis) {
 	t.Helper()
 
 	mr := miniredis.RunT(t)
+	mr.SetTime(revocationNow)
 	client := redisclient.NewClient(redisclient.Config{
 		Addr:         mr.Addr(),
 		ReadTimeout:  5 * time.Second,
@@ -29,37 +35,41 @@ func newTestRevocationStore(t *testing.T) (*adapter.RevocationStore, *miniredis.
 	return adapter.NewRevocationStore(client.RDB), mr
 }
 
+// logoutFor is a logout revocation of a token expiring ttl from now.
+func logoutFor(ttl time.Duration) app.Revocation {
+	return app.Revocation{Reason: "logout", RevokedAt: revocationNow, ExpiresAt: revocationNow.Add(ttl)}
+}
+
 func TestRevocationStore_Revoke(t *testing.T) {
-	t.Run("creates revocation key", func(t *testing.T) {
+	t.Run("creates revocation key holding the reason", func(t *testing.T) {
 		store, mr := newTestRevocationStore(t)
 		ctx := context.Background()
 
-		err := store.Revoke(ctx, "abc-123-jti")
+		err := store.Revoke(ctx, "abc-123-jti", logoutFor(time.Hour))
 
 		require.NoError(t, err)
 		assert.True(t, mr.Exists("revoked_jti:abc-123-jti"), "revocation key should exist")
 		val, getErr := mr.Get("revoked_jti:abc-123-jti")
 		require.NoError(t, getErr)
-		assert.Equal(t, "1", val, "value should be '1'")
+		assert.Equal(t, "logout", val)
 	})
 
-	t.Run("sets fixed 3600s TTL", func(t *testing.T) {
+	t.Run("expires when the token does", func(t *testing.T) {
 		store, mr := newTestRevocationStore(t)
 		ctx := context.Background()
 
-		err := store.Revoke(ctx, "def-456-jti")
+		err := store.Revoke(ctx, "def-456-jti", logoutFor(17*time.Minute))
 
 		require.NoError(t, err)
-		ttl := mr.TTL("revoked_jti:def-456-jti")
-		assert.Equal(t, 3600*time.Second, ttl, "TTL should be exactly 3600 seconds")
+		assert.Equal(t, 17*time.Minute, mr.TTL("revoked_jti:def-456-jti"))
 	})
 
 	t.Run("revoking same JTI twice succeeds", func(t *testing.T) {
 		store, mr := newTestRevocationStore(t)
 		ctx := context.Background()
 
-		require.NoError(t, store.Revoke(ctx, "ghi-789-jti"))
-		require.NoError(t, store.Revoke(ctx, "ghi-789-jti"))
+		require.NoError(t, store.Revoke(ctx, "ghi-789-jti", logoutFor(time.Hour)))
+		require.NoError(t, store.Revoke(ctx, "ghi-789-jti", logoutFor(time.Hour)))
 
 		assert.True(t, mr.Exists("revoked_jti:ghi-789-jti"), "key should still exist")
 	})
@@ -80,7 +90,7 @@ func TestRevocationStore_IsRevoked(t *testing.T) {
 		store, _ := newTestRevocationStore(t)
 		ctx := context.Background()
 
-		require.NoError(t, store.Revoke(ctx, "revoked-jti"))
+		require.NoError(t, store.Revoke(ctx, "revoked-jti", logoutFor(time.Hour)))
 
 		revoked, err := store.IsRevoked(ctx, "revoked-jti")
 
@@ -88,32 +98,103 @@ func TestRevocationStore_IsRevoked(t *testing.T) {
 		assert.True(t, revoked, "revoked JTI should return true")
 	})
 
-	t.Run("returns false after TTL expires", func(t *testing.T) {
+	t.Run("returns false once the token has expired", func(t *testing.T) {
 		store, mr := newTestRevocationStore(t)
 		ctx := context.Background()
 
-		require.NoError(t, store.Revoke(ctx, "expiring-jti"))
+		require.NoError(t, store.Revoke(ctx, "expiring-jti", logoutFor(time.Hour)))
 
-		// Fast-forward past the 3600s TTL.
-		mr.FastForward(3601 * time.Second)
+		mr.FastForward(time.Hour + time.Second)
 
 		revoked, err := store.IsRevoked(ctx, "expiring-jti")
 
 		require.NoError(t, err)
-		assert.False(t, revoked, "revocation should expire after TTL")
+		assert.False(t, revoked, "revocation should expire with the token")
 	})
 
 	t.Run("different JTIs are independent", func(t *testing.T) {
 		store, _ := newTestRevocationStore(t)
 		ctx := context.Background()
 
-		require.NoError(t, store.Revoke(ctx, "jti-a"))
+		require.NoError(t, store.Revoke(ctx, "jti-a", logoutFor(time.Hour)))
 
 		revoked, err := store.IsRevoked(ctx, "jti-b")
 
 		require.NoError(t, err)
 		assert.False(t, revoked, "unrevoked JTI should not be affected by other revocations")
 	})
+
+	t.Run("redis unavailable: fails closed", func(t *testing.T) {
+		store, mr := newTestRevocationStore(t)
+		mr.Close()
+
+		revoked, err := store.IsRevoked(context.Background(), "any-jti")
+
+		require.Error(t, err)
+		assert.True(t, revoked)
+	})
+}
+
+func TestRevocationStore_RevokeAllForUser(t *testing.T) {
+	deprovision := app.Revocation{
+		Reason:    "scim_deprovision",
+		RevokedAt: revocationNow,
+		ExpiresAt: revocationNow.Add(time.Hour),
+	}
+
+	t.Run("records the reason and cutoff until the last token expires", func(t *testing.T) {
+		store, mr := newTestRevocationStore(t)
+
+		require.NoError(t, store.RevokeAllForUser(context.Background(), "user-1", deprovision))
+
+		assert.Equal(t, "scim_deprovision", mr.HGet("revoked_user:user-1", "reason"))
+		assert.Equal(t, strconv.FormatInt(revocationNow.Unix(), 10), mr.HGet("revoked_user:user-1", "revoked_at"))
+		assert.Equal(t, time.Hour, mr.TTL("revoked_user:user-1"))
+	})
+
+	t.Run("revokes tokens issued up to the revocation only", func(t *testing.T) {
+		store, _ := newTestRevocationStore(t)
+		ctx := context.Background()
+		require.NoError(t, store.RevokeAllForUser(ctx, "user-1", deprovision))
+
+		for _, tc := range []struct {
+			name     string
+			userID   string
+			issuedAt time.Time
+			want     bool
+		}{
+			{"issued before", "user-1", revocationNow.Add(-10 * time.Minute), true},
+			{"issued in the same second", "user-1", revocationNow.Add(500 * time.Millisecond), true},
+			{"issued after", "user-1", revocationNow.Add(time.Second), false},
+			{"other user", "user-2", revocationNow.Add(-10 * time.Minute), false},
+		} {
+			revoked, err := store.IsUserRevoked(ctx, tc.userID, tc.issuedAt)
+			require.NoError(t, err, tc.name)
+			assert.Equal(t, tc.want, revoked, tc.name)
+		}
+	})
+
+	t.Run("forgotten once the last token has expired", func(t *testing.T) {
+		store, mr := newTestRevocationStore(t)
+		ctx := context.Background()
+		require.NoError(t, store.RevokeAllForUser(ctx, "user-1", deprovision))
+
+		mr.FastForward(time.Hour + time.Second)
+
+		revoked, err := store.IsUserRevoked(ctx, "user-1", revocationNow.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("redis unavailable: fails closed", func(t *testing.T) {
+		store, mr := newTestRevocationStore(t)
+		mr.Close()
+
+		revoked, err := store.IsUserRevoked(context.Background(), "user-1", revocationNow)
+
+		require.Error(t, err)
+		assert.True(t, revoked)
+	})
 }
 
 func TestRevocationStore_RevokeAndCheck_Integration(t *testing.T) {
@@ -128,17 +209,17 @@ func TestRevocationStore_RevokeAndCheck_Integration(t *testing.T) {
 		assert.False(t, revoked, "should not be revoked initially")
 
 		// After revocation.
-		require.NoError(t, store.Revoke(ctx, jti))
+		require.NoError(t, store.Revoke(ctx, jti, logoutFor(time.Hour)))
 
 		revoked, err = store.IsRevoked(ctx, jti)
 		require.NoError(t, err)
 		assert.True(t, revoked, "should be revoked after Revoke call")
 
-		// After TTL expires.
-		mr.FastForward(3601 * time.Second)
+		// After the token expires.
+		mr.FastForward(time.Hour + time.Second)
 
 		revoked, err = store.IsRevoked(ctx, jti)
 		require.NoError(t, err)
-		assert.False(t, revoked, "should no longer be revoked after TTL expires")
+		assert.False(t, revoked, "should no longer be revoked after the token expires")
 	})
 }
@@ -0,0 +1,103 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// translationPrefix is the Redis key prefix for cached translations.
+// Key pattern: translation:{chat_id}:{sequence}:{language}.
+const translationPrefix = "translation:"
+
+// Compile-time check: TranslationCache satisfies app.TranslationCache.
+var _ app.TranslationCache = (*TranslationCache)(nil)
+
+// TranslationCache caches message translations in Redis. Entries expire
+// on their own; messages are immutable, so nothing needs invalidating.
+type TranslationCache struct {
+	cmd redisclient.Cmdable
+}
+
+// NewTranslationCache creates a TranslationCache that uses cmd for Redis
+// operations.
+func NewTranslationCache(cmd redisclient.Cmdable) *TranslationCache {
+	return &TranslationCache{cmd: cmd}
+}
+
+// translationEntry is the JSON value stored per cache key.
+type translationEntry struct {
+	Text           string `json:"text"`
+	SourceLanguage string `json:"source_language"`
+}
+
+func translationKey(chatID string, sequence uint64, language string) string {
+	return fmt.Sprintf("%s%s:%d:%s", translationPrefix, chatID, sequence, language)
+}
+
+// GetTranslation returns the cached translation, or (zero, false, nil) on
+// a miss.
+func (c *TranslationCache) GetTranslation(ctx context.Context, chatID string, sequence uint64, language string) (domain.Translation, bool, error) {
+	ctx, span := tracer.Start(ctx, "redis.translation.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "GET"),
+	)
+
+	raw, err := c.cmd.Get(ctx, translationKey(chatID, sequence, language)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return domain.Translation{}, false, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, false, fmt.Errorf("get translation: %w", err)
+	}
+
+	var entry translationEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, false, fmt.Errorf("decode translation: %w", err)
+	}
+
+	return domain.Translation{
+		Text:           entry.Text,
+		SourceLanguage: entry.SourceLanguage,
+		TargetLanguage: language,
+	}, true, nil
+}
+
+// PutTranslation caches tr under its target language for ttl.
+func (c *TranslationCache) PutTranslation(ctx context.Context, chatID string, sequence uint64, tr domain.Translation, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "redis.translation.put")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "SET"),
+	)
+
+	raw, err := json.Marshal(translationEntry{Text: tr.Text, SourceLanguage: tr.SourceLanguage})
+	if err != nil {
+		return fmt.Errorf("encode translation: %w", err)
+	}
+
+	if err := c.cmd.Set(ctx, translationKey(chatID, sequence, tr.TargetLanguage), raw, ttl).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("put translation: %w", err)
+	}
+
+	return nil
+}
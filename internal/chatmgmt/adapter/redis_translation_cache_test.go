@@ -0,0 +1,66 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestTranslationCache(t *testing.T) (*adapter.TranslationCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	return adapter.NewTranslationCache(client.RDB), mr
+}
+
+func TestTranslationCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("round trip with ttl", func(t *testing.T) {
+		cache, mr := newTestTranslationCache(t)
+		tr := domain.Translation{Text: "hola", SourceLanguage: "en", TargetLanguage: "es"}
+
+		require.NoError(t, cache.PutTranslation(ctx, "chat-001", 7, tr, time.Hour))
+
+		got, hit, err := cache.GetTranslation(ctx, "chat-001", 7, "es")
+		require.NoError(t, err)
+		assert.True(t, hit)
+		assert.Equal(t, tr, got)
+		assert.Equal(t, time.Hour, mr.TTL("translation:chat-001:7:es"))
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		cache, _ := newTestTranslationCache(t)
+
+		_, hit, err := cache.GetTranslation(ctx, "chat-001", 7, "fr")
+
+		require.NoError(t, err)
+		assert.False(t, hit)
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		cache, mr := newTestTranslationCache(t)
+		mr.Close()
+
+		_, _, err := cache.GetTranslation(ctx, "chat-001", 7, "es")
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,101 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// s3GetObjectAPI is a narrow, consumer-defined interface for the subset of
+// S3 operations required by S3ManifestReader. The real *s3.Client
+// satisfies it.
+type s3GetObjectAPI interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// S3ManifestReader opens bulk import manifests stored in S3. Manifests are
+// named by s3:// URI rather than URL, so an import request cannot make the
+// service fetch from arbitrary hosts.
+type S3ManifestReader struct {
+	client s3GetObjectAPI
+}
+
+// NewS3ManifestReader creates an S3ManifestReader.
+func NewS3ManifestReader(client s3GetObjectAPI) *S3ManifestReader {
+	return &S3ManifestReader{client: client}
+}
+
+// Open streams the object at uri, of the form s3://bucket/key. The caller
+// closes the returned body. A malformed uri returns domain.ErrInvalidInput
+// and a missing object domain.ErrNotFound.
+func (r *S3ManifestReader) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	ctx, span := tracer.Start(ctx, "s3.manifest.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("rpc.system", "aws-api"),
+		attribute.String("rpc.service", "S3"),
+		attribute.String("rpc.method", "GetObject"),
+	)
+
+	body, err := r.getObject(ctx, uri)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("s3 manifest: get %s: %w", uri, err)
+	}
+	return body, nil
+}
+
+func (r *S3ManifestReader) getObject(ctx context.Context, uri string) (io.ReadCloser, error) {
+	bucket, key, err := parseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := r.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// parseS3URI splits s3://bucket/key.
+func parseS3URI(uri string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("%w: manifest must be an s3:// URI", domain.ErrInvalidInput)
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	if !validBucketName(bucket) || key == "" {
+		return "", "", fmt.Errorf("%w: manifest must be s3://bucket/key", domain.ErrInvalidInput)
+	}
+	return bucket, key, nil
+}
+
+// validBucketName reports whether name uses only the characters S3 allows
+// in bucket names, which keeps it from altering the request host.
+func validBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubGetObject serves body for every GetObject, or fails with err, and
+// records the last input.
+type stubGetObject struct {
+	input *s3.GetObjectInput
+	body  string
+	err   error
+}
+
+func (s *stubGetObject) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	s.input = in
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(s.body))}, nil
+}
+
+func TestS3ManifestReader_Open(t *testing.T) {
+	t.Run("streams the object", func(t *testing.T) {
+		api := &stubGetObject{body: `{"external_id":"u1"}` + "\n"}
+
+		body, err := NewS3ManifestReader(api).Open(context.Background(), "s3://imports/legacy/users.ndjson")
+		require.NoError(t, err)
+		defer func() { _ = body.Close() }()
+
+		assert.Equal(t, "imports", aws.ToString(api.input.Bucket))
+		assert.Equal(t, "legacy/users.ndjson", aws.ToString(api.input.Key))
+		got, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"external_id":"u1"}`+"\n", string(got))
+	})
+
+	t.Run("missing object is not found", func(t *testing.T) {
+		_, err := NewS3ManifestReader(&stubGetObject{err: &s3types.NoSuchKey{}}).Open(context.Background(), "s3://imports/missing.ndjson")
+
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("surfaces service errors", func(t *testing.T) {
+		apiErr := errors.New("AccessDenied")
+
+		_, err := NewS3ManifestReader(&stubGetObject{err: apiErr}).Open(context.Background(), "s3://imports/users.ndjson")
+
+		require.ErrorIs(t, err, apiErr)
+		assert.Contains(t, err.Error(), "s3 manifest: get s3://imports/users.ndjson")
+	})
+
+	t.Run("rejects anything but an s3 URI", func(t *testing.T) {
+		api := &stubGetObject{}
+		reader := NewS3ManifestReader(api)
+
+		for _, uri := range []string{
+			"https://example.com/users.ndjson",
+			"s3://imports",
+			"s3:///users.ndjson",
+			"s3://evil.com:443@imports/users.ndjson",
+			"s3://Imports/users.ndjson",
+		} {
+			_, err := reader.Open(context.Background(), uri)
+			assert.ErrorIs(t, err, domain.ErrInvalidInput, uri)
+		}
+		assert.Nil(t, api.input, "no request expected")
+	})
+}
@@ -0,0 +1,219 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// userEncryptDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the user encryptor.
+type userEncryptDynamoDB interface {
+	Scan(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// UserEncryptor moves users' personal data to its current form: phone
+// numbers and display names encrypted under the newest key version, and
+// the phone's blind index in phone_index with the phone sentinel keyed by
+// it. Plaintext written before encryption was turned on, ciphertext under
+// a key since rotated, and users written before phone_index existed are
+// all rewritten. It is safe to re-run, and to run while users register:
+// users already current are skipped, and users that change mid-run are
+// left to the next run.
+type UserEncryptor struct {
+	db         userEncryptDynamoDB
+	usersTable string
+	fields     *msgcrypt.FieldCipher
+	phones     *msgcrypt.BlindIndex
+}
+
+// NewUserEncryptor creates a UserEncryptor backed by the given DynamoDB
+// client, encrypting with fields and indexing phones with phones. With a
+// nil fields, users are only indexed and their data stays in plaintext.
+func NewUserEncryptor(db userEncryptDynamoDB, usersTable string, fields *msgcrypt.FieldCipher, phones *msgcrypt.BlindIndex) *UserEncryptor {
+	return &UserEncryptor{db: db, usersTable: usersTable, fields: fields, phones: phones}
+}
+
+// EncryptUsers rewrites every user not in its current form. A user's
+// legacy phone sentinel is re-keyed in the same transaction, so the phone
+// stays reserved throughout. It returns the number of users rewritten.
+func (e *UserEncryptor) EncryptUsers(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.encrypt")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Scan+TransactWriteItems"),
+	)
+
+	if e.phones == nil {
+		return 0, errors.New("user encryptor: no phone blind index configured")
+	}
+	current := 0 // plaintext
+	if e.fields != nil {
+		var err error
+		if current, err = e.fields.CurrentVersion(ctx); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return 0, fmt.Errorf("user encryptor: %w", err)
+		}
+	}
+
+	projection := "user_id, phone_number, phone_index, display_name"
+	consistentRead := true
+	input := &dynamo.ScanInput{
+		TableName:            &e.usersTable,
+		ProjectionExpression: &projection,
+		ConsistentRead:       &consistentRead,
+	}
+
+	encrypted := 0
+	for {
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return encrypted, fmt.Errorf("user encryptor: %w", err)
+		}
+		out, err := e.db.Scan(ctx, input)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return encrypted, fmt.Errorf("user encryptor: scan: %w", err)
+		}
+		for _, av := range out.Items {
+			var item userItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return encrypted, fmt.Errorf("user encryptor: unmarshal user: %w", err)
+			}
+			if strings.HasPrefix(item.UserID, phoneSentinelPrefix) {
+				continue // re-keyed with its owner
+			}
+			ok, err := e.encryptUser(ctx, item, current)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return encrypted, fmt.Errorf("user encryptor: user %s: %w", item.UserID, err)
+			}
+			if ok {
+				encrypted++
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			span.SetAttributes(attribute.Int("users.encrypted", encrypted))
+			return encrypted, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// encryptUser rewrites stored in its current form, with its fields under
+// key version current (0 for plaintext). It reports false if it already
+// is, or if the user changed since it was read.
+func (e *UserEncryptor) encryptUser(ctx context.Context, stored userItem, current int) (bool, error) {
+	phoneStale, err := fieldStale(stored.PhoneNumber, current)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", phoneField, err)
+	}
+	nameStale, err := fieldStale(stored.DisplayName, current)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", displayNameField, err)
+	}
+	unindexed := stored.PhoneNumber != "" && stored.PhoneIndex == ""
+	if !phoneStale && !nameStale && !unindexed {
+		return false, nil
+	}
+
+	plain := stored
+	if err := decryptUserItem(ctx, e.fields, &plain); err != nil {
+		return false, err
+	}
+	phone, err := e.fields.EncryptSearchable(ctx, phoneField, plain.PhoneNumber)
+	if err != nil {
+		return false, err
+	}
+	name, err := e.fields.Encrypt(ctx, displayNameField, plain.DisplayName)
+	if err != nil {
+		return false, err
+	}
+	index := e.phones.Token(phoneField, plain.PhoneNumber)
+
+	updateExpr := "SET display_name = :name"
+	condExpr := "display_name = :old_name"
+	values := map[string]dynamo.AttributeValue{
+		":name":     &dynamo.AttributeValueMemberS{Value: name},
+		":old_name": &dynamo.AttributeValueMemberS{Value: stored.DisplayName},
+	}
+	if stored.PhoneNumber == "" {
+		condExpr += " AND attribute_not_exists(phone_number)"
+	} else {
+		updateExpr += ", phone_number = :phone, phone_index = :index"
+		condExpr += " AND phone_number = :old_phone"
+		values[":phone"] = &dynamo.AttributeValueMemberS{Value: phone}
+		values[":index"] = &dynamo.AttributeValueMemberS{Value: index}
+		values[":old_phone"] = &dynamo.AttributeValueMemberS{Value: stored.PhoneNumber}
+	}
+	items := []dynamo.TransactWriteItem{{
+		Update: &dynamo.Update{
+			TableName: &e.usersTable,
+			Key: map[string]dynamo.AttributeValue{
+				"user_id": &dynamo.AttributeValueMemberS{Value: stored.UserID},
+			},
+			UpdateExpression:          &updateExpr,
+			ConditionExpression:       &condExpr,
+			ExpressionAttributeValues: values,
+		},
+	}}
+	if unindexed {
+		items = append(items, e.sentinelMove(stored.UserID, stored.PhoneNumber, index)...)
+	}
+
+	_, err = e.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{TransactItems: items})
+	if _, canceled := dynamo.IsTransactionCanceledException(err); canceled {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("transact: %w", err)
+	}
+	return true, nil
+}
+
+// sentinelMove puts the sentinel keyed by the phone's blind index and
+// deletes the legacy one keyed by storedPhone, unless that belongs to
+// another user. Users created outside registration may have no legacy
+// sentinel to delete.
+func (e *UserEncryptor) sentinelMove(userID, storedPhone, phoneIndex string) []dynamo.TransactWriteItem {
+	deleteCond := "attribute_not_exists(user_id) OR owner_id = :owner"
+	return []dynamo.TransactWriteItem{
+		phoneSentinelPut(e.usersTable, phoneIndex, userID),
+		{Delete: &dynamo.Delete{
+			TableName: &e.usersTable,
+			Key: map[string]dynamo.AttributeValue{
+				"user_id": &dynamo.AttributeValueMemberS{Value: phoneSentinelPrefix + storedPhone},
+			},
+			ConditionExpression: &deleteCond,
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":owner": &dynamo.AttributeValueMemberS{Value: userID},
+			},
+		}},
+	}
+}
+
+// fieldStale reports whether a stored field value is on a key version
+// other than current, 0 meaning plaintext. Empty values have nothing to
+// encrypt.
+func fieldStale(value string, current int) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	version, err := msgcrypt.Version(value)
+	if err != nil {
+		return false, err
+	}
+	return version != current, nil
+}
@@ -0,0 +1,212 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt/msgcrypttest"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements userEncryptDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubUserEncryptDynamo struct {
+	scanFn     func(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error)
+	transactFn func(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+func (s *stubUserEncryptDynamo) Scan(ctx context.Context, params *dynamo.ScanInput, optFns ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+	return s.scanFn(ctx, params, optFns...)
+}
+
+func (s *stubUserEncryptDynamo) TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	return s.transactFn(ctx, params, optFns...)
+}
+
+var _ userEncryptDynamoDB = (*stubUserEncryptDynamo)(nil)
+
+// scanPages returns a scanFn serving items one per page, resuming after
+// the start key's user_id.
+func scanPages(t *testing.T, items ...userItem) func(context.Context, *dynamo.ScanInput, ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+	return func(_ context.Context, params *dynamo.ScanInput, _ ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+		assert.Equal(t, usersTable, *params.TableName)
+		i := 0
+		if start, ok := params.ExclusiveStartKey["user_id"]; ok {
+			for items[i].UserID != start.(*dynamo.AttributeValueMemberS).Value {
+				i++
+			}
+			i++
+		}
+		av, err := dynamo.MarshalMap(items[i])
+		require.NoError(t, err)
+		out := &dynamo.ScanOutput{Items: []map[string]dynamo.AttributeValue{av}}
+		if i+1 < len(items) {
+			out.LastEvaluatedKey = map[string]dynamo.AttributeValue{"user_id": av["user_id"]}
+		}
+		return out, nil
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestUserEncryptor_EncryptUsers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("encrypts and indexes plaintext users and re-keys their sentinels", func(t *testing.T) {
+		fields := msgcrypttest.NewFieldCipher(t)
+		plain := sampleUserItem()
+		sso := userItem{UserID: "sso-user", DisplayName: "Grace"}
+		sentinel := userItem{UserID: "phone#" + plain.PhoneNumber, PhoneNumber: plain.PhoneNumber}
+
+		var txs [][]dynamo.TransactWriteItem
+		stub := &stubUserEncryptDynamo{
+			scanFn: scanPages(t, plain, sentinel, sso),
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				txs = append(txs, params.TransactItems)
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+
+		n, err := NewUserEncryptor(stub, usersTable, fields, testPhones).EncryptUsers(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		require.Len(t, txs, 2, "the sentinel moves with its owner")
+
+		phone, err := fields.EncryptSearchable(ctx, phoneField, plain.PhoneNumber)
+		require.NoError(t, err)
+		user := txs[0]
+		require.Len(t, user, 3)
+		assert.Equal(t, "display_name = :old_name AND phone_number = :old_phone", *user[0].Update.ConditionExpression)
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: phone}, user[0].Update.ExpressionAttributeValues[":phone"])
+		index := testPhones.Token(phoneField, plain.PhoneNumber)
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: index}, user[0].Update.ExpressionAttributeValues[":index"])
+		name := user[0].Update.ExpressionAttributeValues[":name"].(*dynamo.AttributeValueMemberS).Value
+		got, err := fields.Decrypt(ctx, displayNameField, name)
+		require.NoError(t, err)
+		assert.Equal(t, plain.DisplayName, got)
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "phone#" + index}, user[1].Put.Item["user_id"])
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "phone#" + plain.PhoneNumber}, user[2].Delete.Key["user_id"])
+
+		ssoTx := txs[1]
+		require.Len(t, ssoTx, 1, "no phone, no sentinel")
+		assert.Contains(t, *ssoTx[0].Update.ConditionExpression, "attribute_not_exists(phone_number)")
+	})
+
+	t.Run("indexes users without a cipher", func(t *testing.T) {
+		var tx []dynamo.TransactWriteItem
+		stub := &stubUserEncryptDynamo{
+			scanFn: scanPages(t, sampleUserItem()),
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				tx = params.TransactItems
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+
+		n, err := NewUserEncryptor(stub, usersTable, nil, testPhones).EncryptUsers(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		require.Len(t, tx, 3)
+		values := tx[0].Update.ExpressionAttributeValues
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: sampleUserItem().PhoneNumber}, values[":phone"], "stays in plaintext")
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: testPhones.Token(phoneField, sampleUserItem().PhoneNumber)}, values[":index"])
+	})
+
+	t.Run("skips users on the current version", func(t *testing.T) {
+		fields := msgcrypttest.NewFieldCipher(t)
+		rec := mustFromUserItem(t, sampleUserItem())
+		item, err := encryptedUserItem(ctx, fields, rec)
+		require.NoError(t, err)
+		item.PhoneIndex = testPhones.Token(phoneField, rec.PhoneNumber)
+		stub := &stubUserEncryptDynamo{
+			scanFn: scanPages(t, item),
+			transactFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				t.Fatal("current users should not be written")
+				return nil, nil
+			},
+		}
+
+		n, err := NewUserEncryptor(stub, usersTable, fields, testPhones).EncryptUsers(ctx)
+
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("re-encrypts after rotation", func(t *testing.T) {
+		fields := msgcrypttest.NewFieldCipher(t)
+		rec := mustFromUserItem(t, sampleUserItem())
+		item, err := encryptedUserItem(ctx, fields, rec)
+		require.NoError(t, err)
+		item.PhoneIndex = testPhones.Token(phoneField, rec.PhoneNumber)
+		_, err = fields.Rotate(ctx)
+		require.NoError(t, err)
+
+		var written string
+		stub := &stubUserEncryptDynamo{
+			scanFn: scanPages(t, item),
+			transactFn: func(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				require.Len(t, params.TransactItems, 1, "the sentinel is keyed by the blind index and stays")
+				written = params.TransactItems[0].Update.ExpressionAttributeValues[":phone"].(*dynamo.AttributeValueMemberS).Value
+				return &dynamo.TransactWriteItemsOutput{}, nil
+			},
+		}
+
+		n, err := NewUserEncryptor(stub, usersTable, fields, testPhones).EncryptUsers(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		version, err := msgcrypt.Version(written)
+		require.NoError(t, err)
+		assert.Equal(t, 2, version)
+	})
+
+	t.Run("users changed mid-run are skipped", func(t *testing.T) {
+		stub := &stubUserEncryptDynamo{
+			scanFn: scanPages(t, sampleUserItem()),
+			transactFn: func(_ context.Context, _ *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+				return nil, dynamo.ErrTransactionCanceled("ConditionalCheckFailed", "None", "None")
+			},
+		}
+
+		n, err := NewUserEncryptor(stub, usersTable, msgcrypttest.NewFieldCipher(t), testPhones).EncryptUsers(ctx)
+
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		stub := &stubUserEncryptDynamo{
+			scanFn: func(_ context.Context, _ *dynamo.ScanInput, _ ...func(*dynamo.Options)) (*dynamo.ScanOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}
+
+		_, err := NewUserEncryptor(stub, usersTable, msgcrypttest.NewFieldCipher(t), testPhones).EncryptUsers(ctx)
+
+		require.ErrorContains(t, err, "user encryptor: scan: throttled")
+	})
+
+	t.Run("requires a blind index", func(t *testing.T) {
+		_, err := NewUserEncryptor(&stubUserEncryptDynamo{}, usersTable, msgcrypttest.NewFieldCipher(t), nil).EncryptUsers(ctx)
+
+		require.Error(t, err)
+	})
+}
+
+func mustFromUserItem(t *testing.T, item userItem) app.UserRecord {
+	t.Helper()
+	rec, err := fromUserItem(item)
+	require.NoError(t, err)
+	return *rec
+}
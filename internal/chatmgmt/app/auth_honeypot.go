@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// Canary event kinds.
+const (
+	CanaryIssued  = "issued"
+	CanaryAttempt = "attempt"
+)
+
+// CanaryEvent is one step of a canary OTP flow. Canary codes are never
+// issued, so the attempted Candidate is recorded verbatim: guessing
+// patterns are what the analysis is for.
+type CanaryEvent struct {
+	Kind      string // CanaryIssued or CanaryAttempt
+	Rule      string // honeypot rule that matched: "number" or "prefix"
+	PhoneHash string
+	ClientIP  string // set on CanaryIssued
+	DeviceID  string // set on CanaryAttempt
+	Candidate string // set on CanaryAttempt
+	At        time.Time
+}
+
+// CanaryRecorder receives canary OTP events for breach-attempt analysis.
+type CanaryRecorder interface {
+	RecordCanary(ctx context.Context, ev CanaryEvent) error
+}
+
+// issueCanary answers an OTP request exactly as a real one would, without
+// storing or sending a code.
+func (s *AuthService) issueCanary(
+	ctx context.Context, phoneHash, rule, clientIP string, format domain.OTPCodeFormat,
+) *RequestOTPResult {
+	now := s.clock.Now().UTC()
+	s.recordCanary(ctx, CanaryEvent{
+		Kind:      CanaryIssued,
+		Rule:      rule,
+		PhoneHash: phoneHash,
+		ClientIP:  clientIP,
+		At:        now,
+	})
+	// Count it as a success too, so the canary is invisible in the request
+	// metrics an attacker-facing dashboard might leak.
+	otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "success")))
+	return &RequestOTPResult{
+		ExpiresAt:         now.Add(format.TTL),
+		RetryAfterSeconds: int(domain.OTPResendDelay.Seconds()),
+		CodeLength:        format.Length,
+		CodeAlphabet:      format.Alphabet,
+	}
+}
+
+// verifyCanary records a verification attempt against a canary and fails it
+// exactly as a wrong code would.
+func (s *AuthService) verifyCanary(ctx context.Context, phoneHash, rule, candidate, deviceID string) error {
+	s.recordCanary(ctx, CanaryEvent{
+		Kind:      CanaryAttempt,
+		Rule:      rule,
+		PhoneHash: phoneHash,
+		DeviceID:  deviceID,
+		Candidate: candidate,
+		At:        s.clock.Now().UTC(),
+	})
+	authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_otp")))
+	return domain.ErrInvalidOTP
+}
+
+func (s *AuthService) recordCanary(ctx context.Context, ev CanaryEvent) {
+	honeypotEventsTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("kind", ev.Kind),
+		attribute.String("rule", ev.Rule),
+	))
+	logger := observability.WithTraceID(ctx, s.logger)
+	logger.WarnContext(ctx, "security.honeypot",
+		"kind", ev.Kind, "rule", ev.Rule, "phone_hash", ev.PhoneHash)
+	if s.canaryRecorder == nil {
+		return
+	}
+	if err := s.canaryRecorder.RecordCanary(ctx, ev); err != nil {
+		logger.ErrorContext(ctx, "failed to record canary event", "error", err, "kind", ev.Kind)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// Token lineage events: how a generation's refresh token was issued.
+const (
+	LineageRegistration = "registration"
+	LineageLogin        = "login"
+	LineageRefresh      = "refresh"
+	LineageSSO          = "sso"
+)
+
+// TokenLineageEntry is one generation of a refresh token family. A family
+// starts when OTP verification creates a session and gains a generation on
+// every rotation. Entries outlive their session so a reuse incident can be
+// reconstructed after the session is revoked.
+type TokenLineageEntry struct {
+	FamilyID   string
+	Generation int64
+	SessionID  string
+	UserID     string
+	DeviceID   string
+	ClientIP   string
+	Event      string // LineageRegistration, LineageLogin or LineageRefresh
+	IssuedAt   string // RFC 3339
+	TTL        int64
+
+	// Set when this generation's token was presented again after rotation.
+	ReusedAt      string
+	ReuseDeviceID string
+	ReuseClientIP string
+}
+
+// TokenReuse describes a presentation of an already-rotated refresh token.
+type TokenReuse struct {
+	At       string // RFC 3339
+	DeviceID string
+	ClientIP string
+}
+
+// TokenLineageStore persists refresh token lineage.
+type TokenLineageStore interface {
+	Append(ctx context.Context, entry TokenLineageEntry) error
+	MarkReused(ctx context.Context, familyID string, generation int64, reuse TokenReuse) error
+	// ListFamily returns a family's generations in order.
+	ListFamily(ctx context.Context, familyID string) ([]TokenLineageEntry, error)
+	// ListByUser returns the generations of every retained family of the
+	// user, ordered by family then generation.
+	ListByUser(ctx context.Context, userID string) ([]TokenLineageEntry, error)
+}
+
+// TokenFamily returns the lineage of one refresh token family for
+// administrators. It is not exposed to end users.
+func (s *AuthService) TokenFamily(ctx context.Context, familyID string) ([]TokenLineageEntry, error) {
+	ctx, span := tracer.Start(ctx, "auth.token_family")
+	defer span.End()
+
+	if s.lineage == nil {
+		return nil, nil
+	}
+	entries, err := s.lineage.ListFamily(ctx, familyID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("list token family: %w", err)
+	}
+	return entries, nil
+}
+
+// UserTokenLineage returns the lineage of every retained refresh token
+// family of a user for administrators.
+func (s *AuthService) UserTokenLineage(ctx context.Context, userID string) ([]TokenLineageEntry, error) {
+	ctx, span := tracer.Start(ctx, "auth.user_token_lineage")
+	defer span.End()
+
+	if s.lineage == nil {
+		return nil, nil
+	}
+	entries, err := s.lineage.ListByUser(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("list user token lineage: %w", err)
+	}
+	return entries, nil
+}
+
+// recordGeneration appends a generation to its family. Lineage is forensic
+// data: a failed write is logged and never fails the auth flow.
+func (s *AuthService) recordGeneration(ctx context.Context, entry TokenLineageEntry) {
+	if s.lineage == nil {
+		return
+	}
+	now := s.clock.Now().UTC()
+	entry.IssuedAt = now.Format(time.RFC3339)
+	entry.TTL = now.Add(s.refreshTTL + domain.TokenLineageRetention).Unix()
+	if err := s.lineage.Append(ctx, entry); err != nil {
+		observability.WithTraceID(ctx, s.logger).ErrorContext(ctx, "failed to record token lineage",
+			"error", err, "family_id", entry.FamilyID, "generation", entry.Generation)
+	}
+}
+
+// recordReuse marks the generation whose token was replayed.
+func (s *AuthService) recordReuse(ctx context.Context, session *SessionRecord, deviceID, clientIP string) {
+	if s.lineage == nil {
+		return
+	}
+	reuse := TokenReuse{
+		At:       s.clock.Now().UTC().Format(time.RFC3339),
+		DeviceID: deviceID,
+		ClientIP: clientIP,
+	}
+	family := familyOf(session)
+	if err := s.lineage.MarkReused(ctx, family, session.TokenGeneration-1, reuse); err != nil {
+		observability.WithTraceID(ctx, s.logger).ErrorContext(ctx, "failed to record token reuse",
+			"error", err, "family_id", family, "generation", session.TokenGeneration-1)
+	}
+}
+
+// familyOf returns the session's token family. Sessions created before
+// lineage tracking form a family keyed by their session ID.
+func familyOf(session *SessionRecord) string {
+	if session.FamilyID != "" {
+		return session.FamilyID
+	}
+	return session.SessionID
+}
@@ -0,0 +1,107 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestTokenLineage(t *testing.T) {
+	testPhoneHash := auth.HashPhone(testPhone)
+
+	t.Run("registration starts a family at generation 1", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) {
+			return sampleOTPRecord(testPhoneHash, h.clock), nil
+		}
+		h.userStore.findByPhoneFn = func(context.Context, string) (*app.UserRecord, error) {
+			return nil, domain.ErrNotFound
+		}
+		var params app.RegistrationParams
+		h.transactor.verifyOTPAndCreateUserFn = func(_ context.Context, p app.RegistrationParams) error {
+			params = p
+			return nil
+		}
+
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
+		require.NoError(t, err)
+
+		require.Len(t, h.lineage.entries, 1)
+		entry := h.lineage.entries[0]
+		assert.NotEmpty(t, params.FamilyID)
+		assert.Equal(t, params.FamilyID, entry.FamilyID)
+		assert.Equal(t, int64(1), entry.Generation)
+		assert.Equal(t, result.SessionID, entry.SessionID)
+		assert.Equal(t, testDeviceID, entry.DeviceID)
+		assert.Equal(t, testClientIP, entry.ClientIP)
+		assert.Equal(t, app.LineageRegistration, entry.Event)
+		assert.Equal(t, testStart.Format(time.RFC3339), entry.IssuedAt)
+		assert.Equal(t, testStart.Add(domain.RefreshTokenLifetime+domain.TokenLineageRetention).Unix(), entry.TTL)
+	})
+
+	t.Run("rotation appends the next generation from the new address", func(t *testing.T) {
+		h := newTestHarness(t)
+		mint, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+		session := sampleSessionRecord("user-001", "sess-001", testDeviceID, auth.HashRefreshToken("rt"), h.clock)
+		session.FamilyID = "family-001"
+		h.sessionStore.getByIDFn = func(context.Context, string) (*app.SessionRecord, error) {
+			return session, nil
+		}
+
+		_, err = h.svc.RefreshTokens(context.Background(), mint.Token, "rt", testDeviceID, "198.51.100.9")
+		require.NoError(t, err)
+
+		require.Len(t, h.lineage.entries, 1)
+		entry := h.lineage.entries[0]
+		assert.Equal(t, "family-001", entry.FamilyID)
+		assert.Equal(t, int64(2), entry.Generation)
+		assert.Equal(t, "198.51.100.9", entry.ClientIP)
+		assert.Equal(t, app.LineageRefresh, entry.Event)
+	})
+
+	t.Run("reuse marks the replayed generation; pre-lineage sessions are their own family", func(t *testing.T) {
+		h := newTestHarness(t)
+		mint, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+		session := sampleSessionRecord("user-001", "sess-001", testDeviceID, auth.HashRefreshToken("current"), h.clock)
+		session.PrevTokenHash = auth.HashRefreshToken("stolen")
+		session.TokenGeneration = 3
+		h.sessionStore.getByIDFn = func(context.Context, string) (*app.SessionRecord, error) {
+			return session, nil
+		}
+
+		_, err = h.svc.RefreshTokens(context.Background(), mint.Token, "stolen", testDeviceID, "192.0.2.66")
+		require.ErrorIs(t, err, domain.ErrRefreshTokenReuse)
+
+		assert.Equal(t, "sess-001", h.lineage.family)
+		assert.Equal(t, app.TokenReuse{
+			At:       testStart.Format(time.RFC3339),
+			DeviceID: testDeviceID,
+			ClientIP: "192.0.2.66",
+		}, h.lineage.reused[2])
+	})
+
+	t.Run("admin queries read the store", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.lineage.entries = []app.TokenLineageEntry{
+			{FamilyID: "family-001", Generation: 1, UserID: "user-001"},
+			{FamilyID: "family-002", Generation: 1, UserID: "user-002"},
+		}
+
+		family, err := h.svc.TokenFamily(context.Background(), "family-002")
+		require.NoError(t, err)
+		assert.Equal(t, h.lineage.entries[1:], family)
+
+		byUser, err := h.svc.UserTokenLineage(context.Background(), "user-001")
+		require.NoError(t, err)
+		assert.Equal(t, h.lineage.entries[:1], byUser)
+	})
+}
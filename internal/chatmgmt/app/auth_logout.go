@@ -35,7 +35,7 @@ func (s *AuthService) Logout(ctx context.Context, accessToken string) error {
 	}
 
 	// 3. Revoke JTI.
-	if err := s.revocationStore.Revoke(ctx, claims.ID); err != nil {
+	if err := s.revokeToken(ctx, claims, "logout"); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("revoke JTI: %w", err)
@@ -49,3 +49,33 @@ func (s *AuthService) Logout(ctx context.Context, accessToken string) error {
 
 	return nil
 }
+
+// LogoutAllSessions signs the caller out on every device, the calling one
+// included: it revokes all of the user's access tokens, deletes their
+// sessions, and returns how many it deleted.
+func (s *AuthService) LogoutAllSessions(ctx context.Context, accessToken string) (int, error) {
+	ctx, span := tracer.Start(ctx, "auth.logout_all")
+	defer span.End()
+
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	n, err := s.RevokeUserSessions(ctx, claims.Subject, "logout_all")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return n, err
+	}
+
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "auth.logout_all",
+		"user_id", claims.Subject,
+		"session_id", claims.SessionID,
+		"sessions", n,
+	)
+	return n, nil
+}
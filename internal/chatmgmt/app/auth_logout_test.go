@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 )
 
@@ -26,8 +27,10 @@ func TestLogout(t *testing.T) {
 		}
 
 		jtiRevoked := ""
-		h.revocationStore.revokeFn = func(_ context.Context, jti string) error {
+		var revocation app.Revocation
+		h.revocationStore.revokeFn = func(_ context.Context, jti string, r app.Revocation) error {
 			jtiRevoked = jti
+			revocation = r
 			return nil
 		}
 
@@ -35,6 +38,8 @@ func TestLogout(t *testing.T) {
 		require.NoError(t, err)
 		assert.True(t, sessionDeleted, "session should be deleted")
 		assert.Equal(t, mintResult.JTI, jtiRevoked, "JTI should be revoked")
+		assert.Equal(t, "logout", revocation.Reason)
+		assert.Equal(t, mintResult.ExpiresAt.Unix(), revocation.ExpiresAt.Unix(), "revocation should expire with the token")
 	})
 
 	t.Run("invalid access token: ErrUnauthorized", func(t *testing.T) {
@@ -66,7 +71,7 @@ func TestLogout(t *testing.T) {
 		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
 		require.NoError(t, err)
 
-		h.revocationStore.revokeFn = func(_ context.Context, _ string) error {
+		h.revocationStore.revokeFn = func(_ context.Context, _ string, _ app.Revocation) error {
 			return errRedis
 		}
 
@@ -75,3 +80,64 @@ func TestLogout(t *testing.T) {
 		assert.ErrorIs(t, err, errRedis)
 	})
 }
+
+func TestLogoutAllSessions(t *testing.T) {
+	t.Run("success: revokes every session of the caller", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		h.sessionStore.listByUserFn = func(_ context.Context, userID string) ([]app.SessionRecord, error) {
+			assert.Equal(t, "user-001", userID)
+			return []app.SessionRecord{{SessionID: "sess-001"}, {SessionID: "sess-002"}}, nil
+		}
+		var deleted []string
+		h.sessionStore.deleteFn = func(_ context.Context, sessionID string) error {
+			deleted = append(deleted, sessionID)
+			return nil
+		}
+		var revocation app.Revocation
+		h.revocationStore.revokeAllFn = func(_ context.Context, userID string, r app.Revocation) error {
+			assert.Equal(t, "user-001", userID)
+			revocation = r
+			return nil
+		}
+
+		n, err := h.svc.LogoutAllSessions(context.Background(), mintResult.Token)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"sess-001", "sess-002"}, deleted, "the calling session is signed out too")
+		assert.Equal(t, "logout_all", revocation.Reason)
+		assert.Equal(t, []string{"user-001/logout_all/sess-001,sess-002"}, h.revocationPub.published)
+	})
+
+	t.Run("invalid access token: ErrUnauthorized", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.revocationStore.revokeAllFn = func(context.Context, string, app.Revocation) error {
+			t.Fatal("revoked without a valid token")
+			return nil
+		}
+
+		_, err := h.svc.LogoutAllSessions(context.Background(), "garbage-token")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("revocation store failure: error", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		h.revocationStore.revokeAllFn = func(context.Context, string, app.Revocation) error {
+			return errors.New("redis down")
+		}
+
+		n, err := h.svc.LogoutAllSessions(context.Background(), mintResult.Token)
+
+		assert.ErrorContains(t, err, "redis down")
+		assert.Zero(t, n)
+	})
+}
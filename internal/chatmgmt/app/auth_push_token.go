@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// PushRegistration is the push token a client registers for its device.
+type PushRegistration struct {
+	Platform domain.PushPlatform
+	// Token is the APNs device token, FCM registration token or WebPush
+	// endpoint.
+	Token string
+	// P256DH and Auth are the WebPush subscription keys.
+	P256DH string
+	Auth   string
+}
+
+// RegisterPushToken records the push token of the device behind the access
+// token's session. Clients call it at every launch and whenever the
+// platform hands them a new token (APNs and FCM rotate tokens without
+// notice, browsers renew WebPush subscriptions); registering again
+// replaces the device's previous token, so a refresh never leaves a stale
+// token behind.
+func (s *AuthService) RegisterPushToken(ctx context.Context, accessToken string, reg PushRegistration) error {
+	ctx, span := tracer.Start(ctx, "auth.register_push_token")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, s.logger)
+
+	// 1. Validate access token (full validation including expiry).
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	// 2. Resolve the device from the session; the client never names it.
+	session, err := s.sessionStore.GetByID(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("%w: session not found", domain.ErrUnauthorized)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("get session: %w", err)
+	}
+	if session.UserID != claims.Subject {
+		return fmt.Errorf("%w: session does not belong to token subject", domain.ErrUnauthorized)
+	}
+
+	dt := domain.DeviceToken{
+		UserID:    claims.Subject,
+		DeviceID:  session.DeviceID,
+		Platform:  reg.Platform,
+		Token:     reg.Token,
+		P256DH:    reg.P256DH,
+		Auth:      reg.Auth,
+		UpdatedAt: s.clock.Now().UTC(),
+	}
+	if err := dt.Validate(); err != nil {
+		return err
+	}
+
+	// 3. Upsert by (user, device).
+	if err := s.pushTokenStore.PutPushToken(ctx, dt); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("put push token: %w", err)
+	}
+
+	logger.InfoContext(ctx, "auth.register_push_token",
+		"user_id", dt.UserID,
+		"device_id", dt.DeviceID,
+		"platform", string(reg.Platform),
+	)
+
+	return nil
+}
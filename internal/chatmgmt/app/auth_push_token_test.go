@@ -0,0 +1,123 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestRegisterPushToken(t *testing.T) {
+	ctx := context.Background()
+
+	// withSession mints an access token for sess-001 on dev-001.
+	withSession := func(t *testing.T, h *testHarness, owner string) string {
+		t.Helper()
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+		h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
+			return sampleSessionRecord(owner, "sess-001", "dev-001", "hash", h.clock), nil
+		}
+		return mintResult.Token
+	}
+
+	t.Run("success: token stored for the session's device", func(t *testing.T) {
+		h := newTestHarness(t)
+		accessToken := withSession(t, h, "user-001")
+
+		var stored domain.DeviceToken
+		h.pushTokenStore.putFn = func(_ context.Context, tok domain.DeviceToken) error {
+			stored = tok
+			return nil
+		}
+
+		err := h.svc.RegisterPushToken(ctx, accessToken, app.PushRegistration{Platform: domain.PushPlatformAPNs, Token: "apns-token"})
+		require.NoError(t, err)
+		assert.Equal(t, domain.DeviceToken{
+			UserID:    "user-001",
+			DeviceID:  "dev-001",
+			Platform:  domain.PushPlatformAPNs,
+			Token:     "apns-token",
+			UpdatedAt: h.clock.Now().UTC(),
+		}, stored)
+	})
+
+	t.Run("webpush subscription keeps its keys", func(t *testing.T) {
+		h := newTestHarness(t)
+		accessToken := withSession(t, h, "user-001")
+
+		var stored domain.DeviceToken
+		h.pushTokenStore.putFn = func(_ context.Context, tok domain.DeviceToken) error {
+			stored = tok
+			return nil
+		}
+
+		err := h.svc.RegisterPushToken(ctx, accessToken, app.PushRegistration{
+			Platform: domain.PushPlatformWebPush,
+			Token:    "https://push.example.net/send/abc",
+			P256DH:   "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+			Auth:     "BTBZMqHH6r4Tts7J_aSIgg",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "BTBZMqHH6r4Tts7J_aSIgg", stored.Auth)
+		assert.NotEmpty(t, stored.P256DH)
+	})
+
+	t.Run("webpush without keys: ErrInvalidInput", func(t *testing.T) {
+		h := newTestHarness(t)
+		accessToken := withSession(t, h, "user-001")
+
+		err := h.svc.RegisterPushToken(ctx, accessToken, app.PushRegistration{
+			Platform: domain.PushPlatformWebPush,
+			Token:    "https://push.example.net/send/abc",
+		})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("invalid access token: ErrUnauthorized", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		err := h.svc.RegisterPushToken(ctx, "garbage-token", app.PushRegistration{Platform: domain.PushPlatformFCM, Token: "fcm-token"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("session gone: ErrUnauthorized", func(t *testing.T) {
+		h := newTestHarness(t)
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		err = h.svc.RegisterPushToken(ctx, mintResult.Token, app.PushRegistration{Platform: domain.PushPlatformFCM, Token: "fcm-token"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("session of another user: ErrUnauthorized", func(t *testing.T) {
+		h := newTestHarness(t)
+		accessToken := withSession(t, h, "user-002")
+
+		err := h.svc.RegisterPushToken(ctx, accessToken, app.PushRegistration{Platform: domain.PushPlatformFCM, Token: "fcm-token"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("unknown platform: ErrInvalidInput", func(t *testing.T) {
+		h := newTestHarness(t)
+		accessToken := withSession(t, h, "user-001")
+
+		err := h.svc.RegisterPushToken(ctx, accessToken, app.PushRegistration{Platform: "mpns", Token: "token"})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("store failure: returns wrapped error", func(t *testing.T) {
+		h := newTestHarness(t)
+		accessToken := withSession(t, h, "user-001")
+		errDynamo := errors.New("throttled")
+		h.pushTokenStore.putFn = func(context.Context, domain.DeviceToken) error { return errDynamo }
+
+		err := h.svc.RegisterPushToken(ctx, accessToken, app.PushRegistration{Platform: domain.PushPlatformFCM, Token: "fcm-token"})
+		assert.ErrorIs(t, err, errDynamo)
+	})
+}
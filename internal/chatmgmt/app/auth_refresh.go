@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -17,7 +16,7 @@ import (
 
 // RefreshTokens rotates tokens using the refresh token rotation protocol
 // with reuse detection (ADR-015 §4.2, §4.3).
-func (s *AuthService) RefreshTokens(ctx context.Context, accessToken, refreshToken, deviceID string) (*RefreshResult, error) {
+func (s *AuthService) RefreshTokens(ctx context.Context, accessToken, refreshToken, deviceID, clientIP string) (*RefreshResult, error) {
 	ctx, span := tracer.Start(ctx, "auth.refresh_tokens")
 	defer span.End()
 
@@ -53,21 +52,27 @@ func (s *AuthService) RefreshTokens(ctx context.Context, accessToken, refreshTok
 	}
 
 	// 4. Check session expiry.
-	sessionExpiry, err := time.Parse(time.RFC3339, session.ExpiresAt)
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-		return nil, fmt.Errorf("parse session expiry: %w", err)
-	}
-	if s.clock.Now().UTC().After(sessionExpiry) {
+	if s.clock.Now().UTC().After(session.ExpiresAt.Time()) {
 		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "session_expired")))
 		span.SetStatus(codes.Error, "session expired")
 		return nil, domain.ErrSessionExpired
 	}
 
+	// 4b. Check idle expiry. The sweeper removes idle sessions periodically;
+	// this closes the window until it runs.
+	if s.isIdle(session, s.clock.Now().UTC()) {
+		sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "idle")))
+		if delErr := s.sessionStore.Delete(ctx, claims.SessionID); delErr != nil {
+			logger.ErrorContext(ctx, "failed to delete idle session", "error", delErr)
+		}
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "session_idle")))
+		span.SetStatus(codes.Error, "session idle")
+		return nil, domain.ErrSessionExpired
+	}
+
 	// 5. Check current refresh token hash.
-	if auth.ValidateRefreshHash(refreshToken, session.RefreshTokenHash) {
-		result, rotateErr := s.rotateRefreshToken(ctx, claims.Subject, claims.SessionID, session)
+	if s.refreshHasher.Verify(refreshToken, session.RefreshTokenHash) {
+		result, rotateErr := s.rotateRefreshToken(ctx, claims.Subject, claims.SessionID, session, clientIP)
 		if rotateErr != nil {
 			span.RecordError(rotateErr)
 			span.SetStatus(codes.Error, rotateErr.Error())
@@ -81,19 +86,21 @@ func (s *AuthService) RefreshTokens(ctx context.Context, accessToken, refreshTok
 	}
 
 	// 6. Check previous token hash (reuse detection).
-	if session.PrevTokenHash != "" && auth.ValidateRefreshHash(refreshToken, session.PrevTokenHash) {
+	if session.PrevTokenHash != "" && s.refreshHasher.Verify(refreshToken, session.PrevTokenHash) {
 		// REUSE DETECTED — revoke session immediately.
 		sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "reuse_detection")))
+		s.recordReuse(ctx, session, deviceID, clientIP)
 		if delErr := s.sessionStore.Delete(ctx, claims.SessionID); delErr != nil {
 			logger.ErrorContext(ctx, "failed to delete session on reuse detection", "error", delErr)
 		}
-		if revErr := s.revocationStore.Revoke(ctx, claims.ID); revErr != nil {
+		if revErr := s.revokeToken(ctx, claims, "reuse_detection"); revErr != nil {
 			logger.ErrorContext(ctx, "failed to revoke JTI on reuse detection", "error", revErr)
 		}
 		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "refresh_token_reuse")))
 		logger.WarnContext(ctx, "auth.refresh_token_reuse",
 			"session_id", claims.SessionID,
 			"user_id", claims.Subject,
+			"family_id", familyOf(session),
 		)
 		span.SetStatus(codes.Error, "refresh token reuse detected")
 		return nil, domain.ErrRefreshTokenReuse
@@ -109,26 +116,53 @@ func (s *AuthService) rotateRefreshToken(
 	ctx context.Context,
 	userID, sessionID string,
 	session *SessionRecord,
+	clientIP string,
 ) (*RefreshResult, error) {
 	newRefresh, err := auth.GenerateRefreshToken()
 	if err != nil {
 		return nil, fmt.Errorf("generate refresh token: %w", err)
 	}
-	newHash := auth.HashRefreshToken(newRefresh)
+	newHash, err := s.refreshHasher.Hash(newRefresh)
+	if err != nil {
+		return nil, err
+	}
 
-	newExpiry := s.clock.Now().UTC().Add(domain.RefreshTokenLifetime)
+	now := domain.TimestampNow(s.clock)
+	newExpiry := now.Add(s.refreshTTL)
+	if !session.MaxExpiresAt.IsZero() && session.MaxExpiresAt.Before(newExpiry) {
+		newExpiry = session.MaxExpiresAt
+	}
 
 	update := SessionUpdate{
 		RefreshTokenHash: newHash,
 		PrevTokenHash:    session.RefreshTokenHash,
 		TokenGeneration:  session.TokenGeneration + 1,
-		ExpiresAt:        newExpiry.Format(time.RFC3339),
-		TTL:              newExpiry.Unix(),
+		ExpiresAt:        newExpiry,
+		LastActiveAt:     now,
+		TTL:              newExpiry.Time().Unix(),
+
+		ExpectedGeneration: session.TokenGeneration,
 	}
 
+	// A parallel refresh with the same token may rotate the session
+	// first. The loser is rejected rather than retried: on a re-read its
+	// token would be the previous one, which reuse detection treats as
+	// theft. The client keeps the tokens from the refresh that won.
 	if updateErr := s.sessionStore.Update(ctx, sessionID, update); updateErr != nil {
+		if errors.Is(updateErr, domain.ErrVersionConflict) {
+			authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "refresh_conflict")))
+		}
 		return nil, fmt.Errorf("update session: %w", updateErr)
 	}
+	s.recordGeneration(ctx, TokenLineageEntry{
+		FamilyID:   familyOf(session),
+		Generation: update.TokenGeneration,
+		SessionID:  sessionID,
+		UserID:     userID,
+		DeviceID:   session.DeviceID,
+		ClientIP:   clientIP,
+		Event:      LineageRefresh,
+	})
 
 	mintResult, err := s.minter.MintAccessToken(userID, sessionID)
 	if err != nil {
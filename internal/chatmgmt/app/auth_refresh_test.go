@@ -3,6 +3,8 @@ package app_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,7 +40,7 @@ func TestRefreshTokens(t *testing.T) {
 			return nil
 		}
 
-		result, err := h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID)
+		result, err := h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID, testClientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		assert.NotEmpty(t, result.AccessToken)
@@ -48,6 +50,63 @@ func TestRefreshTokens(t *testing.T) {
 		// Session should be updated with new hash and bumped generation.
 		assert.Equal(t, refreshHash, updatedSession.PrevTokenHash, "prev hash should be old hash")
 		assert.Equal(t, session.TokenGeneration+1, updatedSession.TokenGeneration)
+		assert.Equal(t, session.TokenGeneration, updatedSession.ExpectedGeneration)
+		assert.Equal(t, domain.NewTimestampMS(testStart.Add(domain.RefreshTokenLifetime)), updatedSession.ExpiresAt)
+		assert.Equal(t, domain.NewTimestampMS(testStart), updatedSession.LastActiveAt, "refresh counts as activity")
+	})
+
+	t.Run("configured refresh TTL bounds the rotated session expiry", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := h.newService(7 * 24 * time.Hour)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		refreshToken := "original-refresh-token"
+		session := sampleSessionRecord("user-001", "sess-001", deviceID, auth.HashRefreshToken(refreshToken), h.clock)
+		h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
+			return session, nil
+		}
+
+		var updatedSession app.SessionUpdate
+		h.sessionStore.updateFn = func(_ context.Context, _ string, update app.SessionUpdate) error {
+			updatedSession = update
+			return nil
+		}
+
+		_, err = svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID, testClientIP)
+		require.NoError(t, err)
+
+		wantExpiry := testStart.Add(7 * 24 * time.Hour)
+		assert.Equal(t, domain.NewTimestampMS(wantExpiry), updatedSession.ExpiresAt)
+		assert.Equal(t, wantExpiry.Unix(), updatedSession.TTL)
+	})
+
+	t.Run("SSO session cap is never extended by a refresh", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		refreshToken := "original-refresh-token"
+		session := sampleSessionRecord("user-001", "sess-001", deviceID, auth.HashRefreshToken(refreshToken), h.clock)
+		session.MaxExpiresAt = domain.NewTimestampMS(testStart.Add(8 * time.Hour))
+		session.ExpiresAt = session.MaxExpiresAt
+		h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
+			return session, nil
+		}
+
+		var updatedSession app.SessionUpdate
+		h.sessionStore.updateFn = func(_ context.Context, _ string, update app.SessionUpdate) error {
+			updatedSession = update
+			return nil
+		}
+
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID, testClientIP)
+		require.NoError(t, err)
+
+		assert.Equal(t, session.MaxExpiresAt, updatedSession.ExpiresAt)
+		assert.Equal(t, testStart.Add(8*time.Hour).Unix(), updatedSession.TTL)
 	})
 
 	t.Run("reuse detection: session deleted + JTI revoked + ErrRefreshTokenReuse", func(t *testing.T) {
@@ -77,12 +136,12 @@ func TestRefreshTokens(t *testing.T) {
 		}
 
 		jtiRevoked := false
-		h.revocationStore.revokeFn = func(_ context.Context, _ string) error {
+		h.revocationStore.revokeFn = func(_ context.Context, _ string, _ app.Revocation) error {
 			jtiRevoked = true
 			return nil
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, reusedRefresh, deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, reusedRefresh, deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrRefreshTokenReuse)
 		assert.True(t, sessionDeleted, "session should be deleted on reuse")
@@ -101,7 +160,7 @@ func TestRefreshTokens(t *testing.T) {
 			return session, nil
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "wrong-token", deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "wrong-token", deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
 	})
@@ -118,7 +177,7 @@ func TestRefreshTokens(t *testing.T) {
 			return session, nil
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrDeviceMismatch)
 	})
@@ -132,17 +191,47 @@ func TestRefreshTokens(t *testing.T) {
 		refreshHash := auth.HashRefreshToken("some-token")
 		session := sampleSessionRecord("user-001", "sess-002", deviceID, refreshHash, h.clock)
 		// Expire the session.
-		session.ExpiresAt = testStart.Add(-time.Hour).Format(time.RFC3339)
+		session.ExpiresAt = domain.NewTimestampMS(testStart.Add(-time.Hour))
 
 		h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
 			return session, nil
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrSessionExpired)
 	})
 
+	t.Run("idle session: deleted + ErrSessionExpired", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-002")
+		require.NoError(t, err)
+
+		refreshHash := auth.HashRefreshToken("some-token")
+		session := sampleSessionRecord("user-001", "sess-002", deviceID, refreshHash, h.clock)
+		// Still within the refresh TTL, but unused for longer than the idle timeout.
+		session.LastActiveAt = domain.NewTimestampMS(testStart.Add(-domain.SessionIdleTimeout))
+
+		h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
+			return session, nil
+		}
+		var deleted string
+		h.sessionStore.deleteFn = func(_ context.Context, sessionID string) error {
+			deleted = sessionID
+			return nil
+		}
+		h.sessionStore.updateFn = func(context.Context, string, app.SessionUpdate) error {
+			t.Fatal("idle session must not be rotated")
+			return nil
+		}
+
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID, testClientIP)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrSessionExpired)
+		assert.Equal(t, "sess-002", deleted)
+	})
+
 	t.Run("session not found: ErrSessionRevoked", func(t *testing.T) {
 		h := newTestHarness(t)
 
@@ -153,7 +242,7 @@ func TestRefreshTokens(t *testing.T) {
 			return nil, domain.ErrNotFound
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-token", deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrSessionRevoked)
 	})
@@ -161,7 +250,7 @@ func TestRefreshTokens(t *testing.T) {
 	t.Run("invalid access token: ErrUnauthorized", func(t *testing.T) {
 		h := newTestHarness(t)
 
-		_, err := h.svc.RefreshTokens(context.Background(), "garbage-token", "some-refresh", deviceID)
+		_, err := h.svc.RefreshTokens(context.Background(), "garbage-token", "some-refresh", deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrUnauthorized)
 	})
@@ -177,7 +266,7 @@ func TestRefreshTokens(t *testing.T) {
 			return nil, errDB
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-refresh", deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, "some-refresh", deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errDB)
 	})
@@ -200,8 +289,67 @@ func TestRefreshTokens(t *testing.T) {
 			return errDB
 		}
 
-		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID)
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errDB)
 	})
+
+	t.Run("parallel refresh lost the race: ErrVersionConflict, session kept", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		refreshToken := "valid-refresh-token"
+		session := sampleSessionRecord("user-001", "sess-001", deviceID, auth.HashRefreshToken(refreshToken), h.clock)
+		h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
+			return session, nil
+		}
+		h.sessionStore.updateFn = func(_ context.Context, _ string, _ app.SessionUpdate) error {
+			return fmt.Errorf("session store: update: %w", domain.ErrVersionConflict)
+		}
+		h.sessionStore.deleteFn = func(_ context.Context, _ string) error {
+			t.Fatal("losing a refresh race must not delete the session")
+			return nil
+		}
+
+		_, err = h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID, testClientIP)
+		require.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.Empty(t, h.lineage.entries, "no generation is recorded for a rejected rotation")
+	})
+}
+
+func TestRefreshTokens_Argon2id(t *testing.T) {
+	const deviceID = "device-abc-123"
+
+	h := newTestHarness(t)
+	h.refreshHasher = auth.NewRefreshHasher(auth.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+	h.svc = h.newService(0)
+	mintResult, err := h.minter.MintAccessToken("user-001", "sess-001")
+	require.NoError(t, err)
+
+	// A session from before the migration, hashed with SHA-256.
+	refreshToken := "original-refresh-token"
+	session := sampleSessionRecord("user-001", "sess-001", deviceID, auth.HashRefreshToken(refreshToken), h.clock)
+	h.sessionStore.getByIDFn = func(_ context.Context, _ string) (*app.SessionRecord, error) {
+		return session, nil
+	}
+	h.sessionStore.updateFn = func(_ context.Context, _ string, update app.SessionUpdate) error {
+		session.PrevTokenHash = update.PrevTokenHash
+		session.RefreshTokenHash = update.RefreshTokenHash
+		session.TokenGeneration = update.TokenGeneration
+		return nil
+	}
+
+	result, err := h.svc.RefreshTokens(context.Background(), mintResult.Token, refreshToken, deviceID, testClientIP)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(session.RefreshTokenHash, "$argon2id$"), "rotated onto Argon2id")
+
+	first := result.RefreshToken
+	result, err = h.svc.RefreshTokens(context.Background(), result.AccessToken, first, deviceID, testClientIP)
+	require.NoError(t, err)
+
+	h.sessionStore.deleteFn = func(context.Context, string) error { return nil }
+	_, err = h.svc.RefreshTokens(context.Background(), result.AccessToken, first, deviceID, testClientIP)
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenReuse, "reuse is detected against the Argon2id previous hash")
 }
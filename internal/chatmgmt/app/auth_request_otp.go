@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/netip"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -15,8 +16,6 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/observability"
 )
 
-const otpRetryAfterSeconds = 60
-
 // RequestOTP validates the phone number, enforces rate limits, generates an OTP,
 // stores it, and fires SMS delivery (ADR-015 §1).
 func (s *AuthService) RequestOTP(ctx context.Context, phone, clientIP string) (*RequestOTPResult, error) {
@@ -25,15 +24,30 @@ func (s *AuthService) RequestOTP(ctx context.Context, phone, clientIP string) (*
 
 	logger := observability.WithTraceID(ctx, s.logger)
 
-	// 1. Validate E.164 phone number.
-	if _, err := domain.NewPhoneNumber(phone); err != nil {
+	// 1. Normalize to E.164 and apply the region/line-type policy.
+	phoneNumber, err := domain.NewPhoneNumber(phone)
+	if err == nil {
+		err = s.phonePolicy.Check(phoneNumber)
+	}
+	if err != nil {
 		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_phone")))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+	phone = phoneNumber.String()
+
+	// 1a. IP reputation and geo-blocking, before any rate limit budget or
+	// SMS is spent on the request.
+	if s.ipScreener != nil {
+		if err := s.ipScreener.Screen(ctx, "request_otp", clientIP); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "client address blocked")
+			return nil, err
+		}
+	}
 
-	phoneHash := auth.HashPhone(phone)
+	phoneHash := s.peppers.HashPhone(phone)
 
 	// 2. Rate limit: phone (fail-closed per ADR-013).
 	allowed, err := s.rateLimiter.CheckAndIncrement(
@@ -51,56 +65,79 @@ func (s *AuthService) RequestOTP(ctx context.Context, phone, clientIP string) (*
 		rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
 			attribute.String("endpoint", "request_otp"),
 			attribute.String("limit_type", "phone"),
+			attribute.String("tier", "number"),
 		))
 		span.SetStatus(codes.Error, "phone rate limited")
 		return nil, domain.ErrPhoneRateLimited
 	}
 
-	// 3. Rate limit: IP (fail-open — log and continue if Redis fails).
-	ipAllowed, ipErr := s.rateLimiter.CheckAndIncrement(
-		ctx,
-		"otp_req:ip:"+clientIP,
-		domain.OTPRequestRateLimitPerIP,
-		int(domain.OTPRateLimitWindow.Seconds()),
-	)
-	if ipErr != nil {
-		logger.WarnContext(ctx, "ip rate limit check failed, proceeding (fail-open)",
-			"error", ipErr, "client_ip", clientIP)
-	} else if !ipAllowed {
-		rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("endpoint", "request_otp"),
-			attribute.String("limit_type", "ip"),
-		))
-		span.SetStatus(codes.Error, "IP rate limited")
-		return nil, domain.ErrIPRateLimited
+	// 3. Rate limit: IP, then its subnet, so rotating addresses within one
+	// allocation does not escape the limit (fail-open — log and continue
+	// if Redis fails).
+	for _, l := range s.ipRateLimits(clientIP) {
+		ipAllowed, ipErr := s.rateLimiter.CheckAndIncrement(
+			ctx,
+			l.key,
+			l.limit,
+			int(domain.OTPRateLimitWindow.Seconds()),
+		)
+		if ipErr != nil {
+			logger.WarnContext(ctx, "ip rate limit check failed, proceeding (fail-open)",
+				"error", ipErr, "client_ip", clientIP, "tier", l.tier)
+			continue
+		}
+		if !ipAllowed {
+			rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("endpoint", "request_otp"),
+				attribute.String("limit_type", "ip"),
+				attribute.String("tier", l.tier),
+			))
+			span.SetStatus(codes.Error, "IP rate limited")
+			return nil, domain.ErrIPRateLimited
+		}
 	}
 
-	// 4. Generate OTP and compute MAC.
-	otp, err := auth.GenerateOTP()
+	// 3a. Grade the request; riskier requests get harder, shorter-lived codes.
+	risk := s.otpPolicy.Assess(phoneNumber)
+	format := s.otpPolicy.Format(risk)
+	span.SetAttributes(attribute.String("auth.otp_risk", risk.String()))
+
+	// 3b. Honeypot: suspicious numbers get a canary flow that looks real
+	// but stores and sends nothing. Rate limits above still apply, so the
+	// canary behaves like any other number under load.
+	if rule, ok := s.honeypot.Match(phoneNumber); ok {
+		return s.issueCanary(ctx, phoneHash, rule, clientIP, format), nil
+	}
+
+	// 4. Generate OTP and compute MAC. The MAC binds the code format so the
+	// record cannot be verified under a weaker one.
+	otp, err := auth.GenerateCode(format.Length, format.Alphabet)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("generate OTP: %w", err)
 	}
 
-	now := s.clock.Now().UTC()
-	expiresAt := now.Add(domain.OTPValidityDuration)
-	expiresAtStr := expiresAt.Format(time.RFC3339)
+	now := domain.TimestampNow(s.clock)
+	expiresAt := now.Add(format.TTL)
+	params := format.Params()
 
-	mac := auth.ComputeOTPMAC(s.pepper, otp, phoneHash, expiresAtStr)
+	mac := s.peppers.ComputeOTPMAC(otp, phoneHash, otpMACExpiry(expiresAt), params)
 
 	// 5. Store OTP record (conditional put — fails if active OTP exists).
 	record := OTPRecord{
-		PhoneHash: phoneHash,
-		OTPMAC:    mac,
-		Status:    "pending",
-		CreatedAt: now.Format(time.RFC3339),
-		ExpiresAt: expiresAtStr,
-		TTL:       expiresAt.Unix(),
+		PhoneHash:  phoneHash,
+		OTPMAC:     mac,
+		CodeParams: params,
+		Status:     "pending",
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt,
+		TTL:        expiresAt.Time().Unix(),
+		ResendAt:   now.Add(otpResendDelay(0)),
 	}
 
 	if err := s.otpStore.CreateOTP(ctx, record); err != nil {
-		// 6. Active OTP exists — return existing expiry (KMS fallback per ADR-015).
+		// 6. Active OTP exists — resend it once its backoff has passed.
 		if errors.Is(err, domain.ErrAlreadyExists) {
 			existing, getErr := s.otpStore.GetOTP(ctx, phoneHash)
 			if getErr != nil {
@@ -108,27 +145,118 @@ func (s *AuthService) RequestOTP(ctx context.Context, phone, clientIP string) (*
 				span.SetStatus(codes.Error, getErr.Error())
 				return nil, fmt.Errorf("get existing OTP: %w", getErr)
 			}
-			parsedExpiry, parseErr := time.Parse(time.RFC3339, existing.ExpiresAt)
-			if parseErr != nil {
-				span.RecordError(parseErr)
-				span.SetStatus(codes.Error, parseErr.Error())
-				return nil, fmt.Errorf("parse existing OTP expiry: %w", parseErr)
+			result, resendErr := s.resendOTP(ctx, phone, existing)
+			if resendErr != nil {
+				span.RecordError(resendErr)
+				span.SetStatus(codes.Error, resendErr.Error())
+				return nil, resendErr
 			}
-			otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "existing")))
-			return &RequestOTPResult{
-				ExpiresAt:         parsedExpiry,
-				RetryAfterSeconds: otpRetryAfterSeconds,
-			}, nil
+			return result, nil
 		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("create OTP: %w", err)
 	}
 
-	// 7. Background SMS delivery — owned by AuthService via bgWG.
-	// Detach from request context so cancellation of the HTTP request
-	// does not kill the in-flight send. WithoutCancel preserves trace
-	// values for structured logging.
+	// 7. Background SMS delivery.
+	s.sendOTPSMS(ctx, phone, phoneHash, otp)
+
+	otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "success")))
+	logger.InfoContext(ctx, "auth.otp_requested", "phone_hash", phoneHash, "risk", risk.String())
+
+	return &RequestOTPResult{
+		ExpiresAt:         expiresAt.Time(),
+		RetryAfterSeconds: retryAfterSeconds(now, record.ResendAt, expiresAt),
+		CodeLength:        format.Length,
+		CodeAlphabet:      format.Alphabet,
+	}, nil
+}
+
+// ipRateLimit is one client address limit on RequestOTP.
+type ipRateLimit struct {
+	tier  string // "address" or "subnet"
+	key   string
+	limit int
+}
+
+// ipRateLimits returns the limits a request from clientIP counts against:
+// the address itself and, when it parses, its domain.RateLimitSubnet.
+func (s *AuthService) ipRateLimits(clientIP string) []ipRateLimit {
+	limits := []ipRateLimit{{tier: "address", key: "otp_req:ip:" + clientIP, limit: s.ipRateLimit}}
+	if addr, err := netip.ParseAddr(clientIP); err == nil {
+		limits = append(limits, ipRateLimit{
+			tier:  "subnet",
+			key:   "otp_req:subnet:" + domain.RateLimitSubnet(addr).String(),
+			limit: s.subnetRateLimit,
+		})
+	}
+	return limits
+}
+
+// resendOTP answers a request for a phone that already has a live OTP.
+// Until the record's resend time the caller is only told how long to
+// wait. After it, a fresh code with the same expiry, format and attempt
+// count replaces the old one and is sent, and the next wait doubles. The
+// replacement is conditional on the resend count read, so concurrent
+// requests send one SMS between them.
+func (s *AuthService) resendOTP(ctx context.Context, phone string, existing *OTPRecord) (*RequestOTPResult, error) {
+	format, err := issuedFormat(existing)
+	if err != nil {
+		return nil, err
+	}
+	result := &RequestOTPResult{
+		ExpiresAt:    existing.ExpiresAt.Time(),
+		CodeLength:   format.Length,
+		CodeAlphabet: format.Alphabet,
+	}
+
+	now := domain.TimestampNow(s.clock)
+	resendAt := existing.ResendAt
+	if resendAt.IsZero() {
+		resendAt = existing.CreatedAt.Add(otpResendDelay(0))
+	}
+	if now.Before(resendAt) {
+		otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "existing")))
+		result.RetryAfterSeconds = retryAfterSeconds(now, resendAt, existing.ExpiresAt)
+		return result, nil
+	}
+
+	otp, err := auth.GenerateCode(format.Length, format.Alphabet)
+	if err != nil {
+		return nil, fmt.Errorf("generate OTP: %w", err)
+	}
+	params := format.Params()
+	resend := OTPResend{
+		OTPMAC:              s.peppers.ComputeOTPMAC(otp, existing.PhoneHash, otpMACExpiry(existing.ExpiresAt), params),
+		CodeParams:          params,
+		ResendCount:         existing.ResendCount + 1,
+		ResendAt:            now.Add(otpResendDelay(existing.ResendCount + 1)),
+		ExpectedResendCount: existing.ResendCount,
+	}
+	result.RetryAfterSeconds = retryAfterSeconds(now, resend.ResendAt, existing.ExpiresAt)
+
+	if err := s.otpStore.ResendOTP(ctx, existing.PhoneHash, resend); err != nil {
+		if errors.Is(err, domain.ErrVersionConflict) {
+			// A concurrent request resent first, or the code was used.
+			otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "existing")))
+			return result, nil
+		}
+		return nil, fmt.Errorf("resend OTP: %w", err)
+	}
+
+	s.sendOTPSMS(ctx, phone, existing.PhoneHash, otp)
+
+	otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "resent")))
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "auth.otp_resent",
+		"phone_hash", existing.PhoneHash, "resends", resend.ResendCount)
+	return result, nil
+}
+
+// sendOTPSMS delivers otp in the background, owned by AuthService via
+// bgWG. It is detached from the request context so cancellation of the
+// HTTP request does not kill the in-flight send; WithoutCancel preserves
+// trace values for structured logging.
+func (s *AuthService) sendOTPSMS(ctx context.Context, phone, phoneHash, otp string) {
 	smsCtx := context.WithoutCancel(ctx)
 	s.bgWG.Add(1)
 	go func() {
@@ -138,12 +266,42 @@ func (s *AuthService) RequestOTP(ctx context.Context, phone, clientIP string) (*
 				"error", sendErr, "phone_hash", phoneHash)
 		}
 	}()
+}
 
-	otpRequestsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("status", "success")))
-	logger.InfoContext(ctx, "auth.otp_requested", "phone_hash", phoneHash)
+// otpResendDelay is the wait after an OTP has been resent resends times:
+// domain.OTPResendDelay, doubled per resend, at most the longest OTP
+// validity.
+func otpResendDelay(resends int) time.Duration {
+	return min(domain.OTPResendDelay<<min(resends, 8), domain.OTPMaxValidity)
+}
 
-	return &RequestOTPResult{
-		ExpiresAt:         expiresAt,
-		RetryAfterSeconds: otpRetryAfterSeconds,
-	}, nil
+// retryAfterSeconds is the whole seconds from now until the next resend,
+// or until the OTP expires if that comes first: past expiry a request
+// issues a new code straight away.
+func retryAfterSeconds(now, resendAt, expiresAt domain.TimestampMS) int {
+	if expiresAt.Before(resendAt) {
+		resendAt = expiresAt
+	}
+	wait := resendAt.Sub(now)
+	if wait <= 0 {
+		return 0
+	}
+	return int((wait + time.Second - 1) / time.Second)
+}
+
+// otpMACExpiry is the expiry an OTP MAC binds: whole-second RFC 3339, as
+// stored before record timestamps carried milliseconds, so codes issued
+// across the change still verify.
+func otpMACExpiry(expiresAt domain.TimestampMS) string {
+	return expiresAt.Time().Format(time.RFC3339)
+}
+
+// issuedFormat returns the code format an OTP record was issued with.
+// Records from before code policies hold 6-digit codes.
+func issuedFormat(r *OTPRecord) (domain.OTPCodeFormat, error) {
+	if r.CodeParams == "" {
+		var legacy *domain.OTPPolicy
+		return legacy.Format(domain.OTPRiskStandard), nil
+	}
+	return domain.ParseOTPCodeFormat(r.CodeParams)
 }
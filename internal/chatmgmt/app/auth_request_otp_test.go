@@ -3,6 +3,7 @@ package app_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -43,7 +44,7 @@ func TestRequestOTP(t *testing.T) {
 
 		expectedExpiry := testStart.Add(domain.OTPValidityDuration)
 		assert.Equal(t, expectedExpiry, result.ExpiresAt)
-		assert.Equal(t, 60, result.RetryAfterSeconds)
+		assert.Equal(t, 30, result.RetryAfterSeconds)
 	})
 
 	t.Run("SMS goroutine survives request context cancellation", func(t *testing.T) {
@@ -83,6 +84,67 @@ func TestRequestOTP(t *testing.T) {
 		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
 	})
 
+	t.Run("formatted phone: normalized before hashing and SMS", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		var stored app.OTPRecord
+		h.otpStore.createOTPFn = func(_ context.Context, record app.OTPRecord) error {
+			stored = record
+			return nil
+		}
+		smsTo := make(chan string, 1)
+		h.smsProvider.sendOTPFn = func(_ context.Context, phone, _ string) error {
+			smsTo <- phone
+			return nil
+		}
+
+		_, err := h.svc.RequestOTP(context.Background(), "+1 (555) 123-4567", clientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+
+		assert.Equal(t, validPhoneHash, stored.PhoneHash)
+		assert.Equal(t, validPhone, <-smsTo)
+	})
+
+	t.Run("phone policy: denied region returns ErrInvalidPhoneNumber", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.phonePolicy = domain.NewPhonePolicy(domain.PhonePolicyConfig{Deny: []string{"US"}})
+		h.svc = h.newService(0)
+
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
+	})
+
+	t.Run("phone policy: fixed-line number returns ErrInvalidPhoneNumber", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.phonePolicy = domain.NewPhonePolicy(domain.PhonePolicyConfig{})
+		h.svc = h.newService(0)
+
+		_, err := h.svc.RequestOTP(context.Background(), "+442071838750", clientIP)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
+	})
+
+	t.Run("blocked client address: returns ErrForbidden before rate limits", func(t *testing.T) {
+		h := newTestHarness(t)
+		var screened string
+		h.ipScreener = ipScreenerFunc(func(_ context.Context, surface, ip string) error {
+			screened = surface + " " + ip
+			return fmt.Errorf("client country KP is blocked: %w", domain.ErrForbidden)
+		})
+		h.svc = h.newService(0)
+		h.rateLimiter.checkAndIncrementFn = func(context.Context, string, int, int) (bool, error) {
+			t.Fatal("rate limit budget spent on a blocked address")
+			return false, nil
+		}
+
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.Equal(t, "request_otp "+clientIP, screened)
+	})
+
 	t.Run("phone rate limited: returns ErrPhoneRateLimited", func(t *testing.T) {
 		h := newTestHarness(t)
 		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
@@ -126,24 +188,80 @@ func TestRequestOTP(t *testing.T) {
 		h.svc.Wait()
 	})
 
-	t.Run("active OTP exists: returns existing expiry", func(t *testing.T) {
+	t.Run("rate limits: number, address and subnet tiers checked with domain defaults", func(t *testing.T) {
 		h := newTestHarness(t)
-		existingExpiry := testStart.Add(3 * time.Minute)
+		limits := map[string]int{}
+		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, limit, _ int) (bool, error) {
+			limits[key] = limit
+			return true, nil
+		}
 
-		h.otpStore.createOTPFn = func(_ context.Context, _ app.OTPRecord) error {
-			return domain.ErrAlreadyExists
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+		assert.Equal(t, map[string]int{
+			"otp_req:phone:" + validPhoneHash: domain.OTPRequestRateLimitPerPhone,
+			"otp_req:ip:" + clientIP:          domain.OTPRequestRateLimitPerIP,
+			"otp_req:subnet:192.168.1.0/24":   domain.OTPRequestRateLimitPerSubnet,
+		}, limits)
+	})
+
+	t.Run("rate limits: configured address and subnet limits override defaults", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.ipRateLimit = 5
+		h.subnetRateLimit = 50
+		h.svc = h.newService(0)
+		limits := map[string]int{}
+		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, limit, _ int) (bool, error) {
+			limits[key] = limit
+			return true, nil
+		}
+
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, "2001:db8:1:2:3:4:5:6")
+		require.NoError(t, err)
+		h.svc.Wait()
+		assert.Equal(t, 5, limits["otp_req:ip:2001:db8:1:2:3:4:5:6"])
+		assert.Equal(t, 50, limits["otp_req:subnet:2001:db8:1:2::/64"])
+	})
+
+	t.Run("subnet rate limited: returns ErrIPRateLimited", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
+			return key != "otp_req:subnet:192.168.1.0/24", nil
 		}
-		h.otpStore.getOTPFn = func(_ context.Context, _ string) (*app.OTPRecord, error) {
-			return &app.OTPRecord{
-				ExpiresAt: existingExpiry.Format(time.RFC3339),
-			}, nil
+
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrIPRateLimited)
+	})
+
+	t.Run("subnet rate limit Redis failure: proceeds (fail-open)", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
+			if key == "otp_req:subnet:192.168.1.0/24" {
+				return false, errors.New("redis connection refused")
+			}
+			return true, nil
 		}
 
 		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
-		assert.Equal(t, existingExpiry, result.ExpiresAt)
-		assert.Equal(t, 60, result.RetryAfterSeconds)
+		h.svc.Wait()
+	})
+
+	t.Run("unparseable client address: subnet check skipped", func(t *testing.T) {
+		h := newTestHarness(t)
+		var keys []string
+		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
+			keys = append(keys, key)
+			return true, nil
+		}
+
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, "unknown")
+		require.NoError(t, err)
+		h.svc.Wait()
+		assert.Equal(t, []string{"otp_req:phone:" + validPhoneHash, "otp_req:ip:unknown"}, keys)
 	})
 
 	t.Run("phone rate limit Redis failure: returns ErrUnavailable (fail-closed → 503)", func(t *testing.T) {
@@ -174,3 +292,245 @@ func TestRequestOTP(t *testing.T) {
 		assert.Contains(t, err.Error(), "create OTP")
 	})
 }
+
+// ipScreenerFunc adapts a function to app.IPScreener.
+type ipScreenerFunc func(ctx context.Context, surface, clientIP string) error
+
+func (fn ipScreenerFunc) Screen(ctx context.Context, surface, clientIP string) error {
+	return fn(ctx, surface, clientIP)
+}
+
+func TestRequestOTP_Honeypot(t *testing.T) {
+	const validPhone = "+15551234567"
+	const clientIP = "192.168.1.1"
+	validPhoneHash := auth.HashPhone(validPhone)
+
+	h := newTestHarness(t)
+	h.honeypot = domain.NewHoneypotPolicy(domain.HoneypotPolicyConfig{Prefixes: []string{validPhone[:6]}})
+	h.svc = h.newService(0)
+	h.otpStore.createOTPFn = func(context.Context, app.OTPRecord) error {
+		t.Fatal("canary request stored an OTP record")
+		return nil
+	}
+	h.smsProvider.sendOTPFn = func(context.Context, string, string) error {
+		t.Fatal("canary request sent an SMS")
+		return nil
+	}
+
+	result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+
+	require.NoError(t, err)
+	assert.Equal(t, h.clock.Now().Add(domain.OTPValidityDuration), result.ExpiresAt)
+	assert.Positive(t, result.RetryAfterSeconds)
+	require.Len(t, h.canaries.events, 1)
+	ev := h.canaries.events[0]
+	assert.Equal(t, app.CanaryIssued, ev.Kind)
+	assert.Equal(t, "prefix", ev.Rule)
+	assert.Equal(t, validPhoneHash, ev.PhoneHash)
+	assert.Equal(t, clientIP, ev.ClientIP)
+}
+
+func TestRequestOTP_CodePolicy(t *testing.T) {
+	const clientIP = "192.168.1.1"
+
+	t.Run("high-risk region gets the high format bound into the MAC", func(t *testing.T) {
+		h := newTestHarness(t)
+		var err error
+		h.otpPolicy, err = domain.NewOTPPolicy(domain.OTPPolicyConfig{HighRegions: []string{"GB"}})
+		require.NoError(t, err)
+		h.svc = h.newService(0)
+		var stored app.OTPRecord
+		h.otpStore.createOTPFn = func(_ context.Context, record app.OTPRecord) error {
+			stored = record
+			return nil
+		}
+		sent := make(chan string, 1)
+		h.smsProvider.sendOTPFn = func(_ context.Context, _, otp string) error {
+			sent <- otp
+			return nil
+		}
+
+		result, err := h.svc.RequestOTP(context.Background(), "+447911123456", clientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+
+		high := h.otpPolicy.Format(domain.OTPRiskHigh)
+		assert.Equal(t, high.Length, result.CodeLength)
+		assert.Equal(t, high.Alphabet, result.CodeAlphabet)
+		assert.Equal(t, h.clock.Now().Add(high.TTL), result.ExpiresAt)
+		assert.Equal(t, high.Params(), stored.CodeParams)
+		otp := <-sent
+		assert.Len(t, otp, high.Length)
+		assert.Equal(t, auth.ComputePolicyOTPMAC(testPepper, otp, stored.PhoneHash, stored.ExpiresAt.Time().Format(time.RFC3339), high.Params()), stored.OTPMAC)
+	})
+
+	t.Run("existing legacy OTP reports the 6-digit format", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.otpStore.createOTPFn = func(context.Context, app.OTPRecord) error {
+			return domain.ErrAlreadyExists
+		}
+		h.otpStore.getOTPFn = func(_ context.Context, phoneHash string) (*app.OTPRecord, error) {
+			return sampleOTPRecord(phoneHash, h.clock), nil
+		}
+
+		result, err := h.svc.RequestOTP(context.Background(), "+15551234567", clientIP)
+
+		require.NoError(t, err)
+		assert.Equal(t, 6, result.CodeLength)
+		assert.Equal(t, domain.OTPAlphabetDigits, result.CodeAlphabet)
+	})
+}
+
+func TestRequestOTP_Resend(t *testing.T) {
+	const validPhone = "+15551234567"
+	const clientIP = "192.168.1.1"
+
+	// pending is a live OTP issued at testStart and resent resends times,
+	// the last time at lastSend.
+	pending := func(h *testHarness, resends int, lastSend time.Time) *app.OTPRecord {
+		r := sampleOTPRecord(auth.HashPhone(validPhone), h.clock)
+		r.ResendCount = resends
+		r.ResendAt = domain.NewTimestampMS(lastSend).Add(domain.OTPResendDelay << resends)
+		return r
+	}
+	// activeOTP makes RequestOTP find record instead of creating one.
+	activeOTP := func(h *testHarness, record *app.OTPRecord) {
+		h.otpStore.createOTPFn = func(context.Context, app.OTPRecord) error {
+			return domain.ErrAlreadyExists
+		}
+		h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) {
+			return record, nil
+		}
+	}
+
+	t.Run("before the backoff: remaining wait, nothing sent", func(t *testing.T) {
+		h := newTestHarness(t)
+		record := pending(h, 0, testStart)
+		activeOTP(h, record)
+		h.clock.Advance(10 * time.Second)
+		h.otpStore.resendOTPFn = func(context.Context, string, app.OTPResend) error {
+			t.Fatal("resent within the backoff")
+			return nil
+		}
+		h.smsProvider.sendOTPFn = func(context.Context, string, string) error {
+			t.Fatal("SMS sent within the backoff")
+			return nil
+		}
+
+		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+
+		require.NoError(t, err)
+		assert.Equal(t, record.ExpiresAt.Time(), result.ExpiresAt)
+		assert.Equal(t, 20, result.RetryAfterSeconds)
+	})
+
+	t.Run("after the backoff: new code with the same expiry, doubled wait", func(t *testing.T) {
+		h := newTestHarness(t)
+		record := pending(h, 0, testStart)
+		activeOTP(h, record)
+		h.clock.Advance(40 * time.Second)
+		var resend app.OTPResend
+		h.otpStore.resendOTPFn = func(_ context.Context, phoneHash string, r app.OTPResend) error {
+			assert.Equal(t, record.PhoneHash, phoneHash)
+			resend = r
+			return nil
+		}
+		sent := make(chan string, 1)
+		h.smsProvider.sendOTPFn = func(_ context.Context, phone, otp string) error {
+			assert.Equal(t, validPhone, phone)
+			sent <- otp
+			return nil
+		}
+
+		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+
+		assert.Equal(t, record.ExpiresAt.Time(), result.ExpiresAt)
+		assert.Equal(t, 60, result.RetryAfterSeconds)
+		assert.Equal(t, 0, resend.ExpectedResendCount)
+		assert.Equal(t, 1, resend.ResendCount)
+		assert.Equal(t, domain.NewTimestampMS(h.clock.Now().Add(time.Minute)), resend.ResendAt)
+		otp := <-sent
+		assert.True(t, testPeppers.VerifyOTPMAC(otp, record.PhoneHash, record.ExpiresAt.Time().Format(time.RFC3339), resend.CodeParams, resend.OTPMAC),
+			"the resent code verifies against the unchanged expiry")
+	})
+
+	t.Run("second resend waits 120s", func(t *testing.T) {
+		h := newTestHarness(t)
+		activeOTP(h, pending(h, 1, testStart.Add(30*time.Second)))
+		h.clock.Advance(90 * time.Second)
+		var resend app.OTPResend
+		h.otpStore.resendOTPFn = func(_ context.Context, _ string, r app.OTPResend) error {
+			resend = r
+			return nil
+		}
+
+		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+
+		assert.Equal(t, 120, result.RetryAfterSeconds)
+		assert.Equal(t, 1, resend.ExpectedResendCount)
+		assert.Equal(t, 2, resend.ResendCount)
+	})
+
+	t.Run("wait is capped at the expiry", func(t *testing.T) {
+		h := newTestHarness(t)
+		activeOTP(h, pending(h, 2, testStart.Add(90*time.Second)))
+		h.clock.Advance(4 * time.Minute)
+
+		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+
+		assert.Equal(t, 60, result.RetryAfterSeconds, "a new code can be requested once this one expires")
+	})
+
+	t.Run("concurrent resend won: nothing sent", func(t *testing.T) {
+		h := newTestHarness(t)
+		activeOTP(h, pending(h, 0, testStart))
+		h.clock.Advance(time.Minute)
+		h.otpStore.resendOTPFn = func(context.Context, string, app.OTPResend) error {
+			return fmt.Errorf("otp store: %w", domain.ErrVersionConflict)
+		}
+		h.smsProvider.sendOTPFn = func(context.Context, string, string) error {
+			t.Fatal("SMS sent after losing the resend")
+			return nil
+		}
+
+		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+
+		require.NoError(t, err)
+		assert.Equal(t, 60, result.RetryAfterSeconds)
+	})
+
+	t.Run("resend store failure: error, nothing sent", func(t *testing.T) {
+		h := newTestHarness(t)
+		activeOTP(h, pending(h, 0, testStart))
+		h.clock.Advance(time.Minute)
+		h.otpStore.resendOTPFn = func(context.Context, string, app.OTPResend) error {
+			return errors.New("dynamodb timeout")
+		}
+		h.smsProvider.sendOTPFn = func(context.Context, string, string) error {
+			t.Fatal("SMS sent for an unstored code")
+			return nil
+		}
+
+		_, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+
+		assert.ErrorContains(t, err, "resend OTP")
+	})
+
+	t.Run("record from before backoff: resendable 30s after creation", func(t *testing.T) {
+		h := newTestHarness(t)
+		record := sampleOTPRecord(auth.HashPhone(validPhone), h.clock)
+		activeOTP(h, record)
+		h.clock.Advance(5 * time.Second)
+
+		result, err := h.svc.RequestOTP(context.Background(), validPhone, clientIP)
+
+		require.NoError(t, err)
+		assert.Equal(t, 25, result.RetryAfterSeconds)
+	})
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
 	"sync"
@@ -22,6 +23,7 @@ var (
 	authFailuresTotal       metric.Int64Counter
 	rateLimitsTotal         metric.Int64Counter
 	sessionRevocationsTotal metric.Int64Counter
+	honeypotEventsTotal     metric.Int64Counter
 )
 
 func init() {
@@ -39,6 +41,8 @@ func init() {
 		metric.WithDescription("Total rate limit hits"))
 	sessionRevocationsTotal, _ = m.Int64Counter("security_session_revocations_total",
 		metric.WithDescription("Total session revocations"))
+	honeypotEventsTotal, _ = m.Int64Counter("security_honeypot_events_total",
+		metric.WithDescription("Canary OTP flows issued and verification attempts against them, by kind and rule"))
 }
 
 // OTPRecord represents an OTP request stored in the OTP table.
@@ -47,11 +51,30 @@ type OTPRecord struct {
 	PhoneHash     string
 	OTPMAC        string
 	OTPCiphertext string
-	CreatedAt     string
-	ExpiresAt     string
+	CodeParams    string // domain.OTPCodeFormat.Params; empty for codes issued before code policies
+	CreatedAt     domain.TimestampMS
+	ExpiresAt     domain.TimestampMS
 	Status        string
 	AttemptCount  int
 	TTL           int64
+
+	// ResendCount is how many times the code was resent; ResendAt is the
+	// earliest the next resend may go out. Zero on records from before
+	// resend backoff.
+	ResendCount int
+	ResendAt    domain.TimestampMS
+}
+
+// OTPResend replaces the code of a pending OTP that is sent again.
+type OTPResend struct {
+	OTPMAC      string
+	CodeParams  string
+	ResendCount int
+	ResendAt    domain.TimestampMS
+
+	// ExpectedResendCount is the resend count the resend was computed
+	// from. The update applies only while the record is still at it.
+	ExpectedResendCount int
 }
 
 // UserRecord represents a user stored in the users table.
@@ -59,8 +82,12 @@ type UserRecord struct {
 	UserID      string
 	PhoneNumber string
 	DisplayName string
-	CreatedAt   string
-	UpdatedAt   string
+	CreatedAt   domain.TimestampMS
+	UpdatedAt   domain.TimestampMS
+	// FlagReason and FlaggedAt are set while support has the user flagged
+	// for review (see UserDirectory).
+	FlagReason string
+	FlaggedAt  domain.TimestampMS
 }
 
 // SessionRecord represents an active session stored in the sessions table.
@@ -68,10 +95,13 @@ type SessionRecord struct {
 	SessionID        string
 	UserID           string
 	DeviceID         string
+	FamilyID         string // refresh token family; empty on sessions from before lineage tracking
 	RefreshTokenHash string
 	PrevTokenHash    string
-	CreatedAt        string
-	ExpiresAt        string
+	CreatedAt        domain.TimestampMS
+	ExpiresAt        domain.TimestampMS
+	LastActiveAt     domain.TimestampMS // zero until the first refresh or Gateway activity report
+	MaxExpiresAt     domain.TimestampMS // zero unless an SSO policy caps the session; refreshes never extend past it
 	TokenGeneration  int64
 	TTL              int64
 }
@@ -80,48 +110,68 @@ type SessionRecord struct {
 type SessionUpdate struct {
 	RefreshTokenHash string
 	PrevTokenHash    string
-	ExpiresAt        string
+	ExpiresAt        domain.TimestampMS
+	LastActiveAt     domain.TimestampMS
 	TokenGeneration  int64
 	TTL              int64
+
+	// ExpectedGeneration is the token generation the rotation was
+	// computed from. The update applies only while the session is still
+	// at it.
+	ExpectedGeneration int64
 }
 
 // RegistrationParams holds the inputs for a transactional new-user registration.
 type RegistrationParams struct {
 	PhoneHash    string
-	OTPExpiresAt string
+	OTPExpiresAt domain.TimestampMS
 	OTPMAC       string
 
 	UserID      string
 	PhoneNumber string
-	Now         string
+	Now         domain.TimestampMS
 
 	SessionID        string
+	FamilyID         string
 	DeviceID         string
 	RefreshTokenHash string
-	SessionExpiresAt string
+	SessionExpiresAt domain.TimestampMS
 	SessionTTL       int64
 }
 
 // LoginParams holds the inputs for a transactional existing-user login.
 type LoginParams struct {
 	PhoneHash    string
-	OTPExpiresAt string
+	OTPExpiresAt domain.TimestampMS
 	OTPMAC       string
 
 	SessionID        string
+	FamilyID         string
 	UserID           string
 	DeviceID         string
 	RefreshTokenHash string
-	CreatedAt        string
-	SessionExpiresAt string
+	CreatedAt        domain.TimestampMS
+	SessionExpiresAt domain.TimestampMS
 	SessionTTL       int64
+
+	// EvictSessionIDs are the user's sessions the login replaces, deleted
+	// in the same transaction.
+	EvictSessionIDs []string
 }
 
 // OTPStore persists and retrieves OTP requests.
 type OTPStore interface {
 	CreateOTP(ctx context.Context, record OTPRecord) error
 	GetOTP(ctx context.Context, phoneHash string) (*OTPRecord, error)
-	IncrementAttempts(ctx context.Context, phoneHash string) error
+	// ResendOTP stores a resent code on the pending, unexpired OTP,
+	// keeping its expiry and attempt count. It returns
+	// domain.ErrVersionConflict if the record is no longer pending at
+	// resend.ExpectedResendCount.
+	ResendOTP(ctx context.Context, phoneHash string, resend OTPResend) error
+	// IncrementAttempts counts a failed verification and returns the new
+	// count. It counts only while fewer than limit have been made,
+	// returning domain.ErrRateLimited once limit is reached.
+	IncrementAttempts(ctx context.Context, phoneHash string, limit int) (int, error)
 }
 
 // UserStore persists and retrieves user records.
@@ -135,33 +185,110 @@ type SessionStore interface {
 	Create(ctx context.Context, session SessionRecord) error
 	GetByID(ctx context.Context, sessionID string) (*SessionRecord, error)
 	ListByUser(ctx context.Context, userID string) ([]SessionRecord, error)
+	// GetByUserAndDevice returns the user's sessions on deviceID in a
+	// single indexed query.
+	GetByUserAndDevice(ctx context.Context, userID, deviceID string) ([]SessionRecord, error)
+	// Update applies a rotation, returning domain.ErrVersionConflict if
+	// the session is gone or no longer at update.ExpectedGeneration.
 	Update(ctx context.Context, sessionID string, update SessionUpdate) error
 	Delete(ctx context.Context, sessionID string) error
+	// ListIdle returns the sessions last active before cutoff. Sessions
+	// never reported active count from their creation.
+	ListIdle(ctx context.Context, cutoff domain.TimestampMS) ([]SessionRecord, error)
+	// DeleteIdle deletes the session only if it is still idle at cutoff,
+	// returning domain.ErrVersionConflict otherwise.
+	DeleteIdle(ctx context.Context, sessionID string, cutoff domain.TimestampMS) error
 }
 
 // AuthTransactor executes multi-item DynamoDB transactions for auth flows.
+// Sessions a login evicts are deleted only if they still belong to the
+// user; if one has gone, the transaction fails with
+// domain.ErrVersionConflict and nothing is written.
 type AuthTransactor interface {
 	VerifyOTPAndCreateUser(ctx context.Context, params RegistrationParams) error
 	VerifyOTPAndCreateSession(ctx context.Context, params LoginParams) error
+	// EvictAndCreateSession deletes the evicted sessions and creates
+	// session together.
+	EvictAndCreateSession(ctx context.Context, session SessionRecord, evictSessionIDs []string) error
 }
 
 // RateLimiter checks and enforces rate limits.
 type RateLimiter interface {
 	CheckAndIncrement(ctx context.Context, key string, limit, windowSeconds int) (bool, error)
-	CheckLockout(ctx context.Context, key string) (bool, error)
-	SetLockout(ctx context.Context, key string, ttlSeconds int) error
+}
+
+// OTPVerifyAdmission is OTPVerifyLimiter's decision on a verification attempt.
+type OTPVerifyAdmission int
+
+const (
+	OTPVerifyAdmitted    OTPVerifyAdmission = iota
+	OTPVerifyRateLimited                    // limit attempts already made in the window
+	OTPVerifyLockedOut                      // the phone is locked out
+)
+
+// OTPVerifyLimiter keeps a phone's OTP verification attempt count and
+// lockout together, so checking both and counting the attempt is one
+// atomic step.
+type OTPVerifyLimiter interface {
+	// Admit refuses the attempt if the phone is locked out or has made
+	// limit attempts within the window, and otherwise counts it.
+	Admit(ctx context.Context, phoneHash string, limit, windowSeconds int) (OTPVerifyAdmission, error)
+	// Lock locks the phone out of verification for ttlSeconds.
+	Lock(ctx context.Context, phoneHash string, ttlSeconds int) error
 }
 
 // RevocationStore tracks revoked JTIs for token invalidation.
 type RevocationStore interface {
-	Revoke(ctx context.Context, jti string) error
+	// Revoke revokes the token jti until r.ExpiresAt.
+	Revoke(ctx context.Context, jti string, r Revocation) error
 	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeAllForUser revokes every token issued to userID up to
+	// r.RevokedAt, until r.ExpiresAt.
+	RevokeAllForUser(ctx context.Context, userID string, r Revocation) error
+	// IsUserRevoked reports whether a token issued to userID at issuedAt
+	// falls under a RevokeAllForUser.
+	IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error)
+}
+
+// Revocation records why tokens were revoked, and when. ExpiresAt is when
+// the last token it covers expires, after which it can be forgotten.
+type Revocation struct {
+	Reason    string
+	RevokedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SessionRevocationPublisher tells Gateways that a user's sessions were
+// revoked, so they close those sessions' connections and drop cached
+// tokens instead of waiting for their next revocation check.
+type SessionRevocationPublisher interface {
+	PublishSessionsRevoked(ctx context.Context, userID string, sessionIDs []string, reason string) error
+}
+
+// PushTokenStore persists the push token each device registered.
+type PushTokenStore interface {
+	// PutPushToken creates or replaces the device's token.
+	PutPushToken(ctx context.Context, token domain.DeviceToken) error
+}
+
+// IPScreener refuses requests from blocked or disreputable client addresses
+// with an error wrapping domain.ErrForbidden.
+type IPScreener interface {
+	Screen(ctx context.Context, surface, clientIP string) error
+}
+
+// SessionAnalytics records product analytics for sign-ins. It must not
+// block; analytics.Emitter queues and drops instead.
+type SessionAnalytics interface {
+	SessionStarted(ctx context.Context, userID string, newUser bool)
 }
 
 // RequestOTPResult is returned by RequestOTP on success.
 type RequestOTPResult struct {
 	ExpiresAt         time.Time
 	RetryAfterSeconds int
+	CodeLength        int
+	CodeAlphabet      string
 }
 
 // VerifyOTPResult is returned by VerifyOTP on success.
@@ -188,49 +315,165 @@ type AuthServiceConfig struct {
 	SessionStore    SessionStore
 	Transactor      AuthTransactor
 	RateLimiter     RateLimiter
+	VerifyLimiter   OTPVerifyLimiter
 	RevocationStore RevocationStore
+	PushTokenStore  PushTokenStore
 	SMSProvider     auth.SMSProvider
 	Minter          *auth.Minter
 	Validator       *auth.Validator
 	Clock           domain.Clock
-	Pepper          []byte
+	Peppers         *auth.PepperRing
 	Logger          *slog.Logger
+
+	// RefreshTTL bounds session lifetime. Zero defaults to
+	// domain.RefreshTokenLifetime.
+	RefreshTTL time.Duration
+
+	// RefreshHasher hashes refresh tokens for storage. Nil hashes with
+	// SHA-256; either way, hashes of both kinds verify.
+	RefreshHasher *auth.RefreshHasher
+
+	// IdleTimeout revokes sessions with no activity for this long. Zero
+	// defaults to domain.SessionIdleTimeout.
+	IdleTimeout time.Duration
+
+	// IPRateLimit and SubnetRateLimit cap OTP requests per client address
+	// and per client subnet (IPv4 /24, IPv6 /64) in each rate limit
+	// window. Zero defaults to domain.OTPRequestRateLimitPerIP and
+	// domain.OTPRequestRateLimitPerSubnet.
+	IPRateLimit     int
+	SubnetRateLimit int
+
+	// PhonePolicy restricts which numbers may request an OTP. Nil allows
+	// every valid number.
+	PhonePolicy *domain.PhonePolicy
+
+	// OTPPolicy sets the code length, alphabet, and lifetime per request
+	// risk. Nil issues 6-digit codes valid for domain.OTPValidityDuration.
+	OTPPolicy *domain.OTPPolicy
+
+	// IPScreener blocks OTP requests from known-bad address ranges and
+	// countries before any SMS is spent. Nil screens nothing.
+	IPScreener IPScreener
+
+	// HoneypotPolicy selects numbers that get a canary OTP flow. Nil
+	// disables canaries. CanaryRecorder receives every canary event; nil
+	// only logs.
+	HoneypotPolicy *domain.HoneypotPolicy
+	CanaryRecorder CanaryRecorder
+
+	// LineageStore records every refresh token generation for forensics.
+	// Nil records nothing.
+	LineageStore TokenLineageStore
+
+	// Analytics receives a pseudonymized session_started event for every
+	// verified OTP. Nil records nothing.
+	Analytics SessionAnalytics
+
+	// SSO and IDTokens enable workspace single sign-on (SSOLogin). Nil
+	// disables it.
+	SSO      SSOStore
+	IDTokens IDTokenVerifier
+
+	// RevocationPublisher pushes bulk session revocations to Gateways. Nil
+	// publishes nothing; Gateways still refuse the revoked tokens at their
+	// next revocation check.
+	RevocationPublisher SessionRevocationPublisher
+
+	// Members reads SCIM-provisioned workspace members. Sign-ins to a
+	// SCIM-managed workspace are refused while it is nil.
+	Members WorkspaceMemberReader
+
+	// Region is the data region this deployment serves. SSO sign-ins and
+	// connection changes for workspaces in another region are refused;
+	// empty disables residency checks.
+	Region domain.DataRegion
 }
 
 // AuthService orchestrates the four auth flows: Request OTP, Verify OTP,
-// Refresh Tokens, and Logout (ADR-015).
+// Refresh Tokens, and Logout (ADR-015), plus workspace SSO login. It also
+// registers the push token of an authenticated session's device.
 type AuthService struct {
 	otpStore        OTPStore
 	userStore       UserStore
 	sessionStore    SessionStore
 	transactor      AuthTransactor
 	rateLimiter     RateLimiter
+	verifyLimiter   OTPVerifyLimiter
 	revocationStore RevocationStore
+	pushTokenStore  PushTokenStore
 	smsProvider     auth.SMSProvider
 	minter          *auth.Minter
 	validator       *auth.Validator
 	clock           domain.Clock
-	pepper          []byte
+	peppers         *auth.PepperRing
 	logger          *slog.Logger
+	refreshTTL      time.Duration
+	refreshHasher   *auth.RefreshHasher
+	idleTimeout     time.Duration
+	ipRateLimit     int
+	subnetRateLimit int
+	phonePolicy     *domain.PhonePolicy
+	otpPolicy       *domain.OTPPolicy
+	ipScreener      IPScreener
+	honeypot        *domain.HoneypotPolicy
+	canaryRecorder  CanaryRecorder
+	lineage         TokenLineageStore
+	analytics       SessionAnalytics
+	sso             SSOStore
+	idTokens        IDTokenVerifier
+	members         WorkspaceMemberReader
+	revocationPub   SessionRevocationPublisher
+	region          domain.DataRegion
 	bgWG            sync.WaitGroup // owns background goroutines (SMS sends)
 }
 
 // NewAuthService creates a new AuthService with the given dependencies.
 func NewAuthService(cfg AuthServiceConfig) *AuthService {
-	return &AuthService{
+	refreshTTL := cfg.RefreshTTL
+	if refreshTTL == 0 {
+		refreshTTL = domain.RefreshTokenLifetime
+	}
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = domain.SessionIdleTimeout
+	}
+
+	s := &AuthService{
 		otpStore:        cfg.OTPStore,
 		userStore:       cfg.UserStore,
 		sessionStore:    cfg.SessionStore,
 		transactor:      cfg.Transactor,
 		rateLimiter:     cfg.RateLimiter,
+		verifyLimiter:   cfg.VerifyLimiter,
 		revocationStore: cfg.RevocationStore,
+		pushTokenStore:  cfg.PushTokenStore,
 		smsProvider:     cfg.SMSProvider,
 		minter:          cfg.Minter,
 		validator:       cfg.Validator,
 		clock:           cfg.Clock,
-		pepper:          cfg.Pepper,
+		peppers:         cfg.Peppers,
 		logger:          cfg.Logger,
+		refreshTTL:      refreshTTL,
+		refreshHasher:   cfg.RefreshHasher,
+		idleTimeout:     idleTimeout,
+		ipRateLimit:     cmp.Or(cfg.IPRateLimit, domain.OTPRequestRateLimitPerIP),
+		subnetRateLimit: cmp.Or(cfg.SubnetRateLimit, domain.OTPRequestRateLimitPerSubnet),
+		ipScreener:      cfg.IPScreener,
+		canaryRecorder:  cfg.CanaryRecorder,
+		lineage:         cfg.LineageStore,
+		analytics:       cfg.Analytics,
+		sso:             cfg.SSO,
+		idTokens:        cfg.IDTokens,
+		members:         cfg.Members,
+		revocationPub:   cfg.RevocationPublisher,
+		region:          cfg.Region,
+		phonePolicy:     cfg.PhonePolicy,
+		otpPolicy:       cfg.OTPPolicy,
+		honeypot:        cfg.HoneypotPolicy,
 	}
+
+	return s
 }
 
 // Wait blocks until all background goroutines owned by this service complete.
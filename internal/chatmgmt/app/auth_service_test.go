@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"fmt"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,13 +25,26 @@ func TestMain(m *testing.M) {
 
 var testPepper = []byte("test-pepper-32-bytes-long-ok!!")
 
+// testPeppers issues under version 0, the unversioned scheme, with
+// testPepper.
+var testPeppers = mustPepperRing(0, map[int][]byte{0: testPepper})
+
+func mustPepperRing(current int, peppers map[int][]byte) *auth.PepperRing {
+	ring, err := auth.NewPepperRing(current, peppers)
+	if err != nil {
+		panic(err)
+	}
+	return ring
+}
+
 var testStart = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
 
 // stubOTPStore implements app.OTPStore with function fields.
 type stubOTPStore struct {
 	createOTPFn         func(ctx context.Context, record app.OTPRecord) error
 	getOTPFn            func(ctx context.Context, phoneHash string) (*app.OTPRecord, error)
-	incrementAttemptsFn func(ctx context.Context, phoneHash string) error
+	incrementAttemptsFn func(ctx context.Context, phoneHash string, limit int) (int, error)
+	resendOTPFn         func(ctx context.Context, phoneHash string, resend app.OTPResend) error
 }
 
 func (s *stubOTPStore) CreateOTP(ctx context.Context, record app.OTPRecord) error {
@@ -46,13 +61,20 @@ func (s *stubOTPStore) GetOTP(ctx context.Context, phoneHash string) (*app.OTPRe
 	return nil, domain.ErrNotFound
 }
 
-func (s *stubOTPStore) IncrementAttempts(ctx context.Context, phoneHash string) error {
-	if s.incrementAttemptsFn != nil {
-		return s.incrementAttemptsFn(ctx, phoneHash)
+func (s *stubOTPStore) ResendOTP(ctx context.Context, phoneHash string, resend app.OTPResend) error {
+	if s.resendOTPFn != nil {
+		return s.resendOTPFn(ctx, phoneHash, resend)
 	}
 	return nil
 }
 
+func (s *stubOTPStore) IncrementAttempts(ctx context.Context, phoneHash string, limit int) (int, error) {
+	if s.incrementAttemptsFn != nil {
+		return s.incrementAttemptsFn(ctx, phoneHash, limit)
+	}
+	return 1, nil
+}
+
 // stubUserStore implements app.UserStore with function fields.
 type stubUserStore struct {
 	getByIDFn     func(ctx context.Context, userID string) (*app.UserRecord, error)
@@ -75,11 +97,14 @@ func (s *stubUserStore) FindByPhone(ctx context.Context, phone string) (*app.Use
 
 // stubSessionStore implements app.SessionStore with function fields.
 type stubSessionStore struct {
-	createFn     func(ctx context.Context, session app.SessionRecord) error
-	getByIDFn    func(ctx context.Context, sessionID string) (*app.SessionRecord, error)
-	listByUserFn func(ctx context.Context, userID string) ([]app.SessionRecord, error)
-	updateFn     func(ctx context.Context, sessionID string, update app.SessionUpdate) error
-	deleteFn     func(ctx context.Context, sessionID string) error
+	createFn             func(ctx context.Context, session app.SessionRecord) error
+	getByIDFn            func(ctx context.Context, sessionID string) (*app.SessionRecord, error)
+	listByUserFn         func(ctx context.Context, userID string) ([]app.SessionRecord, error)
+	getByUserAndDeviceFn func(ctx context.Context, userID, deviceID string) ([]app.SessionRecord, error)
+	updateFn             func(ctx context.Context, sessionID string, update app.SessionUpdate) error
+	deleteFn             func(ctx context.Context, sessionID string) error
+	listIdleFn           func(ctx context.Context, cutoff domain.TimestampMS) ([]app.SessionRecord, error)
+	deleteIdleFn         func(ctx context.Context, sessionID string, cutoff domain.TimestampMS) error
 }
 
 func (s *stubSessionStore) Create(ctx context.Context, session app.SessionRecord) error {
@@ -103,6 +128,25 @@ func (s *stubSessionStore) ListByUser(ctx context.Context, userID string) ([]app
 	return nil, nil
 }
 
+// GetByUserAndDevice defaults to the ListByUser sessions on deviceID, as
+// the index would return them.
+func (s *stubSessionStore) GetByUserAndDevice(ctx context.Context, userID, deviceID string) ([]app.SessionRecord, error) {
+	if s.getByUserAndDeviceFn != nil {
+		return s.getByUserAndDeviceFn(ctx, userID, deviceID)
+	}
+	sessions, err := s.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out []app.SessionRecord
+	for _, sess := range sessions {
+		if sess.DeviceID == deviceID {
+			out = append(out, sess)
+		}
+	}
+	return out, nil
+}
+
 func (s *stubSessionStore) Update(ctx context.Context, sessionID string, update app.SessionUpdate) error {
 	if s.updateFn != nil {
 		return s.updateFn(ctx, sessionID, update)
@@ -117,10 +161,25 @@ func (s *stubSessionStore) Delete(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+func (s *stubSessionStore) ListIdle(ctx context.Context, cutoff domain.TimestampMS) ([]app.SessionRecord, error) {
+	if s.listIdleFn != nil {
+		return s.listIdleFn(ctx, cutoff)
+	}
+	return nil, nil
+}
+
+func (s *stubSessionStore) DeleteIdle(ctx context.Context, sessionID string, cutoff domain.TimestampMS) error {
+	if s.deleteIdleFn != nil {
+		return s.deleteIdleFn(ctx, sessionID, cutoff)
+	}
+	return nil
+}
+
 // stubTransactor implements app.AuthTransactor with function fields.
 type stubTransactor struct {
 	verifyOTPAndCreateUserFn    func(ctx context.Context, params app.RegistrationParams) error
 	verifyOTPAndCreateSessionFn func(ctx context.Context, params app.LoginParams) error
+	evictAndCreateSessionFn     func(ctx context.Context, session app.SessionRecord, evictSessionIDs []string) error
 }
 
 func (s *stubTransactor) VerifyOTPAndCreateUser(ctx context.Context, params app.RegistrationParams) error {
@@ -137,11 +196,16 @@ func (s *stubTransactor) VerifyOTPAndCreateSession(ctx context.Context, params a
 	return nil
 }
 
+func (s *stubTransactor) EvictAndCreateSession(ctx context.Context, session app.SessionRecord, evictSessionIDs []string) error {
+	if s.evictAndCreateSessionFn != nil {
+		return s.evictAndCreateSessionFn(ctx, session, evictSessionIDs)
+	}
+	return nil
+}
+
 // stubRateLimiter implements app.RateLimiter with function fields.
 type stubRateLimiter struct {
 	checkAndIncrementFn func(ctx context.Context, key string, limit, windowSeconds int) (bool, error)
-	checkLockoutFn      func(ctx context.Context, key string) (bool, error)
-	setLockoutFn        func(ctx context.Context, key string, ttlSeconds int) error
 }
 
 func (s *stubRateLimiter) CheckAndIncrement(ctx context.Context, key string, limit, windowSeconds int) (bool, error) {
@@ -151,29 +215,37 @@ func (s *stubRateLimiter) CheckAndIncrement(ctx context.Context, key string, lim
 	return true, nil
 }
 
-func (s *stubRateLimiter) CheckLockout(ctx context.Context, key string) (bool, error) {
-	if s.checkLockoutFn != nil {
-		return s.checkLockoutFn(ctx, key)
+// stubVerifyLimiter implements app.OTPVerifyLimiter with function fields.
+type stubVerifyLimiter struct {
+	admitFn func(ctx context.Context, phoneHash string, limit, windowSeconds int) (app.OTPVerifyAdmission, error)
+	lockFn  func(ctx context.Context, phoneHash string, ttlSeconds int) error
+}
+
+func (s *stubVerifyLimiter) Admit(ctx context.Context, phoneHash string, limit, windowSeconds int) (app.OTPVerifyAdmission, error) {
+	if s.admitFn != nil {
+		return s.admitFn(ctx, phoneHash, limit, windowSeconds)
 	}
-	return false, nil
+	return app.OTPVerifyAdmitted, nil
 }
 
-func (s *stubRateLimiter) SetLockout(ctx context.Context, key string, ttlSeconds int) error {
-	if s.setLockoutFn != nil {
-		return s.setLockoutFn(ctx, key, ttlSeconds)
+func (s *stubVerifyLimiter) Lock(ctx context.Context, phoneHash string, ttlSeconds int) error {
+	if s.lockFn != nil {
+		return s.lockFn(ctx, phoneHash, ttlSeconds)
 	}
 	return nil
 }
 
 // stubRevocationStore implements app.RevocationStore with function fields.
 type stubRevocationStore struct {
-	revokeFn    func(ctx context.Context, jti string) error
-	isRevokedFn func(ctx context.Context, jti string) (bool, error)
+	revokeFn        func(ctx context.Context, jti string, r app.Revocation) error
+	isRevokedFn     func(ctx context.Context, jti string) (bool, error)
+	revokeAllFn     func(ctx context.Context, userID string, r app.Revocation) error
+	isUserRevokedFn func(ctx context.Context, userID string, issuedAt time.Time) (bool, error)
 }
 
-func (s *stubRevocationStore) Revoke(ctx context.Context, jti string) error {
+func (s *stubRevocationStore) Revoke(ctx context.Context, jti string, r app.Revocation) error {
 	if s.revokeFn != nil {
-		return s.revokeFn(ctx, jti)
+		return s.revokeFn(ctx, jti, r)
 	}
 	return nil
 }
@@ -185,6 +257,32 @@ func (s *stubRevocationStore) IsRevoked(ctx context.Context, jti string) (bool,
 	return false, nil
 }
 
+func (s *stubRevocationStore) RevokeAllForUser(ctx context.Context, userID string, r app.Revocation) error {
+	if s.revokeAllFn != nil {
+		return s.revokeAllFn(ctx, userID, r)
+	}
+	return nil
+}
+
+func (s *stubRevocationStore) IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	if s.isUserRevokedFn != nil {
+		return s.isUserRevokedFn(ctx, userID, issuedAt)
+	}
+	return false, nil
+}
+
+// stubPushTokenStore implements app.PushTokenStore with a function field.
+type stubPushTokenStore struct {
+	putFn func(ctx context.Context, token domain.DeviceToken) error
+}
+
+func (s *stubPushTokenStore) PutPushToken(ctx context.Context, token domain.DeviceToken) error {
+	if s.putFn != nil {
+		return s.putFn(ctx, token)
+	}
+	return nil
+}
+
 // stubSMSProvider implements auth.SMSProvider with a function field.
 type stubSMSProvider struct {
 	sendOTPFn func(ctx context.Context, phone, otp string) error
@@ -197,6 +295,141 @@ func (s *stubSMSProvider) SendOTP(ctx context.Context, phone, otp string) error
 	return nil
 }
 
+type stubCanaryRecorder struct {
+	events []app.CanaryEvent
+}
+
+func (s *stubCanaryRecorder) RecordCanary(_ context.Context, ev app.CanaryEvent) error {
+	s.events = append(s.events, ev)
+	return nil
+}
+
+// stubSessionAnalytics records session_started events as "userID/newUser".
+type stubSessionAnalytics struct {
+	started []string
+}
+
+func (s *stubSessionAnalytics) SessionStarted(_ context.Context, userID string, newUser bool) {
+	s.started = append(s.started, fmt.Sprintf("%s/%t", userID, newUser))
+}
+
+// stubRevocationPublisher records published revocations as
+// "user/reason/session,...". err fails every publish.
+type stubRevocationPublisher struct {
+	published []string
+	err       error
+}
+
+func (s *stubRevocationPublisher) PublishSessionsRevoked(_ context.Context, userID string, sessionIDs []string, reason string) error {
+	s.published = append(s.published, fmt.Sprintf("%s/%s/%s", userID, reason, strings.Join(sessionIDs, ",")))
+	return s.err
+}
+
+type stubLineageStore struct {
+	entries []app.TokenLineageEntry
+	reused  map[int64]app.TokenReuse
+	family  string
+}
+
+func (s *stubLineageStore) Append(_ context.Context, entry app.TokenLineageEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *stubLineageStore) MarkReused(_ context.Context, familyID string, generation int64, reuse app.TokenReuse) error {
+	if s.reused == nil {
+		s.reused = make(map[int64]app.TokenReuse)
+	}
+	s.family = familyID
+	s.reused[generation] = reuse
+	return nil
+}
+
+func (s *stubLineageStore) ListFamily(_ context.Context, familyID string) ([]app.TokenLineageEntry, error) {
+	var out []app.TokenLineageEntry
+	for _, e := range s.entries {
+		if e.FamilyID == familyID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *stubLineageStore) ListByUser(_ context.Context, userID string) ([]app.TokenLineageEntry, error) {
+	var out []app.TokenLineageEntry
+	for _, e := range s.entries {
+		if e.UserID == userID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// stubSSOStore implements app.SSOStore over in-memory maps.
+type stubSSOStore struct {
+	connections map[string]domain.SSOConnection
+	identities  map[string]string // workspaceID/subject → userID
+	provisioned []app.UserRecord
+	provisionFn func(ctx context.Context, workspaceID, subject string, user app.UserRecord) error
+}
+
+func (s *stubSSOStore) GetConnection(_ context.Context, workspaceID string) (domain.SSOConnection, error) {
+	conn, ok := s.connections[workspaceID]
+	if !ok {
+		return domain.SSOConnection{}, domain.ErrNotFound
+	}
+	return conn, nil
+}
+
+func (s *stubSSOStore) PutConnection(_ context.Context, conn domain.SSOConnection) error {
+	if s.connections == nil {
+		s.connections = make(map[string]domain.SSOConnection)
+	}
+	s.connections[conn.WorkspaceID] = conn
+	return nil
+}
+
+func (s *stubSSOStore) FindIdentity(_ context.Context, workspaceID, subject string) (string, error) {
+	userID, ok := s.identities[workspaceID+"/"+subject]
+	if !ok {
+		return "", domain.ErrNotFound
+	}
+	return userID, nil
+}
+
+func (s *stubSSOStore) ProvisionUser(ctx context.Context, workspaceID, subject string, user app.UserRecord) error {
+	if s.provisionFn != nil {
+		return s.provisionFn(ctx, workspaceID, subject, user)
+	}
+	if s.identities == nil {
+		s.identities = make(map[string]string)
+	}
+	s.identities[workspaceID+"/"+subject] = user.UserID
+	s.provisioned = append(s.provisioned, user)
+	return nil
+}
+
+func (s *stubSSOStore) LinkIdentity(_ context.Context, workspaceID, subject, userID string, _ domain.TimestampMS) error {
+	key := workspaceID + "/" + subject
+	if _, ok := s.identities[key]; ok {
+		return domain.ErrAlreadyExists
+	}
+	if s.identities == nil {
+		s.identities = make(map[string]string)
+	}
+	s.identities[key] = userID
+	return nil
+}
+
+// stubIDTokenVerifier implements app.IDTokenVerifier with a function field.
+type stubIDTokenVerifier struct {
+	verifyFn func(ctx context.Context, issuer, clientID, rawToken, nonce string) (*auth.IDTokenClaims, error)
+}
+
+func (s *stubIDTokenVerifier) VerifyIDToken(ctx context.Context, issuer, clientID, rawToken, nonce string) (*auth.IDTokenClaims, error) {
+	return s.verifyFn(ctx, issuer, clientID, rawToken, nonce)
+}
+
 // testHarness holds all stubs and the constructed AuthService for a test.
 type testHarness struct {
 	svc             *app.AuthService
@@ -206,8 +439,26 @@ type testHarness struct {
 	sessionStore    *stubSessionStore
 	transactor      *stubTransactor
 	rateLimiter     *stubRateLimiter
+	verifyLimiter   *stubVerifyLimiter
 	revocationStore *stubRevocationStore
+	pushTokenStore  *stubPushTokenStore
 	smsProvider     *stubSMSProvider
+	ipScreener      app.IPScreener
+	phonePolicy     *domain.PhonePolicy
+	otpPolicy       *domain.OTPPolicy
+	honeypot        *domain.HoneypotPolicy
+	canaries        *stubCanaryRecorder
+	lineage         *stubLineageStore
+	analytics       *stubSessionAnalytics
+	revocationPub   *stubRevocationPublisher
+	sso             *stubSSOStore
+	idTokens        *stubIDTokenVerifier
+	members         *memSCIMStore
+	region          domain.DataRegion
+	ipRateLimit     int
+	subnetRateLimit int
+	peppers         *auth.PepperRing
+	refreshHasher   *auth.RefreshHasher
 	minter          *auth.Minter
 	validator       *auth.Validator
 }
@@ -242,30 +493,64 @@ func newTestHarness(t *testing.T) *testHarness {
 		sessionStore:    &stubSessionStore{},
 		transactor:      &stubTransactor{},
 		rateLimiter:     &stubRateLimiter{},
+		verifyLimiter:   &stubVerifyLimiter{},
 		revocationStore: &stubRevocationStore{},
+		pushTokenStore:  &stubPushTokenStore{},
 		smsProvider:     &stubSMSProvider{},
+		canaries:        &stubCanaryRecorder{},
+		lineage:         &stubLineageStore{},
+		analytics:       &stubSessionAnalytics{},
+		revocationPub:   &stubRevocationPublisher{},
+		sso:             &stubSSOStore{},
+		idTokens:        &stubIDTokenVerifier{},
+		members:         newMemSCIMStore(),
+		peppers:         testPeppers,
 		minter:          minter,
 		validator:       validator,
 	}
 
-	h.svc = app.NewAuthService(app.AuthServiceConfig{
-		OTPStore:        h.otpStore,
-		UserStore:       h.userStore,
-		SessionStore:    h.sessionStore,
-		Transactor:      h.transactor,
-		RateLimiter:     h.rateLimiter,
-		RevocationStore: h.revocationStore,
-		SMSProvider:     h.smsProvider,
-		Minter:          minter,
-		Validator:       validator,
-		Clock:           clock,
-		Pepper:          testPepper,
-		Logger:          slog.Default(),
-	})
+	h.svc = h.newService(0)
 
 	return h
 }
 
+// newService builds an AuthService over the harness stubs. A zero refreshTTL
+// exercises the domain default.
+func (h *testHarness) newService(refreshTTL time.Duration) *app.AuthService {
+	return app.NewAuthService(app.AuthServiceConfig{
+		OTPStore:            h.otpStore,
+		UserStore:           h.userStore,
+		SessionStore:        h.sessionStore,
+		Transactor:          h.transactor,
+		RateLimiter:         h.rateLimiter,
+		VerifyLimiter:       h.verifyLimiter,
+		RevocationStore:     h.revocationStore,
+		PushTokenStore:      h.pushTokenStore,
+		SMSProvider:         h.smsProvider,
+		Minter:              h.minter,
+		Validator:           h.validator,
+		Clock:               h.clock,
+		Peppers:             h.peppers,
+		Logger:              slog.Default(),
+		RefreshTTL:          refreshTTL,
+		RefreshHasher:       h.refreshHasher,
+		IPScreener:          h.ipScreener,
+		PhonePolicy:         h.phonePolicy,
+		OTPPolicy:           h.otpPolicy,
+		HoneypotPolicy:      h.honeypot,
+		CanaryRecorder:      h.canaries,
+		LineageStore:        h.lineage,
+		Analytics:           h.analytics,
+		SSO:                 h.sso,
+		IDTokens:            h.idTokens,
+		Members:             h.members,
+		Region:              h.region,
+		RevocationPublisher: h.revocationPub,
+		IPRateLimit:         h.ipRateLimit,
+		SubnetRateLimit:     h.subnetRateLimit,
+	})
+}
+
 // sampleOTPRecord returns a valid pending OTP record for testing.
 func sampleOTPRecord(phoneHash string, clock *domaintest.FakeClock) *app.OTPRecord {
 	now := clock.Now().UTC()
@@ -276,8 +561,8 @@ func sampleOTPRecord(phoneHash string, clock *domaintest.FakeClock) *app.OTPReco
 		PhoneHash: phoneHash,
 		OTPMAC:    mac,
 		Status:    "pending",
-		CreatedAt: now.Format(time.RFC3339),
-		ExpiresAt: expiresAt.Format(time.RFC3339),
+		CreatedAt: domain.NewTimestampMS(now),
+		ExpiresAt: domain.NewTimestampMS(expiresAt),
 		TTL:       expiresAt.Unix(),
 	}
 }
@@ -288,8 +573,8 @@ func sampleUserRecord() *app.UserRecord {
 		UserID:      "user-existing-001",
 		PhoneNumber: "+15551234567",
 		DisplayName: "",
-		CreatedAt:   testStart.Add(-24 * time.Hour).Format(time.RFC3339),
-		UpdatedAt:   testStart.Add(-24 * time.Hour).Format(time.RFC3339),
+		CreatedAt:   domain.NewTimestampMS(testStart.Add(-24 * time.Hour)),
+		UpdatedAt:   domain.NewTimestampMS(testStart.Add(-24 * time.Hour)),
 	}
 }
 
@@ -303,8 +588,8 @@ func sampleSessionRecord(userID, sessionID, deviceID, refreshHash string, clock
 		DeviceID:         deviceID,
 		RefreshTokenHash: refreshHash,
 		TokenGeneration:  1,
-		CreatedAt:        now.Format(time.RFC3339),
-		ExpiresAt:        expiry.Format(time.RFC3339),
+		CreatedAt:        domain.NewTimestampMS(now),
+		ExpiresAt:        domain.NewTimestampMS(expiry),
 		TTL:              expiry.Unix(),
 	}
 }
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// DeviceSession is one signed-in device as shown to its user.
+type DeviceSession struct {
+	SessionID    string
+	DeviceID     string
+	CreatedAt    time.Time
+	LastActiveAt time.Time
+	// IdleExpiresAt is when the session is revoked if it stays unused.
+	IdleExpiresAt time.Time
+	// Current marks the session the request was made with.
+	Current bool
+}
+
+// ListDevices returns the caller's signed-in devices, most recently active
+// first. Sessions already past the idle timeout are omitted even if the
+// sweeper has not yet removed them.
+func (s *AuthService) ListDevices(ctx context.Context, accessToken string) ([]DeviceSession, error) {
+	ctx, span := tracer.Start(ctx, "auth.list_devices")
+	defer span.End()
+
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	sessions, err := s.sessionStore.ListByUser(ctx, claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	now := s.clock.Now().UTC()
+	devices := make([]DeviceSession, 0, len(sessions))
+	for _, session := range sessions {
+		lastActive := lastActiveAt(session).Time()
+		idleExpiry := lastActive.Add(s.idleTimeout)
+		if !idleExpiry.After(now) {
+			continue
+		}
+		devices = append(devices, DeviceSession{
+			SessionID:     session.SessionID,
+			DeviceID:      session.DeviceID,
+			CreatedAt:     session.CreatedAt.Time(),
+			LastActiveAt:  lastActive,
+			IdleExpiresAt: idleExpiry,
+			Current:       session.SessionID == claims.SessionID,
+		})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].LastActiveAt.After(devices[j].LastActiveAt) })
+	return devices, nil
+}
+
+// SweepIdleSessions revokes every session unused for the idle timeout and
+// returns how many it removed. A session that turns active between the
+// scan and its delete is kept. Concurrent sweeps are safe: each delete is
+// conditional, so a session is only ever counted by one of them.
+func (s *AuthService) SweepIdleSessions(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "auth.sweep_idle_sessions")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	cutoff := domain.TimestampNow(s.clock).Add(-s.idleTimeout)
+
+	idle, err := s.sessionStore.ListIdle(ctx, cutoff)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("list idle sessions: %w", err)
+	}
+
+	var removed int
+	for _, session := range idle {
+		if err := s.sessionStore.DeleteIdle(ctx, session.SessionID, cutoff); err != nil {
+			if errors.Is(err, domain.ErrVersionConflict) {
+				continue
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return removed, fmt.Errorf("delete idle session: %w", err)
+		}
+		removed++
+		sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "idle")))
+		logger.InfoContext(ctx, "auth.session_idle_expired",
+			"user_id", session.UserID,
+			"session_id", session.SessionID,
+			"last_active_at", lastActiveAt(session).String(),
+		)
+	}
+	span.SetAttributes(attribute.Int("sessions.removed", removed))
+	return removed, nil
+}
+
+// RevokeUserSessions revokes every access token of userID and deletes
+// every session, as when the user's workspace deprovisions them or they
+// log out everywhere, and returns how many sessions it deleted. reason is
+// recorded with the revocation and labels the revocations metric. The
+// deleted sessions are published to Gateways. It stops at the first
+// failure; calling it again revokes whatever is left.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID, reason string) (int, error) {
+	ctx, span := tracer.Start(ctx, "auth.revoke_user_sessions")
+	defer span.End()
+
+	// Revoke first, so that no token outlives a partial failure below.
+	now := s.clock.Now().UTC()
+	err := s.revocationStore.RevokeAllForUser(ctx, userID, Revocation{
+		Reason:    reason,
+		RevokedAt: now,
+		ExpiresAt: now.Add(s.minter.AccessTTL()),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("revoke user tokens: %w", err)
+	}
+
+	sessions, err := s.sessionStore.ListByUser(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("list sessions: %w", err)
+	}
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	revoked := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if err := s.sessionStore.Delete(ctx, session.SessionID); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.publishSessionsRevoked(ctx, userID, revoked, reason)
+			return len(revoked), fmt.Errorf("delete session: %w", err)
+		}
+		revoked = append(revoked, session.SessionID)
+		sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+		logger.InfoContext(ctx, "auth.session_revoked",
+			"user_id", userID,
+			"session_id", session.SessionID,
+			"reason", reason,
+		)
+	}
+	s.publishSessionsRevoked(ctx, userID, revoked, reason)
+	span.SetAttributes(attribute.Int("sessions.removed", len(revoked)))
+	return len(revoked), nil
+}
+
+// publishSessionsRevoked tells Gateways about sessionIDs, if a publisher is
+// configured. The revocation store already refuses the sessions' tokens,
+// so a failure is only logged.
+func (s *AuthService) publishSessionsRevoked(ctx context.Context, userID string, sessionIDs []string, reason string) {
+	if s.revocationPub == nil || len(sessionIDs) == 0 {
+		return
+	}
+	if err := s.revocationPub.PublishSessionsRevoked(ctx, userID, sessionIDs, reason); err != nil {
+		observability.WithTraceID(ctx, s.logger).WarnContext(ctx, "failed to publish session revocations",
+			"error", err, "user_id", userID, "sessions", len(sessionIDs))
+	}
+}
+
+// revokeToken revokes the access token claims describes for reason, until
+// it expires. An already expired token needs no revocation.
+func (s *AuthService) revokeToken(ctx context.Context, claims *auth.Claims, reason string) error {
+	now := s.clock.Now().UTC()
+	expiresAt := now.Add(s.minter.AccessTTL())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if !expiresAt.After(now) {
+		return nil
+	}
+	return s.revocationStore.Revoke(ctx, claims.ID, Revocation{Reason: reason, RevokedAt: now, ExpiresAt: expiresAt})
+}
+
+// revokeSession revokes sessionID for reason, until any access token
+// minted for it by now has expired.
+func (s *AuthService) revokeSession(ctx context.Context, sessionID, reason string) error {
+	now := s.clock.Now().UTC()
+	return s.revocationStore.Revoke(ctx, sessionID, Revocation{Reason: reason, RevokedAt: now, ExpiresAt: now.Add(s.minter.AccessTTL())})
+}
+
+// RunIdleSweeper calls SweepIdleSessions every interval until ctx is done.
+// Zero interval defaults to domain.SessionIdleSweepInterval.
+func (s *AuthService) RunIdleSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = domain.SessionIdleSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.SweepIdleSessions(ctx); err != nil && ctx.Err() == nil {
+				observability.WithTraceID(ctx, s.logger).ErrorContext(ctx, "idle session sweep failed", "error", err)
+			}
+		}
+	}
+}
+
+// isIdle reports whether session has been unused for the idle timeout at
+// now.
+func (s *AuthService) isIdle(session *SessionRecord, now time.Time) bool {
+	return !lastActiveAt(*session).Time().Add(s.idleTimeout).After(now)
+}
+
+// lastActiveAt returns when the session was last used. Sessions never
+// reported active count from their creation.
+func lastActiveAt(session SessionRecord) domain.TimestampMS {
+	if !session.LastActiveAt.IsZero() {
+		return session.LastActiveAt
+	}
+	return session.CreatedAt
+}
@@ -0,0 +1,226 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestListDevices(t *testing.T) {
+	t.Run("newest activity first, idle sessions hidden", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		mintResult, err := h.minter.MintAccessToken("user-001", "sess-current")
+		require.NoError(t, err)
+
+		current := sampleSessionRecord("user-001", "sess-current", "phone", "h1", h.clock)
+		current.LastActiveAt = domain.NewTimestampMS(testStart.Add(-time.Hour))
+		tablet := sampleSessionRecord("user-001", "sess-tablet", "tablet", "h2", h.clock)
+		tablet.CreatedAt = domain.NewTimestampMS(testStart.Add(-3 * 24 * time.Hour))
+		idle := sampleSessionRecord("user-001", "sess-idle", "laptop", "h3", h.clock)
+		idle.LastActiveAt = domain.NewTimestampMS(testStart.Add(-domain.SessionIdleTimeout - time.Minute))
+		h.sessionStore.listByUserFn = func(_ context.Context, userID string) ([]app.SessionRecord, error) {
+			assert.Equal(t, "user-001", userID)
+			return []app.SessionRecord{*tablet, *idle, *current}, nil
+		}
+
+		devices, err := h.svc.ListDevices(context.Background(), mintResult.Token)
+
+		require.NoError(t, err)
+		require.Len(t, devices, 2)
+		assert.Equal(t, "sess-current", devices[0].SessionID)
+		assert.True(t, devices[0].Current)
+		assert.Equal(t, testStart.Add(-time.Hour+domain.SessionIdleTimeout), devices[0].IdleExpiresAt)
+		assert.Equal(t, "sess-tablet", devices[1].SessionID)
+		assert.False(t, devices[1].Current)
+		assert.Equal(t, devices[1].CreatedAt, devices[1].LastActiveAt, "never-active session counts from creation")
+	})
+
+	t.Run("invalid access token", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		_, err := h.svc.ListDevices(context.Background(), "garbage")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
+
+func TestSweepIdleSessions(t *testing.T) {
+	t.Run("deletes idle sessions conditionally", func(t *testing.T) {
+		h := newTestHarness(t)
+		wantCutoff := domain.NewTimestampMS(testStart.Add(-domain.SessionIdleTimeout))
+
+		h.sessionStore.listIdleFn = func(_ context.Context, cutoff domain.TimestampMS) ([]app.SessionRecord, error) {
+			assert.Equal(t, wantCutoff, cutoff)
+			return []app.SessionRecord{
+				{SessionID: "sess-1", UserID: "user-001"},
+				{SessionID: "sess-2", UserID: "user-002"},
+			}, nil
+		}
+		var deleted []string
+		h.sessionStore.deleteIdleFn = func(_ context.Context, sessionID string, cutoff domain.TimestampMS) error {
+			assert.Equal(t, wantCutoff, cutoff)
+			if sessionID == "sess-2" {
+				// Became active after the scan.
+				return domain.ErrVersionConflict
+			}
+			deleted = append(deleted, sessionID)
+			return nil
+		}
+
+		removed, err := h.svc.SweepIdleSessions(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, removed)
+		assert.Equal(t, []string{"sess-1"}, deleted)
+	})
+
+	t.Run("store failure stops the sweep", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.sessionStore.listIdleFn = func(context.Context, domain.TimestampMS) ([]app.SessionRecord, error) {
+			return []app.SessionRecord{{SessionID: "sess-1"}, {SessionID: "sess-2"}}, nil
+		}
+		calls := 0
+		h.sessionStore.deleteIdleFn = func(context.Context, string, domain.TimestampMS) error {
+			calls++
+			return errors.New("throttled")
+		}
+
+		removed, err := h.svc.SweepIdleSessions(context.Background())
+
+		assert.ErrorContains(t, err, "throttled")
+		assert.Zero(t, removed)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("configured idle timeout sets the cutoff", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := app.NewAuthService(app.AuthServiceConfig{
+			SessionStore: h.sessionStore,
+			Clock:        h.clock,
+			Logger:       slog.Default(),
+			IdleTimeout:  7 * 24 * time.Hour,
+		})
+		var got domain.TimestampMS
+		h.sessionStore.listIdleFn = func(_ context.Context, cutoff domain.TimestampMS) ([]app.SessionRecord, error) {
+			got = cutoff
+			return nil, nil
+		}
+
+		_, err := svc.SweepIdleSessions(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.NewTimestampMS(testStart.Add(-7*24*time.Hour)), got)
+	})
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	t.Run("revokes the user's tokens and deletes every session", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.sessionStore.listByUserFn = func(_ context.Context, userID string) ([]app.SessionRecord, error) {
+			assert.Equal(t, "user-001", userID)
+			return []app.SessionRecord{{SessionID: "sess-1"}, {SessionID: "sess-2"}}, nil
+		}
+		var deleted []string
+		h.sessionStore.deleteFn = func(_ context.Context, sessionID string) error {
+			deleted = append(deleted, sessionID)
+			return nil
+		}
+		var revocation app.Revocation
+		h.revocationStore.revokeAllFn = func(_ context.Context, userID string, r app.Revocation) error {
+			assert.Equal(t, "user-001", userID)
+			assert.Empty(t, deleted, "tokens are revoked before sessions are deleted")
+			revocation = r
+			return nil
+		}
+
+		n, err := h.svc.RevokeUserSessions(context.Background(), "user-001", "scim_deprovision")
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"sess-1", "sess-2"}, deleted)
+		assert.Equal(t, app.Revocation{
+			Reason:    "scim_deprovision",
+			RevokedAt: testStart,
+			ExpiresAt: testStart.Add(domain.AccessTokenLifetime),
+		}, revocation)
+	})
+
+	t.Run("publishes the deleted sessions", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.sessionStore.listByUserFn = func(context.Context, string) ([]app.SessionRecord, error) {
+			return []app.SessionRecord{{SessionID: "sess-1"}, {SessionID: "sess-2"}}, nil
+		}
+
+		_, err := h.svc.RevokeUserSessions(context.Background(), "user-001", "scim_deprovision")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user-001/scim_deprovision/sess-1,sess-2"}, h.revocationPub.published)
+	})
+
+	t.Run("publish failure does not fail the revocation", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.sessionStore.listByUserFn = func(context.Context, string) ([]app.SessionRecord, error) {
+			return []app.SessionRecord{{SessionID: "sess-1"}}, nil
+		}
+		h.revocationPub.err = errors.New("gateway unreachable")
+
+		n, err := h.svc.RevokeUserSessions(context.Background(), "user-001", "scim_deprovision")
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("no sessions: nothing published", func(t *testing.T) {
+		h := newTestHarness(t)
+
+		_, err := h.svc.RevokeUserSessions(context.Background(), "user-001", "scim_deprovision")
+
+		require.NoError(t, err)
+		assert.Empty(t, h.revocationPub.published)
+	})
+
+	t.Run("revocation failure deletes nothing", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.revocationStore.revokeAllFn = func(context.Context, string, app.Revocation) error {
+			return errors.New("redis down")
+		}
+		h.sessionStore.deleteFn = func(context.Context, string) error {
+			t.Fatal("session deleted after the revocation failed")
+			return nil
+		}
+
+		n, err := h.svc.RevokeUserSessions(context.Background(), "user-001", "scim_deprovision")
+
+		assert.ErrorContains(t, err, "redis down")
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("delete failure stops and reports progress", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.sessionStore.listByUserFn = func(context.Context, string) ([]app.SessionRecord, error) {
+			return []app.SessionRecord{{SessionID: "sess-1"}, {SessionID: "sess-2"}}, nil
+		}
+		h.sessionStore.deleteFn = func(_ context.Context, sessionID string) error {
+			if sessionID == "sess-2" {
+				return errors.New("throttled")
+			}
+			return nil
+		}
+
+		n, err := h.svc.RevokeUserSessions(context.Background(), "user-001", "scim_deprovision")
+
+		assert.ErrorContains(t, err, "throttled")
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"user-001/scim_deprovision/sess-1"}, h.revocationPub.published,
+			"sessions deleted before the failure are still published")
+	})
+}
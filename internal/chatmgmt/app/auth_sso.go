@@ -0,0 +1,369 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// IDTokenVerifier validates ID tokens from workspace identity providers.
+// *auth.OIDCVerifier satisfies it.
+type IDTokenVerifier interface {
+	VerifyIDToken(ctx context.Context, issuer, clientID, rawToken, nonce string) (*auth.IDTokenClaims, error)
+}
+
+// SSOStore persists workspace SSO connections and the IdP subjects linked
+// to users.
+type SSOStore interface {
+	// GetConnection returns domain.ErrNotFound if the workspace has no SSO.
+	GetConnection(ctx context.Context, workspaceID string) (domain.SSOConnection, error)
+	PutConnection(ctx context.Context, conn domain.SSOConnection) error
+	// FindIdentity returns the user linked to subject in the workspace, or
+	// domain.ErrNotFound.
+	FindIdentity(ctx context.Context, workspaceID, subject string) (string, error)
+	// ProvisionUser creates user and links subject to it in one
+	// transaction. Returns domain.ErrAlreadyExists if subject is already
+	// linked, leaving no user behind.
+	ProvisionUser(ctx context.Context, workspaceID, subject string, user UserRecord) error
+	// LinkIdentity links subject to an existing user at the given time.
+	// Returns domain.ErrAlreadyExists if subject is already linked.
+	LinkIdentity(ctx context.Context, workspaceID, subject, userID string, at domain.TimestampMS) error
+}
+
+// WorkspaceMemberReader reads SCIM-provisioned workspace members. Both
+// methods return domain.ErrNotFound for an unknown member.
+type WorkspaceMemberReader interface {
+	GetMember(ctx context.Context, workspaceID, userID string) (*domain.WorkspaceMember, error)
+	// FindMemberByUserName matches userName ignoring case.
+	FindMemberByUserName(ctx context.Context, workspaceID, userName string) (*domain.WorkspaceMember, error)
+}
+
+// SSOLogin signs a workspace member in with an ID token from the
+// workspace's identity provider. A subject seen for the first time gets a
+// phone-less account, provided its email passes the connection's domain
+// allowlist; in a SCIM-managed workspace it is instead linked to the
+// active member provisioned with that email. The session's lifetime,
+// refreshes included, is capped by the connection's MaxSessionTTL.
+func (s *AuthService) SSOLogin(ctx context.Context, workspaceID, idToken, nonce, deviceID, clientIP string) (*VerifyOTPResult, error) {
+	ctx, span := tracer.Start(ctx, "auth.sso_login")
+	defer span.End()
+	span.SetAttributes(attribute.String("auth.workspace_id", workspaceID))
+
+	result, err := s.ssoLogin(ctx, workspaceID, idToken, nonce, deviceID, clientIP)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Bool("auth.is_new_user", result.IsNewUser))
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "auth.sso_verified",
+		"workspace_id", workspaceID,
+		"user_id", result.User.UserID,
+		"session_id", result.SessionID,
+		"is_new_user", result.IsNewUser,
+	)
+	if s.analytics != nil {
+		s.analytics.SessionStarted(ctx, result.User.UserID, result.IsNewUser)
+	}
+	return result, nil
+}
+
+func (s *AuthService) ssoLogin(ctx context.Context, workspaceID, idToken, nonce, deviceID, clientIP string) (*VerifyOTPResult, error) {
+	switch {
+	case s.sso == nil || s.idTokens == nil:
+		return nil, fmt.Errorf("sso login: not configured: %w", domain.ErrNotFound)
+	case workspaceID == "":
+		return nil, fmt.Errorf("sso login: %w", domain.NewValidationError("workspace_id", "is required"))
+	case deviceID == "":
+		return nil, fmt.Errorf("sso login: %w", domain.NewValidationError("device_id", "is required"))
+	case nonce == "" || len(nonce) > domain.MaxSSONonceLength:
+		return nil, fmt.Errorf("sso login: %w", domain.NewValidationError("nonce", "is required"))
+	}
+
+	conn, err := s.sso.GetConnection(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("sso login: get connection: %w", err)
+	}
+	if err := domain.CheckResidency(workspaceID, conn.DataRegion, s.region); err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "wrong_region")))
+		return nil, fmt.Errorf("sso login: %w", err)
+	}
+	claims, err := s.idTokens.VerifyIDToken(ctx, conn.Issuer, conn.ClientID, idToken, nonce)
+	if err != nil {
+		if errors.Is(err, domain.ErrUnauthorized) {
+			authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_id_token")))
+		}
+		return nil, fmt.Errorf("sso login: %w", err)
+	}
+
+	user, isNew, err := s.ssoUser(ctx, conn, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.createSSOSession(ctx, conn, user, deviceID, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	result.IsNewUser = isNew
+	return result, nil
+}
+
+// ssoUser returns the user linked to the token's subject, provisioning one
+// on first sign-in.
+func (s *AuthService) ssoUser(ctx context.Context, conn domain.SSOConnection, claims *auth.IDTokenClaims) (*UserRecord, bool, error) {
+	if conn.SCIMManaged() {
+		user, err := s.scimUser(ctx, conn, claims)
+		return user, false, err
+	}
+
+	userID, err := s.sso.FindIdentity(ctx, conn.WorkspaceID, claims.Subject)
+	if err == nil {
+		user, err := s.userStore.GetByID(ctx, userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("get sso user: %w", err)
+		}
+		return user, false, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, false, fmt.Errorf("find sso identity: %w", err)
+	}
+
+	if !conn.AllowsEmail(claims.Email, claims.EmailVerified) {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "sso_domain_not_allowed")))
+		return nil, false, fmt.Errorf("sso login: email domain not allowed: %w", domain.ErrForbidden)
+	}
+
+	now := domain.TimestampNow(s.clock)
+	user := UserRecord{
+		UserID:      uuid.NewString(),
+		DisplayName: claims.Name,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.sso.ProvisionUser(ctx, conn.WorkspaceID, claims.Subject, user); err != nil {
+		if !errors.Is(err, domain.ErrAlreadyExists) {
+			return nil, false, fmt.Errorf("provision sso user: %w", err)
+		}
+		// A concurrent first sign-in of the same subject won the race.
+		userID, err := s.sso.FindIdentity(ctx, conn.WorkspaceID, claims.Subject)
+		if err != nil {
+			return nil, false, fmt.Errorf("find sso identity after race: %w", err)
+		}
+		existing, err := s.userStore.GetByID(ctx, userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("get sso user: %w", err)
+		}
+		return existing, false, nil
+	}
+	return &user, true, nil
+}
+
+// scimUser returns the provisioned member the token's subject signs in
+// as. On the subject's first sign-in it is linked to the member whose
+// userName is the token's verified email. Unprovisioned and deactivated
+// members are refused.
+func (s *AuthService) scimUser(ctx context.Context, conn domain.SSOConnection, claims *auth.IDTokenClaims) (*UserRecord, error) {
+	if s.members == nil {
+		return nil, fmt.Errorf("sso login: scim members not configured: %w", domain.ErrForbidden)
+	}
+
+	var member *domain.WorkspaceMember
+	userID, err := s.sso.FindIdentity(ctx, conn.WorkspaceID, claims.Subject)
+	switch {
+	case err == nil:
+		member, err = s.members.GetMember(ctx, conn.WorkspaceID, userID)
+	case errors.Is(err, domain.ErrNotFound):
+		member, err = s.linkSCIMMember(ctx, conn, claims)
+	default:
+		return nil, fmt.Errorf("find sso identity: %w", err)
+	}
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && !member.Active) {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "sso_member_not_provisioned")))
+		return nil, fmt.Errorf("sso login: member not provisioned or deactivated: %w", domain.ErrForbidden)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userStore.GetByID(ctx, member.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get sso user: %w", err)
+	}
+	return user, nil
+}
+
+// linkSCIMMember links a subject signing in for the first time to the
+// member provisioned with its verified email.
+func (s *AuthService) linkSCIMMember(ctx context.Context, conn domain.SSOConnection, claims *auth.IDTokenClaims) (*domain.WorkspaceMember, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, fmt.Errorf("sso login: no verified email: %w", domain.ErrNotFound)
+	}
+	member, err := s.members.FindMemberByUserName(ctx, conn.WorkspaceID, claims.Email)
+	if err != nil {
+		return nil, fmt.Errorf("find scim member: %w", err)
+	}
+	if !member.Active {
+		return member, nil
+	}
+	err = s.sso.LinkIdentity(ctx, conn.WorkspaceID, claims.Subject, member.UserID, domain.TimestampNow(s.clock))
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		// A concurrent first sign-in linked it; it must agree.
+		userID, findErr := s.sso.FindIdentity(ctx, conn.WorkspaceID, claims.Subject)
+		if findErr != nil {
+			return nil, fmt.Errorf("find sso identity after race: %w", findErr)
+		}
+		if userID != member.UserID {
+			return nil, fmt.Errorf("sso login: subject linked to another member: %w", domain.ErrNotFound)
+		}
+		return member, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("link sso identity: %w", err)
+	}
+	return member, nil
+}
+
+// createSSOSession creates the session and mints its tokens. SSO has no
+// OTP to consume, so the session is a plain create rather than a
+// transaction.
+func (s *AuthService) createSSOSession(
+	ctx context.Context,
+	conn domain.SSOConnection,
+	user *UserRecord,
+	deviceID, clientIP string,
+) (*VerifyOTPResult, error) {
+	sessionID := uuid.NewString()
+	familyID := uuid.NewString()
+	now := domain.TimestampNow(s.clock)
+
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+	refreshHash, err := s.refreshHasher.Hash(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionExpiry := now.Add(conn.SessionTTL(s.refreshTTL))
+	session := SessionRecord{
+		SessionID:        sessionID,
+		UserID:           user.UserID,
+		DeviceID:         deviceID,
+		FamilyID:         familyID,
+		RefreshTokenHash: refreshHash,
+		CreatedAt:        now,
+		ExpiresAt:        sessionExpiry,
+		TokenGeneration:  1,
+		TTL:              sessionExpiry.Time().Unix(),
+	}
+	if conn.MaxSessionTTL > 0 {
+		session.MaxExpiresAt = now.Add(conn.MaxSessionTTL)
+	}
+	err = s.replaceSessions(ctx, user.UserID, deviceID, func(evictSessionIDs []string) error {
+		return s.transactor.EvictAndCreateSession(ctx, session, evictSessionIDs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create sso session: %w", err)
+	}
+	s.recordGeneration(ctx, TokenLineageEntry{
+		FamilyID:   familyID,
+		Generation: 1,
+		SessionID:  sessionID,
+		UserID:     user.UserID,
+		DeviceID:   deviceID,
+		ClientIP:   clientIP,
+		Event:      LineageSSO,
+	})
+
+	mintResult, err := s.minter.MintAccessToken(user.UserID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("mint access token: %w", err)
+	}
+
+	sessionCreatedTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("flow", "sso")))
+	tokenMintedTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("flow", "sso")))
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "session.created",
+		"user_id", user.UserID,
+		"session_id", sessionID,
+		"flow", "sso",
+	)
+
+	return &VerifyOTPResult{
+		User:              *user,
+		SessionID:         sessionID,
+		AccessToken:       mintResult.Token,
+		RefreshToken:      refreshToken,
+		AccessTokenExpiry: mintResult.ExpiresAt,
+	}, nil
+}
+
+// SSOConnection returns the workspace's SSO connection, or
+// domain.ErrNotFound.
+func (s *AuthService) SSOConnection(ctx context.Context, workspaceID string) (domain.SSOConnection, error) {
+	if s.sso == nil {
+		return domain.SSOConnection{}, fmt.Errorf("sso connection: not configured: %w", domain.ErrNotFound)
+	}
+	return s.sso.GetConnection(ctx, workspaceID)
+}
+
+// SetSSOConnection validates and stores a workspace's SSO connection,
+// replacing any previous one but keeping its SCIM token and data region.
+// A new connection without a region is placed in the deployment's region.
+// A workspace's region cannot change, since its data would have to move
+// with it. Sessions already issued keep their cap.
+func (s *AuthService) SetSSOConnection(ctx context.Context, conn domain.SSOConnection) error {
+	if s.sso == nil {
+		return fmt.Errorf("set sso connection: not configured: %w", domain.ErrNotFound)
+	}
+	if err := conn.Validate(); err != nil {
+		return fmt.Errorf("set sso connection: %w", err)
+	}
+	prev, err := s.sso.GetConnection(ctx, conn.WorkspaceID)
+	switch {
+	case err == nil:
+		conn.SCIMTokenHash = prev.SCIMTokenHash
+		if err := domain.CheckResidency(conn.WorkspaceID, prev.DataRegion, s.region); err != nil {
+			return fmt.Errorf("set sso connection: %w", err)
+		}
+		switch {
+		case conn.DataRegion == "":
+			conn.DataRegion = prev.DataRegion
+		case prev.DataRegion != "" && conn.DataRegion != prev.DataRegion:
+			return fmt.Errorf("set sso connection: %w",
+				domain.NewValidationError("data_region", "cannot change from "+string(prev.DataRegion)+"; the workspace's data would have to be migrated"))
+		}
+	case !errors.Is(err, domain.ErrNotFound):
+		return fmt.Errorf("set sso connection: %w", err)
+	default:
+		conn.SCIMTokenHash = ""
+	}
+	if conn.DataRegion == "" {
+		conn.DataRegion = s.region
+	}
+	if err := domain.CheckResidency(conn.WorkspaceID, conn.DataRegion, s.region); err != nil {
+		return fmt.Errorf("set sso connection: %w", err)
+	}
+	conn.UpdatedAt = s.clock.Now().UTC()
+	if err := s.sso.PutConnection(ctx, conn); err != nil {
+		return fmt.Errorf("set sso connection: %w", err)
+	}
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "auth.sso_connection_updated",
+		"workspace_id", conn.WorkspaceID,
+		"issuer", conn.Issuer,
+		"data_region", conn.DataRegion,
+		"max_session_ttl", conn.MaxSessionTTL,
+	)
+	return nil
+}
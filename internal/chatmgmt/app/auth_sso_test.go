@@ -0,0 +1,357 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+const (
+	testWorkspace = "ws-001"
+	testIssuer    = "https://idp.example.com"
+	testNonce     = "nonce-abc"
+)
+
+// newSSOHarness returns a harness whose workspace has an SSO connection
+// capped at 8 hours and an IdP that vouches for ada@example.com.
+func newSSOHarness(t *testing.T) *testHarness {
+	t.Helper()
+	h := newTestHarness(t)
+	h.sso.connections = map[string]domain.SSOConnection{
+		testWorkspace: {
+			WorkspaceID:    testWorkspace,
+			Issuer:         testIssuer,
+			ClientID:       "messaging",
+			AllowedDomains: []string{"example.com"},
+			MaxSessionTTL:  8 * time.Hour,
+		},
+	}
+	h.idTokens.verifyFn = func(_ context.Context, issuer, clientID, rawToken, nonce string) (*auth.IDTokenClaims, error) {
+		assert.Equal(t, testIssuer, issuer)
+		assert.Equal(t, "messaging", clientID)
+		assert.Equal(t, testNonce, nonce)
+		if rawToken != "good-token" {
+			return nil, fmt.Errorf("verify id token: %w", domain.ErrUnauthorized)
+		}
+		return &auth.IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "idp-sub-1"},
+			Email:            "ada@example.com",
+			EmailVerified:    true,
+			Name:             "Ada",
+		}, nil
+	}
+	return h
+}
+
+func TestSSOLogin(t *testing.T) {
+	t.Run("first sign-in provisions a phone-less user with a capped session", func(t *testing.T) {
+		h := newSSOHarness(t)
+		var created app.SessionRecord
+		h.transactor.evictAndCreateSessionFn = func(_ context.Context, session app.SessionRecord, _ []string) error {
+			created = session
+			return nil
+		}
+
+		result, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		require.NoError(t, err)
+
+		assert.True(t, result.IsNewUser)
+		assert.Empty(t, result.User.PhoneNumber)
+		assert.Equal(t, "Ada", result.User.DisplayName)
+		require.Len(t, h.sso.provisioned, 1)
+		assert.Equal(t, result.User.UserID, h.sso.provisioned[0].UserID)
+
+		assert.Equal(t, result.SessionID, created.SessionID)
+		assert.Equal(t, domain.NewTimestampMS(testStart.Add(8*time.Hour)), created.ExpiresAt)
+		assert.Equal(t, created.ExpiresAt, created.MaxExpiresAt)
+		assert.Equal(t, int64(1), created.TokenGeneration)
+
+		claims, err := h.validator.ValidateAccessToken(result.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, result.User.UserID, claims.Subject)
+
+		require.Len(t, h.lineage.entries, 1)
+		assert.Equal(t, app.LineageSSO, h.lineage.entries[0].Event)
+		assert.Equal(t, []string{result.User.UserID + "/true"}, h.analytics.started)
+	})
+
+	t.Run("linked subject signs in to its existing user", func(t *testing.T) {
+		h := newSSOHarness(t)
+		h.sso.identities = map[string]string{testWorkspace + "/idp-sub-1": "user-existing-001"}
+		h.userStore.getByIDFn = func(_ context.Context, userID string) (*app.UserRecord, error) {
+			assert.Equal(t, "user-existing-001", userID)
+			return sampleUserRecord(), nil
+		}
+
+		result, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		require.NoError(t, err)
+
+		assert.False(t, result.IsNewUser)
+		assert.Equal(t, "user-existing-001", result.User.UserID)
+		assert.Empty(t, h.sso.provisioned)
+	})
+
+	t.Run("provisioning race resolves to the winner's user", func(t *testing.T) {
+		h := newSSOHarness(t)
+		h.sso.provisionFn = func(_ context.Context, workspaceID, subject string, _ app.UserRecord) error {
+			h.sso.identities = map[string]string{workspaceID + "/" + subject: "user-existing-001"}
+			return fmt.Errorf("provision: %w", domain.ErrAlreadyExists)
+		}
+		h.userStore.getByIDFn = func(_ context.Context, _ string) (*app.UserRecord, error) {
+			return sampleUserRecord(), nil
+		}
+
+		result, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		require.NoError(t, err)
+		assert.False(t, result.IsNewUser)
+		assert.Equal(t, "user-existing-001", result.User.UserID)
+	})
+
+	t.Run("email outside the allowed domains is not provisioned", func(t *testing.T) {
+		h := newSSOHarness(t)
+		conn := h.sso.connections[testWorkspace]
+		conn.AllowedDomains = []string{"corp.example.org"}
+		h.sso.connections[testWorkspace] = conn
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.Empty(t, h.sso.provisioned)
+	})
+
+	t.Run("connection without a cap uses the refresh lifetime", func(t *testing.T) {
+		h := newSSOHarness(t)
+		conn := h.sso.connections[testWorkspace]
+		conn.MaxSessionTTL = 0
+		h.sso.connections[testWorkspace] = conn
+		var created app.SessionRecord
+		h.transactor.evictAndCreateSessionFn = func(_ context.Context, session app.SessionRecord, _ []string) error {
+			created = session
+			return nil
+		}
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		require.NoError(t, err)
+		assert.Equal(t, domain.NewTimestampMS(testStart.Add(domain.RefreshTokenLifetime)), created.ExpiresAt)
+		assert.True(t, created.MaxExpiresAt.IsZero())
+	})
+
+	t.Run("sign-in on a known device replaces its session", func(t *testing.T) {
+		h := newSSOHarness(t)
+		h.sessionStore.listByUserFn = func(_ context.Context, _ string) ([]app.SessionRecord, error) {
+			return []app.SessionRecord{{SessionID: "old-session", DeviceID: testDeviceID}}, nil
+		}
+		var evicted []string
+		h.transactor.evictAndCreateSessionFn = func(_ context.Context, _ app.SessionRecord, evictSessionIDs []string) error {
+			evicted = evictSessionIDs
+			return nil
+		}
+		var revoked []string
+		h.revocationStore.revokeFn = func(_ context.Context, jti string, _ app.Revocation) error {
+			revoked = append(revoked, jti)
+			return nil
+		}
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"old-session"}, evicted)
+		assert.Equal(t, []string{"old-session"}, revoked)
+	})
+
+	t.Run("invalid ID token is unauthorized", func(t *testing.T) {
+		h := newSSOHarness(t)
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "forged-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("workspace without SSO is not found", func(t *testing.T) {
+		h := newSSOHarness(t)
+		_, err := h.svc.SSOLogin(context.Background(), "ws-other", "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("missing device or nonce is invalid input", func(t *testing.T) {
+		h := newSSOHarness(t)
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, "", testClientIP)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		_, err = h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", "", testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("session create failure: returns wrapped error", func(t *testing.T) {
+		h := newSSOHarness(t)
+		h.transactor.evictAndCreateSessionFn = func(_ context.Context, _ app.SessionRecord, _ []string) error {
+			return errors.New("throttled")
+		}
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorContains(t, err, "create sso session")
+	})
+}
+
+func TestSetSSOConnection(t *testing.T) {
+	h := newTestHarness(t)
+
+	err := h.svc.SetSSOConnection(context.Background(), domain.SSOConnection{WorkspaceID: testWorkspace, Issuer: "http://idp.example.com", ClientID: "messaging"})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	conn := domain.SSOConnection{WorkspaceID: testWorkspace, Issuer: testIssuer, ClientID: "messaging", MaxSessionTTL: 12 * time.Hour}
+	require.NoError(t, h.svc.SetSSOConnection(context.Background(), conn))
+
+	got, err := h.svc.SSOConnection(context.Background(), testWorkspace)
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, got.MaxSessionTTL)
+	assert.Equal(t, testStart, got.UpdatedAt)
+}
+
+func TestSSOLogin_SCIMManaged(t *testing.T) {
+	newManaged := func(t *testing.T) (*testHarness, domain.WorkspaceMember) {
+		h := newSSOHarness(t)
+		conn := h.sso.connections[testWorkspace]
+		conn.SCIMTokenHash = "token-hash"
+		h.sso.connections[testWorkspace] = conn
+		member := domain.WorkspaceMember{WorkspaceID: testWorkspace, UserID: "user-existing-001", UserName: "Ada@Example.com", Active: true}
+		require.NoError(t, h.members.CreateMember(context.Background(), member, app.UserRecord{}))
+		h.userStore.getByIDFn = func(_ context.Context, userID string) (*app.UserRecord, error) {
+			assert.Equal(t, member.UserID, userID)
+			return sampleUserRecord(), nil
+		}
+		return h, member
+	}
+
+	t.Run("first sign-in links the member provisioned with the email", func(t *testing.T) {
+		h, member := newManaged(t)
+
+		result, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		require.NoError(t, err)
+
+		assert.False(t, result.IsNewUser)
+		assert.Equal(t, member.UserID, result.User.UserID)
+		assert.Empty(t, h.sso.provisioned, "SCIM-managed workspaces never provision just in time")
+		assert.Equal(t, member.UserID, h.sso.identities[testWorkspace+"/idp-sub-1"])
+	})
+
+	t.Run("unprovisioned subject is forbidden", func(t *testing.T) {
+		h, member := newManaged(t)
+		require.NoError(t, h.members.DeleteMember(context.Background(), member))
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.Empty(t, h.sso.provisioned)
+	})
+
+	t.Run("deactivated member is forbidden even when linked", func(t *testing.T) {
+		h, member := newManaged(t)
+		h.sso.identities = map[string]string{testWorkspace + "/idp-sub-1": member.UserID}
+		member.Active = false
+		require.NoError(t, h.members.UpdateMember(context.Background(), member, member.UserName))
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("unverified email is not linked", func(t *testing.T) {
+		h, _ := newManaged(t)
+		verify := h.idTokens.verifyFn
+		h.idTokens.verifyFn = func(ctx context.Context, issuer, clientID, rawToken, nonce string) (*auth.IDTokenClaims, error) {
+			claims, err := verify(ctx, issuer, clientID, rawToken, nonce)
+			claims.EmailVerified = false
+			return claims, err
+		}
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.Empty(t, h.sso.identities)
+	})
+}
+
+func TestSetSSOConnection_KeepsSCIMToken(t *testing.T) {
+	h := newTestHarness(t)
+	h.sso.connections = map[string]domain.SSOConnection{
+		testWorkspace: {WorkspaceID: testWorkspace, Issuer: testIssuer, ClientID: "messaging", SCIMTokenHash: "token-hash"},
+	}
+
+	err := h.svc.SetSSOConnection(context.Background(), domain.SSOConnection{
+		WorkspaceID: testWorkspace, Issuer: testIssuer, ClientID: "messaging-v2", SCIMTokenHash: "forged",
+	})
+	require.NoError(t, err)
+
+	got := h.sso.connections[testWorkspace]
+	assert.Equal(t, "messaging-v2", got.ClientID)
+	assert.Equal(t, "token-hash", got.SCIMTokenHash)
+}
+
+func TestSSOResidency(t *testing.T) {
+	inRegion := func(t *testing.T, region domain.DataRegion) *testHarness {
+		h := newSSOHarness(t)
+		conn := h.sso.connections[testWorkspace]
+		conn.DataRegion = domain.RegionEU
+		h.sso.connections[testWorkspace] = conn
+		h.region = region
+		h.svc = h.newService(0)
+		return h
+	}
+
+	t.Run("sign-in in the workspace's region", func(t *testing.T) {
+		h := inRegion(t, domain.RegionEU)
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.NoError(t, err)
+	})
+
+	t.Run("sign-in in another region is refused before the token is checked", func(t *testing.T) {
+		h := inRegion(t, domain.RegionUS)
+		h.idTokens.verifyFn = func(context.Context, string, string, string, string) (*auth.IDTokenClaims, error) {
+			t.Fatal("the ID token must not be verified")
+			return nil, nil
+		}
+
+		_, err := h.svc.SSOLogin(context.Background(), testWorkspace, "good-token", testNonce, testDeviceID, testClientIP)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.True(t, domain.IsResidencyError(err))
+		assert.Empty(t, h.sso.provisioned)
+	})
+
+	t.Run("new connection is placed in the deployment's region", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.region = domain.RegionUS
+		h.svc = h.newService(0)
+
+		require.NoError(t, h.svc.SetSSOConnection(context.Background(), domain.SSOConnection{
+			WorkspaceID: testWorkspace, Issuer: testIssuer, ClientID: "messaging",
+		}))
+		assert.Equal(t, domain.RegionUS, h.sso.connections[testWorkspace].DataRegion)
+
+		err := h.svc.SetSSOConnection(context.Background(), domain.SSOConnection{
+			WorkspaceID: "ws-002", Issuer: testIssuer, ClientID: "messaging", DataRegion: domain.RegionEU,
+		})
+		assert.True(t, domain.IsResidencyError(err), "an EU workspace cannot be created in the US")
+	})
+
+	t.Run("region cannot change", func(t *testing.T) {
+		h := inRegion(t, domain.RegionEU)
+		conn := h.sso.connections[testWorkspace]
+
+		conn.DataRegion = ""
+		require.NoError(t, h.svc.SetSSOConnection(context.Background(), conn))
+		assert.Equal(t, domain.RegionEU, h.sso.connections[testWorkspace].DataRegion)
+
+		conn.DataRegion = domain.RegionUS
+		err := h.svc.SetSSOConnection(context.Background(), conn)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		assert.ErrorContains(t, err, "data_region")
+	})
+
+	t.Run("connection in another region cannot be edited", func(t *testing.T) {
+		h := inRegion(t, domain.RegionUS)
+		err := h.svc.SetSSOConnection(context.Background(), h.sso.connections[testWorkspace])
+		assert.True(t, domain.IsResidencyError(err))
+	})
+}
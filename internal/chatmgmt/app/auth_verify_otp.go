@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"time"
 
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/attribute"
@@ -19,25 +18,27 @@ import (
 
 // VerifyOTP validates an OTP candidate and completes either new-user registration
 // or existing-user login (ADR-015 §1.4, §2).
-func (s *AuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, deviceID string) (*VerifyOTPResult, error) {
+func (s *AuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, deviceID, clientIP string) (*VerifyOTPResult, error) {
 	ctx, span := tracer.Start(ctx, "auth.verify_otp")
 	defer span.End()
 
 	logger := observability.WithTraceID(ctx, s.logger)
 
-	if _, err := domain.NewPhoneNumber(phone); err != nil {
+	phoneNumber, err := domain.NewPhoneNumber(phone)
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+	phone = phoneNumber.String()
 	if deviceID == "" {
-		err := fmt.Errorf("device ID is required: %w", domain.ErrInvalidInput)
+		err := fmt.Errorf("verify otp: %w", domain.NewValidationError("device_id", "is required"))
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
-	phoneHash := auth.HashPhone(phone)
+	phoneHash := s.peppers.HashPhone(phone)
 
 	if err := s.checkVerifyRateLimits(ctx, phoneHash); err != nil {
 		span.RecordError(err)
@@ -45,7 +46,16 @@ func (s *AuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, device
 		return nil, err
 	}
 
-	record, err := s.validateOTPRecord(ctx, phoneHash, otpCandidate)
+	if rule, ok := s.honeypot.Match(phoneNumber); ok {
+		err := s.verifyCanary(ctx, phoneHash, rule, otpCandidate, deviceID)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// The OTP may have been issued under an earlier pepper version, so its
+	// record can be keyed by an older phone hash than the rate limits.
+	record, recordHash, err := s.validateOTPRecord(ctx, phone, otpCandidate)
 	if err != nil {
 		logger.InfoContext(ctx, "auth.otp_failed", "phone_hash", phoneHash)
 		span.RecordError(err)
@@ -62,9 +72,9 @@ func (s *AuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, device
 
 	var result *VerifyOTPResult
 	if errors.Is(findErr, domain.ErrNotFound) {
-		result, err = s.verifyOTPNewUser(ctx, phone, phoneHash, record, deviceID)
+		result, err = s.verifyOTPNewUser(ctx, phone, recordHash, record, deviceID, clientIP)
 	} else {
-		result, err = s.verifyOTPExistingUser(ctx, phoneHash, record, existingUser, deviceID)
+		result, err = s.verifyOTPExistingUser(ctx, recordHash, record, existingUser, deviceID, clientIP)
 	}
 	if err != nil {
 		span.RecordError(err)
@@ -78,87 +88,120 @@ func (s *AuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, device
 		"session_id", result.SessionID,
 		"is_new_user", result.IsNewUser,
 	)
+	if s.analytics != nil {
+		s.analytics.SessionStarted(ctx, result.User.UserID, result.IsNewUser)
+	}
 
 	return result, nil
 }
 
-// checkVerifyRateLimits enforces rate limits and lockout for OTP verification.
+// checkVerifyRateLimits enforces rate limits and lockout for OTP verification
+// in one atomic call, so concurrent attempts cannot slip between the lockout
+// check and the count.
 func (s *AuthService) checkVerifyRateLimits(ctx context.Context, phoneHash string) error {
-	allowed, err := s.rateLimiter.CheckAndIncrement(
-		ctx,
-		"otp_verify:phone:"+phoneHash,
-		domain.MaxOTPVerifyAttempts,
-		int(domain.OTPRateLimitWindow.Seconds()),
-	)
+	admission, err := s.verifyLimiter.Admit(ctx, phoneHash,
+		domain.MaxOTPVerifyAttempts, int(domain.OTPRateLimitWindow.Seconds()))
 	if err != nil {
 		return fmt.Errorf("check verify rate limit: %w", errors.Join(err, domain.ErrUnavailable))
 	}
-	if !allowed {
-		rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("endpoint", "verify_otp"),
-			attribute.String("limit_type", "phone"),
-		))
-		return domain.ErrRateLimited
-	}
 
-	locked, err := s.rateLimiter.CheckLockout(ctx, "otp_lockout:phone:"+phoneHash)
-	if err != nil {
-		return fmt.Errorf("check lockout: %w", errors.Join(err, domain.ErrUnavailable))
-	}
-	if locked {
-		rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("endpoint", "verify_otp"),
-			attribute.String("limit_type", "lockout"),
-		))
-		return domain.ErrRateLimited
-	}
-
-	return nil
+	var limitType string
+	switch admission {
+	case OTPVerifyAdmitted:
+		return nil
+	case OTPVerifyRateLimited:
+		limitType = "phone"
+	default:
+		limitType = "lockout"
+	}
+	rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("endpoint", "verify_otp"),
+		attribute.String("limit_type", limitType),
+	))
+	return domain.ErrRateLimited
 }
 
-// validateOTPRecord retrieves and validates the OTP record, including
-// status, attempt count, expiry, and MAC verification.
-func (s *AuthService) validateOTPRecord(ctx context.Context, phoneHash, otpCandidate string) (*OTPRecord, error) {
-	record, err := s.otpStore.GetOTP(ctx, phoneHash)
+// validateOTPRecord retrieves and validates the phone's OTP record,
+// including status, attempt count, expiry, and MAC verification. It also
+// returns the phone hash the record is keyed by.
+func (s *AuthService) validateOTPRecord(ctx context.Context, phone, otpCandidate string) (*OTPRecord, string, error) {
+	record, phoneHash, err := s.findOTP(ctx, phone)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
 			authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_otp")))
-			return nil, domain.ErrInvalidOTP
+			return nil, "", domain.ErrInvalidOTP
 		}
-		return nil, fmt.Errorf("get OTP: %w", err)
+		return nil, "", fmt.Errorf("get OTP: %w", err)
 	}
 
 	if record.Status == "verified" {
 		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_otp")))
-		return nil, domain.ErrInvalidOTP
+		return nil, "", domain.ErrInvalidOTP
 	}
 
 	if record.AttemptCount >= domain.MaxOTPVerifyAttempts {
-		if lockErr := s.rateLimiter.SetLockout(ctx, "otp_lockout:phone:"+phoneHash,
-			int(domain.OTPLockoutDuration.Seconds())); lockErr != nil {
-			s.logger.ErrorContext(ctx, "failed to set lockout", "error", lockErr)
-		}
-		return nil, domain.ErrRateLimited
+		s.lockVerify(ctx, phone)
+		return nil, "", domain.ErrRateLimited
 	}
 
-	expiresAt, err := time.Parse(time.RFC3339, record.ExpiresAt)
-	if err != nil {
-		return nil, fmt.Errorf("parse OTP expiry: %w", err)
-	}
-	if s.clock.Now().UTC().After(expiresAt) {
+	if s.clock.Now().UTC().After(record.ExpiresAt.Time()) {
 		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "otp_expired")))
-		return nil, domain.ErrInvalidOTP
-	}
-
-	if !auth.VerifyOTPMAC(s.pepper, otpCandidate, phoneHash, record.ExpiresAt, record.OTPMAC) {
-		if incErr := s.otpStore.IncrementAttempts(ctx, phoneHash); incErr != nil {
+		return nil, "", domain.ErrInvalidOTP
+	}
+
+	if !s.verifyOTPMAC(record, phoneHash, otpCandidate) {
+		// The conditional increment, not the attempt count read above,
+		// decides the lockout: concurrent failures may all have read a
+		// count below the limit.
+		attempts, incErr := s.otpStore.IncrementAttempts(ctx, phoneHash, domain.MaxOTPVerifyAttempts)
+		switch {
+		case errors.Is(incErr, domain.ErrRateLimited):
+			s.lockVerify(ctx, phone)
+			return nil, "", domain.ErrRateLimited
+		case incErr != nil:
 			s.logger.ErrorContext(ctx, "failed to increment OTP attempts", "error", incErr)
+		case attempts >= domain.MaxOTPVerifyAttempts:
+			s.lockVerify(ctx, phone)
 		}
 		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_otp")))
-		return nil, domain.ErrInvalidOTP
+		return nil, "", domain.ErrInvalidOTP
 	}
 
-	return record, nil
+	return record, phoneHash, nil
+}
+
+// lockVerify locks the phone out of OTP verification. It locks under the
+// current hash, which Admit checks, not the hash an older record is keyed
+// by.
+func (s *AuthService) lockVerify(ctx context.Context, phone string) {
+	if err := s.verifyLimiter.Lock(ctx, s.peppers.HashPhone(phone),
+		int(domain.OTPLockoutDuration.Seconds())); err != nil {
+		s.logger.ErrorContext(ctx, "failed to set lockout", "error", err)
+	}
+}
+
+// findOTP looks the phone's OTP record up under its hash for every pepper
+// version, the current one first. It returns the record and the hash it
+// was found under.
+func (s *AuthService) findOTP(ctx context.Context, phone string) (*OTPRecord, string, error) {
+	for _, phoneHash := range s.peppers.PhoneHashes(phone) {
+		record, err := s.otpStore.GetOTP(ctx, phoneHash)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return record, phoneHash, nil
+	}
+	return nil, "", domain.ErrNotFound
+}
+
+// verifyOTPMAC checks the candidate against the record's MAC, under the code
+// format and pepper version the record was issued with.
+func (s *AuthService) verifyOTPMAC(record *OTPRecord, phoneHash, otpCandidate string) bool {
+	otpCandidate = domain.NormalizeOTP(otpCandidate)
+	return s.peppers.VerifyOTPMAC(otpCandidate, phoneHash, otpMACExpiry(record.ExpiresAt), record.CodeParams, record.OTPMAC)
 }
 
 // verifyOTPNewUser handles registration: creates user + session in a single transaction.
@@ -166,20 +209,23 @@ func (s *AuthService) verifyOTPNewUser(
 	ctx context.Context,
 	phone, phoneHash string,
 	record *OTPRecord,
-	deviceID string,
+	deviceID, clientIP string,
 ) (*VerifyOTPResult, error) {
 	userID := uuid.NewString()
 	sessionID := uuid.NewString()
-	now := s.clock.Now().UTC()
-	nowStr := now.Format(time.RFC3339)
+	familyID := uuid.NewString()
+	now := domain.TimestampNow(s.clock)
 
 	refreshToken, err := auth.GenerateRefreshToken()
 	if err != nil {
 		return nil, fmt.Errorf("generate refresh token: %w", err)
 	}
-	refreshHash := auth.HashRefreshToken(refreshToken)
+	refreshHash, err := s.refreshHasher.Hash(refreshToken)
+	if err != nil {
+		return nil, err
+	}
 
-	sessionExpiry := now.Add(domain.RefreshTokenLifetime)
+	sessionExpiry := now.Add(s.refreshTTL)
 
 	params := RegistrationParams{
 		PhoneHash:        phoneHash,
@@ -187,12 +233,13 @@ func (s *AuthService) verifyOTPNewUser(
 		OTPMAC:           record.OTPMAC,
 		UserID:           userID,
 		PhoneNumber:      phone,
-		Now:              nowStr,
+		Now:              now,
 		SessionID:        sessionID,
+		FamilyID:         familyID,
 		DeviceID:         deviceID,
 		RefreshTokenHash: refreshHash,
-		SessionExpiresAt: sessionExpiry.Format(time.RFC3339),
-		SessionTTL:       sessionExpiry.Unix(),
+		SessionExpiresAt: sessionExpiry,
+		SessionTTL:       sessionExpiry.Time().Unix(),
 	}
 
 	if txErr := s.transactor.VerifyOTPAndCreateUser(ctx, params); txErr != nil {
@@ -201,10 +248,19 @@ func (s *AuthService) verifyOTPNewUser(
 			if findErr != nil {
 				return nil, fmt.Errorf("find user after race: %w", findErr)
 			}
-			return s.verifyOTPExistingUser(ctx, phoneHash, record, user, deviceID)
+			return s.verifyOTPExistingUser(ctx, phoneHash, record, user, deviceID, clientIP)
 		}
 		return nil, fmt.Errorf("register user: %w", txErr)
 	}
+	s.recordGeneration(ctx, TokenLineageEntry{
+		FamilyID:   familyID,
+		Generation: 1,
+		SessionID:  sessionID,
+		UserID:     userID,
+		DeviceID:   deviceID,
+		ClientIP:   clientIP,
+		Event:      LineageRegistration,
+	})
 
 	mintResult, err := s.minter.MintAccessToken(userID, sessionID)
 	if err != nil {
@@ -223,8 +279,8 @@ func (s *AuthService) verifyOTPNewUser(
 		User: UserRecord{
 			UserID:      userID,
 			PhoneNumber: phone,
-			CreatedAt:   nowStr,
-			UpdatedAt:   nowStr,
+			CreatedAt:   now,
+			UpdatedAt:   now,
 		},
 		SessionID:         sessionID,
 		AccessToken:       mintResult.Token,
@@ -234,111 +290,144 @@ func (s *AuthService) verifyOTPNewUser(
 	}, nil
 }
 
-// verifyOTPExistingUser handles login: enforces session limits, creates session.
-func (s *AuthService) verifyOTPExistingUser(
-	ctx context.Context,
-	phoneHash string,
-	record *OTPRecord,
-	user *UserRecord,
-	deviceID string,
-) (*VerifyOTPResult, error) {
-	sessions, err := s.sessionStore.ListByUser(ctx, user.UserID)
-	if err != nil {
-		return nil, fmt.Errorf("list sessions: %w", err)
-	}
+// loginSessionAttempts bounds how often a login re-reads the user's
+// sessions when another login or logout changes them before its
+// transaction commits.
+const loginSessionAttempts = 3
 
-	if err := s.evictConflictingSessions(ctx, sessions, deviceID); err != nil {
-		return nil, err
-	}
-
-	if err := s.enforceSessionLimit(ctx, sessions, deviceID); err != nil {
-		return nil, err
-	}
-
-	return s.createLoginSession(ctx, phoneHash, record, user, deviceID)
+// sessionEviction is a session a login replaces, and the reason recorded
+// for it.
+type sessionEviction struct {
+	sessionID string
+	reason    string
 }
 
-// evictConflictingSessions removes sessions bound to the same device ID.
-func (s *AuthService) evictConflictingSessions(ctx context.Context, sessions []SessionRecord, deviceID string) error {
-	for _, sess := range sessions {
-		if sess.DeviceID == deviceID {
-			if delErr := s.sessionStore.Delete(ctx, sess.SessionID); delErr != nil {
-				return fmt.Errorf("delete replaced session: %w", delErr)
-			}
-			if revErr := s.revocationStore.Revoke(ctx, sess.SessionID); revErr != nil {
-				return fmt.Errorf("revoke replaced session: %w", revErr)
+// replaceSessions reads the user's sessions, revokes the ones a login on
+// deviceID evicts, and calls create with their IDs to delete them and
+// create the new session in one transaction. If an evicted session changed
+// in the meantime it starts over from a fresh read.
+func (s *AuthService) replaceSessions(ctx context.Context, userID, deviceID string, create func(evictSessionIDs []string) error) error {
+	for attempt := 1; ; attempt++ {
+		deviceSessions, err := s.sessionStore.GetByUserAndDevice(ctx, userID, deviceID)
+		if err != nil {
+			return fmt.Errorf("get device sessions: %w", err)
+		}
+		sessions, err := s.sessionStore.ListByUser(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("list sessions: %w", err)
+		}
+		evictions := sessionsToEvict(sessions, deviceSessions)
+
+		// Revoke first: a failed transaction leaves revoked sessions that
+		// the retry, or the next login, evicts anyway, but a revocation
+		// failure after the commit would leave evicted tokens live.
+		ids := make([]string, len(evictions))
+		for i, ev := range evictions {
+			if revErr := s.revokeSession(ctx, ev.sessionID, ev.reason); revErr != nil {
+				return fmt.Errorf("revoke evicted session: %w", revErr)
 			}
-			sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "device_conflict")))
+			ids[i] = ev.sessionID
+		}
+
+		err = create(ids)
+		if errors.Is(err, domain.ErrVersionConflict) && attempt < loginSessionAttempts {
+			continue
+		}
+		if err != nil {
+			return err
 		}
+		for _, ev := range evictions {
+			sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", ev.reason)))
+		}
+		return nil
 	}
-	return nil
 }
 
-// enforceSessionLimit evicts the oldest sessions when the user is at the max.
-func (s *AuthService) enforceSessionLimit(ctx context.Context, sessions []SessionRecord, deviceID string) error {
+// sessionsToEvict returns the sessions a login replaces: deviceSessions,
+// bound to the login's device, and the oldest of the user's other sessions
+// beyond domain.MaxSessionsPerUser - 1, leaving room for the new session.
+func sessionsToEvict(sessions, deviceSessions []SessionRecord) []sessionEviction {
+	evictions := make([]sessionEviction, 0, len(deviceSessions))
+	onDevice := make(map[string]bool, len(deviceSessions))
+	for _, sess := range deviceSessions {
+		evictions = append(evictions, sessionEviction{sessionID: sess.SessionID, reason: "device_conflict"})
+		onDevice[sess.SessionID] = true
+	}
 	activeSessions := make([]SessionRecord, 0, len(sessions))
 	for _, sess := range sessions {
-		if sess.DeviceID != deviceID {
+		if !onDevice[sess.SessionID] {
 			activeSessions = append(activeSessions, sess)
 		}
 	}
 
 	if len(activeSessions) < domain.MaxSessionsPerUser {
-		return nil
+		return evictions
 	}
 
 	sort.Slice(activeSessions, func(i, j int) bool {
-		return activeSessions[i].CreatedAt < activeSessions[j].CreatedAt
+		return activeSessions[i].CreatedAt.Before(activeSessions[j].CreatedAt)
 	})
 	evictCount := len(activeSessions) - domain.MaxSessionsPerUser + 1
-	for i := 0; i < evictCount; i++ {
-		sess := activeSessions[i]
-		if delErr := s.sessionStore.Delete(ctx, sess.SessionID); delErr != nil {
-			return fmt.Errorf("delete evicted session: %w", delErr)
-		}
-		if revErr := s.revocationStore.Revoke(ctx, sess.SessionID); revErr != nil {
-			return fmt.Errorf("revoke evicted session: %w", revErr)
-		}
-		sessionRevocationsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "session_limit")))
+	for _, sess := range activeSessions[:evictCount] {
+		evictions = append(evictions, sessionEviction{sessionID: sess.SessionID, reason: "session_limit"})
 	}
-	return nil
+	return evictions
 }
 
-// createLoginSession generates credentials and executes the login transaction.
-func (s *AuthService) createLoginSession(
+// verifyOTPExistingUser handles login: generates credentials and executes
+// the login transaction, evicting sessions to stay within the limit.
+func (s *AuthService) verifyOTPExistingUser(
 	ctx context.Context,
 	phoneHash string,
 	record *OTPRecord,
 	user *UserRecord,
-	deviceID string,
+	deviceID, clientIP string,
 ) (*VerifyOTPResult, error) {
 	sessionID := uuid.NewString()
-	now := s.clock.Now().UTC()
+	familyID := uuid.NewString()
+	now := domain.TimestampNow(s.clock)
 
 	refreshToken, err := auth.GenerateRefreshToken()
 	if err != nil {
 		return nil, fmt.Errorf("generate refresh token: %w", err)
 	}
-	refreshHash := auth.HashRefreshToken(refreshToken)
+	refreshHash, err := s.refreshHasher.Hash(refreshToken)
+	if err != nil {
+		return nil, err
+	}
 
-	sessionExpiry := now.Add(domain.RefreshTokenLifetime)
+	sessionExpiry := now.Add(s.refreshTTL)
 
 	params := LoginParams{
 		PhoneHash:        phoneHash,
 		OTPExpiresAt:     record.ExpiresAt,
 		OTPMAC:           record.OTPMAC,
 		SessionID:        sessionID,
+		FamilyID:         familyID,
 		UserID:           user.UserID,
 		DeviceID:         deviceID,
 		RefreshTokenHash: refreshHash,
-		CreatedAt:        now.Format(time.RFC3339),
-		SessionExpiresAt: sessionExpiry.Format(time.RFC3339),
-		SessionTTL:       sessionExpiry.Unix(),
+		CreatedAt:        now,
+		SessionExpiresAt: sessionExpiry,
+		SessionTTL:       sessionExpiry.Time().Unix(),
 	}
 
-	if txErr := s.transactor.VerifyOTPAndCreateSession(ctx, params); txErr != nil {
+	txErr := s.replaceSessions(ctx, user.UserID, deviceID, func(evictSessionIDs []string) error {
+		params.EvictSessionIDs = evictSessionIDs
+		return s.transactor.VerifyOTPAndCreateSession(ctx, params)
+	})
+	if txErr != nil {
 		return nil, fmt.Errorf("create login session: %w", txErr)
 	}
+	s.recordGeneration(ctx, TokenLineageEntry{
+		FamilyID:   familyID,
+		Generation: 1,
+		SessionID:  sessionID,
+		UserID:     user.UserID,
+		DeviceID:   deviceID,
+		ClientIP:   clientIP,
+		Event:      LineageLogin,
+	})
 
 	mintResult, err := s.minter.MintAccessToken(user.UserID, sessionID)
 	if err != nil {
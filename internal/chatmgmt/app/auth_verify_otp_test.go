@@ -3,6 +3,7 @@ package app_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -18,6 +19,7 @@ const (
 	testPhone    = "+15551234567"
 	testDeviceID = "device-abc-123"
 	testOTP      = "123456"
+	testClientIP = "203.0.113.10"
 )
 
 func TestVerifyOTP(t *testing.T) {
@@ -35,7 +37,7 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, domain.ErrNotFound
 		}
 
-		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		assert.True(t, result.IsNewUser)
@@ -43,6 +45,7 @@ func TestVerifyOTP(t *testing.T) {
 		assert.NotEmpty(t, result.RefreshToken)
 		assert.NotEmpty(t, result.SessionID)
 		assert.Equal(t, testPhone, result.User.PhoneNumber)
+		assert.Equal(t, []string{result.User.UserID + "/true"}, h.analytics.started)
 	})
 
 	t.Run("existing user success: login session created + tokens minted", func(t *testing.T) {
@@ -60,13 +63,14 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, nil
 		}
 
-		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		assert.False(t, result.IsNewUser)
 		assert.NotEmpty(t, result.AccessToken)
 		assert.NotEmpty(t, result.RefreshToken)
 		assert.Equal(t, user.UserID, result.User.UserID)
+		assert.Equal(t, []string{user.UserID + "/false"}, h.analytics.started)
 	})
 
 	t.Run("invalid OTP code: attempt incremented + ErrInvalidOTP", func(t *testing.T) {
@@ -78,17 +82,60 @@ func TestVerifyOTP(t *testing.T) {
 		}
 
 		incrementCalled := false
-		h.otpStore.incrementAttemptsFn = func(_ context.Context, _ string) error {
+		h.otpStore.incrementAttemptsFn = func(_ context.Context, _ string, limit int) (int, error) {
 			incrementCalled = true
+			assert.Equal(t, domain.MaxOTPVerifyAttempts, limit)
+			return 1, nil
+		}
+		h.verifyLimiter.lockFn = func(context.Context, string, int) error {
+			t.Error("no lockout before the limit")
 			return nil
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, "000000", testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, "000000", testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
 		assert.True(t, incrementCalled, "IncrementAttempts should be called on bad OTP")
 	})
 
+	t.Run("failure reaching the limit: lockout set + ErrInvalidOTP", func(t *testing.T) {
+		h := newTestHarness(t)
+		record := sampleOTPRecord(testPhoneHash, h.clock)
+		record.AttemptCount = domain.MaxOTPVerifyAttempts - 1
+		h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) { return record, nil }
+		h.otpStore.incrementAttemptsFn = func(context.Context, string, int) (int, error) {
+			return domain.MaxOTPVerifyAttempts, nil
+		}
+		var locked []string
+		h.verifyLimiter.lockFn = func(_ context.Context, phoneHash string, _ int) error {
+			locked = append(locked, phoneHash)
+			return nil
+		}
+
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, "000000", testDeviceID, testClientIP)
+		require.ErrorIs(t, err, domain.ErrInvalidOTP)
+		assert.Equal(t, []string{testPhoneHash}, locked)
+	})
+
+	t.Run("concurrent failure past the limit: lockout set + ErrRateLimited", func(t *testing.T) {
+		h := newTestHarness(t)
+		record := sampleOTPRecord(testPhoneHash, h.clock)
+		record.AttemptCount = domain.MaxOTPVerifyAttempts - 1 // read before the others counted
+		h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) { return record, nil }
+		h.otpStore.incrementAttemptsFn = func(context.Context, string, int) (int, error) {
+			return domain.MaxOTPVerifyAttempts, fmt.Errorf("otp store: increment attempts: %w", domain.ErrRateLimited)
+		}
+		var locked []string
+		h.verifyLimiter.lockFn = func(_ context.Context, phoneHash string, _ int) error {
+			locked = append(locked, phoneHash)
+			return nil
+		}
+
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, "000000", testDeviceID, testClientIP)
+		require.ErrorIs(t, err, domain.ErrRateLimited)
+		assert.Equal(t, []string{testPhoneHash}, locked)
+	})
+
 	t.Run("expired OTP: returns ErrInvalidOTP", func(t *testing.T) {
 		h := newTestHarness(t)
 		record := sampleOTPRecord(testPhoneHash, h.clock)
@@ -100,7 +147,7 @@ func TestVerifyOTP(t *testing.T) {
 		// Advance clock past OTP expiry.
 		h.clock.Advance(domain.OTPValidityDuration + time.Minute)
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
 	})
@@ -114,16 +161,16 @@ func TestVerifyOTP(t *testing.T) {
 			return record, nil
 		}
 
-		lockoutSet := false
-		h.rateLimiter.setLockoutFn = func(_ context.Context, _ string, _ int) error {
-			lockoutSet = true
+		var locked []string
+		h.verifyLimiter.lockFn = func(_ context.Context, phoneHash string, _ int) error {
+			locked = append(locked, phoneHash)
 			return nil
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrRateLimited)
-		assert.True(t, lockoutSet, "lockout should be set on max attempts")
+		assert.Equal(t, []string{testPhoneHash}, locked, "lockout should be set on max attempts")
 	})
 
 	t.Run("phone sentinel race: falls back to existing user flow", func(t *testing.T) {
@@ -153,7 +200,7 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, nil
 		}
 
-		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		assert.False(t, result.IsNewUser, "should fall back to existing user flow")
@@ -179,25 +226,29 @@ func TestVerifyOTP(t *testing.T) {
 				SessionID: "sess-" + string(rune('A'+i)),
 				UserID:    user.UserID,
 				DeviceID:  "other-device-" + string(rune('A'+i)),
-				CreatedAt: testStart.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
-				ExpiresAt: testStart.Add(30 * 24 * time.Hour).Format(time.RFC3339),
+				CreatedAt: domain.NewTimestampMS(testStart.Add(time.Duration(i) * time.Hour)),
+				ExpiresAt: domain.NewTimestampMS(testStart.Add(30 * 24 * time.Hour)),
 			}
 		}
 		h.sessionStore.listByUserFn = func(_ context.Context, _ string) ([]app.SessionRecord, error) {
 			return sessions, nil
 		}
 
-		deletedSessions := make([]string, 0)
-		h.sessionStore.deleteFn = func(_ context.Context, sessionID string) error {
-			deletedSessions = append(deletedSessions, sessionID)
+		var evicted []string
+		h.transactor.verifyOTPAndCreateSessionFn = func(_ context.Context, p app.LoginParams) error {
+			evicted = p.EvictSessionIDs
+			return nil
+		}
+		h.sessionStore.deleteFn = func(_ context.Context, _ string) error {
+			t.Fatal("evicted session deleted outside the login transaction")
 			return nil
 		}
 
-		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
 		// Oldest session should be evicted.
-		assert.Contains(t, deletedSessions, "sess-A", "oldest session should be evicted")
+		assert.Equal(t, []string{"sess-A"}, evicted, "oldest session should be evicted")
 	})
 
 	t.Run("device replacement: existing session with same device deleted", func(t *testing.T) {
@@ -219,44 +270,44 @@ func TestVerifyOTP(t *testing.T) {
 					SessionID: "old-session",
 					UserID:    user.UserID,
 					DeviceID:  testDeviceID,
-					CreatedAt: testStart.Add(-time.Hour).Format(time.RFC3339),
+					CreatedAt: domain.NewTimestampMS(testStart.Add(-time.Hour)),
 				},
 			}, nil
 		}
 
-		deletedSessions := make([]string, 0)
-		h.sessionStore.deleteFn = func(_ context.Context, sessionID string) error {
-			deletedSessions = append(deletedSessions, sessionID)
+		var evicted []string
+		h.transactor.verifyOTPAndCreateSessionFn = func(_ context.Context, p app.LoginParams) error {
+			evicted = p.EvictSessionIDs
 			return nil
 		}
 
-		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.NoError(t, err)
 		require.NotNil(t, result)
-		assert.Contains(t, deletedSessions, "old-session", "old session with same device should be deleted")
+		assert.Equal(t, []string{"old-session"}, evicted, "old session with same device should be deleted")
 	})
 
 	t.Run("verify rate limit exceeded: returns ErrRateLimited", func(t *testing.T) {
 		h := newTestHarness(t)
-		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
-			if key == "otp_verify:phone:"+testPhoneHash {
-				return false, nil
+		h.verifyLimiter.admitFn = func(_ context.Context, phoneHash string, _, _ int) (app.OTPVerifyAdmission, error) {
+			if phoneHash == testPhoneHash {
+				return app.OTPVerifyRateLimited, nil
 			}
-			return true, nil
+			return app.OTPVerifyAdmitted, nil
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrRateLimited)
 	})
 
 	t.Run("lockout active: returns ErrRateLimited", func(t *testing.T) {
 		h := newTestHarness(t)
-		h.rateLimiter.checkLockoutFn = func(_ context.Context, _ string) (bool, error) {
-			return true, nil
+		h.verifyLimiter.admitFn = func(context.Context, string, int, int) (app.OTPVerifyAdmission, error) {
+			return app.OTPVerifyLockedOut, nil
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrRateLimited)
 	})
@@ -264,7 +315,7 @@ func TestVerifyOTP(t *testing.T) {
 	t.Run("empty device ID: returns ErrInvalidInput", func(t *testing.T) {
 		h := newTestHarness(t)
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, "")
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, "", testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidInput)
 	})
@@ -275,7 +326,7 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, domain.ErrNotFound
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
 	})
@@ -289,7 +340,7 @@ func TestVerifyOTP(t *testing.T) {
 			return record, nil
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
 	})
@@ -306,7 +357,7 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, errDB
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errDB)
 	})
@@ -327,16 +378,15 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, errDB
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errDB)
 	})
 
-	t.Run("evict session delete failure: returns wrapped error", func(t *testing.T) {
+	t.Run("evicted session already gone: retries with a fresh list", func(t *testing.T) {
 		h := newTestHarness(t)
 		record := sampleOTPRecord(testPhoneHash, h.clock)
 		user := sampleUserRecord()
-		errDB := errors.New("conditional check failed")
 
 		h.otpStore.getOTPFn = func(_ context.Context, _ string) (*app.OTPRecord, error) {
 			return record, nil
@@ -344,18 +394,33 @@ func TestVerifyOTP(t *testing.T) {
 		h.userStore.findByPhoneFn = func(_ context.Context, _ string) (*app.UserRecord, error) {
 			return user, nil
 		}
-		h.sessionStore.listByUserFn = func(_ context.Context, _ string) ([]app.SessionRecord, error) {
-			return []app.SessionRecord{
-				{SessionID: "old-session", UserID: user.UserID, DeviceID: testDeviceID},
-			}, nil
+		// A concurrent logout removes old-session between the first read
+		// and the login transaction.
+		lists := 0
+		h.sessionStore.getByUserAndDeviceFn = func(_ context.Context, _, deviceID string) ([]app.SessionRecord, error) {
+			assert.Equal(t, testDeviceID, deviceID)
+			lists++
+			if lists == 1 {
+				return []app.SessionRecord{
+					{SessionID: "old-session", UserID: user.UserID, DeviceID: testDeviceID},
+				}, nil
+			}
+			return nil, nil
 		}
-		h.sessionStore.deleteFn = func(_ context.Context, _ string) error {
-			return errDB
+		var evicted [][]string
+		h.transactor.verifyOTPAndCreateSessionFn = func(_ context.Context, p app.LoginParams) error {
+			evicted = append(evicted, p.EvictSessionIDs)
+			if len(p.EvictSessionIDs) > 0 {
+				return fmt.Errorf("transactor: evict: %w", domain.ErrVersionConflict)
+			}
+			return nil
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
-		require.Error(t, err)
-		assert.ErrorIs(t, err, errDB)
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, 2, lists)
+		assert.Equal(t, [][]string{{"old-session"}, {}}, evicted)
 	})
 
 	t.Run("evict session revoke failure: returns wrapped error", func(t *testing.T) {
@@ -375,11 +440,11 @@ func TestVerifyOTP(t *testing.T) {
 				{SessionID: "old-session", UserID: user.UserID, DeviceID: testDeviceID},
 			}, nil
 		}
-		h.revocationStore.revokeFn = func(_ context.Context, _ string) error {
+		h.revocationStore.revokeFn = func(_ context.Context, _ string, _ app.Revocation) error {
 			return errRedis
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errRedis)
 	})
@@ -403,7 +468,7 @@ func TestVerifyOTP(t *testing.T) {
 			return errTx
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errTx)
 	})
@@ -423,7 +488,7 @@ func TestVerifyOTP(t *testing.T) {
 			return errTx
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errTx)
 	})
@@ -448,7 +513,7 @@ func TestVerifyOTP(t *testing.T) {
 			return domain.ErrAlreadyExists
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errDB)
 	})
@@ -456,37 +521,20 @@ func TestVerifyOTP(t *testing.T) {
 	t.Run("invalid phone number: returns error", func(t *testing.T) {
 		h := newTestHarness(t)
 
-		_, err := h.svc.VerifyOTP(context.Background(), "not-a-phone", testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), "not-a-phone", testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
 	})
 
-	t.Run("CheckAndIncrement Redis error: returns ErrUnavailable (fail-closed → 503)", func(t *testing.T) {
+	t.Run("Admit Redis error: returns ErrUnavailable (fail-closed → 503)", func(t *testing.T) {
 		h := newTestHarness(t)
 		errRedis := errors.New("redis connection refused")
 
-		h.rateLimiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
-			if key == "otp_verify:phone:"+testPhoneHash {
-				return false, errRedis
-			}
-			return true, nil
+		h.verifyLimiter.admitFn = func(context.Context, string, int, int) (app.OTPVerifyAdmission, error) {
+			return app.OTPVerifyLockedOut, errRedis
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
-		require.Error(t, err)
-		assert.ErrorIs(t, err, domain.ErrUnavailable)
-		assert.ErrorIs(t, err, errRedis)
-	})
-
-	t.Run("CheckLockout Redis error: returns ErrUnavailable (fail-closed → 503)", func(t *testing.T) {
-		h := newTestHarness(t)
-		errRedis := errors.New("redis connection refused")
-
-		h.rateLimiter.checkLockoutFn = func(_ context.Context, _ string) (bool, error) {
-			return false, errRedis
-		}
-
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrUnavailable)
 		assert.ErrorIs(t, err, errRedis)
@@ -500,16 +548,15 @@ func TestVerifyOTP(t *testing.T) {
 			return nil, errDB
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errDB)
 	})
 
-	t.Run("enforceSessionLimit delete failure: returns wrapped error", func(t *testing.T) {
+	t.Run("evicted sessions keep changing: returns ErrVersionConflict", func(t *testing.T) {
 		h := newTestHarness(t)
 		record := sampleOTPRecord(testPhoneHash, h.clock)
 		user := sampleUserRecord()
-		errDB := errors.New("conditional check failed")
 
 		h.otpStore.getOTPFn = func(_ context.Context, _ string) (*app.OTPRecord, error) {
 			return record, nil
@@ -517,31 +564,23 @@ func TestVerifyOTP(t *testing.T) {
 		h.userStore.findByPhoneFn = func(_ context.Context, _ string) (*app.UserRecord, error) {
 			return user, nil
 		}
-
-		// MaxSessionsPerUser sessions with different devices triggers eviction.
-		sessions := make([]app.SessionRecord, domain.MaxSessionsPerUser)
-		for i := range sessions {
-			sessions[i] = app.SessionRecord{
-				SessionID: "sess-" + string(rune('A'+i)),
-				UserID:    user.UserID,
-				DeviceID:  "other-device-" + string(rune('A'+i)),
-				CreatedAt: testStart.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
-				ExpiresAt: testStart.Add(30 * 24 * time.Hour).Format(time.RFC3339),
-			}
-		}
 		h.sessionStore.listByUserFn = func(_ context.Context, _ string) ([]app.SessionRecord, error) {
-			return sessions, nil
+			return []app.SessionRecord{
+				{SessionID: "old-session", UserID: user.UserID, DeviceID: testDeviceID},
+			}, nil
 		}
-		h.sessionStore.deleteFn = func(_ context.Context, _ string) error {
-			return errDB
+		attempts := 0
+		h.transactor.verifyOTPAndCreateSessionFn = func(_ context.Context, _ app.LoginParams) error {
+			attempts++
+			return fmt.Errorf("transactor: evict: %w", domain.ErrVersionConflict)
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
-		require.Error(t, err)
-		assert.ErrorIs(t, err, errDB)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
+		require.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.Equal(t, 3, attempts)
 	})
 
-	t.Run("enforceSessionLimit revoke failure: returns wrapped error", func(t *testing.T) {
+	t.Run("session limit revoke failure: returns wrapped error", func(t *testing.T) {
 		h := newTestHarness(t)
 		record := sampleOTPRecord(testPhoneHash, h.clock)
 		user := sampleUserRecord()
@@ -560,20 +599,172 @@ func TestVerifyOTP(t *testing.T) {
 				SessionID: "sess-" + string(rune('A'+i)),
 				UserID:    user.UserID,
 				DeviceID:  "other-device-" + string(rune('A'+i)),
-				CreatedAt: testStart.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
-				ExpiresAt: testStart.Add(30 * 24 * time.Hour).Format(time.RFC3339),
+				CreatedAt: domain.NewTimestampMS(testStart.Add(time.Duration(i) * time.Hour)),
+				ExpiresAt: domain.NewTimestampMS(testStart.Add(30 * 24 * time.Hour)),
 			}
 		}
 		h.sessionStore.listByUserFn = func(_ context.Context, _ string) ([]app.SessionRecord, error) {
 			return sessions, nil
 		}
-		// Delete succeeds, but revoke fails — partial failure.
-		h.revocationStore.revokeFn = func(_ context.Context, _ string) error {
+		// Revoke fails before the transaction deletes anything.
+		h.transactor.verifyOTPAndCreateSessionFn = func(_ context.Context, _ app.LoginParams) error {
+			t.Fatal("login transaction ran after a revoke failure")
+			return nil
+		}
+		h.revocationStore.revokeFn = func(_ context.Context, _ string, _ app.Revocation) error {
 			return errRedis
 		}
 
-		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID)
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
 		require.Error(t, err)
 		assert.ErrorIs(t, err, errRedis)
 	})
 }
+
+func TestVerifyOTP_Honeypot(t *testing.T) {
+	h := newTestHarness(t)
+	h.honeypot = domain.NewHoneypotPolicy(domain.HoneypotPolicyConfig{Numbers: []string{testPhone}})
+	h.svc = h.newService(0)
+	h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) {
+		t.Fatal("canary verification read a real OTP record")
+		return nil, nil
+	}
+
+	_, err := h.svc.VerifyOTP(context.Background(), testPhone, "000000", testDeviceID, testClientIP)
+
+	assert.ErrorIs(t, err, domain.ErrInvalidOTP)
+	require.Len(t, h.canaries.events, 1)
+	ev := h.canaries.events[0]
+	assert.Equal(t, app.CanaryAttempt, ev.Kind)
+	assert.Equal(t, "number", ev.Rule)
+	assert.Equal(t, "000000", ev.Candidate)
+	assert.Equal(t, testDeviceID, ev.DeviceID)
+}
+
+func TestVerifyOTP_CodePolicy(t *testing.T) {
+	testPhoneHash := auth.HashPhone(testPhone)
+	high := (*domain.OTPPolicy)(nil).Format(domain.OTPRiskHigh)
+
+	policyRecord := func(h *testHarness, params string) *app.OTPRecord {
+		record := sampleOTPRecord(testPhoneHash, h.clock)
+		record.CodeParams = params
+		record.OTPMAC = auth.ComputePolicyOTPMAC(testPepper, "AB23CD45", testPhoneHash, record.ExpiresAt.Time().Format(time.RFC3339), high.Params())
+		return record
+	}
+
+	t.Run("verifies under the issuing format, case-insensitively", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) {
+			return policyRecord(h, high.Params()), nil
+		}
+		h.userStore.findByPhoneFn = func(context.Context, string) (*app.UserRecord, error) {
+			return nil, domain.ErrNotFound
+		}
+
+		result, err := h.svc.VerifyOTP(context.Background(), testPhone, "ab23cd45", testDeviceID, testClientIP)
+
+		require.NoError(t, err)
+		assert.True(t, result.IsNewUser)
+	})
+
+	t.Run("rejects a record whose format was downgraded", func(t *testing.T) {
+		h := newTestHarness(t)
+		standard := (*domain.OTPPolicy)(nil).Format(domain.OTPRiskStandard)
+		h.otpStore.getOTPFn = func(context.Context, string) (*app.OTPRecord, error) {
+			return policyRecord(h, standard.Params()), nil
+		}
+
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, "AB23CD45", testDeviceID, testClientIP)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
+	})
+}
+
+func TestVerifyOTP_PepperRotation(t *testing.T) {
+	rotated := mustPepperRing(1, map[int][]byte{0: testPepper, 1: []byte("rotated-pepper-32-bytes-long-ok!")})
+
+	t.Run("OTPs issued before the rotation still verify", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.peppers = rotated
+		h.svc = h.newService(0)
+		legacyHash := auth.HashPhone(testPhone)
+		var looked []string
+		h.otpStore.getOTPFn = func(_ context.Context, phoneHash string) (*app.OTPRecord, error) {
+			looked = append(looked, phoneHash)
+			if phoneHash != legacyHash {
+				return nil, domain.ErrNotFound
+			}
+			return sampleOTPRecord(legacyHash, h.clock), nil
+		}
+		var limited []string
+		h.verifyLimiter.admitFn = func(_ context.Context, phoneHash string, _, _ int) (app.OTPVerifyAdmission, error) {
+			limited = append(limited, phoneHash)
+			return app.OTPVerifyAdmitted, nil
+		}
+		h.userStore.findByPhoneFn = func(context.Context, string) (*app.UserRecord, error) {
+			return nil, domain.ErrNotFound
+		}
+		var registered app.RegistrationParams
+		h.transactor.verifyOTPAndCreateUserFn = func(_ context.Context, params app.RegistrationParams) error {
+			registered = params
+			return nil
+		}
+
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{rotated.HashPhone(testPhone), legacyHash}, looked, "current version first")
+		assert.Equal(t, []string{rotated.HashPhone(testPhone)}, limited)
+		assert.Equal(t, legacyHash, registered.PhoneHash, "the record is consumed under its own hash")
+	})
+
+	t.Run("new OTPs are issued and verified under the current version", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.peppers = rotated
+		h.svc = h.newService(0)
+		var stored app.OTPRecord
+		h.otpStore.createOTPFn = func(_ context.Context, record app.OTPRecord) error {
+			stored = record
+			return nil
+		}
+		sent := make(chan string, 1)
+		h.smsProvider.sendOTPFn = func(_ context.Context, _, otp string) error {
+			sent <- otp
+			return nil
+		}
+		_, err := h.svc.RequestOTP(context.Background(), testPhone, testClientIP)
+		require.NoError(t, err)
+		h.svc.Wait()
+		otp := <-sent
+
+		assert.Equal(t, rotated.HashPhone(testPhone), stored.PhoneHash)
+		version, err := auth.PepperVersion(stored.OTPMAC)
+		require.NoError(t, err)
+		assert.Equal(t, 1, version)
+
+		h.otpStore.getOTPFn = func(_ context.Context, phoneHash string) (*app.OTPRecord, error) {
+			if phoneHash != stored.PhoneHash {
+				return nil, domain.ErrNotFound
+			}
+			return &stored, nil
+		}
+		h.userStore.findByPhoneFn = func(context.Context, string) (*app.UserRecord, error) {
+			return nil, domain.ErrNotFound
+		}
+		_, err = h.svc.VerifyOTP(context.Background(), testPhone, otp, testDeviceID, testClientIP)
+		require.NoError(t, err)
+	})
+
+	t.Run("OTPs under a retired version fail", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.peppers = mustPepperRing(1, map[int][]byte{1: []byte("rotated-pepper-32-bytes-long-ok!")})
+		h.svc = h.newService(0)
+		h.otpStore.getOTPFn = func(_ context.Context, phoneHash string) (*app.OTPRecord, error) {
+			return sampleOTPRecord(phoneHash, h.clock), nil // unversioned MAC
+		}
+
+		_, err := h.svc.VerifyOTP(context.Background(), testPhone, testOTP, testDeviceID, testClientIP)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
+	})
+}
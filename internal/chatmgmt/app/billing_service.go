@@ -0,0 +1,269 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+var billingWebhooksTotal metric.Int64Counter
+
+func init() {
+	billingWebhooksTotal, _ = otel.Meter("chatmgmt/app").Int64Counter("billing_webhooks_total",
+		metric.WithDescription("Payment provider webhooks by event type and outcome (applied, stale, ignored, rejected, failed)"))
+}
+
+// Payment provider event types. Other types are acknowledged and ignored.
+const (
+	BillingSubscriptionCreated = "subscription.created"
+	BillingSubscriptionUpdated = "subscription.updated"
+	BillingSubscriptionDeleted = "subscription.deleted"
+)
+
+// EntitlementStore persists one entitlement per subject.
+type EntitlementStore interface {
+	// GetEntitlement returns domain.ErrNotFound for a subject that has
+	// never had one.
+	GetEntitlement(ctx context.Context, subject domain.EntitlementSubject) (domain.Entitlement, error)
+	// PutEntitlement stores ent unless the stored entitlement's EventAt is
+	// not before ent's, in which case it returns domain.ErrVersionConflict.
+	PutEntitlement(ctx context.Context, ent domain.Entitlement) error
+}
+
+// EntitlementReader returns the limits a subject is entitled to. The
+// *BillingService satisfies this.
+type EntitlementReader interface {
+	Limits(ctx context.Context, subject domain.EntitlementSubject) domain.Limits
+}
+
+// BillingServiceConfig holds the dependencies for BillingService.
+type BillingServiceConfig struct {
+	Store EntitlementStore
+	// WebhookSecrets are the signing secrets shared with the payment
+	// provider. A delivery signed with any of them is accepted, so a
+	// secret can be rotated without dropping webhooks.
+	WebhookSecrets [][]byte
+	Clock          domain.Clock
+	Logger         *slog.Logger
+}
+
+// BillingService keeps entitlements in step with the payment provider and
+// answers limit lookups for the services that enforce them. It never
+// talks to the provider: subscriptions change only through signed
+// webhooks.
+type BillingService struct {
+	store   EntitlementStore
+	secrets [][]byte
+	clock   domain.Clock
+	logger  *slog.Logger
+}
+
+// NewBillingService creates a new BillingService with the given
+// dependencies.
+func NewBillingService(cfg BillingServiceConfig) *BillingService {
+	return &BillingService{
+		store:   cfg.Store,
+		secrets: cfg.WebhookSecrets,
+		clock:   cfg.Clock,
+		logger:  cfg.Logger,
+	}
+}
+
+// billingEvent is a payment provider webhook body. Times are Unix
+// seconds.
+type billingEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		SubjectType        string `json:"subject_type"`
+		SubjectID          string `json:"subject_id"`
+		Tier               string `json:"tier"`
+		CurrentPeriodEnd   int64  `json:"current_period_end"`
+		MaxGroupSize       int    `json:"max_group_size"`
+		MaxAttachmentBytes int64  `json:"max_attachment_bytes"`
+	} `json:"data"`
+}
+
+// HandleWebhook verifies and applies one webhook delivery. signature is
+// the provider's signature header. A bad signature returns
+// domain.ErrUnauthorized and a malformed event domain.ErrInvalidInput.
+// Events older than the stored entitlement, redeliveries included, and
+// event types that do not change entitlements return nil, so the provider
+// stops retrying them.
+func (s *BillingService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	ctx, span := tracer.Start(ctx, "billing.webhook")
+	defer span.End()
+	logger := observability.WithTraceID(ctx, s.logger)
+
+	if err := verifyBillingSignature(s.secrets, signature, payload, s.clock.Now()); err != nil {
+		countBillingWebhook(ctx, "", "rejected")
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "billing_signature")))
+		logger.WarnContext(ctx, "billing.signature_rejected", "error", err)
+		return fmt.Errorf("billing webhook: %w: %w", domain.ErrUnauthorized, err)
+	}
+
+	var ev billingEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		countBillingWebhook(ctx, "", "rejected")
+		return fmt.Errorf("billing webhook: %w", domain.NewValidationError("body", "is not a billing event"))
+	}
+	span.SetAttributes(attribute.String("billing.event_type", ev.Type), attribute.String("billing.event_id", ev.ID))
+
+	ent, ok, err := s.entitlementFor(ev)
+	if err != nil {
+		countBillingWebhook(ctx, ev.Type, "rejected")
+		return fmt.Errorf("billing webhook: %w", err)
+	}
+	if !ok {
+		countBillingWebhook(ctx, ev.Type, "ignored")
+		logger.InfoContext(ctx, "billing.event_ignored", "event_id", ev.ID, "event_type", ev.Type)
+		return nil
+	}
+
+	switch err := s.store.PutEntitlement(ctx, ent); {
+	case errors.Is(err, domain.ErrVersionConflict):
+		countBillingWebhook(ctx, ev.Type, "stale")
+		logger.InfoContext(ctx, "billing.event_stale", "event_id", ev.ID, "subject", ent.Subject.String())
+		return nil
+	case err != nil:
+		countBillingWebhook(ctx, ev.Type, "failed")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("billing webhook: put entitlement: %w", err)
+	}
+
+	countBillingWebhook(ctx, ev.Type, "applied")
+	logger.InfoContext(ctx, "billing.entitlement_updated",
+		"event_id", ev.ID,
+		"subject", ent.Subject.String(),
+		"tier", string(ent.Tier),
+	)
+	return nil
+}
+
+// entitlementFor converts ev to the entitlement it sets. ok is false for
+// event types that set none.
+func (s *BillingService) entitlementFor(ev billingEvent) (domain.Entitlement, bool, error) {
+	var tier domain.Tier
+	switch ev.Type {
+	case BillingSubscriptionCreated, BillingSubscriptionUpdated:
+		tier = domain.Tier(ev.Data.Tier)
+	case BillingSubscriptionDeleted:
+		tier = domain.TierFree
+	default:
+		return domain.Entitlement{}, false, nil
+	}
+	if ev.ID == "" || ev.Created <= 0 {
+		return domain.Entitlement{}, false, domain.NewValidationError("id", "event id and created are required")
+	}
+
+	ent := domain.Entitlement{
+		Subject:   domain.EntitlementSubject{Kind: domain.SubjectKind(ev.Data.SubjectType), ID: ev.Data.SubjectID},
+		Tier:      tier,
+		EventID:   ev.ID,
+		EventAt:   time.Unix(ev.Created, 0).UTC(),
+		UpdatedAt: s.clock.Now().UTC(),
+	}
+	if tier != domain.TierFree {
+		ent.Limits = domain.Limits{MaxGroupSize: ev.Data.MaxGroupSize, MaxAttachmentSize: ev.Data.MaxAttachmentBytes}
+		if ev.Data.CurrentPeriodEnd > 0 {
+			ent.PaidUntil = time.Unix(ev.Data.CurrentPeriodEnd, 0).UTC()
+		}
+	}
+	if err := ent.Validate(); err != nil {
+		return domain.Entitlement{}, false, err
+	}
+	return ent, true, nil
+}
+
+// Limits returns the limits subject is entitled to now. A subject without
+// an entitlement gets the free limits, and so does one whose entitlement
+// cannot be read: a store outage must not grant premium limits, and free
+// limits keep every service working.
+func (s *BillingService) Limits(ctx context.Context, subject domain.EntitlementSubject) domain.Limits {
+	ent, err := s.store.GetEntitlement(ctx, subject)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			observability.WithTraceID(ctx, s.logger).WarnContext(ctx, "billing.entitlement_unavailable",
+				"subject", subject.String(),
+				"error", err,
+			)
+		}
+		return domain.TierLimits(domain.TierFree)
+	}
+	return ent.Effective(s.clock.Now())
+}
+
+// verifyBillingSignature checks a "t=<unix>,v1=<hex>[,v1=<hex>...]"
+// signature header: each v1 is a hex HMAC-SHA256 of "<t>.<payload>", and
+// one must match a secret. t must be within domain.BillingWebhookTolerance
+// of now so a captured delivery cannot be replayed later.
+func verifyBillingSignature(secrets [][]byte, header string, payload []byte, now time.Time) error {
+	var (
+		ts   string
+		sigs [][]byte
+	)
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return errors.New("malformed signature header")
+	}
+	if skew := now.Sub(time.Unix(unix, 0)).Abs(); skew > domain.BillingWebhookTolerance {
+		return fmt.Errorf("signature timestamp is %s from now", skew.Round(time.Second))
+	}
+
+	for _, secret := range secrets {
+		for _, sig := range sigs {
+			if auth.VerifyHMACSHA256(secret, sig, []byte(ts+"."), payload) {
+				return nil
+			}
+		}
+	}
+	return errors.New("no signature matches")
+}
+
+// SignBillingPayload returns the signature header for payload sent at t,
+// as the payment provider computes it, for tests that exercise the webhook
+// endpoint.
+func SignBillingPayload(secret, payload []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(payload)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func countBillingWebhook(ctx context.Context, eventType, outcome string) {
+	billingWebhooksTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("event_type", eventType),
+		attribute.String("outcome", outcome),
+	))
+}
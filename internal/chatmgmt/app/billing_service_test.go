@@ -0,0 +1,151 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+var (
+	billingSecret    = []byte("whsec-current-0123456789")
+	billingOldSecret = []byte("whsec-previous-012345678")
+)
+
+// memEntitlementStore keeps entitlements in memory with the store's
+// EventAt ordering.
+type memEntitlementStore struct {
+	ents   map[domain.EntitlementSubject]domain.Entitlement
+	getErr error
+}
+
+func newEntitlementStore() *memEntitlementStore {
+	return &memEntitlementStore{ents: map[domain.EntitlementSubject]domain.Entitlement{}}
+}
+
+func (s *memEntitlementStore) GetEntitlement(_ context.Context, subject domain.EntitlementSubject) (domain.Entitlement, error) {
+	if s.getErr != nil {
+		return domain.Entitlement{}, s.getErr
+	}
+	ent, ok := s.ents[subject]
+	if !ok {
+		return domain.Entitlement{}, domain.ErrNotFound
+	}
+	return ent, nil
+}
+
+func (s *memEntitlementStore) PutEntitlement(_ context.Context, ent domain.Entitlement) error {
+	if old, ok := s.ents[ent.Subject]; ok && !old.EventAt.Before(ent.EventAt) {
+		return domain.ErrVersionConflict
+	}
+	s.ents[ent.Subject] = ent
+	return nil
+}
+
+func newBillingService(store app.EntitlementStore, clock domain.Clock) *app.BillingService {
+	return app.NewBillingService(app.BillingServiceConfig{
+		Store:          store,
+		WebhookSecrets: [][]byte{billingSecret, billingOldSecret},
+		Clock:          clock,
+		Logger:         slog.Default(),
+	})
+}
+
+func subscriptionEvent(id, typ string, created time.Time, tier string, periodEnd time.Time) []byte {
+	return fmt.Appendf(nil, `{"id":%q,"type":%q,"created":%d,"data":{"subject_type":"user","subject_id":"user-001","tier":%q,"current_period_end":%d}}`,
+		id, typ, created.Unix(), tier, periodEnd.Unix())
+}
+
+func TestBillingService_HandleWebhook(t *testing.T) {
+	ctx := context.Background()
+	now := testStart
+	subject := domain.UserSubject("user-001")
+
+	t.Run("applies subscriptions in event order", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(now)
+		store := newEntitlementStore()
+		svc := newBillingService(store, clock)
+		created := subscriptionEvent("evt_1", app.BillingSubscriptionCreated, now.Add(-time.Minute), "premium", now.Add(30*24*time.Hour))
+		deleted := subscriptionEvent("evt_2", app.BillingSubscriptionDeleted, now, "", time.Time{})
+
+		require.NoError(t, svc.HandleWebhook(ctx, created, app.SignBillingPayload(billingSecret, created, now)))
+		assert.Equal(t, domain.TierLimits(domain.TierPremium), svc.Limits(ctx, subject))
+
+		require.NoError(t, svc.HandleWebhook(ctx, deleted, app.SignBillingPayload(billingOldSecret, deleted, now)))
+		assert.Equal(t, domain.TierLimits(domain.TierFree), svc.Limits(ctx, subject))
+
+		// The creation webhook redelivered after the cancellation is stale.
+		require.NoError(t, svc.HandleWebhook(ctx, created, app.SignBillingPayload(billingSecret, created, now)))
+		assert.Equal(t, domain.TierFree, store.ents[subject].Tier)
+		assert.Equal(t, "evt_2", store.ents[subject].EventID)
+	})
+
+	t.Run("rejects bad signatures", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(now)
+		store := newEntitlementStore()
+		svc := newBillingService(store, clock)
+		payload := subscriptionEvent("evt_1", app.BillingSubscriptionCreated, now, "premium", now.Add(time.Hour))
+
+		for name, sig := range map[string]string{
+			"missing":      "",
+			"wrong secret": app.SignBillingPayload([]byte("whsec-attacker-000000000"), payload, now),
+			"tampered":     app.SignBillingPayload(billingSecret, append([]byte(" "), payload...), now),
+			"replayed":     app.SignBillingPayload(billingSecret, payload, now.Add(-domain.BillingWebhookTolerance-time.Second)),
+		} {
+			err := svc.HandleWebhook(ctx, payload, sig)
+			assert.ErrorIs(t, err, domain.ErrUnauthorized, name)
+		}
+		assert.Empty(t, store.ents)
+	})
+
+	t.Run("rejects malformed events and ignores other types", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(now)
+		store := newEntitlementStore()
+		svc := newBillingService(store, clock)
+		sign := func(payload []byte) string { return app.SignBillingPayload(billingSecret, payload, now) }
+
+		gold := subscriptionEvent("evt_1", app.BillingSubscriptionUpdated, now, "gold", now.Add(time.Hour))
+		assert.ErrorIs(t, svc.HandleWebhook(ctx, gold, sign(gold)), domain.ErrInvalidInput)
+		notJSON := []byte("<xml/>")
+		assert.ErrorIs(t, svc.HandleWebhook(ctx, notJSON, sign(notJSON)), domain.ErrInvalidInput)
+
+		invoice := []byte(`{"id":"evt_9","type":"invoice.paid","created":1}`)
+		assert.NoError(t, svc.HandleWebhook(ctx, invoice, sign(invoice)))
+		assert.Empty(t, store.ents)
+	})
+}
+
+func TestBillingService_Limits(t *testing.T) {
+	ctx := context.Background()
+	subject := domain.UserSubject("user-001")
+
+	t.Run("contract overrides", func(t *testing.T) {
+		store := newEntitlementStore()
+		store.ents[subject] = domain.Entitlement{
+			Subject:   subject,
+			Tier:      domain.TierPremium,
+			Limits:    domain.Limits{MaxGroupSize: 5000},
+			PaidUntil: testStart.Add(time.Hour),
+		}
+		svc := newBillingService(store, domaintest.NewFakeClock(testStart))
+
+		assert.Equal(t, 5000, svc.Limits(ctx, subject).MaxGroupSize)
+	})
+
+	t.Run("store failure falls back to free", func(t *testing.T) {
+		store := newEntitlementStore()
+		store.getErr = errors.New("throttled")
+		svc := newBillingService(store, domaintest.NewFakeClock(testStart))
+
+		assert.Equal(t, domain.TierLimits(domain.TierFree), svc.Limits(ctx, subject))
+	})
+}
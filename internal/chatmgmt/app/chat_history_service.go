@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// ChatEventStore reads and compacts chats' event logs. The stores that
+// change settings and memberships append the events.
+type ChatEventStore interface {
+	// ListChatEvents returns chatID's events in log order, up to and
+	// including those made at until; a zero until returns them all.
+	ListChatEvents(ctx context.Context, chatID string, until time.Time) ([]domain.ChatEvent, error)
+	// ListCompactableChats returns the chats with events other than a
+	// snapshot made at or before cutoff.
+	ListCompactableChats(ctx context.Context, cutoff time.Time) ([]string, error)
+	// CompactChatEvents replaces folded, a prefix of a chat's log, with
+	// snapshot, which shares the last folded event's ID. Returns
+	// domain.ErrVersionConflict if another compactor got there first.
+	CompactChatEvents(ctx context.Context, snapshot domain.ChatEvent, folded []domain.ChatEvent) error
+}
+
+// ChatHistoryServiceConfig holds the dependencies for ChatHistoryService.
+type ChatHistoryServiceConfig struct {
+	Store  ChatEventStore
+	Clock  domain.Clock
+	Logger *slog.Logger
+	// Retention is how long events are kept before compaction folds them
+	// into a snapshot. Zero defaults to domain.ChatEventRetention.
+	Retention time.Duration
+}
+
+// ChatHistoryService answers what a chat's settings and members were at a
+// point in time, and compacts the event logs that record them.
+type ChatHistoryService struct {
+	store     ChatEventStore
+	clock     domain.Clock
+	logger    *slog.Logger
+	retention time.Duration
+}
+
+// NewChatHistoryService creates a new ChatHistoryService with the given
+// dependencies.
+func NewChatHistoryService(cfg ChatHistoryServiceConfig) *ChatHistoryService {
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = domain.ChatEventRetention
+	}
+	return &ChatHistoryService{
+		store:     cfg.Store,
+		clock:     cfg.Clock,
+		logger:    cfg.Logger,
+		retention: retention,
+	}
+}
+
+// ChatHistory returns chatID's events made at or before at, and the state
+// they leave the chat in; a zero at returns the whole log and the current
+// state. Events older than the retention period have been folded into a
+// snapshot, so an earlier at is refused rather than answered wrongly.
+func (s *ChatHistoryService) ChatHistory(ctx context.Context, chatID string, at time.Time) ([]domain.ChatEvent, domain.ChatState, error) {
+	ctx, span := tracer.Start(ctx, "chat_history.get")
+	defer span.End()
+
+	if !at.IsZero() && at.Before(s.clock.Now().Add(-s.retention)) {
+		return nil, domain.ChatState{}, fmt.Errorf("chat history: %w",
+			domain.NewValidationError("at", fmt.Sprintf("must be within the last %d days", int(s.retention/(24*time.Hour)))))
+	}
+	events, err := s.store.ListChatEvents(ctx, chatID, at)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, domain.ChatState{}, fmt.Errorf("chat history: %w", err)
+	}
+	span.SetAttributes(attribute.Int("chat_events.count", len(events)))
+	return events, domain.ReplayChatEvents(events), nil
+}
+
+// CompactChatEvents folds every chat's events older than the retention
+// period into one snapshot per chat, and returns how many chats it
+// compacted. A chat another compactor is working on is skipped.
+func (s *ChatHistoryService) CompactChatEvents(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "chat_history.compact")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	cutoff := s.clock.Now().Add(-s.retention)
+
+	chats, err := s.store.ListCompactableChats(ctx, cutoff)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("list compactable chats: %w", err)
+	}
+
+	var compacted int
+	for _, chatID := range chats {
+		folded, err := s.store.ListChatEvents(ctx, chatID, cutoff)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return compacted, fmt.Errorf("list chat events: %w", err)
+		}
+		snapshot := domain.ReplayChatEvents(folded).Snapshot(chatID)
+		if snapshot.EventID == "" {
+			continue
+		}
+		if err := s.store.CompactChatEvents(ctx, snapshot, folded); err != nil {
+			if errors.Is(err, domain.ErrVersionConflict) {
+				continue
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return compacted, fmt.Errorf("compact chat events: %w", err)
+		}
+		compacted++
+		logger.InfoContext(ctx, "chat_history.compacted", "chat_id", chatID, "events", len(folded))
+	}
+	span.SetAttributes(attribute.Int("chats.compacted", compacted))
+	return compacted, nil
+}
+
+// RunCompactor calls CompactChatEvents every interval until ctx is done.
+// Zero interval defaults to domain.ChatEventCompactInterval.
+func (s *ChatHistoryService) RunCompactor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = domain.ChatEventCompactInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.CompactChatEvents(ctx); err != nil && ctx.Err() == nil {
+				observability.WithTraceID(ctx, s.logger).ErrorContext(ctx, "chat event compaction failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+// memChatEventStore implements app.ChatEventStore over in-memory logs kept
+// in event ID order. compactErr, when set, fails every compaction.
+type memChatEventStore struct {
+	logs       map[string][]domain.ChatEvent
+	compactErr error
+}
+
+func (s *memChatEventStore) ListChatEvents(_ context.Context, chatID string, until time.Time) ([]domain.ChatEvent, error) {
+	var out []domain.ChatEvent
+	for _, e := range s.logs[chatID] {
+		if until.IsZero() || e.EventID <= domain.LastChatEventID(until) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *memChatEventStore) ListCompactableChats(_ context.Context, cutoff time.Time) ([]string, error) {
+	var chats []string
+	for chatID, log := range s.logs {
+		for _, e := range log {
+			if e.Type != domain.ChatEventSnapshot && e.EventID <= domain.LastChatEventID(cutoff) {
+				chats = append(chats, chatID)
+				break
+			}
+		}
+	}
+	return chats, nil
+}
+
+func (s *memChatEventStore) CompactChatEvents(_ context.Context, snapshot domain.ChatEvent, folded []domain.ChatEvent) error {
+	if s.compactErr != nil {
+		return s.compactErr
+	}
+	log := s.logs[snapshot.ChatID]
+	s.logs[snapshot.ChatID] = append([]domain.ChatEvent{snapshot}, log[len(folded):]...)
+	return nil
+}
+
+// chatEventAt returns an event of typ about userID stamped with at.
+func chatEventAt(at time.Time, typ domain.ChatEventType, userID string, role domain.MemberRole) domain.ChatEvent {
+	return domain.ChatEvent{
+		ChatID:  settingsChatID,
+		EventID: domain.NewChatEventID(at),
+		Type:    typ,
+		At:      at,
+		UserID:  userID,
+		Role:    role,
+	}
+}
+
+func newChatHistoryService(store *memChatEventStore) *app.ChatHistoryService {
+	return app.NewChatHistoryService(app.ChatHistoryServiceConfig{
+		Store:  store,
+		Clock:  domaintest.NewFakeClock(testStart),
+		Logger: slog.Default(),
+	})
+}
+
+func TestChatHistoryService(t *testing.T) {
+	ctx := context.Background()
+	old := testStart.Add(-domain.ChatEventRetention - time.Hour)
+	recent := testStart.Add(-time.Hour)
+	newLog := func() *memChatEventStore {
+		return &memChatEventStore{logs: map[string][]domain.ChatEvent{settingsChatID: {
+			chatEventAt(old, domain.ChatEventMemberJoined, "user-001", domain.MemberRoleOwner),
+			chatEventAt(old.Add(time.Minute), domain.ChatEventMemberJoined, "user-002", domain.MemberRoleMember),
+			chatEventAt(recent, domain.ChatEventRoleChanged, "user-002", domain.MemberRoleAdmin),
+		}}}
+	}
+
+	t.Run("reconstructs the state at a point in time", func(t *testing.T) {
+		svc := newChatHistoryService(newLog())
+
+		events, before, err := svc.ChatHistory(ctx, settingsChatID, recent.Add(-time.Minute))
+		require.NoError(t, err)
+		_, now, err := svc.ChatHistory(ctx, settingsChatID, time.Time{})
+		require.NoError(t, err)
+
+		assert.Len(t, events, 2)
+		assert.Equal(t, domain.MemberRoleMember, before.Members["user-002"])
+		assert.Equal(t, domain.MemberRoleAdmin, now.Members["user-002"])
+	})
+
+	t.Run("refuses times before the retention period", func(t *testing.T) {
+		svc := newChatHistoryService(newLog())
+
+		_, _, err := svc.ChatHistory(ctx, settingsChatID, old)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("compaction folds old events into a snapshot", func(t *testing.T) {
+		store := newLog()
+		svc := newChatHistoryService(store)
+		_, want, err := svc.ChatHistory(ctx, settingsChatID, time.Time{})
+		require.NoError(t, err)
+
+		compacted, err := svc.CompactChatEvents(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, compacted)
+		log := store.logs[settingsChatID]
+		require.Len(t, log, 2)
+		assert.Equal(t, domain.ChatEventSnapshot, log[0].Type)
+		_, got, err := svc.ChatHistory(ctx, settingsChatID, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, want.Members, got.Members)
+
+		again, err := svc.CompactChatEvents(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, again)
+	})
+
+	t.Run("chat compacted concurrently is skipped", func(t *testing.T) {
+		svc := newChatHistoryService(&memChatEventStore{
+			logs:       newLog().logs,
+			compactErr: domain.ErrVersionConflict,
+		})
+
+		compacted, err := svc.CompactChatEvents(ctx)
+
+		require.NoError(t, err)
+		assert.Zero(t, compacted)
+	})
+
+	t.Run("store failure stops compaction", func(t *testing.T) {
+		svc := newChatHistoryService(&memChatEventStore{
+			logs:       newLog().logs,
+			compactErr: errors.New("throttled"),
+		})
+
+		_, err := svc.CompactChatEvents(ctx)
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// ChatSettingsRecord is a chat's settings at a version. Version starts at
+// 1 and increases by one on every update.
+type ChatSettingsRecord struct {
+	ChatID    string
+	ChatType  domain.ChatType
+	Settings  domain.ChatSettings
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// ChatSettingsStore reads and writes the settings on chat records.
+type ChatSettingsStore interface {
+	// GetChatSettings returns domain.ErrNotFound for an unknown chat.
+	GetChatSettings(ctx context.Context, chatID string) (ChatSettingsRecord, error)
+	// PutChatSettings stores settings, changed by actorID, as version
+	// expectedVersion+1 if the chat is still at expectedVersion, and
+	// returns domain.ErrVersionConflict otherwise.
+	PutChatSettings(ctx context.Context, chatID string, settings domain.ChatSettings, expectedVersion int64, actorID string, updatedAt time.Time) error
+}
+
+// MemberRoleReader resolves a user's role in a chat.
+type MemberRoleReader interface {
+	// MemberRole returns domain.ErrNotMember if userID is not in the chat.
+	MemberRole(ctx context.Context, chatID, userID string) (domain.MemberRole, error)
+}
+
+// SystemMessagePoster posts a system message into a chat. key identifies
+// the change; posting the same key twice yields one message.
+type SystemMessagePoster interface {
+	PostSystemMessage(ctx context.Context, chatID, key string, msg domain.SystemMessage) error
+}
+
+// ChatSettingsServiceConfig holds the dependencies for ChatSettingsService.
+type ChatSettingsServiceConfig struct {
+	Store     ChatSettingsStore
+	Roles     MemberRoleReader
+	System    SystemMessagePoster // nil disables settings-changed messages
+	Validator *auth.Validator
+	Clock     domain.Clock
+	Logger    *slog.Logger
+}
+
+// ChatSettingsService updates group chat settings on behalf of chat admins.
+type ChatSettingsService struct {
+	store     ChatSettingsStore
+	roles     MemberRoleReader
+	system    SystemMessagePoster
+	validator *auth.Validator
+	clock     domain.Clock
+	logger    *slog.Logger
+}
+
+// NewChatSettingsService creates a new ChatSettingsService with the given
+// dependencies.
+func NewChatSettingsService(cfg ChatSettingsServiceConfig) *ChatSettingsService {
+	return &ChatSettingsService{
+		store:     cfg.Store,
+		roles:     cfg.Roles,
+		system:    cfg.System,
+		validator: cfg.Validator,
+		clock:     cfg.Clock,
+		logger:    cfg.Logger,
+	}
+}
+
+// UpdateChatSettings applies patch to a group chat's settings. The caller
+// must be an admin or the owner.
+//
+// With a non-zero expectedVersion the update is conditional: if the chat
+// has moved past that version, before or during the write, the call fails
+// with a *domain.VersionConflictError carrying the current version and the
+// client re-reads before retrying. With zero, a concurrent update
+// is absorbed by re-reading and reapplying the patch, up to
+// domain.SettingsUpdateAttempts times.
+//
+// Each change is announced in the chat as a system message. A patch that
+// changes nothing returns the current record without writing.
+func (s *ChatSettingsService) UpdateChatSettings(
+	ctx context.Context, accessToken, chatID string, patch domain.ChatSettingsPatch, expectedVersion int64,
+) (ChatSettingsRecord, error) {
+	ctx, span := tracer.Start(ctx, "chat_settings.update")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, s.logger)
+
+	// 1. Authenticate.
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return ChatSettingsRecord{}, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	// 2. Authorize.
+	role, err := s.roles.MemberRole(ctx, chatID, claims.Subject)
+	if err != nil {
+		return ChatSettingsRecord{}, fmt.Errorf("update chat settings: %w", err)
+	}
+	if !role.AtLeast(domain.MemberRoleAdmin) {
+		return ChatSettingsRecord{}, fmt.Errorf("update chat settings: only admins can change settings: %w", domain.ErrForbidden)
+	}
+
+	// 3. Read, apply, conditionally write.
+	var (
+		rec     ChatSettingsRecord
+		changed []string
+	)
+	for attempt := 1; ; attempt++ {
+		rec, err = s.store.GetChatSettings(ctx, chatID)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return ChatSettingsRecord{}, fmt.Errorf("get chat settings: %w", err)
+		}
+		if rec.ChatType != domain.ChatTypeGroup {
+			return ChatSettingsRecord{}, fmt.Errorf("update chat settings: %w",
+				domain.NewValidationError("chat_id", "only group chats have settings"))
+		}
+		if expectedVersion != 0 && rec.Version != expectedVersion {
+			return ChatSettingsRecord{}, fmt.Errorf("update chat settings: %w",
+				domain.NewVersionConflictError(expectedVersion, rec.Version))
+		}
+
+		var next domain.ChatSettings
+		next, changed = patch.Apply(rec.Settings)
+		if len(changed) == 0 {
+			return rec, nil
+		}
+		if err := next.Validate(); err != nil {
+			return ChatSettingsRecord{}, fmt.Errorf("update chat settings: %w", err)
+		}
+
+		now := s.clock.Now()
+		err = s.store.PutChatSettings(ctx, chatID, next, rec.Version, claims.Subject, now)
+		if err == nil {
+			rec.Settings, rec.Version, rec.UpdatedAt = next, rec.Version+1, now
+			break
+		}
+		// On a lost race the next read either absorbs the concurrent write
+		// or, for a conditional update, reports the version that won.
+		if !errors.Is(err, domain.ErrVersionConflict) || attempt == domain.SettingsUpdateAttempts {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return ChatSettingsRecord{}, fmt.Errorf("put chat settings: %w", err)
+		}
+	}
+
+	span.SetAttributes(attribute.Int64("chat.settings_version", rec.Version))
+	logger.InfoContext(ctx, "chat_settings.updated",
+		"chat_id", chatID,
+		"user_id", claims.Subject,
+		"version", rec.Version,
+		"changed", changed,
+	)
+
+	// 4. Announce. The settings are committed; a failed post is logged
+	// rather than failing a request that has already taken effect.
+	if s.system != nil {
+		for i, msg := range domain.SettingsChangeMessages(claims.Subject, rec.Settings, changed) {
+			key := fmt.Sprintf("settings:%s:%d:%d", chatID, rec.Version, i)
+			if err := s.system.PostSystemMessage(ctx, chatID, key, msg); err != nil {
+				logger.WarnContext(ctx, "chat_settings.announce_failed",
+					"chat_id", chatID,
+					"version", rec.Version,
+					"event", msg.Event,
+					"error", err,
+				)
+			}
+		}
+	}
+
+	return rec, nil
+}
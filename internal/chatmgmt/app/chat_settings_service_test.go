@@ -0,0 +1,236 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// memSettingsStore implements app.ChatSettingsStore over a single record.
+// conflicts, when positive, makes that many puts fail with a version
+// conflict after bumping the stored version, as a concurrent writer would.
+// actor is the actor of the last put.
+type memSettingsStore struct {
+	rec       app.ChatSettingsRecord
+	puts      int
+	conflicts int
+	actor     string
+}
+
+func (s *memSettingsStore) GetChatSettings(_ context.Context, chatID string) (app.ChatSettingsRecord, error) {
+	if chatID != s.rec.ChatID {
+		return app.ChatSettingsRecord{}, domain.ErrNotFound
+	}
+	return s.rec, nil
+}
+
+func (s *memSettingsStore) PutChatSettings(_ context.Context, _ string, settings domain.ChatSettings, expectedVersion int64, actorID string, updatedAt time.Time) error {
+	s.puts++
+	s.actor = actorID
+	if s.conflicts > 0 {
+		s.conflicts--
+		s.rec.Version++
+		return domain.ErrVersionConflict
+	}
+	if expectedVersion != s.rec.Version {
+		return domain.ErrVersionConflict
+	}
+	s.rec.Settings, s.rec.Version, s.rec.UpdatedAt = settings, s.rec.Version+1, updatedAt
+	return nil
+}
+
+// stubRoles implements app.MemberRoleReader from a map; absent users are
+// not members.
+type stubRoles map[string]domain.MemberRole
+
+func (r stubRoles) MemberRole(_ context.Context, _, userID string) (domain.MemberRole, error) {
+	role, ok := r[userID]
+	if !ok {
+		return "", domain.ErrNotMember
+	}
+	return role, nil
+}
+
+// recordingPoster implements app.SystemMessagePoster, recording posts by key.
+type recordingPoster struct {
+	keys []string
+	msgs []domain.SystemMessage
+	err  error
+}
+
+func (p *recordingPoster) PostSystemMessage(_ context.Context, _, key string, msg domain.SystemMessage) error {
+	p.keys = append(p.keys, key)
+	p.msgs = append(p.msgs, msg)
+	return p.err
+}
+
+const settingsChatID = "chat-001"
+
+func newSettingsStore() *memSettingsStore {
+	return &memSettingsStore{rec: app.ChatSettingsRecord{
+		ChatID:   settingsChatID,
+		ChatType: domain.ChatTypeGroup,
+		Settings: domain.DefaultChatSettings("Team"),
+		Version:  1,
+	}}
+}
+
+func newChatSettingsService(h *testHarness, store app.ChatSettingsStore, roles app.MemberRoleReader, poster app.SystemMessagePoster) *app.ChatSettingsService {
+	return app.NewChatSettingsService(app.ChatSettingsServiceConfig{
+		Store:     store,
+		Roles:     roles,
+		System:    poster,
+		Validator: h.validator,
+		Clock:     h.clock,
+		Logger:    slog.Default(),
+	})
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestChatSettingsService_UpdateChatSettings(t *testing.T) {
+	ctx := context.Background()
+	admins := stubRoles{feedUserID: domain.MemberRoleAdmin}
+
+	t.Run("admin renames the chat", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, poster := newSettingsStore(), &recordingPoster{}
+		svc := newChatSettingsService(h, store, admins, poster)
+
+		rec, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID,
+			domain.ChatSettingsPatch{Name: ptr("Launch"), SlowModeSeconds: ptr(30)}, 1)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), rec.Version)
+		assert.Equal(t, "Launch", rec.Settings.Name)
+		assert.Equal(t, testStart, rec.UpdatedAt)
+		assert.Equal(t, rec, store.rec)
+		assert.Equal(t, feedUserID, store.actor)
+		assert.Equal(t, []string{"settings:chat-001:2:0", "settings:chat-001:2:1"}, poster.keys)
+		assert.Equal(t, domain.SystemEventChatRenamed, poster.msgs[0].Event)
+		assert.Equal(t, domain.SystemEventSettingsChanged, poster.msgs[1].Event)
+	})
+
+	t.Run("stale expected version", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newSettingsStore()
+		store.rec.Version = 3
+		svc := newChatSettingsService(h, store, admins, nil)
+
+		_, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, domain.ChatSettingsPatch{Name: ptr("Launch")}, 2)
+
+		var conflict *domain.VersionConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(3), conflict.Current)
+		assert.Zero(t, store.puts)
+	})
+
+	t.Run("conditional write conflict is returned when a version was expected", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newSettingsStore()
+		store.conflicts = 1
+		svc := newChatSettingsService(h, store, admins, nil)
+
+		_, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, domain.ChatSettingsPatch{Name: ptr("Launch")}, 1)
+
+		var conflict *domain.VersionConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(2), conflict.Current, "the version the concurrent write left")
+		assert.Equal(t, 1, store.puts)
+	})
+
+	t.Run("unconditional update retries a concurrent write", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newSettingsStore()
+		store.conflicts = 1
+		svc := newChatSettingsService(h, store, admins, nil)
+
+		rec, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, domain.ChatSettingsPatch{Name: ptr("Launch")}, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), rec.Version)
+		assert.Equal(t, 2, store.puts)
+	})
+
+	t.Run("gives up after the attempt budget", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newSettingsStore()
+		store.conflicts = domain.SettingsUpdateAttempts
+		svc := newChatSettingsService(h, store, admins, nil)
+
+		_, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, domain.ChatSettingsPatch{Name: ptr("Launch")}, 0)
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.Equal(t, domain.SettingsUpdateAttempts, store.puts)
+	})
+
+	t.Run("no-op patch does not write or announce", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, poster := newSettingsStore(), &recordingPoster{}
+		svc := newChatSettingsService(h, store, admins, poster)
+
+		rec, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, domain.ChatSettingsPatch{Name: ptr("Team")}, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), rec.Version)
+		assert.Zero(t, store.puts)
+		assert.Empty(t, poster.keys)
+	})
+
+	t.Run("announce failure does not fail the update", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newSettingsStore()
+		svc := newChatSettingsService(h, store, admins, &recordingPoster{err: errors.New("ingest down")})
+
+		_, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, domain.ChatSettingsPatch{Name: ptr("Launch")}, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Launch", store.rec.Settings.Name)
+	})
+
+	t.Run("rejections", func(t *testing.T) {
+		direct := newSettingsStore()
+		direct.rec.ChatType = domain.ChatTypeDirect
+
+		tests := []struct {
+			name    string
+			store   *memSettingsStore
+			roles   stubRoles
+			patch   domain.ChatSettingsPatch
+			wantErr error
+		}{
+			{"member cannot edit", newSettingsStore(), stubRoles{feedUserID: domain.MemberRoleMember}, domain.ChatSettingsPatch{Name: ptr("x")}, domain.ErrForbidden},
+			{"non-member", newSettingsStore(), stubRoles{}, domain.ChatSettingsPatch{Name: ptr("x")}, domain.ErrNotMember},
+			{"direct chat", direct, admins, domain.ChatSettingsPatch{Name: ptr("x")}, domain.ErrInvalidInput},
+			{"invalid value", newSettingsStore(), admins, domain.ChatSettingsPatch{SlowModeSeconds: ptr(-5)}, domain.ErrInvalidInput},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				h := newTestHarness(t)
+				svc := newChatSettingsService(h, tt.store, tt.roles, nil)
+
+				_, err := svc.UpdateChatSettings(ctx, feedToken(t, h), settingsChatID, tt.patch, 0)
+
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Zero(t, tt.store.puts)
+			})
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newChatSettingsService(h, newSettingsStore(), admins, nil)
+
+		_, err := svc.UpdateChatSettings(ctx, "garbage", settingsChatID, domain.ChatSettingsPatch{}, 0)
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
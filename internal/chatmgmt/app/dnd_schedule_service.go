@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// DNDScheduleStore holds each user's do-not-disturb schedule. An unset
+// schedule reads as the zero schedule.
+type DNDScheduleStore interface {
+	DNDSchedule(ctx context.Context, userID string) (domain.DNDSchedule, error)
+	SetDNDSchedule(ctx context.Context, userID string, s domain.DNDSchedule) error
+}
+
+// DNDScheduleServiceConfig holds the dependencies for DNDScheduleService.
+type DNDScheduleServiceConfig struct {
+	Store     DNDScheduleStore
+	Validator *auth.Validator
+}
+
+// DNDScheduleService reads and changes the caller's quiet hours. Fanout
+// holds push notifications that fall in them and sends a summary when
+// they end.
+type DNDScheduleService struct {
+	store     DNDScheduleStore
+	validator *auth.Validator
+}
+
+// NewDNDScheduleService creates a new DNDScheduleService with the given
+// dependencies.
+func NewDNDScheduleService(cfg DNDScheduleServiceConfig) *DNDScheduleService {
+	return &DNDScheduleService{store: cfg.Store, validator: cfg.Validator}
+}
+
+// GetDNDSchedule returns the caller's schedule.
+func (s *DNDScheduleService) GetDNDSchedule(ctx context.Context, accessToken string) (domain.DNDSchedule, error) {
+	ctx, span := tracer.Start(ctx, "dnd_schedule.get")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return domain.DNDSchedule{}, err
+	}
+	schedule, err := s.store.DNDSchedule(ctx, claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.DNDSchedule{}, fmt.Errorf("get dnd schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// SetDNDSchedule validates and replaces the caller's schedule.
+func (s *DNDScheduleService) SetDNDSchedule(ctx context.Context, accessToken string, schedule domain.DNDSchedule) (domain.DNDSchedule, error) {
+	ctx, span := tracer.Start(ctx, "dnd_schedule.set")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return domain.DNDSchedule{}, err
+	}
+	if err := schedule.Validate(); err != nil {
+		return domain.DNDSchedule{}, err
+	}
+	if err := s.store.SetDNDSchedule(ctx, claims.Subject, schedule); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.DNDSchedule{}, fmt.Errorf("set dnd schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+func (s *DNDScheduleService) authenticate(ctx context.Context, accessToken string) (*auth.Claims, error) {
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	return claims, nil
+}
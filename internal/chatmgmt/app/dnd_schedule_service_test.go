@@ -0,0 +1,60 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubDNDScheduleStore implements app.DNDScheduleStore over a map.
+type stubDNDScheduleStore map[string]domain.DNDSchedule
+
+func (s stubDNDScheduleStore) DNDSchedule(_ context.Context, userID string) (domain.DNDSchedule, error) {
+	return s[userID], nil
+}
+
+func (s stubDNDScheduleStore) SetDNDSchedule(_ context.Context, userID string, schedule domain.DNDSchedule) error {
+	s[userID] = schedule
+	return nil
+}
+
+func TestDNDScheduleService(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	store := stubDNDScheduleStore{}
+	svc := app.NewDNDScheduleService(app.DNDScheduleServiceConfig{Store: store, Validator: h.validator})
+
+	t.Run("unset holds nothing", func(t *testing.T) {
+		got, err := svc.GetDNDSchedule(ctx, feedToken(t, h))
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.DNDSchedule{}, got)
+	})
+
+	t.Run("set stores the caller's schedule", func(t *testing.T) {
+		schedule := domain.DNDSchedule{Windows: []domain.QuietWindow{{Start: 22 * 60, End: 7 * 60}}}
+
+		got, err := svc.SetDNDSchedule(ctx, feedToken(t, h), schedule)
+
+		require.NoError(t, err)
+		assert.Equal(t, schedule, got)
+		assert.Equal(t, schedule, store[feedUserID])
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		_, err := svc.SetDNDSchedule(ctx, feedToken(t, h), domain.DNDSchedule{Windows: []domain.QuietWindow{{Start: 0, End: 1440}}})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := svc.SetDNDSchedule(ctx, "garbage", domain.DNDSchedule{})
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// FeedStore persists users' notification feeds.
+type FeedStore interface {
+	// PutFeedEntry stores a new entry.
+	PutFeedEntry(ctx context.Context, entry domain.FeedEntry) error
+	// ListFeedEntries returns up to limit of the user's entries with IDs
+	// before beforeID (empty for the newest), newest first. unreadOnly
+	// skips entries that have been read.
+	ListFeedEntries(ctx context.Context, userID, beforeID string, limit int, unreadOnly bool) ([]domain.FeedEntry, error)
+	// MarkFeedEntriesRead sets the read time of the user's entries in ids.
+	// Unknown IDs and entries already read are left unchanged.
+	MarkFeedEntriesRead(ctx context.Context, userID string, ids []string, at time.Time) error
+}
+
+// FeedPublisher delivers new feed entries to the user's connected devices.
+type FeedPublisher interface {
+	PublishFeedEntry(ctx context.Context, entry domain.FeedEntry) error
+}
+
+// FeedPage is one page of a notification feed. Cursor is empty on the last
+// page.
+type FeedPage struct {
+	Entries []domain.FeedEntry
+	Cursor  string
+}
+
+// FeedServiceConfig holds the dependencies for FeedService.
+type FeedServiceConfig struct {
+	Store     FeedStore
+	Publisher FeedPublisher // nil disables realtime delivery
+	Validator *auth.Validator
+	Clock     domain.Clock
+	Logger    *slog.Logger
+}
+
+// FeedService records notifications in per-user feeds and serves them back
+// with read/unread state.
+type FeedService struct {
+	store     FeedStore
+	publisher FeedPublisher
+	validator *auth.Validator
+	clock     domain.Clock
+	logger    *slog.Logger
+}
+
+// NewFeedService creates a new FeedService with the given dependencies.
+func NewFeedService(cfg FeedServiceConfig) *FeedService {
+	return &FeedService{
+		store:     cfg.Store,
+		publisher: cfg.Publisher,
+		validator: cfg.Validator,
+		clock:     cfg.Clock,
+		logger:    cfg.Logger,
+	}
+}
+
+// Notify adds entry to its user's feed and pushes it to their connected
+// devices. EntryID and CreatedAt are assigned here. Realtime delivery is
+// best-effort: the entry is already durable, and clients that miss the
+// push see it on their next list.
+func (s *FeedService) Notify(ctx context.Context, entry domain.FeedEntry) (domain.FeedEntry, error) {
+	ctx, span := tracer.Start(ctx, "feed.notify")
+	defer span.End()
+
+	if err := entry.Validate(); err != nil {
+		return domain.FeedEntry{}, fmt.Errorf("notify: %w", err)
+	}
+	entry.EntryID = domain.GenerateFeedEntryID()
+	entry.CreatedAt = s.clock.Now()
+	entry.ReadAt = time.Time{}
+
+	if err := s.store.PutFeedEntry(ctx, entry); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.FeedEntry{}, fmt.Errorf("store feed entry: %w", err)
+	}
+
+	if s.publisher != nil {
+		if err := s.publisher.PublishFeedEntry(ctx, entry); err != nil {
+			observability.WithTraceID(ctx, s.logger).WarnContext(ctx, "feed.publish_failed",
+				"user_id", entry.UserID,
+				"notification_id", entry.EntryID,
+				"error", err,
+			)
+		}
+	}
+
+	return entry, nil
+}
+
+// ListNotifications returns one page of the caller's feed, newest first,
+// continuing from cursor (empty for the newest). pageSize is clamped to
+// domain.MaxPageSize; zero selects domain.DefaultPageSize.
+func (s *FeedService) ListNotifications(
+	ctx context.Context, accessToken, cursor string, pageSize int, unreadOnly bool,
+) (FeedPage, error) {
+	ctx, span := tracer.Start(ctx, "feed.list")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, span, accessToken)
+	if err != nil {
+		return FeedPage{}, err
+	}
+
+	before, err := parseFeedCursor(cursor)
+	if err != nil {
+		return FeedPage{}, fmt.Errorf("list notifications: %w", err)
+	}
+	switch {
+	case pageSize <= 0:
+		pageSize = domain.DefaultPageSize
+	case pageSize > domain.MaxPageSize:
+		pageSize = domain.MaxPageSize
+	}
+
+	entries, err := s.store.ListFeedEntries(ctx, claims.Subject, before, pageSize, unreadOnly)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return FeedPage{}, fmt.Errorf("list feed entries: %w", err)
+	}
+
+	page := FeedPage{Entries: entries}
+	if len(entries) == pageSize {
+		page.Cursor = encodeFeedCursor(entries[len(entries)-1].EntryID)
+	}
+	span.SetAttributes(attribute.Int("feed.entries", len(entries)))
+	return page, nil
+}
+
+// AckNotifications marks the caller's entries in ids as read. Acknowledging
+// is idempotent; IDs that are unknown or belong to another user are
+// ignored.
+func (s *FeedService) AckNotifications(ctx context.Context, accessToken string, ids []string) error {
+	ctx, span := tracer.Start(ctx, "feed.ack")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, span, accessToken)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > domain.MaxPageSize {
+		return fmt.Errorf("ack notifications: %w",
+			domain.NewValidationError("notification_ids", fmt.Sprintf("at most %d allowed", domain.MaxPageSize)))
+	}
+
+	if err := s.store.MarkFeedEntriesRead(ctx, claims.Subject, ids, s.clock.Now()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("mark feed entries read: %w", err)
+	}
+
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "feed.ack",
+		"user_id", claims.Subject,
+		"count", len(ids),
+	)
+	return nil
+}
+
+// authenticate validates accessToken, recording failures on span.
+func (s *FeedService) authenticate(ctx context.Context, span trace.Span, accessToken string) (*auth.Claims, error) {
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	return claims, nil
+}
+
+// encodeFeedCursor returns an opaque cursor resuming a feed after entryID.
+// Cursors need no user binding: the store only ever reads the caller's
+// partition.
+func encodeFeedCursor(entryID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(entryID))
+}
+
+// parseFeedCursor returns the entry ID encoded in cursor. An empty cursor
+// starts from the newest entry.
+func parseFeedCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) == 0 {
+		return "", domain.NewValidationError("cursor", "is malformed")
+	}
+	return string(raw), nil
+}
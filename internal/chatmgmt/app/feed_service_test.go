@@ -0,0 +1,254 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// memFeedStore implements app.FeedStore over an in-memory slice. Errors in
+// err are returned from every call.
+type memFeedStore struct {
+	entries []domain.FeedEntry
+	err     error
+}
+
+func (s *memFeedStore) PutFeedEntry(_ context.Context, entry domain.FeedEntry) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memFeedStore) ListFeedEntries(_ context.Context, userID, beforeID string, limit int, unreadOnly bool) ([]domain.FeedEntry, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	sorted := slices.Clone(s.entries)
+	slices.SortFunc(sorted, func(a, b domain.FeedEntry) int { return strings.Compare(b.EntryID, a.EntryID) })
+	var out []domain.FeedEntry
+	for _, e := range sorted {
+		if e.UserID != userID || (beforeID != "" && e.EntryID >= beforeID) || (unreadOnly && !e.Unread()) {
+			continue
+		}
+		if len(out) == limit {
+			break
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (s *memFeedStore) MarkFeedEntriesRead(_ context.Context, userID string, ids []string, at time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	for i, e := range s.entries {
+		if e.UserID == userID && slices.Contains(ids, e.EntryID) && e.Unread() {
+			s.entries[i].ReadAt = at
+		}
+	}
+	return nil
+}
+
+// stubFeedPublisher records published entries and returns err.
+type stubFeedPublisher struct {
+	published []domain.FeedEntry
+	err       error
+}
+
+func (p *stubFeedPublisher) PublishFeedEntry(_ context.Context, entry domain.FeedEntry) error {
+	p.published = append(p.published, entry)
+	return p.err
+}
+
+const feedUserID = "user-001"
+
+// feedToken mints an access token for feedUserID.
+func feedToken(t *testing.T, h *testHarness) string {
+	t.Helper()
+	token, err := h.minter.MintAccessToken(feedUserID, "sess-001")
+	require.NoError(t, err)
+	return token.Token
+}
+
+func newFeedService(h *testHarness, store app.FeedStore, pub app.FeedPublisher) *app.FeedService {
+	return app.NewFeedService(app.FeedServiceConfig{
+		Store:     store,
+		Publisher: pub,
+		Validator: h.validator,
+		Clock:     h.clock,
+		Logger:    slog.Default(),
+	})
+}
+
+func TestFeedService_Notify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stores and publishes the entry", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, pub := &memFeedStore{}, &stubFeedPublisher{}
+		svc := newFeedService(h, store, pub)
+
+		got, err := svc.Notify(ctx, domain.FeedEntry{
+			UserID:  feedUserID,
+			Kind:    domain.FeedKindMention,
+			ChatID:  "chat-1",
+			ActorID: "user-002",
+		})
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, got.EntryID)
+		assert.Equal(t, testStart, got.CreatedAt)
+		assert.True(t, got.Unread())
+		assert.Equal(t, []domain.FeedEntry{got}, store.entries)
+		assert.Equal(t, []domain.FeedEntry{got}, pub.published)
+	})
+
+	t.Run("publish failure is not an error", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := &memFeedStore{}
+		svc := newFeedService(h, store, &stubFeedPublisher{err: errors.New("gateway down")})
+
+		_, err := svc.Notify(ctx, domain.FeedEntry{UserID: feedUserID, Kind: domain.FeedKindSystem})
+
+		require.NoError(t, err)
+		assert.Len(t, store.entries, 1)
+	})
+
+	t.Run("store failure is not published", func(t *testing.T) {
+		h := newTestHarness(t)
+		pub := &stubFeedPublisher{}
+		svc := newFeedService(h, &memFeedStore{err: errors.New("throttled")}, pub)
+
+		_, err := svc.Notify(ctx, domain.FeedEntry{UserID: feedUserID, Kind: domain.FeedKindInvite})
+
+		require.Error(t, err)
+		assert.Empty(t, pub.published)
+	})
+
+	t.Run("invalid entry", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+
+		_, err := svc.Notify(ctx, domain.FeedEntry{UserID: feedUserID, Kind: "like"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestFeedService_ListNotifications(t *testing.T) {
+	ctx := context.Background()
+
+	seed := func(t *testing.T, h *testHarness, svc *app.FeedService, n int) []domain.FeedEntry {
+		t.Helper()
+		var out []domain.FeedEntry
+		for range n {
+			e, err := svc.Notify(ctx, domain.FeedEntry{UserID: feedUserID, Kind: domain.FeedKindMention})
+			require.NoError(t, err)
+			out = append(out, e)
+			h.clock.Advance(time.Millisecond)
+		}
+		return out
+	}
+
+	t.Run("pages newest first", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+		entries := seed(t, h, svc, 3)
+		token := feedToken(t, h)
+
+		first, err := svc.ListNotifications(ctx, token, "", 2, false)
+		require.NoError(t, err)
+		require.Len(t, first.Entries, 2)
+		assert.Equal(t, entries[2].EntryID, first.Entries[0].EntryID)
+		assert.Equal(t, entries[1].EntryID, first.Entries[1].EntryID)
+		require.NotEmpty(t, first.Cursor)
+
+		second, err := svc.ListNotifications(ctx, token, first.Cursor, 2, false)
+		require.NoError(t, err)
+		require.Len(t, second.Entries, 1)
+		assert.Equal(t, entries[0].EntryID, second.Entries[0].EntryID)
+		assert.Empty(t, second.Cursor)
+	})
+
+	t.Run("unread only", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+		entries := seed(t, h, svc, 2)
+		token := feedToken(t, h)
+		require.NoError(t, svc.AckNotifications(ctx, token, []string{entries[1].EntryID}))
+
+		page, err := svc.ListNotifications(ctx, token, "", 0, true)
+
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 1)
+		assert.Equal(t, entries[0].EntryID, page.Entries[0].EntryID)
+	})
+
+	t.Run("malformed cursor", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+
+		_, err := svc.ListNotifications(ctx, feedToken(t, h), "!!", 0, false)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+
+		_, err := svc.ListNotifications(ctx, "garbage", "", 0, false)
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
+
+func TestFeedService_AckNotifications(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("marks entries read once", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := &memFeedStore{}
+		svc := newFeedService(h, store, nil)
+		e, err := svc.Notify(ctx, domain.FeedEntry{UserID: feedUserID, Kind: domain.FeedKindInvite})
+		require.NoError(t, err)
+		token := feedToken(t, h)
+
+		require.NoError(t, svc.AckNotifications(ctx, token, []string{e.EntryID}))
+		h.clock.Advance(time.Minute)
+		require.NoError(t, svc.AckNotifications(ctx, token, []string{e.EntryID}))
+
+		assert.Equal(t, testStart, store.entries[0].ReadAt)
+	})
+
+	t.Run("too many ids", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+
+		err := svc.AckNotifications(ctx, feedToken(t, h), make([]string, domain.MaxPageSize+1))
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newFeedService(h, &memFeedStore{}, nil)
+
+		err := svc.AckNotifications(ctx, "garbage", []string{"x"})
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
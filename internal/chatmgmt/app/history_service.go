@@ -0,0 +1,217 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// MessageRecord represents a persisted chat message.
+type MessageRecord struct {
+	MessageID       string
+	ChatID          string
+	SenderID        string
+	ClientMessageID string
+	Sequence        uint64
+	ContentType     domain.ContentType
+	Content         string
+	CreatedAt       time.Time
+}
+
+// MessageStore reads persisted chat messages.
+type MessageStore interface {
+	// ListAfter returns up to limit messages with sequence > afterSequence,
+	// in ascending sequence order.
+	ListAfter(ctx context.Context, chatID string, afterSequence uint64, limit int) ([]MessageRecord, error)
+}
+
+// MembershipChecker answers chat membership queries.
+type MembershipChecker interface {
+	IsMember(ctx context.Context, chatID, userID string) (bool, error)
+}
+
+// HistoryPage is one page of a message history stream. Cursor resumes the
+// stream after the last message in Messages.
+type HistoryPage struct {
+	Messages []MessageRecord
+	Cursor   string
+}
+
+// HistoryServiceConfig holds the dependencies for HistoryService.
+type HistoryServiceConfig struct {
+	Messages  MessageStore
+	Members   MembershipChecker
+	Validator *auth.Validator
+	Clock     domain.Clock
+	Logger    *slog.Logger
+
+	// Retention hides messages older than this window even if the store has
+	// not yet expired them (DynamoDB TTL deletion lags by up to 48h). Zero
+	// disables the bound.
+	Retention time.Duration
+}
+
+// HistoryService streams message history for initial client sync.
+type HistoryService struct {
+	messages  MessageStore
+	members   MembershipChecker
+	validator *auth.Validator
+	clock     domain.Clock
+	logger    *slog.Logger
+	retention time.Duration
+}
+
+// NewHistoryService creates a new HistoryService with the given dependencies.
+func NewHistoryService(cfg HistoryServiceConfig) *HistoryService {
+	return &HistoryService{
+		messages:  cfg.Messages,
+		members:   cfg.Members,
+		validator: cfg.Validator,
+		clock:     cfg.Clock,
+		logger:    cfg.Logger,
+		retention: cfg.Retention,
+	}
+}
+
+// StreamHistory sends a chat's history to send, one page at a time, starting
+// after cursor (empty for the beginning). The next page is read only after
+// send returns, so a blocking send — gRPC stream flow control — bounds
+// server-side buffering to a single page. pageSize is clamped to
+// domain.MaxPageSize; zero selects the maximum.
+func (s *HistoryService) StreamHistory(
+	ctx context.Context, accessToken, chatID, cursor string, pageSize int,
+	send func(HistoryPage) error,
+) error {
+	ctx, span := tracer.Start(ctx, "history.stream")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, s.logger)
+
+	// 1. Authenticate.
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	// 2. Resolve the starting position before touching storage.
+	after, err := parseHistoryCursor(chatID, cursor)
+	if err != nil {
+		return fmt.Errorf("stream history: %w", err)
+	}
+	if pageSize <= 0 || pageSize > domain.MaxPageSize {
+		pageSize = domain.MaxPageSize
+	}
+
+	// 3. Authorize.
+	member, err := s.members.IsMember(ctx, chatID, claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("check membership: %w", err)
+	}
+	if !member {
+		return fmt.Errorf("stream history: %w", domain.ErrNotMember)
+	}
+
+	var cutoff time.Time
+	if s.retention > 0 {
+		cutoff = s.clock.Now().Add(-s.retention)
+	}
+
+	// 4. Stream pages until the store is exhausted.
+	pages, sent := 0, 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("stream history: %w", err)
+		}
+
+		batch, err := s.messages.ListAfter(ctx, chatID, after, pageSize)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("list messages: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		after = batch[len(batch)-1].Sequence
+
+		page := HistoryPage{Messages: retained(batch, cutoff), Cursor: encodeHistoryCursor(chatID, after)}
+		if len(page.Messages) > 0 {
+			if err := send(page); err != nil {
+				return fmt.Errorf("send history page: %w", err)
+			}
+			pages++
+			sent += len(page.Messages)
+		}
+		if len(batch) < pageSize {
+			break
+		}
+	}
+
+	span.SetAttributes(attribute.Int("history.pages", pages), attribute.Int("history.messages", sent))
+	logger.InfoContext(ctx, "history.stream",
+		"user_id", claims.Subject,
+		"chat_id", chatID,
+		"pages", pages,
+		"messages", sent,
+	)
+
+	return nil
+}
+
+// retained drops messages created before cutoff. Sequences grow with time, so
+// expired messages form a prefix of the first pages.
+func retained(batch []MessageRecord, cutoff time.Time) []MessageRecord {
+	if cutoff.IsZero() {
+		return batch
+	}
+	for i, m := range batch {
+		if !m.CreatedAt.Before(cutoff) {
+			return batch[i:]
+		}
+	}
+	return nil
+}
+
+// encodeHistoryCursor returns an opaque cursor binding a sequence position to
+// its chat so a cursor cannot be replayed against another chat.
+func encodeHistoryCursor(chatID string, sequence uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(chatID + ":" + strconv.FormatUint(sequence, 10)))
+}
+
+// parseHistoryCursor returns the sequence encoded in cursor. An empty cursor
+// starts from the beginning.
+func parseHistoryCursor(chatID, cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, domain.NewValidationError("cursor", "is malformed")
+	}
+	i := strings.LastIndexByte(string(raw), ':')
+	if i < 0 || string(raw[:i]) != chatID {
+		return 0, domain.NewValidationError("cursor", "does not belong to this chat")
+	}
+	after, err := strconv.ParseUint(string(raw[i+1:]), 10, 64)
+	if err != nil {
+		return 0, domain.NewValidationError("cursor", "is malformed")
+	}
+	return after, nil
+}
@@ -0,0 +1,262 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubMessageStore implements app.MessageStore over an in-memory slice
+// ordered by sequence. listAfterFn, when set, takes precedence.
+type stubMessageStore struct {
+	messages    []app.MessageRecord
+	calls       int
+	listAfterFn func(ctx context.Context, chatID string, afterSequence uint64, limit int) ([]app.MessageRecord, error)
+}
+
+func (s *stubMessageStore) ListAfter(ctx context.Context, chatID string, afterSequence uint64, limit int) ([]app.MessageRecord, error) {
+	s.calls++
+	if s.listAfterFn != nil {
+		return s.listAfterFn(ctx, chatID, afterSequence, limit)
+	}
+	var out []app.MessageRecord
+	for _, m := range s.messages {
+		if m.ChatID == chatID && m.Sequence > afterSequence && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// stubMembershipChecker implements app.MembershipChecker with a function field.
+type stubMembershipChecker struct {
+	isMemberFn func(ctx context.Context, chatID, userID string) (bool, error)
+}
+
+func (s *stubMembershipChecker) IsMember(ctx context.Context, chatID, userID string) (bool, error) {
+	if s.isMemberFn != nil {
+		return s.isMemberFn(ctx, chatID, userID)
+	}
+	return true, nil
+}
+
+// seedMessages returns n messages for chatID with sequences 1..n, one minute apart
+// ending at testStart.
+func seedMessages(chatID string, n int) []app.MessageRecord {
+	out := make([]app.MessageRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, app.MessageRecord{
+			MessageID:   fmt.Sprintf("msg-%03d", i),
+			ChatID:      chatID,
+			SenderID:    "user-001",
+			Sequence:    uint64(i),
+			ContentType: domain.ContentTypeText,
+			Content:     fmt.Sprintf("message %d", i),
+			CreatedAt:   testStart.Add(time.Duration(i-n) * time.Minute),
+		})
+	}
+	return out
+}
+
+func newHistoryService(h *testHarness, store app.MessageStore, members app.MembershipChecker, retention time.Duration) *app.HistoryService {
+	return app.NewHistoryService(app.HistoryServiceConfig{
+		Messages:  store,
+		Members:   members,
+		Validator: h.validator,
+		Clock:     h.clock,
+		Logger:    slog.Default(),
+		Retention: retention,
+	})
+}
+
+// collect returns a send callback that records pages.
+func collect(pages *[]app.HistoryPage) func(app.HistoryPage) error {
+	return func(p app.HistoryPage) error {
+		*pages = append(*pages, p)
+		return nil
+	}
+}
+
+func sequences(pages []app.HistoryPage) []uint64 {
+	var out []uint64
+	for _, p := range pages {
+		for _, m := range p.Messages {
+			out = append(out, m.Sequence)
+		}
+	}
+	return out
+}
+
+func TestStreamHistory(t *testing.T) {
+	t.Run("success: streams all messages in pages", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		store := &stubMessageStore{messages: seedMessages("chat-001", 5)}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 0)
+
+		var pages []app.HistoryPage
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 2, collect(&pages))
+		require.NoError(t, err)
+
+		require.Len(t, pages, 3)
+		assert.Equal(t, []uint64{1, 2, 3, 4, 5}, sequences(pages))
+		assert.Equal(t, 3, store.calls, "short final page ends the stream without an extra read")
+	})
+
+	t.Run("resume: cursor continues after last delivered page", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		store := &stubMessageStore{messages: seedMessages("chat-001", 5)}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 0)
+
+		// Simulate a client disconnect after the first page.
+		var first []app.HistoryPage
+		errDisconnect := errors.New("client went away")
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 2, func(p app.HistoryPage) error {
+			first = append(first, p)
+			return errDisconnect
+		})
+		require.ErrorIs(t, err, errDisconnect)
+		require.Len(t, first, 1)
+
+		var rest []app.HistoryPage
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", first[0].Cursor, 2, collect(&rest))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{3, 4, 5}, sequences(rest))
+	})
+
+	t.Run("retention: messages older than window are skipped", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		// Messages are at testStart-4m .. testStart; keep the last 2 minutes.
+		store := &stubMessageStore{messages: seedMessages("chat-001", 5)}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 2*time.Minute)
+
+		var pages []app.HistoryPage
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 2, collect(&pages))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{3, 4, 5}, sequences(pages))
+	})
+
+	t.Run("page size is clamped to the maximum", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		var gotLimit int
+		store := &stubMessageStore{listAfterFn: func(_ context.Context, _ string, _ uint64, limit int) ([]app.MessageRecord, error) {
+			gotLimit = limit
+			return nil, nil
+		}}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 0)
+
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 1000, collect(new([]app.HistoryPage)))
+		require.NoError(t, err)
+		assert.Equal(t, domain.MaxPageSize, gotLimit)
+	})
+
+	t.Run("not a member: ErrNotMember", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-002", "sess-002")
+		require.NoError(t, err)
+
+		store := &stubMessageStore{messages: seedMessages("chat-001", 3)}
+		members := &stubMembershipChecker{isMemberFn: func(_ context.Context, chatID, userID string) (bool, error) {
+			assert.Equal(t, "chat-001", chatID)
+			assert.Equal(t, "user-002", userID)
+			return false, nil
+		}}
+		svc := newHistoryService(h, store, members, 0)
+
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 0, collect(new([]app.HistoryPage)))
+		require.ErrorIs(t, err, domain.ErrNotMember)
+		assert.Zero(t, store.calls)
+	})
+
+	t.Run("invalid access token: ErrUnauthorized", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newHistoryService(h, &stubMessageStore{}, &stubMembershipChecker{}, 0)
+
+		err := svc.StreamHistory(context.Background(), "garbage-token", "chat-001", "", 0, collect(new([]app.HistoryPage)))
+		require.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("cursor from another chat: ErrInvalidInput", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		store := &stubMessageStore{messages: append(seedMessages("chat-001", 3), seedMessages("chat-002", 3)...)}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 0)
+
+		var pages []app.HistoryPage
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 1, func(p app.HistoryPage) error {
+			pages = append(pages, p)
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-002", pages[0].Cursor, 1, collect(new([]app.HistoryPage)))
+		require.ErrorIs(t, err, domain.ErrInvalidInput)
+
+		var verr *domain.ValidationError
+		require.ErrorAs(t, err, &verr)
+		assert.Equal(t, "cursor", verr.Violations[0].Field)
+	})
+
+	t.Run("malformed cursor: ErrInvalidInput", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+		svc := newHistoryService(h, &stubMessageStore{}, &stubMembershipChecker{}, 0)
+
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "!!!", 0, collect(new([]app.HistoryPage)))
+		require.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("store failure: error propagated", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		store := &stubMessageStore{listAfterFn: func(context.Context, string, uint64, int) ([]app.MessageRecord, error) {
+			return nil, errors.Join(errors.New("dynamo timeout"), domain.ErrUnavailable)
+		}}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 0)
+
+		err = svc.StreamHistory(context.Background(), token.Token, "chat-001", "", 0, collect(new([]app.HistoryPage)))
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("cancelled context stops the stream", func(t *testing.T) {
+		h := newTestHarness(t)
+		token, err := h.minter.MintAccessToken("user-001", "sess-001")
+		require.NoError(t, err)
+
+		store := &stubMessageStore{messages: seedMessages("chat-001", 5)}
+		svc := newHistoryService(h, store, &stubMembershipChecker{}, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err = svc.StreamHistory(ctx, token.Token, "chat-001", "", 2, func(app.HistoryPage) error {
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, store.calls)
+	})
+}
@@ -0,0 +1,393 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// JoinRequestStore persists pending join requests as pending memberships.
+// Expired requests are never returned and may be replaced.
+type JoinRequestStore interface {
+	// CreateJoinRequest returns domain.ErrAlreadyExists if the user is a
+	// member or has a live request.
+	CreateJoinRequest(ctx context.Context, req domain.JoinRequest) error
+	// ListJoinRequests returns up to limit live requests for chatID,
+	// ordered by user ID, after afterUserID (empty for the first page).
+	ListJoinRequests(ctx context.Context, chatID, afterUserID string, limit int, now time.Time) ([]domain.JoinRequest, error)
+	// ApproveJoinRequest turns a live request into a member membership
+	// joined at now on actorID's approval, and returns domain.ErrNotFound
+	// if there is none.
+	ApproveJoinRequest(ctx context.Context, chatID, userID, actorID string, now time.Time) error
+	// DeleteJoinRequest removes a live request, and returns
+	// domain.ErrNotFound if there is none.
+	DeleteJoinRequest(ctx context.Context, chatID, userID string, now time.Time) error
+}
+
+// ChatAdminLister lists the admins and owner of a chat.
+type ChatAdminLister interface {
+	ListAdmins(ctx context.Context, chatID string) ([]string, error)
+}
+
+// MemberLister lists the members of a chat, excluding pending join
+// requests.
+type MemberLister interface {
+	ListMembers(ctx context.Context, chatID string) ([]domain.ChatMember, error)
+}
+
+// FeedNotifier adds entries to users' notification feeds. The
+// *FeedService satisfies this.
+type FeedNotifier interface {
+	Notify(ctx context.Context, entry domain.FeedEntry) (domain.FeedEntry, error)
+}
+
+// JoinRequestPage is one page of a chat's pending join requests. Cursor
+// is empty on the last page.
+type JoinRequestPage struct {
+	Requests []domain.JoinRequest
+	Cursor   string
+}
+
+// JoinRequestServiceConfig holds the dependencies for JoinRequestService.
+type JoinRequestServiceConfig struct {
+	Store     JoinRequestStore
+	Settings  ChatSettingsStore
+	Roles     MemberRoleReader
+	Admins    ChatAdminLister
+	Feed      FeedNotifier        // nil disables admin and requester notifications
+	System    SystemMessagePoster // nil disables member_joined messages
+	Validator *auth.Validator
+	Clock     domain.Clock
+	Logger    *slog.Logger
+
+	// Members and Entitlements cap a group at its owner's MaxGroupSize.
+	// Either nil disables the cap.
+	Members      MemberLister
+	Entitlements EntitlementReader
+}
+
+// JoinRequestService gates group membership behind admin approval: users
+// ask to join, and members allowed to add members (the chat's
+// who_can_add_members policy) approve or reject.
+type JoinRequestService struct {
+	store     JoinRequestStore
+	settings  ChatSettingsStore
+	roles     MemberRoleReader
+	admins    ChatAdminLister
+	feed      FeedNotifier
+	system    SystemMessagePoster
+	validator *auth.Validator
+	members   MemberLister
+	limits    EntitlementReader
+	clock     domain.Clock
+	logger    *slog.Logger
+}
+
+// NewJoinRequestService creates a new JoinRequestService with the given
+// dependencies.
+func NewJoinRequestService(cfg JoinRequestServiceConfig) *JoinRequestService {
+	return &JoinRequestService{
+		store:     cfg.Store,
+		settings:  cfg.Settings,
+		roles:     cfg.Roles,
+		admins:    cfg.Admins,
+		feed:      cfg.Feed,
+		system:    cfg.System,
+		validator: cfg.Validator,
+		members:   cfg.Members,
+		limits:    cfg.Entitlements,
+		clock:     cfg.Clock,
+		logger:    cfg.Logger,
+	}
+}
+
+// RequestToJoin records the caller's request to join a group chat and
+// notifies its admins. Members, and users with a live request, get
+// domain.ErrAlreadyExists.
+func (s *JoinRequestService) RequestToJoin(ctx context.Context, accessToken, chatID, note string) (domain.JoinRequest, error) {
+	ctx, span := tracer.Start(ctx, "join_request.create")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, span, accessToken)
+	if err != nil {
+		return domain.JoinRequest{}, err
+	}
+	if _, err := s.groupSettings(ctx, chatID); err != nil {
+		return domain.JoinRequest{}, fmt.Errorf("request to join: %w", err)
+	}
+	switch _, err := s.roles.MemberRole(ctx, chatID, claims.Subject); {
+	case err == nil:
+		return domain.JoinRequest{}, fmt.Errorf("request to join: already a member: %w", domain.ErrAlreadyExists)
+	case !errors.Is(err, domain.ErrNotMember):
+		return domain.JoinRequest{}, fmt.Errorf("request to join: %w", err)
+	}
+
+	req, err := domain.NewJoinRequest(chatID, claims.Subject, note, s.clock.Now())
+	if err != nil {
+		return domain.JoinRequest{}, fmt.Errorf("request to join: %w", err)
+	}
+	if err := s.store.CreateJoinRequest(ctx, req); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.JoinRequest{}, fmt.Errorf("create join request: %w", err)
+	}
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	logger.InfoContext(ctx, "join_request.created", "chat_id", chatID, "user_id", claims.Subject)
+	s.notifyAdmins(ctx, logger, req)
+	return req, nil
+}
+
+// ListJoinRequests returns one page of a chat's live join requests,
+// continuing from cursor (empty for the first page). pageSize is clamped
+// to domain.MaxPageSize; zero selects domain.DefaultPageSize.
+func (s *JoinRequestService) ListJoinRequests(
+	ctx context.Context, accessToken, chatID, cursor string, pageSize int,
+) (JoinRequestPage, error) {
+	ctx, span := tracer.Start(ctx, "join_request.list")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, span, accessToken)
+	if err != nil {
+		return JoinRequestPage{}, err
+	}
+	if err := s.requireApprover(ctx, chatID, claims.Subject); err != nil {
+		return JoinRequestPage{}, fmt.Errorf("list join requests: %w", err)
+	}
+
+	after, err := parseJoinRequestCursor(cursor)
+	if err != nil {
+		return JoinRequestPage{}, fmt.Errorf("list join requests: %w", err)
+	}
+	switch {
+	case pageSize <= 0:
+		pageSize = domain.DefaultPageSize
+	case pageSize > domain.MaxPageSize:
+		pageSize = domain.MaxPageSize
+	}
+
+	reqs, err := s.store.ListJoinRequests(ctx, chatID, after, pageSize, s.clock.Now())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return JoinRequestPage{}, fmt.Errorf("list join requests: %w", err)
+	}
+
+	page := JoinRequestPage{Requests: reqs}
+	if len(reqs) == pageSize {
+		page.Cursor = encodeJoinRequestCursor(reqs[len(reqs)-1].UserID)
+	}
+	span.SetAttributes(attribute.Int("join_request.count", len(reqs)))
+	return page, nil
+}
+
+// ApproveJoinRequest makes userID a member of the chat, announces the join
+// in the chat and notifies the requester. Returns domain.ErrNotFound if
+// there is no live request, including when it was already decided, and
+// domain.ErrForbidden if the chat is at its owner's group size limit.
+func (s *JoinRequestService) ApproveJoinRequest(ctx context.Context, accessToken, chatID, userID string) error {
+	ctx, span := tracer.Start(ctx, "join_request.approve")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, span, accessToken)
+	if err != nil {
+		return err
+	}
+	if err := s.requireApprover(ctx, chatID, claims.Subject); err != nil {
+		return fmt.Errorf("approve join request: %w", err)
+	}
+	if err := s.requireRoom(ctx, chatID); err != nil {
+		return fmt.Errorf("approve join request: %w", err)
+	}
+
+	now := s.clock.Now()
+	if err := s.store.ApproveJoinRequest(ctx, chatID, userID, claims.Subject, now); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("approve join request: %w", err)
+	}
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	logger.InfoContext(ctx, "join_request.approved", "chat_id", chatID, "user_id", userID, "approved_by", claims.Subject)
+
+	// The membership is committed; announcements are best-effort.
+	if s.system != nil {
+		key := fmt.Sprintf("join:%s:%s:%d", chatID, userID, now.UnixMilli())
+		msg := domain.SystemMessage{Event: domain.SystemEventMemberJoined, ActorID: claims.Subject, TargetID: userID}
+		if err := s.system.PostSystemMessage(ctx, chatID, key, msg); err != nil {
+			logger.WarnContext(ctx, "join_request.announce_failed", "chat_id", chatID, "user_id", userID, "error", err)
+		}
+	}
+	s.notify(ctx, logger, domain.FeedEntry{
+		UserID:  userID,
+		Kind:    domain.FeedKindSystem,
+		ChatID:  chatID,
+		ActorID: claims.Subject,
+		Params:  map[string]string{"event": "join_request_approved"},
+	})
+	return nil
+}
+
+// RejectJoinRequest removes userID's request. The requester is not told;
+// they may ask again. Returns domain.ErrNotFound if there is no live
+// request.
+func (s *JoinRequestService) RejectJoinRequest(ctx context.Context, accessToken, chatID, userID string) error {
+	ctx, span := tracer.Start(ctx, "join_request.reject")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, span, accessToken)
+	if err != nil {
+		return err
+	}
+	if err := s.requireApprover(ctx, chatID, claims.Subject); err != nil {
+		return fmt.Errorf("reject join request: %w", err)
+	}
+
+	if err := s.store.DeleteJoinRequest(ctx, chatID, userID, s.clock.Now()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("reject join request: %w", err)
+	}
+
+	observability.WithTraceID(ctx, s.logger).InfoContext(ctx, "join_request.rejected",
+		"chat_id", chatID,
+		"user_id", userID,
+		"rejected_by", claims.Subject,
+	)
+	return nil
+}
+
+// groupSettings returns a group chat's settings, rejecting other chats.
+func (s *JoinRequestService) groupSettings(ctx context.Context, chatID string) (domain.ChatSettings, error) {
+	rec, err := s.settings.GetChatSettings(ctx, chatID)
+	if err != nil {
+		return domain.ChatSettings{}, err
+	}
+	if rec.ChatType != domain.ChatTypeGroup {
+		return domain.ChatSettings{}, domain.NewValidationError("chat_id", "only group chats take join requests")
+	}
+	return rec.Settings, nil
+}
+
+// requireApprover checks that userID may decide join requests in chatID
+// under its who_can_add_members policy.
+func (s *JoinRequestService) requireApprover(ctx context.Context, chatID, userID string) error {
+	settings, err := s.groupSettings(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	role, err := s.roles.MemberRole(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !settings.WhoCanAddMembers.Allows(role) {
+		return fmt.Errorf("not allowed to add members: %w", domain.ErrForbidden)
+	}
+	return nil
+}
+
+// requireRoom checks that chatID has fewer members than its owner's plan
+// allows. The count is read before the approval commits, so concurrent
+// approvals can overshoot the limit by a few members; the limit is a plan
+// boundary, not a capacity guarantee.
+func (s *JoinRequestService) requireRoom(ctx context.Context, chatID string) error {
+	if s.members == nil || s.limits == nil {
+		return nil
+	}
+	members, err := s.members.ListMembers(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	owner := ""
+	for _, m := range members {
+		if m.Role == domain.MemberRoleOwner {
+			owner = m.UserID
+			break
+		}
+	}
+	limits := domain.TierLimits(domain.TierFree)
+	if owner != "" {
+		limits = s.limits.Limits(ctx, domain.UserSubject(owner))
+	}
+	if len(members) >= limits.MaxGroupSize {
+		return fmt.Errorf("chat is full at %d members: %w", limits.MaxGroupSize, domain.ErrForbidden)
+	}
+	return nil
+}
+
+// notifyAdmins tells every admin of the chat about req.
+func (s *JoinRequestService) notifyAdmins(ctx context.Context, logger *slog.Logger, req domain.JoinRequest) {
+	if s.feed == nil {
+		return
+	}
+	admins, err := s.admins.ListAdmins(ctx, req.ChatID)
+	if err != nil {
+		logger.WarnContext(ctx, "join_request.list_admins_failed", "chat_id", req.ChatID, "error", err)
+		return
+	}
+	for _, admin := range admins {
+		s.notify(ctx, logger, domain.FeedEntry{
+			UserID:  admin,
+			Kind:    domain.FeedKindJoinRequest,
+			ChatID:  req.ChatID,
+			ActorID: req.UserID,
+		})
+	}
+}
+
+// notify adds entry to its user's feed, logging rather than failing.
+func (s *JoinRequestService) notify(ctx context.Context, logger *slog.Logger, entry domain.FeedEntry) {
+	if s.feed == nil {
+		return
+	}
+	if _, err := s.feed.Notify(ctx, entry); err != nil {
+		logger.WarnContext(ctx, "join_request.notify_failed",
+			"chat_id", entry.ChatID,
+			"user_id", entry.UserID,
+			"error", err,
+		)
+	}
+}
+
+// authenticate validates accessToken, recording failures on span.
+func (s *JoinRequestService) authenticate(ctx context.Context, span trace.Span, accessToken string) (*auth.Claims, error) {
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	return claims, nil
+}
+
+// encodeJoinRequestCursor returns an opaque cursor resuming a listing
+// after userID.
+func encodeJoinRequestCursor(userID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(userID))
+}
+
+// parseJoinRequestCursor returns the user ID encoded in cursor. An empty
+// cursor starts from the first request.
+func parseJoinRequestCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) == 0 {
+		return "", domain.NewValidationError("cursor", "is malformed")
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,344 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// memJoinRequestStore implements app.JoinRequestStore over a map keyed by
+// user ID, honouring expiry like the DynamoDB store.
+type memJoinRequestStore struct {
+	reqs     map[string]domain.JoinRequest
+	approved []string
+}
+
+func newJoinRequestStore(reqs ...domain.JoinRequest) *memJoinRequestStore {
+	s := &memJoinRequestStore{reqs: map[string]domain.JoinRequest{}}
+	for _, r := range reqs {
+		s.reqs[r.UserID] = r
+	}
+	return s
+}
+
+func (s *memJoinRequestStore) CreateJoinRequest(_ context.Context, req domain.JoinRequest) error {
+	if old, ok := s.reqs[req.UserID]; ok && !old.Expired(req.CreatedAt) {
+		return domain.ErrAlreadyExists
+	}
+	s.reqs[req.UserID] = req
+	return nil
+}
+
+func (s *memJoinRequestStore) ListJoinRequests(_ context.Context, _, afterUserID string, limit int, now time.Time) ([]domain.JoinRequest, error) {
+	var out []domain.JoinRequest
+	for _, r := range s.reqs {
+		if r.UserID > afterUserID && !r.Expired(now) {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *memJoinRequestStore) ApproveJoinRequest(ctx context.Context, chatID, userID, _ string, now time.Time) error {
+	if err := s.DeleteJoinRequest(ctx, chatID, userID, now); err != nil {
+		return err
+	}
+	s.approved = append(s.approved, userID)
+	return nil
+}
+
+func (s *memJoinRequestStore) DeleteJoinRequest(_ context.Context, _, userID string, now time.Time) error {
+	r, ok := s.reqs[userID]
+	if !ok || r.Expired(now) {
+		return domain.ErrNotFound
+	}
+	delete(s.reqs, userID)
+	return nil
+}
+
+// stubAdmins implements app.ChatAdminLister.
+type stubAdmins []string
+
+func (a stubAdmins) ListAdmins(context.Context, string) ([]string, error) { return a, nil }
+
+// recordingNotifier implements app.FeedNotifier, recording entries.
+type recordingNotifier struct {
+	entries []domain.FeedEntry
+	err     error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, entry domain.FeedEntry) (domain.FeedEntry, error) {
+	n.entries = append(n.entries, entry)
+	return entry, n.err
+}
+
+const requesterID = "user-009"
+
+func newJoinRequestService(
+	h *testHarness, store app.JoinRequestStore, roles app.MemberRoleReader, feed app.FeedNotifier, poster app.SystemMessagePoster,
+) *app.JoinRequestService {
+	return app.NewJoinRequestService(app.JoinRequestServiceConfig{
+		Store:     store,
+		Settings:  newSettingsStore(),
+		Roles:     roles,
+		Admins:    stubAdmins{"user-001", "user-002"},
+		Feed:      feed,
+		System:    poster,
+		Validator: h.validator,
+		Clock:     h.clock,
+		Logger:    slog.Default(),
+	})
+}
+
+// stubMembers implements app.MemberLister.
+type stubMembers []domain.ChatMember
+
+func (m stubMembers) ListMembers(context.Context, string) ([]domain.ChatMember, error) { return m, nil }
+
+// stubLimits implements app.EntitlementReader, granting limits to owner
+// and the free limits to everyone else.
+type stubLimits struct {
+	owner  string
+	limits domain.Limits
+}
+
+func (l stubLimits) Limits(_ context.Context, subject domain.EntitlementSubject) domain.Limits {
+	if subject == domain.UserSubject(l.owner) {
+		return l.limits
+	}
+	return domain.TierLimits(domain.TierFree)
+}
+
+func pendingRequest(userID string) domain.JoinRequest {
+	r, _ := domain.NewJoinRequest(settingsChatID, userID, "", testStart)
+	return r
+}
+
+func TestJoinRequestService_RequestToJoin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("records the request and notifies admins", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, feed := newJoinRequestStore(), &recordingNotifier{}
+		svc := newJoinRequestService(h, store, stubRoles{}, feed, nil)
+
+		req, err := svc.RequestToJoin(ctx, feedToken(t, h), settingsChatID, "hello")
+
+		require.NoError(t, err)
+		assert.Equal(t, feedUserID, req.UserID)
+		assert.Equal(t, testStart.Add(domain.JoinRequestTTL), req.ExpiresAt)
+		assert.Contains(t, store.reqs, feedUserID)
+		require.Len(t, feed.entries, 2)
+		for _, e := range feed.entries {
+			assert.Equal(t, domain.FeedKindJoinRequest, e.Kind)
+			assert.Equal(t, feedUserID, e.ActorID)
+		}
+	})
+
+	t.Run("members cannot request", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), stubRoles{feedUserID: domain.MemberRoleMember}, nil, nil)
+
+		_, err := svc.RequestToJoin(ctx, feedToken(t, h), settingsChatID, "")
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+
+	t.Run("live request is not duplicated", func(t *testing.T) {
+		h := newTestHarness(t)
+		feed := &recordingNotifier{}
+		svc := newJoinRequestService(h, newJoinRequestStore(pendingRequest(feedUserID)), stubRoles{}, feed, nil)
+
+		_, err := svc.RequestToJoin(ctx, feedToken(t, h), settingsChatID, "")
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+		assert.Empty(t, feed.entries)
+	})
+
+	t.Run("notification failure does not fail the request", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), stubRoles{}, &recordingNotifier{err: errors.New("down")}, nil)
+
+		_, err := svc.RequestToJoin(ctx, feedToken(t, h), settingsChatID, "")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown chat", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), stubRoles{}, nil, nil)
+
+		_, err := svc.RequestToJoin(ctx, feedToken(t, h), "chat-404", "")
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), stubRoles{}, nil, nil)
+
+		_, err := svc.RequestToJoin(ctx, "bad", settingsChatID, "")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
+
+func TestJoinRequestService_ListJoinRequests(t *testing.T) {
+	ctx := context.Background()
+	admins := stubRoles{feedUserID: domain.MemberRoleAdmin}
+
+	t.Run("pages through live requests", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newJoinRequestStore(pendingRequest("user-101"), pendingRequest("user-102"), pendingRequest("user-103"))
+		svc := newJoinRequestService(h, store, admins, nil, nil)
+
+		first, err := svc.ListJoinRequests(ctx, feedToken(t, h), settingsChatID, "", 2)
+		require.NoError(t, err)
+		require.Len(t, first.Requests, 2)
+		require.NotEmpty(t, first.Cursor)
+
+		second, err := svc.ListJoinRequests(ctx, feedToken(t, h), settingsChatID, first.Cursor, 2)
+		require.NoError(t, err)
+		require.Len(t, second.Requests, 1)
+		assert.Equal(t, "user-103", second.Requests[0].UserID)
+		assert.Empty(t, second.Cursor)
+	})
+
+	t.Run("expired requests are hidden", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(pendingRequest("user-101")), admins, nil, nil)
+		h.clock.Advance(domain.JoinRequestTTL)
+
+		page, err := svc.ListJoinRequests(ctx, feedToken(t, h), settingsChatID, "", 0)
+
+		require.NoError(t, err)
+		assert.Empty(t, page.Requests)
+	})
+
+	t.Run("plain members are forbidden", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), stubRoles{feedUserID: domain.MemberRoleMember}, nil, nil)
+
+		_, err := svc.ListJoinRequests(ctx, feedToken(t, h), settingsChatID, "", 0)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("malformed cursor", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), admins, nil, nil)
+
+		_, err := svc.ListJoinRequests(ctx, feedToken(t, h), settingsChatID, "!!", 0)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestJoinRequestService_ApproveJoinRequest(t *testing.T) {
+	ctx := context.Background()
+	admins := stubRoles{feedUserID: domain.MemberRoleAdmin}
+
+	t.Run("adds the member, announces and notifies", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, feed, poster := newJoinRequestStore(pendingRequest(requesterID)), &recordingNotifier{}, &recordingPoster{}
+		svc := newJoinRequestService(h, store, admins, feed, poster)
+
+		err := svc.ApproveJoinRequest(ctx, feedToken(t, h), settingsChatID, requesterID)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{requesterID}, store.approved)
+		require.Len(t, poster.msgs, 1)
+		assert.Equal(t, domain.SystemMessage{Event: domain.SystemEventMemberJoined, ActorID: feedUserID, TargetID: requesterID}, poster.msgs[0])
+		require.Len(t, feed.entries, 1)
+		assert.Equal(t, requesterID, feed.entries[0].UserID)
+		assert.Equal(t, "join_request_approved", feed.entries[0].Params["event"])
+	})
+
+	t.Run("expired request", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newJoinRequestStore(pendingRequest(requesterID))
+		svc := newJoinRequestService(h, store, admins, nil, nil)
+		h.clock.Advance(domain.JoinRequestTTL)
+
+		err := svc.ApproveJoinRequest(ctx, feedToken(t, h), settingsChatID, requesterID)
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.Empty(t, store.approved)
+	})
+
+	t.Run("non-member is not an approver", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(pendingRequest(requesterID)), stubRoles{}, nil, nil)
+
+		err := svc.ApproveJoinRequest(ctx, feedToken(t, h), settingsChatID, requesterID)
+
+		assert.ErrorIs(t, err, domain.ErrNotMember)
+	})
+
+	t.Run("caps the group at the owner's plan", func(t *testing.T) {
+		members := stubMembers{{UserID: "owner-1", Role: domain.MemberRoleOwner}, {UserID: feedUserID, Role: domain.MemberRoleAdmin}}
+		approve := func(t *testing.T, limits domain.Limits) (*memJoinRequestStore, error) {
+			h := newTestHarness(t)
+			store := newJoinRequestStore(pendingRequest(requesterID))
+			svc := app.NewJoinRequestService(app.JoinRequestServiceConfig{
+				Store:        store,
+				Settings:     newSettingsStore(),
+				Roles:        admins,
+				Admins:       stubAdmins{feedUserID},
+				Validator:    h.validator,
+				Clock:        h.clock,
+				Logger:       slog.Default(),
+				Members:      members,
+				Entitlements: stubLimits{owner: "owner-1", limits: limits},
+			})
+			return store, svc.ApproveJoinRequest(ctx, feedToken(t, h), settingsChatID, requesterID)
+		}
+
+		store, err := approve(t, domain.Limits{MaxGroupSize: 2})
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.Empty(t, store.approved)
+
+		store, err = approve(t, domain.Limits{MaxGroupSize: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []string{requesterID}, store.approved)
+	})
+}
+
+func TestJoinRequestService_RejectJoinRequest(t *testing.T) {
+	ctx := context.Background()
+	admins := stubRoles{feedUserID: domain.MemberRoleAdmin}
+
+	t.Run("removes the request", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newJoinRequestStore(pendingRequest(requesterID))
+		svc := newJoinRequestService(h, store, admins, nil, nil)
+
+		err := svc.RejectJoinRequest(ctx, feedToken(t, h), settingsChatID, requesterID)
+
+		require.NoError(t, err)
+		assert.Empty(t, store.reqs)
+		assert.Empty(t, store.approved)
+	})
+
+	t.Run("no request", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newJoinRequestService(h, newJoinRequestStore(), admins, nil, nil)
+
+		err := svc.RejectJoinRequest(ctx, feedToken(t, h), settingsChatID, requesterID)
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
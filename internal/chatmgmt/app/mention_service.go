@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// PostedMessage is a persisted chat message, as read from
+// messages.persisted.
+type PostedMessage struct {
+	MessageID   string
+	ChatID      string
+	SenderID    string // empty for system messages
+	Sequence    uint64
+	ContentType domain.ContentType
+	Text        string
+}
+
+// MentionServiceConfig holds the dependencies for MentionService.
+type MentionServiceConfig struct {
+	Roles  MemberRoleReader
+	Feed   FeedNotifier
+	Logger *slog.Logger // nil uses slog.Default
+}
+
+// MentionService adds a mention entry to the feed of each member a
+// message mentions (see domain.Mentions). Users who are not in the chat
+// and senders mentioning themselves are not notified. Messages are read
+// at least once, so a redelivered message can notify twice.
+type MentionService struct {
+	roles  MemberRoleReader
+	feed   FeedNotifier
+	logger *slog.Logger
+}
+
+// NewMentionService creates a MentionService.
+func NewMentionService(cfg MentionServiceConfig) *MentionService {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &MentionService{roles: cfg.Roles, feed: cfg.Feed, logger: logger}
+}
+
+// NotifyMentions notifies the members msg mentions. It fails only when
+// membership cannot be read; a failed notification is logged.
+func (s *MentionService) NotifyMentions(ctx context.Context, msg PostedMessage) error {
+	if msg.ContentType != domain.ContentTypeText || msg.SenderID == "" {
+		return nil
+	}
+	mentioned := domain.Mentions(msg.Text)
+	if len(mentioned) == 0 {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "mention.notify")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("chat.id", msg.ChatID),
+		attribute.Int("mention.count", len(mentioned)),
+	)
+
+	for _, userID := range mentioned {
+		if userID == msg.SenderID {
+			continue
+		}
+		if _, err := s.roles.MemberRole(ctx, msg.ChatID, userID); err != nil {
+			if errors.Is(err, domain.ErrNotMember) {
+				continue
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("notify mentions of %s: %w", msg.MessageID, err)
+		}
+		_, err := s.feed.Notify(ctx, domain.FeedEntry{
+			UserID:  userID,
+			Kind:    domain.FeedKindMention,
+			ChatID:  msg.ChatID,
+			ActorID: msg.SenderID,
+			Params: map[string]string{
+				"message_id": msg.MessageID,
+				"sequence":   strconv.FormatUint(msg.Sequence, 10),
+			},
+		})
+		if err != nil {
+			s.logger.WarnContext(ctx, "mention.notify_failed",
+				"chat_id", msg.ChatID,
+				"user_id", userID,
+				"message_id", msg.MessageID,
+				"error", err,
+			)
+		}
+	}
+	return nil
+}
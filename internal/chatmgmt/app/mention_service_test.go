@@ -0,0 +1,77 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// erringRoles fails every role lookup.
+type erringRoles struct{ err error }
+
+func (r erringRoles) MemberRole(context.Context, string, string) (domain.MemberRole, error) {
+	return "", r.err
+}
+
+func TestMentionService_NotifyMentions(t *testing.T) {
+	ctx := context.Background()
+	const (
+		sender   = "0b4a1c3e-0000-4aaa-8bbb-000000000001"
+		member   = "0b4a1c3e-0000-4aaa-8bbb-000000000002"
+		stranger = "0b4a1c3e-0000-4aaa-8bbb-000000000003"
+	)
+	roles := stubRoles{sender: domain.MemberRoleMember, member: domain.MemberRoleMember}
+	msg := app.PostedMessage{
+		MessageID:   "msg-1",
+		ChatID:      settingsChatID,
+		SenderID:    sender,
+		Sequence:    42,
+		ContentType: domain.ContentTypeText,
+		Text:        "<@" + member + "> <@" + stranger + "> and me <@" + sender + ">",
+	}
+
+	t.Run("notifies mentioned members", func(t *testing.T) {
+		feed := &recordingNotifier{}
+		svc := app.NewMentionService(app.MentionServiceConfig{Roles: roles, Feed: feed})
+
+		require.NoError(t, svc.NotifyMentions(ctx, msg))
+
+		assert.Equal(t, []domain.FeedEntry{{
+			UserID:  member,
+			Kind:    domain.FeedKindMention,
+			ChatID:  settingsChatID,
+			ActorID: sender,
+			Params:  map[string]string{"message_id": "msg-1", "sequence": "42"},
+		}}, feed.entries, "non-members and the sender are skipped")
+	})
+
+	t.Run("system messages mention no one", func(t *testing.T) {
+		feed := &recordingNotifier{}
+		svc := app.NewMentionService(app.MentionServiceConfig{Roles: roles, Feed: feed})
+		system := msg
+		system.SenderID, system.ContentType = "", domain.ContentTypeSystem
+
+		require.NoError(t, svc.NotifyMentions(ctx, system))
+
+		assert.Empty(t, feed.entries)
+	})
+
+	t.Run("a failed notification is not an error", func(t *testing.T) {
+		feed := &recordingNotifier{err: errors.New("throttled")}
+		svc := app.NewMentionService(app.MentionServiceConfig{Roles: roles, Feed: feed})
+
+		assert.NoError(t, svc.NotifyMentions(ctx, msg))
+	})
+
+	t.Run("role read errors fail", func(t *testing.T) {
+		svc := app.NewMentionService(app.MentionServiceConfig{Roles: erringRoles{err: errors.New("throttled")}, Feed: &recordingNotifier{}})
+
+		assert.ErrorContains(t, svc.NotifyMentions(ctx, msg), "throttled")
+	})
+}
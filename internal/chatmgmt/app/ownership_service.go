@@ -0,0 +1,269 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// MembershipStore changes member roles. Every write is conditional on the
+// roles the caller read, so a chat always has exactly one owner; a failed
+// condition is reported as domain.ErrVersionConflict.
+type MembershipStore interface {
+	// ListMembers returns every member of chatID, excluding pending join
+	// requests.
+	ListMembers(ctx context.Context, chatID string) ([]domain.ChatMember, error)
+	// ListOwnedChats returns the IDs of the chats userID owns.
+	ListOwnedChats(ctx context.Context, userID string) ([]string, error)
+	// SetMemberRole sets a non-owner member's role to admin or member on
+	// actorID's behalf.
+	SetMemberRole(ctx context.Context, chatID, userID string, role domain.MemberRole, actorID string, at time.Time) error
+	// TransferOwnership makes toUserID the owner and fromUserID an admin
+	// in one transaction.
+	TransferOwnership(ctx context.Context, chatID, fromUserID, toUserID string, at time.Time) error
+	// ReplaceOwner removes the owner's membership and, unless successorID
+	// is empty, makes successorID the owner, in one transaction.
+	ReplaceOwner(ctx context.Context, chatID, ownerID, successorID string, at time.Time) error
+}
+
+// OwnershipServiceConfig holds the dependencies for OwnershipService.
+type OwnershipServiceConfig struct {
+	Store     MembershipStore
+	Roles     MemberRoleReader
+	System    SystemMessagePoster // nil disables ownership and role messages
+	Validator *auth.Validator
+	Clock     domain.Clock
+	Logger    *slog.Logger
+}
+
+// OwnershipService transfers chat ownership and changes member roles.
+// Ownership only moves by explicit transfer or, when the owner deletes
+// their account, by succession; the owner can never be demoted or left
+// without a successor while other members remain.
+type OwnershipService struct {
+	store     MembershipStore
+	roles     MemberRoleReader
+	system    SystemMessagePoster
+	validator *auth.Validator
+	clock     domain.Clock
+	logger    *slog.Logger
+}
+
+// NewOwnershipService creates a new OwnershipService with the given
+// dependencies.
+func NewOwnershipService(cfg OwnershipServiceConfig) *OwnershipService {
+	return &OwnershipService{
+		store:     cfg.Store,
+		roles:     cfg.Roles,
+		system:    cfg.System,
+		validator: cfg.Validator,
+		clock:     cfg.Clock,
+		logger:    cfg.Logger,
+	}
+}
+
+// TransferOwnership makes newOwnerID the owner of chatID; the caller, who
+// must be the owner, becomes an admin. Unless confirm is set, it only
+// checks that the transfer would be allowed and changes nothing, so
+// clients can validate before asking the user to confirm.
+func (s *OwnershipService) TransferOwnership(ctx context.Context, accessToken, chatID, newOwnerID string, confirm bool) error {
+	ctx, span := tracer.Start(ctx, "ownership.transfer")
+	defer span.End()
+	span.SetAttributes(attribute.Bool("ownership.confirm", confirm))
+
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	if newOwnerID == claims.Subject {
+		return domain.NewValidationError("new_owner_id", "is already the owner")
+	}
+	if err := s.requireOwner(ctx, chatID, claims.Subject); err != nil {
+		return fmt.Errorf("transfer ownership: %w", err)
+	}
+	if _, err := s.memberRole(ctx, chatID, newOwnerID, "new_owner_id"); err != nil {
+		return fmt.Errorf("transfer ownership: %w", err)
+	}
+	if !confirm {
+		return nil
+	}
+
+	if err := s.store.TransferOwnership(ctx, chatID, claims.Subject, newOwnerID, s.clock.Now()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("transfer ownership: %w", err)
+	}
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	logger.InfoContext(ctx, "ownership.transferred", "chat_id", chatID, "from", claims.Subject, "to", newOwnerID)
+	s.announce(ctx, logger, chatID, "owner", domain.SystemMessage{
+		Event:    domain.SystemEventOwnershipTransferred,
+		ActorID:  claims.Subject,
+		TargetID: newOwnerID,
+	})
+	return nil
+}
+
+// SetMemberRole promotes a member to admin or demotes an admin to member.
+// Only the owner may change roles, and the owner's own role cannot be
+// changed: ownership moves only through TransferOwnership.
+func (s *OwnershipService) SetMemberRole(ctx context.Context, accessToken, chatID, userID string, role domain.MemberRole) error {
+	ctx, span := tracer.Start(ctx, "ownership.set_role")
+	defer span.End()
+	span.SetAttributes(attribute.String("member.role", string(role)))
+
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	if role != domain.MemberRoleAdmin && role != domain.MemberRoleMember {
+		return domain.NewValidationError("role", "must be admin or member; use TransferOwnership to change the owner")
+	}
+	if err := s.requireOwner(ctx, chatID, claims.Subject); err != nil {
+		return fmt.Errorf("set member role: %w", err)
+	}
+	current, err := s.memberRole(ctx, chatID, userID, "user_id")
+	if err != nil {
+		return fmt.Errorf("set member role: %w", err)
+	}
+	if current == domain.MemberRoleOwner {
+		return fmt.Errorf("set member role: the owner must transfer ownership first: %w", domain.ErrForbidden)
+	}
+	if current == role {
+		return nil
+	}
+
+	if err := s.store.SetMemberRole(ctx, chatID, userID, role, claims.Subject, s.clock.Now()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("set member role: %w", err)
+	}
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	logger.InfoContext(ctx, "ownership.role_changed", "chat_id", chatID, "user_id", userID, "role", role)
+	s.announce(ctx, logger, chatID, "role", domain.SystemMessage{
+		Event:    domain.SystemEventRoleChanged,
+		ActorID:  claims.Subject,
+		TargetID: userID,
+		Params:   map[string]string{"role": string(role)},
+	})
+	return nil
+}
+
+// SucceedOwner hands every chat userID owns to a successor chosen by
+// domain.ChooseSuccessor and removes userID's owner membership. It is
+// called by account deletion, not by clients, so it takes no token. A
+// chat with nobody else left is left without members. Failures on one
+// chat do not stop the others; they are joined into the returned error.
+func (s *OwnershipService) SucceedOwner(ctx context.Context, userID string) error {
+	ctx, span := tracer.Start(ctx, "ownership.succeed")
+	defer span.End()
+
+	chats, err := s.store.ListOwnedChats(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("succeed owner: %w", err)
+	}
+	span.SetAttributes(attribute.Int("ownership.chats", len(chats)))
+
+	logger := observability.WithTraceID(ctx, s.logger)
+	var errs []error
+	for _, chatID := range chats {
+		if err := s.succeedChat(ctx, logger, chatID, userID); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("succeed owner: %w", err)
+	}
+	return nil
+}
+
+// succeedChat replaces ownerID in one chat, picking again if the chosen
+// successor stops being a member before the transaction commits.
+func (s *OwnershipService) succeedChat(ctx context.Context, logger *slog.Logger, chatID, ownerID string) error {
+	var err error
+	for range domain.OwnerSuccessionAttempts {
+		var members []domain.ChatMember
+		members, err = s.store.ListMembers(ctx, chatID)
+		if err != nil {
+			return err
+		}
+		successor, _ := domain.ChooseSuccessor(members, ownerID)
+
+		err = s.store.ReplaceOwner(ctx, chatID, ownerID, successor.UserID, s.clock.Now())
+		if errors.Is(err, domain.ErrVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		logger.InfoContext(ctx, "ownership.succeeded", "chat_id", chatID, "from", ownerID, "to", successor.UserID)
+		if successor.UserID != "" {
+			s.announce(ctx, logger, chatID, "owner", domain.SystemMessage{
+				Event:    domain.SystemEventOwnershipTransferred,
+				TargetID: successor.UserID,
+			})
+		}
+		return nil
+	}
+	return err
+}
+
+// requireOwner checks that userID owns chatID.
+func (s *OwnershipService) requireOwner(ctx context.Context, chatID, userID string) error {
+	role, err := s.roles.MemberRole(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if role != domain.MemberRoleOwner {
+		return fmt.Errorf("not the chat owner: %w", domain.ErrForbidden)
+	}
+	return nil
+}
+
+// memberRole returns the role of the user a request targets, reporting a
+// non-member as invalid input on field rather than as the caller's own
+// lack of membership.
+func (s *OwnershipService) memberRole(ctx context.Context, chatID, userID, field string) (domain.MemberRole, error) {
+	role, err := s.roles.MemberRole(ctx, chatID, userID)
+	if errors.Is(err, domain.ErrNotMember) {
+		return "", domain.NewValidationError(field, "is not a member of the chat")
+	}
+	return role, err
+}
+
+// announce posts msg to the chat. The role change is committed, so a
+// failure is logged rather than returned. kind distinguishes the
+// idempotency keys of owner and role messages.
+func (s *OwnershipService) announce(ctx context.Context, logger *slog.Logger, chatID, kind string, msg domain.SystemMessage) {
+	if s.system == nil {
+		return
+	}
+	key := fmt.Sprintf("%s:%s:%s:%d", kind, chatID, msg.TargetID, s.clock.Now().UnixMilli())
+	if err := s.system.PostSystemMessage(ctx, chatID, key, msg); err != nil {
+		logger.WarnContext(ctx, "ownership.announce_failed", "chat_id", chatID, "event", msg.Event, "error", err)
+	}
+}
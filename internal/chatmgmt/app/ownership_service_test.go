@@ -0,0 +1,281 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// memMembershipStore implements app.MembershipStore and
+// app.MemberRoleReader over one chat's roles, enforcing the store's
+// conditions. vanish, when set, removes that user just before the next
+// ReplaceOwner, as a concurrent leave would.
+type memMembershipStore struct {
+	chatID string
+	roles  map[string]domain.MemberRole
+	vanish string
+}
+
+func newMembershipStore(roles map[string]domain.MemberRole) *memMembershipStore {
+	return &memMembershipStore{chatID: settingsChatID, roles: roles}
+}
+
+func (s *memMembershipStore) MemberRole(_ context.Context, _, userID string) (domain.MemberRole, error) {
+	role, ok := s.roles[userID]
+	if !ok {
+		return "", domain.ErrNotMember
+	}
+	return role, nil
+}
+
+func (s *memMembershipStore) ListMembers(context.Context, string) ([]domain.ChatMember, error) {
+	out := make([]domain.ChatMember, 0, len(s.roles))
+	for id, role := range s.roles {
+		out = append(out, domain.ChatMember{UserID: id, Role: role, JoinedAt: testStart})
+	}
+	return out, nil
+}
+
+func (s *memMembershipStore) ListOwnedChats(_ context.Context, userID string) ([]string, error) {
+	if s.roles[userID] == domain.MemberRoleOwner {
+		return []string{s.chatID}, nil
+	}
+	return nil, nil
+}
+
+func (s *memMembershipStore) SetMemberRole(_ context.Context, _, userID string, role domain.MemberRole, _ string, _ time.Time) error {
+	if cur, ok := s.roles[userID]; !ok || cur == domain.MemberRoleOwner {
+		return domain.ErrVersionConflict
+	}
+	s.roles[userID] = role
+	return nil
+}
+
+func (s *memMembershipStore) TransferOwnership(_ context.Context, _, fromUserID, toUserID string, _ time.Time) error {
+	if s.roles[fromUserID] != domain.MemberRoleOwner {
+		return domain.ErrVersionConflict
+	}
+	if _, ok := s.roles[toUserID]; !ok {
+		return domain.ErrVersionConflict
+	}
+	s.roles[fromUserID], s.roles[toUserID] = domain.MemberRoleAdmin, domain.MemberRoleOwner
+	return nil
+}
+
+func (s *memMembershipStore) ReplaceOwner(_ context.Context, _, ownerID, successorID string, _ time.Time) error {
+	if s.vanish != "" {
+		delete(s.roles, s.vanish)
+		s.vanish = ""
+	}
+	if s.roles[ownerID] != domain.MemberRoleOwner {
+		return domain.ErrVersionConflict
+	}
+	if successorID != "" {
+		if _, ok := s.roles[successorID]; !ok {
+			return domain.ErrVersionConflict
+		}
+		s.roles[successorID] = domain.MemberRoleOwner
+	}
+	delete(s.roles, ownerID)
+	return nil
+}
+
+func newOwnershipService(h *testHarness, store *memMembershipStore, poster app.SystemMessagePoster) *app.OwnershipService {
+	return app.NewOwnershipService(app.OwnershipServiceConfig{
+		Store:     store,
+		Roles:     store,
+		System:    poster,
+		Validator: h.validator,
+		Clock:     h.clock,
+		Logger:    slog.Default(),
+	})
+}
+
+func TestOwnershipService_TransferOwnership(t *testing.T) {
+	ctx := context.Background()
+	chat := func() *memMembershipStore {
+		return newMembershipStore(map[string]domain.MemberRole{
+			feedUserID: domain.MemberRoleOwner,
+			"user-002": domain.MemberRoleMember,
+		})
+	}
+
+	t.Run("without confirmation only validates", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, poster := chat(), &recordingPoster{}
+		svc := newOwnershipService(h, store, poster)
+
+		err := svc.TransferOwnership(ctx, feedToken(t, h), settingsChatID, "user-002", false)
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.MemberRoleOwner, store.roles[feedUserID])
+		assert.Empty(t, poster.msgs)
+	})
+
+	t.Run("confirmed transfer swaps roles and announces", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, poster := chat(), &recordingPoster{}
+		svc := newOwnershipService(h, store, poster)
+
+		err := svc.TransferOwnership(ctx, feedToken(t, h), settingsChatID, "user-002", true)
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.MemberRoleAdmin, store.roles[feedUserID])
+		assert.Equal(t, domain.MemberRoleOwner, store.roles["user-002"])
+		require.Len(t, poster.msgs, 1)
+		assert.Equal(t, domain.SystemMessage{
+			Event: domain.SystemEventOwnershipTransferred, ActorID: feedUserID, TargetID: "user-002",
+		}, poster.msgs[0])
+	})
+
+	t.Run("non-owner is forbidden", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := chat()
+		store.roles[feedUserID] = domain.MemberRoleAdmin
+		svc := newOwnershipService(h, store, nil)
+
+		err := svc.TransferOwnership(ctx, feedToken(t, h), settingsChatID, "user-002", true)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("target must be a member", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newOwnershipService(h, chat(), nil)
+
+		err := svc.TransferOwnership(ctx, feedToken(t, h), settingsChatID, "user-404", false)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("cannot transfer to self", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newOwnershipService(h, chat(), nil)
+
+		err := svc.TransferOwnership(ctx, feedToken(t, h), settingsChatID, feedUserID, true)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestOwnershipService_SetMemberRole(t *testing.T) {
+	ctx := context.Background()
+	chat := func() *memMembershipStore {
+		return newMembershipStore(map[string]domain.MemberRole{
+			feedUserID: domain.MemberRoleOwner,
+			"user-002": domain.MemberRoleAdmin,
+		})
+	}
+
+	t.Run("owner demotes an admin", func(t *testing.T) {
+		h := newTestHarness(t)
+		store, poster := chat(), &recordingPoster{}
+		svc := newOwnershipService(h, store, poster)
+
+		err := svc.SetMemberRole(ctx, feedToken(t, h), settingsChatID, "user-002", domain.MemberRoleMember)
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.MemberRoleMember, store.roles["user-002"])
+		require.Len(t, poster.msgs, 1)
+		assert.Equal(t, "member", poster.msgs[0].Params["role"])
+	})
+
+	t.Run("owner cannot demote themselves", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := chat()
+		svc := newOwnershipService(h, store, nil)
+
+		err := svc.SetMemberRole(ctx, feedToken(t, h), settingsChatID, feedUserID, domain.MemberRoleAdmin)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		assert.Equal(t, domain.MemberRoleOwner, store.roles[feedUserID])
+	})
+
+	t.Run("owner role is only granted by transfer", func(t *testing.T) {
+		h := newTestHarness(t)
+		svc := newOwnershipService(h, chat(), nil)
+
+		err := svc.SetMemberRole(ctx, feedToken(t, h), settingsChatID, "user-002", domain.MemberRoleOwner)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("admins cannot change roles", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := chat()
+		store.roles[feedUserID] = domain.MemberRoleAdmin
+		svc := newOwnershipService(h, store, nil)
+
+		err := svc.SetMemberRole(ctx, feedToken(t, h), settingsChatID, "user-002", domain.MemberRoleMember)
+
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+}
+
+func TestOwnershipService_SucceedOwner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("admin inherits and is announced", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newMembershipStore(map[string]domain.MemberRole{
+			feedUserID: domain.MemberRoleOwner,
+			"user-002": domain.MemberRoleMember,
+			"user-003": domain.MemberRoleAdmin,
+		})
+		poster := &recordingPoster{}
+		svc := newOwnershipService(h, store, poster)
+
+		require.NoError(t, svc.SucceedOwner(ctx, feedUserID))
+
+		assert.Equal(t, domain.MemberRoleOwner, store.roles["user-003"])
+		assert.NotContains(t, store.roles, feedUserID)
+		require.Len(t, poster.msgs, 1)
+		assert.Empty(t, poster.msgs[0].ActorID)
+	})
+
+	t.Run("picks again when the successor leaves", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newMembershipStore(map[string]domain.MemberRole{
+			feedUserID: domain.MemberRoleOwner,
+			"user-002": domain.MemberRoleMember,
+			"user-003": domain.MemberRoleAdmin,
+		})
+		store.vanish = "user-003"
+		svc := newOwnershipService(h, store, nil)
+
+		require.NoError(t, svc.SucceedOwner(ctx, feedUserID))
+
+		assert.Equal(t, map[string]domain.MemberRole{"user-002": domain.MemberRoleOwner}, store.roles)
+	})
+
+	t.Run("sole member leaves the chat empty", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newMembershipStore(map[string]domain.MemberRole{feedUserID: domain.MemberRoleOwner})
+		poster := &recordingPoster{}
+		svc := newOwnershipService(h, store, poster)
+
+		require.NoError(t, svc.SucceedOwner(ctx, feedUserID))
+
+		assert.Empty(t, store.roles)
+		assert.Empty(t, poster.msgs)
+	})
+
+	t.Run("announcement failure is not an error", func(t *testing.T) {
+		h := newTestHarness(t)
+		store := newMembershipStore(map[string]domain.MemberRole{
+			feedUserID: domain.MemberRoleOwner,
+			"user-002": domain.MemberRoleMember,
+		})
+		svc := newOwnershipService(h, store, &recordingPoster{err: errors.New("ingest down")})
+
+		assert.NoError(t, svc.SucceedOwner(ctx, feedUserID))
+	})
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// PrivacySettingsStore holds each user's activity-sharing settings. Unset
+// settings read as sharing everything.
+type PrivacySettingsStore interface {
+	PrivacySettings(ctx context.Context, userID string) (domain.PrivacySettings, error)
+	SetPrivacySettings(ctx context.Context, userID string, s domain.PrivacySettings) error
+}
+
+// PrivacySettingsServiceConfig holds the dependencies for
+// PrivacySettingsService.
+type PrivacySettingsServiceConfig struct {
+	Store     PrivacySettingsStore
+	Validator *auth.Validator
+}
+
+// PrivacySettingsService reads and changes the caller's read receipt,
+// typing and last-seen settings. Fanout enforces them on every activity
+// signal it delivers.
+type PrivacySettingsService struct {
+	store     PrivacySettingsStore
+	validator *auth.Validator
+}
+
+// NewPrivacySettingsService creates a new PrivacySettingsService with the
+// given dependencies.
+func NewPrivacySettingsService(cfg PrivacySettingsServiceConfig) *PrivacySettingsService {
+	return &PrivacySettingsService{store: cfg.Store, validator: cfg.Validator}
+}
+
+// GetPrivacySettings returns the caller's settings.
+func (s *PrivacySettingsService) GetPrivacySettings(ctx context.Context, accessToken string) (domain.PrivacySettings, error) {
+	ctx, span := tracer.Start(ctx, "privacy_settings.get")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return domain.PrivacySettings{}, err
+	}
+	settings, err := s.store.PrivacySettings(ctx, claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.PrivacySettings{}, fmt.Errorf("get privacy settings: %w", err)
+	}
+	return settings, nil
+}
+
+// SetPrivacySettings replaces the caller's settings.
+func (s *PrivacySettingsService) SetPrivacySettings(ctx context.Context, accessToken string, settings domain.PrivacySettings) (domain.PrivacySettings, error) {
+	ctx, span := tracer.Start(ctx, "privacy_settings.set")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return domain.PrivacySettings{}, err
+	}
+	if err := s.store.SetPrivacySettings(ctx, claims.Subject, settings); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.PrivacySettings{}, fmt.Errorf("set privacy settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *PrivacySettingsService) authenticate(ctx context.Context, accessToken string) (*auth.Claims, error) {
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	return claims, nil
+}
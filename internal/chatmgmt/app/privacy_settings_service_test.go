@@ -0,0 +1,54 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubPrivacySettingsStore implements app.PrivacySettingsStore over a map.
+type stubPrivacySettingsStore map[string]domain.PrivacySettings
+
+func (s stubPrivacySettingsStore) PrivacySettings(_ context.Context, userID string) (domain.PrivacySettings, error) {
+	return s[userID], nil
+}
+
+func (s stubPrivacySettingsStore) SetPrivacySettings(_ context.Context, userID string, settings domain.PrivacySettings) error {
+	s[userID] = settings
+	return nil
+}
+
+func TestPrivacySettingsService(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	store := stubPrivacySettingsStore{}
+	svc := app.NewPrivacySettingsService(app.PrivacySettingsServiceConfig{Store: store, Validator: h.validator})
+
+	t.Run("unset shares everything", func(t *testing.T) {
+		got, err := svc.GetPrivacySettings(ctx, feedToken(t, h))
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.PrivacySettings{}, got)
+	})
+
+	t.Run("set stores the caller's settings", func(t *testing.T) {
+		settings := domain.PrivacySettings{HideTyping: true, HideLastSeen: true}
+
+		got, err := svc.SetPrivacySettings(ctx, feedToken(t, h), settings)
+
+		require.NoError(t, err)
+		assert.Equal(t, settings, got)
+		assert.Equal(t, settings, store[feedUserID])
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := svc.SetPrivacySettings(ctx, "garbage", domain.PrivacySettings{})
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
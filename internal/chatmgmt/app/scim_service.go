@@ -0,0 +1,692 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var scimOperationsTotal metric.Int64Counter
+
+func init() {
+	scimOperationsTotal, _ = otel.Meter("chatmgmt/app").Int64Counter("chatmgmt_scim_operations_total",
+		metric.WithDescription("SCIM provisioning operations by action and outcome"))
+}
+
+// scimRevocationReason labels the sessions revoked by deprovisioning.
+const scimRevocationReason = "scim_deprovision"
+
+// SCIMStore persists SCIM-provisioned workspace members and groups.
+type SCIMStore interface {
+	WorkspaceMemberReader
+	// ListMembers returns every member of the workspace.
+	ListMembers(ctx context.Context, workspaceID string) ([]domain.WorkspaceMember, error)
+	// CreateMember creates member and its phone-less user in one
+	// transaction. Returns domain.ErrAlreadyExists if the userName is
+	// taken in the workspace.
+	CreateMember(ctx context.Context, member domain.WorkspaceMember, user UserRecord) error
+	// UpdateMember replaces member. prevUserName is the userName it was
+	// stored under; a changed userName is claimed in the same
+	// transaction, returning domain.ErrAlreadyExists if it is taken.
+	UpdateMember(ctx context.Context, member domain.WorkspaceMember, prevUserName string) error
+	// DeleteMember removes member and frees its userName. The user record
+	// stays, as message history refers to it.
+	DeleteMember(ctx context.Context, member domain.WorkspaceMember) error
+
+	// GetGroup returns domain.ErrNotFound for an unknown group.
+	GetGroup(ctx context.Context, workspaceID, groupID string) (*domain.WorkspaceGroup, error)
+	ListGroups(ctx context.Context, workspaceID string) ([]domain.WorkspaceGroup, error)
+	// CreateGroup stores a new group at version 1.
+	CreateGroup(ctx context.Context, group domain.WorkspaceGroup) error
+	// UpdateGroup replaces the group if it is still at group.Version,
+	// incrementing the version, and returns domain.ErrVersionConflict
+	// otherwise.
+	UpdateGroup(ctx context.Context, group domain.WorkspaceGroup) error
+	// DeleteGroup returns domain.ErrNotFound for an unknown group.
+	DeleteGroup(ctx context.Context, workspaceID, groupID string) error
+}
+
+// SessionRevoker revokes every session of a user. *AuthService satisfies
+// it.
+type SessionRevoker interface {
+	RevokeUserSessions(ctx context.Context, userID, reason string) (int, error)
+}
+
+// ChatOffboarder hands the chats a departing user owns to successors.
+// *OwnershipService satisfies it.
+type ChatOffboarder interface {
+	SucceedOwner(ctx context.Context, userID string) error
+}
+
+// MembershipRemover removes a user from every chat they belong to but do
+// not own, returning how many they left.
+type MembershipRemover interface {
+	RemoveUserMemberships(ctx context.Context, userID string, at time.Time) (int, error)
+}
+
+// MemberFilter selects members by exact attribute. Empty fields match
+// every member; UserName ignores case.
+type MemberFilter struct {
+	UserName   string
+	ExternalID string
+}
+
+// GroupFilter selects groups by exact attribute. Empty fields match every
+// group.
+type GroupFilter struct {
+	DisplayName string
+	ExternalID  string
+}
+
+// MemberPatch changes the attributes that are set.
+type MemberPatch struct {
+	UserName    *string
+	ExternalID  *string
+	DisplayName *string
+	Active      *bool
+}
+
+// GroupPatch changes a group. Members, if set, replaces the member list
+// before AddMembers and RemoveMembers apply.
+type GroupPatch struct {
+	DisplayName   *string
+	ExternalID    *string
+	Members       *[]string
+	AddMembers    []string
+	RemoveMembers []string
+}
+
+// MemberList is one page of members. Total counts every match.
+type MemberList struct {
+	Members []domain.WorkspaceMember
+	Total   int
+}
+
+// GroupList is one page of groups. Total counts every match.
+type GroupList struct {
+	Groups []domain.WorkspaceGroup
+	Total  int
+}
+
+// SCIMServiceConfig holds the dependencies for SCIMService.
+type SCIMServiceConfig struct {
+	Store       SCIMStore
+	Connections SSOStore // holds each workspace's SCIM token
+	Sessions    SessionRevoker
+	Chats       ChatOffboarder    // nil leaves owned chats to their owner
+	Memberships MembershipRemover // nil leaves chat memberships in place
+	Region      domain.DataRegion // refuses workspaces in other regions; empty disables residency
+	Clock       domain.Clock
+	Logger      *slog.Logger
+}
+
+// SCIMService lets a workspace's identity provider provision and
+// deprovision its members over SCIM 2.0. The IdP authenticates with the
+// workspace's SCIM token. Deactivating or deleting a member revokes their
+// sessions and removes them from the workspace's groups and from their
+// chats, handing owned chats to successors. Every write is recorded in the
+// audit log with actor "scim:<workspace>".
+type SCIMService struct {
+	store       SCIMStore
+	connections SSOStore
+	sessions    SessionRevoker
+	chats       ChatOffboarder
+	memberships MembershipRemover
+	region      domain.DataRegion
+	clock       domain.Clock
+	logger      *slog.Logger
+}
+
+// NewSCIMService creates a SCIMService.
+func NewSCIMService(cfg SCIMServiceConfig) *SCIMService {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SCIMService{
+		store:       cfg.Store,
+		connections: cfg.Connections,
+		sessions:    cfg.Sessions,
+		chats:       cfg.Chats,
+		memberships: cfg.Memberships,
+		region:      cfg.Region,
+		clock:       clock,
+		logger:      logger,
+	}
+}
+
+// Authenticate checks token against the workspace's SCIM token. A
+// workspace without SSO or without a token rejects every token. A valid
+// token for a workspace in another data region gets a
+// domain.ResidencyError, so the IdP learns where to send its requests.
+func (s *SCIMService) Authenticate(ctx context.Context, workspaceID, token string) error {
+	conn, err := s.connections.GetConnection(ctx, workspaceID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return fmt.Errorf("scim authenticate: %w", domain.ErrUnauthorized)
+	}
+	if err != nil {
+		return fmt.Errorf("scim authenticate: %w", err)
+	}
+	if !auth.ValidateSCIMToken(token, conn.SCIMTokenHash) {
+		return fmt.Errorf("scim authenticate: %w", domain.ErrUnauthorized)
+	}
+	if err := domain.CheckResidency(workspaceID, conn.DataRegion, s.region); err != nil {
+		return fmt.Errorf("scim authenticate: %w", err)
+	}
+	return nil
+}
+
+// RotateToken issues the workspace a new SCIM token, invalidating the
+// previous one, and returns it; only its hash is kept. The workspace must
+// already have an SSO connection. Issuing the first token makes the
+// workspace SCIM-managed.
+func (s *SCIMService) RotateToken(ctx context.Context, actor, workspaceID string) (string, error) {
+	ctx, span := tracer.Start(ctx, "scim.rotate_token")
+	defer span.End()
+
+	token, err := s.rotateToken(ctx, actor, workspaceID)
+	writeAudit(ctx, s.logger, scimOperationsTotal, "scim_rotate_token", actor, err,
+		slog.String("workspace_id", workspaceID))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("rotate scim token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *SCIMService) rotateToken(ctx context.Context, actor, workspaceID string) (string, error) {
+	if err := validateActor(actor); err != nil {
+		return "", err
+	}
+	if workspaceID == "" {
+		return "", domain.NewValidationError("workspace_id", "is required")
+	}
+	conn, err := s.connections.GetConnection(ctx, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	if err := domain.CheckResidency(workspaceID, conn.DataRegion, s.region); err != nil {
+		return "", err
+	}
+	token, err := auth.GenerateSCIMToken()
+	if err != nil {
+		return "", err
+	}
+	conn.SCIMTokenHash = auth.HashSCIMToken(token)
+	conn.UpdatedAt = s.clock.Now().UTC()
+	if err := s.connections.PutConnection(ctx, conn); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CreateUser provisions a member and its phone-less account.
+func (s *SCIMService) CreateUser(ctx context.Context, workspaceID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error) {
+	ctx, span := tracer.Start(ctx, "scim.create_user")
+	defer span.End()
+
+	now := s.clock.Now().UTC()
+	member.WorkspaceID = workspaceID
+	member.UserID = uuid.NewString()
+	member.CreatedAt, member.UpdatedAt = now, now
+
+	err := member.Validate()
+	if err == nil {
+		ts := domain.NewTimestampMS(now)
+		err = s.store.CreateMember(ctx, member, UserRecord{
+			UserID:      member.UserID,
+			DisplayName: member.DisplayName,
+			CreatedAt:   ts,
+			UpdatedAt:   ts,
+		})
+	}
+	s.audit(ctx, "scim_create_user", workspaceID, err,
+		slog.String("user_id", member.UserID),
+		slog.Bool("active", member.Active),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim create user: %w", err)
+	}
+	return &member, nil
+}
+
+// GetUser returns a member, or domain.ErrNotFound.
+func (s *SCIMService) GetUser(ctx context.Context, workspaceID, userID string) (*domain.WorkspaceMember, error) {
+	member, err := s.store.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("scim get user: %w", err)
+	}
+	return member, nil
+}
+
+// ListUsers returns the members matching filter from the 1-based
+// startIndex, at most count of them. A negative count selects
+// domain.DefaultPageSize; count is capped at domain.MaxPageSize.
+func (s *SCIMService) ListUsers(ctx context.Context, workspaceID string, filter MemberFilter, startIndex, count int) (MemberList, error) {
+	var members []domain.WorkspaceMember
+	if filter.UserName != "" {
+		member, err := s.store.FindMemberByUserName(ctx, workspaceID, filter.UserName)
+		switch {
+		case err == nil:
+			members = []domain.WorkspaceMember{*member}
+		case !errors.Is(err, domain.ErrNotFound):
+			return MemberList{}, fmt.Errorf("scim list users: %w", err)
+		}
+	} else {
+		var err error
+		if members, err = s.store.ListMembers(ctx, workspaceID); err != nil {
+			return MemberList{}, fmt.Errorf("scim list users: %w", err)
+		}
+	}
+
+	var matched []domain.WorkspaceMember
+	for _, m := range members {
+		if filter.ExternalID == "" || m.ExternalID == filter.ExternalID {
+			matched = append(matched, m)
+		}
+	}
+	return MemberList{Members: scimPage(matched, startIndex, count), Total: len(matched)}, nil
+}
+
+// ReplaceUser replaces a member's attributes.
+func (s *SCIMService) ReplaceUser(ctx context.Context, workspaceID, userID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error) {
+	ctx, span := tracer.Start(ctx, "scim.replace_user")
+	defer span.End()
+
+	next, err := s.updateUser(ctx, workspaceID, userID, func(m *domain.WorkspaceMember) {
+		m.UserName = member.UserName
+		m.ExternalID = member.ExternalID
+		m.DisplayName = member.DisplayName
+		m.Active = member.Active
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim replace user: %w", err)
+	}
+	return next, nil
+}
+
+// PatchUser changes the attributes set in patch.
+func (s *SCIMService) PatchUser(ctx context.Context, workspaceID, userID string, patch MemberPatch) (*domain.WorkspaceMember, error) {
+	ctx, span := tracer.Start(ctx, "scim.patch_user")
+	defer span.End()
+
+	next, err := s.updateUser(ctx, workspaceID, userID, func(m *domain.WorkspaceMember) {
+		if patch.UserName != nil {
+			m.UserName = *patch.UserName
+		}
+		if patch.ExternalID != nil {
+			m.ExternalID = *patch.ExternalID
+		}
+		if patch.DisplayName != nil {
+			m.DisplayName = *patch.DisplayName
+		}
+		if patch.Active != nil {
+			m.Active = *patch.Active
+		}
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim patch user: %w", err)
+	}
+	return next, nil
+}
+
+// updateUser applies edit to the stored member and writes it back. Every
+// write that leaves the member inactive offboards them again, so an IdP
+// retrying a deactivation finishes one that failed partway.
+func (s *SCIMService) updateUser(
+	ctx context.Context, workspaceID, userID string, edit func(*domain.WorkspaceMember),
+) (*domain.WorkspaceMember, error) {
+	prev, err := s.store.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	next := *prev
+	edit(&next)
+	next.UpdatedAt = s.clock.Now().UTC()
+
+	action := "scim_update_user"
+	switch {
+	case prev.Active && !next.Active:
+		action = "scim_deactivate_user"
+	case !prev.Active && next.Active:
+		action = "scim_reactivate_user"
+	}
+
+	var res offboarding
+	err = next.Validate()
+	if err == nil {
+		err = s.store.UpdateMember(ctx, next, prev.UserName)
+	}
+	if err == nil && !next.Active {
+		res, err = s.offboard(ctx, next)
+	}
+	s.audit(ctx, action, workspaceID, err, res.attrs(slog.String("user_id", userID))...)
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// DeleteUser offboards a member and deletes it. The account itself
+// remains, signed out and in no chats.
+func (s *SCIMService) DeleteUser(ctx context.Context, workspaceID, userID string) error {
+	ctx, span := tracer.Start(ctx, "scim.delete_user")
+	defer span.End()
+
+	var res offboarding
+	member, err := s.store.GetMember(ctx, workspaceID, userID)
+	if err == nil {
+		res, err = s.offboard(ctx, *member)
+	}
+	if err == nil {
+		err = s.store.DeleteMember(ctx, *member)
+	}
+	s.audit(ctx, "scim_delete_user", workspaceID, err, res.attrs(slog.String("user_id", userID))...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim delete user: %w", err)
+	}
+	return nil
+}
+
+// offboarding counts what offboard removed, for the audit record.
+type offboarding struct {
+	sessions, groups, chats int
+}
+
+func (o offboarding) attrs(attrs ...slog.Attr) []slog.Attr {
+	if o == (offboarding{}) {
+		return attrs
+	}
+	return append(attrs,
+		slog.Int("sessions_revoked", o.sessions),
+		slog.Int("groups_left", o.groups),
+		slog.Int("chats_left", o.chats),
+	)
+}
+
+// offboard revokes member's sessions and removes them from the
+// workspace's groups and from their chats. Every step runs even if an
+// earlier one fails; the failures are joined.
+func (s *SCIMService) offboard(ctx context.Context, member domain.WorkspaceMember) (offboarding, error) {
+	var (
+		res  offboarding
+		errs []error
+		err  error
+	)
+	if res.sessions, err = s.sessions.RevokeUserSessions(ctx, member.UserID, scimRevocationReason); err != nil {
+		errs = append(errs, fmt.Errorf("revoke sessions: %w", err))
+	}
+	if res.groups, err = s.leaveGroups(ctx, member); err != nil {
+		errs = append(errs, fmt.Errorf("leave groups: %w", err))
+	}
+	if s.chats != nil {
+		if err := s.chats.SucceedOwner(ctx, member.UserID); err != nil {
+			errs = append(errs, fmt.Errorf("hand off owned chats: %w", err))
+		}
+	}
+	if s.memberships != nil {
+		if res.chats, err = s.memberships.RemoveUserMemberships(ctx, member.UserID, s.clock.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("leave chats: %w", err))
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// leaveGroups removes member from every group of the workspace and
+// returns how many groups they were in.
+func (s *SCIMService) leaveGroups(ctx context.Context, member domain.WorkspaceMember) (int, error) {
+	groups, err := s.store.ListGroups(ctx, member.WorkspaceID)
+	if err != nil {
+		return 0, err
+	}
+	var left int
+	for _, g := range groups {
+		removed := false
+		err := s.modifyGroup(ctx, member.WorkspaceID, g.GroupID, func(g *domain.WorkspaceGroup) bool {
+			removed = g.RemoveMembers(member.UserID)
+			return removed
+		})
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return left, fmt.Errorf("group %s: %w", g.GroupID, err)
+		}
+		if removed {
+			left++
+		}
+	}
+	return left, nil
+}
+
+// CreateGroup creates a group. Every member must be a member of the
+// workspace.
+func (s *SCIMService) CreateGroup(ctx context.Context, workspaceID string, group domain.WorkspaceGroup) (*domain.WorkspaceGroup, error) {
+	ctx, span := tracer.Start(ctx, "scim.create_group")
+	defer span.End()
+
+	now := s.clock.Now().UTC()
+	members := group.MemberIDs
+	group.WorkspaceID = workspaceID
+	group.GroupID = uuid.NewString()
+	group.MemberIDs = nil
+	group.AddMembers(members...)
+	group.Version = 1
+	group.CreatedAt, group.UpdatedAt = now, now
+
+	err := group.Validate()
+	if err == nil {
+		err = s.requireMembers(ctx, workspaceID, group.MemberIDs)
+	}
+	if err == nil {
+		err = s.store.CreateGroup(ctx, group)
+	}
+	s.audit(ctx, "scim_create_group", workspaceID, err,
+		slog.String("group_id", group.GroupID),
+		slog.Int("members", len(group.MemberIDs)),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim create group: %w", err)
+	}
+	return &group, nil
+}
+
+// GetGroup returns a group, or domain.ErrNotFound.
+func (s *SCIMService) GetGroup(ctx context.Context, workspaceID, groupID string) (*domain.WorkspaceGroup, error) {
+	group, err := s.store.GetGroup(ctx, workspaceID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("scim get group: %w", err)
+	}
+	return group, nil
+}
+
+// ListGroups returns the groups matching filter, paged like ListUsers.
+func (s *SCIMService) ListGroups(ctx context.Context, workspaceID string, filter GroupFilter, startIndex, count int) (GroupList, error) {
+	groups, err := s.store.ListGroups(ctx, workspaceID)
+	if err != nil {
+		return GroupList{}, fmt.Errorf("scim list groups: %w", err)
+	}
+	var matched []domain.WorkspaceGroup
+	for _, g := range groups {
+		if (filter.DisplayName == "" || strings.EqualFold(g.DisplayName, filter.DisplayName)) &&
+			(filter.ExternalID == "" || g.ExternalID == filter.ExternalID) {
+			matched = append(matched, g)
+		}
+	}
+	return GroupList{Groups: scimPage(matched, startIndex, count), Total: len(matched)}, nil
+}
+
+// ReplaceGroup replaces a group's attributes and members.
+func (s *SCIMService) ReplaceGroup(ctx context.Context, workspaceID, groupID string, group domain.WorkspaceGroup) (*domain.WorkspaceGroup, error) {
+	members := group.MemberIDs
+	return s.PatchGroup(ctx, workspaceID, groupID, GroupPatch{
+		DisplayName: &group.DisplayName,
+		ExternalID:  &group.ExternalID,
+		Members:     &members,
+	})
+}
+
+// PatchGroup applies patch to a group. Added members must be members of
+// the workspace; removing a non-member is not an error.
+func (s *SCIMService) PatchGroup(ctx context.Context, workspaceID, groupID string, patch GroupPatch) (*domain.WorkspaceGroup, error) {
+	ctx, span := tracer.Start(ctx, "scim.patch_group")
+	defer span.End()
+
+	var (
+		updated        domain.WorkspaceGroup
+		added, removed int
+	)
+	adding := patch.AddMembers
+	if patch.Members != nil {
+		adding = append(append([]string(nil), *patch.Members...), adding...)
+	}
+	err := s.requireMembers(ctx, workspaceID, adding)
+	if err == nil {
+		err = s.modifyGroup(ctx, workspaceID, groupID, func(g *domain.WorkspaceGroup) bool {
+			before := append([]string(nil), g.MemberIDs...)
+			if patch.DisplayName != nil {
+				g.DisplayName = *patch.DisplayName
+			}
+			if patch.ExternalID != nil {
+				g.ExternalID = *patch.ExternalID
+			}
+			if patch.Members != nil {
+				g.MemberIDs = nil
+				g.AddMembers(*patch.Members...)
+			}
+			g.AddMembers(patch.AddMembers...)
+			g.RemoveMembers(patch.RemoveMembers...)
+			added, removed = diffCount(before, g.MemberIDs), diffCount(g.MemberIDs, before)
+			updated = *g
+			return true
+		})
+	}
+	s.audit(ctx, "scim_update_group", workspaceID, err,
+		slog.String("group_id", groupID),
+		slog.Int("members_added", added),
+		slog.Int("members_removed", removed),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("scim update group: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteGroup deletes a group. Its members stay in the workspace.
+func (s *SCIMService) DeleteGroup(ctx context.Context, workspaceID, groupID string) error {
+	ctx, span := tracer.Start(ctx, "scim.delete_group")
+	defer span.End()
+
+	err := s.store.DeleteGroup(ctx, workspaceID, groupID)
+	s.audit(ctx, "scim_delete_group", workspaceID, err, slog.String("group_id", groupID))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("scim delete group: %w", err)
+	}
+	return nil
+}
+
+// modifyGroup reads a group, applies edit and writes it back if edit
+// reports a change, rereading and reapplying on a version conflict up to
+// domain.SCIMGroupWriteAttempts times.
+func (s *SCIMService) modifyGroup(ctx context.Context, workspaceID, groupID string, edit func(*domain.WorkspaceGroup) bool) error {
+	var err error
+	for range domain.SCIMGroupWriteAttempts {
+		var group *domain.WorkspaceGroup
+		group, err = s.store.GetGroup(ctx, workspaceID, groupID)
+		if err != nil {
+			return err
+		}
+		if !edit(group) {
+			return nil
+		}
+		group.UpdatedAt = s.clock.Now().UTC()
+		if err = group.Validate(); err != nil {
+			return err
+		}
+		err = s.store.UpdateGroup(ctx, *group)
+		if !errors.Is(err, domain.ErrVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// requireMembers checks that every user ID is a member of the workspace.
+func (s *SCIMService) requireMembers(ctx context.Context, workspaceID string, userIDs []string) error {
+	if len(userIDs) > domain.MaxWorkspaceGroupMembers {
+		return domain.NewValidationError("members", fmt.Sprintf("must number at most %d", domain.MaxWorkspaceGroupMembers))
+	}
+	for _, id := range userIDs {
+		_, err := s.store.GetMember(ctx, workspaceID, id)
+		if errors.Is(err, domain.ErrNotFound) {
+			return domain.NewValidationError("members", "must be members of the workspace: "+id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// audit records one SCIM write with the workspace's IdP as actor.
+func (s *SCIMService) audit(ctx context.Context, action, workspaceID string, err error, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{slog.String("workspace_id", workspaceID)}, attrs...)
+	writeAudit(ctx, s.logger, scimOperationsTotal, action, "scim:"+workspaceID, err, attrs...)
+}
+
+// scimPage returns the page of items starting at the 1-based startIndex.
+func scimPage[T any](items []T, startIndex, count int) []T {
+	switch {
+	case count < 0:
+		count = domain.DefaultPageSize
+	case count > domain.MaxPageSize:
+		count = domain.MaxPageSize
+	}
+	start := max(startIndex, 1) - 1
+	if start >= len(items) {
+		return nil
+	}
+	return items[start:min(start+count, len(items))]
+}
+
+// diffCount returns how many of a are not in b.
+func diffCount(a, b []string) int {
+	n := 0
+	for _, id := range a {
+		if !slices.Contains(b, id) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,540 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+// memSCIMStore is an in-memory app.SCIMStore for a single workspace at a
+// time, keyed like the scim_resources table.
+type memSCIMStore struct {
+	members   map[string]domain.WorkspaceMember // workspaceID/userID
+	userNames map[string]string                 // workspaceID/normalized userName → userID
+	groups    map[string]domain.WorkspaceGroup  // workspaceID/groupID
+	users     []app.UserRecord
+	// updateGroupFn, if set, runs before every group update.
+	updateGroupFn func(group domain.WorkspaceGroup) error
+}
+
+func newMemSCIMStore() *memSCIMStore {
+	return &memSCIMStore{
+		members:   make(map[string]domain.WorkspaceMember),
+		userNames: make(map[string]string),
+		groups:    make(map[string]domain.WorkspaceGroup),
+	}
+}
+
+func (s *memSCIMStore) GetMember(_ context.Context, workspaceID, userID string) (*domain.WorkspaceMember, error) {
+	m, ok := s.members[workspaceID+"/"+userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &m, nil
+}
+
+func (s *memSCIMStore) FindMemberByUserName(ctx context.Context, workspaceID, userName string) (*domain.WorkspaceMember, error) {
+	userID, ok := s.userNames[workspaceID+"/"+domain.NormalizeUserName(userName)]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return s.GetMember(ctx, workspaceID, userID)
+}
+
+func (s *memSCIMStore) ListMembers(_ context.Context, workspaceID string) ([]domain.WorkspaceMember, error) {
+	var out []domain.WorkspaceMember
+	for _, m := range s.members {
+		if m.WorkspaceID == workspaceID {
+			out = append(out, m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserName < out[j].UserName })
+	return out, nil
+}
+
+func (s *memSCIMStore) CreateMember(_ context.Context, member domain.WorkspaceMember, user app.UserRecord) error {
+	key := member.WorkspaceID + "/" + domain.NormalizeUserName(member.UserName)
+	if _, ok := s.userNames[key]; ok {
+		return domain.ErrAlreadyExists
+	}
+	s.userNames[key] = member.UserID
+	s.members[member.WorkspaceID+"/"+member.UserID] = member
+	s.users = append(s.users, user)
+	return nil
+}
+
+func (s *memSCIMStore) UpdateMember(_ context.Context, member domain.WorkspaceMember, prevUserName string) error {
+	prevKey := member.WorkspaceID + "/" + domain.NormalizeUserName(prevUserName)
+	key := member.WorkspaceID + "/" + domain.NormalizeUserName(member.UserName)
+	if key != prevKey {
+		if _, ok := s.userNames[key]; ok {
+			return domain.ErrAlreadyExists
+		}
+		delete(s.userNames, prevKey)
+		s.userNames[key] = member.UserID
+	}
+	s.members[member.WorkspaceID+"/"+member.UserID] = member
+	return nil
+}
+
+func (s *memSCIMStore) DeleteMember(_ context.Context, member domain.WorkspaceMember) error {
+	delete(s.userNames, member.WorkspaceID+"/"+domain.NormalizeUserName(member.UserName))
+	delete(s.members, member.WorkspaceID+"/"+member.UserID)
+	return nil
+}
+
+func (s *memSCIMStore) GetGroup(_ context.Context, workspaceID, groupID string) (*domain.WorkspaceGroup, error) {
+	g, ok := s.groups[workspaceID+"/"+groupID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	g.MemberIDs = append([]string(nil), g.MemberIDs...)
+	return &g, nil
+}
+
+func (s *memSCIMStore) ListGroups(_ context.Context, workspaceID string) ([]domain.WorkspaceGroup, error) {
+	var out []domain.WorkspaceGroup
+	for _, g := range s.groups {
+		if g.WorkspaceID == workspaceID {
+			out = append(out, g)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DisplayName < out[j].DisplayName })
+	return out, nil
+}
+
+func (s *memSCIMStore) CreateGroup(_ context.Context, group domain.WorkspaceGroup) error {
+	s.groups[group.WorkspaceID+"/"+group.GroupID] = group
+	return nil
+}
+
+func (s *memSCIMStore) UpdateGroup(_ context.Context, group domain.WorkspaceGroup) error {
+	if s.updateGroupFn != nil {
+		if err := s.updateGroupFn(group); err != nil {
+			return err
+		}
+	}
+	key := group.WorkspaceID + "/" + group.GroupID
+	if s.groups[key].Version != group.Version {
+		return domain.ErrVersionConflict
+	}
+	group.Version++
+	s.groups[key] = group
+	return nil
+}
+
+func (s *memSCIMStore) DeleteGroup(_ context.Context, workspaceID, groupID string) error {
+	key := workspaceID + "/" + groupID
+	if _, ok := s.groups[key]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(s.groups, key)
+	return nil
+}
+
+type stubSessionRevoker struct {
+	revoked []string
+	err     error
+}
+
+func (r *stubSessionRevoker) RevokeUserSessions(_ context.Context, userID, reason string) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.revoked = append(r.revoked, userID+"/"+reason)
+	return 2, nil
+}
+
+type stubChatOffboarder struct {
+	calls []string
+	log   *[]string
+}
+
+func (o *stubChatOffboarder) SucceedOwner(_ context.Context, userID string) error {
+	o.calls = append(o.calls, userID)
+	*o.log = append(*o.log, "succeed")
+	return nil
+}
+
+type stubMembershipRemover struct {
+	calls []string
+	log   *[]string
+}
+
+func (m *stubMembershipRemover) RemoveUserMemberships(_ context.Context, userID string, _ time.Time) (int, error) {
+	m.calls = append(m.calls, userID)
+	*m.log = append(*m.log, "remove")
+	return 3, nil
+}
+
+type scimHarness struct {
+	svc         *app.SCIMService
+	store       *memSCIMStore
+	connections *stubSSOStore
+	sessions    *stubSessionRevoker
+	chats       *stubChatOffboarder
+	memberships *stubMembershipRemover
+	order       []string
+	logs        *bytes.Buffer
+}
+
+func newSCIMHarness(t *testing.T) *scimHarness {
+	t.Helper()
+	h := &scimHarness{
+		store: newMemSCIMStore(),
+		connections: &stubSSOStore{connections: map[string]domain.SSOConnection{
+			testWorkspace: {WorkspaceID: testWorkspace, Issuer: testIssuer, ClientID: "messaging"},
+		}},
+		sessions: &stubSessionRevoker{},
+		logs:     &bytes.Buffer{},
+	}
+	h.chats = &stubChatOffboarder{log: &h.order}
+	h.memberships = &stubMembershipRemover{log: &h.order}
+	h.svc = app.NewSCIMService(app.SCIMServiceConfig{
+		Store:       h.store,
+		Connections: h.connections,
+		Sessions:    h.sessions,
+		Chats:       h.chats,
+		Memberships: h.memberships,
+		Clock:       domaintest.NewFakeClock(testStart),
+		Logger:      slog.New(slog.NewJSONHandler(h.logs, nil)),
+	})
+	return h
+}
+
+func (h *scimHarness) createUser(t *testing.T, userName string) *domain.WorkspaceMember {
+	t.Helper()
+	m, err := h.svc.CreateUser(context.Background(), testWorkspace, domain.WorkspaceMember{UserName: userName, Active: true})
+	require.NoError(t, err)
+	return m
+}
+
+func TestSCIMService_Token(t *testing.T) {
+	h := newSCIMHarness(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, h.svc.Authenticate(ctx, testWorkspace, "anything"), domain.ErrUnauthorized,
+		"a workspace without a token rejects every token")
+
+	token, err := h.svc.RotateToken(ctx, "alice@ops", testWorkspace)
+	require.NoError(t, err)
+	assert.NotContains(t, h.connections.connections[testWorkspace].SCIMTokenHash, token)
+	assert.NoError(t, h.svc.Authenticate(ctx, testWorkspace, token))
+	assert.ErrorIs(t, h.svc.Authenticate(ctx, testWorkspace, token+"x"), domain.ErrUnauthorized)
+	assert.ErrorIs(t, h.svc.Authenticate(ctx, "ws-other", token), domain.ErrUnauthorized)
+
+	rotated, err := h.svc.RotateToken(ctx, "alice@ops", testWorkspace)
+	require.NoError(t, err)
+	assert.ErrorIs(t, h.svc.Authenticate(ctx, testWorkspace, token), domain.ErrUnauthorized)
+	assert.NoError(t, h.svc.Authenticate(ctx, testWorkspace, rotated))
+
+	_, err = h.svc.RotateToken(ctx, "alice@ops", "ws-other")
+	assert.ErrorIs(t, err, domain.ErrNotFound, "SCIM needs an SSO connection")
+	_, err = h.svc.RotateToken(ctx, "", testWorkspace)
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	records := auditRecords(t, h.logs)
+	require.Len(t, records, 4)
+	assert.Equal(t, "scim_rotate_token", records[0]["action"])
+	assert.Equal(t, "alice@ops", records[0]["actor"])
+	assert.NotContains(t, h.logs.String(), token)
+}
+
+func TestSCIMService_Residency(t *testing.T) {
+	h := newSCIMHarness(t)
+	ctx := context.Background()
+	token, err := h.svc.RotateToken(ctx, "alice@ops", testWorkspace)
+	require.NoError(t, err)
+
+	conn := h.connections.connections[testWorkspace]
+	conn.DataRegion = domain.RegionEU
+	h.connections.connections[testWorkspace] = conn
+	svc := app.NewSCIMService(app.SCIMServiceConfig{
+		Store:       h.store,
+		Connections: h.connections,
+		Sessions:    h.sessions,
+		Region:      domain.RegionUS,
+	})
+
+	err = svc.Authenticate(ctx, testWorkspace, token)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	assert.True(t, domain.IsResidencyError(err))
+	_, err = svc.RotateToken(ctx, "alice@ops", testWorkspace)
+	assert.True(t, domain.IsResidencyError(err))
+
+	assert.ErrorIs(t, svc.Authenticate(ctx, testWorkspace, "wrong"), domain.ErrUnauthorized,
+		"a bad token is rejected before the region is revealed")
+}
+
+func TestSCIMService_Users(t *testing.T) {
+	t.Run("create provisions a phone-less user with a unique userName", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		m, err := h.svc.CreateUser(context.Background(), testWorkspace, domain.WorkspaceMember{
+			UserName: "Ada@Example.com", ExternalID: "okta-1", DisplayName: "Ada", Active: true,
+		})
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, m.UserID)
+		assert.Equal(t, testWorkspace, m.WorkspaceID)
+		assert.Equal(t, testStart, m.CreatedAt)
+		require.Len(t, h.store.users, 1)
+		assert.Equal(t, m.UserID, h.store.users[0].UserID)
+		assert.Empty(t, h.store.users[0].PhoneNumber)
+
+		_, err = h.svc.CreateUser(context.Background(), testWorkspace, domain.WorkspaceMember{UserName: "ada@example.COM"})
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+		_, err = h.svc.CreateUser(context.Background(), testWorkspace, domain.WorkspaceMember{UserName: " "})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+		records := auditRecords(t, h.logs)
+		require.Len(t, records, 3)
+		assert.Equal(t, "scim_create_user", records[0]["action"])
+		assert.Equal(t, "scim:"+testWorkspace, records[0]["actor"])
+		assert.Equal(t, "ok", records[0]["outcome"])
+		assert.Equal(t, "rejected", records[2]["outcome"])
+	})
+
+	t.Run("list filters by userName and pages from startIndex", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		for _, name := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			h.createUser(t, name)
+		}
+		ctx := context.Background()
+
+		list, err := h.svc.ListUsers(ctx, testWorkspace, app.MemberFilter{UserName: "B@example.com"}, 1, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, list.Total)
+		assert.Equal(t, "b@example.com", list.Members[0].UserName)
+
+		list, err = h.svc.ListUsers(ctx, testWorkspace, app.MemberFilter{UserName: "z@example.com"}, 1, -1)
+		require.NoError(t, err)
+		assert.Zero(t, list.Total)
+
+		list, err = h.svc.ListUsers(ctx, testWorkspace, app.MemberFilter{}, 2, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, list.Total)
+		require.Len(t, list.Members, 1)
+		assert.Equal(t, "b@example.com", list.Members[0].UserName)
+
+		list, err = h.svc.ListUsers(ctx, testWorkspace, app.MemberFilter{}, 9, -1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, list.Total)
+		assert.Empty(t, list.Members)
+	})
+
+	t.Run("deactivation revokes sessions and removes every membership", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+		bob := h.createUser(t, "bob@example.com")
+		g, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{
+			DisplayName: "Engineering", MemberIDs: []string{ada.UserID, bob.UserID},
+		})
+		require.NoError(t, err)
+
+		inactive := false
+		m, err := h.svc.PatchUser(context.Background(), testWorkspace, ada.UserID, app.MemberPatch{Active: &inactive})
+		require.NoError(t, err)
+		assert.False(t, m.Active)
+
+		assert.Equal(t, []string{ada.UserID + "/scim_deprovision"}, h.sessions.revoked)
+		group, err := h.store.GetGroup(context.Background(), testWorkspace, g.GroupID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{bob.UserID}, group.MemberIDs)
+		assert.Equal(t, []string{ada.UserID}, h.chats.calls)
+		assert.Equal(t, []string{ada.UserID}, h.memberships.calls)
+		assert.Equal(t, []string{"succeed", "remove"}, h.order, "owned chats are handed off before memberships go")
+
+		records := auditRecords(t, h.logs)
+		last := records[len(records)-1]
+		assert.Equal(t, "scim_deactivate_user", last["action"])
+		assert.EqualValues(t, 2, last["sessions_revoked"])
+		assert.EqualValues(t, 1, last["groups_left"])
+		assert.EqualValues(t, 3, last["chats_left"])
+	})
+
+	t.Run("a failed deactivation finishes when the IdP retries", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+		h.sessions.err = errors.New("redis down")
+
+		inactive := false
+		_, err := h.svc.PatchUser(context.Background(), testWorkspace, ada.UserID, app.MemberPatch{Active: &inactive})
+		assert.ErrorContains(t, err, "redis down")
+		stored, _ := h.store.GetMember(context.Background(), testWorkspace, ada.UserID)
+		assert.False(t, stored.Active, "the member cannot sign in even though offboarding failed")
+		assert.Equal(t, "error", auditRecords(t, h.logs)[1]["outcome"])
+
+		h.sessions.err = nil
+		_, err = h.svc.PatchUser(context.Background(), testWorkspace, ada.UserID, app.MemberPatch{Active: &inactive})
+		require.NoError(t, err)
+		assert.Len(t, h.sessions.revoked, 1)
+	})
+
+	t.Run("reactivation restores sign-in only", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+		inactive, active := false, true
+		_, err := h.svc.PatchUser(context.Background(), testWorkspace, ada.UserID, app.MemberPatch{Active: &inactive})
+		require.NoError(t, err)
+
+		m, err := h.svc.PatchUser(context.Background(), testWorkspace, ada.UserID, app.MemberPatch{Active: &active})
+		require.NoError(t, err)
+		assert.True(t, m.Active)
+		assert.Len(t, h.sessions.revoked, 1)
+		records := auditRecords(t, h.logs)
+		assert.Equal(t, "scim_reactivate_user", records[len(records)-1]["action"])
+	})
+
+	t.Run("replace renames and keeps userNames unique", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+		h.createUser(t, "bob@example.com")
+
+		m, err := h.svc.ReplaceUser(context.Background(), testWorkspace, ada.UserID, domain.WorkspaceMember{
+			UserName: "ada.lovelace@example.com", DisplayName: "Ada Lovelace", Active: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Ada Lovelace", m.DisplayName)
+		assert.Equal(t, testStart, m.CreatedAt)
+		found, err := h.store.FindMemberByUserName(context.Background(), testWorkspace, "ada.lovelace@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, ada.UserID, found.UserID)
+
+		_, err = h.svc.ReplaceUser(context.Background(), testWorkspace, ada.UserID, domain.WorkspaceMember{UserName: "bob@example.com", Active: true})
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+		_, err = h.svc.ReplaceUser(context.Background(), testWorkspace, "missing", domain.WorkspaceMember{UserName: "x@example.com"})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.Empty(t, h.sessions.revoked)
+	})
+
+	t.Run("delete offboards and frees the userName", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+
+		require.NoError(t, h.svc.DeleteUser(context.Background(), testWorkspace, ada.UserID))
+
+		assert.Len(t, h.sessions.revoked, 1)
+		_, err := h.svc.GetUser(context.Background(), testWorkspace, ada.UserID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		h.createUser(t, "ada@example.com")
+
+		assert.ErrorIs(t, h.svc.DeleteUser(context.Background(), testWorkspace, ada.UserID), domain.ErrNotFound)
+	})
+}
+
+func TestSCIMService_Groups(t *testing.T) {
+	t.Run("members must belong to the workspace", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+
+		_, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{
+			DisplayName: "Engineering", MemberIDs: []string{ada.UserID, "stranger"},
+		})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+		g, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{
+			DisplayName: "Engineering", MemberIDs: []string{ada.UserID, ada.UserID},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{ada.UserID}, g.MemberIDs)
+		assert.Equal(t, int64(1), g.Version)
+	})
+
+	t.Run("patch adds and removes members", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada, bob := h.createUser(t, "ada@example.com"), h.createUser(t, "bob@example.com")
+		g, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{
+			DisplayName: "Engineering", MemberIDs: []string{ada.UserID},
+		})
+		require.NoError(t, err)
+
+		name := "Platform"
+		g, err = h.svc.PatchGroup(context.Background(), testWorkspace, g.GroupID, app.GroupPatch{
+			DisplayName:   &name,
+			AddMembers:    []string{bob.UserID},
+			RemoveMembers: []string{ada.UserID},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Platform", g.DisplayName)
+		assert.Equal(t, []string{bob.UserID}, g.MemberIDs)
+
+		records := auditRecords(t, h.logs)
+		last := records[len(records)-1]
+		assert.Equal(t, "scim_update_group", last["action"])
+		assert.EqualValues(t, 1, last["members_added"])
+		assert.EqualValues(t, 1, last["members_removed"])
+	})
+
+	t.Run("a concurrent write is reapplied on the new version", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada, bob := h.createUser(t, "ada@example.com"), h.createUser(t, "bob@example.com")
+		g, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{DisplayName: "Engineering"})
+		require.NoError(t, err)
+
+		raced := false
+		h.store.updateGroupFn = func(domain.WorkspaceGroup) error {
+			if !raced {
+				raced = true
+				stored := h.store.groups[testWorkspace+"/"+g.GroupID]
+				stored.MemberIDs = []string{bob.UserID}
+				stored.Version++
+				h.store.groups[testWorkspace+"/"+g.GroupID] = stored
+			}
+			return nil
+		}
+
+		g, err = h.svc.PatchGroup(context.Background(), testWorkspace, g.GroupID, app.GroupPatch{AddMembers: []string{ada.UserID}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{bob.UserID, ada.UserID}, g.MemberIDs)
+		assert.Equal(t, int64(3), h.store.groups[testWorkspace+"/"+g.GroupID].Version)
+	})
+
+	t.Run("replace, filter and delete", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ada := h.createUser(t, "ada@example.com")
+		g, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{DisplayName: "Engineering", ExternalID: "okta-g1"})
+		require.NoError(t, err)
+		_, err = h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{DisplayName: "Sales"})
+		require.NoError(t, err)
+
+		replaced, err := h.svc.ReplaceGroup(context.Background(), testWorkspace, g.GroupID, domain.WorkspaceGroup{
+			DisplayName: "Engineering", MemberIDs: []string{ada.UserID},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{ada.UserID}, replaced.MemberIDs)
+		assert.Empty(t, replaced.ExternalID)
+
+		list, err := h.svc.ListGroups(context.Background(), testWorkspace, app.GroupFilter{DisplayName: "engineering"}, 1, -1)
+		require.NoError(t, err)
+		require.Equal(t, 1, list.Total)
+		assert.Equal(t, g.GroupID, list.Groups[0].GroupID)
+
+		require.NoError(t, h.svc.DeleteGroup(context.Background(), testWorkspace, g.GroupID))
+		assert.ErrorIs(t, h.svc.DeleteGroup(context.Background(), testWorkspace, g.GroupID), domain.ErrNotFound)
+		_, err = h.svc.GetUser(context.Background(), testWorkspace, ada.UserID)
+		assert.NoError(t, err, "deleting a group keeps its members")
+	})
+
+	t.Run("group size is capped", func(t *testing.T) {
+		h := newSCIMHarness(t)
+		ids := make([]string, domain.MaxWorkspaceGroupMembers+1)
+		for i := range ids {
+			ids[i] = "user-" + strconv.Itoa(i)
+		}
+		_, err := h.svc.CreateGroup(context.Background(), testWorkspace, domain.WorkspaceGroup{DisplayName: "All", MemberIDs: ids})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		assert.ErrorContains(t, err, "at most")
+	})
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// SMSFallbackStore holds each user's SMS fallback setting. An unset
+// setting reads as off.
+type SMSFallbackStore interface {
+	SMSFallback(ctx context.Context, userID string) (domain.SMSFallback, error)
+	SetSMSFallback(ctx context.Context, userID string, s domain.SMSFallback) error
+}
+
+// SMSFallbackServiceConfig holds the dependencies for SMSFallbackService.
+type SMSFallbackServiceConfig struct {
+	Store     SMSFallbackStore
+	Validator *auth.Validator
+}
+
+// SMSFallbackService reads and changes the caller's SMS fallback setting.
+// Fanout sends the SMS; users can also turn it off by replying STOP.
+type SMSFallbackService struct {
+	store     SMSFallbackStore
+	validator *auth.Validator
+}
+
+// NewSMSFallbackService creates a new SMSFallbackService with the given
+// dependencies.
+func NewSMSFallbackService(cfg SMSFallbackServiceConfig) *SMSFallbackService {
+	return &SMSFallbackService{store: cfg.Store, validator: cfg.Validator}
+}
+
+// GetSMSFallback returns the caller's setting, with the threshold filled
+// in when it is the default.
+func (s *SMSFallbackService) GetSMSFallback(ctx context.Context, accessToken string) (domain.SMSFallback, error) {
+	ctx, span := tracer.Start(ctx, "sms_fallback.get")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return domain.SMSFallback{}, err
+	}
+	setting, err := s.store.SMSFallback(ctx, claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.SMSFallback{}, fmt.Errorf("get sms fallback: %w", err)
+	}
+	setting.OfflineAfter = setting.Threshold()
+	return setting, nil
+}
+
+// SetSMSFallback stores the caller's setting. A zero OfflineAfter selects
+// the default threshold.
+func (s *SMSFallbackService) SetSMSFallback(ctx context.Context, accessToken string, setting domain.SMSFallback) (domain.SMSFallback, error) {
+	ctx, span := tracer.Start(ctx, "sms_fallback.set")
+	defer span.End()
+
+	claims, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return domain.SMSFallback{}, err
+	}
+	if err := setting.Validate(); err != nil {
+		return domain.SMSFallback{}, err
+	}
+	if err := s.store.SetSMSFallback(ctx, claims.Subject, setting); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.SMSFallback{}, fmt.Errorf("set sms fallback: %w", err)
+	}
+	setting.OfflineAfter = setting.Threshold()
+	return setting, nil
+}
+
+func (s *SMSFallbackService) authenticate(ctx context.Context, accessToken string) (*auth.Claims, error) {
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,59 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubSMSFallbackStore implements app.SMSFallbackStore over a map.
+type stubSMSFallbackStore map[string]domain.SMSFallback
+
+func (s stubSMSFallbackStore) SMSFallback(_ context.Context, userID string) (domain.SMSFallback, error) {
+	return s[userID], nil
+}
+
+func (s stubSMSFallbackStore) SetSMSFallback(_ context.Context, userID string, setting domain.SMSFallback) error {
+	s[userID] = setting
+	return nil
+}
+
+func TestSMSFallbackService(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	store := stubSMSFallbackStore{}
+	svc := app.NewSMSFallbackService(app.SMSFallbackServiceConfig{Store: store, Validator: h.validator})
+
+	t.Run("unset reads as off with the default threshold", func(t *testing.T) {
+		got, err := svc.GetSMSFallback(ctx, feedToken(t, h))
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.SMSFallback{OfflineAfter: domain.DefaultSMSFallbackOfflineAfter}, got)
+	})
+
+	t.Run("set with the default threshold stores zero", func(t *testing.T) {
+		got, err := svc.SetSMSFallback(ctx, feedToken(t, h), domain.SMSFallback{Enabled: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.DefaultSMSFallbackOfflineAfter, got.OfflineAfter)
+		assert.Equal(t, domain.SMSFallback{Enabled: true}, store[feedUserID], "later default changes apply")
+	})
+
+	t.Run("threshold out of range", func(t *testing.T) {
+		_, err := svc.SetSMSFallback(ctx, feedToken(t, h), domain.SMSFallback{Enabled: true, OfflineAfter: time.Minute})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := svc.GetSMSFallback(ctx, "garbage")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// Translator translates text through an external provider. The source
+// language is detected by the provider.
+type Translator interface {
+	Translate(ctx context.Context, text, targetLanguage string) (domain.Translation, error)
+}
+
+// TranslationCache holds translations per (message, language). A miss is
+// (zero, false, nil).
+type TranslationCache interface {
+	GetTranslation(ctx context.Context, chatID string, sequence uint64, language string) (domain.Translation, bool, error)
+	PutTranslation(ctx context.Context, chatID string, sequence uint64, tr domain.Translation, ttl time.Duration) error
+}
+
+// LanguagePreferenceStore holds each user's preferred translation target.
+// An unset preference reads as "".
+type LanguagePreferenceStore interface {
+	PreferredLanguage(ctx context.Context, userID string) (string, error)
+	SetPreferredLanguage(ctx context.Context, userID, language string) error
+}
+
+// TranslationServiceConfig holds the dependencies for TranslationService.
+type TranslationServiceConfig struct {
+	Messages    MessageStore
+	Members     MembershipChecker
+	Translator  Translator
+	Cache       TranslationCache
+	Preferences LanguagePreferenceStore
+	RateLimiter RateLimiter
+	Validator   *auth.Validator
+	Logger      *slog.Logger
+}
+
+// TranslationService translates chat messages on demand. Translations are
+// cached so repeat requests for a message cost nothing, and provider calls
+// are rate limited per user to bound spend.
+type TranslationService struct {
+	messages    MessageStore
+	members     MembershipChecker
+	translator  Translator
+	cache       TranslationCache
+	preferences LanguagePreferenceStore
+	rateLimiter RateLimiter
+	validator   *auth.Validator
+	logger      *slog.Logger
+}
+
+// NewTranslationService creates a new TranslationService with the given
+// dependencies.
+func NewTranslationService(cfg TranslationServiceConfig) *TranslationService {
+	return &TranslationService{
+		messages:    cfg.Messages,
+		members:     cfg.Members,
+		translator:  cfg.Translator,
+		cache:       cfg.Cache,
+		preferences: cfg.Preferences,
+		rateLimiter: cfg.RateLimiter,
+		validator:   cfg.Validator,
+		logger:      cfg.Logger,
+	}
+}
+
+// TranslateMessage returns the text message at sequence in chatID
+// translated into targetLanguage, or into the caller's preferred language
+// when targetLanguage is empty. Cached translations are returned without
+// touching the provider or the rate limits.
+func (s *TranslationService) TranslateMessage(
+	ctx context.Context, accessToken, chatID string, sequence uint64, targetLanguage string,
+) (domain.Translation, error) {
+	ctx, span := tracer.Start(ctx, "translation.translate")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, s.logger)
+
+	// 1. Authenticate.
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	if sequence == 0 {
+		return domain.Translation{}, domain.NewValidationError("sequence", "must be positive")
+	}
+
+	// 2. Resolve the target language.
+	target, err := s.resolveTarget(ctx, claims.Subject, targetLanguage)
+	if err != nil {
+		return domain.Translation{}, fmt.Errorf("translate message: %w", err)
+	}
+	span.SetAttributes(attribute.String("translation.target", target))
+
+	// 3. Authorize.
+	member, err := s.members.IsMember(ctx, chatID, claims.Subject)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, fmt.Errorf("check membership: %w", err)
+	}
+	if !member {
+		return domain.Translation{}, fmt.Errorf("translate message: %w", domain.ErrNotMember)
+	}
+
+	// 4. Serve from cache (fail-open: a cache error falls through).
+	tr, hit, err := s.cache.GetTranslation(ctx, chatID, sequence, target)
+	if err != nil {
+		logger.WarnContext(ctx, "translation cache read failed, proceeding (fail-open)", "error", err)
+	} else if hit {
+		span.SetAttributes(attribute.Bool("translation.cache_hit", true))
+		return tr, nil
+	}
+
+	// 5. Cost protection (fail-closed: an unlimited provider bill is worse
+	// than a failed translation).
+	if err := s.checkRateLimits(ctx, claims.Subject); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, fmt.Errorf("translate message: %w", err)
+	}
+
+	// 6. Load and translate the message.
+	msg, err := s.message(ctx, chatID, sequence)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, fmt.Errorf("translate message: %w", err)
+	}
+	tr, err = s.translator.Translate(ctx, msg.Content, target)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.Translation{}, fmt.Errorf("translate message: %w", errors.Join(err, domain.ErrUnavailable))
+	}
+
+	if err := s.cache.PutTranslation(ctx, chatID, sequence, tr, domain.TranslationCacheTTL); err != nil {
+		logger.WarnContext(ctx, "translation cache write failed", "error", err)
+	}
+	return tr, nil
+}
+
+// SetPreferredLanguage sets the caller's default translation target. An
+// empty language clears it.
+func (s *TranslationService) SetPreferredLanguage(ctx context.Context, accessToken, language string) (string, error) {
+	ctx, span := tracer.Start(ctx, "translation.set_preference")
+	defer span.End()
+
+	claims, err := s.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		authFailuresTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "invalid_token")))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+
+	if language != "" {
+		if language, err = domain.NormalizeLanguage(language); err != nil {
+			return "", err
+		}
+	}
+	if err := s.preferences.SetPreferredLanguage(ctx, claims.Subject, language); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("set preferred language: %w", err)
+	}
+	return language, nil
+}
+
+// resolveTarget returns the normalized requested language, falling back to
+// the user's preference.
+func (s *TranslationService) resolveTarget(ctx context.Context, userID, requested string) (string, error) {
+	if requested != "" {
+		return domain.NormalizeLanguage(requested)
+	}
+	pref, err := s.preferences.PreferredLanguage(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("read preferred language: %w", err)
+	}
+	if pref == "" {
+		return "", domain.NewValidationError("target_language", "is required when no preferred language is set")
+	}
+	return pref, nil
+}
+
+// checkRateLimits applies the per-minute and per-day provider call limits.
+func (s *TranslationService) checkRateLimits(ctx context.Context, userID string) error {
+	limits := []struct {
+		name   string
+		limit  int
+		window time.Duration
+	}{
+		{"minute", domain.TranslateRateLimitPerMinute, domain.TranslateRateLimitMinuteWindow},
+		{"day", domain.TranslateRateLimitPerDay, domain.TranslateRateLimitDayWindow},
+	}
+	for _, l := range limits {
+		allowed, err := s.rateLimiter.CheckAndIncrement(ctx,
+			"translate:"+l.name+":"+userID, l.limit, int(l.window.Seconds()))
+		if err != nil {
+			return fmt.Errorf("check translation rate limit: %w", errors.Join(err, domain.ErrUnavailable))
+		}
+		if !allowed {
+			rateLimitsTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("endpoint", "translate_message"),
+				attribute.String("limit_type", l.name),
+			))
+			return fmt.Errorf("translation limit per %s: %w", l.name, domain.ErrRateLimited)
+		}
+	}
+	return nil
+}
+
+// message returns the text message at sequence.
+func (s *TranslationService) message(ctx context.Context, chatID string, sequence uint64) (MessageRecord, error) {
+	msgs, err := s.messages.ListAfter(ctx, chatID, sequence-1, 1)
+	if err != nil {
+		return MessageRecord{}, fmt.Errorf("load message: %w", err)
+	}
+	if len(msgs) == 0 || msgs[0].Sequence != sequence {
+		return MessageRecord{}, fmt.Errorf("message %d: %w", sequence, domain.ErrNotFound)
+	}
+	if msgs[0].ContentType != domain.ContentTypeText {
+		return MessageRecord{}, domain.NewValidationError("sequence", "only text messages can be translated")
+	}
+	return msgs[0], nil
+}
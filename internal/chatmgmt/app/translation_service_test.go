@@ -0,0 +1,242 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubTranslator records provider calls and returns a canned translation.
+type stubTranslator struct {
+	calls int
+	err   error
+}
+
+func (s *stubTranslator) Translate(_ context.Context, text, target string) (domain.Translation, error) {
+	s.calls++
+	if s.err != nil {
+		return domain.Translation{}, s.err
+	}
+	return domain.Translation{Text: "[" + target + "] " + text, SourceLanguage: "en", TargetLanguage: target}, nil
+}
+
+// memTranslationCache implements app.TranslationCache in memory.
+type memTranslationCache struct {
+	entries map[string]domain.Translation
+	getErr  error
+}
+
+func newMemTranslationCache() *memTranslationCache {
+	return &memTranslationCache{entries: map[string]domain.Translation{}}
+}
+
+func cacheKey(chatID string, sequence uint64, lang string) string {
+	return fmt.Sprintf("%s/%d/%s", chatID, sequence, lang)
+}
+
+func (c *memTranslationCache) GetTranslation(_ context.Context, chatID string, sequence uint64, lang string) (domain.Translation, bool, error) {
+	if c.getErr != nil {
+		return domain.Translation{}, false, c.getErr
+	}
+	tr, ok := c.entries[cacheKey(chatID, sequence, lang)]
+	return tr, ok, nil
+}
+
+func (c *memTranslationCache) PutTranslation(_ context.Context, chatID string, sequence uint64, tr domain.Translation, _ time.Duration) error {
+	c.entries[cacheKey(chatID, sequence, tr.TargetLanguage)] = tr
+	return nil
+}
+
+// stubPreferences implements app.LanguagePreferenceStore over a map.
+type stubPreferences map[string]string
+
+func (p stubPreferences) PreferredLanguage(_ context.Context, userID string) (string, error) {
+	return p[userID], nil
+}
+
+func (p stubPreferences) SetPreferredLanguage(_ context.Context, userID, lang string) error {
+	p[userID] = lang
+	return nil
+}
+
+type translationFixture struct {
+	svc        *app.TranslationService
+	translator *stubTranslator
+	cache      *memTranslationCache
+	prefs      stubPreferences
+	limiter    *stubRateLimiter
+	members    *stubMembershipChecker
+}
+
+func newTranslationFixture(h *testHarness) *translationFixture {
+	f := &translationFixture{
+		translator: &stubTranslator{},
+		cache:      newMemTranslationCache(),
+		prefs:      stubPreferences{},
+		limiter:    &stubRateLimiter{},
+		members:    &stubMembershipChecker{},
+	}
+	f.svc = app.NewTranslationService(app.TranslationServiceConfig{
+		Messages:    &stubMessageStore{messages: seedMessages("chat-001", 3)},
+		Members:     f.members,
+		Translator:  f.translator,
+		Cache:       f.cache,
+		Preferences: f.prefs,
+		RateLimiter: f.limiter,
+		Validator:   h.validator,
+		Logger:      slog.Default(),
+	})
+	return f
+}
+
+func TestTranslateMessage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("translates and caches", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+
+		tr, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 2, "ES")
+		require.NoError(t, err)
+		assert.Equal(t, domain.Translation{Text: "[es] message 2", SourceLanguage: "en", TargetLanguage: "es"}, tr)
+
+		again, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 2, "es")
+		require.NoError(t, err)
+		assert.Equal(t, tr, again)
+		assert.Equal(t, 1, f.translator.calls, "second request is served from cache")
+	})
+
+	t.Run("falls back to the preferred language", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		f.prefs[feedUserID] = "pt-BR"
+
+		tr, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, "pt-BR", tr.TargetLanguage)
+	})
+
+	t.Run("no target and no preference", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		f.members.isMemberFn = func(context.Context, string, string) (bool, error) { return false, nil }
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+
+		assert.ErrorIs(t, err, domain.ErrNotMember)
+		assert.Zero(t, f.translator.calls)
+	})
+
+	t.Run("missing message", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 9, "es")
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("rate limited per day", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		f.limiter.checkAndIncrementFn = func(_ context.Context, key string, _, _ int) (bool, error) {
+			return key != "translate:day:"+feedUserID, nil
+		}
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+
+		assert.ErrorIs(t, err, domain.ErrRateLimited)
+		assert.Zero(t, f.translator.calls)
+	})
+
+	t.Run("cache hits do not count against limits", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+		require.NoError(t, err)
+		f.limiter.checkAndIncrementFn = func(context.Context, string, int, int) (bool, error) { return false, nil }
+
+		_, err = f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("limiter failure fails closed", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		f.limiter.checkAndIncrementFn = func(context.Context, string, int, int) (bool, error) {
+			return false, errors.New("redis down")
+		}
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("cache failure fails open", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		f.cache.getErr = errors.New("redis down")
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("provider failure", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+		f.translator.err = errors.New("throttled")
+
+		_, err := f.svc.TranslateMessage(ctx, feedToken(t, h), "chat-001", 1, "es")
+
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		h := newTestHarness(t)
+		f := newTranslationFixture(h)
+
+		_, err := f.svc.TranslateMessage(ctx, "bogus", "chat-001", 1, "es")
+
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
+
+func TestSetPreferredLanguage(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	f := newTranslationFixture(h)
+
+	lang, err := f.svc.SetPreferredLanguage(ctx, feedToken(t, h), "DE")
+	require.NoError(t, err)
+	assert.Equal(t, "de", lang)
+	assert.Equal(t, "de", f.prefs[feedUserID])
+
+	_, err = f.svc.SetPreferredLanguage(ctx, feedToken(t, h), "german")
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	lang, err = f.svc.SetPreferredLanguage(ctx, feedToken(t, h), "")
+	require.NoError(t, err)
+	assert.Empty(t, lang)
+	assert.Empty(t, f.prefs[feedUserID])
+}
@@ -0,0 +1,263 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+// memUserDirectory is an in-memory user directory ordered newest first,
+// with ties broken by user ID, as the GSIs return them.
+type memUserDirectory struct {
+	users   []app.UserRecord
+	filters []app.UserFilter
+	listErr error
+}
+
+func (d *memUserDirectory) ListUsers(_ context.Context, filter app.UserFilter, after *app.UserPosition, limit int) ([]app.UserRecord, error) {
+	d.filters = append(d.filters, filter)
+	if d.listErr != nil {
+		return nil, d.listErr
+	}
+	sorted := append([]app.UserRecord(nil), d.users...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].CreatedAt != sorted[j].CreatedAt {
+			return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+		}
+		return sorted[i].UserID > sorted[j].UserID
+	})
+
+	var out []app.UserRecord
+	for _, u := range sorted {
+		if u.CreatedAt.Before(filter.CreatedFrom) || !u.CreatedAt.Before(filter.CreatedTo) {
+			continue
+		}
+		if filter.CallingCode != "" && !strings.HasPrefix(u.PhoneNumber, "+"+filter.CallingCode) {
+			continue
+		}
+		if filter.FlaggedOnly && u.FlagReason == "" {
+			continue
+		}
+		if after != nil && (u.CreatedAt.After(after.CreatedAt) ||
+			u.CreatedAt == after.CreatedAt && u.UserID >= after.UserID) {
+			continue
+		}
+		out = append(out, u)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (d *memUserDirectory) FlagUser(_ context.Context, userID, reason string, at domain.TimestampMS) error {
+	for i := range d.users {
+		if d.users[i].UserID == userID {
+			d.users[i].FlagReason, d.users[i].FlaggedAt = reason, at
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (d *memUserDirectory) UnflagUser(_ context.Context, userID string) error {
+	for i := range d.users {
+		if d.users[i].UserID == userID {
+			d.users[i].FlagReason, d.users[i].FlaggedAt = "", domain.TimestampMS{}
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+var directoryNow = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+func newDirectoryService(t *testing.T, dir *memUserDirectory) (*app.UserDirectoryService, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	return app.NewUserDirectoryService(app.UserDirectoryServiceConfig{
+		Directory: dir,
+		Clock:     domaintest.NewFakeClock(directoryNow),
+		Logger:    slog.New(slog.NewJSONHandler(&logs, nil)),
+	}), &logs
+}
+
+// auditRecords returns the admin.audit records in logs.
+func auditRecords(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		if rec["msg"] == "admin.audit" {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+func directoryUser(id, phone string, age time.Duration) app.UserRecord {
+	created := domain.NewTimestampMS(directoryNow.Add(-age))
+	return app.UserRecord{UserID: id, PhoneNumber: phone, CreatedAt: created, UpdatedAt: created}
+}
+
+func TestUserDirectoryService_ListUsers(t *testing.T) {
+	t.Run("pages newest first across ties", func(t *testing.T) {
+		dir := &memUserDirectory{users: []app.UserRecord{
+			directoryUser("user-a", "+447700900001", time.Hour),
+			directoryUser("user-b", "+447700900002", time.Hour),
+			directoryUser("user-c", "+15551234567", 2*time.Hour),
+			directoryUser("user-old", "+15551234568", 40*24*time.Hour),
+		}}
+		svc, _ := newDirectoryService(t, dir)
+
+		first, err := svc.ListUsers(context.Background(), "alice", app.UserFilter{}, "", 2)
+		require.NoError(t, err)
+		require.Len(t, first.Users, 2)
+		assert.Equal(t, "user-b", first.Users[0].UserID)
+		assert.Equal(t, "user-a", first.Users[1].UserID)
+		require.NotEmpty(t, first.Cursor)
+
+		second, err := svc.ListUsers(context.Background(), "alice", app.UserFilter{}, first.Cursor, 2)
+		require.NoError(t, err)
+		require.Len(t, second.Users, 1)
+		assert.Equal(t, "user-c", second.Users[0].UserID)
+		assert.Empty(t, second.Cursor)
+	})
+
+	t.Run("defaults the window to the last 30 days", func(t *testing.T) {
+		dir := &memUserDirectory{}
+		svc, _ := newDirectoryService(t, dir)
+
+		_, err := svc.ListUsers(context.Background(), "alice", app.UserFilter{}, "", 0)
+
+		require.NoError(t, err)
+		require.Len(t, dir.filters, 1)
+		assert.Equal(t, domain.NewTimestampMS(directoryNow), dir.filters[0].CreatedTo)
+		assert.Equal(t, domain.NewTimestampMS(directoryNow.Add(-domain.UserListDefaultWindow)), dir.filters[0].CreatedFrom)
+	})
+
+	t.Run("filters by country and flag", func(t *testing.T) {
+		flagged := directoryUser("user-a", "+447700900001", time.Hour)
+		flagged.FlagReason = "chargeback"
+		dir := &memUserDirectory{users: []app.UserRecord{
+			flagged,
+			directoryUser("user-b", "+447700900002", time.Hour),
+			directoryUser("user-c", "+15551234567", time.Hour),
+		}}
+		svc, _ := newDirectoryService(t, dir)
+
+		page, err := svc.ListUsers(context.Background(), "alice",
+			app.UserFilter{CallingCode: "+44", FlaggedOnly: true}, "", 10)
+
+		require.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, "user-a", page.Users[0].UserID)
+		assert.Equal(t, "44", dir.filters[0].CallingCode)
+	})
+
+	t.Run("audits the query", func(t *testing.T) {
+		svc, logs := newDirectoryService(t, &memUserDirectory{users: []app.UserRecord{
+			directoryUser("user-a", "+447700900001", time.Hour),
+		}})
+
+		_, err := svc.ListUsers(context.Background(), "alice", app.UserFilter{CallingCode: "44"}, "", 0)
+		require.NoError(t, err)
+
+		records := auditRecords(t, logs)
+		require.Len(t, records, 1)
+		assert.Equal(t, "list_users", records[0]["action"])
+		assert.Equal(t, "alice", records[0]["actor"])
+		assert.Equal(t, "ok", records[0]["outcome"])
+		assert.Equal(t, "44", records[0]["calling_code"])
+		assert.Equal(t, "2026-10-15T12:00:00.000Z", records[0]["created_to"])
+		assert.EqualValues(t, 1, records[0]["results"])
+	})
+
+	t.Run("store failure is audited", func(t *testing.T) {
+		svc, logs := newDirectoryService(t, &memUserDirectory{listErr: errors.New("throttled")})
+
+		_, err := svc.ListUsers(context.Background(), "alice", app.UserFilter{}, "", 0)
+
+		require.Error(t, err)
+		records := auditRecords(t, logs)
+		require.Len(t, records, 1)
+		assert.Equal(t, "error", records[0]["outcome"])
+	})
+
+	invalid := []struct {
+		name   string
+		actor  string
+		filter app.UserFilter
+		cursor string
+	}{
+		{name: "missing actor", actor: " "},
+		{name: "window too long", actor: "alice", filter: app.UserFilter{
+			CreatedFrom: domain.NewTimestampMS(directoryNow.Add(-domain.UserListMaxWindow - time.Hour)),
+		}},
+		{name: "inverted window", actor: "alice", filter: app.UserFilter{
+			CreatedFrom: domain.NewTimestampMS(directoryNow),
+			CreatedTo:   domain.NewTimestampMS(directoryNow.Add(-time.Hour)),
+		}},
+		{name: "country code not digits", actor: "alice", filter: app.UserFilter{CallingCode: "uk"}},
+		{name: "country code too long", actor: "alice", filter: app.UserFilter{CallingCode: "4412"}},
+		{name: "malformed cursor", actor: "alice", cursor: "!!"},
+		{name: "cursor without user", actor: "alice", cursor: "MTIz"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := &memUserDirectory{}
+			svc, logs := newDirectoryService(t, dir)
+
+			_, err := svc.ListUsers(context.Background(), tt.actor, tt.filter, tt.cursor, 0)
+
+			assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			assert.Empty(t, dir.filters)
+			records := auditRecords(t, logs)
+			require.Len(t, records, 1)
+			assert.Equal(t, "rejected", records[0]["outcome"])
+		})
+	}
+}
+
+func TestUserDirectoryService_Flag(t *testing.T) {
+	dir := &memUserDirectory{users: []app.UserRecord{directoryUser("user-a", "+447700900001", time.Hour)}}
+	svc, logs := newDirectoryService(t, dir)
+
+	require.NoError(t, svc.FlagUser(context.Background(), "alice", "user-a", "  suspected SIM swap "))
+	assert.Equal(t, "suspected SIM swap", dir.users[0].FlagReason)
+	assert.Equal(t, domain.NewTimestampMS(directoryNow), dir.users[0].FlaggedAt)
+
+	require.NoError(t, svc.UnflagUser(context.Background(), "bob", "user-a"))
+	assert.Empty(t, dir.users[0].FlagReason)
+
+	assert.ErrorIs(t, svc.FlagUser(context.Background(), "alice", "user-x", "spam"), domain.ErrNotFound)
+	assert.ErrorIs(t, svc.FlagUser(context.Background(), "alice", "user-a", ""), domain.ErrInvalidInput)
+	assert.ErrorIs(t, svc.FlagUser(context.Background(), "alice", "user-a",
+		strings.Repeat("x", domain.MaxUserFlagReasonLength+1)), domain.ErrInvalidInput)
+	assert.ErrorIs(t, svc.UnflagUser(context.Background(), "", "user-a"), domain.ErrInvalidInput)
+
+	records := auditRecords(t, logs)
+	require.Len(t, records, 6)
+	assert.Equal(t, "flag_user", records[0]["action"])
+	assert.Equal(t, "user-a", records[0]["user_id"])
+	assert.Equal(t, "unflag_user", records[1]["action"])
+	assert.Equal(t, "bob", records[1]["actor"])
+	assert.Equal(t, "rejected", records[2]["outcome"])
+}
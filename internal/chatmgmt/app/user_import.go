@@ -0,0 +1,337 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var userImportRowsTotal metric.Int64Counter
+
+func init() {
+	userImportRowsTotal, _ = otel.Meter("chatmgmt/app").Int64Counter("chatmgmt_user_import_rows_total",
+		metric.WithDescription("Bulk user import rows by outcome and dry run"))
+}
+
+// ImportRow is one user in a bulk import manifest.
+type ImportRow struct {
+	// ExternalID is the user's ID in the legacy system. The platform user
+	// ID is derived from it (domain.ImportedUserID), which makes imports
+	// idempotent.
+	ExternalID  string
+	PhoneNumber string
+	DisplayName string
+	// ChatIDs are chats the user joins as a member.
+	ChatIDs []string
+}
+
+// ImportOutcome is what happened to one import row.
+type ImportOutcome string
+
+// Import outcomes. In a dry run, created means the row would be created.
+const (
+	ImportCreated  ImportOutcome = "created"
+	ImportExisting ImportOutcome = "existing" // created by an earlier run
+	ImportConflict ImportOutcome = "conflict" // phone or ID held by another user
+	ImportInvalid  ImportOutcome = "invalid"
+	ImportFailed   ImportOutcome = "failed" // safe to retry by re-running
+)
+
+// ImportResult reports one import row.
+type ImportResult struct {
+	Row         int // 1-based position in the manifest
+	ExternalID  string
+	UserID      string
+	Outcome     ImportOutcome
+	Memberships int // chats joined, or that would be joined in a dry run
+	Reason      string
+}
+
+// ImportProgress counts the rows processed so far.
+type ImportProgress struct {
+	Processed int
+	Created   int
+	Existing  int
+	Conflicts int
+	Invalid   int
+	Failed    int
+}
+
+func (p *ImportProgress) add(o ImportOutcome) {
+	p.Processed++
+	switch o {
+	case ImportCreated:
+		p.Created++
+	case ImportExisting:
+		p.Existing++
+	case ImportConflict:
+		p.Conflicts++
+	case ImportInvalid:
+		p.Invalid++
+	case ImportFailed:
+		p.Failed++
+	}
+}
+
+// ImportSource yields manifest rows. Next returns io.EOF after the last
+// row; any other error aborts the import.
+type ImportSource interface {
+	Next() (ImportRow, error)
+}
+
+// UserImportStore writes imported users.
+type UserImportStore interface {
+	// CreateImportedUser creates user and its phone sentinel in one
+	// transaction, and returns domain.ErrAlreadyExists if either exists.
+	CreateImportedUser(ctx context.Context, user UserRecord) error
+}
+
+// ChatMemberImporter adds imported users to chats.
+type ChatMemberImporter interface {
+	// AddImportedMember makes userID a member of chatID. It keeps an
+	// existing membership and its role, and returns domain.ErrNotFound if
+	// the chat does not exist.
+	AddImportedMember(ctx context.Context, chatID, userID string, joinedAt time.Time) error
+}
+
+// UserImportServiceConfig holds the dependencies for UserImportService.
+type UserImportServiceConfig struct {
+	Users    UserStore
+	Importer UserImportStore
+	// Members adds memberships; nil rejects rows that list chats.
+	Members ChatMemberImporter
+	Clock   domain.Clock
+	Logger  *slog.Logger
+
+	// RatePerSecond caps rows written per second. Zero defaults to
+	// domain.ImportRatePerSecond.
+	RatePerSecond int
+}
+
+// UserImportService migrates users from a legacy system. Each row creates
+// a user and its phone sentinel, then adds the user's chat memberships.
+// Re-running a manifest is safe: rows already imported report existing and
+// have missing memberships added, so a failed or interrupted import is
+// finished by running it again.
+//
+// One import runs at a time per process.
+type UserImportService struct {
+	users    UserStore
+	importer UserImportStore
+	members  ChatMemberImporter
+	clock    domain.Clock
+	logger   *slog.Logger
+	interval time.Duration
+
+	running sync.Mutex
+}
+
+// NewUserImportService creates a UserImportService.
+func NewUserImportService(cfg UserImportServiceConfig) *UserImportService {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	rate := cfg.RatePerSecond
+	if rate <= 0 {
+		rate = domain.ImportRatePerSecond
+	}
+	return &UserImportService{
+		users:    cfg.Users,
+		importer: cfg.Importer,
+		members:  cfg.Members,
+		clock:    clock,
+		logger:   logger,
+		interval: time.Second / time.Duration(rate),
+	}
+}
+
+// Import processes every row of src, calling report after each. A dry run
+// validates rows and checks them against existing users without writing.
+// It returns the final counts, and an error only if the import could not
+// run or src failed; per-row failures are reported and counted instead.
+// Another import in progress returns domain.ErrUnavailable.
+func (s *UserImportService) Import(ctx context.Context, src ImportSource, dryRun bool, report func(ImportResult, ImportProgress)) (ImportProgress, error) {
+	if !s.running.TryLock() {
+		return ImportProgress{}, fmt.Errorf("user import already running: %w", domain.ErrUnavailable)
+	}
+	defer s.running.Unlock()
+
+	ctx, span := tracer.Start(ctx, "app.UserImport")
+	defer span.End()
+
+	pace := time.NewTicker(s.interval)
+	defer pace.Stop()
+
+	s.logger.InfoContext(ctx, "user_import.started", "dry_run", dryRun)
+	var progress ImportProgress
+	for row := 1; ; row++ {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "user_import.source_failed", "row", row, "error", err)
+			return progress, fmt.Errorf("user import: read row %d: %w", row, err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-pace.C:
+		}
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		result := s.importRow(ctx, r, dryRun)
+		result.Row = row
+		progress.add(result.Outcome)
+		userImportRowsTotal.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("outcome", string(result.Outcome)), attribute.Bool("dry_run", dryRun)))
+		if result.Outcome == ImportFailed {
+			s.logger.WarnContext(ctx, "user_import.row_failed",
+				"row", row, "external_id", r.ExternalID, "reason", result.Reason)
+		}
+		if report != nil {
+			report(result, progress)
+		}
+	}
+
+	s.logger.InfoContext(ctx, "user_import.finished",
+		"dry_run", dryRun,
+		"processed", progress.Processed,
+		"created", progress.Created,
+		"existing", progress.Existing,
+		"conflicts", progress.Conflicts,
+		"invalid", progress.Invalid,
+		"failed", progress.Failed,
+	)
+	return progress, nil
+}
+
+// importRow imports one row.
+func (s *UserImportService) importRow(ctx context.Context, r ImportRow, dryRun bool) ImportResult {
+	res := ImportResult{ExternalID: r.ExternalID}
+	phone, reason := s.validate(r)
+	if reason != "" {
+		res.Outcome, res.Reason = ImportInvalid, reason
+		return res
+	}
+	res.UserID = domain.ImportedUserID(r.ExternalID).String()
+
+	res.Outcome, res.Reason = s.createUser(ctx, res.UserID, phone, r.DisplayName, dryRun)
+	if res.Outcome != ImportCreated && res.Outcome != ImportExisting {
+		return res
+	}
+	if dryRun {
+		res.Memberships = len(r.ChatIDs)
+		return res
+	}
+
+	joinedAt := s.clock.Now()
+	for _, chatID := range r.ChatIDs {
+		if err := s.members.AddImportedMember(ctx, chatID, res.UserID, joinedAt); err != nil {
+			res.Outcome = ImportFailed
+			res.Reason = fmt.Sprintf("join chat %s: %v", chatID, err)
+			return res
+		}
+		res.Memberships++
+	}
+	return res
+}
+
+// validate returns r's normalized phone number, or why r is invalid.
+func (s *UserImportService) validate(r ImportRow) (string, string) {
+	if r.ExternalID == "" {
+		return "", "external_id is required"
+	}
+	phone, err := domain.NewPhoneNumber(r.PhoneNumber)
+	if err != nil {
+		return "", fmt.Sprintf("phone_number: %v", err)
+	}
+	if utf8.RuneCountInString(r.DisplayName) > domain.MaxDisplayNameLength {
+		return "", fmt.Sprintf("display_name exceeds %d characters", domain.MaxDisplayNameLength)
+	}
+	if len(r.ChatIDs) > 0 && s.members == nil {
+		return "", "chat memberships are not enabled for imports"
+	}
+	if len(r.ChatIDs) > domain.MaxImportChats {
+		return "", fmt.Sprintf("more than %d chat_ids", domain.MaxImportChats)
+	}
+	for _, id := range r.ChatIDs {
+		if _, err := domain.NewChatID(id); err != nil {
+			return "", fmt.Sprintf("chat_ids: %v", err)
+		}
+	}
+	return phone.String(), ""
+}
+
+// createUser creates the user, or in a dry run checks that it could be.
+func (s *UserImportService) createUser(ctx context.Context, userID, phone, displayName string, dryRun bool) (ImportOutcome, string) {
+	if dryRun {
+		if outcome, reason, done := s.existing(ctx, userID, phone); done {
+			return outcome, reason
+		}
+		_, err := s.users.FindByPhone(ctx, phone)
+		switch {
+		case err == nil:
+			return ImportConflict, "phone_number belongs to another user"
+		case errors.Is(err, domain.ErrNotFound):
+			return ImportCreated, ""
+		default:
+			return ImportFailed, fmt.Sprintf("find by phone: %v", err)
+		}
+	}
+
+	now := domain.TimestampNow(s.clock)
+	err := s.importer.CreateImportedUser(ctx, UserRecord{
+		UserID:      userID,
+		PhoneNumber: phone,
+		DisplayName: displayName,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	switch {
+	case err == nil:
+		return ImportCreated, ""
+	case !errors.Is(err, domain.ErrAlreadyExists):
+		return ImportFailed, err.Error()
+	}
+
+	if outcome, reason, done := s.existing(ctx, userID, phone); done {
+		return outcome, reason
+	}
+	// The user does not exist, so the phone sentinel does.
+	return ImportConflict, "phone_number belongs to another user"
+}
+
+// existing classifies a row whose user ID is already taken. done is false
+// when no such user exists.
+func (s *UserImportService) existing(ctx context.Context, userID, phone string) (outcome ImportOutcome, reason string, done bool) {
+	u, err := s.users.GetByID(ctx, userID)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return "", "", false
+	case err != nil:
+		return ImportFailed, fmt.Sprintf("get user: %v", err), true
+	case u.PhoneNumber != phone:
+		return ImportConflict, "external_id was imported with another phone_number", true
+	default:
+		return ImportExisting, "", true
+	}
+}
@@ -0,0 +1,314 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+// memImportStore is an in-memory users table with phone sentinels and chat
+// memberships.
+type memImportStore struct {
+	mu          sync.Mutex
+	users       map[string]app.UserRecord
+	phones      map[string]string // phone -> user ID
+	chats       map[string]bool
+	memberships map[string]time.Time // chatID/userID -> joined_at
+	createErr   error
+}
+
+func newMemImportStore(chats ...string) *memImportStore {
+	s := &memImportStore{
+		users:       make(map[string]app.UserRecord),
+		phones:      make(map[string]string),
+		chats:       make(map[string]bool),
+		memberships: make(map[string]time.Time),
+	}
+	for _, c := range chats {
+		s.chats[c] = true
+	}
+	return s
+}
+
+func (s *memImportStore) GetByID(_ context.Context, userID string) (*app.UserRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (s *memImportStore) FindByPhone(_ context.Context, phone string) (*app.UserRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.phones[phone]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	u := s.users[id]
+	return &u, nil
+}
+
+func (s *memImportStore) CreateImportedUser(_ context.Context, user app.UserRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.createErr != nil {
+		return s.createErr
+	}
+	if _, ok := s.users[user.UserID]; ok {
+		return domain.ErrAlreadyExists
+	}
+	if _, ok := s.phones[user.PhoneNumber]; ok {
+		return domain.ErrAlreadyExists
+	}
+	s.users[user.UserID] = user
+	s.phones[user.PhoneNumber] = user.UserID
+	return nil
+}
+
+func (s *memImportStore) AddImportedMember(_ context.Context, chatID, userID string, joinedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.chats[chatID] {
+		return domain.ErrNotFound
+	}
+	key := chatID + "/" + userID
+	if _, ok := s.memberships[key]; !ok {
+		s.memberships[key] = joinedAt
+	}
+	return nil
+}
+
+// sliceSource yields rows, then err (io.EOF when nil).
+type sliceSource struct {
+	rows []app.ImportRow
+	err  error
+}
+
+func (s *sliceSource) Next() (app.ImportRow, error) {
+	if len(s.rows) == 0 {
+		if s.err != nil {
+			return app.ImportRow{}, s.err
+		}
+		return app.ImportRow{}, io.EOF
+	}
+	r := s.rows[0]
+	s.rows = s.rows[1:]
+	return r, nil
+}
+
+const (
+	importChatA = "11111111-1111-4111-8111-111111111111"
+	importChatB = "22222222-2222-4222-8222-222222222222"
+)
+
+func newTestImportService(store *memImportStore) *app.UserImportService {
+	return app.NewUserImportService(app.UserImportServiceConfig{
+		Users:         store,
+		Importer:      store,
+		Members:       store,
+		Clock:         domaintest.NewFakeClock(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)),
+		RatePerSecond: 1_000_000,
+	})
+}
+
+func runImport(t *testing.T, svc *app.UserImportService, dryRun bool, rows ...app.ImportRow) ([]app.ImportResult, app.ImportProgress) {
+	t.Helper()
+	var results []app.ImportResult
+	progress, err := svc.Import(context.Background(), &sliceSource{rows: rows}, dryRun, func(r app.ImportResult, _ app.ImportProgress) {
+		results = append(results, r)
+	})
+	require.NoError(t, err)
+	return results, progress
+}
+
+func TestUserImportService_Import(t *testing.T) {
+	rows := []app.ImportRow{
+		{ExternalID: "legacy-1", PhoneNumber: "+1 (555) 123-4567", DisplayName: "Ada", ChatIDs: []string{importChatA, importChatB}},
+		{ExternalID: "legacy-2", PhoneNumber: "+15551234568"},
+		{ExternalID: "legacy-3", PhoneNumber: "+15551234567"}, // legacy-1's phone
+		{ExternalID: "", PhoneNumber: "+15551234569"},
+		{ExternalID: "legacy-5", PhoneNumber: "12"},
+		{ExternalID: "legacy-6", PhoneNumber: "+15551234570", ChatIDs: []string{"not-a-uuid"}},
+	}
+
+	t.Run("creates users, sentinels and memberships", func(t *testing.T) {
+		store := newMemImportStore(importChatA, importChatB)
+		svc := newTestImportService(store)
+
+		results, progress := runImport(t, svc, false, rows...)
+
+		assert.Equal(t, app.ImportProgress{Processed: 6, Created: 2, Conflicts: 1, Invalid: 3}, progress)
+		require.Len(t, results, 6)
+		userID := domain.ImportedUserID("legacy-1").String()
+		assert.Equal(t, app.ImportResult{Row: 1, ExternalID: "legacy-1", UserID: userID, Outcome: app.ImportCreated, Memberships: 2}, results[0])
+		assert.Equal(t, app.ImportConflict, results[2].Outcome)
+		for _, r := range results[3:] {
+			assert.Equal(t, app.ImportInvalid, r.Outcome)
+			assert.NotEmpty(t, r.Reason)
+		}
+
+		u, err := store.GetByID(context.Background(), userID)
+		require.NoError(t, err)
+		assert.Equal(t, "+15551234567", u.PhoneNumber)
+		assert.Equal(t, "Ada", u.DisplayName)
+		assert.Equal(t, "2026-10-01T12:00:00.000Z", u.CreatedAt.String())
+		assert.Len(t, store.memberships, 2)
+	})
+
+	t.Run("re-running is idempotent", func(t *testing.T) {
+		store := newMemImportStore(importChatA, importChatB)
+		svc := newTestImportService(store)
+		runImport(t, svc, false, rows...)
+
+		results, progress := runImport(t, svc, false, rows...)
+
+		assert.Equal(t, app.ImportProgress{Processed: 6, Existing: 2, Conflicts: 1, Invalid: 3}, progress)
+		assert.Equal(t, 2, results[0].Memberships)
+		assert.Len(t, store.users, 2)
+		assert.Len(t, store.memberships, 2)
+	})
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		store := newMemImportStore(importChatA, importChatB)
+		svc := newTestImportService(store)
+		runImport(t, svc, false, rows[1])
+
+		results, progress := runImport(t, svc, true, rows...)
+
+		assert.Equal(t, app.ImportProgress{Processed: 6, Created: 2, Existing: 1, Invalid: 3}, progress,
+			"legacy-3 only conflicts once legacy-1 is written")
+		assert.Equal(t, 2, results[0].Memberships)
+		assert.Len(t, store.users, 1)
+		assert.Empty(t, store.memberships)
+	})
+
+	t.Run("external ID imported with another phone conflicts", func(t *testing.T) {
+		store := newMemImportStore()
+		svc := newTestImportService(store)
+		runImport(t, svc, false, app.ImportRow{ExternalID: "legacy-1", PhoneNumber: "+15551234567"})
+
+		results, _ := runImport(t, svc, false, app.ImportRow{ExternalID: "legacy-1", PhoneNumber: "+15551234599"})
+
+		assert.Equal(t, app.ImportConflict, results[0].Outcome)
+		assert.Contains(t, results[0].Reason, "another phone_number")
+	})
+
+	t.Run("missing chat fails the row, and a re-run finishes it", func(t *testing.T) {
+		store := newMemImportStore(importChatA)
+		svc := newTestImportService(store)
+		row := app.ImportRow{ExternalID: "legacy-1", PhoneNumber: "+15551234567", ChatIDs: []string{importChatA, importChatB}}
+
+		results, progress := runImport(t, svc, false, row)
+		assert.Equal(t, 1, progress.Failed)
+		assert.Equal(t, 1, results[0].Memberships)
+		assert.Contains(t, results[0].Reason, importChatB)
+
+		store.chats[importChatB] = true
+		results, _ = runImport(t, svc, false, row)
+		assert.Equal(t, app.ImportExisting, results[0].Outcome)
+		assert.Len(t, store.memberships, 2)
+	})
+
+	t.Run("store errors fail the row", func(t *testing.T) {
+		store := newMemImportStore()
+		store.createErr = errors.New("throttled")
+		svc := newTestImportService(store)
+
+		results, progress := runImport(t, svc, false, rows[1])
+
+		assert.Equal(t, app.ImportProgress{Processed: 1, Failed: 1}, progress)
+		assert.Equal(t, "throttled", results[0].Reason)
+	})
+
+	t.Run("memberships disabled rejects rows with chats", func(t *testing.T) {
+		store := newMemImportStore(importChatA)
+		svc := app.NewUserImportService(app.UserImportServiceConfig{Users: store, Importer: store, RatePerSecond: 1_000_000})
+
+		results, _ := runImport(t, svc, false, rows[0], rows[1])
+
+		assert.Equal(t, app.ImportInvalid, results[0].Outcome)
+		assert.Equal(t, app.ImportCreated, results[1].Outcome)
+	})
+}
+
+func TestUserImportService_ImportSourceError(t *testing.T) {
+	store := newMemImportStore()
+	svc := newTestImportService(store)
+	src := &sliceSource{
+		rows: []app.ImportRow{{ExternalID: "legacy-1", PhoneNumber: "+15551234567"}},
+		err:  errors.New("unexpected EOF"),
+	}
+
+	progress, err := svc.Import(context.Background(), src, false, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read row 2")
+	assert.Equal(t, 1, progress.Created)
+}
+
+func TestUserImportService_OneImportAtATime(t *testing.T) {
+	store := newMemImportStore()
+	svc := newTestImportService(store)
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.Import(context.Background(), &sliceSource{rows: []app.ImportRow{
+			{ExternalID: "legacy-1", PhoneNumber: "+15551234567"},
+		}}, false, func(app.ImportResult, app.ImportProgress) {
+			close(started)
+			<-block
+		})
+		done <- err
+	}()
+	<-started
+
+	_, err := svc.Import(context.Background(), &sliceSource{}, true, nil)
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+
+	close(block)
+	require.NoError(t, <-done)
+	_, err = svc.Import(context.Background(), &sliceSource{}, true, nil)
+	require.NoError(t, err)
+}
+
+func TestUserImportService_ImportRateLimited(t *testing.T) {
+	store := newMemImportStore()
+	svc := app.NewUserImportService(app.UserImportServiceConfig{Users: store, Importer: store, RatePerSecond: 50})
+
+	start := time.Now()
+	_, progress := runImport(t, svc, true,
+		app.ImportRow{ExternalID: "a", PhoneNumber: "+15551234567"},
+		app.ImportRow{ExternalID: "b", PhoneNumber: "+15551234568"},
+		app.ImportRow{ExternalID: "c", PhoneNumber: "+15551234569"},
+	)
+
+	assert.Equal(t, 3, progress.Processed)
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond, "three rows at 50/s take at least three 20ms ticks")
+}
+
+func TestUserImportService_ImportCanceled(t *testing.T) {
+	store := newMemImportStore()
+	svc := newTestImportService(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := svc.Import(ctx, &sliceSource{rows: []app.ImportRow{{ExternalID: "a", PhoneNumber: "+15551234567"}}}, false, nil)
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, store.users)
+}
@@ -11,33 +11,40 @@ import (
 
 	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
 	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
 )
 
 // authService is a narrow, consumer-defined interface for the auth service
 // operations the handler requires. The *app.AuthService satisfies this.
 type authService interface {
 	RequestOTP(ctx context.Context, phone, clientIP string) (*app.RequestOTPResult, error)
-	VerifyOTP(ctx context.Context, phone, otpCandidate, deviceID string) (*app.VerifyOTPResult, error)
-	RefreshTokens(ctx context.Context, accessToken, refreshToken, deviceID string) (*app.RefreshResult, error)
+	VerifyOTP(ctx context.Context, phone, otpCandidate, deviceID, clientIP string) (*app.VerifyOTPResult, error)
+	RefreshTokens(ctx context.Context, accessToken, refreshToken, deviceID, clientIP string) (*app.RefreshResult, error)
 	Logout(ctx context.Context, accessToken string) error
+	RegisterPushToken(ctx context.Context, accessToken string, reg app.PushRegistration) error
+	ListDevices(ctx context.Context, accessToken string) ([]app.DeviceSession, error)
+	LogoutAllSessions(ctx context.Context, accessToken string) (int, error)
 }
 
 // AuthHandler implements the gRPC AuthServiceServer interface.
 // It translates proto requests into app-layer calls and maps results back.
 type AuthHandler struct {
 	messagingv1.UnimplementedAuthServiceServer
-	svc authService
+	svc       authService
+	clientIPs *domain.ClientIPResolver
 }
 
 // NewAuthHandler creates an AuthHandler backed by the given AuthService.
-func NewAuthHandler(svc *app.AuthService) *AuthHandler {
-	return &AuthHandler{svc: svc}
+// clientIPs decides which forwarded-for hops to believe; nil trusts none.
+func NewAuthHandler(svc *app.AuthService, clientIPs *domain.ClientIPResolver) *AuthHandler {
+	return &AuthHandler{svc: svc, clientIPs: clientIPs}
 }
 
 // RequestOTP sends a one-time password to the given phone number.
 func (h *AuthHandler) RequestOTP(ctx context.Context, req *messagingv1.RequestOTPRequest) (*messagingv1.RequestOTPResponse, error) {
-	clientIP := extractClientIP(ctx)
+	clientIP := h.clientIP(ctx)
 
 	result, err := h.svc.RequestOTP(ctx, req.GetPhoneNumber(), clientIP)
 	if err != nil {
@@ -47,12 +54,16 @@ func (h *AuthHandler) RequestOTP(ctx context.Context, req *messagingv1.RequestOT
 	return &messagingv1.RequestOTPResponse{
 		ExpiresAt:         timeToProtoTimestamp(result.ExpiresAt),
 		RetryAfterSeconds: clampInt32(result.RetryAfterSeconds),
+		CodeLength:        clampInt32(result.CodeLength),
+		CodeAlphabet:      result.CodeAlphabet,
 	}, nil
 }
 
 // VerifyOTP verifies an OTP and returns authentication tokens.
 func (h *AuthHandler) VerifyOTP(ctx context.Context, req *messagingv1.VerifyOTPRequest) (*messagingv1.VerifyOTPResponse, error) {
-	result, err := h.svc.VerifyOTP(ctx, req.GetPhoneNumber(), req.GetOtp(), req.GetDeviceId())
+	done := slo.Start(ctx, slo.VerifyOTP)
+	result, err := h.svc.VerifyOTP(ctx, req.GetPhoneNumber(), req.GetOtp(), req.GetDeviceId(), h.clientIP(ctx))
+	done(err)
 	if err != nil {
 		return nil, errmap.ToGRPCError(err)
 	}
@@ -63,7 +74,7 @@ func (h *AuthHandler) VerifyOTP(ctx context.Context, req *messagingv1.VerifyOTPR
 			PhoneNumber:   result.User.PhoneNumber,
 			DisplayName:   result.User.DisplayName,
 			PhoneVerified: true,
-			CreatedAt:     timeStringToProtoTimestamp(result.User.CreatedAt),
+			CreatedAt:     timestampToProto(result.User.CreatedAt),
 		},
 		SessionId:            result.SessionID,
 		AccessToken:          result.AccessToken,
@@ -78,7 +89,7 @@ func (h *AuthHandler) RefreshTokens(ctx context.Context, req *messagingv1.Refres
 	accessToken := extractBearerToken(ctx)
 	deviceID := extractDeviceID(ctx)
 
-	result, err := h.svc.RefreshTokens(ctx, accessToken, req.GetRefreshToken(), deviceID)
+	result, err := h.svc.RefreshTokens(ctx, accessToken, req.GetRefreshToken(), deviceID, h.clientIP(ctx))
 	if err != nil {
 		return nil, errmap.ToGRPCError(err)
 	}
@@ -101,6 +112,69 @@ func (h *AuthHandler) Logout(ctx context.Context, _ *messagingv1.LogoutRequest)
 	return &messagingv1.LogoutResponse{}, nil
 }
 
+// RegisterPushToken records the push token of the calling session's device.
+func (h *AuthHandler) RegisterPushToken(ctx context.Context, req *messagingv1.RegisterPushTokenRequest) (*messagingv1.RegisterPushTokenResponse, error) {
+	accessToken := extractBearerToken(ctx)
+
+	reg := app.PushRegistration{
+		Platform: pushPlatformFromProto(req.GetPlatform()),
+		Token:    req.GetToken(),
+		P256DH:   req.GetWebPush().GetP256Dh(),
+		Auth:     req.GetWebPush().GetAuth(),
+	}
+	if err := h.svc.RegisterPushToken(ctx, accessToken, reg); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+
+	return &messagingv1.RegisterPushTokenResponse{}, nil
+}
+
+// ListDevices lists the caller's signed-in devices.
+func (h *AuthHandler) ListDevices(ctx context.Context, _ *messagingv1.ListDevicesRequest) (*messagingv1.ListDevicesResponse, error) {
+	devices, err := h.svc.ListDevices(ctx, extractBearerToken(ctx))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+
+	resp := &messagingv1.ListDevicesResponse{Devices: make([]*messagingv1.Device, 0, len(devices))}
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, &messagingv1.Device{
+			SessionId:     d.SessionID,
+			DeviceId:      d.DeviceID,
+			CreatedAt:     timeToProtoTimestamp(d.CreatedAt),
+			LastActiveAt:  timeToProtoTimestamp(d.LastActiveAt),
+			IdleExpiresAt: timeToProtoTimestamp(d.IdleExpiresAt),
+			Current:       d.Current,
+		})
+	}
+	return resp, nil
+}
+
+// LogoutAllSessions signs the caller out on every device.
+func (h *AuthHandler) LogoutAllSessions(ctx context.Context, _ *messagingv1.LogoutAllSessionsRequest) (*messagingv1.LogoutAllSessionsResponse, error) {
+	n, err := h.svc.LogoutAllSessions(ctx, extractBearerToken(ctx))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+
+	return &messagingv1.LogoutAllSessionsResponse{RevokedSessions: clampInt32(n)}, nil
+}
+
+// pushPlatformFromProto maps the wire enum to a domain platform. Unknown
+// values map to the empty platform, which the service rejects.
+func pushPlatformFromProto(p messagingv1.PushPlatform) domain.PushPlatform {
+	switch p {
+	case messagingv1.PushPlatform_PUSH_PLATFORM_APNS:
+		return domain.PushPlatformAPNs
+	case messagingv1.PushPlatform_PUSH_PLATFORM_FCM:
+		return domain.PushPlatformFCM
+	case messagingv1.PushPlatform_PUSH_PLATFORM_WEB_PUSH:
+		return domain.PushPlatformWebPush
+	default:
+		return ""
+	}
+}
+
 // extractBearerToken extracts the bearer token from the gRPC "authorization" metadata.
 func extractBearerToken(ctx context.Context) string {
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -131,31 +205,19 @@ func extractDeviceID(ctx context.Context) string {
 	return vals[0]
 }
 
-// extractClientIP extracts the client IP from "x-forwarded-for" metadata
-// or falls back to the gRPC peer address.
-func extractClientIP(ctx context.Context) string {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if ok {
-		vals := md.Get("x-forwarded-for")
-		if len(vals) > 0 && vals[0] != "" {
-			// x-forwarded-for may contain a comma-separated list; take the first.
-			if idx := strings.IndexByte(vals[0], ','); idx >= 0 {
-				return strings.TrimSpace(vals[0][:idx])
-			}
-			return strings.TrimSpace(vals[0])
-		}
-	}
-
+// clientIP resolves the client IP from the gRPC peer address and
+// "x-forwarded-for" metadata. Calls through the in-process grpc-gateway
+// have no gRPC peer; the gateway appends the HTTP peer address to
+// x-forwarded-for instead, so its last entry stands in for the peer.
+func (h *AuthHandler) clientIP(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get("x-forwarded-for")
 	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
-		addr := p.Addr.String()
-		// Strip port from addr (e.g. "127.0.0.1:54321" → "127.0.0.1").
-		if idx := strings.LastIndexByte(addr, ':'); idx >= 0 {
-			return addr[:idx]
-		}
-		return addr
+		return h.clientIPs.ClientIP(p.Addr.String(), forwarded)
 	}
 
-	return ""
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	return h.clientIPs.ClientIP(strings.TrimSpace(hops[len(hops)-1]), hops[:len(hops)-1])
 }
 
 // clampInt32 safely converts an int to int32, clamping to math.MaxInt32 on overflow.
@@ -171,12 +233,8 @@ func timeToProtoTimestamp(t time.Time) *messagingv1.Timestamp {
 	return &messagingv1.Timestamp{Millis: t.UnixMilli()}
 }
 
-// timeStringToProtoTimestamp parses an RFC3339 string and converts to proto Timestamp.
-// Returns a zero-millis timestamp if the string is not valid RFC3339.
-func timeStringToProtoTimestamp(s string) *messagingv1.Timestamp {
-	t, err := time.Parse(time.RFC3339, s)
-	if err != nil {
-		return &messagingv1.Timestamp{Millis: 0}
-	}
+// timestampToProto converts a domain.TimestampMS to a proto Timestamp. An
+// unset timestamp converts to zero millis.
+func timestampToProto(t domain.TimestampMS) *messagingv1.Timestamp {
 	return &messagingv1.Timestamp{Millis: t.UnixMilli()}
 }
@@ -24,27 +24,42 @@ import (
 
 type stubAuthService struct {
 	requestOTPFn    func(ctx context.Context, phone, clientIP string) (*app.RequestOTPResult, error)
-	verifyOTPFn     func(ctx context.Context, phone, otpCandidate, deviceID string) (*app.VerifyOTPResult, error)
-	refreshTokensFn func(ctx context.Context, accessToken, refreshToken, deviceID string) (*app.RefreshResult, error)
+	verifyOTPFn     func(ctx context.Context, phone, otpCandidate, deviceID, clientIP string) (*app.VerifyOTPResult, error)
+	refreshTokensFn func(ctx context.Context, accessToken, refreshToken, deviceID, clientIP string) (*app.RefreshResult, error)
 	logoutFn        func(ctx context.Context, accessToken string) error
+	registerPushFn  func(ctx context.Context, accessToken string, reg app.PushRegistration) error
+	listDevicesFn   func(ctx context.Context, accessToken string) ([]app.DeviceSession, error)
+	logoutAllFn     func(ctx context.Context, accessToken string) (int, error)
 }
 
 func (s *stubAuthService) RequestOTP(ctx context.Context, phone, clientIP string) (*app.RequestOTPResult, error) {
 	return s.requestOTPFn(ctx, phone, clientIP)
 }
 
-func (s *stubAuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, deviceID string) (*app.VerifyOTPResult, error) {
-	return s.verifyOTPFn(ctx, phone, otpCandidate, deviceID)
+func (s *stubAuthService) VerifyOTP(ctx context.Context, phone, otpCandidate, deviceID, clientIP string) (*app.VerifyOTPResult, error) {
+	return s.verifyOTPFn(ctx, phone, otpCandidate, deviceID, clientIP)
 }
 
-func (s *stubAuthService) RefreshTokens(ctx context.Context, accessToken, refreshToken, deviceID string) (*app.RefreshResult, error) {
-	return s.refreshTokensFn(ctx, accessToken, refreshToken, deviceID)
+func (s *stubAuthService) RefreshTokens(ctx context.Context, accessToken, refreshToken, deviceID, clientIP string) (*app.RefreshResult, error) {
+	return s.refreshTokensFn(ctx, accessToken, refreshToken, deviceID, clientIP)
 }
 
 func (s *stubAuthService) Logout(ctx context.Context, accessToken string) error {
 	return s.logoutFn(ctx, accessToken)
 }
 
+func (s *stubAuthService) RegisterPushToken(ctx context.Context, accessToken string, reg app.PushRegistration) error {
+	return s.registerPushFn(ctx, accessToken, reg)
+}
+
+func (s *stubAuthService) ListDevices(ctx context.Context, accessToken string) ([]app.DeviceSession, error) {
+	return s.listDevicesFn(ctx, accessToken)
+}
+
+func (s *stubAuthService) LogoutAllSessions(ctx context.Context, accessToken string) (int, error) {
+	return s.logoutAllFn(ctx, accessToken)
+}
+
 var _ authService = (*stubAuthService)(nil)
 
 // ---------------------------------------------------------------------------
@@ -71,6 +86,8 @@ func TestAuthHandler_RequestOTP(t *testing.T) {
 				return &app.RequestOTPResult{
 					ExpiresAt:         expiresAt,
 					RetryAfterSeconds: 30,
+					CodeLength:        8,
+					CodeAlphabet:      "0123456789",
 				}, nil
 			},
 		}
@@ -85,6 +102,8 @@ func TestAuthHandler_RequestOTP(t *testing.T) {
 		require.NotNil(t, resp)
 		assert.Equal(t, expiresAt.UnixMilli(), resp.ExpiresAt.Millis)
 		assert.Equal(t, int32(30), resp.RetryAfterSeconds)
+		assert.Equal(t, int32(8), resp.CodeLength)
+		assert.Equal(t, "0123456789", resp.CodeAlphabet)
 	})
 
 	t.Run("rate limited - returns ResourceExhausted", func(t *testing.T) {
@@ -132,7 +151,7 @@ func TestAuthHandler_VerifyOTP(t *testing.T) {
 	t.Run("success - maps all fields to proto response", func(t *testing.T) {
 		accessExpiry := fixedTime.Add(15 * time.Minute)
 		stub := &stubAuthService{
-			verifyOTPFn: func(_ context.Context, phone, otp, deviceID string) (*app.VerifyOTPResult, error) {
+			verifyOTPFn: func(_ context.Context, phone, otp, deviceID, _ string) (*app.VerifyOTPResult, error) {
 				assert.Equal(t, "+14155552671", phone)
 				assert.Equal(t, "123456", otp)
 				assert.Equal(t, "device-abc", deviceID)
@@ -141,7 +160,7 @@ func TestAuthHandler_VerifyOTP(t *testing.T) {
 						UserID:      "user-001",
 						PhoneNumber: "+14155552671",
 						DisplayName: "Alice",
-						CreatedAt:   "2026-02-10T12:00:00Z",
+						CreatedAt:   domain.NewTimestampMS(fixedTime),
 					},
 					SessionID:         "session-001",
 					AccessToken:       "access-jwt",
@@ -176,7 +195,7 @@ func TestAuthHandler_VerifyOTP(t *testing.T) {
 
 	t.Run("invalid OTP - returns Unauthenticated", func(t *testing.T) {
 		stub := &stubAuthService{
-			verifyOTPFn: func(_ context.Context, _, _, _ string) (*app.VerifyOTPResult, error) {
+			verifyOTPFn: func(_ context.Context, _, _, _, _ string) (*app.VerifyOTPResult, error) {
 				return nil, domain.ErrInvalidOTP
 			},
 		}
@@ -203,10 +222,11 @@ func TestAuthHandler_RefreshTokens(t *testing.T) {
 	t.Run("success - extracts bearer and device-id from metadata", func(t *testing.T) {
 		accessExpiry := fixedTime.Add(15 * time.Minute)
 		stub := &stubAuthService{
-			refreshTokensFn: func(_ context.Context, accessToken, refreshToken, deviceID string) (*app.RefreshResult, error) {
+			refreshTokensFn: func(_ context.Context, accessToken, refreshToken, deviceID, clientIP string) (*app.RefreshResult, error) {
 				assert.Equal(t, "my-access-jwt", accessToken)
 				assert.Equal(t, "my-refresh-token", refreshToken)
 				assert.Equal(t, "device-xyz", deviceID)
+				assert.Equal(t, "10.0.0.2", clientIP)
 				return &app.RefreshResult{
 					AccessToken:       "new-access-jwt",
 					RefreshToken:      "new-refresh-token",
@@ -219,6 +239,7 @@ func TestAuthHandler_RefreshTokens(t *testing.T) {
 		ctx := ctxWithMetadata(metadata.Pairs(
 			"authorization", "Bearer my-access-jwt",
 			"x-device-id", "device-xyz",
+			"x-forwarded-for", "10.0.0.2",
 		))
 		resp, err := handler.RefreshTokens(ctx, &messagingv1.RefreshTokensRequest{
 			RefreshToken: "my-refresh-token",
@@ -233,7 +254,7 @@ func TestAuthHandler_RefreshTokens(t *testing.T) {
 
 	t.Run("token reuse - returns Unauthenticated", func(t *testing.T) {
 		stub := &stubAuthService{
-			refreshTokensFn: func(_ context.Context, _, _, _ string) (*app.RefreshResult, error) {
+			refreshTokensFn: func(_ context.Context, _, _, _, _ string) (*app.RefreshResult, error) {
 				return nil, domain.ErrRefreshTokenReuse
 			},
 		}
@@ -288,6 +309,162 @@ func TestAuthHandler_Logout(t *testing.T) {
 	})
 }
 
+// ---------------------------------------------------------------------------
+// Tests — RegisterPushToken
+// ---------------------------------------------------------------------------
+
+func TestAuthHandler_RegisterPushToken(t *testing.T) {
+	t.Run("success - maps platform and forwards token", func(t *testing.T) {
+		stub := &stubAuthService{
+			registerPushFn: func(_ context.Context, accessToken string, reg app.PushRegistration) error {
+				assert.Equal(t, "my-access-jwt", accessToken)
+				assert.Equal(t, app.PushRegistration{Platform: domain.PushPlatformFCM, Token: "fcm-token"}, reg)
+				return nil
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-jwt"))
+		resp, err := handler.RegisterPushToken(ctx, &messagingv1.RegisterPushTokenRequest{
+			Platform: messagingv1.PushPlatform_PUSH_PLATFORM_FCM,
+			Token:    "fcm-token",
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+	})
+
+	t.Run("webpush - forwards subscription keys", func(t *testing.T) {
+		stub := &stubAuthService{
+			registerPushFn: func(_ context.Context, _ string, reg app.PushRegistration) error {
+				assert.Equal(t, app.PushRegistration{
+					Platform: domain.PushPlatformWebPush,
+					Token:    "https://push.example.net/send/abc",
+					P256DH:   "p256dh-key",
+					Auth:     "auth-secret",
+				}, reg)
+				return nil
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		_, err := handler.RegisterPushToken(context.Background(), &messagingv1.RegisterPushTokenRequest{
+			Platform: messagingv1.PushPlatform_PUSH_PLATFORM_WEB_PUSH,
+			Token:    "https://push.example.net/send/abc",
+			WebPush:  &messagingv1.WebPushKeys{P256Dh: "p256dh-key", Auth: "auth-secret"},
+		})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid input - returns InvalidArgument", func(t *testing.T) {
+		stub := &stubAuthService{
+			registerPushFn: func(_ context.Context, _ string, reg app.PushRegistration) error {
+				assert.Empty(t, reg.Platform)
+				return domain.NewValidationError("platform", "unknown push platform")
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		_, err := handler.RegisterPushToken(context.Background(), &messagingv1.RegisterPushTokenRequest{Token: "t"})
+
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.InvalidArgument, st.Code())
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ListDevices
+// ---------------------------------------------------------------------------
+
+func TestAuthHandler_ListDevices(t *testing.T) {
+	t.Run("success - maps sessions to devices", func(t *testing.T) {
+		stub := &stubAuthService{
+			listDevicesFn: func(_ context.Context, accessToken string) ([]app.DeviceSession, error) {
+				assert.Equal(t, "my-access-jwt", accessToken)
+				return []app.DeviceSession{{
+					SessionID:     "sess-001",
+					DeviceID:      "device-abc",
+					CreatedAt:     fixedTime.Add(-time.Hour),
+					LastActiveAt:  fixedTime,
+					IdleExpiresAt: fixedTime.Add(domain.SessionIdleTimeout),
+					Current:       true,
+				}}, nil
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-jwt"))
+		resp, err := handler.ListDevices(ctx, &messagingv1.ListDevicesRequest{})
+
+		require.NoError(t, err)
+		require.Len(t, resp.GetDevices(), 1)
+		d := resp.GetDevices()[0]
+		assert.Equal(t, "sess-001", d.GetSessionId())
+		assert.Equal(t, "device-abc", d.GetDeviceId())
+		assert.Equal(t, fixedTime.Add(-time.Hour).UnixMilli(), d.GetCreatedAt().GetMillis())
+		assert.Equal(t, fixedTime.UnixMilli(), d.GetLastActiveAt().GetMillis())
+		assert.Equal(t, fixedTime.Add(domain.SessionIdleTimeout).UnixMilli(), d.GetIdleExpiresAt().GetMillis())
+		assert.True(t, d.GetCurrent())
+	})
+
+	t.Run("unauthorized - returns Unauthenticated", func(t *testing.T) {
+		stub := &stubAuthService{
+			listDevicesFn: func(_ context.Context, _ string) ([]app.DeviceSession, error) {
+				return nil, domain.ErrUnauthorized
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		_, err := handler.ListDevices(context.Background(), &messagingv1.ListDevicesRequest{})
+
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.Unauthenticated, st.Code())
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — LogoutAllSessions
+// ---------------------------------------------------------------------------
+
+func TestAuthHandler_LogoutAllSessions(t *testing.T) {
+	t.Run("success - returns the revoked session count", func(t *testing.T) {
+		stub := &stubAuthService{
+			logoutAllFn: func(_ context.Context, accessToken string) (int, error) {
+				assert.Equal(t, "my-access-jwt", accessToken)
+				return 3, nil
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-jwt"))
+		resp, err := handler.LogoutAllSessions(ctx, &messagingv1.LogoutAllSessionsRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), resp.GetRevokedSessions())
+	})
+
+	t.Run("unauthorized - returns Unauthenticated", func(t *testing.T) {
+		stub := &stubAuthService{
+			logoutAllFn: func(_ context.Context, _ string) (int, error) {
+				return 0, domain.ErrUnauthorized
+			},
+		}
+		handler := &AuthHandler{svc: stub}
+
+		_, err := handler.LogoutAllSessions(context.Background(), &messagingv1.LogoutAllSessionsRequest{})
+
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.Unauthenticated, st.Code())
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Tests — Metadata extraction helpers
 // ---------------------------------------------------------------------------
@@ -324,26 +501,41 @@ func TestExtractDeviceID(t *testing.T) {
 	})
 }
 
-func TestExtractClientIP(t *testing.T) {
-	t.Run("uses x-forwarded-for when present", func(t *testing.T) {
-		ctx := ctxWithMetadata(metadata.Pairs("x-forwarded-for", "10.0.0.1"))
-		assert.Equal(t, "10.0.0.1", extractClientIP(ctx))
+func TestAuthHandler_ClientIP(t *testing.T) {
+	proxies, err := domain.NewClientIPResolver([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	trusting := &AuthHandler{clientIPs: proxies}
+	withPeer := func(ip string, md metadata.MD) context.Context {
+		return grpcpeer.NewContext(ctxWithMetadata(md), &grpcpeer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 54321},
+		})
+	}
+
+	t.Run("trusted peer: first untrusted hop from the right", func(t *testing.T) {
+		ctx := withPeer("10.0.0.5", metadata.Pairs("x-forwarded-for", "198.51.100.1, 203.0.113.7, 10.0.0.1"))
+		assert.Equal(t, "203.0.113.7", trusting.clientIP(ctx))
 	})
 
-	t.Run("takes first IP from comma-separated list", func(t *testing.T) {
-		ctx := ctxWithMetadata(metadata.Pairs("x-forwarded-for", "10.0.0.1, 192.168.1.1"))
-		assert.Equal(t, "10.0.0.1", extractClientIP(ctx))
+	t.Run("untrusted peer: x-forwarded-for ignored", func(t *testing.T) {
+		ctx := withPeer("192.168.1.100", metadata.Pairs("x-forwarded-for", "203.0.113.7"))
+		assert.Equal(t, "192.168.1.100", trusting.clientIP(ctx))
 	})
 
-	t.Run("falls back to peer address", func(t *testing.T) {
-		ctx := grpcpeer.NewContext(context.Background(), &grpcpeer.Peer{
-			Addr: &net.TCPAddr{IP: net.ParseIP("192.168.1.100"), Port: 54321},
-		})
-		assert.Equal(t, "192.168.1.100", extractClientIP(ctx))
+	t.Run("no trusted proxies: peer address", func(t *testing.T) {
+		ctx := withPeer("10.0.0.5", metadata.Pairs("x-forwarded-for", "203.0.113.7"))
+		assert.Equal(t, "10.0.0.5", (&AuthHandler{}).clientIP(ctx))
+	})
+
+	t.Run("grpc-gateway call: last hop stands in for the peer", func(t *testing.T) {
+		ctx := ctxWithMetadata(metadata.Pairs("x-forwarded-for", "198.51.100.1, 203.0.113.7, 10.0.0.1"))
+		assert.Equal(t, "203.0.113.7", trusting.clientIP(ctx))
+
+		ctx = ctxWithMetadata(metadata.Pairs("x-forwarded-for", "198.51.100.1, 192.168.1.100"))
+		assert.Equal(t, "192.168.1.100", trusting.clientIP(ctx))
 	})
 
 	t.Run("returns empty when no metadata or peer", func(t *testing.T) {
-		assert.Equal(t, "", extractClientIP(context.Background()))
+		assert.Equal(t, "", trusting.clientIP(context.Background()))
 	})
 }
 
@@ -356,14 +548,14 @@ func TestTimeToProtoTimestamp(t *testing.T) {
 	assert.Equal(t, fixedTime.UnixMilli(), ts.Millis)
 }
 
-func TestTimeStringToProtoTimestamp(t *testing.T) {
-	t.Run("valid RFC3339", func(t *testing.T) {
-		ts := timeStringToProtoTimestamp("2026-02-10T12:00:00Z")
+func TestTimestampToProto(t *testing.T) {
+	t.Run("set timestamp", func(t *testing.T) {
+		ts := timestampToProto(domain.NewTimestampMS(fixedTime))
 		assert.Equal(t, fixedTime.UnixMilli(), ts.Millis)
 	})
 
-	t.Run("invalid string returns zero millis", func(t *testing.T) {
-		ts := timeStringToProtoTimestamp("not-a-date")
+	t.Run("unset timestamp returns zero millis", func(t *testing.T) {
+		ts := timestampToProto(domain.TimestampMS{})
 		assert.Equal(t, int64(0), ts.Millis)
 	})
 }
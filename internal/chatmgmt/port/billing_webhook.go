@@ -0,0 +1,60 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// BillingSignatureHeader carries the payment provider's webhook
+// signature.
+const BillingSignatureHeader = "Billing-Signature"
+
+// BillingWebhookService is the app.BillingService the webhook receiver
+// needs.
+type BillingWebhookService interface {
+	HandleWebhook(ctx context.Context, payload []byte, signature string) error
+}
+
+// BillingWebhookHandler receives payment provider webhooks:
+//
+//	POST /webhooks/billing
+//
+// It sits outside /admin because the provider cannot present the admin
+// token; the body's signature authenticates it instead. A bad signature
+// gets 401 and a malformed event 400, which the provider does not retry;
+// a storage failure gets 500 so the provider redelivers.
+func BillingWebhookHandler(svc BillingWebhookService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// The signature covers the exact bytes, so the body is read raw
+		// rather than decoded.
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, domain.MaxBillingWebhookBodyBytes))
+		if err != nil {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		err = svc.HandleWebhook(r.Context(), payload, r.Header.Get(BillingSignatureHeader))
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, domain.ErrUnauthorized):
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		case errors.Is(err, domain.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "billing webhook failed", "error", err)
+			http.Error(w, "billing webhook failed", http.StatusInternalServerError)
+		}
+	})
+}
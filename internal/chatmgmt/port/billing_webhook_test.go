@@ -0,0 +1,57 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type billingWebhookFunc func(ctx context.Context, payload []byte, signature string) error
+
+func (f billingWebhookFunc) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	return f(ctx, payload, signature)
+}
+
+func TestBillingWebhookHandler(t *testing.T) {
+	handler := BillingWebhookHandler(billingWebhookFunc(func(_ context.Context, payload []byte, signature string) error {
+		switch string(payload) {
+		case "unsigned":
+			return fmt.Errorf("billing webhook: %w", domain.ErrUnauthorized)
+		case "malformed":
+			return fmt.Errorf("billing webhook: %w", domain.NewValidationError("tier", "unknown tier"))
+		case "throttled":
+			return errors.New("throttled")
+		}
+		assert.Equal(t, "t=1,v1=ab", signature)
+		return nil
+	}))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/billing", strings.NewReader(body))
+		req.Header.Set(BillingSignatureHeader, "t=1,v1=ab")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNoContent, post(`{"id":"evt_1"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post("unsigned").Code)
+	assert.Equal(t, http.StatusBadRequest, post("malformed").Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(strings.Repeat("x", domain.MaxBillingWebhookBodyBytes+1)).Code)
+
+	rec := post("throttled")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "throttled")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhooks/billing", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,522 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// historyService is a narrow, consumer-defined interface for the history
+// operations the handler requires. The *app.HistoryService satisfies this.
+type historyService interface {
+	StreamHistory(ctx context.Context, accessToken, chatID, cursor string, pageSize int, send func(app.HistoryPage) error) error
+}
+
+// chatSettingsService is a narrow, consumer-defined interface for the
+// settings operations the handler requires. The *app.ChatSettingsService
+// satisfies this.
+type chatSettingsService interface {
+	UpdateChatSettings(ctx context.Context, accessToken, chatID string, patch domain.ChatSettingsPatch, expectedVersion int64) (app.ChatSettingsRecord, error)
+}
+
+// joinRequestService is a narrow, consumer-defined interface for the join
+// request operations the handler requires. The *app.JoinRequestService
+// satisfies this.
+type joinRequestService interface {
+	RequestToJoin(ctx context.Context, accessToken, chatID, note string) (domain.JoinRequest, error)
+	ListJoinRequests(ctx context.Context, accessToken, chatID, cursor string, pageSize int) (app.JoinRequestPage, error)
+	ApproveJoinRequest(ctx context.Context, accessToken, chatID, userID string) error
+	RejectJoinRequest(ctx context.Context, accessToken, chatID, userID string) error
+}
+
+// ownershipService is a narrow, consumer-defined interface for the
+// ownership operations the handler requires. The *app.OwnershipService
+// satisfies this.
+type ownershipService interface {
+	TransferOwnership(ctx context.Context, accessToken, chatID, newOwnerID string, confirm bool) error
+	SetMemberRole(ctx context.Context, accessToken, chatID, userID string, role domain.MemberRole) error
+}
+
+// translationService is a narrow, consumer-defined interface for the
+// translation operations the handler requires. The *app.TranslationService
+// satisfies this.
+type translationService interface {
+	TranslateMessage(ctx context.Context, accessToken, chatID string, sequence uint64, targetLanguage string) (domain.Translation, error)
+	SetPreferredLanguage(ctx context.Context, accessToken, language string) (string, error)
+}
+
+// smsFallbackService is a narrow, consumer-defined interface for the SMS
+// fallback setting operations the handler requires. The
+// *app.SMSFallbackService satisfies this.
+type smsFallbackService interface {
+	GetSMSFallback(ctx context.Context, accessToken string) (domain.SMSFallback, error)
+	SetSMSFallback(ctx context.Context, accessToken string, setting domain.SMSFallback) (domain.SMSFallback, error)
+}
+
+// privacySettingsService is a narrow, consumer-defined interface for the
+// privacy setting operations the handler requires. The
+// *app.PrivacySettingsService satisfies this.
+type privacySettingsService interface {
+	GetPrivacySettings(ctx context.Context, accessToken string) (domain.PrivacySettings, error)
+	SetPrivacySettings(ctx context.Context, accessToken string, settings domain.PrivacySettings) (domain.PrivacySettings, error)
+}
+
+// dndScheduleService is a narrow, consumer-defined interface for the
+// do-not-disturb operations the handler requires. The
+// *app.DNDScheduleService satisfies this.
+type dndScheduleService interface {
+	GetDNDSchedule(ctx context.Context, accessToken string) (domain.DNDSchedule, error)
+	SetDNDSchedule(ctx context.Context, accessToken string, schedule domain.DNDSchedule) (domain.DNDSchedule, error)
+}
+
+// ChatHandler implements the gRPC ChatMgmtServiceServer interface.
+// RPCs without a handler method return UNIMPLEMENTED.
+type ChatHandler struct {
+	messagingv1.UnimplementedChatMgmtServiceServer
+	history      historyService
+	settings     chatSettingsService
+	joinRequests joinRequestService
+	ownership    ownershipService
+	translation  translationService
+	smsFallback  smsFallbackService
+	privacy      privacySettingsService
+	dnd          dndScheduleService
+}
+
+// NewChatHandler creates a ChatHandler backed by the given services.
+func NewChatHandler(
+	history *app.HistoryService,
+	settings *app.ChatSettingsService,
+	joinRequests *app.JoinRequestService,
+	ownership *app.OwnershipService,
+	translation *app.TranslationService,
+	smsFallback *app.SMSFallbackService,
+	privacy *app.PrivacySettingsService,
+	dnd *app.DNDScheduleService,
+) *ChatHandler {
+	return &ChatHandler{
+		history:      history,
+		settings:     settings,
+		joinRequests: joinRequests,
+		ownership:    ownership,
+		translation:  translation,
+		smsFallback:  smsFallback,
+		privacy:      privacy,
+		dnd:          dnd,
+	}
+}
+
+// UpdateChatSettings applies a partial settings update to a group chat.
+func (h *ChatHandler) UpdateChatSettings(
+	ctx context.Context, req *messagingv1.UpdateChatSettingsRequest,
+) (*messagingv1.UpdateChatSettingsResponse, error) {
+	accessToken := extractBearerToken(ctx)
+
+	patch := domain.ChatSettingsPatch{
+		Name:        req.Name,
+		AvatarURL:   req.AvatarUrl,
+		Description: req.Description,
+	}
+	if req.WhoCanPost != nil {
+		p := permissionPolicyFromProto(req.GetWhoCanPost())
+		patch.WhoCanPost = &p
+	}
+	if req.WhoCanAddMembers != nil {
+		p := permissionPolicyFromProto(req.GetWhoCanAddMembers())
+		patch.WhoCanAddMembers = &p
+	}
+	if req.SlowModeSeconds != nil {
+		n := int(req.GetSlowModeSeconds())
+		patch.SlowModeSeconds = &n
+	}
+
+	rec, err := h.settings.UpdateChatSettings(ctx, accessToken, req.GetChatId(), patch, req.GetExpectedVersion())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.UpdateChatSettingsResponse{Settings: chatSettingsToProto(rec)}, nil
+}
+
+// TransferOwnership makes another member the chat's owner, or only
+// validates the transfer when confirm is unset.
+func (h *ChatHandler) TransferOwnership(
+	ctx context.Context, req *messagingv1.TransferOwnershipRequest,
+) (*messagingv1.TransferOwnershipResponse, error) {
+	err := h.ownership.TransferOwnership(ctx, extractBearerToken(ctx), req.GetChatId(), req.GetNewOwnerId(), req.GetConfirm())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.TransferOwnershipResponse{Transferred: req.GetConfirm()}, nil
+}
+
+// SetMemberRole promotes or demotes a non-owner member.
+func (h *ChatHandler) SetMemberRole(
+	ctx context.Context, req *messagingv1.SetMemberRoleRequest,
+) (*messagingv1.SetMemberRoleResponse, error) {
+	err := h.ownership.SetMemberRole(ctx, extractBearerToken(ctx), req.GetChatId(), req.GetUserId(), memberRoleFromProto(req.GetRole()))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.SetMemberRoleResponse{}, nil
+}
+
+// TranslateMessage translates a text message into the requested or
+// preferred language.
+func (h *ChatHandler) TranslateMessage(
+	ctx context.Context, req *messagingv1.TranslateMessageRequest,
+) (*messagingv1.TranslateMessageResponse, error) {
+	tr, err := h.translation.TranslateMessage(ctx, extractBearerToken(ctx), req.GetChatId(), req.GetSequence(), req.GetTargetLanguage())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.TranslateMessageResponse{
+		Text:           tr.Text,
+		SourceLanguage: tr.SourceLanguage,
+		TargetLanguage: tr.TargetLanguage,
+	}, nil
+}
+
+// SetPreferredLanguage sets the caller's default translation language.
+func (h *ChatHandler) SetPreferredLanguage(
+	ctx context.Context, req *messagingv1.SetPreferredLanguageRequest,
+) (*messagingv1.SetPreferredLanguageResponse, error) {
+	lang, err := h.translation.SetPreferredLanguage(ctx, extractBearerToken(ctx), req.GetLanguage())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.SetPreferredLanguageResponse{Language: lang}, nil
+}
+
+// GetSMSFallback returns the caller's SMS fallback setting, tagged with
+// their settings version.
+func (h *ChatHandler) GetSMSFallback(
+	ctx context.Context, _ *messagingv1.GetSMSFallbackRequest,
+) (*messagingv1.GetSMSFallbackResponse, error) {
+	setting, err := h.smsFallback.GetSMSFallback(ctx, extractBearerToken(ctx))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	setETag(ctx, setting.Version)
+	return &messagingv1.GetSMSFallbackResponse{SmsFallback: smsFallbackToProto(setting)}, nil
+}
+
+// SetSMSFallback replaces the caller's SMS fallback setting.
+func (h *ChatHandler) SetSMSFallback(
+	ctx context.Context, req *messagingv1.SetSMSFallbackRequest,
+) (*messagingv1.SetSMSFallbackResponse, error) {
+	setting, err := h.smsFallback.SetSMSFallback(ctx, extractBearerToken(ctx), domain.SMSFallback{
+		Enabled:      req.GetEnabled(),
+		OfflineAfter: time.Duration(req.GetOfflineAfterSeconds()) * time.Second,
+	})
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.SetSMSFallbackResponse{SmsFallback: smsFallbackToProto(setting)}, nil
+}
+
+// GetPrivacySettings returns the caller's privacy settings, tagged with
+// their settings version.
+func (h *ChatHandler) GetPrivacySettings(
+	ctx context.Context, _ *messagingv1.GetPrivacySettingsRequest,
+) (*messagingv1.GetPrivacySettingsResponse, error) {
+	settings, err := h.privacy.GetPrivacySettings(ctx, extractBearerToken(ctx))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	setETag(ctx, settings.Version)
+	return &messagingv1.GetPrivacySettingsResponse{Privacy: privacyToProto(settings)}, nil
+}
+
+// SetPrivacySettings replaces the caller's privacy settings.
+func (h *ChatHandler) SetPrivacySettings(
+	ctx context.Context, req *messagingv1.SetPrivacySettingsRequest,
+) (*messagingv1.SetPrivacySettingsResponse, error) {
+	p := req.GetPrivacy()
+	settings, err := h.privacy.SetPrivacySettings(ctx, extractBearerToken(ctx), domain.PrivacySettings{
+		HideReadReceipts: p.GetHideReadReceipts(),
+		HideTyping:       p.GetHideTyping(),
+		HideLastSeen:     p.GetHideLastSeen(),
+	})
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.SetPrivacySettingsResponse{Privacy: privacyToProto(settings)}, nil
+}
+
+// GetDNDSchedule returns the caller's do-not-disturb schedule, tagged
+// with their settings version.
+func (h *ChatHandler) GetDNDSchedule(
+	ctx context.Context, _ *messagingv1.GetDNDScheduleRequest,
+) (*messagingv1.GetDNDScheduleResponse, error) {
+	schedule, err := h.dnd.GetDNDSchedule(ctx, extractBearerToken(ctx))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	setETag(ctx, schedule.Version)
+	return &messagingv1.GetDNDScheduleResponse{Dnd: dndToProto(schedule)}, nil
+}
+
+// SetDNDSchedule replaces the caller's do-not-disturb schedule.
+func (h *ChatHandler) SetDNDSchedule(
+	ctx context.Context, req *messagingv1.SetDNDScheduleRequest,
+) (*messagingv1.SetDNDScheduleResponse, error) {
+	schedule, err := dndFromProto(req.GetDnd())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	schedule, err = h.dnd.SetDNDSchedule(ctx, extractBearerToken(ctx), schedule)
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.SetDNDScheduleResponse{Dnd: dndToProto(schedule)}, nil
+}
+
+// setETag sends the weak ETag of a user's settings at version as "etag"
+// header metadata, which the REST gateway returns as the ETag header so
+// server.ConditionalGET can answer If-None-Match with 304.
+func setETag(ctx context.Context, version int64) {
+	// Fails only outside a gRPC call; the response is then untagged.
+	_ = grpc.SetHeader(ctx, metadata.Pairs("etag", domain.WeakETag(version, time.Time{})))
+}
+
+// RequestToJoin records the caller's request to join a group chat.
+func (h *ChatHandler) RequestToJoin(
+	ctx context.Context, req *messagingv1.RequestToJoinRequest,
+) (*messagingv1.RequestToJoinResponse, error) {
+	jr, err := h.joinRequests.RequestToJoin(ctx, extractBearerToken(ctx), req.GetChatId(), req.GetNote())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.RequestToJoinResponse{JoinRequest: joinRequestToProto(jr)}, nil
+}
+
+// ListJoinRequests returns one page of a chat's pending join requests.
+func (h *ChatHandler) ListJoinRequests(
+	ctx context.Context, req *messagingv1.ListJoinRequestsRequest,
+) (*messagingv1.ListJoinRequestsResponse, error) {
+	page, err := h.joinRequests.ListJoinRequests(ctx, extractBearerToken(ctx),
+		req.GetChatId(), req.GetCursor(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+
+	out := make([]*messagingv1.JoinRequest, 0, len(page.Requests))
+	for _, jr := range page.Requests {
+		out = append(out, joinRequestToProto(jr))
+	}
+	return &messagingv1.ListJoinRequestsResponse{JoinRequests: out, NextCursor: page.Cursor}, nil
+}
+
+// ApproveJoinRequest makes the requester a member of the chat.
+func (h *ChatHandler) ApproveJoinRequest(
+	ctx context.Context, req *messagingv1.ApproveJoinRequestRequest,
+) (*messagingv1.ApproveJoinRequestResponse, error) {
+	if err := h.joinRequests.ApproveJoinRequest(ctx, extractBearerToken(ctx), req.GetChatId(), req.GetUserId()); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.ApproveJoinRequestResponse{}, nil
+}
+
+// RejectJoinRequest discards a pending join request.
+func (h *ChatHandler) RejectJoinRequest(
+	ctx context.Context, req *messagingv1.RejectJoinRequestRequest,
+) (*messagingv1.RejectJoinRequestResponse, error) {
+	if err := h.joinRequests.RejectJoinRequest(ctx, extractBearerToken(ctx), req.GetChatId(), req.GetUserId()); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.RejectJoinRequestResponse{}, nil
+}
+
+// GetMessageHistory streams a chat's retained history one page per response.
+func (h *ChatHandler) GetMessageHistory(
+	req *messagingv1.GetMessageHistoryRequest,
+	stream grpc.ServerStreamingServer[messagingv1.GetMessageHistoryResponse],
+) error {
+	ctx := stream.Context()
+	accessToken := extractBearerToken(ctx)
+
+	err := h.history.StreamHistory(ctx, accessToken, req.GetChatId(), req.GetCursor(), int(req.GetPageSize()),
+		func(page app.HistoryPage) error {
+			return stream.Send(&messagingv1.GetMessageHistoryResponse{
+				Messages: messagesToProto(page.Messages),
+				Cursor:   page.Cursor,
+			})
+		})
+	if err != nil {
+		return errmap.ToGRPCError(err)
+	}
+	return nil
+}
+
+// messagesToProto converts message records to their wire representation.
+func messagesToProto(records []app.MessageRecord) []*messagingv1.Message {
+	out := make([]*messagingv1.Message, 0, len(records))
+	for _, m := range records {
+		out = append(out, &messagingv1.Message{
+			MessageId:       m.MessageID,
+			ChatId:          m.ChatID,
+			SenderId:        m.SenderID,
+			ClientMessageId: m.ClientMessageID,
+			Sequence:        m.Sequence,
+			ContentType:     contentTypeToProto(m.ContentType),
+			Content:         m.Content,
+			CreatedAt:       timeToProtoTimestamp(m.CreatedAt),
+		})
+	}
+	return out
+}
+
+// contentTypeToProto maps a domain content type to the proto enum.
+func contentTypeToProto(ct domain.ContentType) messagingv1.ContentType {
+	switch ct {
+	case domain.ContentTypeText:
+		return messagingv1.ContentType_CONTENT_TYPE_TEXT
+	case domain.ContentTypeSystem:
+		return messagingv1.ContentType_CONTENT_TYPE_SYSTEM
+	default:
+		return messagingv1.ContentType_CONTENT_TYPE_UNSPECIFIED
+	}
+}
+
+// joinRequestToProto converts a join request to its wire representation.
+func joinRequestToProto(jr domain.JoinRequest) *messagingv1.JoinRequest {
+	return &messagingv1.JoinRequest{
+		ChatId:    jr.ChatID,
+		UserId:    jr.UserID,
+		Note:      jr.Note,
+		CreatedAt: timeToProtoTimestamp(jr.CreatedAt),
+		ExpiresAt: timeToProtoTimestamp(jr.ExpiresAt),
+	}
+}
+
+// chatSettingsToProto converts a settings record to its wire representation.
+func chatSettingsToProto(rec app.ChatSettingsRecord) *messagingv1.ChatSettings {
+	return &messagingv1.ChatSettings{
+		Name:             rec.Settings.Name,
+		AvatarUrl:        rec.Settings.AvatarURL,
+		Description:      rec.Settings.Description,
+		WhoCanPost:       permissionPolicyToProto(rec.Settings.WhoCanPost),
+		WhoCanAddMembers: permissionPolicyToProto(rec.Settings.WhoCanAddMembers),
+		SlowModeSeconds:  int32(rec.Settings.SlowModeSeconds), //nolint:gosec // bounded by domain.MaxSlowModeSeconds
+		Version:          rec.Version,
+		UpdatedAt:        timeToProtoTimestamp(rec.UpdatedAt),
+	}
+}
+
+// permissionPolicyToProto maps a domain permission policy to the proto enum.
+func permissionPolicyToProto(p domain.PermissionPolicy) messagingv1.PermissionPolicy {
+	switch p {
+	case domain.PermissionEveryone:
+		return messagingv1.PermissionPolicy_PERMISSION_POLICY_EVERYONE
+	case domain.PermissionAdmins:
+		return messagingv1.PermissionPolicy_PERMISSION_POLICY_ADMINS
+	default:
+		return messagingv1.PermissionPolicy_PERMISSION_POLICY_UNSPECIFIED
+	}
+}
+
+// permissionPolicyFromProto maps the proto enum to a domain permission
+// policy. UNSPECIFIED maps to an empty policy, which fails validation.
+func permissionPolicyFromProto(p messagingv1.PermissionPolicy) domain.PermissionPolicy {
+	switch p {
+	case messagingv1.PermissionPolicy_PERMISSION_POLICY_EVERYONE:
+		return domain.PermissionEveryone
+	case messagingv1.PermissionPolicy_PERMISSION_POLICY_ADMINS:
+		return domain.PermissionAdmins
+	default:
+		return ""
+	}
+}
+
+// memberRoleFromProto maps the proto enum to a domain role. UNSPECIFIED
+// maps to an empty role, which the service rejects.
+func memberRoleFromProto(r messagingv1.MemberRole) domain.MemberRole {
+	switch r {
+	case messagingv1.MemberRole_MEMBER_ROLE_MEMBER:
+		return domain.MemberRoleMember
+	case messagingv1.MemberRole_MEMBER_ROLE_ADMIN:
+		return domain.MemberRoleAdmin
+	case messagingv1.MemberRole_MEMBER_ROLE_OWNER:
+		return domain.MemberRoleOwner
+	default:
+		return ""
+	}
+}
+
+// smsFallbackToProto converts an SMS fallback setting to its wire
+// representation.
+func smsFallbackToProto(s domain.SMSFallback) *messagingv1.SMSFallback {
+	return &messagingv1.SMSFallback{
+		Enabled:             s.Enabled,
+		OfflineAfterSeconds: int32(s.OfflineAfter / time.Second), //nolint:gosec // bounded by domain.MaxSMSFallbackOfflineAfter
+	}
+}
+
+// privacyToProto converts privacy settings to their wire representation.
+func privacyToProto(s domain.PrivacySettings) *messagingv1.PrivacySettings {
+	return &messagingv1.PrivacySettings{
+		HideReadReceipts: s.HideReadReceipts,
+		HideTyping:       s.HideTyping,
+		HideLastSeen:     s.HideLastSeen,
+	}
+}
+
+// dndFromProto converts a wire schedule, resolving its time zone.
+func dndFromProto(pb *messagingv1.DNDSchedule) (domain.DNDSchedule, error) {
+	loc, err := domain.LoadDNDLocation(pb.GetTimeZone())
+	if err != nil {
+		return domain.DNDSchedule{}, err
+	}
+	s := domain.DNDSchedule{Location: loc, Windows: quietWindowsFromProto(pb.GetWindows())}
+	if len(pb.GetChats()) > 0 {
+		s.Chats = make(map[string]domain.ChatDND, len(pb.GetChats()))
+		for chatID, o := range pb.GetChats() {
+			s.Chats[chatID] = domain.ChatDND{Bypass: o.GetBypass(), Windows: quietWindowsFromProto(o.GetWindows())}
+		}
+	}
+	return s, nil
+}
+
+// dndToProto converts a schedule to its wire representation.
+func dndToProto(s domain.DNDSchedule) *messagingv1.DNDSchedule {
+	pb := &messagingv1.DNDSchedule{Windows: quietWindowsToProto(s.Windows)}
+	if s.Location != nil && s.Location != time.UTC {
+		pb.TimeZone = s.Location.String()
+	}
+	if len(s.Chats) > 0 {
+		pb.Chats = make(map[string]*messagingv1.ChatDND, len(s.Chats))
+		for chatID, o := range s.Chats {
+			pb.Chats[chatID] = &messagingv1.ChatDND{Bypass: o.Bypass, Windows: quietWindowsToProto(o.Windows)}
+		}
+	}
+	return pb
+}
+
+func quietWindowsFromProto(ws []*messagingv1.QuietWindow) []domain.QuietWindow {
+	if len(ws) == 0 {
+		return nil
+	}
+	out := make([]domain.QuietWindow, len(ws))
+	for i, w := range ws {
+		out[i] = domain.QuietWindow{Start: int(w.GetStartMinute()), End: int(w.GetEndMinute())}
+	}
+	return out
+}
+
+func quietWindowsToProto(ws []domain.QuietWindow) []*messagingv1.QuietWindow {
+	out := make([]*messagingv1.QuietWindow, len(ws))
+	for i, w := range ws {
+		out[i] = &messagingv1.QuietWindow{
+			StartMinute: int32(w.Start), //nolint:gosec // validated to [0, 1440)
+			EndMinute:   int32(w.End),   //nolint:gosec // validated to [0, 1440)
+		}
+	}
+	return out
+}
@@ -0,0 +1,650 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Stubs
+// ---------------------------------------------------------------------------
+
+type stubHistoryService struct {
+	streamHistoryFn func(ctx context.Context, accessToken, chatID, cursor string, pageSize int, send func(app.HistoryPage) error) error
+}
+
+func (s *stubHistoryService) StreamHistory(ctx context.Context, accessToken, chatID, cursor string, pageSize int, send func(app.HistoryPage) error) error {
+	return s.streamHistoryFn(ctx, accessToken, chatID, cursor, pageSize, send)
+}
+
+var _ historyService = (*stubHistoryService)(nil)
+
+type stubChatSettingsService struct {
+	updateFn func(ctx context.Context, accessToken, chatID string, patch domain.ChatSettingsPatch, expectedVersion int64) (app.ChatSettingsRecord, error)
+}
+
+func (s *stubChatSettingsService) UpdateChatSettings(ctx context.Context, accessToken, chatID string, patch domain.ChatSettingsPatch, expectedVersion int64) (app.ChatSettingsRecord, error) {
+	return s.updateFn(ctx, accessToken, chatID, patch, expectedVersion)
+}
+
+var _ chatSettingsService = (*stubChatSettingsService)(nil)
+
+type stubJoinRequestService struct {
+	requestFn func(ctx context.Context, accessToken, chatID, note string) (domain.JoinRequest, error)
+	listFn    func(ctx context.Context, accessToken, chatID, cursor string, pageSize int) (app.JoinRequestPage, error)
+	decideFn  func(ctx context.Context, accessToken, chatID, userID string) error
+}
+
+func (s *stubJoinRequestService) RequestToJoin(ctx context.Context, accessToken, chatID, note string) (domain.JoinRequest, error) {
+	return s.requestFn(ctx, accessToken, chatID, note)
+}
+
+func (s *stubJoinRequestService) ListJoinRequests(ctx context.Context, accessToken, chatID, cursor string, pageSize int) (app.JoinRequestPage, error) {
+	return s.listFn(ctx, accessToken, chatID, cursor, pageSize)
+}
+
+func (s *stubJoinRequestService) ApproveJoinRequest(ctx context.Context, accessToken, chatID, userID string) error {
+	return s.decideFn(ctx, accessToken, chatID, userID)
+}
+
+func (s *stubJoinRequestService) RejectJoinRequest(ctx context.Context, accessToken, chatID, userID string) error {
+	return s.decideFn(ctx, accessToken, chatID, userID)
+}
+
+var _ joinRequestService = (*stubJoinRequestService)(nil)
+
+type stubOwnershipService struct {
+	transferFn func(ctx context.Context, accessToken, chatID, newOwnerID string, confirm bool) error
+	setRoleFn  func(ctx context.Context, accessToken, chatID, userID string, role domain.MemberRole) error
+}
+
+func (s *stubOwnershipService) TransferOwnership(ctx context.Context, accessToken, chatID, newOwnerID string, confirm bool) error {
+	return s.transferFn(ctx, accessToken, chatID, newOwnerID, confirm)
+}
+
+func (s *stubOwnershipService) SetMemberRole(ctx context.Context, accessToken, chatID, userID string, role domain.MemberRole) error {
+	return s.setRoleFn(ctx, accessToken, chatID, userID, role)
+}
+
+var _ ownershipService = (*stubOwnershipService)(nil)
+
+type stubTranslationService struct {
+	translateFn func(ctx context.Context, accessToken, chatID string, sequence uint64, target string) (domain.Translation, error)
+	setLangFn   func(ctx context.Context, accessToken, language string) (string, error)
+}
+
+func (s *stubTranslationService) TranslateMessage(ctx context.Context, accessToken, chatID string, sequence uint64, target string) (domain.Translation, error) {
+	return s.translateFn(ctx, accessToken, chatID, sequence, target)
+}
+
+func (s *stubTranslationService) SetPreferredLanguage(ctx context.Context, accessToken, language string) (string, error) {
+	return s.setLangFn(ctx, accessToken, language)
+}
+
+var _ translationService = (*stubTranslationService)(nil)
+
+type stubSMSFallbackService struct {
+	setting domain.SMSFallback
+	err     error
+}
+
+func (s *stubSMSFallbackService) GetSMSFallback(context.Context, string) (domain.SMSFallback, error) {
+	return s.setting, s.err
+}
+
+func (s *stubSMSFallbackService) SetSMSFallback(_ context.Context, _ string, setting domain.SMSFallback) (domain.SMSFallback, error) {
+	if s.err != nil {
+		return domain.SMSFallback{}, s.err
+	}
+	s.setting = setting
+	return setting, nil
+}
+
+var _ smsFallbackService = (*stubSMSFallbackService)(nil)
+
+type stubPrivacySettingsService struct {
+	settings domain.PrivacySettings
+}
+
+func (s *stubPrivacySettingsService) GetPrivacySettings(context.Context, string) (domain.PrivacySettings, error) {
+	return s.settings, nil
+}
+
+func (s *stubPrivacySettingsService) SetPrivacySettings(_ context.Context, _ string, settings domain.PrivacySettings) (domain.PrivacySettings, error) {
+	s.settings = settings
+	return settings, nil
+}
+
+var _ privacySettingsService = (*stubPrivacySettingsService)(nil)
+
+type stubDNDScheduleService struct {
+	schedule domain.DNDSchedule
+}
+
+func (s *stubDNDScheduleService) GetDNDSchedule(context.Context, string) (domain.DNDSchedule, error) {
+	return s.schedule, nil
+}
+
+func (s *stubDNDScheduleService) SetDNDSchedule(_ context.Context, _ string, schedule domain.DNDSchedule) (domain.DNDSchedule, error) {
+	s.schedule = schedule
+	return schedule, nil
+}
+
+var _ dndScheduleService = (*stubDNDScheduleService)(nil)
+
+// headerStream captures the header metadata a unary handler sets.
+type headerStream struct {
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string { return "" }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(metadata.MD) error { return nil }
+
+// fakeHistoryStream captures responses sent on a server stream.
+type fakeHistoryStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*messagingv1.GetMessageHistoryResponse
+}
+
+func (f *fakeHistoryStream) Context() context.Context { return f.ctx }
+
+func (f *fakeHistoryStream) Send(resp *messagingv1.GetMessageHistoryResponse) error {
+	f.sent = append(f.sent, resp)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Tests — GetMessageHistory
+// ---------------------------------------------------------------------------
+
+func TestChatHandler_GetMessageHistory(t *testing.T) {
+	t.Run("success - streams each page", func(t *testing.T) {
+		stub := &stubHistoryService{
+			streamHistoryFn: func(_ context.Context, accessToken, chatID, cursor string, pageSize int, send func(app.HistoryPage) error) error {
+				assert.Equal(t, "my-access-token", accessToken)
+				assert.Equal(t, "chat-001", chatID)
+				assert.Equal(t, "resume", cursor)
+				assert.Equal(t, 50, pageSize)
+
+				require.NoError(t, send(app.HistoryPage{
+					Messages: []app.MessageRecord{{
+						MessageID:   "msg-001",
+						ChatID:      "chat-001",
+						SenderID:    "user-001",
+						Sequence:    1,
+						ContentType: domain.ContentTypeText,
+						Content:     "hello",
+						CreatedAt:   fixedTime,
+					}},
+					Cursor: "c1",
+				}))
+				return send(app.HistoryPage{Messages: []app.MessageRecord{{MessageID: "msg-002", Sequence: 2}}, Cursor: "c2"})
+			},
+		}
+		handler := &ChatHandler{history: stub}
+		stream := &fakeHistoryStream{ctx: ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-token"))}
+
+		err := handler.GetMessageHistory(&messagingv1.GetMessageHistoryRequest{
+			ChatId:   "chat-001",
+			Cursor:   "resume",
+			PageSize: 50,
+		}, stream)
+
+		require.NoError(t, err)
+		require.Len(t, stream.sent, 2)
+
+		first := stream.sent[0]
+		assert.Equal(t, "c1", first.GetCursor())
+		require.Len(t, first.GetMessages(), 1)
+		assert.Equal(t, "msg-001", first.GetMessages()[0].GetMessageId())
+		assert.Equal(t, "hello", first.GetMessages()[0].GetContent())
+		assert.Equal(t, messagingv1.ContentType_CONTENT_TYPE_TEXT, first.GetMessages()[0].GetContentType())
+		assert.Equal(t, fixedTime.UnixMilli(), first.GetMessages()[0].GetCreatedAt().GetMillis())
+		assert.Equal(t, "c2", stream.sent[1].GetCursor())
+	})
+
+	t.Run("error - maps domain errors to gRPC status", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			err      error
+			wantCode codes.Code
+		}{
+			{"not member", domain.ErrNotMember, codes.PermissionDenied},
+			{"unauthorized", domain.ErrUnauthorized, codes.Unauthenticated},
+			{"bad cursor", domain.NewValidationError("cursor", "is malformed"), codes.InvalidArgument},
+			{"store failure", errors.Join(errors.New("timeout"), domain.ErrUnavailable), codes.Unavailable},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				stub := &stubHistoryService{
+					streamHistoryFn: func(context.Context, string, string, string, int, func(app.HistoryPage) error) error {
+						return tt.err
+					},
+				}
+				handler := &ChatHandler{history: stub}
+				stream := &fakeHistoryStream{ctx: context.Background()}
+
+				err := handler.GetMessageHistory(&messagingv1.GetMessageHistoryRequest{ChatId: "chat-001"}, stream)
+
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				assert.Empty(t, stream.sent)
+			})
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — UpdateChatSettings
+// ---------------------------------------------------------------------------
+
+func TestChatHandler_UpdateChatSettings(t *testing.T) {
+	t.Run("success - maps set fields to the patch", func(t *testing.T) {
+		stub := &stubChatSettingsService{
+			updateFn: func(_ context.Context, accessToken, chatID string, patch domain.ChatSettingsPatch, expectedVersion int64) (app.ChatSettingsRecord, error) {
+				assert.Equal(t, "my-access-token", accessToken)
+				assert.Equal(t, "chat-001", chatID)
+				assert.Equal(t, int64(3), expectedVersion)
+				require.NotNil(t, patch.Name)
+				assert.Equal(t, "Launch", *patch.Name)
+				require.NotNil(t, patch.WhoCanPost)
+				assert.Equal(t, domain.PermissionAdmins, *patch.WhoCanPost)
+				require.NotNil(t, patch.SlowModeSeconds)
+				assert.Equal(t, 0, *patch.SlowModeSeconds)
+				assert.Nil(t, patch.Description)
+				assert.Nil(t, patch.WhoCanAddMembers)
+
+				s := domain.DefaultChatSettings("Launch")
+				s.WhoCanPost = domain.PermissionAdmins
+				return app.ChatSettingsRecord{ChatID: chatID, Settings: s, Version: 4, UpdatedAt: fixedTime}, nil
+			},
+		}
+		handler := &ChatHandler{settings: stub}
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-token"))
+
+		resp, err := handler.UpdateChatSettings(ctx, &messagingv1.UpdateChatSettingsRequest{
+			ChatId:          "chat-001",
+			Name:            proto.String("Launch"),
+			WhoCanPost:      messagingv1.PermissionPolicy_PERMISSION_POLICY_ADMINS.Enum(),
+			SlowModeSeconds: proto.Int32(0),
+			ExpectedVersion: 3,
+		})
+
+		require.NoError(t, err)
+		got := resp.GetSettings()
+		assert.Equal(t, "Launch", got.GetName())
+		assert.Equal(t, messagingv1.PermissionPolicy_PERMISSION_POLICY_ADMINS, got.GetWhoCanPost())
+		assert.Equal(t, messagingv1.PermissionPolicy_PERMISSION_POLICY_ADMINS, got.GetWhoCanAddMembers())
+		assert.Equal(t, int64(4), got.GetVersion())
+		assert.Equal(t, fixedTime.UnixMilli(), got.GetUpdatedAt().GetMillis())
+	})
+
+	t.Run("error - version conflict maps to ABORTED", func(t *testing.T) {
+		stub := &stubChatSettingsService{
+			updateFn: func(context.Context, string, string, domain.ChatSettingsPatch, int64) (app.ChatSettingsRecord, error) {
+				return app.ChatSettingsRecord{}, domain.ErrVersionConflict
+			},
+		}
+		handler := &ChatHandler{settings: stub}
+
+		_, err := handler.UpdateChatSettings(context.Background(), &messagingv1.UpdateChatSettingsRequest{ChatId: "chat-001"})
+
+		assert.Equal(t, codes.Aborted, status.Code(err))
+	})
+
+	t.Run("error - forbidden", func(t *testing.T) {
+		stub := &stubChatSettingsService{
+			updateFn: func(context.Context, string, string, domain.ChatSettingsPatch, int64) (app.ChatSettingsRecord, error) {
+				return app.ChatSettingsRecord{}, domain.ErrForbidden
+			},
+		}
+		handler := &ChatHandler{settings: stub}
+
+		_, err := handler.UpdateChatSettings(context.Background(), &messagingv1.UpdateChatSettingsRequest{ChatId: "chat-001"})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestPermissionPolicyFromProto(t *testing.T) {
+	assert.Equal(t, domain.PermissionEveryone, permissionPolicyFromProto(messagingv1.PermissionPolicy_PERMISSION_POLICY_EVERYONE))
+	assert.Equal(t, domain.PermissionAdmins, permissionPolicyFromProto(messagingv1.PermissionPolicy_PERMISSION_POLICY_ADMINS))
+	assert.Equal(t, domain.PermissionPolicy(""), permissionPolicyFromProto(messagingv1.PermissionPolicy_PERMISSION_POLICY_UNSPECIFIED))
+}
+
+// ---------------------------------------------------------------------------
+// Tests — join requests
+// ---------------------------------------------------------------------------
+
+func TestChatHandler_RequestToJoin(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		stub := &stubJoinRequestService{
+			requestFn: func(_ context.Context, accessToken, chatID, note string) (domain.JoinRequest, error) {
+				assert.Equal(t, "my-access-token", accessToken)
+				assert.Equal(t, "let me in", note)
+				return domain.NewJoinRequest(chatID, "user-009", note, fixedTime)
+			},
+		}
+		handler := &ChatHandler{joinRequests: stub}
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-token"))
+
+		resp, err := handler.RequestToJoin(ctx, &messagingv1.RequestToJoinRequest{ChatId: "chat-001", Note: "let me in"})
+
+		require.NoError(t, err)
+		got := resp.GetJoinRequest()
+		assert.Equal(t, "user-009", got.GetUserId())
+		assert.Equal(t, fixedTime.Add(domain.JoinRequestTTL).UnixMilli(), got.GetExpiresAt().GetMillis())
+	})
+
+	t.Run("error - already a member maps to ALREADY_EXISTS", func(t *testing.T) {
+		stub := &stubJoinRequestService{
+			requestFn: func(context.Context, string, string, string) (domain.JoinRequest, error) {
+				return domain.JoinRequest{}, domain.ErrAlreadyExists
+			},
+		}
+		handler := &ChatHandler{joinRequests: stub}
+
+		_, err := handler.RequestToJoin(context.Background(), &messagingv1.RequestToJoinRequest{ChatId: "chat-001"})
+
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+}
+
+func TestChatHandler_ListJoinRequests(t *testing.T) {
+	stub := &stubJoinRequestService{
+		listFn: func(_ context.Context, _, chatID, cursor string, pageSize int) (app.JoinRequestPage, error) {
+			assert.Equal(t, "cur-1", cursor)
+			assert.Equal(t, 10, pageSize)
+			jr, _ := domain.NewJoinRequest(chatID, "user-009", "", fixedTime)
+			return app.JoinRequestPage{Requests: []domain.JoinRequest{jr}, Cursor: "cur-2"}, nil
+		},
+	}
+	handler := &ChatHandler{joinRequests: stub}
+
+	resp, err := handler.ListJoinRequests(context.Background(), &messagingv1.ListJoinRequestsRequest{
+		ChatId: "chat-001", Cursor: "cur-1", PageSize: 10,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, resp.GetJoinRequests(), 1)
+	assert.Equal(t, "user-009", resp.GetJoinRequests()[0].GetUserId())
+	assert.Equal(t, "cur-2", resp.GetNextCursor())
+}
+
+func TestChatHandler_DecideJoinRequest(t *testing.T) {
+	t.Run("approve passes the target user", func(t *testing.T) {
+		stub := &stubJoinRequestService{
+			decideFn: func(_ context.Context, _, chatID, userID string) error {
+				assert.Equal(t, "chat-001", chatID)
+				assert.Equal(t, "user-009", userID)
+				return nil
+			},
+		}
+		handler := &ChatHandler{joinRequests: stub}
+
+		_, err := handler.ApproveJoinRequest(context.Background(), &messagingv1.ApproveJoinRequestRequest{ChatId: "chat-001", UserId: "user-009"})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("reject of a missing request maps to NOT_FOUND", func(t *testing.T) {
+		stub := &stubJoinRequestService{
+			decideFn: func(context.Context, string, string, string) error { return domain.ErrNotFound },
+		}
+		handler := &ChatHandler{joinRequests: stub}
+
+		_, err := handler.RejectJoinRequest(context.Background(), &messagingv1.RejectJoinRequestRequest{ChatId: "chat-001", UserId: "user-009"})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — ownership
+// ---------------------------------------------------------------------------
+
+func TestChatHandler_TransferOwnership(t *testing.T) {
+	t.Run("reports whether the transfer was applied", func(t *testing.T) {
+		for _, confirm := range []bool{false, true} {
+			stub := &stubOwnershipService{
+				transferFn: func(_ context.Context, _, chatID, newOwnerID string, gotConfirm bool) error {
+					assert.Equal(t, "chat-001", chatID)
+					assert.Equal(t, "user-002", newOwnerID)
+					assert.Equal(t, confirm, gotConfirm)
+					return nil
+				},
+			}
+			handler := &ChatHandler{ownership: stub}
+
+			resp, err := handler.TransferOwnership(context.Background(), &messagingv1.TransferOwnershipRequest{
+				ChatId: "chat-001", NewOwnerId: "user-002", Confirm: confirm,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, confirm, resp.GetTransferred())
+		}
+	})
+
+	t.Run("error - concurrent change maps to ABORTED", func(t *testing.T) {
+		stub := &stubOwnershipService{
+			transferFn: func(context.Context, string, string, string, bool) error { return domain.ErrVersionConflict },
+		}
+		handler := &ChatHandler{ownership: stub}
+
+		_, err := handler.TransferOwnership(context.Background(), &messagingv1.TransferOwnershipRequest{ChatId: "chat-001"})
+
+		assert.Equal(t, codes.Aborted, status.Code(err))
+	})
+}
+
+func TestChatHandler_SetMemberRole(t *testing.T) {
+	stub := &stubOwnershipService{
+		setRoleFn: func(_ context.Context, _, _, userID string, role domain.MemberRole) error {
+			assert.Equal(t, "user-002", userID)
+			assert.Equal(t, domain.MemberRoleAdmin, role)
+			return domain.ErrForbidden
+		},
+	}
+	handler := &ChatHandler{ownership: stub}
+
+	_, err := handler.SetMemberRole(context.Background(), &messagingv1.SetMemberRoleRequest{
+		ChatId: "chat-001", UserId: "user-002", Role: messagingv1.MemberRole_MEMBER_ROLE_ADMIN,
+	})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+// ---------------------------------------------------------------------------
+// Tests — translation
+// ---------------------------------------------------------------------------
+
+func TestChatHandler_TranslateMessage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		stub := &stubTranslationService{
+			translateFn: func(_ context.Context, token, chatID string, sequence uint64, target string) (domain.Translation, error) {
+				assert.Equal(t, "test-token", token)
+				assert.Equal(t, "chat-001", chatID)
+				assert.Equal(t, uint64(7), sequence)
+				assert.Equal(t, "es", target)
+				return domain.Translation{Text: "hola", SourceLanguage: "en", TargetLanguage: "es"}, nil
+			},
+		}
+		handler := &ChatHandler{translation: stub}
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer test-token"))
+
+		resp, err := handler.TranslateMessage(ctx, &messagingv1.TranslateMessageRequest{
+			ChatId: "chat-001", Sequence: 7, TargetLanguage: "es",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "hola", resp.GetText())
+		assert.Equal(t, "en", resp.GetSourceLanguage())
+		assert.Equal(t, "es", resp.GetTargetLanguage())
+	})
+
+	t.Run("error - rate limited maps to RESOURCE_EXHAUSTED", func(t *testing.T) {
+		stub := &stubTranslationService{
+			translateFn: func(context.Context, string, string, uint64, string) (domain.Translation, error) {
+				return domain.Translation{}, domain.ErrRateLimited
+			},
+		}
+		handler := &ChatHandler{translation: stub}
+
+		_, err := handler.TranslateMessage(context.Background(), &messagingv1.TranslateMessageRequest{ChatId: "chat-001", Sequence: 1})
+
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func TestChatHandler_SetPreferredLanguage(t *testing.T) {
+	stub := &stubTranslationService{
+		setLangFn: func(_ context.Context, _, language string) (string, error) {
+			assert.Equal(t, "PT-br", language)
+			return "pt-BR", nil
+		},
+	}
+	handler := &ChatHandler{translation: stub}
+
+	resp, err := handler.SetPreferredLanguage(context.Background(), &messagingv1.SetPreferredLanguageRequest{Language: "PT-br"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "pt-BR", resp.GetLanguage())
+}
+
+func TestChatHandler_SMSFallback(t *testing.T) {
+	t.Run("set converts seconds", func(t *testing.T) {
+		stub := &stubSMSFallbackService{}
+		handler := &ChatHandler{smsFallback: stub}
+
+		resp, err := handler.SetSMSFallback(context.Background(), &messagingv1.SetSMSFallbackRequest{
+			Enabled: true, OfflineAfterSeconds: 1800,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.SMSFallback{Enabled: true, OfflineAfter: 30 * time.Minute}, stub.setting)
+		assert.True(t, resp.GetSmsFallback().GetEnabled())
+		assert.Equal(t, int32(1800), resp.GetSmsFallback().GetOfflineAfterSeconds())
+	})
+
+	t.Run("get", func(t *testing.T) {
+		handler := &ChatHandler{smsFallback: &stubSMSFallbackService{
+			setting: domain.SMSFallback{OfflineAfter: domain.DefaultSMSFallbackOfflineAfter},
+		}}
+
+		resp, err := handler.GetSMSFallback(context.Background(), &messagingv1.GetSMSFallbackRequest{})
+
+		require.NoError(t, err)
+		assert.False(t, resp.GetSmsFallback().GetEnabled())
+		assert.Equal(t, int32(900), resp.GetSmsFallback().GetOfflineAfterSeconds())
+	})
+
+	t.Run("invalid threshold maps to INVALID_ARGUMENT", func(t *testing.T) {
+		handler := &ChatHandler{smsFallback: &stubSMSFallbackService{
+			err: domain.NewValidationError("offline_after", "out of range"),
+		}}
+
+		_, err := handler.SetSMSFallback(context.Background(), &messagingv1.SetSMSFallbackRequest{OfflineAfterSeconds: 60})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestChatHandler_PrivacySettings(t *testing.T) {
+	stub := &stubPrivacySettingsService{}
+	handler := &ChatHandler{privacy: stub}
+
+	resp, err := handler.SetPrivacySettings(context.Background(), &messagingv1.SetPrivacySettingsRequest{
+		Privacy: &messagingv1.PrivacySettings{HideReadReceipts: true, HideLastSeen: true},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.PrivacySettings{HideReadReceipts: true, HideLastSeen: true}, stub.settings)
+	assert.True(t, resp.GetPrivacy().GetHideLastSeen())
+
+	got, err := handler.GetPrivacySettings(context.Background(), &messagingv1.GetPrivacySettingsRequest{})
+
+	require.NoError(t, err)
+	assert.True(t, got.GetPrivacy().GetHideReadReceipts())
+	assert.False(t, got.GetPrivacy().GetHideTyping())
+}
+
+func TestChatHandler_DNDSchedule(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubDNDScheduleService{}
+	handler := &ChatHandler{dnd: stub}
+
+	resp, err := handler.SetDNDSchedule(ctx, &messagingv1.SetDNDScheduleRequest{Dnd: &messagingv1.DNDSchedule{
+		TimeZone: "Europe/Madrid",
+		Windows:  []*messagingv1.QuietWindow{{StartMinute: 22 * 60, EndMinute: 7 * 60}},
+		Chats:    map[string]*messagingv1.ChatDND{"chat-1": {Bypass: true}},
+	}})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Madrid", stub.schedule.Location.String())
+	assert.Equal(t, []domain.QuietWindow{{Start: 22 * 60, End: 7 * 60}}, stub.schedule.Windows)
+	assert.Equal(t, map[string]domain.ChatDND{"chat-1": {Bypass: true}}, stub.schedule.Chats)
+	assert.Equal(t, "Europe/Madrid", resp.GetDnd().GetTimeZone())
+
+	got, err := handler.GetDNDSchedule(ctx, &messagingv1.GetDNDScheduleRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(7*60), got.GetDnd().GetWindows()[0].GetEndMinute())
+	assert.True(t, got.GetDnd().GetChats()["chat-1"].GetBypass())
+
+	_, err = handler.SetDNDSchedule(ctx, &messagingv1.SetDNDScheduleRequest{Dnd: &messagingv1.DNDSchedule{TimeZone: "Mars/Olympus_Mons"}})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestChatHandler_SettingsETags(t *testing.T) {
+	handler := &ChatHandler{
+		smsFallback: &stubSMSFallbackService{setting: domain.SMSFallback{Version: 3}},
+		privacy:     &stubPrivacySettingsService{settings: domain.PrivacySettings{Version: 3}},
+		dnd:         &stubDNDScheduleService{schedule: domain.DNDSchedule{Version: 3}},
+	}
+	gets := map[string]func(context.Context) error{
+		"sms fallback": func(ctx context.Context) error {
+			_, err := handler.GetSMSFallback(ctx, &messagingv1.GetSMSFallbackRequest{})
+			return err
+		},
+		"privacy": func(ctx context.Context) error {
+			_, err := handler.GetPrivacySettings(ctx, &messagingv1.GetPrivacySettingsRequest{})
+			return err
+		},
+		"dnd": func(ctx context.Context) error {
+			_, err := handler.GetDNDSchedule(ctx, &messagingv1.GetDNDScheduleRequest{})
+			return err
+		},
+	}
+	for name, get := range gets {
+		t.Run(name, func(t *testing.T) {
+			stream := &headerStream{}
+
+			require.NoError(t, get(grpc.NewContextWithServerTransportStream(context.Background(), stream)))
+
+			assert.Equal(t, []string{domain.WeakETag(3, time.Time{})}, stream.header.Get("etag"))
+		})
+	}
+}
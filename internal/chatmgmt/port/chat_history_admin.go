@@ -0,0 +1,109 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// ChatHistoryService is the subset of app.ChatHistoryService the chat
+// history admin endpoint needs.
+type ChatHistoryService interface {
+	ChatHistory(ctx context.Context, chatID string, at time.Time) ([]domain.ChatEvent, domain.ChatState, error)
+}
+
+// chatEventView is one event in an admin chat history response.
+type chatEventView struct {
+	EventID         string                       `json:"event_id"`
+	Type            string                       `json:"type"`
+	ActorID         string                       `json:"actor_id,omitempty"`
+	At              string                       `json:"at"`
+	Settings        *domain.ChatSettings         `json:"settings,omitempty"`
+	SettingsVersion int64                        `json:"settings_version,omitempty"`
+	UserID          string                       `json:"user_id,omitempty"`
+	Role            string                       `json:"role,omitempty"`
+	SuccessorID     string                       `json:"successor_id,omitempty"`
+	Members         map[string]domain.MemberRole `json:"members,omitempty"`
+}
+
+type chatHistoryResponse struct {
+	ChatID          string                       `json:"chat_id"`
+	Events          []chatEventView              `json:"events"`
+	Settings        *domain.ChatSettings         `json:"settings,omitempty"`
+	SettingsVersion int64                        `json:"settings_version,omitempty"`
+	Members         map[string]domain.MemberRole `json:"members"`
+}
+
+// ChatHistoryAdminHandler serves a chat's settings and membership events,
+// and the state they leave the chat in, for support investigations:
+//
+//	GET /admin/chats/history?chat_id=...&at=...
+//
+// at is an optional RFC 3339 time within the event retention period;
+// without it the whole log is read. Like the other /admin endpoints it is
+// authenticated by server.AdminAuth.
+func ChatHistoryAdminHandler(svc ChatHistoryService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		chatID := q.Get("chat_id")
+		if chatID == "" {
+			http.Error(w, "chat_id is required", http.StatusBadRequest)
+			return
+		}
+		var at time.Time
+		if v := q.Get("at"); v != "" {
+			var err error
+			if at, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "at must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, state, err := svc.ChatHistory(r.Context(), chatID, at)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidInput) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "chat history lookup failed",
+				"chat_id", chatID, "error", err)
+			http.Error(w, "chat history lookup failed", http.StatusInternalServerError)
+			return
+		}
+
+		resp := chatHistoryResponse{
+			ChatID:          chatID,
+			Events:          make([]chatEventView, 0, len(events)),
+			Settings:        state.Settings,
+			SettingsVersion: state.SettingsVersion,
+			Members:         state.Members,
+		}
+		for _, e := range events {
+			resp.Events = append(resp.Events, chatEventView{
+				EventID:         e.EventID,
+				Type:            string(e.Type),
+				ActorID:         e.ActorID,
+				At:              e.At.UTC().Format(time.RFC3339),
+				Settings:        e.Settings,
+				SettingsVersion: e.SettingsVersion,
+				UserID:          e.UserID,
+				Role:            string(e.Role),
+				SuccessorID:     e.SuccessorID,
+				Members:         e.Members,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,99 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type stubChatHistoryService struct {
+	fn func(ctx context.Context, chatID string, at time.Time) ([]domain.ChatEvent, domain.ChatState, error)
+}
+
+func (s *stubChatHistoryService) ChatHistory(ctx context.Context, chatID string, at time.Time) ([]domain.ChatEvent, domain.ChatState, error) {
+	return s.fn(ctx, chatID, at)
+}
+
+func TestChatHistoryAdminHandler(t *testing.T) {
+	joined := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []domain.ChatEvent{
+		{ChatID: "chat-1", EventID: "01J00000000000000000000001", Type: domain.ChatEventMemberJoined,
+			ActorID: "user-1", At: joined, UserID: "user-2", Role: domain.MemberRoleMember},
+	}
+	var gotAt time.Time
+	svc := &stubChatHistoryService{
+		fn: func(_ context.Context, chatID string, at time.Time) ([]domain.ChatEvent, domain.ChatState, error) {
+			gotAt = at
+			switch chatID {
+			case "broken":
+				return nil, domain.ChatState{}, errors.New("throttled")
+			case "old":
+				return nil, domain.ChatState{}, fmt.Errorf("chat history: %w",
+					domain.NewValidationError("at", "must be within the last 90 days"))
+			}
+			return events, domain.ReplayChatEvents(events), nil
+		},
+	}
+	handler := ChatHistoryAdminHandler(svc)
+
+	t.Run("at a point in time", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/chats/history?chat_id=chat-1&at=2026-03-02T00:00:00Z", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), gotAt)
+		var resp chatHistoryResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "chat-1", resp.ChatID)
+		require.Len(t, resp.Events, 1)
+		assert.Equal(t, "member_joined", resp.Events[0].Type)
+		assert.Equal(t, "2026-03-01T12:00:00Z", resp.Events[0].At)
+		assert.Equal(t, domain.MemberRoleMember, resp.Members["user-2"])
+	})
+
+	t.Run("without at reads the whole log", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/chats/history?chat_id=chat-1", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, gotAt.IsZero())
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for _, target := range []string{
+			"/admin/chats/history",
+			"/admin/chats/history?chat_id=chat-1&at=yesterday",
+			"/admin/chats/history?chat_id=old&at=2020-01-01T00:00:00Z",
+		} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		}
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/chats/history?chat_id=broken", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "throttled")
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/chats/history?chat_id=chat-1", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET", rec.Header().Get("Allow"))
+	})
+}
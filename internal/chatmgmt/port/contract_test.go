@@ -0,0 +1,303 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	apiv1 "github.com/aelexs/realtime-messaging-platform/api/v1"
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+// Contract tests run the handlers behind a real gRPC server on an in-memory
+// listener and call them through the generated clients, so metadata, status
+// details and proto validation cross the wire as they do in production.
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// dialBufconn serves the handlers registered by register with the production
+// interceptor chain and returns a client connection to them.
+func dialBufconn(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(server.RecoveryUnaryInterceptor(), server.ValidationUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(server.RecoveryStreamInterceptor()),
+	)
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.Stop()
+	})
+	return conn
+}
+
+func authClient(t *testing.T, svc authService) messagingv1.AuthServiceClient {
+	t.Helper()
+	conn := dialBufconn(t, func(s *grpc.Server) {
+		messagingv1.RegisterAuthServiceServer(s, &AuthHandler{svc: svc})
+	})
+	return messagingv1.NewAuthServiceClient(conn)
+}
+
+func outgoing(kv ...string) context.Context {
+	return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+// ---------------------------------------------------------------------------
+// Tests — metadata
+// ---------------------------------------------------------------------------
+
+func TestContract_Metadata(t *testing.T) {
+	t.Run("bearer and device ID reach the service; forwarded-for from an untrusted peer does not", func(t *testing.T) {
+		var gotToken, gotDevice, gotIP string
+		client := authClient(t, &stubAuthService{
+			refreshTokensFn: func(_ context.Context, accessToken, refreshToken, deviceID, clientIP string) (*app.RefreshResult, error) {
+				assert.Equal(t, "refresh-1", refreshToken)
+				gotToken, gotDevice, gotIP = accessToken, deviceID, clientIP
+				return &app.RefreshResult{AccessToken: "a2", RefreshToken: "r2", AccessTokenExpiry: fixedTime}, nil
+			},
+		})
+
+		ctx := outgoing(
+			"authorization", "Bearer access-1",
+			"x-device-id", "device-001",
+			"x-forwarded-for", "203.0.113.7, 10.0.0.1",
+		)
+		resp, err := client.RefreshTokens(ctx, &messagingv1.RefreshTokensRequest{RefreshToken: "refresh-1"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "access-1", gotToken)
+		assert.Equal(t, "device-001", gotDevice)
+		assert.Equal(t, "bufconn", gotIP, "no proxy is trusted")
+		assert.Equal(t, "a2", resp.GetAccessToken())
+		assert.Equal(t, fixedTime.UnixMilli(), resp.GetAccessTokenExpiresAt().GetMillis())
+	})
+
+	t.Run("metadata keys are case-insensitive on the wire", func(t *testing.T) {
+		var gotToken string
+		client := authClient(t, &stubAuthService{
+			logoutFn: func(_ context.Context, accessToken string) error {
+				gotToken = accessToken
+				return nil
+			},
+		})
+
+		_, err := client.Logout(outgoing("Authorization", "Bearer access-1"), &messagingv1.LogoutRequest{})
+
+		require.NoError(t, err)
+		assert.Equal(t, "access-1", gotToken)
+	})
+
+	t.Run("without forwarded-for the peer address is the client IP", func(t *testing.T) {
+		var gotIP string
+		client := authClient(t, &stubAuthService{
+			requestOTPFn: func(_ context.Context, _, clientIP string) (*app.RequestOTPResult, error) {
+				gotIP = clientIP
+				return &app.RequestOTPResult{ExpiresAt: fixedTime}, nil
+			},
+		})
+
+		_, err := client.RequestOTP(context.Background(), &messagingv1.RequestOTPRequest{PhoneNumber: "+14155552671"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "bufconn", gotIP, "the bufconn peer has no port")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — error mapping
+// ---------------------------------------------------------------------------
+
+func TestContract_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{domain.ErrSessionRevoked, codes.Unauthenticated},
+		{domain.ErrRefreshTokenReuse, codes.Unauthenticated},
+		{domain.ErrDeviceMismatch, codes.Unauthenticated},
+		{domain.ErrPhoneRateLimited, codes.ResourceExhausted},
+		{domain.ErrMaxSessionsExceeded, codes.ResourceExhausted},
+		{domain.ErrInvalidPhoneNumber, codes.InvalidArgument},
+		{domain.ErrUnavailable, codes.Unavailable},
+		{errors.New("dynamodb: connection reset"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			client := authClient(t, &stubAuthService{
+				refreshTokensFn: func(context.Context, string, string, string, string) (*app.RefreshResult, error) {
+					return nil, tt.err
+				},
+			})
+
+			_, err := client.RefreshTokens(context.Background(), &messagingv1.RefreshTokensRequest{RefreshToken: "r"})
+
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, tt.code, st.Code())
+			var info *errdetails.ErrorInfo
+			for _, d := range st.Details() {
+				if ei, ok := d.(*errdetails.ErrorInfo); ok {
+					info = ei
+				}
+			}
+			require.NotNil(t, info, "ErrorInfo detail survives the wire")
+			assert.Equal(t, errmap.Classify(tt.err).Code, info.GetReason())
+			if tt.code == codes.Internal {
+				assert.Equal(t, "internal error", st.Message(), "internal details stay on the server")
+			}
+		})
+	}
+
+	t.Run("proto validation rejects before the handler", func(t *testing.T) {
+		client := authClient(t, &stubAuthService{
+			refreshTokensFn: func(context.Context, string, string, string, string) (*app.RefreshResult, error) {
+				assert.Fail(t, "handler called with an invalid request")
+				return nil, domain.ErrUnavailable
+			},
+		})
+
+		_, err := client.RefreshTokens(context.Background(), &messagingv1.RefreshTokensRequest{})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("unregistered RPCs are UNIMPLEMENTED", func(t *testing.T) {
+		conn := dialBufconn(t, func(s *grpc.Server) {
+			messagingv1.RegisterChatMgmtServiceServer(s, &ChatHandler{})
+		})
+
+		_, err := messagingv1.NewChatMgmtServiceClient(conn).ListChats(context.Background(), &messagingv1.ListChatsRequest{})
+
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — OpenAPI
+// ---------------------------------------------------------------------------
+
+// TestContract_OpenAPIMatchesAnnotations checks that the embedded OpenAPI
+// spec lists exactly the HTTP bindings of the chatmgmt.proto services. It
+// fails when the proto changes and `make proto` has not been rerun.
+func TestContract_OpenAPIMatchesAnnotations(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(apiv1.Spec, &spec))
+
+	documented := map[string]string{} // "METHOD /path" -> operation ID
+	for path, ops := range spec.Paths {
+		for method, op := range ops {
+			documented[strings.ToUpper(method)+" "+path] = op.OperationID
+		}
+	}
+
+	annotated := map[string]string{}
+	services := messagingv1.File_messaging_v1_chatmgmt_proto.Services()
+	for i := range services.Len() {
+		svc := services.Get(i)
+		methods := svc.Methods()
+		for j := range methods.Len() {
+			m := methods.Get(j)
+			rule, _ := proto.GetExtension(m.Options(), annotations.E_Http).(*annotations.HttpRule)
+			if rule == nil {
+				continue
+			}
+			for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+				method, path := httpBinding(r)
+				annotated[method+" "+openAPIPath(path)] = operationID(svc, m)
+			}
+		}
+	}
+
+	assert.Equal(t, annotated, documented)
+}
+
+// httpBinding returns the HTTP method and path template of an http rule.
+func httpBinding(r *annotations.HttpRule) (string, string) {
+	switch p := r.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// openAPIPath rewrites path parameters to the JSON field names
+// protoc-gen-openapiv2 uses: "{chat_id}" becomes "{chatId}".
+func openAPIPath(path string) string {
+	var b strings.Builder
+	inParam, upper := false, false
+	for _, r := range path {
+		switch {
+		case r == '{':
+			inParam = true
+		case r == '}':
+			inParam = false
+		case inParam && r == '_':
+			upper = true
+			continue
+		case upper:
+			r, upper = unicode.ToUpper(r), false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func operationID(svc protoreflect.ServiceDescriptor, m protoreflect.MethodDescriptor) string {
+	return string(svc.Name()) + "_" + string(m.Name())
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/v1/chats/{chatId}/members/{userId}", openAPIPath("/v1/chats/{chat_id}/members/{user_id}"))
+	assert.Equal(t, "/v1/chats/{chatId}/owner:transfer", openAPIPath("/v1/chats/{chat_id}/owner:transfer"))
+	assert.Equal(t, "/v1/auth/push-token", openAPIPath("/v1/auth/push-token"))
+}
@@ -0,0 +1,37 @@
+package port
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// KeyIDLister is the subset of auth.KeyStore the keys admin endpoint
+// needs.
+type KeyIDLister interface {
+	KeyIDs() (signing string, verification []string)
+}
+
+type keysResponse struct {
+	SigningKeyID string   `json:"signing_key_id"`
+	KeyIDs       []string `json:"key_ids"`
+}
+
+// KeysAdminHandler reports which JWT keys this instance signs and
+// verifies with, for checking a key rotation or a token's kid:
+//
+//	GET /admin/keys
+//
+// Only key IDs are returned, never key material. Like the other /admin
+// endpoints it is authenticated by server.AdminAuth.
+func KeysAdminHandler(keys KeyIDLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		signing, verification := keys.KeyIDs()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keysResponse{SigningKeyID: signing, KeyIDs: verification})
+	})
+}
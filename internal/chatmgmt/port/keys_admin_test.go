@@ -0,0 +1,40 @@
+package port
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubKeyIDs struct {
+	signing      string
+	verification []string
+}
+
+func (s stubKeyIDs) KeyIDs() (string, []string) { return s.signing, s.verification }
+
+func TestKeysAdminHandler(t *testing.T) {
+	handler := KeysAdminHandler(stubKeyIDs{signing: "key-002", verification: []string{"key-001", "key-002"}})
+
+	t.Run("lists key IDs", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp keysResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, keysResponse{SigningKeyID: "key-002", KeyIDs: []string{"key-001", "key-002"}}, resp)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/keys", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET", rec.Header().Get("Allow"))
+	})
+}
@@ -0,0 +1,94 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// TokenLineageService is the subset of app.AuthService the lineage admin
+// endpoint needs.
+type TokenLineageService interface {
+	TokenFamily(ctx context.Context, familyID string) ([]app.TokenLineageEntry, error)
+	UserTokenLineage(ctx context.Context, userID string) ([]app.TokenLineageEntry, error)
+}
+
+// lineageGeneration is one generation in an admin lineage response.
+type lineageGeneration struct {
+	FamilyID      string `json:"family_id"`
+	Generation    int64  `json:"generation"`
+	SessionID     string `json:"session_id"`
+	UserID        string `json:"user_id"`
+	DeviceID      string `json:"device_id"`
+	ClientIP      string `json:"client_ip,omitempty"`
+	Event         string `json:"event"`
+	IssuedAt      string `json:"issued_at"`
+	ReusedAt      string `json:"reused_at,omitempty"`
+	ReuseDeviceID string `json:"reuse_device_id,omitempty"`
+	ReuseClientIP string `json:"reuse_client_ip,omitempty"`
+}
+
+type lineageResponse struct {
+	Generations []lineageGeneration `json:"generations"`
+}
+
+// TokenLineageAdminHandler serves refresh token lineage for incident
+// investigation:
+//
+//	GET /admin/token-lineage?family_id=...
+//	GET /admin/token-lineage?user_id=...
+//
+// Exactly one of family_id or user_id must be given. Like the other /admin
+// endpoints it is authenticated by server.AdminAuth.
+func TokenLineageAdminHandler(svc TokenLineageService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		familyID, userID := r.URL.Query().Get("family_id"), r.URL.Query().Get("user_id")
+		var (
+			entries []app.TokenLineageEntry
+			err     error
+		)
+		switch {
+		case familyID != "" && userID == "":
+			entries, err = svc.TokenFamily(r.Context(), familyID)
+		case userID != "" && familyID == "":
+			entries, err = svc.UserTokenLineage(r.Context(), userID)
+		default:
+			http.Error(w, "exactly one of family_id or user_id is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "token lineage lookup failed",
+				"family_id", familyID, "user_id", userID, "error", err)
+			http.Error(w, "lineage lookup failed", http.StatusInternalServerError)
+			return
+		}
+
+		resp := lineageResponse{Generations: make([]lineageGeneration, 0, len(entries))}
+		for _, e := range entries {
+			resp.Generations = append(resp.Generations, lineageGeneration{
+				FamilyID:      e.FamilyID,
+				Generation:    e.Generation,
+				SessionID:     e.SessionID,
+				UserID:        e.UserID,
+				DeviceID:      e.DeviceID,
+				ClientIP:      e.ClientIP,
+				Event:         e.Event,
+				IssuedAt:      e.IssuedAt,
+				ReusedAt:      e.ReusedAt,
+				ReuseDeviceID: e.ReuseDeviceID,
+				ReuseClientIP: e.ReuseClientIP,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
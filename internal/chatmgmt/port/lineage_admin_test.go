@@ -0,0 +1,97 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+)
+
+type stubLineageService struct {
+	familyFn func(ctx context.Context, familyID string) ([]app.TokenLineageEntry, error)
+	userFn   func(ctx context.Context, userID string) ([]app.TokenLineageEntry, error)
+}
+
+func (s *stubLineageService) TokenFamily(ctx context.Context, familyID string) ([]app.TokenLineageEntry, error) {
+	return s.familyFn(ctx, familyID)
+}
+
+func (s *stubLineageService) UserTokenLineage(ctx context.Context, userID string) ([]app.TokenLineageEntry, error) {
+	return s.userFn(ctx, userID)
+}
+
+func TestTokenLineageAdminHandler(t *testing.T) {
+	entries := []app.TokenLineageEntry{
+		{FamilyID: "fam-1", Generation: 1, SessionID: "sess-1", UserID: "user-1", DeviceID: "dev-a",
+			ClientIP: "203.0.113.1", Event: app.LineageLogin, IssuedAt: "2026-03-01T00:00:00Z"},
+		{FamilyID: "fam-1", Generation: 2, SessionID: "sess-1", UserID: "user-1", DeviceID: "dev-a",
+			ClientIP: "203.0.113.1", Event: app.LineageRefresh, IssuedAt: "2026-03-01T00:10:00Z",
+			ReusedAt: "2026-03-01T00:20:00Z", ReuseDeviceID: "dev-b", ReuseClientIP: "198.51.100.7"},
+	}
+	svc := &stubLineageService{
+		familyFn: func(_ context.Context, familyID string) ([]app.TokenLineageEntry, error) {
+			assert.Equal(t, "fam-1", familyID)
+			return entries, nil
+		},
+		userFn: func(_ context.Context, userID string) ([]app.TokenLineageEntry, error) {
+			if userID == "broken" {
+				return nil, errors.New("throttled")
+			}
+			return nil, nil
+		},
+	}
+	handler := TokenLineageAdminHandler(svc)
+
+	t.Run("by family", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/token-lineage?family_id=fam-1", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp lineageResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		require.Len(t, resp.Generations, 2)
+		assert.Equal(t, "203.0.113.1", resp.Generations[0].ClientIP)
+		assert.Empty(t, resp.Generations[0].ReusedAt)
+		assert.Equal(t, "dev-b", resp.Generations[1].ReuseDeviceID)
+		assert.Equal(t, "198.51.100.7", resp.Generations[1].ReuseClientIP)
+	})
+
+	t.Run("empty user lineage is an empty list", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/token-lineage?user_id=user-9", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"generations":[]}`, rec.Body.String())
+	})
+
+	t.Run("requires exactly one selector", func(t *testing.T) {
+		for _, target := range []string{"/admin/token-lineage", "/admin/token-lineage?family_id=f&user_id=u"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		}
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/token-lineage?user_id=broken", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "throttled")
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/token-lineage?family_id=fam-1", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET", rec.Header().Get("Allow"))
+	})
+}
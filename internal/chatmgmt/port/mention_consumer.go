@@ -0,0 +1,97 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// MentionNotifier is the subset of app.MentionService the mention
+// consumer needs.
+type MentionNotifier interface {
+	NotifyMentions(ctx context.Context, msg app.PostedMessage) error
+}
+
+// PostedMessageDecoder decodes the value of a messages.persisted record.
+type PostedMessageDecoder func(value []byte) (app.PostedMessage, error)
+
+// MentionConsumerConfig holds the dependencies for MentionConsumer.
+type MentionConsumerConfig struct {
+	Consumer kafka.Consumer
+	Decode   PostedMessageDecoder
+	Notify   MentionNotifier
+	Logger   *slog.Logger // nil uses slog.Default
+}
+
+// MentionConsumer feeds messages.persisted to the mention notifier.
+// Records are processed in order and committed a batch at a time; a
+// restart redelivers at most one batch. A record that cannot be decoded
+// or processed is logged and skipped: its mentions are not notified.
+type MentionConsumer struct {
+	consumer kafka.Consumer
+	decode   PostedMessageDecoder
+	notify   MentionNotifier
+	logger   *slog.Logger
+}
+
+// NewMentionConsumer creates a MentionConsumer.
+func NewMentionConsumer(cfg MentionConsumerConfig) *MentionConsumer {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &MentionConsumer{consumer: cfg.Consumer, decode: cfg.Decode, notify: cfg.Notify, logger: logger}
+}
+
+// Run processes records until ctx is done or the consumer is closed. An
+// unfinished batch is left uncommitted.
+func (c *MentionConsumer) Run(ctx context.Context) error {
+	for {
+		records, err := c.consumer.Poll(ctx)
+		if errors.Is(err, kafka.ErrClientClosed) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("mention consumer: poll: %w", err)
+		}
+		for _, r := range records {
+			c.process(ctx, r)
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+		if err := c.consumer.Commit(ctx, records...); err != nil {
+			return fmt.Errorf("mention consumer: commit: %w", err)
+		}
+	}
+}
+
+// process notifies the mentions of one record. A panic is recovered and
+// the record skipped, so it cannot stop the loop.
+func (c *MentionConsumer) process(ctx context.Context, r *kafka.Record) {
+	defer observability.Recover(ctx, "kafka_consumer")
+	ctx, span := kafka.StartConsumeSpan(ctx, r)
+	defer span.End()
+
+	msg, err := c.decode(r.Value)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.WarnContext(ctx, "mention.undecodable",
+			"partition", r.Partition, "offset", r.Offset, "error", err)
+		return
+	}
+	if err := c.notify.NotifyMentions(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.ErrorContext(ctx, "mention.dropped",
+			"message_id", msg.MessageID, "chat_id", msg.ChatID, "error", err)
+	}
+}
@@ -0,0 +1,90 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+)
+
+type fakeMentionNotifier struct {
+	mu       sync.Mutex
+	notified []string
+	failing  map[string]bool
+}
+
+func (f *fakeMentionNotifier) NotifyMentions(_ context.Context, msg app.PostedMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[msg.MessageID] {
+		return errors.New("memberships throttled")
+	}
+	f.notified = append(f.notified, msg.MessageID)
+	return nil
+}
+
+func TestMentionConsumer_Run(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	notifier := &fakeMentionNotifier{failing: map[string]bool{"m3": true}}
+	c := NewMentionConsumer(MentionConsumerConfig{
+		Consumer: consumer,
+		Decode: func(value []byte) (app.PostedMessage, error) {
+			if len(value) == 0 {
+				return app.PostedMessage{}, errors.New("empty record")
+			}
+			return app.PostedMessage{MessageID: string(value)}, nil
+		},
+		Notify: notifier,
+	})
+
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")},
+		&kafka.Record{Topic: "messages.persisted", Value: nil},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m3")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m4")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 4 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"m1", "m4"}, notifier.notified, "in order; undecodable and failed records skipped")
+}
+
+func TestMentionConsumer_RunRecoversPanics(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	notifier := &fakeMentionNotifier{}
+	c := NewMentionConsumer(MentionConsumerConfig{
+		Consumer: consumer,
+		Decode: func(value []byte) (app.PostedMessage, error) {
+			if string(value) == "malformed" {
+				panic("index out of range")
+			}
+			return app.PostedMessage{MessageID: string(value)}, nil
+		},
+		Notify: notifier,
+	})
+
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("malformed")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m2")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 2 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"m2"}, notifier.notified, "the panicking record is skipped")
+}
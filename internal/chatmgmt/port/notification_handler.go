@@ -0,0 +1,96 @@
+package port
+
+import (
+	"context"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// feedService is a narrow, consumer-defined interface for the feed
+// operations the handler requires. The *app.FeedService satisfies this.
+type feedService interface {
+	ListNotifications(ctx context.Context, accessToken, cursor string, pageSize int, unreadOnly bool) (app.FeedPage, error)
+	AckNotifications(ctx context.Context, accessToken string, ids []string) error
+}
+
+// NotificationHandler implements the gRPC NotificationServiceServer interface.
+type NotificationHandler struct {
+	messagingv1.UnimplementedNotificationServiceServer
+	feed feedService
+}
+
+// NewNotificationHandler creates a NotificationHandler backed by the given
+// FeedService.
+func NewNotificationHandler(feed *app.FeedService) *NotificationHandler {
+	return &NotificationHandler{feed: feed}
+}
+
+// ListNotifications returns one page of the caller's feed.
+func (h *NotificationHandler) ListNotifications(
+	ctx context.Context, req *messagingv1.ListNotificationsRequest,
+) (*messagingv1.ListNotificationsResponse, error) {
+	accessToken := extractBearerToken(ctx)
+
+	page, err := h.feed.ListNotifications(ctx, accessToken, req.GetCursor(), int(req.GetPageSize()), req.GetUnreadOnly())
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+
+	out := make([]*messagingv1.Notification, 0, len(page.Entries))
+	for _, e := range page.Entries {
+		out = append(out, feedEntryToProto(e))
+	}
+	return &messagingv1.ListNotificationsResponse{
+		Notifications: out,
+		NextCursor:    page.Cursor,
+	}, nil
+}
+
+// AckNotifications marks the caller's entries as read.
+func (h *NotificationHandler) AckNotifications(
+	ctx context.Context, req *messagingv1.AckNotificationsRequest,
+) (*messagingv1.AckNotificationsResponse, error) {
+	accessToken := extractBearerToken(ctx)
+
+	if err := h.feed.AckNotifications(ctx, accessToken, req.GetNotificationIds()); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.AckNotificationsResponse{}, nil
+}
+
+// feedEntryToProto converts a feed entry to its wire representation.
+func feedEntryToProto(e domain.FeedEntry) *messagingv1.Notification {
+	n := &messagingv1.Notification{
+		NotificationId: e.EntryID,
+		Kind:           feedKindToProto(e.Kind),
+		ChatId:         e.ChatID,
+		ActorId:        e.ActorID,
+		Params:         e.Params,
+		CreatedAt:      timeToProtoTimestamp(e.CreatedAt),
+	}
+	if !e.Unread() {
+		n.ReadAt = timeToProtoTimestamp(e.ReadAt)
+	}
+	return n
+}
+
+// feedKindToProto maps a domain feed kind to the proto enum.
+func feedKindToProto(k domain.FeedKind) messagingv1.NotificationKind {
+	switch k {
+	case domain.FeedKindMention:
+		return messagingv1.NotificationKind_NOTIFICATION_KIND_MENTION
+	case domain.FeedKindInvite:
+		return messagingv1.NotificationKind_NOTIFICATION_KIND_INVITE
+	case domain.FeedKindMissedCall:
+		return messagingv1.NotificationKind_NOTIFICATION_KIND_MISSED_CALL
+	case domain.FeedKindSystem:
+		return messagingv1.NotificationKind_NOTIFICATION_KIND_SYSTEM
+	case domain.FeedKindJoinRequest:
+		return messagingv1.NotificationKind_NOTIFICATION_KIND_JOIN_REQUEST
+	default:
+		return messagingv1.NotificationKind_NOTIFICATION_KIND_UNSPECIFIED
+	}
+}
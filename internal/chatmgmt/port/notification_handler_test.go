@@ -0,0 +1,123 @@
+package port
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Stubs
+// ---------------------------------------------------------------------------
+
+type stubFeedService struct {
+	listFn func(ctx context.Context, accessToken, cursor string, pageSize int, unreadOnly bool) (app.FeedPage, error)
+	ackFn  func(ctx context.Context, accessToken string, ids []string) error
+}
+
+func (s *stubFeedService) ListNotifications(ctx context.Context, accessToken, cursor string, pageSize int, unreadOnly bool) (app.FeedPage, error) {
+	return s.listFn(ctx, accessToken, cursor, pageSize, unreadOnly)
+}
+
+func (s *stubFeedService) AckNotifications(ctx context.Context, accessToken string, ids []string) error {
+	return s.ackFn(ctx, accessToken, ids)
+}
+
+var _ feedService = (*stubFeedService)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests — ListNotifications
+// ---------------------------------------------------------------------------
+
+func TestNotificationHandler_ListNotifications(t *testing.T) {
+	t.Run("success - maps entries and cursor", func(t *testing.T) {
+		readAt := fixedTime.Add(time.Minute)
+		stub := &stubFeedService{
+			listFn: func(_ context.Context, accessToken, cursor string, pageSize int, unreadOnly bool) (app.FeedPage, error) {
+				assert.Equal(t, "my-access-token", accessToken)
+				assert.Equal(t, "c0", cursor)
+				assert.Equal(t, 20, pageSize)
+				assert.True(t, unreadOnly)
+				return app.FeedPage{
+					Entries: []domain.FeedEntry{
+						{EntryID: "n2", Kind: domain.FeedKindInvite, ActorID: "user-002", Params: map[string]string{"chat_name": "Team"}, CreatedAt: fixedTime},
+						{EntryID: "n1", Kind: domain.FeedKindMention, ChatID: "chat-001", CreatedAt: fixedTime, ReadAt: readAt},
+					},
+					Cursor: "c1",
+				}, nil
+			},
+		}
+		handler := &NotificationHandler{feed: stub}
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-token"))
+
+		resp, err := handler.ListNotifications(ctx, &messagingv1.ListNotificationsRequest{Cursor: "c0", PageSize: 20, UnreadOnly: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, "c1", resp.GetNextCursor())
+		require.Len(t, resp.GetNotifications(), 2)
+		first := resp.GetNotifications()[0]
+		assert.Equal(t, messagingv1.NotificationKind_NOTIFICATION_KIND_INVITE, first.GetKind())
+		assert.Equal(t, "Team", first.GetParams()["chat_name"])
+		assert.Nil(t, first.GetReadAt())
+		assert.Equal(t, readAt.UnixMilli(), resp.GetNotifications()[1].GetReadAt().GetMillis())
+	})
+
+	t.Run("error - maps domain errors to gRPC status", func(t *testing.T) {
+		stub := &stubFeedService{
+			listFn: func(context.Context, string, string, int, bool) (app.FeedPage, error) {
+				return app.FeedPage{}, domain.ErrUnauthorized
+			},
+		}
+		handler := &NotificationHandler{feed: stub}
+
+		_, err := handler.ListNotifications(context.Background(), &messagingv1.ListNotificationsRequest{})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — AckNotifications
+// ---------------------------------------------------------------------------
+
+func TestNotificationHandler_AckNotifications(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		stub := &stubFeedService{
+			ackFn: func(_ context.Context, accessToken string, ids []string) error {
+				assert.Equal(t, "my-access-token", accessToken)
+				assert.Equal(t, []string{"n1", "n2"}, ids)
+				return nil
+			},
+		}
+		handler := &NotificationHandler{feed: stub}
+		ctx := ctxWithMetadata(metadata.Pairs("authorization", "Bearer my-access-token"))
+
+		resp, err := handler.AckNotifications(ctx, &messagingv1.AckNotificationsRequest{NotificationIds: []string{"n1", "n2"}})
+
+		require.NoError(t, err)
+		assert.NotNil(t, resp)
+	})
+
+	t.Run("error - invalid input", func(t *testing.T) {
+		stub := &stubFeedService{
+			ackFn: func(context.Context, string, []string) error {
+				return domain.NewValidationError("notification_ids", "at most 100 allowed")
+			},
+		}
+		handler := &NotificationHandler{feed: stub}
+
+		_, err := handler.AckNotifications(context.Background(), &messagingv1.AckNotificationsRequest{})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
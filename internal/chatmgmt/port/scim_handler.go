@@ -0,0 +1,790 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// SCIM schema and message URNs (RFC 7643, RFC 7644).
+const (
+	scimContentType      = "application/scim+json"
+	scimUserSchema       = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema      = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema       = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimPatchOpSchema    = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimErrorSchema      = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimPrefix           = "/scim/v2/workspaces/{workspace_id}"
+	scimMaxPatchOps      = 100
+	scimDefaultStartIdx  = 1
+	scimUnspecifiedCount = -1
+)
+
+// SCIMService is the app.SCIMService the SCIM endpoints need.
+type SCIMService interface {
+	Authenticate(ctx context.Context, workspaceID, token string) error
+	CreateUser(ctx context.Context, workspaceID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error)
+	GetUser(ctx context.Context, workspaceID, userID string) (*domain.WorkspaceMember, error)
+	ListUsers(ctx context.Context, workspaceID string, filter app.MemberFilter, startIndex, count int) (app.MemberList, error)
+	ReplaceUser(ctx context.Context, workspaceID, userID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error)
+	PatchUser(ctx context.Context, workspaceID, userID string, patch app.MemberPatch) (*domain.WorkspaceMember, error)
+	DeleteUser(ctx context.Context, workspaceID, userID string) error
+	CreateGroup(ctx context.Context, workspaceID string, group domain.WorkspaceGroup) (*domain.WorkspaceGroup, error)
+	GetGroup(ctx context.Context, workspaceID, groupID string) (*domain.WorkspaceGroup, error)
+	ListGroups(ctx context.Context, workspaceID string, filter app.GroupFilter, startIndex, count int) (app.GroupList, error)
+	ReplaceGroup(ctx context.Context, workspaceID, groupID string, group domain.WorkspaceGroup) (*domain.WorkspaceGroup, error)
+	PatchGroup(ctx context.Context, workspaceID, groupID string, patch app.GroupPatch) (*domain.WorkspaceGroup, error)
+	DeleteGroup(ctx context.Context, workspaceID, groupID string) error
+}
+
+// SCIMTokenService is the app.SCIMService the SCIM token admin endpoint
+// needs.
+type SCIMTokenService interface {
+	RotateToken(ctx context.Context, actor, workspaceID string) (string, error)
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Version      string `json:"version,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string  `json:"schemas"`
+	ID          string    `json:"id,omitempty"`
+	ExternalID  string    `json:"externalId,omitempty"`
+	UserName    string    `json:"userName"`
+	DisplayName string    `json:"displayName,omitempty"`
+	Name        *scimName `json:"name,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+	Meta        *scimMeta `json:"meta,omitempty"`
+}
+
+type scimGroupMember struct {
+	Value string `json:"value"`
+}
+
+type scimGroup struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id,omitempty"`
+	ExternalID  string            `json:"externalId,omitempty"`
+	DisplayName string            `json:"displayName"`
+	Members     []scimGroupMember `json:"members"`
+	Meta        *scimMeta         `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string      `json:"schemas"`
+	Operations []scimPatchOp `json:"Operations"`
+}
+
+type scimPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// scimRequestError is a malformed SCIM request, answered with 400 and its
+// scimType.
+type scimRequestError struct {
+	scimType string
+	detail   string
+}
+
+func (e *scimRequestError) Error() string { return e.detail }
+
+func invalidSCIM(scimType, format string, args ...any) error {
+	return &scimRequestError{scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+// SCIMHandler serves a SCIM 2.0 service provider (RFC 7644) per
+// workspace, for the workspace's identity provider to push its members:
+//
+//	GET|POST              /scim/v2/workspaces/{workspace_id}/Users
+//	GET|PUT|PATCH|DELETE  /scim/v2/workspaces/{workspace_id}/Users/{id}
+//	GET|POST              /scim/v2/workspaces/{workspace_id}/Groups
+//	GET|PUT|PATCH|DELETE  /scim/v2/workspaces/{workspace_id}/Groups/{id}
+//
+// The IdP authenticates with "Authorization: Bearer <token>", the token
+// issued by SCIMTokenAdminHandler. List filters support a single
+// `attribute eq "value"` on userName or externalId (Users) and
+// displayName or externalId (Groups), the forms IdPs use to find a
+// resource before creating it. Deactivating a user, by PATCH or PUT with
+// active false, signs them out and removes them from groups and chats.
+// Errors use the SCIM error schema rather than problem+json.
+func SCIMHandler(svc SCIMService) http.Handler {
+	h := &scimHandler{svc: svc}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+scimPrefix+"/Users", h.authenticated(h.listUsers))
+	mux.HandleFunc("POST "+scimPrefix+"/Users", h.authenticated(h.createUser))
+	mux.HandleFunc("GET "+scimPrefix+"/Users/{id}", h.authenticated(h.getUser))
+	mux.HandleFunc("PUT "+scimPrefix+"/Users/{id}", h.authenticated(h.replaceUser))
+	mux.HandleFunc("PATCH "+scimPrefix+"/Users/{id}", h.authenticated(h.patchUser))
+	mux.HandleFunc("DELETE "+scimPrefix+"/Users/{id}", h.authenticated(h.deleteUser))
+	mux.HandleFunc("GET "+scimPrefix+"/Groups", h.authenticated(h.listGroups))
+	mux.HandleFunc("POST "+scimPrefix+"/Groups", h.authenticated(h.createGroup))
+	mux.HandleFunc("GET "+scimPrefix+"/Groups/{id}", h.authenticated(h.getGroup))
+	mux.HandleFunc("PUT "+scimPrefix+"/Groups/{id}", h.authenticated(h.replaceGroup))
+	mux.HandleFunc("PATCH "+scimPrefix+"/Groups/{id}", h.authenticated(h.patchGroup))
+	mux.HandleFunc("DELETE "+scimPrefix+"/Groups/{id}", h.authenticated(h.deleteGroup))
+	return mux
+}
+
+type scimHandler struct {
+	svc SCIMService
+}
+
+// authenticated checks the bearer token against the path's workspace
+// before calling next.
+func (h *scimHandler) authenticated(next func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		workspaceID := r.PathValue("workspace_id")
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeSCIMError(w, r, fmt.Errorf("missing bearer token: %w", domain.ErrUnauthorized))
+			return
+		}
+		if err := h.svc.Authenticate(r.Context(), workspaceID, token); err != nil {
+			writeSCIMError(w, r, err)
+			return
+		}
+		next(w, r, workspaceID)
+	}
+}
+
+func (h *scimHandler) listUsers(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	startIndex, count, err := scimPaging(r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	var filter app.MemberFilter
+	if f := r.URL.Query().Get("filter"); f != "" {
+		attr, value, err := parseSCIMFilter(f)
+		switch {
+		case err != nil:
+			writeSCIMError(w, r, err)
+			return
+		case strings.EqualFold(attr, "userName"):
+			filter.UserName = value
+		case strings.EqualFold(attr, "externalId"):
+			filter.ExternalID = value
+		default:
+			writeSCIMError(w, r, invalidSCIM("invalidFilter", "users cannot be filtered by %s", attr))
+			return
+		}
+	}
+
+	list, err := h.svc.ListUsers(r.Context(), workspaceID, filter, startIndex, count)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	resources := make([]any, 0, len(list.Members))
+	for _, m := range list.Members {
+		resources = append(resources, toSCIMUser(m))
+	}
+	writeSCIM(w, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: list.Total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *scimHandler) createUser(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	member, err := decodeSCIMUser(w, r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	created, err := h.svc.CreateUser(r.Context(), workspaceID, member)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusCreated, toSCIMUser(*created))
+}
+
+func (h *scimHandler) getUser(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	member, err := h.svc.GetUser(r.Context(), workspaceID, r.PathValue("id"))
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMUser(*member))
+}
+
+func (h *scimHandler) replaceUser(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	member, err := decodeSCIMUser(w, r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	updated, err := h.svc.ReplaceUser(r.Context(), workspaceID, r.PathValue("id"), member)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMUser(*updated))
+}
+
+func (h *scimHandler) patchUser(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	ops, err := decodeSCIMPatch(w, r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	patch, err := toMemberPatch(ops)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	updated, err := h.svc.PatchUser(r.Context(), workspaceID, r.PathValue("id"), patch)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMUser(*updated))
+}
+
+func (h *scimHandler) deleteUser(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	if err := h.svc.DeleteUser(r.Context(), workspaceID, r.PathValue("id")); err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *scimHandler) listGroups(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	startIndex, count, err := scimPaging(r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	var filter app.GroupFilter
+	if f := r.URL.Query().Get("filter"); f != "" {
+		attr, value, err := parseSCIMFilter(f)
+		switch {
+		case err != nil:
+			writeSCIMError(w, r, err)
+			return
+		case strings.EqualFold(attr, "displayName"):
+			filter.DisplayName = value
+		case strings.EqualFold(attr, "externalId"):
+			filter.ExternalID = value
+		default:
+			writeSCIMError(w, r, invalidSCIM("invalidFilter", "groups cannot be filtered by %s", attr))
+			return
+		}
+	}
+
+	list, err := h.svc.ListGroups(r.Context(), workspaceID, filter, startIndex, count)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	resources := make([]any, 0, len(list.Groups))
+	for _, g := range list.Groups {
+		resources = append(resources, toSCIMGroup(g))
+	}
+	writeSCIM(w, http.StatusOK, scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: list.Total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *scimHandler) createGroup(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	group, err := decodeSCIMGroup(w, r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	created, err := h.svc.CreateGroup(r.Context(), workspaceID, group)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusCreated, toSCIMGroup(*created))
+}
+
+func (h *scimHandler) getGroup(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	group, err := h.svc.GetGroup(r.Context(), workspaceID, r.PathValue("id"))
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMGroup(*group))
+}
+
+func (h *scimHandler) replaceGroup(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	group, err := decodeSCIMGroup(w, r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	updated, err := h.svc.ReplaceGroup(r.Context(), workspaceID, r.PathValue("id"), group)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMGroup(*updated))
+}
+
+func (h *scimHandler) patchGroup(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	ops, err := decodeSCIMPatch(w, r)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	patch, err := toGroupPatch(ops)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	updated, err := h.svc.PatchGroup(r.Context(), workspaceID, r.PathValue("id"), patch)
+	if err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, toSCIMGroup(*updated))
+}
+
+func (h *scimHandler) deleteGroup(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	if err := h.svc.DeleteGroup(r.Context(), workspaceID, r.PathValue("id")); err != nil {
+		writeSCIMError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeSCIMBody decodes a request body of at most
+// domain.MaxSCIMRequestBytes into dst.
+func decodeSCIMBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, domain.MaxSCIMRequestBytes)).Decode(dst); err != nil {
+		return invalidSCIM("invalidSyntax", "body must be a SCIM JSON resource")
+	}
+	return nil
+}
+
+// decodeSCIMUser decodes a User resource. active defaults to true, and
+// displayName falls back to the user's name.
+func decodeSCIMUser(w http.ResponseWriter, r *http.Request) (domain.WorkspaceMember, error) {
+	var u scimUser
+	if err := decodeSCIMBody(w, r, &u); err != nil {
+		return domain.WorkspaceMember{}, err
+	}
+	member := domain.WorkspaceMember{
+		UserName:    u.UserName,
+		ExternalID:  u.ExternalID,
+		DisplayName: u.DisplayName,
+		Active:      u.Active == nil || *u.Active,
+	}
+	if member.DisplayName == "" && u.Name != nil {
+		member.DisplayName = u.Name.Formatted
+		if member.DisplayName == "" {
+			member.DisplayName = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+		}
+	}
+	return member, nil
+}
+
+func decodeSCIMGroup(w http.ResponseWriter, r *http.Request) (domain.WorkspaceGroup, error) {
+	var g scimGroup
+	if err := decodeSCIMBody(w, r, &g); err != nil {
+		return domain.WorkspaceGroup{}, err
+	}
+	group := domain.WorkspaceGroup{DisplayName: g.DisplayName, ExternalID: g.ExternalID}
+	for _, m := range g.Members {
+		group.MemberIDs = append(group.MemberIDs, m.Value)
+	}
+	return group, nil
+}
+
+func decodeSCIMPatch(w http.ResponseWriter, r *http.Request) ([]scimPatchOp, error) {
+	var req scimPatchRequest
+	if err := decodeSCIMBody(w, r, &req); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(req.Schemas) > 0 && !slices.Contains(req.Schemas, scimPatchOpSchema):
+		return nil, invalidSCIM("invalidSyntax", "schemas must include %s", scimPatchOpSchema)
+	case len(req.Operations) == 0:
+		return nil, invalidSCIM("invalidValue", "Operations is required")
+	case len(req.Operations) > scimMaxPatchOps:
+		return nil, invalidSCIM("tooMany", "at most %d Operations are allowed", scimMaxPatchOps)
+	}
+	return req.Operations, nil
+}
+
+// toMemberPatch converts PatchOp operations on a User. Operations on the
+// whole resource carry an object of attributes; "remove" clears
+// externalId or displayName.
+func toMemberPatch(ops []scimPatchOp) (app.MemberPatch, error) {
+	var patch app.MemberPatch
+	for _, op := range ops {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path == "" {
+				var attrs map[string]json.RawMessage
+				if err := json.Unmarshal(op.Value, &attrs); err != nil {
+					return patch, invalidSCIM("invalidValue", "%s without a path needs an object value", op.Op)
+				}
+				for path, value := range attrs {
+					if err := setMemberAttr(&patch, path, value); err != nil {
+						return patch, err
+					}
+				}
+				continue
+			}
+			if err := setMemberAttr(&patch, op.Path, op.Value); err != nil {
+				return patch, err
+			}
+		case "remove":
+			empty := ""
+			switch strings.ToLower(op.Path) {
+			case "externalid":
+				patch.ExternalID = &empty
+			case "displayname":
+				patch.DisplayName = &empty
+			default:
+				return patch, invalidSCIM("noTarget", "%q cannot be removed", op.Path)
+			}
+		default:
+			return patch, invalidSCIM("invalidValue", "unsupported op %q", op.Op)
+		}
+	}
+	return patch, nil
+}
+
+// setMemberAttr sets one User attribute from a PatchOp value.
+func setMemberAttr(patch *app.MemberPatch, path string, value json.RawMessage) error {
+	switch strings.ToLower(path) {
+	case "active":
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		patch.Active = &active
+		return nil
+	case "username":
+		return scimString(value, path, &patch.UserName)
+	case "externalid":
+		return scimString(value, path, &patch.ExternalID)
+	case "displayname", "name.formatted":
+		return scimString(value, path, &patch.DisplayName)
+	}
+	return invalidSCIM("invalidPath", "unsupported path %q", path)
+}
+
+// scimGroupMemberFilter matches the members[value eq "id"] path IdPs use
+// to remove one member.
+var scimGroupMemberFilter = regexp.MustCompile(`(?i)^members\[value\s+eq\s+"([^"]*)"\]$`)
+
+// toGroupPatch converts PatchOp operations on a Group.
+func toGroupPatch(ops []scimPatchOp) (app.GroupPatch, error) {
+	var patch app.GroupPatch
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		path := strings.ToLower(op.Path)
+		if m := scimGroupMemberFilter.FindStringSubmatch(op.Path); m != nil && opName == "remove" {
+			patch.RemoveMembers = append(patch.RemoveMembers, m[1])
+			continue
+		}
+		switch {
+		case opName == "add" && path == "members":
+			ids, err := scimMemberValues(op.Value)
+			if err != nil {
+				return patch, err
+			}
+			patch.AddMembers = append(patch.AddMembers, ids...)
+		case opName == "remove" && path == "members":
+			if len(op.Value) == 0 {
+				patch.Members = &[]string{}
+				continue
+			}
+			ids, err := scimMemberValues(op.Value)
+			if err != nil {
+				return patch, err
+			}
+			patch.RemoveMembers = append(patch.RemoveMembers, ids...)
+		case (opName == "add" || opName == "replace") && path == "":
+			var g scimGroup
+			if err := json.Unmarshal(op.Value, &g); err != nil {
+				return patch, invalidSCIM("invalidValue", "%s without a path needs an object value", op.Op)
+			}
+			if g.DisplayName != "" {
+				patch.DisplayName = &g.DisplayName
+			}
+			if g.ExternalID != "" {
+				patch.ExternalID = &g.ExternalID
+			}
+			for _, m := range g.Members {
+				patch.AddMembers = append(patch.AddMembers, m.Value)
+			}
+			if opName == "replace" && g.Members != nil {
+				patch.Members, patch.AddMembers = &patch.AddMembers, nil
+			}
+		case opName == "replace" && path == "members":
+			ids, err := scimMemberValues(op.Value)
+			if err != nil {
+				return patch, err
+			}
+			patch.Members = &ids
+		case (opName == "add" || opName == "replace") && path == "displayname":
+			if err := scimString(op.Value, op.Path, &patch.DisplayName); err != nil {
+				return patch, err
+			}
+		case (opName == "add" || opName == "replace") && path == "externalid":
+			if err := scimString(op.Value, op.Path, &patch.ExternalID); err != nil {
+				return patch, err
+			}
+		case opName == "add" || opName == "replace" || opName == "remove":
+			return patch, invalidSCIM("invalidPath", "unsupported path %q", op.Path)
+		default:
+			return patch, invalidSCIM("invalidValue", "unsupported op %q", op.Op)
+		}
+	}
+	return patch, nil
+}
+
+func scimMemberValues(value json.RawMessage) ([]string, error) {
+	var members []scimGroupMember
+	if err := json.Unmarshal(value, &members); err != nil {
+		return nil, invalidSCIM("invalidValue", "members must be a list of {\"value\"}")
+	}
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+	return ids, nil
+}
+
+func scimString(value json.RawMessage, path string, dst **string) error {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return invalidSCIM("invalidValue", "%s must be a string", path)
+	}
+	*dst = &s
+	return nil
+}
+
+// scimBool reads a boolean, accepting the "True"/"False" strings some
+// IdPs send.
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, invalidSCIM("invalidValue", "active must be a boolean")
+}
+
+// scimFilterExpr matches the one filter form supported: attr eq "value".
+var scimFilterExpr = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseSCIMFilter parses an `attr eq "value"` filter.
+func parseSCIMFilter(filter string) (attr, value string, err error) {
+	m := scimFilterExpr.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", invalidSCIM("invalidFilter", `only filters of the form attribute eq "value" are supported`)
+	}
+	if value, err = strconv.Unquote(m[2]); err != nil {
+		return "", "", invalidSCIM("invalidFilter", "filter value is not a valid string")
+	}
+	return m[1], value, nil
+}
+
+// scimPaging reads startIndex (1-based, default 1) and count. An absent
+// count is passed on as negative so the service picks its page size; a
+// negative count is read as zero, per RFC 7644 section 3.4.2.4.
+func scimPaging(r *http.Request) (startIndex, count int, err error) {
+	q := r.URL.Query()
+	startIndex, count = scimDefaultStartIdx, scimUnspecifiedCount
+	if v := q.Get("startIndex"); v != "" {
+		if startIndex, err = strconv.Atoi(v); err != nil {
+			return 0, 0, invalidSCIM("invalidValue", "startIndex must be an integer")
+		}
+		startIndex = max(startIndex, 1)
+	}
+	if v := q.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil {
+			return 0, 0, invalidSCIM("invalidValue", "count must be an integer")
+		}
+		count = max(count, 0)
+	}
+	return startIndex, count, nil
+}
+
+func toSCIMUser(m domain.WorkspaceMember) scimUser {
+	active := m.Active
+	return scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          m.UserID,
+		ExternalID:  m.ExternalID,
+		UserName:    m.UserName,
+		DisplayName: m.DisplayName,
+		Active:      &active,
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      domain.NewTimestampMS(m.CreatedAt).String(),
+			LastModified: domain.NewTimestampMS(m.UpdatedAt).String(),
+		},
+	}
+}
+
+func toSCIMGroup(g domain.WorkspaceGroup) scimGroup {
+	members := make([]scimGroupMember, 0, len(g.MemberIDs))
+	for _, id := range g.MemberIDs {
+		members = append(members, scimGroupMember{Value: id})
+	}
+	return scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          g.GroupID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Members:     members,
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Created:      domain.NewTimestampMS(g.CreatedAt).String(),
+			LastModified: domain.NewTimestampMS(g.UpdatedAt).String(),
+			Version:      fmt.Sprintf(`W/"%d"`, g.Version),
+		},
+	}
+}
+
+func writeSCIM(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeSCIMError maps an error to a SCIM error response. Unexpected
+// errors are logged and answered without detail.
+func writeSCIMError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		status   int
+		scimType string
+		detail   string
+		reqErr   *scimRequestError
+		resErr   *domain.ResidencyError
+	)
+	switch {
+	case errors.As(err, &reqErr):
+		status, scimType, detail = http.StatusBadRequest, reqErr.scimType, reqErr.detail
+	case errors.As(err, &resErr):
+		status, detail = http.StatusForbidden, resErr.Error()
+	case errors.Is(err, domain.ErrInvalidInput):
+		status, scimType, detail = http.StatusBadRequest, "invalidValue", err.Error()
+	case errors.Is(err, domain.ErrUnauthorized):
+		status, detail = http.StatusUnauthorized, "invalid SCIM token"
+	case errors.Is(err, domain.ErrForbidden):
+		status, detail = http.StatusForbidden, "forbidden"
+	case errors.Is(err, domain.ErrNotFound):
+		status, detail = http.StatusNotFound, "resource not found"
+	case errors.Is(err, domain.ErrAlreadyExists):
+		status, scimType, detail = http.StatusConflict, "uniqueness", "resource already exists"
+	case errors.Is(err, domain.ErrVersionConflict):
+		status, detail = http.StatusConflict, "resource changed concurrently; retry"
+	default:
+		observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "scim request failed",
+			"workspace_id", r.PathValue("workspace_id"), "error", err)
+		status, detail = http.StatusInternalServerError, "scim request failed"
+	}
+	writeSCIM(w, status, scimError{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+type scimTokenResponse struct {
+	WorkspaceID string `json:"workspace_id"`
+	Token       string `json:"token"`
+}
+
+// SCIMTokenAdminHandler issues a workspace's SCIM bearer token:
+//
+//	POST /admin/workspaces/scim-token?workspace_id=...
+//
+// The response holds the only copy of the token, to be entered in the
+// IdP's provisioning settings; a new token replaces the previous one. The
+// workspace must have an SSO connection, and from its first token on
+// only SCIM-provisioned members can sign in through it. Like the other
+// /admin endpoints it is authenticated by server.AdminAuth, and the
+// operator is named in AdminActorHeader for the audit log.
+func SCIMTokenAdminHandler(svc SCIMTokenService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		workspaceID := r.URL.Query().Get("workspace_id")
+		token, err := svc.RotateToken(r.Context(), r.Header.Get(AdminActorHeader), workspaceID)
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, "workspace has no SSO connection", http.StatusNotFound)
+			return
+		case errors.Is(err, domain.ErrForbidden):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		default:
+			observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "scim token rotation failed",
+				"workspace_id", workspaceID, "error", err)
+			http.Error(w, "scim token rotation failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(scimTokenResponse{WorkspaceID: workspaceID, Token: token})
+	})
+}
@@ -0,0 +1,454 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubSCIMService answers the requests a test sets a func for; the
+// embedded nil interface panics on any other.
+type stubSCIMService struct {
+	SCIMService
+	createUserFn  func(ctx context.Context, workspaceID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error)
+	listUsersFn   func(ctx context.Context, workspaceID string, filter app.MemberFilter, startIndex, count int) (app.MemberList, error)
+	patchUserFn   func(ctx context.Context, workspaceID, userID string, patch app.MemberPatch) (*domain.WorkspaceMember, error)
+	deleteUserFn  func(ctx context.Context, workspaceID, userID string) error
+	patchGroupFn  func(ctx context.Context, workspaceID, groupID string, patch app.GroupPatch) (*domain.WorkspaceGroup, error)
+	listGroupsFn  func(ctx context.Context, workspaceID string, filter app.GroupFilter, startIndex, count int) (app.GroupList, error)
+	rotateTokenFn func(ctx context.Context, actor, workspaceID string) (string, error)
+}
+
+func (s *stubSCIMService) Authenticate(_ context.Context, workspaceID, token string) error {
+	if workspaceID == "ws-eu" && token == "scim_good" {
+		return fmt.Errorf("scim authenticate: %w", domain.CheckResidency(workspaceID, domain.RegionEU, domain.RegionUS))
+	}
+	if workspaceID != "ws-001" || token != "scim_good" {
+		return fmt.Errorf("scim authenticate: %w", domain.ErrUnauthorized)
+	}
+	return nil
+}
+
+func (s *stubSCIMService) CreateUser(ctx context.Context, workspaceID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error) {
+	return s.createUserFn(ctx, workspaceID, member)
+}
+
+func (s *stubSCIMService) ListUsers(ctx context.Context, workspaceID string, filter app.MemberFilter, startIndex, count int) (app.MemberList, error) {
+	return s.listUsersFn(ctx, workspaceID, filter, startIndex, count)
+}
+
+func (s *stubSCIMService) PatchUser(ctx context.Context, workspaceID, userID string, patch app.MemberPatch) (*domain.WorkspaceMember, error) {
+	return s.patchUserFn(ctx, workspaceID, userID, patch)
+}
+
+func (s *stubSCIMService) DeleteUser(ctx context.Context, workspaceID, userID string) error {
+	return s.deleteUserFn(ctx, workspaceID, userID)
+}
+
+func (s *stubSCIMService) PatchGroup(ctx context.Context, workspaceID, groupID string, patch app.GroupPatch) (*domain.WorkspaceGroup, error) {
+	return s.patchGroupFn(ctx, workspaceID, groupID, patch)
+}
+
+func (s *stubSCIMService) ListGroups(ctx context.Context, workspaceID string, filter app.GroupFilter, startIndex, count int) (app.GroupList, error) {
+	return s.listGroupsFn(ctx, workspaceID, filter, startIndex, count)
+}
+
+func (s *stubSCIMService) RotateToken(ctx context.Context, actor, workspaceID string) (string, error) {
+	return s.rotateTokenFn(ctx, actor, workspaceID)
+}
+
+func scimRequest(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer scim_good")
+	req.Header.Set("Content-Type", scimContentType)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeSCIMError(t *testing.T, rec *httptest.ResponseRecorder) scimError {
+	t.Helper()
+	assert.Equal(t, scimContentType, rec.Header().Get("Content-Type"))
+	var body scimError
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, []string{scimErrorSchema}, body.Schemas)
+	return body
+}
+
+func TestSCIMHandler_Authentication(t *testing.T) {
+	handler := SCIMHandler(&stubSCIMService{})
+
+	for name, header := range map[string]string{
+		"missing":         "",
+		"not bearer":      "Basic c2NpbTpnb29k",
+		"wrong token":     "Bearer scim_bad",
+		"other workspace": "Bearer scim_good",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := "/scim/v2/workspaces/ws-001/Users"
+			if name == "other workspace" {
+				path = "/scim/v2/workspaces/ws-002/Users"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, "401", decodeSCIMError(t, rec).Status)
+		})
+	}
+}
+
+func TestSCIMHandler_WrongRegion(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/scim/v2/workspaces/ws-eu/Users", nil)
+	req.Header.Set("Authorization", "Bearer scim_good")
+	rec := httptest.NewRecorder()
+	SCIMHandler(&stubSCIMService{}).ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, decodeSCIMError(t, rec).Detail, "use the eu endpoint")
+}
+
+func TestSCIMHandler_CreateUser(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	handler := SCIMHandler(&stubSCIMService{
+		createUserFn: func(_ context.Context, workspaceID string, member domain.WorkspaceMember) (*domain.WorkspaceMember, error) {
+			if member.UserName == "taken@example.com" {
+				return nil, fmt.Errorf("scim create user: %w", domain.ErrAlreadyExists)
+			}
+			assert.Equal(t, "ws-001", workspaceID)
+			member.WorkspaceID, member.UserID = workspaceID, "user-001"
+			member.CreatedAt, member.UpdatedAt = at, at
+			return &member, nil
+		},
+	})
+
+	t.Run("created", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodPost, "/scim/v2/workspaces/ws-001/Users", `{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "ada@example.com",
+			"externalId": "00u1",
+			"name": {"givenName": "Ada", "familyName": "Lovelace"}
+		}`)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var user scimUser
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.Equal(t, "user-001", user.ID)
+		assert.Equal(t, "Ada Lovelace", user.DisplayName)
+		assert.Equal(t, "00u1", user.ExternalID)
+		require.NotNil(t, user.Active)
+		assert.True(t, *user.Active, "active defaults to true")
+		assert.Equal(t, "User", user.Meta.ResourceType)
+		assert.Equal(t, "2026-10-15T12:00:00.000Z", user.Meta.Created)
+	})
+
+	t.Run("duplicate userName is a uniqueness conflict", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodPost, "/scim/v2/workspaces/ws-001/Users", `{"userName":"taken@example.com"}`)
+
+		require.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "uniqueness", decodeSCIMError(t, rec).ScimType)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodPost, "/scim/v2/workspaces/ws-001/Users", `{"userName":`)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalidSyntax", decodeSCIMError(t, rec).ScimType)
+	})
+}
+
+func TestSCIMHandler_ListUsers(t *testing.T) {
+	handler := SCIMHandler(&stubSCIMService{
+		listUsersFn: func(_ context.Context, _ string, filter app.MemberFilter, startIndex, count int) (app.MemberList, error) {
+			if filter.ExternalID == "boom" {
+				return app.MemberList{}, errors.New("throttled")
+			}
+			assert.Equal(t, "Ada@Example.com", filter.UserName)
+			assert.Equal(t, 1, startIndex)
+			assert.Equal(t, -1, count, "absent count leaves the page size to the service")
+			return app.MemberList{
+				Members: []domain.WorkspaceMember{{UserID: "user-001", UserName: "ada@example.com", Active: true}},
+				Total:   1,
+			}, nil
+		},
+	})
+
+	t.Run("userName filter", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodGet,
+			`/scim/v2/workspaces/ws-001/Users?filter=userName+eq+%22Ada@Example.com%22`, "")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list struct {
+			Schemas      []string   `json:"schemas"`
+			TotalResults int        `json:"totalResults"`
+			ItemsPerPage int        `json:"itemsPerPage"`
+			Resources    []scimUser `json:"Resources"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+		assert.Equal(t, []string{scimListSchema}, list.Schemas)
+		assert.Equal(t, 1, list.TotalResults)
+		require.Len(t, list.Resources, 1)
+		assert.Equal(t, "user-001", list.Resources[0].ID)
+	})
+
+	t.Run("unsupported filters", func(t *testing.T) {
+		for _, filter := range []string{
+			`displayName eq "Ada"`,
+			`userName co "ada"`,
+			`userName eq "a" and active eq true`,
+		} {
+			rec := scimRequest(t, handler, http.MethodGet,
+				"/scim/v2/workspaces/ws-001/Users?filter="+strings.ReplaceAll(filter, " ", "+"), "")
+
+			require.Equal(t, http.StatusBadRequest, rec.Code, filter)
+			assert.Equal(t, "invalidFilter", decodeSCIMError(t, rec).ScimType, filter)
+		}
+	})
+
+	t.Run("storage failure hides detail", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodGet,
+			`/scim/v2/workspaces/ws-001/Users?filter=externalId+eq+%22boom%22`, "")
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, decodeSCIMError(t, rec).Detail, "throttled")
+	})
+}
+
+func TestSCIMHandler_PatchUser(t *testing.T) {
+	var got app.MemberPatch
+	handler := SCIMHandler(&stubSCIMService{
+		patchUserFn: func(_ context.Context, _, userID string, patch app.MemberPatch) (*domain.WorkspaceMember, error) {
+			if userID == "user-404" {
+				return nil, fmt.Errorf("scim patch user: %w", domain.ErrNotFound)
+			}
+			got = patch
+			return &domain.WorkspaceMember{UserID: userID, UserName: "ada@example.com"}, nil
+		},
+	})
+
+	tests := []struct {
+		name string
+		ops  string
+		want func(t *testing.T, p app.MemberPatch)
+	}{
+		{
+			name: "deactivate by path",
+			ops:  `[{"op":"replace","path":"active","value":false}]`,
+			want: func(t *testing.T, p app.MemberPatch) {
+				require.NotNil(t, p.Active)
+				assert.False(t, *p.Active)
+			},
+		},
+		{
+			name: "deactivate without path, string boolean",
+			ops:  `[{"op":"Replace","value":{"active":"False","displayName":"Ada L"}}]`,
+			want: func(t *testing.T, p app.MemberPatch) {
+				require.NotNil(t, p.Active)
+				assert.False(t, *p.Active)
+				assert.Equal(t, "Ada L", *p.DisplayName)
+			},
+		},
+		{
+			name: "rename and clear externalId",
+			ops:  `[{"op":"replace","path":"userName","value":"ada@new.example.com"},{"op":"remove","path":"externalId"}]`,
+			want: func(t *testing.T, p app.MemberPatch) {
+				assert.Equal(t, "ada@new.example.com", *p.UserName)
+				assert.Empty(t, *p.ExternalID)
+				assert.Nil(t, p.Active)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = app.MemberPatch{}
+			rec := scimRequest(t, handler, http.MethodPatch, "/scim/v2/workspaces/ws-001/Users/user-001",
+				`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":`+tt.ops+`}`)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			tt.want(t, got)
+		})
+	}
+
+	t.Run("unsupported path", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodPatch, "/scim/v2/workspaces/ws-001/Users/user-001",
+			`{"Operations":[{"op":"replace","path":"emails[type eq \"work\"].value","value":"a@b.c"}]}`)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalidPath", decodeSCIMError(t, rec).ScimType)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodPatch, "/scim/v2/workspaces/ws-001/Users/user-404",
+			`{"Operations":[{"op":"replace","path":"active","value":false}]}`)
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "404", decodeSCIMError(t, rec).Status)
+	})
+}
+
+func TestSCIMHandler_DeleteUser(t *testing.T) {
+	var deleted string
+	handler := SCIMHandler(&stubSCIMService{
+		deleteUserFn: func(_ context.Context, _, userID string) error {
+			deleted = userID
+			return nil
+		},
+	})
+
+	rec := scimRequest(t, handler, http.MethodDelete, "/scim/v2/workspaces/ws-001/Users/user-001", "")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "user-001", deleted)
+}
+
+func TestSCIMHandler_PatchGroup(t *testing.T) {
+	var got app.GroupPatch
+	handler := SCIMHandler(&stubSCIMService{
+		patchGroupFn: func(_ context.Context, _, groupID string, patch app.GroupPatch) (*domain.WorkspaceGroup, error) {
+			got = patch
+			return &domain.WorkspaceGroup{GroupID: groupID, DisplayName: "Eng", MemberIDs: []string{"user-001"}, Version: 4}, nil
+		},
+	})
+
+	eng := "Eng"
+	tests := []struct {
+		name string
+		ops  string
+		want app.GroupPatch
+	}{
+		{
+			name: "add and remove members",
+			ops: `[{"op":"add","path":"members","value":[{"value":"user-001"},{"value":"user-002"}]},
+				{"op":"remove","path":"members[value eq \"user-003\"]"}]`,
+			want: app.GroupPatch{AddMembers: []string{"user-001", "user-002"}, RemoveMembers: []string{"user-003"}},
+		},
+		{
+			name: "remove all members",
+			ops:  `[{"op":"remove","path":"members"}]`,
+			want: app.GroupPatch{Members: &[]string{}},
+		},
+		{
+			name: "replace members",
+			ops:  `[{"op":"replace","path":"members","value":[{"value":"user-001"}]}]`,
+			want: app.GroupPatch{Members: &[]string{"user-001"}},
+		},
+		{
+			name: "rename without path",
+			ops:  `[{"op":"replace","value":{"id":"group-001","displayName":"Eng"}}]`,
+			want: app.GroupPatch{DisplayName: &eng},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = app.GroupPatch{}
+			rec := scimRequest(t, handler, http.MethodPatch, "/scim/v2/workspaces/ws-001/Groups/group-001",
+				`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":`+tt.ops+`}`)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.want, got)
+
+			var group scimGroup
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&group))
+			assert.Equal(t, []scimGroupMember{{Value: "user-001"}}, group.Members)
+			assert.Equal(t, `W/"4"`, group.Meta.Version)
+		})
+	}
+
+	t.Run("wrong message schema", func(t *testing.T) {
+		rec := scimRequest(t, handler, http.MethodPatch, "/scim/v2/workspaces/ws-001/Groups/group-001",
+			`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"Operations":[{"op":"remove","path":"members"}]}`)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalidSyntax", decodeSCIMError(t, rec).ScimType)
+	})
+}
+
+func TestSCIMHandler_ListGroups_Paging(t *testing.T) {
+	handler := SCIMHandler(&stubSCIMService{
+		listGroupsFn: func(_ context.Context, _ string, filter app.GroupFilter, startIndex, count int) (app.GroupList, error) {
+			assert.Equal(t, "Eng", filter.DisplayName)
+			assert.Equal(t, 3, startIndex)
+			assert.Equal(t, 0, count, "a negative count reads as zero")
+			return app.GroupList{Total: 7}, nil
+		},
+	})
+
+	rec := scimRequest(t, handler, http.MethodGet,
+		`/scim/v2/workspaces/ws-001/Groups?filter=displayName+eq+%22Eng%22&startIndex=3&count=-5`, "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list scimListResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	assert.Equal(t, 7, list.TotalResults)
+	assert.Equal(t, 3, list.StartIndex)
+	assert.Empty(t, list.Resources)
+}
+
+func TestSCIMTokenAdminHandler(t *testing.T) {
+	handler := SCIMTokenAdminHandler(&stubSCIMService{
+		rotateTokenFn: func(_ context.Context, actor, workspaceID string) (string, error) {
+			switch workspaceID {
+			case "":
+				return "", fmt.Errorf("rotate scim token: %w", domain.NewValidationError("workspace_id", "is required"))
+			case "ws-404":
+				return "", fmt.Errorf("rotate scim token: %w", domain.ErrNotFound)
+			case "ws-eu":
+				return "", fmt.Errorf("rotate scim token: %w", domain.CheckResidency(workspaceID, domain.RegionEU, domain.RegionUS))
+			}
+			assert.Equal(t, "alice", actor)
+			return "scim_new", nil
+		},
+	})
+
+	post := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/workspaces/scim-token"+query, nil)
+		req.Header.Set(AdminActorHeader, "alice")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("issued", func(t *testing.T) {
+		rec := post("?workspace_id=ws-001")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		var resp scimTokenResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, scimTokenResponse{WorkspaceID: "ws-001", Token: "scim_new"}, resp)
+	})
+
+	t.Run("missing workspace", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("").Code)
+	})
+
+	t.Run("no SSO connection", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, post("?workspace_id=ws-404").Code)
+	})
+
+	t.Run("workspace in another region", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, post("?workspace_id=ws-eu").Code)
+	})
+
+	t.Run("GET is not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/workspaces/scim-token", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -0,0 +1,198 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// maxSSORequestBytes bounds an SSO login or connection request body. ID
+// tokens are a few kilobytes.
+const maxSSORequestBytes = 64 << 10
+
+// SSOLoginService is the subset of app.AuthService the SSO login endpoint
+// needs.
+type SSOLoginService interface {
+	SSOLogin(ctx context.Context, workspaceID, idToken, nonce, deviceID, clientIP string) (*app.VerifyOTPResult, error)
+}
+
+// SSOConnectionService is the subset of app.AuthService the SSO admin
+// endpoint needs.
+type SSOConnectionService interface {
+	SSOConnection(ctx context.Context, workspaceID string) (domain.SSOConnection, error)
+	SetSSOConnection(ctx context.Context, conn domain.SSOConnection) error
+}
+
+type ssoLoginRequest struct {
+	WorkspaceID string `json:"workspaceId"`
+	IDToken     string `json:"idToken"`
+	Nonce       string `json:"nonce"`
+	DeviceID    string `json:"deviceId"`
+}
+
+type ssoUser struct {
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// ssoLoginResponse mirrors the VerifyOTP response, minus the phone number
+// SSO users do not have.
+type ssoLoginResponse struct {
+	User                 ssoUser `json:"user"`
+	SessionID            string  `json:"sessionId"`
+	AccessToken          string  `json:"accessToken"`
+	RefreshToken         string  `json:"refreshToken"`
+	IsNewUser            bool    `json:"isNewUser"`
+	AccessTokenExpiresAt string  `json:"accessTokenExpiresAt"`
+}
+
+// SSOLoginHandler signs a workspace member in with an ID token from the
+// workspace's identity provider:
+//
+//	POST /v1/auth/sso {"workspaceId", "idToken", "nonce", "deviceId"}
+//
+// The client runs the authorization code flow with the IdP itself and
+// sends the resulting ID token with the nonce it generated. Tokens are
+// refreshed and revoked through the usual auth endpoints. Errors are
+// application/problem+json like the rest of /v1. clientIPs decides which
+// X-Forwarded-For hops to believe; nil trusts none.
+func SSOLoginHandler(svc SSOLoginService, clientIPs *domain.ClientIPResolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ssoLoginRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSSORequestBytes)).Decode(&req); err != nil {
+			errmap.WriteProblem(w, r, domain.NewValidationError("body", "must be a JSON SSO login request"))
+			return
+		}
+
+		result, err := svc.SSOLogin(r.Context(), req.WorkspaceID, req.IDToken, req.Nonce, req.DeviceID, clientIPs.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For")))
+		if err != nil {
+			errmap.WriteProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(ssoLoginResponse{
+			User: ssoUser{
+				UserID:      result.User.UserID,
+				DisplayName: result.User.DisplayName,
+				CreatedAt:   result.User.CreatedAt.String(),
+			},
+			SessionID:            result.SessionID,
+			AccessToken:          result.AccessToken,
+			RefreshToken:         result.RefreshToken,
+			IsNewUser:            result.IsNewUser,
+			AccessTokenExpiresAt: result.AccessTokenExpiry.UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+type ssoConnectionBody struct {
+	WorkspaceID          string   `json:"workspace_id"`
+	Issuer               string   `json:"issuer"`
+	ClientID             string   `json:"client_id"`
+	AllowedDomains       []string `json:"allowed_domains,omitempty"`
+	MaxSessionTTLSeconds int64    `json:"max_session_ttl_seconds,omitempty"`
+	DataRegion           string   `json:"data_region,omitempty"`
+	UpdatedAt            string   `json:"updated_at,omitempty"`
+}
+
+// SSOConnectionAdminHandler reads and replaces a workspace's identity
+// provider connection:
+//
+//	GET /admin/workspaces/sso?workspace_id=...
+//	PUT /admin/workspaces/sso {"workspace_id", "issuer", "client_id", "allowed_domains", "max_session_ttl_seconds", "data_region"}
+//
+// issuer is the IdP's OpenID Connect issuer; its discovery document and
+// keys are fetched on the first login. max_session_ttl_seconds caps every
+// session the IdP starts, zero leaving the service default. data_region
+// (eu or us) is fixed once set and defaults to the deployment's region;
+// connections of another region are refused with 403. Like the other
+// /admin endpoints it is authenticated by server.AdminAuth.
+func SSOConnectionAdminHandler(svc SSOConnectionService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			workspaceID := r.URL.Query().Get("workspace_id")
+			if workspaceID == "" {
+				http.Error(w, "workspace_id is required", http.StatusBadRequest)
+				return
+			}
+			conn, err := svc.SSOConnection(r.Context(), workspaceID)
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, "workspace has no SSO connection", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "sso connection lookup failed",
+					"workspace_id", workspaceID, "error", err)
+				http.Error(w, "sso connection lookup failed", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ssoConnectionBody{
+				WorkspaceID:          conn.WorkspaceID,
+				Issuer:               conn.Issuer,
+				ClientID:             conn.ClientID,
+				AllowedDomains:       conn.AllowedDomains,
+				MaxSessionTTLSeconds: int64(conn.MaxSessionTTL / time.Second),
+				DataRegion:           string(conn.DataRegion),
+				UpdatedAt:            domain.NewTimestampMS(conn.UpdatedAt).String(),
+			})
+
+		case http.MethodPut:
+			var body ssoConnectionBody
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSSORequestBytes)).Decode(&body); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			var region domain.DataRegion
+			if body.DataRegion != "" {
+				parsed, err := domain.ParseDataRegion(body.DataRegion)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				region = parsed
+			}
+			err := svc.SetSSOConnection(r.Context(), domain.SSOConnection{
+				WorkspaceID:    body.WorkspaceID,
+				Issuer:         body.Issuer,
+				ClientID:       body.ClientID,
+				AllowedDomains: body.AllowedDomains,
+				MaxSessionTTL:  time.Duration(body.MaxSessionTTLSeconds) * time.Second,
+				DataRegion:     region,
+			})
+			switch {
+			case err == nil:
+				w.WriteHeader(http.StatusNoContent)
+			case errors.Is(err, domain.ErrInvalidInput):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, domain.ErrForbidden):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "sso connection update failed",
+					"workspace_id", body.WorkspaceID, "error", err)
+				http.Error(w, "sso connection update failed", http.StatusInternalServerError)
+			}
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type ssoLoginFunc func(ctx context.Context, workspaceID, idToken, nonce, deviceID, clientIP string) (*app.VerifyOTPResult, error)
+
+func (f ssoLoginFunc) SSOLogin(ctx context.Context, workspaceID, idToken, nonce, deviceID, clientIP string) (*app.VerifyOTPResult, error) {
+	return f(ctx, workspaceID, idToken, nonce, deviceID, clientIP)
+}
+
+func TestSSOLoginHandler(t *testing.T) {
+	expiry := time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)
+	// Trusts httptest's default peer and the load balancer hop.
+	proxies, err := domain.NewClientIPResolver([]string{"192.0.2.0/24", "10.0.0.0/8"})
+	require.NoError(t, err)
+	handler := SSOLoginHandler(ssoLoginFunc(func(_ context.Context, workspaceID, idToken, nonce, deviceID, clientIP string) (*app.VerifyOTPResult, error) {
+		if idToken != "good-token" {
+			return nil, fmt.Errorf("sso login: %w", domain.ErrUnauthorized)
+		}
+		assert.Equal(t, "ws-001", workspaceID)
+		assert.Equal(t, "nonce-1", nonce)
+		assert.Equal(t, "device-1", deviceID)
+		assert.Equal(t, "203.0.113.7", clientIP)
+		return &app.VerifyOTPResult{
+			User:              app.UserRecord{UserID: "user-001", DisplayName: "Ada"},
+			SessionID:         "sess-001",
+			AccessToken:       "access",
+			RefreshToken:      "refresh",
+			IsNewUser:         true,
+			AccessTokenExpiry: expiry,
+		}, nil
+	}), proxies)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/sso", strings.NewReader(body))
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rec := post(`{"workspaceId":"ws-001","idToken":"good-token","nonce":"nonce-1","deviceId":"device-1"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+		var resp ssoLoginResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "user-001", resp.User.UserID)
+		assert.Equal(t, "refresh", resp.RefreshToken)
+		assert.True(t, resp.IsNewUser)
+		assert.Equal(t, "2026-10-15T13:00:00Z", resp.AccessTokenExpiresAt)
+	})
+
+	t.Run("rejected token is a 401 problem", func(t *testing.T) {
+		rec := post(`{"workspaceId":"ws-001","idToken":"forged","nonce":"nonce-1","deviceId":"device-1"}`)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "problem+json")
+	})
+
+	t.Run("malformed body is a 400", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+	})
+
+	t.Run("GET is not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/auth/sso", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+type stubSSOConnectionService struct {
+	conns  map[string]domain.SSOConnection
+	region domain.DataRegion
+	err    error
+}
+
+func (s *stubSSOConnectionService) SSOConnection(_ context.Context, workspaceID string) (domain.SSOConnection, error) {
+	if s.err != nil {
+		return domain.SSOConnection{}, s.err
+	}
+	conn, ok := s.conns[workspaceID]
+	if !ok {
+		return domain.SSOConnection{}, domain.ErrNotFound
+	}
+	return conn, nil
+}
+
+func (s *stubSSOConnectionService) SetSSOConnection(_ context.Context, conn domain.SSOConnection) error {
+	if err := conn.Validate(); err != nil {
+		return err
+	}
+	if err := domain.CheckResidency(conn.WorkspaceID, conn.DataRegion, s.region); err != nil {
+		return err
+	}
+	s.conns[conn.WorkspaceID] = conn
+	return nil
+}
+
+func TestSSOConnectionAdminHandler(t *testing.T) {
+	svc := &stubSSOConnectionService{conns: map[string]domain.SSOConnection{}}
+	handler := SSOConnectionAdminHandler(svc)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/workspaces/sso?workspace_id=ws-001", "").Code)
+
+	rec := do(http.MethodPut, "/admin/workspaces/sso",
+		`{"workspace_id":"ws-001","issuer":"https://idp.example.com","client_id":"messaging","max_session_ttl_seconds":28800}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 8*time.Hour, svc.conns["ws-001"].MaxSessionTTL)
+
+	rec = do(http.MethodGet, "/admin/workspaces/sso?workspace_id=ws-001", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body ssoConnectionBody
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "https://idp.example.com", body.Issuer)
+	assert.Equal(t, int64(28800), body.MaxSessionTTLSeconds)
+
+	rec = do(http.MethodPut, "/admin/workspaces/sso", `{"workspace_id":"ws-001","issuer":"http://idp.example.com","client_id":"messaging"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/workspaces/sso", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodDelete, "/admin/workspaces/sso", "").Code)
+
+	t.Run("data region", func(t *testing.T) {
+		svc.region = domain.RegionUS
+		defer func() { svc.region = "" }()
+
+		rec := do(http.MethodPut, "/admin/workspaces/sso",
+			`{"workspace_id":"ws-002","issuer":"https://idp.example.com","client_id":"messaging","data_region":"US"}`)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, domain.RegionUS, svc.conns["ws-002"].DataRegion)
+
+		rec = do(http.MethodGet, "/admin/workspaces/sso?workspace_id=ws-002", "")
+		assert.Contains(t, rec.Body.String(), `"data_region":"us"`)
+
+		rec = do(http.MethodPut, "/admin/workspaces/sso",
+			`{"workspace_id":"ws-003","issuer":"https://idp.example.com","client_id":"messaging","data_region":"apac"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = do(http.MethodPut, "/admin/workspaces/sso",
+			`{"workspace_id":"ws-003","issuer":"https://idp.example.com","client_id":"messaging","data_region":"eu"}`)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "use the eu endpoint")
+	})
+
+	svc.err = errors.New("throttled")
+	rec = do(http.MethodGet, "/admin/workspaces/sso?workspace_id=ws-001", "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "throttled")
+}
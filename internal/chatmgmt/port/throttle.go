@@ -0,0 +1,133 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+var throttledTotal metric.Int64Counter
+
+func init() {
+	throttledTotal, _ = otel.Meter("chatmgmt/port").Int64Counter("security_rate_limits_total",
+		metric.WithDescription("Total rate limit hits"))
+}
+
+// requestCounter is a narrow, consumer-defined interface for fixed-window
+// request counting. The *adapter.RateLimiter satisfies this.
+type requestCounter interface {
+	Hit(ctx context.Context, key string, windowSeconds int) (int, time.Duration, error)
+}
+
+// accessTokenValidator is a narrow, consumer-defined interface for access
+// token validation. The *auth.Validator satisfies this.
+type accessTokenValidator interface {
+	ValidateAccessToken(tokenString string) (*auth.Claims, error)
+}
+
+// ThrottleConfig holds the dependencies and limits for ThrottleMiddleware.
+type ThrottleConfig struct {
+	Limiter requestCounter
+	// Tokens identifies the caller for the per-user tier. Nil disables it.
+	Tokens accessTokenValidator
+	// ClientIPs decides which X-Forwarded-For hops to believe; nil trusts none.
+	ClientIPs *domain.ClientIPResolver
+	PerIP     int // Requests per client address per window; 0 disables the tier
+	PerUser   int // Requests per user per window; 0 disables the tier
+	Window    time.Duration
+}
+
+// throttleTier is one counter a request is charged against.
+type throttleTier struct {
+	limitType string
+	key       string
+	limit     int
+}
+
+// ThrottleMiddleware caps REST requests per client address and, for
+// requests carrying a valid access token, per user. Every response carries
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset for the tier
+// closest to its limit; a refused request gets 429 problem+json with
+// Retry-After. The limiter fails open: if Redis is unavailable the request
+// is served and a warning logged, so a Redis outage does not take the REST
+// surface down with it.
+func ThrottleMiddleware(cfg ThrottleConfig, next http.Handler) http.Handler {
+	window := max(int(cfg.Window/time.Second), 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var tiers []throttleTier
+		if cfg.PerIP > 0 {
+			clientIP := cfg.ClientIPs.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+			tiers = append(tiers, throttleTier{"ip", "http_req:ip:" + clientIP, cfg.PerIP})
+		}
+		if cfg.PerUser > 0 && cfg.Tokens != nil {
+			if userID := throttleUserID(cfg.Tokens, r); userID != "" {
+				tiers = append(tiers, throttleTier{"user", "http_req:user:" + userID, cfg.PerUser})
+			}
+		}
+
+		var (
+			closest   *throttleTier
+			remaining int
+			reset     time.Duration
+		)
+		for i := range tiers {
+			tier := &tiers[i]
+			count, ttl, err := cfg.Limiter.Hit(ctx, tier.key, window)
+			if err != nil {
+				observability.LoggerFromContext(ctx).Warn("request throttle unavailable, failing open",
+					"limit_type", tier.limitType, "error", err)
+				continue
+			}
+			left := tier.limit - count
+			if closest == nil || left < remaining {
+				closest, remaining, reset = tier, left, ttl
+			}
+		}
+		if closest == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		resetSeconds := strconv.Itoa(int((reset + time.Second - 1) / time.Second))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(closest.limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(max(remaining, 0)))
+		w.Header().Set("RateLimit-Reset", resetSeconds)
+		if remaining < 0 {
+			throttledTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("endpoint", "http"),
+				attribute.String("limit_type", closest.limitType),
+			))
+			errmap.WriteProblemRetryAfter(w, r, domain.ErrRateLimited, reset)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// throttleUserID returns the subject of the request's bearer token, or ""
+// when it carries none or the token does not validate. Invalid tokens are
+// left to the handler to reject; they are still counted per address.
+func throttleUserID(tokens accessTokenValidator, r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return ""
+	}
+	claims, err := tokens.ValidateAccessToken(token)
+	if err != nil {
+		return ""
+	}
+	return claims.Subject
+}
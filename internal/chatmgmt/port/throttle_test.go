@@ -0,0 +1,134 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// fakeCounter counts hits per key in memory with a fixed reset.
+type fakeCounter struct {
+	hits  map[string]int
+	reset time.Duration
+	err   error
+}
+
+func (c *fakeCounter) Hit(_ context.Context, key string, _ int) (int, time.Duration, error) {
+	if c.err != nil {
+		return 0, 0, c.err
+	}
+	c.hits[key]++
+	return c.hits[key], c.reset, nil
+}
+
+type tokenFunc func(string) (*auth.Claims, error)
+
+func (f tokenFunc) ValidateAccessToken(token string) (*auth.Claims, error) { return f(token) }
+
+func TestThrottleMiddleware(t *testing.T) {
+	tokens := tokenFunc(func(token string) (*auth.Claims, error) {
+		if token != "good" {
+			return nil, domain.ErrUnauthorized
+		}
+		return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user-001"}}, nil
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	newHandler := func(counter *fakeCounter, perIP, perUser int) http.Handler {
+		return ThrottleMiddleware(ThrottleConfig{
+			Limiter: counter,
+			Tokens:  tokens,
+			PerIP:   perIP,
+			PerUser: perUser,
+			Window:  time.Minute,
+		}, ok)
+	}
+	send := func(h http.Handler, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/devices", nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("under the limit sets rate limit headers", func(t *testing.T) {
+		h := newHandler(&fakeCounter{hits: map[string]int{}, reset: 42 * time.Second}, 3, 0)
+
+		rec := send(h, "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "42", rec.Header().Get("RateLimit-Reset"))
+	})
+
+	t.Run("over the ip limit is refused with retry-after", func(t *testing.T) {
+		counter := &fakeCounter{hits: map[string]int{}, reset: 42 * time.Second}
+		h := newHandler(counter, 1, 0)
+
+		send(h, "")
+		rec := send(h, "")
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "42", rec.Header().Get("Retry-After"))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, 2, counter.hits["http_req:ip:192.0.2.1"])
+	})
+
+	t.Run("valid token is counted per user", func(t *testing.T) {
+		counter := &fakeCounter{hits: map[string]int{}, reset: time.Minute}
+		h := newHandler(counter, 10, 1)
+
+		require.Equal(t, http.StatusOK, send(h, "good").Code)
+		rec := send(h, "good")
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, 2, counter.hits["http_req:user:user-001"])
+	})
+
+	t.Run("invalid token is counted per address only", func(t *testing.T) {
+		counter := &fakeCounter{hits: map[string]int{}, reset: time.Minute}
+		h := newHandler(counter, 10, 1)
+
+		send(h, "forged")
+		rec := send(h, "forged")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, map[string]int{"http_req:ip:192.0.2.1": 2}, counter.hits)
+	})
+
+	t.Run("limiter failure fails open", func(t *testing.T) {
+		h := newHandler(&fakeCounter{err: errors.New("redis down")}, 1, 1)
+
+		rec := send(h, "good")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("trusted proxy hop is resolved to the client", func(t *testing.T) {
+		proxies, err := domain.NewClientIPResolver([]string{"192.0.2.0/24"})
+		require.NoError(t, err)
+		counter := &fakeCounter{hits: map[string]int{}, reset: time.Minute}
+		h := ThrottleMiddleware(ThrottleConfig{Limiter: counter, ClientIPs: proxies, PerIP: 5, Window: time.Minute}, ok)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/devices", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, counter.hits["http_req:ip:203.0.113.7"])
+	})
+}
@@ -0,0 +1,162 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// AdminActorHeader names the operator making an admin request. The admin
+// token is shared, so the audit log relies on this header to say who ran
+// a query; user directory requests without it are rejected.
+const AdminActorHeader = "X-Admin-Actor"
+
+// UserDirectoryService is the app.UserDirectoryService the user directory
+// admin endpoints need.
+type UserDirectoryService interface {
+	ListUsers(ctx context.Context, actor string, filter app.UserFilter, cursor string, pageSize int) (app.UserPage, error)
+	FlagUser(ctx context.Context, actor, userID, reason string) error
+	UnflagUser(ctx context.Context, actor, userID string) error
+}
+
+// directoryUser is one user in an admin user listing.
+type directoryUser struct {
+	UserID      string `json:"user_id"`
+	PhoneNumber string `json:"phone_number"`
+	DisplayName string `json:"display_name,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	FlagReason  string `json:"flag_reason,omitempty"`
+	FlaggedAt   string `json:"flagged_at,omitempty"`
+}
+
+type userListResponse struct {
+	Users  []directoryUser `json:"users"`
+	Cursor string          `json:"cursor,omitempty"`
+}
+
+type userFlagRequest struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+// UserDirectoryAdminHandler lists users for support tooling:
+//
+//	GET /admin/users?created_from=&created_to=&country_code=&flagged=&cursor=&page_size=
+//
+// Every parameter is optional. created_from and created_to are RFC 3339
+// and bound a window of at most domain.UserListMaxWindow, defaulting to the
+// last domain.UserListDefaultWindow; country_code is a calling code such
+// as 44; flagged=true keeps flagged users. Users are listed newest first,
+// and the response cursor fetches the next page. Like the other /admin
+// endpoints it is authenticated by server.AdminAuth, and every request
+// must name its operator in AdminActorHeader for the audit log.
+func UserDirectoryAdminHandler(svc UserDirectoryService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		var (
+			filter app.UserFilter
+			err    error
+		)
+		if filter.CreatedFrom, err = domain.ParseTimestamp(q.Get("created_from")); err != nil {
+			http.Error(w, "created_from must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		if filter.CreatedTo, err = domain.ParseTimestamp(q.Get("created_to")); err != nil {
+			http.Error(w, "created_to must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		filter.CallingCode = q.Get("country_code")
+		if v := q.Get("flagged"); v != "" {
+			if filter.FlaggedOnly, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "flagged must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+		pageSize := 0
+		if v := q.Get("page_size"); v != "" {
+			if pageSize, err = strconv.Atoi(v); err != nil || pageSize < 0 {
+				http.Error(w, "page_size must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		page, err := svc.ListUsers(r.Context(), r.Header.Get(AdminActorHeader), filter, q.Get("cursor"), pageSize)
+		if err != nil {
+			writeDirectoryError(w, r, "list users failed", err)
+			return
+		}
+
+		resp := userListResponse{Users: make([]directoryUser, 0, len(page.Users)), Cursor: page.Cursor}
+		for _, u := range page.Users {
+			resp.Users = append(resp.Users, directoryUser{
+				UserID:      u.UserID,
+				PhoneNumber: u.PhoneNumber,
+				DisplayName: u.DisplayName,
+				CreatedAt:   u.CreatedAt.String(),
+				FlagReason:  u.FlagReason,
+				FlaggedAt:   u.FlaggedAt.String(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// UserFlagAdminHandler flags users for review by support:
+//
+//	POST   /admin/users/flag   {"user_id": "...", "reason": "..."}
+//	DELETE /admin/users/flag?user_id=...
+//
+// Flagged users are listed by GET /admin/users?flagged=true. It is
+// authenticated and audited like UserDirectoryAdminHandler.
+func UserFlagAdminHandler(svc UserDirectoryService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(AdminActorHeader)
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			var req userFlagRequest
+			if decodeErr := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); decodeErr != nil {
+				http.Error(w, "body must be {\"user_id\", \"reason\"}", http.StatusBadRequest)
+				return
+			}
+			err = svc.FlagUser(r.Context(), actor, req.UserID, req.Reason)
+		case http.MethodDelete:
+			err = svc.UnflagUser(r.Context(), actor, r.URL.Query().Get("user_id"))
+		default:
+			w.Header().Set("Allow", "POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			writeDirectoryError(w, r, "update user flag failed", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// writeDirectoryError maps a user directory error to a status code.
+func writeDirectoryError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	default:
+		observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), msg, "error", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
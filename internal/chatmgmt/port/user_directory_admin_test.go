@@ -0,0 +1,150 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type stubUserDirectoryService struct {
+	listFn   func(ctx context.Context, actor string, filter app.UserFilter, cursor string, pageSize int) (app.UserPage, error)
+	flagFn   func(ctx context.Context, actor, userID, reason string) error
+	unflagFn func(ctx context.Context, actor, userID string) error
+}
+
+func (s *stubUserDirectoryService) ListUsers(ctx context.Context, actor string, filter app.UserFilter, cursor string, pageSize int) (app.UserPage, error) {
+	return s.listFn(ctx, actor, filter, cursor, pageSize)
+}
+
+func (s *stubUserDirectoryService) FlagUser(ctx context.Context, actor, userID, reason string) error {
+	return s.flagFn(ctx, actor, userID, reason)
+}
+
+func (s *stubUserDirectoryService) UnflagUser(ctx context.Context, actor, userID string) error {
+	return s.unflagFn(ctx, actor, userID)
+}
+
+func TestUserDirectoryAdminHandler(t *testing.T) {
+	created := domain.NewTimestampMS(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	svc := &stubUserDirectoryService{
+		listFn: func(_ context.Context, actor string, filter app.UserFilter, cursor string, pageSize int) (app.UserPage, error) {
+			switch cursor {
+			case "broken":
+				return app.UserPage{}, errors.New("throttled")
+			case "bad":
+				return app.UserPage{}, fmt.Errorf("list users: %w", domain.NewValidationError("cursor", "is malformed"))
+			}
+			assert.Equal(t, "alice", actor)
+			assert.Equal(t, "2026-01-01T00:00:00.000Z", filter.CreatedFrom.String())
+			assert.True(t, filter.CreatedTo.IsZero())
+			assert.Equal(t, "44", filter.CallingCode)
+			assert.True(t, filter.FlaggedOnly)
+			assert.Equal(t, 25, pageSize)
+			return app.UserPage{
+				Users: []app.UserRecord{{UserID: "user-1", PhoneNumber: "+447700900123", CreatedAt: created,
+					FlagReason: "chargeback", FlaggedAt: created}},
+				Cursor: "next",
+			}, nil
+		},
+	}
+	handler := UserDirectoryAdminHandler(svc)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(AdminActorHeader, "alice")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("lists a filtered page", func(t *testing.T) {
+		rec := get("/admin/users?created_from=2026-01-01T00:00:00Z&country_code=44&flagged=true&page_size=25")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp userListResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		require.Len(t, resp.Users, 1)
+		assert.Equal(t, "user-1", resp.Users[0].UserID)
+		assert.Equal(t, "2026-03-01T12:00:00.000Z", resp.Users[0].CreatedAt)
+		assert.Equal(t, "chargeback", resp.Users[0].FlagReason)
+		assert.Equal(t, "next", resp.Cursor)
+	})
+
+	t.Run("rejects malformed parameters", func(t *testing.T) {
+		for _, target := range []string{
+			"/admin/users?created_from=yesterday",
+			"/admin/users?created_to=2026-13-01",
+			"/admin/users?flagged=maybe",
+			"/admin/users?page_size=-1",
+			"/admin/users?cursor=bad",
+		} {
+			assert.Equal(t, http.StatusBadRequest, get(target).Code, target)
+		}
+	})
+
+	t.Run("service failure", func(t *testing.T) {
+		rec := get("/admin/users?cursor=broken")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "throttled")
+	})
+
+	t.Run("GET only", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET", rec.Header().Get("Allow"))
+	})
+}
+
+func TestUserFlagAdminHandler(t *testing.T) {
+	var flagged, unflagged []string
+	svc := &stubUserDirectoryService{
+		flagFn: func(_ context.Context, actor, userID, reason string) error {
+			if userID == "missing" {
+				return fmt.Errorf("flag user: %w", domain.ErrNotFound)
+			}
+			flagged = append(flagged, actor+"/"+userID+"/"+reason)
+			return nil
+		},
+		unflagFn: func(_ context.Context, actor, userID string) error {
+			unflagged = append(unflagged, actor+"/"+userID)
+			return nil
+		},
+	}
+	handler := UserFlagAdminHandler(svc)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(AdminActorHeader, "alice")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/admin/users/flag", `{"user_id":"user-1","reason":"spam"}`).Code)
+	assert.Equal(t, []string{"alice/user-1/spam"}, flagged)
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/users/flag?user_id=user-1", "").Code)
+	assert.Equal(t, []string{"alice/user-1"}, unflagged)
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/users/flag", `{"user_id":"missing","reason":"spam"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/admin/users/flag", `not json`).Code)
+
+	rec := serve(http.MethodGet, "/admin/users/flag", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST, DELETE", rec.Header().Get("Allow"))
+}
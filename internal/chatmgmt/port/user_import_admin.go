@@ -0,0 +1,198 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// UserImportService is the subset of app.UserImportService the import
+// admin endpoint needs.
+type UserImportService interface {
+	Import(ctx context.Context, src app.ImportSource, dryRun bool, report func(app.ImportResult, app.ImportProgress)) (app.ImportProgress, error)
+}
+
+// ManifestOpener opens an import manifest stored in S3.
+type ManifestOpener interface {
+	Open(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// importRow is one line of an import manifest.
+type importRow struct {
+	ExternalID  string   `json:"external_id"`
+	PhoneNumber string   `json:"phone_number"`
+	DisplayName string   `json:"display_name,omitempty"`
+	ChatIDs     []string `json:"chat_ids,omitempty"`
+}
+
+// jsonLinesSource reads import rows from a stream of JSON objects, one
+// per line.
+type jsonLinesSource struct {
+	dec *json.Decoder
+}
+
+func newJSONLinesSource(r io.Reader) *jsonLinesSource {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return &jsonLinesSource{dec: dec}
+}
+
+func (s *jsonLinesSource) Next() (app.ImportRow, error) {
+	var row importRow
+	if err := s.dec.Decode(&row); err != nil {
+		if errors.Is(err, io.EOF) {
+			return app.ImportRow{}, io.EOF
+		}
+		return app.ImportRow{}, fmt.Errorf("decode manifest: %w", err)
+	}
+	return app.ImportRow{
+		ExternalID:  row.ExternalID,
+		PhoneNumber: row.PhoneNumber,
+		DisplayName: row.DisplayName,
+		ChatIDs:     row.ChatIDs,
+	}, nil
+}
+
+// importProgress is the counts in an import progress or done line.
+type importProgress struct {
+	Processed int `json:"processed"`
+	Created   int `json:"created"`
+	Existing  int `json:"existing"`
+	Conflicts int `json:"conflicts"`
+	Invalid   int `json:"invalid"`
+	Failed    int `json:"failed"`
+}
+
+func toImportProgress(p app.ImportProgress) *importProgress {
+	return &importProgress{
+		Processed: p.Processed,
+		Created:   p.Created,
+		Existing:  p.Existing,
+		Conflicts: p.Conflicts,
+		Invalid:   p.Invalid,
+		Failed:    p.Failed,
+	}
+}
+
+// importRowResult reports a row that was not imported.
+type importRowResult struct {
+	Row        int    `json:"row"`
+	ExternalID string `json:"external_id"`
+	UserID     string `json:"user_id,omitempty"`
+	Outcome    string `json:"outcome"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// importLine is one line of the streamed import response.
+type importLine struct {
+	Type     string           `json:"type"` // row, progress, done or error
+	DryRun   bool             `json:"dry_run"`
+	Result   *importRowResult `json:"result,omitempty"`
+	Progress *importProgress  `json:"progress,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// UserImportAdminHandler serves bulk user imports from a legacy system:
+//
+//	POST /admin/users/import[?dry_run=true]             manifest in the body
+//	POST /admin/users/import?manifest=s3://bucket/key[&dry_run=true]
+//
+// A manifest is JSON lines of {"external_id", "phone_number",
+// "display_name", "chat_ids"}. The response streams JSON lines as the
+// import runs: a "row" line for each row not created or already existing,
+// a "progress" line every domain.ImportProgressEvery rows, and a final
+// "done" line with the counts, or an "error" line if the import stopped
+// early. Re-running a manifest is safe, so a stopped import is finished by
+// running it again. A dry run writes nothing.
+//
+// opener may be nil, which disables S3 manifests. Like the other /admin
+// endpoints it is authenticated by server.AdminAuth.
+func UserImportAdminHandler(svc UserImportService, opener ManifestOpener) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+		logger := observability.LoggerFromContext(ctx)
+
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "dry_run must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var manifest io.Reader = r.Body
+		if uri := r.URL.Query().Get("manifest"); uri != "" {
+			if opener == nil {
+				http.Error(w, "S3 manifests are not enabled", http.StatusBadRequest)
+				return
+			}
+			body, err := opener.Open(ctx, uri)
+			switch {
+			case errors.Is(err, domain.ErrInvalidInput):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			case errors.Is(err, domain.ErrNotFound):
+				http.Error(w, "manifest not found", http.StatusNotFound)
+				return
+			case err != nil:
+				logger.ErrorContext(ctx, "open import manifest failed", "manifest", uri, "error", err)
+				http.Error(w, "open manifest failed", http.StatusBadGateway)
+				return
+			}
+			defer func() { _ = body.Close() }()
+			manifest = body
+		}
+
+		// Headers go out with the first line, so an import that cannot
+		// start still gets a status code.
+		rc := http.NewResponseController(w)
+		started := false
+		write := func(line importLine) {
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				started = true
+			}
+			line.DryRun = dryRun
+			_ = json.NewEncoder(w).Encode(line)
+			_ = rc.Flush()
+		}
+
+		progress, err := svc.Import(ctx, newJSONLinesSource(manifest), dryRun, func(res app.ImportResult, p app.ImportProgress) {
+			if res.Outcome != app.ImportCreated && res.Outcome != app.ImportExisting {
+				write(importLine{Type: "row", Result: &importRowResult{
+					Row:        res.Row,
+					ExternalID: res.ExternalID,
+					UserID:     res.UserID,
+					Outcome:    string(res.Outcome),
+					Reason:     res.Reason,
+				}})
+			}
+			if p.Processed%domain.ImportProgressEvery == 0 {
+				write(importLine{Type: "progress", Progress: toImportProgress(p)})
+			}
+		})
+		switch {
+		case err == nil:
+			write(importLine{Type: "done", Progress: toImportProgress(progress)})
+		case !started && errors.Is(err, domain.ErrUnavailable):
+			http.Error(w, "an import is already running", http.StatusServiceUnavailable)
+		default:
+			logger.ErrorContext(ctx, "user import stopped", "dry_run", dryRun, "processed", progress.Processed, "error", err)
+			write(importLine{Type: "error", Progress: toImportProgress(progress), Error: err.Error()})
+		}
+	})
+}
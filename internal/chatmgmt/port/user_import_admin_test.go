@@ -0,0 +1,181 @@
+package port
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/chatmgmt/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// stubImportService reads every row of the manifest and reports each as
+// created, except rows whose external ID starts with "bad".
+type stubImportService struct {
+	rows   []app.ImportRow
+	dryRun bool
+	err    error
+}
+
+func (s *stubImportService) Import(_ context.Context, src app.ImportSource, dryRun bool, report func(app.ImportResult, app.ImportProgress)) (app.ImportProgress, error) {
+	s.dryRun = dryRun
+	if errors.Is(s.err, domain.ErrUnavailable) {
+		return app.ImportProgress{}, s.err
+	}
+	var p app.ImportProgress
+	for {
+		row, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return p, nil
+		}
+		if err != nil {
+			return p, err
+		}
+		s.rows = append(s.rows, row)
+		res := app.ImportResult{Row: p.Processed + 1, ExternalID: row.ExternalID, Outcome: app.ImportCreated}
+		p.Processed++
+		if strings.HasPrefix(row.ExternalID, "bad") {
+			res.Outcome, res.Reason = app.ImportInvalid, "phone_number: invalid"
+			p.Invalid++
+		} else {
+			p.Created++
+		}
+		report(res, p)
+	}
+}
+
+type stubManifestOpener map[string]string
+
+func (o stubManifestOpener) Open(_ context.Context, uri string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(uri, "s3://") {
+		return nil, fmt.Errorf("%w: manifest must be an s3:// URI", domain.ErrInvalidInput)
+	}
+	body, ok := o[uri]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func decodeImportLines(t *testing.T, body io.Reader) []importLine {
+	t.Helper()
+	var lines []importLine
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		var line importLine
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestUserImportAdminHandler(t *testing.T) {
+	manifest := `{"external_id":"u1","phone_number":"+14155550101","display_name":"Ada","chat_ids":["c1"]}
+{"external_id":"bad-1","phone_number":"nope"}
+`
+
+	t.Run("streams rows and the final counts", func(t *testing.T) {
+		svc := &stubImportService{}
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(svc, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users/import", strings.NewReader(manifest)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		assert.Equal(t, []app.ImportRow{
+			{ExternalID: "u1", PhoneNumber: "+14155550101", DisplayName: "Ada", ChatIDs: []string{"c1"}},
+			{ExternalID: "bad-1", PhoneNumber: "nope"},
+		}, svc.rows)
+
+		lines := decodeImportLines(t, rec.Body)
+		require.Len(t, lines, 2)
+		assert.Equal(t, importLine{Type: "row", Result: &importRowResult{
+			Row: 2, ExternalID: "bad-1", Outcome: "invalid", Reason: "phone_number: invalid",
+		}}, lines[0])
+		assert.Equal(t, importLine{Type: "done", Progress: &importProgress{Processed: 2, Created: 1, Invalid: 1}}, lines[1])
+	})
+
+	t.Run("dry run from an S3 manifest", func(t *testing.T) {
+		svc := &stubImportService{}
+		opener := stubManifestOpener{"s3://imports/users.ndjson": manifest}
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(svc, opener).ServeHTTP(rec, httptest.NewRequest(http.MethodPost,
+			"/admin/users/import?dry_run=true&manifest=s3://imports/users.ndjson", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, svc.dryRun)
+		assert.Len(t, svc.rows, 2)
+		lines := decodeImportLines(t, rec.Body)
+		require.NotEmpty(t, lines)
+		assert.True(t, lines[len(lines)-1].DryRun)
+	})
+
+	t.Run("malformed manifest stops with an error line", func(t *testing.T) {
+		svc := &stubImportService{}
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(svc, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users/import",
+			strings.NewReader(`{"external_id":"u1","phone_number":"+14155550101"}`+"\n"+`{"external_id":`)))
+
+		lines := decodeImportLines(t, rec.Body)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "error", lines[0].Type)
+		assert.Contains(t, lines[0].Error, "decode manifest")
+		assert.Equal(t, 1, lines[0].Progress.Processed)
+	})
+
+	t.Run("unknown manifest fields are rejected", func(t *testing.T) {
+		svc := &stubImportService{}
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(svc, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users/import",
+			strings.NewReader(`{"external_id":"u1","phone":"+14155550101"}`)))
+
+		lines := decodeImportLines(t, rec.Body)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "error", lines[0].Type)
+		assert.Empty(t, svc.rows)
+	})
+
+	t.Run("manifest errors", func(t *testing.T) {
+		opener := stubManifestOpener{}
+		for query, want := range map[string]int{
+			"manifest=https://example.com/users.ndjson": http.StatusBadRequest,
+			"manifest=s3://imports/missing.ndjson":      http.StatusNotFound,
+			"dry_run=maybe":                             http.StatusBadRequest,
+		} {
+			rec := httptest.NewRecorder()
+			UserImportAdminHandler(&stubImportService{}, opener).ServeHTTP(rec,
+				httptest.NewRequest(http.MethodPost, "/admin/users/import?"+query, nil))
+			assert.Equal(t, want, rec.Code, query)
+		}
+
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(&stubImportService{}, nil).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/admin/users/import?manifest=s3://imports/users.ndjson", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "S3 manifests disabled")
+	})
+
+	t.Run("import already running", func(t *testing.T) {
+		svc := &stubImportService{err: fmt.Errorf("user import already running: %w", domain.ErrUnavailable)}
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(svc, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users/import", strings.NewReader(manifest)))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		UserImportAdminHandler(&stubImportService{}, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/users/import", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "POST", rec.Header().Get("Allow"))
+	})
+}
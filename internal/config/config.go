@@ -5,6 +5,9 @@ package config
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,14 +24,38 @@ type Config struct {
 	Environment string `koanf:"environment"`
 
 	// Logging configuration
-	LogLevel  string `koanf:"log_level"`
-	LogFormat string `koanf:"log_format"`
+	LogLevel  string        `koanf:"log_level"`
+	LogFormat string        `koanf:"log_format"`
+	Logging   LoggingConfig `koanf:"logging"`
 
 	// Service-specific configurations
 	Gateway  GatewayConfig  `koanf:"gateway"`
 	Ingest   IngestConfig   `koanf:"ingest"`
 	Fanout   FanoutConfig   `koanf:"fanout"`
 	ChatMgmt ChatMgmtConfig `koanf:"chatmgmt"`
+	Bridge   BridgeConfig   `koanf:"bridge"`
+	Canary   CanaryConfig   `koanf:"canary"`
+
+	// Token issuance configuration (ADR-015)
+	Auth AuthConfig `koanf:"auth"`
+
+	// AdminToken is the bearer token /admin endpoints require (ADMINTOKEN).
+	// Empty leaves them open in local development and refuses every
+	// admin request anywhere else.
+	AdminToken string `koanf:"admintoken"`
+
+	// Anonymized product analytics (internal/analytics)
+	Analytics AnalyticsConfig `koanf:"analytics"`
+
+	// Payment provider webhooks and entitlements
+	Billing BillingConfig `koanf:"billing"`
+
+	// Data residency: the region whose workspaces this deployment serves
+	Residency ResidencyConfig `koanf:"residency"`
+
+	// Message content encryption at rest (internal/msgcrypt)
+	Messages MessagesConfig `koanf:"messages"`
+	PII      PIIConfig      `koanf:"pii"`
 
 	// Infrastructure configurations
 	DynamoDB DynamoDBConfig `koanf:"dynamodb"`
@@ -38,29 +65,405 @@ type Config struct {
 
 	// OpenTelemetry configuration
 	OTEL OTELConfig `koanf:"otel"`
+
+	// HTTP listener protocols and timeouts, shared by every service
+	HTTP HTTPConfig `koanf:"http"`
+}
+
+// LoggingConfig holds runtime log controls. Levels can also be changed
+// while running through the /admin/loglevel endpoint.
+type LoggingConfig struct {
+	SampleRate int      `koanf:"samplerate"` // LOGGING_SAMPLERATE: records/s per message at info and below; 0 disables
+	Levels     []string `koanf:"levels"`     // LOGGING_LEVELS: subsystem=level overrides, e.g. gateway/app=debug
 }
 
 // GatewayConfig holds Gateway service configuration.
 type GatewayConfig struct {
-	HTTPPort int `koanf:"http_port"`
-	GRPCPort int `koanf:"grpc_port"`
+	HTTPPort  int             `koanf:"http_port"`
+	GRPCPort  int             `koanf:"grpc_port"`
+	Drain     DrainConfig     `koanf:"drain"`
+	Reconnect ReconnectConfig `koanf:"reconnect"`
+	Admission AdmissionConfig `koanf:"admission"`
+	Batch     BatchConfig     `koanf:"batch"`
+	Ack       AckConfig       `koanf:"ack"`
+	Quota     QuotaConfig     `koanf:"quota"`
+	Upload    UploadConfig    `koanf:"upload"`
+	Reader    ReaderConfig    `koanf:"reader"`
+	AuthCache AuthCacheConfig `koanf:"authcache"`
+	IP        IPConfig        `koanf:"ip"`
+	// IngestAddr is the Ingest gRPC address client messages are persisted
+	// through. GATEWAY_INGESTADDR.
+	IngestAddr string `koanf:"ingestaddr"`
+	// FanoutAddr is the Fanout gRPC address typing, read receipt and
+	// presence signals are delivered through. GATEWAY_FANOUTADDR.
+	FanoutAddr string `koanf:"fanoutaddr"`
+}
+
+// AdmissionConfig sets the load limits at which the Gateway refuses new
+// connections. Ephemeral frames are shed from ShedRatio of any limit. A zero
+// limit disables that signal.
+type AdmissionConfig struct {
+	MaxCPU        float64 `koanf:"maxcpu"`        // GATEWAY_ADMISSION_MAXCPU: fraction of GOMAXPROCS
+	MaxMemory     uint64  `koanf:"maxmemory"`     // GATEWAY_ADMISSION_MAXMEMORY: bytes
+	MaxGoroutines int     `koanf:"maxgoroutines"` // GATEWAY_ADMISSION_MAXGOROUTINES
+	ShedRatio     float64 `koanf:"shedratio"`     // GATEWAY_ADMISSION_SHEDRATIO: in (0, 1]
+}
+
+// BatchConfig bounds frame coalescing for clients that negotiate the batch
+// capability (ADR-005 §2).
+type BatchConfig struct {
+	MaxFrames int           `koanf:"maxframes"` // GATEWAY_BATCH_MAXFRAMES: 1 disables batching
+	MaxDelay  time.Duration `koanf:"maxdelay"`  // GATEWAY_BATCH_MAXDELAY
+}
+
+// AckConfig bounds the message frames a connection holds until the client
+// acks them, and how long a closed connection's unacked frames are kept for
+// resending when the device reconnects (ADR-005 §3.5).
+type AckConfig struct {
+	Window    int           `koanf:"window"`    // GATEWAY_ACK_WINDOW: unacked frames before the connection closes
+	Retention time.Duration `koanf:"retention"` // GATEWAY_ACK_RETENTION
+}
+
+// QuotaConfig caps concurrent connections across the fleet, counted in the
+// Redis connection registry (ADR-009 §3).
+type QuotaConfig struct {
+	PerUser   int `koanf:"peruser"`   // GATEWAY_QUOTA_PERUSER
+	PerDevice int `koanf:"perdevice"` // GATEWAY_QUOTA_PERDEVICE
+	PerIP     int `koanf:"perip"`     // GATEWAY_QUOTA_PERIP
+}
+
+// UploadConfig enables chunked attachment uploads over client connections
+// (ADR-005 §3.13).
+type UploadConfig struct {
+	Bucket string `koanf:"bucket"` // GATEWAY_UPLOAD_BUCKET: empty disables uploads
+}
+
+// ReaderConfig selects how the Gateway reads from client connections.
+// "goroutine" parks one goroutine per connection; "epoll" (Linux only) waits
+// on all sockets at once and reads with Workers goroutines, using far less
+// memory at very high connection counts.
+type ReaderConfig struct {
+	Mode    string `koanf:"mode"`    // GATEWAY_READER_MODE: goroutine or epoll
+	Workers int    `koanf:"workers"` // GATEWAY_READER_WORKERS: epoll workers; 0 is 4 per CPU
+}
+
+// AuthCacheConfig sizes the cache of validated access tokens.
+type AuthCacheConfig struct {
+	Entries int `koanf:"entries"` // GATEWAY_AUTHCACHE_ENTRIES: 0 disables the cache
+}
+
+// DrainConfig bounds how many Gateway pods drain connections at once during
+// a rolling deploy (ADR-014 §4.1).
+type DrainConfig struct {
+	Slots int           `koanf:"slots"` // GATEWAY_DRAIN_SLOTS: pods draining concurrently
+	Wait  time.Duration `koanf:"wait"`  // GATEWAY_DRAIN_WAIT: wait for a slot before draining anyway
 }
 
 // IngestConfig holds Ingest service configuration.
 type IngestConfig struct {
-	HTTPPort int `koanf:"http_port"`
-	GRPCPort int `koanf:"grpc_port"`
+	HTTPPort int          `koanf:"http_port"`
+	GRPCPort int          `koanf:"grpc_port"`
+	Shadow   ShadowConfig `koanf:"shadow"`
+
+	// MessageIDScheme selects how new message IDs are generated:
+	// "uuid" (default) or time-ordered "ulid". INGEST_MESSAGEIDSCHEME.
+	MessageIDScheme domain.MessageIDScheme `koanf:"messageidscheme"`
 }
 
+// ShadowConfig controls duplicating Ingest writes to a secondary persistence
+// path for comparison during storage migrations. The secondary path is the
+// same persist flow against its own set of tables, without publication.
+type ShadowConfig struct {
+	Percent     float64       `koanf:"percent"`     // INGEST_SHADOW_PERCENT: share of chats in [0, 100]; 0 disables
+	Timeout     time.Duration `koanf:"timeout"`     // INGEST_SHADOW_TIMEOUT
+	MaxInFlight int           `koanf:"maxinflight"` // INGEST_SHADOW_MAXINFLIGHT
+
+	// Tables of the secondary path, required when Percent is above zero.
+	MessagesTable    string `koanf:"messagestable"`    // INGEST_SHADOW_MESSAGESTABLE
+	CountersTable    string `koanf:"counterstable"`    // INGEST_SHADOW_COUNTERSTABLE
+	IdempotencyTable string `koanf:"idempotencytable"` // INGEST_SHADOW_IDEMPOTENCYTABLE
+}
+
+// Enabled reports whether a share of writes is shadowed.
+func (s ShadowConfig) Enabled() bool { return s.Percent > 0 }
+
 // FanoutConfig holds Fanout service configuration.
 type FanoutConfig struct {
 	HTTPPort int `koanf:"http_port"`
+	GRPCPort int `koanf:"grpc_port"`
+
+	// PausedConsumers start paused, for incidents that outlast a restart
+	// (e.g. FANOUT_PAUSEDCONSUMERS=delivery). Resume them through
+	// /admin/consumers.
+	PausedConsumers []string `koanf:"pausedconsumers"`
+
+	WebPush WebPushConfig `koanf:"webpush"`
+	SMS     SMSConfig     `koanf:"sms"`
+
+	// IngestAddr is the Ingest gRPC address SMS replies are persisted
+	// through. FANOUT_INGESTADDR.
+	IngestAddr string `koanf:"ingestaddr"`
+}
+
+// WebPushConfig configures push notifications to browsers (RFC 8030).
+// Browsers subscribe with the key's public half as applicationServerKey.
+// Offline users are pushed only when a key is set.
+type WebPushConfig struct {
+	VAPIDKey string `koanf:"vapidkey"` // FANOUT_WEBPUSH_VAPIDKEY: base64url P-256 private key; empty disables push
+	Subject  string `koanf:"subject"`  // FANOUT_WEBPUSH_SUBJECT: VAPID contact, a mailto: or https: URL
 }
 
+// Enabled reports whether push notifications are configured.
+func (c WebPushConfig) Enabled() bool { return c.VAPIDKey != "" }
+
+// SMSConfig configures SMS fallback: texting messages to offline users
+// who opted in, through Amazon SNS, and posting their replies back to the
+// chat. Replies arrive from the two-way number's SNS topic at Fanout's
+// /webhooks/sms. Offline users are texted only when a number is set.
+type SMSConfig struct {
+	OriginationNumber string `koanf:"originationnumber"` // FANOUT_SMS_ORIGINATIONNUMBER: E.164 two-way number; empty disables SMS fallback
+	ReplyTopicARN     string `koanf:"replytopicarn"`     // FANOUT_SMS_REPLYTOPICARN: SNS topic inbound SMS are published to; empty ignores replies
+}
+
+// Enabled reports whether SMS fallback is configured.
+func (c SMSConfig) Enabled() bool { return c.OriginationNumber != "" }
+
 // ChatMgmtConfig holds Chat Management service configuration.
 type ChatMgmtConfig struct {
+	HTTPPort  int                `koanf:"http_port"`
+	GRPCPort  int                `koanf:"grpc_port"`
+	Phone     PhoneConfig        `koanf:"phone"`
+	IP        IPConfig           `koanf:"ip"`
+	Honeypot  HoneypotConfig     `koanf:"honeypot"`
+	OTP       OTPConfig          `koanf:"otp"`
+	OTPSink   OTPSinkConfig      `koanf:"otpsink"`
+	RateLimit OTPRateLimitConfig `koanf:"ratelimit"`
+	Throttle  ThrottleConfig     `koanf:"throttle"`
+	// IngestAddr is the Ingest gRPC address system messages are posted
+	// through. CHATMGMT_INGESTADDR.
+	IngestAddr string `koanf:"ingestaddr"`
+	// FanoutAddr is the Fanout gRPC address new notification feed entries
+	// are delivered through. CHATMGMT_FANOUTADDR.
+	FanoutAddr string `koanf:"fanoutaddr"`
+}
+
+// ThrottleConfig caps REST requests per client address and per
+// authenticated user in each window (e.g. CHATMGMT_THROTTLE_USER=600).
+// Zero turns a tier off.
+type ThrottleConfig struct {
+	IP     int           `koanf:"ip"`
+	User   int           `koanf:"user"`
+	Window time.Duration `koanf:"window"`
+}
+
+// OTPRateLimitConfig caps OTP requests per client address and per client
+// subnet (IPv4 /24, IPv6 /64) in each 15-minute window (e.g.
+// CHATMGMT_RATELIMIT_SUBNET=60 for a carrier NAT). Zero keeps the domain
+// default. The per-phone limit is fixed.
+type OTPRateLimitConfig struct {
+	IP     int `koanf:"ip"`
+	Subnet int `koanf:"subnet"`
+}
+
+// OTPSinkConfig lists the canary's test numbers, whose OTPs are written
+// to Redis instead of sent by SMS (e.g.
+// CHATMGMT_OTPSINK_NUMBERS=+14155550100,+14155550101). Use only numbers
+// no real user can own.
+type OTPSinkConfig struct {
+	Numbers []string `koanf:"numbers"` // E.164
+}
+
+// Phones parses the sink numbers.
+func (c OTPSinkConfig) Phones() ([]domain.PhoneNumber, error) {
+	phones := make([]domain.PhoneNumber, 0, len(c.Numbers))
+	for _, n := range c.Numbers {
+		p, err := domain.NewPhoneNumber(strings.TrimSpace(n))
+		if err != nil {
+			return nil, fmt.Errorf("%w: chatmgmt.otpsink.numbers entry %q: %w", domain.ErrConfigInvalid, n, err)
+		}
+		phones = append(phones, p)
+	}
+	return phones, nil
+}
+
+// BridgeConfig holds federation bridge service configuration. Each bridge
+// has its own section and is disabled until its remote server is set.
+type BridgeConfig struct {
 	HTTPPort int `koanf:"http_port"`
-	GRPCPort int `koanf:"grpc_port"`
+	// IngestAddr is the Ingest gRPC address bridged messages are submitted
+	// to. BRIDGE_INGESTADDR.
+	IngestAddr string             `koanf:"ingestaddr"`
+	Matrix     MatrixBridgeConfig `koanf:"matrix"`
+}
+
+// CanaryConfig configures the synthetic canary. Each journey logs in two
+// users with sink numbers (see OTPSinkConfig), connects both to the
+// Gateway, and sends a message from one to the other in a chat they are
+// both members of. The canary is disabled until SenderPhone is set.
+type CanaryConfig struct {
+	HTTPPort      int           `koanf:"http_port"`
+	ChatMgmtURL   string        `koanf:"chatmgmturl"`   // CANARY_CHATMGMTURL: REST API base URL
+	GatewayURL    string        `koanf:"gatewayurl"`    // CANARY_GATEWAYURL: WebSocket URL, e.g. wss://gateway.example.com/v1/ws
+	SenderPhone   string        `koanf:"senderphone"`   // CANARY_SENDERPHONE: sink number of the sending user
+	ReceiverPhone string        `koanf:"receiverphone"` // CANARY_RECEIVERPHONE: sink number of the receiving user
+	ChatID        string        `koanf:"chatid"`        // CANARY_CHATID: chat both users are members of
+	Interval      time.Duration `koanf:"interval"`      // CANARY_INTERVAL: time between journey starts
+	StepTimeout   time.Duration `koanf:"steptimeout"`   // CANARY_STEPTIMEOUT
+}
+
+// Enabled reports whether the canary is configured to run.
+func (c CanaryConfig) Enabled() bool { return c.SenderPhone != "" }
+
+// MatrixBridgeConfig configures the Matrix bridge, which runs as a Matrix
+// application service. The tokens and puppet prefix must match the
+// registration file installed on the homeserver. Rooms are chat=room
+// pairs, comma-separated in env vars (e.g.
+// BRIDGE_MATRIX_ROOMS=<chat-id>=!abc:example.org).
+type MatrixBridgeConfig struct {
+	Homeserver   string   `koanf:"homeserver"`   // BRIDGE_MATRIX_HOMESERVER: client-server API URL; empty disables
+	ServerName   string   `koanf:"servername"`   // BRIDGE_MATRIX_SERVERNAME: e.g. example.org
+	ASToken      string   `koanf:"astoken"`      // BRIDGE_MATRIX_ASTOKEN: authenticates the bridge to the homeserver
+	HSToken      string   `koanf:"hstoken"`      // BRIDGE_MATRIX_HSTOKEN: authenticates the homeserver to the bridge
+	PuppetPrefix string   `koanf:"puppetprefix"` // BRIDGE_MATRIX_PUPPETPREFIX: localpart prefix of platform users' puppets
+	Rooms        []string `koanf:"rooms"`
+}
+
+// Enabled reports whether the Matrix bridge is configured to run.
+func (c MatrixBridgeConfig) Enabled() bool { return c.Homeserver != "" }
+
+// PhoneConfig restricts which phone numbers may request an OTP.
+// Region codes are ISO 3166-1 alpha-2, comma-separated in env vars
+// (e.g. CHATMGMT_PHONE_DENY=RU,KP).
+type PhoneConfig struct {
+	Allow     []string `koanf:"allow"`     // Empty allows all regions
+	Deny      []string `koanf:"deny"`      // Deny wins over Allow
+	FixedLine bool     `koanf:"fixedline"` // Admit numbers classified as fixed-line
+}
+
+// OTPConfig sets the OTP code format per request risk. Numbers from the
+// listed regions are graded elevated or high (e.g.
+// CHATMGMT_OTP_HIGHREGIONS=NG,PK); zero format fields keep the domain
+// defaults (e.g. CHATMGMT_OTP_HIGH_TTL=90s).
+type OTPConfig struct {
+	ElevatedRegions []string        `koanf:"elevatedregions"`
+	HighRegions     []string        `koanf:"highregions"`
+	Standard        OTPFormatConfig `koanf:"standard"`
+	Elevated        OTPFormatConfig `koanf:"elevated"`
+	High            OTPFormatConfig `koanf:"high"`
+}
+
+// OTPFormatConfig is the code format for one risk level.
+type OTPFormatConfig struct {
+	Length   int           `koanf:"length"`
+	Alphabet string        `koanf:"alphabet"` // Digits and upper-case letters
+	TTL      time.Duration `koanf:"ttl"`
+}
+
+// HoneypotConfig selects phone numbers that get a canary OTP flow: no code
+// is stored or sent, and every verification attempt is recorded. Lists are
+// comma-separated in env vars (e.g. CHATMGMT_HONEYPOT_PREFIXES=+1555000).
+type HoneypotConfig struct {
+	Prefixes []string `koanf:"prefixes"` // E.164 prefixes
+	Numbers  []string `koanf:"numbers"`  // Exact E.164 numbers
+}
+
+// IPConfig blocks client addresses at Gateway upgrade and RequestOTP.
+// Lists are comma-separated in env vars (e.g. GATEWAY_IP_BLOCK=203.0.113.0/24,
+// CHATMGMT_IP_BLOCKCOUNTRIES=KP). Country blocking needs a reputation
+// provider to locate addresses; without one the screener warns at startup
+// and the rules are not enforced. X-Forwarded-For is believed only as far as
+// TrustedProxies appended it (e.g. CHATMGMT_IP_TRUSTEDPROXIES=10.0.0.0/16,
+// the load balancer subnets); unset, the peer address is the client.
+type IPConfig struct {
+	Allow          []string `koanf:"allow"`          // CIDRs exempt from every check
+	Block          []string `koanf:"block"`          // CIDRs always refused
+	BlockCountries []string `koanf:"blockcountries"` // ISO 3166-1 alpha-2 regions
+	TrustedProxies []string `koanf:"trustedproxies"` // CIDRs of proxies whose X-Forwarded-For hops are believed
+}
+
+// ReconnectConfig is the reconnect policy sent to clients in
+// connection_closing and retryable error frames (ADR-005 §6).
+type ReconnectConfig struct {
+	MinBackoff time.Duration `koanf:"minbackoff"` // GATEWAY_RECONNECT_MINBACKOFF
+	MaxBackoff time.Duration `koanf:"maxbackoff"` // GATEWAY_RECONNECT_MAXBACKOFF
+	Jitter     float64       `koanf:"jitter"`     // GATEWAY_RECONNECT_JITTER: fraction in [0, 1]
+	Budget     int           `koanf:"budget"`     // GATEWAY_RECONNECT_BUDGET: 0 is unlimited
+}
+
+// AuthConfig holds JWT issuance configuration shared by the token minter and
+// validator. Lifetimes are bounded by the domain Min/Max token lifetime limits.
+type AuthConfig struct {
+	Issuer   string        `koanf:"issuer"`
+	Audience string        `koanf:"audience"`
+	Access   TokenConfig   `koanf:"access"`  // AUTH_ACCESS_TTL
+	Refresh  TokenConfig   `koanf:"refresh"` // AUTH_REFRESH_TTL
+	Session  SessionConfig `koanf:"session"`
+
+	// Peppers (AUTH_PEPPERS) key phone hashes and OTP MACs, as
+	// comma-separated version=secret entries; PepperVersion
+	// (AUTH_PEPPERVERSION) is the version new ones use. Version 0 is the
+	// scheme from before peppers were versioned. Unset, a development
+	// pepper is version 0.
+	Peppers       []string `koanf:"peppers"`
+	PepperVersion int      `koanf:"pepperversion"`
+
+	RefreshHash RefreshHashConfig `koanf:"refreshhash"`
+}
+
+// RefreshHashConfig selects how refresh tokens are hashed for storage.
+// Switching to argon2id is safe at any time: SHA-256 hashes keep
+// verifying, and sessions move to Argon2id at their next refresh.
+type RefreshHashConfig struct {
+	Algorithm   string `koanf:"algorithm"`   // AUTH_REFRESHHASH_ALGORITHM: sha256 or argon2id
+	Memory      uint32 `koanf:"memory"`      // AUTH_REFRESHHASH_MEMORY: Argon2id memory in KiB
+	Iterations  uint32 `koanf:"iterations"`  // AUTH_REFRESHHASH_ITERATIONS: Argon2id passes
+	Parallelism uint8  `koanf:"parallelism"` // AUTH_REFRESHHASH_PARALLELISM: Argon2id lanes
+}
+
+// Argon2 reports whether refresh tokens are hashed with Argon2id.
+func (r RefreshHashConfig) Argon2() bool { return r.Algorithm == "argon2id" }
+
+// devPepper keys phone hashes and OTP MACs as version 0 when Peppers is
+// unset.
+const devPepper = "local-dev-pepper-32-bytes-long!!"
+
+// PepperKeys returns the configured peppers by version.
+func (a AuthConfig) PepperKeys() map[int][]byte {
+	entries := a.pepperEntries()
+	if len(entries) == 0 {
+		return map[int][]byte{0: []byte(devPepper)}
+	}
+	keys := make(map[int][]byte, len(entries))
+	for _, entry := range entries {
+		if version, pepper, err := ParsePepper(entry); err == nil {
+			keys[version] = pepper
+		}
+	}
+	return keys
+}
+
+// pepperEntries returns Peppers without blank entries, so an empty
+// AUTH_PEPPERS reads as unset.
+func (a AuthConfig) pepperEntries() []string {
+	var entries []string
+	for _, entry := range a.Peppers {
+		if strings.TrimSpace(entry) != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SessionConfig holds session lifecycle settings.
+type SessionConfig struct {
+	// IdleTimeout revokes sessions unused for this long, even within the
+	// refresh TTL. AUTH_SESSION_IDLETIMEOUT.
+	IdleTimeout time.Duration `koanf:"idletimeout"`
+}
+
+// TokenConfig holds the lifetime of a single token type.
+type TokenConfig struct {
+	TTL time.Duration `koanf:"ttl"`
 }
 
 // DynamoDBConfig holds DynamoDB configuration.
@@ -69,6 +472,100 @@ type DynamoDBConfig struct {
 	Timeout  time.Duration `koanf:"timeout"`
 }
 
+// AnalyticsConfig controls the anonymized product analytics pipeline. It
+// is off until Topic is set (e.g. ANALYTICS_TOPIC=analytics.events).
+type AnalyticsConfig struct {
+	Topic        string   `koanf:"topic"`        // ANALYTICS_TOPIC: empty disables analytics
+	PseudonymKey string   `koanf:"pseudonymkey"` // ANALYTICS_PSEUDONYMKEY: HMAC key replacing user and chat IDs; required with a topic
+	SampleRate   float64  `koanf:"samplerate"`   // ANALYTICS_SAMPLERATE: share of users in [0, 1] whose events are kept
+	SampleRates  []string `koanf:"samplerates"`  // ANALYTICS_SAMPLERATES: event=rate overrides, e.g. message_sent=0.1
+}
+
+// Enabled reports whether analytics events are produced.
+func (a AnalyticsConfig) Enabled() bool { return a.Topic != "" }
+
+// BillingConfig controls the payment provider webhook receiver. It is off
+// until a signing secret is set.
+type BillingConfig struct {
+	// WebhookSecrets (BILLING_WEBHOOKSECRETS) are the provider's signing
+	// secrets, comma-separated. Listing the old and new secret together
+	// rotates without rejecting deliveries.
+	WebhookSecrets []string `koanf:"webhooksecrets"`
+}
+
+// Enabled reports whether the webhook receiver is registered.
+func (b BillingConfig) Enabled() bool { return len(b.WebhookSecrets) > 0 }
+
+// ResidencyConfig pins a deployment to one data region. Each region runs
+// its own deployment against AWS resources in that region and region-named
+// Kafka topics; workspaces of other regions are refused at request time.
+type ResidencyConfig struct {
+	Region string `koanf:"region"` // RESIDENCY_REGION: eu or us; empty serves every workspace
+}
+
+// Enabled reports whether the deployment enforces data residency.
+func (r ResidencyConfig) Enabled() bool { return r.Region != "" }
+
+// DataRegion returns the served region, or "" when residency is off.
+// Load has already validated it.
+func (r ResidencyConfig) DataRegion() domain.DataRegion {
+	if !r.Enabled() {
+		return ""
+	}
+	region, _ := domain.ParseDataRegion(r.Region)
+	return region
+}
+
+// MessagesConfig controls encryption of message content at rest. Content
+// is written in plaintext until KMSKeyID is set; encrypted content is read
+// back either way.
+type MessagesConfig struct {
+	KMSKeyID    string        `koanf:"kmskeyid"`    // MESSAGES_KMSKEYID: KMS key (ID, ARN or alias) wrapping chat data keys; empty disables encryption
+	KeyRotation time.Duration `koanf:"keyrotation"` // MESSAGES_KEYROTATION: age at which a chat gets a new data key
+	KeyCacheTTL time.Duration `koanf:"keycachettl"` // MESSAGES_KEYCACHETTL: how long unwrapped data keys stay in memory
+}
+
+// Enabled reports whether new message content is encrypted.
+func (m MessagesConfig) Enabled() bool { return m.KMSKeyID != "" }
+
+// PIIConfig controls encryption of users' phone numbers and display names
+// at rest. They are written in plaintext until KMSKeyID is set; once it is,
+// encrypted values can only be read with it.
+//
+// Phones are looked up by a blind index keyed by PhoneIndexPepper. Until
+// userencrypt has indexed every user, LegacyPhoneLookup also searches the
+// stored phone numbers; turn it off once the backfill completes.
+type PIIConfig struct {
+	KMSKeyID          string        `koanf:"kmskeyid"`          // PII_KMSKEYID: KMS key (ID, ARN or alias) wrapping the users table's data keys; empty disables encryption
+	KeyCacheTTL       time.Duration `koanf:"keycachettl"`       // PII_KEYCACHETTL: how long unwrapped data keys stay in memory
+	PhoneIndexPepper  string        `koanf:"phoneindexpepper"`  // PII_PHONEINDEXPEPPER: HMAC key of the phone blind index; required with encryption
+	LegacyPhoneLookup bool          `koanf:"legacyphonelookup"` // PII_LEGACYPHONELOOKUP: also find users by stored phone number
+}
+
+// Enabled reports whether users' personal data is encrypted.
+func (p PIIConfig) Enabled() bool { return p.KMSKeyID != "" }
+
+// devPhoneIndexPepper keys the phone blind index when PhoneIndexPepper is
+// unset, which validation allows only while encryption is off.
+const devPhoneIndexPepper = "local-dev-phone-index-pepper-32b"
+
+// PhoneIndexKey returns the key of the phone blind index.
+func (p PIIConfig) PhoneIndexKey() []byte {
+	if p.PhoneIndexPepper == "" {
+		return []byte(devPhoneIndexPepper)
+	}
+	return []byte(p.PhoneIndexPepper)
+}
+
+// Topic returns the served region's copy of a Kafka topic, or name itself
+// when residency is off.
+func (r ResidencyConfig) Topic(name string) string {
+	if !r.Enabled() {
+		return name
+	}
+	return r.DataRegion().Topic(name)
+}
+
 // KafkaConfig holds Kafka configuration.
 type KafkaConfig struct {
 	Brokers  []string `koanf:"brokers"` // Required in production
@@ -95,6 +592,47 @@ type OTELConfig struct {
 	ServiceName string `koanf:"service_name"`
 }
 
+// HTTPConfig selects the HTTP listener's protocols. With a certificate
+// (HTTP_TLS_CERT, HTTP_TLS_KEY) the listener serves TLS and negotiates
+// HTTP/2 through ALPN; H2C (HTTP_H2C) accepts HTTP/2 in cleartext, for a
+// load balancer that speaks HTTP/2 to its targets. HTTP/1.1 is always on.
+type HTTPConfig struct {
+	H2C               bool          `koanf:"h2c"`
+	TLS               TLSConfig     `koanf:"tls"`
+	ReadHeaderTimeout time.Duration `koanf:"readheadertimeout"` // HTTP_READHEADERTIMEOUT
+	ReadTimeout       time.Duration `koanf:"readtimeout"`       // HTTP_READTIMEOUT
+	WriteTimeout      time.Duration `koanf:"writetimeout"`      // HTTP_WRITETIMEOUT: bounds standard routes
+	IdleTimeout       time.Duration `koanf:"idletimeout"`       // HTTP_IDLETIMEOUT
+	LongPollTimeout   time.Duration `koanf:"longpolltimeout"`   // HTTP_LONGPOLLTIMEOUT: bounds long-poll routes
+	MaxBodyBytes      int64         `koanf:"maxbodybytes"`      // HTTP_MAXBODYBYTES: routes may set their own
+	CORS              CORSConfig    `koanf:"cors"`
+}
+
+// CORSConfig lets browser clients on other origins call the HTTP surface,
+// e.g. HTTP_CORS_ORIGINS=https://app.example.com. No origins disables CORS.
+// "*" allows any origin and is refused in production.
+type CORSConfig struct {
+	Origins []string      `koanf:"origins"` // Exact scheme://host[:port] origins
+	Headers []string      `koanf:"headers"` // Request headers a browser may send
+	MaxAge  time.Duration `koanf:"maxage"`  // How long a browser may cache a preflight
+}
+
+// Enabled reports whether any origin is allowed.
+func (c CORSConfig) Enabled() bool {
+	return len(c.Origins) > 0
+}
+
+// TLSConfig holds PEM file paths. Both or neither must be set.
+type TLSConfig struct {
+	Cert string `koanf:"cert"`
+	Key  string `koanf:"key"`
+}
+
+// Enabled reports whether a certificate is configured.
+func (c TLSConfig) Enabled() bool {
+	return c.Cert != ""
+}
+
 // defaults returns a Config with compiled default values.
 // These match normative limits from ADR-009.
 func defaults() *Config {
@@ -102,26 +640,130 @@ func defaults() *Config {
 		Environment: "local",
 		LogLevel:    "info",
 		LogFormat:   "json",
+		Logging: LoggingConfig{
+			SampleRate: domain.LogSampleRate,
+		},
 
 		Gateway: GatewayConfig{
-			HTTPPort: 8080,
-			GRPCPort: 9090,
+			HTTPPort:   8080,
+			GRPCPort:   9090,
+			IngestAddr: "localhost:9091",
+			FanoutAddr: "localhost:9094",
+			Drain: DrainConfig{
+				Slots: domain.MaxConcurrentDrains,
+				Wait:  domain.DrainSlotWait,
+			},
+			Reconnect: ReconnectConfig{
+				MinBackoff: domain.ReconnectMinBackoff,
+				MaxBackoff: domain.ReconnectMaxBackoff,
+				Jitter:     domain.ReconnectJitter,
+				Budget:     domain.ReconnectRetryBudget,
+			},
+			Admission: AdmissionConfig{
+				MaxCPU:        domain.AdmissionMaxCPU,
+				MaxGoroutines: domain.AdmissionMaxGoroutines,
+				ShedRatio:     domain.AdmissionShedRatio,
+			},
+			Batch: BatchConfig{
+				MaxFrames: domain.MaxBatchFrames,
+				MaxDelay:  domain.MaxBatchDelay,
+			},
+			Ack: AckConfig{
+				Window:    domain.AckWindowSize,
+				Retention: domain.AckResendRetention,
+			},
+			Quota: QuotaConfig{
+				PerUser:   domain.MaxConnectionsPerUser,
+				PerDevice: domain.MaxConnectionsPerDevice,
+				PerIP:     domain.MaxConnectionsPerIP,
+			},
+			Reader: ReaderConfig{
+				Mode: "goroutine",
+			},
+			AuthCache: AuthCacheConfig{
+				Entries: domain.AuthCacheEntries,
+			},
 		},
 		Ingest: IngestConfig{
-			HTTPPort: 8081,
-			GRPCPort: 9091,
+			HTTPPort:        8081,
+			GRPCPort:        9091,
+			MessageIDScheme: domain.MessageIDSchemeUUID,
+			Shadow: ShadowConfig{
+				Timeout:     domain.ShadowTimeout,
+				MaxInFlight: domain.ShadowMaxInFlight,
+			},
 		},
 		Fanout: FanoutConfig{
-			HTTPPort: 8082,
+			HTTPPort:   8082,
+			GRPCPort:   9094,
+			IngestAddr: "localhost:9091",
 		},
 		ChatMgmt: ChatMgmtConfig{
-			HTTPPort: 8083,
-			GRPCPort: 9093,
+			HTTPPort:   8083,
+			GRPCPort:   9093,
+			IngestAddr: "localhost:9091",
+			FanoutAddr: "localhost:9094",
+			Throttle: ThrottleConfig{
+				IP:     domain.HTTPThrottlePerIP,
+				User:   domain.HTTPThrottlePerUser,
+				Window: domain.HTTPThrottleWindow,
+			},
+		},
+		Bridge: BridgeConfig{
+			HTTPPort:   8084,
+			IngestAddr: "localhost:9091",
+			Matrix: MatrixBridgeConfig{
+				PuppetPrefix: "platform_",
+			},
+		},
+		Canary: CanaryConfig{
+			HTTPPort:    8085,
+			ChatMgmtURL: "http://localhost:8083",
+			GatewayURL:  "ws://localhost:8080/v1/ws",
+			Interval:    domain.CanaryInterval,
+			StepTimeout: domain.CanaryStepTimeout,
+		},
+
+		Auth: AuthConfig{
+			Issuer:   "messaging-platform",
+			Audience: "messaging-api",
+			Access:   TokenConfig{TTL: domain.AccessTokenLifetime},
+			Refresh:  TokenConfig{TTL: domain.RefreshTokenLifetime},
+			Session:  SessionConfig{IdleTimeout: domain.SessionIdleTimeout},
+			RefreshHash: RefreshHashConfig{
+				Algorithm:   "sha256",
+				Memory:      domain.RefreshArgon2Memory,
+				Iterations:  domain.RefreshArgon2Iterations,
+				Parallelism: domain.RefreshArgon2Parallelism,
+			},
 		},
 
 		DynamoDB: DynamoDBConfig{
 			Timeout: domain.DynamoDBTimeout,
 		},
+		HTTP: HTTPConfig{
+			ReadHeaderTimeout: domain.HTTPReadHeaderTimeout,
+			ReadTimeout:       domain.HTTPReadTimeout,
+			WriteTimeout:      domain.HTTPWriteTimeout,
+			IdleTimeout:       domain.HTTPIdleTimeout,
+			LongPollTimeout:   domain.HTTPLongPollTimeout,
+			MaxBodyBytes:      domain.HTTPMaxBodyBytes,
+			CORS: CORSConfig{
+				Headers: []string{"Authorization", "Content-Type", "If-None-Match", "X-Device-Id"},
+				MaxAge:  domain.HTTPCORSMaxAge,
+			},
+		},
+		Analytics: AnalyticsConfig{
+			SampleRate: 1,
+		},
+		Messages: MessagesConfig{
+			KeyRotation: domain.ChatKeyRotationPeriod,
+			KeyCacheTTL: domain.ChatKeyCacheTTL,
+		},
+		PII: PIIConfig{
+			KeyCacheTTL:       domain.ChatKeyCacheTTL,
+			LegacyPhoneLookup: true,
+		},
 		Kafka: KafkaConfig{
 			ClientID: "messaging-platform",
 		},
@@ -136,6 +778,34 @@ func defaults() *Config {
 	}
 }
 
+// listKeys are config keys whose env var values are split on commas.
+var listKeys = map[string]struct{}{
+	"kafka.brokers":                {},
+	"chatmgmt.phone.allow":         {},
+	"chatmgmt.phone.deny":          {},
+	"chatmgmt.ip.allow":            {},
+	"chatmgmt.ip.block":            {},
+	"chatmgmt.ip.blockcountries":   {},
+	"chatmgmt.ip.trustedproxies":   {},
+	"chatmgmt.honeypot.prefixes":   {},
+	"chatmgmt.otp.elevatedregions": {},
+	"chatmgmt.otp.highregions":     {},
+	"chatmgmt.honeypot.numbers":    {},
+	"gateway.ip.allow":             {},
+	"gateway.ip.block":             {},
+	"gateway.ip.blockcountries":    {},
+	"gateway.ip.trustedproxies":    {},
+	"logging.levels":               {},
+	"http.cors.origins":            {},
+	"http.cors.headers":            {},
+	"fanout.pausedconsumers":       {},
+	"bridge.matrix.rooms":          {},
+	"chatmgmt.otpsink.numbers":     {},
+	"analytics.samplerates":        {},
+	"billing.webhooksecrets":       {},
+	"auth.peppers":                 {},
+}
+
 // Load loads configuration following the precedence:
 // 1. Environment variables (highest)
 // 2. AWS SDK (Secrets Manager / SSM) - not implemented in PR-0
@@ -153,13 +823,24 @@ func Load(ctx context.Context) (*Config, error) {
 	// Load environment variables
 	// Prefix: none (we use full names like GATEWAY_HTTP_PORT)
 	// Delimiter: _ maps to . for nested config
-	err := k.Load(env.Provider("", ".", func(s string) string {
-		return strings.ReplaceAll(strings.ToLower(s), "_", ".")
+	// List-valued keys are comma-separated (e.g. KAFKA_BROKERS=a:9092,b:9092)
+	err := k.Load(env.ProviderWithValue("", ".", func(key, value string) (string, interface{}) {
+		key = strings.ReplaceAll(strings.ToLower(key), "_", ".")
+		if _, ok := listKeys[key]; ok {
+			return key, strings.Split(value, ",")
+		}
+		return key, value
 	}), nil)
 	if err != nil {
 		return nil, fmt.Errorf("load env vars: %w", err)
 	}
 
+	// Overlay per-environment defaults before env vars are applied
+	if k.Exists("environment") {
+		cfg.Environment = k.String("environment")
+	}
+	applyEnvironmentDefaults(cfg)
+
 	// Unmarshal into config struct
 	if err := k.Unmarshal("", cfg); err != nil {
 		return nil, fmt.Errorf("unmarshal config: %w", err)
@@ -170,6 +851,86 @@ func Load(ctx context.Context) (*Config, error) {
 		return nil, err
 	}
 
+	// Validate bounded fields
+	if err := validateAuth(cfg.Auth); err != nil {
+		return nil, err
+	}
+	if err := validateReconnect(cfg.Gateway.Reconnect); err != nil {
+		return nil, err
+	}
+	if err := validateHTTP(cfg.HTTP); err != nil {
+		return nil, err
+	}
+	if err := validateCORS(cfg.HTTP.CORS, cfg.IsProd()); err != nil {
+		return nil, err
+	}
+	if err := validateAdmission(cfg.Gateway.Admission); err != nil {
+		return nil, err
+	}
+	if err := validateBatch(cfg.Gateway.Batch); err != nil {
+		return nil, err
+	}
+	if err := validateAck(cfg.Gateway.Ack); err != nil {
+		return nil, err
+	}
+	if err := validateQuota(cfg.Gateway.Quota); err != nil {
+		return nil, err
+	}
+	if err := validateReader(cfg.Gateway.Reader); err != nil {
+		return nil, err
+	}
+	if err := validateLogging(cfg.Logging); err != nil {
+		return nil, err
+	}
+	if err := validateShadow(cfg.Ingest.Shadow); err != nil {
+		return nil, err
+	}
+	if _, err := domain.NewMessageIDGenerator(cfg.Ingest.MessageIDScheme, domain.RealClock{}); err != nil {
+		return nil, fmt.Errorf("%w: ingest.messageidscheme: %w", domain.ErrConfigInvalid, err)
+	}
+	if err := validateAnalytics(cfg.Analytics); err != nil {
+		return nil, err
+	}
+	if err := validateBilling(cfg.Billing); err != nil {
+		return nil, err
+	}
+	if err := validateResidency(cfg.Residency, cfg.AWS); err != nil {
+		return nil, err
+	}
+	if err := validateMessages(cfg.Messages); err != nil {
+		return nil, err
+	}
+	if err := validatePII(cfg.PII); err != nil {
+		return nil, err
+	}
+	if err := validateMatrixBridge(cfg.Bridge.Matrix); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.ChatMgmt.OTPSink.Phones(); err != nil {
+		return nil, err
+	}
+	if err := validateCanary(cfg.Canary); err != nil {
+		return nil, err
+	}
+	if err := validateIP("gateway.ip", cfg.Gateway.IP); err != nil {
+		return nil, err
+	}
+	if err := validateIP("chatmgmt.ip", cfg.ChatMgmt.IP); err != nil {
+		return nil, err
+	}
+	if _, err := domain.NewOTPPolicy(cfg.ChatMgmt.OTP.Policy()); err != nil {
+		return nil, fmt.Errorf("chatmgmt.otp: %w", err)
+	}
+	if rl := cfg.ChatMgmt.RateLimit; rl.IP < 0 || rl.Subnet < 0 {
+		return nil, fmt.Errorf("%w: chatmgmt.ratelimit limits must not be negative", domain.ErrConfigInvalid)
+	}
+	if th := cfg.ChatMgmt.Throttle; th.IP < 0 || th.User < 0 || th.Window < time.Second {
+		return nil, fmt.Errorf("%w: chatmgmt.throttle limits must not be negative and the window must be at least 1s", domain.ErrConfigInvalid)
+	}
+	if cfg.Gateway.AuthCache.Entries < 0 {
+		return nil, fmt.Errorf("%w: gateway.authcache.entries %d must not be negative", domain.ErrConfigInvalid, cfg.Gateway.AuthCache.Entries)
+	}
+
 	return cfg, nil
 }
 
@@ -194,6 +955,455 @@ func validateRequired(cfg *Config) error {
 	return nil
 }
 
+// applyEnvironmentDefaults adjusts compiled defaults for the selected
+// environment. Non-prod issuers carry the environment name so tokens minted
+// in dev or local are rejected by prod validators, and vice versa.
+func applyEnvironmentDefaults(cfg *Config) {
+	if cfg.Environment != "prod" && cfg.Environment != "" {
+		cfg.Auth.Issuer = "messaging-platform-" + cfg.Environment
+	}
+}
+
+// validateAuth checks that token configuration is present and that lifetimes
+// fall within the ADR-015 bounds.
+func validateAuth(auth AuthConfig) error {
+	if auth.Issuer == "" {
+		return fmt.Errorf("%w: auth.issuer", domain.ErrConfigRequired)
+	}
+	if auth.Audience == "" {
+		return fmt.Errorf("%w: auth.audience", domain.ErrConfigRequired)
+	}
+	if auth.Access.TTL < domain.MinAccessTokenLifetime || auth.Access.TTL > domain.MaxAccessTokenLifetime {
+		return fmt.Errorf("%w: auth.access.ttl %s not in [%s, %s]", domain.ErrConfigInvalid,
+			auth.Access.TTL, domain.MinAccessTokenLifetime, domain.MaxAccessTokenLifetime)
+	}
+	if auth.Refresh.TTL < domain.MinRefreshTokenLifetime || auth.Refresh.TTL > domain.MaxRefreshTokenLifetime {
+		return fmt.Errorf("%w: auth.refresh.ttl %s not in [%s, %s]", domain.ErrConfigInvalid,
+			auth.Refresh.TTL, domain.MinRefreshTokenLifetime, domain.MaxRefreshTokenLifetime)
+	}
+	if idle := auth.Session.IdleTimeout; idle < domain.MinSessionIdleTimeout || idle > domain.MaxSessionIdleTimeout {
+		return fmt.Errorf("%w: auth.session.idletimeout %s not in [%s, %s]", domain.ErrConfigInvalid,
+			idle, domain.MinSessionIdleTimeout, domain.MaxSessionIdleTimeout)
+	}
+	if err := validateRefreshHash(auth.RefreshHash); err != nil {
+		return err
+	}
+	return validatePeppers(auth)
+}
+
+// validateRefreshHash checks the algorithm and keeps the Argon2id cost
+// within bounds a refresh can afford.
+func validateRefreshHash(r RefreshHashConfig) error {
+	switch {
+	case r.Algorithm != "sha256" && !r.Argon2():
+		return fmt.Errorf("%w: auth.refreshhash.algorithm %q must be sha256 or argon2id", domain.ErrConfigInvalid, r.Algorithm)
+	case r.Memory < domain.MinRefreshArgon2Memory || r.Memory > domain.MaxRefreshArgon2Memory:
+		return fmt.Errorf("%w: auth.refreshhash.memory %d KiB not in [%d, %d]", domain.ErrConfigInvalid,
+			r.Memory, domain.MinRefreshArgon2Memory, domain.MaxRefreshArgon2Memory)
+	case r.Iterations < 1 || r.Iterations > domain.MaxRefreshArgon2Iterations:
+		return fmt.Errorf("%w: auth.refreshhash.iterations %d not in [1, %d]", domain.ErrConfigInvalid, r.Iterations, domain.MaxRefreshArgon2Iterations)
+	case r.Parallelism < 1:
+		return fmt.Errorf("%w: auth.refreshhash.parallelism must be positive", domain.ErrConfigInvalid)
+	}
+	return nil
+}
+
+// validatePeppers checks that every pepper is long enough and that the
+// current version is among them.
+func validatePeppers(auth AuthConfig) error {
+	entries := auth.pepperEntries()
+	versions := make(map[int]bool, len(entries))
+	for _, entry := range entries {
+		version, _, err := ParsePepper(entry)
+		if err != nil {
+			return err
+		}
+		if versions[version] {
+			return fmt.Errorf("%w: auth.peppers lists version %d twice", domain.ErrConfigInvalid, version)
+		}
+		versions[version] = true
+	}
+	if len(entries) == 0 && auth.PepperVersion != 0 {
+		return fmt.Errorf("%w: auth.peppers", domain.ErrConfigRequired)
+	}
+	if len(entries) > 0 && !versions[auth.PepperVersion] {
+		return fmt.Errorf("%w: auth.pepperversion %d is not in auth.peppers", domain.ErrConfigInvalid, auth.PepperVersion)
+	}
+	return nil
+}
+
+// ParsePepper splits an AUTH_PEPPERS entry of the form version=secret.
+func ParsePepper(entry string) (int, []byte, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimSpace(entry), "=")
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: auth.peppers entries must be version=secret", domain.ErrConfigInvalid)
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version < 0 {
+		return 0, nil, fmt.Errorf("%w: auth.peppers version %q must be a non-negative integer", domain.ErrConfigInvalid, prefix)
+	}
+	if len(secret) < domain.MinAuthPepperBytes {
+		return 0, nil, fmt.Errorf("%w: auth.peppers version %d must be at least %d bytes", domain.ErrConfigInvalid, version, domain.MinAuthPepperBytes)
+	}
+	return version, []byte(secret), nil
+}
+
+// validateReconnect checks that the reconnect policy is one clients can follow.
+func validateReconnect(r ReconnectConfig) error {
+	if r.MinBackoff <= 0 {
+		return fmt.Errorf("%w: gateway.reconnect.minbackoff %s must be positive", domain.ErrConfigInvalid, r.MinBackoff)
+	}
+	if r.MaxBackoff < r.MinBackoff {
+		return fmt.Errorf("%w: gateway.reconnect.maxbackoff %s below minbackoff %s", domain.ErrConfigInvalid,
+			r.MaxBackoff, r.MinBackoff)
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("%w: gateway.reconnect.jitter %g not in [0, 1]", domain.ErrConfigInvalid, r.Jitter)
+	}
+	if r.Budget < 0 {
+		return fmt.Errorf("%w: gateway.reconnect.budget %d must not be negative", domain.ErrConfigInvalid, r.Budget)
+	}
+	return nil
+}
+
+// validateShadow checks that shadow traffic settings are usable.
+func validateShadow(s ShadowConfig) error {
+	if s.Percent < 0 || s.Percent > 100 {
+		return fmt.Errorf("%w: ingest.shadow.percent %g not in [0, 100]", domain.ErrConfigInvalid, s.Percent)
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("%w: ingest.shadow.timeout %s must be positive", domain.ErrConfigInvalid, s.Timeout)
+	}
+	if s.MaxInFlight <= 0 {
+		return fmt.Errorf("%w: ingest.shadow.maxinflight %d must be positive", domain.ErrConfigInvalid, s.MaxInFlight)
+	}
+	if s.Enabled() && (s.MessagesTable == "" || s.CountersTable == "" || s.IdempotencyTable == "") {
+		return fmt.Errorf("%w: ingest.shadow tables are required when ingest.shadow.percent is set", domain.ErrConfigInvalid)
+	}
+	return nil
+}
+
+// validatePII checks the key cache TTL and that encrypted phones are not
+// indexed under the development pepper.
+func validatePII(p PIIConfig) error {
+	switch {
+	case p.KeyCacheTTL <= 0:
+		return fmt.Errorf("%w: pii.keycachettl must be positive", domain.ErrConfigInvalid)
+	case p.PhoneIndexPepper == "" && p.Enabled():
+		return fmt.Errorf("%w: pii.phoneindexpepper", domain.ErrConfigRequired)
+	case p.PhoneIndexPepper != "" && len(p.PhoneIndexPepper) < domain.MinPhoneIndexPepperBytes:
+		return fmt.Errorf("%w: pii.phoneindexpepper must be at least %d bytes", domain.ErrConfigInvalid, domain.MinPhoneIndexPepperBytes)
+	}
+	return nil
+}
+
+// validateAnalytics checks that enabled analytics can pseudonymize and
+// that every sample rate is a share.
+func validateAnalytics(a AnalyticsConfig) error {
+	if !a.Enabled() {
+		return nil
+	}
+	switch {
+	case a.PseudonymKey == "":
+		return fmt.Errorf("%w: analytics.pseudonymkey", domain.ErrConfigRequired)
+	case len(a.PseudonymKey) < domain.MinAnalyticsPseudonymKeyBytes:
+		return fmt.Errorf("%w: analytics.pseudonymkey must be at least %d bytes", domain.ErrConfigInvalid, domain.MinAnalyticsPseudonymKeyBytes)
+	case a.SampleRate < 0 || a.SampleRate > 1:
+		return fmt.Errorf("%w: analytics.samplerate %g not in [0, 1]", domain.ErrConfigInvalid, a.SampleRate)
+	}
+	for _, entry := range a.SampleRates {
+		if _, _, err := ParseSampleRateOverride(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseSampleRateOverride splits an ANALYTICS_SAMPLERATES entry of the
+// form event=rate.
+func ParseSampleRateOverride(entry string) (string, float64, error) {
+	event, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+	if !ok || event == "" {
+		return "", 0, fmt.Errorf("%w: analytics.samplerates entry %q must be event=rate", domain.ErrConfigInvalid, entry)
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return "", 0, fmt.Errorf("%w: analytics.samplerates entry %q: rate not in [0, 1]", domain.ErrConfigInvalid, entry)
+	}
+	return event, rate, nil
+}
+
+// validateResidency checks that the served region is known and that the
+// AWS region the deployment stores data in lies within it.
+func validateResidency(r ResidencyConfig, aws AWSConfig) error {
+	if !r.Enabled() {
+		return nil
+	}
+	region, err := domain.ParseDataRegion(r.Region)
+	if err != nil {
+		return fmt.Errorf("%w: residency.region %q must be %s or %s", domain.ErrConfigInvalid, r.Region, domain.RegionEU, domain.RegionUS)
+	}
+	if !region.HostsAWSRegion(aws.Region) {
+		return fmt.Errorf("%w: aws.region %q is outside residency region %s", domain.ErrConfigInvalid, aws.Region, region)
+	}
+	return nil
+}
+
+// validateMessages checks that data keys rotate and leave the cache.
+func validateMessages(m MessagesConfig) error {
+	if m.KeyRotation <= 0 {
+		return fmt.Errorf("%w: messages.keyrotation must be positive", domain.ErrConfigInvalid)
+	}
+	if m.KeyCacheTTL <= 0 {
+		return fmt.Errorf("%w: messages.keycachettl must be positive", domain.ErrConfigInvalid)
+	}
+	return nil
+}
+
+// validateBilling checks that every webhook secret is long enough to
+// resist guessing.
+func validateBilling(b BillingConfig) error {
+	for _, secret := range b.WebhookSecrets {
+		if len(secret) < domain.MinBillingWebhookSecretLength {
+			return fmt.Errorf("%w: billing.webhooksecrets entries must be at least %d bytes",
+				domain.ErrConfigInvalid, domain.MinBillingWebhookSecretLength)
+		}
+	}
+	return nil
+}
+
+// validateMatrixBridge checks that an enabled Matrix bridge can reach and
+// authenticate with its homeserver.
+func validateMatrixBridge(m MatrixBridgeConfig) error {
+	if !m.Enabled() {
+		return nil
+	}
+	for _, f := range []struct{ key, value string }{
+		{"bridge.matrix.servername", m.ServerName},
+		{"bridge.matrix.astoken", m.ASToken},
+		{"bridge.matrix.hstoken", m.HSToken},
+		{"bridge.matrix.puppetprefix", m.PuppetPrefix},
+	} {
+		if f.value == "" {
+			return fmt.Errorf("%w: %s", domain.ErrConfigRequired, f.key)
+		}
+	}
+	if m.ASToken == m.HSToken {
+		return fmt.Errorf("%w: bridge.matrix.hstoken must differ from astoken", domain.ErrConfigInvalid)
+	}
+	return nil
+}
+
+// validateCanary checks an enabled canary's users, chat and pacing.
+func validateCanary(c CanaryConfig) error {
+	if !c.Enabled() {
+		return nil
+	}
+	for _, f := range []struct{ key, value string }{
+		{"canary.receiverphone", c.ReceiverPhone},
+		{"canary.chatid", c.ChatID},
+		{"canary.chatmgmturl", c.ChatMgmtURL},
+		{"canary.gatewayurl", c.GatewayURL},
+	} {
+		if f.value == "" {
+			return fmt.Errorf("%w: %s", domain.ErrConfigRequired, f.key)
+		}
+	}
+	sender, err := domain.NewPhoneNumber(c.SenderPhone)
+	if err != nil {
+		return fmt.Errorf("%w: canary.senderphone: %w", domain.ErrConfigInvalid, err)
+	}
+	receiver, err := domain.NewPhoneNumber(c.ReceiverPhone)
+	if err != nil {
+		return fmt.Errorf("%w: canary.receiverphone: %w", domain.ErrConfigInvalid, err)
+	}
+	if sender == receiver {
+		return fmt.Errorf("%w: canary.receiverphone must differ from senderphone", domain.ErrConfigInvalid)
+	}
+	if _, err := domain.NewChatID(c.ChatID); err != nil {
+		return fmt.Errorf("%w: canary.chatid: %w", domain.ErrConfigInvalid, err)
+	}
+	if c.Interval < domain.CanaryMinInterval {
+		return fmt.Errorf("%w: canary.interval %s is below %s, which would trip the OTP rate limit",
+			domain.ErrConfigInvalid, c.Interval, domain.CanaryMinInterval)
+	}
+	if c.StepTimeout <= 0 {
+		return fmt.Errorf("%w: canary.steptimeout must be positive", domain.ErrConfigInvalid)
+	}
+	return nil
+}
+
+// validateIP checks that every IP list entry parses as an address or CIDR.
+func validateIP(key string, c IPConfig) error {
+	if _, err := domain.NewIPPolicy(c.Policy()); err != nil {
+		return fmt.Errorf("%w: %s: %w", domain.ErrConfigInvalid, key, err)
+	}
+	if _, err := domain.NewClientIPResolver(c.TrustedProxies); err != nil {
+		return fmt.Errorf("%w: %s.trustedproxies: %w", domain.ErrConfigInvalid, key, err)
+	}
+	return nil
+}
+
+// Policy returns the domain policy inputs for c.
+func (c IPConfig) Policy() domain.IPPolicyConfig {
+	return domain.IPPolicyConfig{Allow: c.Allow, Block: c.Block, BlockCountries: c.BlockCountries}
+}
+
+// Policy returns the domain policy inputs for c.
+func (c OTPConfig) Policy() domain.OTPPolicyConfig {
+	return domain.OTPPolicyConfig{
+		Standard:        c.Standard.format(),
+		Elevated:        c.Elevated.format(),
+		High:            c.High.format(),
+		ElevatedRegions: c.ElevatedRegions,
+		HighRegions:     c.HighRegions,
+	}
+}
+
+func (c OTPFormatConfig) format() domain.OTPCodeFormat {
+	return domain.OTPCodeFormat{Length: c.Length, Alphabet: c.Alphabet, TTL: c.TTL}
+}
+
+// validateAdmission checks that admission limits are usable.
+func validateAdmission(a AdmissionConfig) error {
+	if a.MaxCPU < 0 || a.MaxCPU > 1 {
+		return fmt.Errorf("%w: gateway.admission.maxcpu %g not in [0, 1]", domain.ErrConfigInvalid, a.MaxCPU)
+	}
+	if a.MaxGoroutines < 0 {
+		return fmt.Errorf("%w: gateway.admission.maxgoroutines %d must not be negative", domain.ErrConfigInvalid, a.MaxGoroutines)
+	}
+	if a.ShedRatio <= 0 || a.ShedRatio > 1 {
+		return fmt.Errorf("%w: gateway.admission.shedratio %g not in (0, 1]", domain.ErrConfigInvalid, a.ShedRatio)
+	}
+	return nil
+}
+
+// validateBatch checks that batching limits are usable.
+func validateBatch(b BatchConfig) error {
+	if b.MaxFrames < 1 {
+		return fmt.Errorf("%w: gateway.batch.maxframes %d must be at least 1", domain.ErrConfigInvalid, b.MaxFrames)
+	}
+	if b.MaxDelay <= 0 {
+		return fmt.Errorf("%w: gateway.batch.maxdelay %s must be positive", domain.ErrConfigInvalid, b.MaxDelay)
+	}
+	return nil
+}
+
+// validateAck checks that the ack window and resend retention are usable.
+func validateAck(a AckConfig) error {
+	if a.Window < 1 {
+		return fmt.Errorf("%w: gateway.ack.window %d must be at least 1", domain.ErrConfigInvalid, a.Window)
+	}
+	if a.Retention <= 0 {
+		return fmt.Errorf("%w: gateway.ack.retention %s must be positive", domain.ErrConfigInvalid, a.Retention)
+	}
+	return nil
+}
+
+// validateQuota checks that every connection quota admits at least one
+// connection.
+func validateQuota(q QuotaConfig) error {
+	for _, f := range []struct {
+		key   string
+		value int
+	}{
+		{"gateway.quota.peruser", q.PerUser},
+		{"gateway.quota.perdevice", q.PerDevice},
+		{"gateway.quota.perip", q.PerIP},
+	} {
+		if f.value < 1 {
+			return fmt.Errorf("%w: %s %d must be at least 1", domain.ErrConfigInvalid, f.key, f.value)
+		}
+	}
+	return nil
+}
+
+// validateHTTP checks that the TLS files come as a pair and the timeouts
+// and body cap are positive.
+func validateHTTP(h HTTPConfig) error {
+	if (h.TLS.Cert == "") != (h.TLS.Key == "") {
+		return fmt.Errorf("%w: http.tls.cert and http.tls.key must be set together", domain.ErrConfigInvalid)
+	}
+	for _, f := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"http.readheadertimeout", h.ReadHeaderTimeout},
+		{"http.readtimeout", h.ReadTimeout},
+		{"http.writetimeout", h.WriteTimeout},
+		{"http.idletimeout", h.IdleTimeout},
+		{"http.longpolltimeout", h.LongPollTimeout},
+	} {
+		if f.value <= 0 {
+			return fmt.Errorf("%w: %s %s must be positive", domain.ErrConfigInvalid, f.key, f.value)
+		}
+	}
+	if h.MaxBodyBytes <= 0 {
+		return fmt.Errorf("%w: http.maxbodybytes %d must be positive", domain.ErrConfigInvalid, h.MaxBodyBytes)
+	}
+	return nil
+}
+
+// validateCORS checks that each allowed origin is "*" or a bare
+// scheme://host[:port] origin, and that production does not allow "*".
+func validateCORS(c CORSConfig, prod bool) error {
+	for _, origin := range c.Origins {
+		if origin == "*" {
+			if prod {
+				return fmt.Errorf("%w: http.cors.origins must list origins in prod, not *", domain.ErrConfigInvalid)
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("%w: http.cors.origins entry %q must be scheme://host[:port]", domain.ErrConfigInvalid, origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("%w: http.cors.maxage %s must not be negative", domain.ErrConfigInvalid, c.MaxAge)
+	}
+	return nil
+}
+
+// validateReader checks the read scheduler selection.
+func validateReader(r ReaderConfig) error {
+	if r.Mode != "goroutine" && r.Mode != "epoll" {
+		return fmt.Errorf("%w: gateway.reader.mode %q must be goroutine or epoll", domain.ErrConfigInvalid, r.Mode)
+	}
+	if r.Workers < 0 {
+		return fmt.Errorf("%w: gateway.reader.workers %d must not be negative", domain.ErrConfigInvalid, r.Workers)
+	}
+	return nil
+}
+
+// validateLogging checks the sampling rate and level overrides.
+func validateLogging(l LoggingConfig) error {
+	if l.SampleRate < 0 {
+		return fmt.Errorf("%w: logging.samplerate %d must not be negative", domain.ErrConfigInvalid, l.SampleRate)
+	}
+	for _, entry := range l.Levels {
+		if _, _, err := ParseLevelOverride(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseLevelOverride splits a LOGGING_LEVELS entry of the form
+// subsystem=level.
+func ParseLevelOverride(entry string) (string, slog.Level, error) {
+	subsystem, name, ok := strings.Cut(strings.TrimSpace(entry), "=")
+	var level slog.Level
+	if !ok || subsystem == "" {
+		return "", level, fmt.Errorf("%w: logging.levels entry %q must be subsystem=level", domain.ErrConfigInvalid, entry)
+	}
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return "", level, fmt.Errorf("%w: logging.levels entry %q: %w", domain.ErrConfigInvalid, entry, err)
+	}
+	return subsystem, level, nil
+}
+
 // IsLocal returns true if running in local development environment.
 func (c *Config) IsLocal() bool {
 	return c.Environment == "local"
@@ -2,7 +2,10 @@ package config_test
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aelexs/realtime-messaging-platform/internal/config"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
@@ -17,6 +20,8 @@ func TestDefaults(t *testing.T) {
 	assert.Equal(t, "local", cfg.Environment)
 	assert.Equal(t, "info", cfg.LogLevel)
 	assert.Equal(t, "json", cfg.LogFormat)
+	assert.Equal(t, domain.LogSampleRate, cfg.Logging.SampleRate)
+	assert.Empty(t, cfg.Logging.Levels)
 
 	// Service ports
 	assert.Equal(t, 8080, cfg.Gateway.HTTPPort)
@@ -24,8 +29,42 @@ func TestDefaults(t *testing.T) {
 	assert.Equal(t, 8081, cfg.Ingest.HTTPPort)
 	assert.Equal(t, 9091, cfg.Ingest.GRPCPort)
 	assert.Equal(t, 8082, cfg.Fanout.HTTPPort)
+	assert.Equal(t, 9094, cfg.Fanout.GRPCPort)
 	assert.Equal(t, 8083, cfg.ChatMgmt.HTTPPort)
 	assert.Equal(t, 9093, cfg.ChatMgmt.GRPCPort)
+	assert.Equal(t, "localhost:9094", cfg.ChatMgmt.FanoutAddr)
+	assert.Equal(t, 8084, cfg.Bridge.HTTPPort)
+	assert.False(t, cfg.Bridge.Matrix.Enabled())
+
+	// Gateway drain coordination
+	assert.Equal(t, domain.MaxConcurrentDrains, cfg.Gateway.Drain.Slots)
+	assert.Equal(t, domain.DrainSlotWait, cfg.Gateway.Drain.Wait)
+	assert.Equal(t, domain.ReconnectMinBackoff, cfg.Gateway.Reconnect.MinBackoff)
+	assert.Equal(t, domain.ReconnectMaxBackoff, cfg.Gateway.Reconnect.MaxBackoff)
+	assert.InDelta(t, domain.ReconnectJitter, cfg.Gateway.Reconnect.Jitter, 1e-9)
+	assert.Equal(t, domain.ReconnectRetryBudget, cfg.Gateway.Reconnect.Budget)
+	assert.InDelta(t, domain.AdmissionMaxCPU, cfg.Gateway.Admission.MaxCPU, 1e-9)
+	assert.Zero(t, cfg.Gateway.Admission.MaxMemory)
+	assert.Equal(t, domain.AdmissionMaxGoroutines, cfg.Gateway.Admission.MaxGoroutines)
+	assert.InDelta(t, domain.AdmissionShedRatio, cfg.Gateway.Admission.ShedRatio, 1e-9)
+	assert.Equal(t, domain.MaxBatchFrames, cfg.Gateway.Batch.MaxFrames)
+	assert.Equal(t, domain.MaxBatchDelay, cfg.Gateway.Batch.MaxDelay)
+	assert.Equal(t, domain.AckWindowSize, cfg.Gateway.Ack.Window)
+	assert.Equal(t, domain.AckResendRetention, cfg.Gateway.Ack.Retention)
+	assert.Equal(t, domain.MaxConnectionsPerUser, cfg.Gateway.Quota.PerUser)
+	assert.Equal(t, domain.MaxConnectionsPerDevice, cfg.Gateway.Quota.PerDevice)
+	assert.Equal(t, domain.MaxConnectionsPerIP, cfg.Gateway.Quota.PerIP)
+	assert.Empty(t, cfg.Gateway.Upload.Bucket, "uploads are off by default")
+	assert.Equal(t, "goroutine", cfg.Gateway.Reader.Mode)
+	assert.Zero(t, cfg.Gateway.Reader.Workers)
+	assert.Equal(t, domain.AuthCacheEntries, cfg.Gateway.AuthCache.Entries)
+	assert.Equal(t, "localhost:9091", cfg.Gateway.IngestAddr)
+	assert.Equal(t, "localhost:9094", cfg.Gateway.FanoutAddr)
+
+	// Ingest shadow traffic
+	assert.Zero(t, cfg.Ingest.Shadow.Percent)
+	assert.Equal(t, domain.ShadowTimeout, cfg.Ingest.Shadow.Timeout)
+	assert.Equal(t, domain.ShadowMaxInFlight, cfg.Ingest.Shadow.MaxInFlight)
 
 	// Infrastructure defaults
 	assert.Equal(t, domain.DynamoDBTimeout, cfg.DynamoDB.Timeout)
@@ -34,6 +73,22 @@ func TestDefaults(t *testing.T) {
 	assert.Equal(t, domain.RedisTimeout, cfg.Redis.Timeout)
 	assert.Equal(t, "messaging-platform", cfg.Kafka.ClientID)
 	assert.Equal(t, "us-east-1", cfg.AWS.Region)
+
+	// HTTP listener
+	assert.False(t, cfg.HTTP.H2C)
+	assert.False(t, cfg.HTTP.TLS.Enabled())
+	assert.Equal(t, domain.HTTPReadHeaderTimeout, cfg.HTTP.ReadHeaderTimeout)
+	assert.Equal(t, domain.HTTPReadTimeout, cfg.HTTP.ReadTimeout)
+	assert.Equal(t, domain.HTTPWriteTimeout, cfg.HTTP.WriteTimeout)
+	assert.Equal(t, domain.HTTPIdleTimeout, cfg.HTTP.IdleTimeout)
+	assert.Equal(t, domain.HTTPLongPollTimeout, cfg.HTTP.LongPollTimeout)
+	assert.Equal(t, int64(domain.HTTPMaxBodyBytes), cfg.HTTP.MaxBodyBytes)
+
+	// Token defaults
+	assert.Equal(t, "messaging-platform-local", cfg.Auth.Issuer)
+	assert.Equal(t, "messaging-api", cfg.Auth.Audience)
+	assert.Equal(t, domain.AccessTokenLifetime, cfg.Auth.Access.TTL)
+	assert.Equal(t, domain.RefreshTokenLifetime, cfg.Auth.Refresh.TTL)
 }
 
 func TestIsLocal(t *testing.T) {
@@ -110,14 +165,1003 @@ func TestValidateRequired_ProdRequiresRedisAddr(t *testing.T) {
 	assert.Contains(t, err.Error(), "redis.addr")
 }
 
+func TestAdminTokenEnv(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "local")
+	t.Setenv("ADMINTOKEN", "s3cret")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.AdminToken)
+}
+
 func TestLoadWithEnvOverride(t *testing.T) {
 	t.Setenv("ENVIRONMENT", "prod")
 	t.Setenv("REDIS_ADDR", "redis:6379")
-	t.Setenv("KAFKA_BROKERS", "broker1:9092")
+	t.Setenv("KAFKA_BROKERS", "broker1:9092,broker2:9092")
 
 	cfg, err := config.Load(context.Background())
 
 	require.NoError(t, err)
 	assert.Equal(t, "prod", cfg.Environment)
 	assert.Equal(t, "redis:6379", cfg.Redis.Addr)
+	assert.Equal(t, []string{"broker1:9092", "broker2:9092"}, cfg.Kafka.Brokers)
+}
+
+func TestAuthIssuerPerEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{name: "local is suffixed", env: "local", want: "messaging-platform-local"},
+		{name: "dev is suffixed", env: "dev", want: "messaging-platform-dev"},
+		{name: "prod is unsuffixed", env: "prod", want: "messaging-platform"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENVIRONMENT", tt.env)
+			t.Setenv("KAFKA_BROKERS", "broker1:9092")
+
+			cfg, err := config.Load(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg.Auth.Issuer)
+		})
+	}
+}
+
+func TestAuthEnvOverride(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "dev")
+	t.Setenv("AUTH_ISSUER", "custom-issuer")
+	t.Setenv("AUTH_AUDIENCE", "custom-audience")
+	t.Setenv("AUTH_ACCESS_TTL", "15m")
+	t.Setenv("AUTH_REFRESH_TTL", "168h")
+	t.Setenv("AUTH_SESSION_IDLETIMEOUT", "720h")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "custom-issuer", cfg.Auth.Issuer)
+	assert.Equal(t, "custom-audience", cfg.Auth.Audience)
+	assert.Equal(t, 15*time.Minute, cfg.Auth.Access.TTL)
+	assert.Equal(t, 7*24*time.Hour, cfg.Auth.Refresh.TTL)
+	assert.Equal(t, 30*24*time.Hour, cfg.Auth.Session.IdleTimeout)
+}
+
+func TestAuthTTLBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "access at minimum", key: "AUTH_ACCESS_TTL", value: "5m"},
+		{name: "access at maximum", key: "AUTH_ACCESS_TTL", value: "1h"},
+		{name: "access below minimum", key: "AUTH_ACCESS_TTL", value: "4m59s", wantErr: true},
+		{name: "access above maximum", key: "AUTH_ACCESS_TTL", value: "61m", wantErr: true},
+		{name: "refresh at minimum", key: "AUTH_REFRESH_TTL", value: "24h"},
+		{name: "refresh at maximum", key: "AUTH_REFRESH_TTL", value: "2160h"},
+		{name: "refresh below minimum", key: "AUTH_REFRESH_TTL", value: "23h", wantErr: true},
+		{name: "refresh above maximum", key: "AUTH_REFRESH_TTL", value: "2161h", wantErr: true},
+		{name: "idle timeout below minimum", key: "AUTH_SESSION_IDLETIMEOUT", value: "23h", wantErr: true},
+		{name: "idle timeout above maximum", key: "AUTH_SESSION_IDLETIMEOUT", value: "8761h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthRequiresIssuer(t *testing.T) {
+	t.Setenv("AUTH_ISSUER", "")
+
+	_, err := config.Load(context.Background())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrConfigRequired)
+	assert.Contains(t, err.Error(), "auth.issuer")
+}
+
+func TestAuthRefreshHash(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cfg.Auth.RefreshHash.Argon2())
+	assert.Equal(t, uint32(domain.RefreshArgon2Memory), cfg.Auth.RefreshHash.Memory)
+
+	t.Setenv("AUTH_REFRESHHASH_ALGORITHM", "argon2id")
+	t.Setenv("AUTH_REFRESHHASH_MEMORY", "65536")
+	t.Setenv("AUTH_REFRESHHASH_ITERATIONS", "3")
+	t.Setenv("AUTH_REFRESHHASH_PARALLELISM", "2")
+	cfg, err = config.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, config.RefreshHashConfig{Algorithm: "argon2id", Memory: 65536, Iterations: 3, Parallelism: 2}, cfg.Auth.RefreshHash)
+	assert.True(t, cfg.Auth.RefreshHash.Argon2())
+
+	tests := []struct{ name, key, value string }{
+		{name: "unknown algorithm", key: "AUTH_REFRESHHASH_ALGORITHM", value: "bcrypt"},
+		{name: "memory too low", key: "AUTH_REFRESHHASH_MEMORY", value: "1024"},
+		{name: "memory too high", key: "AUTH_REFRESHHASH_MEMORY", value: "1048576"},
+		{name: "no passes", key: "AUTH_REFRESHHASH_ITERATIONS", value: "0"},
+		{name: "too many passes", key: "AUTH_REFRESHHASH_ITERATIONS", value: "11"},
+		{name: "no lanes", key: "AUTH_REFRESHHASH_PARALLELISM", value: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+		})
+	}
+}
+
+func TestAuthPeppers(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Auth.PepperVersion)
+	require.Len(t, cfg.Auth.PepperKeys(), 1, "development pepper")
+	assert.Len(t, cfg.Auth.PepperKeys()[0], domain.MinAuthPepperBytes)
+
+	old := strings.Repeat("o", domain.MinAuthPepperBytes)
+	next := strings.Repeat("n", domain.MinAuthPepperBytes) + "=="
+	t.Setenv("AUTH_PEPPERS", "0="+old+",1="+next)
+	t.Setenv("AUTH_PEPPERVERSION", "1")
+	cfg, err = config.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.Auth.PepperVersion)
+	assert.Equal(t, map[int][]byte{0: []byte(old), 1: []byte(next)}, cfg.Auth.PepperKeys())
+
+	tests := []struct {
+		name, peppers, version string
+		want                   error
+	}{
+		{name: "current version missing", peppers: "0=" + old, version: "1", want: domain.ErrConfigInvalid},
+		{name: "short pepper", peppers: "1=short", version: "1", want: domain.ErrConfigInvalid},
+		{name: "no version", peppers: old, version: "0", want: domain.ErrConfigInvalid},
+		{name: "negative version", peppers: "-1=" + old, version: "-1", want: domain.ErrConfigInvalid},
+		{name: "duplicate version", peppers: "1=" + old + ",1=" + next, version: "1", want: domain.ErrConfigInvalid},
+		{name: "version without peppers", peppers: "", version: "2", want: domain.ErrConfigRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_PEPPERS", tt.peppers)
+			t.Setenv("AUTH_PEPPERVERSION", tt.version)
+
+			_, err := config.Load(context.Background())
+
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestPhoneEnvOverride(t *testing.T) {
+	t.Setenv("CHATMGMT_PHONE_ALLOW", "US,CA,GB")
+	t.Setenv("CHATMGMT_PHONE_DENY", "RU")
+	t.Setenv("CHATMGMT_PHONE_FIXEDLINE", "true")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"US", "CA", "GB"}, cfg.ChatMgmt.Phone.Allow)
+	assert.Equal(t, []string{"RU"}, cfg.ChatMgmt.Phone.Deny)
+	assert.True(t, cfg.ChatMgmt.Phone.FixedLine)
+}
+
+func TestIPEnvOverride(t *testing.T) {
+	t.Setenv("GATEWAY_IP_BLOCK", "203.0.113.0/24,2001:db8::/32")
+	t.Setenv("CHATMGMT_IP_ALLOW", "198.51.100.7")
+	t.Setenv("CHATMGMT_IP_BLOCKCOUNTRIES", "KP,RU")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"203.0.113.0/24", "2001:db8::/32"}, cfg.Gateway.IP.Block)
+	assert.Equal(t, []string{"198.51.100.7"}, cfg.ChatMgmt.IP.Allow)
+	assert.Equal(t, []string{"KP", "RU"}, cfg.ChatMgmt.IP.BlockCountries)
+}
+
+func TestIPInvalidCIDR(t *testing.T) {
+	t.Setenv("CHATMGMT_IP_BLOCK", "10.0.0.0/33")
+
+	_, err := config.Load(context.Background())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+	assert.Contains(t, err.Error(), "chatmgmt.ip")
+}
+
+func TestIPTrustedProxies(t *testing.T) {
+	t.Setenv("GATEWAY_IP_TRUSTEDPROXIES", "10.0.0.0/16,10.1.0.0/16")
+	t.Setenv("CHATMGMT_IP_TRUSTEDPROXIES", "10.0.0.0/16")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16", "10.1.0.0/16"}, cfg.Gateway.IP.TrustedProxies)
+	assert.Equal(t, []string{"10.0.0.0/16"}, cfg.ChatMgmt.IP.TrustedProxies)
+
+	t.Setenv("GATEWAY_IP_TRUSTEDPROXIES", "10.0.0.0/33")
+
+	_, err = config.Load(context.Background())
+
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+	assert.ErrorContains(t, err, "gateway.ip.trustedproxies")
+}
+
+func TestHoneypotEnvOverride(t *testing.T) {
+	t.Setenv("CHATMGMT_HONEYPOT_PREFIXES", "+1555000,+44700900")
+	t.Setenv("CHATMGMT_HONEYPOT_NUMBERS", "+15551230000")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+1555000", "+44700900"}, cfg.ChatMgmt.Honeypot.Prefixes)
+	assert.Equal(t, []string{"+15551230000"}, cfg.ChatMgmt.Honeypot.Numbers)
+}
+
+func TestOTPEnvOverride(t *testing.T) {
+	t.Setenv("CHATMGMT_OTP_HIGHREGIONS", "NG,PK")
+	t.Setenv("CHATMGMT_OTP_HIGH_LENGTH", "10")
+	t.Setenv("CHATMGMT_OTP_HIGH_TTL", "90s")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NG", "PK"}, cfg.ChatMgmt.OTP.HighRegions)
+	assert.Equal(t, 10, cfg.ChatMgmt.OTP.High.Length)
+	assert.Equal(t, 90*time.Second, cfg.ChatMgmt.OTP.High.TTL)
+}
+
+func TestOTPRateLimitEnvOverride(t *testing.T) {
+	t.Setenv("CHATMGMT_RATELIMIT_IP", "20")
+	t.Setenv("CHATMGMT_RATELIMIT_SUBNET", "60")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.ChatMgmt.RateLimit.IP)
+	assert.Equal(t, 60, cfg.ChatMgmt.RateLimit.Subnet)
+}
+
+func TestOTPRateLimitNegative(t *testing.T) {
+	t.Setenv("CHATMGMT_RATELIMIT_SUBNET", "-1")
+
+	_, err := config.Load(context.Background())
+
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestThrottleDefaults(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.HTTPThrottlePerIP, cfg.ChatMgmt.Throttle.IP)
+	assert.Equal(t, domain.HTTPThrottlePerUser, cfg.ChatMgmt.Throttle.User)
+	assert.Equal(t, domain.HTTPThrottleWindow, cfg.ChatMgmt.Throttle.Window)
+}
+
+func TestThrottleBounds(t *testing.T) {
+	tests := []struct {
+		name, key, value string
+	}{
+		{"negative ip limit", "CHATMGMT_THROTTLE_IP", "-1"},
+		{"negative user limit", "CHATMGMT_THROTTLE_USER", "-1"},
+		{"sub-second window", "CHATMGMT_THROTTLE_WINDOW", "500ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+		})
+	}
+}
+
+func TestFanoutPausedConsumersEnvOverride(t *testing.T) {
+	t.Setenv("FANOUT_PAUSEDCONSUMERS", "push,search-index")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"push", "search-index"}, cfg.Fanout.PausedConsumers)
+}
+
+func TestFanoutWebPushEnvOverride(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cfg.Fanout.WebPush.Enabled())
+
+	t.Setenv("FANOUT_WEBPUSH_VAPIDKEY", "c2VjcmV0")
+	t.Setenv("FANOUT_WEBPUSH_SUBJECT", "mailto:ops@example.com")
+
+	cfg, err = config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, cfg.Fanout.WebPush.Enabled())
+	assert.Equal(t, "mailto:ops@example.com", cfg.Fanout.WebPush.Subject)
+}
+
+func TestFanoutSMSEnvOverride(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cfg.Fanout.SMS.Enabled())
+
+	t.Setenv("FANOUT_SMS_ORIGINATIONNUMBER", "+14155550100")
+	t.Setenv("FANOUT_SMS_REPLYTOPICARN", "arn:aws:sns:us-east-1:123456789012:sms-replies")
+	t.Setenv("FANOUT_INGESTADDR", "ingest:9091")
+
+	cfg, err = config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, cfg.Fanout.SMS.Enabled())
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:sms-replies", cfg.Fanout.SMS.ReplyTopicARN)
+	assert.Equal(t, "ingest:9091", cfg.Fanout.IngestAddr)
+}
+
+func TestMatrixBridgeEnvOverride(t *testing.T) {
+	t.Setenv("BRIDGE_MATRIX_HOMESERVER", "https://matrix.example.org")
+	t.Setenv("BRIDGE_MATRIX_SERVERNAME", "example.org")
+	t.Setenv("BRIDGE_MATRIX_ASTOKEN", "as-secret")
+	t.Setenv("BRIDGE_MATRIX_HSTOKEN", "hs-secret")
+	t.Setenv("BRIDGE_MATRIX_ROOMS", "chat-1=!a:example.org,chat-2=!b:example.org")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, cfg.Bridge.Matrix.Enabled())
+	assert.Equal(t, "platform_", cfg.Bridge.Matrix.PuppetPrefix)
+	assert.Equal(t, []string{"chat-1=!a:example.org", "chat-2=!b:example.org"}, cfg.Bridge.Matrix.Rooms)
+}
+
+func TestMatrixBridgeRequiresTokens(t *testing.T) {
+	t.Setenv("BRIDGE_MATRIX_HOMESERVER", "https://matrix.example.org")
+	t.Setenv("BRIDGE_MATRIX_SERVERNAME", "example.org")
+	t.Setenv("BRIDGE_MATRIX_ASTOKEN", "as-secret")
+
+	_, err := config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigRequired)
+
+	t.Setenv("BRIDGE_MATRIX_HSTOKEN", "as-secret")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid, "tokens must differ")
+}
+
+func TestOTPWeakFormat(t *testing.T) {
+	t.Setenv("CHATMGMT_OTP_STANDARD_LENGTH", "4")
+
+	_, err := config.Load(context.Background())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+	assert.Contains(t, err.Error(), "chatmgmt.otp")
+}
+
+func TestGatewayDrainEnvOverride(t *testing.T) {
+	t.Setenv("GATEWAY_DRAIN_SLOTS", "4")
+	t.Setenv("GATEWAY_DRAIN_WAIT", "8s")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.Gateway.Drain.Slots)
+	assert.Equal(t, 8*time.Second, cfg.Gateway.Drain.Wait)
+}
+
+func TestGatewayReconnectEnvOverride(t *testing.T) {
+	t.Setenv("GATEWAY_RECONNECT_MINBACKOFF", "5s")
+	t.Setenv("GATEWAY_RECONNECT_MAXBACKOFF", "2m")
+	t.Setenv("GATEWAY_RECONNECT_JITTER", "0.8")
+	t.Setenv("GATEWAY_RECONNECT_BUDGET", "3")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Gateway.Reconnect.MinBackoff)
+	assert.Equal(t, 2*time.Minute, cfg.Gateway.Reconnect.MaxBackoff)
+	assert.InDelta(t, 0.8, cfg.Gateway.Reconnect.Jitter, 1e-9)
+	assert.Equal(t, 3, cfg.Gateway.Reconnect.Budget)
+}
+
+func TestGatewayReconnectBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "zero jitter", key: "GATEWAY_RECONNECT_JITTER", value: "0"},
+		{name: "full jitter", key: "GATEWAY_RECONNECT_JITTER", value: "1"},
+		{name: "unlimited budget", key: "GATEWAY_RECONNECT_BUDGET", value: "0"},
+		{name: "zero min backoff", key: "GATEWAY_RECONNECT_MINBACKOFF", value: "0s", wantErr: true},
+		{name: "max below min", key: "GATEWAY_RECONNECT_MAXBACKOFF", value: "500ms", wantErr: true},
+		{name: "jitter above one", key: "GATEWAY_RECONNECT_JITTER", value: "1.5", wantErr: true},
+		{name: "negative jitter", key: "GATEWAY_RECONNECT_JITTER", value: "-0.1", wantErr: true},
+		{name: "negative budget", key: "GATEWAY_RECONNECT_BUDGET", value: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayAdmissionEnvOverride(t *testing.T) {
+	t.Setenv("GATEWAY_ADMISSION_MAXCPU", "0.7")
+	t.Setenv("GATEWAY_ADMISSION_MAXMEMORY", "2147483648")
+	t.Setenv("GATEWAY_ADMISSION_MAXGOROUTINES", "50000")
+	t.Setenv("GATEWAY_ADMISSION_SHEDRATIO", "0.6")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.InDelta(t, 0.7, cfg.Gateway.Admission.MaxCPU, 1e-9)
+	assert.Equal(t, uint64(2147483648), cfg.Gateway.Admission.MaxMemory)
+	assert.Equal(t, 50000, cfg.Gateway.Admission.MaxGoroutines)
+	assert.InDelta(t, 0.6, cfg.Gateway.Admission.ShedRatio, 1e-9)
+}
+
+func TestGatewayAdmissionBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "cpu disabled", key: "GATEWAY_ADMISSION_MAXCPU", value: "0"},
+		{name: "shed at limit", key: "GATEWAY_ADMISSION_SHEDRATIO", value: "1"},
+		{name: "cpu above one", key: "GATEWAY_ADMISSION_MAXCPU", value: "1.2", wantErr: true},
+		{name: "negative goroutines", key: "GATEWAY_ADMISSION_MAXGOROUTINES", value: "-1", wantErr: true},
+		{name: "zero shed ratio", key: "GATEWAY_ADMISSION_SHEDRATIO", value: "0", wantErr: true},
+		{name: "shed ratio above one", key: "GATEWAY_ADMISSION_SHEDRATIO", value: "1.1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayBatchBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "batching disabled", key: "GATEWAY_BATCH_MAXFRAMES", value: "1"},
+		{name: "custom delay", key: "GATEWAY_BATCH_MAXDELAY", value: "10ms"},
+		{name: "zero frames", key: "GATEWAY_BATCH_MAXFRAMES", value: "0", wantErr: true},
+		{name: "zero delay", key: "GATEWAY_BATCH_MAXDELAY", value: "0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayAckBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "custom window", key: "GATEWAY_ACK_WINDOW", value: "64"},
+		{name: "custom retention", key: "GATEWAY_ACK_RETENTION", value: "30s"},
+		{name: "zero window", key: "GATEWAY_ACK_WINDOW", value: "0", wantErr: true},
+		{name: "zero retention", key: "GATEWAY_ACK_RETENTION", value: "0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayQuotaBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "custom per user", key: "GATEWAY_QUOTA_PERUSER", value: "10"},
+		{name: "custom per ip", key: "GATEWAY_QUOTA_PERIP", value: "100"},
+		{name: "zero per user", key: "GATEWAY_QUOTA_PERUSER", value: "0", wantErr: true},
+		{name: "zero per device", key: "GATEWAY_QUOTA_PERDEVICE", value: "0", wantErr: true},
+		{name: "negative per ip", key: "GATEWAY_QUOTA_PERIP", value: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "h2c", env: map[string]string{"HTTP_H2C": "true"}},
+		{name: "tls pair", env: map[string]string{"HTTP_TLS_CERT": "/tls/cert.pem", "HTTP_TLS_KEY": "/tls/key.pem"}},
+		{name: "custom write timeout", env: map[string]string{"HTTP_WRITETIMEOUT": "30s"}},
+		{name: "cert without key", env: map[string]string{"HTTP_TLS_CERT": "/tls/cert.pem"}, wantErr: true},
+		{name: "key without cert", env: map[string]string{"HTTP_TLS_KEY": "/tls/key.pem"}, wantErr: true},
+		{name: "zero read timeout", env: map[string]string{"HTTP_READTIMEOUT": "0s"}, wantErr: true},
+		{name: "negative idle timeout", env: map[string]string{"HTTP_IDLETIMEOUT": "-1s"}, wantErr: true},
+		{name: "custom long-poll timeout", env: map[string]string{"HTTP_LONGPOLLTIMEOUT": "25s"}},
+		{name: "zero long-poll timeout", env: map[string]string{"HTTP_LONGPOLLTIMEOUT": "0s"}, wantErr: true},
+		{name: "custom body cap", env: map[string]string{"HTTP_MAXBODYBYTES": "65536"}},
+		{name: "zero body cap", env: map[string]string{"HTTP_MAXBODYBYTES": "0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCORSEnvOverride(t *testing.T) {
+	t.Setenv("HTTP_CORS_ORIGINS", "https://app.example.com,http://localhost:5173")
+	t.Setenv("HTTP_CORS_HEADERS", "Authorization,Content-Type")
+	t.Setenv("HTTP_CORS_MAXAGE", "1h")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, cfg.HTTP.CORS.Enabled())
+	assert.Equal(t, []string{"https://app.example.com", "http://localhost:5173"}, cfg.HTTP.CORS.Origins)
+	assert.Equal(t, []string{"Authorization", "Content-Type"}, cfg.HTTP.CORS.Headers)
+	assert.Equal(t, time.Hour, cfg.HTTP.CORS.MaxAge)
+}
+
+func TestCORSDefaultsOff(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.False(t, cfg.HTTP.CORS.Enabled())
+	assert.Equal(t, domain.HTTPCORSMaxAge, cfg.HTTP.CORS.MaxAge)
+}
+
+func TestCORSBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "wildcard outside prod", env: map[string]string{"HTTP_CORS_ORIGINS": "*"}},
+		{name: "origin with port", env: map[string]string{"HTTP_CORS_ORIGINS": "http://localhost:5173"}},
+		{name: "wildcard in prod", env: map[string]string{
+			"HTTP_CORS_ORIGINS": "*", "ENVIRONMENT": "prod", "KAFKA_BROKERS": "broker1:9092", "REDIS_ADDR": "redis:6379",
+		}, wantErr: true},
+		{name: "origin with path", env: map[string]string{"HTTP_CORS_ORIGINS": "https://app.example.com/login"}, wantErr: true},
+		{name: "origin without scheme", env: map[string]string{"HTTP_CORS_ORIGINS": "app.example.com"}, wantErr: true},
+		{name: "negative max age", env: map[string]string{"HTTP_CORS_MAXAGE": "-1s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayReaderBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "epoll mode", key: "GATEWAY_READER_MODE", value: "epoll"},
+		{name: "explicit workers", key: "GATEWAY_READER_WORKERS", value: "64"},
+		{name: "unknown mode", key: "GATEWAY_READER_MODE", value: "io_uring", wantErr: true},
+		{name: "negative workers", key: "GATEWAY_READER_WORKERS", value: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGatewayAuthCacheBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "disabled", value: "0"},
+		{name: "explicit size", value: "50000"},
+		{name: "negative", value: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GATEWAY_AUTHCACHE_ENTRIES", tt.value)
+
+			cfg, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, strconv.Itoa(cfg.Gateway.AuthCache.Entries))
+		})
+	}
+}
+
+func TestIngestShadowBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		tables  bool
+		wantErr bool
+	}{
+		{name: "fractional percent", key: "INGEST_SHADOW_PERCENT", value: "0.5", tables: true},
+		{name: "all traffic", key: "INGEST_SHADOW_PERCENT", value: "100", tables: true},
+		{name: "percent without tables", key: "INGEST_SHADOW_PERCENT", value: "1", wantErr: true},
+		{name: "percent above 100", key: "INGEST_SHADOW_PERCENT", value: "101", tables: true, wantErr: true},
+		{name: "negative percent", key: "INGEST_SHADOW_PERCENT", value: "-1", wantErr: true},
+		{name: "zero timeout", key: "INGEST_SHADOW_TIMEOUT", value: "0s", wantErr: true},
+		{name: "zero in-flight", key: "INGEST_SHADOW_MAXINFLIGHT", value: "0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if tt.tables {
+				t.Setenv("INGEST_SHADOW_MESSAGESTABLE", "messages_shadow")
+				t.Setenv("INGEST_SHADOW_COUNTERSTABLE", "chat_counters_shadow")
+				t.Setenv("INGEST_SHADOW_IDEMPOTENCYTABLE", "idempotency_keys_shadow")
+			}
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestIngestMessageIDScheme(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, domain.MessageIDSchemeUUID, cfg.Ingest.MessageIDScheme)
+
+	t.Setenv("INGEST_MESSAGEIDSCHEME", "ulid")
+	cfg, err = config.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, domain.MessageIDSchemeULID, cfg.Ingest.MessageIDScheme)
+
+	t.Setenv("INGEST_MESSAGEIDSCHEME", "snowflake")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestAnalyticsBounds(t *testing.T) {
+	key := strings.Repeat("k", domain.MinAnalyticsPseudonymKeyBytes)
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr error
+	}{
+		{name: "disabled without topic", env: map[string]string{"ANALYTICS_SAMPLERATE": "7"}},
+		{name: "enabled", env: map[string]string{
+			"ANALYTICS_TOPIC": "analytics.events", "ANALYTICS_PSEUDONYMKEY": key,
+			"ANALYTICS_SAMPLERATE": "0.25", "ANALYTICS_SAMPLERATES": "message_sent=0.1,session_started=1",
+		}},
+		{name: "topic without key", env: map[string]string{"ANALYTICS_TOPIC": "analytics.events"},
+			wantErr: domain.ErrConfigRequired},
+		{name: "short key", env: map[string]string{"ANALYTICS_TOPIC": "analytics.events", "ANALYTICS_PSEUDONYMKEY": "short"},
+			wantErr: domain.ErrConfigInvalid},
+		{name: "rate above 1", env: map[string]string{
+			"ANALYTICS_TOPIC": "analytics.events", "ANALYTICS_PSEUDONYMKEY": key, "ANALYTICS_SAMPLERATE": "1.5",
+		}, wantErr: domain.ErrConfigInvalid},
+		{name: "override without rate", env: map[string]string{
+			"ANALYTICS_TOPIC": "analytics.events", "ANALYTICS_PSEUDONYMKEY": key, "ANALYTICS_SAMPLERATES": "message_sent",
+		}, wantErr: domain.ErrConfigInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := config.Load(context.Background())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if cfg.Analytics.Enabled() {
+				assert.Equal(t, 0.25, cfg.Analytics.SampleRate)
+				assert.Equal(t, []string{"message_sent=0.1", "session_started=1"}, cfg.Analytics.SampleRates)
+			}
+		})
+	}
+}
+
+func TestBillingBounds(t *testing.T) {
+	t.Setenv("BILLING_WEBHOOKSECRETS", "whsec-current-0123456789,whsec-previous-012345678")
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cfg.Billing.Enabled())
+	assert.Equal(t, []string{"whsec-current-0123456789", "whsec-previous-012345678"}, cfg.Billing.WebhookSecrets)
+
+	t.Setenv("BILLING_WEBHOOKSECRETS", "whsec-current-0123456789,short")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestResidency(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cfg.Residency.Enabled())
+	assert.Equal(t, "analytics.events", cfg.Residency.Topic("analytics.events"))
+
+	t.Setenv("RESIDENCY_REGION", "EU")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	cfg, err = config.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, domain.RegionEU, cfg.Residency.DataRegion())
+	assert.Equal(t, "eu.analytics.events", cfg.Residency.Topic("analytics.events"))
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid, "EU data must not be stored in a US region")
+
+	t.Setenv("RESIDENCY_REGION", "apac")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestMessages(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cfg.Messages.Enabled())
+	assert.Equal(t, domain.ChatKeyRotationPeriod, cfg.Messages.KeyRotation)
+	assert.Equal(t, domain.ChatKeyCacheTTL, cfg.Messages.KeyCacheTTL)
+
+	t.Setenv("MESSAGES_KMSKEYID", "alias/messages")
+	t.Setenv("MESSAGES_KEYROTATION", "168h")
+	cfg, err = config.Load(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cfg.Messages.Enabled())
+	assert.Equal(t, "alias/messages", cfg.Messages.KMSKeyID)
+	assert.Equal(t, 7*24*time.Hour, cfg.Messages.KeyRotation)
+
+	t.Setenv("MESSAGES_KEYCACHETTL", "0s")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestPII(t *testing.T) {
+	cfg, err := config.Load(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cfg.PII.Enabled())
+	assert.Equal(t, domain.ChatKeyCacheTTL, cfg.PII.KeyCacheTTL)
+	assert.True(t, cfg.PII.LegacyPhoneLookup)
+	assert.Len(t, cfg.PII.PhoneIndexKey(), domain.MinPhoneIndexPepperBytes, "development pepper")
+
+	t.Setenv("PII_KMSKEYID", "alias/pii")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigRequired, "encryption needs a phone index pepper")
+
+	t.Setenv("PII_PHONEINDEXPEPPER", "too-short")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+
+	t.Setenv("PII_PHONEINDEXPEPPER", strings.Repeat("p", domain.MinPhoneIndexPepperBytes))
+	t.Setenv("PII_LEGACYPHONELOOKUP", "false")
+	cfg, err = config.Load(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cfg.PII.Enabled())
+	assert.Equal(t, "alias/pii", cfg.PII.KMSKeyID)
+	assert.False(t, cfg.PII.LegacyPhoneLookup)
+	assert.Equal(t, []byte(strings.Repeat("p", domain.MinPhoneIndexPepperBytes)), cfg.PII.PhoneIndexKey())
+
+	t.Setenv("PII_KEYCACHETTL", "-1s")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestLoggingBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "sampling disabled", key: "LOGGING_SAMPLERATE", value: "0"},
+		{name: "level overrides", key: "LOGGING_LEVELS", value: "gateway/app=debug,chatmgmt/auth=warn"},
+		{name: "negative sample rate", key: "LOGGING_SAMPLERATE", value: "-1", wantErr: true},
+		{name: "override without level", key: "LOGGING_LEVELS", value: "gateway/app", wantErr: true},
+		{name: "unknown level", key: "LOGGING_LEVELS", value: "gateway/app=verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			_, err := config.Load(context.Background())
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestOTPSinkNumbers(t *testing.T) {
+	t.Setenv("CHATMGMT_OTPSINK_NUMBERS", "+14155550100,+14155550101")
+
+	cfg, err := config.Load(context.Background())
+
+	require.NoError(t, err)
+	phones, err := cfg.ChatMgmt.OTPSink.Phones()
+	require.NoError(t, err)
+	assert.Len(t, phones, 2)
+
+	t.Setenv("CHATMGMT_OTPSINK_NUMBERS", "+14155550100,not-a-phone")
+	_, err = config.Load(context.Background())
+	assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+}
+
+func TestCanaryBounds(t *testing.T) {
+	enabled := map[string]string{
+		"CANARY_SENDERPHONE":   "+14155550100",
+		"CANARY_RECEIVERPHONE": "+14155550101",
+		"CANARY_CHATID":        "11111111-1111-4111-8111-111111111111",
+	}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr error
+	}{
+		{name: "disabled by default"},
+		{name: "enabled", env: enabled},
+		{name: "missing chat", env: map[string]string{"CANARY_CHATID": ""}, wantErr: domain.ErrConfigRequired},
+		{name: "invalid chat", env: map[string]string{"CANARY_CHATID": "canary-chat"}, wantErr: domain.ErrConfigInvalid},
+		{name: "invalid phone", env: map[string]string{"CANARY_RECEIVERPHONE": "555"}, wantErr: domain.ErrConfigInvalid},
+		{name: "same phones", env: map[string]string{"CANARY_RECEIVERPHONE": "+14155550100"}, wantErr: domain.ErrConfigInvalid},
+		{name: "interval below rate limit", env: map[string]string{"CANARY_INTERVAL": "1m"}, wantErr: domain.ErrConfigInvalid},
+		{name: "zero step timeout", env: map[string]string{"CANARY_STEPTIMEOUT": "0s"}, wantErr: domain.ErrConfigInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != nil {
+				for k, v := range enabled {
+					t.Setenv(k, v)
+				}
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := config.Load(context.Background())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.env != nil, cfg.Canary.Enabled())
+			assert.Equal(t, domain.CanaryInterval, cfg.Canary.Interval)
+		})
+	}
 }
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// bridgedUserNamespace scopes the name-based UUIDs of remote users who post
+// through a federation bridge.
+var bridgedUserNamespace = uuid.MustParse("3d9a7c51-4e2b-5f80-b6a1-c8e2d4f7a903")
+
+// BridgedUserID derives the sender ID for a remote user posting into a
+// platform chat through bridge (e.g. "matrix"), from the user's ID on the
+// remote protocol. The same remote user always maps to the same ID.
+func BridgedUserID(bridge, remoteUser string) UserID {
+	return UserID{value: uuid.NewSHA1(bridgedUserNamespace, []byte(bridge+"\x00"+remoteUser)).String()}
+}
+
+// ValidateBridgeName checks a federation bridge name: lower-case letters,
+// digits and '-', at most MaxBridgeNameLength characters. The name is part
+// of the idempotency key of every bridged message.
+func ValidateBridgeName(name string) error {
+	if name == "" || len(name) > MaxBridgeNameLength {
+		return NewValidationError("bridge", fmt.Sprintf("name must be 1 to %d characters", MaxBridgeNameLength))
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return NewValidationError("bridge", fmt.Sprintf("name %q may only contain a-z, 0-9 and '-'", name))
+		}
+	}
+	return nil
+}
+
+// BridgeClientMessageID returns the idempotency key for a message bridge
+// received from the remote protocol as eventID. It is in the system
+// namespace, so clients cannot claim it, and a redelivered remote event
+// does not post twice.
+func BridgeClientMessageID(bridge, eventID string) string {
+	return SystemClientMessageID(bridgePrefix(bridge) + eventID)
+}
+
+// IsBridgedFrom reports whether clientMessageID belongs to a message bridge
+// brought in from its remote protocol. Bridges use it to avoid sending a
+// message back where it came from.
+func IsBridgedFrom(clientMessageID, bridge string) bool {
+	return strings.HasPrefix(clientMessageID, SystemClientMessageIDPrefix+bridgePrefix(bridge))
+}
+
+func bridgePrefix(bridge string) string {
+	return "bridge:" + bridge + ":"
+}
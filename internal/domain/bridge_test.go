@@ -0,0 +1,39 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestBridgedUserID(t *testing.T) {
+	assert.Equal(t, domain.BridgedUserID("matrix", "@alice:example.org"), domain.BridgedUserID("matrix", "@alice:example.org"))
+	assert.NotEqual(t, domain.BridgedUserID("matrix", "@alice:example.org"), domain.BridgedUserID("xmpp", "@alice:example.org"), "scoped to the bridge")
+	assert.NotEqual(t, domain.BridgedUserID("matrix", "@alice:example.org"), domain.BridgedUserID("matrix", "@bob:example.org"))
+}
+
+func TestValidateBridgeName(t *testing.T) {
+	require.NoError(t, domain.ValidateBridgeName("matrix"))
+	require.NoError(t, domain.ValidateBridgeName("matrix-eu-2"))
+
+	for _, name := range []string{"", "Matrix", "matrix:1", "matrix bridge", strings.Repeat("a", domain.MaxBridgeNameLength+1)} {
+		err := domain.ValidateBridgeName(name)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput, "name %q", name)
+	}
+}
+
+func TestBridgeClientMessageID(t *testing.T) {
+	id := domain.BridgeClientMessageID("matrix", "$ev1")
+
+	assert.True(t, domain.IsBridgedFrom(id, "matrix"))
+	assert.False(t, domain.IsBridgedFrom(id, "matrix-eu"), "another bridge")
+	assert.False(t, domain.IsBridgedFrom("client-1", "matrix"))
+	assert.False(t, domain.IsBridgedFrom(domain.SystemClientMessageID("evt-1"), "matrix"))
+
+	_, err := domain.NewClientMessageID(id)
+	assert.ErrorIs(t, err, domain.ErrInvalidID, "clients cannot claim bridged IDs")
+}
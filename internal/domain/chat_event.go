@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"maps"
+	"time"
+)
+
+// ChatEventType names a change recorded in a chat's event log.
+type ChatEventType string
+
+// Chat event types.
+const (
+	// ChatEventSettingsChanged carries the settings after the change and
+	// their version.
+	ChatEventSettingsChanged ChatEventType = "settings_changed"
+	// ChatEventMemberJoined adds UserID with Role. A user who is already a
+	// member keeps their role, as a re-run import does.
+	ChatEventMemberJoined ChatEventType = "member_joined"
+	// ChatEventRoleChanged sets a non-owner member's Role.
+	ChatEventRoleChanged ChatEventType = "role_changed"
+	// ChatEventOwnershipTransferred makes SuccessorID the owner and the
+	// previous owner, UserID, an admin.
+	ChatEventOwnershipTransferred ChatEventType = "ownership_transferred"
+	// ChatEventOwnerRemoved removes the owner, UserID, and makes
+	// SuccessorID the owner unless it is empty.
+	ChatEventOwnerRemoved ChatEventType = "owner_removed"
+	// ChatEventMemberRemoved removes a non-owner member, UserID.
+	ChatEventMemberRemoved ChatEventType = "member_removed"
+	// ChatEventSnapshot replaces the state with Settings, SettingsVersion
+	// and Members. Compaction folds old events into one.
+	ChatEventSnapshot ChatEventType = "snapshot"
+)
+
+// ChatEvent is one entry of a chat's append-only event log, written in the
+// same transaction as the change it records. EventID is a ULID stamped
+// with At, so the log sorts by time. ActorID is empty for changes the
+// platform made on its own, such as succession and deprovisioning.
+type ChatEvent struct {
+	ChatID  string
+	EventID string
+	Type    ChatEventType
+	ActorID string
+	At      time.Time
+
+	Settings        *ChatSettings
+	SettingsVersion int64
+
+	UserID      string
+	Role        MemberRole
+	SuccessorID string
+
+	Members map[string]MemberRole
+}
+
+// NewChatEventID returns a new event ID stamped with at. IDs drawn in the
+// same millisecond are ordered at random; the writes they record are
+// conditional, so only commuting changes can share a millisecond.
+func NewChatEventID(at time.Time) string {
+	u := ulidAt(uint64(at.UnixMilli()))
+	fillEntropy(&u)
+	return u.String()
+}
+
+// LastChatEventID returns the greatest event ID stamped in at's
+// millisecond: event IDs at or below it record changes made at or before
+// at.
+func LastChatEventID(at time.Time) string {
+	u := ulidAt(uint64(at.UnixMilli()))
+	for i := 6; i < len(u); i++ {
+		u[i] = 0xFF
+	}
+	return u.String()
+}
+
+// ChatState is a chat's settings and members as reconstructed from its
+// event log. Settings is nil until the log records them. Chats that
+// predate the log only show the changes made since.
+type ChatState struct {
+	Settings        *ChatSettings
+	SettingsVersion int64
+	Members         map[string]MemberRole
+	// LastEventID and At identify the last event applied.
+	LastEventID string
+	At          time.Time
+}
+
+// Apply folds e into s. A settings change older than the version already
+// applied is skipped, so events that share a millisecond replay in version
+// order whatever order they sort in. Unknown event types are skipped.
+func (s *ChatState) Apply(e ChatEvent) {
+	if s.Members == nil {
+		s.Members = make(map[string]MemberRole)
+	}
+	switch e.Type {
+	case ChatEventSettingsChanged:
+		if e.Settings == nil || e.SettingsVersion <= s.SettingsVersion {
+			break
+		}
+		settings := *e.Settings
+		s.Settings, s.SettingsVersion = &settings, e.SettingsVersion
+	case ChatEventMemberJoined:
+		if _, ok := s.Members[e.UserID]; !ok {
+			s.Members[e.UserID] = e.Role
+		}
+	case ChatEventRoleChanged:
+		s.Members[e.UserID] = e.Role
+	case ChatEventOwnershipTransferred:
+		s.Members[e.UserID] = MemberRoleAdmin
+		s.Members[e.SuccessorID] = MemberRoleOwner
+	case ChatEventOwnerRemoved:
+		delete(s.Members, e.UserID)
+		if e.SuccessorID != "" {
+			s.Members[e.SuccessorID] = MemberRoleOwner
+		}
+	case ChatEventMemberRemoved:
+		delete(s.Members, e.UserID)
+	case ChatEventSnapshot:
+		s.Settings, s.SettingsVersion = nil, e.SettingsVersion
+		if e.Settings != nil {
+			settings := *e.Settings
+			s.Settings = &settings
+		}
+		s.Members = maps.Clone(e.Members)
+		if s.Members == nil {
+			s.Members = make(map[string]MemberRole)
+		}
+	default:
+		return
+	}
+	s.LastEventID, s.At = e.EventID, e.At
+}
+
+// Snapshot returns a snapshot event of s for chatID, keyed by the last
+// event applied so it takes that event's place in the log.
+func (s ChatState) Snapshot(chatID string) ChatEvent {
+	return ChatEvent{
+		ChatID:          chatID,
+		EventID:         s.LastEventID,
+		Type:            ChatEventSnapshot,
+		At:              s.At,
+		Settings:        s.Settings,
+		SettingsVersion: s.SettingsVersion,
+		Members:         maps.Clone(s.Members),
+	}
+}
+
+// ReplayChatEvents folds events, in log order, into the state they leave
+// the chat in.
+func ReplayChatEvents(events []ChatEvent) ChatState {
+	var s ChatState
+	for _, e := range events {
+		s.Apply(e)
+	}
+	if s.Members == nil {
+		s.Members = make(map[string]MemberRole)
+	}
+	return s
+}
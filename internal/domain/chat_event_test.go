@@ -0,0 +1,75 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestChatEventIDs(t *testing.T) {
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	id := domain.NewChatEventID(at)
+	next := domain.NewChatEventID(at.Add(time.Millisecond))
+
+	assert.Len(t, id, 26)
+	assert.Less(t, id, next)
+	assert.LessOrEqual(t, id, domain.LastChatEventID(at))
+	assert.Greater(t, next, domain.LastChatEventID(at))
+}
+
+func TestReplayChatEvents(t *testing.T) {
+	t0 := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	team := domain.DefaultChatSettings("Team")
+	launch := domain.DefaultChatSettings("Launch")
+	events := []domain.ChatEvent{
+		{EventID: "01", Type: domain.ChatEventMemberJoined, UserID: "user-001", Role: domain.MemberRoleOwner, At: t0},
+		{EventID: "02", Type: domain.ChatEventMemberJoined, UserID: "user-002", Role: domain.MemberRoleMember},
+		{EventID: "03", Type: domain.ChatEventSettingsChanged, Settings: &team, SettingsVersion: 2},
+		{EventID: "04", Type: domain.ChatEventRoleChanged, UserID: "user-002", Role: domain.MemberRoleAdmin},
+		{EventID: "05", Type: domain.ChatEventMemberJoined, UserID: "user-002", Role: domain.MemberRoleMember},
+		{EventID: "06", Type: domain.ChatEventMemberJoined, UserID: "user-003", Role: domain.MemberRoleMember},
+		{EventID: "07", Type: domain.ChatEventOwnershipTransferred, UserID: "user-001", SuccessorID: "user-002"},
+		{EventID: "08", Type: domain.ChatEventMemberRemoved, UserID: "user-003"},
+		{EventID: "09", Type: domain.ChatEventOwnerRemoved, UserID: "user-002", SuccessorID: "user-001", At: t0.Add(time.Hour)},
+	}
+
+	s := domain.ReplayChatEvents(events)
+
+	require.NotNil(t, s.Settings)
+	assert.Equal(t, team, *s.Settings)
+	assert.Equal(t, int64(2), s.SettingsVersion)
+	assert.Equal(t, map[string]domain.MemberRole{"user-001": domain.MemberRoleOwner}, s.Members)
+	assert.Equal(t, "09", s.LastEventID)
+	assert.Equal(t, t0.Add(time.Hour), s.At)
+
+	t.Run("settings replay in version order", func(t *testing.T) {
+		s := domain.ReplayChatEvents([]domain.ChatEvent{
+			{EventID: "01", Type: domain.ChatEventSettingsChanged, Settings: &launch, SettingsVersion: 3},
+			{EventID: "02", Type: domain.ChatEventSettingsChanged, Settings: &team, SettingsVersion: 2},
+		})
+
+		assert.Equal(t, launch, *s.Settings)
+		assert.Equal(t, int64(3), s.SettingsVersion)
+	})
+
+	t.Run("snapshot replaces the state", func(t *testing.T) {
+		snap := domain.ReplayChatEvents(events[:4]).Snapshot("chat-001")
+		s := domain.ReplayChatEvents(append([]domain.ChatEvent{snap}, events[4:]...))
+
+		assert.Equal(t, domain.ChatEventSnapshot, snap.Type)
+		assert.Equal(t, "04", snap.EventID)
+		assert.Equal(t, domain.ReplayChatEvents(events), s)
+	})
+
+	t.Run("unknown events are skipped", func(t *testing.T) {
+		s := domain.ReplayChatEvents([]domain.ChatEvent{{EventID: "01", Type: "renamed"}})
+
+		assert.Empty(t, s.LastEventID)
+		assert.Empty(t, s.Members)
+	})
+}
@@ -0,0 +1,188 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MemberRole is a chat member's role. Roles are ordered: owner > admin >
+// member.
+type MemberRole string
+
+// Member roles.
+const (
+	MemberRoleMember MemberRole = "member"
+	MemberRoleAdmin  MemberRole = "admin"
+	MemberRoleOwner  MemberRole = "owner"
+)
+
+// rank orders roles for permission checks; unknown roles rank lowest.
+func (r MemberRole) rank() int {
+	switch r {
+	case MemberRoleOwner:
+		return 3
+	case MemberRoleAdmin:
+		return 2
+	case MemberRoleMember:
+		return 1
+	}
+	return 0
+}
+
+// Valid reports whether r is a known role.
+func (r MemberRole) Valid() bool { return r.rank() > 0 }
+
+// AtLeast reports whether r is min or a higher role.
+func (r MemberRole) AtLeast(min MemberRole) bool { return r.Valid() && r.rank() >= min.rank() }
+
+// PermissionPolicy says which members may perform a chat action.
+type PermissionPolicy string
+
+// Permission policies.
+const (
+	PermissionEveryone PermissionPolicy = "everyone" // any member
+	PermissionAdmins   PermissionPolicy = "admins"   // admins and the owner
+)
+
+// Valid reports whether p is a known policy.
+func (p PermissionPolicy) Valid() bool {
+	return p == PermissionEveryone || p == PermissionAdmins
+}
+
+// Allows reports whether a member with role may act under p.
+func (p PermissionPolicy) Allows(role MemberRole) bool {
+	if p == PermissionAdmins {
+		return role.AtLeast(MemberRoleAdmin)
+	}
+	return role.Valid()
+}
+
+// ChatSettings are a group chat's admin-editable settings, stored on its
+// chats record.
+type ChatSettings struct {
+	Name        string
+	AvatarURL   string
+	Description string
+	// WhoCanPost and WhoCanAddMembers gate sending and adding members.
+	WhoCanPost       PermissionPolicy
+	WhoCanAddMembers PermissionPolicy
+	// SlowModeSeconds is the minimum gap between one member's messages;
+	// zero disables slow mode. Admins are exempt.
+	SlowModeSeconds int
+}
+
+// DefaultChatSettings returns the settings of a new group chat.
+func DefaultChatSettings(name string) ChatSettings {
+	return ChatSettings{
+		Name:             name,
+		WhoCanPost:       PermissionEveryone,
+		WhoCanAddMembers: PermissionAdmins,
+	}
+}
+
+// Validate checks every field against its limits.
+func (s ChatSettings) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return NewValidationError("name", "is required")
+	}
+	if utf8.RuneCountInString(s.Name) > MaxChatNameLength {
+		return NewValidationError("name", fmt.Sprintf("at most %d characters", MaxChatNameLength))
+	}
+	if utf8.RuneCountInString(s.Description) > MaxChatDescriptionLength {
+		return NewValidationError("description", fmt.Sprintf("at most %d characters", MaxChatDescriptionLength))
+	}
+	if s.AvatarURL != "" {
+		u, err := url.Parse(s.AvatarURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return NewValidationError("avatar_url", "must be an https URL")
+		}
+	}
+	if !s.WhoCanPost.Valid() {
+		return NewValidationError("who_can_post", fmt.Sprintf("unknown policy %q", s.WhoCanPost))
+	}
+	if !s.WhoCanAddMembers.Valid() {
+		return NewValidationError("who_can_add_members", fmt.Sprintf("unknown policy %q", s.WhoCanAddMembers))
+	}
+	if s.SlowModeSeconds < 0 || s.SlowModeSeconds > MaxSlowModeSeconds {
+		return NewValidationError("slow_mode_seconds", fmt.Sprintf("must be in [0, %d]", MaxSlowModeSeconds))
+	}
+	return nil
+}
+
+// ChatSettingsPatch is a partial settings update; nil fields are unchanged.
+type ChatSettingsPatch struct {
+	Name             *string
+	AvatarURL        *string
+	Description      *string
+	WhoCanPost       *PermissionPolicy
+	WhoCanAddMembers *PermissionPolicy
+	SlowModeSeconds  *int
+}
+
+// Apply returns s with the patch applied and the wire names of the fields
+// whose value changed, in a fixed order.
+func (p ChatSettingsPatch) Apply(s ChatSettings) (ChatSettings, []string) {
+	var changed []string
+	set := func(field string, differs bool, assign func()) {
+		if differs {
+			assign()
+			changed = append(changed, field)
+		}
+	}
+	if p.Name != nil {
+		set("name", *p.Name != s.Name, func() { s.Name = *p.Name })
+	}
+	if p.AvatarURL != nil {
+		set("avatar_url", *p.AvatarURL != s.AvatarURL, func() { s.AvatarURL = *p.AvatarURL })
+	}
+	if p.Description != nil {
+		set("description", *p.Description != s.Description, func() { s.Description = *p.Description })
+	}
+	if p.WhoCanPost != nil {
+		set("who_can_post", *p.WhoCanPost != s.WhoCanPost, func() { s.WhoCanPost = *p.WhoCanPost })
+	}
+	if p.WhoCanAddMembers != nil {
+		set("who_can_add_members", *p.WhoCanAddMembers != s.WhoCanAddMembers, func() { s.WhoCanAddMembers = *p.WhoCanAddMembers })
+	}
+	if p.SlowModeSeconds != nil {
+		set("slow_mode_seconds", *p.SlowModeSeconds != s.SlowModeSeconds, func() { s.SlowModeSeconds = *p.SlowModeSeconds })
+	}
+	return s, changed
+}
+
+// SettingsChangeMessages returns the system messages announcing a settings
+// change by actorID: chat_renamed for a new name, and settings_changed
+// listing any other changed fields with their new values.
+func SettingsChangeMessages(actorID string, s ChatSettings, changed []string) []SystemMessage {
+	var msgs []SystemMessage
+	params := map[string]string{}
+	var others []string
+	for _, field := range changed {
+		switch field {
+		case "name":
+			msgs = append(msgs, SystemMessage{
+				Event:   SystemEventChatRenamed,
+				ActorID: actorID,
+				Params:  map[string]string{"name": s.Name},
+			})
+			continue
+		case "who_can_post":
+			params[field] = string(s.WhoCanPost)
+		case "who_can_add_members":
+			params[field] = string(s.WhoCanAddMembers)
+		case "slow_mode_seconds":
+			params[field] = strconv.Itoa(s.SlowModeSeconds)
+		}
+		// Avatar and description values are not repeated into the chat;
+		// clients read them from the chat record.
+		others = append(others, field)
+	}
+	if len(others) > 0 {
+		params["fields"] = strings.Join(others, ",")
+		msgs = append(msgs, SystemMessage{Event: SystemEventSettingsChanged, ActorID: actorID, Params: params})
+	}
+	return msgs
+}
@@ -0,0 +1,93 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestMemberRole_AtLeast(t *testing.T) {
+	assert.True(t, domain.MemberRoleOwner.AtLeast(domain.MemberRoleAdmin))
+	assert.True(t, domain.MemberRoleAdmin.AtLeast(domain.MemberRoleAdmin))
+	assert.False(t, domain.MemberRoleMember.AtLeast(domain.MemberRoleAdmin))
+	assert.False(t, domain.MemberRole("guest").AtLeast(domain.MemberRoleMember))
+}
+
+func TestPermissionPolicy_Allows(t *testing.T) {
+	assert.True(t, domain.PermissionEveryone.Allows(domain.MemberRoleMember))
+	assert.False(t, domain.PermissionAdmins.Allows(domain.MemberRoleMember))
+	assert.True(t, domain.PermissionAdmins.Allows(domain.MemberRoleOwner))
+	assert.False(t, domain.PermissionEveryone.Allows(""))
+}
+
+func TestChatSettings_Validate(t *testing.T) {
+	valid := domain.DefaultChatSettings("Team")
+
+	tests := []struct {
+		name   string
+		mutate func(*domain.ChatSettings)
+		field  string
+	}{
+		{name: "defaults are valid"},
+		{name: "blank name", mutate: func(s *domain.ChatSettings) { s.Name = "  " }, field: "name"},
+		{name: "long name", mutate: func(s *domain.ChatSettings) { s.Name = strings.Repeat("é", domain.MaxChatNameLength+1) }, field: "name"},
+		{name: "long description", mutate: func(s *domain.ChatSettings) {
+			s.Description = strings.Repeat("x", domain.MaxChatDescriptionLength+1)
+		}, field: "description"},
+		{name: "http avatar", mutate: func(s *domain.ChatSettings) { s.AvatarURL = "http://cdn.example.com/a.png" }, field: "avatar_url"},
+		{name: "https avatar", mutate: func(s *domain.ChatSettings) { s.AvatarURL = "https://cdn.example.com/a.png" }},
+		{name: "unknown post policy", mutate: func(s *domain.ChatSettings) { s.WhoCanPost = "owner" }, field: "who_can_post"},
+		{name: "negative slow mode", mutate: func(s *domain.ChatSettings) { s.SlowModeSeconds = -1 }, field: "slow_mode_seconds"},
+		{name: "slow mode too long", mutate: func(s *domain.ChatSettings) { s.SlowModeSeconds = domain.MaxSlowModeSeconds + 1 }, field: "slow_mode_seconds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			if tt.mutate != nil {
+				tt.mutate(&s)
+			}
+
+			err := s.Validate()
+
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			assert.Contains(t, err.Error(), tt.field)
+		})
+	}
+}
+
+func TestChatSettingsPatch_Apply(t *testing.T) {
+	s := domain.DefaultChatSettings("Team")
+	name, same := "Launch", domain.PermissionEveryone
+	slow := 30
+
+	got, changed := domain.ChatSettingsPatch{Name: &name, WhoCanPost: &same, SlowModeSeconds: &slow}.Apply(s)
+
+	assert.Equal(t, "Launch", got.Name)
+	assert.Equal(t, 30, got.SlowModeSeconds)
+	assert.Equal(t, []string{"name", "slow_mode_seconds"}, changed)
+	assert.Equal(t, "Team", s.Name, "input is not modified")
+}
+
+func TestSettingsChangeMessages(t *testing.T) {
+	s := domain.DefaultChatSettings("Launch")
+	s.SlowModeSeconds = 30
+
+	msgs := domain.SettingsChangeMessages("user-001", s, []string{"name", "description", "slow_mode_seconds"})
+
+	assert.Equal(t, []domain.SystemMessage{
+		{Event: domain.SystemEventChatRenamed, ActorID: "user-001", Params: map[string]string{"name": "Launch"}},
+		{Event: domain.SystemEventSettingsChanged, ActorID: "user-001", Params: map[string]string{
+			"fields":            "description,slow_mode_seconds",
+			"slow_mode_seconds": "30",
+		}},
+	}, msgs)
+	assert.Empty(t, domain.SettingsChangeMessages("user-001", s, nil))
+}
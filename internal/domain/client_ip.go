@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPResolver picks the client address out of a request's peer
+// address and X-Forwarded-For chain. Forwarded entries are honored only
+// as far as they were appended by trusted proxies: the chain is walked
+// right to left from the peer, and the first hop outside the trusted
+// ranges is the client. Entries a client writes itself sit left of that
+// hop and are never reached. A nil *ClientIPResolver trusts no proxy and
+// always returns the peer.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver builds a resolver trusting the given proxy ranges,
+// e.g. the load balancer subnets. It returns ErrInvalidInput for a
+// malformed CIDR. A bare address is treated as a single-host range.
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	trusted, err := parsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientIPResolver{trusted: trusted}, nil
+}
+
+// ClientIP returns the client address of a request whose transport peer
+// is peer (an address, with or without a port) and which carried the
+// forwardedFor header values in order of appearance.
+func (r *ClientIPResolver) ClientIP(peer string, forwardedFor []string) string {
+	client := hostOnly(peer)
+	if r == nil || len(r.trusted) == 0 {
+		return client
+	}
+
+	var hops []string
+	for _, v := range forwardedFor {
+		for hop := range strings.SplitSeq(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0 && r.trusts(client); i-- {
+		client = hostOnly(hops[i])
+	}
+	return client
+}
+
+func (r *ClientIPResolver) trusts(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	return containsAddr(r.trusted, addr.Unmap())
+}
+
+// hostOnly strips the port from addr, if it has one.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,51 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := domain.NewClientIPResolver([]string{"10.0.0.0/8", "2001:db8::/32", "127.0.0.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		peer         string
+		forwardedFor []string
+		want         string
+	}{
+		{"untrusted peer ignores forwarded-for", "203.0.113.7:443", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted peer without forwarded-for", "10.0.0.5:443", nil, "10.0.0.5"},
+		{"trusted peer honors the hop it appended", "10.0.0.5:443", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entries left of the client are skipped", "10.0.0.5:443", []string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{"trusted hops are walked", "127.0.0.1", []string{"198.51.100.1, 203.0.113.7, 10.1.2.3"}, "203.0.113.7"},
+		{"values across header lines", "10.0.0.5:443", []string{"198.51.100.1", "203.0.113.7, 10.1.2.3"}, "203.0.113.7"},
+		{"hops with ports", "[2001:db8::1]:443", []string{"203.0.113.7:5000"}, "203.0.113.7"},
+		{"IPv4-mapped peer", "[::ffff:10.0.0.5]:443", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"all hops trusted returns the leftmost", "10.0.0.5:443", []string{"10.1.1.1, 10.2.2.2"}, "10.1.1.1"},
+		{"malformed hop stops the walk", "10.0.0.5:443", []string{"203.0.113.7, junk"}, "junk"},
+		{"unparseable peer", "bufconn", []string{"203.0.113.7"}, "bufconn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolver.ClientIP(tt.peer, tt.forwardedFor))
+		})
+	}
+
+	t.Run("invalid cidr", func(t *testing.T) {
+		_, err := domain.NewClientIPResolver([]string{"10.0.0.0/33"})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestClientIPResolver_NilTrustsNoProxy(t *testing.T) {
+	var resolver *domain.ClientIPResolver
+
+	assert.Equal(t, "10.0.0.5", resolver.ClientIP("10.0.0.5:443", []string{"203.0.113.7"}))
+	assert.Equal(t, "", resolver.ClientIP("", []string{"203.0.113.7"}))
+}
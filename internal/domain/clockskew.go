@@ -0,0 +1,117 @@
+package domain
+
+import "time"
+
+// ServerTime is a timestamp read from a server clock. It is the only time
+// type with an ordering: anything that sorts or compares by time uses
+// ServerTime, and messages themselves are ordered by sequence (ADR-001 §6).
+type ServerTime struct{ t time.Time }
+
+// ServerNow stamps the current time from c.
+func ServerNow(c Clock) ServerTime {
+	return ServerTime{t: c.Now().UTC()}
+}
+
+// ServerTimeFromMillis rebuilds a ServerTime the server stamped earlier, such
+// as one read back from storage. It must not be fed client-supplied values;
+// use NewClientTime for those.
+func ServerTimeFromMillis(ms int64) ServerTime {
+	return ServerTime{t: FromMillis(ms)}
+}
+
+// Time returns t as a time.Time.
+func (t ServerTime) Time() time.Time { return t.t }
+
+// UnixMilli returns t as UTC milliseconds since epoch.
+func (t ServerTime) UnixMilli() int64 { return t.t.UnixMilli() }
+
+// IsZero reports whether t is unset.
+func (t ServerTime) IsZero() bool { return t.t.IsZero() }
+
+// Compare returns -1, 0 or +1 as t is before, equal to or after u.
+func (t ServerTime) Compare(u ServerTime) int { return t.t.Compare(u.t) }
+
+// Before reports whether t is before u.
+func (t ServerTime) Before(u ServerTime) bool { return t.t.Before(u.t) }
+
+// Sub returns t - u.
+func (t ServerTime) Sub(u ServerTime) time.Duration { return t.t.Sub(u.t) }
+
+// ClientTime is a timestamp read from a client's clock. Client clocks drift
+// and can be set to anything, so ClientTime deliberately has no ordering
+// methods: it is carried for display and diagnostics only.
+type ClientTime struct{ ms int64 }
+
+// NewClientTime wraps a client-supplied UTC milliseconds timestamp.
+func NewClientTime(ms int64) ClientTime {
+	return ClientTime{ms: ms}
+}
+
+// UnixMilli returns t as the client reported it.
+func (t ClientTime) UnixMilli() int64 { return t.ms }
+
+// IsZero reports whether the client sent no timestamp.
+func (t ClientTime) IsZero() bool { return t.ms == 0 }
+
+// clockSmoothing is the weight of a new sample in the smoothed RTT and
+// offset, as for the TCP RTT estimator (RFC 6298).
+const clockSmoothing = 0.125
+
+// ClockSkewEstimator estimates how far a client's clock is from the
+// server's. Round trips are measured from server pings, whose pongs echo
+// the server's own timestamp, so RTT never depends on the client clock. A
+// client ping stamped c and received at s then gives the offset sample
+// c - (s - RTT/2): the client's clock reading minus the server's at the
+// moment the client sent it. Both are exponentially smoothed.
+//
+// A ClockSkewEstimator is not safe for concurrent use.
+type ClockSkewEstimator struct {
+	rtt     time.Duration
+	offset  time.Duration
+	rtts    int
+	offsets int
+}
+
+// ObserveRoundTrip records a server ping sent at sent whose pong arrived at
+// received. Samples with a negative duration are ignored.
+func (e *ClockSkewEstimator) ObserveRoundTrip(sent, received ServerTime) {
+	sample := received.Sub(sent)
+	if sample < 0 {
+		return
+	}
+	e.rtt = smooth(e.rtt, sample, e.rtts)
+	e.rtts++
+}
+
+// ObserveClientTime records a client timestamp received at received and
+// returns the updated offset estimate. Zero timestamps are ignored.
+func (e *ClockSkewEstimator) ObserveClientTime(sent ClientTime, received ServerTime) time.Duration {
+	if sent.IsZero() {
+		return e.offset
+	}
+	serverAtSend := received.UnixMilli() - (e.rtt / 2).Milliseconds()
+	sample := time.Duration(sent.UnixMilli()-serverAtSend) * time.Millisecond
+	e.offset = smooth(e.offset, sample, e.offsets)
+	e.offsets++
+	return e.offset
+}
+
+// RoundTrip returns the smoothed round-trip time and whether any has been
+// measured.
+func (e *ClockSkewEstimator) RoundTrip() (time.Duration, bool) {
+	return e.rtt, e.rtts > 0
+}
+
+// Offset returns the smoothed client-minus-server clock offset and whether
+// any has been measured. A positive offset means the client clock is ahead.
+func (e *ClockSkewEstimator) Offset() (time.Duration, bool) {
+	return e.offset, e.offsets > 0
+}
+
+// smooth folds sample into avg; the first sample is taken as is.
+func smooth(avg, sample time.Duration, n int) time.Duration {
+	if n == 0 {
+		return sample
+	}
+	return avg + time.Duration(clockSmoothing*float64(sample-avg))
+}
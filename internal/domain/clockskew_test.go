@@ -0,0 +1,78 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+func TestServerTime(t *testing.T) {
+	base := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	clock := domaintest.NewFakeClock(base)
+
+	earlier := domain.ServerNow(clock)
+	clock.Advance(time.Second)
+	later := domain.ServerNow(clock)
+
+	assert.True(t, earlier.Before(later))
+	assert.Equal(t, -1, earlier.Compare(later))
+	assert.Equal(t, time.Second, later.Sub(earlier))
+	assert.Equal(t, later, domain.ServerTimeFromMillis(later.UnixMilli()))
+	assert.True(t, domain.ServerTime{}.IsZero())
+}
+
+func TestClientTime(t *testing.T) {
+	assert.True(t, domain.NewClientTime(0).IsZero())
+	assert.Equal(t, int64(1700000000000), domain.NewClientTime(1700000000000).UnixMilli())
+}
+
+func TestClockSkewEstimator(t *testing.T) {
+	base := domain.ServerTimeFromMillis(1_700_000_000_000)
+	at := func(ms int64) domain.ServerTime { return domain.ServerTimeFromMillis(base.UnixMilli() + ms) }
+
+	t.Run("no samples", func(t *testing.T) {
+		var e domain.ClockSkewEstimator
+
+		_, ok := e.Offset()
+		assert.False(t, ok)
+		_, ok = e.RoundTrip()
+		assert.False(t, ok)
+	})
+
+	t.Run("offset corrects for half the round trip", func(t *testing.T) {
+		var e domain.ClockSkewEstimator
+		e.ObserveRoundTrip(at(0), at(200))
+
+		// Client is 5s ahead: it sent at server time 1000, i.e. client 6000,
+		// and the frame took 100ms to arrive.
+		got := e.ObserveClientTime(domain.NewClientTime(base.UnixMilli()+6000), at(1100))
+
+		assert.Equal(t, 5*time.Second, got)
+		rtt, ok := e.RoundTrip()
+		assert.True(t, ok)
+		assert.Equal(t, 200*time.Millisecond, rtt)
+	})
+
+	t.Run("samples are smoothed", func(t *testing.T) {
+		var e domain.ClockSkewEstimator
+		e.ObserveClientTime(domain.NewClientTime(base.UnixMilli()), at(0))
+		got := e.ObserveClientTime(domain.NewClientTime(base.UnixMilli()+8000), at(0))
+
+		assert.Equal(t, time.Second, got)
+	})
+
+	t.Run("invalid samples are ignored", func(t *testing.T) {
+		var e domain.ClockSkewEstimator
+		e.ObserveRoundTrip(at(100), at(0))
+		e.ObserveClientTime(domain.ClientTime{}, at(0))
+
+		_, ok := e.RoundTrip()
+		assert.False(t, ok)
+		_, ok = e.Offset()
+		assert.False(t, ok)
+	})
+}
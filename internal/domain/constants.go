@@ -9,51 +9,377 @@ const (
 	MaxMessageSize           = 64 * 1024 // 64 KB max message body
 	MaxClientMessageIDLength = 128       // Max length for client-provided message IDs
 
+	// Idempotency (ADR-001 §3). Ingest remembers each client message ID's
+	// result for IdempotencyKeyTTL, so a retry within it gets the original
+	// sequence instead of a second message.
+	IdempotencyKeyTTL = 7 * 24 * time.Hour
+
+	// Inbound frame limits (ADR-005). A client frame carries at most one
+	// message; the size leaves room for its envelope and JSON escaping.
+	MaxInboundFrameSize  = 4 * MaxMessageSize
+	MaxInboundFrameDepth = 16 // Nested JSON objects and arrays, the frame included
+
+	// Chunked attachment uploads (ADR-005 §3.13). Clients send files in
+	// chunks of at most UploadChunkSize bytes, base64-encoded so a chunk
+	// frame fits MaxInboundFrameSize; the Gateway assembles them into
+	// UploadPartSize parts of an S3 multipart upload. An upload resumes on
+	// any pod for UploadSessionTTL after its last stored part. Files are
+	// capped by the uploader's entitlement (MaxAttachmentSize).
+	UploadChunkSize         = 128 * 1024
+	UploadPartSize          = 5 * 1024 * 1024 // S3's minimum part size
+	MaxUploadsPerConnection = 2
+	UploadSessionTTL        = 24 * time.Hour
+
 	// Chat limits
 	MaxGroupSize          = 100 // Maximum members in a group chat
 	MaxConcurrentChats    = 500 // Maximum chats a user can be a member of
 	MaxDirectChatsPerPair = 1   // Exactly one direct chat per user pair
+	MaxMessageShards      = 16  // Partitions a hot chat's messages may be spread over
 
-	// Connection limits (ADR-009 §3)
-	MaxConnectionsPerUser     = 5  // Max concurrent WebSocket connections per user
-	MaxConnectionsPerIP       = 20 // Max concurrent connections from a single IP
+	// Chat settings limits
+	MaxChatNameLength        = 100         // Characters in a group chat name
+	MaxChatDescriptionLength = 500         // Characters in a group chat description
+	MaxSlowModeSeconds       = 6 * 60 * 60 // Longest slow mode interval (6h)
+	SettingsUpdateAttempts   = 3           // Read-modify-write attempts on a version conflict
+	OwnerSuccessionAttempts  = 3           // Successor picks per chat when the pick stops being a member
+
+	// Chat event log. Settings and membership changes stay in the log for
+	// ChatEventRetention; Chat Mgmt folds older events into one snapshot
+	// per chat every ChatEventCompactInterval.
+	ChatEventRetention       = 90 * 24 * time.Hour
+	ChatEventCompactInterval = 24 * time.Hour
+
+	// Connection limits (ADR-009 §3). Concurrent connections are counted
+	// across all Gateway pods in the Redis connection registry, where each
+	// connection holds a lease for ConnectionTTL, renewed every
+	// HeartbeatInterval. The per-device limit leaves room for a reconnect
+	// to overlap the old connection's lease.
+	MaxConnectionsPerUser     = MaxSessionsPerUser // Max concurrent connections per user
+	MaxConnectionsPerDevice   = 2                  // Max concurrent connections per device
+	MaxConnectionsPerIP       = 20                 // Max concurrent connections from a single IP
 	ConnectionRateLimitWindow = 10 * time.Second
-	ConnectionRateLimit       = 5 // Max new connections per user per window
+	ConnectionRateLimit       = 5  // Max new connections per user per window
+	RegistryShards            = 64 // Lock shards in the Gateway connection registry
 
 	// Buffer limits (ADR-009 §2 Backpressure)
 	OutboundBufferSize    = 256             // Messages buffered per connection before backpressure
 	OutboundBufferTimeout = 5 * time.Second // Time to drain buffer before disconnect
 	SlowConsumerThreshold = 100             // Buffer depth that triggers slow consumer warning
+	FanoutDeliveryLanes   = 16              // Partition lane sets per Fanout instance; higher partitions share them
+	FanoutLaneCapacity    = 1024            // Deliveries buffered per priority lane of a partition
+
+	// Delivery acknowledgment (ADR-005 §3.5). A connection holds at most
+	// AckWindowSize message frames the client has not acked; unacked frames
+	// of a closed connection are kept for AckResendRetention and resent when
+	// the same device reconnects to the pod.
+	AckWindowSize      = 256
+	AckResendRetention = 2 * time.Minute
+
+	// Delivery cursors (ADR-007 §2.7). Acks advance a device's per-chat
+	// cursor in memory; the Gateway writes cursors to Redis every
+	// DeliveryCursorFlushInterval and checkpoints them to DynamoDB every
+	// DeliveryCursorCheckpointInterval. Redis keeps a device's cursors for
+	// DeliveryCursorTTL after its last ack.
+	DeliveryCursorFlushInterval      = time.Second
+	DeliveryCursorCheckpointInterval = time.Minute
+	DeliveryCursorTTL                = 7 * 24 * time.Hour
+
+	// Frame batching (ADR-005 §2). Pending frames for a connection that
+	// negotiated the batch capability are coalesced into one WebSocket message.
+	MaxBatchFrames = 32                   // Frames per batch; 1 disables batching
+	MaxBatchDelay  = 2 * time.Millisecond // Longest a burst of frames waits for more
+
+	// WebSocket transport (ADR-005 §1). A client that cannot take one frame
+	// within WebSocketWriteTimeout is disconnected. With the epoll reader, so
+	// is one that stalls part way through sending a frame for
+	// EpollFrameTimeout, rather than holding a read worker.
+	WebSocketWriteTimeout = 10 * time.Second
+	EpollFrameTimeout     = 5 * time.Second
+
+	// Gateway admission control (ADR-009 §2). Ephemeral frames are shed at
+	// AdmissionShedRatio of any limit; new connections are refused at the
+	// limit. Memory has no compiled default: it depends on the container.
+	AdmissionMaxCPU         = 0.85    // Fraction of GOMAXPROCS CPU time
+	AdmissionMaxGoroutines  = 200_000 // ~3 per connection plus headroom
+	AdmissionShedRatio      = 0.8
+	AdmissionSampleInterval = 1 * time.Second
+
+	// Gateway spam heuristics. A sender repeating the same content within
+	// SpamDuplicateWindow is throttled, then blocked; a sender younger than
+	// SpamNewAccountAge may send SpamNewAccountSendsPerMinute messages per
+	// minute. Link-heavy messages are shadow-flagged.
+	SpamDuplicateWindow          = 10 * time.Minute
+	SpamDuplicateThrottleAt      = 3
+	SpamDuplicateBlockAt         = 6
+	SpamMinLinks                 = 2
+	SpamMaxLinkDensity           = 0.5 // Links per word
+	SpamNewAccountAge            = 24 * time.Hour
+	SpamNewAccountSendsPerMinute = 10
+
+	// Broadcast serialization cache. One entry per (message, encoding,
+	// compression); a message is delivered in a burst and then evicted.
+	BroadcastCacheEntries = 10_000
+
+	// Gateway access token cache. Sized for the tokens presented within one
+	// access TTL by a pod's reconnecting clients.
+	AuthCacheEntries = 10_000
 
 	// Heartbeat configuration (ADR-005 §6, ADR-009)
 	HeartbeatInterval = 30 * time.Second // Server sends ping every 30s
 	HeartbeatTimeout  = 30 * time.Second // Client must respond within 30s
 	ConnectionTTL     = 60 * time.Second // Redis key TTL = 2x heartbeat interval
 
+	// Server-driven reconnect policy (ADR-005 §6), sent in connection_closing
+	// and retryable error frames. Raise the backoff during incidents to slow
+	// reconnect storms without a client release.
+	ReconnectMinBackoff  = 1 * time.Second
+	ReconnectMaxBackoff  = 30 * time.Second
+	ReconnectJitter      = 0.25 // ± fraction of each delay (CLIENT_PROTOCOL_CONTRACT CL-06)
+	ReconnectRetryBudget = 10   // Attempts before the client stops retrying
+
 	// Timeout contracts (ADR-009 §1)
-	DynamoDBTimeout     = 5 * time.Second  // Max time for DynamoDB operations
-	KafkaProduceTimeout = 10 * time.Second // Max time for Kafka produce
-	RedisTimeout        = 2 * time.Second  // Max time for Redis operations
-	GRPCCallTimeout     = 10 * time.Second // Max time for inter-service gRPC calls
+	DynamoDBTimeout     = 5 * time.Second        // Max time for DynamoDB operations
+	KafkaProduceTimeout = 10 * time.Second       // Max time for Kafka produce
+	RedisTimeout        = 2 * time.Second        // Max time for Redis operations
+	GRPCCallTimeout     = 10 * time.Second       // Max time for inter-service gRPC calls
+	DeliveryTimeout     = 100 * time.Millisecond // Max time for one Fanout → Gateway publish (ADR-002 §4.3)
+	SignalTimeout       = 500 * time.Millisecond // Max time for one Gateway → Fanout activity signal; best effort
+	PushTimeout         = 5 * time.Second        // Max time for one request to a push service
+
+	// HTTP server timeouts and route limits. Standard routes are bounded
+	// by the write timeout, long-poll routes by HTTPLongPollTimeout;
+	// streaming routes (SSE, WebSocket, NDJSON) lift both.
+	HTTPReadHeaderTimeout = 5 * time.Second
+	HTTPReadTimeout       = 10 * time.Second
+	HTTPWriteTimeout      = 10 * time.Second
+	HTTPIdleTimeout       = 60 * time.Second
+	HTTPLongPollTimeout   = 60 * time.Second
+	HTTPMaxBodyBytes      = 1 << 20          // Request body cap unless a route sets its own
+	HTTPCORSMaxAge        = 10 * time.Minute // Browser preflight cache lifetime
 
 	// Graceful shutdown budget (ADR-014 §4.1)
-	GracefulShutdownTimeout = 30 * time.Second // Total shutdown budget
-	ShutdownDrainDelay      = 3 * time.Second  // Pause after failing health checks for LB propagation
-	ShutdownHTTPTimeout     = 20 * time.Second // HTTP server drain for in-flight requests
-	ShutdownOTELTimeout     = 5 * time.Second  // OTEL tracer + metrics flush
+	GracefulShutdownTimeout  = 30 * time.Second // Total shutdown budget
+	ShutdownDrainDelay       = 3 * time.Second  // Pause after failing health checks for LB propagation
+	ShutdownConnDrainTimeout = 10 * time.Second // Drain slot wait + closing long-lived connections
+	ShutdownHTTPTimeout      = 20 * time.Second // HTTP server drain for in-flight requests
+	ShutdownOTELTimeout      = 5 * time.Second  // OTEL tracer + metrics flush
+
+	// Log sampling: records kept per second for each subsystem and message
+	// at info level and below. Warnings and errors are never sampled.
+	LogSampleRate = 100
+
+	// Ingest shadow traffic. A sampled share of chats is also written to a
+	// secondary persistence path and the results compared off the request
+	// path, to rehearse storage migrations against production traffic.
+	ShadowTimeout     = 2 * time.Second // Max time for one shadow write
+	ShadowMaxInFlight = 256             // Concurrent shadow writes before sampling skips
+
+	// SLO burn rate alert evaluation (ADR-012 §2.4)
+	SLOEvaluateInterval = 1 * time.Minute
+
+	// Fanout consumer lag alerts. A partition alerts when it is
+	// ConsumerLagAlertRecords behind (ADR-012 HighConsumerLag), or when its
+	// oldest unprocessed event has waited ConsumerLagAlertAge: that is the
+	// delivery latency objective (ADR-012 §2.2), so every event behind it
+	// is already missing the SLO.
+	ConsumerLagAlertRecords     = 10_000
+	ConsumerLagAlertAge         = 2 * time.Second
+	ConsumerLagEvaluateInterval = 15 * time.Second
+
+	// Startup warmup (ADR-018). Services register steps that must finish
+	// before /readyz reports ready.
+	WarmupStepTimeout = 10 * time.Second // Default bound per warmup step
+
+	// Rolling-deploy drain coordination (ADR-014 §4.1). Gateway pods take a
+	// shared slot before closing their connections so reconnects from one
+	// pod land before the next pod starts draining.
+	MaxConcurrentDrains = 2               // Gateway pods draining at once
+	DrainSlotWait       = 5 * time.Second // Wait for a slot before draining anyway
+	DrainSlotTTL        = GracefulShutdownTimeout
 
 	// Rate limiting (ADR-013 §4.1)
-	OTPRequestRateLimitPerPhone = 3                // Max OTP requests per phone per window
-	OTPRequestRateLimitPerIP    = 10               // Max OTP requests per IP per window
-	OTPRateLimitWindow          = 15 * time.Minute // Rate limit window for OTP requests
-	OTPValidityDuration         = 5 * time.Minute  // How long an OTP remains valid
-	MaxOTPVerifyAttempts        = 5                // Max verification attempts before lockout
-	OTPLockoutDuration          = 15 * time.Minute // Lockout duration after max attempts
+	OTPRequestRateLimitPerPhone  = 3                // Max OTP requests per phone per window
+	OTPRequestRateLimitPerIP     = 10               // Max OTP requests per IP per window
+	OTPRequestRateLimitPerSubnet = 30               // Max OTP requests per subnet (RateLimitSubnet) per window
+	OTPRateLimitWindow           = 15 * time.Minute // Rate limit window for OTP requests
+	OTPValidityDuration          = 5 * time.Minute  // How long an OTP remains valid
+	MaxOTPVerifyAttempts         = 5                // Max verification attempts before lockout
+	OTPLockoutDuration           = 15 * time.Minute // Lockout duration after max attempts
+	OTPResendDelay               = 30 * time.Second // Wait before resending a live OTP; doubles with each resend
+
+	// Subnet sizes for rate limits that aggregate client addresses: the
+	// smallest allocation a single subscriber usually controls.
+	RateLimitSubnetV4Bits = 24
+	RateLimitSubnetV6Bits = 64
+
+	// REST request throttling. Each tier counts requests in a fixed window;
+	// the per-user tier applies to requests with a valid access token.
+	HTTPThrottlePerIP   = 600 // Max REST requests per client address per window
+	HTTPThrottlePerUser = 300 // Max REST requests per user per window
+	HTTPThrottleWindow  = time.Minute
+
+	// OTP code policy bounds. A policy may not offer fewer codes than the
+	// 6-digit default, nor stay valid longer than it.
+	OTPMinCodeSpace   = 1_000_000
+	OTPMaxCodeLength  = 12
+	OTPMinValidity    = 1 * time.Minute
+	OTPMaxValidity    = OTPValidityDuration
+	OTPElevatedLength = 8
+	OTPElevatedTTL    = 3 * time.Minute
+	OTPHighLength     = 8
+	OTPHighTTL        = 2 * time.Minute
+
+	// Versioned peppers keying phone hashes and OTP MACs. An old version
+	// must stay configured for PepperRetireAfter once a newer one is
+	// current, so OTPs issued under it can still be verified.
+	MinAuthPepperBytes = 32
+	PepperRetireAfter  = OTPMaxValidity
+
+	// Bulk user import. Rows are written at ImportRatePerSecond so a
+	// migration leaves table capacity for live traffic; progress is reported
+	// every ImportProgressEvery rows.
+	ImportRatePerSecond  = 50
+	ImportProgressEvery  = 1000
+	MaxImportChats       = MaxConcurrentChats // Chats one imported user may join
+	MaxDisplayNameLength = 64                 // Characters in a user's display name
+
+	// Admin user directory. A listing without a start date covers the last
+	// UserListDefaultWindow; no listing spans more than UserListMaxWindow,
+	// which bounds the month partitions one page may read.
+	UserListDefaultWindow   = 30 * 24 * time.Hour
+	UserListMaxWindow       = 366 * 24 * time.Hour
+	MaxUserFlagReasonLength = 200 // Characters in a support flag's reason
+
+	// Product analytics (internal/analytics). Events queue in memory and
+	// are produced in batches of AnalyticsBatchEvents at least every
+	// AnalyticsFlushInterval; when AnalyticsBufferEvents are queued, new
+	// events are dropped rather than slowing the request path.
+	AnalyticsBufferEvents         = 10_000
+	AnalyticsBatchEvents          = 500
+	AnalyticsFlushInterval        = 1 * time.Second
+	MinAnalyticsPseudonymKeyBytes = 32
+
+	// Billing entitlements. Premium raises the free limits (MaxGroupSize,
+	// MaxAttachmentSize); a lapsed subscription keeps them for
+	// EntitlementGracePeriod. Webhook signatures older than
+	// BillingWebhookTolerance are rejected as replays.
+	MaxAttachmentSize             = 25 << 20 // 25 MiB
+	PremiumMaxGroupSize           = 1000
+	PremiumMaxAttachmentSize      = 100 << 20 // 100 MiB
+	EntitlementGracePeriod        = 72 * time.Hour
+	BillingWebhookTolerance       = 5 * time.Minute
+	MaxBillingWebhookBodyBytes    = 64 << 10
+	MinBillingWebhookSecretLength = 16
+
+	// Conversation import from chat exports. Messages are written at
+	// ConversationImportRatePerSecond, one chat at a time, to keep the
+	// chat's message partition under its write limit.
+	ConversationImportRatePerSecond = 100
+	MaxConversationImportMessages   = 1_000_000
+	MaxChatExportBytes              = 512 << 20 // Uncompressed export size
+
+	// Message content encryption at rest. Each chat's content is sealed
+	// under a per-chat data key wrapped by KMS; a chat gets a new key
+	// version once its current one is ChatKeyRotationPeriod old, and old
+	// versions stay readable. Unwrapped keys are cached for ChatKeyCacheTTL
+	// so a busy chat calls KMS once per TTL rather than once per message.
+	ChatKeyRotationPeriod = 30 * 24 * time.Hour
+	ChatKeyCacheTTL       = 5 * time.Minute
+	ChatKeyCacheEntries   = 10_000
+	KMSRequestTimeout     = 5 * time.Second
+
+	// Phone lookups go through a blind index, an HMAC of the phone under a
+	// pepper of at least MinPhoneIndexPepperBytes, so the users table holds
+	// no searchable plaintext phone numbers.
+	MinPhoneIndexPepperBytes = 32
+
+	// Federation bridges. A bridge's HTTP calls to its remote server are
+	// bounded by BridgeRequestTimeout so a slow homeserver cannot stall the
+	// outbound relay. A message the remote keeps refusing is tried
+	// BridgeRelayAttempts times, backing off from BridgeRelayBackoff, then
+	// dropped so one bad room does not hold up the partition.
+	MaxBridgeNameLength  = 32
+	BridgeRequestTimeout = 10 * time.Second
+	BridgeRelayAttempts  = 5
+	BridgeRelayBackoff   = 500 * time.Millisecond
+	// MaxBridgeTransactionBytes bounds a transaction pushed by a Matrix
+	// homeserver.
+	MaxBridgeTransactionBytes = 16 << 20
+
+	// SMS fallback delivery. Users choose how long they must be inactive
+	// before messages go out by SMS. Every SMS costs money, so sends are
+	// capped per user and across the platform per UTC day, a chat texts a
+	// user at most once per SMSCoalesceWindow, and bodies are truncated to
+	// MaxSMSSegments. A reply goes to the chat that last texted the user,
+	// within SMSReplyWindow.
+	DefaultSMSFallbackOfflineAfter = 15 * time.Minute
+	MinSMSFallbackOfflineAfter     = 5 * time.Minute
+	MaxSMSFallbackOfflineAfter     = 24 * time.Hour
+	MaxSMSSegments                 = 2
+	MaxSMSSenderNameLength         = 20
+	SMSDailyLimitPerUser           = 20
+	SMSGlobalDailyLimit            = 10_000
+	SMSCoalesceWindow              = 10 * time.Minute
+	SMSReplyWindow                 = 24 * time.Hour
 
 	// Token configuration (ADR-015)
-	AccessTokenLifetime  = 1 * time.Hour       // JWT access token validity
-	RefreshTokenLifetime = 30 * 24 * time.Hour // Refresh token validity (30 days)
-	MaxSessionsPerUser   = 5                   // Max concurrent sessions per user
+	AccessTokenLifetime   = 1 * time.Hour       // JWT access token validity
+	RefreshTokenLifetime  = 30 * 24 * time.Hour // Refresh token validity (30 days)
+	MaxSessionsPerUser    = 5                   // Max concurrent sessions per user
+	TokenLineageRetention = 90 * 24 * time.Hour // How long refresh token lineage outlives its session
+
+	// Token lifetime bounds for configuration overrides (ADR-015).
+	// The access token ceiling equals AccessTokenLifetime because the JTI
+	// revocation TTL is sized to it (PR1-DECISIONS: revocation TTL = 60 min).
+	MinAccessTokenLifetime  = 5 * time.Minute
+	MaxAccessTokenLifetime  = AccessTokenLifetime
+	MinRefreshTokenLifetime = 24 * time.Hour
+	MaxRefreshTokenLifetime = 90 * 24 * time.Hour
+
+	// Argon2id cost of refresh token hashes (OWASP's minimum: 19 MiB, two
+	// passes, one lane), paid on every refresh. Configured memory must lie
+	// in [MinRefreshArgon2Memory, MaxRefreshArgon2Memory] KiB.
+	RefreshArgon2Memory        = 19 << 10
+	RefreshArgon2Iterations    = 2
+	RefreshArgon2Parallelism   = 1
+	MinRefreshArgon2Memory     = 8 << 10
+	MaxRefreshArgon2Memory     = 256 << 10
+	MaxRefreshArgon2Iterations = 10
+
+	// Idle session expiry. A session with no activity for SessionIdleTimeout
+	// is revoked even if its refresh token is still valid. The Gateway
+	// reports activity at most once per SessionActivityGranularity per
+	// session and writes reports every SessionActivityFlushInterval; Chat
+	// Mgmt sweeps idle sessions every SessionIdleSweepInterval.
+	SessionIdleTimeout           = 60 * 24 * time.Hour
+	MinSessionIdleTimeout        = 24 * time.Hour
+	MaxSessionIdleTimeout        = 365 * 24 * time.Hour
+	SessionActivityGranularity   = 5 * time.Minute
+	SessionActivityFlushInterval = 30 * time.Second
+	SessionIdleSweepInterval     = time.Hour
+
+	// Workspace SSO (OpenID Connect). An IdP may cap sessions at
+	// MinSSOSessionTTL or more. Discovery documents and signing keys are
+	// cached for SSOMetadataCacheTTL; an unknown key ID refetches them at
+	// most once per SSOKeyRefreshInterval. ID tokens issued more than
+	// SSOMaxIDTokenAge ago are refused even if unexpired.
+	MinSSOSessionTTL      = 15 * time.Minute
+	SSOMetadataCacheTTL   = time.Hour
+	SSOKeyRefreshInterval = time.Minute
+	SSOMaxIDTokenAge      = 10 * time.Minute
+	SSOFetchTimeout       = 5 * time.Second
+	MaxSSOMetadataBytes   = 256 << 10
+	MaxSSONonceLength     = 256
+
+	// SCIM provisioning. A workspace group holds at most
+	// MaxWorkspaceGroupMembers members, which keeps its item well under
+	// DynamoDB's 400 KB limit; a group update that loses a race is retried
+	// up to SCIMGroupWriteAttempts times. SCIM request bodies are capped at
+	// MaxSCIMRequestBytes.
+	MaxWorkspaceGroupMembers = 1000
+	MaxSCIMUserNameLength    = 256
+	MaxSCIMRequestBytes      = 1 << 20
+	SCIMGroupWriteAttempts   = 3
 
 	// Membership cache (ADR-003 §3.2)
 	MembershipCacheTTL = 5 * time.Minute // Redis cache TTL for chat memberships
@@ -61,6 +387,57 @@ const (
 	// Pagination defaults
 	DefaultPageSize = 50
 	MaxPageSize     = 100
+
+	// Sync for very stale clients (ADR-001 §4). A client more than
+	// SyncSnapshotThreshold sequences behind gets a snapshot of the latest
+	// SyncSnapshotMessages messages and a gap marker instead of a replay.
+	SyncSnapshotThreshold = 1000
+	SyncSnapshotMessages  = 50
+
+	// Push notifications held by do-not-disturb are released, as one
+	// summary per user, by a sweep every PushReleaseInterval. Within
+	// PushCoalesceWindow of a chat's last push to a user, further messages
+	// are folded into one collapsing push sent when the window closes.
+	PushReleaseInterval = 5 * time.Second
+	PushCoalesceWindow  = 15 * time.Second
+
+	// Do-not-disturb schedules. A user has at most MaxQuietWindows daily
+	// windows and MaxDNDChatOverrides per-chat overrides, so a schedule
+	// stays a small part of the users item.
+	MaxQuietWindows     = 8
+	MaxDNDChatOverrides = 100
+
+	// Notification feed. Entries expire FeedRetention after creation; each
+	// carries at most MaxFeedParams localization parameters.
+	FeedRetention = 90 * 24 * time.Hour
+	MaxFeedParams = 16
+
+	// MaxMentions caps the users one message notifies by mention; later
+	// mentions still render but notify no one.
+	MaxMentions = 20
+
+	// Join requests to a group expire JoinRequestTTL after they are made if
+	// no admin decides them; the requester may then ask again.
+	JoinRequestTTL           = 7 * 24 * time.Hour
+	MaxJoinRequestNoteLength = 200
+
+	// On-demand message translation. Translations are cached per message
+	// and language for TranslationCacheTTL; only provider calls count
+	// against the per-user limits, which cap translation spend.
+	TranslationCacheTTL            = 7 * 24 * time.Hour
+	TranslateRateLimitPerMinute    = 20
+	TranslateRateLimitPerDay       = 500
+	TranslateRateLimitMinuteWindow = time.Minute
+	TranslateRateLimitDayWindow    = 24 * time.Hour
+	TranslateTimeout               = 5 * time.Second // bounds one provider call
+
+	// Synthetic canary. Each journey requests an OTP for two sink numbers
+	// from one address, so journeys start at most every
+	// CanaryMinInterval to stay within the per-phone OTP rate limit.
+	// Each step of a journey is bounded by CanaryStepTimeout.
+	CanaryInterval    = 5 * time.Minute
+	CanaryMinInterval = OTPRateLimitWindow / OTPRequestRateLimitPerPhone
+	CanaryStepTimeout = 10 * time.Second
 )
 
 // ContentType represents supported message content types.
@@ -68,9 +445,14 @@ type ContentType string
 
 const (
 	ContentTypeText ContentType = "text"
+	// ContentTypeSystem marks server-generated messages; the body is a
+	// JSON-encoded SystemMessage. Clients cannot send it.
+	ContentTypeSystem ContentType = "system"
 )
 
-// IsValidContentType checks if a content type is supported.
+// IsValidContentType checks if a content type can be sent by clients.
+// ContentTypeSystem is excluded: system messages are built with
+// SystemMessage.Content.
 func IsValidContentType(ct ContentType) bool {
 	return ct == ContentTypeText
 }
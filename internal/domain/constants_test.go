@@ -16,6 +16,7 @@ func TestIsValidContentType(t *testing.T) {
 		{name: "text is valid", ct: "text", want: true},
 		{name: "empty is invalid", ct: "", want: false},
 		{name: "image is invalid", ct: "image", want: false},
+		{name: "system is server-only", ct: domain.ContentTypeSystem, want: false},
 		{name: "TEXT is invalid (case-sensitive)", ct: "TEXT", want: false},
 	}
 
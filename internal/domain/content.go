@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MessageContent is a value object representing a validated message body.
+// Always valid in memory — use NewMessageContent to construct. Every entry
+// point that accepts message text (the Gateway send_message handler,
+// Ingest's PersistMessage, federation bridges, SMS replies) must go through
+// NewMessageContent so the same invariants apply everywhere.
+type MessageContent struct {
+	contentType ContentType
+	body        string
+}
+
+// NewMessageContent validates and normalizes a raw message body.
+//
+// Invariants (ADR-009 §Message limits):
+//   - content type must be supported (IsValidContentType)
+//   - body must be valid UTF-8
+//   - control characters other than \n and \t are stripped, \r\n becomes \n
+//   - normalized body must be non-blank and at most MaxMessageSize bytes
+//
+// The size check runs against the raw input as well so oversized payloads
+// are rejected before any normalization work is done.
+func NewMessageContent(contentType ContentType, raw string) (MessageContent, error) {
+	if !IsValidContentType(contentType) {
+		return MessageContent{}, fmt.Errorf("content type %q: %w", contentType, ErrInvalidContentType)
+	}
+	if len(raw) > MaxMessageSize {
+		return MessageContent{}, fmt.Errorf("message body is %d bytes, max %d: %w", len(raw), MaxMessageSize, ErrMessageTooLarge)
+	}
+	if !utf8.ValidString(raw) {
+		return MessageContent{}, fmt.Errorf("message body is not valid UTF-8: %w", ErrInvalidInput)
+	}
+
+	body := stripControlChars(raw)
+	if strings.TrimSpace(body) == "" {
+		return MessageContent{}, fmt.Errorf("message body cannot be empty: %w", ErrInvalidInput)
+	}
+
+	return MessageContent{contentType: contentType, body: body}, nil
+}
+
+// MustMessageContent creates a MessageContent, panicking on invalid input. Use only in tests.
+func MustMessageContent(contentType ContentType, raw string) MessageContent {
+	c, err := NewMessageContent(contentType, raw)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// stripControlChars removes Unicode control (Cc) and format-override
+// characters, keeping newlines and tabs. Carriage returns are dropped so
+// CRLF and LF line endings normalize to the same body.
+func stripControlChars(s string) string {
+	clean := true
+	for _, r := range s {
+		if isStrippable(r) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if !isStrippable(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isStrippable reports whether r is removed by stripControlChars. Bidi
+// override and isolate characters are included because they can make the
+// rendered text differ from the stored bytes (CVE-2021-42574 class).
+func isStrippable(r rune) bool {
+	switch {
+	case r == '\n' || r == '\t':
+		return false
+	case unicode.Is(unicode.Cc, r):
+		return true
+	case r >= '\u202A' && r <= '\u202E', r >= '\u2066' && r <= '\u2069':
+		return true
+	default:
+		return false
+	}
+}
+
+func (c MessageContent) ContentType() ContentType { return c.contentType }
+func (c MessageContent) Body() string             { return c.body }
+func (c MessageContent) Size() int                { return len(c.body) }
+func (c MessageContent) IsZero() bool             { return c.body == "" }
@@ -0,0 +1,59 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessageContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		ct       domain.ContentType
+		raw      string
+		wantBody string
+		wantErr  error
+	}{
+		{name: "plain text", ct: domain.ContentTypeText, raw: "hello", wantBody: "hello"},
+		{name: "unicode text", ct: domain.ContentTypeText, raw: "héllo 👋 世界", wantBody: "héllo 👋 世界"},
+		{name: "keeps newlines and tabs", ct: domain.ContentTypeText, raw: "a\n\tb", wantBody: "a\n\tb"},
+		{name: "normalizes CRLF", ct: domain.ContentTypeText, raw: "a\r\nb", wantBody: "a\nb"},
+		{name: "strips NUL and BEL", ct: domain.ContentTypeText, raw: "a\x00b\x07c", wantBody: "abc"},
+		{name: "strips C1 controls", ct: domain.ContentTypeText, raw: "a\u0085b", wantBody: "ab"},
+		{name: "strips bidi overrides", ct: domain.ContentTypeText, raw: "a\u202Eb\u2066c", wantBody: "abc"},
+		{name: "exactly max size", ct: domain.ContentTypeText, raw: strings.Repeat("x", domain.MaxMessageSize), wantBody: strings.Repeat("x", domain.MaxMessageSize)},
+		{name: "unsupported content type", ct: "image", raw: "hello", wantErr: domain.ErrInvalidContentType},
+		{name: "empty content type", ct: "", raw: "hello", wantErr: domain.ErrInvalidContentType},
+		{name: "over max size", ct: domain.ContentTypeText, raw: strings.Repeat("x", domain.MaxMessageSize+1), wantErr: domain.ErrMessageTooLarge},
+		{name: "invalid UTF-8", ct: domain.ContentTypeText, raw: "a\xffb", wantErr: domain.ErrInvalidInput},
+		{name: "empty body", ct: domain.ContentTypeText, raw: "", wantErr: domain.ErrInvalidInput},
+		{name: "whitespace only", ct: domain.ContentTypeText, raw: " \n\t ", wantErr: domain.ErrInvalidInput},
+		{name: "control chars only", ct: domain.ContentTypeText, raw: "\x00\x01\x1b", wantErr: domain.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := domain.NewMessageContent(tt.ct, tt.raw)
+
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.True(t, got.IsZero())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBody, got.Body())
+			assert.Equal(t, tt.ct, got.ContentType())
+			assert.Equal(t, len(tt.wantBody), got.Size())
+			assert.False(t, got.IsZero())
+		})
+	}
+}
+
+func TestMustMessageContent_PanicsOnInvalid(t *testing.T) {
+	assert.Panics(t, func() { domain.MustMessageContent(domain.ContentTypeText, "") })
+	assert.NotPanics(t, func() { domain.MustMessageContent(domain.ContentTypeText, "ok") })
+}
@@ -0,0 +1,21 @@
+package domain
+
+// Fanout hands messages to the Gateway instances their recipients are
+// connected to (ADR-002 §3.4, ADR-010 §1.6). Each Gateway advertises the
+// users it holds connections for in a per-user routing set and subscribes
+// to its own delivery channel; Fanout publishes a message to the channel of
+// every instance in a recipient's set. Routes are renewed every
+// HeartbeatInterval and lapse after ConnectionTTL, so a Gateway that dies
+// stops being routed to.
+
+// UserServersKey returns the Redis sorted set of the Gateway instances
+// userID is connected to, scored by route expiry in Unix milliseconds.
+func UserServersKey(userID string) string {
+	return "user_servers:" + userID
+}
+
+// GatewayDeliverChannel returns the Redis Pub/Sub channel the Gateway
+// instance gatewayID receives deliveries on.
+func GatewayDeliverChannel(gatewayID string) string {
+	return "gateway:" + gatewayID + ":deliver"
+}
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// minutesPerDay bounds QuietWindow offsets.
+const minutesPerDay = 24 * 60
+
+// QuietWindow is a daily span of local time during which push
+// notifications are held, as minutes after midnight in [Start, End). A
+// window with End before Start spans midnight; Start == End is empty.
+type QuietWindow struct {
+	Start int
+	End   int
+}
+
+// validate checks the window's offsets.
+func (w QuietWindow) validate(field string) error {
+	if w.Start < 0 || w.Start >= minutesPerDay || w.End < 0 || w.End >= minutesPerDay {
+		return NewValidationError(field, fmt.Sprintf("must be minutes in [0, %d)", minutesPerDay))
+	}
+	return nil
+}
+
+// until returns the end of the occurrence of w that contains local, and
+// whether one does.
+func (w QuietWindow) until(local time.Time) (time.Time, bool) {
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	at := func(days, minutes int) time.Time {
+		// time.Date normalizes, so DST days resolve to the wall clock time.
+		return time.Date(midnight.Year(), midnight.Month(), midnight.Day()+days, minutes/60, minutes%60, 0, 0, midnight.Location())
+	}
+
+	switch {
+	case w.Start < w.End:
+		if minute >= w.Start && minute < w.End {
+			return at(0, w.End), true
+		}
+	case w.Start > w.End:
+		if minute >= w.Start {
+			return at(1, w.End), true
+		}
+		if minute < w.End {
+			return at(0, w.End), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ChatDND overrides a user's quiet hours for one chat.
+type ChatDND struct {
+	// Bypass delivers the chat's notifications even during quiet hours.
+	Bypass bool
+	// Windows, when non-nil, replaces the user's windows for the chat.
+	Windows []QuietWindow
+}
+
+// DNDSchedule is a user's do-not-disturb configuration. The zero value
+// never holds a notification.
+type DNDSchedule struct {
+	// Location is the user's time zone. Nil is UTC.
+	Location *time.Location
+	Windows  []QuietWindow
+	// Chats holds per-chat overrides, keyed by chat ID.
+	Chats map[string]ChatDND
+	// Version is the user's settings version when read (see
+	// PrivacySettings.Version).
+	Version int64
+}
+
+// Validate checks every window in the schedule and the schedule's size.
+func (s DNDSchedule) Validate() error {
+	if len(s.Windows) > MaxQuietWindows {
+		return NewValidationError("windows", fmt.Sprintf("must have at most %d entries", MaxQuietWindows))
+	}
+	if len(s.Chats) > MaxDNDChatOverrides {
+		return NewValidationError("chats", fmt.Sprintf("must have at most %d entries", MaxDNDChatOverrides))
+	}
+	for i, w := range s.Windows {
+		if err := w.validate(fmt.Sprintf("windows[%d]", i)); err != nil {
+			return err
+		}
+	}
+	for chatID, o := range s.Chats {
+		if len(o.Windows) > MaxQuietWindows {
+			return NewValidationError(fmt.Sprintf("chats[%s].windows", chatID), fmt.Sprintf("must have at most %d entries", MaxQuietWindows))
+		}
+		for i, w := range o.Windows {
+			if err := w.validate(fmt.Sprintf("chats[%s].windows[%d]", chatID, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// QuietUntil reports whether a notification for chatID at now falls in
+// quiet hours and, if so, when they end. Windows that overlap or abut are
+// followed to the end of the combined quiet period.
+func (s DNDSchedule) QuietUntil(now time.Time, chatID string) (time.Time, bool) {
+	windows := s.Windows
+	if o, ok := s.Chats[chatID]; ok {
+		if o.Bypass {
+			return time.Time{}, false
+		}
+		if o.Windows != nil {
+			windows = o.Windows
+		}
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var until time.Time
+	at := now.In(loc)
+	// Each window can extend the quiet period at most once.
+	for range len(windows) {
+		extended := false
+		for _, w := range windows {
+			if end, ok := w.until(at); ok && end.After(until) {
+				until, extended = end, true
+			}
+		}
+		if !extended {
+			break
+		}
+		at = until
+	}
+	if until.IsZero() {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// LoadDNDLocation resolves a schedule's IANA time zone name. The empty
+// name is UTC.
+func LoadDNDLocation(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, NewValidationError("time_zone", "must be an IANA time zone")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, NewValidationError("time_zone", "must be an IANA time zone")
+	}
+	return loc, nil
+}
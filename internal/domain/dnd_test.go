@@ -0,0 +1,136 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestDNDSchedule_QuietUntil(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	night := domain.QuietWindow{Start: 22 * 60, End: 7 * 60}
+	lunch := domain.QuietWindow{Start: 12 * 60, End: 13 * 60}
+	local := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, ny) }
+
+	tests := []struct {
+		name      string
+		schedule  domain.DNDSchedule
+		now       time.Time
+		chatID    string
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{name: "no windows", schedule: domain.DNDSchedule{Location: ny}, now: local(2, 23, 0)},
+		{name: "outside window", schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night}}, now: local(2, 21, 59)},
+		{name: "before midnight", schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night}}, now: local(2, 23, 30), wantQuiet: true, wantUntil: local(3, 7, 0)},
+		{name: "after midnight", schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night}}, now: local(3, 6, 59), wantQuiet: true, wantUntil: local(3, 7, 0)},
+		{name: "end is exclusive", schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night}}, now: local(3, 7, 0)},
+		{name: "same-day window", schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{lunch}}, now: local(2, 12, 15), wantQuiet: true, wantUntil: local(2, 13, 0)},
+		{
+			name:      "uses the user's time zone",
+			schedule:  domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night}},
+			now:       time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC), // 22:00 EST
+			wantQuiet: true,
+			wantUntil: local(3, 7, 0),
+		},
+		{
+			name:      "window across DST change ends at local wall time",
+			schedule:  domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night}},
+			now:       local(7, 23, 0), // clocks spring forward on 8 March
+			wantQuiet: true,
+			wantUntil: local(8, 7, 0),
+		},
+		{
+			name:      "abutting windows chain",
+			schedule:  domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night, {Start: 7 * 60, End: 9 * 60}}},
+			now:       local(2, 23, 0),
+			wantQuiet: true,
+			wantUntil: local(3, 9, 0),
+		},
+		{
+			name: "chat bypass",
+			schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night},
+				Chats: map[string]domain.ChatDND{"family": {Bypass: true}}},
+			now:    local(2, 23, 0),
+			chatID: "family",
+		},
+		{
+			name: "chat windows replace the user's",
+			schedule: domain.DNDSchedule{Location: ny, Windows: []domain.QuietWindow{night},
+				Chats: map[string]domain.ChatDND{"work": {Windows: []domain.QuietWindow{{Start: 18 * 60, End: 9 * 60}}}}},
+			now:       local(2, 19, 0),
+			chatID:    "work",
+			wantQuiet: true,
+			wantUntil: local(3, 9, 0),
+		},
+		{
+			name:      "nil location is UTC",
+			schedule:  domain.DNDSchedule{Windows: []domain.QuietWindow{lunch}},
+			now:       time.Date(2026, 3, 2, 12, 30, 0, 0, time.UTC),
+			wantQuiet: true,
+			wantUntil: time.Date(2026, 3, 2, 13, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := tt.schedule.QuietUntil(tt.now, tt.chatID)
+
+			assert.Equal(t, tt.wantQuiet, quiet)
+			assert.True(t, tt.wantUntil.Equal(until), "until = %v, want %v", until, tt.wantUntil)
+		})
+	}
+}
+
+func TestDNDSchedule_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule domain.DNDSchedule
+		wantErr  bool
+	}{
+		{name: "empty", schedule: domain.DNDSchedule{}},
+		{name: "valid", schedule: domain.DNDSchedule{Windows: []domain.QuietWindow{{Start: 0, End: 1439}}}},
+		{name: "end out of range", schedule: domain.DNDSchedule{Windows: []domain.QuietWindow{{Start: 0, End: 1440}}}, wantErr: true},
+		{name: "negative start", schedule: domain.DNDSchedule{Windows: []domain.QuietWindow{{Start: -1, End: 60}}}, wantErr: true},
+		{
+			name: "chat window out of range",
+			schedule: domain.DNDSchedule{Chats: map[string]domain.ChatDND{
+				"chat-1": {Windows: []domain.QuietWindow{{Start: 60, End: 2000}}},
+			}},
+			wantErr: true,
+		},
+		{name: "too many windows", schedule: domain.DNDSchedule{Windows: make([]domain.QuietWindow, domain.MaxQuietWindows+1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadDNDLocation(t *testing.T) {
+	loc, err := domain.LoadDNDLocation("Europe/Madrid")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Madrid", loc.String())
+
+	loc, err = domain.LoadDNDLocation("")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	for _, name := range []string{"Local", "Mars/Olympus_Mons"} {
+		_, err := domain.LoadDNDLocation(name)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput, name)
+	}
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tier is a billing plan.
+type Tier string
+
+const (
+	TierFree    Tier = "free"
+	TierPremium Tier = "premium"
+)
+
+// IsValidTier reports whether t is a known plan.
+func IsValidTier(t Tier) bool {
+	return t == TierFree || t == TierPremium
+}
+
+// SubjectKind is what an entitlement is bought for.
+type SubjectKind string
+
+const (
+	SubjectUser      SubjectKind = "user"
+	SubjectWorkspace SubjectKind = "workspace"
+)
+
+// EntitlementSubject identifies the user or workspace an entitlement
+// belongs to.
+type EntitlementSubject struct {
+	Kind SubjectKind
+	ID   string
+}
+
+// UserSubject returns the subject for userID's own entitlement.
+func UserSubject(userID string) EntitlementSubject {
+	return EntitlementSubject{Kind: SubjectUser, ID: userID}
+}
+
+// Validate checks that s names a known kind and a non-empty ID.
+func (s EntitlementSubject) Validate() error {
+	if s.Kind != SubjectUser && s.Kind != SubjectWorkspace {
+		return NewValidationError("subject_type", "must be user or workspace")
+	}
+	if s.ID == "" {
+		return NewValidationError("subject_id", "is required")
+	}
+	return nil
+}
+
+// String returns the subject as kind#id, the entitlement storage key.
+func (s EntitlementSubject) String() string {
+	return string(s.Kind) + "#" + s.ID
+}
+
+// Limits are the plan limits services enforce. Zero fields fall back to
+// the tier default.
+type Limits struct {
+	MaxGroupSize      int
+	MaxAttachmentSize int64
+}
+
+// TierLimits returns the default limits of tier. Unknown tiers get the
+// free limits.
+func TierLimits(tier Tier) Limits {
+	if tier == TierPremium {
+		return Limits{MaxGroupSize: PremiumMaxGroupSize, MaxAttachmentSize: PremiumMaxAttachmentSize}
+	}
+	return Limits{MaxGroupSize: MaxGroupSize, MaxAttachmentSize: MaxAttachmentSize}
+}
+
+// Entitlement is a subject's current plan as last reported by the payment
+// provider. EventAt is the provider's time for that report, so an older
+// webhook delivered late cannot overwrite a newer one.
+type Entitlement struct {
+	Subject   EntitlementSubject
+	Tier      Tier
+	Limits    Limits    // per-contract overrides; zero fields use the tier default
+	PaidUntil time.Time // end of the paid period; zero for free
+	EventID   string
+	EventAt   time.Time
+	UpdatedAt time.Time
+}
+
+// Validate checks the fields a webhook must supply.
+func (e Entitlement) Validate() error {
+	if err := e.Subject.Validate(); err != nil {
+		return err
+	}
+	if !IsValidTier(e.Tier) {
+		return NewValidationError("tier", fmt.Sprintf("unknown tier %q", e.Tier))
+	}
+	if e.Limits.MaxGroupSize < 0 || e.Limits.MaxAttachmentSize < 0 {
+		return NewValidationError("limits", "must not be negative")
+	}
+	return nil
+}
+
+// Effective returns the limits e grants at now. A paid plan past PaidUntil
+// plus EntitlementGracePeriod, or with no PaidUntil at all, falls back to
+// free, so a missed cancellation webhook does not grant premium forever.
+func (e Entitlement) Effective(now time.Time) Limits {
+	free := TierLimits(TierFree)
+	if e.Tier != TierPremium || now.After(e.PaidUntil.Add(EntitlementGracePeriod)) {
+		return free
+	}
+	limits := TierLimits(e.Tier)
+	if e.Limits.MaxGroupSize > 0 {
+		limits.MaxGroupSize = e.Limits.MaxGroupSize
+	}
+	if e.Limits.MaxAttachmentSize > 0 {
+		limits.MaxAttachmentSize = e.Limits.MaxAttachmentSize
+	}
+	return limits
+}
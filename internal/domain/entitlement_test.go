@@ -0,0 +1,76 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestEntitlement_Effective(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	free := domain.TierLimits(domain.TierFree)
+	premium := domain.TierLimits(domain.TierPremium)
+
+	tests := []struct {
+		name string
+		ent  domain.Entitlement
+		want domain.Limits
+	}{
+		{name: "zero value is free", want: free},
+		{
+			name: "paid premium",
+			ent:  domain.Entitlement{Tier: domain.TierPremium, PaidUntil: now.Add(24 * time.Hour)},
+			want: premium,
+		},
+		{
+			name: "lapsed premium within grace",
+			ent:  domain.Entitlement{Tier: domain.TierPremium, PaidUntil: now.Add(-domain.EntitlementGracePeriod + time.Minute)},
+			want: premium,
+		},
+		{
+			name: "lapsed premium past grace",
+			ent:  domain.Entitlement{Tier: domain.TierPremium, PaidUntil: now.Add(-domain.EntitlementGracePeriod - time.Minute)},
+			want: free,
+		},
+		{
+			name: "premium without a paid period",
+			ent:  domain.Entitlement{Tier: domain.TierPremium},
+			want: free,
+		},
+		{
+			name: "contract overrides",
+			ent: domain.Entitlement{
+				Tier:      domain.TierPremium,
+				PaidUntil: now.Add(time.Hour),
+				Limits:    domain.Limits{MaxGroupSize: 5000},
+			},
+			want: domain.Limits{MaxGroupSize: 5000, MaxAttachmentSize: premium.MaxAttachmentSize},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.ent.Effective(now))
+		})
+	}
+}
+
+func TestEntitlement_Validate(t *testing.T) {
+	valid := domain.Entitlement{Subject: domain.UserSubject("user-1"), Tier: domain.TierPremium}
+	assert.NoError(t, valid.Validate())
+	assert.Equal(t, "user#user-1", valid.Subject.String())
+
+	for name, mutate := range map[string]func(*domain.Entitlement){
+		"unknown kind":    func(e *domain.Entitlement) { e.Subject.Kind = "team" },
+		"missing id":      func(e *domain.Entitlement) { e.Subject.ID = "" },
+		"unknown tier":    func(e *domain.Entitlement) { e.Tier = "gold" },
+		"negative limits": func(e *domain.Entitlement) { e.Limits.MaxGroupSize = -1 },
+	} {
+		ent := valid
+		mutate(&ent)
+		assert.ErrorIs(t, ent.Validate(), domain.ErrInvalidInput, name)
+	}
+}
@@ -0,0 +1,89 @@
+package domain
+
+import "errors"
+
+// ErrorCode is a stable, public number identifying a domain error. Client
+// teams program against codes rather than messages, so a code is never
+// renumbered or reused once published; a retired error keeps its code.
+//
+// Codes are grouped by the thousand: 1xxx validation, 2xxx authentication,
+// 3xxx authorization, 4xxx resources, 5xxx rate limiting, 6xxx availability.
+type ErrorCode int
+
+// ErrorCodeInternal identifies every error without a catalog entry.
+const ErrorCodeInternal ErrorCode = 9000
+
+// ErrorDefinition is a catalog entry: the error, its code, and a
+// description safe to show to clients.
+type ErrorDefinition struct {
+	Code        ErrorCode
+	Err         error
+	Description string
+}
+
+// errorCatalog lists every client-visible domain error. Order matters: the
+// first entry matching via errors.Is wins, so specific errors that wrap a
+// general one must come first.
+var errorCatalog = []ErrorDefinition{
+	// Validation
+	{1000, ErrInvalidInput, "The request is malformed or a field is invalid."},
+	{1001, ErrMessageTooLarge, "The message exceeds the maximum size."},
+	{1002, ErrInvalidContentType, "The message content type is not supported."},
+	{1003, ErrEmptyID, "A required identifier is missing."},
+	{1004, ErrInvalidID, "An identifier is not in the expected format."},
+	{1005, ErrInvalidPhoneNumber, "The phone number is not a valid E.164 number."},
+
+	// Authentication (ADR-015)
+	{2000, ErrUnauthorized, "Authentication is required."},
+	{2001, ErrInvalidOTP, "The one-time password is incorrect."},
+	{2002, ErrOTPExpired, "The one-time password has expired; request a new one."},
+	{2003, ErrDeviceMismatch, "The device does not match the session."},
+	{2004, ErrInvalidRefreshToken, "The refresh token is invalid."},
+	{2005, ErrRefreshTokenReuse, "The refresh token was already used; the session has been revoked."},
+	{2006, ErrSessionExpired, "The session has expired; sign in again."},
+	{2007, ErrSessionRevoked, "The session has been revoked; sign in again."},
+
+	// Authorization
+	{3000, ErrForbidden, "The caller is not allowed to perform this action."},
+	{3001, ErrNotMember, "The caller is not a member of the chat."},
+
+	// Resources
+	{4000, ErrNotFound, "The resource does not exist."},
+	{4001, ErrAlreadyExists, "The resource already exists."},
+	{4002, ErrVersionConflict, "The resource changed since it was read; reload and retry."},
+	{4003, ErrDuplicateMessage, "The message was already accepted with this client message ID."},
+
+	// Rate limiting
+	{5000, ErrRateLimited, "Too many requests; retry later."},
+	{5001, ErrPhoneRateLimited, "Too many requests for this phone number; retry later."},
+	{5002, ErrIPRateLimited, "Too many requests from this address; retry later."},
+	{5003, ErrMaxSessionsExceeded, "The account has too many active sessions."},
+	{5004, ErrSlowConsumer, "The client is not reading messages fast enough."},
+	{5005, ErrSlowMode, "The chat is in slow mode; wait before sending again."},
+	{5006, ErrUserConnectionLimit, "The account has too many open connections."},
+	{5007, ErrDeviceConnectionLimit, "The device has too many open connections."},
+	{5008, ErrIPConnectionLimit, "Too many connections are open from this address."},
+
+	// Availability
+	{6000, ErrUnavailable, "The service is temporarily unavailable; retry later."},
+}
+
+var internalErrorDefinition = ErrorDefinition{Code: ErrorCodeInternal, Description: "An internal error occurred."}
+
+// ErrorCatalog returns a copy of every catalog entry, in code order.
+func ErrorCatalog() []ErrorDefinition {
+	return append([]ErrorDefinition(nil), errorCatalog...)
+}
+
+// LookupError returns the catalog entry for err. Errors without an entry,
+// including nil, return the internal error definition and false.
+func LookupError(err error) (ErrorDefinition, bool) {
+	if err != nil {
+		for _, d := range errorCatalog {
+			if errors.Is(err, d.Err) {
+				return d, true
+			}
+		}
+	}
+	return internalErrorDefinition, false
+}
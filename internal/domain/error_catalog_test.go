@@ -0,0 +1,62 @@
+package domain_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCatalog_CodesAreUniqueAndOrdered(t *testing.T) {
+	catalog := domain.ErrorCatalog()
+	require.NotEmpty(t, catalog)
+
+	seenErr := map[error]bool{}
+	for i, d := range catalog {
+		assert.NotNil(t, d.Err, "code %d", d.Code)
+		assert.NotEmpty(t, d.Description, "code %d", d.Code)
+		assert.False(t, seenErr[d.Err], "%v listed twice", d.Err)
+		seenErr[d.Err] = true
+		assert.Less(t, d.Code, domain.ErrorCodeInternal, "code %d", d.Code)
+		if i > 0 {
+			assert.Greater(t, d.Code, catalog[i-1].Code, "codes are unique and in order")
+		}
+	}
+}
+
+func TestErrorCatalog_ReturnsCopy(t *testing.T) {
+	catalog := domain.ErrorCatalog()
+	catalog[0].Code = 1
+
+	assert.NotEqual(t, domain.ErrorCode(1), domain.ErrorCatalog()[0].Code)
+}
+
+func TestLookupError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want domain.ErrorCode
+		ok   bool
+	}{
+		{"sentinel", domain.ErrNotMember, 3001, true},
+		{"wrapped", fmt.Errorf("send: %w", domain.ErrSessionRevoked), 2007, true},
+		{"slow mode error", &domain.SlowModeError{}, 5005, true},
+		{"validation error", domain.NewValidationError("name", "too long"), 1000, true},
+		{"unknown", errors.New("boom"), domain.ErrorCodeInternal, false},
+		{"internal-only", domain.ErrConfigRequired, domain.ErrorCodeInternal, false},
+		{"nil", nil, domain.ErrorCodeInternal, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := domain.LookupError(tt.err)
+
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, d.Code)
+			assert.NotEmpty(t, d.Description)
+		})
+	}
+}
@@ -12,6 +12,9 @@ var (
 	// Resource errors
 	ErrNotFound      = errors.New("resource not found")
 	ErrAlreadyExists = errors.New("resource already exists")
+	// ErrVersionConflict reports an optimistic-concurrency failure: the
+	// resource changed since the caller read it.
+	ErrVersionConflict = errors.New("resource was modified concurrently")
 
 	// Authorization errors
 	ErrUnauthorized = errors.New("authentication required")
@@ -27,6 +30,14 @@ var (
 	ErrRateLimited  = errors.New("rate limit exceeded")
 	ErrUnavailable  = errors.New("service temporarily unavailable")
 	ErrSlowConsumer = errors.New("client not consuming messages fast enough")
+	// ErrSlowMode rejects a message sent sooner than the chat's slow mode
+	// allows; see SlowModeError for the remaining wait.
+	ErrSlowMode = errors.New("slow mode: wait before sending again")
+	// Connection quotas (ADR-009 §3) refuse a connection that would take
+	// its user, device or client address past the concurrent limit.
+	ErrUserConnectionLimit   = errors.New("too many concurrent connections for user")
+	ErrDeviceConnectionLimit = errors.New("too many concurrent connections for device")
+	ErrIPConnectionLimit     = errors.New("too many concurrent connections from address")
 
 	// Idempotency signal (not semantically a failure - indicates successful deduplication)
 	// Returns HTTP 200/gRPC OK with the original message; included here for mapper completeness
@@ -47,6 +58,7 @@ var (
 
 	// Configuration errors
 	ErrConfigRequired = errors.New("required configuration key missing")
+	ErrConfigInvalid  = errors.New("configuration value out of bounds")
 )
 
 // IsRetryable returns true if the error represents a transient condition
@@ -54,14 +66,19 @@ var (
 func IsRetryable(err error) bool {
 	return errors.Is(err, ErrUnavailable) ||
 		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrSlowMode) ||
 		errors.Is(err, ErrPhoneRateLimited) ||
 		errors.Is(err, ErrIPRateLimited) ||
-		errors.Is(err, ErrMaxSessionsExceeded)
+		errors.Is(err, ErrMaxSessionsExceeded) ||
+		errors.Is(err, ErrUserConnectionLimit) ||
+		errors.Is(err, ErrDeviceConnectionLimit) ||
+		errors.Is(err, ErrIPConnectionLimit)
 }
 
 // clientErrors enumerates all domain errors that represent client-side issues.
 var clientErrors = []error{
 	ErrInvalidInput,
+	ErrVersionConflict,
 	ErrMessageTooLarge,
 	ErrInvalidContentType,
 	ErrNotFound,
@@ -18,6 +18,8 @@ func TestIsRetryable(t *testing.T) {
 		{"nil error", nil, false},
 		{"ErrUnavailable", domain.ErrUnavailable, true},
 		{"ErrRateLimited", domain.ErrRateLimited, true},
+		{"ErrSlowMode", domain.ErrSlowMode, true},
+		{"ErrUserConnectionLimit", domain.ErrUserConnectionLimit, true},
 		{"ErrNotFound", domain.ErrNotFound, false},
 		{"ErrUnauthorized", domain.ErrUnauthorized, false},
 		{"wrapped ErrUnavailable", fmt.Errorf("context: %w", domain.ErrUnavailable), true},
@@ -48,6 +50,7 @@ func TestIsClientError(t *testing.T) {
 		{"ErrUnauthorized", domain.ErrUnauthorized, true},
 		{"ErrEmptyID", domain.ErrEmptyID, true},
 		{"ErrInvalidID", domain.ErrInvalidID, true},
+		{"ErrVersionConflict", domain.ErrVersionConflict, true},
 		{"ErrUnavailable", domain.ErrUnavailable, false},
 		{"ErrRateLimited", domain.ErrRateLimited, false},
 		{"wrapped ErrNotFound", fmt.Errorf("context: %w", domain.ErrNotFound), true},
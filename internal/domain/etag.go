@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WeakETag returns the weak entity tag (RFC 9110 §8.8.3) of a record at
+// version, last updated at updatedAt. Records without a version pass 0 and
+// are told apart by updatedAt alone; records without an update time pass
+// the zero time. The tag is weak because the encoded payload may differ
+// between equal records, e.g. in field order.
+func WeakETag(version int64, updatedAt time.Time) string {
+	if updatedAt.IsZero() {
+		return `W/"` + strconv.FormatInt(version, 36) + `"`
+	}
+	return `W/"` + strconv.FormatInt(version, 36) + "-" + strconv.FormatInt(updatedAt.UnixMilli(), 36) + `"`
+}
+
+// ETagMatches reports whether an If-None-Match header value matches etag
+// under the weak comparison RFC 9110 §13.1.2 prescribes for it: tags are
+// equal when their opaque parts are, whether or not either is weak. "*"
+// matches any tag.
+func ETagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
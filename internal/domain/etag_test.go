@@ -0,0 +1,45 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestWeakETag(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	tag := domain.WeakETag(3, at)
+
+	assert.Regexp(t, `^W/"[0-9a-z]+-[0-9a-z]+"$`, tag)
+	assert.Equal(t, tag, domain.WeakETag(3, at))
+	assert.NotEqual(t, tag, domain.WeakETag(4, at), "version bump")
+	assert.NotEqual(t, tag, domain.WeakETag(3, at.Add(time.Millisecond)), "later update")
+	assert.Equal(t, `W/"3"`, domain.WeakETag(3, time.Time{}), "version only")
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{"same weak tag", `W/"1-a"`, `W/"1-a"`, true},
+		{"strong candidate against weak tag", `"1-a"`, `W/"1-a"`, true},
+		{"one of a list", `W/"0-z", W/"1-a"`, `W/"1-a"`, true},
+		{"wildcard", `*`, `W/"1-a"`, true},
+		{"different tag", `W/"2-a"`, `W/"1-a"`, false},
+		{"unquoted candidate", `1-a`, `W/"1-a"`, false},
+		{"response without a tag", `*`, "", false},
+		{"empty header", "", `W/"1-a"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, domain.ETagMatches(tt.ifNoneMatch, tt.etag))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeedKind classifies an entry in a user's notification feed.
+type FeedKind string
+
+// Feed entry kinds.
+const (
+	FeedKindMention    FeedKind = "mention"
+	FeedKindInvite     FeedKind = "invite"
+	FeedKindMissedCall FeedKind = "missed_call"
+	FeedKindSystem     FeedKind = "system"
+	// FeedKindJoinRequest tells a group's admins someone asked to join.
+	FeedKindJoinRequest FeedKind = "join_request"
+)
+
+// Valid reports whether k is a known kind.
+func (k FeedKind) Valid() bool {
+	switch k {
+	case FeedKindMention, FeedKindInvite, FeedKindMissedCall, FeedKindSystem, FeedKindJoinRequest:
+		return true
+	}
+	return false
+}
+
+// FeedEntry is one notification in a user's feed. Entries carry structured
+// parameters rather than rendered text so clients localize them.
+type FeedEntry struct {
+	UserID  string
+	EntryID string // time-ordered; see GenerateFeedEntryID
+	Kind    FeedKind
+	ChatID  string // empty for notices not about a chat
+	ActorID string // user who caused the entry, if any
+	Params  map[string]string
+	// CreatedAt is when the entry was added; ReadAt is zero while unread.
+	CreatedAt time.Time
+	ReadAt    time.Time
+}
+
+// Unread reports whether the user has not acknowledged e.
+func (e FeedEntry) Unread() bool {
+	return e.ReadAt.IsZero()
+}
+
+// Validate checks the entry's recipient and kind.
+func (e FeedEntry) Validate() error {
+	if e.UserID == "" {
+		return NewValidationError("user_id", "is required")
+	}
+	if !e.Kind.Valid() {
+		return NewValidationError("kind", fmt.Sprintf("unknown feed kind %q", e.Kind))
+	}
+	if len(e.Params) > MaxFeedParams {
+		return NewValidationError("params", fmt.Sprintf("at most %d allowed", MaxFeedParams))
+	}
+	return nil
+}
+
+// GenerateFeedEntryID returns a UUIDv7. Its timestamp prefix makes entry
+// IDs sort by creation time, so a feed pages newest-first by ID alone.
+func GenerateFeedEntryID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
@@ -0,0 +1,53 @@
+package domain_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestFeedEntry_Validate(t *testing.T) {
+	tooMany := make(map[string]string, domain.MaxFeedParams+1)
+	for i := range domain.MaxFeedParams + 1 {
+		tooMany["p"+strconv.Itoa(i)] = "v"
+	}
+
+	tests := []struct {
+		name    string
+		entry   domain.FeedEntry
+		wantErr bool
+	}{
+		{name: "valid", entry: domain.FeedEntry{UserID: "u1", Kind: domain.FeedKindMention}},
+		{name: "missing user", entry: domain.FeedEntry{Kind: domain.FeedKindInvite}, wantErr: true},
+		{name: "unknown kind", entry: domain.FeedEntry{UserID: "u1", Kind: "like"}, wantErr: true},
+		{name: "too many params", entry: domain.FeedEntry{UserID: "u1", Kind: domain.FeedKindSystem, Params: tooMany}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFeedEntry_Unread(t *testing.T) {
+	assert.True(t, domain.FeedEntry{}.Unread())
+	assert.False(t, domain.FeedEntry{ReadAt: time.Now()}.Unread())
+}
+
+func TestGenerateFeedEntryID_SortsByCreation(t *testing.T) {
+	first := domain.GenerateFeedEntryID()
+	time.Sleep(2 * time.Millisecond)
+	second := domain.GenerateFeedEntryID()
+
+	assert.Less(t, first, second)
+}
@@ -0,0 +1,56 @@
+package domain
+
+import "strings"
+
+// HoneypotPolicy picks OTP requests that get a canary flow instead of a
+// real one: no code is stored or sent, and every verification attempt is
+// recorded for analysis. Numbers are matched in E.164. A nil
+// *HoneypotPolicy matches nothing. HoneypotPolicy is immutable; swap the
+// whole value to change it at runtime.
+type HoneypotPolicy struct {
+	prefixes []string
+	numbers  map[string]struct{}
+}
+
+// HoneypotPolicyConfig holds the inputs for NewHoneypotPolicy.
+type HoneypotPolicyConfig struct {
+	// Prefixes are E.164 prefixes of ranges abused for SMS pumping, e.g.
+	// premium-rate or international-network blocks such as "+8823".
+	Prefixes []string
+	// Numbers are individual E.164 numbers, e.g. seeded canaries that no
+	// real user owns.
+	Numbers []string
+}
+
+// NewHoneypotPolicy builds a HoneypotPolicy. Blank entries are ignored.
+func NewHoneypotPolicy(cfg HoneypotPolicyConfig) *HoneypotPolicy {
+	p := &HoneypotPolicy{numbers: make(map[string]struct{}, len(cfg.Numbers))}
+	for _, prefix := range cfg.Prefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			p.prefixes = append(p.prefixes, prefix)
+		}
+	}
+	for _, n := range cfg.Numbers {
+		if n = strings.TrimSpace(n); n != "" {
+			p.numbers[n] = struct{}{}
+		}
+	}
+	return p
+}
+
+// Match reports whether phone gets the canary flow, and which rule
+// matched: "number" or "prefix".
+func (hp *HoneypotPolicy) Match(phone PhoneNumber) (reason string, ok bool) {
+	if hp == nil {
+		return "", false
+	}
+	if _, ok := hp.numbers[phone.String()]; ok {
+		return "number", true
+	}
+	for _, prefix := range hp.prefixes {
+		if strings.HasPrefix(phone.String(), prefix) {
+			return "prefix", true
+		}
+	}
+	return "", false
+}
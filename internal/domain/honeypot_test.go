@@ -0,0 +1,43 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestHoneypotPolicy_Match(t *testing.T) {
+	policy := domain.NewHoneypotPolicy(domain.HoneypotPolicyConfig{
+		Prefixes: []string{" +79", ""},
+		Numbers:  []string{"+447911123456"},
+	})
+
+	tests := []struct {
+		name       string
+		phone      string
+		wantReason string
+		wantMatch  bool
+	}{
+		{name: "listed number", phone: "+447911123456", wantReason: "number", wantMatch: true},
+		{name: "listed prefix", phone: "+79123456789", wantReason: "prefix", wantMatch: true},
+		{name: "ordinary number", phone: "+14155552671"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := policy.Match(domain.MustPhoneNumber(tt.phone))
+
+			assert.Equal(t, tt.wantMatch, ok)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestHoneypotPolicy_NilMatchesNothing(t *testing.T) {
+	var policy *domain.HoneypotPolicy
+
+	_, ok := policy.Match(domain.MustPhoneNumber("+447911123456"))
+
+	assert.False(t, ok)
+}
@@ -4,6 +4,7 @@ package domain
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -72,6 +73,26 @@ func GenerateUserID() UserID {
 	return UserID{value: uuid.NewString()}
 }
 
+// importedUserNamespace scopes the name-based UUIDs of imported users.
+var importedUserNamespace = uuid.MustParse("6f1c2d4e-8a3b-5c7d-9e0f-1a2b3c4d5e6f")
+
+// ImportedUserID derives the UserID of a user imported from a legacy system
+// from its ID there, so re-running an import finds the users it created.
+func ImportedUserID(externalID string) UserID {
+	return UserID{value: uuid.NewSHA1(importedUserNamespace, []byte(externalID)).String()}
+}
+
+// placeholderUserNamespace scopes the name-based UUIDs of placeholder
+// senders in imported conversations.
+var placeholderUserNamespace = uuid.MustParse("0b6e3f7a-2c1d-5e8f-9a4b-7c3d2e1f0a9b")
+
+// PlaceholderUserID derives the sender ID for a participant of an imported
+// conversation who has no platform account, from the chat and the name the
+// export shows. The same name in the same chat always maps to the same ID.
+func PlaceholderUserID(chatID ChatID, name string) UserID {
+	return UserID{value: uuid.NewSHA1(placeholderUserNamespace, []byte(chatID.String()+"\x00"+name)).String()}
+}
+
 func (id UserID) String() string { return id.value }
 func (id UserID) IsZero() bool   { return id.value == "" }
 
@@ -80,11 +101,16 @@ type MessageID struct {
 	value string
 }
 
-// NewMessageID creates a MessageID from a raw string, validating it is a valid UUID.
+// NewMessageID creates a MessageID from a raw string, validating it is a valid
+// UUID or ULID. ULIDs are normalized to upper case so that string ordering
+// matches generation order.
 func NewMessageID(raw string) (MessageID, error) {
 	if raw == "" {
 		return MessageID{}, ErrEmptyID
 	}
+	if u, ok := parseULID(raw); ok {
+		return MessageID{value: u.String()}, nil
+	}
 	if _, err := uuid.Parse(raw); err != nil {
 		return MessageID{}, fmt.Errorf("invalid message ID %q: %w", raw, ErrInvalidID)
 	}
@@ -108,6 +134,16 @@ func GenerateMessageID() MessageID {
 func (id MessageID) String() string { return id.value }
 func (id MessageID) IsZero() bool   { return id.value == "" }
 
+// Time returns the creation time embedded in a ULID MessageID at millisecond
+// precision. ok is false for UUID MessageIDs, which carry no timestamp.
+func (id MessageID) Time() (t time.Time, ok bool) {
+	u, ok := parseULID(id.value)
+	if !ok {
+		return time.Time{}, false
+	}
+	return u.time(), true
+}
+
 // SessionID is a value object representing a unique session identifier.
 type SessionID struct {
 	value string
@@ -181,11 +217,15 @@ type ClientMessageID struct {
 }
 
 // NewClientMessageID creates a ClientMessageID from a raw string.
-// Client message IDs must be non-empty but can be any format the client chooses.
+// Client message IDs must be non-empty but can be any format the client
+// chooses, outside the namespace reserved by SystemClientMessageIDPrefix.
 func NewClientMessageID(raw string) (ClientMessageID, error) {
 	if raw == "" {
 		return ClientMessageID{}, ErrEmptyID
 	}
+	if isSystemClientMessageID(raw) {
+		return ClientMessageID{}, fmt.Errorf("client message ID prefix %q is reserved: %w", SystemClientMessageIDPrefix, ErrInvalidID)
+	}
 	if len(raw) > MaxClientMessageIDLength {
 		return ClientMessageID{}, fmt.Errorf("client message ID exceeds max length %d: %w", MaxClientMessageIDLength, ErrInvalidID)
 	}
@@ -243,3 +283,8 @@ func GenerateConnectionID() ConnectionID {
 
 func (id ConnectionID) String() string { return id.value }
 func (id ConnectionID) IsZero() bool   { return id.value == "" }
+
+// GenerateUploadID returns a random attachment upload ID.
+func GenerateUploadID() string {
+	return uuid.NewString()
+}
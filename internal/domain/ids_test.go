@@ -94,6 +94,50 @@ func TestMessageID(t *testing.T) {
 		id := domain.GenerateMessageID()
 		assert.False(t, id.IsZero())
 	})
+
+	t.Run("valid ULID", func(t *testing.T) {
+		id, err := domain.NewMessageID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+		require.NoError(t, err)
+		assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", id.String())
+	})
+
+	t.Run("lower-case ULID is normalized", func(t *testing.T) {
+		id, err := domain.NewMessageID("01arz3ndektsv4rrffq69g5fav")
+		require.NoError(t, err)
+		assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", id.String())
+	})
+
+	t.Run("Crockford aliases decode to their canonical symbols", func(t *testing.T) {
+		id, err := domain.NewMessageID("OlARZ3NDEKTSV4RRFFQ69G5FAU")
+		require.NoError(t, err)
+		assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", id.String())
+
+		id, err = domain.NewMessageID("0IARZ3NDEKTSV4RRFFQ69G5FAu")
+		require.NoError(t, err)
+		assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", id.String())
+	})
+
+	t.Run("invalid IDs", func(t *testing.T) {
+		invalid := []string{
+			"not-an-id",
+			"81ARZ3NDEKTSV4RRFFQ69G5FAV", // ULID timestamp overflow
+			"01ARZ3NDEKTSV4RRFFQ69G5FA-", // not in the Crockford alphabet
+			"01ARZ3NDEKTSV4RRFFQ69G5FA",  // 25 chars
+		}
+		for _, raw := range invalid {
+			_, err := domain.NewMessageID(raw)
+			assert.ErrorIs(t, err, domain.ErrInvalidID, raw)
+		}
+	})
+
+	t.Run("Time is only available for ULIDs", func(t *testing.T) {
+		_, ok := domain.MustMessageID(validUUID).Time()
+		assert.False(t, ok)
+
+		ts, ok := domain.MustMessageID("01ARZ3NDEKTSV4RRFFQ69G5FAV").Time()
+		require.True(t, ok)
+		assert.Equal(t, int64(1469922850259), ts.UnixMilli())
+	})
 }
 
 func TestSessionID(t *testing.T) {
@@ -147,6 +191,12 @@ func TestClientMessageID(t *testing.T) {
 		assert.ErrorIs(t, err, domain.ErrInvalidID)
 	})
 
+	t.Run("reserved system prefix returns error", func(t *testing.T) {
+		_, err := domain.NewClientMessageID(domain.SystemClientMessageID("evt-1"))
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrInvalidID)
+	})
+
 	t.Run("max length accepted", func(t *testing.T) {
 		maxID := strings.Repeat("a", domain.MaxClientMessageIDLength)
 		id, err := domain.NewClientMessageID(maxID)
@@ -187,3 +237,22 @@ func TestConnectionID(t *testing.T) {
 		assert.False(t, id.IsZero())
 	})
 }
+
+func TestImportedUserID(t *testing.T) {
+	a := domain.ImportedUserID("legacy-42")
+
+	assert.Equal(t, a, domain.ImportedUserID("legacy-42"), "derivation is deterministic")
+	assert.NotEqual(t, a, domain.ImportedUserID("legacy-43"))
+	parsed, err := domain.NewUserID(a.String())
+	require.NoError(t, err)
+	assert.Equal(t, a, parsed)
+}
+
+func TestPlaceholderUserID(t *testing.T) {
+	chatA := domain.MustChatID("11111111-1111-4111-8111-111111111111")
+	chatB := domain.MustChatID("22222222-2222-4222-8222-222222222222")
+
+	assert.Equal(t, domain.PlaceholderUserID(chatA, "Alice"), domain.PlaceholderUserID(chatA, "Alice"))
+	assert.NotEqual(t, domain.PlaceholderUserID(chatA, "Alice"), domain.PlaceholderUserID(chatB, "Alice"), "scoped to the chat")
+	assert.NotEqual(t, domain.PlaceholderUserID(chatA, "Alice"), domain.PlaceholderUserID(chatA, "Bob"))
+}
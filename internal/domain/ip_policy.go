@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IPPolicy blocks client addresses by static CIDR list and by country. A
+// nil *IPPolicy permits every address. IPPolicy is immutable; swap the
+// whole value to change it at runtime.
+type IPPolicy struct {
+	allow     []netip.Prefix
+	block     []netip.Prefix
+	countries map[string]struct{}
+}
+
+// IPPolicyConfig holds the inputs for NewIPPolicy.
+type IPPolicyConfig struct {
+	// Allow exempts these ranges from every check, including external
+	// reputation, e.g. office or load-test ranges.
+	Allow []string
+	// Block rejects these ranges, e.g. known-bad hosting providers.
+	Block []string
+	// BlockCountries rejects addresses located in these ISO 3166-1 alpha-2
+	// regions, matched case-insensitively.
+	BlockCountries []string
+}
+
+// NewIPPolicy builds an IPPolicy. It returns ErrInvalidInput for a
+// malformed CIDR. A bare address is treated as a single-host range.
+func NewIPPolicy(cfg IPPolicyConfig) (*IPPolicy, error) {
+	allow, err := parsePrefixes(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+	block, err := parsePrefixes(cfg.Block)
+	if err != nil {
+		return nil, err
+	}
+	return &IPPolicy{allow: allow, block: block, countries: regionSet(cfg.BlockCountries)}, nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, c := range cidrs {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			addr, err := netip.ParseAddr(c)
+			if err != nil {
+				return nil, NewValidationError("cidr", fmt.Sprintf("%q is not an address or CIDR", c))
+			}
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, NewValidationError("cidr", fmt.Sprintf("%q is not an address or CIDR", c))
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// Exempt reports whether addr is in an allowed range.
+func (p *IPPolicy) Exempt(addr netip.Addr) bool {
+	if p == nil {
+		return false
+	}
+	return containsAddr(p.allow, addr.Unmap())
+}
+
+// CheckAddr returns ErrForbidden if addr is in a blocked range.
+func (p *IPPolicy) CheckAddr(addr netip.Addr) error {
+	if p == nil {
+		return nil
+	}
+	if containsAddr(p.block, addr.Unmap()) {
+		return fmt.Errorf("client address is in a blocked range: %w", ErrForbidden)
+	}
+	return nil
+}
+
+// BlocksCountries reports whether the policy blocks any country, so
+// callers can skip the country lookup when it does not.
+func (p *IPPolicy) BlocksCountries() bool {
+	return p != nil && len(p.countries) > 0
+}
+
+// CheckCountry returns ErrForbidden if country is blocked. An unknown
+// ("") country is permitted.
+func (p *IPPolicy) CheckCountry(country string) error {
+	if p == nil || country == "" {
+		return nil
+	}
+	if _, blocked := p.countries[strings.ToUpper(country)]; blocked {
+		return fmt.Errorf("client country %s is blocked: %w", strings.ToUpper(country), ErrForbidden)
+	}
+	return nil
+}
+
+// RateLimitSubnet returns the subnet addr is rate limited with: its IPv4
+// /24 or IPv6 /64. Addresses rotated within one allocation share it.
+func RateLimitSubnet(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := RateLimitSubnetV6Bits
+	if addr.Is4() {
+		bits = RateLimitSubnetV4Bits
+	}
+	p, _ := addr.Prefix(bits)
+	return p
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package domain_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestIPPolicy(t *testing.T) {
+	policy, err := domain.NewIPPolicy(domain.IPPolicyConfig{
+		Allow:          []string{"203.0.113.7"},
+		Block:          []string{"203.0.113.0/24", " 2001:db8::/32", ""},
+		BlockCountries: []string{" kp", "RU"},
+	})
+	require.NoError(t, err)
+
+	t.Run("blocked range", func(t *testing.T) {
+		assert.ErrorIs(t, policy.CheckAddr(netip.MustParseAddr("203.0.113.9")), domain.ErrForbidden)
+		assert.ErrorIs(t, policy.CheckAddr(netip.MustParseAddr("2001:db8::1")), domain.ErrForbidden)
+		assert.ErrorIs(t, policy.CheckAddr(netip.MustParseAddr("::ffff:203.0.113.9")), domain.ErrForbidden,
+			"IPv4-mapped addresses match IPv4 ranges")
+		assert.NoError(t, policy.CheckAddr(netip.MustParseAddr("198.51.100.1")))
+	})
+
+	t.Run("allow list exempts", func(t *testing.T) {
+		assert.True(t, policy.Exempt(netip.MustParseAddr("203.0.113.7")))
+		assert.False(t, policy.Exempt(netip.MustParseAddr("203.0.113.8")))
+	})
+
+	t.Run("countries", func(t *testing.T) {
+		assert.True(t, policy.BlocksCountries())
+		assert.ErrorIs(t, policy.CheckCountry("kp"), domain.ErrForbidden)
+		assert.NoError(t, policy.CheckCountry("US"))
+		assert.NoError(t, policy.CheckCountry(""))
+	})
+
+	t.Run("invalid cidr", func(t *testing.T) {
+		_, err := domain.NewIPPolicy(domain.IPPolicyConfig{Block: []string{"10.0.0.0/33"}})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestIPPolicy_NilAllowsAll(t *testing.T) {
+	var policy *domain.IPPolicy
+
+	assert.NoError(t, policy.CheckAddr(netip.MustParseAddr("203.0.113.9")))
+	assert.NoError(t, policy.CheckCountry("KP"))
+	assert.False(t, policy.Exempt(netip.MustParseAddr("203.0.113.9")))
+	assert.False(t, policy.BlocksCountries())
+}
+
+func TestRateLimitSubnet(t *testing.T) {
+	for addr, want := range map[string]string{
+		"203.0.113.7":             "203.0.113.0/24",
+		"::ffff:203.0.113.7":      "203.0.113.0/24",
+		"2001:db8:1:2:3:4:5:6":    "2001:db8:1:2::/64",
+		"2001:db8:1:2:ffff::9999": "2001:db8:1:2::/64",
+	} {
+		assert.Equal(t, want, domain.RateLimitSubnet(netip.MustParseAddr(addr)).String(), addr)
+	}
+}
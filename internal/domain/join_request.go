@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// JoinRequest is a user's pending request to join a group chat. It is held
+// as a pending membership until an admin approves or rejects it, or it
+// expires.
+type JoinRequest struct {
+	ChatID    string
+	UserID    string
+	Note      string // optional message to the admins
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// NewJoinRequest returns a request by userID to join chatID made at now.
+func NewJoinRequest(chatID, userID, note string, now time.Time) (JoinRequest, error) {
+	if utf8.RuneCountInString(note) > MaxJoinRequestNoteLength {
+		return JoinRequest{}, NewValidationError("note", fmt.Sprintf("at most %d characters", MaxJoinRequestNoteLength))
+	}
+	return JoinRequest{
+		ChatID:    chatID,
+		UserID:    userID,
+		Note:      note,
+		CreatedAt: now,
+		ExpiresAt: now.Add(JoinRequestTTL),
+	}, nil
+}
+
+// Expired reports whether r can no longer be decided at now.
+func (r JoinRequest) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
@@ -0,0 +1,31 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestNewJoinRequest(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("expires after the TTL", func(t *testing.T) {
+		r, err := domain.NewJoinRequest("chat-001", "user-001", "hi", now)
+
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(domain.JoinRequestTTL), r.ExpiresAt)
+		assert.False(t, r.Expired(now.Add(domain.JoinRequestTTL-time.Second)))
+		assert.True(t, r.Expired(now.Add(domain.JoinRequestTTL)))
+	})
+
+	t.Run("note too long", func(t *testing.T) {
+		_, err := domain.NewJoinRequest("chat-001", "user-001", strings.Repeat("é", domain.MaxJoinRequestNoteLength+1), now)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
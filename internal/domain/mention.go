@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"regexp"
+	"slices"
+)
+
+// mentionPattern matches a mention in message text: the mentioned user's
+// ID in angle brackets, e.g. "<@0b4a1c3e-...>". Clients insert the token
+// when a member is picked and render it as the member's name.
+var mentionPattern = regexp.MustCompile(`<@([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})>`)
+
+// Mentions returns the IDs of the users text mentions, in order of first
+// mention, without repeats and at most MaxMentions of them.
+func Mentions(text string) []string {
+	var ids []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if slices.Contains(ids, m[1]) {
+			continue
+		}
+		ids = append(ids, m[1])
+		if len(ids) == MaxMentions {
+			break
+		}
+	}
+	return ids
+}
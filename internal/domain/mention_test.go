@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestMentions(t *testing.T) {
+	alice := "0b4a1c3e-1111-4aaa-8bbb-000000000001"
+	bob := "0b4a1c3e-2222-4aaa-8bbb-000000000002"
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "none", text: "hello @alice", want: nil},
+		{name: "one", text: "hi <@" + alice + ">!", want: []string{alice}},
+		{name: "repeats count once", text: "<@" + bob + "> <@" + alice + "> <@" + bob + ">", want: []string{bob, alice}},
+		{name: "malformed IDs are text", text: "<@alice> <@" + alice[:8] + ">", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, domain.Mentions(tt.text))
+		})
+	}
+
+	t.Run("capped", func(t *testing.T) {
+		var b strings.Builder
+		for i := range domain.MaxMentions + 5 {
+			fmt.Fprintf(&b, "<@0b4a1c3e-0000-4aaa-8bbb-%012d> ", i)
+		}
+		assert.Len(t, domain.Mentions(b.String()), domain.MaxMentions)
+	})
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "strconv"
+
+// Messages of a chat are spread over one or more partitions ("shards") of
+// the messages_v2 table so a very active chat does not hot-partition on
+// chat_id (ADR-007 §6). A chat's shard count is stored on its chat record;
+// chats without one have a single shard. Sequences are assigned to shards
+// round robin, so consecutive writes land on different partitions and a
+// reader can locate any sequence from the count alone.
+
+// MessageShard returns the shard holding sequence for a chat with shards
+// shards. shards below 1 is treated as 1.
+func MessageShard(sequence uint64, shards int) int {
+	if shards <= 1 {
+		return 0
+	}
+	return int(sequence % uint64(shards))
+}
+
+// MessagePartitionKey returns the messages_v2 partition key of a chat's
+// shard, e.g. "<chat_id>#shard3".
+func MessagePartitionKey(chatID string, shard int) string {
+	return chatID + "#shard" + strconv.Itoa(shard)
+}
+
+// IsValidMessageShards reports whether n is a usable shard count.
+func IsValidMessageShards(n int) bool {
+	return n >= 1 && n <= MaxMessageShards
+}
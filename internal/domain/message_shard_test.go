@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestMessageShard(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence uint64
+		shards   int
+		want     int
+	}{
+		{name: "unsharded", sequence: 41, shards: 1, want: 0},
+		{name: "missing count", sequence: 41, shards: 0, want: 0},
+		{name: "round robin", sequence: 41, shards: 4, want: 1},
+		{name: "wraps", sequence: 44, shards: 4, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, domain.MessageShard(tt.sequence, tt.shards))
+		})
+	}
+}
+
+func TestMessagePartitionKey(t *testing.T) {
+	assert.Equal(t, "chat-1#shard3", domain.MessagePartitionKey("chat-1", 3))
+}
+
+func TestIsValidMessageShards(t *testing.T) {
+	assert.False(t, domain.IsValidMessageShards(0))
+	assert.True(t, domain.IsValidMessageShards(1))
+	assert.True(t, domain.IsValidMessageShards(domain.MaxMessageShards))
+	assert.False(t, domain.IsValidMessageShards(domain.MaxMessageShards+1))
+}
@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTP code alphabets. OTPAlphabetAlphanumeric drops 0/O, 1/I/L so codes
+// survive being read aloud or retyped.
+const (
+	OTPAlphabetDigits       = "0123456789"
+	OTPAlphabetAlphanumeric = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+// OTPRisk grades an OTP request. Higher risk gets a harder code with a
+// shorter life.
+type OTPRisk int
+
+const (
+	OTPRiskStandard OTPRisk = iota
+	OTPRiskElevated
+	OTPRiskHigh
+)
+
+// String returns the metric/log label for the risk level.
+func (r OTPRisk) String() string {
+	switch r {
+	case OTPRiskElevated:
+		return "elevated"
+	case OTPRiskHigh:
+		return "high"
+	default:
+		return "standard"
+	}
+}
+
+// OTPCodeFormat is the shape and lifetime of the codes issued at one risk
+// level.
+type OTPCodeFormat struct {
+	Length   int
+	Alphabet string // Digits and upper-case letters only
+	TTL      time.Duration
+}
+
+// Params returns the canonical encoding of the format. It is stored with
+// each OTP and bound into its MAC, so a code issued under one format never
+// verifies under another.
+func (f OTPCodeFormat) Params() string {
+	return "len=" + strconv.Itoa(f.Length) +
+		";alphabet=" + f.Alphabet +
+		";ttl=" + strconv.FormatInt(int64(f.TTL/time.Second), 10)
+}
+
+// ParseOTPCodeFormat decodes the output of Params.
+func ParseOTPCodeFormat(params string) (OTPCodeFormat, error) {
+	var f OTPCodeFormat
+	for _, field := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(field, "=")
+		var err error
+		switch key {
+		case "len":
+			f.Length, err = strconv.Atoi(value)
+		case "alphabet":
+			f.Alphabet = value
+		case "ttl":
+			var secs int64
+			secs, err = strconv.ParseInt(value, 10, 64)
+			f.TTL = time.Duration(secs) * time.Second
+		default:
+			err = fmt.Errorf("unknown field %q", key)
+		}
+		if err != nil {
+			return OTPCodeFormat{}, fmt.Errorf("parse otp code format %q: %w", params, err)
+		}
+	}
+	return f, nil
+}
+
+// NormalizeOTP canonicalizes a user-entered code: surrounding space is
+// dropped and letters are upper-cased, matching the alphabets' case.
+func NormalizeOTP(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validate checks the format against the OTP bounds.
+func (f OTPCodeFormat) validate() error {
+	if f.Length < 1 || f.Length > OTPMaxCodeLength {
+		return fmt.Errorf("length %d outside [1, %d]", f.Length, OTPMaxCodeLength)
+	}
+	seen := make(map[rune]struct{}, len(f.Alphabet))
+	for _, c := range f.Alphabet {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return fmt.Errorf("alphabet character %q is not a digit or upper-case letter", c)
+		}
+		if _, dup := seen[c]; dup {
+			return fmt.Errorf("alphabet character %q repeats", c)
+		}
+		seen[c] = struct{}{}
+	}
+	if space := math.Pow(float64(len(seen)), float64(f.Length)); space < OTPMinCodeSpace {
+		return fmt.Errorf("%.0f possible codes is below the minimum %d", space, OTPMinCodeSpace)
+	}
+	if f.TTL < OTPMinValidity || f.TTL > OTPMaxValidity {
+		return fmt.Errorf("ttl %s outside [%s, %s]", f.TTL, OTPMinValidity, OTPMaxValidity)
+	}
+	return nil
+}
+
+// OTPPolicy picks the code format for an OTP request by risk. A nil
+// *OTPPolicy issues the default formats and grades every number standard.
+// OTPPolicy is immutable; swap the whole value to change it at runtime.
+type OTPPolicy struct {
+	formats  [OTPRiskHigh + 1]OTPCodeFormat
+	elevated map[string]struct{}
+	high     map[string]struct{}
+}
+
+// OTPPolicyConfig holds the inputs for NewOTPPolicy. A zero format field
+// takes the default for its level. Region codes are ISO 3166-1 alpha-2.
+type OTPPolicyConfig struct {
+	Standard OTPCodeFormat
+	Elevated OTPCodeFormat
+	High     OTPCodeFormat
+
+	// ElevatedRegions and HighRegions raise the risk of numbers from these
+	// regions. High wins when a region is in both.
+	ElevatedRegions []string
+	HighRegions     []string
+}
+
+// defaultOTPFormats are the formats issued when none is configured. The
+// standard format is the original fixed 6-digit, 5-minute code.
+var defaultOTPFormats = [OTPRiskHigh + 1]OTPCodeFormat{
+	OTPRiskStandard: {Length: 6, Alphabet: OTPAlphabetDigits, TTL: OTPValidityDuration},
+	OTPRiskElevated: {Length: OTPElevatedLength, Alphabet: OTPAlphabetDigits, TTL: OTPElevatedTTL},
+	OTPRiskHigh:     {Length: OTPHighLength, Alphabet: OTPAlphabetAlphanumeric, TTL: OTPHighTTL},
+}
+
+// NewOTPPolicy builds an OTPPolicy, returning ErrConfigInvalid if a format
+// is outside the OTP bounds.
+func NewOTPPolicy(cfg OTPPolicyConfig) (*OTPPolicy, error) {
+	p := &OTPPolicy{
+		elevated: regionSet(cfg.ElevatedRegions),
+		high:     regionSet(cfg.HighRegions),
+	}
+	for risk, f := range []OTPCodeFormat{cfg.Standard, cfg.Elevated, cfg.High} {
+		def := defaultOTPFormats[risk]
+		if f.Length == 0 {
+			f.Length = def.Length
+		}
+		if f.Alphabet == "" {
+			f.Alphabet = def.Alphabet
+		}
+		if f.TTL == 0 {
+			f.TTL = def.TTL
+		}
+		if err := f.validate(); err != nil {
+			return nil, fmt.Errorf("%w: otp %s format: %w", ErrConfigInvalid, OTPRisk(risk), err)
+		}
+		p.formats[risk] = f
+	}
+	return p, nil
+}
+
+// Assess grades a phone number by region. Signals outside the number, such
+// as client reputation, are combined by the caller, taking the higher grade.
+func (p *OTPPolicy) Assess(phone PhoneNumber) OTPRisk {
+	if p == nil || phone.region == "" {
+		return OTPRiskStandard
+	}
+	if _, ok := p.high[phone.region]; ok {
+		return OTPRiskHigh
+	}
+	if _, ok := p.elevated[phone.region]; ok {
+		return OTPRiskElevated
+	}
+	return OTPRiskStandard
+}
+
+// Format returns the code format for risk.
+func (p *OTPPolicy) Format(risk OTPRisk) OTPCodeFormat {
+	risk = min(max(risk, OTPRiskStandard), OTPRiskHigh)
+	if p == nil {
+		return defaultOTPFormats[risk]
+	}
+	return p.formats[risk]
+}
@@ -0,0 +1,68 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestOTPPolicy_Defaults(t *testing.T) {
+	p, err := domain.NewOTPPolicy(domain.OTPPolicyConfig{})
+	require.NoError(t, err)
+
+	standard := p.Format(domain.OTPRiskStandard)
+	assert.Equal(t, domain.OTPCodeFormat{Length: 6, Alphabet: domain.OTPAlphabetDigits, TTL: domain.OTPValidityDuration}, standard)
+	assert.Equal(t, standard, (*domain.OTPPolicy)(nil).Format(domain.OTPRiskStandard))
+
+	high := p.Format(domain.OTPRiskHigh)
+	assert.Equal(t, domain.OTPAlphabetAlphanumeric, high.Alphabet)
+	assert.Less(t, high.TTL, standard.TTL)
+	assert.Equal(t, high, p.Format(domain.OTPRisk(99)), "out-of-range risk clamps")
+}
+
+func TestOTPPolicy_Assess(t *testing.T) {
+	p, err := domain.NewOTPPolicy(domain.OTPPolicyConfig{
+		ElevatedRegions: []string{"gb", "US"},
+		HighRegions:     []string{"US"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, domain.OTPRiskHigh, p.Assess(domain.MustPhoneNumber("+14155552671")))
+	assert.Equal(t, domain.OTPRiskElevated, p.Assess(domain.MustPhoneNumber("+447911123456")))
+	assert.Equal(t, domain.OTPRiskStandard, p.Assess(domain.MustPhoneNumber("+4915123456789")))
+	assert.Equal(t, domain.OTPRiskStandard, (*domain.OTPPolicy)(nil).Assess(domain.MustPhoneNumber("+14155552671")))
+}
+
+func TestOTPPolicy_InvalidFormat(t *testing.T) {
+	tests := map[string]domain.OTPCodeFormat{
+		"too few codes":      {Length: 4},
+		"too long":           {Length: 13},
+		"lower-case letters": {Alphabet: "abcdefghij"},
+		"repeated character": {Alphabet: "0012345678"},
+		"ttl above default":  {TTL: 10 * time.Minute},
+		"ttl too short":      {TTL: 10 * time.Second},
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := domain.NewOTPPolicy(domain.OTPPolicyConfig{Elevated: f})
+			assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+			assert.ErrorContains(t, err, "elevated")
+		})
+	}
+}
+
+func TestOTPCodeFormat_Params(t *testing.T) {
+	f := domain.OTPCodeFormat{Length: 8, Alphabet: domain.OTPAlphabetDigits, TTL: 2 * time.Minute}
+
+	assert.Equal(t, "len=8;alphabet=0123456789;ttl=120", f.Params())
+	parsed, err := domain.ParseOTPCodeFormat(f.Params())
+	require.NoError(t, err)
+	assert.Equal(t, f, parsed)
+	_, err = domain.ParseOTPCodeFormat("len=8;digits=yes")
+	assert.Error(t, err)
+	assert.Equal(t, "AB12CD", domain.NormalizeOTP(" ab12cd\n"))
+}
@@ -0,0 +1,13 @@
+package domain
+
+// The synthetic canary logs in with test phone numbers ("sink numbers")
+// whose OTPs Chat Mgmt writes to Redis instead of sending by SMS, so the
+// canary can complete the real login flow without a phone. Chat Mgmt
+// writes the code for at most OTPMaxValidity; the canary reads it and
+// deletes it once verified, so a later request never sees a stale code.
+
+// OTPSinkKey returns the Redis key holding the pending OTP of a sink
+// number in E.164.
+func OTPSinkKey(phone string) string {
+	return "otp_sink:" + phone
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// ChatMember is one member of a chat. Pending join requests are not
+// members.
+type ChatMember struct {
+	UserID   string
+	Role     MemberRole
+	JoinedAt time.Time
+}
+
+// Membership is one chat a user belongs to, seen from the user's side.
+type Membership struct {
+	ChatID   string
+	Role     MemberRole
+	JoinedAt time.Time
+}
+
+// ChooseSuccessor picks who inherits a chat when its owner, departingID,
+// leaves the platform: the longest-standing admin, otherwise the
+// longest-standing member. Ties go to the lower user ID so the choice is
+// deterministic. It reports false if nobody else is left.
+func ChooseSuccessor(members []ChatMember, departingID string) (ChatMember, bool) {
+	var (
+		best  ChatMember
+		found bool
+	)
+	for _, m := range members {
+		if m.UserID == departingID || !m.Role.Valid() || m.Role == MemberRoleOwner {
+			continue
+		}
+		if !found || successorBefore(m, best) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+// successorBefore reports whether a ranks ahead of b for succession.
+func successorBefore(a, b ChatMember) bool {
+	if a.Role.rank() != b.Role.rank() {
+		return a.Role.rank() > b.Role.rank()
+	}
+	if !a.JoinedAt.Equal(b.JoinedAt) {
+		return a.JoinedAt.Before(b.JoinedAt)
+	}
+	return a.UserID < b.UserID
+}
@@ -0,0 +1,61 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestChooseSuccessor(t *testing.T) {
+	t0 := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	owner := domain.ChatMember{UserID: "user-001", Role: domain.MemberRoleOwner, JoinedAt: t0}
+
+	tests := []struct {
+		name    string
+		members []domain.ChatMember
+		want    string
+	}{
+		{
+			name: "admins before members",
+			members: []domain.ChatMember{
+				owner,
+				{UserID: "user-002", Role: domain.MemberRoleMember, JoinedAt: t0},
+				{UserID: "user-003", Role: domain.MemberRoleAdmin, JoinedAt: t0.Add(time.Hour)},
+			},
+			want: "user-003",
+		},
+		{
+			name: "longest-standing member",
+			members: []domain.ChatMember{
+				owner,
+				{UserID: "user-002", Role: domain.MemberRoleMember, JoinedAt: t0.Add(time.Hour)},
+				{UserID: "user-003", Role: domain.MemberRoleMember, JoinedAt: t0.Add(time.Minute)},
+			},
+			want: "user-003",
+		},
+		{
+			name: "tie broken by user ID",
+			members: []domain.ChatMember{
+				{UserID: "user-005", Role: domain.MemberRoleAdmin, JoinedAt: t0},
+				{UserID: "user-004", Role: domain.MemberRoleAdmin, JoinedAt: t0},
+				owner,
+			},
+			want: "user-004",
+		},
+		{
+			name:    "nobody left",
+			members: []domain.ChatMember{owner, {UserID: "user-002", Role: ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := domain.ChooseSuccessor(tt.members, owner.UserID)
+
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got.UserID)
+		})
+	}
+}
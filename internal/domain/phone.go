@@ -3,6 +3,7 @@ package domain
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // e164Pattern matches E.164 phone numbers: + followed by 7-15 digits.
@@ -11,19 +12,52 @@ var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
 // PhoneNumber is a value object representing a phone number in E.164 format.
 // Always valid in memory — use NewPhoneNumber to construct.
 type PhoneNumber struct {
-	value string
+	value       string
+	callingCode string
+	region      string
+	lineType    LineType
 }
 
-// NewPhoneNumber creates a PhoneNumber from a raw string, validating E.164 format.
-// E.164 requires: '+' prefix, 7-15 digits, no leading zero after country code.
+// NewPhoneNumber creates a PhoneNumber from user-entered input, normalizing it
+// to E.164 before validation.
+//
+// Normalization accepts the common ways users type international numbers:
+// spaces, dashes, dots, and parentheses are removed, and a leading "00"
+// international prefix is rewritten to "+". The result must then satisfy:
+//   - E.164 shape: '+' prefix, 7-15 digits, no leading zero
+//   - an ITU-T assigned country calling code
+//   - the national number length for the country, when metadata is known
 func NewPhoneNumber(raw string) (PhoneNumber, error) {
 	if raw == "" {
 		return PhoneNumber{}, fmt.Errorf("phone number cannot be empty: %w", ErrInvalidPhoneNumber)
 	}
-	if !e164Pattern.MatchString(raw) {
+
+	normalized := normalizePhone(raw)
+	if !e164Pattern.MatchString(normalized) {
 		return PhoneNumber{}, fmt.Errorf("phone number %q is not valid E.164: %w", raw, ErrInvalidPhoneNumber)
 	}
-	return PhoneNumber{value: raw}, nil
+
+	digits := normalized[1:]
+	cc, ok := splitCallingCode(digits)
+	if !ok {
+		return PhoneNumber{}, fmt.Errorf("phone number %q has unassigned country code: %w", raw, ErrInvalidPhoneNumber)
+	}
+	national := digits[len(cc):]
+
+	p := PhoneNumber{value: normalized, callingCode: cc}
+
+	meta := lookupRegion(cc, national)
+	if meta == nil {
+		// Assigned code without length metadata: E.164 shape is the only check.
+		return p, nil
+	}
+	if !meta.validLength(len(national)) {
+		return PhoneNumber{}, fmt.Errorf("phone number %q has invalid length for %s: %w", raw, meta.region, ErrInvalidPhoneNumber)
+	}
+
+	p.region = meta.region
+	p.lineType = meta.classify(national)
+	return p, nil
 }
 
 // MustPhoneNumber creates a PhoneNumber, panicking on invalid input. Use only in tests.
@@ -35,5 +69,37 @@ func MustPhoneNumber(raw string) PhoneNumber {
 	return p
 }
 
+// normalizePhone strips formatting characters and rewrites the "00"
+// international dialing prefix. It does not attempt to infer a country for
+// national-format input; numbers without a prefix fail E.164 validation.
+func normalizePhone(raw string) string {
+	var b strings.Builder
+	b.Grow(len(raw))
+	for _, r := range strings.TrimSpace(raw) {
+		switch r {
+		case ' ', '\u00A0', '-', '.', '(', ')':
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+	if strings.HasPrefix(s, "00") {
+		s = "+" + s[2:]
+	}
+	return s
+}
+
 func (p PhoneNumber) String() string { return p.value }
 func (p PhoneNumber) IsZero() bool   { return p.value == "" }
+
+// CallingCode returns the country calling code without the '+' prefix.
+func (p PhoneNumber) CallingCode() string { return p.callingCode }
+
+// Region returns the ISO 3166-1 alpha-2 region, or "" when the calling code
+// has no region metadata.
+func (p PhoneNumber) Region() string { return p.region }
+
+// LineType returns the mobile/fixed-line classification, or LineTypeUnknown
+// when the numbering plan does not distinguish them.
+func (p PhoneNumber) LineType() LineType { return p.lineType }
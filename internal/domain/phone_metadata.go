@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+)
+
+// LineType classifies a phone number as mobile or fixed-line.
+type LineType string
+
+const (
+	LineTypeUnknown   LineType = ""
+	LineTypeMobile    LineType = "mobile"
+	LineTypeFixedLine LineType = "fixed_line"
+)
+
+// assignedCallingCodes lists ITU-T E.164 assigned country calling codes,
+// including shared and non-geographic codes. Codes are prefix-free, so at
+// most one of the 1-, 2-, or 3-digit prefixes of a number can match.
+var assignedCallingCodes = buildCallingCodeSet(
+	"1 7 " +
+		"20 27 30 31 32 33 34 36 39 40 41 43 44 45 46 47 48 49 " +
+		"51 52 53 54 55 56 57 58 60 61 62 63 64 65 66 81 82 84 86 " +
+		"90 91 92 93 94 95 98 " +
+		"211 212 213 216 218 220 221 222 223 224 225 226 227 228 229 " +
+		"230 231 232 233 234 235 236 237 238 239 240 241 242 243 244 245 246 247 248 249 " +
+		"250 251 252 253 254 255 256 257 258 260 261 262 263 264 265 266 267 268 269 " +
+		"290 291 297 298 299 " +
+		"350 351 352 353 354 355 356 357 358 359 370 371 372 373 374 375 376 377 378 " +
+		"380 381 382 383 385 386 387 389 420 421 423 " +
+		"500 501 502 503 504 505 506 507 508 509 590 591 592 593 594 595 596 597 598 599 " +
+		"670 672 673 674 675 676 677 678 679 680 681 682 683 685 686 687 688 689 690 691 692 " +
+		"800 808 850 852 853 855 856 870 878 880 881 882 883 886 888 " +
+		"960 961 962 963 964 965 966 967 968 970 971 972 973 974 975 976 977 979 " +
+		"992 993 994 995 996 998",
+)
+
+func buildCallingCodeSet(codes string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, c := range strings.Fields(codes) {
+		set[c] = struct{}{}
+	}
+	return set
+}
+
+// splitCallingCode returns the country calling code prefix of an E.164 digit
+// string (without '+').
+func splitCallingCode(digits string) (string, bool) {
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		if _, ok := assignedCallingCodes[digits[:n]]; ok {
+			return digits[:n], true
+		}
+	}
+	return "", false
+}
+
+// regionMeta describes the numbering plan of one region.
+type regionMeta struct {
+	region         string   // ISO 3166-1 alpha-2
+	callingCode    string   // without '+'
+	leadingDigits  []string // national prefixes selecting this region among a shared calling code
+	lengths        []int    // valid national significant number lengths
+	mobilePrefixes []string // nil when the plan does not distinguish mobile numbers
+}
+
+func (m *regionMeta) validLength(n int) bool {
+	return slices.Contains(m.lengths, n)
+}
+
+func (m *regionMeta) classify(national string) LineType {
+	if m.mobilePrefixes == nil {
+		return LineTypeUnknown
+	}
+	for _, prefix := range m.mobilePrefixes {
+		if strings.HasPrefix(national, prefix) {
+			return LineTypeMobile
+		}
+	}
+	return LineTypeFixedLine
+}
+
+// regionMetadata covers the regions the platform launches in. Calling codes
+// missing here are still accepted on E.164 shape alone. Entries with
+// leadingDigits must precede the catch-all entry for the same calling code;
+// NANP territories other than Canada resolve to US.
+var regionMetadata = []regionMeta{
+	{region: "CA", callingCode: "1", lengths: []int{10}, leadingDigits: []string{
+		"204", "226", "236", "249", "250", "263", "289", "306", "343", "354", "365", "367", "368",
+		"382", "403", "416", "418", "428", "431", "437", "438", "450", "468", "474", "506", "514",
+		"519", "548", "579", "581", "584", "587", "604", "613", "639", "647", "672", "683", "705",
+		"709", "742", "753", "778", "780", "782", "807", "819", "825", "867", "873", "879", "902", "905",
+	}},
+	{region: "US", callingCode: "1", lengths: []int{10}},
+	{region: "KZ", callingCode: "7", lengths: []int{10}, leadingDigits: []string{"6", "7"}, mobilePrefixes: []string{"7"}},
+	{region: "RU", callingCode: "7", lengths: []int{10}, mobilePrefixes: []string{"9"}},
+	{region: "ZA", callingCode: "27", lengths: []int{9}, mobilePrefixes: []string{"6", "7", "8"}},
+	{region: "NL", callingCode: "31", lengths: []int{9}, mobilePrefixes: []string{"6"}},
+	{region: "FR", callingCode: "33", lengths: []int{9}, mobilePrefixes: []string{"6", "7"}},
+	{region: "ES", callingCode: "34", lengths: []int{9}, mobilePrefixes: []string{"6", "7"}},
+	{region: "IT", callingCode: "39", lengths: []int{6, 7, 8, 9, 10, 11}, mobilePrefixes: []string{"3"}},
+	{region: "GB", callingCode: "44", lengths: []int{9, 10}, mobilePrefixes: []string{"7"}},
+	{region: "DE", callingCode: "49", lengths: []int{6, 7, 8, 9, 10, 11, 12, 13}, mobilePrefixes: []string{"15", "16", "17"}},
+	{region: "MX", callingCode: "52", lengths: []int{10}},
+	{region: "AR", callingCode: "54", lengths: []int{10, 11}, mobilePrefixes: []string{"9"}},
+	{region: "BR", callingCode: "55", lengths: []int{10, 11}},
+	{region: "CO", callingCode: "57", lengths: []int{10}, mobilePrefixes: []string{"3"}},
+	{region: "AU", callingCode: "61", lengths: []int{9}, mobilePrefixes: []string{"4"}},
+	{region: "JP", callingCode: "81", lengths: []int{9, 10}, mobilePrefixes: []string{"70", "80", "90"}},
+	{region: "KR", callingCode: "82", lengths: []int{8, 9, 10}, mobilePrefixes: []string{"1"}},
+	{region: "CN", callingCode: "86", lengths: []int{10, 11}, mobilePrefixes: []string{"1"}},
+	{region: "IN", callingCode: "91", lengths: []int{10}, mobilePrefixes: []string{"6", "7", "8", "9"}},
+	{region: "NG", callingCode: "234", lengths: []int{8, 10}, mobilePrefixes: []string{"70", "80", "81", "90", "91"}},
+}
+
+// lookupRegion returns the metadata matching a calling code and national
+// number, or nil when the calling code has no metadata.
+func lookupRegion(callingCode, national string) *regionMeta {
+	for i := range regionMetadata {
+		m := &regionMetadata[i]
+		if m.callingCode != callingCode {
+			continue
+		}
+		if m.leadingDigits == nil {
+			return m
+		}
+		for _, prefix := range m.leadingDigits {
+			if strings.HasPrefix(national, prefix) {
+				return m
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PhonePolicy restricts which phone numbers may register or log in, by region
+// and line type. A nil *PhonePolicy permits every valid number. PhonePolicy
+// is immutable; swap the whole value to change it at runtime.
+type PhonePolicy struct {
+	allow          map[string]struct{}
+	deny           map[string]struct{}
+	allowFixedLine bool
+}
+
+// PhonePolicyConfig holds the inputs for NewPhonePolicy. Region codes are ISO
+// 3166-1 alpha-2 and matched case-insensitively.
+type PhonePolicyConfig struct {
+	// Allow, when non-empty, admits only numbers from these regions. Numbers
+	// whose region cannot be determined are rejected in allow-list mode.
+	Allow []string
+	// Deny rejects numbers from these regions. Deny wins over Allow.
+	Deny []string
+	// AllowFixedLine admits numbers classified as fixed-line. These cannot
+	// receive SMS, so OTP delivery to them always fails.
+	AllowFixedLine bool
+}
+
+// NewPhonePolicy builds a PhonePolicy from region lists.
+func NewPhonePolicy(cfg PhonePolicyConfig) *PhonePolicy {
+	return &PhonePolicy{
+		allow:          regionSet(cfg.Allow),
+		deny:           regionSet(cfg.Deny),
+		allowFixedLine: cfg.AllowFixedLine,
+	}
+}
+
+// regionSet returns nil for lists with no non-blank entries so that an empty
+// CHATMGMT_PHONE_ALLOW does not switch the policy into allow-list mode.
+func regionSet(regions []string) map[string]struct{} {
+	var set map[string]struct{}
+	for _, r := range regions {
+		if r = strings.ToUpper(strings.TrimSpace(r)); r != "" {
+			if set == nil {
+				set = make(map[string]struct{}, len(regions))
+			}
+			set[r] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Check returns ErrInvalidPhoneNumber if the policy rejects p.
+func (pp *PhonePolicy) Check(p PhoneNumber) error {
+	if pp == nil {
+		return nil
+	}
+	if _, denied := pp.deny[p.region]; denied && p.region != "" {
+		return fmt.Errorf("phone region %s is not permitted: %w", p.region, ErrInvalidPhoneNumber)
+	}
+	if pp.allow != nil {
+		if _, allowed := pp.allow[p.region]; !allowed {
+			return fmt.Errorf("phone region %q is not permitted: %w", p.region, ErrInvalidPhoneNumber)
+		}
+	}
+	if !pp.allowFixedLine && p.lineType == LineTypeFixedLine {
+		return fmt.Errorf("fixed-line numbers cannot receive SMS: %w", ErrInvalidPhoneNumber)
+	}
+	return nil
+}
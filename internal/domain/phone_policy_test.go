@@ -0,0 +1,55 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhonePolicy_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     domain.PhonePolicyConfig
+		phone   string
+		wantErr bool
+	}{
+		{name: "empty policy allows mobile", cfg: domain.PhonePolicyConfig{}, phone: "+447911123456"},
+		{name: "empty policy allows unknown line type", cfg: domain.PhonePolicyConfig{}, phone: "+14155552671"},
+		{name: "empty policy rejects fixed-line", cfg: domain.PhonePolicyConfig{}, phone: "+442071838750", wantErr: true},
+		{name: "fixed-line allowed when configured", cfg: domain.PhonePolicyConfig{AllowFixedLine: true}, phone: "+442071838750"},
+		{name: "blank allow list allows all", cfg: domain.PhonePolicyConfig{Allow: []string{""}}, phone: "+447911123456"},
+		{name: "allow list admits listed region", cfg: domain.PhonePolicyConfig{Allow: []string{"US", "GB"}}, phone: "+447911123456"},
+		{name: "allow list is case-insensitive", cfg: domain.PhonePolicyConfig{Allow: []string{" gb "}}, phone: "+447911123456"},
+		{name: "allow list rejects other region", cfg: domain.PhonePolicyConfig{Allow: []string{"US"}}, phone: "+447911123456", wantErr: true},
+		{name: "allow list rejects unknown region", cfg: domain.PhonePolicyConfig{Allow: []string{"US"}}, phone: "+35312345678", wantErr: true},
+		{name: "deny list rejects listed region", cfg: domain.PhonePolicyConfig{Deny: []string{"RU"}}, phone: "+79123456789", wantErr: true},
+		{name: "deny list admits other region", cfg: domain.PhonePolicyConfig{Deny: []string{"RU"}}, phone: "+77012345678"},
+		{name: "deny list admits unknown region", cfg: domain.PhonePolicyConfig{Deny: []string{"RU"}}, phone: "+35312345678"},
+		{name: "deny wins over allow", cfg: domain.PhonePolicyConfig{Allow: []string{"US"}, Deny: []string{"US"}}, phone: "+14155552671", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := domain.NewPhonePolicy(tt.cfg)
+
+			err := policy.Check(domain.MustPhoneNumber(tt.phone))
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPhonePolicy_NilAllowsAll(t *testing.T) {
+	var policy *domain.PhonePolicy
+
+	err := policy.Check(domain.MustPhoneNumber("+442071838750"))
+
+	require.NoError(t, err)
+}
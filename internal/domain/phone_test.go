@@ -14,8 +14,8 @@ func TestPhoneNumber(t *testing.T) {
 			"+14155552671",     // US
 			"+447911123456",    // UK
 			"+8613800138000",   // China
-			"+1234567",         // Minimum 7 digits
-			"+123456789012345", // Maximum 15 digits
+			"+2901234",         // Minimum 7 digits (Saint Helena, no length metadata)
+			"+882123456789012", // Maximum 15 digits (international networks)
 		}
 		for _, raw := range valid {
 			p, err := domain.NewPhoneNumber(raw)
@@ -61,8 +61,20 @@ func TestPhoneNumber(t *testing.T) {
 		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
 	})
 
-	t.Run("contains spaces", func(t *testing.T) {
-		_, err := domain.NewPhoneNumber("+1 415 555 2671")
+	t.Run("unassigned country code", func(t *testing.T) {
+		_, err := domain.NewPhoneNumber("+2101234567")
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
+	})
+
+	t.Run("invalid length for region", func(t *testing.T) {
+		_, err := domain.NewPhoneNumber("+1415555267") // NANP requires 10 national digits
+		require.Error(t, err)
+		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
+	})
+
+	t.Run("national format without prefix", func(t *testing.T) {
+		_, err := domain.NewPhoneNumber("(415) 555-2671")
 		require.Error(t, err)
 		assert.ErrorIs(t, err, domain.ErrInvalidPhoneNumber)
 	})
@@ -86,3 +98,60 @@ func TestPhoneNumber(t *testing.T) {
 		})
 	})
 }
+
+func TestPhoneNumber_Normalization(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "spaces", raw: "+1 415 555 2671", want: "+14155552671"},
+		{name: "dashes and parentheses", raw: "+1 (415) 555-2671", want: "+14155552671"},
+		{name: "dots", raw: "+44.7911.123.456", want: "+447911123456"},
+		{name: "double-zero international prefix", raw: "0044 7911 123456", want: "+447911123456"},
+		{name: "surrounding whitespace", raw: "  +14155552671\n", want: "+14155552671"},
+		{name: "non-breaking space", raw: "+33\u00A0612345678", want: "+33612345678"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := domain.NewPhoneNumber(tt.raw)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.String())
+		})
+	}
+}
+
+func TestPhoneNumber_CountryMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		callingCode string
+		region      string
+		lineType    domain.LineType
+	}{
+		{name: "US", raw: "+14155552671", callingCode: "1", region: "US", lineType: domain.LineTypeUnknown},
+		{name: "CA by area code", raw: "+14165550123", callingCode: "1", region: "CA", lineType: domain.LineTypeUnknown},
+		{name: "GB mobile", raw: "+447911123456", callingCode: "44", region: "GB", lineType: domain.LineTypeMobile},
+		{name: "GB fixed-line", raw: "+442071838750", callingCode: "44", region: "GB", lineType: domain.LineTypeFixedLine},
+		{name: "FR mobile", raw: "+33612345678", callingCode: "33", region: "FR", lineType: domain.LineTypeMobile},
+		{name: "DE mobile", raw: "+4915123456789", callingCode: "49", region: "DE", lineType: domain.LineTypeMobile},
+		{name: "RU mobile", raw: "+79123456789", callingCode: "7", region: "RU", lineType: domain.LineTypeMobile},
+		{name: "KZ by leading digit", raw: "+77012345678", callingCode: "7", region: "KZ", lineType: domain.LineTypeMobile},
+		{name: "IN mobile", raw: "+919876543210", callingCode: "91", region: "IN", lineType: domain.LineTypeMobile},
+		{name: "NG mobile", raw: "+2348031234567", callingCode: "234", region: "NG", lineType: domain.LineTypeMobile},
+		{name: "assigned code without metadata", raw: "+35312345678", callingCode: "353", region: "", lineType: domain.LineTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := domain.NewPhoneNumber(tt.raw)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.callingCode, p.CallingCode())
+			assert.Equal(t, tt.region, p.Region())
+			assert.Equal(t, tt.lineType, p.LineType())
+		})
+	}
+}
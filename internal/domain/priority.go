@@ -0,0 +1,115 @@
+package domain
+
+// DeliveryPriority classifies server-to-client events for delivery queues.
+// Under contention each scheduling round gives higher priorities a larger
+// share, so chat messages are never starved by high-volume ephemeral events
+// while those events still make progress (ADR-009 §2 Backpressure).
+type DeliveryPriority int
+
+const (
+	// PriorityMessage covers chat messages and connection control frames.
+	PriorityMessage DeliveryPriority = iota
+	// PriorityReceipt covers delivery and read receipts.
+	PriorityReceipt
+	// PriorityEphemeral covers typing and presence. Best effort: when its
+	// lane is full the oldest event is dropped.
+	PriorityEphemeral
+
+	numPriorities
+)
+
+// deliveryWeights is how many items each priority may take per round.
+var deliveryWeights = [numPriorities]int{
+	PriorityMessage:   8,
+	PriorityReceipt:   3,
+	PriorityEphemeral: 1,
+}
+
+// String returns the priority name used in metrics and logs.
+func (p DeliveryPriority) String() string {
+	switch p {
+	case PriorityMessage:
+		return "message"
+	case PriorityReceipt:
+		return "receipt"
+	case PriorityEphemeral:
+		return "ephemeral"
+	default:
+		return "unknown"
+	}
+}
+
+// PriorityQueue is a bounded FIFO per DeliveryPriority lane, drained by
+// weighted round-robin. Not safe for concurrent use; callers hold their own
+// lock.
+type PriorityQueue[T any] struct {
+	lanes    [numPriorities][]T
+	credits  [numPriorities]int
+	capacity int
+}
+
+// NewPriorityQueue creates a PriorityQueue holding up to capacity items per lane.
+func NewPriorityQueue[T any](capacity int) *PriorityQueue[T] {
+	q := &PriorityQueue[T]{capacity: capacity}
+	q.credits = deliveryWeights
+	return q
+}
+
+// Push appends v to lane p. It returns false when the lane is full; the
+// ephemeral lane instead drops its oldest item to make room.
+func (q *PriorityQueue[T]) Push(p DeliveryPriority, v T) bool {
+	if p < 0 || p >= numPriorities {
+		p = PriorityMessage
+	}
+	if len(q.lanes[p]) >= q.capacity {
+		if p != PriorityEphemeral || q.capacity == 0 {
+			return false
+		}
+		q.popLane(p)
+	}
+	q.lanes[p] = append(q.lanes[p], v)
+	return true
+}
+
+// Pop removes the next item by weighted round-robin. It reports false when
+// every lane is empty.
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	for range 2 {
+		for p := range numPriorities {
+			if q.credits[p] > 0 && len(q.lanes[p]) > 0 {
+				q.credits[p]--
+				return q.popLane(p), true
+			}
+		}
+		// Round exhausted, or only lanes without credit have items.
+		q.credits = deliveryWeights
+	}
+	var zero T
+	return zero, false
+}
+
+// Len returns the total number of queued items.
+func (q *PriorityQueue[T]) Len() int {
+	n := 0
+	for p := range numPriorities {
+		n += len(q.lanes[p])
+	}
+	return n
+}
+
+// LaneLen returns the number of items queued at priority p.
+func (q *PriorityQueue[T]) LaneLen(p DeliveryPriority) int {
+	if p < 0 || p >= numPriorities {
+		return 0
+	}
+	return len(q.lanes[p])
+}
+
+func (q *PriorityQueue[T]) popLane(p DeliveryPriority) T {
+	lane := q.lanes[p]
+	v := lane[0]
+	var zero T
+	lane[0] = zero // release the reference for GC
+	q.lanes[p] = lane[1:]
+	return v
+}
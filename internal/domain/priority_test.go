@@ -0,0 +1,111 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type item struct {
+	p domain.DeliveryPriority
+	n int
+}
+
+func drain(q *domain.PriorityQueue[item]) []item {
+	var out []item
+	for {
+		v, ok := q.Pop()
+		if !ok {
+			return out
+		}
+		out = append(out, v)
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	t.Run("empty queue pops nothing", func(t *testing.T) {
+		q := domain.NewPriorityQueue[item](4)
+		_, ok := q.Pop()
+		assert.False(t, ok)
+	})
+
+	t.Run("FIFO within a lane", func(t *testing.T) {
+		q := domain.NewPriorityQueue[item](4)
+		for n := range 3 {
+			require.True(t, q.Push(domain.PriorityReceipt, item{domain.PriorityReceipt, n}))
+		}
+		got := drain(q)
+		require.Len(t, got, 3)
+		for n, v := range got {
+			assert.Equal(t, n, v.n)
+		}
+	})
+
+	t.Run("weighted rounds: 8 messages, 3 receipts, 1 ephemeral", func(t *testing.T) {
+		q := domain.NewPriorityQueue[item](100)
+		for n := range 20 {
+			for _, p := range []domain.DeliveryPriority{domain.PriorityMessage, domain.PriorityReceipt, domain.PriorityEphemeral} {
+				require.True(t, q.Push(p, item{p, n}))
+			}
+		}
+
+		var round [3]int
+		for range 12 {
+			v, ok := q.Pop()
+			require.True(t, ok)
+			round[v.p]++
+		}
+		assert.Equal(t, [3]int{8, 3, 1}, round)
+	})
+
+	t.Run("messages are never starved by ephemeral floods", func(t *testing.T) {
+		q := domain.NewPriorityQueue[item](1000)
+		for n := range 1000 {
+			q.Push(domain.PriorityEphemeral, item{domain.PriorityEphemeral, n})
+		}
+		q.Push(domain.PriorityMessage, item{domain.PriorityMessage, 0})
+
+		v, ok := q.Pop()
+		require.True(t, ok)
+		assert.Equal(t, domain.PriorityMessage, v.p)
+	})
+
+	t.Run("lower lanes drain when higher lanes are empty", func(t *testing.T) {
+		q := domain.NewPriorityQueue[item](100)
+		for n := range 5 {
+			q.Push(domain.PriorityEphemeral, item{domain.PriorityEphemeral, n})
+		}
+		assert.Len(t, drain(q), 5)
+	})
+
+	t.Run("full lane rejects; ephemeral lane drops oldest", func(t *testing.T) {
+		q := domain.NewPriorityQueue[item](2)
+		require.True(t, q.Push(domain.PriorityMessage, item{domain.PriorityMessage, 0}))
+		require.True(t, q.Push(domain.PriorityMessage, item{domain.PriorityMessage, 1}))
+		assert.False(t, q.Push(domain.PriorityMessage, item{domain.PriorityMessage, 2}))
+
+		for n := range 3 {
+			require.True(t, q.Push(domain.PriorityEphemeral, item{domain.PriorityEphemeral, n}))
+		}
+		assert.Equal(t, 2, q.LaneLen(domain.PriorityEphemeral))
+		assert.Equal(t, 4, q.Len())
+
+		got := drain(q)
+		assert.Equal(t, []item{
+			{domain.PriorityMessage, 0},
+			{domain.PriorityMessage, 1},
+			{domain.PriorityEphemeral, 1},
+			{domain.PriorityEphemeral, 2},
+		}, got)
+	})
+}
+
+func TestDeliveryPriority_String(t *testing.T) {
+	assert.Equal(t, "message", domain.PriorityMessage.String())
+	assert.Equal(t, "receipt", domain.PriorityReceipt.String())
+	assert.Equal(t, "ephemeral", domain.PriorityEphemeral.String())
+	assert.Equal(t, "unknown", domain.DeliveryPriority(42).String())
+}
@@ -0,0 +1,68 @@
+package domain
+
+// PrivacySignal is a kind of activity signal a user can stop sharing.
+type PrivacySignal int
+
+const (
+	// SignalReadReceipt is a read receipt sent to a message's author.
+	SignalReadReceipt PrivacySignal = iota
+	// SignalTyping is a typing indicator.
+	SignalTyping
+	// SignalLastSeen is presence: online status and last-seen time.
+	SignalLastSeen
+)
+
+// String returns the signal name used in metrics and logs.
+func (s PrivacySignal) String() string {
+	switch s {
+	case SignalReadReceipt:
+		return "read_receipt"
+	case SignalTyping:
+		return "typing"
+	case SignalLastSeen:
+		return "last_seen"
+	default:
+		return "unknown"
+	}
+}
+
+// PrivacySettings are a user's activity-sharing preferences. The zero value
+// shares everything.
+type PrivacySettings struct {
+	HideReadReceipts bool
+	HideTyping       bool
+	HideLastSeen     bool
+
+	// Version is the user's settings version when read, bumped by every
+	// change to their privacy, do-not-disturb or SMS fallback settings. It
+	// tags GET responses for conditional requests.
+	Version int64
+}
+
+// Hides reports whether s opts out of sharing sig.
+func (s PrivacySettings) Hides(sig PrivacySignal) bool {
+	switch sig {
+	case SignalReadReceipt:
+		return s.HideReadReceipts
+	case SignalTyping:
+		return s.HideTyping
+	case SignalLastSeen:
+		return s.HideLastSeen
+	default:
+		return false
+	}
+}
+
+// PrivacyAllows reports whether a signal about subject may be delivered to
+// viewer. Read receipts and last-seen are reciprocal: a user who hides
+// them does not see anyone else's either. Typing is not: hiding your own
+// typing still lets you see others type.
+func PrivacyAllows(sig PrivacySignal, subject, viewer PrivacySettings) bool {
+	if subject.Hides(sig) {
+		return false
+	}
+	if sig == SignalTyping {
+		return true
+	}
+	return !viewer.Hides(sig)
+}
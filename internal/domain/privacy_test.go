@@ -0,0 +1,45 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestPrivacyAllows(t *testing.T) {
+	var open domain.PrivacySettings
+	hideAll := domain.PrivacySettings{HideReadReceipts: true, HideTyping: true, HideLastSeen: true}
+
+	tests := []struct {
+		name    string
+		sig     domain.PrivacySignal
+		subject domain.PrivacySettings
+		viewer  domain.PrivacySettings
+		want    bool
+	}{
+		{name: "receipt shared", sig: domain.SignalReadReceipt, subject: open, viewer: open, want: true},
+		{name: "receipt hidden by reader", sig: domain.SignalReadReceipt, subject: hideAll, viewer: open, want: false},
+		{name: "receipt reciprocal", sig: domain.SignalReadReceipt, subject: open, viewer: hideAll, want: false},
+		{name: "last seen shared", sig: domain.SignalLastSeen, subject: open, viewer: open, want: true},
+		{name: "last seen hidden", sig: domain.SignalLastSeen, subject: domain.PrivacySettings{HideLastSeen: true}, viewer: open, want: false},
+		{name: "last seen reciprocal", sig: domain.SignalLastSeen, subject: open, viewer: domain.PrivacySettings{HideLastSeen: true}, want: false},
+		{name: "typing hidden", sig: domain.SignalTyping, subject: domain.PrivacySettings{HideTyping: true}, viewer: open, want: false},
+		{name: "typing not reciprocal", sig: domain.SignalTyping, subject: open, viewer: hideAll, want: true},
+		{name: "settings are per signal", sig: domain.SignalLastSeen, subject: domain.PrivacySettings{HideReadReceipts: true}, viewer: open, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, domain.PrivacyAllows(tt.sig, tt.subject, tt.viewer))
+		})
+	}
+}
+
+func TestPrivacySignal_String(t *testing.T) {
+	assert.Equal(t, "read_receipt", domain.SignalReadReceipt.String())
+	assert.Equal(t, "typing", domain.SignalTyping.String())
+	assert.Equal(t, "last_seen", domain.SignalLastSeen.String())
+	assert.Equal(t, "unknown", domain.PrivacySignal(99).String())
+}
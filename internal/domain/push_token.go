@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PushPlatform is the provider that delivers a device's push notifications.
+type PushPlatform string
+
+// Push platforms.
+const (
+	PushPlatformAPNs    PushPlatform = "apns"
+	PushPlatformFCM     PushPlatform = "fcm"
+	PushPlatformWebPush PushPlatform = "webpush"
+)
+
+// Valid reports whether p is a known platform.
+func (p PushPlatform) Valid() bool {
+	switch p {
+	case PushPlatformAPNs, PushPlatformFCM, PushPlatformWebPush:
+		return true
+	}
+	return false
+}
+
+// MaxPushTokenLength bounds a device token. APNs tokens are 64 hex
+// characters and FCM registration tokens are around 160; the bound leaves
+// room for format changes without accepting arbitrary blobs.
+const MaxPushTokenLength = 4096
+
+// DeviceToken is the provider token a device registered for push
+// notifications. A device holds at most one token; registering again
+// replaces it.
+//
+// For WebPush the token is the subscription's push service endpoint, and
+// P256DH and Auth carry the browser's keys for payload encryption
+// (RFC 8291), unpadded base64url as PushSubscription.toJSON returns them.
+type DeviceToken struct {
+	UserID    string
+	DeviceID  string
+	Platform  PushPlatform
+	Token     string
+	P256DH    string // WebPush only
+	Auth      string // WebPush only
+	UpdatedAt time.Time
+}
+
+// Validate checks the token's platform and value.
+func (t DeviceToken) Validate() error {
+	if t.DeviceID == "" {
+		return NewValidationError("device_id", "is required")
+	}
+	if !t.Platform.Valid() {
+		return NewValidationError("platform", fmt.Sprintf("unknown push platform %q", t.Platform))
+	}
+	if t.Token == "" || len(t.Token) > MaxPushTokenLength {
+		return NewValidationError("token", fmt.Sprintf("must be 1-%d bytes", MaxPushTokenLength))
+	}
+	if t.Platform == PushPlatformWebPush {
+		return t.validateWebPush()
+	}
+	return nil
+}
+
+// validateWebPush checks a WebPush subscription: an https endpoint, an
+// uncompressed P-256 public key and a 16-byte auth secret.
+func (t DeviceToken) validateWebPush() error {
+	if u, err := url.Parse(t.Token); err != nil || u.Scheme != "https" || u.Host == "" {
+		return NewValidationError("token", "must be an https push endpoint")
+	}
+	if key, err := base64.RawURLEncoding.DecodeString(t.P256DH); err != nil || len(key) != 65 || key[0] != 0x04 {
+		return NewValidationError("p256dh", "must be an uncompressed P-256 public key")
+	}
+	if auth, err := base64.RawURLEncoding.DecodeString(t.Auth); err != nil || len(auth) != 16 {
+		return NewValidationError("auth", "must be a 16-byte secret")
+	}
+	return nil
+}
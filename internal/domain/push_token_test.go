@@ -0,0 +1,59 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestDeviceToken_Validate(t *testing.T) {
+	valid := domain.DeviceToken{UserID: "user-1", DeviceID: "device-1", Platform: domain.PushPlatformFCM, Token: "tok"}
+	webPush := func(d *domain.DeviceToken) {
+		d.Platform = domain.PushPlatformWebPush
+		d.Token = "https://push.example.net/send/abc"
+		d.P256DH = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+		d.Auth = "BTBZMqHH6r4Tts7J_aSIgg"
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*domain.DeviceToken)
+		wantErr bool
+	}{
+		{name: "valid", mutate: func(*domain.DeviceToken) {}},
+		{name: "apns", mutate: func(d *domain.DeviceToken) { d.Platform = domain.PushPlatformAPNs }},
+		{name: "missing device", mutate: func(d *domain.DeviceToken) { d.DeviceID = "" }, wantErr: true},
+		{name: "unknown platform", mutate: func(d *domain.DeviceToken) { d.Platform = "mpns" }, wantErr: true},
+		{name: "empty token", mutate: func(d *domain.DeviceToken) { d.Token = "" }, wantErr: true},
+		{
+			name:    "oversized token",
+			mutate:  func(d *domain.DeviceToken) { d.Token = strings.Repeat("a", domain.MaxPushTokenLength+1) },
+			wantErr: true,
+		},
+		{name: "webpush", mutate: webPush},
+		{
+			name:    "webpush over http",
+			mutate:  func(d *domain.DeviceToken) { webPush(d); d.Token = "http://push.example.net/send/abc" },
+			wantErr: true,
+		},
+		{name: "webpush without keys", mutate: func(d *domain.DeviceToken) { webPush(d); d.P256DH = "" }, wantErr: true},
+		{name: "webpush short auth", mutate: func(d *domain.DeviceToken) { webPush(d); d.Auth = "AAAA" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok := valid
+			tt.mutate(&tok)
+
+			err := tok.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DataRegion is the jurisdiction a workspace's data is stored and
+// processed in. Each region runs its own deployment with its own DynamoDB
+// tables and Kafka topics; a deployment serves only its region's
+// workspaces.
+type DataRegion string
+
+// Supported data regions.
+const (
+	RegionEU DataRegion = "eu"
+	RegionUS DataRegion = "us"
+)
+
+// ParseDataRegion parses a region name, ignoring case.
+func ParseDataRegion(s string) (DataRegion, error) {
+	switch r := DataRegion(strings.ToLower(strings.TrimSpace(s))); r {
+	case RegionEU, RegionUS:
+		return r, nil
+	}
+	return "", NewValidationError("data_region", fmt.Sprintf("must be %s or %s", RegionEU, RegionUS))
+}
+
+// Topic returns the region's copy of a Kafka topic, e.g. eu.analytics.events.
+func (r DataRegion) Topic(name string) string {
+	return string(r) + "." + name
+}
+
+// HostsAWSRegion reports whether AWS region awsRegion (e.g. eu-west-1) lies
+// within r, so resources created there keep r's data in r.
+func (r DataRegion) HostsAWSRegion(awsRegion string) bool {
+	return r != "" && strings.HasPrefix(awsRegion, string(r)+"-")
+}
+
+// ResidencyError rejects access to a workspace's data from a deployment
+// serving another region. It matches ErrForbidden.
+type ResidencyError struct {
+	WorkspaceID string
+	Region      DataRegion // where the workspace's data lives
+	Serving     DataRegion // the region of the rejecting deployment
+}
+
+// Error implements error.
+func (e *ResidencyError) Error() string {
+	return fmt.Sprintf("workspace %s keeps its data in region %s; this deployment serves region %s, use the %s endpoint",
+		e.WorkspaceID, e.Region, e.Serving, e.Region)
+}
+
+// Unwrap returns ErrForbidden.
+func (e *ResidencyError) Unwrap() error { return ErrForbidden }
+
+// IsResidencyError reports whether err's chain holds a ResidencyError.
+func IsResidencyError(err error) bool {
+	var re *ResidencyError
+	return errors.As(err, &re)
+}
+
+// CheckResidency returns a ResidencyError unless a deployment serving
+// region serving may access the data of a workspace in region. An empty
+// serving region disables residency; a workspace without a region, saved
+// before it had one, is served anywhere.
+func CheckResidency(workspaceID string, region, serving DataRegion) error {
+	if serving == "" || region == "" || region == serving {
+		return nil
+	}
+	return &ResidencyError{WorkspaceID: workspaceID, Region: region, Serving: serving}
+}
@@ -0,0 +1,44 @@
+package domain_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestParseDataRegion(t *testing.T) {
+	r, err := domain.ParseDataRegion(" EU ")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RegionEU, r)
+
+	for _, s := range []string{"", "eu-west-1", "apac"} {
+		_, err := domain.ParseDataRegion(s)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput, s)
+	}
+}
+
+func TestDataRegion_Resources(t *testing.T) {
+	assert.Equal(t, "eu.analytics.events", domain.RegionEU.Topic("analytics.events"))
+
+	assert.True(t, domain.RegionEU.HostsAWSRegion("eu-central-1"))
+	assert.False(t, domain.RegionEU.HostsAWSRegion("us-east-1"))
+	assert.True(t, domain.RegionUS.HostsAWSRegion("us-west-2"))
+	assert.False(t, domain.RegionUS.HostsAWSRegion("useast"))
+	assert.False(t, domain.DataRegion("").HostsAWSRegion("us-east-1"))
+}
+
+func TestCheckResidency(t *testing.T) {
+	assert.NoError(t, domain.CheckResidency("ws-1", domain.RegionEU, domain.RegionEU))
+	assert.NoError(t, domain.CheckResidency("ws-1", domain.RegionEU, ""), "residency disabled")
+	assert.NoError(t, domain.CheckResidency("ws-1", "", domain.RegionUS), "workspace saved before residency")
+
+	err := fmt.Errorf("sso login: %w", domain.CheckResidency("ws-1", domain.RegionEU, domain.RegionUS))
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	assert.True(t, domain.IsResidencyError(err))
+	assert.False(t, domain.IsResidencyError(domain.ErrForbidden))
+	assert.Contains(t, err.Error(), "workspace ws-1 keeps its data in region eu; this deployment serves region us")
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SlowModeError rejects a message sent before the chat's slow mode
+// interval has passed since the sender's previous message. It matches
+// ErrSlowMode.
+type SlowModeError struct {
+	Remaining time.Duration // wait until the sender may post again
+}
+
+// Error implements error.
+func (e *SlowModeError) Error() string {
+	return fmt.Sprintf("%s (%ds remaining)", ErrSlowMode, e.SecondsRemaining())
+}
+
+// Unwrap returns ErrSlowMode.
+func (e *SlowModeError) Unwrap() error { return ErrSlowMode }
+
+// SecondsRemaining is Remaining rounded up to whole seconds, so a client
+// that waits that long is never rejected again.
+func (e *SlowModeError) SecondsRemaining() int64 {
+	return int64((e.Remaining + time.Second - 1) / time.Second)
+}
+
+// SlowModeRemaining returns the wait carried by a SlowModeError in err's
+// chain.
+func SlowModeRemaining(err error) (time.Duration, bool) {
+	var sm *SlowModeError
+	if errors.As(err, &sm) {
+		return sm.Remaining, true
+	}
+	return 0, false
+}
+
+// SlowModeApplies reports whether a member with role is subject to slow
+// mode of the given interval. Admins and the owner are exempt.
+func SlowModeApplies(seconds int, role MemberRole) bool {
+	return seconds > 0 && !role.AtLeast(MemberRoleAdmin)
+}
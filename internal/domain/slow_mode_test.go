@@ -0,0 +1,33 @@
+package domain_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestSlowModeError(t *testing.T) {
+	err := fmt.Errorf("send: %w", &domain.SlowModeError{Remaining: 2100 * time.Millisecond})
+
+	assert.ErrorIs(t, err, domain.ErrSlowMode)
+	assert.True(t, domain.IsRetryable(err))
+	assert.Contains(t, err.Error(), "3s remaining")
+
+	remaining, ok := domain.SlowModeRemaining(err)
+	assert.True(t, ok)
+	assert.Equal(t, 2100*time.Millisecond, remaining)
+
+	_, ok = domain.SlowModeRemaining(domain.ErrSlowMode)
+	assert.False(t, ok)
+}
+
+func TestSlowModeApplies(t *testing.T) {
+	assert.True(t, domain.SlowModeApplies(30, domain.MemberRoleMember))
+	assert.False(t, domain.SlowModeApplies(30, domain.MemberRoleAdmin))
+	assert.False(t, domain.SlowModeApplies(30, domain.MemberRoleOwner))
+	assert.False(t, domain.SlowModeApplies(0, domain.MemberRoleMember))
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SMSFallback is a user's setting for receiving messages as SMS while
+// offline, for feature phones that cannot keep a connection or take push
+// notifications.
+type SMSFallback struct {
+	Enabled bool
+	// OfflineAfter is how long the user must have been inactive before
+	// messages go out by SMS. Zero means DefaultSMSFallbackOfflineAfter.
+	OfflineAfter time.Duration
+	// Version is the user's settings version when read (see
+	// PrivacySettings.Version).
+	Version int64
+}
+
+// Validate checks that OfflineAfter is zero or within the allowed range.
+func (s SMSFallback) Validate() error {
+	if s.OfflineAfter == 0 {
+		return nil
+	}
+	if s.OfflineAfter < MinSMSFallbackOfflineAfter || s.OfflineAfter > MaxSMSFallbackOfflineAfter {
+		return NewValidationError("offline_after", fmt.Sprintf("must be between %s and %s",
+			MinSMSFallbackOfflineAfter, MaxSMSFallbackOfflineAfter))
+	}
+	return nil
+}
+
+// Threshold returns OfflineAfter, or the default when it is unset.
+func (s SMSFallback) Threshold() time.Duration {
+	if s.OfflineAfter == 0 {
+		return DefaultSMSFallbackOfflineAfter
+	}
+	return s.OfflineAfter
+}
+
+// Due reports whether a message at now should go out by SMS to a user last
+// active at lastActive. A user with no recorded activity counts as offline.
+func (s SMSFallback) Due(lastActive, now time.Time) bool {
+	return s.Enabled && now.Sub(lastActive) >= s.Threshold()
+}
+
+// SMS segment capacities. A message that fits one segment has the full
+// payload; longer messages are sent as concatenated segments, each losing
+// room to the concatenation header.
+const (
+	gsmSingleSegment  = 160
+	gsmMultiSegment   = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// gsmBasic and gsmExtension are the GSM 03.38 default alphabet and its
+// extension table. Extension characters take two septets.
+const (
+	gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtension = "^{}\\[~]|€\f"
+)
+
+// smsUnits returns the length of s in its SMS encoding: septets if s fits
+// the GSM alphabet, else UTF-16 code units.
+func smsUnits(s string) (units int, gsm bool) {
+	septets, utf16 := 0, 0
+	gsm = true
+	for _, r := range s {
+		switch {
+		case !gsm:
+		case strings.ContainsRune(gsmBasic, r):
+			septets++
+		case strings.ContainsRune(gsmExtension, r):
+			septets += 2
+		default:
+			gsm = false
+		}
+		utf16++
+		if r > 0xFFFF {
+			utf16++ // surrogate pair
+		}
+	}
+	if gsm {
+		return septets, true
+	}
+	return utf16, false
+}
+
+// smsCapacity returns how many units segments hold.
+func smsCapacity(segments int, gsm bool) int {
+	switch {
+	case gsm && segments == 1:
+		return gsmSingleSegment
+	case gsm:
+		return gsmMultiSegment * segments
+	case segments == 1:
+		return ucs2SingleSegment
+	default:
+		return ucs2MultiSegment * segments
+	}
+}
+
+// SMSSegments returns how many SMS segments s is sent as. Carriers bill
+// per segment.
+func SMSSegments(s string) int {
+	units, gsm := smsUnits(s)
+	if units <= smsCapacity(1, gsm) {
+		return 1
+	}
+	per := smsCapacity(2, gsm) / 2
+	return (units + per - 1) / per
+}
+
+// FormatSMS renders a chat message as an SMS of at most maxSegments
+// segments:
+//
+//   - Whitespace runs, newlines included, become one space, and control
+//     characters are dropped.
+//   - The body is "sender: text", with the sender cut to
+//     MaxSMSSenderNameLength characters.
+//   - Text that does not fit is cut at a word boundary when one is close,
+//     and marked with "..." (or "…" in messages that need Unicode).
+//
+// The encoding is decided by the whole message, so one emoji costs the
+// message more than half its room.
+func FormatSMS(sender, text string, maxSegments int) string {
+	maxSegments = max(maxSegments, 1)
+	sender = cleanSMSText(sender)
+	if utf8.RuneCountInString(sender) > MaxSMSSenderNameLength {
+		sender = string([]rune(sender)[:MaxSMSSenderNameLength])
+	}
+	body := sender + ": " + cleanSMSText(text)
+
+	units, gsm := smsUnits(body)
+	limit := smsCapacity(maxSegments, gsm)
+	if units <= limit {
+		return body
+	}
+
+	marker, markerUnits := "...", 3
+	if !gsm {
+		marker, markerUnits = "…", 1
+	}
+	runes := []rune(body)
+	n, used := 0, 0
+	for _, r := range runes {
+		u := smsRuneUnits(r, gsm)
+		if used+u+markerUnits > limit {
+			break
+		}
+		used += u
+		n++
+	}
+	cut := runes[:n]
+	if i := lastSpace(cut); i >= 0 && n-i <= smsWordBoundarySlack {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(string(cut), " ") + marker
+}
+
+// smsWordBoundarySlack is how many characters FormatSMS gives up to end a
+// truncated message on a whole word.
+const smsWordBoundarySlack = 15
+
+// cleanSMSText collapses whitespace and drops control characters.
+func cleanSMSText(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+		case unicode.IsControl(r), isStrippable(r):
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// smsRuneUnits returns the units r takes in the GSM alphabet or UTF-16.
+func smsRuneUnits(r rune, gsm bool) int {
+	switch {
+	case gsm && strings.ContainsRune(gsmExtension, r):
+		return 2
+	case !gsm && r > 0xFFFF:
+		return 2 // surrogate pair
+	default:
+		return 1
+	}
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,82 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestSMSFallback_Validate(t *testing.T) {
+	assert.NoError(t, domain.SMSFallback{Enabled: true}.Validate())
+	assert.NoError(t, domain.SMSFallback{Enabled: true, OfflineAfter: time.Hour}.Validate())
+	assert.ErrorIs(t, domain.SMSFallback{OfflineAfter: time.Minute}.Validate(), domain.ErrInvalidInput)
+	assert.ErrorIs(t, domain.SMSFallback{OfflineAfter: 48 * time.Hour}.Validate(), domain.ErrInvalidInput)
+}
+
+func TestSMSFallback_Due(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	on := domain.SMSFallback{Enabled: true}
+
+	assert.False(t, on.Due(now.Add(-domain.DefaultSMSFallbackOfflineAfter+time.Second), now))
+	assert.True(t, on.Due(now.Add(-domain.DefaultSMSFallbackOfflineAfter), now))
+	assert.True(t, on.Due(time.Time{}, now), "never active")
+	assert.False(t, domain.SMSFallback{}.Due(time.Time{}, now), "disabled")
+	assert.False(t, domain.SMSFallback{Enabled: true, OfflineAfter: time.Hour}.Due(now.Add(-30*time.Minute), now))
+}
+
+func TestSMSSegments(t *testing.T) {
+	assert.Equal(t, 1, domain.SMSSegments(strings.Repeat("a", 160)))
+	assert.Equal(t, 2, domain.SMSSegments(strings.Repeat("a", 161)))
+	assert.Equal(t, 2, domain.SMSSegments(strings.Repeat("a", 306)))
+	assert.Equal(t, 3, domain.SMSSegments(strings.Repeat("a", 307)))
+	assert.Equal(t, 2, domain.SMSSegments(strings.Repeat("€", 81)), "extension characters take two septets")
+	assert.Equal(t, 1, domain.SMSSegments(strings.Repeat("я", 70)))
+	assert.Equal(t, 2, domain.SMSSegments(strings.Repeat("я", 71)))
+	assert.Equal(t, 2, domain.SMSSegments("🙂"+strings.Repeat("a", 69)), "emoji are surrogate pairs")
+}
+
+func TestFormatSMS(t *testing.T) {
+	t.Run("short message is kept", func(t *testing.T) {
+		assert.Equal(t, "Alice: see you at 5", domain.FormatSMS("Alice", "see you\n\tat  5", 1))
+	})
+
+	t.Run("drops control and bidi characters", func(t *testing.T) {
+		assert.Equal(t, "Alice: hi", domain.FormatSMS("Alice\u202e", "h\x07i", 1))
+	})
+
+	t.Run("long sender name is cut", func(t *testing.T) {
+		got := domain.FormatSMS(strings.Repeat("n", 30), "hi", 1)
+		assert.Equal(t, strings.Repeat("n", domain.MaxSMSSenderNameLength)+": hi", got)
+	})
+
+	t.Run("truncates GSM text at a word boundary", func(t *testing.T) {
+		text := strings.Repeat("word ", 60)
+
+		got := domain.FormatSMS("Alice", text, 1)
+
+		assert.Equal(t, 1, domain.SMSSegments(got))
+		assert.True(t, strings.HasSuffix(got, "word..."), got)
+		assert.LessOrEqual(t, len(got), 160)
+	})
+
+	t.Run("truncates Unicode text with a one-character marker", func(t *testing.T) {
+		text := strings.Repeat("я", 200)
+
+		got := domain.FormatSMS("Alice", text, 2)
+
+		assert.Equal(t, 2, domain.SMSSegments(got))
+		assert.True(t, strings.HasSuffix(got, "…"))
+		assert.Len(t, []rune(got), 2*67)
+	})
+
+	t.Run("does not split surrogate pairs", func(t *testing.T) {
+		got := domain.FormatSMS("A", strings.Repeat("🙂", 100), 1)
+
+		assert.Equal(t, 1, domain.SMSSegments(got))
+		assert.NotContains(t, got, "�")
+	})
+}
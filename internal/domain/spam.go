@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// SpamAction is what the Gateway does with a send that tripped a spam
+// heuristic. Actions are ordered by severity.
+type SpamAction int
+
+const (
+	// SpamAllow passes the send through.
+	SpamAllow SpamAction = iota
+	// SpamShadow passes the send through but flags it for review.
+	SpamShadow
+	// SpamThrottle rejects the send as rate limited; the client may retry.
+	SpamThrottle
+	// SpamBlock rejects the send outright.
+	SpamBlock
+)
+
+// String returns the action name used in logs and metrics.
+func (a SpamAction) String() string {
+	switch a {
+	case SpamShadow:
+		return "shadow"
+	case SpamThrottle:
+		return "throttle"
+	case SpamBlock:
+		return "block"
+	default:
+		return "allow"
+	}
+}
+
+// SpamSignals are the per-send inputs to SpamPolicy.Evaluate.
+type SpamSignals struct {
+	// Duplicates counts sends of the same content by this sender within
+	// the duplicate window, including this one.
+	Duplicates int
+	// RecentSends counts sends by this sender in the last minute,
+	// including this one.
+	RecentSends int
+	// Links and Words describe the content; see CountLinks.
+	Links int
+	Words int
+	// AccountAge is how long the sender has had an account. Zero means
+	// unknown, which disables the new-account heuristic.
+	AccountAge time.Duration
+}
+
+// SpamVerdict is the outcome of a spam evaluation. Reason names the
+// heuristic behind a non-allow action.
+type SpamVerdict struct {
+	Action SpamAction
+	Reason string
+}
+
+// SpamPolicy holds the spam heuristic thresholds. A zero threshold
+// disables its heuristic.
+type SpamPolicy struct {
+	DuplicateWindow          time.Duration
+	DuplicateThrottleAt      int
+	DuplicateBlockAt         int
+	MinLinks                 int
+	MaxLinkDensity           float64
+	NewAccountAge            time.Duration
+	NewAccountSendsPerMinute int
+
+	// ShadowOnly downgrades throttle and block verdicts to shadow, so new
+	// thresholds can be observed before they are enforced.
+	ShadowOnly bool
+}
+
+// DefaultSpamPolicy returns the compiled spam thresholds.
+func DefaultSpamPolicy() SpamPolicy {
+	return SpamPolicy{
+		DuplicateWindow:          SpamDuplicateWindow,
+		DuplicateThrottleAt:      SpamDuplicateThrottleAt,
+		DuplicateBlockAt:         SpamDuplicateBlockAt,
+		MinLinks:                 SpamMinLinks,
+		MaxLinkDensity:           SpamMaxLinkDensity,
+		NewAccountAge:            SpamNewAccountAge,
+		NewAccountSendsPerMinute: SpamNewAccountSendsPerMinute,
+	}
+}
+
+// Evaluate returns the most severe verdict any heuristic reaches for s.
+func (p SpamPolicy) Evaluate(s SpamSignals) SpamVerdict {
+	newAccount := p.NewAccountAge > 0 && s.AccountAge > 0 && s.AccountAge < p.NewAccountAge
+
+	var v SpamVerdict
+	raise := func(action SpamAction, reason string) {
+		if action > v.Action {
+			v = SpamVerdict{Action: action, Reason: reason}
+		}
+	}
+
+	switch {
+	case p.DuplicateBlockAt > 0 && s.Duplicates >= p.DuplicateBlockAt:
+		raise(SpamBlock, "duplicate_content")
+	case p.DuplicateThrottleAt > 0 && s.Duplicates >= p.DuplicateThrottleAt:
+		raise(SpamThrottle, "duplicate_content")
+	}
+
+	if p.MaxLinkDensity > 0 && s.Links >= max(p.MinLinks, 1) &&
+		float64(s.Links)/float64(max(s.Words, 1)) > p.MaxLinkDensity {
+		// Link spam from an established account is usually a false
+		// positive worth a look; from a fresh account it rarely is.
+		if newAccount {
+			raise(SpamBlock, "link_density")
+		} else {
+			raise(SpamShadow, "link_density")
+		}
+	}
+
+	if newAccount && p.NewAccountSendsPerMinute > 0 && s.RecentSends > p.NewAccountSendsPerMinute {
+		raise(SpamThrottle, "new_account_velocity")
+	}
+
+	if p.ShadowOnly && v.Action > SpamShadow {
+		v.Action = SpamShadow
+	}
+	return v
+}
+
+// CountLinks returns the number of whitespace-separated words in content
+// and how many of them look like links.
+func CountLinks(content string) (links, words int) {
+	for _, w := range strings.Fields(content) {
+		words++
+		lw := strings.ToLower(w)
+		if strings.Contains(lw, "http://") || strings.Contains(lw, "https://") || strings.HasPrefix(lw, "www.") {
+			links++
+		}
+	}
+	return links, words
+}
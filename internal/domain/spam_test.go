@@ -0,0 +1,99 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestSpamPolicy_Evaluate(t *testing.T) {
+	policy := domain.DefaultSpamPolicy()
+	established := 30 * 24 * time.Hour
+	fresh := time.Hour
+
+	tests := []struct {
+		name    string
+		policy  domain.SpamPolicy
+		signals domain.SpamSignals
+		want    domain.SpamVerdict
+	}{
+		{
+			name:    "ordinary message",
+			policy:  policy,
+			signals: domain.SpamSignals{Duplicates: 1, RecentSends: 1, Words: 5, AccountAge: established},
+			want:    domain.SpamVerdict{},
+		},
+		{
+			name:    "repeated content throttles",
+			policy:  policy,
+			signals: domain.SpamSignals{Duplicates: 3, Words: 2},
+			want:    domain.SpamVerdict{Action: domain.SpamThrottle, Reason: "duplicate_content"},
+		},
+		{
+			name:    "heavily repeated content blocks",
+			policy:  policy,
+			signals: domain.SpamSignals{Duplicates: 6, Words: 2},
+			want:    domain.SpamVerdict{Action: domain.SpamBlock, Reason: "duplicate_content"},
+		},
+		{
+			name:    "link heavy message is shadow flagged",
+			policy:  policy,
+			signals: domain.SpamSignals{Links: 2, Words: 3, AccountAge: established},
+			want:    domain.SpamVerdict{Action: domain.SpamShadow, Reason: "link_density"},
+		},
+		{
+			name:    "link heavy message from a new account blocks",
+			policy:  policy,
+			signals: domain.SpamSignals{Links: 2, Words: 3, AccountAge: fresh},
+			want:    domain.SpamVerdict{Action: domain.SpamBlock, Reason: "link_density"},
+		},
+		{
+			name:    "single link is fine",
+			policy:  policy,
+			signals: domain.SpamSignals{Links: 1, Words: 1, AccountAge: fresh},
+			want:    domain.SpamVerdict{},
+		},
+		{
+			name:    "new account sending fast throttles",
+			policy:  policy,
+			signals: domain.SpamSignals{RecentSends: 11, Words: 4, AccountAge: fresh},
+			want:    domain.SpamVerdict{Action: domain.SpamThrottle, Reason: "new_account_velocity"},
+		},
+		{
+			name:    "unknown account age skips velocity",
+			policy:  policy,
+			signals: domain.SpamSignals{RecentSends: 50, Words: 4},
+			want:    domain.SpamVerdict{},
+		},
+		{
+			name:    "shadow only downgrades enforcement",
+			policy:  domain.SpamPolicy{DuplicateBlockAt: 2, ShadowOnly: true},
+			signals: domain.SpamSignals{Duplicates: 2},
+			want:    domain.SpamVerdict{Action: domain.SpamShadow, Reason: "duplicate_content"},
+		},
+		{
+			name:    "zero policy allows everything",
+			policy:  domain.SpamPolicy{},
+			signals: domain.SpamSignals{Duplicates: 100, RecentSends: 100, Links: 10, Words: 10, AccountAge: fresh},
+			want:    domain.SpamVerdict{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Evaluate(tt.signals))
+		})
+	}
+}
+
+func TestCountLinks(t *testing.T) {
+	links, words := domain.CountLinks("check https://x.example and WWW.y.example now http://z")
+	assert.Equal(t, 3, links)
+	assert.Equal(t, 6, words)
+
+	links, words = domain.CountLinks("   ")
+	assert.Zero(t, links)
+	assert.Zero(t, words)
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SSOConnection is a workspace's OpenID Connect identity provider. Members
+// of the workspace sign in with an ID token from Issuer instead of an OTP;
+// their accounts have no phone number.
+type SSOConnection struct {
+	WorkspaceID string
+	Issuer      string // exact iss claim; discovery is fetched below it
+	ClientID    string // expected aud claim
+
+	// AllowedDomains restricts just-in-time provisioning to verified email
+	// addresses in these domains. Empty provisions any subject the IdP
+	// vouches for.
+	AllowedDomains []string
+
+	// MaxSessionTTL caps sessions started through this connection,
+	// refreshes included. Zero uses the service's refresh lifetime.
+	MaxSessionTTL time.Duration
+
+	// SCIMTokenHash is the SHA-256 of the bearer token the IdP provisions
+	// the workspace's members with. Once set, members are managed over
+	// SCIM: sign-ins need an active provisioned member instead of being
+	// provisioned just in time.
+	SCIMTokenHash string
+
+	// DataRegion is where the workspace's data is stored. Empty on
+	// connections saved before regions were recorded.
+	DataRegion DataRegion
+
+	UpdatedAt time.Time
+}
+
+// Validate checks the connection an admin submitted.
+func (c SSOConnection) Validate() error {
+	if c.WorkspaceID == "" {
+		return NewValidationError("workspace_id", "is required")
+	}
+	u, err := url.Parse(c.Issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return NewValidationError("issuer", "must be an absolute URL")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopbackHost(u.Hostname())) {
+		return NewValidationError("issuer", "must use https")
+	}
+	if c.ClientID == "" {
+		return NewValidationError("client_id", "is required")
+	}
+	for _, d := range c.AllowedDomains {
+		if d == "" || strings.ContainsAny(d, "@/ ") {
+			return NewValidationError("allowed_domains", "must be bare domain names")
+		}
+	}
+	if r := c.DataRegion; r != "" && r != RegionEU && r != RegionUS {
+		return NewValidationError("data_region", "must be "+string(RegionEU)+" or "+string(RegionUS))
+	}
+	if c.MaxSessionTTL != 0 && (c.MaxSessionTTL < MinSSOSessionTTL || c.MaxSessionTTL > MaxRefreshTokenLifetime) {
+		return NewValidationError("max_session_ttl", "must be between "+MinSSOSessionTTL.String()+" and "+MaxRefreshTokenLifetime.String())
+	}
+	return nil
+}
+
+// SessionTTL returns the lifetime of a session started through c, given
+// the service's refresh lifetime def.
+func (c SSOConnection) SessionTTL(def time.Duration) time.Duration {
+	if c.MaxSessionTTL > 0 && c.MaxSessionTTL < def {
+		return c.MaxSessionTTL
+	}
+	return def
+}
+
+// SCIMManaged reports whether the workspace's members are provisioned
+// over SCIM.
+func (c SSOConnection) SCIMManaged() bool {
+	return c.SCIMTokenHash != ""
+}
+
+// AllowsEmail reports whether a subject with email may be provisioned. An
+// unverified email never matches an allowlist.
+func (c SSOConnection) AllowsEmail(email string, verified bool) bool {
+	if len(c.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndexByte(email, '@')
+	if !verified || at < 0 {
+		return false
+	}
+	host := email[at+1:]
+	for _, d := range c.AllowedDomains {
+		if strings.EqualFold(host, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopbackHost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
@@ -0,0 +1,72 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestSSOConnection_Validate(t *testing.T) {
+	valid := domain.SSOConnection{
+		WorkspaceID: "ws-001",
+		Issuer:      "https://idp.example.com/tenant",
+		ClientID:    "messaging",
+	}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name  string
+		edit  func(*domain.SSOConnection)
+		field string
+	}{
+		{name: "missing workspace", edit: func(c *domain.SSOConnection) { c.WorkspaceID = "" }, field: "workspace_id"},
+		{name: "relative issuer", edit: func(c *domain.SSOConnection) { c.Issuer = "/tenant" }, field: "issuer"},
+		{name: "plain http issuer", edit: func(c *domain.SSOConnection) { c.Issuer = "http://idp.example.com" }, field: "issuer"},
+		{name: "missing client", edit: func(c *domain.SSOConnection) { c.ClientID = "" }, field: "client_id"},
+		{name: "email as domain", edit: func(c *domain.SSOConnection) { c.AllowedDomains = []string{"a@example.com"} }, field: "allowed_domains"},
+		{name: "ttl too short", edit: func(c *domain.SSOConnection) { c.MaxSessionTTL = time.Minute }, field: "max_session_ttl"},
+		{name: "unknown region", edit: func(c *domain.SSOConnection) { c.DataRegion = "apac" }, field: "data_region"},
+		{name: "ttl too long", edit: func(c *domain.SSOConnection) { c.MaxSessionTTL = 365 * 24 * time.Hour }, field: "max_session_ttl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.edit(&c)
+			err := c.Validate()
+			assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			assert.Contains(t, err.Error(), tt.field)
+		})
+	}
+
+	t.Run("loopback http issuer", func(t *testing.T) {
+		c := valid
+		c.Issuer = "http://localhost:8080"
+		assert.NoError(t, c.Validate())
+	})
+}
+
+func TestSSOConnection_SessionTTL(t *testing.T) {
+	def := 30 * 24 * time.Hour
+	assert.Equal(t, def, domain.SSOConnection{}.SessionTTL(def))
+	assert.Equal(t, 8*time.Hour, domain.SSOConnection{MaxSessionTTL: 8 * time.Hour}.SessionTTL(def))
+	assert.Equal(t, def, domain.SSOConnection{MaxSessionTTL: 60 * 24 * time.Hour}.SessionTTL(def))
+}
+
+func TestSSOConnection_AllowsEmail(t *testing.T) {
+	open := domain.SSOConnection{}
+	assert.True(t, open.AllowsEmail("", false))
+
+	c := domain.SSOConnection{AllowedDomains: []string{"example.com"}}
+	assert.True(t, c.AllowsEmail("ada@Example.COM", true))
+	assert.False(t, c.AllowsEmail("ada@example.com", false))
+	assert.False(t, c.AllowsEmail("ada@example.com.evil.io", true))
+	assert.False(t, c.AllowsEmail("ada", true))
+}
+
+func TestSSOConnection_SCIMManaged(t *testing.T) {
+	assert.False(t, domain.SSOConnection{}.SCIMManaged())
+	assert.True(t, domain.SSOConnection{SCIMTokenHash: "ab12"}.SCIMManaged())
+}
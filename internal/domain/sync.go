@@ -0,0 +1,40 @@
+package domain
+
+// SyncPolicy decides whether a client catching up on a chat replays every
+// missed message or receives a snapshot of the latest ones. Replaying
+// months of history on reconnect is slow for the client and expensive for
+// the messages table; past a threshold the client is better served by the
+// recent messages and an explicit gap it can backfill on demand.
+type SyncPolicy struct {
+	// SnapshotThreshold is how many sequences a client may be behind and
+	// still get an incremental replay.
+	SnapshotThreshold uint64
+	// SnapshotMessages is how many of the latest messages a snapshot holds.
+	SnapshotMessages int
+}
+
+// DefaultSyncPolicy returns the compiled sync thresholds.
+func DefaultSyncPolicy() SyncPolicy {
+	return SyncPolicy{SnapshotThreshold: SyncSnapshotThreshold, SnapshotMessages: SyncSnapshotMessages}
+}
+
+// SyncPlan is how to bring a client up to date.
+type SyncPlan struct {
+	// After is the sequence to read forward from.
+	After uint64
+	// Snapshot is set when sequences in (lastAcked, After] are skipped.
+	Snapshot bool
+}
+
+// Plan returns the plan for a client that acknowledged lastAcked in a chat
+// whose latest sequence is latest.
+func (p SyncPolicy) Plan(lastAcked, latest uint64) SyncPlan {
+	if latest <= lastAcked || latest-lastAcked <= p.SnapshotThreshold {
+		return SyncPlan{After: lastAcked}
+	}
+	after := latest - min(uint64(max(p.SnapshotMessages, 0)), latest)
+	if after <= lastAcked {
+		return SyncPlan{After: lastAcked}
+	}
+	return SyncPlan{After: after, Snapshot: true}
+}
@@ -0,0 +1,46 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestSyncPolicy_Plan(t *testing.T) {
+	policy := domain.SyncPolicy{SnapshotThreshold: 100, SnapshotMessages: 20}
+
+	tests := []struct {
+		name      string
+		policy    domain.SyncPolicy
+		lastAcked uint64
+		latest    uint64
+		want      domain.SyncPlan
+	}{
+		{name: "up to date", policy: policy, lastAcked: 500, latest: 500, want: domain.SyncPlan{After: 500}},
+		{name: "ahead of the server", policy: policy, lastAcked: 600, latest: 500, want: domain.SyncPlan{After: 600}},
+		{name: "within threshold", policy: policy, lastAcked: 400, latest: 500, want: domain.SyncPlan{After: 400}},
+		{name: "beyond threshold", policy: policy, lastAcked: 399, latest: 500, want: domain.SyncPlan{After: 480, Snapshot: true}},
+		{name: "never synced", policy: policy, lastAcked: 0, latest: 5000, want: domain.SyncPlan{After: 4980, Snapshot: true}},
+		{
+			name:      "snapshot would not skip anything",
+			policy:    domain.SyncPolicy{SnapshotThreshold: 10, SnapshotMessages: 50},
+			lastAcked: 30, latest: 45,
+			want: domain.SyncPlan{After: 30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Plan(tt.lastAcked, tt.latest))
+		})
+	}
+}
+
+func TestDefaultSyncPolicy(t *testing.T) {
+	p := domain.DefaultSyncPolicy()
+	assert.Equal(t, uint64(domain.SyncSnapshotThreshold), p.SnapshotThreshold)
+	assert.Equal(t, domain.SyncSnapshotMessages, p.SnapshotMessages)
+	assert.Less(t, p.SnapshotMessages, domain.SyncSnapshotThreshold, "a snapshot must skip something")
+}
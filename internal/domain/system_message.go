@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SystemEvent identifies the change a system message reports.
+type SystemEvent string
+
+// System message events.
+const (
+	SystemEventMemberJoined    SystemEvent = "member_joined"
+	SystemEventMemberLeft      SystemEvent = "member_left"
+	SystemEventMemberRemoved   SystemEvent = "member_removed"
+	SystemEventChatRenamed     SystemEvent = "chat_renamed"
+	SystemEventSettingsChanged SystemEvent = "settings_changed"
+	// SystemEventOwnershipTransferred has the new owner as TargetID and no
+	// ActorID when ownership passed by succession.
+	SystemEventOwnershipTransferred SystemEvent = "ownership_transferred"
+	// SystemEventRoleChanged carries the new role in Params["role"].
+	SystemEventRoleChanged SystemEvent = "role_changed"
+)
+
+// Valid reports whether e is a known event.
+func (e SystemEvent) Valid() bool {
+	switch e {
+	case SystemEventMemberJoined, SystemEventMemberLeft, SystemEventMemberRemoved,
+		SystemEventChatRenamed, SystemEventSettingsChanged,
+		SystemEventOwnershipTransferred, SystemEventRoleChanged:
+		return true
+	}
+	return false
+}
+
+// SystemClientMessageIDPrefix reserves a client message ID namespace for
+// server-generated messages. NewClientMessageID rejects IDs with this
+// prefix, so a client cannot claim a system message's idempotency key.
+const SystemClientMessageIDPrefix = "sys:"
+
+// SystemMessage is a server-generated chat message reporting a membership
+// or settings change. It carries structured parameters, never rendered
+// text: clients localize it from Event and Params (e.g. "name" for
+// chat_renamed).
+type SystemMessage struct {
+	Event    SystemEvent       `json:"event"`
+	ActorID  string            `json:"actor_id,omitempty"`  // user who made the change
+	TargetID string            `json:"target_id,omitempty"` // user the change is about
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// Content validates m and encodes it as a ContentTypeSystem message body.
+func (m SystemMessage) Content() (MessageContent, error) {
+	if !m.Event.Valid() {
+		return MessageContent{}, NewValidationError("event", fmt.Sprintf("unknown system event %q", m.Event))
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return MessageContent{}, fmt.Errorf("encode system message: %w", err)
+	}
+	if len(body) > MaxMessageSize {
+		return MessageContent{}, fmt.Errorf("system message is %d bytes, max %d: %w", len(body), MaxMessageSize, ErrMessageTooLarge)
+	}
+	return MessageContent{contentType: ContentTypeSystem, body: string(body)}, nil
+}
+
+// ParseSystemMessage decodes a ContentTypeSystem message body.
+func ParseSystemMessage(body string) (SystemMessage, error) {
+	var m SystemMessage
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		return SystemMessage{}, fmt.Errorf("decode system message: %w", ErrInvalidInput)
+	}
+	return m, nil
+}
+
+// SystemClientMessageID returns the idempotency key for a system message
+// generated from the change identified by key, so a redelivered change
+// does not post twice.
+func SystemClientMessageID(key string) string {
+	return SystemClientMessageIDPrefix + key
+}
+
+// isSystemClientMessageID reports whether raw is in the reserved namespace.
+func isSystemClientMessageID(raw string) bool {
+	return strings.HasPrefix(raw, SystemClientMessageIDPrefix)
+}
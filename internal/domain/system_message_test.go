@@ -0,0 +1,52 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestSystemMessage_Content(t *testing.T) {
+	t.Run("round-trips through the message body", func(t *testing.T) {
+		msg := domain.SystemMessage{
+			Event:   domain.SystemEventChatRenamed,
+			ActorID: "user-001",
+			Params:  map[string]string{"name": "Launch"},
+		}
+
+		content, err := msg.Content()
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.ContentTypeSystem, content.ContentType())
+		got, err := domain.ParseSystemMessage(content.Body())
+		require.NoError(t, err)
+		assert.Equal(t, msg, got)
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		_, err := domain.SystemMessage{Event: "chat_deleted"}.Content()
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("oversized params", func(t *testing.T) {
+		msg := domain.SystemMessage{
+			Event:  domain.SystemEventSettingsChanged,
+			Params: map[string]string{"description": strings.Repeat("x", domain.MaxMessageSize)},
+		}
+
+		_, err := msg.Content()
+
+		assert.ErrorIs(t, err, domain.ErrMessageTooLarge)
+	})
+}
+
+func TestParseSystemMessage_Malformed(t *testing.T) {
+	_, err := domain.ParseSystemMessage("hello")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimestampLayout is how a TimestampMS is stored: RFC 3339 in UTC with a
+// fixed three-digit fraction, so stored timestamps compare correctly as
+// strings. Timestamps written before TimestampMS have no fraction; they
+// still parse, and compare correctly against this layout except within the
+// same second.
+const TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// TimestampMS is a UTC instant with millisecond precision, the form record
+// timestamps take between the application and its stores. The zero value is
+// unset and formats as the empty string, as an absent attribute does.
+type TimestampMS struct{ ms int64 }
+
+// NewTimestampMS truncates t to the millisecond. A zero t yields the zero
+// TimestampMS.
+func NewTimestampMS(t time.Time) TimestampMS {
+	if t.IsZero() {
+		return TimestampMS{}
+	}
+	return TimestampMS{ms: t.UnixMilli()}
+}
+
+// TimestampNow stamps the current time from c.
+func TimestampNow(c Clock) TimestampMS {
+	return NewTimestampMS(c.Now())
+}
+
+// TimestampFromMillis wraps UTC milliseconds since epoch. Zero is unset.
+func TimestampFromMillis(ms int64) TimestampMS {
+	return TimestampMS{ms: ms}
+}
+
+// ParseTimestamp parses a stored RFC 3339 timestamp with any fraction. The
+// empty string is the zero TimestampMS.
+func ParseTimestamp(s string) (TimestampMS, error) {
+	if s == "" {
+		return TimestampMS{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return TimestampMS{}, fmt.Errorf("parse timestamp %q: %w", s, ErrInvalidInput)
+	}
+	return NewTimestampMS(t), nil
+}
+
+// String formats t in TimestampLayout, or returns "" when t is unset.
+func (t TimestampMS) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time().Format(TimestampLayout)
+}
+
+// Time returns t as a UTC time.Time, or the zero time when t is unset.
+func (t TimestampMS) Time() time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return time.UnixMilli(t.ms).UTC()
+}
+
+// UnixMilli returns t as UTC milliseconds since epoch.
+func (t TimestampMS) UnixMilli() int64 { return t.ms }
+
+// IsZero reports whether t is unset.
+func (t TimestampMS) IsZero() bool { return t.ms == 0 }
+
+// Add returns t+d, truncated to the millisecond.
+func (t TimestampMS) Add(d time.Duration) TimestampMS {
+	return TimestampMS{ms: t.ms + d.Milliseconds()}
+}
+
+// Sub returns t - u.
+func (t TimestampMS) Sub(u TimestampMS) time.Duration {
+	return time.Duration(t.ms-u.ms) * time.Millisecond
+}
+
+// Before reports whether t is before u.
+func (t TimestampMS) Before(u TimestampMS) bool { return t.ms < u.ms }
+
+// After reports whether t is after u.
+func (t TimestampMS) After(u TimestampMS) bool { return t.ms > u.ms }
@@ -0,0 +1,101 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+func TestTimestampMS_String(t *testing.T) {
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{"whole second", time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC), "2026-02-10T12:00:00.000Z"},
+		{"truncates to millis", time.Date(2026, 2, 10, 12, 0, 0, 123_987_000, time.UTC), "2026-02-10T12:00:00.123Z"},
+		{"converts to UTC", time.Date(2026, 2, 10, 13, 0, 0, 0, time.FixedZone("CET", 3600)), "2026-02-10T12:00:00.000Z"},
+		{"zero is unset", time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, domain.NewTimestampMS(tt.in).String())
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := domain.NewTimestampMS(time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		in   string
+		want domain.TimestampMS
+	}{
+		{"current layout", "2026-02-10T12:00:00.000Z", want},
+		{"legacy whole second", "2026-02-10T12:00:00Z", want},
+		{"offset", "2026-02-10T13:00:00+01:00", want},
+		{"nanoseconds truncate", "2026-02-10T12:00:00.000999Z", want},
+		{"empty is unset", "", domain.TimestampMS{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := domain.ParseTimestamp(tt.in)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := domain.ParseTimestamp("yesterday")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestTimestampMS_RoundTrip(t *testing.T) {
+	ts := domain.NewTimestampMS(time.Date(2026, 2, 10, 12, 0, 0, 456_000_000, time.UTC))
+
+	got, err := domain.ParseTimestamp(ts.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, ts, got)
+	assert.Equal(t, ts.UnixMilli(), domain.TimestampFromMillis(ts.UnixMilli()).UnixMilli())
+	assert.Equal(t, time.UTC, ts.Time().Location())
+}
+
+// Stored timestamps are compared as strings by DynamoDB conditions, so the
+// string order must match the time order.
+func TestTimestampMS_StringsSortInTimeOrder(t *testing.T) {
+	base := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	steps := []time.Duration{0, time.Millisecond, 999 * time.Millisecond, time.Second, time.Hour, 24 * time.Hour}
+
+	for i := 1; i < len(steps); i++ {
+		earlier := domain.NewTimestampMS(base.Add(steps[i-1]))
+		later := domain.NewTimestampMS(base.Add(steps[i]))
+
+		assert.True(t, earlier.Before(later))
+		assert.True(t, later.After(earlier))
+		assert.Less(t, earlier.String(), later.String())
+	}
+}
+
+func TestTimestampMS_Arithmetic(t *testing.T) {
+	clock := domaintest.NewFakeClock(time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC))
+	now := domain.TimestampNow(clock)
+
+	later := now.Add(1500 * time.Millisecond)
+
+	assert.Equal(t, 1500*time.Millisecond, later.Sub(now))
+	assert.False(t, now.IsZero())
+	assert.True(t, domain.TimestampMS{}.IsZero())
+	assert.True(t, domain.TimestampMS{}.Time().IsZero())
+}
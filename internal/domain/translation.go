@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Translation is a message's text in another language.
+type Translation struct {
+	Text           string
+	SourceLanguage string // detected by the provider
+	TargetLanguage string
+}
+
+// languageTagPattern accepts the language tags translation providers use:
+// a 2-3 letter language with an optional 2 letter region, e.g. "en",
+// "pt-BR", "fil".
+var languageTagPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z]{2})?$`)
+
+// NormalizeLanguage validates a language tag and returns it in canonical
+// case: lower-case language, upper-case region ("PT-br" → "pt-BR").
+func NormalizeLanguage(tag string) (string, error) {
+	if !languageTagPattern.MatchString(tag) {
+		return "", NewValidationError("language", "must be a language tag such as en or pt-BR")
+	}
+	lang, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		return strings.ToLower(lang), nil
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region), nil
+}
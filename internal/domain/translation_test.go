@@ -0,0 +1,38 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "en", want: "en"},
+		{in: "EN", want: "en"},
+		{in: "PT-br", want: "pt-BR"},
+		{in: "fil", want: "fil"},
+		{in: ""},
+		{in: "english"},
+		{in: "en_US"},
+		{in: "zh-Hant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := domain.NormalizeLanguage(tt.in)
+
+			if tt.want == "" {
+				assert.ErrorIs(t, err, domain.ErrInvalidInput)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
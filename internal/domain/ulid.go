@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs. Its ordering
+// matches ASCII ordering, so ULID strings sort lexicographically by time.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const ulidLength = 26
+
+// crockfordAliases are the letters Crockford base32 decoders accept for
+// symbols they resemble: I and L read as 1, O as 0, and U as V. Encoders
+// never produce them.
+var crockfordAliases = map[byte]byte{'I': '1', 'L': '1', 'O': '0', 'U': 'V'}
+
+// crockfordIndex maps an upper-case ASCII byte to its 5-bit value, or 0xFF.
+var crockfordIndex = func() [256]byte {
+	var idx [256]byte
+	for i := range idx {
+		idx[i] = 0xFF
+	}
+	for i := 0; i < len(crockford); i++ {
+		idx[crockford[i]] = byte(i)
+	}
+	for alias, canonical := range crockfordAliases {
+		idx[alias] = idx[canonical]
+	}
+	return idx
+}()
+
+// ulid is a 128-bit identifier: 48-bit big-endian millisecond timestamp
+// followed by 80 bits of entropy.
+type ulid [16]byte
+
+// parseULID decodes a 26-character Crockford base32 ULID, case-insensitively
+// and accepting the I/L/O/U aliases. String re-encodes it canonically.
+func parseULID(s string) (ulid, bool) {
+	var u ulid
+	if len(s) != ulidLength {
+		return u, false
+	}
+	// 26 chars × 5 bits = 130 bits; the first char carries only 3 bits.
+	if crockfordIndex[upperASCII(s[0])] > 7 {
+		return u, false
+	}
+
+	var hi, lo uint64 // 128-bit accumulator
+	for i := 0; i < ulidLength; i++ {
+		v := crockfordIndex[upperASCII(s[i])]
+		if v == 0xFF {
+			return u, false
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, true
+}
+
+func upperASCII(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+	return c
+}
+
+// String encodes the ULID as 26 upper-case Crockford base32 characters.
+func (u ulid) String() string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+
+	var out [ulidLength]byte
+	for i := ulidLength - 1; i >= 0; i-- {
+		out[i] = crockford[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// time returns the millisecond timestamp component.
+func (u ulid) time() time.Time {
+	ms := uint64(u[0])<<40 | uint64(u[1])<<32 | uint64(u[2])<<24 |
+		uint64(u[3])<<16 | uint64(u[4])<<8 | uint64(u[5])
+	return FromMillis(int64(ms))
+}
+
+// ULIDGenerator produces monotonic ULIDs: IDs generated within the same
+// millisecond increment the entropy of the previous ID instead of drawing new
+// randomness, so IDs from one generator are strictly increasing even when the
+// clock does not advance. Safe for concurrent use.
+type ULIDGenerator struct {
+	clock Clock
+
+	mu     sync.Mutex
+	lastMs uint64
+	last   ulid
+}
+
+// NewULIDGenerator creates a ULIDGenerator reading time from clock.
+func NewULIDGenerator(clock Clock) *ULIDGenerator {
+	return &ULIDGenerator{clock: clock}
+}
+
+// next returns the next ULID. If the clock moves backwards the last
+// timestamp is reused so ordering is preserved.
+func (g *ULIDGenerator) next() ulid {
+	ms := uint64(NowUTCMillis(g.clock))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ms <= g.lastMs && g.lastMs != 0 {
+		if incrementEntropy(&g.last) {
+			return g.last
+		}
+		// Entropy exhausted within one millisecond (2^80 IDs): borrow the next one.
+		ms = g.lastMs + 1
+	}
+
+	u := ulidAt(ms)
+	fillEntropy(&u)
+
+	g.lastMs = ms
+	g.last = u
+	return u
+}
+
+// ulidAt returns a ULID stamped with ms and zero entropy.
+func ulidAt(ms uint64) ulid {
+	var u ulid
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	return u
+}
+
+// fillEntropy draws the 80-bit entropy field from crypto/rand.
+func fillEntropy(u *ulid) {
+	if _, err := rand.Read(u[6:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+}
+
+// incrementEntropy adds one to the 80-bit entropy field, reporting false on
+// overflow.
+func incrementEntropy(u *ulid) bool {
+	for i := len(u) - 1; i >= 6; i-- {
+		u[i]++
+		if u[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMessageID generates a time-ordered MessageID.
+func (g *ULIDGenerator) NewMessageID() MessageID {
+	return MessageID{value: g.next().String()}
+}
+
+// MessageIDScheme selects how new MessageIDs are generated. Both schemes are
+// always accepted by NewMessageID, so switching schemes is backward compatible.
+type MessageIDScheme string
+
+const (
+	MessageIDSchemeUUID MessageIDScheme = "uuid"
+	MessageIDSchemeULID MessageIDScheme = "ulid"
+)
+
+// MessageIDGenerator produces new MessageIDs.
+type MessageIDGenerator interface {
+	NewMessageID() MessageID
+}
+
+// uuidMessageIDs generates random UUIDv4 MessageIDs.
+type uuidMessageIDs struct{}
+
+func (uuidMessageIDs) NewMessageID() MessageID { return GenerateMessageID() }
+
+// NewMessageIDGenerator returns a generator for the given scheme. An empty
+// scheme selects UUIDs, the historical default.
+func NewMessageIDGenerator(scheme MessageIDScheme, clock Clock) (MessageIDGenerator, error) {
+	switch MessageIDScheme(strings.ToLower(string(scheme))) {
+	case "", MessageIDSchemeUUID:
+		return uuidMessageIDs{}, nil
+	case MessageIDSchemeULID:
+		return NewULIDGenerator(clock), nil
+	default:
+		return nil, fmt.Errorf("unknown message ID scheme %q: %w", scheme, ErrInvalidInput)
+	}
+}
+
+// Compile-time interface checks.
+var (
+	_ MessageIDGenerator = uuidMessageIDs{}
+	_ MessageIDGenerator = (*ULIDGenerator)(nil)
+)
@@ -0,0 +1,121 @@
+package domain_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestULIDGenerator_EmbedsClockTime(t *testing.T) {
+	start := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	gen := domain.NewULIDGenerator(domaintest.NewFakeClock(start))
+
+	id := gen.NewMessageID()
+
+	assert.Len(t, id.String(), 26)
+	ts, ok := id.Time()
+	require.True(t, ok)
+	assert.Equal(t, start, ts)
+
+	parsed, err := domain.NewMessageID(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+}
+
+func TestULIDGenerator_MonotonicWithinMillisecond(t *testing.T) {
+	gen := domain.NewULIDGenerator(domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)))
+
+	prev := gen.NewMessageID().String()
+	for range 1000 {
+		next := gen.NewMessageID().String()
+		require.Less(t, prev, next)
+		prev = next
+	}
+}
+
+func TestULIDGenerator_SortsByTime(t *testing.T) {
+	clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	gen := domain.NewULIDGenerator(clock)
+
+	var ids []string
+	for range 50 {
+		ids = append(ids, gen.NewMessageID().String())
+		clock.Advance(time.Millisecond)
+	}
+
+	assert.True(t, sort.StringsAreSorted(ids))
+}
+
+func TestULIDGenerator_ClockRegression(t *testing.T) {
+	clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	gen := domain.NewULIDGenerator(clock)
+
+	first := gen.NewMessageID().String()
+	clock.Set(time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC))
+	second := gen.NewMessageID().String()
+
+	assert.Less(t, first, second)
+}
+
+func TestULIDGenerator_ConcurrentUnique(t *testing.T) {
+	gen := domain.NewULIDGenerator(domain.RealClock{})
+
+	const workers, perWorker = 8, 250
+	results := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				results <- gen.NewMessageID().String()
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[string]struct{}, workers*perWorker)
+	for id := range results {
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, workers*perWorker)
+}
+
+func TestNewMessageIDGenerator(t *testing.T) {
+	clock := domaintest.NewFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		scheme   domain.MessageIDScheme
+		wantULID bool
+		wantErr  bool
+	}{
+		{name: "empty defaults to UUID", scheme: ""},
+		{name: "uuid", scheme: domain.MessageIDSchemeUUID},
+		{name: "ulid", scheme: domain.MessageIDSchemeULID, wantULID: true},
+		{name: "ULID is case-insensitive", scheme: "ULID", wantULID: true},
+		{name: "unknown scheme", scheme: "snowflake", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := domain.NewMessageIDGenerator(tt.scheme, clock)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrInvalidInput)
+				return
+			}
+			require.NoError(t, err)
+			id := gen.NewMessageID()
+			_, isULID := id.Time()
+			assert.Equal(t, tt.wantULID, isULID)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "strings"
+
+// FieldViolation describes why a single request field is invalid.
+// Field is a dotted path in the wire representation (e.g. "phone_number").
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError reports one or more invalid request fields. It matches
+// ErrInvalidInput via errors.Is, so existing mappings apply unchanged, while
+// transport mappers can recover the violations via errors.As.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+// NewValidationError creates a ValidationError for a single field.
+func NewValidationError(field, description string) *ValidationError {
+	return &ValidationError{Violations: []FieldViolation{{Field: field, Description: description}}}
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Description)
+	}
+	return ErrInvalidInput.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns ErrInvalidInput.
+func (e *ValidationError) Unwrap() error { return ErrInvalidInput }
@@ -0,0 +1,32 @@
+package domain_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationError(t *testing.T) {
+	t.Run("matches ErrInvalidInput", func(t *testing.T) {
+		err := fmt.Errorf("verify otp: %w", domain.NewValidationError("device_id", "is required"))
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		assert.True(t, domain.IsClientError(err))
+	})
+
+	t.Run("violations recoverable via errors.As", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", &domain.ValidationError{Violations: []domain.FieldViolation{
+			{Field: "phone_number", Description: "is required"},
+			{Field: "otp", Description: "must be 6 digits"},
+		}})
+
+		var ve *domain.ValidationError
+		require.True(t, errors.As(err, &ve))
+		assert.Len(t, ve.Violations, 2)
+		assert.Equal(t, "invalid input: phone_number: is required; otp: must be 6 digits", ve.Error())
+	})
+}
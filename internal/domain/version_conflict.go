@@ -0,0 +1,25 @@
+package domain
+
+import "strconv"
+
+// VersionConflictError reports that a conditional update named a version
+// the resource has moved past. It matches ErrVersionConflict via
+// errors.Is, while transport mappers can recover Current via errors.As so
+// the client can re-read, merge and retry against it.
+type VersionConflictError struct {
+	Expected int64 // Version the caller last read
+	Current  int64 // Version the resource is at now
+}
+
+// NewVersionConflictError creates a VersionConflictError.
+func NewVersionConflictError(expected, current int64) *VersionConflictError {
+	return &VersionConflictError{Expected: expected, Current: current}
+}
+
+func (e *VersionConflictError) Error() string {
+	return ErrVersionConflict.Error() + ": at version " + strconv.FormatInt(e.Current, 10) +
+		", not " + strconv.FormatInt(e.Expected, 10)
+}
+
+// Unwrap returns ErrVersionConflict.
+func (e *VersionConflictError) Unwrap() error { return ErrVersionConflict }
@@ -0,0 +1,30 @@
+package domain_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestVersionConflictError(t *testing.T) {
+	t.Run("matches ErrVersionConflict", func(t *testing.T) {
+		err := fmt.Errorf("update chat settings: %w", domain.NewVersionConflictError(3, 5))
+
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+		assert.True(t, domain.IsClientError(err))
+	})
+
+	t.Run("versions recoverable via errors.As", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", domain.NewVersionConflictError(3, 5))
+
+		var vc *domain.VersionConflictError
+		require.True(t, errors.As(err, &vc))
+		assert.Equal(t, int64(5), vc.Current)
+		assert.Equal(t, "resource was modified concurrently: at version 5, not 3", vc.Error())
+	})
+}
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// WorkspaceMember is a workspace account provisioned by the workspace's
+// identity provider over SCIM. UserName is the IdP's login name, usually
+// an email address; it is unique within the workspace, ignoring case, and
+// is what an SSO sign-in's verified email is matched against.
+type WorkspaceMember struct {
+	WorkspaceID string
+	UserID      string
+	UserName    string
+	ExternalID  string // IdP's own ID for the user, echoed back verbatim
+	DisplayName string
+	// Active is false once the IdP deactivates the member. Inactive
+	// members cannot sign in and belong to no groups or chats.
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Validate checks a member the IdP submitted.
+func (m WorkspaceMember) Validate() error {
+	switch {
+	case m.WorkspaceID == "":
+		return NewValidationError("workspace_id", "is required")
+	case strings.TrimSpace(m.UserName) == "":
+		return NewValidationError("userName", "is required")
+	case utf8.RuneCountInString(m.UserName) > MaxSCIMUserNameLength:
+		return NewValidationError("userName", fmt.Sprintf("must be at most %d characters", MaxSCIMUserNameLength))
+	case utf8.RuneCountInString(m.DisplayName) > MaxDisplayNameLength:
+		return NewValidationError("displayName", fmt.Sprintf("must be at most %d characters", MaxDisplayNameLength))
+	}
+	return nil
+}
+
+// NormalizeUserName returns the form of a SCIM userName used for
+// uniqueness and lookups.
+func NormalizeUserName(userName string) string {
+	return strings.ToLower(strings.TrimSpace(userName))
+}
+
+// WorkspaceGroup is a group of workspace members pushed by the IdP.
+type WorkspaceGroup struct {
+	WorkspaceID string
+	GroupID     string
+	DisplayName string
+	ExternalID  string
+	MemberIDs   []string // user IDs, without duplicates
+	// Version counts the group's writes; an update is conditional on the
+	// version it read.
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Validate checks a group the IdP submitted.
+func (g WorkspaceGroup) Validate() error {
+	switch {
+	case g.WorkspaceID == "":
+		return NewValidationError("workspace_id", "is required")
+	case strings.TrimSpace(g.DisplayName) == "":
+		return NewValidationError("displayName", "is required")
+	case utf8.RuneCountInString(g.DisplayName) > MaxDisplayNameLength:
+		return NewValidationError("displayName", fmt.Sprintf("must be at most %d characters", MaxDisplayNameLength))
+	case len(g.MemberIDs) > MaxWorkspaceGroupMembers:
+		return NewValidationError("members", fmt.Sprintf("must number at most %d", MaxWorkspaceGroupMembers))
+	}
+	return nil
+}
+
+// AddMembers adds userIDs not already in the group, keeping the order
+// members were added in.
+func (g *WorkspaceGroup) AddMembers(userIDs ...string) {
+	for _, id := range userIDs {
+		if !slices.Contains(g.MemberIDs, id) {
+			g.MemberIDs = append(g.MemberIDs, id)
+		}
+	}
+}
+
+// RemoveMembers removes userIDs from the group and reports whether any
+// was a member.
+func (g *WorkspaceGroup) RemoveMembers(userIDs ...string) bool {
+	n := len(g.MemberIDs)
+	g.MemberIDs = slices.DeleteFunc(g.MemberIDs, func(id string) bool {
+		return slices.Contains(userIDs, id)
+	})
+	return len(g.MemberIDs) != n
+}
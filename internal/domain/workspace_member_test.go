@@ -0,0 +1,63 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestWorkspaceMember_Validate(t *testing.T) {
+	valid := domain.WorkspaceMember{WorkspaceID: "ws-001", UserName: "ada@example.com", Active: true}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name  string
+		edit  func(*domain.WorkspaceMember)
+		field string
+	}{
+		{name: "missing workspace", edit: func(m *domain.WorkspaceMember) { m.WorkspaceID = "" }, field: "workspace_id"},
+		{name: "blank userName", edit: func(m *domain.WorkspaceMember) { m.UserName = "  " }, field: "userName"},
+		{name: "long userName", edit: func(m *domain.WorkspaceMember) { m.UserName = strings.Repeat("a", 257) }, field: "userName"},
+		{name: "long displayName", edit: func(m *domain.WorkspaceMember) { m.DisplayName = strings.Repeat("a", 65) }, field: "displayName"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid
+			tt.edit(&m)
+			err := m.Validate()
+			assert.ErrorIs(t, err, domain.ErrInvalidInput)
+			assert.Contains(t, err.Error(), tt.field)
+		})
+	}
+}
+
+func TestNormalizeUserName(t *testing.T) {
+	assert.Equal(t, "ada@example.com", domain.NormalizeUserName(" Ada@Example.COM "))
+}
+
+func TestWorkspaceGroup_Validate(t *testing.T) {
+	valid := domain.WorkspaceGroup{WorkspaceID: "ws-001", DisplayName: "Engineering"}
+	assert.NoError(t, valid.Validate())
+
+	g := valid
+	g.DisplayName = ""
+	assert.ErrorIs(t, g.Validate(), domain.ErrInvalidInput)
+
+	g = valid
+	g.MemberIDs = make([]string, domain.MaxWorkspaceGroupMembers+1)
+	assert.ErrorContains(t, g.Validate(), "members")
+}
+
+func TestWorkspaceGroup_Members(t *testing.T) {
+	g := domain.WorkspaceGroup{MemberIDs: []string{"u1"}}
+
+	g.AddMembers("u2", "u1", "u3")
+	assert.Equal(t, []string{"u1", "u2", "u3"}, g.MemberIDs)
+
+	assert.True(t, g.RemoveMembers("u2", "u9"))
+	assert.Equal(t, []string{"u1", "u3"}, g.MemberIDs)
+	assert.False(t, g.RemoveMembers("u9"))
+}
@@ -79,6 +79,22 @@ func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 	}, nil
 }
 
+// Warmup describes each table, which opens a connection to the endpoint and
+// fails fast when a table is missing or not yet active. Use it as a startup
+// warmup step.
+func (c *Client) Warmup(ctx context.Context, tables ...string) error {
+	for _, table := range tables {
+		out, err := c.DB.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return fmt.Errorf("describe table %s: %w", table, err)
+		}
+		if status := out.Table.TableStatus; status != types.TableStatusActive {
+			return fmt.Errorf("table %s is %s", table, status)
+		}
+	}
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // Type aliases — adapters import dynamo.GetItemInput instead of the SDK.
 // ---------------------------------------------------------------------------
@@ -97,6 +113,19 @@ type (
 	DeleteItemOutput = dynamodb.DeleteItemOutput
 )
 
+// Scan types. Scans read the whole table; keep them to background jobs.
+type (
+	ScanInput  = dynamodb.ScanInput
+	ScanOutput = dynamodb.ScanOutput
+)
+
+// Batch read types.
+type (
+	BatchGetItemInput  = dynamodb.BatchGetItemInput
+	BatchGetItemOutput = dynamodb.BatchGetItemOutput
+	KeysAndAttributes  = types.KeysAndAttributes
+)
+
 // Transaction types.
 type (
 	TransactWriteItemsInput  = dynamodb.TransactWriteItemsInput
@@ -117,7 +146,9 @@ type (
 	AttributeValue           = types.AttributeValue
 	AttributeValueMemberS    = types.AttributeValueMemberS
 	AttributeValueMemberN    = types.AttributeValueMemberN
+	AttributeValueMemberB    = types.AttributeValueMemberB
 	AttributeValueMemberBOOL = types.AttributeValueMemberBOOL
+	AttributeValueMemberM    = types.AttributeValueMemberM
 )
 
 // Expression builder types.
@@ -129,6 +160,21 @@ type (
 	KeyBuilder       = expression.KeyBuilder
 )
 
+// Return value options for write operations.
+type (
+	ReturnValue                         = types.ReturnValue
+	ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailure
+)
+
+const (
+	// ReturnValueUpdatedNew returns the updated attributes as they are
+	// after the write.
+	ReturnValueUpdatedNew = types.ReturnValueUpdatedNew
+	// ReturnAllOldOnConditionCheckFailure returns the item as it was when
+	// a write's condition fails; see ConditionalCheckFailedItem.
+	ReturnAllOldOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+)
+
 // Options is the DynamoDB client options type.
 // Re-exported so adapter-defined interfaces can reference optFns variadic params.
 type Options = dynamodb.Options
@@ -165,6 +211,17 @@ func IsConditionalCheckFailed(err error) bool {
 	return errors.As(err, &ccf)
 }
 
+// ConditionalCheckFailedItem returns the item a failed condition was
+// checked against, for writes made with ReturnAllOldOnConditionCheckFailure.
+// The item is empty when it did not exist.
+func ConditionalCheckFailedItem(err error) (map[string]AttributeValue, bool) {
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		return nil, false
+	}
+	return ccf.Item, true
+}
+
 // ErrConditionalCheckFailed returns a ConditionalCheckFailedException suitable
 // for testing. Production code should never construct this error — DynamoDB
 // returns it. This helper exists so adapter tests can exercise the
@@ -175,6 +232,16 @@ func ErrConditionalCheckFailed() error {
 	}
 }
 
+// ErrConditionalCheckFailedWithItem is ErrConditionalCheckFailed carrying
+// the item the condition was checked against, as DynamoDB returns it for
+// ReturnAllOldOnConditionCheckFailure. For tests only.
+func ErrConditionalCheckFailedWithItem(item map[string]AttributeValue) error {
+	return &types.ConditionalCheckFailedException{
+		Message: aws.String("The conditional request failed"),
+		Item:    item,
+	}
+}
+
 // ErrTransactionCanceled returns a TransactionCanceledException with the given
 // cancellation reason codes, suitable for testing. Each code corresponds to a
 // transaction item; empty string means that item succeeded. Production code
@@ -2,6 +2,10 @@ package dynamo_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -36,3 +40,52 @@ func TestNewClientWithDefaultEndpoint(t *testing.T) {
 	require.NotNil(t, client)
 	require.NotNil(t, client.DB)
 }
+
+func TestClientWarmup(t *testing.T) {
+	// statuses maps table name to the status the fake endpoint reports;
+	// unknown tables get ResourceNotFoundException.
+	statuses := map[string]string{"users": "ACTIVE", "sessions": "CREATING"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ TableName string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		status, ok := statuses[in.TableName]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"Table":{"TableName":%q,"TableStatus":%q}}`, in.TableName, status)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := dynamo.NewClient(context.Background(), dynamo.Config{
+		Endpoint: srv.URL,
+		Region:   "us-east-2",
+		Timeout:  5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		tables  []string
+		wantErr string
+	}{
+		{name: "active table", tables: []string{"users"}},
+		{name: "table not active", tables: []string{"users", "sessions"}, wantErr: "table sessions is CREATING"},
+		{name: "missing table", tables: []string{"otp_requests"}, wantErr: "describe table otp_requests"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.Warmup(context.Background(), tt.tables...)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package errmap
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// CatalogEntry is one error of the published catalog: its stable numeric
+// code, the string code it carries on every transport, and how it surfaces.
+type CatalogEntry struct {
+	ErrorCode   int    `json:"error_code"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	GRPCCode    string `json:"grpc_code"`
+	HTTPStatus  int    `json:"http_status"`
+	Retryable   bool   `json:"retryable"`
+}
+
+// Catalog returns the domain error catalog, followed by the internal error
+// every uncataloged error maps to, in code order.
+func Catalog() []CatalogEntry {
+	defs := domain.ErrorCatalog()
+	out := make([]CatalogEntry, 0, len(defs)+1)
+	for _, d := range defs {
+		out = append(out, catalogEntry(d.Err, d.Description))
+	}
+	internal, _ := domain.LookupError(errUncataloged)
+	return append(out, catalogEntry(errUncataloged, internal.Description))
+}
+
+// errUncataloged stands for any error outside the catalog.
+var errUncataloged = errors.New("uncataloged error")
+
+func catalogEntry(err error, description string) CatalogEntry {
+	m := Map(err)
+	return CatalogEntry{
+		ErrorCode:   int(m.ErrorCode),
+		Code:        m.Protocol,
+		Description: description,
+		GRPCCode:    m.GRPC.String(),
+		HTTPStatus:  m.HTTPStatus,
+		Retryable:   m.Retryable,
+	}
+}
+
+// CatalogHandler serves the error catalog as JSON so client teams can
+// generate their error handling from it.
+func CatalogHandler() http.Handler {
+	body, err := json.Marshal(struct {
+		Errors []CatalogEntry `json:"errors"`
+	}{Catalog()})
+	if err != nil {
+		panic("errmap: marshal catalog: " + err.Error()) // static data; cannot fail
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
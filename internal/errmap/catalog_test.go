@@ -0,0 +1,48 @@
+package errmap_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+func TestCatalog(t *testing.T) {
+	catalog := errmap.Catalog()
+	require.Len(t, catalog, len(domain.ErrorCatalog())+1)
+
+	i := slices.IndexFunc(catalog, func(e errmap.CatalogEntry) bool { return e.ErrorCode == 3001 })
+	require.GreaterOrEqual(t, i, 0)
+	assert.Equal(t, errmap.CatalogEntry{
+		ErrorCode:   3001,
+		Code:        "NOT_MEMBER",
+		Description: "The caller is not a member of the chat.",
+		GRPCCode:    "PermissionDenied",
+		HTTPStatus:  http.StatusForbidden,
+	}, catalog[i])
+
+	internal := catalog[len(catalog)-1]
+	assert.Equal(t, int(domain.ErrorCodeInternal), internal.ErrorCode)
+	assert.Equal(t, "INTERNAL", internal.Code)
+	assert.Equal(t, http.StatusInternalServerError, internal.HTTPStatus)
+}
+
+func TestCatalogHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	errmap.CatalogHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/errors", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body struct {
+		Errors []errmap.CatalogEntry `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, errmap.Catalog(), body.Errors)
+}
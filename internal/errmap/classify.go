@@ -0,0 +1,104 @@
+package errmap
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Classification is transport-independent error metadata that lets clients
+// implement one retry policy across gRPC, HTTP, and WebSocket.
+type Classification struct {
+	// Code is a stable, machine-readable identifier for the error. Unlike the
+	// HTTP code, it is unique per domain error and never changes once published.
+	Code string
+	// Retryable reports whether the same request may succeed if retried.
+	Retryable bool
+	// RetryAfter is the suggested minimum delay before retrying. Zero means
+	// no suggestion (use client backoff) or not retryable.
+	RetryAfter time.Duration
+	// ErrorCode is the error's number in the domain error catalog.
+	ErrorCode domain.ErrorCode
+}
+
+// errorDomain identifies this platform in gRPC ErrorInfo details.
+const errorDomain = "messaging-platform"
+
+// classification defines a domain error to stable code mapping.
+type classification struct {
+	err        error
+	code       string
+	retryAfter time.Duration
+}
+
+// classifications maps domain errors to stable codes and retry hints.
+// Order matters: first match wins (via errors.Is). Retryability is derived
+// from domain.IsRetryable so the two can never disagree.
+var classifications = []classification{
+	// Resource errors
+	{domain.ErrNotFound, "NOT_FOUND", 0},
+	{domain.ErrAlreadyExists, "ALREADY_EXISTS", 0},
+	{domain.ErrVersionConflict, "VERSION_CONFLICT", 0},
+	{domain.ErrDuplicateMessage, "DUPLICATE_MESSAGE", 0},
+
+	// Auth errors (ADR-015)
+	{domain.ErrUnauthorized, "UNAUTHENTICATED", 0},
+	{domain.ErrInvalidOTP, "INVALID_OTP", 0},
+	{domain.ErrOTPExpired, "OTP_EXPIRED", 0},
+	{domain.ErrDeviceMismatch, "DEVICE_MISMATCH", 0},
+	{domain.ErrInvalidRefreshToken, "INVALID_REFRESH_TOKEN", 0},
+	{domain.ErrRefreshTokenReuse, "REFRESH_TOKEN_REUSE", 0},
+	{domain.ErrSessionExpired, "SESSION_EXPIRED", 0},
+	{domain.ErrSessionRevoked, "SESSION_REVOKED", 0},
+
+	// Permission errors
+	{domain.ErrForbidden, "PERMISSION_DENIED", 0},
+	{domain.ErrNotMember, "NOT_MEMBER", 0},
+
+	// Validation errors
+	{domain.ErrInvalidInput, "INVALID_ARGUMENT", 0},
+	{domain.ErrMessageTooLarge, "MESSAGE_TOO_LARGE", 0},
+	{domain.ErrInvalidContentType, "INVALID_CONTENT_TYPE", 0},
+	{domain.ErrEmptyID, "EMPTY_ID", 0},
+	{domain.ErrInvalidID, "INVALID_ID", 0},
+	{domain.ErrInvalidPhoneNumber, "INVALID_PHONE_NUMBER", 0},
+
+	// Rate limiting — OTP limits share the RequestOTP retry-after (ADR-013)
+	{domain.ErrRateLimited, "RATE_LIMITED", time.Second},
+	{domain.ErrPhoneRateLimited, "PHONE_RATE_LIMITED", time.Minute},
+	{domain.ErrIPRateLimited, "IP_RATE_LIMITED", time.Minute},
+	{domain.ErrMaxSessionsExceeded, "MAX_SESSIONS_EXCEEDED", 0},
+	{domain.ErrUserConnectionLimit, "USER_CONNECTION_LIMIT", 0},
+	{domain.ErrDeviceConnectionLimit, "DEVICE_CONNECTION_LIMIT", 0},
+	{domain.ErrIPConnectionLimit, "IP_CONNECTION_LIMIT", 0},
+	{domain.ErrSlowConsumer, "SLOW_CONSUMER", 0},
+	// Slow mode's wait is per error; see Classify.
+	{domain.ErrSlowMode, "SLOW_MODE", time.Second},
+
+	// Availability
+	{domain.ErrUnavailable, "UNAVAILABLE", time.Second},
+}
+
+// Classify returns the stable code and retry metadata for err.
+// Unknown errors classify as INTERNAL and permanent; nil returns the zero value.
+func Classify(err error) Classification {
+	if err == nil {
+		return Classification{}
+	}
+	for _, c := range classifications {
+		if errors.Is(err, c.err) {
+			retryable := domain.IsRetryable(err)
+			var retryAfter time.Duration
+			if retryable {
+				retryAfter = c.retryAfter
+				if remaining, ok := domain.SlowModeRemaining(err); ok {
+					retryAfter = remaining
+				}
+			}
+			def, _ := domain.LookupError(err)
+			return Classification{Code: c.code, Retryable: retryable, RetryAfter: retryAfter, ErrorCode: def.Code}
+		}
+	}
+	return Classification{Code: "INTERNAL", ErrorCode: domain.ErrorCodeInternal}
+}
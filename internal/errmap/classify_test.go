@@ -0,0 +1,102 @@
+package errmap_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantCode       string
+		wantRetryable  bool
+		wantRetryAfter time.Duration
+	}{
+		{"nil error", nil, "", false, 0},
+		{"ErrNotFound", domain.ErrNotFound, "NOT_FOUND", false, 0},
+		{"ErrMessageTooLarge", domain.ErrMessageTooLarge, "MESSAGE_TOO_LARGE", false, 0},
+		{"ErrInvalidOTP", domain.ErrInvalidOTP, "INVALID_OTP", false, 0},
+		{"ErrRateLimited", domain.ErrRateLimited, "RATE_LIMITED", true, time.Second},
+		{"ErrPhoneRateLimited", domain.ErrPhoneRateLimited, "PHONE_RATE_LIMITED", true, time.Minute},
+		{"ErrIPRateLimited", domain.ErrIPRateLimited, "IP_RATE_LIMITED", true, time.Minute},
+		{"ErrMaxSessionsExceeded", domain.ErrMaxSessionsExceeded, "MAX_SESSIONS_EXCEEDED", true, 0},
+		{"ErrIPConnectionLimit", domain.ErrIPConnectionLimit, "IP_CONNECTION_LIMIT", true, 0},
+		{"ErrSlowConsumer", domain.ErrSlowConsumer, "SLOW_CONSUMER", false, 0},
+		{"ErrSlowMode", domain.ErrSlowMode, "SLOW_MODE", true, time.Second},
+		{"SlowModeError", fmt.Errorf("send: %w", &domain.SlowModeError{Remaining: 12 * time.Second}), "SLOW_MODE", true, 12 * time.Second},
+		{"ErrUnavailable", domain.ErrUnavailable, "UNAVAILABLE", true, time.Second},
+		{"joined ErrUnavailable", errors.Join(errors.New("redis: timeout"), domain.ErrUnavailable), "UNAVAILABLE", true, time.Second},
+		{"wrapped ErrNotFound", fmt.Errorf("chat: %w", domain.ErrNotFound), "NOT_FOUND", false, 0},
+		{"unknown error", errors.New("unexpected"), "INTERNAL", false, 0},
+		{"ErrConfigRequired", domain.ErrConfigRequired, "INTERNAL", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errmap.Classify(tt.err)
+
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantRetryable, got.Retryable)
+			assert.Equal(t, tt.wantRetryAfter, got.RetryAfter)
+		})
+	}
+}
+
+// TestClassifyCompleteness ensures every client-facing domain error has a
+// unique stable code and that retryability agrees with domain.IsRetryable.
+func TestClassifyCompleteness(t *testing.T) {
+	domainErrors := []error{
+		domain.ErrEmptyID,
+		domain.ErrInvalidID,
+		domain.ErrNotFound,
+		domain.ErrAlreadyExists,
+		domain.ErrVersionConflict,
+		domain.ErrUnauthorized,
+		domain.ErrForbidden,
+		domain.ErrNotMember,
+		domain.ErrInvalidInput,
+		domain.ErrMessageTooLarge,
+		domain.ErrInvalidContentType,
+		domain.ErrRateLimited,
+		domain.ErrUnavailable,
+		domain.ErrSlowConsumer,
+		domain.ErrSlowMode,
+		domain.ErrDuplicateMessage,
+		domain.ErrInvalidOTP,
+		domain.ErrOTPExpired,
+		domain.ErrDeviceMismatch,
+		domain.ErrInvalidRefreshToken,
+		domain.ErrRefreshTokenReuse,
+		domain.ErrSessionExpired,
+		domain.ErrSessionRevoked,
+		domain.ErrMaxSessionsExceeded,
+		domain.ErrPhoneRateLimited,
+		domain.ErrIPRateLimited,
+		domain.ErrUserConnectionLimit,
+		domain.ErrDeviceConnectionLimit,
+		domain.ErrIPConnectionLimit,
+		domain.ErrInvalidPhoneNumber,
+	}
+
+	seen := make(map[string]error)
+	for _, err := range domainErrors {
+		t.Run(err.Error(), func(t *testing.T) {
+			got := errmap.Classify(err)
+
+			assert.NotEqual(t, "INTERNAL", got.Code, "domain error %q should have an explicit classification", err)
+			assert.Equal(t, domain.IsRetryable(err), got.Retryable)
+			if prev, dup := seen[got.Code]; dup {
+				t.Errorf("code %q is shared by %q and %q", got.Code, prev, err)
+			}
+			seen[got.Code] = err
+		})
+	}
+}
@@ -4,25 +4,34 @@ package errmap
 
 import (
 	"errors"
+	"strconv"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 )
 
+// grpcMapping defines a domain error to gRPC status code mapping.
+type grpcMapping struct {
+	err  error
+	code codes.Code
+}
+
 // grpcMappings maps domain errors to gRPC status codes.
 // Order matters: first match wins (via errors.Is).
 //
 // Mapping follows gRPC status codes reference:
 // https://grpc.github.io/grpc/core/md_doc_statuscodes.html
-var grpcMappings = []struct {
-	err  error
-	code codes.Code
-}{
+var grpcMappings = []grpcMapping{
 	// Resource errors
 	{domain.ErrNotFound, codes.NotFound},
 	{domain.ErrAlreadyExists, codes.AlreadyExists},
+	{domain.ErrVersionConflict, codes.Aborted},
 	{domain.ErrDuplicateMessage, codes.AlreadyExists},
 
 	// Auth errors (ADR-015) — Unauthenticated
@@ -52,6 +61,10 @@ var grpcMappings = []struct {
 	{domain.ErrPhoneRateLimited, codes.ResourceExhausted},
 	{domain.ErrIPRateLimited, codes.ResourceExhausted},
 	{domain.ErrMaxSessionsExceeded, codes.ResourceExhausted},
+	{domain.ErrUserConnectionLimit, codes.ResourceExhausted},
+	{domain.ErrDeviceConnectionLimit, codes.ResourceExhausted},
+	{domain.ErrIPConnectionLimit, codes.ResourceExhausted},
+	{domain.ErrSlowMode, codes.ResourceExhausted},
 	{domain.ErrSlowConsumer, codes.ResourceExhausted},
 
 	// Availability
@@ -59,18 +72,61 @@ var grpcMappings = []struct {
 }
 
 // ToGRPCStatus converts a domain error to a gRPC status.
-// The returned status can be sent directly to gRPC clients.
+// The returned status can be sent directly to gRPC clients. It carries an
+// ErrorInfo detail with the Classify code and retryability, plus a RetryInfo
+// detail when a retry delay is suggested.
 func ToGRPCStatus(err error) *status.Status {
 	if err == nil {
 		return status.New(codes.OK, "")
 	}
+	st := status.New(codes.Internal, "internal error") // Never expose internal error details to clients
 	for _, m := range grpcMappings {
 		if errors.Is(err, m.err) {
-			return status.New(m.code, err.Error())
+			st = status.New(m.code, err.Error())
+			break
+		}
+	}
+	return withDetails(st, err)
+}
+
+// withDetails attaches Classification metadata, field violations for
+// domain.ValidationError, and the current version for
+// domain.VersionConflictError, as status details. If the details cannot be
+// marshaled the bare status is returned.
+func withDetails(st *status.Status, err error) *status.Status {
+	c := Classify(err)
+	info := &errdetails.ErrorInfo{
+		Reason: c.Code,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"retryable":  strconv.FormatBool(c.Retryable),
+			"error_code": strconv.Itoa(int(c.ErrorCode)),
+		},
+	}
+	var vc *domain.VersionConflictError
+	if errors.As(err, &vc) {
+		info.Metadata["current_version"] = strconv.FormatInt(vc.Current, 10)
+	}
+	details := []protoadapt.MessageV1{info}
+	if c.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(c.RetryAfter)})
+	}
+	var ve *domain.ValidationError
+	if errors.As(err, &ve) {
+		br := &errdetails.BadRequest{}
+		for _, v := range ve.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
 		}
+		details = append(details, br)
+	}
+	detailed, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return st
 	}
-	// Never expose internal error details to clients
-	return status.New(codes.Internal, "internal error")
+	return detailed
 }
 
 // ToGRPCError converts a domain error to a gRPC error (implements error interface).
@@ -78,6 +134,65 @@ func ToGRPCError(err error) error {
 	return ToGRPCStatus(err).Err()
 }
 
+// FromGRPCStatus converts an error returned by another platform service
+// back into the domain error ToGRPCStatus encoded, so a caller can handle
+// and re-surface it like its own. The ErrorInfo reason selects the domain
+// error; field violations come back as a domain.ValidationError and a slow
+// mode wait as a domain.SlowModeError. A status without platform ErrorInfo
+// maps Unavailable and DeadlineExceeded to domain.ErrUnavailable and is
+// otherwise returned unchanged, as is a non-status error.
+func FromGRPCStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return err
+	}
+	var (
+		reason     string
+		retryAfter time.Duration
+		violations []domain.FieldViolation
+	)
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() == errorDomain {
+				reason = d.GetReason()
+			}
+		case *errdetails.RetryInfo:
+			retryAfter = d.GetRetryDelay().AsDuration()
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				violations = append(violations, domain.FieldViolation{Field: v.GetField(), Description: v.GetDescription()})
+			}
+		}
+	}
+
+	switch {
+	case len(violations) > 0:
+		return &domain.ValidationError{Violations: violations}
+	case reason == "SLOW_MODE" && retryAfter > 0:
+		return &domain.SlowModeError{Remaining: retryAfter}
+	}
+	for _, c := range classifications {
+		if c.code == reason {
+			return &remoteError{message: st.Message(), err: c.err}
+		}
+	}
+	if st.Code() == codes.Unavailable || st.Code() == codes.DeadlineExceeded {
+		return &remoteError{message: st.Message(), err: domain.ErrUnavailable}
+	}
+	return err
+}
+
+// remoteError is a domain error reported by another service. It keeps the
+// remote message and matches the domain sentinel.
+type remoteError struct {
+	message string
+	err     error
+}
+
+func (e *remoteError) Error() string { return e.message }
+func (e *remoteError) Unwrap() error { return e.err }
+
 // FromGRPCError extracts the gRPC status code from an error.
 // Returns codes.Unknown if the error is not a gRPC status error.
 func FromGRPCError(err error) codes.Code {
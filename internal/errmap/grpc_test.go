@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
@@ -24,6 +28,7 @@ func TestToGRPCStatus(t *testing.T) {
 		// Resource errors
 		{"ErrNotFound", domain.ErrNotFound, codes.NotFound},
 		{"ErrAlreadyExists", domain.ErrAlreadyExists, codes.AlreadyExists},
+		{"ErrVersionConflict", domain.ErrVersionConflict, codes.Aborted},
 
 		// Authorization errors
 		{"ErrUnauthorized", domain.ErrUnauthorized, codes.Unauthenticated},
@@ -49,10 +54,12 @@ func TestToGRPCStatus(t *testing.T) {
 		{"ErrPhoneRateLimited", domain.ErrPhoneRateLimited, codes.ResourceExhausted},
 		{"ErrIPRateLimited", domain.ErrIPRateLimited, codes.ResourceExhausted},
 		{"ErrMaxSessionsExceeded", domain.ErrMaxSessionsExceeded, codes.ResourceExhausted},
+		{"ErrUserConnectionLimit", domain.ErrUserConnectionLimit, codes.ResourceExhausted},
 
 		// Operational errors
 		{"ErrRateLimited", domain.ErrRateLimited, codes.ResourceExhausted},
 		{"ErrSlowConsumer", domain.ErrSlowConsumer, codes.ResourceExhausted},
+		{"ErrSlowMode", domain.ErrSlowMode, codes.ResourceExhausted},
 		{"ErrUnavailable", domain.ErrUnavailable, codes.Unavailable},
 
 		// Idempotency
@@ -107,6 +114,53 @@ func TestFromGRPCError(t *testing.T) {
 	})
 }
 
+func TestFromGRPCStatus(t *testing.T) {
+	t.Run("round-trips domain errors", func(t *testing.T) {
+		for _, tt := range []struct{ err, want error }{
+			{domain.ErrNotMember, domain.ErrNotMember},
+			{domain.ErrMessageTooLarge, domain.ErrMessageTooLarge},
+			{fmt.Errorf("persist: %w", domain.ErrUnavailable), domain.ErrUnavailable},
+			{domain.ErrRateLimited, domain.ErrRateLimited},
+		} {
+			got := errmap.FromGRPCStatus(errmap.ToGRPCError(tt.err))
+
+			assert.ErrorIs(t, got, tt.want)
+			assert.Equal(t, tt.err.Error(), got.Error())
+		}
+	})
+
+	t.Run("restores field violations", func(t *testing.T) {
+		got := errmap.FromGRPCStatus(errmap.ToGRPCError(domain.NewValidationError("chat_id", "is required")))
+
+		var ve *domain.ValidationError
+		require.ErrorAs(t, got, &ve)
+		assert.Equal(t, []domain.FieldViolation{{Field: "chat_id", Description: "is required"}}, ve.Violations)
+	})
+
+	t.Run("restores the slow mode wait", func(t *testing.T) {
+		got := errmap.FromGRPCStatus(errmap.ToGRPCError(&domain.SlowModeError{Remaining: 7 * time.Second}))
+
+		remaining, ok := domain.SlowModeRemaining(got)
+		require.True(t, ok)
+		assert.Equal(t, 7*time.Second, remaining)
+	})
+
+	t.Run("maps transport unavailability", func(t *testing.T) {
+		got := errmap.FromGRPCStatus(status.Error(codes.Unavailable, "connection refused"))
+
+		assert.ErrorIs(t, got, domain.ErrUnavailable)
+	})
+
+	t.Run("passes other errors through", func(t *testing.T) {
+		plain := errors.New("boom")
+		foreign := status.Error(codes.Internal, "internal error")
+
+		assert.Equal(t, plain, errmap.FromGRPCStatus(plain))
+		assert.Equal(t, foreign, errmap.FromGRPCStatus(foreign))
+		assert.NoError(t, errmap.FromGRPCStatus(nil))
+	})
+}
+
 // TestGRPCMappingCompleteness ensures every domain error has an explicit mapping.
 // This test will fail if a new domain error is added without updating the mapper.
 func TestGRPCMappingCompleteness(t *testing.T) {
@@ -116,6 +170,7 @@ func TestGRPCMappingCompleteness(t *testing.T) {
 		domain.ErrInvalidID,
 		domain.ErrNotFound,
 		domain.ErrAlreadyExists,
+		domain.ErrVersionConflict,
 		domain.ErrUnauthorized,
 		domain.ErrForbidden,
 		domain.ErrNotMember,
@@ -125,8 +180,10 @@ func TestGRPCMappingCompleteness(t *testing.T) {
 		domain.ErrRateLimited,
 		domain.ErrUnavailable,
 		domain.ErrSlowConsumer,
+		domain.ErrSlowMode,
 		domain.ErrDuplicateMessage,
 		domain.ErrConfigRequired,
+		domain.ErrConfigInvalid,
 		// Auth errors (ADR-015)
 		domain.ErrInvalidOTP,
 		domain.ErrOTPExpired,
@@ -138,18 +195,95 @@ func TestGRPCMappingCompleteness(t *testing.T) {
 		domain.ErrMaxSessionsExceeded,
 		domain.ErrPhoneRateLimited,
 		domain.ErrIPRateLimited,
+		domain.ErrUserConnectionLimit,
+		domain.ErrDeviceConnectionLimit,
+		domain.ErrIPConnectionLimit,
 		domain.ErrInvalidPhoneNumber,
 	}
 
 	for _, err := range domainErrors {
 		t.Run(err.Error(), func(t *testing.T) {
 			status := errmap.ToGRPCStatus(err)
-			// ErrConfigRequired is internal, so it maps to Internal
+			// Configuration errors are internal, so they map to Internal
 			// All others should NOT map to Internal (they should have explicit mappings)
-			if !errors.Is(err, domain.ErrConfigRequired) {
+			if !errors.Is(err, domain.ErrConfigRequired) && !errors.Is(err, domain.ErrConfigInvalid) {
 				assert.NotEqual(t, codes.Internal, status.Code(),
 					"domain error %q should have explicit gRPC mapping, not Internal", err.Error())
 			}
 		})
 	}
 }
+
+func TestToGRPCStatus_ClassificationDetails(t *testing.T) {
+	t.Run("retryable error carries ErrorInfo and RetryInfo", func(t *testing.T) {
+		st := errmap.ToGRPCStatus(domain.ErrPhoneRateLimited)
+
+		var info *errdetails.ErrorInfo
+		var retry *errdetails.RetryInfo
+		for _, d := range st.Details() {
+			switch v := d.(type) {
+			case *errdetails.ErrorInfo:
+				info = v
+			case *errdetails.RetryInfo:
+				retry = v
+			}
+		}
+		require.NotNil(t, info)
+		assert.Equal(t, "PHONE_RATE_LIMITED", info.GetReason())
+		assert.Equal(t, "messaging-platform", info.GetDomain())
+		assert.Equal(t, "true", info.GetMetadata()["retryable"])
+		require.NotNil(t, retry)
+		assert.Equal(t, time.Minute, retry.GetRetryDelay().AsDuration())
+	})
+
+	t.Run("permanent error carries ErrorInfo only", func(t *testing.T) {
+		st := errmap.ToGRPCStatus(domain.ErrInvalidOTP)
+
+		details := st.Details()
+		require.Len(t, details, 1)
+		info, ok := details[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, "INVALID_OTP", info.GetReason())
+		assert.Equal(t, "false", info.GetMetadata()["retryable"])
+	})
+
+	t.Run("internal error carries INTERNAL reason without leaking message", func(t *testing.T) {
+		st := errmap.ToGRPCStatus(errors.New("dynamo: connection reset"))
+
+		assert.Equal(t, codes.Internal, st.Code())
+		assert.Equal(t, "internal error", st.Message())
+		require.Len(t, st.Details(), 1)
+		assert.Equal(t, "INTERNAL", st.Details()[0].(*errdetails.ErrorInfo).GetReason())
+	})
+
+	t.Run("nil error has no details", func(t *testing.T) {
+		assert.Empty(t, errmap.ToGRPCStatus(nil).Details())
+	})
+}
+
+func TestToGRPCStatus_FieldViolations(t *testing.T) {
+	st := errmap.ToGRPCStatus(domain.NewValidationError("device_id", "is required"))
+
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	var br *errdetails.BadRequest
+	for _, d := range st.Details() {
+		if v, ok := d.(*errdetails.BadRequest); ok {
+			br = v
+		}
+	}
+	require.NotNil(t, br)
+	require.Len(t, br.GetFieldViolations(), 1)
+	assert.Equal(t, "device_id", br.GetFieldViolations()[0].GetField())
+	assert.Equal(t, "is required", br.GetFieldViolations()[0].GetDescription())
+}
+
+func TestToGRPCStatus_VersionConflict(t *testing.T) {
+	st := errmap.ToGRPCStatus(fmt.Errorf("update chat settings: %w", domain.NewVersionConflictError(3, 5)))
+
+	assert.Equal(t, codes.Aborted, st.Code())
+	require.NotEmpty(t, st.Details())
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, "VERSION_CONFLICT", info.GetReason())
+	assert.Equal(t, "5", info.GetMetadata()["current_version"])
+}
@@ -3,15 +3,22 @@ package errmap
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 )
 
 // HTTPError represents an HTTP error response.
+// Reason, Retryable, RetryAfterSeconds, and ErrorCode carry the Classify
+// metadata.
 type HTTPError struct {
-	StatusCode int    `json:"-"`
-	Code       string `json:"code"`
-	Message    string `json:"message"`
+	StatusCode        int    `json:"-"`
+	Code              string `json:"code"`
+	Message           string `json:"message"`
+	Reason            string `json:"reason,omitempty"`
+	Retryable         bool   `json:"retryable"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+	ErrorCode         int    `json:"error_code"`
 }
 
 func (e HTTPError) Error() string {
@@ -34,6 +41,7 @@ var httpMappings = []httpMapping{
 	// Resource errors
 	{domain.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
 	{domain.ErrAlreadyExists, http.StatusConflict, "ALREADY_EXISTS"},
+	{domain.ErrVersionConflict, http.StatusConflict, "VERSION_CONFLICT"},
 	{domain.ErrDuplicateMessage, http.StatusOK, "DUPLICATE"},
 
 	// Auth errors — 401 (ADR-015)
@@ -63,6 +71,10 @@ var httpMappings = []httpMapping{
 	{domain.ErrPhoneRateLimited, http.StatusTooManyRequests, "PHONE_RATE_LIMITED"},
 	{domain.ErrIPRateLimited, http.StatusTooManyRequests, "IP_RATE_LIMITED"},
 	{domain.ErrMaxSessionsExceeded, http.StatusTooManyRequests, "MAX_SESSIONS_EXCEEDED"},
+	{domain.ErrUserConnectionLimit, http.StatusTooManyRequests, "USER_CONNECTION_LIMIT"},
+	{domain.ErrDeviceConnectionLimit, http.StatusTooManyRequests, "DEVICE_CONNECTION_LIMIT"},
+	{domain.ErrIPConnectionLimit, http.StatusTooManyRequests, "IP_CONNECTION_LIMIT"},
+	{domain.ErrSlowMode, http.StatusTooManyRequests, "SLOW_MODE"},
 	{domain.ErrSlowConsumer, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED"},
 
 	// Availability
@@ -74,13 +86,30 @@ func ToHTTPError(err error) HTTPError {
 	if err == nil {
 		return HTTPError{StatusCode: http.StatusOK}
 	}
+	// Never expose internal error details to clients
+	he := HTTPError{StatusCode: http.StatusInternalServerError, Code: "INTERNAL", Message: "internal error"}
 	for _, m := range httpMappings {
 		if errors.Is(err, m.err) {
-			return HTTPError{StatusCode: m.statusCode, Code: m.code, Message: err.Error()}
+			he = HTTPError{StatusCode: m.statusCode, Code: m.code, Message: err.Error()}
+			break
 		}
 	}
-	// Never expose internal error details to clients
-	return HTTPError{StatusCode: http.StatusInternalServerError, Code: "INTERNAL", Message: "internal error"}
+
+	c := Classify(err)
+	he.Reason = c.Code
+	he.Retryable = c.Retryable
+	he.RetryAfterSeconds = retryAfterSeconds(c.RetryAfter)
+	he.ErrorCode = int(c.ErrorCode)
+	return he
+}
+
+// retryAfterSeconds rounds a retry delay up to whole seconds, matching the
+// granularity of the Retry-After header (RFC 9110 §10.2.3).
+func retryAfterSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
 }
 
 // ToHTTPStatusCode extracts just the HTTP status code for a domain error.
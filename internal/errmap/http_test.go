@@ -24,6 +24,7 @@ func TestToHTTPError(t *testing.T) {
 		// Resource errors
 		{"ErrNotFound", domain.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
 		{"ErrAlreadyExists", domain.ErrAlreadyExists, http.StatusConflict, "ALREADY_EXISTS"},
+		{"ErrVersionConflict", domain.ErrVersionConflict, http.StatusConflict, "VERSION_CONFLICT"},
 
 		// Authorization errors
 		{"ErrUnauthorized", domain.ErrUnauthorized, http.StatusUnauthorized, "UNAUTHENTICATED"},
@@ -49,10 +50,12 @@ func TestToHTTPError(t *testing.T) {
 		{"ErrPhoneRateLimited", domain.ErrPhoneRateLimited, http.StatusTooManyRequests, "PHONE_RATE_LIMITED"},
 		{"ErrIPRateLimited", domain.ErrIPRateLimited, http.StatusTooManyRequests, "IP_RATE_LIMITED"},
 		{"ErrMaxSessionsExceeded", domain.ErrMaxSessionsExceeded, http.StatusTooManyRequests, "MAX_SESSIONS_EXCEEDED"},
+		{"ErrDeviceConnectionLimit", domain.ErrDeviceConnectionLimit, http.StatusTooManyRequests, "DEVICE_CONNECTION_LIMIT"},
 
 		// Operational errors
 		{"ErrRateLimited", domain.ErrRateLimited, http.StatusTooManyRequests, "RATE_LIMITED"},
 		{"ErrSlowConsumer", domain.ErrSlowConsumer, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED"},
+		{"ErrSlowMode", domain.ErrSlowMode, http.StatusTooManyRequests, "SLOW_MODE"},
 		{"ErrUnavailable", domain.ErrUnavailable, http.StatusServiceUnavailable, "UNAVAILABLE"},
 
 		// Idempotency - returns OK (not error)
@@ -140,3 +143,28 @@ func TestHTTPMappingMatchesGRPCGateway(t *testing.T) {
 		})
 	}
 }
+
+func TestToHTTPError_Classification(t *testing.T) {
+	tests := []struct {
+		name                  string
+		err                   error
+		wantReason            string
+		wantRetryable         bool
+		wantRetryAfterSeconds int
+	}{
+		{"permanent error", domain.ErrMessageTooLarge, "MESSAGE_TOO_LARGE", false, 0},
+		{"retryable with delay", domain.ErrIPRateLimited, "IP_RATE_LIMITED", true, 60},
+		{"retryable without delay", domain.ErrMaxSessionsExceeded, "MAX_SESSIONS_EXCEEDED", true, 0},
+		{"internal error", fmt.Errorf("unexpected"), "INTERNAL", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errmap.ToHTTPError(tt.err)
+
+			assert.Equal(t, tt.wantReason, got.Reason)
+			assert.Equal(t, tt.wantRetryable, got.Retryable)
+			assert.Equal(t, tt.wantRetryAfterSeconds, got.RetryAfterSeconds)
+		})
+	}
+}
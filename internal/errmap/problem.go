@@ -0,0 +1,150 @@
+package errmap
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// ProblemContentType is the media type for RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix namespaces problem type URIs. A URN avoids implying a
+// dereferenceable documentation URL that does not exist.
+const problemTypePrefix = "urn:messaging-platform:problem:"
+
+// Problem is an RFC 7807 problem details object. Code, ErrorCode, Retryable,
+// RetryAfterSeconds, CurrentVersion, TraceID, and Errors are extension
+// members.
+type Problem struct {
+	Type              string              `json:"type"`
+	Title             string              `json:"title"`
+	Status            int                 `json:"status"`
+	Detail            string              `json:"detail,omitempty"`
+	Instance          string              `json:"instance,omitempty"`
+	Code              string              `json:"code"`
+	ErrorCode         int                 `json:"error_code,omitempty"`
+	Retryable         bool                `json:"retryable"`
+	RetryAfterSeconds int                 `json:"retry_after_seconds,omitempty"`
+	CurrentVersion    int64               `json:"current_version,omitempty"` // Set on VERSION_CONFLICT
+	TraceID           string              `json:"trace_id,omitempty"`
+	Errors            []ProblemFieldError `json:"errors,omitempty"`
+}
+
+// ProblemFieldError is a field-level validation failure.
+type ProblemFieldError struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// problemFamily groups errors that share a type URI and title. Families are
+// keyed by gRPC code so the domain path and the grpc-gateway path agree.
+type problemFamily struct {
+	name  string
+	title string
+	code  string // fallback Code when the status carries no ErrorInfo
+}
+
+var problemFamilies = map[codes.Code]problemFamily{
+	codes.InvalidArgument:   {"validation", "Invalid request", "INVALID_ARGUMENT"},
+	codes.Unauthenticated:   {"authentication", "Authentication required", "UNAUTHENTICATED"},
+	codes.PermissionDenied:  {"authorization", "Permission denied", "PERMISSION_DENIED"},
+	codes.NotFound:          {"not-found", "Resource not found", "NOT_FOUND"},
+	codes.AlreadyExists:     {"conflict", "Resource conflict", "ALREADY_EXISTS"},
+	codes.Aborted:           {"conflict", "Resource conflict", "ABORTED"},
+	codes.ResourceExhausted: {"rate-limit", "Too many requests", "RESOURCE_EXHAUSTED"},
+	codes.Unavailable:       {"unavailable", "Service unavailable", "UNAVAILABLE"},
+	codes.Unimplemented:     {"not-implemented", "Not implemented", "UNIMPLEMENTED"},
+}
+
+var internalFamily = problemFamily{"internal", "Internal error", "INTERNAL"}
+
+// ToProblem converts a domain error to problem details. The trace ID is taken
+// from the active span in ctx.
+func ToProblem(ctx context.Context, err error) Problem {
+	return problemFromStatus(ctx, ToGRPCStatus(err), ToHTTPStatusCode(err))
+}
+
+// problemFromStatus builds problem details from a gRPC status produced by
+// ToGRPCStatus, reading Classify metadata and field violations from its details.
+func problemFromStatus(ctx context.Context, st *status.Status, httpStatus int) Problem {
+	family, ok := problemFamilies[st.Code()]
+	if !ok {
+		family = internalFamily
+	}
+
+	p := Problem{
+		Type:    problemTypePrefix + family.name,
+		Title:   family.title,
+		Status:  httpStatus,
+		Detail:  st.Message(),
+		Code:    family.code,
+		TraceID: observability.TraceIDFromContext(ctx),
+	}
+	if family == internalFamily {
+		p.Detail = "internal error" // Never expose internal error details to clients
+	}
+
+	for _, d := range st.Details() {
+		switch v := d.(type) {
+		case *errdetails.ErrorInfo:
+			p.Code = v.GetReason()
+			p.Retryable = v.GetMetadata()["retryable"] == "true"
+			p.ErrorCode, _ = strconv.Atoi(v.GetMetadata()["error_code"])
+			p.CurrentVersion, _ = strconv.ParseInt(v.GetMetadata()["current_version"], 10, 64)
+		case *errdetails.RetryInfo:
+			p.RetryAfterSeconds = retryAfterSeconds(v.GetRetryDelay().AsDuration())
+		case *errdetails.BadRequest:
+			for _, fv := range v.GetFieldViolations() {
+				p.Errors = append(p.Errors, ProblemFieldError{Field: fv.GetField(), Detail: fv.GetDescription()})
+			}
+		}
+	}
+	return p
+}
+
+// WriteProblem renders err as application/problem+json. Use from HTTP
+// handlers that are not served through grpc-gateway.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := ToProblem(r.Context(), err)
+	p.Instance = r.URL.Path
+	writeProblem(w, p)
+}
+
+// WriteProblemRetryAfter is WriteProblem with a known wait, e.g. until a
+// rate limit window resets, in place of the error class's default.
+func WriteProblemRetryAfter(w http.ResponseWriter, r *http.Request, err error, after time.Duration) {
+	p := ToProblem(r.Context(), err)
+	p.Instance = r.URL.Path
+	p.RetryAfterSeconds = retryAfterSeconds(after)
+	writeProblem(w, p)
+}
+
+// GatewayErrorHandler is a runtime.ErrorHandlerFunc that renders gRPC errors
+// from grpc-gateway handlers as problem+json. Register it with
+// runtime.WithErrorHandler.
+func GatewayErrorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	p := problemFromStatus(ctx, st, runtime.HTTPStatusFromCode(st.Code()))
+	p.Instance = r.URL.Path
+	writeProblem(w, p)
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	if p.RetryAfterSeconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(p.RetryAfterSeconds))
+	}
+	w.WriteHeader(p.Status)
+	// Headers are already sent; an encode failure can only be a broken connection.
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,194 @@
+package errmap_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+func TestToProblem(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantType   string
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{
+			name:       "validation family",
+			err:        domain.ErrMessageTooLarge,
+			wantType:   "urn:messaging-platform:problem:validation",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "MESSAGE_TOO_LARGE",
+			wantDetail: domain.ErrMessageTooLarge.Error(),
+		},
+		{
+			name:       "authentication family",
+			err:        domain.ErrSessionRevoked,
+			wantType:   "urn:messaging-platform:problem:authentication",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "SESSION_REVOKED",
+			wantDetail: domain.ErrSessionRevoked.Error(),
+		},
+		{
+			name:       "rate-limit family",
+			err:        domain.ErrPhoneRateLimited,
+			wantType:   "urn:messaging-platform:problem:rate-limit",
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   "PHONE_RATE_LIMITED",
+			wantDetail: domain.ErrPhoneRateLimited.Error(),
+		},
+		{
+			name:       "internal family hides detail",
+			err:        errors.New("dynamo: connection reset"),
+			wantType:   "urn:messaging-platform:problem:internal",
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "INTERNAL",
+			wantDetail: "internal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errmap.ToProblem(context.Background(), tt.err)
+
+			assert.Equal(t, tt.wantType, got.Type)
+			assert.NotEmpty(t, got.Title)
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantDetail, got.Detail)
+			assert.Empty(t, got.TraceID)
+		})
+	}
+}
+
+func TestToProblem_FieldViolations(t *testing.T) {
+	err := fmt.Errorf("verify otp: %w", &domain.ValidationError{Violations: []domain.FieldViolation{
+		{Field: "phone_number", Description: "is required"},
+		{Field: "device_id", Description: "is required"},
+	}})
+
+	got := errmap.ToProblem(context.Background(), err)
+
+	assert.Equal(t, "urn:messaging-platform:problem:validation", got.Type)
+	assert.Equal(t, "INVALID_ARGUMENT", got.Code)
+	assert.Equal(t, []errmap.ProblemFieldError{
+		{Field: "phone_number", Detail: "is required"},
+		{Field: "device_id", Detail: "is required"},
+	}, got.Errors)
+}
+
+func TestToProblem_VersionConflict(t *testing.T) {
+	err := fmt.Errorf("update chat settings: %w", domain.NewVersionConflictError(3, 5))
+
+	got := errmap.ToProblem(context.Background(), err)
+
+	assert.Equal(t, "urn:messaging-platform:problem:conflict", got.Type)
+	assert.Equal(t, http.StatusConflict, got.Status)
+	assert.Equal(t, "VERSION_CONFLICT", got.Code)
+	assert.Equal(t, int64(5), got.CurrentVersion)
+	assert.Equal(t, err.Error(), got.Detail)
+}
+
+func TestToProblem_TraceID(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	defer span.End()
+
+	got := errmap.ToProblem(ctx, domain.ErrNotFound)
+
+	assert.Equal(t, span.SpanContext().TraceID().String(), got.TraceID)
+}
+
+func TestWriteProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth/otp", nil)
+
+	errmap.WriteProblem(rec, req, domain.ErrIPRateLimited)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, errmap.ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "urn:messaging-platform:problem:rate-limit", body["type"])
+	assert.Equal(t, "/v1/auth/otp", body["instance"])
+	assert.Equal(t, "IP_RATE_LIMITED", body["code"])
+	assert.Equal(t, true, body["retryable"])
+	assert.EqualValues(t, 60, body["retry_after_seconds"])
+}
+
+func TestWriteProblemRetryAfter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/devices", nil)
+
+	errmap.WriteProblemRetryAfter(rec, req, domain.ErrRateLimited, 42*time.Second)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "42", rec.Header().Get("Retry-After"))
+	var got errmap.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "RATE_LIMITED", got.Code)
+	assert.Equal(t, 42, got.RetryAfterSeconds)
+	assert.Equal(t, "/v1/devices", got.Instance)
+}
+
+func TestGatewayErrorHandler(t *testing.T) {
+	t.Run("status from errmap keeps classification", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/refresh", nil)
+
+		errmap.GatewayErrorHandler(req.Context(), nil, &runtime.JSONPb{}, rec, req, errmap.ToGRPCError(domain.ErrRefreshTokenReuse))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, errmap.ProblemContentType, rec.Header().Get("Content-Type"))
+		var got errmap.Problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "REFRESH_TOKEN_REUSE", got.Code)
+		assert.Equal(t, "urn:messaging-platform:problem:authentication", got.Type)
+	})
+
+	t.Run("bare gateway status uses family fallback code", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/otp", nil)
+
+		errmap.GatewayErrorHandler(req.Context(), nil, &runtime.JSONPb{}, rec, req, status.Error(codes.InvalidArgument, "malformed body"))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var got errmap.Problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "INVALID_ARGUMENT", got.Code)
+		assert.Equal(t, "malformed body", got.Detail)
+	})
+
+	t.Run("unknown code is internal", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/x", nil)
+
+		errmap.GatewayErrorHandler(req.Context(), nil, &runtime.JSONPb{}, rec, req, errors.New("boom"))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		var got errmap.Problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "INTERNAL", got.Code)
+		assert.Equal(t, "internal error", got.Detail)
+	})
+}
@@ -0,0 +1,45 @@
+package errmap
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Keys set in protocol.Error Details by ToProtocolError.
+const (
+	DetailRetryable    = "retryable"
+	DetailRetryAfterMs = "retry_after_ms"
+	// DetailSecondsRemaining is set on SLOW_MODE errors: whole seconds
+	// until the sender may post again.
+	DetailSecondsRemaining = "seconds_remaining"
+)
+
+// ToProtocolError converts a domain error to a WebSocket error frame payload
+// for errors that do not close the connection (e.g. a rejected send_message).
+// Code is the Classify code and ErrorCode its catalog number; retry metadata
+// is carried in Details.
+func ToProtocolError(err error) protocol.Error {
+	if err == nil {
+		return protocol.Error{}
+	}
+	c := Classify(err)
+
+	message := "internal error" // Never expose internal error details to clients
+	if c.Code != "INTERNAL" {
+		message = err.Error()
+	}
+
+	details := map[string]string{DetailRetryable: strconv.FormatBool(c.Retryable)}
+	if c.RetryAfter > 0 {
+		details[DetailRetryAfterMs] = strconv.FormatInt(c.RetryAfter.Milliseconds(), 10)
+	}
+	var sm *domain.SlowModeError
+	if errors.As(err, &sm) {
+		details[DetailSecondsRemaining] = strconv.FormatInt(sm.SecondsRemaining(), 10)
+	}
+
+	return protocol.Error{Code: c.Code, ErrorCode: int(c.ErrorCode), Message: message, Details: details}
+}
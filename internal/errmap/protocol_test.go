@@ -0,0 +1,79 @@
+package errmap_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestToProtocolError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want protocol.Error
+	}{
+		{
+			name: "nil error",
+			err:  nil,
+			want: protocol.Error{},
+		},
+		{
+			name: "permanent error",
+			err:  domain.ErrMessageTooLarge,
+			want: protocol.Error{
+				Code:      "MESSAGE_TOO_LARGE",
+				ErrorCode: 1001,
+				Message:   domain.ErrMessageTooLarge.Error(),
+				Details:   map[string]string{errmap.DetailRetryable: "false"},
+			},
+		},
+		{
+			name: "retryable error with delay",
+			err:  domain.ErrRateLimited,
+			want: protocol.Error{
+				Code:      "RATE_LIMITED",
+				ErrorCode: 5000,
+				Message:   domain.ErrRateLimited.Error(),
+				Details:   map[string]string{errmap.DetailRetryable: "true", errmap.DetailRetryAfterMs: "1000"},
+			},
+		},
+		{
+			name: "slow mode carries seconds remaining",
+			err:  &domain.SlowModeError{Remaining: 4500 * time.Millisecond},
+			want: protocol.Error{
+				Code:      "SLOW_MODE",
+				ErrorCode: 5005,
+				Message:   "slow mode: wait before sending again (5s remaining)",
+				Details: map[string]string{
+					errmap.DetailRetryable:        "true",
+					errmap.DetailRetryAfterMs:     "4500",
+					errmap.DetailSecondsRemaining: "5",
+				},
+			},
+		},
+		{
+			name: "internal error hides details",
+			err:  errors.New("dynamo: connection reset"),
+			want: protocol.Error{
+				Code:      "INTERNAL",
+				ErrorCode: 9000,
+				Message:   "internal error",
+				Details:   map[string]string{errmap.DetailRetryable: "false"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errmap.ToProtocolError(tt.err)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package errmap
+
+import (
+	"errors"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Mapping is how an error surfaces on every transport.
+type Mapping struct {
+	GRPC codes.Code
+	// HTTPStatus and HTTPCode are what non-gateway HTTP handlers return;
+	// GatewayStatus is what grpc-gateway routes return for the gRPC code.
+	HTTPStatus    int
+	HTTPCode      string
+	GatewayStatus int
+	WebSocket     WebSocketClose
+	// Protocol is the protocol.Error frame code sent for errors that do not
+	// close the connection.
+	Protocol string
+	// ErrorCode is the error's number in the domain error catalog, carried
+	// on every transport.
+	ErrorCode domain.ErrorCode
+	Retryable bool
+}
+
+// Map returns the mapping of err on every transport.
+func Map(err error) Mapping {
+	st := ToGRPCStatus(err)
+	he := ToHTTPError(err)
+	return Mapping{
+		GRPC:          st.Code(),
+		HTTPStatus:    he.StatusCode,
+		HTTPCode:      he.Code,
+		GatewayStatus: runtime.HTTPStatusFromCode(st.Code()),
+		WebSocket:     ToWebSocketClose(err),
+		Protocol:      ToProtocolError(err).Code,
+		ErrorCode:     Classify(err).ErrorCode,
+		Retryable:     Classify(err).Retryable,
+	}
+}
+
+// Unmapped lists the transports on which err has no explicit mapping and
+// falls back to an internal error. It is empty for a fully mapped error.
+func Unmapped(err error) []string {
+	var missing []string
+	if !matchesAny(err, grpcMappings, func(m grpcMapping) error { return m.err }) {
+		missing = append(missing, "grpc")
+	}
+	if !matchesAny(err, httpMappings, func(m httpMapping) error { return m.err }) {
+		missing = append(missing, "http")
+	}
+	if !matchesAny(err, wsMappings, func(m wsMapping) error { return m.err }) {
+		missing = append(missing, "websocket")
+	}
+	if !matchesAny(err, classifications, func(c classification) error { return c.err }) {
+		missing = append(missing, "protocol")
+	}
+	return missing
+}
+
+func matchesAny[M any](err error, mappings []M, target func(M) error) bool {
+	for _, m := range mappings {
+		if errors.Is(err, target(m)) {
+			return true
+		}
+	}
+	return false
+}
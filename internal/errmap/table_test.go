@@ -0,0 +1,165 @@
+package errmap_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/transports.golden")
+
+// sentinels lists every domain sentinel error by name. A new error in
+// internal/domain/errors.go fails TestMap_CoversEveryDomainError until it
+// is added here and mapped on every transport.
+var sentinels = map[string]error{
+	"ErrEmptyID":               domain.ErrEmptyID,
+	"ErrInvalidID":             domain.ErrInvalidID,
+	"ErrNotFound":              domain.ErrNotFound,
+	"ErrAlreadyExists":         domain.ErrAlreadyExists,
+	"ErrVersionConflict":       domain.ErrVersionConflict,
+	"ErrUnauthorized":          domain.ErrUnauthorized,
+	"ErrForbidden":             domain.ErrForbidden,
+	"ErrNotMember":             domain.ErrNotMember,
+	"ErrInvalidInput":          domain.ErrInvalidInput,
+	"ErrMessageTooLarge":       domain.ErrMessageTooLarge,
+	"ErrInvalidContentType":    domain.ErrInvalidContentType,
+	"ErrRateLimited":           domain.ErrRateLimited,
+	"ErrUnavailable":           domain.ErrUnavailable,
+	"ErrSlowConsumer":          domain.ErrSlowConsumer,
+	"ErrSlowMode":              domain.ErrSlowMode,
+	"ErrDuplicateMessage":      domain.ErrDuplicateMessage,
+	"ErrInvalidOTP":            domain.ErrInvalidOTP,
+	"ErrOTPExpired":            domain.ErrOTPExpired,
+	"ErrDeviceMismatch":        domain.ErrDeviceMismatch,
+	"ErrInvalidRefreshToken":   domain.ErrInvalidRefreshToken,
+	"ErrRefreshTokenReuse":     domain.ErrRefreshTokenReuse,
+	"ErrSessionExpired":        domain.ErrSessionExpired,
+	"ErrSessionRevoked":        domain.ErrSessionRevoked,
+	"ErrMaxSessionsExceeded":   domain.ErrMaxSessionsExceeded,
+	"ErrPhoneRateLimited":      domain.ErrPhoneRateLimited,
+	"ErrIPRateLimited":         domain.ErrIPRateLimited,
+	"ErrUserConnectionLimit":   domain.ErrUserConnectionLimit,
+	"ErrDeviceConnectionLimit": domain.ErrDeviceConnectionLimit,
+	"ErrIPConnectionLimit":     domain.ErrIPConnectionLimit,
+	"ErrInvalidPhoneNumber":    domain.ErrInvalidPhoneNumber,
+	"ErrConfigRequired":        domain.ErrConfigRequired,
+	"ErrConfigInvalid":         domain.ErrConfigInvalid,
+}
+
+// internalOnly are domain errors that never reach a client and so map to
+// INTERNAL everywhere.
+var internalOnly = []string{"ErrConfigRequired", "ErrConfigInvalid"}
+
+// gatewayExceptions are errors whose direct HTTP status deliberately
+// differs from the one grpc-gateway derives from the gRPC code.
+var gatewayExceptions = map[string]string{
+	"ErrDuplicateMessage": "a deduplicated send succeeds over HTTP",
+}
+
+// domainSentinels parses internal/domain/errors.go for its exported Err*
+// variables, so the list cannot drift from the source.
+func domainSentinels(t *testing.T) []string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), filepath.Join("..", "domain", "errors.go"), nil, 0)
+	require.NoError(t, err)
+	var names []string
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if name.IsExported() && strings.HasPrefix(name.Name, "Err") {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func sortedSentinels() []string {
+	names := make([]string, 0, len(sentinels))
+	for name := range sentinels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestMap_CoversEveryDomainError(t *testing.T) {
+	require.Equal(t, domainSentinels(t), sortedSentinels(), "sentinels must list every error in internal/domain/errors.go")
+
+	for _, name := range sortedSentinels() {
+		unmapped := errmap.Unmapped(sentinels[name])
+		_, cataloged := domain.LookupError(sentinels[name])
+		if slices.Contains(internalOnly, name) {
+			assert.Len(t, unmapped, 4, "%s is internal-only but mapped on %v", name, unmapped)
+			assert.False(t, cataloged, "%s is internal-only but in the error catalog", name)
+			continue
+		}
+		assert.Empty(t, unmapped, "%s has no mapping on %v", name, unmapped)
+		assert.True(t, cataloged, "%s has no error catalog entry", name)
+	}
+}
+
+func TestMap_TransportsAgree(t *testing.T) {
+	for _, name := range sortedSentinels() {
+		err := fmt.Errorf("wrapped: %w", sentinels[name])
+		m := errmap.Map(err)
+
+		assert.Equal(t, errmap.Classify(err).Code, m.Protocol, "%s: protocol frames carry the Classify code", name)
+		assert.Equal(t, domain.IsRetryable(err), m.Retryable, name)
+		assert.Equal(t, m.ErrorCode, domain.ErrorCode(errmap.ToHTTPError(err).ErrorCode), "%s: HTTP carries the catalog code", name)
+		assert.Equal(t, m.ErrorCode, domain.ErrorCode(errmap.ToProtocolError(err).ErrorCode), "%s: protocol frames carry the catalog code", name)
+		if _, ok := gatewayExceptions[name]; !ok {
+			assert.Equal(t, m.GatewayStatus, m.HTTPStatus, "%s: direct HTTP and grpc-gateway statuses differ", name)
+		}
+		if domain.IsClientError(err) {
+			assert.GreaterOrEqual(t, m.HTTPStatus, http.StatusBadRequest, name)
+			assert.Less(t, m.HTTPStatus, http.StatusInternalServerError, "%s: client errors are 4xx", name)
+		}
+	}
+}
+
+// TestMap_Golden pins every domain error's mapping on every transport.
+// Rerun with -update after an intended change and review the diff.
+func TestMap_Golden(t *testing.T) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "error\tcode\tgrpc\thttp\tgateway\twebsocket\tprotocol\tretryable")
+	for _, name := range sortedSentinels() {
+		m := errmap.Map(sentinels[name])
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d %s\t%d\t%d %s\t%s\t%t\n",
+			name, m.ErrorCode, m.GRPC, m.HTTPStatus, m.HTTPCode, m.GatewayStatus,
+			m.WebSocket.Code, m.WebSocket.Reason, m.Protocol, m.Retryable)
+	}
+	require.NoError(t, w.Flush())
+
+	golden := filepath.Join("testdata", "transports.golden")
+	if *update {
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err, "run go test ./internal/errmap -run TestMap_Golden -update")
+	assert.Equal(t, string(want), buf.String())
+}
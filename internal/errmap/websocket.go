@@ -35,13 +35,16 @@ type WebSocketClose struct {
 	Reason string
 }
 
-// wsMappings maps domain errors to WebSocket close codes and reasons.
-// Order matters: first match wins (via errors.Is).
-var wsMappings = []struct {
+// wsMapping defines a domain error to WebSocket close code mapping.
+type wsMapping struct {
 	err    error
 	code   int
 	reason string
-}{
+}
+
+// wsMappings maps domain errors to WebSocket close codes and reasons.
+// Order matters: first match wins (via errors.Is).
+var wsMappings = []wsMapping{
 	// Auth errors — unauthorized (ADR-015)
 	{domain.ErrUnauthorized, CloseUnauthorized, "unauthorized"},
 	{domain.ErrInvalidOTP, CloseUnauthorized, "invalid_otp"},
@@ -59,6 +62,7 @@ var wsMappings = []struct {
 	// Resource errors
 	{domain.ErrNotFound, CloseNotFound, "not_found"},
 	{domain.ErrAlreadyExists, CloseAlreadyExists, "already_exists"},
+	{domain.ErrVersionConflict, CloseAlreadyExists, "version_conflict"},
 	{domain.ErrDuplicateMessage, CloseAlreadyExists, "duplicate_message"},
 
 	// Validation errors
@@ -74,7 +78,11 @@ var wsMappings = []struct {
 	{domain.ErrPhoneRateLimited, CloseRateLimited, "phone_rate_limited"},
 	{domain.ErrIPRateLimited, CloseRateLimited, "ip_rate_limited"},
 	{domain.ErrMaxSessionsExceeded, CloseRateLimited, "max_sessions_exceeded"},
+	{domain.ErrUserConnectionLimit, CloseRateLimited, "user_connection_limit"},
+	{domain.ErrDeviceConnectionLimit, CloseRateLimited, "device_connection_limit"},
+	{domain.ErrIPConnectionLimit, CloseRateLimited, "ip_connection_limit"},
 	{domain.ErrSlowConsumer, CloseRateLimited, "slow_consumer"},
+	{domain.ErrSlowMode, CloseRateLimited, "slow_mode"},
 
 	// Availability
 	{domain.ErrUnavailable, CloseTryAgainLater, "service_unavailable"},
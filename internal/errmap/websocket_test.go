@@ -37,10 +37,14 @@ func TestToWebSocketClose(t *testing.T) {
 		{"ErrPhoneRateLimited", domain.ErrPhoneRateLimited, errmap.CloseRateLimited, "phone_rate_limited"},
 		{"ErrIPRateLimited", domain.ErrIPRateLimited, errmap.CloseRateLimited, "ip_rate_limited"},
 		{"ErrMaxSessionsExceeded", domain.ErrMaxSessionsExceeded, errmap.CloseRateLimited, "max_sessions_exceeded"},
+		{"ErrUserConnectionLimit", domain.ErrUserConnectionLimit, errmap.CloseRateLimited, "user_connection_limit"},
+		{"ErrDeviceConnectionLimit", domain.ErrDeviceConnectionLimit, errmap.CloseRateLimited, "device_connection_limit"},
+		{"ErrIPConnectionLimit", domain.ErrIPConnectionLimit, errmap.CloseRateLimited, "ip_connection_limit"},
 
 		// Resource errors
 		{"ErrNotFound", domain.ErrNotFound, errmap.CloseNotFound, "not_found"},
 		{"ErrAlreadyExists", domain.ErrAlreadyExists, errmap.CloseAlreadyExists, "already_exists"},
+		{"ErrVersionConflict", domain.ErrVersionConflict, errmap.CloseAlreadyExists, "version_conflict"},
 
 		// Validation errors
 		{"ErrInvalidInput", domain.ErrInvalidInput, errmap.CloseInvalidMessage, "invalid_message"},
@@ -52,6 +56,7 @@ func TestToWebSocketClose(t *testing.T) {
 		// Operational errors
 		{"ErrRateLimited", domain.ErrRateLimited, errmap.CloseRateLimited, "rate_limited"},
 		{"ErrSlowConsumer", domain.ErrSlowConsumer, errmap.CloseRateLimited, "slow_consumer"},
+		{"ErrSlowMode", domain.ErrSlowMode, errmap.CloseRateLimited, "slow_mode"},
 		{"ErrUnavailable", domain.ErrUnavailable, errmap.CloseTryAgainLater, "service_unavailable"},
 
 		// Idempotency
@@ -132,6 +137,7 @@ func TestWebSocketMappingCompleteness(t *testing.T) {
 		domain.ErrInvalidID,
 		domain.ErrNotFound,
 		domain.ErrAlreadyExists,
+		domain.ErrVersionConflict,
 		domain.ErrUnauthorized,
 		domain.ErrForbidden,
 		domain.ErrNotMember,
@@ -141,6 +147,7 @@ func TestWebSocketMappingCompleteness(t *testing.T) {
 		domain.ErrRateLimited,
 		domain.ErrUnavailable,
 		domain.ErrSlowConsumer,
+		domain.ErrSlowMode,
 		domain.ErrDuplicateMessage,
 		// Auth errors (ADR-015)
 		domain.ErrInvalidOTP,
@@ -153,6 +160,9 @@ func TestWebSocketMappingCompleteness(t *testing.T) {
 		domain.ErrMaxSessionsExceeded,
 		domain.ErrPhoneRateLimited,
 		domain.ErrIPRateLimited,
+		domain.ErrUserConnectionLimit,
+		domain.ErrDeviceConnectionLimit,
+		domain.ErrIPConnectionLimit,
 		domain.ErrInvalidPhoneNumber,
 	}
 
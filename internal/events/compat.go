@@ -0,0 +1,101 @@
+package events
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Violation is one way a schema version breaks compatibility with the
+// version before it.
+type Violation struct {
+	Field  string // dotted path from the payload message
+	Reason string
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Reason
+}
+
+// CheckCompatibility reports how next breaks full (forward and backward)
+// compatibility with prev, per ADR-011 §5.1. Proto3 fields are all
+// optional, so adding fields is always allowed; what breaks readers is a
+// field number whose meaning changes. An empty result means compatible.
+//
+// The rules, per field number:
+//   - a field removed from next must have its number reserved
+//   - a field kept in next must keep its name, kind, cardinality and, for
+//     message and enum fields, its type
+//   - a field added in next must not reuse a number or name prev reserved
+//     and must not be proto2 required
+//
+// Message-typed fields whose descriptors differ are checked recursively.
+func CheckCompatibility(prev, next protoreflect.MessageDescriptor) []Violation {
+	var out []Violation
+	checkMessage(prev, next, "", map[protoreflect.FullName]bool{}, &out)
+	return out
+}
+
+func checkMessage(prev, next protoreflect.MessageDescriptor, path string, seen map[protoreflect.FullName]bool, out *[]Violation) {
+	if seen[prev.FullName()] {
+		return
+	}
+	seen[prev.FullName()] = true
+
+	prevFields, nextFields := prev.Fields(), next.Fields()
+	for i := range prevFields.Len() {
+		pf := prevFields.Get(i)
+		name := path + string(pf.Name())
+		nf := nextFields.ByNumber(pf.Number())
+		if nf == nil {
+			if !next.ReservedRanges().Has(pf.Number()) {
+				*out = append(*out, Violation{name, fmt.Sprintf("removed without reserving field number %d", pf.Number())})
+			}
+			continue
+		}
+		checkField(pf, nf, name, seen, out)
+	}
+
+	for i := range nextFields.Len() {
+		nf := nextFields.Get(i)
+		if prevFields.ByNumber(nf.Number()) != nil {
+			continue
+		}
+		name := path + string(nf.Name())
+		if prev.ReservedRanges().Has(nf.Number()) {
+			*out = append(*out, Violation{name, fmt.Sprintf("reuses reserved field number %d", nf.Number())})
+		}
+		if prev.ReservedNames().Has(nf.Name()) {
+			*out = append(*out, Violation{name, "reuses reserved field name"})
+		}
+		if nf.Cardinality() == protoreflect.Required {
+			*out = append(*out, Violation{name, "added as required"})
+		}
+	}
+}
+
+func checkField(pf, nf protoreflect.FieldDescriptor, name string, seen map[protoreflect.FullName]bool, out *[]Violation) {
+	if pf.Name() != nf.Name() {
+		*out = append(*out, Violation{name, fmt.Sprintf("renamed to %s", nf.Name())})
+	}
+	if pf.Kind() != nf.Kind() {
+		*out = append(*out, Violation{name, fmt.Sprintf("type changed from %s to %s", pf.Kind(), nf.Kind())})
+		return
+	}
+	if pf.Cardinality() != nf.Cardinality() || pf.IsMap() != nf.IsMap() {
+		*out = append(*out, Violation{name, "cardinality changed"})
+	}
+
+	if pm, nm := pf.Message(), nf.Message(); pm != nil && nm != nil {
+		if pm.FullName() != nm.FullName() {
+			*out = append(*out, Violation{name, fmt.Sprintf("type changed from %s to %s", pm.FullName(), nm.FullName())})
+			return
+		}
+		if pm != nm {
+			checkMessage(pm, nm, name+".", seen, out)
+		}
+	}
+	if pe, ne := pf.Enum(), nf.Enum(); pe != nil && ne != nil && pe.FullName() != ne.FullName() {
+		*out = append(*out, Violation{name, fmt.Sprintf("type changed from %s to %s", pe.FullName(), ne.FullName())})
+	}
+}
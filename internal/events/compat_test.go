@@ -0,0 +1,107 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+)
+
+const (
+	tString = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	tInt64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	base := []*descriptorpb.FieldDescriptorProto{
+		field("chat_id", 1, tString),
+		field("sequence", 2, tInt64),
+	}
+
+	tests := []struct {
+		name string
+		next schemaDef
+		want []string
+	}{
+		{
+			name: "adding a field is compatible",
+			next: schemaDef{fields: append(base[:2:2], field("priority", 3, tString))},
+		},
+		{
+			name: "removing a reserved field is compatible",
+			next: schemaDef{fields: base[:1], reservedNums: []int32{2}},
+		},
+		{
+			name: "removing without reserving",
+			next: schemaDef{fields: base[:1]},
+			want: []string{"sequence: removed without reserving field number 2"},
+		},
+		{
+			name: "renaming",
+			next: schemaDef{fields: []*descriptorpb.FieldDescriptorProto{base[0], field("seq", 2, tInt64)}},
+			want: []string{"sequence: renamed to seq"},
+		},
+		{
+			name: "changing type",
+			next: schemaDef{fields: []*descriptorpb.FieldDescriptorProto{base[0], field("sequence", 2, tString)}},
+			want: []string{"sequence: type changed from int64 to string"},
+		},
+		{
+			name: "changing cardinality",
+			next: schemaDef{fields: []*descriptorpb.FieldDescriptorProto{base[0], repeated(field("sequence", 2, tInt64))}},
+			want: []string{"sequence: cardinality changed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := compile(t, map[string]schemaDef{"V1": {fields: base}, "V2": tt.next})
+
+			got := events.CheckCompatibility(md["V1"], md["V2"])
+
+			assert.Equal(t, tt.want, violationStrings(got))
+		})
+	}
+}
+
+func TestCheckCompatibility_ReusesReserved(t *testing.T) {
+	md := compile(t, map[string]schemaDef{
+		"V1": {fields: []*descriptorpb.FieldDescriptorProto{field("chat_id", 1, tString)}, reservedNums: []int32{2}, reservedNames: []string{"content"}},
+		"V2": {fields: []*descriptorpb.FieldDescriptorProto{field("chat_id", 1, tString), field("content", 2, tString)}},
+	})
+
+	got := events.CheckCompatibility(md["V1"], md["V2"])
+
+	assert.Equal(t, []string{
+		"content: reuses reserved field number 2",
+		"content: reuses reserved field name",
+	}, violationStrings(got))
+}
+
+func TestCheckCompatibility_MessageFields(t *testing.T) {
+	md := compile(t, map[string]schemaDef{
+		"Sender":  {fields: []*descriptorpb.FieldDescriptorProto{field("user_id", 1, tString)}},
+		"Bot":     {fields: []*descriptorpb.FieldDescriptorProto{field("bot_id", 1, tString)}},
+		"V1":      {fields: []*descriptorpb.FieldDescriptorProto{messageField("sender", 1, "Sender")}},
+		"V2":      {fields: []*descriptorpb.FieldDescriptorProto{messageField("sender", 1, "Bot")}},
+		"V2Equal": {fields: []*descriptorpb.FieldDescriptorProto{messageField("sender", 1, "Sender")}},
+	})
+
+	assert.Empty(t, events.CheckCompatibility(md["V1"], md["V2Equal"]))
+
+	got := events.CheckCompatibility(md["V1"], md["V2"])
+	assert.Len(t, got, 1)
+	assert.Contains(t, got[0].String(), "sender: type changed from")
+}
+
+func violationStrings(vs []events.Violation) []string {
+	if len(vs) == 0 {
+		return nil
+	}
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		out[i] = v.String()
+	}
+	return out
+}
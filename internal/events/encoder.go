@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// EncoderConfig holds the dependencies for Encoder.
+type EncoderConfig struct {
+	Registry *Registry
+	Clock    domain.Clock
+
+	// ProducerID identifies this service instance, e.g. "ingest-<hostname>".
+	ProducerID string
+}
+
+// Encoder wraps payloads in envelopes for producing.
+type Encoder struct {
+	registry   *Registry
+	clock      domain.Clock
+	producerID string
+}
+
+// NewEncoder creates an Encoder with the given dependencies.
+func NewEncoder(cfg EncoderConfig) *Encoder {
+	return &Encoder{
+		registry:   cfg.Registry,
+		clock:      cfg.Clock,
+		producerID: cfg.ProducerID,
+	}
+}
+
+// Encode wraps payload in an envelope. The event type and version come
+// from the schema payload's message type is registered under, so producers
+// name them once, at registration. The trace context of ctx is recorded for
+// consumers.
+func (e *Encoder) Encode(ctx context.Context, partitionKey string, payload proto.Message) (*Envelope, error) {
+	schema, ok := e.registry.schemaFor(payload)
+	if !ok {
+		return nil, fmt.Errorf("%w: no schema for %s", ErrUnknownEventType, payload.ProtoReflect().Descriptor().FullName())
+	}
+
+	b, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("events: encode %q v%d payload: %w", schema.Type, schema.Version, err)
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return &Envelope{
+		EventID:      uuid.NewString(),
+		EventType:    schema.Type,
+		EventVersion: schema.Version,
+		EventTime:    e.clock.Now(),
+		PartitionKey: partitionKey,
+		ProducerID:   e.producerID,
+		TraceParent:  carrier["traceparent"],
+		TraceState:   carrier["tracestate"],
+		Payload:      b,
+	}, nil
+}
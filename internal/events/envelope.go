@@ -0,0 +1,182 @@
+// Package events defines the versioned envelope carried by every Kafka event
+// (ADR-011 §3.3), the schema registry that maps (event type, version) to a
+// protobuf payload, and the compatibility rules new versions must satisfy
+// (ADR-011 §5). Producers and consumers deploy independently: consumers
+// decode any version up to the newest they know and upgrade older payloads.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrMalformedEnvelope is returned when a record value is not a valid
+// envelope. Such records are poison pills (ADR-011 §4.5).
+var ErrMalformedEnvelope = errors.New("events: malformed envelope")
+
+// Envelope field numbers; see messaging.v1.EventEnvelope in
+// proto/messaging/v1/events.proto.
+const (
+	fieldEventID      protowire.Number = 1
+	fieldEventType    protowire.Number = 2
+	fieldEventVersion protowire.Number = 3
+	fieldEventTime    protowire.Number = 4
+	fieldPartitionKey protowire.Number = 5
+	fieldProducerID   protowire.Number = 6
+	fieldTraceParent  protowire.Number = 7
+	fieldTraceState   protowire.Number = 8
+	fieldPayload      protowire.Number = 9
+
+	// messaging.v1.Timestamp.millis
+	fieldTimestampMillis protowire.Number = 1
+)
+
+// Envelope is the wire wrapper of every Kafka event.
+type Envelope struct {
+	EventID      string
+	EventType    string
+	EventVersion uint32
+	EventTime    time.Time
+	PartitionKey string
+	ProducerID   string
+	TraceParent  string
+	TraceState   string
+	Payload      []byte
+}
+
+// Marshal returns the protobuf encoding of e. Empty fields are omitted, as
+// proto3 does.
+func (e *Envelope) Marshal() []byte {
+	b := make([]byte, 0, 128+len(e.Payload))
+	b = appendString(b, fieldEventID, e.EventID)
+	b = appendString(b, fieldEventType, e.EventType)
+	if e.EventVersion != 0 {
+		b = protowire.AppendTag(b, fieldEventVersion, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(e.EventVersion))
+	}
+	if !e.EventTime.IsZero() {
+		var ts []byte
+		if ms := e.EventTime.UnixMilli(); ms != 0 {
+			ts = protowire.AppendTag(ts, fieldTimestampMillis, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(ms)) //nolint:gosec // two's complement, as proto int64
+		}
+		b = protowire.AppendTag(b, fieldEventTime, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+	b = appendString(b, fieldPartitionKey, e.PartitionKey)
+	b = appendString(b, fieldProducerID, e.ProducerID)
+	b = appendString(b, fieldTraceParent, e.TraceParent)
+	b = appendString(b, fieldTraceState, e.TraceState)
+	if len(e.Payload) > 0 {
+		b = protowire.AppendTag(b, fieldPayload, protowire.BytesType)
+		b = protowire.AppendBytes(b, e.Payload)
+	}
+	return b
+}
+
+// UnmarshalEnvelope decodes an envelope. Unknown fields are skipped so that
+// envelope fields added later do not break older consumers.
+func UnmarshalEnvelope(b []byte) (*Envelope, error) {
+	var e Envelope
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("%w: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		switch {
+		case num == fieldEventVersion && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, fmt.Errorf("%w: event_version: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+			}
+			e.EventVersion = uint32(v) //nolint:gosec // proto uint32 truncation semantics
+			b = b[n:]
+		case typ == protowire.BytesType && num >= fieldEventID && num <= fieldPayload:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, fmt.Errorf("%w: field %d: %w", ErrMalformedEnvelope, num, protowire.ParseError(n))
+			}
+			if err := e.setBytesField(num, v); err != nil {
+				return nil, err
+			}
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, fmt.Errorf("%w: field %d: %w", ErrMalformedEnvelope, num, protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+
+	if e.EventType == "" || e.EventVersion == 0 {
+		return nil, fmt.Errorf("%w: missing event_type or event_version", ErrMalformedEnvelope)
+	}
+	return &e, nil
+}
+
+func (e *Envelope) setBytesField(num protowire.Number, v []byte) error {
+	switch num {
+	case fieldEventID:
+		e.EventID = string(v)
+	case fieldEventType:
+		e.EventType = string(v)
+	case fieldEventTime:
+		ms, err := consumeTimestamp(v)
+		if err != nil {
+			return err
+		}
+		e.EventTime = time.UnixMilli(ms).UTC()
+	case fieldPartitionKey:
+		e.PartitionKey = string(v)
+	case fieldProducerID:
+		e.ProducerID = string(v)
+	case fieldTraceParent:
+		e.TraceParent = string(v)
+	case fieldTraceState:
+		e.TraceState = string(v)
+	case fieldPayload:
+		e.Payload = append([]byte(nil), v...)
+	}
+	return nil
+}
+
+// consumeTimestamp decodes a messaging.v1.Timestamp.
+func consumeTimestamp(b []byte) (int64, error) {
+	var ms int64
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0, fmt.Errorf("%w: event_time: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if num == fieldTimestampMillis && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0, fmt.Errorf("%w: event_time: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+			}
+			ms = int64(v) //nolint:gosec // two's complement, as proto int64
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return 0, fmt.Errorf("%w: event_time: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return ms, nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
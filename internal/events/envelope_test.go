@@ -0,0 +1,76 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	env := &events.Envelope{
+		EventID:      "5b0e7d6a-2f0c-4c36-9a53-0c8f5a0f1e11",
+		EventType:    "MessagePersisted",
+		EventVersion: 2,
+		EventTime:    time.Date(2026, 1, 31, 14, 30, 0, 123_000_000, time.UTC),
+		PartitionKey: "chat-abc",
+		ProducerID:   "ingest-pod-1",
+		TraceParent:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		TraceState:   "vendor=x",
+		Payload:      []byte{0x0a, 0x03, 'a', 'b', 'c'},
+	}
+
+	got, err := events.UnmarshalEnvelope(env.Marshal())
+
+	require.NoError(t, err)
+	assert.Equal(t, env, got)
+}
+
+func TestEnvelope_EventTimeIsMillis(t *testing.T) {
+	env := &events.Envelope{
+		EventType:    "ChatCreated",
+		EventVersion: 1,
+		EventTime:    time.Date(2026, 1, 31, 14, 30, 0, 123_456_789, time.FixedZone("CET", 3600)),
+	}
+
+	got, err := events.UnmarshalEnvelope(env.Marshal())
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 31, 13, 30, 0, 123_000_000, time.UTC), got.EventTime)
+}
+
+func TestUnmarshalEnvelope_SkipsUnknownFields(t *testing.T) {
+	b := (&events.Envelope{EventType: "ChatCreated", EventVersion: 1}).Marshal()
+	b = protowire.AppendTag(b, 50, protowire.VarintType)
+	b = protowire.AppendVarint(b, 7)
+	b = protowire.AppendTag(b, 51, protowire.BytesType)
+	b = protowire.AppendString(b, "added later")
+
+	got, err := events.UnmarshalEnvelope(b)
+
+	require.NoError(t, err)
+	assert.Equal(t, "ChatCreated", got.EventType)
+}
+
+func TestUnmarshalEnvelope_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "not protobuf", data: []byte(`{"event_type":"ChatCreated"}`)},
+		{name: "truncated", data: (&events.Envelope{EventType: "ChatCreated", EventVersion: 1}).Marshal()[:4]},
+		{name: "missing version", data: (&events.Envelope{EventType: "ChatCreated"}).Marshal()},
+		{name: "missing type", data: (&events.Envelope{EventVersion: 1}).Marshal()},
+		{name: "empty", data: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := events.UnmarshalEnvelope(tt.data)
+			assert.ErrorIs(t, err, events.ErrMalformedEnvelope)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package events_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var fileSeq atomic.Int64
+
+// field builds an optional proto3 scalar field.
+func field(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(num),
+		Type:     typ.Enum(),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String(name),
+	}
+}
+
+func repeated(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
+
+// messageField builds a field of message type typeName, which must be
+// declared in the same test file.
+func messageField(name string, num int32, typeName string) *descriptorpb.FieldDescriptorProto {
+	f := field(name, num, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	f.TypeName = proto.String("." + typeName)
+	return f
+}
+
+// schemaDef describes a test message.
+type schemaDef struct {
+	fields        []*descriptorpb.FieldDescriptorProto
+	reservedNums  []int32
+	reservedNames []string
+}
+
+// compile builds the messages in a fresh file and returns their
+// descriptors by name. Each file gets its own package, so the same message
+// names can be reused across tests.
+func compile(t *testing.T, msgs map[string]schemaDef) map[string]protoreflect.MessageDescriptor {
+	t.Helper()
+	pkg := fmt.Sprintf("eventstest%d", fileSeq.Add(1))
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(pkg + ".proto"),
+		Package: proto.String(pkg),
+		Syntax:  proto.String("proto3"),
+	}
+	for name, def := range msgs {
+		m := &descriptorpb.DescriptorProto{Name: proto.String(name), ReservedName: def.reservedNames}
+		for _, f := range def.fields {
+			f = proto.Clone(f).(*descriptorpb.FieldDescriptorProto)
+			if f.TypeName != nil {
+				f.TypeName = proto.String("." + pkg + f.GetTypeName())
+			}
+			m.Field = append(m.Field, f)
+		}
+		for _, n := range def.reservedNums {
+			m.ReservedRange = append(m.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+				Start: proto.Int32(n), End: proto.Int32(n + 1),
+			})
+		}
+		fd.MessageType = append(fd.MessageType, m)
+	}
+
+	file, err := protodesc.NewFile(fd, nil)
+	require.NoError(t, err)
+	out := make(map[string]protoreflect.MessageDescriptor, len(msgs))
+	for name := range msgs {
+		out[name] = file.Messages().ByName(protoreflect.Name(name))
+	}
+	return out
+}
+
+func newMessage(md protoreflect.MessageDescriptor) func() proto.Message {
+	return func() proto.Message { return dynamicpb.NewMessage(md) }
+}
+
+func setString(m proto.Message, name, value string) {
+	r := m.ProtoReflect()
+	r.Set(r.Descriptor().Fields().ByName(protoreflect.Name(name)), protoreflect.ValueOfString(value))
+}
+
+func getString(m proto.Message, name string) string {
+	r := m.ProtoReflect()
+	return r.Get(r.Descriptor().Fields().ByName(protoreflect.Name(name))).String()
+}
@@ -0,0 +1,180 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrUnknownEventType is returned when decoding an event type the registry
+// has no schema for.
+var ErrUnknownEventType = errors.New("events: unknown event type")
+
+// Schema describes one version of an event type's payload.
+type Schema struct {
+	Type    string
+	Version uint32
+
+	// New returns an empty payload message for this version.
+	New func() proto.Message
+
+	// Upgrade converts a payload of this version to the next version. Nil
+	// means the next version reads this version's encoding as is, which
+	// holds for any compatible change; set it when a field's meaning moves,
+	// e.g. to fill a new field from a deprecated one.
+	Upgrade func(proto.Message) (proto.Message, error)
+}
+
+// Event is a decoded envelope whose payload has been upgraded to the newest
+// version the registry knows.
+type Event struct {
+	Envelope *Envelope
+	// Payload is a message of the newest registered version of the type.
+	Payload proto.Message
+}
+
+// Context returns ctx carrying the producer's trace context, so consumer
+// spans join the producer's trace.
+func (e *Event) Context(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	if e.Envelope.TraceParent != "" {
+		carrier["traceparent"] = e.Envelope.TraceParent
+	}
+	if e.Envelope.TraceState != "" {
+		carrier["tracestate"] = e.Envelope.TraceState
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Registry maps event types and versions to payload schemas. Register
+// every schema at startup; after that the registry is read-only and safe
+// for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	versions map[string][]Schema // index i holds version i+1
+	byName   map[protoreflect.FullName]Schema
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		versions: make(map[string][]Schema),
+		byName:   make(map[protoreflect.FullName]Schema),
+	}
+}
+
+// Register adds the next version of an event type. Versions must be
+// registered in order starting at 1, and each must be compatible with the
+// one before it (CheckCompatibility); a breaking change needs a new event
+// type (ADR-011 §5.2).
+func (r *Registry) Register(s Schema) error {
+	if s.Type == "" || s.New == nil {
+		return fmt.Errorf("events: register %q v%d: type and New are required", s.Type, s.Version)
+	}
+	desc := s.New().ProtoReflect().Descriptor()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prior := r.versions[s.Type]
+	if want := uint32(len(prior)) + 1; s.Version != want { //nolint:gosec // versions are small
+		return fmt.Errorf("events: register %q v%d: next version is v%d", s.Type, s.Version, want)
+	}
+	if other, ok := r.byName[desc.FullName()]; ok {
+		return fmt.Errorf("events: register %q v%d: %s already registered as %q v%d",
+			s.Type, s.Version, desc.FullName(), other.Type, other.Version)
+	}
+	if len(prior) > 0 {
+		prev := prior[len(prior)-1].New().ProtoReflect().Descriptor()
+		if vs := CheckCompatibility(prev, desc); len(vs) > 0 {
+			reasons := make([]string, len(vs))
+			for i, v := range vs {
+				reasons[i] = v.String()
+			}
+			return fmt.Errorf("events: register %q v%d: incompatible with v%d: %s",
+				s.Type, s.Version, s.Version-1, strings.Join(reasons, "; "))
+		}
+	}
+
+	r.versions[s.Type] = append(prior, s)
+	r.byName[desc.FullName()] = s
+	return nil
+}
+
+// Latest returns the newest registered version of eventType, or 0.
+func (r *Registry) Latest(eventType string) uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return uint32(len(r.versions[eventType])) //nolint:gosec // versions are small
+}
+
+// schemaFor returns the registered schema of payload's message type.
+func (r *Registry) schemaFor(payload proto.Message) (Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.byName[payload.ProtoReflect().Descriptor().FullName()]
+	return s, ok
+}
+
+// Decode parses an envelope and returns its payload as the newest version
+// of its type. Older payloads pass through each version's Upgrade in turn.
+// A payload newer than any registered version is read with the newest
+// schema; compatible changes guarantee that loses only fields this
+// consumer does not know about.
+func (r *Registry) Decode(data []byte) (*Event, error) {
+	env, err := UnmarshalEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	chain := r.versions[env.EventType]
+	r.mu.RUnlock()
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEventType, env.EventType)
+	}
+
+	start := min(int(env.EventVersion), len(chain)) - 1
+	msg := chain[start].New()
+	if err := proto.Unmarshal(env.Payload, msg); err != nil {
+		return nil, fmt.Errorf("events: decode %q v%d payload: %w", env.EventType, env.EventVersion, err)
+	}
+
+	for i := start; i < len(chain)-1; i++ {
+		if msg, err = upgrade(chain[i], chain[i+1], msg); err != nil {
+			return nil, fmt.Errorf("events: upgrade %q v%d to v%d: %w", env.EventType, chain[i].Version, chain[i+1].Version, err)
+		}
+	}
+	return &Event{Envelope: env, Payload: msg}, nil
+}
+
+// upgrade converts msg from version from to version to.
+func upgrade(from, to Schema, msg proto.Message) (proto.Message, error) {
+	if from.Upgrade != nil {
+		next, err := from.Upgrade(msg)
+		if err != nil {
+			return nil, err
+		}
+		if got, want := next.ProtoReflect().Descriptor().FullName(), to.New().ProtoReflect().Descriptor().FullName(); got != want {
+			return nil, fmt.Errorf("upgrade returned %s, want %s", got, want)
+		}
+		return next, nil
+	}
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	next := to.New()
+	if err := proto.Unmarshal(b, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
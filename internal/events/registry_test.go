@@ -0,0 +1,212 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/events"
+)
+
+// messagePersisted compiles three versions of a MessagePersisted payload:
+// v2 adds content_type, v3 replaces the free-form kind with content_type.
+func messagePersisted(t *testing.T) (v1, v2, v3 protoreflect.MessageDescriptor) {
+	t.Helper()
+	md := compile(t, map[string]schemaDef{
+		"MessagePersistedV1": {fields: []*descriptorpb.FieldDescriptorProto{
+			field("chat_id", 1, tString),
+			field("kind", 2, tString),
+		}},
+		"MessagePersistedV2": {fields: []*descriptorpb.FieldDescriptorProto{
+			field("chat_id", 1, tString),
+			field("kind", 2, tString),
+			field("content_type", 3, tString),
+		}},
+		"MessagePersistedV3": {fields: []*descriptorpb.FieldDescriptorProto{
+			field("chat_id", 1, tString),
+			field("content_type", 3, tString),
+		}, reservedNums: []int32{2}},
+	})
+	return md["MessagePersistedV1"], md["MessagePersistedV2"], md["MessagePersistedV3"]
+}
+
+func newRegistry(t *testing.T) (*events.Registry, [3]protoreflect.MessageDescriptor) {
+	t.Helper()
+	v1, v2, v3 := messagePersisted(t)
+	r := events.NewRegistry()
+	require.NoError(t, r.Register(events.Schema{Type: "MessagePersisted", Version: 1, New: newMessage(v1)}))
+	require.NoError(t, r.Register(events.Schema{
+		Type: "MessagePersisted", Version: 2, New: newMessage(v2),
+		Upgrade: func(m proto.Message) (proto.Message, error) {
+			next := newMessage(v3)()
+			setString(next, "chat_id", getString(m, "chat_id"))
+			ct := getString(m, "content_type")
+			if ct == "" {
+				ct = getString(m, "kind")
+			}
+			setString(next, "content_type", ct)
+			return next, nil
+		},
+	}))
+	require.NoError(t, r.Register(events.Schema{Type: "MessagePersisted", Version: 3, New: newMessage(v3)}))
+	return r, [3]protoreflect.MessageDescriptor{v1, v2, v3}
+}
+
+func encode(t *testing.T, version uint32, payload proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(payload)
+	require.NoError(t, err)
+	return (&events.Envelope{EventType: "MessagePersisted", EventVersion: version, Payload: b}).Marshal()
+}
+
+func TestRegistry_Register(t *testing.T) {
+	v1, v2, v3 := messagePersisted(t)
+
+	t.Run("versions must be registered in order", func(t *testing.T) {
+		r := events.NewRegistry()
+		err := r.Register(events.Schema{Type: "MessagePersisted", Version: 2, New: newMessage(v2)})
+		assert.ErrorContains(t, err, "next version is v1")
+	})
+
+	t.Run("incompatible version is refused", func(t *testing.T) {
+		r := events.NewRegistry()
+		require.NoError(t, r.Register(events.Schema{Type: "MessagePersisted", Version: 1, New: newMessage(v1)}))
+
+		// v3 drops kind, which v1 has; only v2 -> v3 reserves it.
+		md := compile(t, map[string]schemaDef{"Broken": {fields: []*descriptorpb.FieldDescriptorProto{field("chat_id", 1, tString)}}})
+		err := r.Register(events.Schema{Type: "MessagePersisted", Version: 2, New: newMessage(md["Broken"])})
+
+		assert.ErrorContains(t, err, "incompatible with v1: kind: removed without reserving field number 2")
+		assert.Equal(t, uint32(1), r.Latest("MessagePersisted"))
+	})
+
+	t.Run("a message type belongs to one version", func(t *testing.T) {
+		r := events.NewRegistry()
+		require.NoError(t, r.Register(events.Schema{Type: "MessagePersisted", Version: 1, New: newMessage(v3)}))
+		err := r.Register(events.Schema{Type: "ChatCreated", Version: 1, New: newMessage(v3)})
+		assert.ErrorContains(t, err, "already registered")
+	})
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	r, md := newRegistry(t)
+
+	t.Run("old version is upgraded through every step", func(t *testing.T) {
+		p := newMessage(md[0])()
+		setString(p, "chat_id", "chat-abc")
+		setString(p, "kind", "text/plain")
+
+		ev, err := r.Decode(encode(t, 1, p))
+
+		require.NoError(t, err)
+		assert.Equal(t, md[2].FullName(), ev.Payload.ProtoReflect().Descriptor().FullName())
+		assert.Equal(t, "chat-abc", getString(ev.Payload, "chat_id"))
+		assert.Equal(t, "text/plain", getString(ev.Payload, "content_type"))
+		assert.Equal(t, uint32(1), ev.Envelope.EventVersion)
+	})
+
+	t.Run("latest version is returned as is", func(t *testing.T) {
+		p := newMessage(md[2])()
+		setString(p, "content_type", "text/markdown")
+
+		ev, err := r.Decode(encode(t, 3, p))
+
+		require.NoError(t, err)
+		assert.Equal(t, "text/markdown", getString(ev.Payload, "content_type"))
+	})
+
+	t.Run("newer version is read with the latest known schema", func(t *testing.T) {
+		p := newMessage(md[2])()
+		setString(p, "chat_id", "chat-abc")
+
+		ev, err := r.Decode(encode(t, 7, p))
+
+		require.NoError(t, err)
+		assert.Equal(t, "chat-abc", getString(ev.Payload, "chat_id"))
+	})
+
+	t.Run("unknown event type", func(t *testing.T) {
+		data := (&events.Envelope{EventType: "ChatArchived", EventVersion: 1}).Marshal()
+
+		_, err := r.Decode(data)
+
+		assert.ErrorIs(t, err, events.ErrUnknownEventType)
+	})
+
+	t.Run("malformed payload", func(t *testing.T) {
+		data := (&events.Envelope{EventType: "MessagePersisted", EventVersion: 1, Payload: []byte{0x0a, 0x05}}).Marshal()
+
+		_, err := r.Decode(data)
+
+		assert.ErrorContains(t, err, `decode "MessagePersisted" v1 payload`)
+	})
+
+	t.Run("upgrade failure", func(t *testing.T) {
+		v1, v2, _ := messagePersisted(t)
+		r := events.NewRegistry()
+		require.NoError(t, r.Register(events.Schema{
+			Type: "MessagePersisted", Version: 1, New: newMessage(v1),
+			Upgrade: func(proto.Message) (proto.Message, error) { return nil, errors.New("no kind") },
+		}))
+		require.NoError(t, r.Register(events.Schema{Type: "MessagePersisted", Version: 2, New: newMessage(v2)}))
+
+		_, err := r.Decode(encode(t, 1, newMessage(v1)()))
+
+		assert.ErrorContains(t, err, "upgrade \"MessagePersisted\" v1 to v2: no kind")
+	})
+}
+
+func TestEncoder(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+	tp := sdktrace.NewTracerProvider()
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	r, md := newRegistry(t)
+	now := time.Date(2026, 1, 31, 14, 30, 0, 0, time.UTC)
+	enc := events.NewEncoder(events.EncoderConfig{
+		Registry:   r,
+		Clock:      domaintest.NewFakeClock(now),
+		ProducerID: "ingest-pod-1",
+	})
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "persist")
+	defer span.End()
+
+	p := newMessage(md[1])()
+	setString(p, "chat_id", "chat-abc")
+	env, err := enc.Encode(ctx, "chat-abc", p)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, env.EventID)
+	assert.Equal(t, "MessagePersisted", env.EventType)
+	assert.Equal(t, uint32(2), env.EventVersion, "version comes from the payload's schema")
+	assert.Equal(t, now, env.EventTime)
+	assert.Equal(t, "chat-abc", env.PartitionKey)
+	assert.Equal(t, "ingest-pod-1", env.ProducerID)
+
+	ev, err := r.Decode(env.Marshal())
+	require.NoError(t, err)
+	assert.Equal(t, "chat-abc", getString(ev.Payload, "chat_id"))
+	got := trace.SpanContextFromContext(ev.Context(context.Background()))
+	assert.Equal(t, span.SpanContext().TraceID(), got.TraceID())
+
+	t.Run("unregistered payload", func(t *testing.T) {
+		md := compile(t, map[string]schemaDef{"Other": {}})
+		_, err := enc.Encode(context.Background(), "k", newMessage(md["Other"])())
+		assert.ErrorIs(t, err, events.ErrUnknownEventType)
+	})
+}
@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// Compile-time check: DNDStore satisfies app.DNDStore.
+var _ app.DNDStore = (*DNDStore)(nil)
+
+// dndDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the DND store.
+type dndDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+}
+
+// dndItem is the projection of a users item holding the do-not-disturb
+// schedule Chat Mgmt stores.
+type dndItem struct {
+	DND *dndAttr `dynamodbav:"dnd"`
+}
+
+// dndAttr is the dnd map attribute. Windows are minutes after local
+// midnight; the time zone is an IANA name, empty for UTC.
+type dndAttr struct {
+	TimeZone string                 `dynamodbav:"time_zone,omitempty"`
+	Windows  []quietWindowAttr      `dynamodbav:"windows,omitempty"`
+	Chats    map[string]chatDNDAttr `dynamodbav:"chats,omitempty"`
+}
+
+type quietWindowAttr struct {
+	Start int `dynamodbav:"start"`
+	End   int `dynamodbav:"end"`
+}
+
+type chatDNDAttr struct {
+	Bypass  bool              `dynamodbav:"bypass,omitempty"`
+	Windows []quietWindowAttr `dynamodbav:"windows,omitempty"`
+}
+
+// DNDStore reads do-not-disturb schedules from the users table.
+type DNDStore struct {
+	db        dndDynamoDB
+	tableName string
+}
+
+// NewDNDStore creates a DNDStore backed by the given DynamoDB client.
+func NewDNDStore(db dndDynamoDB, tableName string) *DNDStore {
+	return &DNDStore{db: db, tableName: tableName}
+}
+
+// DNDSchedule returns userID's schedule. Users without one, and users that
+// no longer exist, get the zero schedule, which holds nothing.
+func (s *DNDStore) DNDSchedule(ctx context.Context, userID string) (domain.DNDSchedule, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.dnd_schedule")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "dnd"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.DNDSchedule{}, fmt.Errorf("dnd store: get: %w", err)
+	}
+
+	var item dndItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return domain.DNDSchedule{}, fmt.Errorf("dnd store: unmarshal: %w", err)
+	}
+	if item.DND == nil {
+		return domain.DNDSchedule{}, nil
+	}
+	loc, err := domain.LoadDNDLocation(item.DND.TimeZone)
+	if err != nil {
+		return domain.DNDSchedule{}, fmt.Errorf("dnd store: %w", err)
+	}
+	schedule := domain.DNDSchedule{Location: loc, Windows: quietWindows(item.DND.Windows)}
+	if len(item.DND.Chats) > 0 {
+		schedule.Chats = make(map[string]domain.ChatDND, len(item.DND.Chats))
+		for chatID, o := range item.DND.Chats {
+			schedule.Chats[chatID] = domain.ChatDND{Bypass: o.Bypass, Windows: quietWindows(o.Windows)}
+		}
+	}
+	return schedule, nil
+}
+
+func quietWindows(ws []quietWindowAttr) []domain.QuietWindow {
+	if len(ws) == 0 {
+		return nil
+	}
+	out := make([]domain.QuietWindow, len(ws))
+	for i, w := range ws {
+		out[i] = domain.QuietWindow{Start: w.Start, End: w.End}
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// fakeDNDDynamo returns item from every GetItem.
+type fakeDNDDynamo struct {
+	item map[string]dynamo.AttributeValue
+	err  error
+}
+
+func (f fakeDNDDynamo) GetItem(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if *params.ProjectionExpression != "dnd" {
+		return nil, errors.New("unexpected projection")
+	}
+	return &dynamo.GetItemOutput{Item: f.item}, nil
+}
+
+func TestDNDStore_DNDSchedule(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stored schedule", func(t *testing.T) {
+		dnd, err := dynamo.MarshalMap(dndAttr{
+			TimeZone: "America/New_York",
+			Windows:  []quietWindowAttr{{Start: 22 * 60, End: 7 * 60}},
+			Chats:    map[string]chatDNDAttr{"family": {Bypass: true}},
+		})
+		require.NoError(t, err)
+		store := NewDNDStore(fakeDNDDynamo{item: map[string]dynamo.AttributeValue{
+			"dnd": &dynamo.AttributeValueMemberM{Value: dnd},
+		}}, usersTable)
+
+		got, err := store.DNDSchedule(ctx, "user-001")
+
+		require.NoError(t, err)
+		ny, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		assert.Equal(t, domain.DNDSchedule{
+			Location: ny,
+			Windows:  []domain.QuietWindow{{Start: 22 * 60, End: 7 * 60}},
+			Chats:    map[string]domain.ChatDND{"family": {Bypass: true}},
+		}, got)
+	})
+
+	t.Run("unset holds nothing", func(t *testing.T) {
+		got, err := NewDNDStore(fakeDNDDynamo{}, usersTable).DNDSchedule(ctx, "user-001")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.DNDSchedule{}, got)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		_, err := NewDNDStore(fakeDNDDynamo{err: errors.New("throttled")}, usersTable).DNDSchedule(ctx, "user-001")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
@@ -0,0 +1,195 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// Compile-time checks: MembershipStore satisfies its app interfaces.
+var (
+	_ app.SMSMembership = (*MembershipStore)(nil)
+	_ app.MemberLister  = (*MembershipStore)(nil)
+	_ app.ContactLister = (*MembershipStore)(nil)
+)
+
+// membershipDynamoDB is the subset of the DynamoDB client MembershipStore
+// needs. The *dynamodb.Client satisfies this interface.
+type membershipDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+// MembershipStore reads the chat_memberships table (PK chat_id, SK
+// user_id) and its user_chats-index GSI.
+type MembershipStore struct {
+	db        membershipDynamoDB
+	tableName string
+	indexName string
+}
+
+// NewMembershipStore creates a MembershipStore backed by the given
+// DynamoDB client.
+func NewMembershipStore(db membershipDynamoDB, tableName string) *MembershipStore {
+	return &MembershipStore{db: db, tableName: tableName, indexName: "user_chats-index"}
+}
+
+// IsMember reports whether userID is a member of chatID.
+func (s *MembershipStore) IsMember(ctx context.Context, chatID, userID string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.memberships.is_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "user_id"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return false, fmt.Errorf("membership store: get: %w", err)
+	}
+	return out.Item != nil, nil
+}
+
+// Members returns the user IDs of chatID's members, read consistently so a
+// member who just joined receives the chat's next message. Pending join
+// requests are filtered server-side.
+func (s *MembershipStore) Members(ctx context.Context, chatID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.memberships.members")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "chat_id = :cid"
+	filter := "attribute_not_exists(#status)"
+	projection := "user_id"
+	consistentRead := true
+	var (
+		members  []string
+		startKey map[string]dynamo.AttributeValue
+	)
+	for {
+		out, err := s.db.Query(ctx, &dynamo.QueryInput{
+			TableName:                &s.tableName,
+			KeyConditionExpression:   &keyExpr,
+			FilterExpression:         &filter,
+			ProjectionExpression:     &projection,
+			ExpressionAttributeNames: map[string]string{"#status": "status"},
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":cid": &dynamo.AttributeValueMemberS{Value: chatID},
+			},
+			ConsistentRead:    &consistentRead,
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("membership store: query members: %w", err)
+		}
+		for _, av := range out.Items {
+			var item struct {
+				UserID string `dynamodbav:"user_id"`
+			}
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("membership store: unmarshal member: %w", err)
+			}
+			members = append(members, item.UserID)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = out.LastEvaluatedKey
+	}
+	span.SetAttributes(attribute.Int("chat.members", len(members)))
+	return members, nil
+}
+
+// Contacts returns the users who share a chat with userID, once each and
+// without userID. The user's chats are read from the eventually consistent
+// user_chats-index GSI, so a chat joined moments ago may be missed.
+func (s *MembershipStore) Contacts(ctx context.Context, userID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.memberships.contacts")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid"
+	filter := "attribute_not_exists(#status)"
+	projection := "chat_id"
+	var (
+		chats    []string
+		startKey map[string]dynamo.AttributeValue
+	)
+	for {
+		out, err := s.db.Query(ctx, &dynamo.QueryInput{
+			TableName:                &s.tableName,
+			IndexName:                &s.indexName,
+			KeyConditionExpression:   &keyExpr,
+			FilterExpression:         &filter,
+			ProjectionExpression:     &projection,
+			ExpressionAttributeNames: map[string]string{"#status": "status"},
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":uid": &dynamo.AttributeValueMemberS{Value: userID},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("membership store: query user chats: %w", err)
+		}
+		for _, av := range out.Items {
+			var item struct {
+				ChatID string `dynamodbav:"chat_id"`
+			}
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("membership store: unmarshal user chat: %w", err)
+			}
+			chats = append(chats, item.ChatID)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = out.LastEvaluatedKey
+	}
+
+	seen := map[string]struct{}{userID: {}}
+	var contacts []string
+	for _, chatID := range chats {
+		members, err := s.Members(ctx, chatID)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		for _, id := range members {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				contacts = append(contacts, id)
+			}
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("user.chats", len(chats)),
+		attribute.Int("user.contacts", len(contacts)),
+	)
+	return contacts, nil
+}
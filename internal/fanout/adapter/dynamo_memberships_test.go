@@ -0,0 +1,105 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// stubMembershipDynamo serves Query pages in order.
+type stubMembershipDynamo struct {
+	pages   []*dynamo.QueryOutput
+	queries []*dynamo.QueryInput
+	err     error
+}
+
+func (s *stubMembershipDynamo) GetItem(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return nil, errors.New("unexpected GetItem")
+}
+
+func (s *stubMembershipDynamo) Query(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.queries = append(s.queries, params)
+	return s.pages[len(s.queries)-1], nil
+}
+
+var _ membershipDynamoDB = (*stubMembershipDynamo)(nil)
+
+func memberAV(userID string) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{"user_id": &dynamo.AttributeValueMemberS{Value: userID}}
+}
+
+func TestMembershipStore_IsMember(t *testing.T) {
+	ctx := context.Background()
+	store := NewMembershipStore(newFakeSMSDynamo(), "chat_memberships")
+
+	member, err := store.IsMember(ctx, "chat-1", "alice")
+	require.NoError(t, err)
+	assert.True(t, member)
+
+	member, err = store.IsMember(ctx, "chat-1", "bob")
+	require.NoError(t, err)
+	assert.False(t, member)
+}
+
+func TestMembershipStore_Members(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads every page consistently", func(t *testing.T) {
+		lastKey := map[string]dynamo.AttributeValue{"chat_id": &dynamo.AttributeValueMemberS{Value: "chat-1"}}
+		db := &stubMembershipDynamo{pages: []*dynamo.QueryOutput{
+			{Items: []map[string]dynamo.AttributeValue{memberAV("alice"), memberAV("bob")}, LastEvaluatedKey: lastKey},
+			{Items: []map[string]dynamo.AttributeValue{memberAV("carol")}},
+		}}
+		store := NewMembershipStore(db, "chat_memberships")
+
+		members, err := store.Members(ctx, "chat-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "bob", "carol"}, members)
+		require.Len(t, db.queries, 2)
+		q := db.queries[0]
+		assert.Equal(t, "chat_memberships", *q.TableName)
+		assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "chat-1"}, q.ExpressionAttributeValues[":cid"])
+		assert.Equal(t, "attribute_not_exists(#status)", *q.FilterExpression, "pending join requests are not members")
+		assert.True(t, *q.ConsistentRead)
+		assert.Equal(t, lastKey, db.queries[1].ExclusiveStartKey)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{err: errors.New("throttled")}, "chat_memberships")
+
+		_, err := store.Members(ctx, "chat-1")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
+
+func TestMembershipStore_Contacts(t *testing.T) {
+	chatAV := func(chatID string) map[string]dynamo.AttributeValue {
+		return map[string]dynamo.AttributeValue{"chat_id": &dynamo.AttributeValueMemberS{Value: chatID}}
+	}
+	db := &stubMembershipDynamo{pages: []*dynamo.QueryOutput{
+		{Items: []map[string]dynamo.AttributeValue{chatAV("chat-1"), chatAV("chat-2")}},
+		{Items: []map[string]dynamo.AttributeValue{memberAV("alice"), memberAV("bob")}},
+		{Items: []map[string]dynamo.AttributeValue{memberAV("alice"), memberAV("carol"), memberAV("bob")}},
+	}}
+	store := NewMembershipStore(db, "chat_memberships")
+
+	contacts, err := store.Contacts(context.Background(), "alice")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob", "carol"}, contacts, "once each, without the user")
+	require.Len(t, db.queries, 3)
+	q := db.queries[0]
+	assert.Equal(t, "user_chats-index", *q.IndexName)
+	assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "alice"}, q.ExpressionAttributeValues[":uid"])
+	assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "chat-2"}, db.queries[2].ExpressionAttributeValues[":cid"])
+}
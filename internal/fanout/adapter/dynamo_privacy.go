@@ -0,0 +1,123 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// Compile-time check: PrivacyStore satisfies app.PrivacyStore.
+var _ app.PrivacyStore = (*PrivacyStore)(nil)
+
+// maxBatchGetKeys is DynamoDB's per-request BatchGetItem key limit.
+const maxBatchGetKeys = 100
+
+// privacyDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the privacy store.
+type privacyDynamoDB interface {
+	BatchGetItem(ctx context.Context, params *dynamo.BatchGetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.BatchGetItemOutput, error)
+}
+
+// privacyItem is the projection of a users item holding privacy settings.
+// Absent attributes read as false, so users who never changed a setting
+// share everything.
+type privacyItem struct {
+	UserID           string `dynamodbav:"user_id"`
+	HideReadReceipts bool   `dynamodbav:"hide_read_receipts"`
+	HideTyping       bool   `dynamodbav:"hide_typing"`
+	HideLastSeen     bool   `dynamodbav:"hide_last_seen"`
+}
+
+// PrivacyStore reads privacy settings from the users table.
+type PrivacyStore struct {
+	db        privacyDynamoDB
+	tableName string
+}
+
+// NewPrivacyStore creates a PrivacyStore backed by the given DynamoDB client.
+func NewPrivacyStore(db privacyDynamoDB, tableName string) *PrivacyStore {
+	return &PrivacyStore{db: db, tableName: tableName}
+}
+
+// PrivacySettings batch-reads the settings of userIDs. Eventually consistent
+// reads are fine here: a settings change applies to signals sent after it
+// propagates.
+func (s *PrivacyStore) PrivacySettings(ctx context.Context, userIDs []string) (map[string]domain.PrivacySettings, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.privacy_settings")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "BatchGetItem"),
+		attribute.Int("users.count", len(userIDs)),
+	)
+
+	settings := make(map[string]domain.PrivacySettings, len(userIDs))
+	for chunk := range slices.Chunk(dedupe(userIDs), maxBatchGetKeys) {
+		if err := s.readChunk(ctx, chunk, settings); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// readChunk reads up to maxBatchGetKeys users into settings, re-requesting
+// unprocessed keys until none remain.
+func (s *PrivacyStore) readChunk(ctx context.Context, userIDs []string, settings map[string]domain.PrivacySettings) error {
+	keys := make([]map[string]dynamo.AttributeValue, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = map[string]dynamo.AttributeValue{"user_id": &dynamo.AttributeValueMemberS{Value: id}}
+	}
+	projection := "user_id, hide_read_receipts, hide_typing, hide_last_seen"
+	request := map[string]dynamo.KeysAndAttributes{
+		s.tableName: {Keys: keys, ProjectionExpression: &projection},
+	}
+
+	for len(request) > 0 {
+		out, err := s.db.BatchGetItem(ctx, &dynamo.BatchGetItemInput{RequestItems: request})
+		if err != nil {
+			return fmt.Errorf("privacy store: batch get: %w", err)
+		}
+		for _, av := range out.Responses[s.tableName] {
+			var item privacyItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return fmt.Errorf("privacy store: unmarshal settings: %w", err)
+			}
+			settings[item.UserID] = domain.PrivacySettings{
+				HideReadReceipts: item.HideReadReceipts,
+				HideTyping:       item.HideTyping,
+				HideLastSeen:     item.HideLastSeen,
+			}
+		}
+		request = out.UnprocessedKeys
+
+		// Check context between batch rounds per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("privacy store: batch get: %w", err)
+		}
+	}
+	return nil
+}
+
+// dedupe returns ids without repeats, in first-seen order. BatchGetItem
+// rejects requests with duplicate keys.
+func dedupe(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
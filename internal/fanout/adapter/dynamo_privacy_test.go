@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — an in-memory users table implementing privacyDynamoDB.
+// ---------------------------------------------------------------------------
+
+type fakePrivacyDynamo struct {
+	users       map[string]privacyItem
+	unprocessed int   // keys to defer on the first call
+	batches     []int // key count of each call
+	err         error
+}
+
+func (f *fakePrivacyDynamo) BatchGetItem(_ context.Context, params *dynamo.BatchGetItemInput, _ ...func(*dynamo.Options)) (*dynamo.BatchGetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	req := params.RequestItems[usersTable]
+	f.batches = append(f.batches, len(req.Keys))
+	if len(req.Keys) > maxBatchGetKeys {
+		return nil, errors.New("too many keys")
+	}
+
+	keys := req.Keys
+	out := &dynamo.BatchGetItemOutput{Responses: map[string][]map[string]dynamo.AttributeValue{}}
+	if f.unprocessed > 0 {
+		n := min(f.unprocessed, len(keys))
+		f.unprocessed = 0
+		out.UnprocessedKeys = map[string]dynamo.KeysAndAttributes{
+			usersTable: {Keys: keys[:n], ProjectionExpression: req.ProjectionExpression},
+		}
+		keys = keys[n:]
+	}
+	for _, key := range keys {
+		item, ok := f.users[key["user_id"].(*dynamo.AttributeValueMemberS).Value]
+		if !ok {
+			continue
+		}
+		av, err := dynamo.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		out.Responses[usersTable] = append(out.Responses[usersTable], av)
+	}
+	return out, nil
+}
+
+var _ privacyDynamoDB = (*fakePrivacyDynamo)(nil)
+
+const usersTable = "users"
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestPrivacyStore_PrivacySettings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads settings; unknown users are absent", func(t *testing.T) {
+		db := &fakePrivacyDynamo{users: map[string]privacyItem{
+			"alice": {UserID: "alice", HideTyping: true},
+			"bob":   {UserID: "bob", HideReadReceipts: true, HideLastSeen: true},
+		}}
+		store := NewPrivacyStore(db, usersTable)
+
+		got, err := store.PrivacySettings(ctx, []string{"alice", "bob", "carol", "alice"})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]domain.PrivacySettings{
+			"alice": {HideTyping: true},
+			"bob":   {HideReadReceipts: true, HideLastSeen: true},
+		}, got)
+		assert.Equal(t, []int{3}, db.batches, "duplicates are removed")
+	})
+
+	t.Run("splits large requests", func(t *testing.T) {
+		db := &fakePrivacyDynamo{users: map[string]privacyItem{}}
+		ids := make([]string, 250)
+		for i := range ids {
+			ids[i] = "user-" + strconv.Itoa(i)
+		}
+		store := NewPrivacyStore(db, usersTable)
+
+		_, err := store.PrivacySettings(ctx, ids)
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{100, 100, 50}, db.batches)
+	})
+
+	t.Run("retries unprocessed keys", func(t *testing.T) {
+		db := &fakePrivacyDynamo{
+			users:       map[string]privacyItem{"alice": {UserID: "alice", HideTyping: true}},
+			unprocessed: 1,
+		}
+		store := NewPrivacyStore(db, usersTable)
+
+		got, err := store.PrivacySettings(ctx, []string{"alice", "bob"})
+
+		require.NoError(t, err)
+		assert.True(t, got["alice"].HideTyping)
+		assert.Equal(t, []int{2, 1}, db.batches)
+	})
+
+	t.Run("read failure", func(t *testing.T) {
+		store := NewPrivacyStore(&fakePrivacyDynamo{err: errors.New("throttled")}, usersTable)
+
+		_, err := store.PrivacySettings(ctx, []string{"alice"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "privacy store: batch get")
+	})
+}
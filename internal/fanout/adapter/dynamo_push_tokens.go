@@ -0,0 +1,129 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// Compile-time check: PushTokenStore satisfies app.DeviceTokenStore.
+var _ app.DeviceTokenStore = (*PushTokenStore)(nil)
+
+// pushTokenDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the push token store.
+type pushTokenDynamoDB interface {
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	DeleteItem(ctx context.Context, params *dynamo.DeleteItemInput, optFns ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error)
+}
+
+// pushTokenItem is the DynamoDB item shape for the device_tokens table
+// (PK user_id, SK device_id).
+type pushTokenItem struct {
+	UserID    string `dynamodbav:"user_id"`
+	DeviceID  string `dynamodbav:"device_id"`
+	Platform  string `dynamodbav:"platform"`
+	Token     string `dynamodbav:"token"`
+	P256DH    string `dynamodbav:"p256dh,omitempty"`
+	Auth      string `dynamodbav:"auth,omitempty"`
+	UpdatedAt string `dynamodbav:"updated_at"`
+}
+
+// PushTokenStore reads and prunes device tokens in the device_tokens table.
+type PushTokenStore struct {
+	db        pushTokenDynamoDB
+	tableName string
+}
+
+// NewPushTokenStore creates a PushTokenStore backed by the given DynamoDB client.
+func NewPushTokenStore(db pushTokenDynamoDB, tableName string) *PushTokenStore {
+	return &PushTokenStore{db: db, tableName: tableName}
+}
+
+// PushTokens returns every device token registered by userID. A user has a
+// handful of devices, so one page always holds them.
+func (s *PushTokenStore) PushTokens(ctx context.Context, userID string) ([]domain.DeviceToken, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.device_tokens.list")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "user_id = :uid"
+	out, err := s.db.Query(ctx, &dynamo.QueryInput{
+		TableName:              &s.tableName,
+		KeyConditionExpression: &keyExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":uid": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("push token store: list: %w", err)
+	}
+
+	tokens := make([]domain.DeviceToken, 0, len(out.Items))
+	for _, av := range out.Items {
+		var item pushTokenItem
+		if err := dynamo.UnmarshalMap(av, &item); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("push token store: unmarshal token: %w", err)
+		}
+		// A malformed timestamp only loses UpdatedAt, which delivery ignores.
+		updatedAt, _ := time.Parse(time.RFC3339, item.UpdatedAt)
+		tokens = append(tokens, domain.DeviceToken{
+			UserID:    item.UserID,
+			DeviceID:  item.DeviceID,
+			Platform:  domain.PushPlatform(item.Platform),
+			Token:     item.Token,
+			P256DH:    item.P256DH,
+			Auth:      item.Auth,
+			UpdatedAt: updatedAt,
+		})
+	}
+	return tokens, nil
+}
+
+// DeletePushToken removes the device's token if it still equals token. A
+// device that registered a new token since the push was sent keeps it, so
+// the condition failing is not an error.
+func (s *PushTokenStore) DeletePushToken(ctx context.Context, userID, deviceID, token string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.device_tokens.delete")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "DeleteItem"),
+	)
+
+	condExpr := "#token = :token"
+	_, err := s.db.DeleteItem(ctx, &dynamo.DeleteItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id":   &dynamo.AttributeValueMemberS{Value: userID},
+			"device_id": &dynamo.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression:      &condExpr,
+		ExpressionAttributeNames: map[string]string{"#token": "token"},
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":token": &dynamo.AttributeValueMemberS{Value: token},
+		},
+	})
+	if err != nil {
+		if dynamo.IsConditionalCheckFailed(err) {
+			return nil
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("push token store: delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — an in-memory device_tokens table implementing pushTokenDynamoDB.
+// ---------------------------------------------------------------------------
+
+type fakePushTokenDynamo struct {
+	items map[[2]string]pushTokenItem // by (user_id, device_id)
+	err   error
+}
+
+func (f *fakePushTokenDynamo) Query(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	userID := params.ExpressionAttributeValues[":uid"].(*dynamo.AttributeValueMemberS).Value
+	out := &dynamo.QueryOutput{}
+	for key, item := range f.items {
+		if key[0] != userID {
+			continue
+		}
+		av, err := dynamo.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, av)
+	}
+	return out, nil
+}
+
+func (f *fakePushTokenDynamo) DeleteItem(_ context.Context, params *dynamo.DeleteItemInput, _ ...func(*dynamo.Options)) (*dynamo.DeleteItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key := [2]string{
+		params.Key["user_id"].(*dynamo.AttributeValueMemberS).Value,
+		params.Key["device_id"].(*dynamo.AttributeValueMemberS).Value,
+	}
+	want := params.ExpressionAttributeValues[":token"].(*dynamo.AttributeValueMemberS).Value
+	if item, ok := f.items[key]; !ok || item.Token != want {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	delete(f.items, key)
+	return &dynamo.DeleteItemOutput{}, nil
+}
+
+var _ pushTokenDynamoDB = (*fakePushTokenDynamo)(nil)
+
+const deviceTokensTable = "device_tokens"
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestPushTokenStore_PushTokens(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lists the user's devices", func(t *testing.T) {
+		db := &fakePushTokenDynamo{items: map[[2]string]pushTokenItem{
+			{"alice", "phone"}: {UserID: "alice", DeviceID: "phone", Platform: "apns", Token: "apns-1", UpdatedAt: "2026-03-02T12:00:00Z"},
+			{"bob", "phone"}:   {UserID: "bob", DeviceID: "phone", Platform: "fcm", Token: "fcm-1"},
+		}}
+		store := NewPushTokenStore(db, deviceTokensTable)
+
+		got, err := store.PushTokens(ctx, "alice")
+
+		require.NoError(t, err)
+		assert.Equal(t, []domain.DeviceToken{{
+			UserID:    "alice",
+			DeviceID:  "phone",
+			Platform:  domain.PushPlatformAPNs,
+			Token:     "apns-1",
+			UpdatedAt: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+		}}, got)
+	})
+
+	t.Run("webpush subscription keys", func(t *testing.T) {
+		db := &fakePushTokenDynamo{items: map[[2]string]pushTokenItem{
+			{"alice", "browser"}: {UserID: "alice", DeviceID: "browser", Platform: "webpush",
+				Token: "https://fcm.googleapis.com/fcm/send/abc", P256DH: "p256dh-key", Auth: "auth-secret"},
+		}}
+		store := NewPushTokenStore(db, deviceTokensTable)
+
+		got, err := store.PushTokens(ctx, "alice")
+
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, domain.PushPlatformWebPush, got[0].Platform)
+		assert.Equal(t, "p256dh-key", got[0].P256DH)
+		assert.Equal(t, "auth-secret", got[0].Auth)
+	})
+
+	t.Run("read failure", func(t *testing.T) {
+		store := NewPushTokenStore(&fakePushTokenDynamo{err: errors.New("throttled")}, deviceTokensTable)
+
+		_, err := store.PushTokens(ctx, "alice")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "push token store: list")
+	})
+}
+
+func TestPushTokenStore_DeletePushToken(t *testing.T) {
+	ctx := context.Background()
+	newDB := func() *fakePushTokenDynamo {
+		return &fakePushTokenDynamo{items: map[[2]string]pushTokenItem{
+			{"alice", "phone"}: {UserID: "alice", DeviceID: "phone", Platform: "apns", Token: "apns-2"},
+		}}
+	}
+
+	t.Run("deletes the matching token", func(t *testing.T) {
+		db := newDB()
+		store := NewPushTokenStore(db, deviceTokensTable)
+
+		require.NoError(t, store.DeletePushToken(ctx, "alice", "phone", "apns-2"))
+
+		assert.Empty(t, db.items)
+	})
+
+	t.Run("keeps a refreshed token", func(t *testing.T) {
+		db := newDB()
+		store := NewPushTokenStore(db, deviceTokensTable)
+
+		require.NoError(t, store.DeletePushToken(ctx, "alice", "phone", "apns-1"))
+
+		assert.Len(t, db.items, 1)
+	})
+
+	t.Run("delete failure", func(t *testing.T) {
+		store := NewPushTokenStore(&fakePushTokenDynamo{err: errors.New("throttled")}, deviceTokensTable)
+
+		err := store.DeletePushToken(ctx, "alice", "phone", "apns-2")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "push token store: delete")
+	})
+}
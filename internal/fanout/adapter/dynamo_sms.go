@@ -0,0 +1,350 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// Compile-time checks: the DynamoDB SMS stores satisfy their app interfaces.
+var (
+	_ app.SMSRecipientStore = (*SMSUserStore)(nil)
+	_ app.SMSAccounts       = (*SMSUserStore)(nil)
+	_ app.SenderNames       = (*SMSUserStore)(nil)
+)
+
+// smsDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the SMS stores.
+type smsDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+// smsUserItem is the projection of a users item SMS fallback reads. The
+// threshold is stored in seconds; absent attributes read as off and the
+// default threshold.
+type smsUserItem struct {
+	UserID       string `dynamodbav:"user_id"`
+	PhoneNumber  string `dynamodbav:"phone_number"`
+	DisplayName  string `dynamodbav:"display_name,omitempty"`
+	Enabled      bool   `dynamodbav:"sms_fallback_enabled"`
+	OfflineAfter int64  `dynamodbav:"sms_fallback_offline_after"`
+}
+
+func (i smsUserItem) settings() domain.SMSFallback {
+	return domain.SMSFallback{Enabled: i.Enabled, OfflineAfter: time.Duration(i.OfflineAfter) * time.Second}
+}
+
+// sessionActivityItem is the projection of a sessions item that dates the
+// user's last activity. Sessions the Gateway never touched date from
+// creation.
+type sessionActivityItem struct {
+	CreatedAt    string `dynamodbav:"created_at"`
+	LastActiveAt string `dynamodbav:"last_active_at"`
+}
+
+// Attribute names of the users table's encrypted fields; they name the
+// field to the field cipher, and the phone to the blind index.
+const (
+	phoneField       = "phone_number"
+	displayNameField = "display_name"
+)
+
+// The users table's phone indexes: phone_index-index keyed by the phone's
+// blind index, and legacy phone_number-index keyed by the phone as stored.
+const (
+	phoneIndexName       = "phone_index-index"
+	legacyPhoneIndexName = "phone_number-index"
+)
+
+// SMSUserStore reads SMS fallback settings from the users table and
+// activity from the sessions table.
+type SMSUserStore struct {
+	db            smsDynamoDB
+	usersTable    string
+	sessionsTable string
+	fields        *msgcrypt.FieldCipher
+	phones        *msgcrypt.BlindIndex
+	legacyLookup  bool
+}
+
+// NewSMSUserStore creates an SMSUserStore backed by the given DynamoDB
+// client. Phone numbers encrypted at rest are read with fields, which may
+// be nil when the users table holds plaintext, and looked up by their
+// blind index from phones. With legacyLookup, users phone_index has not
+// been backfilled for are looked up in phone_number-index.
+func NewSMSUserStore(db smsDynamoDB, usersTable, sessionsTable string, fields *msgcrypt.FieldCipher, phones *msgcrypt.BlindIndex, legacyLookup bool) *SMSUserStore {
+	return &SMSUserStore{
+		db:            db,
+		usersTable:    usersTable,
+		sessionsTable: sessionsTable,
+		fields:        fields,
+		phones:        phones,
+		legacyLookup:  legacyLookup,
+	}
+}
+
+// SMSRecipient returns userID's phone, setting and latest session
+// activity. Returns domain.ErrNotFound when the user does not exist.
+func (s *SMSUserStore) SMSRecipient(ctx context.Context, userID string) (app.SMSRecipient, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.sms_recipient")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem+Query"),
+	)
+
+	item, err := s.user(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.SMSRecipient{}, err
+	}
+	r := app.SMSRecipient{Phone: item.PhoneNumber, Settings: item.settings()}
+	if !r.Settings.Enabled {
+		return r, nil // activity is only read for users who opted in
+	}
+
+	// Check context between multi-step operations per 04_CONTEXT.
+	if err := ctx.Err(); err != nil {
+		return app.SMSRecipient{}, fmt.Errorf("sms user store: recipient: %w", err)
+	}
+	if r.LastActiveAt, err = s.lastActive(ctx, userID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.SMSRecipient{}, err
+	}
+	return r, nil
+}
+
+func (s *SMSUserStore) user(ctx context.Context, userID string) (smsUserItem, error) {
+	projection := "user_id, phone_number, sms_fallback_enabled, sms_fallback_offline_after"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.usersTable,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		return smsUserItem{}, fmt.Errorf("sms user store: get user: %w", err)
+	}
+	if out.Item == nil {
+		return smsUserItem{}, fmt.Errorf("sms user store: get user: %w", domain.ErrNotFound)
+	}
+	var item smsUserItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		return smsUserItem{}, fmt.Errorf("sms user store: unmarshal user: %w", err)
+	}
+	if item.PhoneNumber, err = s.fields.Decrypt(ctx, phoneField, item.PhoneNumber); err != nil {
+		return smsUserItem{}, fmt.Errorf("sms user store: %w", err)
+	}
+	return item, nil
+}
+
+// DisplayName returns userID's display name, which SMS fallback signs
+// texts with. Returns domain.ErrNotFound when the user does not exist.
+func (s *SMSUserStore) DisplayName(ctx context.Context, userID string) (string, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.display_name")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "display_name"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.usersTable,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("sms user store: get display name: %w", err)
+	}
+	if out.Item == nil {
+		return "", fmt.Errorf("sms user store: get display name: %w", domain.ErrNotFound)
+	}
+	var item smsUserItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		return "", fmt.Errorf("sms user store: unmarshal display name: %w", err)
+	}
+	name, err := s.fields.Decrypt(ctx, displayNameField, item.DisplayName)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("sms user store: %w", err)
+	}
+	return name, nil
+}
+
+// lastActive returns the latest activity across userID's sessions, or zero
+// when they have none. A user has a handful of sessions, so one page
+// always holds them.
+func (s *SMSUserStore) lastActive(ctx context.Context, userID string) (time.Time, error) {
+	indexName := "user_sessions-index"
+	keyExpr := "user_id = :uid"
+	projection := "created_at, last_active_at"
+	out, err := s.db.Query(ctx, &dynamo.QueryInput{
+		TableName:              &s.sessionsTable,
+		IndexName:              &indexName,
+		KeyConditionExpression: &keyExpr,
+		ProjectionExpression:   &projection,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":uid": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("sms user store: query sessions: %w", err)
+	}
+
+	var latest time.Time
+	for _, av := range out.Items {
+		var item sessionActivityItem
+		if err := dynamo.UnmarshalMap(av, &item); err != nil {
+			return time.Time{}, fmt.Errorf("sms user store: unmarshal session: %w", err)
+		}
+		raw := item.LastActiveAt
+		if raw == "" {
+			raw = item.CreatedAt
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			continue // an undated session says nothing about activity
+		}
+		if at.After(latest) {
+			latest = at
+		}
+	}
+	return latest, nil
+}
+
+// UserByPhone looks up the user registered with phone via the
+// phone_index-index GSI and, with legacy lookup on, phone_number-index,
+// trying each value the phone may be stored as. Returns domain.ErrNotFound
+// when there is none.
+func (s *SMSUserStore) UserByPhone(ctx context.Context, phone string) (string, domain.SMSFallback, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.sms_user_by_phone")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query+GetItem"),
+	)
+
+	out, err := s.queryPhone(ctx, phoneIndexName, "phone_index", s.phones.Token(phoneField, phone))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", domain.SMSFallback{}, err
+	}
+	if len(out.Items) == 0 && s.legacyLookup {
+		values, err := s.fields.SearchValues(ctx, phoneField, phone)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return "", domain.SMSFallback{}, fmt.Errorf("sms user store: user by phone: %w", err)
+		}
+		for _, value := range values {
+			if out, err = s.queryPhone(ctx, legacyPhoneIndexName, phoneField, value); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return "", domain.SMSFallback{}, err
+			}
+			if len(out.Items) > 0 {
+				break
+			}
+		}
+	}
+	if len(out.Items) == 0 {
+		return "", domain.SMSFallback{}, fmt.Errorf("sms user store: user by phone: %w", domain.ErrNotFound)
+	}
+	var projected struct {
+		UserID string `dynamodbav:"user_id"`
+	}
+	if err := dynamo.UnmarshalMap(out.Items[0], &projected); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", domain.SMSFallback{}, fmt.Errorf("sms user store: unmarshal gsi projection: %w", err)
+	}
+
+	// Check context between multi-step operations per 04_CONTEXT.
+	if err := ctx.Err(); err != nil {
+		return "", domain.SMSFallback{}, fmt.Errorf("sms user store: user by phone: %w", err)
+	}
+	// The GSI may not project the settings; read them from the table.
+	item, err := s.user(ctx, projected.UserID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", domain.SMSFallback{}, err
+	}
+	return item.UserID, item.settings(), nil
+}
+
+// queryPhone queries indexName for users whose attribute holds value.
+// Legacy phone sentinels, which phone_number-index also holds, are
+// filtered out.
+func (s *SMSUserStore) queryPhone(ctx context.Context, indexName, attribute, value string) (*dynamo.QueryOutput, error) {
+	keyExpr := attribute + " = :phone"
+	filterExpr := "NOT begins_with(user_id, :sentinel)"
+	out, err := s.db.Query(ctx, &dynamo.QueryInput{
+		TableName:              &s.usersTable,
+		IndexName:              &indexName,
+		KeyConditionExpression: &keyExpr,
+		FilterExpression:       &filterExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":phone":    &dynamo.AttributeValueMemberS{Value: value},
+			":sentinel": &dynamo.AttributeValueMemberS{Value: "phone#"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sms user store: query phone: %w", err)
+	}
+	return out, nil
+}
+
+// DisableSMSFallback turns userID's SMS fallback off, keeping the
+// threshold for when they turn it back on, and bumps their settings
+// version so clients revalidate their cached copy. A user who no longer exists
+// is not an error.
+func (s *SMSUserStore) DisableSMSFallback(ctx context.Context, userID string) error {
+	ctx, span := tracer.Start(ctx, "dynamo.users.disable_sms_fallback")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	updateExpr := "SET sms_fallback_enabled = :off ADD settings_version :one"
+	condExpr := "attribute_exists(user_id)"
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.usersTable,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":off": &dynamo.AttributeValueMemberBOOL{Value: false},
+			":one": &dynamo.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil && !dynamo.IsConditionalCheckFailed(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("sms user store: disable: %w", err)
+	}
+	return nil
+}
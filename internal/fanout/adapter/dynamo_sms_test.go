@@ -0,0 +1,248 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt/msgcrypttest"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — in-memory users, sessions and chat_memberships tables implementing
+// smsDynamoDB.
+// ---------------------------------------------------------------------------
+
+type fakeSMSDynamo struct {
+	users    map[string]smsUserItem
+	indexed  map[string]string                // phone_index of the users that have one
+	sessions map[string][]sessionActivityItem // by user_id
+	members  map[[2]string]bool               // by (chat_id, user_id)
+	updates  []string                         // user IDs updated
+	err      error
+}
+
+func (f *fakeSMSDynamo) GetItem(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	userID := params.Key["user_id"].(*dynamo.AttributeValueMemberS).Value
+	if chat, ok := params.Key["chat_id"]; ok {
+		if !f.members[[2]string{chat.(*dynamo.AttributeValueMemberS).Value, userID}] {
+			return &dynamo.GetItemOutput{}, nil
+		}
+		return &dynamo.GetItemOutput{Item: params.Key}, nil
+	}
+	item, ok := f.users[userID]
+	if !ok {
+		return &dynamo.GetItemOutput{}, nil
+	}
+	av, err := dynamo.MarshalMap(item)
+	return &dynamo.GetItemOutput{Item: av}, err
+}
+
+func (f *fakeSMSDynamo) Query(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := &dynamo.QueryOutput{}
+	if phone, ok := params.ExpressionAttributeValues[":phone"]; ok {
+		value := phone.(*dynamo.AttributeValueMemberS).Value
+		for _, u := range f.users {
+			stored := u.PhoneNumber
+			if *params.IndexName == phoneIndexName {
+				stored = f.indexed[u.UserID]
+			}
+			if stored != "" && stored == value {
+				out.Items = append(out.Items, map[string]dynamo.AttributeValue{
+					"user_id":      &dynamo.AttributeValueMemberS{Value: u.UserID},
+					"phone_number": &dynamo.AttributeValueMemberS{Value: u.PhoneNumber},
+				})
+			}
+		}
+		return out, nil
+	}
+	userID := params.ExpressionAttributeValues[":uid"].(*dynamo.AttributeValueMemberS).Value
+	for _, s := range f.sessions[userID] {
+		av, err := dynamo.MarshalMap(s)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, av)
+	}
+	return out, nil
+}
+
+func (f *fakeSMSDynamo) UpdateItem(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	userID := params.Key["user_id"].(*dynamo.AttributeValueMemberS).Value
+	item, ok := f.users[userID]
+	if !ok {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	item.Enabled = params.ExpressionAttributeValues[":off"].(*dynamo.AttributeValueMemberBOOL).Value
+	f.users[userID] = item
+	f.updates = append(f.updates, userID)
+	return &dynamo.UpdateItemOutput{}, nil
+}
+
+var _ smsDynamoDB = (*fakeSMSDynamo)(nil)
+
+func newFakeSMSDynamo() *fakeSMSDynamo {
+	return &fakeSMSDynamo{
+		users: map[string]smsUserItem{
+			"alice": {UserID: "alice", PhoneNumber: "+14155550100", Enabled: true, OfflineAfter: 3600},
+			"bob":   {UserID: "bob", PhoneNumber: "+14155550101"},
+		},
+		sessions: map[string][]sessionActivityItem{
+			"alice": {
+				{CreatedAt: "2026-09-01T08:00:00Z", LastActiveAt: "2026-10-01T09:30:00Z"},
+				{CreatedAt: "2026-10-01T10:00:00Z"}, // never active
+				{CreatedAt: "2026-09-20T08:00:00Z", LastActiveAt: "2026-09-21T08:00:00Z"},
+			},
+		},
+		members: map[[2]string]bool{{"chat-1", "alice"}: true},
+		indexed: map[string]string{"alice": testPhones.Token(phoneField, "+14155550100")},
+	}
+}
+
+// testPhones derives phone blind indexes in tests.
+var testPhones = msgcrypt.NewBlindIndex([]byte("test-pepper"))
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestSMSUserStore_SMSRecipient(t *testing.T) {
+	ctx := context.Background()
+	store := NewSMSUserStore(newFakeSMSDynamo(), "users", "sessions", nil, testPhones, true)
+
+	t.Run("settings and latest activity", func(t *testing.T) {
+		r, err := store.SMSRecipient(ctx, "alice")
+
+		require.NoError(t, err)
+		assert.Equal(t, "+14155550100", r.Phone)
+		assert.Equal(t, domain.SMSFallback{Enabled: true, OfflineAfter: time.Hour}, r.Settings)
+		assert.Equal(t, time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC), r.LastActiveAt)
+	})
+
+	t.Run("absent settings read as off", func(t *testing.T) {
+		r, err := store.SMSRecipient(ctx, "bob")
+
+		require.NoError(t, err)
+		assert.Equal(t, domain.SMSFallback{}, r.Settings)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := store.SMSRecipient(ctx, "ghost")
+
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		db := newFakeSMSDynamo()
+		db.err = errors.New("throttled")
+
+		_, err := NewSMSUserStore(db, "users", "sessions", nil, testPhones, true).SMSRecipient(ctx, "alice")
+
+		require.Error(t, err)
+	})
+}
+
+func TestSMSUserStore_UserByPhone(t *testing.T) {
+	ctx := context.Background()
+	store := NewSMSUserStore(newFakeSMSDynamo(), "users", "sessions", nil, testPhones, true)
+
+	userID, settings, err := store.UserByPhone(ctx, "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", userID)
+	assert.True(t, settings.Enabled)
+
+	userID, _, err = store.UserByPhone(ctx, "+14155550101")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", userID, "users not yet indexed are found by the legacy lookup")
+
+	_, _, err = store.UserByPhone(ctx, "+14155550199")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	noLegacy := NewSMSUserStore(newFakeSMSDynamo(), "users", "sessions", nil, testPhones, false)
+	userID, _, err = noLegacy.UserByPhone(ctx, "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", userID)
+	_, _, err = noLegacy.UserByPhone(ctx, "+14155550101")
+	require.ErrorIs(t, err, domain.ErrNotFound, "only the blind index is queried")
+}
+
+func TestSMSUserStore_EncryptedPhone(t *testing.T) {
+	ctx := context.Background()
+	fields := msgcrypttest.NewFieldCipher(t)
+	encrypted, err := fields.EncryptSearchable(ctx, phoneField, "+14155550100")
+	require.NoError(t, err)
+	db := newFakeSMSDynamo()
+	alice := db.users["alice"]
+	alice.PhoneNumber = encrypted
+	db.users["alice"] = alice
+	store := NewSMSUserStore(db, "users", "sessions", fields, testPhones, true)
+
+	r, err := store.SMSRecipient(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "+14155550100", r.Phone)
+
+	userID, _, err := store.UserByPhone(ctx, "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", userID)
+
+	userID, _, err = store.UserByPhone(ctx, "+14155550101")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", userID, "plaintext phones are still found")
+
+	_, err = NewSMSUserStore(db, "users", "sessions", nil, testPhones, true).SMSRecipient(ctx, "alice")
+	require.Error(t, err, "encrypted phone without a cipher")
+}
+
+func TestSMSUserStore_DisplayName(t *testing.T) {
+	ctx := context.Background()
+	fields := msgcrypttest.NewFieldCipher(t)
+	encrypted, err := fields.Encrypt(ctx, displayNameField, "Alice")
+	require.NoError(t, err)
+	db := newFakeSMSDynamo()
+	alice := db.users["alice"]
+	alice.DisplayName = encrypted
+	db.users["alice"] = alice
+	bob := db.users["bob"]
+	bob.DisplayName = "Bob"
+	db.users["bob"] = bob
+	store := NewSMSUserStore(db, "users", "sessions", fields, testPhones, true)
+
+	name, err := store.DisplayName(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", name)
+
+	name, err = store.DisplayName(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", name, "plaintext names are read as is")
+
+	_, err = store.DisplayName(ctx, "ghost")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestSMSUserStore_DisableSMSFallback(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeSMSDynamo()
+	store := NewSMSUserStore(db, "users", "sessions", nil, testPhones, true)
+
+	require.NoError(t, store.DisableSMSFallback(ctx, "alice"))
+	assert.False(t, db.users["alice"].Enabled)
+	assert.Equal(t, int64(3600), db.users["alice"].OfflineAfter, "threshold is kept")
+
+	require.NoError(t, store.DisableSMSFallback(ctx, "ghost"), "deleted users are not an error")
+}
@@ -0,0 +1,45 @@
+package adapter
+
+import (
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+// ConsumerStatusSource is the subset of *kafka.Client KafkaLagSource needs.
+type ConsumerStatusSource interface {
+	ConsumerStatus() kafka.ConsumerStatus
+}
+
+// KafkaLagSource reports the Fanout consumer group member's lag from its
+// Kafka client.
+type KafkaLagSource struct {
+	client ConsumerStatusSource
+}
+
+// NewKafkaLagSource creates a KafkaLagSource.
+func NewKafkaLagSource(client ConsumerStatusSource) *KafkaLagSource {
+	return &KafkaLagSource{client: client}
+}
+
+// ConsumerLag implements app.LagSource.
+func (s *KafkaLagSource) ConsumerLag() app.ConsumerLag {
+	st := s.client.ConsumerStatus()
+	out := app.ConsumerLag{
+		Group:          st.Group,
+		Partitions:     make([]app.PartitionLag, len(st.Partitions)),
+		Rebalances:     st.Rebalances,
+		PartitionsLost: st.PartitionsLost,
+		LastRebalance:  st.LastRebalance,
+	}
+	for i, p := range st.Partitions {
+		out.Partitions[i] = app.PartitionLag{
+			Topic:         p.Topic,
+			Partition:     p.Partition,
+			HighWatermark: p.HighWatermark,
+			Committed:     p.Committed,
+			Lag:           p.Lag,
+			LagAge:        p.LagAge,
+		}
+	}
+	return out
+}
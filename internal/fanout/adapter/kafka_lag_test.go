@@ -0,0 +1,38 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+type stubStatusSource kafka.ConsumerStatus
+
+func (s stubStatusSource) ConsumerStatus() kafka.ConsumerStatus { return kafka.ConsumerStatus(s) }
+
+func TestKafkaLagSource(t *testing.T) {
+	rebalanced := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	src := NewKafkaLagSource(stubStatusSource{
+		Group: "fanout",
+		Partitions: []kafka.PartitionStatus{
+			{Topic: "messages.persisted", Partition: 3, HighWatermark: 120, Committed: 100, Lag: 20, LagAge: time.Second},
+		},
+		Rebalances:     2,
+		PartitionsLost: 1,
+		LastRebalance:  rebalanced,
+	})
+
+	assert.Equal(t, app.ConsumerLag{
+		Group: "fanout",
+		Partitions: []app.PartitionLag{
+			{Topic: "messages.persisted", Partition: 3, HighWatermark: 120, Committed: 100, Lag: 20, LagAge: time.Second},
+		},
+		Rebalances:     2,
+		PartitionsLost: 1,
+		LastRebalance:  rebalanced,
+	}, src.ConsumerLag())
+}
@@ -0,0 +1,86 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Compile-time check: GatewayChannels satisfies app.GatewayPublisher.
+var _ app.GatewayPublisher = (*GatewayChannels)(nil)
+
+// deliveryMessage is the JSON published on a Gateway's delivery channel
+// (ADR-002 §3.4). A "deliver" carries Message, a protocol.Message embedded
+// as encoded by the dispatcher; a "signal" carries Frame, an encoded
+// typing, receipt or presence frame. The trace context lets the Gateway
+// continue this trace.
+type deliveryMessage struct {
+	Type        string          `json:"type"` // "deliver" or "signal"
+	UserIDs     []string        `json:"user_ids"`
+	Message     json.RawMessage `json:"message,omitempty"`
+	Frame       json.RawMessage `json:"frame,omitempty"`
+	TraceParent string          `json:"traceparent,omitempty"`
+	TraceState  string          `json:"tracestate,omitempty"`
+}
+
+// GatewayChannels publishes deliveries on the Gateways' Redis Pub/Sub
+// channels, domain.GatewayDeliverChannel. Pub/Sub is fire and forget: a
+// Gateway not subscribed when a delivery is published never sees it.
+type GatewayChannels struct {
+	cmd redisclient.Cmdable
+}
+
+// NewGatewayChannels creates a GatewayChannels.
+func NewGatewayChannels(cmd redisclient.Cmdable) *GatewayChannels {
+	return &GatewayChannels{cmd: cmd}
+}
+
+// Publish publishes d on gatewayID's delivery channel, with d.Payload as
+// the message or, for a signal, d.Signal as the frame.
+func (g *GatewayChannels) Publish(ctx context.Context, gatewayID string, d app.Delivery) error {
+	ctx, span := tracer.Start(ctx, "redis.gateways.publish")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "PUBLISH"),
+		attribute.String("gateway.id", gatewayID),
+	)
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	m := deliveryMessage{
+		Type:        "deliver",
+		UserIDs:     d.UserIDs,
+		Message:     d.Payload,
+		TraceParent: carrier["traceparent"],
+		TraceState:  carrier["tracestate"],
+	}
+	if d.Signal != nil {
+		frame, err := protocol.AppendFrame(nil, d.Signal)
+		if err != nil {
+			return fmt.Errorf("publish delivery: encode signal: %w", err)
+		}
+		m.Type, m.Message, m.Frame = "signal", nil, frame
+	}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("publish delivery: marshal: %w", err)
+	}
+
+	if err := g.cmd.Publish(ctx, domain.GatewayDeliverChannel(gatewayID), payload).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("publish delivery to %s: %w", gatewayID, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestGatewayChannels_Publish(t *testing.T) {
+	ctx := context.Background()
+	cmd, _ := newTestRedis(t)
+	sub := cmd.(*redis.Client).Subscribe(ctx, "gateway:gw-1:deliver")
+	t.Cleanup(func() { _ = sub.Close() })
+	_, err := sub.Receive(ctx) // subscription confirmed
+	require.NoError(t, err)
+
+	msg := protocol.Message{MessageID: "msg-1", ChatID: "chat-1", SenderID: "alice", Sequence: 4, ContentType: "text", Content: "hi"}
+	payload, err := json.Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, NewGatewayChannels(cmd).Publish(ctx, "gw-1", app.Delivery{UserIDs: []string{"bob"}, Message: msg, Payload: payload}))
+
+	select {
+	case m := <-sub.Channel():
+		var got struct {
+			Type    string           `json:"type"`
+			UserIDs []string         `json:"user_ids"`
+			Message protocol.Message `json:"message"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(m.Payload), &got))
+		assert.Equal(t, "deliver", got.Type)
+		assert.Equal(t, []string{"bob"}, got.UserIDs)
+		assert.Equal(t, msg, got.Message)
+		assert.Contains(t, m.Payload, `"message":`+string(payload), "the payload is embedded as encoded")
+	case <-time.After(time.Second):
+		t.Fatal("no delivery published")
+	}
+}
+
+func TestGatewayChannels_PublishSignal(t *testing.T) {
+	ctx := context.Background()
+	cmd, _ := newTestRedis(t)
+	sub := cmd.(*redis.Client).Subscribe(ctx, "gateway:gw-1:deliver")
+	t.Cleanup(func() { _ = sub.Close() })
+	_, err := sub.Receive(ctx)
+	require.NoError(t, err)
+
+	frame, err := protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-1", UserID: "alice", Active: true})
+	require.NoError(t, err)
+	require.NoError(t, NewGatewayChannels(cmd).Publish(ctx, "gw-1", app.Delivery{UserIDs: []string{"bob"}, Signal: frame}))
+
+	select {
+	case m := <-sub.Channel():
+		var got struct {
+			Type    string          `json:"type"`
+			UserIDs []string        `json:"user_ids"`
+			Message json.RawMessage `json:"message"`
+			Frame   protocol.Frame  `json:"frame"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(m.Payload), &got))
+		assert.Equal(t, "signal", got.Type)
+		assert.Equal(t, []string{"bob"}, got.UserIDs)
+		assert.Nil(t, got.Message)
+		assert.Equal(t, protocol.FrameTypeTyping, got.Frame.Type)
+		assert.JSONEq(t, `{"chat_id":"chat-1","user_id":"alice","active":true}`, string(got.Frame.Payload))
+	case <-time.After(time.Second):
+		t.Fatal("no delivery published")
+	}
+}
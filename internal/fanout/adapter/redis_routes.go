@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// Compile-time check: RouteTable satisfies app.RouteLookup.
+var _ app.RouteLookup = (*RouteTable)(nil)
+
+// routeLookupBatch bounds the users looked up in one pipeline, so a large
+// chat does not hold one Redis connection for a single huge round trip.
+const routeLookupBatch = 500
+
+// RouteTable reads the delivery routing table the Gateways advertise in
+// (ADR-010 §1.2): a Redis sorted set per user of Gateway instance IDs
+// scored by route expiry in Unix milliseconds, at domain.UserServersKey.
+// Routes past their expiry are ignored; the Gateways prune them.
+type RouteTable struct {
+	cmd   redisclient.Cmdable
+	clock domain.Clock
+}
+
+// NewRouteTable creates a RouteTable.
+func NewRouteTable(cmd redisclient.Cmdable, clock domain.Clock) *RouteTable {
+	return &RouteTable{cmd: cmd, clock: clock}
+}
+
+// Gateways returns the users connected to each Gateway instance, keyed by
+// instance ID. Users with no live route are absent.
+func (t *RouteTable) Gateways(ctx context.Context, userIDs []string) (map[string][]string, error) {
+	ctx, span := tracer.Start(ctx, "redis.routes.lookup")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "ZRANGEBYSCORE"),
+		attribute.Int("routes.users", len(userIDs)),
+	)
+
+	live := &redis.ZRangeBy{Min: strconv.FormatInt(t.clock.Now().UnixMilli(), 10), Max: "+inf"}
+	gateways := make(map[string][]string)
+	for start := 0; start < len(userIDs); start += routeLookupBatch {
+		batch := userIDs[start:min(start+routeLookupBatch, len(userIDs))]
+		pipe := t.cmd.Pipeline()
+		cmds := make([]*redis.StringSliceCmd, len(batch))
+		for i, userID := range batch {
+			cmds[i] = pipe.ZRangeByScore(ctx, domain.UserServersKey(userID), live)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("look up routes: %w", err)
+		}
+		for i, cmd := range cmds {
+			for _, gatewayID := range cmd.Val() {
+				gateways[gatewayID] = append(gateways[gatewayID], batch[i])
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("routes.gateways", len(gateways)))
+	return gateways, nil
+}
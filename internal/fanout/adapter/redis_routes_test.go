@@ -0,0 +1,38 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+func TestRouteTable_Gateways(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	live := float64(now.Add(time.Minute).UnixMilli())
+	expired := float64(now.Add(-time.Second).UnixMilli())
+
+	cmd, mr := newTestRedis(t)
+	_, err := mr.ZAdd("user_servers:alice", live, "gw-1")
+	require.NoError(t, err)
+	_, err = mr.ZAdd("user_servers:bob", live, "gw-1")
+	require.NoError(t, err)
+	_, err = mr.ZAdd("user_servers:bob", live, "gw-2")
+	require.NoError(t, err)
+	_, err = mr.ZAdd("user_servers:carol", expired, "gw-3")
+	require.NoError(t, err)
+	routes := NewRouteTable(cmd, domaintest.NewFakeClock(now))
+
+	got, err := routes.Gateways(ctx, []string{"alice", "bob", "carol", "dave"})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"gw-1": {"alice", "bob"},
+		"gw-2": {"bob"},
+	}, got, "expired routes and users without routes are left out")
+}
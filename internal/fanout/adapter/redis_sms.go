@@ -0,0 +1,139 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// SMS key patterns. The day is a hash tag so a day's per-user and global
+// counters share a cluster slot and one script can touch both.
+//
+//	sms:budget:{2026-10-01}:user:{user_id}
+//	sms:budget:{2026-10-01}:global
+//	sms:route:{phone}
+const (
+	smsBudgetPrefix = "sms:budget:"
+	smsRoutePrefix  = "sms:route:"
+)
+
+// smsBudgetTTL keeps a day's counters past its end, so a counter cannot
+// expire and reset while its day is still current in some time zone.
+const smsBudgetTTL = 48 * time.Hour
+
+// smsBudgetScript claims one SMS against both counters, or neither when
+// either is at its limit. Counters get their TTL on the first claim, as in
+// the chatmgmt rate limiter.
+//
+//	KEYS[1] user counter, KEYS[2] global counter
+//	ARGV[1] user limit, ARGV[2] global limit, ARGV[3] TTL seconds
+const smsBudgetScript = `
+local user = tonumber(redis.call('GET', KEYS[1]) or '0')
+local global = tonumber(redis.call('GET', KEYS[2]) or '0')
+if user >= tonumber(ARGV[1]) or global >= tonumber(ARGV[2]) then
+  return 0
+end
+for _, key in ipairs(KEYS) do
+  if redis.call('INCR', key) == 1 then
+    redis.call('EXPIRE', key, ARGV[3])
+  end
+end
+return 1
+`
+
+// Compile-time checks: the Redis SMS stores satisfy their app interfaces.
+var (
+	_ app.SMSBudget      = (*SMSBudget)(nil)
+	_ app.SMSReplyRoutes = (*SMSReplyRoutes)(nil)
+)
+
+// SMSBudget keeps the daily SMS counters in Redis.
+type SMSBudget struct {
+	cmd redisclient.Cmdable
+}
+
+// NewSMSBudget creates an SMSBudget that uses cmd for Redis operations.
+func NewSMSBudget(cmd redisclient.Cmdable) *SMSBudget {
+	return &SMSBudget{cmd: cmd}
+}
+
+// Take claims one SMS for userID on day. Redis errors are returned with
+// false, so callers fail closed.
+func (b *SMSBudget) Take(ctx context.Context, day, userID string, userLimit, globalLimit int) (bool, error) {
+	ctx, span := tracer.Start(ctx, "redis.sms.take_budget")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	tag := smsBudgetPrefix + "{" + day + "}:"
+	keys := []string{tag + "user:" + userID, tag + "global"}
+	ok, err := b.cmd.Eval(ctx, smsBudgetScript, keys, userLimit, globalLimit, int(smsBudgetTTL.Seconds())).Int()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return false, fmt.Errorf("sms budget: take: %w", err)
+	}
+	return ok == 1, nil
+}
+
+// SMSReplyRoutes keeps each phone's reply route in Redis, expiring with
+// the reply window.
+type SMSReplyRoutes struct {
+	cmd redisclient.Cmdable
+}
+
+// NewSMSReplyRoutes creates an SMSReplyRoutes that uses cmd for Redis
+// operations.
+func NewSMSReplyRoutes(cmd redisclient.Cmdable) *SMSReplyRoutes {
+	return &SMSReplyRoutes{cmd: cmd}
+}
+
+// SetReplyRoute points phone's replies at chatID for ttl, replacing any
+// earlier route.
+func (r *SMSReplyRoutes) SetReplyRoute(ctx context.Context, phone, chatID string, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "redis.sms.set_route")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "SET"),
+	)
+
+	if err := r.cmd.Set(ctx, smsRoutePrefix+phone, chatID, ttl).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("sms route: set: %w", err)
+	}
+	return nil
+}
+
+// ReplyRoute returns phone's current route, or domain.ErrNotFound.
+func (r *SMSReplyRoutes) ReplyRoute(ctx context.Context, phone string) (string, error) {
+	ctx, span := tracer.Start(ctx, "redis.sms.get_route")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "GET"),
+	)
+
+	chatID, err := r.cmd.Get(ctx, smsRoutePrefix+phone).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("sms route: %w", domain.ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("sms route: get: %w", err)
+	}
+	return chatID, nil
+}
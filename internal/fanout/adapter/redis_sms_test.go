@@ -0,0 +1,110 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestRedis(t *testing.T) (redisclient.Cmdable, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	return client.RDB, mr
+}
+
+func TestSMSBudget_Take(t *testing.T) {
+	ctx := context.Background()
+	const day = "2026-10-01"
+
+	t.Run("per-user limit", func(t *testing.T) {
+		cmd, mr := newTestRedis(t)
+		b := NewSMSBudget(cmd)
+
+		for i := range 3 {
+			ok, err := b.Take(ctx, day, "alice", 2, 100)
+			require.NoError(t, err)
+			assert.Equal(t, i < 2, ok, "claim %d", i)
+		}
+		ok, err := b.Take(ctx, day, "bob", 2, 100)
+		require.NoError(t, err)
+		assert.True(t, ok, "other users keep their allowance")
+
+		assert.Equal(t, "3", mustGet(t, mr, "sms:budget:{2026-10-01}:global"), "refused claims are not counted")
+		assert.Equal(t, smsBudgetTTL, mr.TTL("sms:budget:{2026-10-01}:user:alice"))
+	})
+
+	t.Run("global limit", func(t *testing.T) {
+		cmd, mr := newTestRedis(t)
+		b := NewSMSBudget(cmd)
+
+		ok, _ := b.Take(ctx, day, "alice", 5, 1)
+		assert.True(t, ok)
+		ok, err := b.Take(ctx, day, "bob", 5, 1)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.False(t, mr.Exists("sms:budget:{2026-10-01}:user:bob"))
+	})
+
+	t.Run("days are counted separately", func(t *testing.T) {
+		cmd, _ := newTestRedis(t)
+		b := NewSMSBudget(cmd)
+
+		ok, _ := b.Take(ctx, day, "alice", 1, 1)
+		assert.True(t, ok)
+		ok, _ = b.Take(ctx, "2026-10-02", "alice", 1, 1)
+		assert.True(t, ok)
+	})
+
+	t.Run("redis unavailable fails closed", func(t *testing.T) {
+		cmd, mr := newTestRedis(t)
+		mr.Close()
+
+		ok, err := NewSMSBudget(cmd).Take(ctx, day, "alice", 5, 5)
+
+		require.Error(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestSMSReplyRoutes(t *testing.T) {
+	ctx := context.Background()
+	cmd, mr := newTestRedis(t)
+	routes := NewSMSReplyRoutes(cmd)
+
+	_, err := routes.ReplyRoute(ctx, "+14155550100")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	require.NoError(t, routes.SetReplyRoute(ctx, "+14155550100", "chat-1", time.Hour))
+	require.NoError(t, routes.SetReplyRoute(ctx, "+14155550100", "chat-2", time.Hour))
+
+	chatID, err := routes.ReplyRoute(ctx, "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, "chat-2", chatID, "the latest SMS wins")
+
+	mr.FastForward(time.Hour)
+	_, err = routes.ReplyRoute(ctx, "+14155550100")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func mustGet(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	v, err := mr.Get(key)
+	require.NoError(t, err)
+	return v
+}
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// Compile-time check: SNSSMSSender satisfies app.SMSSender.
+var _ app.SMSSender = (*SNSSMSSender)(nil)
+
+// snsPublisher is a narrow, consumer-defined interface for the subset of
+// SNS operations required by the SMS sender. The real *sns.Client
+// satisfies it.
+type snsPublisher interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNSSMSSender sends SMS fallback messages through Amazon SNS.
+type SNSSMSSender struct {
+	client snsPublisher
+	origin string
+}
+
+// NewSNSSMSSender creates an SNSSMSSender. originationNumber is the
+// two-way number replies are sent to; it must have two-way SMS routed to
+// the reply webhook's topic. Empty lets SNS pick a number, and replies are
+// lost.
+func NewSNSSMSSender(client snsPublisher, originationNumber string) *SNSSMSSender {
+	return &SNSSMSSender{client: client, origin: originationNumber}
+}
+
+// SendSMS publishes body to phone as a transactional SMS, which carriers
+// deliver ahead of promotional traffic and outside quiet hours.
+func (s *SNSSMSSender) SendSMS(ctx context.Context, phone, body string) error {
+	ctx, span := tracer.Start(ctx, "sns.sms.send_fallback")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("messaging.system", "aws_sns"),
+		attribute.String("messaging.operation", "publish"),
+	)
+
+	attrs := map[string]snstypes.MessageAttributeValue{
+		"AWS.SNS.SMS.SMSType": stringAttribute("Transactional"),
+	}
+	if s.origin != "" {
+		attrs["AWS.MM.SMS.OriginationNumber"] = stringAttribute(s.origin)
+	}
+	_, err := s.client.Publish(ctx, &sns.PublishInput{
+		PhoneNumber:       &phone,
+		Message:           &body,
+		MessageAttributes: attrs,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("sns sms: send fallback: %w", err)
+	}
+	return nil
+}
+
+func stringAttribute(v string) snstypes.MessageAttributeValue {
+	dataType := "String"
+	return snstypes.MessageAttributeValue{DataType: &dataType, StringValue: &v}
+}
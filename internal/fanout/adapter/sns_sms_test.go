@@ -0,0 +1,57 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher records the last Publish input and fails with err.
+type recordingPublisher struct {
+	input *sns.PublishInput
+	err   error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, in *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	p.input = in
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &sns.PublishOutput{}, nil
+}
+
+func TestSNSSMSSender_SendSMS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("transactional from the two-way number", func(t *testing.T) {
+		pub := &recordingPublisher{}
+
+		require.NoError(t, NewSNSSMSSender(pub, "+14155550000").SendSMS(ctx, "+14155550100", "Alice: hi"))
+
+		assert.Equal(t, "+14155550100", *pub.input.PhoneNumber)
+		assert.Equal(t, "Alice: hi", *pub.input.Message)
+		assert.Equal(t, "Transactional", *pub.input.MessageAttributes["AWS.SNS.SMS.SMSType"].StringValue)
+		assert.Equal(t, "+14155550000", *pub.input.MessageAttributes["AWS.MM.SMS.OriginationNumber"].StringValue)
+	})
+
+	t.Run("no origination number", func(t *testing.T) {
+		pub := &recordingPublisher{}
+
+		require.NoError(t, NewSNSSMSSender(pub, "").SendSMS(ctx, "+14155550100", "hi"))
+
+		assert.NotContains(t, pub.input.MessageAttributes, "AWS.MM.SMS.OriginationNumber")
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		publishErr := errors.New("sns throttled")
+
+		err := NewSNSSMSSender(&recordingPublisher{err: publishErr}, "").SendSMS(ctx, "+14155550100", "hi")
+
+		require.ErrorIs(t, err, publishErr)
+		assert.NotContains(t, err.Error(), "+14155550100", "phone numbers stay out of errors")
+	})
+}
@@ -0,0 +1,241 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// Compile-time check: WebPushProvider satisfies app.PushProvider.
+var _ app.PushProvider = (*WebPushProvider)(nil)
+
+const (
+	// defaultWebPushTTL is how long a push service keeps an undelivered
+	// message. Chat notifications are stale after a day.
+	defaultWebPushTTL = 24 * time.Hour
+	// vapidTokenLifetime is the VAPID JWT expiry; RFC 8292 caps it at 24h.
+	vapidTokenLifetime = 12 * time.Hour
+)
+
+// DefaultWebPushHosts are the push services of the major browsers. A
+// subscription endpoint must be on one of them (or a subdomain), so a
+// client cannot make the server POST to an arbitrary URL.
+var DefaultWebPushHosts = []string{
+	"fcm.googleapis.com",                // Chrome, Edge (Chromium), Opera
+	"updates.push.services.mozilla.com", // Firefox
+	"notify.windows.com",                // legacy Edge
+	"push.apple.com",                    // Safari
+}
+
+// ParseVAPIDKey decodes a VAPID private key in the form web-push tooling
+// generates: the raw 32-byte P-256 scalar, base64url-encoded.
+func ParseVAPIDKey(encoded string) (*ecdsa.PrivateKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("webpush: decode VAPID key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: parse VAPID key: %w", err)
+	}
+	return key, nil
+}
+
+// WebPushConfig holds the dependencies for WebPushProvider.
+type WebPushConfig struct {
+	// Client sends requests to push services. Nil uses http.DefaultClient.
+	Client *http.Client
+	// VAPIDKey is the application server's P-256 key; browsers subscribed
+	// with its public half as applicationServerKey.
+	VAPIDKey *ecdsa.PrivateKey
+	// Subject is the VAPID contact, a mailto: or https: URL.
+	Subject string
+	// AllowedHosts restricts subscription endpoints. Nil uses
+	// DefaultWebPushHosts.
+	AllowedHosts []string
+	// TTL is how long the push service holds an undelivered message. Zero
+	// defaults to 24h.
+	TTL   time.Duration
+	Clock domain.Clock
+}
+
+// WebPushProvider delivers notifications to browsers through the Web Push
+// protocol (RFC 8030), authenticated with VAPID (RFC 8292) and encrypted
+// per RFC 8291.
+type WebPushProvider struct {
+	client       *http.Client
+	key          *ecdsa.PrivateKey
+	publicKey    string // base64url uncompressed point, the VAPID k parameter
+	subject      string
+	allowedHosts []string
+	ttl          time.Duration
+	clock        domain.Clock
+}
+
+// NewWebPushProvider creates a WebPushProvider.
+func NewWebPushProvider(cfg WebPushConfig) (*WebPushProvider, error) {
+	if cfg.VAPIDKey == nil {
+		return nil, errors.New("webpush: VAPID key is required")
+	}
+	if cfg.VAPIDKey.Curve != elliptic.P256() {
+		return nil, errors.New("webpush: VAPID key must be P-256")
+	}
+	pub, err := cfg.VAPIDKey.PublicKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("webpush: VAPID public key: %w", err)
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	hosts := cfg.AllowedHosts
+	if hosts == nil {
+		hosts = DefaultWebPushHosts
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultWebPushTTL
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	return &WebPushProvider{
+		client:       client,
+		key:          cfg.VAPIDKey,
+		publicKey:    base64.RawURLEncoding.EncodeToString(pub.Bytes()),
+		subject:      cfg.Subject,
+		allowedHosts: hosts,
+		ttl:          ttl,
+		clock:        clock,
+	}, nil
+}
+
+// webPushPayload is the JSON the service worker receives in its push event.
+type webPushPayload struct {
+	Title  string `json:"title,omitempty"`
+	Body   string `json:"body,omitempty"`
+	ChatID string `json:"chat_id,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+// Push encrypts n for the subscription in token and posts it to the
+// subscription's endpoint. A 404 or 410 from the push service means the
+// subscription is gone and is reported as app.TokenRejectedError.
+func (p *WebPushProvider) Push(ctx context.Context, token domain.DeviceToken, n app.Notification) error {
+	ctx, span := tracer.Start(ctx, "webpush.push")
+	defer span.End()
+
+	endpoint, err := url.Parse(token.Token)
+	if err != nil || endpoint.Scheme != "https" || !p.allowed(endpoint.Hostname()) {
+		// Registration validates endpoints, so this is a subscription from
+		// a push service we no longer trust.
+		return &app.TokenRejectedError{Reason: app.TokenMalformed}
+	}
+	span.SetAttributes(attribute.String("webpush.host", endpoint.Hostname()))
+
+	plaintext, err := json.Marshal(webPushPayload{Title: n.Title, Body: n.Body, ChatID: n.ChatID, Count: n.Count})
+	if err != nil {
+		return fmt.Errorf("webpush: marshal payload: %w", err)
+	}
+	body, err := encryptWebPush(plaintext, token.P256DH, token.Auth)
+	if errors.Is(err, errWebPushPayloadTooLarge) {
+		return fmt.Errorf("webpush: encrypt: %w", err)
+	}
+	if err != nil {
+		// Keys that cannot be used will never work.
+		return fmt.Errorf("webpush: encrypt: %w", &app.TokenRejectedError{Reason: app.TokenMalformed})
+	}
+	auth, err := p.vapid(endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webpush: build request: %w", err)
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(p.ttl/time.Second)))
+	req.Header.Set("Urgency", "high")
+	if n.CollapseKey != "" {
+		req.Header.Set("Topic", webPushTopic(n.CollapseKey))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("webpush: send: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return &app.TokenRejectedError{Reason: app.TokenUnregistered}
+	default:
+		// 401/403 mean our VAPID credentials were refused; that is a
+		// server problem, not a reason to drop the subscription.
+		err := fmt.Errorf("webpush: push service returned %d", resp.StatusCode)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+}
+
+// allowed reports whether host is an allowed push service or a subdomain
+// of one.
+func (p *WebPushProvider) allowed(host string) bool {
+	for _, h := range p.allowedHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// vapid returns the Authorization header for a request to endpoint: a JWT
+// for the endpoint's origin signed with the VAPID key (RFC 8292 §3).
+func (p *WebPushProvider) vapid(endpoint *url.URL) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{endpoint.Scheme + "://" + endpoint.Host},
+		ExpiresAt: jwt.NewNumericDate(p.clock.Now().Add(vapidTokenLifetime)),
+		Subject:   p.subject,
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("webpush: sign VAPID token: %w", err)
+	}
+	return "vapid t=" + signed + ", k=" + p.publicKey, nil
+}
+
+// webPushTopic maps a collapse key to an RFC 8030 Topic, which allows at
+// most 32 characters of the base64url alphabet.
+func webPushTopic(collapseKey string) string {
+	sum := sha256.Sum256([]byte(collapseKey))
+	return base64.RawURLEncoding.EncodeToString(sum[:])[:32]
+}
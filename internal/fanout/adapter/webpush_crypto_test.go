@@ -0,0 +1,106 @@
+package adapter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 8291 Appendix A test vector.
+const (
+	rfcPlaintext  = "When I grow up, I want to be a watermelon"
+	rfcASPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcUAPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcAuthSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcSalt       = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcMessage    = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func b64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// openWebPush decrypts a message the way a user agent does.
+func openWebPush(t *testing.T, msg []byte, uaPrivate *ecdh.PrivateKey, authSecret []byte) []byte {
+	t.Helper()
+	require.Greater(t, len(msg), 86)
+	salt := msg[:16]
+	require.Equal(t, uint32(webPushRecordSize), binary.BigEndian.Uint32(msg[16:20]))
+	idlen := int(msg[20])
+	asPublicBytes := msg[21 : 21+idlen]
+	ciphertext := msg[21+idlen:]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	require.NoError(t, err)
+	ecdhSecret, err := uaPrivate.ECDH(asPublic)
+	require.NoError(t, err)
+
+	keyInfo := "WebPush: info\x00" + string(uaPrivate.PublicKey().Bytes()) + string(asPublicBytes)
+	ikm, err := hkdf.Key(sha256.New, ecdhSecret, authSecret, keyInfo, 32)
+	require.NoError(t, err)
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	require.NoError(t, err)
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	require.NoError(t, err)
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x02), record[len(record)-1], "last-record delimiter")
+	return record[:len(record)-1]
+}
+
+func TestSealWebPush_RFC8291Vector(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(b64(t, rfcASPrivate))
+	require.NoError(t, err)
+
+	msg, err := sealWebPush([]byte(rfcPlaintext), b64(t, rfcUAPublic), b64(t, rfcAuthSecret), asPrivate, b64(t, rfcSalt))
+
+	require.NoError(t, err)
+	assert.Equal(t, rfcMessage, base64.RawURLEncoding.EncodeToString(msg))
+}
+
+func TestEncryptWebPush(t *testing.T) {
+	uaPrivate, err := ecdh.P256().NewPrivateKey(b64(t, rfcUAPrivate))
+	require.NoError(t, err)
+
+	t.Run("round trips with fresh keys each time", func(t *testing.T) {
+		first, err := encryptWebPush([]byte("hello"), rfcUAPublic, rfcAuthSecret)
+		require.NoError(t, err)
+		second, err := encryptWebPush([]byte("hello"), rfcUAPublic, rfcAuthSecret)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, "hello", string(openWebPush(t, first, uaPrivate, b64(t, rfcAuthSecret))))
+	})
+
+	t.Run("rejects oversized payloads", func(t *testing.T) {
+		_, err := encryptWebPush([]byte(strings.Repeat("a", maxWebPushPayload+1)), rfcUAPublic, rfcAuthSecret)
+
+		assert.ErrorIs(t, err, errWebPushPayloadTooLarge)
+	})
+
+	t.Run("rejects a malformed key", func(t *testing.T) {
+		_, err := encryptWebPush([]byte("hello"), "AAAA", rfcAuthSecret)
+
+		assert.Error(t, err)
+	})
+}
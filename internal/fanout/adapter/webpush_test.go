@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// capturedPush is one request received by the fake push service.
+type capturedPush struct {
+	header http.Header
+	body   []byte
+}
+
+// newPushService starts a TLS push service replying with status.
+func newPushService(t *testing.T, status int) (*httptest.Server, <-chan capturedPush) {
+	t.Helper()
+	got := make(chan capturedPush, 1)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- capturedPush{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestWebPushProvider_Push(t *testing.T) {
+	ctx := context.Background()
+	vapidKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	uaPrivate, err := ecdh.P256().NewPrivateKey(b64(t, rfcUAPrivate))
+	require.NoError(t, err)
+	clock := domaintest.NewFakeClock(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+
+	newProvider := func(t *testing.T, srv *httptest.Server) *WebPushProvider {
+		t.Helper()
+		p, err := NewWebPushProvider(WebPushConfig{
+			Client:       srv.Client(),
+			VAPIDKey:     vapidKey,
+			Subject:      "mailto:ops@example.com",
+			AllowedHosts: []string{"127.0.0.1"},
+			Clock:        clock,
+		})
+		require.NoError(t, err)
+		return p
+	}
+	subscription := func(srv *httptest.Server) domain.DeviceToken {
+		return domain.DeviceToken{
+			UserID:   "alice",
+			DeviceID: "browser",
+			Platform: domain.PushPlatformWebPush,
+			Token:    srv.URL + "/push/abc",
+			P256DH:   rfcUAPublic,
+			Auth:     rfcAuthSecret,
+		}
+	}
+	n := app.Notification{UserID: "alice", ChatID: "work", Title: "Work", Body: "3 new messages", Count: 3, CollapseKey: "chat:work"}
+
+	t.Run("sends an encrypted, VAPID-signed message", func(t *testing.T) {
+		srv, got := newPushService(t, http.StatusCreated)
+
+		require.NoError(t, newProvider(t, srv).Push(ctx, subscription(srv), n))
+
+		req := <-got
+		assert.Equal(t, "aes128gcm", req.header.Get("Content-Encoding"))
+		assert.Equal(t, "86400", req.header.Get("TTL"))
+		assert.Equal(t, webPushTopic("chat:work"), req.header.Get("Topic"))
+		assert.Len(t, req.header.Get("Topic"), 32)
+
+		var payload webPushPayload
+		require.NoError(t, json.Unmarshal(openWebPush(t, req.body, uaPrivate, b64(t, rfcAuthSecret)), &payload))
+		assert.Equal(t, webPushPayload{Title: "Work", Body: "3 new messages", ChatID: "work", Count: 3}, payload)
+
+		auth := req.header.Get("Authorization")
+		require.True(t, strings.HasPrefix(auth, "vapid t="), auth)
+		signed := strings.TrimSuffix(strings.TrimPrefix(auth, "vapid t="), ", k="+newProvider(t, srv).publicKey)
+		claims := &jwt.RegisteredClaims{}
+		_, err := jwt.ParseWithClaims(signed, claims, func(*jwt.Token) (any, error) { return &vapidKey.PublicKey, nil },
+			jwt.WithValidMethods([]string{"ES256"}), jwt.WithTimeFunc(clock.Now))
+		require.NoError(t, err)
+		assert.Equal(t, jwt.ClaimStrings{srv.URL}, claims.Audience)
+		assert.Equal(t, "mailto:ops@example.com", claims.Subject)
+	})
+
+	t.Run("gone subscription is rejected", func(t *testing.T) {
+		for _, status := range []int{http.StatusNotFound, http.StatusGone} {
+			srv, _ := newPushService(t, status)
+
+			err := newProvider(t, srv).Push(ctx, subscription(srv), n)
+
+			var rejected *app.TokenRejectedError
+			require.ErrorAs(t, err, &rejected, "status %d", status)
+			assert.Equal(t, app.TokenUnregistered, rejected.Reason)
+		}
+	})
+
+	t.Run("refused VAPID credentials keep the subscription", func(t *testing.T) {
+		srv, _ := newPushService(t, http.StatusForbidden)
+
+		err := newProvider(t, srv).Push(ctx, subscription(srv), n)
+
+		require.Error(t, err)
+		var rejected *app.TokenRejectedError
+		assert.False(t, errors.As(err, &rejected))
+	})
+
+	t.Run("endpoint outside the allowed push services", func(t *testing.T) {
+		srv, _ := newPushService(t, http.StatusCreated)
+		sub := subscription(srv)
+		sub.Token = "https://attacker.example/collect"
+
+		err := newProvider(t, srv).Push(ctx, sub, n)
+
+		var rejected *app.TokenRejectedError
+		require.ErrorAs(t, err, &rejected)
+		assert.Equal(t, app.TokenMalformed, rejected.Reason)
+	})
+
+	t.Run("unusable keys are rejected", func(t *testing.T) {
+		srv, _ := newPushService(t, http.StatusCreated)
+		sub := subscription(srv)
+		sub.P256DH = "AAAA"
+
+		err := newProvider(t, srv).Push(ctx, sub, n)
+
+		var rejected *app.TokenRejectedError
+		require.ErrorAs(t, err, &rejected)
+	})
+}
+
+func TestNewWebPushProvider_RequiresP256Key(t *testing.T) {
+	_, err := NewWebPushProvider(WebPushConfig{})
+	require.Error(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewWebPushProvider(WebPushConfig{VAPIDKey: key})
+	assert.Error(t, err)
+}
+
+func TestParseVAPIDKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	raw, err := key.Bytes()
+	require.NoError(t, err)
+
+	got, err := ParseVAPIDKey(base64.RawURLEncoding.EncodeToString(raw))
+
+	require.NoError(t, err)
+	assert.True(t, key.Equal(got))
+
+	_, err = ParseVAPIDKey("not base64!")
+	assert.Error(t, err)
+	_, err = ParseVAPIDKey(base64.RawURLEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
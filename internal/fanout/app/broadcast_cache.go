@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var broadcastCacheLookups metric.Int64Counter
+
+func init() {
+	m := otel.Meter("fanout/app")
+
+	broadcastCacheLookups, _ = m.Int64Counter("fanout_broadcast_cache_lookups_total",
+		metric.WithDescription("Broadcast serialization cache lookups, by result (hit, miss)"))
+}
+
+// Encoding is the wire format a delivery is serialized to.
+type Encoding string
+
+const (
+	EncodingJSON     Encoding = "json"     // WebSocket frames (ADR-005)
+	EncodingProtobuf Encoding = "protobuf" // gRPC Connect stream
+)
+
+// Compression is the transport compression applied after encoding.
+type Compression string
+
+const (
+	CompressionNone    Compression = "none"
+	CompressionDeflate Compression = "deflate" // permessage-deflate
+)
+
+// EncodingKey identifies one serialized form of a message.
+type EncodingKey struct {
+	MessageID   string
+	Encoding    Encoding
+	Compression Compression
+}
+
+func (k EncodingKey) String() string {
+	return k.MessageID + "/" + string(k.Encoding) + "/" + string(k.Compression)
+}
+
+// BroadcastCache serializes each message once per encoding and compression
+// and hands the same bytes to every recipient. Concurrent lookups for a
+// missing key share one encode call. Entries are evicted oldest first once
+// the cache is full. Callers must treat returned bytes as read-only. Safe
+// for concurrent use.
+type BroadcastCache struct {
+	mu       sync.Mutex
+	entries  map[EncodingKey][]byte
+	order    []EncodingKey // insertion ring; next points at the oldest
+	next     int
+	capacity int
+
+	group singleflight.Group
+}
+
+// NewBroadcastCache creates a BroadcastCache holding up to capacity entries.
+// Zero defaults to domain.BroadcastCacheEntries.
+func NewBroadcastCache(capacity int) *BroadcastCache {
+	if capacity <= 0 {
+		capacity = domain.BroadcastCacheEntries
+	}
+	return &BroadcastCache{
+		entries:  make(map[EncodingKey][]byte, capacity),
+		order:    make([]EncodingKey, 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// Encoded returns the cached bytes for key, calling encode on a miss. Encode
+// errors are returned to every waiting caller and not cached.
+func (c *BroadcastCache) Encoded(ctx context.Context, key EncodingKey, encode func() ([]byte, error)) ([]byte, error) {
+	if b, ok := c.get(key); ok {
+		broadcastCacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "hit")))
+		return b, nil
+	}
+	broadcastCacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "miss")))
+
+	v, err, _ := c.group.Do(key.String(), func() (any, error) {
+		// A concurrent caller may have filled the entry between get and Do.
+		if b, ok := c.get(key); ok {
+			return b, nil
+		}
+		b, err := encode()
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, b)
+		return b, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// Len returns the number of cached entries.
+func (c *BroadcastCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *BroadcastCache) get(key EncodingKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.entries[key]
+	return b, ok
+}
+
+func (c *BroadcastCache) put(key EncodingKey, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.order) < c.capacity {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.entries, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % c.capacity
+	}
+	c.entries[key] = b
+}
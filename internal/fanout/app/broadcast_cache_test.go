@@ -0,0 +1,112 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+func TestBroadcastCache_Encoded(t *testing.T) {
+	jsonKey := app.EncodingKey{MessageID: "msg-001", Encoding: app.EncodingJSON, Compression: app.CompressionNone}
+
+	t.Run("encodes once per key", func(t *testing.T) {
+		c := app.NewBroadcastCache(8)
+		calls := 0
+		encode := func() ([]byte, error) {
+			calls++
+			return []byte(`{"type":"message"}`), nil
+		}
+
+		for range 3 {
+			b, err := c.Encoded(context.Background(), jsonKey, encode)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"type":"message"}`, string(b))
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("each encoding and compression is cached separately", func(t *testing.T) {
+		c := app.NewBroadcastCache(8)
+		keys := []app.EncodingKey{
+			jsonKey,
+			{MessageID: "msg-001", Encoding: app.EncodingJSON, Compression: app.CompressionDeflate},
+			{MessageID: "msg-001", Encoding: app.EncodingProtobuf, Compression: app.CompressionNone},
+		}
+		for _, k := range keys {
+			b, err := c.Encoded(context.Background(), k, func() ([]byte, error) { return []byte(k.String()), nil })
+			require.NoError(t, err)
+			assert.Equal(t, k.String(), string(b))
+		}
+		assert.Equal(t, 3, c.Len())
+	})
+
+	t.Run("concurrent misses share one encode", func(t *testing.T) {
+		c := app.NewBroadcastCache(8)
+		var calls atomic.Int32
+		release := make(chan struct{})
+		encode := func() ([]byte, error) {
+			calls.Add(1)
+			<-release
+			return []byte("encoded"), nil
+		}
+
+		var wg sync.WaitGroup
+		for range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b, err := c.Encoded(context.Background(), jsonKey, encode)
+				assert.NoError(t, err)
+				assert.Equal(t, "encoded", string(b))
+			}()
+		}
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		c := app.NewBroadcastCache(8)
+		boom := errors.New("boom")
+
+		_, err := c.Encoded(context.Background(), jsonKey, func() ([]byte, error) { return nil, boom })
+		require.ErrorIs(t, err, boom)
+		assert.Zero(t, c.Len())
+
+		b, err := c.Encoded(context.Background(), jsonKey, func() ([]byte, error) { return []byte("ok"), nil })
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(b))
+	})
+
+	t.Run("full cache evicts the oldest entry", func(t *testing.T) {
+		c := app.NewBroadcastCache(2)
+		calls := map[string]int{}
+		get := func(id string) {
+			key := app.EncodingKey{MessageID: id, Encoding: app.EncodingJSON, Compression: app.CompressionNone}
+			_, err := c.Encoded(context.Background(), key, func() ([]byte, error) {
+				calls[id]++
+				return []byte(id), nil
+			})
+			require.NoError(t, err)
+		}
+
+		get("msg-001")
+		get("msg-002")
+		get("msg-003") // evicts msg-001
+		get("msg-002")
+		get("msg-001")
+
+		assert.Equal(t, 2, c.Len())
+		assert.Equal(t, map[string]int{"msg-001": 2, "msg-002": 1, "msg-003": 1}, calls)
+	})
+}
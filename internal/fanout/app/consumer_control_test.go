@@ -0,0 +1,106 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+func TestConsumerControl(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("pause blocks Wait until resume", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(start)
+		c, err := app.NewConsumerControl(app.ConsumerControlConfig{Clock: clock})
+		require.NoError(t, err)
+
+		require.NoError(t, c.Wait(ctx, app.ConsumerDelivery), "running consumers do not wait")
+
+		state, err := c.Pause(ctx, app.ConsumerDelivery, "gateway overloaded")
+		require.NoError(t, err)
+		assert.Equal(t, app.ConsumerState{Name: app.ConsumerDelivery, Paused: true, Reason: "gateway overloaded", Since: start}, state)
+		assert.True(t, c.Paused(app.ConsumerDelivery))
+		assert.False(t, c.Paused(app.ConsumerPush), "other consumers keep running")
+
+		done := make(chan error, 1)
+		go func() { done <- c.Wait(ctx, app.ConsumerDelivery) }()
+		select {
+		case <-done:
+			t.Fatal("Wait returned while paused")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		clock.Advance(time.Minute)
+		state, err = c.Resume(ctx, app.ConsumerDelivery)
+		require.NoError(t, err)
+		assert.Equal(t, app.ConsumerState{Name: app.ConsumerDelivery, Since: start.Add(time.Minute)}, state)
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("Wait did not return after resume")
+		}
+	})
+
+	t.Run("pausing again updates the reason only", func(t *testing.T) {
+		clock := domaintest.NewFakeClock(start)
+		c, err := app.NewConsumerControl(app.ConsumerControlConfig{Clock: clock})
+		require.NoError(t, err)
+
+		_, err = c.Pause(ctx, app.ConsumerPush, "APNs outage")
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+		state, err := c.Pause(ctx, app.ConsumerPush, "APNs and FCM outage")
+		require.NoError(t, err)
+		assert.Equal(t, start, state.Since)
+		assert.Equal(t, "APNs and FCM outage", state.Reason)
+
+		state, err = c.Resume(ctx, app.ConsumerSearchIndex)
+		require.NoError(t, err)
+		assert.False(t, state.Paused, "resuming a running consumer is a no-op")
+	})
+
+	t.Run("Wait honours ctx", func(t *testing.T) {
+		c, err := app.NewConsumerControl(app.ConsumerControlConfig{Paused: []string{app.ConsumerSearchIndex}})
+		require.NoError(t, err)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, c.Wait(waitCtx, app.ConsumerSearchIndex), context.DeadlineExceeded)
+	})
+
+	t.Run("configured pauses", func(t *testing.T) {
+		c, err := app.NewConsumerControl(app.ConsumerControlConfig{Clock: domaintest.NewFakeClock(start), Paused: []string{app.ConsumerPush}})
+		require.NoError(t, err)
+
+		states := c.States()
+		require.Len(t, states, 3)
+		assert.Equal(t, []string{app.ConsumerDelivery, app.ConsumerPush, app.ConsumerSearchIndex},
+			[]string{states[0].Name, states[1].Name, states[2].Name})
+		assert.True(t, states[1].Paused)
+		assert.Equal(t, "paused by configuration", states[1].Reason)
+
+		_, err = app.NewConsumerControl(app.ConsumerControlConfig{Paused: []string{"indexer"}})
+		assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+	})
+
+	t.Run("unknown consumer", func(t *testing.T) {
+		c, err := app.NewConsumerControl(app.ConsumerControlConfig{})
+		require.NoError(t, err)
+
+		_, err = c.Pause(ctx, "indexer", "")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = c.Resume(ctx, "indexer")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.False(t, c.Paused("indexer"))
+		assert.NoError(t, c.Wait(ctx, "indexer"))
+	})
+}
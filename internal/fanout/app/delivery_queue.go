@@ -0,0 +1,248 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var laneDeliveries metric.Int64Counter
+
+func init() {
+	laneDeliveries, _ = otel.Meter("fanout/app").Int64Counter("fanout_lane_deliveries_total",
+		metric.WithDescription("Deliveries published from the partition priority lanes, by priority and result (published, failed)"))
+}
+
+// Priority returns the lane d is queued in: receipts in the receipt lane,
+// typing and presence in the ephemeral lane, and messages and
+// notifications in the message lane.
+func (d Delivery) Priority() domain.DeliveryPriority {
+	if d.Signal == nil {
+		return domain.PriorityMessage
+	}
+	switch d.Signal.Type {
+	case protocol.FrameTypeReceipt:
+		return domain.PriorityReceipt
+	case protocol.FrameTypeTyping, protocol.FrameTypePresence:
+		return domain.PriorityEphemeral
+	default:
+		return domain.PriorityMessage
+	}
+}
+
+// GatewayDelivery is a delivery resolved to the Gateway instance it is
+// bound for.
+type GatewayDelivery struct {
+	GatewayID string
+	Delivery  Delivery
+}
+
+// DeliveryQueue buffers deliveries for one Kafka partition between
+// membership resolution and Gateway dispatch. Messages are dispatched ahead
+// of receipts and ephemeral events by weighted round-robin, so a burst of
+// typing or presence events cannot delay chat messages. Safe for concurrent
+// use.
+type DeliveryQueue struct {
+	mu    sync.Mutex
+	queue *domain.PriorityQueue[GatewayDelivery]
+	ready chan struct{}
+	space chan struct{}
+}
+
+// NewDeliveryQueue creates a DeliveryQueue holding up to capacity deliveries
+// per priority lane.
+func NewDeliveryQueue(capacity int) *DeliveryQueue {
+	return &DeliveryQueue{
+		queue: domain.NewPriorityQueue[GatewayDelivery](capacity),
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+}
+
+// Push queues d without blocking. A full message or receipt lane returns an
+// error matching ErrUnavailable so the consumer pauses the partition instead
+// of dropping events; a full ephemeral lane drops its oldest event.
+func (q *DeliveryQueue) Push(d GatewayDelivery) error {
+	p := d.Delivery.Priority()
+	q.mu.Lock()
+	ok := q.queue.Push(p, d)
+	q.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("delivery queue %s lane full: %w", p, domain.ErrUnavailable)
+	}
+	signal(q.ready)
+	return nil
+}
+
+// Pop blocks until a delivery is available or ctx is done.
+func (q *DeliveryQueue) Pop(ctx context.Context) (GatewayDelivery, error) {
+	for {
+		q.mu.Lock()
+		d, ok := q.queue.Pop()
+		q.mu.Unlock()
+		if ok {
+			signal(q.space)
+			return d, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return GatewayDelivery{}, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
+
+// Len returns the number of queued deliveries.
+func (q *DeliveryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queue.Len()
+}
+
+// signal wakes one waiter on ch without blocking.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// DeliveryLanesConfig holds the dependencies for DeliveryLanes.
+type DeliveryLanesConfig struct {
+	Gateways GatewayPublisher
+	Logger   *slog.Logger
+
+	// Partitions is the number of lane sets. Zero defaults to
+	// domain.FanoutDeliveryLanes.
+	Partitions int
+	// Capacity bounds each priority lane of a partition. Zero defaults to
+	// domain.FanoutLaneCapacity.
+	Capacity int
+	// Timeout bounds each publish to a Gateway. Zero defaults to
+	// domain.DeliveryTimeout.
+	Timeout time.Duration
+}
+
+// DeliveryLanes holds a DeliveryQueue per partition, each drained by its
+// own worker, so the deliveries of a partition reach their Gateways in
+// priority order. The delivery consumer
+// queues a record's resolved deliveries in its partition's lanes; activity
+// signals and notifications are queued through Publish, which makes
+// DeliveryLanes a GatewayPublisher, in the lanes of their Gateway. Run
+// starts the workers.
+type DeliveryLanes struct {
+	queues   []*DeliveryQueue
+	gateways GatewayPublisher
+	logger   *slog.Logger
+	timeout  time.Duration
+}
+
+// NewDeliveryLanes creates DeliveryLanes with the given dependencies.
+func NewDeliveryLanes(cfg DeliveryLanesConfig) *DeliveryLanes {
+	partitions := cfg.Partitions
+	if partitions <= 0 {
+		partitions = domain.FanoutDeliveryLanes
+	}
+	capacity := cfg.Capacity
+	if capacity <= 0 {
+		capacity = domain.FanoutLaneCapacity
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = domain.DeliveryTimeout
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	queues := make([]*DeliveryQueue, partitions)
+	for i := range queues {
+		queues[i] = NewDeliveryQueue(capacity)
+	}
+	return &DeliveryLanes{
+		queues:   queues,
+		gateways: cfg.Gateways,
+		logger:   logger,
+		timeout:  timeout,
+	}
+}
+
+// Enqueue queues d in partition's lanes, waiting while its lane is full;
+// the caller stops consuming the partition meanwhile. It fails only when
+// ctx is done first.
+func (l *DeliveryLanes) Enqueue(ctx context.Context, partition int32, d GatewayDelivery) error {
+	q := l.queues[int(partition)%len(l.queues)]
+	for {
+		err := q.Push(d)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("enqueue delivery: %w", ctx.Err())
+		case <-q.space:
+		}
+	}
+}
+
+// Publish queues d for gatewayID in the lanes of that Gateway without
+// blocking, so a Gateway's signals keep their order. A full receipt or
+// message lane fails with an error matching domain.ErrUnavailable.
+func (l *DeliveryLanes) Publish(_ context.Context, gatewayID string, d Delivery) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(gatewayID))
+	return l.queues[int(h.Sum32()%uint32(len(l.queues)))].Push(GatewayDelivery{GatewayID: gatewayID, Delivery: d}) //nolint:gosec // lane count is small
+}
+
+// Run publishes queued deliveries until ctx is done; what is still queued
+// then is dropped, and its recipients catch up with sync.
+func (l *DeliveryLanes) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, q := range l.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.drain(ctx, q)
+		}()
+	}
+	wg.Wait()
+}
+
+// drain publishes one partition's deliveries in priority order.
+func (l *DeliveryLanes) drain(ctx context.Context, q *DeliveryQueue) {
+	for {
+		d, err := q.Pop(ctx)
+		if err != nil {
+			return
+		}
+		priority := attribute.String("priority", d.Delivery.Priority().String())
+		if err := l.publish(ctx, d); err != nil {
+			laneDeliveries.Add(ctx, 1, metric.WithAttributes(priority, attribute.String("result", "failed")))
+			l.logger.WarnContext(ctx, "gateway delivery failed, recipients will sync",
+				"gateway_id", d.GatewayID, "message_id", d.Delivery.Message.MessageID, "error", err)
+			continue
+		}
+		laneDeliveries.Add(ctx, 1, metric.WithAttributes(priority, attribute.String("result", "published")))
+	}
+}
+
+// publish hands one delivery to a Gateway within the publish timeout.
+func (l *DeliveryLanes) publish(ctx context.Context, d GatewayDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	return l.gateways.Publish(ctx, d.GatewayID, d.Delivery)
+}
+
+var _ GatewayPublisher = (*DeliveryLanes)(nil)
@@ -0,0 +1,135 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// message returns a message delivery for id bound for gw-1.
+func message(id string) app.GatewayDelivery {
+	return app.GatewayDelivery{GatewayID: "gw-1", Delivery: app.Delivery{Message: protocol.Message{MessageID: id}}}
+}
+
+// typing returns a typing signal delivery from userID bound for gw-1.
+func typing(t *testing.T, userID string) app.GatewayDelivery {
+	t.Helper()
+	frame, err := protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-1", UserID: userID, Active: true})
+	require.NoError(t, err)
+	return app.GatewayDelivery{GatewayID: "gw-1", Delivery: app.Delivery{UserIDs: []string{"bob"}, Signal: frame}}
+}
+
+func TestDelivery_Priority(t *testing.T) {
+	frame := func(ft protocol.FrameType) *protocol.Frame { return &protocol.Frame{Type: ft} }
+
+	assert.Equal(t, domain.PriorityMessage, app.Delivery{}.Priority())
+	assert.Equal(t, domain.PriorityReceipt, app.Delivery{Signal: frame(protocol.FrameTypeReceipt)}.Priority())
+	assert.Equal(t, domain.PriorityEphemeral, app.Delivery{Signal: frame(protocol.FrameTypeTyping)}.Priority())
+	assert.Equal(t, domain.PriorityEphemeral, app.Delivery{Signal: frame(protocol.FrameTypePresence)}.Priority())
+	assert.Equal(t, domain.PriorityMessage, app.Delivery{Signal: frame(protocol.FrameTypeNotification)}.Priority())
+}
+
+func TestDeliveryQueue_Push(t *testing.T) {
+	t.Run("full message lane signals backpressure", func(t *testing.T) {
+		q := app.NewDeliveryQueue(1)
+		require.NoError(t, q.Push(message("m1")))
+
+		err := q.Push(message("m2"))
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+		assert.Equal(t, 1, q.Len())
+	})
+
+	t.Run("full ephemeral lane drops its oldest event", func(t *testing.T) {
+		q := app.NewDeliveryQueue(1)
+		require.NoError(t, q.Push(typing(t, "alice")))
+		require.NoError(t, q.Push(typing(t, "carol")))
+
+		d, err := q.Pop(context.Background())
+		require.NoError(t, err)
+		var got protocol.Typing
+		require.NoError(t, d.Delivery.Signal.ParsePayload(&got))
+		assert.Equal(t, "carol", got.UserID)
+		assert.Equal(t, 0, q.Len())
+	})
+}
+
+func TestDeliveryQueue_Pop(t *testing.T) {
+	t.Run("messages are dispatched ahead of ephemeral events", func(t *testing.T) {
+		q := app.NewDeliveryQueue(16)
+		for range 4 {
+			require.NoError(t, q.Push(typing(t, "alice")))
+		}
+		require.NoError(t, q.Push(message("m1")))
+
+		d, err := q.Pop(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "m1", d.Delivery.Message.MessageID)
+	})
+
+	t.Run("blocks until a delivery is pushed", func(t *testing.T) {
+		q := app.NewDeliveryQueue(4)
+		got := make(chan app.GatewayDelivery, 1)
+		go func() {
+			d, err := q.Pop(context.Background())
+			if err == nil {
+				got <- d
+			}
+		}()
+
+		require.NoError(t, q.Push(message("m1")))
+		select {
+		case d := <-got:
+			assert.Equal(t, "m1", d.Delivery.Message.MessageID)
+		case <-time.After(time.Second):
+			t.Fatal("Pop did not return after Push")
+		}
+	})
+
+	t.Run("returns ctx error when cancelled", func(t *testing.T) {
+		q := app.NewDeliveryQueue(4)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := q.Pop(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDeliveryLanes(t *testing.T) {
+	t.Run("publishes queued deliveries with a deadline", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		lanes := app.NewDeliveryLanes(app.DeliveryLanesConfig{Gateways: gateways, Partitions: 2})
+		require.NoError(t, lanes.Enqueue(context.Background(), 3, message("m1")))
+		require.NoError(t, lanes.Publish(context.Background(), "gw-2", typing(t, "alice").Delivery))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() { lanes.Run(ctx); close(done) }()
+
+		require.Eventually(t, func() bool {
+			gateways.mu.Lock()
+			defer gateways.mu.Unlock()
+			return len(gateways.published) == 2
+		}, time.Second, time.Millisecond)
+		cancel()
+		<-done
+		assert.Equal(t, "m1", gateways.published["gw-1"].Message.MessageID)
+		assert.Equal(t, protocol.FrameTypeTyping, gateways.published["gw-2"].Signal.Type)
+	})
+
+	t.Run("a full message lane holds Enqueue until ctx is done", func(t *testing.T) {
+		lanes := app.NewDeliveryLanes(app.DeliveryLanesConfig{Gateways: &recordingGateways{}, Partitions: 1, Capacity: 1})
+		require.NoError(t, lanes.Enqueue(context.Background(), 0, message("m1")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, lanes.Enqueue(ctx, 0, message("m2")), context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var tracer = otel.Tracer("fanout/app")
+
+var (
+	gatewayDeliveries metric.Int64Counter
+
+	deliveryPublishedAttr = metric.WithAttributes(attribute.String("result", "published"))
+	deliveryFailedAttr    = metric.WithAttributes(attribute.String("result", "failed"))
+)
+
+func init() {
+	gatewayDeliveries, _ = otel.Meter("fanout/app").Int64Counter("fanout_gateway_deliveries_total",
+		metric.WithDescription("Message deliveries published to Gateway instances, by result (published, failed)"))
+}
+
+// Delivery is a message or activity signal for the recipients connected
+// to one Gateway instance (ADR-002 §3.4).
+type Delivery struct {
+	UserIDs []string
+	Message protocol.Message
+	// Payload is Message's JSON encoding, serialized once per message and
+	// shared by all of its deliveries. Read-only.
+	Payload []byte
+	// Signal is set instead of Message for an activity signal or a
+	// notification: the prepared typing, receipt, presence or notification
+	// frame its recipients get.
+	// Read-only.
+	Signal *protocol.Frame
+}
+
+// MemberLister lists a chat's members.
+type MemberLister interface {
+	Members(ctx context.Context, chatID string) ([]string, error)
+}
+
+// RouteLookup reads the routing table the Gateways advertise in (ADR-010
+// §1.2): the Gateway instances each user is connected to, keyed by
+// instance ID. Users connected nowhere are absent.
+type RouteLookup interface {
+	Gateways(ctx context.Context, userIDs []string) (map[string][]string, error)
+}
+
+// GatewayPublisher hands a delivery to one Gateway instance.
+type GatewayPublisher interface {
+	Publish(ctx context.Context, gatewayID string, d Delivery) error
+}
+
+// DispatcherConfig holds the dependencies for Dispatcher.
+type DispatcherConfig struct {
+	Members  MemberLister
+	Routes   RouteLookup
+	Gateways GatewayPublisher
+	Logger   *slog.Logger
+
+	// Cache holds encoded messages across deliveries; nil creates one of
+	// domain.BroadcastCacheEntries.
+	Cache *BroadcastCache
+
+	// Timeout bounds each publish to a Gateway. Zero defaults to
+	// domain.DeliveryTimeout.
+	Timeout time.Duration
+}
+
+// Dispatcher delivers persisted messages to their recipients' Gateways
+// (ADR-002 §3.3). Recipients are the chat's members other than the
+// sender; each Gateway a recipient is connected to gets one delivery
+// naming its recipients. A message is serialized once, through the
+// broadcast cache, and every delivery carries the same bytes. Publishing
+// is best effort: a Gateway that misses a delivery leaves its recipients
+// to catch up with sync.
+type Dispatcher struct {
+	members  MemberLister
+	routes   RouteLookup
+	gateways GatewayPublisher
+	logger   *slog.Logger
+	cache    *BroadcastCache
+	timeout  time.Duration
+}
+
+// NewDispatcher creates a Dispatcher with the given dependencies.
+func NewDispatcher(cfg DispatcherConfig) *Dispatcher {
+	cache := cfg.Cache
+	if cache == nil {
+		cache = NewBroadcastCache(0)
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = domain.DeliveryTimeout
+	}
+	return &Dispatcher{
+		members:  cfg.Members,
+		routes:   cfg.Routes,
+		gateways: cfg.Gateways,
+		logger:   cfg.Logger,
+		cache:    cache,
+		timeout:  timeout,
+	}
+}
+
+// Dispatch publishes msg to the Gateways its recipients are connected to.
+// It fails only when the recipients or their routes cannot be read or the
+// message cannot be encoded; failed publishes are logged and counted.
+func (d *Dispatcher) Dispatch(ctx context.Context, msg protocol.Message) error {
+	deliveries, err := d.Resolve(ctx, msg)
+	if err != nil {
+		return err
+	}
+	for _, gd := range deliveries {
+		if err := d.publish(ctx, gd.GatewayID, gd.Delivery); err != nil {
+			gatewayDeliveries.Add(ctx, 1, deliveryFailedAttr)
+			d.logger.WarnContext(ctx, "gateway delivery failed, recipients will sync",
+				"gateway_id", gd.GatewayID, "message_id", msg.MessageID, "error", err)
+			continue
+		}
+		gatewayDeliveries.Add(ctx, 1, deliveryPublishedAttr)
+	}
+	return nil
+}
+
+// Resolve returns msg's deliveries, one per Gateway its recipients are
+// connected to, without publishing them. It fails when the recipients or
+// their routes cannot be read or the message cannot be encoded.
+func (d *Dispatcher) Resolve(ctx context.Context, msg protocol.Message) ([]GatewayDelivery, error) {
+	ctx, span := tracer.Start(ctx, "fanout.dispatch")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("message.chat_id", msg.ChatID),
+		attribute.Int64("message.sequence", int64(msg.Sequence)), //nolint:gosec // sequences fit in int64
+	)
+
+	members, err := d.members.Members(ctx, msg.ChatID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("dispatch %s: list members: %w", msg.MessageID, err)
+	}
+	recipients := make([]string, 0, len(members))
+	for _, userID := range members {
+		if userID != msg.SenderID {
+			recipients = append(recipients, userID)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	routes, err := d.routes.Gateways(ctx, recipients)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("dispatch %s: look up routes: %w", msg.MessageID, err)
+	}
+	span.SetAttributes(
+		attribute.Int("dispatch.recipients", len(recipients)),
+		attribute.Int("dispatch.gateways", len(routes)),
+	)
+
+	payload, err := d.cache.Encoded(ctx, EncodingKey{MessageID: msg.MessageID, Encoding: EncodingJSON, Compression: CompressionNone},
+		func() ([]byte, error) { return json.Marshal(msg) })
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("dispatch %s: encode: %w", msg.MessageID, err)
+	}
+
+	deliveries := make([]GatewayDelivery, 0, len(routes))
+	for gatewayID, userIDs := range routes {
+		deliveries = append(deliveries, GatewayDelivery{
+			GatewayID: gatewayID,
+			Delivery:  Delivery{UserIDs: userIDs, Message: msg, Payload: payload},
+		})
+	}
+	return deliveries, nil
+}
+
+// publish hands one delivery to a Gateway within the publish timeout.
+func (d *Dispatcher) publish(ctx context.Context, gatewayID string, delivery Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	return d.gateways.Publish(ctx, gatewayID, delivery)
+}
@@ -0,0 +1,149 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+type stubMembers struct {
+	members []string
+	err     error
+}
+
+func (s stubMembers) Members(context.Context, string) ([]string, error) {
+	return s.members, s.err
+}
+
+// stubRoutes routes each user to the Gateways listed for them.
+type stubRoutes struct {
+	routes map[string][]string // user ID -> gateway IDs
+	asked  []string
+	err    error
+}
+
+func (s *stubRoutes) Gateways(_ context.Context, userIDs []string) (map[string][]string, error) {
+	s.asked = userIDs
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make(map[string][]string)
+	for _, u := range userIDs {
+		for _, gw := range s.routes[u] {
+			out[gw] = append(out[gw], u)
+		}
+	}
+	return out, nil
+}
+
+// recordingGateways records published deliveries by Gateway and fails for
+// the Gateways in failing.
+type recordingGateways struct {
+	mu        sync.Mutex
+	published map[string]app.Delivery
+	failing   map[string]bool
+}
+
+func (g *recordingGateways) Publish(ctx context.Context, gatewayID string, d app.Delivery) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("publish without a deadline")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failing[gatewayID] {
+		return errors.New("redis down")
+	}
+	g.published[gatewayID] = d
+	return nil
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	msg := protocol.Message{MessageID: "msg-1", ChatID: "chat-1", SenderID: "alice", Sequence: 7}
+
+	newDispatcher := func(members app.MemberLister, routes app.RouteLookup, gateways app.GatewayPublisher) *app.Dispatcher {
+		return app.NewDispatcher(app.DispatcherConfig{
+			Members:  members,
+			Routes:   routes,
+			Gateways: gateways,
+			Logger:   slog.Default(),
+		})
+	}
+
+	t.Run("publishes once per Gateway, without the sender", func(t *testing.T) {
+		routes := &stubRoutes{routes: map[string][]string{
+			"alice": {"gw-1"},
+			"bob":   {"gw-1", "gw-2"},
+			"carol": {"gw-2"},
+		}}
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		d := newDispatcher(stubMembers{members: []string{"alice", "bob", "carol", "dave"}}, routes, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, msg))
+
+		assert.Equal(t, []string{"bob", "carol", "dave"}, routes.asked)
+		payload, err := json.Marshal(msg)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]app.Delivery{
+			"gw-1": {UserIDs: []string{"bob"}, Message: msg, Payload: payload},
+			"gw-2": {UserIDs: []string{"bob", "carol"}, Message: msg, Payload: payload},
+		}, gateways.published)
+		assert.Same(t, &gateways.published["gw-1"].Payload[0], &gateways.published["gw-2"].Payload[0],
+			"the message is serialized once")
+	})
+
+	t.Run("encodings are reused across dispatches", func(t *testing.T) {
+		cache := app.NewBroadcastCache(0)
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		d := app.NewDispatcher(app.DispatcherConfig{
+			Members:  stubMembers{members: []string{"bob"}},
+			Routes:   &stubRoutes{routes: map[string][]string{"bob": {"gw-1"}}},
+			Gateways: gateways,
+			Logger:   slog.Default(),
+			Cache:    cache,
+		})
+
+		require.NoError(t, d.Dispatch(ctx, msg))
+		require.NoError(t, d.Dispatch(ctx, msg))
+
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("a failed publish does not stop the others", func(t *testing.T) {
+		routes := &stubRoutes{routes: map[string][]string{"bob": {"gw-1"}, "carol": {"gw-2"}}}
+		gateways := &recordingGateways{published: map[string]app.Delivery{}, failing: map[string]bool{"gw-1": true}}
+		d := newDispatcher(stubMembers{members: []string{"bob", "carol"}}, routes, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, msg))
+
+		require.Contains(t, gateways.published, "gw-2")
+		assert.NotContains(t, gateways.published, "gw-1")
+		assert.Equal(t, []string{"carol"}, gateways.published["gw-2"].UserIDs)
+	})
+
+	t.Run("a sender alone in the chat has no recipients", func(t *testing.T) {
+		routes := &stubRoutes{}
+		d := newDispatcher(stubMembers{members: []string{"alice"}}, routes, &recordingGateways{})
+
+		require.NoError(t, d.Dispatch(ctx, msg))
+
+		assert.Nil(t, routes.asked, "routes are not read")
+	})
+
+	t.Run("membership and route errors fail the dispatch", func(t *testing.T) {
+		d := newDispatcher(stubMembers{err: errors.New("throttled")}, &stubRoutes{}, &recordingGateways{})
+		assert.ErrorContains(t, d.Dispatch(ctx, msg), "throttled")
+
+		d = newDispatcher(stubMembers{members: []string{"bob"}}, &stubRoutes{err: errors.New("redis down")}, &recordingGateways{})
+		assert.ErrorContains(t, d.Dispatch(ctx, msg), "redis down")
+	})
+}
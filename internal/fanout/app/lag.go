@@ -0,0 +1,229 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// PartitionLag is the consumer's progress on one assigned partition.
+type PartitionLag struct {
+	Topic     string
+	Partition int32
+
+	HighWatermark int64 // -1 before the first fetch
+	Committed     int64 // -1 before the first commit
+	Lag           int64
+	// LagAge is how long the oldest unprocessed event has waited since it
+	// was produced; zero when the partition is caught up.
+	LagAge time.Duration
+}
+
+// ConsumerLag is a snapshot of this instance's membership in the Fanout
+// consumer group.
+type ConsumerLag struct {
+	Group          string
+	Partitions     []PartitionLag // sorted by topic, then partition
+	Rebalances     int64
+	PartitionsLost int64
+	LastRebalance  time.Time
+}
+
+// LagSource reports consumer lag.
+type LagSource interface {
+	ConsumerLag() ConsumerLag
+}
+
+// LagAlert reports a partition's lag crossing a threshold or recovering.
+type LagAlert struct {
+	Topic     string
+	Partition int32
+	Lag       int64
+	LagAge    time.Duration
+	Firing    bool // false when the alert resolves
+}
+
+// LagMonitorConfig holds the dependencies for LagMonitor.
+type LagMonitorConfig struct {
+	Source LagSource
+	Logger *slog.Logger // nil uses slog.Default
+
+	// MaxLag and MaxLagAge are the per-partition alert thresholds. Zero
+	// defaults to domain.ConsumerLagAlertRecords and
+	// domain.ConsumerLagAlertAge.
+	MaxLag    int64
+	MaxLagAge time.Duration
+
+	// OnAlert is called when a partition starts or stops exceeding a
+	// threshold. Alerts are logged whether or not it is set.
+	OnAlert func(context.Context, LagAlert)
+}
+
+// LagMonitor exports the Fanout consumer's lag, assignments and rebalances
+// as metrics and alerts on partitions falling behind the delivery latency
+// SLO.
+type LagMonitor struct {
+	source    LagSource
+	logger    *slog.Logger
+	maxLag    int64
+	maxLagAge time.Duration
+	onAlert   func(context.Context, LagAlert)
+
+	mu     sync.Mutex
+	firing map[partitionKey]bool
+
+	registration metric.Registration
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// NewLagMonitor creates a LagMonitor and registers its metrics:
+// fanout_consumer_lag{topic, partition}, fanout_consumer_lag_seconds{topic,
+// partition}, fanout_consumer_assigned_partitions,
+// fanout_consumer_rebalances_total and fanout_consumer_partitions_lost_total.
+func NewLagMonitor(cfg LagMonitorConfig) (*LagMonitor, error) {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &LagMonitor{
+		source:    cfg.Source,
+		logger:    logger,
+		maxLag:    cmp.Or(cfg.MaxLag, domain.ConsumerLagAlertRecords),
+		maxLagAge: cmp.Or(cfg.MaxLagAge, domain.ConsumerLagAlertAge),
+		onAlert:   cfg.OnAlert,
+		firing:    make(map[partitionKey]bool),
+	}
+
+	meter := otel.Meter("fanout/app")
+	lag, err := meter.Int64ObservableGauge("fanout_consumer_lag",
+		metric.WithDescription("Records between the committed offset and the high watermark, by topic and partition"))
+	if err != nil {
+		return nil, fmt.Errorf("create fanout_consumer_lag: %w", err)
+	}
+	lagAge, err := meter.Float64ObservableGauge("fanout_consumer_lag_seconds",
+		metric.WithDescription("Age of the oldest unprocessed event, by topic and partition"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create fanout_consumer_lag_seconds: %w", err)
+	}
+	assigned, err := meter.Int64ObservableGauge("fanout_consumer_assigned_partitions",
+		metric.WithDescription("Partitions assigned to this consumer group member"))
+	if err != nil {
+		return nil, fmt.Errorf("create fanout_consumer_assigned_partitions: %w", err)
+	}
+	rebalances, err := meter.Int64ObservableCounter("fanout_consumer_rebalances_total",
+		metric.WithDescription("Partition assignments received from the consumer group"))
+	if err != nil {
+		return nil, fmt.Errorf("create fanout_consumer_rebalances_total: %w", err)
+	}
+	lost, err := meter.Int64ObservableCounter("fanout_consumer_partitions_lost_total",
+		metric.WithDescription("Partitions lost without a clean revoke (session timeout, fenced member)"))
+	if err != nil {
+		return nil, fmt.Errorf("create fanout_consumer_partitions_lost_total: %w", err)
+	}
+
+	m.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := m.source.ConsumerLag()
+		for _, p := range s.Partitions {
+			attrs := metric.WithAttributes(
+				attribute.String("topic", p.Topic),
+				attribute.String("partition", strconv.Itoa(int(p.Partition))))
+			o.ObserveInt64(lag, p.Lag, attrs)
+			o.ObserveFloat64(lagAge, p.LagAge.Seconds(), attrs)
+		}
+		o.ObserveInt64(assigned, int64(len(s.Partitions)))
+		o.ObserveInt64(rebalances, s.Rebalances)
+		o.ObserveInt64(lost, s.PartitionsLost)
+		return nil
+	}, lag, lagAge, assigned, rebalances, lost)
+	if err != nil {
+		return nil, fmt.Errorf("register fanout consumer lag metrics: %w", err)
+	}
+	return m, nil
+}
+
+// Status returns the current consumer lag.
+func (m *LagMonitor) Status() ConsumerLag {
+	return m.source.ConsumerLag()
+}
+
+// Evaluate checks every assigned partition against the thresholds and
+// calls OnAlert for each that started or stopped exceeding them since the
+// last call. A firing partition that is no longer assigned resolves: its
+// new owner reports it.
+func (m *LagMonitor) Evaluate(ctx context.Context) {
+	s := m.source.ConsumerLag()
+	var changed []LagAlert
+
+	m.mu.Lock()
+	assigned := make(map[partitionKey]bool, len(s.Partitions))
+	for _, p := range s.Partitions {
+		key := partitionKey{p.Topic, p.Partition}
+		assigned[key] = true
+		firing := p.Lag >= m.maxLag || p.LagAge >= m.maxLagAge
+		if m.firing[key] != firing {
+			m.firing[key] = firing
+			changed = append(changed, LagAlert{Topic: p.Topic, Partition: p.Partition, Lag: p.Lag, LagAge: p.LagAge, Firing: firing})
+		}
+	}
+	for key, firing := range m.firing {
+		if assigned[key] {
+			continue
+		}
+		delete(m.firing, key)
+		if firing {
+			changed = append(changed, LagAlert{Topic: key.topic, Partition: key.partition})
+		}
+	}
+	m.mu.Unlock()
+
+	for _, a := range changed {
+		if a.Firing {
+			m.logger.WarnContext(ctx, "consumer lag alert firing",
+				"topic", a.Topic, "partition", a.Partition, "lag", a.Lag, "lag_age", a.LagAge)
+		} else {
+			m.logger.InfoContext(ctx, "consumer lag alert resolved",
+				"topic", a.Topic, "partition", a.Partition, "lag", a.Lag, "lag_age", a.LagAge)
+		}
+		if m.onAlert != nil {
+			m.onAlert(ctx, a)
+		}
+	}
+}
+
+// Run calls Evaluate every interval until ctx is done. Zero interval
+// defaults to domain.ConsumerLagEvaluateInterval.
+func (m *LagMonitor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = domain.ConsumerLagEvaluateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Evaluate(ctx)
+		}
+	}
+}
+
+// Close unregisters the lag metrics.
+func (m *LagMonitor) Close() error {
+	return m.registration.Unregister()
+}
@@ -0,0 +1,93 @@
+package app_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// stubLagSource returns whatever lag was last set.
+type stubLagSource struct {
+	mu  sync.Mutex
+	lag app.ConsumerLag
+}
+
+func (s *stubLagSource) ConsumerLag() app.ConsumerLag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lag
+}
+
+func (s *stubLagSource) set(partitions ...app.PartitionLag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lag = app.ConsumerLag{Group: "fanout", Partitions: partitions}
+}
+
+func TestLagMonitor_Evaluate(t *testing.T) {
+	ctx := context.Background()
+	src := &stubLagSource{}
+	var alerts []app.LagAlert
+	m, err := app.NewLagMonitor(app.LagMonitorConfig{
+		Source:    src,
+		MaxLag:    100,
+		MaxLagAge: 2 * time.Second,
+		OnAlert:   func(_ context.Context, a app.LagAlert) { alerts = append(alerts, a) },
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = m.Close() })
+
+	src.set(
+		app.PartitionLag{Topic: "messages.persisted", Partition: 0, Lag: 5},
+		app.PartitionLag{Topic: "messages.persisted", Partition: 1, Lag: 5},
+	)
+	m.Evaluate(ctx)
+	assert.Empty(t, alerts, "healthy partitions do not alert")
+
+	src.set(
+		app.PartitionLag{Topic: "messages.persisted", Partition: 0, Lag: 100},
+		app.PartitionLag{Topic: "messages.persisted", Partition: 1, Lag: 3, LagAge: 2500 * time.Millisecond},
+	)
+	m.Evaluate(ctx)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, app.LagAlert{Topic: "messages.persisted", Partition: 0, Lag: 100, Firing: true}, alerts[0])
+	assert.Equal(t, app.LagAlert{Topic: "messages.persisted", Partition: 1, Lag: 3, LagAge: 2500 * time.Millisecond, Firing: true}, alerts[1])
+
+	alerts = nil
+	m.Evaluate(ctx)
+	assert.Empty(t, alerts, "alerts fire on transitions only")
+
+	// Partition 0 recovers; partition 1 is revoked while firing.
+	src.set(app.PartitionLag{Topic: "messages.persisted", Partition: 0, Lag: 10})
+	m.Evaluate(ctx)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, app.LagAlert{Topic: "messages.persisted", Partition: 0, Lag: 10}, alerts[0])
+	assert.Equal(t, app.LagAlert{Topic: "messages.persisted", Partition: 1}, alerts[1])
+}
+
+func TestLagMonitor_Defaults(t *testing.T) {
+	src := &stubLagSource{}
+	var alerts []app.LagAlert
+	m, err := app.NewLagMonitor(app.LagMonitorConfig{
+		Source:  src,
+		OnAlert: func(_ context.Context, a app.LagAlert) { alerts = append(alerts, a) },
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = m.Close() })
+
+	src.set(app.PartitionLag{Topic: "t", Lag: 9_999, LagAge: 1999 * time.Millisecond})
+	m.Evaluate(context.Background())
+	assert.Empty(t, alerts)
+
+	src.set(app.PartitionLag{Topic: "t", Lag: 10_000})
+	m.Evaluate(context.Background())
+	require.Len(t, alerts, 1)
+	assert.True(t, alerts[0].Firing)
+	assert.Equal(t, "fanout", m.Status().Group)
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var notificationDeliveries metric.Int64Counter
+
+func init() {
+	notificationDeliveries, _ = otel.Meter("fanout/app").Int64Counter("fanout_notification_deliveries_total",
+		metric.WithDescription("Notification deliveries published to Gateway instances, by result (published, failed)"))
+}
+
+// NotificationDispatcherConfig holds the dependencies for
+// NotificationDispatcher.
+type NotificationDispatcherConfig struct {
+	Routes   RouteLookup
+	Gateways GatewayPublisher
+	Logger   *slog.Logger
+
+	// Timeout bounds each publish to a Gateway. Zero defaults to
+	// domain.DeliveryTimeout.
+	Timeout time.Duration
+}
+
+// NotificationDispatcher delivers new notification feed entries to the
+// Gateways their user is connected to, as notification frames. Delivery is
+// best effort: the entry is already stored in the user's feed, so a device
+// that misses the frame sees the entry the next time it lists the feed.
+type NotificationDispatcher struct {
+	routes   RouteLookup
+	gateways GatewayPublisher
+	logger   *slog.Logger
+	timeout  time.Duration
+}
+
+// NewNotificationDispatcher creates a NotificationDispatcher with the
+// given dependencies.
+func NewNotificationDispatcher(cfg NotificationDispatcherConfig) *NotificationDispatcher {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = domain.DeliveryTimeout
+	}
+	return &NotificationDispatcher{
+		routes:   cfg.Routes,
+		gateways: cfg.Gateways,
+		logger:   cfg.Logger,
+		timeout:  timeout,
+	}
+}
+
+// Dispatch publishes entry to the Gateways its user is connected to. It
+// fails when the user's routes cannot be read; failed publishes are logged
+// and counted.
+func (d *NotificationDispatcher) Dispatch(ctx context.Context, entry domain.FeedEntry) error {
+	ctx, span := tracer.Start(ctx, "fanout.dispatch_notification")
+	defer span.End()
+	span.SetAttributes(attribute.String("notification.kind", string(entry.Kind)))
+
+	routes, err := d.routes.Gateways(ctx, []string{entry.UserID})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("dispatch notification: look up routes: %w", err)
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+	span.SetAttributes(attribute.Int("dispatch.gateways", len(routes)))
+
+	frame, err := protocol.NewFrame(protocol.FrameTypeNotification, protocol.Notification{
+		NotificationID: entry.EntryID,
+		Kind:           string(entry.Kind),
+		ChatID:         entry.ChatID,
+		ActorID:        entry.ActorID,
+		Params:         entry.Params,
+		CreatedAt:      entry.CreatedAt.UnixMilli(),
+	})
+	if err == nil {
+		err = frame.Prepare()
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("dispatch notification: encode: %w", err)
+	}
+
+	for gatewayID, userIDs := range routes {
+		if err := d.publish(ctx, gatewayID, Delivery{UserIDs: userIDs, Signal: frame}); err != nil {
+			notificationDeliveries.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "failed")))
+			d.logger.DebugContext(ctx, "notification delivery failed, dropped",
+				"gateway_id", gatewayID, "notification_id", entry.EntryID, "error", err)
+			continue
+		}
+		notificationDeliveries.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "published")))
+	}
+	return nil
+}
+
+// publish hands one delivery to a Gateway within the publish timeout.
+func (d *NotificationDispatcher) publish(ctx context.Context, gatewayID string, delivery Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	return d.gateways.Publish(ctx, gatewayID, delivery)
+}
@@ -0,0 +1,76 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestNotificationDispatcher_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.UnixMilli(1_700_000_000_000)
+	entry := domain.FeedEntry{
+		UserID:    "bob",
+		EntryID:   "entry-1",
+		Kind:      domain.FeedKindMention,
+		ChatID:    "chat-1",
+		ActorID:   "alice",
+		Params:    map[string]string{"message_id": "msg-1"},
+		CreatedAt: createdAt,
+	}
+	newDispatcher := func(routes app.RouteLookup, gateways app.GatewayPublisher) *app.NotificationDispatcher {
+		return app.NewNotificationDispatcher(app.NotificationDispatcherConfig{
+			Routes:   routes,
+			Gateways: gateways,
+			Logger:   slog.Default(),
+		})
+	}
+
+	t.Run("reaches every Gateway the user is connected to", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}, failing: map[string]bool{"gw-3": true}}
+		d := newDispatcher(&stubRoutes{routes: map[string][]string{"bob": {"gw-1", "gw-2", "gw-3"}}}, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, entry), "a failed publish is dropped")
+
+		require.Len(t, gateways.published, 2)
+		delivery := gateways.published["gw-1"]
+		assert.Equal(t, []string{"bob"}, delivery.UserIDs)
+		require.NotNil(t, delivery.Signal)
+		assert.Equal(t, protocol.FrameTypeNotification, delivery.Signal.Type)
+		var got protocol.Notification
+		require.NoError(t, delivery.Signal.ParsePayload(&got))
+		assert.Equal(t, protocol.Notification{
+			NotificationID: "entry-1",
+			Kind:           "mention",
+			ChatID:         "chat-1",
+			ActorID:        "alice",
+			Params:         map[string]string{"message_id": "msg-1"},
+			CreatedAt:      createdAt.UnixMilli(),
+		}, got)
+		assert.Same(t, delivery.Signal, gateways.published["gw-2"].Signal, "the frame is encoded once")
+	})
+
+	t.Run("an offline user gets nothing", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		d := newDispatcher(&stubRoutes{}, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, entry))
+
+		assert.Empty(t, gateways.published)
+	})
+
+	t.Run("route errors fail the dispatch", func(t *testing.T) {
+		d := newDispatcher(&stubRoutes{err: errors.New("redis down")}, &recordingGateways{})
+
+		assert.ErrorContains(t, d.Dispatch(ctx, entry), "redis down")
+	})
+}
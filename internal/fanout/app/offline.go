@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// PushQueue takes a notification for one offline user. PushDispatcher
+// satisfies it.
+type PushQueue interface {
+	Dispatch(ctx context.Context, n Notification) error
+}
+
+// SMSDeliverer texts a message to one offline user if they opted in.
+// SMSFallback satisfies it.
+type SMSDeliverer interface {
+	Deliver(ctx context.Context, msg SMSMessage) (bool, error)
+}
+
+// SenderNames reads the display names SMS are signed with.
+type SenderNames interface {
+	DisplayName(ctx context.Context, userID string) (string, error)
+}
+
+// OfflineNotifierConfig holds the dependencies for OfflineNotifier.
+type OfflineNotifierConfig struct {
+	Members MemberLister
+	Routes  RouteLookup
+	Push    PushQueue // nil sends no pushes
+	// SMS and Names text text messages to offline recipients; nil SMS
+	// sends none.
+	SMS    SMSDeliverer
+	Names  SenderNames
+	Logger *slog.Logger // nil uses slog.Default
+}
+
+// OfflineNotifier notifies a persisted message's recipients who are not
+// connected to any Gateway. Connected recipients get the message from the
+// Dispatcher instead. Pushes carry a count, never the content, so message
+// text does not pass through the platforms' push services. Text messages
+// are also offered to SMS fallback, which texts the recipients who opted
+// in and have been offline long enough.
+type OfflineNotifier struct {
+	members MemberLister
+	routes  RouteLookup
+	push    PushQueue
+	sms     SMSDeliverer
+	names   SenderNames
+	logger  *slog.Logger
+}
+
+// NewOfflineNotifier creates an OfflineNotifier.
+func NewOfflineNotifier(cfg OfflineNotifierConfig) *OfflineNotifier {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &OfflineNotifier{
+		members: cfg.Members,
+		routes:  cfg.Routes,
+		push:    cfg.Push,
+		sms:     cfg.SMS,
+		names:   cfg.Names,
+		logger:  logger,
+	}
+}
+
+// Dispatch pushes msg to each offline recipient and, for text, offers it
+// to SMS fallback. System messages are neither pushed nor texted. It fails
+// only when the recipients or their routes cannot be read; a failed push
+// or SMS is logged and the next message tries again.
+func (o *OfflineNotifier) Dispatch(ctx context.Context, msg protocol.Message) error {
+	if msg.ContentType == string(domain.ContentTypeSystem) {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "fanout.notify_offline")
+	defer span.End()
+	span.SetAttributes(attribute.String("message.chat_id", msg.ChatID))
+
+	offline, err := o.offline(ctx, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("notify offline %s: %w", msg.MessageID, err)
+	}
+	span.SetAttributes(attribute.Int("notify.offline", len(offline)))
+
+	if o.push != nil {
+		for _, userID := range offline {
+			n := Notification{UserID: userID, ChatID: msg.ChatID, Body: newMessages(1)}
+			if err := o.push.Dispatch(ctx, n); err != nil {
+				o.logger.WarnContext(ctx, "push failed",
+					"user_id", userID, "message_id", msg.MessageID, "error", err)
+			}
+		}
+	}
+	if o.sms != nil && len(offline) > 0 && msg.ContentType == string(domain.ContentTypeText) {
+		o.text(ctx, msg, offline)
+	}
+	return nil
+}
+
+// text offers msg to SMS fallback for each offline recipient, signed with
+// the sender's display name.
+func (o *OfflineNotifier) text(ctx context.Context, msg protocol.Message, offline []string) {
+	sender, err := o.names.DisplayName(ctx, msg.SenderID)
+	if err != nil {
+		o.logger.WarnContext(ctx, "sms fallback skipped: sender name not read",
+			"message_id", msg.MessageID, "error", err)
+		return
+	}
+	for _, userID := range offline {
+		_, err := o.sms.Deliver(ctx, SMSMessage{UserID: userID, ChatID: msg.ChatID, SenderName: sender, Text: msg.Content})
+		if err != nil {
+			o.logger.WarnContext(ctx, "sms fallback failed",
+				"user_id", userID, "message_id", msg.MessageID, "error", err)
+		}
+	}
+}
+
+// offline returns msg's recipients who have no Gateway route.
+func (o *OfflineNotifier) offline(ctx context.Context, msg protocol.Message) ([]string, error) {
+	members, err := o.members.Members(ctx, msg.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+	recipients := make([]string, 0, len(members))
+	for _, userID := range members {
+		if userID != msg.SenderID {
+			recipients = append(recipients, userID)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	routes, err := o.routes.Gateways(ctx, recipients)
+	if err != nil {
+		return nil, fmt.Errorf("look up routes: %w", err)
+	}
+	connected := make(map[string]struct{}, len(recipients))
+	for _, userIDs := range routes {
+		for _, userID := range userIDs {
+			connected[userID] = struct{}{}
+		}
+	}
+	var offline []string
+	for _, userID := range recipients {
+		if _, ok := connected[userID]; !ok {
+			offline = append(offline, userID)
+		}
+	}
+	return offline, nil
+}
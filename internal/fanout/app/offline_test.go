@@ -0,0 +1,111 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// recordingPushQueue records queued notifications and fails for the users
+// in failing.
+type recordingPushQueue struct {
+	mu      sync.Mutex
+	queued  []app.Notification
+	failing map[string]bool
+}
+
+func (q *recordingPushQueue) Dispatch(_ context.Context, n app.Notification) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failing[n.UserID] {
+		return errors.New("provider down")
+	}
+	q.queued = append(q.queued, n)
+	return nil
+}
+
+// recordingSMS records the SMS offered to fallback.
+type recordingSMS struct {
+	offered []app.SMSMessage
+}
+
+func (r *recordingSMS) Deliver(_ context.Context, msg app.SMSMessage) (bool, error) {
+	r.offered = append(r.offered, msg)
+	return true, nil
+}
+
+type stubNames map[string]string
+
+func (n stubNames) DisplayName(_ context.Context, userID string) (string, error) {
+	name, ok := n[userID]
+	if !ok {
+		return "", domain.ErrNotFound
+	}
+	return name, nil
+}
+
+func TestOfflineNotifier_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	msg := protocol.Message{MessageID: "msg-1", ChatID: "chat-1", SenderID: "alice", ContentType: string(domain.ContentTypeText), Content: "secret"}
+	members := stubMembers{members: []string{"alice", "bob", "carol", "dave"}}
+	routes := &stubRoutes{routes: map[string][]string{"bob": {"gw-1"}}}
+
+	t.Run("pushes recipients without a route", func(t *testing.T) {
+		push := &recordingPushQueue{failing: map[string]bool{"carol": true}}
+		n := app.NewOfflineNotifier(app.OfflineNotifierConfig{Members: members, Routes: routes, Push: push})
+
+		require.NoError(t, n.Dispatch(ctx, msg))
+
+		assert.ElementsMatch(t, []string{"bob", "carol", "dave"}, routes.asked, "the sender is not a recipient")
+		assert.Equal(t, []app.Notification{{UserID: "dave", ChatID: "chat-1", Body: "1 new message"}}, push.queued,
+			"a failed push does not stop the others, and content is never pushed")
+	})
+
+	t.Run("offers text to SMS fallback", func(t *testing.T) {
+		sms := &recordingSMS{}
+		n := app.NewOfflineNotifier(app.OfflineNotifierConfig{Members: members, Routes: routes, SMS: sms, Names: stubNames{"alice": "Alice"}})
+
+		require.NoError(t, n.Dispatch(ctx, msg))
+
+		assert.Equal(t, []app.SMSMessage{
+			{UserID: "carol", ChatID: "chat-1", SenderName: "Alice", Text: "secret"},
+			{UserID: "dave", ChatID: "chat-1", SenderName: "Alice", Text: "secret"},
+		}, sms.offered, "only offline recipients are offered it")
+	})
+
+	t.Run("an unknown sender skips SMS", func(t *testing.T) {
+		sms := &recordingSMS{}
+		n := app.NewOfflineNotifier(app.OfflineNotifierConfig{Members: members, Routes: routes, SMS: sms, Names: stubNames{}})
+
+		require.NoError(t, n.Dispatch(ctx, msg))
+
+		assert.Empty(t, sms.offered)
+	})
+
+	t.Run("system messages are not pushed", func(t *testing.T) {
+		push := &recordingPushQueue{}
+		sms := &recordingSMS{}
+		n := app.NewOfflineNotifier(app.OfflineNotifierConfig{Members: members, Routes: routes, Push: push, SMS: sms, Names: stubNames{}})
+
+		system := msg
+		system.ContentType = string(domain.ContentTypeSystem)
+		require.NoError(t, n.Dispatch(ctx, system))
+
+		assert.Empty(t, push.queued)
+		assert.Empty(t, sms.offered)
+	})
+
+	t.Run("read errors fail the dispatch", func(t *testing.T) {
+		n := app.NewOfflineNotifier(app.OfflineNotifierConfig{Members: members, Routes: &stubRoutes{err: errors.New("redis down")}, Push: &recordingPushQueue{}})
+
+		assert.ErrorContains(t, n.Dispatch(ctx, msg), "redis down")
+	})
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var privacySuppressed metric.Int64Counter
+
+func init() {
+	privacySuppressed, _ = otel.Meter("fanout/app").Int64Counter("fanout_privacy_suppressed_total",
+		metric.WithDescription("Activity signal deliveries withheld by privacy settings, by signal"))
+}
+
+// PrivacyStore reads users' privacy settings.
+type PrivacyStore interface {
+	// PrivacySettings returns the settings of each user that has any.
+	// Users missing from the result share everything.
+	PrivacySettings(ctx context.Context, userIDs []string) (map[string]domain.PrivacySettings, error)
+}
+
+// PrivacyFilter enforces privacy settings on activity signals before they
+// are queued for delivery. Clients also honor the settings, but only the
+// server can stop a hidden signal reaching another user's device.
+type PrivacyFilter struct {
+	store PrivacyStore
+}
+
+// NewPrivacyFilter creates a PrivacyFilter backed by store.
+func NewPrivacyFilter(store PrivacyStore) *PrivacyFilter {
+	return &PrivacyFilter{store: store}
+}
+
+// Recipients returns the recipients allowed to receive sig about subjectID,
+// in their original order. The subject never receives its own signal.
+func (f *PrivacyFilter) Recipients(ctx context.Context, sig domain.PrivacySignal, subjectID string, recipients []string) ([]string, error) {
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	settings, err := f.store.PrivacySettings(ctx, append([]string{subjectID}, recipients...))
+	if err != nil {
+		return nil, fmt.Errorf("privacy filter: read settings: %w", err)
+	}
+
+	subject := settings[subjectID]
+	allowed := make([]string, 0, len(recipients))
+	withheld := 0
+	for _, id := range recipients {
+		switch {
+		case id == subjectID:
+		case domain.PrivacyAllows(sig, subject, settings[id]):
+			allowed = append(allowed, id)
+		default:
+			withheld++
+		}
+	}
+	if withheld > 0 {
+		privacySuppressed.Add(ctx, int64(withheld),
+			metric.WithAttributes(attribute.String("signal", sig.String())))
+	}
+	return allowed, nil
+}
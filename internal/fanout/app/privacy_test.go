@@ -0,0 +1,78 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+type stubPrivacyStore struct {
+	settings map[string]domain.PrivacySettings
+	err      error
+	asked    []string
+}
+
+func (s *stubPrivacyStore) PrivacySettings(_ context.Context, userIDs []string) (map[string]domain.PrivacySettings, error) {
+	s.asked = userIDs
+	return s.settings, s.err
+}
+
+func TestPrivacyFilter_Recipients(t *testing.T) {
+	ctx := context.Background()
+	store := &stubPrivacyStore{settings: map[string]domain.PrivacySettings{
+		"hides-receipts": {HideReadReceipts: true},
+		"hides-typing":   {HideTyping: true},
+		"hides-seen":     {HideLastSeen: true},
+	}}
+	members := []string{"alice", "hides-receipts", "hides-typing", "hides-seen"}
+
+	tests := []struct {
+		name    string
+		sig     domain.PrivacySignal
+		subject string
+		want    []string
+	}{
+		{name: "open subject, reciprocal receipts", sig: domain.SignalReadReceipt, subject: "bob", want: []string{"alice", "hides-typing", "hides-seen"}},
+		{name: "subject hiding receipts reaches nobody", sig: domain.SignalReadReceipt, subject: "hides-receipts", want: []string{}},
+		{name: "typing is not reciprocal", sig: domain.SignalTyping, subject: "bob", want: members},
+		{name: "subject hiding typing reaches nobody", sig: domain.SignalTyping, subject: "hides-typing", want: []string{}},
+		{name: "subject is skipped", sig: domain.SignalLastSeen, subject: "alice", want: []string{"hides-receipts", "hides-typing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := app.NewPrivacyFilter(store)
+
+			got, err := f.Recipients(ctx, tt.sig, tt.subject, members)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.subject, store.asked[0], "subject settings are read in the same call")
+		})
+	}
+
+	t.Run("store failure", func(t *testing.T) {
+		f := app.NewPrivacyFilter(&stubPrivacyStore{err: errors.New("throttled")})
+
+		_, err := f.Recipients(ctx, domain.SignalTyping, "bob", members)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("no recipients skips the store", func(t *testing.T) {
+		s := &stubPrivacyStore{}
+		f := app.NewPrivacyFilter(s)
+
+		got, err := f.Recipients(ctx, domain.SignalTyping, "bob", nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		assert.Nil(t, s.asked)
+	})
+}
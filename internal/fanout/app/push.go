@@ -0,0 +1,327 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	pushNotifications metric.Int64Counter
+
+	pushSentAttr      = metric.WithAttributes(attribute.String("result", "sent"))
+	pushDeferredAttr  = metric.WithAttributes(attribute.String("result", "deferred"))
+	pushSummaryAttr   = metric.WithAttributes(attribute.String("result", "summary"))
+	pushCoalescedAttr = metric.WithAttributes(attribute.String("result", "coalesced"))
+	pushCollapsedAttr = metric.WithAttributes(attribute.String("result", "collapsed"))
+	pushFailedAttr    = metric.WithAttributes(attribute.String("result", "failed"))
+)
+
+func init() {
+	pushNotifications, _ = otel.Meter("fanout/app").Int64Counter("fanout_push_notifications_total",
+		metric.WithDescription("Push notifications by result (sent, deferred, summary, coalesced, collapsed, failed)"))
+}
+
+// Notification is a push notification for one user's devices.
+type Notification struct {
+	UserID string
+	ChatID string // empty on a summary spanning several chats
+	Title  string
+	Body   string
+	// Count is set on a summary to the number of notifications it replaces.
+	Count int
+	// CollapseKey is sent as the FCM collapse_key and APNs apns-collapse-id:
+	// a device shows only the latest notification per key.
+	CollapseKey string
+}
+
+// CollapseKey returns the collapse key for a chat's notifications.
+func CollapseKey(chatID string) string {
+	return "chat:" + chatID
+}
+
+// PushSender delivers a notification to a user's devices.
+type PushSender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// DNDStore reads users' do-not-disturb schedules.
+type DNDStore interface {
+	DNDSchedule(ctx context.Context, userID string) (domain.DNDSchedule, error)
+}
+
+// PushDispatcherConfig holds the dependencies for PushDispatcher.
+type PushDispatcherConfig struct {
+	Sender    PushSender
+	Schedules DNDStore
+	Clock     domain.Clock
+	Logger    *slog.Logger
+
+	// CoalesceWindow is how long after a chat's push to a user further
+	// messages are folded into one collapsing push. Zero defaults to
+	// domain.PushCoalesceWindow.
+	CoalesceWindow time.Duration
+
+	// Control, if set, holds Run's releases while ConsumerPush is paused.
+	Control *ConsumerControl
+}
+
+// burstKey identifies a (user, chat) coalescing window.
+type burstKey struct {
+	userID string
+	chatID string
+}
+
+// burst tracks pushes for one (user, chat) inside a coalescing window.
+type burst struct {
+	closes  time.Time // end of the current window
+	total   int       // messages since the burst began
+	pending int       // messages not yet reflected in a sent push
+}
+
+// held is the notifications deferred for one user.
+type held struct {
+	until time.Time
+	count int
+	chats map[string]struct{}
+}
+
+// PushDispatcher sends push notifications to offline users.
+//
+// Notifications that fall in the user's quiet hours are held, not dropped:
+// once quiet hours end, ReleaseDue replaces them with one summary.
+//
+// Bursty chats are coalesced per (user, chat). The first message pushes at
+// once and opens a window; messages inside it are counted, and when it
+// closes one push with the running count replaces the earlier one on the
+// device through the chat's collapse key. A busy chat therefore costs one
+// push per window instead of one per message.
+//
+// State is in memory, so a restart loses it. Safe for concurrent use.
+type PushDispatcher struct {
+	sender    PushSender
+	schedules DNDStore
+	clock     domain.Clock
+	logger    *slog.Logger
+	window    time.Duration
+	control   *ConsumerControl
+
+	mu     sync.Mutex
+	held   map[string]*held // by user ID
+	bursts map[burstKey]*burst
+}
+
+// NewPushDispatcher creates a PushDispatcher.
+func NewPushDispatcher(cfg PushDispatcherConfig) *PushDispatcher {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	window := cfg.CoalesceWindow
+	if window <= 0 {
+		window = domain.PushCoalesceWindow
+	}
+	return &PushDispatcher{
+		sender:    cfg.Sender,
+		schedules: cfg.Schedules,
+		clock:     clock,
+		logger:    logger,
+		window:    window,
+		control:   cfg.Control,
+		held:      make(map[string]*held),
+		bursts:    make(map[burstKey]*burst),
+	}
+}
+
+// Dispatch sends n now, folds it into its chat's open coalescing window,
+// or holds it until the user's quiet hours for its chat end.
+func (d *PushDispatcher) Dispatch(ctx context.Context, n Notification) error {
+	schedule, err := d.schedules.DNDSchedule(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("push dispatcher: read schedule: %w", err)
+	}
+	now := d.clock.Now()
+	if until, quiet := schedule.QuietUntil(now, n.ChatID); quiet {
+		d.hold(n.UserID, until, 1, n.ChatID)
+		pushNotifications.Add(ctx, 1, pushDeferredAttr)
+		return nil
+	}
+
+	if n.ChatID != "" {
+		if d.coalesce(burstKey{userID: n.UserID, chatID: n.ChatID}, now) {
+			pushNotifications.Add(ctx, 1, pushCoalescedAttr)
+			return nil
+		}
+		n.CollapseKey = CollapseKey(n.ChatID)
+	}
+	if err := d.sender.Send(ctx, n); err != nil {
+		pushNotifications.Add(ctx, 1, pushFailedAttr)
+		return fmt.Errorf("push dispatcher: send: %w", err)
+	}
+	pushNotifications.Add(ctx, 1, pushSentAttr)
+	return nil
+}
+
+// coalesce counts a message for key. It reports whether the message falls
+// in an open window; if not, it opens one and the caller sends the message.
+func (d *PushDispatcher) coalesce(key burstKey, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if b, ok := d.bursts[key]; ok && now.Before(b.closes) {
+		b.total++
+		b.pending++
+		return true
+	}
+	d.bursts[key] = &burst{closes: now.Add(d.window), total: 1}
+	return false
+}
+
+// hold adds count notifications from chats to userID's held set. The set
+// is released at the latest quiet-hours end of any chat in it.
+func (d *PushDispatcher) hold(userID string, until time.Time, count int, chats ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.held[userID]
+	if !ok {
+		h = &held{chats: make(map[string]struct{})}
+		d.held[userID] = h
+	}
+	if until.After(h.until) {
+		h.until = until
+	}
+	h.count += count
+	for _, c := range chats {
+		h.chats[c] = struct{}{}
+	}
+}
+
+// ReleaseDue sends a summary to every user whose quiet hours have ended,
+// and a collapsing push for every closed window with uncounted messages. A
+// summary that fails to send is held again for the next sweep.
+func (d *PushDispatcher) ReleaseDue(ctx context.Context) {
+	now := d.clock.Now()
+	due := make(map[string]*held)
+	var collapsed []Notification
+	d.mu.Lock()
+	for userID, h := range d.held {
+		if !h.until.After(now) {
+			due[userID] = h
+			delete(d.held, userID)
+		}
+	}
+	for key, b := range d.bursts {
+		if now.Before(b.closes) {
+			continue
+		}
+		if b.pending == 0 {
+			delete(d.bursts, key) // the chat went quiet
+			continue
+		}
+		collapsed = append(collapsed, Notification{
+			UserID:      key.userID,
+			ChatID:      key.chatID,
+			Body:        newMessages(b.total),
+			Count:       b.total,
+			CollapseKey: CollapseKey(key.chatID),
+		})
+		// Keep the burst open so a chat that stays busy still pushes at
+		// most once per window.
+		b.pending = 0
+		b.closes = now.Add(d.window)
+	}
+	d.mu.Unlock()
+
+	for _, n := range collapsed {
+		if err := d.sender.Send(ctx, n); err != nil {
+			// The next message in the chat carries the count forward.
+			pushNotifications.Add(ctx, 1, pushFailedAttr)
+			d.logger.WarnContext(ctx, "collapsed push failed",
+				"user_id", n.UserID, "chat_id", n.ChatID, "count", n.Count, "error", err)
+			continue
+		}
+		pushNotifications.Add(ctx, 1, pushCollapsedAttr)
+	}
+
+	for userID, h := range due {
+		n := summarize(userID, h)
+		if err := d.sender.Send(ctx, n); err != nil {
+			pushNotifications.Add(ctx, 1, pushFailedAttr)
+			d.logger.WarnContext(ctx, "push summary failed; holding for retry",
+				"user_id", userID, "count", h.count, "error", err)
+			chats := make([]string, 0, len(h.chats))
+			for c := range h.chats {
+				chats = append(chats, c)
+			}
+			d.hold(userID, now, h.count, chats...)
+			continue
+		}
+		pushNotifications.Add(ctx, 1, pushSummaryAttr)
+	}
+}
+
+// Run calls ReleaseDue every interval until ctx is done, skipping ticks
+// while the push consumer is paused. Zero interval defaults to
+// domain.PushReleaseInterval.
+func (d *PushDispatcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = domain.PushReleaseInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if d.control != nil && d.control.Paused(ConsumerPush) {
+				continue
+			}
+			d.ReleaseDue(ctx)
+		}
+	}
+}
+
+// Held returns the number of notifications held for userID.
+func (d *PushDispatcher) Held(userID string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if h, ok := d.held[userID]; ok {
+		return h.count
+	}
+	return 0
+}
+
+// summarize builds the summary that replaces h's notifications.
+func summarize(userID string, h *held) Notification {
+	n := Notification{UserID: userID, Count: h.count}
+	if len(h.chats) == 1 {
+		for c := range h.chats {
+			n.ChatID = c
+		}
+		n.Body = newMessages(h.count)
+		n.CollapseKey = CollapseKey(n.ChatID)
+		return n
+	}
+	n.Body = fmt.Sprintf("%s in %d chats", newMessages(h.count), len(h.chats))
+	return n
+}
+
+// newMessages renders a message count for a notification body.
+func newMessages(count int) string {
+	if count == 1 {
+		return "1 new message"
+	}
+	return fmt.Sprintf("%d new messages", count)
+}
@@ -0,0 +1,213 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// recordingSender records sent notifications and fails while err is set.
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []app.Notification
+	err  error
+}
+
+func (s *recordingSender) Send(_ context.Context, n app.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func (s *recordingSender) failWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+type stubDNDStore struct {
+	schedule domain.DNDSchedule
+	err      error
+}
+
+func (s stubDNDStore) DNDSchedule(context.Context, string) (domain.DNDSchedule, error) {
+	return s.schedule, s.err
+}
+
+func TestPushDispatcher(t *testing.T) {
+	ctx := context.Background()
+	// Quiet 22:00-07:00 UTC, except the "family" chat.
+	schedule := domain.DNDSchedule{
+		Windows: []domain.QuietWindow{{Start: 22 * 60, End: 7 * 60}},
+		Chats:   map[string]domain.ChatDND{"family": {Bypass: true}},
+	}
+	night := time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)
+
+	newDispatcher := func(now time.Time) (*app.PushDispatcher, *recordingSender, *domaintest.FakeClock) {
+		sender := &recordingSender{}
+		clock := domaintest.NewFakeClock(now)
+		d := app.NewPushDispatcher(app.PushDispatcherConfig{
+			Sender:    sender,
+			Schedules: stubDNDStore{schedule: schedule},
+			Clock:     clock,
+		})
+		return d, sender, clock
+	}
+
+	t.Run("outside quiet hours sends immediately", func(t *testing.T) {
+		d, sender, _ := newDispatcher(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work", Body: "hi"}))
+
+		assert.Len(t, sender.sent, 1)
+		assert.Zero(t, d.Held("alice"))
+	})
+
+	t.Run("quiet hours defer, then release one summary", func(t *testing.T) {
+		d, sender, clock := newDispatcher(night)
+		for _, chat := range []string{"work", "work", "friends"} {
+			require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: chat}))
+		}
+		assert.Empty(t, sender.sent)
+		assert.Equal(t, 3, d.Held("alice"))
+
+		d.ReleaseDue(ctx)
+		assert.Empty(t, sender.sent, "quiet hours have not ended")
+
+		clock.Advance(8 * time.Hour)
+		d.ReleaseDue(ctx)
+
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, app.Notification{UserID: "alice", Body: "3 new messages in 2 chats", Count: 3}, sender.sent[0])
+		assert.Zero(t, d.Held("alice"))
+	})
+
+	t.Run("single chat summary keeps the chat", func(t *testing.T) {
+		d, sender, clock := newDispatcher(night)
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work"}))
+
+		clock.Advance(8 * time.Hour)
+		d.ReleaseDue(ctx)
+
+		require.Len(t, sender.sent, 1)
+		assert.Equal(t, app.Notification{
+			UserID: "alice", ChatID: "work", Body: "1 new message", Count: 1, CollapseKey: "chat:work",
+		}, sender.sent[0])
+	})
+
+	t.Run("chat override bypasses quiet hours", func(t *testing.T) {
+		d, sender, _ := newDispatcher(night)
+
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "family"}))
+
+		assert.Len(t, sender.sent, 1)
+	})
+
+	t.Run("failed summary is held for the next sweep", func(t *testing.T) {
+		d, sender, clock := newDispatcher(night)
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work"}))
+		clock.Advance(8 * time.Hour)
+
+		sender.failWith(errors.New("apns unavailable"))
+		d.ReleaseDue(ctx)
+		assert.Equal(t, 1, d.Held("alice"))
+
+		sender.failWith(nil)
+		d.ReleaseDue(ctx)
+		assert.Len(t, sender.sent, 1)
+		assert.Zero(t, d.Held("alice"))
+	})
+
+	t.Run("burst collapses into one push per window", func(t *testing.T) {
+		d, sender, clock := newDispatcher(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+		for range 50 {
+			require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work", Body: "hi"}))
+			clock.Advance(100 * time.Millisecond)
+		}
+		require.Len(t, sender.sent, 1, "first message pushes at once")
+		assert.Equal(t, "chat:work", sender.sent[0].CollapseKey)
+
+		clock.Advance(domain.PushCoalesceWindow)
+		d.ReleaseDue(ctx)
+
+		require.Len(t, sender.sent, 2)
+		assert.Equal(t, app.Notification{
+			UserID: "alice", ChatID: "work", Body: "50 new messages", Count: 50, CollapseKey: "chat:work",
+		}, sender.sent[1])
+
+		clock.Advance(domain.PushCoalesceWindow)
+		d.ReleaseDue(ctx)
+		assert.Len(t, sender.sent, 2, "nothing new to collapse")
+
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work"}))
+		assert.Len(t, sender.sent, 3, "a quiet chat pushes at once again")
+	})
+
+	t.Run("windows are per user and chat", func(t *testing.T) {
+		d, sender, _ := newDispatcher(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work"}))
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "friends"}))
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "bob", ChatID: "work"}))
+		require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "bob", ChatID: "work"}))
+
+		assert.Len(t, sender.sent, 3)
+	})
+
+	t.Run("schedule read failure", func(t *testing.T) {
+		d := app.NewPushDispatcher(app.PushDispatcherConfig{
+			Sender:    &recordingSender{},
+			Schedules: stubDNDStore{err: domain.ErrUnavailable},
+		})
+
+		err := d.Dispatch(ctx, app.Notification{UserID: "alice"})
+
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+}
+
+func TestPushDispatcher_RunHoldsWhilePaused(t *testing.T) {
+	ctx := context.Background()
+	schedule := domain.DNDSchedule{Windows: []domain.QuietWindow{{Start: 22 * 60, End: 7 * 60}}}
+	clock := domaintest.NewFakeClock(time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC))
+	control, err := app.NewConsumerControl(app.ConsumerControlConfig{Clock: clock, Paused: []string{app.ConsumerPush}})
+	require.NoError(t, err)
+	sender := &recordingSender{}
+	d := app.NewPushDispatcher(app.PushDispatcherConfig{
+		Sender:    sender,
+		Schedules: stubDNDStore{schedule: schedule},
+		Clock:     clock,
+		Control:   control,
+	})
+
+	require.NoError(t, d.Dispatch(ctx, app.Notification{UserID: "alice", ChatID: "work"}))
+	clock.Advance(8 * time.Hour)
+
+	runFor := func() {
+		runCtx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+		defer cancel()
+		d.Run(runCtx, time.Millisecond)
+	}
+
+	runFor()
+	assert.Equal(t, 1, d.Held("alice"), "paused dispatcher keeps notifications held")
+
+	_, err = control.Resume(ctx, app.ConsumerPush)
+	require.NoError(t, err)
+	runFor()
+	assert.Zero(t, d.Held("alice"))
+	assert.Len(t, sender.sent, 1)
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var pushDeliveries metric.Int64Counter
+
+func init() {
+	pushDeliveries, _ = otel.Meter("fanout/app").Int64Counter("fanout_push_deliveries_total",
+		metric.WithDescription("Per-device push deliveries by platform and result (delivered, invalid_token, failed)"))
+}
+
+// Provider feedback reasons for a rejected token.
+const (
+	// TokenUnregistered: the app was uninstalled or the user revoked
+	// notification permission (APNs 410 Unregistered, FCM UNREGISTERED).
+	TokenUnregistered = "unregistered"
+	// TokenExpired: the provider rotated the token (APNs ExpiredToken).
+	TokenExpired = "expired"
+	// TokenMalformed: the provider does not recognize the token at all
+	// (APNs BadDeviceToken, FCM INVALID_ARGUMENT on the token).
+	TokenMalformed = "malformed"
+)
+
+// TokenRejectedError is returned by a PushProvider when the provider reports
+// that a device token will never work again. Any other error is treated as
+// transient and leaves the token in place.
+type TokenRejectedError struct {
+	Reason string // one of the Token* reasons
+}
+
+func (e *TokenRejectedError) Error() string {
+	return "push token rejected: " + e.Reason
+}
+
+// PushProvider delivers a notification to one device through its platform.
+type PushProvider interface {
+	Push(ctx context.Context, token domain.DeviceToken, n Notification) error
+}
+
+// DeviceTokenStore reads and prunes registered device tokens.
+type DeviceTokenStore interface {
+	PushTokens(ctx context.Context, userID string) ([]domain.DeviceToken, error)
+	// DeletePushToken removes the device's token only if it still equals
+	// token, so a token the client refreshed meanwhile survives.
+	DeletePushToken(ctx context.Context, userID, deviceID, token string) error
+}
+
+// TokenSenderConfig holds the dependencies for TokenSender.
+type TokenSenderConfig struct {
+	Tokens    DeviceTokenStore
+	Providers map[domain.PushPlatform]PushProvider
+	Logger    *slog.Logger
+}
+
+// TokenSender is the PushSender that fans a notification out to each of
+// the user's registered devices. It acts on provider feedback: a token the
+// provider rejects is deleted so it is not retried on every later push.
+type TokenSender struct {
+	tokens    DeviceTokenStore
+	providers map[domain.PushPlatform]PushProvider
+	logger    *slog.Logger
+}
+
+var _ PushSender = (*TokenSender)(nil)
+
+// NewTokenSender creates a TokenSender.
+func NewTokenSender(cfg TokenSenderConfig) *TokenSender {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &TokenSender{tokens: cfg.Tokens, providers: cfg.Providers, logger: logger}
+}
+
+// Send pushes n to every device of n.UserID. It succeeds if any device
+// accepted the notification or the user has no usable device left; it
+// fails only when every delivery failed transiently, so the dispatcher's
+// retry covers the case where nothing reached the user.
+func (s *TokenSender) Send(ctx context.Context, n Notification) error {
+	tokens, err := s.tokens.PushTokens(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("token sender: list tokens: %w", err)
+	}
+
+	var errs []error
+	delivered := false
+	for _, tok := range tokens {
+		provider, ok := s.providers[tok.Platform]
+		if !ok {
+			s.logger.WarnContext(ctx, "no push provider for platform",
+				"platform", string(tok.Platform), "user_id", tok.UserID, "device_id", tok.DeviceID)
+			continue
+		}
+		platform := attribute.String("platform", string(tok.Platform))
+
+		err := provider.Push(ctx, tok, n)
+		var rejected *TokenRejectedError
+		switch {
+		case err == nil:
+			delivered = true
+			pushDeliveries.Add(ctx, 1, metric.WithAttributes(platform, attribute.String("result", "delivered")))
+		case errors.As(err, &rejected):
+			pushDeliveries.Add(ctx, 1, metric.WithAttributes(platform,
+				attribute.String("result", "invalid_token"), attribute.String("reason", rejected.Reason)))
+			s.prune(ctx, tok, rejected.Reason)
+		default:
+			pushDeliveries.Add(ctx, 1, metric.WithAttributes(platform, attribute.String("result", "failed")))
+			errs = append(errs, fmt.Errorf("%s device %s: %w", tok.Platform, tok.DeviceID, err))
+		}
+	}
+
+	if delivered || len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("token sender: %w", errors.Join(errs...))
+}
+
+// prune deletes a rejected token. Failure is logged, not returned: the next
+// push gets the same feedback and tries again.
+func (s *TokenSender) prune(ctx context.Context, tok domain.DeviceToken, reason string) {
+	if err := s.tokens.DeletePushToken(ctx, tok.UserID, tok.DeviceID, tok.Token); err != nil {
+		s.logger.WarnContext(ctx, "prune push token failed",
+			"user_id", tok.UserID, "device_id", tok.DeviceID, "reason", reason, "error", err)
+		return
+	}
+	s.logger.InfoContext(ctx, "pruned push token",
+		"user_id", tok.UserID, "device_id", tok.DeviceID, "platform", string(tok.Platform), "reason", reason)
+}
@@ -0,0 +1,146 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// memTokenStore is an in-memory DeviceTokenStore keyed by device ID.
+type memTokenStore struct {
+	tokens    map[string]domain.DeviceToken
+	listErr   error
+	deleteErr error
+}
+
+func (s *memTokenStore) PushTokens(_ context.Context, userID string) ([]domain.DeviceToken, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	var out []domain.DeviceToken
+	for _, t := range s.tokens {
+		if t.UserID == userID {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *memTokenStore) DeletePushToken(_ context.Context, _, deviceID, token string) error {
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+	if t, ok := s.tokens[deviceID]; ok && t.Token == token {
+		delete(s.tokens, deviceID)
+	}
+	return nil
+}
+
+// scriptedProvider returns the error scripted for each token value.
+type scriptedProvider struct {
+	results map[string]error
+	pushed  []string
+}
+
+func (p *scriptedProvider) Push(_ context.Context, tok domain.DeviceToken, _ app.Notification) error {
+	p.pushed = append(p.pushed, tok.Token)
+	return p.results[tok.Token]
+}
+
+func TestTokenSender_Send(t *testing.T) {
+	ctx := context.Background()
+	n := app.Notification{UserID: "alice", ChatID: "work", Body: "hi"}
+
+	newSender := func(results map[string]error, tokens ...domain.DeviceToken) (*app.TokenSender, *memTokenStore, *scriptedProvider) {
+		store := &memTokenStore{tokens: make(map[string]domain.DeviceToken)}
+		for _, tok := range tokens {
+			store.tokens[tok.DeviceID] = tok
+		}
+		provider := &scriptedProvider{results: results}
+		sender := app.NewTokenSender(app.TokenSenderConfig{
+			Tokens: store,
+			Providers: map[domain.PushPlatform]app.PushProvider{
+				domain.PushPlatformAPNs: provider,
+				domain.PushPlatformFCM:  provider,
+			},
+		})
+		return sender, store, provider
+	}
+	phone := domain.DeviceToken{UserID: "alice", DeviceID: "phone", Platform: domain.PushPlatformAPNs, Token: "apns-1"}
+	tablet := domain.DeviceToken{UserID: "alice", DeviceID: "tablet", Platform: domain.PushPlatformFCM, Token: "fcm-1"}
+
+	t.Run("pushes to every device", func(t *testing.T) {
+		sender, _, provider := newSender(nil, phone, tablet)
+
+		require.NoError(t, sender.Send(ctx, n))
+
+		assert.ElementsMatch(t, []string{"apns-1", "fcm-1"}, provider.pushed)
+	})
+
+	t.Run("rejected token is pruned", func(t *testing.T) {
+		sender, store, _ := newSender(map[string]error{
+			"apns-1": &app.TokenRejectedError{Reason: app.TokenUnregistered},
+		}, phone, tablet)
+
+		require.NoError(t, sender.Send(ctx, n))
+
+		assert.NotContains(t, store.tokens, "phone")
+		assert.Contains(t, store.tokens, "tablet")
+	})
+
+	t.Run("wrapped rejection is recognized", func(t *testing.T) {
+		sender, store, _ := newSender(map[string]error{
+			"fcm-1": fmt.Errorf("fcm: %w", &app.TokenRejectedError{Reason: app.TokenMalformed}),
+		}, tablet)
+
+		require.NoError(t, sender.Send(ctx, n), "no usable device left is not a failure")
+
+		assert.Empty(t, store.tokens)
+	})
+
+	t.Run("transient failure keeps the token", func(t *testing.T) {
+		sender, store, _ := newSender(map[string]error{"apns-1": errors.New("503")}, phone)
+
+		err := sender.Send(ctx, n)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "apns device phone")
+		assert.Contains(t, store.tokens, "phone")
+	})
+
+	t.Run("one delivery is enough", func(t *testing.T) {
+		sender, _, _ := newSender(map[string]error{"apns-1": errors.New("503")}, phone, tablet)
+
+		assert.NoError(t, sender.Send(ctx, n))
+	})
+
+	t.Run("prune failure does not fail the send", func(t *testing.T) {
+		sender, store, _ := newSender(map[string]error{
+			"apns-1": &app.TokenRejectedError{Reason: app.TokenExpired},
+		}, phone)
+		store.deleteErr = errors.New("throttled")
+
+		assert.NoError(t, sender.Send(ctx, n))
+	})
+
+	t.Run("device without provider is skipped", func(t *testing.T) {
+		store := &memTokenStore{tokens: map[string]domain.DeviceToken{"phone": phone}}
+		sender := app.NewTokenSender(app.TokenSenderConfig{Tokens: store})
+
+		assert.NoError(t, sender.Send(ctx, n))
+	})
+
+	t.Run("token read failure", func(t *testing.T) {
+		store := &memTokenStore{listErr: domain.ErrUnavailable}
+		sender := app.NewTokenSender(app.TokenSenderConfig{Tokens: store})
+
+		assert.ErrorIs(t, sender.Send(ctx, n), domain.ErrUnavailable)
+	})
+}
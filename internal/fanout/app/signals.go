@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var signalDeliveries metric.Int64Counter
+
+func init() {
+	signalDeliveries, _ = otel.Meter("fanout/app").Int64Counter("fanout_signal_deliveries_total",
+		metric.WithDescription("Activity signal deliveries published to Gateway instances, by signal and result (published, failed)"))
+}
+
+// Signal is an activity signal about a user: typing or a read receipt in a
+// chat, or a presence change. Only the fields of its Kind are set.
+type Signal struct {
+	Kind   domain.PrivacySignal
+	UserID string
+
+	// ChatID is the chat a typing or read receipt signal is in.
+	ChatID string
+	// Active reports whether the user started (true) or stopped typing.
+	Active bool
+	// Sequence is the last message a read receipt covers.
+	Sequence uint64
+	// Online is the user's presence; LastSeenAt is when they went offline.
+	Online     bool
+	LastSeenAt time.Time
+}
+
+// frame returns the frame recipients get for s.
+func (s Signal) frame() (*protocol.Frame, error) {
+	switch s.Kind {
+	case domain.SignalTyping:
+		return protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{
+			ChatID: s.ChatID, UserID: s.UserID, Active: s.Active,
+		})
+	case domain.SignalReadReceipt:
+		return protocol.NewFrame(protocol.FrameTypeReceipt, protocol.Receipt{
+			ChatID: s.ChatID, UserID: s.UserID, Sequence: s.Sequence, Status: protocol.ReceiptRead,
+		})
+	case domain.SignalLastSeen:
+		p := protocol.Presence{UserID: s.UserID, Online: s.Online}
+		if !s.Online && !s.LastSeenAt.IsZero() {
+			p.LastSeenAt = s.LastSeenAt.UnixMilli()
+		}
+		return protocol.NewFrame(protocol.FrameTypePresence, p)
+	default:
+		return nil, fmt.Errorf("unknown signal %d", s.Kind)
+	}
+}
+
+// ContactLister lists the users who share a chat with a user: the
+// audience of their presence.
+type ContactLister interface {
+	Contacts(ctx context.Context, userID string) ([]string, error)
+}
+
+// SignalDispatcherConfig holds the dependencies for SignalDispatcher.
+type SignalDispatcherConfig struct {
+	Members  MemberLister
+	Contacts ContactLister
+	Privacy  *PrivacyFilter
+	Routes   RouteLookup
+	Gateways GatewayPublisher
+	Logger   *slog.Logger
+
+	// Timeout bounds each publish to a Gateway. Zero defaults to
+	// domain.DeliveryTimeout.
+	Timeout time.Duration
+}
+
+// SignalDispatcher delivers activity signals to the Gateways of the users
+// allowed to see them. Typing and read receipts go to the chat's other
+// members, presence to the user's contacts; privacy settings are enforced
+// on every recipient before routing, so a hidden signal never leaves
+// Fanout. Like messages, signals are delivered best effort, and unlike
+// messages nothing catches up on a missed one.
+type SignalDispatcher struct {
+	members  MemberLister
+	contacts ContactLister
+	privacy  *PrivacyFilter
+	routes   RouteLookup
+	gateways GatewayPublisher
+	logger   *slog.Logger
+	timeout  time.Duration
+}
+
+// NewSignalDispatcher creates a SignalDispatcher with the given
+// dependencies.
+func NewSignalDispatcher(cfg SignalDispatcherConfig) *SignalDispatcher {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = domain.DeliveryTimeout
+	}
+	return &SignalDispatcher{
+		members:  cfg.Members,
+		contacts: cfg.Contacts,
+		privacy:  cfg.Privacy,
+		routes:   cfg.Routes,
+		gateways: cfg.Gateways,
+		logger:   cfg.Logger,
+		timeout:  timeout,
+	}
+}
+
+// Dispatch publishes sig to the Gateways its allowed recipients are
+// connected to. It returns domain.ErrNotMember for a typing or read
+// receipt signal from a non-member, and fails when recipients, settings or
+// routes cannot be read; failed publishes are logged and counted.
+func (d *SignalDispatcher) Dispatch(ctx context.Context, sig Signal) error {
+	ctx, span := tracer.Start(ctx, "fanout.dispatch_signal")
+	defer span.End()
+	span.SetAttributes(attribute.String("signal.kind", sig.Kind.String()))
+
+	audience, err := d.audience(ctx, sig)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("dispatch %s signal: %w", sig.Kind, err)
+	}
+	recipients, err := d.privacy.Recipients(ctx, sig.Kind, sig.UserID, audience)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("dispatch %s signal: %w", sig.Kind, err)
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	routes, err := d.routes.Gateways(ctx, recipients)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("dispatch %s signal: look up routes: %w", sig.Kind, err)
+	}
+	span.SetAttributes(
+		attribute.Int("dispatch.recipients", len(recipients)),
+		attribute.Int("dispatch.gateways", len(routes)),
+	)
+
+	// One prepared frame is shared by every delivery.
+	frame, err := sig.frame()
+	if err == nil {
+		err = frame.Prepare()
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("dispatch %s signal: encode: %w", sig.Kind, err)
+	}
+
+	signalAttr := attribute.String("signal", sig.Kind.String())
+	for gatewayID, userIDs := range routes {
+		if err := d.publish(ctx, gatewayID, Delivery{UserIDs: userIDs, Signal: frame}); err != nil {
+			signalDeliveries.Add(ctx, 1, metric.WithAttributes(signalAttr, attribute.String("result", "failed")))
+			d.logger.DebugContext(ctx, "signal delivery failed, dropped",
+				"gateway_id", gatewayID, "signal", sig.Kind.String(), "error", err)
+			continue
+		}
+		signalDeliveries.Add(ctx, 1, metric.WithAttributes(signalAttr, attribute.String("result", "published")))
+	}
+	return nil
+}
+
+// audience returns the users sig is about to be shown to, before privacy
+// settings are applied.
+func (d *SignalDispatcher) audience(ctx context.Context, sig Signal) ([]string, error) {
+	if sig.Kind == domain.SignalLastSeen {
+		if !sig.Online {
+			// A user still connected to another Gateway is still online.
+			routes, err := d.routes.Gateways(ctx, []string{sig.UserID})
+			if err != nil {
+				return nil, fmt.Errorf("look up routes: %w", err)
+			}
+			if len(routes) > 0 {
+				return nil, nil
+			}
+		}
+		contacts, err := d.contacts.Contacts(ctx, sig.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("list contacts: %w", err)
+		}
+		return contacts, nil
+	}
+
+	members, err := d.members.Members(ctx, sig.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+	if !slices.Contains(members, sig.UserID) {
+		return nil, domain.ErrNotMember
+	}
+	return members, nil
+}
+
+// publish hands one delivery to a Gateway within the publish timeout.
+func (d *SignalDispatcher) publish(ctx context.Context, gatewayID string, delivery Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	return d.gateways.Publish(ctx, gatewayID, delivery)
+}
@@ -0,0 +1,132 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+type stubContacts struct {
+	contacts []string
+	err      error
+}
+
+func (s stubContacts) Contacts(context.Context, string) ([]string, error) {
+	return s.contacts, s.err
+}
+
+func TestSignalDispatcher_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	settings := map[string]domain.PrivacySettings{
+		"hides-receipts": {HideReadReceipts: true},
+		"hides-seen":     {HideLastSeen: true},
+	}
+	routes := map[string][]string{
+		"alice":          {"gw-1"},
+		"bob":            {"gw-1"},
+		"carol":          {"gw-2"},
+		"hides-receipts": {"gw-2"},
+		"hides-seen":     {"gw-2"},
+	}
+
+	newDispatcher := func(members app.MemberLister, contacts app.ContactLister, routes app.RouteLookup, gateways app.GatewayPublisher) *app.SignalDispatcher {
+		return app.NewSignalDispatcher(app.SignalDispatcherConfig{
+			Members:  members,
+			Contacts: contacts,
+			Privacy:  app.NewPrivacyFilter(&stubPrivacyStore{settings: settings}),
+			Routes:   routes,
+			Gateways: gateways,
+			Logger:   slog.Default(),
+		})
+	}
+	frameOf := func(t *testing.T, d app.Delivery, v any) protocol.FrameType {
+		t.Helper()
+		require.NotNil(t, d.Signal)
+		require.NoError(t, d.Signal.ParsePayload(v))
+		return d.Signal.Type
+	}
+
+	t.Run("typing reaches the other members", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		d := newDispatcher(stubMembers{members: []string{"alice", "bob", "hides-seen"}}, nil, &stubRoutes{routes: routes}, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalTyping, UserID: "alice", ChatID: "chat-1", Active: true}))
+
+		assert.Equal(t, []string{"bob"}, gateways.published["gw-1"].UserIDs)
+		assert.Equal(t, []string{"hides-seen"}, gateways.published["gw-2"].UserIDs, "typing is not reciprocal")
+		var typing protocol.Typing
+		assert.Equal(t, protocol.FrameTypeTyping, frameOf(t, gateways.published["gw-1"], &typing))
+		assert.Equal(t, protocol.Typing{ChatID: "chat-1", UserID: "alice", Active: true}, typing)
+		assert.Same(t, gateways.published["gw-1"].Signal, gateways.published["gw-2"].Signal, "the frame is encoded once")
+	})
+
+	t.Run("read receipts skip members who hide theirs", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		d := newDispatcher(stubMembers{members: []string{"alice", "carol", "hides-receipts"}}, nil, &stubRoutes{routes: routes}, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalReadReceipt, UserID: "alice", ChatID: "chat-1", Sequence: 9}))
+
+		require.Len(t, gateways.published, 1)
+		assert.Equal(t, []string{"carol"}, gateways.published["gw-2"].UserIDs)
+		var receipt protocol.Receipt
+		assert.Equal(t, protocol.FrameTypeReceipt, frameOf(t, gateways.published["gw-2"], &receipt))
+		assert.Equal(t, protocol.Receipt{ChatID: "chat-1", UserID: "alice", Sequence: 9, Status: protocol.ReceiptRead}, receipt)
+	})
+
+	t.Run("a subject hiding the signal reaches nobody", func(t *testing.T) {
+		routes := &stubRoutes{routes: routes}
+		d := newDispatcher(stubMembers{members: []string{"hides-receipts", "bob"}}, nil, routes, &recordingGateways{})
+
+		require.NoError(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalReadReceipt, UserID: "hides-receipts", ChatID: "chat-1", Sequence: 1}))
+
+		assert.Nil(t, routes.asked, "routes are not read")
+	})
+
+	t.Run("non-members are refused", func(t *testing.T) {
+		d := newDispatcher(stubMembers{members: []string{"bob"}}, nil, &stubRoutes{routes: routes}, &recordingGateways{})
+
+		err := d.Dispatch(ctx, app.Signal{Kind: domain.SignalTyping, UserID: "mallory", ChatID: "chat-1", Active: true})
+
+		assert.ErrorIs(t, err, domain.ErrNotMember)
+	})
+
+	t.Run("presence reaches contacts who share last-seen", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		lastSeen := time.UnixMilli(1_700_000_000_000)
+		d := newDispatcher(nil, stubContacts{contacts: []string{"bob", "hides-seen"}}, &stubRoutes{routes: routes}, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalLastSeen, UserID: "dave", LastSeenAt: lastSeen}))
+
+		require.Len(t, gateways.published, 1)
+		assert.Equal(t, []string{"bob"}, gateways.published["gw-1"].UserIDs)
+		var presence protocol.Presence
+		assert.Equal(t, protocol.FrameTypePresence, frameOf(t, gateways.published["gw-1"], &presence))
+		assert.Equal(t, protocol.Presence{UserID: "dave", LastSeenAt: lastSeen.UnixMilli()}, presence)
+	})
+
+	t.Run("offline is withheld while connected elsewhere", func(t *testing.T) {
+		gateways := &recordingGateways{published: map[string]app.Delivery{}}
+		d := newDispatcher(nil, stubContacts{contacts: []string{"bob"}}, &stubRoutes{routes: routes}, gateways)
+
+		require.NoError(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalLastSeen, UserID: "alice", LastSeenAt: time.Now()}))
+
+		assert.Empty(t, gateways.published)
+	})
+
+	t.Run("read errors fail the dispatch", func(t *testing.T) {
+		d := newDispatcher(stubMembers{err: errors.New("throttled")}, nil, &stubRoutes{}, &recordingGateways{})
+		assert.ErrorContains(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalTyping, UserID: "alice", ChatID: "chat-1"}), "throttled")
+
+		d = newDispatcher(nil, stubContacts{err: errors.New("throttled")}, &stubRoutes{}, &recordingGateways{})
+		assert.ErrorContains(t, d.Dispatch(ctx, app.Signal{Kind: domain.SignalLastSeen, UserID: "alice", Online: true}), "throttled")
+	})
+}
@@ -0,0 +1,233 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	smsMessages metric.Int64Counter
+
+	smsSentAttr      = metric.WithAttributes(attribute.String("result", "sent"))
+	smsCoalescedAttr = metric.WithAttributes(attribute.String("result", "coalesced"))
+	smsCappedAttr    = metric.WithAttributes(attribute.String("result", "capped"))
+	smsFailedAttr    = metric.WithAttributes(attribute.String("result", "failed"))
+)
+
+func init() {
+	smsMessages, _ = otel.Meter("fanout/app").Int64Counter("fanout_sms_fallback_total",
+		metric.WithDescription("SMS fallback deliveries by result (sent, coalesced, capped, failed)"))
+}
+
+// SMSMessage is a chat message to deliver by SMS to one offline user.
+type SMSMessage struct {
+	UserID     string
+	ChatID     string
+	SenderName string
+	Text       string
+}
+
+// SMSRecipient is what SMS fallback reads about a user.
+type SMSRecipient struct {
+	Phone    string // E.164; empty when the user has none
+	Settings domain.SMSFallback
+	// LastActiveAt is the user's latest activity on any session; zero when
+	// none is recorded.
+	LastActiveAt time.Time
+}
+
+// SMSRecipientStore reads users' SMS fallback settings and activity.
+type SMSRecipientStore interface {
+	SMSRecipient(ctx context.Context, userID string) (SMSRecipient, error)
+}
+
+// SMSSender sends one SMS through the provider.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone, body string) error
+}
+
+// SMSBudget enforces the daily SMS caps across Fanout instances.
+type SMSBudget interface {
+	// Take claims one SMS for userID on day against both userID's limit and
+	// the global limit. It reports false, claiming nothing, when either is
+	// spent.
+	Take(ctx context.Context, day, userID string, userLimit, globalLimit int) (bool, error)
+}
+
+// SMSReplyRoutes remembers the chat that last texted each phone, so a
+// reply can be posted back to it.
+type SMSReplyRoutes interface {
+	SetReplyRoute(ctx context.Context, phone, chatID string, ttl time.Duration) error
+	// ReplyRoute returns the chat for phone, or domain.ErrNotFound when
+	// none is current.
+	ReplyRoute(ctx context.Context, phone string) (string, error)
+}
+
+// SMSFallbackConfig holds the dependencies for SMSFallback.
+type SMSFallbackConfig struct {
+	Recipients SMSRecipientStore
+	Sender     SMSSender
+	Budget     SMSBudget
+	Routes     SMSReplyRoutes
+	Clock      domain.Clock
+	Logger     *slog.Logger
+
+	// UserDailyLimit and GlobalDailyLimit cap the SMS sent per user and in
+	// total per UTC day. Zero defaults to domain.SMSDailyLimitPerUser and
+	// domain.SMSGlobalDailyLimit.
+	UserDailyLimit   int
+	GlobalDailyLimit int
+
+	// CoalesceWindow is how long after a chat texts a user further
+	// messages from it are dropped. Zero defaults to
+	// domain.SMSCoalesceWindow.
+	CoalesceWindow time.Duration
+}
+
+// SMSFallback texts messages to users who opted in to SMS fallback and
+// have been offline past their threshold.
+//
+// SMS is billed per segment, so spend is bounded three ways: each message
+// is cut to domain.MaxSMSSegments, a chat texts a user at most once per
+// coalescing window, and daily per-user and global caps are enforced in
+// shared storage. The caps fail closed: if the budget cannot be read,
+// nothing is sent.
+//
+// Each SMS records its chat as the reply route for the phone, so the
+// user's reply is posted back there (see SMSReplyService).
+//
+// Coalescing state is in memory, so a restart forgets it; the daily caps
+// still hold. Safe for concurrent use.
+type SMSFallback struct {
+	recipients  SMSRecipientStore
+	sender      SMSSender
+	budget      SMSBudget
+	routes      SMSReplyRoutes
+	clock       domain.Clock
+	logger      *slog.Logger
+	userLimit   int
+	globalLimit int
+	window      time.Duration
+
+	mu       sync.Mutex
+	lastSent map[burstKey]time.Time
+}
+
+// NewSMSFallback creates an SMSFallback.
+func NewSMSFallback(cfg SMSFallbackConfig) *SMSFallback {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	userLimit := cfg.UserDailyLimit
+	if userLimit <= 0 {
+		userLimit = domain.SMSDailyLimitPerUser
+	}
+	globalLimit := cfg.GlobalDailyLimit
+	if globalLimit <= 0 {
+		globalLimit = domain.SMSGlobalDailyLimit
+	}
+	window := cfg.CoalesceWindow
+	if window <= 0 {
+		window = domain.SMSCoalesceWindow
+	}
+	return &SMSFallback{
+		recipients:  cfg.Recipients,
+		sender:      cfg.Sender,
+		budget:      cfg.Budget,
+		routes:      cfg.Routes,
+		clock:       clock,
+		logger:      logger,
+		userLimit:   userLimit,
+		globalLimit: globalLimit,
+		window:      window,
+		lastSent:    make(map[burstKey]time.Time),
+	}
+}
+
+// Deliver texts msg to its recipient if they opted in and are offline. It
+// reports whether an SMS was sent.
+func (f *SMSFallback) Deliver(ctx context.Context, msg SMSMessage) (bool, error) {
+	r, err := f.recipients.SMSRecipient(ctx, msg.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("sms fallback: read recipient: %w", err)
+	}
+	now := f.clock.Now()
+	if r.Phone == "" || !r.Settings.Due(r.LastActiveAt, now) {
+		return false, nil
+	}
+
+	key := burstKey{userID: msg.UserID, chatID: msg.ChatID}
+	if f.inWindow(key, now) {
+		smsMessages.Add(ctx, 1, smsCoalescedAttr)
+		return false, nil
+	}
+
+	ok, err := f.budget.Take(ctx, now.UTC().Format(time.DateOnly), msg.UserID, f.userLimit, f.globalLimit)
+	if err != nil {
+		return false, fmt.Errorf("sms fallback: take budget: %w", err)
+	}
+	if !ok {
+		smsMessages.Add(ctx, 1, smsCappedAttr)
+		f.logger.WarnContext(ctx, "sms fallback daily limit reached",
+			"user_id", msg.UserID, "chat_id", msg.ChatID)
+		return false, nil
+	}
+
+	// Record the route first: an SMS the user cannot answer is worse than
+	// a route to a chat whose SMS failed.
+	if err := f.routes.SetReplyRoute(ctx, r.Phone, msg.ChatID, domain.SMSReplyWindow); err != nil {
+		f.logger.WarnContext(ctx, "sms reply route not saved",
+			"user_id", msg.UserID, "chat_id", msg.ChatID, "error", err)
+	}
+	body := domain.FormatSMS(msg.SenderName, msg.Text, domain.MaxSMSSegments)
+	if err := f.sender.SendSMS(ctx, r.Phone, body); err != nil {
+		smsMessages.Add(ctx, 1, smsFailedAttr)
+		return false, fmt.Errorf("sms fallback: send: %w", err)
+	}
+	f.markSent(key, now)
+	smsMessages.Add(ctx, 1, smsSentAttr)
+	return true, nil
+}
+
+// inWindow reports whether key was texted within the coalescing window.
+func (f *SMSFallback) inWindow(key burstKey, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	last, ok := f.lastSent[key]
+	if ok && now.Sub(last) >= f.window {
+		delete(f.lastSent, key)
+		return false
+	}
+	return ok
+}
+
+// markSent opens key's coalescing window and drops closed ones. Sends are
+// capped daily, so the sweep stays small.
+func (f *SMSFallback) markSent(key burstKey, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, last := range f.lastSent {
+		if now.Sub(last) >= f.window {
+			delete(f.lastSent, k)
+		}
+	}
+	f.lastSent[key] = now
+}
@@ -0,0 +1,224 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+type stubRecipients map[string]app.SMSRecipient
+
+func (s stubRecipients) SMSRecipient(_ context.Context, userID string) (app.SMSRecipient, error) {
+	r, ok := s[userID]
+	if !ok {
+		return app.SMSRecipient{}, domain.ErrNotFound
+	}
+	return r, nil
+}
+
+type recordingSMSSender struct {
+	mu   sync.Mutex
+	sent []string // phone|body
+	err  error
+}
+
+func (s *recordingSMSSender) SendSMS(_ context.Context, phone, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, phone+"|"+body)
+	return nil
+}
+
+// memoryBudget counts claims per day and user.
+type memoryBudget struct {
+	mu     sync.Mutex
+	user   map[string]int
+	global map[string]int
+	err    error
+}
+
+func newMemoryBudget() *memoryBudget {
+	return &memoryBudget{user: make(map[string]int), global: make(map[string]int)}
+}
+
+func (b *memoryBudget) Take(_ context.Context, day, userID string, userLimit, globalLimit int) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return false, b.err
+	}
+	if b.user[day+userID] >= userLimit || b.global[day] >= globalLimit {
+		return false, nil
+	}
+	b.user[day+userID]++
+	b.global[day]++
+	return true, nil
+}
+
+// memoryRoutes holds reply routes without expiry.
+type memoryRoutes struct {
+	mu     sync.Mutex
+	routes map[string]string
+}
+
+func newMemoryRoutes() *memoryRoutes {
+	return &memoryRoutes{routes: make(map[string]string)}
+}
+
+func (r *memoryRoutes) SetReplyRoute(_ context.Context, phone, chatID string, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[phone] = chatID
+	return nil
+}
+
+func (r *memoryRoutes) ReplyRoute(_ context.Context, phone string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chatID, ok := r.routes[phone]
+	if !ok {
+		return "", domain.ErrNotFound
+	}
+	return chatID, nil
+}
+
+func TestSMSFallback_Deliver(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	recipients := stubRecipients{
+		"offline": {Phone: "+14155550100", Settings: domain.SMSFallback{Enabled: true}, LastActiveAt: now.Add(-time.Hour)},
+		"online":  {Phone: "+14155550101", Settings: domain.SMSFallback{Enabled: true}, LastActiveAt: now.Add(-time.Minute)},
+		"optout":  {Phone: "+14155550102", LastActiveAt: now.Add(-time.Hour)},
+		"nophone": {Settings: domain.SMSFallback{Enabled: true}},
+	}
+
+	type fixture struct {
+		f      *app.SMSFallback
+		sender *recordingSMSSender
+		budget *memoryBudget
+		routes *memoryRoutes
+		clock  *domaintest.FakeClock
+	}
+	newFallback := func(cfg app.SMSFallbackConfig) fixture {
+		fx := fixture{
+			sender: &recordingSMSSender{},
+			budget: newMemoryBudget(),
+			routes: newMemoryRoutes(),
+			clock:  domaintest.NewFakeClock(now),
+		}
+		cfg.Recipients, cfg.Sender, cfg.Budget, cfg.Routes, cfg.Clock = recipients, fx.sender, fx.budget, fx.routes, fx.clock
+		fx.f = app.NewSMSFallback(cfg)
+		return fx
+	}
+	msg := app.SMSMessage{UserID: "offline", ChatID: "chat-1", SenderName: "Alice", Text: "running late"}
+
+	t.Run("texts an offline user and records the reply route", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{})
+
+		sent, err := fx.f.Deliver(ctx, msg)
+
+		require.NoError(t, err)
+		assert.True(t, sent)
+		assert.Equal(t, []string{"+14155550100|Alice: running late"}, fx.sender.sent)
+		assert.Equal(t, "chat-1", fx.routes.routes["+14155550100"])
+	})
+
+	t.Run("skips online, opted-out, phoneless and unknown users", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{})
+
+		for _, userID := range []string{"online", "optout", "nophone", "ghost"} {
+			m := msg
+			m.UserID = userID
+			sent, err := fx.f.Deliver(ctx, m)
+			require.NoError(t, err)
+			assert.False(t, sent, userID)
+		}
+		assert.Empty(t, fx.sender.sent)
+	})
+
+	t.Run("one SMS per chat per coalescing window", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{CoalesceWindow: 10 * time.Minute})
+
+		sent, _ := fx.f.Deliver(ctx, msg)
+		assert.True(t, sent)
+		sent, _ = fx.f.Deliver(ctx, msg)
+		assert.False(t, sent, "same chat inside the window")
+
+		other := msg
+		other.ChatID = "chat-2"
+		sent, _ = fx.f.Deliver(ctx, other)
+		assert.True(t, sent, "other chats have their own window")
+
+		fx.clock.Advance(10 * time.Minute)
+		sent, _ = fx.f.Deliver(ctx, msg)
+		assert.True(t, sent, "window closed")
+		assert.Len(t, fx.sender.sent, 3)
+	})
+
+	t.Run("daily caps", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{UserDailyLimit: 2})
+
+		for _, chatID := range []string{"a", "b", "c"} {
+			m := msg
+			m.ChatID = chatID
+			_, err := fx.f.Deliver(ctx, m)
+			require.NoError(t, err)
+		}
+		assert.Len(t, fx.sender.sent, 2)
+
+		fx.clock.Advance(24 * time.Hour)
+		sent, err := fx.f.Deliver(ctx, msg)
+		require.NoError(t, err)
+		assert.True(t, sent, "the caps reset each UTC day")
+	})
+
+	t.Run("budget errors fail closed", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{})
+		fx.budget.err = errors.New("redis down")
+
+		sent, err := fx.f.Deliver(ctx, msg)
+
+		require.Error(t, err)
+		assert.False(t, sent)
+		assert.Empty(t, fx.sender.sent)
+	})
+
+	t.Run("failed send does not open a window", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{})
+		fx.sender.err = errors.New("throttled")
+
+		_, err := fx.f.Deliver(ctx, msg)
+		require.Error(t, err)
+
+		fx.sender.err = nil
+		sent, err := fx.f.Deliver(ctx, msg)
+		require.NoError(t, err)
+		assert.True(t, sent)
+	})
+
+	t.Run("long messages are cut to the segment limit", func(t *testing.T) {
+		fx := newFallback(app.SMSFallbackConfig{})
+		long := msg
+		long.Text = strings.Repeat("lorem ipsum ", 100)
+
+		_, err := fx.f.Deliver(ctx, long)
+
+		require.NoError(t, err)
+		require.Len(t, fx.sender.sent, 1)
+		body := strings.TrimPrefix(fx.sender.sent[0], "+14155550100|")
+		assert.Equal(t, domain.MaxSMSSegments, domain.SMSSegments(body))
+	})
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var smsReplies metric.Int64Counter
+
+func init() {
+	smsReplies, _ = otel.Meter("fanout/app").Int64Counter("fanout_sms_replies_total",
+		metric.WithDescription("Inbound SMS replies by outcome"))
+}
+
+// SMSReply is an inbound SMS from the provider.
+type SMSReply struct {
+	// MessageID is the provider's ID for the inbound SMS. Providers
+	// redeliver, so it doubles as the idempotency key.
+	MessageID string
+	From      string
+	Body      string
+}
+
+// SMSReplyOutcome is what became of an SMS reply.
+type SMSReplyOutcome string
+
+// SMS reply outcomes.
+const (
+	SMSReplyPosted    SMSReplyOutcome = "posted"
+	SMSReplyStopped   SMSReplyOutcome = "stopped"
+	SMSReplyUnknown   SMSReplyOutcome = "unknown_sender"
+	SMSReplyDisabled  SMSReplyOutcome = "disabled"
+	SMSReplyUnrouted  SMSReplyOutcome = "unrouted"
+	SMSReplyNotMember SMSReplyOutcome = "not_member"
+	SMSReplyRejected  SMSReplyOutcome = "rejected"
+)
+
+// SMSAccounts resolves SMS senders to users and lets them opt out.
+type SMSAccounts interface {
+	// UserByPhone returns the user registered with phone and their SMS
+	// fallback setting, or domain.ErrNotFound.
+	UserByPhone(ctx context.Context, phone string) (string, domain.SMSFallback, error)
+	DisableSMSFallback(ctx context.Context, userID string) error
+}
+
+// SMSMembership checks chat membership for SMS replies.
+type SMSMembership interface {
+	IsMember(ctx context.Context, chatID, userID string) (bool, error)
+}
+
+// SMSReplySubmission is an SMS reply to persist as a chat message.
+type SMSReplySubmission struct {
+	ChatID          domain.ChatID
+	SenderID        domain.UserID
+	ClientMessageID string
+	Content         domain.MessageContent
+	ReceivedAt      domain.ServerTime
+}
+
+// SMSReplySink hands SMS replies to Ingest. Submitting a ClientMessageID
+// that is already stored succeeds without a second message.
+type SMSReplySink interface {
+	Submit(ctx context.Context, msg SMSReplySubmission) error
+}
+
+// SMSReplyServiceConfig holds the dependencies for SMSReplyService.
+type SMSReplyServiceConfig struct {
+	Accounts SMSAccounts
+	Routes   SMSReplyRoutes
+	Members  SMSMembership
+	Sink     SMSReplySink
+	Clock    domain.Clock
+	Logger   *slog.Logger
+}
+
+// SMSReplyService posts SMS replies back to the chat that last texted the
+// phone, within domain.SMSReplyWindow of that SMS. Replies are accepted
+// only from users with SMS fallback on who are still members of the chat.
+//
+// An opt-out keyword (STOP and the like) turns SMS fallback off instead of
+// being posted; carriers block further SMS on their side as well.
+type SMSReplyService struct {
+	accounts SMSAccounts
+	routes   SMSReplyRoutes
+	members  SMSMembership
+	sink     SMSReplySink
+	clock    domain.Clock
+	logger   *slog.Logger
+}
+
+// NewSMSReplyService creates an SMSReplyService.
+func NewSMSReplyService(cfg SMSReplyServiceConfig) *SMSReplyService {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SMSReplyService{
+		accounts: cfg.Accounts,
+		routes:   cfg.Routes,
+		members:  cfg.Members,
+		sink:     cfg.Sink,
+		clock:    clock,
+		logger:   logger,
+	}
+}
+
+// smsOptOutKeywords are the replies carriers treat as an opt-out.
+var smsOptOutKeywords = map[string]struct{}{
+	"STOP": {}, "STOPALL": {}, "UNSUBSCRIBE": {}, "CANCEL": {}, "END": {}, "QUIT": {},
+}
+
+// HandleReply posts reply to its chat. Replies that cannot be posted are
+// reported by outcome, not error; an error means the reply should be
+// redelivered.
+func (s *SMSReplyService) HandleReply(ctx context.Context, reply SMSReply) (SMSReplyOutcome, error) {
+	outcome, err := s.handle(ctx, reply)
+	if err != nil {
+		return "", err
+	}
+	smsReplies.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", string(outcome))))
+	return outcome, nil
+}
+
+func (s *SMSReplyService) handle(ctx context.Context, reply SMSReply) (SMSReplyOutcome, error) {
+	phone, err := domain.NewPhoneNumber(reply.From)
+	if err != nil {
+		return SMSReplyUnknown, nil
+	}
+	userID, settings, err := s.accounts.UserByPhone(ctx, phone.String())
+	if errors.Is(err, domain.ErrNotFound) {
+		return SMSReplyUnknown, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("sms reply: look up sender: %w", err)
+	}
+
+	if _, ok := smsOptOutKeywords[strings.ToUpper(strings.TrimSpace(reply.Body))]; ok {
+		if err := s.accounts.DisableSMSFallback(ctx, userID); err != nil {
+			return "", fmt.Errorf("sms reply: opt out: %w", err)
+		}
+		s.logger.InfoContext(ctx, "sms fallback turned off by reply", "user_id", userID)
+		return SMSReplyStopped, nil
+	}
+	if !settings.Enabled {
+		return SMSReplyDisabled, nil
+	}
+
+	route, err := s.routes.ReplyRoute(ctx, phone.String())
+	if errors.Is(err, domain.ErrNotFound) {
+		return SMSReplyUnrouted, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("sms reply: read route: %w", err)
+	}
+	member, err := s.members.IsMember(ctx, route, userID)
+	if err != nil {
+		return "", fmt.Errorf("sms reply: check membership: %w", err)
+	}
+	if !member {
+		return SMSReplyNotMember, nil
+	}
+
+	chatID, err := domain.NewChatID(route)
+	if err != nil {
+		return "", fmt.Errorf("sms reply: route: %w", err)
+	}
+	senderID, err := domain.NewUserID(userID)
+	if err != nil {
+		return "", fmt.Errorf("sms reply: sender: %w", err)
+	}
+	content, err := domain.NewMessageContent(domain.ContentTypeText, strings.TrimSpace(reply.Body))
+	if err != nil || reply.MessageID == "" {
+		return SMSReplyRejected, nil
+	}
+
+	err = s.sink.Submit(ctx, SMSReplySubmission{
+		ChatID:          chatID,
+		SenderID:        senderID,
+		ClientMessageID: domain.SystemClientMessageID("sms:" + reply.MessageID),
+		Content:         content,
+		ReceivedAt:      domain.ServerNow(s.clock),
+	})
+	if err != nil {
+		return "", fmt.Errorf("sms reply: submit: %w", err)
+	}
+	return SMSReplyPosted, nil
+}
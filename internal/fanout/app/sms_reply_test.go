@@ -0,0 +1,161 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+const (
+	smsUserID = "33333333-3333-4333-8333-333333333333"
+	smsChatID = "44444444-4444-4444-8444-444444444444"
+	smsPhone  = "+14155550100"
+)
+
+type stubAccounts struct {
+	settings domain.SMSFallback
+	disabled []string
+	err      error
+}
+
+func (a *stubAccounts) UserByPhone(_ context.Context, phone string) (string, domain.SMSFallback, error) {
+	if a.err != nil {
+		return "", domain.SMSFallback{}, a.err
+	}
+	if phone != smsPhone {
+		return "", domain.SMSFallback{}, domain.ErrNotFound
+	}
+	return smsUserID, a.settings, nil
+}
+
+func (a *stubAccounts) DisableSMSFallback(_ context.Context, userID string) error {
+	a.disabled = append(a.disabled, userID)
+	return nil
+}
+
+type stubMembership map[string]bool // chatID/userID
+
+func (m stubMembership) IsMember(_ context.Context, chatID, userID string) (bool, error) {
+	return m[chatID+"/"+userID], nil
+}
+
+type recordingReplySink struct {
+	got []app.SMSReplySubmission
+	err error
+}
+
+func (s *recordingReplySink) Submit(_ context.Context, msg app.SMSReplySubmission) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.got = append(s.got, msg)
+	return nil
+}
+
+func TestSMSReplyService_HandleReply(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type fixture struct {
+		svc      *app.SMSReplyService
+		accounts *stubAccounts
+		routes   *memoryRoutes
+		sink     *recordingReplySink
+	}
+	newService := func() fixture {
+		fx := fixture{
+			accounts: &stubAccounts{settings: domain.SMSFallback{Enabled: true}},
+			routes:   newMemoryRoutes(),
+			sink:     &recordingReplySink{},
+		}
+		fx.routes.routes[smsPhone] = smsChatID
+		fx.svc = app.NewSMSReplyService(app.SMSReplyServiceConfig{
+			Accounts: fx.accounts,
+			Routes:   fx.routes,
+			Members:  stubMembership{smsChatID + "/" + smsUserID: true},
+			Sink:     fx.sink,
+			Clock:    domaintest.NewFakeClock(now),
+		})
+		return fx
+	}
+	reply := app.SMSReply{MessageID: "in-1", From: "+1 (415) 555-0100", Body: " on my way "}
+
+	t.Run("posts to the routed chat", func(t *testing.T) {
+		fx := newService()
+
+		outcome, err := fx.svc.HandleReply(ctx, reply)
+
+		require.NoError(t, err)
+		assert.Equal(t, app.SMSReplyPosted, outcome)
+		require.Len(t, fx.sink.got, 1)
+		got := fx.sink.got[0]
+		assert.Equal(t, smsChatID, got.ChatID.String())
+		assert.Equal(t, smsUserID, got.SenderID.String())
+		assert.Equal(t, "on my way", got.Content.Body())
+		assert.Equal(t, domain.SystemClientMessageID("sms:in-1"), got.ClientMessageID)
+		assert.Equal(t, now, got.ReceivedAt.Time())
+	})
+
+	t.Run("stop keyword turns fallback off", func(t *testing.T) {
+		fx := newService()
+		stop := reply
+		stop.Body = "stop"
+
+		outcome, err := fx.svc.HandleReply(ctx, stop)
+
+		require.NoError(t, err)
+		assert.Equal(t, app.SMSReplyStopped, outcome)
+		assert.Equal(t, []string{smsUserID}, fx.accounts.disabled)
+		assert.Empty(t, fx.sink.got)
+	})
+
+	t.Run("replies that are not posted", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			setup func(fx fixture, r *app.SMSReply)
+			want  app.SMSReplyOutcome
+		}{
+			{"unknown phone", func(_ fixture, r *app.SMSReply) { r.From = "+14155550199" }, app.SMSReplyUnknown},
+			{"invalid phone", func(_ fixture, r *app.SMSReply) { r.From = "shortcode" }, app.SMSReplyUnknown},
+			{"fallback off", func(fx fixture, _ *app.SMSReply) { fx.accounts.settings.Enabled = false }, app.SMSReplyDisabled},
+			{"no current route", func(fx fixture, _ *app.SMSReply) { delete(fx.routes.routes, smsPhone) }, app.SMSReplyUnrouted},
+			{"left the chat", func(fx fixture, _ *app.SMSReply) {
+				fx.routes.routes[smsPhone] = "55555555-5555-4555-8555-555555555555"
+			}, app.SMSReplyNotMember},
+			{"empty body", func(_ fixture, r *app.SMSReply) { r.Body = "  " }, app.SMSReplyRejected},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				fx := newService()
+				r := reply
+				tt.setup(fx, &r)
+
+				outcome, err := fx.svc.HandleReply(ctx, r)
+
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, outcome)
+				assert.Empty(t, fx.sink.got)
+			})
+		}
+	})
+
+	t.Run("store and sink failures are returned for redelivery", func(t *testing.T) {
+		fx := newService()
+		fx.sink.err = errors.New("ingest unavailable")
+		_, err := fx.svc.HandleReply(ctx, reply)
+		require.Error(t, err)
+
+		fx = newService()
+		fx.accounts.err = errors.New("dynamo throttled")
+		_, err = fx.svc.HandleReply(ctx, reply)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,96 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// ConsumerControlService is the subset of app.ConsumerControl the consumer
+// admin endpoint needs.
+type ConsumerControlService interface {
+	Pause(ctx context.Context, name, reason string) (app.ConsumerState, error)
+	Resume(ctx context.Context, name string) (app.ConsumerState, error)
+	States() []app.ConsumerState
+}
+
+// consumerRequest is the body of a PUT to the consumer admin endpoint.
+type consumerRequest struct {
+	Consumer string `json:"consumer"`
+	Paused   bool   `json:"paused"`
+	Reason   string `json:"reason"`
+}
+
+type consumerState struct {
+	Consumer string `json:"consumer"`
+	Paused   bool   `json:"paused"`
+	Reason   string `json:"reason,omitempty"`
+	Since    string `json:"since"`
+}
+
+type consumersResponse struct {
+	Consumers []consumerState `json:"consumers"`
+}
+
+// ConsumerControlAdminHandler serves whether each Fanout consumer is paused
+// on GET and pauses or resumes one on PUT:
+//
+//	PUT /admin/consumers {"consumer": "push", "paused": true, "reason": "APNs outage"}
+//
+// A paused consumer stops after its current batch and keeps its committed
+// offsets. Changes apply to this process only, so pause every pod; set
+// FANOUT_PAUSEDCONSUMERS to keep a consumer paused across restarts. Like
+// the other /admin endpoints it is authenticated by server.AdminAuth.
+func ConsumerControlAdminHandler(svc ConsumerControlService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req consumerRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("decode request: %v", err), http.StatusBadRequest)
+				return
+			}
+			var err error
+			if req.Paused {
+				_, err = svc.Pause(r.Context(), req.Consumer, req.Reason)
+			} else {
+				_, err = svc.Resume(r.Context(), req.Consumer)
+			}
+			if errors.Is(err, domain.ErrNotFound) {
+				http.Error(w, fmt.Sprintf("unknown consumer %q", req.Consumer), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				observability.LoggerFromContext(r.Context()).ErrorContext(r.Context(), "consumer control failed",
+					"consumer", req.Consumer, "paused", req.Paused, "error", err)
+				http.Error(w, "consumer control failed", http.StatusInternalServerError)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		states := svc.States()
+		resp := consumersResponse{Consumers: make([]consumerState, 0, len(states))}
+		for _, s := range states {
+			resp.Consumers = append(resp.Consumers, consumerState{
+				Consumer: s.Name,
+				Paused:   s.Paused,
+				Reason:   s.Reason,
+				Since:    s.Since.UTC().Format(time.RFC3339),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,74 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+func TestConsumerControlAdminHandler(t *testing.T) {
+	clock := domaintest.NewFakeClock(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+	control, err := app.NewConsumerControl(app.ConsumerControlConfig{Clock: clock})
+	require.NoError(t, err)
+	handler := ConsumerControlAdminHandler(control)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/consumers", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("pause", func(t *testing.T) {
+		rec := put(`{"consumer": "push", "paused": true, "reason": "APNs outage"}`)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"consumers": [
+			{"consumer": "delivery", "paused": false, "since": "2026-03-02T12:00:00Z"},
+			{"consumer": "push", "paused": true, "reason": "APNs outage", "since": "2026-03-02T12:00:00Z"},
+			{"consumer": "search-index", "paused": false, "since": "2026-03-02T12:00:00Z"}
+		]}`, rec.Body.String())
+		assert.True(t, control.Paused(app.ConsumerPush))
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		clock.Advance(time.Minute)
+		rec := put(`{"consumer": "push", "paused": false}`)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `{"consumer":"push","paused":false,"since":"2026-03-02T12:01:00Z"}`)
+		require.NoError(t, control.Wait(context.Background(), app.ConsumerPush))
+	})
+
+	t.Run("get", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/consumers", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"consumer":"delivery"`)
+	})
+
+	t.Run("unknown consumer", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, put(`{"consumer": "indexer", "paused": true}`).Code)
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, put(`{`).Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/consumers", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET, PUT", rec.Header().Get("Allow"))
+	})
+}
@@ -0,0 +1,173 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// MessageDispatcher is the subset of app.Dispatcher the delivery consumer
+// needs.
+type MessageDispatcher interface {
+	Dispatch(ctx context.Context, msg protocol.Message) error
+}
+
+// MessageResolver is the subset of app.Dispatcher the delivery consumer
+// needs when it queues deliveries in priority lanes.
+type MessageResolver interface {
+	Resolve(ctx context.Context, msg protocol.Message) ([]app.GatewayDelivery, error)
+}
+
+// PersistedMessageDecoder decodes the value of a messages.persisted record.
+type PersistedMessageDecoder func(value []byte) (protocol.Message, error)
+
+// DeliveryConsumerConfig holds the dependencies for DeliveryConsumer.
+type DeliveryConsumerConfig struct {
+	Consumer kafka.Consumer
+	Decode   PersistedMessageDecoder
+	Dispatch MessageDispatcher
+	Logger   *slog.Logger // nil uses slog.Default
+
+	// Lanes, if set, replaces Dispatch: each record's deliveries are found
+	// by Resolve and queued in its partition's priority lanes, whose
+	// workers publish them.
+	Lanes   *app.DeliveryLanes
+	Resolve MessageResolver
+
+	// Objective is the SLO operation each record's delivery is measured
+	// against (slo.Deliver for the delivery consumer). Empty measures
+	// nothing.
+	Objective string
+
+	// Control, if set, holds polling while the consumer Name is paused.
+	Control *app.ConsumerControl
+	// Name is the consumer Control pauses. Empty defaults to
+	// app.ConsumerDelivery.
+	Name string
+}
+
+// DeliveryConsumer feeds messages.persisted to the delivery dispatcher
+// (ADR-002 §3.3), or, in its own group, to another MessageDispatcher such
+// as the offline notifier. Records are dispatched in order and committed a batch at
+// a time once processed, whether or not their Gateways got them; a restart
+// redelivers at most one batch, which clients deduplicate by message ID.
+// With Lanes, a record is processed once its deliveries are queued behind
+// the partition's earlier ones, and a full message lane holds the
+// partition until its worker catches up.
+// A record that cannot be decoded or dispatched is logged and skipped, and
+// its recipients catch up with sync.
+type DeliveryConsumer struct {
+	consumer  kafka.Consumer
+	decode    PersistedMessageDecoder
+	dispatch  MessageDispatcher
+	lanes     *app.DeliveryLanes
+	resolve   MessageResolver
+	logger    *slog.Logger
+	objective string
+	control   *app.ConsumerControl
+	name      string
+}
+
+// NewDeliveryConsumer creates a DeliveryConsumer.
+func NewDeliveryConsumer(cfg DeliveryConsumerConfig) *DeliveryConsumer {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	name := cfg.Name
+	if name == "" {
+		name = app.ConsumerDelivery
+	}
+	return &DeliveryConsumer{
+		consumer:  cfg.Consumer,
+		decode:    cfg.Decode,
+		dispatch:  cfg.Dispatch,
+		lanes:     cfg.Lanes,
+		resolve:   cfg.Resolve,
+		objective: cfg.Objective,
+		logger:    logger,
+		control:   cfg.Control,
+		name:      name,
+	}
+}
+
+// Run dispatches records until ctx is done or the consumer is closed. An
+// unfinished batch is left uncommitted. While paused it polls nothing, so
+// the group keeps its offsets and resuming continues from them.
+func (c *DeliveryConsumer) Run(ctx context.Context) error {
+	for {
+		if c.control != nil {
+			if err := c.control.Wait(ctx, c.name); err != nil {
+				return nil
+			}
+		}
+		records, err := c.consumer.Poll(ctx)
+		if errors.Is(err, kafka.ErrClientClosed) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("fanout %s: poll: %w", c.name, err)
+		}
+		for _, r := range records {
+			c.process(ctx, r)
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+		if err := c.consumer.Commit(ctx, records...); err != nil {
+			return fmt.Errorf("fanout %s: commit: %w", c.name, err)
+		}
+	}
+}
+
+// process dispatches one record. A panic is recovered and the record
+// skipped, so it cannot stop the loop.
+func (c *DeliveryConsumer) process(ctx context.Context, r *kafka.Record) {
+	defer observability.Recover(ctx, "kafka_consumer")
+	ctx, span := kafka.StartConsumeSpan(ctx, r)
+	defer span.End()
+
+	msg, err := c.decode(r.Value)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.WarnContext(ctx, "fanout.delivery_undecodable", "consumer", c.name,
+			"partition", r.Partition, "offset", r.Offset, "error", err)
+		return
+	}
+	done := slo.Start(ctx, c.objective)
+	err = c.deliver(ctx, r.Partition, msg)
+	done(err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.logger.ErrorContext(ctx, "fanout.delivery_dropped", "consumer", c.name,
+			"message_id", msg.MessageID, "chat_id", msg.ChatID, "error", err)
+	}
+}
+
+// deliver dispatches msg, or queues its deliveries in partition's lanes.
+func (c *DeliveryConsumer) deliver(ctx context.Context, partition int32, msg protocol.Message) error {
+	if c.lanes == nil {
+		return c.dispatch.Dispatch(ctx, msg)
+	}
+	deliveries, err := c.resolve.Resolve(ctx, msg)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		if err := c.lanes.Enqueue(ctx, partition, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+type fakeDispatcher struct {
+	mu         sync.Mutex
+	dispatched []string
+	failing    map[string]bool
+}
+
+func (f *fakeDispatcher) Dispatch(_ context.Context, msg protocol.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[msg.MessageID] {
+		return errors.New("memberships throttled")
+	}
+	f.dispatched = append(f.dispatched, msg.MessageID)
+	return nil
+}
+
+// decodeMessageID decodes records whose value is the message ID.
+func decodeMessageID(value []byte) (protocol.Message, error) {
+	if len(value) == 0 {
+		return protocol.Message{}, errors.New("empty record")
+	}
+	return protocol.Message{MessageID: string(value), ChatID: "chat-1"}, nil
+}
+
+func TestDeliveryConsumer_Run(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	dispatcher := &fakeDispatcher{failing: map[string]bool{"m3": true}}
+	c := NewDeliveryConsumer(DeliveryConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodeMessageID,
+		Dispatch: dispatcher,
+	})
+
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")},
+		&kafka.Record{Topic: "messages.persisted", Value: nil},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m2")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m3")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m4")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 5 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"m1", "m2", "m4"}, dispatcher.dispatched, "in order; undecodable and failed records skipped")
+}
+
+func TestDeliveryConsumer_RunWhilePaused(t *testing.T) {
+	ctx := context.Background()
+	consumer := kafkatest.NewConsumer()
+	dispatcher := &fakeDispatcher{}
+	control, err := app.NewConsumerControl(app.ConsumerControlConfig{Paused: []string{app.ConsumerDelivery}})
+	require.NoError(t, err)
+	c := NewDeliveryConsumer(DeliveryConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodeMessageID,
+		Dispatch: dispatcher,
+		Control:  control,
+	})
+	consumer.Push(&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")})
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(runCtx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, consumer.Committed(), "nothing is consumed while paused")
+
+	_, err = control.Resume(ctx, app.ConsumerDelivery)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"m1"}, dispatcher.dispatched)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestDeliveryConsumer_RunNamed(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	dispatcher := &fakeDispatcher{}
+	control, err := app.NewConsumerControl(app.ConsumerControlConfig{Paused: []string{app.ConsumerDelivery}})
+	require.NoError(t, err)
+	c := NewDeliveryConsumer(DeliveryConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodeMessageID,
+		Dispatch: dispatcher,
+		Control:  control,
+		Name:     app.ConsumerPush,
+	})
+	consumer.Push(&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")})
+
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 1 }, time.Second, time.Millisecond,
+		"pausing delivery does not hold the push consumer")
+	consumer.Close()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"m1"}, dispatcher.dispatched)
+}
+
+// fakeResolver resolves every message to one delivery to gw-1.
+type fakeResolver struct{}
+
+func (fakeResolver) Resolve(_ context.Context, msg protocol.Message) ([]app.GatewayDelivery, error) {
+	return []app.GatewayDelivery{{GatewayID: "gw-1", Delivery: app.Delivery{UserIDs: []string{"bob"}, Message: msg}}}, nil
+}
+
+// orderedGateways records the order deliveries are published in: message
+// IDs, or the signal frame type.
+type orderedGateways struct {
+	mu        sync.Mutex
+	published []string
+}
+
+func (g *orderedGateways) Publish(_ context.Context, _ string, d app.Delivery) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if d.Signal != nil {
+		g.published = append(g.published, string(d.Signal.Type))
+	} else {
+		g.published = append(g.published, d.Message.MessageID)
+	}
+	return nil
+}
+
+func (g *orderedGateways) Published() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.published...)
+}
+
+func TestDeliveryConsumer_RunLanes(t *testing.T) {
+	ctx := context.Background()
+	consumer := kafkatest.NewConsumer()
+	gateways := &orderedGateways{}
+	lanes := app.NewDeliveryLanes(app.DeliveryLanesConfig{Gateways: gateways, Partitions: 1})
+	c := NewDeliveryConsumer(DeliveryConsumerConfig{
+		Consumer: consumer,
+		Decode:   decodeMessageID,
+		Lanes:    lanes,
+		Resolve:  fakeResolver{},
+	})
+
+	// A burst of typing events is queued ahead of the message.
+	typing, err := protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-1", UserID: "alice", Active: true})
+	require.NoError(t, err)
+	for range 50 {
+		require.NoError(t, lanes.Publish(ctx, "gw-1", app.Delivery{UserIDs: []string{"bob"}, Signal: typing}))
+	}
+	consumer.Push(&kafka.Record{Topic: "messages.persisted", Partition: 0, Value: []byte("m1")})
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Run(runCtx) }()
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 1 }, time.Second, time.Millisecond)
+
+	go lanes.Run(runCtx)
+	require.Eventually(t, func() bool { return len(gateways.Published()) == 51 }, time.Second, time.Millisecond)
+	assert.Equal(t, "m1", gateways.Published()[0], "the message is not held back by the burst")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestDeliveryConsumer_RunRecoversPanics(t *testing.T) {
+	consumer := kafkatest.NewConsumer()
+	dispatcher := &fakeDispatcher{}
+	c := NewDeliveryConsumer(DeliveryConsumerConfig{
+		Consumer: consumer,
+		Decode: func(value []byte) (protocol.Message, error) {
+			if string(value) == "malformed" {
+				panic("index out of range")
+			}
+			return decodeMessageID(value)
+		},
+		Dispatch: dispatcher,
+	})
+
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("malformed")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m2")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 2 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"m2"}, dispatcher.dispatched, "the panicking record is skipped")
+}
+
+func TestDeliveryConsumer_RunMeasuresSLO(t *testing.T) {
+	recorder, err := slo.NewRecorder(slo.RecorderConfig{
+		Objectives: []slo.Objective{{Operation: slo.Deliver, Target: 0.5}},
+		Clock:      domain.RealClock{},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, recorder.Close()) })
+	slo.SetDefault(recorder)
+	t.Cleanup(func() { slo.SetDefault(nil) })
+
+	consumer := kafkatest.NewConsumer()
+	c := NewDeliveryConsumer(DeliveryConsumerConfig{
+		Consumer:  consumer,
+		Decode:    decodeMessageID,
+		Dispatch:  &fakeDispatcher{failing: map[string]bool{"m2": true}},
+		Objective: slo.Deliver,
+	})
+	consumer.Push(
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m1")},
+		&kafka.Record{Topic: "messages.persisted", Value: []byte("m2")},
+	)
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+
+	require.Eventually(t, func() bool { return len(consumer.Committed()) == 2 }, time.Second, time.Millisecond)
+	consumer.Close()
+	require.NoError(t, <-done)
+
+	// One bad delivery in two against a 50% error budget.
+	assert.InDelta(t, 1.0, recorder.BurnRate(slo.Deliver, time.Hour), 0.001)
+}
@@ -1,3 +1,3 @@
 // Package port contains entry points into the Fanout service.
-// Kafka consumer entrypoint lives here.
+// Kafka consumer and gRPC entrypoints live here.
 package port
@@ -0,0 +1,74 @@
+package port
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// ConsumerLagService is the subset of app.LagMonitor the lag admin endpoint
+// needs.
+type ConsumerLagService interface {
+	Status() app.ConsumerLag
+}
+
+type partitionLag struct {
+	Topic         string  `json:"topic"`
+	Partition     int32   `json:"partition"`
+	HighWatermark int64   `json:"high_watermark"`
+	Committed     int64   `json:"committed"`
+	Lag           int64   `json:"lag"`
+	LagSeconds    float64 `json:"lag_seconds"`
+}
+
+type consumerLagResponse struct {
+	Group          string         `json:"group"`
+	Partitions     []partitionLag `json:"partitions"`
+	Rebalances     int64          `json:"rebalances"`
+	PartitionsLost int64          `json:"partitions_lost"`
+	LastRebalance  string         `json:"last_rebalance,omitempty"`
+}
+
+// ConsumerLagAdminHandler serves this instance's Fanout consumer group
+// membership: its assigned partitions with their lag, and its rebalance
+// counts.
+//
+//	GET /admin/consumer-lag
+//
+// Each instance reports only its own assignments; the group-wide view is
+// the fanout_consumer_lag metric. Like the other /admin endpoints it is
+// authenticated by server.AdminAuth.
+func ConsumerLagAdminHandler(svc ConsumerLagService) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		s := svc.Status()
+		resp := consumerLagResponse{
+			Group:          s.Group,
+			Partitions:     make([]partitionLag, 0, len(s.Partitions)),
+			Rebalances:     s.Rebalances,
+			PartitionsLost: s.PartitionsLost,
+		}
+		if !s.LastRebalance.IsZero() {
+			resp.LastRebalance = s.LastRebalance.UTC().Format(time.RFC3339)
+		}
+		for _, p := range s.Partitions {
+			resp.Partitions = append(resp.Partitions, partitionLag{
+				Topic:         p.Topic,
+				Partition:     p.Partition,
+				HighWatermark: p.HighWatermark,
+				Committed:     p.Committed,
+				Lag:           p.Lag,
+				LagSeconds:    p.LagAge.Seconds(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,64 @@
+package port
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+type stubLagService app.ConsumerLag
+
+func (s stubLagService) Status() app.ConsumerLag { return app.ConsumerLag(s) }
+
+func TestConsumerLagAdminHandler(t *testing.T) {
+	t.Run("reports partitions and rebalances", func(t *testing.T) {
+		handler := ConsumerLagAdminHandler(stubLagService{
+			Group: "fanout",
+			Partitions: []app.PartitionLag{
+				{Topic: "messages.persisted", Partition: 0, HighWatermark: 120, Committed: 100, Lag: 20, LagAge: 1500 * time.Millisecond},
+				{Topic: "messages.persisted", Partition: 1, HighWatermark: -1, Committed: -1},
+			},
+			Rebalances:     3,
+			PartitionsLost: 1,
+			LastRebalance:  time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+		})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/consumer-lag", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"group": "fanout",
+			"partitions": [
+				{"topic": "messages.persisted", "partition": 0, "high_watermark": 120, "committed": 100, "lag": 20, "lag_seconds": 1.5},
+				{"topic": "messages.persisted", "partition": 1, "high_watermark": -1, "committed": -1, "lag": 0, "lag_seconds": 0}
+			],
+			"rebalances": 3,
+			"partitions_lost": 1,
+			"last_rebalance": "2026-03-02T12:00:00Z"
+		}`, rec.Body.String())
+	})
+
+	t.Run("no assignment is an empty list", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ConsumerLagAdminHandler(stubLagService{Group: "fanout"}).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodGet, "/admin/consumer-lag", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"group":"fanout","partitions":[],"rebalances":0,"partitions_lost":0}`, rec.Body.String())
+	})
+
+	t.Run("GET only", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		ConsumerLagAdminHandler(stubLagService{}).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/admin/consumer-lag", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET", rec.Header().Get("Allow"))
+	})
+}
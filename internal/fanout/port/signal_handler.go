@@ -0,0 +1,103 @@
+package port
+
+import (
+	"context"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+// SignalDispatcher delivers one activity signal. *app.SignalDispatcher
+// satisfies this.
+type SignalDispatcher interface {
+	Dispatch(ctx context.Context, sig app.Signal) error
+}
+
+// NotificationDispatcher delivers one new feed entry.
+// *app.NotificationDispatcher satisfies this.
+type NotificationDispatcher interface {
+	Dispatch(ctx context.Context, entry domain.FeedEntry) error
+}
+
+// SignalHandler implements the gRPC FanoutServiceServer interface for the
+// Gateway's activity signals and ChatMgmt's feed notifications.
+type SignalHandler struct {
+	messagingv1.UnimplementedFanoutServiceServer
+	dispatch      SignalDispatcher
+	notifications NotificationDispatcher
+}
+
+// NewSignalHandler creates a SignalHandler that delivers signals through
+// dispatch and notifications through notifications.
+func NewSignalHandler(dispatch SignalDispatcher, notifications NotificationDispatcher) *SignalHandler {
+	return &SignalHandler{dispatch: dispatch, notifications: notifications}
+}
+
+// PublishSignal delivers a typing, read receipt or presence signal.
+func (h *SignalHandler) PublishSignal(
+	ctx context.Context, req *messagingv1.PublishSignalRequest,
+) (*messagingv1.PublishSignalResponse, error) {
+	sig, err := signalFromProto(req)
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	if err := h.dispatch.Dispatch(ctx, sig); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.PublishSignalResponse{}, nil
+}
+
+// PublishNotification delivers a new feed entry to the user's devices.
+func (h *SignalHandler) PublishNotification(
+	ctx context.Context, req *messagingv1.PublishNotificationRequest,
+) (*messagingv1.PublishNotificationResponse, error) {
+	entry := domain.FeedEntry{
+		UserID:  req.GetUserId(),
+		EntryID: req.GetNotificationId(),
+		Kind:    domain.FeedKind(req.GetKind()),
+		ChatID:  req.GetChatId(),
+		ActorID: req.GetActorId(),
+		Params:  req.GetParams(),
+	}
+	if ts := req.GetCreatedAt(); ts != nil {
+		entry.CreatedAt = domain.FromMillis(ts.GetMillis())
+	}
+	if err := entry.Validate(); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	if entry.EntryID == "" {
+		return nil, errmap.ToGRPCError(domain.NewValidationError("notification_id", "is required"))
+	}
+	if err := h.notifications.Dispatch(ctx, entry); err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.PublishNotificationResponse{}, nil
+}
+
+// signalFromProto validates req into an app.Signal.
+func signalFromProto(req *messagingv1.PublishSignalRequest) (app.Signal, error) {
+	sig := app.Signal{UserID: req.GetUserId()}
+	if sig.UserID == "" {
+		return app.Signal{}, domain.NewValidationError("user_id", "is required")
+	}
+	switch s := req.GetSignal().(type) {
+	case *messagingv1.PublishSignalRequest_Typing:
+		sig.Kind, sig.ChatID, sig.Active = domain.SignalTyping, s.Typing.GetChatId(), s.Typing.GetActive()
+	case *messagingv1.PublishSignalRequest_ReadReceipt:
+		sig.Kind, sig.ChatID, sig.Sequence = domain.SignalReadReceipt, s.ReadReceipt.GetChatId(), s.ReadReceipt.GetSequence()
+	case *messagingv1.PublishSignalRequest_Presence:
+		sig.Kind, sig.Online = domain.SignalLastSeen, s.Presence.GetOnline()
+		if ts := s.Presence.GetLastSeenAt(); ts != nil {
+			sig.LastSeenAt = domain.FromMillis(ts.GetMillis())
+		}
+		return sig, nil
+	default:
+		return app.Signal{}, domain.NewValidationError("signal", "is required")
+	}
+	if sig.ChatID == "" {
+		return app.Signal{}, domain.NewValidationError("chat_id", "is required")
+	}
+	return sig, nil
+}
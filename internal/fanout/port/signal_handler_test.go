@@ -0,0 +1,154 @@
+package port
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+type signalDispatcherFunc func(ctx context.Context, sig app.Signal) error
+
+func (f signalDispatcherFunc) Dispatch(ctx context.Context, sig app.Signal) error { return f(ctx, sig) }
+
+type notificationDispatcherFunc func(ctx context.Context, entry domain.FeedEntry) error
+
+func (f notificationDispatcherFunc) Dispatch(ctx context.Context, entry domain.FeedEntry) error {
+	return f(ctx, entry)
+}
+
+func TestSignalHandler_PublishSignal(t *testing.T) {
+	ctx := context.Background()
+	lastSeen := time.UnixMilli(1_700_000_000_000)
+
+	tests := []struct {
+		name string
+		req  *messagingv1.PublishSignalRequest
+		want app.Signal
+	}{
+		{
+			name: "typing",
+			req: &messagingv1.PublishSignalRequest{UserId: "alice", Signal: &messagingv1.PublishSignalRequest_Typing{
+				Typing: &messagingv1.TypingSignal{ChatId: "chat-1", Active: true},
+			}},
+			want: app.Signal{Kind: domain.SignalTyping, UserID: "alice", ChatID: "chat-1", Active: true},
+		},
+		{
+			name: "read receipt",
+			req: &messagingv1.PublishSignalRequest{UserId: "alice", Signal: &messagingv1.PublishSignalRequest_ReadReceipt{
+				ReadReceipt: &messagingv1.ReadReceiptSignal{ChatId: "chat-1", Sequence: 9},
+			}},
+			want: app.Signal{Kind: domain.SignalReadReceipt, UserID: "alice", ChatID: "chat-1", Sequence: 9},
+		},
+		{
+			name: "presence",
+			req: &messagingv1.PublishSignalRequest{UserId: "alice", Signal: &messagingv1.PublishSignalRequest_Presence{
+				Presence: &messagingv1.PresenceSignal{LastSeenAt: &messagingv1.Timestamp{Millis: lastSeen.UnixMilli()}},
+			}},
+			want: app.Signal{Kind: domain.SignalLastSeen, UserID: "alice", LastSeenAt: lastSeen},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got app.Signal
+			h := NewSignalHandler(signalDispatcherFunc(func(_ context.Context, sig app.Signal) error {
+				got = sig
+				return nil
+			}), nil)
+
+			_, err := h.PublishSignal(ctx, tt.req)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Kind, got.Kind)
+			assert.Equal(t, tt.want.UserID, got.UserID)
+			assert.Equal(t, tt.want.ChatID, got.ChatID)
+			assert.Equal(t, tt.want.Active, got.Active)
+			assert.Equal(t, tt.want.Sequence, got.Sequence)
+			assert.True(t, tt.want.LastSeenAt.Equal(got.LastSeenAt))
+		})
+	}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		h := NewSignalHandler(signalDispatcherFunc(func(context.Context, app.Signal) error {
+			t.Fatal("invalid signal dispatched")
+			return nil
+		}), nil)
+
+		for name, req := range map[string]*messagingv1.PublishSignalRequest{
+			"no user":   {Signal: &messagingv1.PublishSignalRequest_Presence{Presence: &messagingv1.PresenceSignal{Online: true}}},
+			"no signal": {UserId: "alice"},
+			"no chat":   {UserId: "alice", Signal: &messagingv1.PublishSignalRequest_Typing{Typing: &messagingv1.TypingSignal{}}},
+		} {
+			_, err := h.PublishSignal(ctx, req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+
+	t.Run("non-members are denied", func(t *testing.T) {
+		h := NewSignalHandler(signalDispatcherFunc(func(context.Context, app.Signal) error {
+			return domain.ErrNotMember
+		}), nil)
+
+		_, err := h.PublishSignal(ctx, &messagingv1.PublishSignalRequest{UserId: "mallory", Signal: &messagingv1.PublishSignalRequest_Typing{
+			Typing: &messagingv1.TypingSignal{ChatId: "chat-1", Active: true},
+		}})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestSignalHandler_PublishNotification(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.UnixMilli(1_700_000_000_000)
+
+	t.Run("dispatches the entry", func(t *testing.T) {
+		var got domain.FeedEntry
+		h := NewSignalHandler(nil, notificationDispatcherFunc(func(_ context.Context, entry domain.FeedEntry) error {
+			got = entry
+			return nil
+		}))
+
+		_, err := h.PublishNotification(ctx, &messagingv1.PublishNotificationRequest{
+			UserId:         "bob",
+			NotificationId: "entry-1",
+			Kind:           "mention",
+			ChatId:         "chat-1",
+			ActorId:        "alice",
+			Params:         map[string]string{"message_id": "msg-1"},
+			CreatedAt:      &messagingv1.Timestamp{Millis: createdAt.UnixMilli()},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "bob", got.UserID)
+		assert.Equal(t, "entry-1", got.EntryID)
+		assert.Equal(t, domain.FeedKindMention, got.Kind)
+		assert.Equal(t, "chat-1", got.ChatID)
+		assert.Equal(t, "alice", got.ActorID)
+		assert.Equal(t, map[string]string{"message_id": "msg-1"}, got.Params)
+		assert.True(t, createdAt.Equal(got.CreatedAt))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		h := NewSignalHandler(nil, notificationDispatcherFunc(func(context.Context, domain.FeedEntry) error {
+			t.Fatal("invalid notification dispatched")
+			return nil
+		}))
+
+		for name, req := range map[string]*messagingv1.PublishNotificationRequest{
+			"no user":  {NotificationId: "entry-1", Kind: "mention"},
+			"no id":    {UserId: "bob", Kind: "mention"},
+			"bad kind": {UserId: "bob", NotificationId: "entry-1", Kind: "poke"},
+		} {
+			_, err := h.PublishNotification(ctx, req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+}
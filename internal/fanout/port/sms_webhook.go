@@ -0,0 +1,115 @@
+package port
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// SMSReplyPath is where the SMS provider posts inbound SMS.
+const SMSReplyPath = "/webhooks/sms"
+
+// maxSNSMessageBytes bounds an SNS delivery. SNS messages are at most
+// 256 KiB.
+const maxSNSMessageBytes = 256 << 10
+
+// SMSReplyHandler is the subset of app.SMSReplyService the webhook needs.
+type SMSReplyHandler interface {
+	HandleReply(ctx context.Context, reply app.SMSReply) (app.SMSReplyOutcome, error)
+}
+
+// SMSWebhookConfig holds the dependencies for SMSReplyWebhook.
+type SMSWebhookConfig struct {
+	Replies SMSReplyHandler
+	// TopicARN is the SNS topic two-way SMS is published to. Deliveries
+	// from any other topic are refused.
+	TopicARN string
+	// Client fetches signing certificates and confirms the subscription.
+	// Nil uses http.DefaultClient.
+	Client httpDoer
+}
+
+// inboundSMS is the two-way SMS payload SNS carries in its Message field.
+type inboundSMS struct {
+	OriginationNumber string `json:"originationNumber"`
+	MessageBody       string `json:"messageBody"`
+	InboundMessageID  string `json:"inboundMessageId"`
+}
+
+// SMSReplyWebhook receives inbound SMS, which AWS publishes to an SNS
+// topic subscribed to this endpoint over HTTPS:
+//
+//	POST /webhooks/sms
+//
+// Every delivery must carry a valid SNS signature from the configured
+// topic. A subscription confirmation is confirmed, which is how the
+// subscription starts. A notification is passed to the reply service; if
+// that fails the reply is 500 and SNS redelivers, which the service
+// deduplicates by inbound message ID.
+func SMSReplyWebhook(cfg SMSWebhookConfig) http.Handler {
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	verifier := newSNSVerifier(client)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+		logger := observability.LoggerFromContext(ctx)
+
+		var m snsMessage
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSNSMessageBytes)).Decode(&m); err != nil {
+			http.Error(w, "invalid SNS message", http.StatusBadRequest)
+			return
+		}
+		if m.TopicARN != cfg.TopicARN {
+			http.Error(w, "unknown topic", http.StatusForbidden)
+			return
+		}
+		if err := verifier.verify(ctx, m); err != nil {
+			logger.WarnContext(ctx, "sms webhook: rejected SNS message", "message_id", m.MessageID, "error", err)
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		switch m.Type {
+		case "SubscriptionConfirmation":
+			if err := verifier.confirm(ctx, m.SubscribeURL); err != nil {
+				logger.ErrorContext(ctx, "sms webhook: subscription not confirmed", "error", err)
+				http.Error(w, "confirmation failed", http.StatusBadGateway)
+				return
+			}
+			logger.InfoContext(ctx, "sms webhook: subscription confirmed", "topic_arn", m.TopicARN)
+		case "UnsubscribeConfirmation":
+			logger.WarnContext(ctx, "sms webhook: unsubscribed", "topic_arn", m.TopicARN)
+		case "Notification":
+			var sms inboundSMS
+			if err := json.Unmarshal([]byte(m.Message), &sms); err != nil {
+				// Not an inbound SMS; redelivery would not change that.
+				logger.WarnContext(ctx, "sms webhook: undecodable notification", "message_id", m.MessageID, "error", err)
+				break
+			}
+			outcome, err := cfg.Replies.HandleReply(ctx, app.SMSReply{
+				MessageID: sms.InboundMessageID,
+				From:      sms.OriginationNumber,
+				Body:      sms.MessageBody,
+			})
+			if err != nil {
+				logger.ErrorContext(ctx, "sms webhook: reply failed", "inbound_message_id", sms.InboundMessageID, "error", err)
+				http.Error(w, "reply not stored", http.StatusInternalServerError)
+				return
+			}
+			logger.DebugContext(ctx, "sms webhook: reply handled",
+				"inbound_message_id", sms.InboundMessageID, "outcome", string(outcome))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
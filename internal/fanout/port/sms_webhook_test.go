@@ -0,0 +1,191 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/fanout/app"
+)
+
+const (
+	testTopicARN = "arn:aws:sns:us-east-1:123456789012:inbound-sms"
+	testCertURL  = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+)
+
+// fakeSNS signs messages and serves its certificate and subscribe URLs.
+type fakeSNS struct {
+	key     *rsa.PrivateKey
+	certPEM []byte
+
+	mu      sync.Mutex
+	fetched []string
+}
+
+func newFakeSNS(t *testing.T) *fakeSNS {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &fakeSNS{key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (s *fakeSNS) Do(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	s.fetched = append(s.fetched, req.URL.String())
+	s.mu.Unlock()
+	body := []byte("<ConfirmSubscriptionResponse/>")
+	if req.URL.String() == testCertURL {
+		body = s.certPEM
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (s *fakeSNS) sign(t *testing.T, m snsMessage) snsMessage {
+	t.Helper()
+	m.TopicARN = testTopicARN
+	m.SignatureVersion = "2"
+	m.SigningCertURL = testCertURL
+	m.Timestamp = "2026-10-01T12:00:00.000Z"
+	digest := sha256.Sum256([]byte(snsStringToSign(m)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	m.Signature = base64.StdEncoding.EncodeToString(sig)
+	return m
+}
+
+func (s *fakeSNS) notification(t *testing.T, sms inboundSMS) snsMessage {
+	t.Helper()
+	raw, err := json.Marshal(sms)
+	require.NoError(t, err)
+	return s.sign(t, snsMessage{Type: "Notification", MessageID: "sns-1", Message: string(raw)})
+}
+
+type stubReplyHandler struct {
+	got []app.SMSReply
+	err error
+}
+
+func (h *stubReplyHandler) HandleReply(_ context.Context, reply app.SMSReply) (app.SMSReplyOutcome, error) {
+	if h.err != nil {
+		return "", h.err
+	}
+	h.got = append(h.got, reply)
+	return app.SMSReplyPosted, nil
+}
+
+func postSNS(t *testing.T, h http.Handler, m snsMessage) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(m)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, SMSReplyPath, bytes.NewReader(body)))
+	return rec
+}
+
+func TestSMSReplyWebhook(t *testing.T) {
+	sms := inboundSMS{OriginationNumber: "+14155550100", MessageBody: "on my way", InboundMessageID: "in-1"}
+
+	newWebhook := func(t *testing.T) (http.Handler, *fakeSNS, *stubReplyHandler) {
+		sns := newFakeSNS(t)
+		replies := &stubReplyHandler{}
+		return SMSReplyWebhook(SMSWebhookConfig{Replies: replies, TopicARN: testTopicARN, Client: sns}), sns, replies
+	}
+
+	t.Run("signed notification is handled", func(t *testing.T) {
+		h, sns, replies := newWebhook(t)
+
+		rec := postSNS(t, h, sns.notification(t, sms))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []app.SMSReply{{MessageID: "in-1", From: "+14155550100", Body: "on my way"}}, replies.got)
+
+		postSNS(t, h, sns.notification(t, sms))
+		assert.Equal(t, []string{testCertURL}, sns.fetched, "the certificate is cached")
+	})
+
+	t.Run("subscription confirmation", func(t *testing.T) {
+		h, sns, _ := newWebhook(t)
+		subscribe := "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=abc"
+
+		rec := postSNS(t, h, sns.sign(t, snsMessage{
+			Type: "SubscriptionConfirmation", MessageID: "sns-0", Token: "abc",
+			Message: "You have chosen to subscribe", SubscribeURL: subscribe,
+		}))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{testCertURL, subscribe}, sns.fetched)
+	})
+
+	t.Run("rejected deliveries", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(m *snsMessage)
+			want   int
+		}{
+			{"tampered body", func(m *snsMessage) { m.Message = strings.Replace(m.Message, "on my way", "send money", 1) }, http.StatusForbidden},
+			{"other topic", func(m *snsMessage) { m.TopicARN = "arn:aws:sns:us-east-1:999999999999:other" }, http.StatusForbidden},
+			{"foreign certificate host", func(m *snsMessage) { m.SigningCertURL = "https://attacker.example/cert.pem" }, http.StatusForbidden},
+			{"plain http certificate", func(m *snsMessage) { m.SigningCertURL = strings.Replace(testCertURL, "https", "http", 1) }, http.StatusForbidden},
+			{"signature version 1", func(m *snsMessage) { m.SignatureVersion = "1" }, http.StatusForbidden},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				h, sns, replies := newWebhook(t)
+				m := sns.notification(t, sms)
+				tt.modify(&m)
+
+				rec := postSNS(t, h, m)
+
+				assert.Equal(t, tt.want, rec.Code)
+				assert.Empty(t, replies.got)
+			})
+		}
+	})
+
+	t.Run("reply failure asks for redelivery", func(t *testing.T) {
+		h, sns, replies := newWebhook(t)
+		replies.err = errors.New("ingest unavailable")
+
+		rec := postSNS(t, h, sns.notification(t, sms))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		h, _, _ := newWebhook(t)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, SMSReplyPath, nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+	})
+}
@@ -0,0 +1,182 @@
+package port
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// snsHostPattern matches the hosts SNS serves signing certificates and
+// subscription confirmations from.
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// maxSNSCertBytes bounds a fetched signing certificate.
+const maxSNSCertBytes = 16 << 10
+
+// httpDoer is a narrow, consumer-defined interface for the HTTP calls SNS
+// verification makes. *http.Client satisfies it.
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// snsMessage is an Amazon SNS HTTP(S) delivery.
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicARN         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL"`
+}
+
+// snsVerifier checks SNS message signatures. Signing certificates are
+// fetched once per URL and cached; SNS rotates them rarely.
+type snsVerifier struct {
+	client httpDoer
+
+	mu    sync.Mutex
+	certs map[string]*rsa.PublicKey
+}
+
+func newSNSVerifier(client httpDoer) *snsVerifier {
+	return &snsVerifier{client: client, certs: make(map[string]*rsa.PublicKey)}
+}
+
+// verify checks m's signature. Only SignatureVersion 2 (SHA256withRSA) is
+// accepted; set the topic's SignatureVersion attribute to 2.
+func (v *snsVerifier) verify(ctx context.Context, m snsMessage) error {
+	if m.SignatureVersion != "2" {
+		return fmt.Errorf("sns: unsupported signature version %q", m.SignatureVersion)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("sns: decode signature: %w", err)
+	}
+	key, err := v.signingKey(ctx, m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(snsStringToSign(m)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return fmt.Errorf("sns: bad signature: %w", err)
+	}
+	return nil
+}
+
+// snsStringToSign builds the canonical string SNS signs: the message's
+// signed fields as name/value lines in byte order of name.
+func snsStringToSign(m snsMessage) string {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}}
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", m.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", m.Timestamp})
+	if m.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", m.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", m.TopicARN}, [2]string{"Type", m.Type})
+
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0] + "\n" + f[1] + "\n")
+	}
+	return b.String()
+}
+
+// signingKey returns the public key of the certificate at rawURL, which
+// must be an SNS-hosted PEM file.
+func (v *snsVerifier) signingKey(ctx context.Context, rawURL string) (*rsa.PublicKey, error) {
+	if err := checkSNSURL(rawURL); err != nil {
+		return nil, fmt.Errorf("sns: signing cert: %w", err)
+	}
+	v.mu.Lock()
+	key, ok := v.certs[rawURL]
+	v.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	body, err := v.get(ctx, rawURL, maxSNSCertBytes)
+	if err != nil {
+		return nil, fmt.Errorf("sns: fetch signing cert: %w", err)
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("sns: signing cert is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("sns: parse signing cert: %w", err)
+	}
+	key, ok = cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("sns: signing cert has %T key", cert.PublicKey)
+	}
+
+	v.mu.Lock()
+	v.certs[rawURL] = key
+	v.mu.Unlock()
+	return key, nil
+}
+
+// confirm visits a subscription confirmation's SubscribeURL, which
+// activates the subscription.
+func (v *snsVerifier) confirm(ctx context.Context, rawURL string) error {
+	if err := checkSNSURL(rawURL); err != nil {
+		return fmt.Errorf("sns: subscribe url: %w", err)
+	}
+	if _, err := v.get(ctx, rawURL, maxSNSCertBytes); err != nil {
+		return fmt.Errorf("sns: confirm subscription: %w", err)
+	}
+	return nil
+}
+
+func (v *snsVerifier) get(ctx context.Context, rawURL string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// checkSNSURL rejects URLs not served over HTTPS by SNS, so a forged
+// message cannot point verification at a certificate its sender controls.
+func checkSNSURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || !snsHostPattern.MatchString(u.Hostname()) || u.Port() != "" {
+		return fmt.Errorf("%q is not an SNS URL", rawURL)
+	}
+	return nil
+}
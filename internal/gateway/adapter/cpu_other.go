@@ -0,0 +1,9 @@
+//go:build !unix
+
+package adapter
+
+import "time"
+
+// processCPUTime is unsupported on this platform; CPU pressure reads as zero
+// and admission falls back to the memory and goroutine signals.
+func processCPUTime() time.Duration { return 0 }
@@ -1,3 +1,7 @@
 // Package adapter contains implementations of interfaces defined in app.
 // Redis operations, gRPC clients, and other I/O live here.
 package adapter
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("gateway/adapter")
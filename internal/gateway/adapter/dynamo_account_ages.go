@@ -0,0 +1,65 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// Compile-time check: AccountAgeStore satisfies app.AccountAges.
+var _ app.AccountAges = (*AccountAgeStore)(nil)
+
+// AccountAgeStore reads account creation times from the Chat Mgmt users
+// table (PK user_id). It never writes to it.
+type AccountAgeStore struct {
+	db        membershipDynamoDB
+	tableName string
+}
+
+// NewAccountAgeStore creates an AccountAgeStore for the users table.
+func NewAccountAgeStore(db membershipDynamoDB, tableName string) *AccountAgeStore {
+	return &AccountAgeStore{db: db, tableName: tableName}
+}
+
+// CreatedAt returns when userID's account was created.
+func (s *AccountAgeStore) CreatedAt(ctx context.Context, userID string) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.users.created_at")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "created_at"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: &projection,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return time.Time{}, fmt.Errorf("account age store: get: %w", err)
+	}
+	if out.Item == nil {
+		return time.Time{}, fmt.Errorf("account age store: user %s: %w", userID, domain.ErrNotFound)
+	}
+	raw, _ := out.Item["created_at"].(*dynamo.AttributeValueMemberS)
+	if raw == nil {
+		return time.Time{}, fmt.Errorf("account age store: user %s has no created_at: %w", userID, domain.ErrNotFound)
+	}
+	createdAt, err := domain.ParseTimestamp(raw.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("account age store: %w", err)
+	}
+	return createdAt.Time(), nil
+}
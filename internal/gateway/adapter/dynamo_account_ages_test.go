@@ -0,0 +1,51 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+func TestAccountAgeStore_CreatedAt(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2026, 1, 15, 12, 0, 0, 123_000_000, time.UTC)
+	store := func(item map[string]dynamo.AttributeValue, err error) *AccountAgeStore {
+		return NewAccountAgeStore(&stubMembershipDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, "users", *params.TableName)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "user-001"}, params.Key["user_id"])
+				return &dynamo.GetItemOutput{Item: item}, err
+			},
+		}, "users")
+	}
+
+	t.Run("parses the stored creation time", func(t *testing.T) {
+		s := store(map[string]dynamo.AttributeValue{
+			"created_at": &dynamo.AttributeValueMemberS{Value: created.Format(time.RFC3339Nano)},
+		}, nil)
+
+		got, err := s.CreatedAt(ctx, "user-001")
+
+		require.NoError(t, err)
+		assert.True(t, created.Equal(got))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := store(nil, nil).CreatedAt(ctx, "user-001")
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		_, err := store(nil, errors.New("throttled")).CreatedAt(ctx, "user-001")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// cursorWriteConcurrency bounds the UpdateItem calls one checkpoint has in
+// flight. A forward-only write needs a condition, which batch writes lack.
+const cursorWriteConcurrency = 16
+
+// Compile-time check: DeliveryCursorStore satisfies app.CursorStore.
+var _ app.CursorStore = (*DeliveryCursorStore)(nil)
+
+// cursorDynamoDB is the subset of the DynamoDB client the cursor store
+// needs. The *dynamodb.Client satisfies this interface.
+type cursorDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+// DeliveryCursorStore checkpoints delivery cursors in the delivery_state
+// table (ADR-007 §2.7): PK user_id, SK cursor (<device_id>#<chat_id>).
+type DeliveryCursorStore struct {
+	db        cursorDynamoDB
+	tableName string
+}
+
+// NewDeliveryCursorStore creates a DeliveryCursorStore for the
+// delivery_state table.
+func NewDeliveryCursorStore(db cursorDynamoDB, tableName string) *DeliveryCursorStore {
+	return &DeliveryCursorStore{db: db, tableName: tableName}
+}
+
+// SaveCursors moves each cursor's last_acked_sequence forward. Cursors
+// already at or past their sequence are skipped. Every write is attempted;
+// the failures are returned joined.
+func (s *DeliveryCursorStore) SaveCursors(ctx context.Context, batch []app.DeliveryCursor) error {
+	ctx, span := tracer.Start(ctx, "dynamo.delivery_state.save_cursors")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+		attribute.Int("cursors.count", len(batch)),
+	)
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	var g errgroup.Group
+	g.SetLimit(cursorWriteConcurrency)
+	for _, c := range batch {
+		g.Go(func() error {
+			if err := s.save(ctx, c); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("delivery cursor store: save: %d of %d writes failed: %w", len(errs), len(batch), err)
+	}
+	return nil
+}
+
+func (s *DeliveryCursorStore) save(ctx context.Context, c app.DeliveryCursor) error {
+	updateExpr := "SET last_acked_sequence = :seq, device_id = :device, chat_id = :chat, updated_at = :at"
+	condExpr := "attribute_not_exists(last_acked_sequence) OR last_acked_sequence < :seq"
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName:           &s.tableName,
+		Key:                 deliveryCursorKey(c.UserID, c.DeviceID, c.ChatID),
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":seq":    &dynamo.AttributeValueMemberN{Value: strconv.FormatUint(c.Sequence, 10)},
+			":device": &dynamo.AttributeValueMemberS{Value: c.DeviceID},
+			":chat":   &dynamo.AttributeValueMemberS{Value: c.ChatID},
+			":at":     &dynamo.AttributeValueMemberS{Value: domain.NewTimestampMS(c.At).String()},
+		},
+	})
+	if err != nil && !dynamo.IsConditionalCheckFailed(err) {
+		return fmt.Errorf("cursor %s/%s/%s: %w", c.UserID, c.DeviceID, c.ChatID, err)
+	}
+	return nil
+}
+
+// GetCursor returns the device's checkpointed cursor in the chat.
+func (s *DeliveryCursorStore) GetCursor(ctx context.Context, userID, deviceID, chatID string) (uint64, bool, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.delivery_state.get_cursor")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "last_acked_sequence"
+	consistent := true
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName:            &s.tableName,
+		Key:                  deliveryCursorKey(userID, deviceID, chatID),
+		ProjectionExpression: &projection,
+		ConsistentRead:       &consistent,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, false, fmt.Errorf("delivery cursor store: get: %w", err)
+	}
+	n, ok := out.Item["last_acked_sequence"].(*dynamo.AttributeValueMemberN)
+	if !ok {
+		return 0, false, nil
+	}
+	seq, err := strconv.ParseUint(n.Value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("delivery cursor store: parse sequence %q: %w", n.Value, err)
+	}
+	return seq, true, nil
+}
+
+func deliveryCursorKey(userID, deviceID, chatID string) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{
+		"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		"cursor":  &dynamo.AttributeValueMemberS{Value: deviceID + "#" + chatID},
+	}
+}
@@ -0,0 +1,133 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+type stubCursorDynamo struct {
+	mu           sync.Mutex
+	getItemFn    func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	updateItemFn func(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+func (s *stubCursorDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubCursorDynamo) UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateItemFn(ctx, params, optFns...)
+}
+
+var _ cursorDynamoDB = (*stubCursorDynamo)(nil)
+
+func TestDeliveryCursorStore_SaveCursors(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("moves each cursor forward", func(t *testing.T) {
+		written := map[string]string{}
+		store := NewDeliveryCursorStore(&stubCursorDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "delivery_state", *params.TableName)
+				assert.Equal(t, "attribute_not_exists(last_acked_sequence) OR last_acked_sequence < :seq", *params.ConditionExpression)
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "user-001"}, params.Key["user_id"])
+				sk := params.Key["cursor"].(*dynamo.AttributeValueMemberS).Value
+				written[sk] = params.ExpressionAttributeValues[":seq"].(*dynamo.AttributeValueMemberN).Value
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, "delivery_state")
+
+		err := store.SaveCursors(context.Background(), []app.DeliveryCursor{
+			{UserID: "user-001", DeviceID: "device-001", ChatID: "chat-1", Sequence: 5, At: at},
+			{UserID: "user-001", DeviceID: "device-002", ChatID: "chat-1", Sequence: 9, At: at},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"device-001#chat-1": "5", "device-002#chat-1": "9"}, written)
+	})
+
+	t.Run("cursors already ahead are skipped", func(t *testing.T) {
+		store := NewDeliveryCursorStore(&stubCursorDynamo{
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "delivery_state")
+
+		err := store.SaveCursors(context.Background(), []app.DeliveryCursor{{UserID: "user-001", DeviceID: "device-001", ChatID: "chat-1", Sequence: 5}})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("failures are joined", func(t *testing.T) {
+		store := NewDeliveryCursorStore(&stubCursorDynamo{
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "delivery_state")
+
+		err := store.SaveCursors(context.Background(), []app.DeliveryCursor{
+			{UserID: "user-001", DeviceID: "device-001", ChatID: "chat-1", Sequence: 5},
+			{UserID: "user-001", DeviceID: "device-001", ChatID: "chat-2", Sequence: 5},
+		})
+
+		assert.ErrorContains(t, err, "2 of 2 writes failed")
+	})
+}
+
+func TestDeliveryCursorStore_GetCursor(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the checkpointed sequence", func(t *testing.T) {
+		store := NewDeliveryCursorStore(&stubCursorDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "device-001#chat-1"}, params.Key["cursor"])
+				assert.True(t, *params.ConsistentRead)
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+					"last_acked_sequence": &dynamo.AttributeValueMemberN{Value: "42"},
+				}}, nil
+			},
+		}, "delivery_state")
+
+		seq, ok, err := store.GetCursor(ctx, "user-001", "device-001", "chat-1")
+
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(42), seq)
+	})
+
+	t.Run("no cursor", func(t *testing.T) {
+		store := NewDeliveryCursorStore(&stubCursorDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, "delivery_state")
+
+		_, ok, err := store.GetCursor(ctx, "user-001", "device-001", "chat-1")
+
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewDeliveryCursorStore(&stubCursorDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "delivery_state")
+
+		_, _, err := store.GetCursor(ctx, "user-001", "device-001", "chat-1")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// Compile-time check: MembershipStore satisfies app.MembershipChecker.
+var _ app.MembershipChecker = (*MembershipStore)(nil)
+
+// membershipStatusPending marks a chat_memberships item that is a join
+// request awaiting approval rather than a membership.
+const membershipStatusPending = "pending"
+
+// membershipDynamoDB is the subset of the DynamoDB client the membership
+// store needs. The *dynamodb.Client satisfies this interface.
+type membershipDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+}
+
+// MembershipStore reads the Chat Mgmt chat_memberships table (PK chat_id,
+// SK user_id). It never writes to it.
+type MembershipStore struct {
+	db        membershipDynamoDB
+	tableName string
+}
+
+// NewMembershipStore creates a MembershipStore for the chat_memberships
+// table.
+func NewMembershipStore(db membershipDynamoDB, tableName string) *MembershipStore {
+	return &MembershipStore{db: db, tableName: tableName}
+}
+
+// IsMember reports whether userID is a member of chatID. A pending join
+// request is not a membership.
+func (s *MembershipStore) IsMember(ctx context.Context, chatID, userID string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_memberships.is_member")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "#status"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+			"user_id": &dynamo.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression:     &projection,
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return false, fmt.Errorf("membership store: get: %w", err)
+	}
+	if out.Item == nil {
+		return false, nil
+	}
+	status, _ := out.Item["status"].(*dynamo.AttributeValueMemberS)
+	return status == nil || status.Value != membershipStatusPending, nil
+}
@@ -0,0 +1,64 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+type stubMembershipDynamo struct {
+	getItemFn func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+}
+
+func (s *stubMembershipDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+var _ membershipDynamoDB = (*stubMembershipDynamo)(nil)
+
+func TestMembershipStore_IsMember(t *testing.T) {
+	tests := []struct {
+		name string
+		item map[string]dynamo.AttributeValue
+		want bool
+	}{
+		{name: "member", item: map[string]dynamo.AttributeValue{"status": &dynamo.AttributeValueMemberS{Value: "active"}}, want: true},
+		{name: "member without a status", item: map[string]dynamo.AttributeValue{}, want: true},
+		{name: "pending join request", item: map[string]dynamo.AttributeValue{"status": &dynamo.AttributeValueMemberS{Value: "pending"}}},
+		{name: "no item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMembershipStore(&stubMembershipDynamo{
+				getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+					assert.Equal(t, "chat_memberships", *params.TableName)
+					assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "chat-1"}, params.Key["chat_id"])
+					assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "user-001"}, params.Key["user_id"])
+					return &dynamo.GetItemOutput{Item: tt.item}, nil
+				},
+			}, "chat_memberships")
+
+			got, err := store.IsMember(context.Background(), "chat-1", "user-001")
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("store error", func(t *testing.T) {
+		store := NewMembershipStore(&stubMembershipDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "chat_memberships")
+
+		_, err := store.IsMember(context.Background(), "chat-1", "user-001")
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
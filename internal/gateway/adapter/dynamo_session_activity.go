@@ -0,0 +1,101 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// activityWriteConcurrency bounds the UpdateItem calls one flush has in
+// flight. DynamoDB has no batch update, so a flush is one write per session.
+const activityWriteConcurrency = 16
+
+// Compile-time check: SessionActivityStore satisfies app.ActivityStore.
+var _ app.ActivityStore = (*SessionActivityStore)(nil)
+
+// activityDynamoDB is the subset of the DynamoDB client the activity store
+// needs. The *dynamodb.Client satisfies this interface.
+type activityDynamoDB interface {
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+// SessionActivityStore writes last_active_at on the Chat Mgmt sessions
+// table (ADR-007 §2.8). It touches no other attribute.
+type SessionActivityStore struct {
+	db        activityDynamoDB
+	tableName string
+}
+
+// NewSessionActivityStore creates a SessionActivityStore for the sessions
+// table.
+func NewSessionActivityStore(db activityDynamoDB, tableName string) *SessionActivityStore {
+	return &SessionActivityStore{db: db, tableName: tableName}
+}
+
+// RecordActivity moves each session's last_active_at forward to its
+// activity time. Sessions that no longer exist, or already record later
+// activity, are skipped. Every write is attempted; the failures are
+// returned joined.
+func (s *SessionActivityStore) RecordActivity(ctx context.Context, batch []app.SessionActivity) error {
+	ctx, span := tracer.Start(ctx, "dynamo.sessions.record_activity")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+		attribute.Int("sessions.count", len(batch)),
+	)
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	var g errgroup.Group
+	g.SetLimit(activityWriteConcurrency)
+	for _, a := range batch {
+		g.Go(func() error {
+			if err := s.touch(ctx, a); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("session activity store: record: %d of %d writes failed: %w", len(errs), len(batch), err)
+	}
+	return nil
+}
+
+func (s *SessionActivityStore) touch(ctx context.Context, a app.SessionActivity) error {
+	updateExpr := "SET last_active_at = :at"
+	condExpr := "attribute_exists(session_id) AND (attribute_not_exists(last_active_at) OR last_active_at < :at)"
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]dynamo.AttributeValue{
+			"session_id": &dynamo.AttributeValueMemberS{Value: a.SessionID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":at": &dynamo.AttributeValueMemberS{Value: domain.NewTimestampMS(a.At).String()},
+		},
+	})
+	if err != nil && !dynamo.IsConditionalCheckFailed(err) {
+		return fmt.Errorf("session %s: %w", a.SessionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+type stubActivityDynamo struct {
+	mu           sync.Mutex
+	updateItemFn func(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+func (s *stubActivityDynamo) UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateItemFn(ctx, params, optFns...)
+}
+
+var _ activityDynamoDB = (*stubActivityDynamo)(nil)
+
+func TestSessionActivityStore_RecordActivity(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	t.Run("moves last_active_at forward on live sessions", func(t *testing.T) {
+		written := map[string]string{}
+		store := NewSessionActivityStore(&stubActivityDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				assert.Equal(t, "sessions", *params.TableName)
+				assert.Equal(t, "SET last_active_at = :at", *params.UpdateExpression)
+				assert.Contains(t, *params.ConditionExpression, "attribute_exists(session_id)")
+				assert.Contains(t, *params.ConditionExpression, "last_active_at < :at")
+				id := params.Key["session_id"].(*dynamo.AttributeValueMemberS).Value
+				written[id] = params.ExpressionAttributeValues[":at"].(*dynamo.AttributeValueMemberS).Value
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, "sessions")
+
+		err := store.RecordActivity(context.Background(), []app.SessionActivity{
+			{SessionID: "sess-a", At: at},
+			{SessionID: "sess-b", At: at.Add(time.Minute)},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"sess-a": "2026-03-01T11:00:00.000Z",
+			"sess-b": "2026-03-01T11:01:00.000Z",
+		}, written)
+	})
+
+	t.Run("revoked or newer sessions are skipped", func(t *testing.T) {
+		store := NewSessionActivityStore(&stubActivityDynamo{
+			updateItemFn: func(context.Context, *dynamo.UpdateItemInput, ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				return nil, dynamo.ErrConditionalCheckFailed()
+			},
+		}, "sessions")
+
+		assert.NoError(t, store.RecordActivity(context.Background(), []app.SessionActivity{{SessionID: "sess-a", At: at}}))
+	})
+
+	t.Run("attempts every write and reports failures", func(t *testing.T) {
+		var calls int
+		store := NewSessionActivityStore(&stubActivityDynamo{
+			updateItemFn: func(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+				calls++
+				if params.Key["session_id"].(*dynamo.AttributeValueMemberS).Value == "sess-b" {
+					return nil, errors.New("throttled")
+				}
+				return &dynamo.UpdateItemOutput{}, nil
+			},
+		}, "sessions")
+
+		err := store.RecordActivity(context.Background(), []app.SessionActivity{
+			{SessionID: "sess-a", At: at},
+			{SessionID: "sess-b", At: at},
+			{SessionID: "sess-c", At: at},
+		})
+
+		assert.ErrorContains(t, err, "1 of 3 writes failed")
+		assert.ErrorContains(t, err, "sess-b: throttled")
+		assert.Equal(t, 3, calls)
+	})
+}
@@ -0,0 +1,290 @@
+package adapter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Compile-time check: SyncStore satisfies app.SyncStore.
+var _ app.SyncStore = (*SyncStore)(nil)
+
+// syncDynamoDB is the subset of the DynamoDB client the sync store needs.
+// The *dynamodb.Client satisfies this interface.
+type syncDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+// syncMessageItem is the messages_v2 item shape, as written by Ingest. PK
+// is domain.MessagePartitionKey of the chat's shard for Sequence.
+type syncMessageItem struct {
+	Sequence        uint64 `dynamodbav:"sequence"`
+	ChatID          string `dynamodbav:"chat_id"`
+	MessageID       string `dynamodbav:"message_id"`
+	SenderID        string `dynamodbav:"sender_id"`
+	ClientMessageID string `dynamodbav:"client_message_id"`
+	Content         string `dynamodbav:"content"`
+	ContentType     string `dynamodbav:"content_type"`
+	CreatedAt       string `dynamodbav:"created_at"`
+	ReceivedAt      string `dynamodbav:"server_received_at"`
+
+	// ContentKey and SealedContent replace Content on encrypted messages.
+	ContentKey    int    `dynamodbav:"content_key"`
+	SealedContent []byte `dynamodbav:"sealed_content"`
+}
+
+// SyncStore reads sync state from the tables Ingest and Chat Mgmt own:
+// chat metadata and shard counts from chats, the latest sequence from
+// chat_counters and messages from the sharded messages_v2. It never
+// writes to them.
+type SyncStore struct {
+	db            syncDynamoDB
+	messagesTable string
+	chatsTable    string
+	countersTable string
+	opener        msgcrypt.Opener
+}
+
+// NewSyncStore creates a SyncStore backed by the given DynamoDB client.
+// Encrypted content is opened with opener; with a nil opener, reading an
+// encrypted message fails.
+func NewSyncStore(db syncDynamoDB, messagesTable, chatsTable, countersTable string, opener msgcrypt.Opener) *SyncStore {
+	return &SyncStore{
+		db:            db,
+		messagesTable: messagesTable,
+		chatsTable:    chatsTable,
+		countersTable: countersTable,
+		opener:        opener,
+	}
+}
+
+// Chat returns the chat's type, name and latest persisted sequence. Returns
+// domain.ErrNotFound for an unknown chat. A chat with no messages yet has
+// latest sequence 0.
+func (s *SyncStore) Chat(ctx context.Context, chatID string) (protocol.ChatSnapshot, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chats.sync_snapshot")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	projection := "chat_type, #name"
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName:                &s.chatsTable,
+		Key:                      chatKey(chatID),
+		ProjectionExpression:     &projection,
+		ExpressionAttributeNames: map[string]string{"#name": "name"},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return protocol.ChatSnapshot{}, fmt.Errorf("sync store: get chat: %w", err)
+	}
+	if out.Item == nil {
+		return protocol.ChatSnapshot{}, fmt.Errorf("sync store: chat %s: %w", chatID, domain.ErrNotFound)
+	}
+	var chat struct {
+		ChatType string `dynamodbav:"chat_type"`
+		Name     string `dynamodbav:"name"`
+	}
+	if err := dynamo.UnmarshalMap(out.Item, &chat); err != nil {
+		return protocol.ChatSnapshot{}, fmt.Errorf("sync store: unmarshal chat: %w", err)
+	}
+
+	projection = "sequence_counter"
+	consistentRead := true
+	out, err = s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName:            &s.countersTable,
+		Key:                  chatKey(chatID),
+		ProjectionExpression: &projection,
+		ConsistentRead:       &consistentRead,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return protocol.ChatSnapshot{}, fmt.Errorf("sync store: get sequence: %w", err)
+	}
+	var counter struct {
+		Sequence uint64 `dynamodbav:"sequence_counter"`
+	}
+	if err := dynamo.UnmarshalMap(out.Item, &counter); err != nil {
+		return protocol.ChatSnapshot{}, fmt.Errorf("sync store: unmarshal sequence: %w", err)
+	}
+
+	return protocol.ChatSnapshot{
+		ChatType:       chat.ChatType,
+		Name:           chat.Name,
+		LatestSequence: counter.Sequence,
+	}, nil
+}
+
+// ListAfter returns up to limit messages with sequence > after, in
+// ascending sequence order. Each shard is read, page by page, until it has
+// yielded limit messages past after or has none left, and the results are
+// merged, which always contains the overall first limit.
+func (s *SyncStore) ListAfter(ctx context.Context, chatID string, after uint64, limit int) ([]protocol.Message, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.list_after")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem+Query"),
+	)
+
+	shards, err := s.shards(ctx, chatID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("messages.shards", shards))
+
+	keyExpr := "pk = :pk AND #seq > :after"
+	afterValue := strconv.FormatUint(after, 10)
+	consistentRead := true
+
+	var messages []protocol.Message
+	for shard := range shards {
+		var startKey map[string]dynamo.AttributeValue
+		for read := 0; read < limit; {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("sync store: list after: %w", err)
+			}
+
+			pageLimit := int32(limit - read) //nolint:gosec // limit is at most domain.MaxPageSize
+			out, err := s.db.Query(ctx, &dynamo.QueryInput{
+				TableName:                &s.messagesTable,
+				KeyConditionExpression:   &keyExpr,
+				ExpressionAttributeNames: map[string]string{"#seq": "sequence"},
+				ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+					":pk":    &dynamo.AttributeValueMemberS{Value: domain.MessagePartitionKey(chatID, shard)},
+					":after": &dynamo.AttributeValueMemberN{Value: afterValue},
+				},
+				Limit:             &pageLimit,
+				ConsistentRead:    &consistentRead,
+				ExclusiveStartKey: startKey,
+			})
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, fmt.Errorf("sync store: query shard %d: %w", shard, err)
+			}
+
+			for _, av := range out.Items {
+				var item syncMessageItem
+				if err := dynamo.UnmarshalMap(av, &item); err != nil {
+					return nil, fmt.Errorf("sync store: unmarshal message: %w", err)
+				}
+				msg, err := s.toMessage(ctx, item)
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+					return nil, fmt.Errorf("sync store: %w", err)
+				}
+				messages = append(messages, msg)
+			}
+			read += len(out.Items)
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			startKey = out.LastEvaluatedKey
+		}
+	}
+
+	slices.SortFunc(messages, func(a, b protocol.Message) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// toMessage converts a stored message to its wire form, opening encrypted
+// content.
+func (s *SyncStore) toMessage(ctx context.Context, item syncMessageItem) (protocol.Message, error) {
+	if item.ContentKey != 0 {
+		if s.opener == nil {
+			return protocol.Message{}, fmt.Errorf("message %s/%d is encrypted and no keyring is configured", item.ChatID, item.Sequence)
+		}
+		plaintext, err := s.opener.Open(ctx, item.ChatID, item.MessageID, msgcrypt.Sealed{
+			KeyVersion: item.ContentKey,
+			Ciphertext: item.SealedContent,
+		})
+		if err != nil {
+			return protocol.Message{}, err
+		}
+		item.Content = string(plaintext)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	if err != nil {
+		return protocol.Message{}, fmt.Errorf("parse created_at: %w", err)
+	}
+	msg := protocol.Message{
+		MessageID:       item.MessageID,
+		ChatID:          item.ChatID,
+		SenderID:        item.SenderID,
+		ClientMessageID: item.ClientMessageID,
+		Sequence:        item.Sequence,
+		ContentType:     item.ContentType,
+		Content:         item.Content,
+		CreatedAt:       createdAt.UnixMilli(),
+	}
+	if item.ReceivedAt != "" {
+		receivedAt, err := time.Parse(time.RFC3339Nano, item.ReceivedAt)
+		if err != nil {
+			return protocol.Message{}, fmt.Errorf("parse server_received_at: %w", err)
+		}
+		msg.ServerReceivedAt = receivedAt.UnixMilli()
+	}
+	return msg, nil
+}
+
+// shards returns the chat's message shard count.
+func (s *SyncStore) shards(ctx context.Context, chatID string) (int, error) {
+	projection := "message_shards"
+	consistentRead := true
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName:            &s.chatsTable,
+		Key:                  chatKey(chatID),
+		ProjectionExpression: &projection,
+		ConsistentRead:       &consistentRead,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("sync store: get shard count: %w", err)
+	}
+
+	var chat struct {
+		MessageShards int `dynamodbav:"message_shards"`
+	}
+	if err := dynamo.UnmarshalMap(out.Item, &chat); err != nil {
+		return 0, fmt.Errorf("sync store: unmarshal shard count: %w", err)
+	}
+	if chat.MessageShards == 0 {
+		return 1, nil
+	}
+	if !domain.IsValidMessageShards(chat.MessageShards) {
+		return 0, fmt.Errorf("sync store: chat %s has %d message shards, want [1, %d]",
+			chatID, chat.MessageShards, domain.MaxMessageShards)
+	}
+	return chat.MessageShards, nil
+}
+
+// chatKey is the key of a chat's item in the tables keyed by chat_id.
+func chatKey(chatID string) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{
+		"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+	}
+}
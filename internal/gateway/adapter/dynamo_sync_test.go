@@ -0,0 +1,211 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+type stubSyncDynamo struct {
+	getItemFn func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	queryFn   func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+func (s *stubSyncDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubSyncDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+var _ syncDynamoDB = (*stubSyncDynamo)(nil)
+
+// openerFunc adapts a function to msgcrypt.Opener.
+type openerFunc func(ctx context.Context, chatID, messageID string, sealed msgcrypt.Sealed) ([]byte, error)
+
+func (f openerFunc) Open(ctx context.Context, chatID, messageID string, sealed msgcrypt.Sealed) ([]byte, error) {
+	return f(ctx, chatID, messageID, sealed)
+}
+
+func syncMessageAV(shard int, seq, content string) map[string]dynamo.AttributeValue {
+	return map[string]dynamo.AttributeValue{
+		"pk":                 &dynamo.AttributeValueMemberS{Value: domain.MessagePartitionKey("chat-1", shard)},
+		"sequence":           &dynamo.AttributeValueMemberN{Value: seq},
+		"chat_id":            &dynamo.AttributeValueMemberS{Value: "chat-1"},
+		"message_id":         &dynamo.AttributeValueMemberS{Value: "msg-" + seq},
+		"sender_id":          &dynamo.AttributeValueMemberS{Value: "user-001"},
+		"client_message_id":  &dynamo.AttributeValueMemberS{Value: "c-" + seq},
+		"content":            &dynamo.AttributeValueMemberS{Value: content},
+		"content_type":       &dynamo.AttributeValueMemberS{Value: "text"},
+		"created_at":         &dynamo.AttributeValueMemberS{Value: "2024-01-15T10:00:00Z"},
+		"server_received_at": &dynamo.AttributeValueMemberS{Value: "2024-01-15T09:59:59.5Z"},
+	}
+}
+
+func TestSyncStore_Chat(t *testing.T) {
+	t.Run("reads metadata and the latest sequence", func(t *testing.T) {
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				assert.Equal(t, &dynamo.AttributeValueMemberS{Value: "chat-1"}, params.Key["chat_id"])
+				switch *params.TableName {
+				case "chats":
+					return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+						"chat_type": &dynamo.AttributeValueMemberS{Value: "group"},
+						"name":      &dynamo.AttributeValueMemberS{Value: "Team"},
+					}}, nil
+				case "chat_counters":
+					return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+						"sequence_counter": &dynamo.AttributeValueMemberN{Value: "42"},
+					}}, nil
+				}
+				t.Fatalf("unexpected table %s", *params.TableName)
+				return nil, nil
+			},
+		}, "messages_v2", "chats", "chat_counters", nil)
+
+		got, err := store.Chat(context.Background(), "chat-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, protocol.ChatSnapshot{ChatType: "group", Name: "Team", LatestSequence: 42}, got)
+	})
+
+	t.Run("a chat without messages is at sequence 0", func(t *testing.T) {
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				if *params.TableName == "chats" {
+					return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+						"chat_type": &dynamo.AttributeValueMemberS{Value: "direct"},
+					}}, nil
+				}
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, "messages_v2", "chats", "chat_counters", nil)
+
+		got, err := store.Chat(context.Background(), "chat-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, protocol.ChatSnapshot{ChatType: "direct"}, got)
+	})
+
+	t.Run("unknown chat", func(t *testing.T) {
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+				return &dynamo.GetItemOutput{}, nil
+			},
+		}, "messages_v2", "chats", "chat_counters", nil)
+
+		_, err := store.Chat(context.Background(), "chat-1")
+
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestSyncStore_ListAfter(t *testing.T) {
+	shardCount := func(n string) func(context.Context, *dynamo.GetItemInput, ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+		return func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			assert.Equal(t, "chats", *params.TableName)
+			if n == "" {
+				return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{}}, nil
+			}
+			return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+				"message_shards": &dynamo.AttributeValueMemberN{Value: n},
+			}}, nil
+		}
+	}
+
+	t.Run("merges shards in sequence order", func(t *testing.T) {
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: shardCount("2"),
+			queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				assert.Equal(t, "messages_v2", *params.TableName)
+				assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "10"}, params.ExpressionAttributeValues[":after"])
+				pk := params.ExpressionAttributeValues[":pk"].(*dynamo.AttributeValueMemberS).Value
+				if pk == domain.MessagePartitionKey("chat-1", 0) {
+					return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+						syncMessageAV(0, "12", "b"), syncMessageAV(0, "14", "d"),
+					}}, nil
+				}
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{
+					syncMessageAV(1, "11", "a"), syncMessageAV(1, "13", "c"),
+				}}, nil
+			},
+		}, "messages_v2", "chats", "chat_counters", nil)
+
+		got, err := store.ListAfter(context.Background(), "chat-1", 10, 3)
+
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, []uint64{11, 12, 13}, []uint64{got[0].Sequence, got[1].Sequence, got[2].Sequence})
+		assert.Equal(t, protocol.Message{
+			MessageID:        "msg-11",
+			ChatID:           "chat-1",
+			SenderID:         "user-001",
+			ClientMessageID:  "c-11",
+			Sequence:         11,
+			ContentType:      "text",
+			Content:          "a",
+			CreatedAt:        1705312800000,
+			ServerReceivedAt: 1705312799500,
+		}, got[0])
+	})
+
+	t.Run("opens encrypted content", func(t *testing.T) {
+		item := syncMessageAV(0, "11", "")
+		item["content_key"] = &dynamo.AttributeValueMemberN{Value: "3"}
+		item["sealed_content"] = &dynamo.AttributeValueMemberB{Value: []byte("sealed")}
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: shardCount(""),
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item}}, nil
+			},
+		}, "messages_v2", "chats", "chat_counters", openerFunc(func(_ context.Context, chatID, messageID string, sealed msgcrypt.Sealed) ([]byte, error) {
+			assert.Equal(t, "chat-1", chatID)
+			assert.Equal(t, "msg-11", messageID)
+			assert.Equal(t, msgcrypt.Sealed{KeyVersion: 3, Ciphertext: []byte("sealed")}, sealed)
+			return []byte("secret"), nil
+		}))
+
+		got, err := store.ListAfter(context.Background(), "chat-1", 10, 5)
+
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "secret", got[0].Content)
+	})
+
+	t.Run("encrypted content without a keyring", func(t *testing.T) {
+		item := syncMessageAV(0, "11", "")
+		item["content_key"] = &dynamo.AttributeValueMemberN{Value: "3"}
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: shardCount(""),
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{item}}, nil
+			},
+		}, "messages_v2", "chats", "chat_counters", nil)
+
+		_, err := store.ListAfter(context.Background(), "chat-1", 10, 5)
+
+		assert.ErrorContains(t, err, "no keyring")
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		store := NewSyncStore(&stubSyncDynamo{
+			getItemFn: shardCount(""),
+			queryFn: func(context.Context, *dynamo.QueryInput, ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}, "messages_v2", "chats", "chat_counters", nil)
+
+		_, err := store.ListAfter(context.Background(), "chat-1", 10, 5)
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
@@ -0,0 +1,29 @@
+package adapter
+
+import (
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// PollableTransport is a socket-backed transport that EpollReader can watch
+// for readiness instead of parking a goroutine in Recv.
+type PollableTransport interface {
+	app.Transport
+
+	// Fd returns the socket descriptor. It must stay open until reads stop.
+	Fd() int
+
+	// Buffered reports whether Recv can return a frame from bytes already
+	// read off the socket. Such frames raise no readiness event, so the
+	// reader keeps calling Recv while this is true.
+	Buffered() bool
+
+	// SetReadDeadline bounds the socket reads of the next Recv, which must
+	// fail once it passes. The reader sets it before each Recv so a client
+	// that stalls part way through a frame cannot hold a worker.
+	SetReadDeadline(t time.Time) error
+}
+
+// Compile-time check: EpollReader satisfies app.ReadScheduler.
+var _ app.ReadScheduler = (*EpollReader)(nil)
@@ -0,0 +1,212 @@
+//go:build linux
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// pollEvents arms a socket for one readable or hang-up notification. The
+// oneshot flag keeps two workers from reading the same connection; a worker
+// re-arms the socket after draining it.
+const pollEvents = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT
+
+// pollWaitMillis bounds epoll_wait so Run notices ctx cancellation.
+const pollWaitMillis = 100
+
+// EpollReader is an app.ReadScheduler that watches every connection's socket
+// with one epoll instance and reads ready connections with a fixed worker
+// pool. Idle connections then cost a registration instead of a parked
+// goroutine and its stack (see BenchmarkIdleReaders).
+//
+// A worker stays with a connection for one Recv and for the frame handler.
+// The rest of a partially received frame is waited for up to the frame
+// timeout; a client that stalls longer is disconnected. Size the pool for
+// handler latency, not connection count. Transports that are not
+// PollableTransport fall back to a goroutine per connection.
+type EpollReader struct {
+	epfd         int
+	workers      int
+	frameTimeout time.Duration
+	ready        chan *pollEntry
+
+	mu      sync.Mutex
+	entries map[int32]*pollEntry
+}
+
+type pollEntry struct {
+	ctx  context.Context
+	t    PollableTransport
+	read app.ReadFunc
+	fd   int32
+	stop func() bool // unregisters the ctx callback; guarded by EpollReader.mu
+
+	done atomic.Bool
+}
+
+// NewEpollReader creates an EpollReader with the given number of workers.
+// Zero workers uses four per GOMAXPROCS; zero frameTimeout defaults to
+// domain.EpollFrameTimeout. Run must be called to start reading.
+func NewEpollReader(workers int, frameTimeout time.Duration) (*EpollReader, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("epoll create: %w", err)
+	}
+	if workers <= 0 {
+		workers = 4 * runtime.GOMAXPROCS(0)
+	}
+	if frameTimeout <= 0 {
+		frameTimeout = domain.EpollFrameTimeout
+	}
+	return &EpollReader{
+		epfd:         epfd,
+		workers:      workers,
+		frameTimeout: frameTimeout,
+		ready:        make(chan *pollEntry, 1024),
+		entries:      make(map[int32]*pollEntry),
+	}, nil
+}
+
+// Start registers t for reading. Reads stop when read returns false, Recv
+// fails, or ctx is done.
+func (r *EpollReader) Start(ctx context.Context, t app.Transport, read app.ReadFunc) {
+	pt, ok := t.(PollableTransport)
+	if !ok {
+		app.GoroutineReader{}.Start(ctx, t, read)
+		return
+	}
+
+	e := &pollEntry{ctx: ctx, t: pt, read: read, fd: int32(pt.Fd())}
+	r.mu.Lock()
+	r.entries[e.fd] = e
+	err := syscall.EpollCtl(r.epfd, syscall.EPOLL_CTL_ADD, int(e.fd), &syscall.EpollEvent{Events: pollEvents, Fd: e.fd})
+	r.mu.Unlock()
+	if err != nil {
+		r.finish(e)
+		read(nil, fmt.Errorf("epoll register: %w", err))
+		return
+	}
+	stop := context.AfterFunc(ctx, func() { r.finish(e) })
+	r.mu.Lock()
+	e.stop = stop
+	r.mu.Unlock()
+}
+
+// Run dispatches readiness events to the worker pool until ctx is done or
+// epoll fails.
+func (r *EpollReader) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for range r.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range r.ready {
+				r.serve(e)
+			}
+		}()
+	}
+	defer func() {
+		close(r.ready)
+		wg.Wait()
+	}()
+
+	events := make([]syscall.EpollEvent, 256)
+	for ctx.Err() == nil {
+		n, err := syscall.EpollWait(r.epfd, events, pollWaitMillis)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("epoll wait: %w", err)
+		}
+		for i := range n {
+			r.mu.Lock()
+			e := r.entries[events[i].Fd]
+			r.mu.Unlock()
+			if e != nil {
+				r.ready <- e
+			}
+		}
+	}
+	return nil
+}
+
+// Close releases the epoll instance. Call it after Run returns.
+func (r *EpollReader) Close() error {
+	return syscall.Close(r.epfd)
+}
+
+// serve reads every frame available on e, then re-arms its socket. Each
+// Recv must complete within the frame timeout. A panic in Recv ends that
+// connection as a read error; the worker survives.
+func (r *EpollReader) serve(e *pollEntry) {
+	defer func() {
+		if v := recover(); v != nil {
+			err := observability.HandlePanic(e.ctx, "gateway_read_loop", v)
+			r.finish(e)
+			e.read(nil, err)
+		}
+	}()
+	for {
+		if e.done.Load() {
+			return
+		}
+		if err := e.t.SetReadDeadline(time.Now().Add(r.frameTimeout)); err != nil {
+			r.finish(e)
+			e.read(nil, fmt.Errorf("set read deadline: %w", err))
+			return
+		}
+		f, err := e.t.Recv(e.ctx)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = fmt.Errorf("frame not completed within %s: %w", r.frameTimeout, err)
+		}
+		if !e.read(f, err) || err != nil {
+			r.finish(e)
+			return
+		}
+		if !e.t.Buffered() {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	var err error
+	if r.entries[e.fd] == e {
+		err = syscall.EpollCtl(r.epfd, syscall.EPOLL_CTL_MOD, int(e.fd), &syscall.EpollEvent{Events: pollEvents, Fd: e.fd})
+	}
+	r.mu.Unlock()
+	if err != nil && !e.done.Load() {
+		r.finish(e)
+		e.read(nil, fmt.Errorf("epoll rearm: %w", err))
+	}
+}
+
+// finish unregisters e once. The entry check keeps a late finish from
+// removing a newer connection that reuses the same descriptor.
+func (r *EpollReader) finish(e *pollEntry) {
+	if !e.done.CompareAndSwap(false, true) {
+		return
+	}
+	r.mu.Lock()
+	stop := e.stop
+	if r.entries[e.fd] == e {
+		delete(r.entries, e.fd)
+		_ = syscall.EpollCtl(r.epfd, syscall.EPOLL_CTL_DEL, int(e.fd), nil)
+	}
+	r.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
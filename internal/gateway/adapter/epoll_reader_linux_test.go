@@ -0,0 +1,300 @@
+//go:build linux
+
+package adapter_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// fdTransport reads newline-delimited JSON frames from a raw socket. Recv is
+// only called after epoll reports the socket readable.
+type fdTransport struct {
+	fd      int
+	pending []byte
+}
+
+func (t *fdTransport) Fd() int { return t.fd }
+
+func (t *fdTransport) Buffered() bool { return bytes.IndexByte(t.pending, '\n') >= 0 }
+
+// SetReadDeadline arms SO_RCVTIMEO, so a blocking read past d fails.
+func (t *fdTransport) SetReadDeadline(d time.Time) error {
+	tv := syscall.NsecToTimeval(max(time.Until(d), time.Microsecond).Nanoseconds())
+	return syscall.SetsockoptTimeval(t.fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+}
+
+func (t *fdTransport) Send(context.Context, *protocol.Frame) error { return nil }
+
+func (t *fdTransport) Recv(context.Context) (*protocol.Frame, error) {
+	buf := make([]byte, 4096)
+	for !t.Buffered() {
+		n, err := syscall.Read(t.fd, buf)
+		if errors.Is(err, syscall.EAGAIN) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, io.EOF
+		}
+		t.pending = append(t.pending, buf[:n]...)
+	}
+	i := bytes.IndexByte(t.pending, '\n')
+	line := t.pending[:i]
+	t.pending = t.pending[i+1:]
+	var f protocol.Frame
+	if err := json.Unmarshal(line, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+var _ adapter.PollableTransport = (*fdTransport)(nil)
+
+// socketPair returns our end wrapped in an fdTransport and the peer's file.
+func socketPair(tb testing.TB) (*fdTransport, *os.File) {
+	tb.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	require.NoError(tb, err)
+	peer := os.NewFile(uintptr(fds[1]), "peer")
+	tb.Cleanup(func() {
+		_ = peer.Close()
+		_ = syscall.Close(fds[0])
+	})
+	return &fdTransport{fd: fds[0]}, peer
+}
+
+func writeFrames(tb testing.TB, w io.Writer, types ...protocol.FrameType) {
+	tb.Helper()
+	var buf bytes.Buffer
+	for _, ft := range types {
+		require.NoError(tb, protocol.WriteFrame(&buf, &protocol.Frame{Type: ft}))
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	require.NoError(tb, err)
+}
+
+func runReader(tb testing.TB, workers int, frameTimeout time.Duration) *adapter.EpollReader {
+	tb.Helper()
+	r, err := adapter.NewEpollReader(workers, frameTimeout)
+	require.NoError(tb, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	tb.Cleanup(func() {
+		cancel()
+		assert.NoError(tb, <-done)
+		assert.NoError(tb, r.Close())
+	})
+	return r
+}
+
+// recorder collects ReadFunc calls.
+type recorder struct {
+	mu     sync.Mutex
+	frames []protocol.FrameType
+	err    error
+	ended  chan struct{}
+}
+
+func newRecorder() *recorder { return &recorder{ended: make(chan struct{})} }
+
+func (r *recorder) read(f *protocol.Frame, err error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.err = err
+		close(r.ended)
+		return false
+	}
+	r.frames = append(r.frames, f.Type)
+	return true
+}
+
+func (r *recorder) got() []protocol.FrameType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]protocol.FrameType(nil), r.frames...)
+}
+
+func TestEpollReader(t *testing.T) {
+	t.Run("delivers frames in order across wakeups until EOF", func(t *testing.T) {
+		r := runReader(t, 2, 0)
+		tr, peer := socketPair(t)
+		rec := newRecorder()
+
+		r.Start(context.Background(), tr, rec.read)
+
+		writeFrames(t, peer, protocol.FrameTypePong, protocol.FrameTypeAck) // one wakeup, two frames
+		require.Eventually(t, func() bool { return len(rec.got()) == 2 }, time.Second, time.Millisecond)
+		writeFrames(t, peer, protocol.FrameTypeSyncRequest)
+		require.NoError(t, peer.Close())
+
+		select {
+		case <-rec.ended:
+		case <-time.After(time.Second):
+			t.Fatal("reader did not stop at EOF")
+		}
+		assert.Equal(t, []protocol.FrameType{protocol.FrameTypePong, protocol.FrameTypeAck, protocol.FrameTypeSyncRequest}, rec.got())
+		assert.ErrorIs(t, rec.err, io.EOF)
+	})
+
+	t.Run("cancelled ctx stops delivery", func(t *testing.T) {
+		r := runReader(t, 1, 0)
+		tr, peer := socketPair(t)
+		rec := newRecorder()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		r.Start(ctx, tr, rec.read)
+		cancel()
+		time.Sleep(10 * time.Millisecond) // let the ctx callback unregister
+		writeFrames(t, peer, protocol.FrameTypePong)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, rec.got())
+	})
+
+	t.Run("a stalled partial frame fails its connection, not the worker", func(t *testing.T) {
+		r := runReader(t, 1, 50*time.Millisecond)
+		stalled, stalledPeer := socketPair(t)
+		live, livePeer := socketPair(t)
+		stalledRec, liveRec := newRecorder(), newRecorder()
+
+		r.Start(context.Background(), stalled, stalledRec.read)
+		r.Start(context.Background(), live, liveRec.read)
+
+		_, err := stalledPeer.Write([]byte(`{"type":`))
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond) // the only worker is now waiting on it
+		writeFrames(t, livePeer, protocol.FrameTypePong)
+
+		select {
+		case <-stalledRec.ended:
+		case <-time.After(time.Second):
+			t.Fatal("stalled connection was not failed")
+		}
+		assert.ErrorIs(t, stalledRec.err, os.ErrDeadlineExceeded)
+		require.Eventually(t, func() bool { return len(liveRec.got()) == 1 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("non-pollable transport falls back to a goroutine", func(t *testing.T) {
+		r := runReader(t, 1, 0)
+		rec := newRecorder()
+		tr := &chanTransport{frames: make(chan *protocol.Frame, 1)}
+
+		r.Start(context.Background(), tr, rec.read)
+		tr.frames <- &protocol.Frame{Type: protocol.FrameTypePong}
+		close(tr.frames)
+
+		<-rec.ended
+		assert.Equal(t, []protocol.FrameType{protocol.FrameTypePong}, rec.got())
+	})
+}
+
+type chanTransport struct{ frames chan *protocol.Frame }
+
+func (t *chanTransport) Send(context.Context, *protocol.Frame) error { return nil }
+
+func (t *chanTransport) Recv(context.Context) (*protocol.Frame, error) {
+	f, ok := <-t.frames
+	if !ok {
+		return nil, io.EOF
+	}
+	return f, nil
+}
+
+// netTransport is the goroutine-per-connection baseline: a Recv parked in
+// the Go netpoller, as a WebSocket library's read loop would be.
+type netTransport struct {
+	r *bufio.Reader
+}
+
+func (t *netTransport) Send(context.Context, *protocol.Frame) error { return nil }
+
+func (t *netTransport) Recv(context.Context) (*protocol.Frame, error) {
+	line, err := t.r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var f protocol.Frame
+	return &f, json.Unmarshal(line, &f)
+}
+
+// BenchmarkIdleReaders measures the memory held per idle connection by each
+// read scheduler: a parked goroutine (stack plus bufio buffer) against an
+// epoll registration. On linux/amd64, Go 1.25, 5,000 connections:
+//
+//	goroutine  3.5-8 KB/conn (varies with stack reuse between runs)
+//	epoll      ~0.3 KB/conn
+//
+// The gap is the goroutine stack and read buffer, which the epoll path only
+// holds while a worker is reading.
+func BenchmarkIdleReaders(b *testing.B) {
+	const conns = 5000
+
+	// measure starts conns idle readers via open and reports the memory
+	// they hold. open returns a func that closes the connection.
+	measure := func(b *testing.B, sched app.ReadScheduler, open func(ctx context.Context, sched app.ReadScheduler) func()) {
+		for b.Loop() {
+			ctx, cancel := context.WithCancel(context.Background())
+			closers := make([]func(), 0, conns)
+			before := heapAndStack()
+			for range conns {
+				closers = append(closers, open(ctx, sched))
+			}
+			b.ReportMetric(float64(int64(heapAndStack())-int64(before))/conns, "B/conn")
+			cancel()
+			for _, c := range closers {
+				c()
+			}
+		}
+	}
+	idle := func(_ *protocol.Frame, err error) bool { return err == nil }
+
+	b.Run("goroutine", func(b *testing.B) {
+		measure(b, app.GoroutineReader{}, func(ctx context.Context, sched app.ReadScheduler) func() {
+			ours, peer := net.Pipe()
+			sched.Start(ctx, &netTransport{r: bufio.NewReader(ours)}, idle)
+			return func() { _ = ours.Close(); _ = peer.Close() }
+		})
+	})
+
+	b.Run("epoll", func(b *testing.B) {
+		measure(b, runReader(b, 0, 0), func(ctx context.Context, sched app.ReadScheduler) func() {
+			fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			sched.Start(ctx, &fdTransport{fd: fds[0]}, idle)
+			return func() { _ = syscall.Close(fds[0]); _ = syscall.Close(fds[1]) }
+		})
+	})
+}
+
+func heapAndStack() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse + m.StackInuse
+}
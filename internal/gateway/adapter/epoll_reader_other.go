@@ -0,0 +1,34 @@
+//go:build !linux
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// EpollReader requires Linux. On other platforms NewEpollReader fails and
+// the Gateway keeps the default goroutine-per-connection reader.
+type EpollReader struct{}
+
+// NewEpollReader reports that epoll is unavailable on this platform.
+func NewEpollReader(int, time.Duration) (*EpollReader, error) {
+	return nil, errors.New("epoll reader requires linux")
+}
+
+// Start falls back to a goroutine per connection.
+func (*EpollReader) Start(ctx context.Context, t app.Transport, read app.ReadFunc) {
+	app.GoroutineReader{}.Start(ctx, t, read)
+}
+
+// Run returns when ctx is done.
+func (*EpollReader) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Close is a no-op.
+func (*EpollReader) Close() error { return nil }
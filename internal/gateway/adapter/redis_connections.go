@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// leaseRenewBatch bounds the leases renewed in one pipeline, so a pod
+// with 100k connections does not send one 600k-command round trip.
+const leaseRenewBatch = 1000
+
+// connectionAcquireScript evicts expired leases from each scope's sorted
+// set and, if no scope is at its limit, adds the connection to all of them.
+// A connection already in a set does not count against that set's limit.
+// Returns 0 when the lease is taken, else the 1-based index of the first
+// key at its limit.
+//
+//	ARGV[1] = now (ms), ARGV[2] = lease expiry (ms), ARGV[3] = connection ID,
+//	ARGV[4] = lease TTL (ms), ARGV[5..] = limit for each key
+const connectionAcquireScript = `
+for i = 1, #KEYS do
+  redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', ARGV[1])
+  if not redis.call('ZSCORE', KEYS[i], ARGV[3]) and redis.call('ZCARD', KEYS[i]) >= tonumber(ARGV[4 + i]) then
+    return i
+  end
+end
+for i = 1, #KEYS do
+  redis.call('ZADD', KEYS[i], ARGV[2], ARGV[3])
+  redis.call('PEXPIRE', KEYS[i], ARGV[4])
+end
+return 0
+`
+
+// Compile-time check: ConnectionLeases satisfies app.ConnectionLeaseStore.
+var _ app.ConnectionLeaseStore = (*ConnectionLeases)(nil)
+
+// ConnectionLeases is the fleet-wide connection registry (ADR-010). Each
+// quota scope is a Redis sorted set of connection IDs scored by lease
+// expiry in Unix milliseconds:
+//
+//	gateway:conns:user:<user_id>
+//	gateway:conns:device:<user_id>:<device_id>
+//	gateway:conns:ip:<client_ip>
+//
+// Each set expires with its newest lease, so an idle user leaves nothing
+// behind.
+type ConnectionLeases struct {
+	cmd   redisclient.Cmdable
+	clock domain.Clock
+}
+
+// NewConnectionLeases creates a ConnectionLeases.
+func NewConnectionLeases(cmd redisclient.Cmdable, clock domain.Clock) *ConnectionLeases {
+	return &ConnectionLeases{cmd: cmd, clock: clock}
+}
+
+// Acquire takes lease in every scope it belongs to, or returns the first
+// scope at its limit.
+func (l *ConnectionLeases) Acquire(ctx context.Context, lease app.ConnectionLease, limits app.ConnectionLimits, ttl time.Duration) (app.QuotaScope, error) {
+	ctx, span := tracer.Start(ctx, "redis.connections.acquire")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	scopes := leaseScopes(lease)
+	keys := make([]string, len(scopes))
+	now := l.clock.Now()
+	args := []any{now.UnixMilli(), now.Add(ttl).UnixMilli(), lease.ConnectionID, ttl.Milliseconds()}
+	for i, s := range scopes {
+		keys[i] = s.key
+		args = append(args, s.limit(limits))
+	}
+
+	full, err := l.cmd.Eval(ctx, connectionAcquireScript, keys, args...).Int()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("acquire connection lease %q: %w", lease.ConnectionID, err)
+	}
+	if full < 1 || full > len(scopes) {
+		return "", nil
+	}
+	return scopes[full-1].scope, nil
+}
+
+// Renew extends each lease to ttl from now. A lease missing from the
+// registry, e.g. one admitted while Redis was unreachable, is added back
+// without a limit check.
+func (l *ConnectionLeases) Renew(ctx context.Context, leases []app.ConnectionLease, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "redis.connections.renew")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "ZADD"),
+		attribute.Int("leases.count", len(leases)),
+	)
+
+	expiry := l.clock.Now().Add(ttl).UnixMilli()
+	for start := 0; start < len(leases); start += leaseRenewBatch {
+		pipe := l.cmd.Pipeline()
+		for _, lease := range leases[start:min(start+leaseRenewBatch, len(leases))] {
+			for _, s := range leaseScopes(lease) {
+				pipe.ZAdd(ctx, s.key, redis.Z{Score: float64(expiry), Member: lease.ConnectionID})
+				pipe.PExpire(ctx, s.key, ttl)
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("renew connection leases: %w", err)
+		}
+	}
+	return nil
+}
+
+// Release removes lease from every scope it belongs to.
+func (l *ConnectionLeases) Release(ctx context.Context, lease app.ConnectionLease) error {
+	ctx, span := tracer.Start(ctx, "redis.connections.release")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "ZREM"),
+	)
+
+	pipe := l.cmd.Pipeline()
+	for _, s := range leaseScopes(lease) {
+		pipe.ZRem(ctx, s.key, lease.ConnectionID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("release connection lease %q: %w", lease.ConnectionID, err)
+	}
+	return nil
+}
+
+// leaseScope is one sorted set a lease is counted in.
+type leaseScope struct {
+	scope app.QuotaScope
+	key   string
+}
+
+func (s leaseScope) limit(limits app.ConnectionLimits) int {
+	switch s.scope {
+	case app.QuotaScopeDevice:
+		return limits.PerDevice
+	case app.QuotaScopeIP:
+		return limits.PerIP
+	default:
+		return limits.PerUser
+	}
+}
+
+// leaseScopes returns the sets lease is counted in, in the order quotas
+// are checked. Empty device and address fields have no set.
+func leaseScopes(lease app.ConnectionLease) []leaseScope {
+	scopes := []leaseScope{{app.QuotaScopeUser, "gateway:conns:user:" + lease.UserID}}
+	if lease.DeviceID != "" {
+		scopes = append(scopes, leaseScope{app.QuotaScopeDevice, "gateway:conns:device:" + lease.UserID + ":" + lease.DeviceID})
+	}
+	if lease.ClientIP != "" {
+		scopes = append(scopes, leaseScope{app.QuotaScopeIP, "gateway:conns:ip:" + lease.ClientIP})
+	}
+	return scopes
+}
@@ -0,0 +1,141 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestConnectionLeases(t *testing.T) (*adapter.ConnectionLeases, *domaintest.FakeClock, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	clock := domaintest.NewFakeClock(testStart)
+	return adapter.NewConnectionLeases(client.RDB, clock), clock, mr
+}
+
+func connLease(id, device, ip string) app.ConnectionLease {
+	return app.ConnectionLease{ConnectionID: id, UserID: "user-1", DeviceID: device, ClientIP: ip}
+}
+
+func TestConnectionLeases_Acquire(t *testing.T) {
+	limits := app.ConnectionLimits{PerUser: 3, PerDevice: 1, PerIP: 2}
+	ctx := context.Background()
+
+	acquire := func(t *testing.T, l *adapter.ConnectionLeases, lease app.ConnectionLease) app.QuotaScope {
+		t.Helper()
+		scope, err := l.Acquire(ctx, lease, limits, time.Minute)
+		require.NoError(t, err)
+		return scope
+	}
+
+	t.Run("reports the first scope at its limit", func(t *testing.T) {
+		l, _, _ := newTestConnectionLeases(t)
+
+		assert.Empty(t, acquire(t, l, connLease("conn-1", "device-a", "10.0.0.1")))
+		assert.Equal(t, app.QuotaScopeDevice, acquire(t, l, connLease("conn-2", "device-a", "10.0.0.2")))
+		assert.Empty(t, acquire(t, l, connLease("conn-3", "device-b", "10.0.0.1")))
+		assert.Equal(t, app.QuotaScopeIP, acquire(t, l, connLease("conn-4", "device-c", "10.0.0.1")))
+		assert.Empty(t, acquire(t, l, connLease("conn-5", "device-c", "")))
+		assert.Equal(t, app.QuotaScopeUser, acquire(t, l, connLease("conn-6", "device-d", "10.0.0.3")))
+	})
+
+	t.Run("a refused connection takes no slot", func(t *testing.T) {
+		l, _, mr := newTestConnectionLeases(t)
+
+		acquire(t, l, connLease("conn-1", "device-a", "10.0.0.1"))
+		require.Equal(t, app.QuotaScopeDevice, acquire(t, l, connLease("conn-2", "device-a", "10.0.0.1")))
+
+		members, err := mr.ZMembers("gateway:conns:user:user-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"conn-1"}, members)
+	})
+
+	t.Run("expired leases free their slot", func(t *testing.T) {
+		l, clock, _ := newTestConnectionLeases(t)
+
+		acquire(t, l, connLease("conn-1", "device-a", ""))
+		clock.Advance(2 * time.Minute)
+
+		assert.Empty(t, acquire(t, l, connLease("conn-2", "device-a", "")))
+	})
+
+	t.Run("sets expire with their newest lease", func(t *testing.T) {
+		l, _, mr := newTestConnectionLeases(t)
+
+		acquire(t, l, connLease("conn-1", "device-a", "10.0.0.1"))
+
+		assert.Equal(t, time.Minute, mr.TTL("gateway:conns:user:user-1"))
+		assert.Equal(t, time.Minute, mr.TTL("gateway:conns:device:user-1:device-a"))
+		assert.Equal(t, time.Minute, mr.TTL("gateway:conns:ip:10.0.0.1"))
+	})
+
+	t.Run("reports redis failure", func(t *testing.T) {
+		l, _, mr := newTestConnectionLeases(t)
+		mr.Close()
+
+		_, err := l.Acquire(ctx, connLease("conn-1", "device-a", ""), limits, time.Minute)
+		assert.Error(t, err)
+	})
+}
+
+func TestConnectionLeases_RenewAndRelease(t *testing.T) {
+	limits := app.ConnectionLimits{PerUser: 1, PerDevice: 1, PerIP: 1}
+	ctx := context.Background()
+
+	t.Run("renewed leases outlive their first TTL", func(t *testing.T) {
+		l, clock, _ := newTestConnectionLeases(t)
+		lease := connLease("conn-1", "device-a", "10.0.0.1")
+		_, err := l.Acquire(ctx, lease, limits, time.Minute)
+		require.NoError(t, err)
+
+		clock.Advance(45 * time.Second)
+		require.NoError(t, l.Renew(ctx, []app.ConnectionLease{lease}, time.Minute))
+		clock.Advance(45 * time.Second)
+
+		scope, err := l.Acquire(ctx, connLease("conn-2", "device-b", "10.0.0.2"), limits, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, app.QuotaScopeUser, scope)
+	})
+
+	t.Run("renewal re-registers a missing lease", func(t *testing.T) {
+		l, _, mr := newTestConnectionLeases(t)
+
+		require.NoError(t, l.Renew(ctx, []app.ConnectionLease{connLease("conn-1", "device-a", "")}, time.Minute))
+
+		members, err := mr.ZMembers("gateway:conns:device:user-1:device-a")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"conn-1"}, members)
+	})
+
+	t.Run("release frees every scope", func(t *testing.T) {
+		l, _, _ := newTestConnectionLeases(t)
+		lease := connLease("conn-1", "device-a", "10.0.0.1")
+		_, err := l.Acquire(ctx, lease, limits, time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, l.Release(ctx, lease))
+
+		scope, err := l.Acquire(ctx, connLease("conn-2", "device-a", "10.0.0.1"), limits, time.Minute)
+		require.NoError(t, err)
+		assert.Empty(t, scope)
+	})
+}
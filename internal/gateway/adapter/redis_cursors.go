@@ -0,0 +1,102 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// cursorAdvanceScript sets a chat's field in a device's cursor hash unless
+// it already holds a later sequence, and extends the hash's TTL.
+//
+//	ARGV[1] = chat ID, ARGV[2] = sequence, ARGV[3] = TTL (ms)
+const cursorAdvanceScript = `
+local cur = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if tonumber(ARGV[2]) > cur then
+  redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`
+
+// Compile-time check: CursorCache satisfies app.CursorStore.
+var _ app.CursorStore = (*CursorCache)(nil)
+
+// CursorCache keeps live delivery cursors in one Redis hash per device,
+// keyed gateway:cursors:<user_id>:<device_id> with a field per chat. A
+// device's hash expires ttl after its last write.
+type CursorCache struct {
+	cmd redisclient.Cmdable
+	ttl time.Duration
+}
+
+// NewCursorCache creates a CursorCache. Zero ttl defaults to
+// domain.DeliveryCursorTTL.
+func NewCursorCache(cmd redisclient.Cmdable, ttl time.Duration) *CursorCache {
+	if ttl <= 0 {
+		ttl = domain.DeliveryCursorTTL
+	}
+	return &CursorCache{cmd: cmd, ttl: ttl}
+}
+
+// SaveCursors moves each cursor forward in one pipeline.
+func (c *CursorCache) SaveCursors(ctx context.Context, batch []app.DeliveryCursor) error {
+	ctx, span := tracer.Start(ctx, "redis.cursors.save")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+		attribute.Int("cursors.count", len(batch)),
+	)
+
+	pipe := c.cmd.Pipeline()
+	for _, cur := range batch {
+		pipe.Eval(ctx, cursorAdvanceScript, []string{cursorKey(cur.UserID, cur.DeviceID)},
+			cur.ChatID, cur.Sequence, c.ttl.Milliseconds())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("save %d delivery cursors: %w", len(batch), err)
+	}
+	return nil
+}
+
+// GetCursor returns the device's cursor in the chat.
+func (c *CursorCache) GetCursor(ctx context.Context, userID, deviceID, chatID string) (uint64, bool, error) {
+	ctx, span := tracer.Start(ctx, "redis.cursors.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "HGET"),
+	)
+
+	raw, err := c.cmd.HGet(ctx, cursorKey(userID, deviceID), chatID).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, false, fmt.Errorf("get delivery cursor: %w", err)
+	}
+	seq, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("parse delivery cursor %q: %w", raw, err)
+	}
+	return seq, true, nil
+}
+
+func cursorKey(userID, deviceID string) string {
+	return "gateway:cursors:" + userID + ":" + deviceID
+}
@@ -0,0 +1,77 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestCursorCache(t *testing.T) (*adapter.CursorCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	return adapter.NewCursorCache(client.RDB, time.Hour), mr
+}
+
+func TestCursorCache(t *testing.T) {
+	ctx := context.Background()
+	cursor := func(chatID string, seq uint64) app.DeliveryCursor {
+		return app.DeliveryCursor{UserID: "user-001", DeviceID: "device-001", ChatID: chatID, Sequence: seq}
+	}
+
+	t.Run("cursors only move forward", func(t *testing.T) {
+		cache, _ := newTestCursorCache(t)
+
+		require.NoError(t, cache.SaveCursors(ctx, []app.DeliveryCursor{cursor("chat-1", 5), cursor("chat-2", 1)}))
+		require.NoError(t, cache.SaveCursors(ctx, []app.DeliveryCursor{cursor("chat-1", 3)}))
+
+		seq, ok, err := cache.GetCursor(ctx, "user-001", "device-001", "chat-1")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(5), seq)
+	})
+
+	t.Run("unknown device or chat", func(t *testing.T) {
+		cache, _ := newTestCursorCache(t)
+		require.NoError(t, cache.SaveCursors(ctx, []app.DeliveryCursor{cursor("chat-1", 5)}))
+
+		_, ok, err := cache.GetCursor(ctx, "user-001", "device-002", "chat-1")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		_, ok, err = cache.GetCursor(ctx, "user-001", "device-001", "chat-2")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("device hash expires after its last write", func(t *testing.T) {
+		cache, mr := newTestCursorCache(t)
+		require.NoError(t, cache.SaveCursors(ctx, []app.DeliveryCursor{cursor("chat-1", 5)}))
+
+		assert.Equal(t, time.Hour, mr.TTL("gateway:cursors:user-001:device-001"))
+	})
+
+	t.Run("redis failure", func(t *testing.T) {
+		cache, mr := newTestCursorCache(t)
+		mr.Close()
+
+		require.Error(t, cache.SaveCursors(ctx, []app.DeliveryCursor{cursor("chat-1", 5)}))
+		_, _, err := cache.GetCursor(ctx, "user-001", "device-001", "chat-1")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// deliveryMessage is the JSON Fanout publishes on a Gateway's delivery
+// channel (ADR-002 §3.4): a "deliver" with a message or a "signal" with an
+// activity signal's frame. The trace context continues Fanout's trace.
+type deliveryMessage struct {
+	Type        string          `json:"type"` // "deliver" or "signal"
+	UserIDs     []string        `json:"user_ids"`
+	Message     json.RawMessage `json:"message"` // a protocol.Message
+	Frame       *protocol.Frame `json:"frame"`
+	TraceParent string          `json:"traceparent,omitempty"`
+	TraceState  string          `json:"tracestate,omitempty"`
+}
+
+// deliverySubscriber is the subset of the Redis client DeliverySubscriber
+// needs. The *redis.Client satisfies this interface.
+type deliverySubscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// DeliverySubscriber receives the deliveries Fanout publishes to this
+// Gateway instance on domain.GatewayDeliverChannel. Redis Pub/Sub is fire
+// and forget: deliveries published while the subscription is down, e.g.
+// during a Redis failover, are lost and their recipients catch up with
+// sync.
+type DeliverySubscriber struct {
+	client  deliverySubscriber
+	channel string
+	logger  *slog.Logger
+}
+
+// NewDeliverySubscriber creates a DeliverySubscriber for instanceID's
+// channel.
+func NewDeliverySubscriber(client deliverySubscriber, instanceID string, logger *slog.Logger) *DeliverySubscriber {
+	return &DeliverySubscriber{
+		client:  client,
+		channel: domain.GatewayDeliverChannel(instanceID),
+		logger:  logger,
+	}
+}
+
+// Run passes every delivery received to deliver until ctx is cancelled,
+// keeping the message's encoding from Fanout as its payload and a signal's
+// frame as sent. The client
+// resubscribes on its own after a lost connection. Malformed deliveries
+// are logged and skipped.
+func (s *DeliverySubscriber) Run(ctx context.Context, deliver func(context.Context, app.Delivery)) {
+	sub := s.client.Subscribe(ctx, s.channel)
+	defer func() { _ = sub.Close() }()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var d deliveryMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &d); err != nil {
+				s.logger.WarnContext(ctx, "skipping malformed delivery", "channel", s.channel, "error", err)
+				continue
+			}
+			delivery := app.Delivery{UserIDs: d.UserIDs}
+			if d.Type == "signal" {
+				if d.Frame == nil || d.Frame.Type == "" {
+					s.logger.WarnContext(ctx, "skipping malformed delivery", "channel", s.channel, "error", "signal without a frame")
+					continue
+				}
+				delivery.Signal = d.Frame
+			} else {
+				if err := json.Unmarshal(d.Message, &delivery.Message); err != nil {
+					s.logger.WarnContext(ctx, "skipping malformed delivery", "channel", s.channel, "error", err)
+					continue
+				}
+				delivery.Payload = d.Message
+			}
+			carrier := propagation.MapCarrier{}
+			if d.TraceParent != "" {
+				carrier["traceparent"] = d.TraceParent
+			}
+			if d.TraceState != "" {
+				carrier["tracestate"] = d.TraceState
+			}
+			deliver(otel.GetTextMapPropagator().Extract(ctx, carrier), delivery)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package adapter_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestDeliverySubscriber(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{Addr: mr.Addr()})
+	t.Cleanup(func() { require.NoError(t, client.Close()) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan app.Delivery, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		adapter.NewDeliverySubscriber(client.RDB, "gw-1", slog.Default()).Run(ctx, func(_ context.Context, d app.Delivery) {
+			got <- d
+		})
+	}()
+	require.Eventually(t, func() bool {
+		return client.RDB.PubSubNumSub(context.Background(), "gateway:gw-1:deliver").Val()["gateway:gw-1:deliver"] == 1
+	}, time.Second, 5*time.Millisecond)
+
+	const message = `{"message_id":"msg-1","chat_id":"chat-1","sender_id":"user-2","client_message_id":"c-1","sequence":4,"content_type":"text","content":"hi","created_at":1700000000000}`
+	mr.Publish("gateway:gw-1:deliver", "not json")
+	mr.Publish("gateway:gw-1:deliver", `{"type":"deliver","user_ids":["user-1"],"message":"not a message"}`)
+	mr.Publish("gateway:gw-1:deliver", `{"type":"deliver","user_ids":["user-1"],"message":`+message+`}`)
+
+	select {
+	case d := <-got:
+		assert.Equal(t, app.Delivery{
+			UserIDs: []string{"user-1"},
+			Message: protocol.Message{
+				MessageID: "msg-1", ChatID: "chat-1", SenderID: "user-2", ClientMessageID: "c-1",
+				Sequence: 4, ContentType: "text", Content: "hi", CreatedAt: 1700000000000,
+			},
+			Payload: json.RawMessage(message),
+		}, d, "malformed deliveries are skipped; the message keeps its encoding")
+	case <-time.After(time.Second):
+		t.Fatal("no delivery received")
+	}
+
+	mr.Publish("gateway:gw-1:deliver", `{"type":"signal","user_ids":["user-1"]}`)
+	mr.Publish("gateway:gw-1:deliver", `{"type":"signal","user_ids":["user-1"],"frame":{"type":"typing","payload":{"chat_id":"chat-1","user_id":"user-2","active":true}}}`)
+
+	select {
+	case d := <-got:
+		assert.Equal(t, []string{"user-1"}, d.UserIDs)
+		require.NotNil(t, d.Signal, "a signal without a frame is skipped")
+		assert.Equal(t, protocol.FrameTypeTyping, d.Signal.Type)
+		assert.JSONEq(t, `{"chat_id":"chat-1","user_id":"user-2","active":true}`, string(d.Signal.Payload))
+	case <-time.After(time.Second):
+		t.Fatal("no signal received")
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,94 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// drainSlotsKey is the sorted set of current drain slot holders, scored by
+// lease expiry in Unix milliseconds.
+const drainSlotsKey = "gateway:drain_slots"
+
+// drainAcquireScript atomically evicts expired leases and grants a slot if
+// fewer than ARGV[4] holders remain. Re-acquiring a held slot extends its
+// lease. The key itself expires with the newest lease so an abandoned set
+// does not linger.
+//
+//	ARGV[1] = now (ms), ARGV[2] = lease expiry (ms), ARGV[3] = holder,
+//	ARGV[4] = slots, ARGV[5] = lease TTL (ms)
+const drainAcquireScript = `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if not redis.call('ZSCORE', KEYS[1], ARGV[3]) and redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[4]) then
+  return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`
+
+// Compile-time check: DrainSemaphore satisfies app.DrainSemaphore.
+var _ app.DrainSemaphore = (*DrainSemaphore)(nil)
+
+// DrainSemaphore is a fleet-wide counting semaphore backed by a Redis
+// sorted set. Leases expire, so a pod killed mid-drain frees its slot
+// within the lease TTL.
+type DrainSemaphore struct {
+	cmd   redisclient.Cmdable
+	slots int
+	clock domain.Clock
+}
+
+// NewDrainSemaphore creates a DrainSemaphore allowing slots concurrent holders.
+func NewDrainSemaphore(cmd redisclient.Cmdable, slots int, clock domain.Clock) *DrainSemaphore {
+	return &DrainSemaphore{cmd: cmd, slots: slots, clock: clock}
+}
+
+// TryAcquire grants holder a slot for ttl if one is free. Returns (true, nil)
+// when the slot is held, (false, nil) when all slots are taken, and
+// (false, err) on Redis failure.
+func (s *DrainSemaphore) TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	ctx, span := tracer.Start(ctx, "redis.drain.acquire")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	now := s.clock.Now()
+	granted, err := s.cmd.Eval(ctx, drainAcquireScript, []string{drainSlotsKey},
+		now.UnixMilli(), now.Add(ttl).UnixMilli(), holder, s.slots, ttl.Milliseconds(),
+	).Int64()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return false, fmt.Errorf("acquire drain slot %q: %w", holder, err)
+	}
+
+	return granted == 1, nil
+}
+
+// Release gives up holder's slot. Releasing a slot that is not held is a no-op.
+func (s *DrainSemaphore) Release(ctx context.Context, holder string) error {
+	ctx, span := tracer.Start(ctx, "redis.drain.release")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "ZREM"),
+	)
+
+	if err := s.cmd.ZRem(ctx, drainSlotsKey, holder).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("release drain slot %q: %w", holder, err)
+	}
+
+	return nil
+}
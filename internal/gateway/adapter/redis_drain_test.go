@@ -0,0 +1,120 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+var testStart = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func newTestDrainSemaphore(t *testing.T, slots int) (*adapter.DrainSemaphore, *domaintest.FakeClock, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	clock := domaintest.NewFakeClock(testStart)
+	return adapter.NewDrainSemaphore(client.RDB, slots, clock), clock, mr
+}
+
+func TestDrainSemaphore_TryAcquire(t *testing.T) {
+	t.Run("grants up to the slot count", func(t *testing.T) {
+		sem, _, _ := newTestDrainSemaphore(t, 2)
+		ctx := context.Background()
+
+		for _, holder := range []string{"pod-a", "pod-b"} {
+			ok, err := sem.TryAcquire(ctx, holder, time.Minute)
+			require.NoError(t, err)
+			assert.True(t, ok, "%s should get a slot", holder)
+		}
+
+		ok, err := sem.TryAcquire(ctx, "pod-c", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, ok, "third holder should wait")
+	})
+
+	t.Run("re-acquiring a held slot succeeds when full", func(t *testing.T) {
+		sem, _, _ := newTestDrainSemaphore(t, 1)
+		ctx := context.Background()
+
+		ok, err := sem.TryAcquire(ctx, "pod-a", time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = sem.TryAcquire(ctx, "pod-a", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("expired leases free their slot", func(t *testing.T) {
+		sem, clock, _ := newTestDrainSemaphore(t, 1)
+		ctx := context.Background()
+
+		ok, err := sem.TryAcquire(ctx, "pod-a", time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		clock.Advance(time.Minute + time.Millisecond)
+
+		ok, err = sem.TryAcquire(ctx, "pod-b", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok, "crashed holder's lease should have expired")
+	})
+
+	t.Run("sets TTL on the key", func(t *testing.T) {
+		sem, _, mr := newTestDrainSemaphore(t, 1)
+
+		_, err := sem.TryAcquire(context.Background(), "pod-a", 30*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, 30*time.Second, mr.TTL("gateway:drain_slots"))
+	})
+
+	t.Run("returns error when Redis is unavailable", func(t *testing.T) {
+		sem, _, mr := newTestDrainSemaphore(t, 1)
+		mr.Close()
+
+		ok, err := sem.TryAcquire(context.Background(), "pod-a", time.Minute)
+		require.Error(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestDrainSemaphore_Release(t *testing.T) {
+	t.Run("frees the slot for the next holder", func(t *testing.T) {
+		sem, _, _ := newTestDrainSemaphore(t, 1)
+		ctx := context.Background()
+
+		ok, err := sem.TryAcquire(ctx, "pod-a", time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.NoError(t, sem.Release(ctx, "pod-a"))
+
+		ok, err = sem.TryAcquire(ctx, "pod-b", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("releasing an unheld slot is a no-op", func(t *testing.T) {
+		sem, _, _ := newTestDrainSemaphore(t, 1)
+
+		assert.NoError(t, sem.Release(context.Background(), "pod-a"))
+	})
+}
@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// Compile-time check: RouteTable satisfies app.RouteStore.
+var _ app.RouteStore = (*RouteTable)(nil)
+
+// RouteTable is the fleet-wide delivery routing table (ADR-010 §1.2).
+// Each user's routes are a Redis sorted set of Gateway instance IDs scored
+// by route expiry in Unix milliseconds, at domain.UserServersKey. Fanout
+// reads it; expired members are dropped on the next advertise, and each
+// set expires with its newest route.
+type RouteTable struct {
+	cmd   redisclient.Cmdable
+	clock domain.Clock
+}
+
+// NewRouteTable creates a RouteTable.
+func NewRouteTable(cmd redisclient.Cmdable, clock domain.Clock) *RouteTable {
+	return &RouteTable{cmd: cmd, clock: clock}
+}
+
+// Advertise routes each user's messages to instanceID for ttl from now.
+func (t *RouteTable) Advertise(ctx context.Context, instanceID string, userIDs []string, ttl time.Duration) error {
+	ctx, span := tracer.Start(ctx, "redis.routes.advertise")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "ZADD"),
+		attribute.Int("routes.count", len(userIDs)),
+	)
+
+	now := t.clock.Now()
+	expiry := now.Add(ttl).UnixMilli()
+	for start := 0; start < len(userIDs); start += leaseRenewBatch {
+		pipe := t.cmd.Pipeline()
+		for _, userID := range userIDs[start:min(start+leaseRenewBatch, len(userIDs))] {
+			key := domain.UserServersKey(userID)
+			pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+			pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiry), Member: instanceID})
+			pipe.PExpire(ctx, key, ttl)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("advertise routes: %w", err)
+		}
+	}
+	return nil
+}
+
+// Withdraw stops routing userID's messages to instanceID.
+func (t *RouteTable) Withdraw(ctx context.Context, instanceID, userID string) error {
+	ctx, span := tracer.Start(ctx, "redis.routes.withdraw")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "ZREM"),
+	)
+
+	if err := t.cmd.ZRem(ctx, domain.UserServersKey(userID), instanceID).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("withdraw route for %q: %w", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestRouteTable(t *testing.T) (*adapter.RouteTable, *domaintest.FakeClock, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	clock := domaintest.NewFakeClock(testStart)
+	return adapter.NewRouteTable(client.RDB, clock), clock, mr
+}
+
+func TestRouteTable(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("advertises each user scored by expiry", func(t *testing.T) {
+		routes, _, mr := newTestRouteTable(t)
+
+		require.NoError(t, routes.Advertise(ctx, "gw-1", []string{"user-1", "user-2"}, time.Minute))
+		require.NoError(t, routes.Advertise(ctx, "gw-2", []string{"user-1"}, time.Minute))
+
+		members, err := mr.ZMembers("user_servers:user-1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"gw-1", "gw-2"}, members)
+		score, err := mr.ZScore("user_servers:user-2", "gw-1")
+		require.NoError(t, err)
+		assert.Equal(t, float64(testStart.Add(time.Minute).UnixMilli()), score)
+		assert.Equal(t, time.Minute, mr.TTL("user_servers:user-2"))
+	})
+
+	t.Run("drops expired routes when advertising", func(t *testing.T) {
+		routes, clock, mr := newTestRouteTable(t)
+		require.NoError(t, routes.Advertise(ctx, "gw-dead", []string{"user-1"}, time.Minute))
+
+		clock.Advance(2 * time.Minute)
+		require.NoError(t, routes.Advertise(ctx, "gw-1", []string{"user-1"}, time.Minute))
+
+		members, err := mr.ZMembers("user_servers:user-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gw-1"}, members)
+	})
+
+	t.Run("withdraw removes only this instance", func(t *testing.T) {
+		routes, _, mr := newTestRouteTable(t)
+		require.NoError(t, routes.Advertise(ctx, "gw-1", []string{"user-1"}, time.Minute))
+		require.NoError(t, routes.Advertise(ctx, "gw-2", []string{"user-1"}, time.Minute))
+
+		require.NoError(t, routes.Withdraw(ctx, "gw-1", "user-1"))
+
+		members, err := mr.ZMembers("user_servers:user-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gw-2"}, members)
+	})
+}
@@ -0,0 +1,103 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// Compile-time check: UploadSessions satisfies app.UploadSessionStore.
+var _ app.UploadSessionStore = (*UploadSessions)(nil)
+
+// UploadSessions keeps attachment upload sessions as JSON strings keyed
+// gateway:uploads:<upload_id>. Each expires ttl after its last save.
+type UploadSessions struct {
+	cmd redisclient.Cmdable
+	ttl time.Duration
+}
+
+// NewUploadSessions creates an UploadSessions. Zero ttl defaults to
+// domain.UploadSessionTTL.
+func NewUploadSessions(cmd redisclient.Cmdable, ttl time.Duration) *UploadSessions {
+	if ttl <= 0 {
+		ttl = domain.UploadSessionTTL
+	}
+	return &UploadSessions{cmd: cmd, ttl: ttl}
+}
+
+// SaveUpload writes s and restarts its TTL.
+func (u *UploadSessions) SaveUpload(ctx context.Context, s app.UploadSession) error {
+	ctx, span := tracer.Start(ctx, "redis.uploads.save")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "SET"),
+	)
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encode upload %s: %w", s.UploadID, err)
+	}
+	if err := u.cmd.Set(ctx, uploadKey(s.UploadID), raw, u.ttl).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("save upload %s: %w", s.UploadID, err)
+	}
+	return nil
+}
+
+// GetUpload returns the session, or false if it is unknown or expired.
+func (u *UploadSessions) GetUpload(ctx context.Context, uploadID string) (app.UploadSession, bool, error) {
+	ctx, span := tracer.Start(ctx, "redis.uploads.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "GET"),
+	)
+
+	raw, err := u.cmd.Get(ctx, uploadKey(uploadID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return app.UploadSession{}, false, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.UploadSession{}, false, fmt.Errorf("get upload %s: %w", uploadID, err)
+	}
+	var s app.UploadSession
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return app.UploadSession{}, false, fmt.Errorf("decode upload %s: %w", uploadID, err)
+	}
+	return s, true, nil
+}
+
+// DeleteUpload removes the session.
+func (u *UploadSessions) DeleteUpload(ctx context.Context, uploadID string) error {
+	ctx, span := tracer.Start(ctx, "redis.uploads.delete")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "DEL"),
+	)
+
+	if err := u.cmd.Del(ctx, uploadKey(uploadID)).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("delete upload %s: %w", uploadID, err)
+	}
+	return nil
+}
+
+func uploadKey(uploadID string) string {
+	return "gateway:uploads:" + uploadID
+}
@@ -0,0 +1,86 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestUploadSessions(t *testing.T) (*adapter.UploadSessions, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	return adapter.NewUploadSessions(client.RDB, time.Hour), mr
+}
+
+func TestUploadSessions(t *testing.T) {
+	ctx := context.Background()
+	session := app.UploadSession{
+		UploadID:    "upload-1",
+		UserID:      "user-1",
+		ChatID:      "chat-1",
+		Key:         "attachments/chat-1/upload-1",
+		MultipartID: "mp-1",
+		ContentType: "image/png",
+		Size:        1024,
+		SHA256:      "ab",
+		Offset:      512,
+		Parts:       []app.UploadedPart{{Number: 1, ETag: `"etag-1"`}},
+		HashState:   []byte{1, 2, 3},
+	}
+
+	t.Run("round-trips a saved session", func(t *testing.T) {
+		s, mr := newTestUploadSessions(t)
+		require.NoError(t, s.SaveUpload(ctx, session))
+
+		got, ok, err := s.GetUpload(ctx, "upload-1")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, session, got)
+		assert.Equal(t, time.Hour, mr.TTL("gateway:uploads:upload-1"))
+	})
+
+	t.Run("unknown and deleted sessions are not found", func(t *testing.T) {
+		s, _ := newTestUploadSessions(t)
+		require.NoError(t, s.SaveUpload(ctx, session))
+		require.NoError(t, s.DeleteUpload(ctx, "upload-1"))
+
+		_, ok, err := s.GetUpload(ctx, "upload-1")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("sessions expire", func(t *testing.T) {
+		s, mr := newTestUploadSessions(t)
+		require.NoError(t, s.SaveUpload(ctx, session))
+		mr.FastForward(2 * time.Hour)
+
+		_, ok, err := s.GetUpload(ctx, "upload-1")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("reports redis failure", func(t *testing.T) {
+		s, mr := newTestUploadSessions(t)
+		mr.Close()
+
+		_, _, err := s.GetUpload(ctx, "upload-1")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// memoryTotalMetric is all memory mapped by the Go runtime, the figure that
+// tracks the container's resident set most closely.
+const memoryTotalMetric = "/memory/classes/total:bytes"
+
+// Compile-time check: RuntimeSampler satisfies app.PressureSampler.
+var _ app.PressureSampler = (*RuntimeSampler)(nil)
+
+// RuntimeSampler reads process pressure from the Go runtime and the OS.
+// CPU is the process CPU time consumed since the previous sample, as a
+// fraction of GOMAXPROCS over the elapsed wall time.
+type RuntimeSampler struct {
+	mu       sync.Mutex
+	lastWall time.Time
+	lastCPU  time.Duration
+	samples  []metrics.Sample
+}
+
+// NewRuntimeSampler creates a RuntimeSampler.
+func NewRuntimeSampler() *RuntimeSampler {
+	return &RuntimeSampler{
+		lastWall: time.Now(),
+		lastCPU:  processCPUTime(),
+		samples:  []metrics.Sample{{Name: memoryTotalMetric}},
+	}
+}
+
+// Sample returns current pressure. Safe for concurrent use.
+func (s *RuntimeSampler) Sample() app.Pressure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now, cpu := time.Now(), processCPUTime()
+	var cpuFraction float64
+	if wall := now.Sub(s.lastWall); wall > 0 {
+		cpuFraction = float64(cpu-s.lastCPU) / (float64(wall) * float64(runtime.GOMAXPROCS(0)))
+	}
+	s.lastWall, s.lastCPU = now, cpu
+
+	metrics.Read(s.samples)
+	var memory uint64
+	if v := s.samples[0].Value; v.Kind() == metrics.KindUint64 {
+		memory = v.Uint64()
+	}
+
+	return app.Pressure{
+		CPU:         min(max(cpuFraction, 0), 1),
+		MemoryBytes: memory,
+		Goroutines:  runtime.NumGoroutine(),
+	}
+}
@@ -0,0 +1,27 @@
+package adapter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+)
+
+func TestRuntimeSampler_Sample(t *testing.T) {
+	s := adapter.NewRuntimeSampler()
+
+	// Burn a little CPU so the delta is non-trivial.
+	x := 0
+	for i := range 5_000_000 {
+		x += i
+	}
+	_ = x
+
+	p := s.Sample()
+
+	assert.GreaterOrEqual(t, p.CPU, 0.0)
+	assert.LessOrEqual(t, p.CPU, 1.0)
+	assert.Positive(t, p.MemoryBytes)
+	assert.Positive(t, p.Goroutines)
+}
@@ -0,0 +1,141 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// s3MultipartAPI is a narrow, consumer-defined interface for the subset of
+// S3 operations required by S3Attachments. The real *s3.Client satisfies
+// it.
+type s3MultipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// Compile-time check: S3Attachments satisfies app.MultipartStore.
+var _ app.MultipartStore = (*S3Attachments)(nil)
+
+// S3Attachments stores uploaded attachments in one bucket as S3 multipart
+// uploads. Parts of abandoned uploads are left to the bucket's
+// AbortIncompleteMultipartUpload lifecycle rule.
+type S3Attachments struct {
+	client s3MultipartAPI
+	bucket string
+}
+
+// NewS3Attachments creates an S3Attachments for bucket.
+func NewS3Attachments(client s3MultipartAPI, bucket string) *S3Attachments {
+	return &S3Attachments{client: client, bucket: bucket}
+}
+
+// CreateMultipartUpload starts a multipart upload of key and returns its
+// upload ID.
+func (s *S3Attachments) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	ctx, span := startS3Span(ctx, "s3.attachments.create", "CreateMultipartUpload")
+	defer span.End()
+
+	out, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err == nil && aws.ToString(out.UploadId) == "" {
+		err = fmt.Errorf("response has no UploadId")
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("s3 attachments: create %s: %w", key, err)
+	}
+	return *out.UploadId, nil
+}
+
+// UploadPart stores data as part number of the upload and returns its ETag.
+func (s *S3Attachments) UploadPart(ctx context.Context, key, multipartID string, number int, data []byte) (string, error) {
+	ctx, span := startS3Span(ctx, "s3.attachments.upload_part", "UploadPart")
+	defer span.End()
+	span.SetAttributes(attribute.Int("s3.part_number", number), attribute.Int("s3.part_bytes", len(data)))
+
+	out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(multipartID),
+		PartNumber:    aws.Int32(int32(number)),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err == nil && aws.ToString(out.ETag) == "" {
+		err = fmt.Errorf("response has no ETag")
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("s3 attachments: upload %s part %d: %w", key, number, err)
+	}
+	return *out.ETag, nil
+}
+
+// CompleteMultipartUpload assembles parts into the object.
+func (s *S3Attachments) CompleteMultipartUpload(ctx context.Context, key, multipartID string, parts []app.UploadedPart) error {
+	ctx, span := startS3Span(ctx, "s3.attachments.complete", "CompleteMultipartUpload")
+	defer span.End()
+
+	completed := make([]s3types.CompletedPart, len(parts))
+	for i, p := range parts {
+		completed[i] = s3types.CompletedPart{PartNumber: aws.Int32(int32(p.Number)), ETag: aws.String(p.ETag)}
+	}
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(multipartID),
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("s3 attachments: complete %s: %w", key, err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards the upload's parts.
+func (s *S3Attachments) AbortMultipartUpload(ctx context.Context, key, multipartID string) error {
+	ctx, span := startS3Span(ctx, "s3.attachments.abort", "AbortMultipartUpload")
+	defer span.End()
+
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(multipartID),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("s3 attachments: abort %s: %w", key, err)
+	}
+	return nil
+}
+
+// startS3Span starts the span for one S3 API call.
+func startS3Span(ctx context.Context, name, method string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name)
+	span.SetAttributes(
+		attribute.String("rpc.system", "aws-api"),
+		attribute.String("rpc.service", "S3"),
+		attribute.String("rpc.method", method),
+	)
+	return ctx, span
+}
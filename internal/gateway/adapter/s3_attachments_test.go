@@ -0,0 +1,116 @@
+package adapter_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/adapter"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// recordingS3 records the last input of each multipart call and fails
+// every call with err.
+type recordingS3 struct {
+	create   *s3.CreateMultipartUploadInput
+	part     *s3.UploadPartInput
+	partBody []byte
+	complete *s3.CompleteMultipartUploadInput
+	abort    *s3.AbortMultipartUploadInput
+	err      error
+}
+
+func (r *recordingS3) CreateMultipartUpload(_ context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	r.create = in
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("mp-1")}, nil
+}
+
+func (r *recordingS3) UploadPart(_ context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	r.part = in
+	r.partBody, _ = io.ReadAll(in.Body)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &s3.UploadPartOutput{ETag: aws.String(`"etag-2"`)}, nil
+}
+
+func (r *recordingS3) CompleteMultipartUpload(_ context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	r.complete = in
+	return &s3.CompleteMultipartUploadOutput{}, r.err
+}
+
+func (r *recordingS3) AbortMultipartUpload(_ context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	r.abort = in
+	return &s3.AbortMultipartUploadOutput{}, r.err
+}
+
+func TestS3Attachments(t *testing.T) {
+	ctx := context.Background()
+	const key = "attachments/chat-1/upload-1"
+
+	t.Run("creates a multipart upload", func(t *testing.T) {
+		api := &recordingS3{}
+
+		id, err := adapter.NewS3Attachments(api, "attachments-bucket").CreateMultipartUpload(ctx, key, "image/png")
+
+		require.NoError(t, err)
+		assert.Equal(t, "mp-1", id)
+		assert.Equal(t, "attachments-bucket", aws.ToString(api.create.Bucket))
+		assert.Equal(t, key, aws.ToString(api.create.Key))
+		assert.Equal(t, "image/png", aws.ToString(api.create.ContentType))
+	})
+
+	t.Run("uploads a part and returns its ETag", func(t *testing.T) {
+		api := &recordingS3{}
+
+		etag, err := adapter.NewS3Attachments(api, "attachments-bucket").UploadPart(ctx, key, "mp-1", 2, []byte("part bytes"))
+
+		require.NoError(t, err)
+		assert.Equal(t, `"etag-2"`, etag)
+		assert.Equal(t, "mp-1", aws.ToString(api.part.UploadId))
+		assert.Equal(t, int32(2), aws.ToInt32(api.part.PartNumber))
+		assert.Equal(t, int64(10), aws.ToInt64(api.part.ContentLength))
+		assert.Equal(t, "part bytes", string(api.partBody))
+	})
+
+	t.Run("completes with the parts in order", func(t *testing.T) {
+		api := &recordingS3{}
+
+		err := adapter.NewS3Attachments(api, "attachments-bucket").CompleteMultipartUpload(ctx, key, "mp-1",
+			[]app.UploadedPart{{Number: 1, ETag: `"a"`}, {Number: 2, ETag: `"b"`}})
+
+		require.NoError(t, err)
+		assert.Equal(t, "mp-1", aws.ToString(api.complete.UploadId))
+		assert.Equal(t, []s3types.CompletedPart{
+			{PartNumber: aws.Int32(1), ETag: aws.String(`"a"`)},
+			{PartNumber: aws.Int32(2), ETag: aws.String(`"b"`)},
+		}, api.complete.MultipartUpload.Parts)
+	})
+
+	t.Run("aborts", func(t *testing.T) {
+		api := &recordingS3{}
+
+		require.NoError(t, adapter.NewS3Attachments(api, "attachments-bucket").AbortMultipartUpload(ctx, key, "mp-1"))
+
+		assert.Equal(t, "mp-1", aws.ToString(api.abort.UploadId))
+	})
+
+	t.Run("surfaces service errors", func(t *testing.T) {
+		apiErr := &s3types.NoSuchUpload{}
+
+		_, err := adapter.NewS3Attachments(&recordingS3{err: apiErr}, "attachments-bucket").UploadPart(ctx, key, "mp-1", 1, []byte("x"))
+
+		var noSuchUpload *s3types.NoSuchUpload
+		require.ErrorAs(t, err, &noSuchUpload)
+		assert.ErrorContains(t, err, "s3 attachments: upload "+key+" part 1")
+	})
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var (
+	messageFramesDelivered metric.Int64Counter
+	messageFramesResent    metric.Int64Counter
+)
+
+func init() {
+	m := otel.Meter("gateway/app")
+
+	messageFramesDelivered, _ = m.Int64Counter("gateway_message_frames_delivered_total",
+		metric.WithDescription("Message frames queued for clients, resends included"))
+	messageFramesResent, _ = m.Int64Counter("gateway_message_frames_resent_total",
+		metric.WithDescription("Message frames resent to a reconnected device because it had not acked them"))
+}
+
+// unacked is a message frame awaiting the client's ack.
+type unacked struct {
+	msg   protocol.Message
+	frame *protocol.Frame
+}
+
+// ackWindow holds the message frames sent on one connection that the client
+// has not acknowledged, in delivery order. It also remembers the highest
+// sequence sent per chat, which bounds what an ack can claim. Safe for
+// concurrent use.
+type ackWindow struct {
+	mu      sync.Mutex
+	size    int
+	pending []unacked
+	sent    map[string]uint64 // chat ID -> highest sequence sent
+}
+
+// track adds u to the window. It reports false without adding u when u's
+// message is already pending, and ok false when the window is full.
+func (w *ackWindow) track(u unacked) (added, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if slices.ContainsFunc(w.pending, func(p unacked) bool { return p.msg.MessageID == u.msg.MessageID }) {
+		return false, true
+	}
+	if len(w.pending) >= w.size {
+		return false, false
+	}
+	w.pending = append(w.pending, u)
+	w.markSent(u.msg.ChatID, u.msg.Sequence)
+	return true, true
+}
+
+// sentUpTo records that chatID's messages up to sequence were sent outside
+// the window, in a sync response.
+func (w *ackWindow) sentUpTo(chatID string, sequence uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.markSent(chatID, sequence)
+}
+
+// markSent raises chatID's highest sent sequence. The caller holds w.mu.
+func (w *ackWindow) markSent(chatID string, sequence uint64) {
+	if w.sent == nil {
+		w.sent = make(map[string]uint64)
+	}
+	if sequence > w.sent[chatID] {
+		w.sent[chatID] = sequence
+	}
+}
+
+// ack drops chatID's frames up to and including sequence. Acks are
+// cumulative (ADR-005 §3.5). It returns the acked sequence capped at the
+// highest one sent for chatID, and false if nothing was sent for it: a
+// client cannot ack what it was never sent.
+func (w *ackWindow) ack(chatID string, sequence uint64) (uint64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = slices.DeleteFunc(w.pending, func(p unacked) bool {
+		return p.msg.ChatID == chatID && p.msg.Sequence <= sequence
+	})
+	sent, ok := w.sent[chatID]
+	if !ok {
+		return 0, false
+	}
+	return min(sequence, sent), true
+}
+
+// drain empties the window and returns what was in it.
+func (w *ackWindow) drain() []unacked {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := w.pending
+	w.pending = nil
+	return pending
+}
+
+// len returns the number of frames awaiting an ack.
+func (w *ackWindow) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// ResendBuffer keeps the unacked message frames of closed connections so
+// they can be resent when the same device reconnects to this Gateway
+// instance. Resending is best effort: the frames live only in this
+// process, so a device that reconnects to another pod, after the
+// retention period or after this pod restarts gets nothing from here and
+// catches up with sync from its delivery cursor. Sync is what guarantees
+// delivery; the buffer only saves the round trip. Safe for concurrent use.
+type ResendBuffer struct {
+	retention time.Duration
+
+	mu      sync.Mutex
+	devices map[string]parked
+	order   []parkedKey // parking order, for expiry
+}
+
+type parked struct {
+	frames []unacked
+	at     time.Time
+}
+
+type parkedKey struct {
+	device string
+	at     time.Time
+}
+
+// NewResendBuffer creates a ResendBuffer that keeps frames for retention.
+// Zero defaults to domain.AckResendRetention.
+func NewResendBuffer(retention time.Duration) *ResendBuffer {
+	if retention <= 0 {
+		retention = domain.AckResendRetention
+	}
+	return &ResendBuffer{
+		retention: retention,
+		devices:   make(map[string]parked),
+	}
+}
+
+// park keeps frames for identity's device, replacing any kept earlier.
+// Identities without a device are not parked: a reconnect cannot be matched
+// to them.
+func (b *ResendBuffer) park(identity Identity, frames []unacked, now time.Time) {
+	if identity.DeviceID == "" || len(frames) == 0 {
+		return
+	}
+	key := resendKey(identity)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expire(now)
+	b.devices[key] = parked{frames: frames, at: now}
+	b.order = append(b.order, parkedKey{device: key, at: now})
+}
+
+// take removes and returns the frames kept for identity's device.
+func (b *ResendBuffer) take(identity Identity, now time.Time) []unacked {
+	if identity.DeviceID == "" {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expire(now)
+	key := resendKey(identity)
+	p, ok := b.devices[key]
+	if !ok {
+		return nil
+	}
+	delete(b.devices, key)
+	return p.frames
+}
+
+// expire drops frames parked longer than the retention period. The caller
+// holds b.mu.
+func (b *ResendBuffer) expire(now time.Time) {
+	cutoff := now.Add(-b.retention)
+	n := 0
+	for ; n < len(b.order) && b.order[n].at.Before(cutoff); n++ {
+		k := b.order[n]
+		// The device may have been taken, or parked again since.
+		if p, ok := b.devices[k.device]; ok && p.at.Equal(k.at) {
+			delete(b.devices, k.device)
+		}
+	}
+	b.order = b.order[n:]
+}
+
+func resendKey(identity Identity) string {
+	return identity.UserID + "\x00" + identity.DeviceID
+}
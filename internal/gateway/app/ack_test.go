@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func testMessage(chatID string, seq uint64) protocol.Message {
+	return protocol.Message{MessageID: fmt.Sprintf("%s-%d", chatID, seq), ChatID: chatID, Sequence: seq}
+}
+
+func TestConnection_Deliver(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("held until acked cumulatively per chat", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, 8)
+		for seq := uint64(1); seq <= 3; seq++ {
+			require.NoError(t, c.Deliver(ctx, testMessage("chat-a", seq)))
+		}
+		require.NoError(t, c.Deliver(ctx, testMessage("chat-b", 1)))
+
+		c.acks.ack("chat-a", 2)
+
+		assert.Equal(t, 2, c.Unacked())
+		pending := c.acks.drain()
+		assert.Equal(t, uint64(3), pending[0].msg.Sequence)
+		assert.Equal(t, "chat-b", pending[1].msg.ChatID)
+	})
+
+	t.Run("acks are capped at what was sent", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, 8)
+		require.NoError(t, c.Deliver(ctx, testMessage("chat-a", 5)))
+		c.acks.sentUpTo("chat-b", 9)
+
+		acked, ok := c.acks.ack("chat-a", 1000)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(5), acked)
+
+		acked, ok = c.acks.ack("chat-b", 7)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(7), acked)
+
+		_, ok = c.acks.ack("chat-never-sent", 3)
+		assert.False(t, ok)
+	})
+
+	t.Run("a pending message is not queued twice", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, 8)
+
+		require.NoError(t, c.Deliver(ctx, testMessage("chat-a", 1)))
+		require.NoError(t, c.Deliver(ctx, testMessage("chat-a", 1)))
+
+		assert.Equal(t, 1, c.Unacked())
+		_, ok := c.dequeue()
+		require.True(t, ok)
+		_, ok = c.dequeue()
+		assert.False(t, ok)
+	})
+
+	t.Run("a full window closes the connection with ErrSlowConsumer", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, 8)
+		c.acks.size = 2
+		require.NoError(t, c.Deliver(ctx, testMessage("chat-a", 1)))
+		require.NoError(t, c.Deliver(ctx, testMessage("chat-a", 2)))
+
+		err := c.Deliver(ctx, testMessage("chat-a", 3))
+
+		require.ErrorIs(t, err, domain.ErrSlowConsumer)
+		assert.ErrorIs(t, c.Err(), domain.ErrSlowConsumer)
+	})
+}
+
+func TestResendBuffer(t *testing.T) {
+	device := Identity{UserID: "user-001", DeviceID: "device-001"}
+	frames := []unacked{{msg: testMessage("chat-a", 1)}}
+
+	t.Run("frames are taken once by the same device", func(t *testing.T) {
+		b := NewResendBuffer(time.Minute)
+		b.park(device, frames, testStart)
+
+		assert.Empty(t, b.take(Identity{UserID: "user-001", DeviceID: "device-002"}, testStart))
+		assert.Equal(t, frames, b.take(device, testStart.Add(time.Minute)))
+		assert.Empty(t, b.take(device, testStart.Add(time.Minute)))
+	})
+
+	t.Run("frames expire after the retention period", func(t *testing.T) {
+		b := NewResendBuffer(time.Minute)
+		b.park(device, frames, testStart)
+
+		assert.Empty(t, b.take(device, testStart.Add(time.Minute+time.Second)))
+		assert.Empty(t, b.devices)
+		assert.Empty(t, b.order)
+	})
+
+	t.Run("parking again outlives the earlier expiry", func(t *testing.T) {
+		b := NewResendBuffer(time.Minute)
+		b.park(device, frames, testStart)
+		b.park(device, frames, testStart.Add(30*time.Second))
+
+		assert.Equal(t, frames, b.take(device, testStart.Add(80*time.Second)))
+	})
+
+	t.Run("connections without a device are not parked", func(t *testing.T) {
+		b := NewResendBuffer(time.Minute)
+		b.park(Identity{UserID: "user-001"}, frames, testStart)
+
+		assert.Empty(t, b.devices)
+	})
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var sessionActivityWrites metric.Int64Counter
+
+func init() {
+	sessionActivityWrites, _ = otel.Meter("gateway/app").Int64Counter("gateway_session_activity_writes_total",
+		metric.WithDescription("Session last-active updates written by the Gateway, by result"))
+}
+
+// SessionActivity is the latest time a session was seen in use.
+type SessionActivity struct {
+	SessionID string
+	At        time.Time
+}
+
+// ActivityStore persists session activity for idle-session expiry. A write
+// must only move a session's last-active time forward and must never
+// recreate a session that was revoked.
+type ActivityStore interface {
+	RecordActivity(ctx context.Context, batch []SessionActivity) error
+}
+
+// ActivityTrackerConfig holds the dependencies for ActivityTracker.
+type ActivityTrackerConfig struct {
+	Store  ActivityStore
+	Clock  domain.Clock
+	Logger *slog.Logger
+
+	// Zero values default to domain.SessionActivityGranularity and
+	// domain.SessionActivityFlushInterval.
+	Granularity   time.Duration
+	FlushInterval time.Duration
+}
+
+// ActivityTracker batches session activity seen on this pod into periodic
+// writes. A session is reported at most once per granularity, so a busy
+// connection costs one write every few minutes rather than one per frame.
+// Safe for concurrent use.
+type ActivityTracker struct {
+	store         ActivityStore
+	clock         domain.Clock
+	logger        *slog.Logger
+	granularity   time.Duration
+	flushInterval time.Duration
+
+	mu       sync.Mutex
+	pending  map[string]time.Time // not yet written
+	reported map[string]time.Time // last time queued, for granularity
+}
+
+// NewActivityTracker creates an ActivityTracker with the given dependencies.
+func NewActivityTracker(cfg ActivityTrackerConfig) *ActivityTracker {
+	t := &ActivityTracker{
+		store:         cfg.Store,
+		clock:         cfg.Clock,
+		logger:        cfg.Logger,
+		granularity:   cfg.Granularity,
+		flushInterval: cfg.FlushInterval,
+		pending:       make(map[string]time.Time),
+		reported:      make(map[string]time.Time),
+	}
+	if t.granularity == 0 {
+		t.granularity = domain.SessionActivityGranularity
+	}
+	if t.flushInterval == 0 {
+		t.flushInterval = domain.SessionActivityFlushInterval
+	}
+	return t
+}
+
+// Touch records that sessionID was in use at at. Calls within granularity
+// of the last reported time are dropped.
+func (t *ActivityTracker) Touch(sessionID string, at time.Time) {
+	if sessionID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.reported[sessionID]; ok && at.Sub(last) < t.granularity {
+		return
+	}
+	t.reported[sessionID] = at
+	t.pending[sessionID] = at
+}
+
+// Flush writes the pending activity. A failed batch is kept for the next
+// flush unless newer activity has replaced it.
+func (t *ActivityTracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]time.Time, len(pending))
+	// Entries older than granularity no longer suppress anything.
+	horizon := t.clock.Now().Add(-t.granularity)
+	for id, at := range t.reported {
+		if at.Before(horizon) {
+			delete(t.reported, id)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	batch := make([]SessionActivity, 0, len(pending))
+	for id, at := range pending {
+		batch = append(batch, SessionActivity{SessionID: id, At: at})
+	}
+
+	if err := t.store.RecordActivity(ctx, batch); err != nil {
+		sessionActivityWrites.Add(ctx, int64(len(batch)), metric.WithAttributes(attribute.String("result", "error")))
+		t.mu.Lock()
+		for _, a := range batch {
+			if _, newer := t.pending[a.SessionID]; !newer {
+				t.pending[a.SessionID] = a.At
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+	sessionActivityWrites.Add(ctx, int64(len(batch)), metric.WithAttributes(attribute.String("result", "ok")))
+	return nil
+}
+
+// Run flushes every flush interval until ctx is cancelled, then flushes
+// once more so activity seen before shutdown is not lost.
+func (t *ActivityTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(context.WithoutCancel(ctx)); err != nil {
+				t.logger.WarnContext(ctx, "final session activity flush failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				t.logger.WarnContext(ctx, "session activity flush failed, retrying next interval", "error", err)
+			}
+		}
+	}
+}
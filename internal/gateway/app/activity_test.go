@@ -0,0 +1,138 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// recordingActivityStore implements app.ActivityStore.
+type recordingActivityStore struct {
+	mu      sync.Mutex
+	batches [][]app.SessionActivity
+	err     error
+}
+
+func (s *recordingActivityStore) RecordActivity(_ context.Context, batch []app.SessionActivity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Slice(batch, func(i, j int) bool { return batch[i].SessionID < batch[j].SessionID })
+	s.batches = append(s.batches, batch)
+	return s.err
+}
+
+func (s *recordingActivityStore) last() []app.SessionActivity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.batches) == 0 {
+		return nil
+	}
+	return s.batches[len(s.batches)-1]
+}
+
+func TestActivityTracker(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newTracker := func(store app.ActivityStore) (*app.ActivityTracker, *domaintest.FakeClock) {
+		clock := domaintest.NewFakeClock(start)
+		return app.NewActivityTracker(app.ActivityTrackerConfig{
+			Store:       store,
+			Clock:       clock,
+			Logger:      slog.Default(),
+			Granularity: 5 * time.Minute,
+		}), clock
+	}
+
+	t.Run("reports each session at most once per granularity", func(t *testing.T) {
+		store := &recordingActivityStore{}
+		tracker, _ := newTracker(store)
+
+		tracker.Touch("sess-a", start)
+		tracker.Touch("sess-a", start.Add(time.Minute))
+		tracker.Touch("sess-b", start.Add(2*time.Minute))
+		require.NoError(t, tracker.Flush(context.Background()))
+
+		assert.Equal(t, []app.SessionActivity{
+			{SessionID: "sess-a", At: start},
+			{SessionID: "sess-b", At: start.Add(2 * time.Minute)},
+		}, store.last())
+
+		tracker.Touch("sess-a", start.Add(5*time.Minute))
+		require.NoError(t, tracker.Flush(context.Background()))
+		assert.Equal(t, []app.SessionActivity{{SessionID: "sess-a", At: start.Add(5 * time.Minute)}}, store.last())
+	})
+
+	t.Run("nothing pending writes nothing", func(t *testing.T) {
+		store := &recordingActivityStore{}
+		tracker, _ := newTracker(store)
+
+		require.NoError(t, tracker.Flush(context.Background()))
+		assert.Empty(t, store.batches)
+	})
+
+	t.Run("failed batch is retried on the next flush", func(t *testing.T) {
+		store := &recordingActivityStore{err: errors.New("throttled")}
+		tracker, _ := newTracker(store)
+
+		tracker.Touch("sess-a", start)
+		require.Error(t, tracker.Flush(context.Background()))
+
+		store.err = nil
+		require.NoError(t, tracker.Flush(context.Background()))
+		assert.Equal(t, []app.SessionActivity{{SessionID: "sess-a", At: start}}, store.last())
+	})
+
+	t.Run("empty session ID is ignored", func(t *testing.T) {
+		store := &recordingActivityStore{}
+		tracker, _ := newTracker(store)
+
+		tracker.Touch("", start)
+		require.NoError(t, tracker.Flush(context.Background()))
+		assert.Empty(t, store.batches)
+	})
+
+	t.Run("run flushes once more on shutdown", func(t *testing.T) {
+		store := &recordingActivityStore{}
+		tracker, _ := newTracker(store)
+		tracker.Touch("sess-a", start)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		tracker.Run(ctx)
+
+		assert.Equal(t, []app.SessionActivity{{SessionID: "sess-a", At: start}}, store.last())
+	})
+}
+
+func TestSessionManager_ReportsActivity(t *testing.T) {
+	store := &recordingActivityStore{}
+	tracker := app.NewActivityTracker(app.ActivityTrackerConfig{
+		Store:  store,
+		Clock:  domaintest.NewFakeClock(time.Now()),
+		Logger: slog.Default(),
+	})
+	h := newSessionHarness()
+	h.cfg.Activity = tracker
+	tr := newFakeTransport()
+	done := h.serve(context.Background(), tr)
+	tr.next(t) // ack
+
+	require.Eventually(t, func() bool {
+		require.NoError(t, tracker.Flush(context.Background()))
+		return len(store.last()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "sess-001", store.last()[0].SessionID)
+
+	tr.hangUp()
+	require.NoError(t, wait(t, done))
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var (
+	admissionRejectedTotal metric.Int64Counter
+	framesShedTotal        metric.Int64Counter
+	loadLevelGauge         metric.Int64Gauge
+)
+
+func init() {
+	m := otel.Meter("gateway/app")
+
+	admissionRejectedTotal, _ = m.Int64Counter("gateway_admission_rejected_total",
+		metric.WithDescription("New connections refused because the Gateway is overloaded"))
+	framesShedTotal, _ = m.Int64Counter("gateway_frames_shed_total",
+		metric.WithDescription("Ephemeral frames dropped under load, by frame type"))
+	loadLevelGauge, _ = m.Int64Gauge("gateway_load_level",
+		metric.WithDescription("Admission load level: 0 normal, 1 shedding, 2 rejecting"))
+}
+
+// ErrOverloaded refuses new connections while the Gateway is saturated. It
+// matches ErrUnavailable, so HTTP upgrades get 503 with Retry-After and
+// clients back off before reconnecting.
+var ErrOverloaded = fmt.Errorf("gateway overloaded: %w", domain.ErrUnavailable)
+
+// Pressure is a point-in-time reading of process load.
+type Pressure struct {
+	CPU         float64 // Fraction of available CPU in use, 0..1
+	MemoryBytes uint64
+	Goroutines  int
+}
+
+// PressureSampler reads current process load.
+type PressureSampler interface {
+	Sample() Pressure
+}
+
+// LoadLevel is the admission state derived from Pressure.
+type LoadLevel int32
+
+const (
+	// LoadNormal admits everything.
+	LoadNormal LoadLevel = iota
+	// LoadShedding drops ephemeral frames (typing, presence) so message
+	// delivery keeps its headroom.
+	LoadShedding
+	// LoadRejecting also refuses new connections.
+	LoadRejecting
+)
+
+// String returns the level name used in logs.
+func (l LoadLevel) String() string {
+	switch l {
+	case LoadShedding:
+		return "shedding"
+	case LoadRejecting:
+		return "rejecting"
+	default:
+		return "normal"
+	}
+}
+
+// AdmissionControllerConfig holds the dependencies for AdmissionController.
+type AdmissionControllerConfig struct {
+	Sampler PressureSampler
+	Logger  *slog.Logger
+
+	// Limits at which new connections are refused. A zero limit disables
+	// that signal.
+	MaxCPU        float64
+	MaxMemory     uint64
+	MaxGoroutines int
+
+	// ShedRatio is the fraction of any limit at which ephemeral frames start
+	// being dropped. Zero values default to the ADR-009 limits in domain.
+	ShedRatio float64
+	Interval  time.Duration
+}
+
+// AdmissionController protects message delivery under overload. As pressure
+// approaches the configured limits it first sheds ephemeral frames, then
+// refuses new connections. Safe for concurrent use.
+type AdmissionController struct {
+	sampler       PressureSampler
+	logger        *slog.Logger
+	maxCPU        float64
+	maxMemory     uint64
+	maxGoroutines int
+	shedRatio     float64
+	interval      time.Duration
+
+	level atomic.Int32
+}
+
+// NewAdmissionController creates an AdmissionController with the given dependencies.
+func NewAdmissionController(cfg AdmissionControllerConfig) *AdmissionController {
+	a := &AdmissionController{
+		sampler:       cfg.Sampler,
+		logger:        cfg.Logger,
+		maxCPU:        cfg.MaxCPU,
+		maxMemory:     cfg.MaxMemory,
+		maxGoroutines: cfg.MaxGoroutines,
+		shedRatio:     cfg.ShedRatio,
+		interval:      cfg.Interval,
+	}
+	if a.shedRatio == 0 {
+		a.shedRatio = domain.AdmissionShedRatio
+	}
+	if a.interval == 0 {
+		a.interval = domain.AdmissionSampleInterval
+	}
+	return a
+}
+
+// Run samples pressure every interval until ctx is cancelled.
+func (a *AdmissionController) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.Update(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update takes one pressure sample and recomputes the load level.
+func (a *AdmissionController) Update(ctx context.Context) {
+	p := a.sampler.Sample()
+	ratio := max(
+		utilization(p.CPU, a.maxCPU),
+		utilization(float64(p.MemoryBytes), float64(a.maxMemory)),
+		utilization(float64(p.Goroutines), float64(a.maxGoroutines)),
+	)
+
+	level := LoadNormal
+	switch {
+	case ratio >= 1:
+		level = LoadRejecting
+	case ratio >= a.shedRatio:
+		level = LoadShedding
+	}
+
+	loadLevelGauge.Record(ctx, int64(level))
+	if prev := LoadLevel(a.level.Swap(int32(level))); prev != level {
+		a.logger.WarnContext(ctx, "gateway.load_level_changed",
+			"from", prev.String(),
+			"to", level.String(),
+			"cpu", p.CPU,
+			"memory_bytes", p.MemoryBytes,
+			"goroutines", p.Goroutines,
+		)
+	}
+}
+
+// Level returns the current load level.
+func (a *AdmissionController) Level() LoadLevel {
+	return LoadLevel(a.level.Load())
+}
+
+// AdmitConnection returns ErrOverloaded when new connections are refused.
+func (a *AdmissionController) AdmitConnection(ctx context.Context) error {
+	if a.Level() < LoadRejecting {
+		return nil
+	}
+	admissionRejectedTotal.Add(ctx, 1)
+	return ErrOverloaded
+}
+
+// AdmitFrame reports whether a frame of type ft should be delivered. Only
+// ephemeral frames are ever shed; messages, acks and control frames always
+// pass, since dropping them would break delivery guarantees.
+func (a *AdmissionController) AdmitFrame(ctx context.Context, ft protocol.FrameType) bool {
+	if a.Level() < LoadShedding || FramePriority(ft) != domain.PriorityEphemeral {
+		return true
+	}
+	framesShedTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("frame_type", string(ft))))
+	return false
+}
+
+// utilization returns value/limit, or 0 when the limit is disabled.
+func utilization(value, limit float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return value / limit
+}
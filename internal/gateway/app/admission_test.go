@@ -0,0 +1,177 @@
+package app_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// stubSampler returns a settable Pressure.
+type stubSampler struct {
+	mu sync.Mutex
+	p  app.Pressure
+}
+
+func (s *stubSampler) Sample() app.Pressure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.p
+}
+
+func (s *stubSampler) set(p app.Pressure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.p = p
+}
+
+func newAdmission(sampler app.PressureSampler) *app.AdmissionController {
+	return app.NewAdmissionController(app.AdmissionControllerConfig{
+		Sampler:       sampler,
+		Logger:        slog.Default(),
+		MaxCPU:        0.9,
+		MaxMemory:     1000,
+		MaxGoroutines: 100,
+		ShedRatio:     0.8,
+	})
+}
+
+func TestAdmissionController_Level(t *testing.T) {
+	tests := []struct {
+		name     string
+		pressure app.Pressure
+		want     app.LoadLevel
+	}{
+		{"idle", app.Pressure{}, app.LoadNormal},
+		{"below shed ratio", app.Pressure{CPU: 0.5, MemoryBytes: 700, Goroutines: 79}, app.LoadNormal},
+		{"cpu at shed ratio", app.Pressure{CPU: 0.73}, app.LoadShedding},
+		{"memory at shed ratio", app.Pressure{MemoryBytes: 800}, app.LoadShedding},
+		{"goroutines at shed ratio", app.Pressure{Goroutines: 80}, app.LoadShedding},
+		{"cpu at limit", app.Pressure{CPU: 0.9}, app.LoadRejecting},
+		{"memory over limit", app.Pressure{MemoryBytes: 1500}, app.LoadRejecting},
+		{"worst signal wins", app.Pressure{CPU: 0.1, MemoryBytes: 100, Goroutines: 100}, app.LoadRejecting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmission(&stubSampler{p: tt.pressure})
+			a.Update(context.Background())
+			assert.Equal(t, tt.want, a.Level())
+		})
+	}
+
+	t.Run("zero limits disable a signal", func(t *testing.T) {
+		a := app.NewAdmissionController(app.AdmissionControllerConfig{
+			Sampler: &stubSampler{p: app.Pressure{CPU: 1, MemoryBytes: 1 << 40, Goroutines: 1 << 20}},
+			Logger:  slog.Default(),
+		})
+		a.Update(context.Background())
+		assert.Equal(t, app.LoadNormal, a.Level())
+	})
+}
+
+func TestAdmissionController_Admit(t *testing.T) {
+	ctx := context.Background()
+	sampler := &stubSampler{}
+	a := newAdmission(sampler)
+
+	a.Update(ctx)
+	require.NoError(t, a.AdmitConnection(ctx))
+	assert.True(t, a.AdmitFrame(ctx, protocol.FrameTypeTyping))
+
+	sampler.set(app.Pressure{Goroutines: 85})
+	a.Update(ctx)
+	require.NoError(t, a.AdmitConnection(ctx), "shedding still admits connections")
+	assert.False(t, a.AdmitFrame(ctx, protocol.FrameTypeTyping))
+	assert.False(t, a.AdmitFrame(ctx, protocol.FrameTypePresence))
+	for _, ft := range []protocol.FrameType{
+		protocol.FrameTypeMessage,
+		protocol.FrameTypeSendMessageAck,
+		protocol.FrameTypePing,
+		protocol.FrameTypeError,
+		protocol.FrameTypeConnectionClosing,
+	} {
+		assert.True(t, a.AdmitFrame(ctx, ft), "%s must never be shed", ft)
+	}
+
+	sampler.set(app.Pressure{Goroutines: 100})
+	a.Update(ctx)
+	err := a.AdmitConnection(ctx)
+	require.ErrorIs(t, err, app.ErrOverloaded)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+
+	sampler.set(app.Pressure{})
+	a.Update(ctx)
+	assert.NoError(t, a.AdmitConnection(ctx), "recovers once pressure drops")
+}
+
+func TestAdmissionController_Run(t *testing.T) {
+	sampler := &stubSampler{p: app.Pressure{CPU: 0.95}}
+	a := app.NewAdmissionController(app.AdmissionControllerConfig{
+		Sampler:  sampler,
+		Logger:   slog.Default(),
+		MaxCPU:   0.9,
+		Interval: 5 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { defer close(done); a.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return a.Level() == app.LoadRejecting }, time.Second, 5*time.Millisecond)
+	sampler.set(app.Pressure{})
+	require.Eventually(t, func() bool { return a.Level() == app.LoadNormal }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestSessionManager_Admission(t *testing.T) {
+	t.Run("overloaded gateway refuses before authenticating", func(t *testing.T) {
+		h := newSessionHarness()
+		a := newAdmission(&stubSampler{p: app.Pressure{CPU: 1}})
+		a.Update(context.Background())
+		h.cfg.Admission = a
+		h.auth.authenticateFn = func(context.Context, string) (app.Identity, error) {
+			t.Error("authenticator must not be called")
+			return app.Identity{}, nil
+		}
+		tr := newFakeTransport()
+
+		err := wait(t, h.serve(context.Background(), tr))
+		require.ErrorIs(t, err, app.ErrOverloaded)
+		assert.Empty(t, tr.sent)
+		assert.Zero(t, h.registry.Len())
+	})
+
+	t.Run("shedding drops ephemeral frames but delivers messages", func(t *testing.T) {
+		h := newSessionHarness()
+		a := newAdmission(&stubSampler{p: app.Pressure{CPU: 0.8}})
+		a.Update(context.Background())
+		h.cfg.Admission = a
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+		conn := h.registry.UserConnections("user-001")[0]
+		typing, err := protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-001"})
+		require.NoError(t, err)
+		msg, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{Sequence: 1})
+		require.NoError(t, err)
+
+		require.NoError(t, conn.Enqueue(typing), "shedding is not an error")
+		require.NoError(t, conn.Enqueue(msg))
+		assert.Equal(t, protocol.FrameTypeMessage, tr.next(t).Type)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+		assert.Empty(t, tr.sent)
+	})
+}
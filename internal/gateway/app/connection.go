@@ -0,0 +1,262 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Identity is the authenticated principal behind a connection.
+type Identity struct {
+	UserID            string
+	SessionID         string
+	DeviceID          string
+	AccessTokenExpiry time.Time
+}
+
+// Connection is one live client connection, independent of transport. Frames
+// for the client are queued with Enqueue into priority lanes and drained by
+// the session writer. Safe for concurrent use.
+type Connection struct {
+	id          string
+	identity    Identity
+	connectedAt time.Time
+
+	mu       sync.Mutex
+	outbound *domain.PriorityQueue[*protocol.Frame]
+	ready    chan struct{} // signalled when outbound goes non-empty
+	batch    bool          // client negotiated protocol.CapabilityBatch
+	upload   bool          // client negotiated protocol.CapabilityUpload
+
+	lastSeen atomic.Int64 // Unix millis of the last inbound frame
+	warned   atomic.Bool  // slow-consumer warning already queued
+
+	// acks holds delivered message frames until the client acks them. Its
+	// size is set before the connection is registered.
+	acks ackWindow
+
+	// Clock skew. skew is touched only by the inbound loop; the latest
+	// offset is published for everyone else.
+	lastPing    atomic.Int64 // Timestamp of the last server ping
+	skew        domain.ClockSkewEstimator
+	clockOffset atomic.Int64 // Milliseconds, client minus server
+	hasOffset   atomic.Bool
+
+	// admit filters frames before queueing; nil admits all. Set before the
+	// connection is registered.
+	admit func(protocol.FrameType) bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	cause     error // written once before closed is closed
+}
+
+func newConnection(id string, identity Identity, now time.Time, bufferSize int) *Connection {
+	c := &Connection{
+		id:          id,
+		identity:    identity,
+		connectedAt: now,
+		outbound:    domain.NewPriorityQueue[*protocol.Frame](bufferSize),
+		ready:       make(chan struct{}, 1),
+		acks:        ackWindow{size: domain.AckWindowSize},
+		closed:      make(chan struct{}),
+	}
+	c.lastSeen.Store(now.UnixMilli())
+	return c
+}
+
+// ID returns the connection ID.
+func (c *Connection) ID() string { return c.id }
+
+// Identity returns the authenticated principal.
+func (c *Connection) Identity() Identity { return c.identity }
+
+// ConnectedAt returns when the connection was established.
+func (c *Connection) ConnectedAt() time.Time { return c.connectedAt }
+
+// Enqueue queues f for delivery without blocking. Frames are delivered in
+// weighted priority order (see FramePriority), so chat messages are never
+// starved by ephemeral traffic. When the message or receipt lane is full the
+// connection is closed with ErrSlowConsumer: those frames are never dropped
+// silently, so the client reconnects and syncs (ADR-005 §5.2). Ephemeral
+// frames are best effort: a full lane drops its oldest, and admission control
+// may shed them under load.
+func (c *Connection) Enqueue(f *protocol.Frame) error {
+	select {
+	case <-c.closed:
+		return fmt.Errorf("connection %s closed: %w", c.id, domain.ErrNotFound)
+	default:
+	}
+
+	if c.admit != nil && !c.admit(f.Type) {
+		return nil
+	}
+
+	c.mu.Lock()
+	ok := c.outbound.Push(FramePriority(f.Type), f)
+	depth := c.outbound.LaneLen(domain.PriorityMessage)
+	c.mu.Unlock()
+
+	if !ok {
+		c.Close(domain.ErrSlowConsumer)
+		return fmt.Errorf("connection %s outbound buffer full: %w", c.id, domain.ErrSlowConsumer)
+	}
+	c.signal()
+
+	if depth >= domain.SlowConsumerThreshold && c.warned.CompareAndSwap(false, true) {
+		c.warnSlowConsumer()
+	}
+	return nil
+}
+
+// Deliver queues a chat message for the client and holds it until the
+// client acks its sequence. A message already awaiting an ack is not queued
+// again, so upstream redelivery is deduplicated by message ID. When the ack
+// window is full the connection is closed with ErrSlowConsumer, like a full
+// buffer: the client reconnects, is resent what it had not acked and syncs
+// the rest.
+func (c *Connection) Deliver(ctx context.Context, m protocol.Message) error {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, m)
+	if err != nil {
+		return fmt.Errorf("encode message %s: %w", m.MessageID, err)
+	}
+	return c.deliver(ctx, unacked{msg: m, frame: f})
+}
+
+func (c *Connection) deliver(ctx context.Context, u unacked) error {
+	select {
+	case <-c.closed:
+		return fmt.Errorf("connection %s closed: %w", c.id, domain.ErrNotFound)
+	default:
+	}
+
+	added, ok := c.acks.track(u)
+	if !ok {
+		c.Close(domain.ErrSlowConsumer)
+		return fmt.Errorf("connection %s ack window full: %w", c.id, domain.ErrSlowConsumer)
+	}
+	if !added {
+		return nil
+	}
+	if err := c.Enqueue(u.frame); err != nil {
+		return err
+	}
+	messageFramesDelivered.Add(ctx, 1)
+	return nil
+}
+
+// Unacked returns the number of delivered messages the client has not
+// acked.
+func (c *Connection) Unacked() int { return c.acks.len() }
+
+// warnSlowConsumer queues a SLOW_CONSUMER error frame so the client can react
+// before the buffer overflows. Best effort: a full buffer is about to close
+// the connection anyway.
+func (c *Connection) warnSlowConsumer() {
+	def, _ := domain.LookupError(domain.ErrSlowConsumer)
+	f, err := protocol.NewFrame(protocol.FrameTypeError, protocol.Error{
+		Code:      "SLOW_CONSUMER",
+		ErrorCode: int(def.Code),
+		Message:   "outbound buffer is filling; connection will close if it overflows",
+	})
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.outbound.Push(domain.PriorityMessage, f)
+	c.mu.Unlock()
+	c.signal()
+}
+
+// signal wakes the writer without blocking.
+func (c *Connection) signal() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue removes the next frame to write, if any.
+func (c *Connection) dequeue() (*protocol.Frame, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.outbound.Pop()
+}
+
+// Close terminates the connection with cause. Only the first cause is kept;
+// a nil cause means normal closure.
+func (c *Connection) Close(cause error) {
+	c.closeOnce.Do(func() {
+		c.cause = cause
+		close(c.closed)
+	})
+}
+
+// Done is closed when the connection is closed.
+func (c *Connection) Done() <-chan struct{} { return c.closed }
+
+// Err returns the close cause once Done is closed, and nil before.
+func (c *Connection) Err() error {
+	select {
+	case <-c.closed:
+		return c.cause
+	default:
+		return nil
+	}
+}
+
+// FramePriority returns the delivery lane for a server frame type. Control
+// frames share the message lane so heartbeats and errors are never starved.
+func FramePriority(ft protocol.FrameType) domain.DeliveryPriority {
+	switch ft {
+	case protocol.FrameTypeReceipt:
+		return domain.PriorityReceipt
+	case protocol.FrameTypeTyping, protocol.FrameTypePresence:
+		return domain.PriorityEphemeral
+	default:
+		return domain.PriorityMessage
+	}
+}
+
+// touch records inbound activity for heartbeat liveness.
+func (c *Connection) touch(now time.Time) {
+	c.lastSeen.Store(now.UnixMilli())
+}
+
+// ClockOffset returns the estimated client-minus-server clock offset and
+// whether the client has sent a ping to estimate it from.
+func (c *Connection) ClockOffset() (time.Duration, bool) {
+	if !c.hasOffset.Load() {
+		return 0, false
+	}
+	return time.Duration(c.clockOffset.Load()) * time.Millisecond, true
+}
+
+// observePong records the round trip of the last server ping. Pongs that
+// echo any other timestamp are ignored, so a client cannot skew its own RTT.
+func (c *Connection) observePong(p protocol.Pong, received domain.ServerTime) {
+	if p.Timestamp == 0 || p.Timestamp != c.lastPing.Load() {
+		return
+	}
+	c.skew.ObserveRoundTrip(domain.ServerTimeFromMillis(p.Timestamp), received)
+}
+
+// observePing folds a client ping into the offset estimate and returns it.
+func (c *Connection) observePing(p protocol.Ping, received domain.ServerTime) time.Duration {
+	offset := c.skew.ObserveClientTime(domain.NewClientTime(p.Timestamp), received)
+	if _, ok := c.skew.Offset(); ok {
+		c.clockOffset.Store(offset.Milliseconds())
+		c.hasOffset.Store(true)
+	}
+	return offset
+}
+
+// idleSince returns how long the connection has been silent.
+func (c *Connection) idleSince(now time.Time) time.Duration {
+	return now.Sub(domain.FromMillis(c.lastSeen.Load()))
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var testStart = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func testFrame(t *testing.T) *protocol.Frame {
+	t.Helper()
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{})
+	require.NoError(t, err)
+	return f
+}
+
+func TestConnection_Enqueue(t *testing.T) {
+	t.Run("overflow closes the connection with ErrSlowConsumer", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 2)
+
+		require.NoError(t, c.Enqueue(testFrame(t)))
+		require.NoError(t, c.Enqueue(testFrame(t)))
+
+		err := c.Enqueue(testFrame(t))
+		require.ErrorIs(t, err, domain.ErrSlowConsumer)
+
+		select {
+		case <-c.Done():
+		default:
+			t.Fatal("connection should be closed")
+		}
+		assert.ErrorIs(t, c.Err(), domain.ErrSlowConsumer)
+	})
+
+	t.Run("enqueue after close fails", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, 4)
+		c.Close(nil)
+
+		require.ErrorIs(t, c.Enqueue(testFrame(t)), domain.ErrNotFound)
+		assert.NoError(t, c.Err(), "nil cause means normal closure")
+	})
+
+	t.Run("crossing the slow-consumer threshold queues one warning", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, domain.SlowConsumerThreshold*2)
+
+		for i := 0; i < domain.SlowConsumerThreshold+10; i++ {
+			require.NoError(t, c.Enqueue(testFrame(t)))
+		}
+
+		warnings := 0
+		for f, ok := c.dequeue(); ok; f, ok = c.dequeue() {
+			if f.Type == protocol.FrameTypeError {
+				var e protocol.Error
+				require.NoError(t, f.ParsePayload(&e))
+				assert.Equal(t, "SLOW_CONSUMER", e.Code)
+				warnings++
+			}
+		}
+		assert.Equal(t, 1, warnings)
+	})
+
+	t.Run("ephemeral frames never close the connection and yield to messages", func(t *testing.T) {
+		c := newConnection("conn-1", Identity{}, testStart, 2)
+		for range 5 {
+			f, err := protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-001"})
+			require.NoError(t, err)
+			require.NoError(t, c.Enqueue(f), "a full ephemeral lane drops its oldest frame")
+		}
+		require.NoError(t, c.Enqueue(testFrame(t)))
+		require.NoError(t, c.Err())
+
+		f, ok := c.dequeue()
+		require.True(t, ok)
+		assert.Equal(t, protocol.FrameTypeMessage, f.Type, "message overtakes queued typing frames")
+	})
+}
+
+func TestFramePriority(t *testing.T) {
+	assert.Equal(t, domain.PriorityReceipt, FramePriority(protocol.FrameTypeReceipt))
+	assert.Equal(t, domain.PriorityEphemeral, FramePriority(protocol.FrameTypeTyping))
+	assert.Equal(t, domain.PriorityEphemeral, FramePriority(protocol.FrameTypePresence))
+	for _, ft := range []protocol.FrameType{
+		protocol.FrameTypeMessage,
+		protocol.FrameTypeSendMessageAck,
+		protocol.FrameTypeSyncResponse,
+		protocol.FrameTypePing,
+		protocol.FrameTypeError,
+		protocol.FrameTypeConnectionClosing,
+	} {
+		assert.Equal(t, domain.PriorityMessage, FramePriority(ft), ft)
+	}
+}
+
+func TestConnection_Close(t *testing.T) {
+	c := newConnection("conn-1", Identity{}, testStart, 1)
+	assert.NoError(t, c.Err(), "open connection has no cause")
+
+	c.Close(domain.ErrSlowConsumer)
+	c.Close(ErrHeartbeatTimeout)
+
+	assert.ErrorIs(t, c.Err(), domain.ErrSlowConsumer, "first cause wins")
+}
+
+func TestConnection_IdleSince(t *testing.T) {
+	c := newConnection("conn-1", Identity{}, testStart, 1)
+	assert.Equal(t, 10*time.Second, c.idleSince(testStart.Add(10*time.Second)))
+
+	c.touch(testStart.Add(8 * time.Second))
+	assert.Equal(t, 2*time.Second, c.idleSince(testStart.Add(10*time.Second)))
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var deliveryCursorWrites metric.Int64Counter
+
+func init() {
+	deliveryCursorWrites, _ = otel.Meter("gateway/app").Int64Counter("gateway_delivery_cursor_writes_total",
+		metric.WithDescription("Delivery cursors written by the Gateway, by tier (cache, checkpoint) and result"))
+}
+
+// DeliveryCursor is the highest sequence a device has acked in a chat.
+type DeliveryCursor struct {
+	UserID   string
+	DeviceID string
+	ChatID   string
+	Sequence uint64
+	At       time.Time // when the ack arrived
+}
+
+// CursorStore persists delivery cursors. Writes must only move a cursor
+// forward. GetCursor reports false for a device that has no cursor in the
+// chat.
+type CursorStore interface {
+	SaveCursors(ctx context.Context, batch []DeliveryCursor) error
+	GetCursor(ctx context.Context, userID, deviceID, chatID string) (uint64, bool, error)
+}
+
+// CursorReader answers where a device's sync should start from.
+type CursorReader interface {
+	Cursor(ctx context.Context, userID, deviceID, chatID string) (uint64, bool, error)
+}
+
+// CursorTrackerConfig holds the dependencies for CursorTracker.
+type CursorTrackerConfig struct {
+	// Cache holds live cursors (Redis); Checkpoint holds them durably
+	// (DynamoDB) for when the cache has lost them.
+	Cache      CursorStore
+	Checkpoint CursorStore
+	Logger     *slog.Logger
+
+	// Zero values default to domain.DeliveryCursorFlushInterval and
+	// domain.DeliveryCursorCheckpointInterval.
+	FlushInterval      time.Duration
+	CheckpointInterval time.Duration
+}
+
+// CursorTracker keeps each device's delivery cursors authoritative on the
+// server. Acks advance cursors in memory, so the read loop never waits on
+// I/O; they reach the cache every flush interval and the checkpoint every
+// checkpoint interval. Safe for concurrent use.
+type CursorTracker struct {
+	cache              CursorStore
+	checkpoint         CursorStore
+	logger             *slog.Logger
+	flushInterval      time.Duration
+	checkpointInterval time.Duration
+
+	mu        sync.Mutex
+	uncached  map[cursorKey]DeliveryCursor // not yet in the cache
+	unchecked map[cursorKey]DeliveryCursor // not yet checkpointed
+}
+
+type cursorKey struct {
+	userID, deviceID, chatID string
+}
+
+// NewCursorTracker creates a CursorTracker with the given dependencies.
+func NewCursorTracker(cfg CursorTrackerConfig) *CursorTracker {
+	t := &CursorTracker{
+		cache:              cfg.Cache,
+		checkpoint:         cfg.Checkpoint,
+		logger:             cfg.Logger,
+		flushInterval:      cfg.FlushInterval,
+		checkpointInterval: cfg.CheckpointInterval,
+		uncached:           make(map[cursorKey]DeliveryCursor),
+		unchecked:          make(map[cursorKey]DeliveryCursor),
+	}
+	if t.flushInterval == 0 {
+		t.flushInterval = domain.DeliveryCursorFlushInterval
+	}
+	if t.checkpointInterval == 0 {
+		t.checkpointInterval = domain.DeliveryCursorCheckpointInterval
+	}
+	return t
+}
+
+// Advance moves c's cursor forward to c.Sequence. Acks from connections
+// without a device, or without a chat, are ignored.
+func (t *CursorTracker) Advance(c DeliveryCursor) {
+	if c.DeviceID == "" || c.ChatID == "" || c.Sequence == 0 {
+		return
+	}
+	k := cursorKey{c.UserID, c.DeviceID, c.ChatID}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	advance(t.uncached, k, c)
+	advance(t.unchecked, k, c)
+}
+
+// advance sets m[k] to c unless it already holds a later sequence.
+func advance(m map[cursorKey]DeliveryCursor, k cursorKey, c DeliveryCursor) {
+	if cur, ok := m[k]; !ok || cur.Sequence < c.Sequence {
+		m[k] = c
+	}
+}
+
+// Cursor returns the device's cursor in the chat: the latest ack seen on
+// this pod, else the cache, else the checkpoint. A failed cache read falls
+// back to the checkpoint, which may lag by up to a checkpoint interval;
+// the client then receives messages it already has and drops them by
+// message_id.
+func (t *CursorTracker) Cursor(ctx context.Context, userID, deviceID, chatID string) (uint64, bool, error) {
+	t.mu.Lock()
+	local, hasLocal := t.unchecked[cursorKey{userID, deviceID, chatID}]
+	t.mu.Unlock()
+
+	seq, ok, err := t.cache.GetCursor(ctx, userID, deviceID, chatID)
+	if err != nil || !ok {
+		if err != nil {
+			t.logger.WarnContext(ctx, "delivery cursor cache read failed, using checkpoint", "error", err)
+		}
+		if seq, ok, err = t.checkpoint.GetCursor(ctx, userID, deviceID, chatID); err != nil {
+			return 0, false, fmt.Errorf("read delivery cursor: %w", err)
+		}
+	}
+	if hasLocal && local.Sequence > seq {
+		return local.Sequence, true, nil
+	}
+	return seq, ok, nil
+}
+
+// Flush writes the cursors advanced since the last flush to the cache.
+func (t *CursorTracker) Flush(ctx context.Context) error {
+	return t.write(ctx, t.cache, &t.uncached, "cache")
+}
+
+// Checkpoint writes the cursors advanced since the last checkpoint to the
+// checkpoint store.
+func (t *CursorTracker) Checkpoint(ctx context.Context) error {
+	return t.write(ctx, t.checkpoint, &t.unchecked, "checkpoint")
+}
+
+// write saves the cursors pending in *pending to store. A failed batch is
+// kept for the next write unless a later ack has replaced it.
+func (t *CursorTracker) write(ctx context.Context, store CursorStore, pending *map[cursorKey]DeliveryCursor, tier string) error {
+	t.mu.Lock()
+	batched := *pending
+	*pending = make(map[cursorKey]DeliveryCursor, len(batched))
+	t.mu.Unlock()
+
+	if len(batched) == 0 {
+		return nil
+	}
+	batch := make([]DeliveryCursor, 0, len(batched))
+	for _, c := range batched {
+		batch = append(batch, c)
+	}
+
+	if err := store.SaveCursors(ctx, batch); err != nil {
+		deliveryCursorWrites.Add(ctx, int64(len(batch)), metric.WithAttributes(
+			attribute.String("tier", tier), attribute.String("result", "error")))
+		t.mu.Lock()
+		for k, c := range batched {
+			advance(*pending, k, c)
+		}
+		t.mu.Unlock()
+		return fmt.Errorf("write delivery cursors to %s: %w", tier, err)
+	}
+	deliveryCursorWrites.Add(ctx, int64(len(batch)), metric.WithAttributes(
+		attribute.String("tier", tier), attribute.String("result", "ok")))
+	return nil
+}
+
+// Run flushes and checkpoints on their intervals until ctx is cancelled,
+// then does both once more so acks seen before shutdown are not lost.
+func (t *CursorTracker) Run(ctx context.Context) {
+	flush := time.NewTicker(t.flushInterval)
+	defer flush.Stop()
+	checkpoint := time.NewTicker(t.checkpointInterval)
+	defer checkpoint.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			final := context.WithoutCancel(ctx)
+			if err := t.Flush(final); err != nil {
+				t.logger.WarnContext(ctx, "final delivery cursor flush failed", "error", err)
+			}
+			if err := t.Checkpoint(final); err != nil {
+				t.logger.WarnContext(ctx, "final delivery cursor checkpoint failed", "error", err)
+			}
+			return
+		case <-flush.C:
+			if err := t.Flush(ctx); err != nil {
+				t.logger.WarnContext(ctx, "delivery cursor flush failed, retrying next interval", "error", err)
+			}
+		case <-checkpoint.C:
+			if err := t.Checkpoint(ctx); err != nil {
+				t.logger.WarnContext(ctx, "delivery cursor checkpoint failed, retrying next interval", "error", err)
+			}
+		}
+	}
+}
+
+// Compile-time interface check.
+var _ CursorReader = (*CursorTracker)(nil)
@@ -0,0 +1,257 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// memCursorStore implements app.CursorStore over a map. err, when set,
+// fails every call.
+type memCursorStore struct {
+	mu      sync.Mutex
+	cursors map[string]uint64
+	err     error
+}
+
+func newMemCursorStore() *memCursorStore {
+	return &memCursorStore{cursors: make(map[string]uint64)}
+}
+
+func (s *memCursorStore) SaveCursors(_ context.Context, batch []app.DeliveryCursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, c := range batch {
+		k := c.UserID + "/" + c.DeviceID + "/" + c.ChatID
+		s.cursors[k] = max(s.cursors[k], c.Sequence)
+	}
+	return nil
+}
+
+func (s *memCursorStore) GetCursor(_ context.Context, userID, deviceID, chatID string) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, false, s.err
+	}
+	seq, ok := s.cursors[userID+"/"+deviceID+"/"+chatID]
+	return seq, ok, nil
+}
+
+func (s *memCursorStore) get(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursors[key]
+}
+
+func (s *memCursorStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func newCursorTracker(cache, checkpoint *memCursorStore) *app.CursorTracker {
+	return app.NewCursorTracker(app.CursorTrackerConfig{
+		Cache:      cache,
+		Checkpoint: checkpoint,
+		Logger:     slog.Default(),
+	})
+}
+
+func deviceCursor(chatID string, seq uint64) app.DeliveryCursor {
+	return app.DeliveryCursor{UserID: "user-001", DeviceID: "device-001", ChatID: chatID, Sequence: seq}
+}
+
+func TestCursorTracker(t *testing.T) {
+	ctx := context.Background()
+	const key = "user-001/device-001/chat-1"
+
+	t.Run("acks move cursors forward through cache and checkpoint", func(t *testing.T) {
+		cache, checkpoint := newMemCursorStore(), newMemCursorStore()
+		tracker := newCursorTracker(cache, checkpoint)
+
+		tracker.Advance(deviceCursor("chat-1", 5))
+		tracker.Advance(deviceCursor("chat-1", 3))
+		require.NoError(t, tracker.Flush(ctx))
+
+		assert.Equal(t, uint64(5), cache.get(key))
+		assert.Zero(t, checkpoint.get(key), "checkpoints run on their own interval")
+
+		require.NoError(t, tracker.Checkpoint(ctx))
+		assert.Equal(t, uint64(5), checkpoint.get(key))
+	})
+
+	t.Run("a failed write is retried", func(t *testing.T) {
+		cache := newMemCursorStore()
+		tracker := newCursorTracker(cache, newMemCursorStore())
+		tracker.Advance(deviceCursor("chat-1", 5))
+		cache.fail(errors.New("connection refused"))
+
+		require.Error(t, tracker.Flush(ctx))
+		cache.fail(nil)
+		require.NoError(t, tracker.Flush(ctx))
+
+		assert.Equal(t, uint64(5), cache.get(key))
+	})
+
+	t.Run("acks without a device are ignored", func(t *testing.T) {
+		cache := newMemCursorStore()
+		tracker := newCursorTracker(cache, newMemCursorStore())
+
+		tracker.Advance(app.DeliveryCursor{UserID: "user-001", ChatID: "chat-1", Sequence: 5})
+		require.NoError(t, tracker.Flush(ctx))
+
+		assert.Empty(t, cache.cursors)
+	})
+
+	t.Run("reads prefer local acks, then the cache, then the checkpoint", func(t *testing.T) {
+		cache, checkpoint := newMemCursorStore(), newMemCursorStore()
+		tracker := newCursorTracker(cache, checkpoint)
+		cursor := func() uint64 {
+			t.Helper()
+			seq, ok, err := tracker.Cursor(ctx, "user-001", "device-001", "chat-1")
+			require.NoError(t, err)
+			require.True(t, ok)
+			return seq
+		}
+
+		_, ok, err := tracker.Cursor(ctx, "user-001", "device-001", "chat-1")
+		require.NoError(t, err)
+		assert.False(t, ok, "no cursor yet")
+
+		checkpoint.cursors[key] = 2
+		assert.Equal(t, uint64(2), cursor())
+		cache.cursors[key] = 4
+		assert.Equal(t, uint64(4), cursor())
+		tracker.Advance(deviceCursor("chat-1", 6))
+		assert.Equal(t, uint64(6), cursor())
+	})
+
+	t.Run("a cache failure falls back to the checkpoint", func(t *testing.T) {
+		cache, checkpoint := newMemCursorStore(), newMemCursorStore()
+		tracker := newCursorTracker(cache, checkpoint)
+		cache.cursors[key] = 4
+		checkpoint.cursors[key] = 2
+		cache.fail(errors.New("connection refused"))
+
+		seq, ok, err := tracker.Cursor(ctx, "user-001", "device-001", "chat-1")
+
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(2), seq)
+
+		checkpoint.fail(errors.New("throttled"))
+		_, _, err = tracker.Cursor(ctx, "user-001", "device-001", "chat-1")
+		assert.ErrorContains(t, err, "throttled")
+	})
+
+	t.Run("run writes everything on shutdown", func(t *testing.T) {
+		cache, checkpoint := newMemCursorStore(), newMemCursorStore()
+		tracker := app.NewCursorTracker(app.CursorTrackerConfig{
+			Cache:              cache,
+			Checkpoint:         checkpoint,
+			Logger:             slog.Default(),
+			FlushInterval:      time.Hour,
+			CheckpointInterval: time.Hour,
+		})
+		tracker.Advance(deviceCursor("chat-1", 5))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() { defer close(done); tracker.Run(runCtx) }()
+		cancel()
+		<-done
+
+		assert.Equal(t, uint64(5), cache.get(key))
+		assert.Equal(t, uint64(5), checkpoint.get(key))
+	})
+}
+
+func TestSyncHandler_Cursors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  uint64 // zero is no cursor
+		claimed uint64
+		want    []uint64
+	}{
+		{name: "a claim past the cursor starts at the cursor", cursor: 2, claimed: 4, want: []uint64{3, 4, 5}},
+		{name: "a claim short of the cursor is honoured", cursor: 4, claimed: 1, want: []uint64{2, 3, 4, 5}},
+		{name: "without a cursor the claim is used", claimed: 3, want: []uint64{4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkpoint := newMemCursorStore()
+			if tt.cursor > 0 {
+				checkpoint.cursors["user-001/device-001/chat-1"] = tt.cursor
+			}
+			h := newSessionHarness()
+			h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+				protocol.FrameTypeSyncRequest: app.NewSyncHandler(app.SyncHandlerConfig{
+					Store:   &fakeSyncStore{latest: 5},
+					Members: stubMembers{member: true},
+					Cursors: newCursorTracker(newMemCursorStore(), checkpoint),
+				}),
+			}
+			tr := newFakeTransport()
+			done := h.serve(context.Background(), tr)
+			tr.next(t) // ack
+
+			tr.push(t, protocol.FrameTypeSyncRequest, protocol.SyncRequest{ChatID: "chat-1", LastAckedSequence: tt.claimed})
+
+			var resp protocol.SyncResponse
+			require.NoError(t, tr.next(t).ParsePayload(&resp))
+			assert.Equal(t, tt.want, seqs(resp.Messages))
+
+			tr.hangUp()
+			require.NoError(t, wait(t, done))
+		})
+	}
+}
+
+func TestSessionManager_AcksAdvanceCursors(t *testing.T) {
+	cache := newMemCursorStore()
+	tracker := newCursorTracker(cache, newMemCursorStore())
+	h := newSessionHarness()
+	h.cfg.Cursors = tracker
+	tr := newFakeTransport()
+	done := h.serve(context.Background(), tr)
+	tr.next(t) // ack
+
+	conns := h.registry.UserConnections("user-001")
+	require.Len(t, conns, 1)
+	for seq := uint64(1); seq <= 7; seq++ {
+		require.NoError(t, conns[0].Deliver(context.Background(), protocol.Message{
+			MessageID: fmt.Sprintf("msg-%d", seq), ChatID: "chat-1", Sequence: seq,
+		}))
+	}
+
+	// Acks past what was sent are capped; acks for chats never sent are
+	// ignored.
+	tr.push(t, protocol.FrameTypeAck, protocol.Ack{ChatID: "chat-2", Sequence: 50})
+	tr.push(t, protocol.FrameTypeAck, protocol.Ack{ChatID: "chat-1", Sequence: 1000})
+
+	require.Eventually(t, func() bool {
+		seq, _, err := tracker.Cursor(context.Background(), "user-001", "device-001", "chat-1")
+		return err == nil && seq == 7
+	}, time.Second, 5*time.Millisecond)
+	_, ok, err := tracker.Cursor(context.Background(), "user-001", "device-001", "chat-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	tr.hangUp()
+	require.NoError(t, wait(t, done))
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var fanoutDeliveries metric.Int64Counter
+
+func init() {
+	fanoutDeliveries, _ = otel.Meter("gateway/app").Int64Counter("gateway_fanout_deliveries_total",
+		metric.WithDescription("Messages from Fanout handed to a recipient's connections on this instance, by result (delivered, offline, failed)"))
+}
+
+// Delivery is a message or activity signal Fanout routed to this Gateway
+// instance for some of its recipients (ADR-002 §3.4).
+type Delivery struct {
+	UserIDs []string
+	Message protocol.Message
+	// Payload is Message's JSON encoding as Fanout serialized it. When set,
+	// it is sent to clients as is instead of encoding Message again.
+	Payload json.RawMessage
+	// Signal is set instead of Message for an activity signal or a
+	// notification: the typing, receipt, presence or notification frame
+	// recipients get. Fanout has already applied privacy settings.
+	Signal *protocol.Frame
+}
+
+// RouteStore is the fleet-wide routing table Fanout reads (ADR-010 §1.2):
+// which Gateway instances each user is connected to. Routes expire ttl
+// after they were last advertised, so an instance that dies stops being
+// routed to.
+type RouteStore interface {
+	Advertise(ctx context.Context, instanceID string, userIDs []string, ttl time.Duration) error
+	Withdraw(ctx context.Context, instanceID, userID string) error
+}
+
+// DeliveryRouterConfig holds the dependencies for DeliveryRouter.
+type DeliveryRouterConfig struct {
+	Registry   *Registry
+	Routes     RouteStore
+	InstanceID string
+	Logger     *slog.Logger
+
+	// Signals, when set, receives a presence signal as a user's first
+	// connection here opens and their last closes. Clock stamps last-seen
+	// times; nil uses the real clock.
+	Signals SignalPublisher
+	Clock   domain.Clock
+
+	// Zero values default to domain.ConnectionTTL and
+	// domain.HeartbeatInterval.
+	TTL           time.Duration
+	RenewInterval time.Duration
+}
+
+// DeliveryRouter connects Fanout to this instance's connections. Users are
+// advertised in the routing table as they connect and withdrawn when their
+// last connection here closes; every renew interval all connected users
+// are advertised again, which also repairs routes lost to a Redis outage.
+// Deliveries are handed to each recipient's connections. Delivery is best
+// effort: a recipient this instance no longer holds, or whose connection
+// is too slow, catches up with sync. Safe for concurrent use.
+type DeliveryRouter struct {
+	registry      *Registry
+	routes        RouteStore
+	instanceID    string
+	logger        *slog.Logger
+	signals       SignalPublisher
+	clock         domain.Clock
+	ttl           time.Duration
+	renewInterval time.Duration
+}
+
+// NewDeliveryRouter creates a DeliveryRouter with the given dependencies.
+func NewDeliveryRouter(cfg DeliveryRouterConfig) *DeliveryRouter {
+	r := &DeliveryRouter{
+		registry:      cfg.Registry,
+		routes:        cfg.Routes,
+		instanceID:    cfg.InstanceID,
+		logger:        cfg.Logger,
+		signals:       cfg.Signals,
+		clock:         cfg.Clock,
+		ttl:           cfg.TTL,
+		renewInterval: cfg.RenewInterval,
+	}
+	if r.clock == nil {
+		r.clock = domain.RealClock{}
+	}
+	if r.ttl == 0 {
+		r.ttl = domain.ConnectionTTL
+	}
+	if r.renewInterval == 0 {
+		r.renewInterval = domain.HeartbeatInterval
+	}
+	return r
+}
+
+// Connected routes userID's messages to this instance, and announces the
+// user online with their first connection here. A failure is only logged;
+// the next renewal advertises the user again.
+func (r *DeliveryRouter) Connected(ctx context.Context, userID string) {
+	if err := r.routes.Advertise(ctx, r.instanceID, []string{userID}, r.ttl); err != nil {
+		r.logger.WarnContext(ctx, "route advertise failed, retrying next renewal",
+			"user_id", userID, "error", err)
+	}
+	if len(r.registry.UserConnections(userID)) == 1 {
+		r.presence(ctx, Signal{Kind: domain.SignalLastSeen, UserID: userID, Online: true})
+	}
+}
+
+// Disconnected stops routing userID's messages to this instance once it
+// holds none of the user's connections, and announces them offline;
+// Fanout withholds that while another instance still holds one. A
+// failure is only logged; the route expires with its TTL.
+func (r *DeliveryRouter) Disconnected(ctx context.Context, userID string) {
+	if len(r.registry.UserConnections(userID)) > 0 {
+		return
+	}
+	if err := r.routes.Withdraw(ctx, r.instanceID, userID); err != nil {
+		r.logger.WarnContext(ctx, "route withdraw failed, expires with its TTL",
+			"user_id", userID, "error", err)
+	}
+	r.presence(ctx, Signal{Kind: domain.SignalLastSeen, UserID: userID, LastSeenAt: r.clock.Now()})
+}
+
+// presence publishes a presence signal, best effort.
+func (r *DeliveryRouter) presence(ctx context.Context, sig Signal) {
+	if r.signals == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, domain.SignalTimeout)
+	defer cancel()
+	if err := r.signals.PublishSignal(ctx, sig); err != nil {
+		r.logger.DebugContext(ctx, "presence dropped", "user_id", sig.UserID, "online", sig.Online, "error", err)
+	}
+}
+
+// Deliver hands d's message or signal to every connection its users hold
+// on this instance.
+func (r *DeliveryRouter) Deliver(ctx context.Context, d Delivery) {
+	if d.Signal != nil {
+		r.signal(ctx, d)
+		return
+	}
+	ctx, span := tracer.Start(ctx, "gateway.deliver")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("message.chat_id", d.Message.ChatID),
+		attribute.Int64("message.sequence", int64(d.Message.Sequence)), //nolint:gosec // sequences fit in int64
+		attribute.Int("delivery.users", len(d.UserIDs)),
+	)
+
+	// One prepared frame is shared by every connection: the message is
+	// encoded once however many of its recipients are connected here.
+	f := &protocol.Frame{Type: protocol.FrameTypeMessage, Payload: d.Payload}
+	if len(f.Payload) == 0 {
+		var err error
+		if f, err = protocol.NewFrame(protocol.FrameTypeMessage, d.Message); err != nil {
+			r.logger.WarnContext(ctx, "delivery dropped, message unencodable",
+				"message_id", d.Message.MessageID, "error", err)
+			return
+		}
+	}
+	if err := f.Prepare(); err != nil {
+		r.logger.WarnContext(ctx, "delivery dropped, message unencodable",
+			"message_id", d.Message.MessageID, "error", err)
+		return
+	}
+
+	for _, userID := range d.UserIDs {
+		conns := r.registry.UserConnections(userID)
+		if len(conns) == 0 {
+			fanoutDeliveries.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "offline")))
+			continue
+		}
+		for _, c := range conns {
+			result := "delivered"
+			if err := c.deliver(ctx, unacked{msg: d.Message, frame: f}); err != nil {
+				result = "failed"
+				r.logger.DebugContext(ctx, "delivery dropped, client will sync",
+					"connection_id", c.ID(), "message_id", d.Message.MessageID, "error", err)
+			}
+			fanoutDeliveries.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+		}
+	}
+}
+
+// signal queues d's signal frame on every connection its users hold here.
+// Signals are not acked: they take the ephemeral or receipt lane, and a
+// client that misses one is not sent it again.
+func (r *DeliveryRouter) signal(ctx context.Context, d Delivery) {
+	if err := d.Signal.Prepare(); err != nil {
+		r.logger.WarnContext(ctx, "signal dropped, frame unencodable",
+			"frame_type", string(d.Signal.Type), "error", err)
+		return
+	}
+	for _, userID := range d.UserIDs {
+		for _, c := range r.registry.UserConnections(userID) {
+			_ = c.Enqueue(d.Signal) // a closed or overflowing connection has nothing to catch up
+		}
+	}
+}
+
+// Renew advertises every user connected to this instance again.
+func (r *DeliveryRouter) Renew(ctx context.Context) error {
+	seen := make(map[string]struct{})
+	var users []string
+	for _, c := range r.registry.Snapshot() {
+		userID := c.Identity().UserID
+		if _, ok := seen[userID]; ok {
+			continue
+		}
+		seen[userID] = struct{}{}
+		users = append(users, userID)
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	if err := r.routes.Advertise(ctx, r.instanceID, users, r.ttl); err != nil {
+		return fmt.Errorf("renew routes for %d users: %w", len(users), err)
+	}
+	return nil
+}
+
+// Run renews the routes every renew interval until ctx is cancelled.
+func (r *DeliveryRouter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Renew(ctx); err != nil {
+				r.logger.WarnContext(ctx, "route renewal failed, retrying next interval", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// memRoutes is an in-memory RouteStore.
+type memRoutes struct {
+	mu        sync.Mutex
+	routes    map[string][]string // user ID -> instance IDs
+	advertise [][]string          // user IDs of each Advertise call
+	err       error
+}
+
+func newMemRoutes() *memRoutes { return &memRoutes{routes: make(map[string][]string)} }
+
+func (s *memRoutes) Advertise(_ context.Context, instanceID string, userIDs []string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.advertise = append(s.advertise, slices.Clone(userIDs))
+	for _, u := range userIDs {
+		if !slices.Contains(s.routes[u], instanceID) {
+			s.routes[u] = append(s.routes[u], instanceID)
+		}
+	}
+	return nil
+}
+
+func (s *memRoutes) Withdraw(_ context.Context, instanceID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[userID] = slices.DeleteFunc(s.routes[userID], func(id string) bool { return id == instanceID })
+	return nil
+}
+
+func (s *memRoutes) get(userID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.routes[userID]
+}
+
+func newTestRouter(registry *Registry, routes RouteStore) *DeliveryRouter {
+	return NewDeliveryRouter(DeliveryRouterConfig{
+		Registry:   registry,
+		Routes:     routes,
+		InstanceID: "gw-1",
+		Logger:     slog.Default(),
+	})
+}
+
+func TestDeliveryRouter_Deliver(t *testing.T) {
+	registry := NewRegistry()
+	phone := newConnection("conn-1", Identity{UserID: "user-001", DeviceID: "phone"}, testStart, 4)
+	laptop := newConnection("conn-2", Identity{UserID: "user-001", DeviceID: "laptop"}, testStart, 4)
+	other := newConnection("conn-3", Identity{UserID: "user-002"}, testStart, 4)
+	for _, c := range []*Connection{phone, laptop, other} {
+		registry.Add(c)
+	}
+	r := newTestRouter(registry, newMemRoutes())
+	msg := protocol.Message{MessageID: "msg-1", ChatID: "chat-1", Sequence: 3}
+
+	r.Deliver(context.Background(), Delivery{UserIDs: []string{"user-001", "user-offline"}, Message: msg})
+
+	for _, c := range []*Connection{phone, laptop} {
+		f, ok := c.dequeue()
+		require.True(t, ok, "every connection of a recipient gets the message")
+		assert.Equal(t, protocol.FrameTypeMessage, f.Type)
+		var got protocol.Message
+		require.NoError(t, f.ParsePayload(&got))
+		assert.Equal(t, msg, got)
+		assert.Equal(t, 1, c.Unacked())
+	}
+	_, ok := other.dequeue()
+	assert.False(t, ok, "users not in the delivery get nothing")
+}
+
+func TestDeliveryRouter_DeliverSharesOneFrame(t *testing.T) {
+	registry := NewRegistry()
+	phone := newConnection("conn-1", Identity{UserID: "user-001", DeviceID: "phone"}, testStart, 4)
+	laptop := newConnection("conn-2", Identity{UserID: "user-001", DeviceID: "laptop"}, testStart, 4)
+	registry.Add(phone)
+	registry.Add(laptop)
+	r := newTestRouter(registry, newMemRoutes())
+	payload := json.RawMessage(`{"message_id":"msg-1","chat_id":"chat-1","sequence":3}`)
+
+	r.Deliver(context.Background(), Delivery{
+		UserIDs: []string{"user-001"},
+		Message: protocol.Message{MessageID: "msg-1", ChatID: "chat-1", Sequence: 3},
+		Payload: payload,
+	})
+
+	first, ok := phone.dequeue()
+	require.True(t, ok)
+	second, ok := laptop.dequeue()
+	require.True(t, ok)
+	assert.Same(t, first, second, "connections share the prepared frame")
+	encoded, err := protocol.AppendFrame(nil, first)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"message","payload":`+string(payload)+`}`, string(encoded), "Fanout's encoding is sent as is")
+}
+
+func TestDeliveryRouter_DeliverSignal(t *testing.T) {
+	registry := NewRegistry()
+	phone := newConnection("conn-1", Identity{UserID: "user-001", DeviceID: "phone"}, testStart, 4)
+	other := newConnection("conn-2", Identity{UserID: "user-002"}, testStart, 4)
+	registry.Add(phone)
+	registry.Add(other)
+	r := newTestRouter(registry, newMemRoutes())
+	typing, err := protocol.NewFrame(protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-1", UserID: "user-003", Active: true})
+	require.NoError(t, err)
+
+	r.Deliver(context.Background(), Delivery{UserIDs: []string{"user-001"}, Signal: typing})
+
+	f, ok := phone.dequeue()
+	require.True(t, ok)
+	assert.Same(t, typing, f, "the signal frame is sent as is")
+	assert.Zero(t, phone.Unacked(), "signals are not acked")
+	_, ok = other.dequeue()
+	assert.False(t, ok)
+}
+
+func TestDeliveryRouter_Presence(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry()
+	signals := &recordingSignals{}
+	clock := domaintest.NewFakeClock(testStart)
+	r := NewDeliveryRouter(DeliveryRouterConfig{
+		Registry:   registry,
+		Routes:     newMemRoutes(),
+		InstanceID: "gw-1",
+		Logger:     slog.Default(),
+		Signals:    signals,
+		Clock:      clock,
+	})
+	first := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+	second := newConnection("conn-2", Identity{UserID: "user-001"}, testStart, 4)
+
+	registry.Add(first)
+	r.Connected(ctx, "user-001")
+	registry.Add(second)
+	r.Connected(ctx, "user-001")
+	registry.Remove(first)
+	r.Disconnected(ctx, "user-001")
+	clock.Advance(time.Minute)
+	registry.Remove(second)
+	r.Disconnected(ctx, "user-001")
+
+	assert.Equal(t, []Signal{
+		{Kind: domain.SignalLastSeen, UserID: "user-001", Online: true},
+		{Kind: domain.SignalLastSeen, UserID: "user-001", LastSeenAt: testStart.Add(time.Minute)},
+	}, signals.signals, "announced with the first connection and the last")
+}
+
+func TestDeliveryRouter_Routes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("withdrawn with the user's last connection", func(t *testing.T) {
+		registry := NewRegistry()
+		routes := newMemRoutes()
+		r := newTestRouter(registry, routes)
+		first := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+		second := newConnection("conn-2", Identity{UserID: "user-001"}, testStart, 4)
+
+		registry.Add(first)
+		r.Connected(ctx, "user-001")
+		registry.Add(second)
+		r.Connected(ctx, "user-001")
+		assert.Equal(t, []string{"gw-1"}, routes.get("user-001"))
+
+		registry.Remove(first)
+		r.Disconnected(ctx, "user-001")
+		assert.Equal(t, []string{"gw-1"}, routes.get("user-001"), "a remaining connection keeps the route")
+
+		registry.Remove(second)
+		r.Disconnected(ctx, "user-001")
+		assert.Empty(t, routes.get("user-001"))
+	})
+
+	t.Run("renewal advertises each connected user once", func(t *testing.T) {
+		registry := NewRegistry()
+		routes := newMemRoutes()
+		r := newTestRouter(registry, routes)
+		registry.Add(newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4))
+		registry.Add(newConnection("conn-2", Identity{UserID: "user-001"}, testStart, 4))
+		registry.Add(newConnection("conn-3", Identity{UserID: "user-002"}, testStart, 4))
+
+		require.NoError(t, r.Renew(ctx))
+
+		require.Len(t, routes.advertise, 1)
+		assert.ElementsMatch(t, []string{"user-001", "user-002"}, routes.advertise[0])
+	})
+
+	t.Run("a failed advertise is left to the next renewal", func(t *testing.T) {
+		routes := newMemRoutes()
+		routes.err = errors.New("redis down")
+		r := newTestRouter(NewRegistry(), routes)
+
+		r.Connected(ctx, "user-001")
+
+		assert.Empty(t, routes.get("user-001"))
+	})
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// ErrServerShutdown closes connections drained during shutdown. It matches
+// ErrUnavailable so clients reconnect to another Gateway instance.
+var ErrServerShutdown = fmt.Errorf("server shutting down: %w", domain.ErrUnavailable)
+
+// DrainSemaphore bounds how many Gateway instances drain at once across the
+// fleet. Slots are leased for ttl so a crashed holder cannot block others.
+type DrainSemaphore interface {
+	TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, holder string) error
+}
+
+// DrainCoordinatorConfig holds the dependencies for DrainCoordinator.
+type DrainCoordinatorConfig struct {
+	Registry   *Registry
+	Semaphore  DrainSemaphore
+	Logger     *slog.Logger
+	InstanceID string // Semaphore holder; unique per Gateway pod
+
+	// Zero values default to the ADR-014 limits in domain.
+	Wait         time.Duration // How long to wait for a slot before draining anyway
+	SlotTTL      time.Duration
+	PollInterval time.Duration
+}
+
+// DrainCoordinator closes every connection on this instance during
+// shutdown, after taking a fleet-wide drain slot. During a rolling deploy
+// this keeps reconnect storms to a bounded fraction of the fleet.
+type DrainCoordinator struct {
+	registry     *Registry
+	semaphore    DrainSemaphore
+	logger       *slog.Logger
+	instanceID   string
+	wait         time.Duration
+	slotTTL      time.Duration
+	pollInterval time.Duration
+}
+
+// NewDrainCoordinator creates a DrainCoordinator with the given dependencies.
+func NewDrainCoordinator(cfg DrainCoordinatorConfig) *DrainCoordinator {
+	d := &DrainCoordinator{
+		registry:     cfg.Registry,
+		semaphore:    cfg.Semaphore,
+		logger:       cfg.Logger,
+		instanceID:   cfg.InstanceID,
+		wait:         cfg.Wait,
+		slotTTL:      cfg.SlotTTL,
+		pollInterval: cfg.PollInterval,
+	}
+	if d.wait == 0 {
+		d.wait = domain.DrainSlotWait
+	}
+	if d.slotTTL == 0 {
+		d.slotTTL = domain.DrainSlotTTL
+	}
+	if d.pollInterval == 0 {
+		d.pollInterval = 250 * time.Millisecond
+	}
+	return d
+}
+
+// Drain takes a drain slot, closes every registered connection with
+// ErrServerShutdown, and waits for the sessions to unregister. If no slot
+// frees up within the configured wait, or the semaphore is unreachable, it
+// drains anyway: the pod is going away regardless, and holding connections
+// past the shutdown budget only turns a graceful close into a reset.
+func (d *DrainCoordinator) Drain(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "gateway.drain")
+	defer span.End()
+
+	logger := observability.WithTraceID(ctx, d.logger)
+
+	start := time.Now()
+	acquired := d.acquire(ctx, logger)
+	span.SetAttributes(
+		attribute.Bool("drain.slot_acquired", acquired),
+		attribute.Int("drain.connections", d.registry.Len()),
+	)
+	logger.InfoContext(ctx, "gateway.drain_started",
+		"slot_acquired", acquired,
+		"slot_wait_ms", time.Since(start).Milliseconds(),
+		"connections", d.registry.Len(),
+	)
+
+	if acquired {
+		defer func() {
+			// Release even when ctx is done so the next pod is not held up
+			// for the full slot TTL.
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), domain.RedisTimeout)
+			defer cancel()
+			if err := d.semaphore.Release(releaseCtx, d.instanceID); err != nil {
+				logger.WarnContext(ctx, "release drain slot failed", "error", err)
+			}
+		}()
+	}
+
+	for _, c := range d.registry.Snapshot() {
+		c.Close(ErrServerShutdown)
+	}
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for d.registry.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			span.RecordError(ctx.Err())
+			return fmt.Errorf("drain connections: %d remaining: %w", d.registry.Len(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	logger.InfoContext(ctx, "gateway.drain_completed", "duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// acquire polls the semaphore until a slot is granted, the wait elapses, or
+// the semaphore fails. It reports whether a slot is held.
+func (d *DrainCoordinator) acquire(ctx context.Context, logger *slog.Logger) bool {
+	waitCtx, cancel := context.WithTimeout(ctx, d.wait)
+	defer cancel()
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := d.semaphore.TryAcquire(waitCtx, d.instanceID, d.slotTTL)
+		if err != nil {
+			logger.WarnContext(ctx, "drain semaphore unavailable, draining without a slot", "error", err)
+			return false
+		}
+		if ok {
+			return true
+		}
+
+		select {
+		case <-waitCtx.Done():
+			logger.WarnContext(ctx, "no drain slot within wait, draining without a slot", "wait_ms", d.wait.Milliseconds())
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// stubDrainSemaphore implements app.DrainSemaphore with function fields.
+type stubDrainSemaphore struct {
+	tryAcquireFn func(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+
+	mu       sync.Mutex
+	released []string
+}
+
+func (s *stubDrainSemaphore) TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	if s.tryAcquireFn != nil {
+		return s.tryAcquireFn(ctx, holder, ttl)
+	}
+	return true, nil
+}
+
+func (s *stubDrainSemaphore) Release(_ context.Context, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.released = append(s.released, holder)
+	return nil
+}
+
+func (s *stubDrainSemaphore) releasedHolders() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.released...)
+}
+
+func newDrainCoordinator(registry *app.Registry, sem app.DrainSemaphore) *app.DrainCoordinator {
+	return app.NewDrainCoordinator(app.DrainCoordinatorConfig{
+		Registry:     registry,
+		Semaphore:    sem,
+		Logger:       slog.Default(),
+		InstanceID:   "gateway-0",
+		Wait:         50 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	})
+}
+
+func TestDrainCoordinator_Drain(t *testing.T) {
+	t.Run("takes a slot, closes sessions, releases the slot", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+		sem := &stubDrainSemaphore{}
+		sem.tryAcquireFn = func(_ context.Context, holder string, ttl time.Duration) (bool, error) {
+			assert.Equal(t, "gateway-0", holder)
+			assert.Equal(t, domain.DrainSlotTTL, ttl)
+			return true, nil
+		}
+
+		require.NoError(t, newDrainCoordinator(h.registry, sem).Drain(context.Background()))
+
+		err := wait(t, done)
+		require.ErrorIs(t, err, app.ErrServerShutdown)
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+		assert.Zero(t, h.registry.Len())
+		assert.Equal(t, []string{"gateway-0"}, sem.releasedHolders())
+	})
+
+	t.Run("polls until a slot frees up", func(t *testing.T) {
+		calls := 0
+		sem := &stubDrainSemaphore{}
+		sem.tryAcquireFn = func(context.Context, string, time.Duration) (bool, error) {
+			calls++
+			return calls >= 3, nil
+		}
+
+		require.NoError(t, newDrainCoordinator(app.NewRegistry(), sem).Drain(context.Background()))
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, []string{"gateway-0"}, sem.releasedHolders())
+	})
+
+	t.Run("drains anyway when no slot frees up in time", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+		sem := &stubDrainSemaphore{}
+		sem.tryAcquireFn = func(context.Context, string, time.Duration) (bool, error) {
+			return false, nil
+		}
+
+		require.NoError(t, newDrainCoordinator(h.registry, sem).Drain(context.Background()))
+		require.ErrorIs(t, wait(t, done), app.ErrServerShutdown)
+		assert.Empty(t, sem.releasedHolders(), "no slot was held")
+	})
+
+	t.Run("drains anyway when the semaphore fails", func(t *testing.T) {
+		sem := &stubDrainSemaphore{}
+		sem.tryAcquireFn = func(context.Context, string, time.Duration) (bool, error) {
+			return false, errors.New("connection refused")
+		}
+
+		require.NoError(t, newDrainCoordinator(app.NewRegistry(), sem).Drain(context.Background()))
+		assert.Empty(t, sem.releasedHolders())
+	})
+
+	t.Run("context bounds the wait for sessions to unregister", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+		// Wedge the writer so the session cannot finish unregistering.
+		held, release := tr.holdSends()
+		f, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{})
+		require.NoError(t, err)
+		require.NoError(t, h.registry.UserConnections("user-001")[0].Enqueue(f))
+		<-held
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = newDrainCoordinator(h.registry, &stubDrainSemaphore{}).Drain(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release()
+		require.ErrorIs(t, wait(t, done), app.ErrServerShutdown)
+	})
+}
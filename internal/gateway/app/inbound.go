@@ -0,0 +1,43 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// MessageKind is the WebSocket data opcode an inbound message arrived with.
+type MessageKind int
+
+const (
+	TextMessage MessageKind = iota + 1
+	BinaryMessage
+)
+
+// inboundLimits bounds client frames before they are parsed.
+var inboundLimits = protocol.DecodeLimits{
+	MaxBytes: domain.MaxInboundFrameSize,
+	MaxDepth: domain.MaxInboundFrameDepth,
+}
+
+// DecodeClientFrame decodes one WebSocket message from a client, for use
+// in Transport.Recv. Frames are JSON text (ADR-005): binary messages are
+// refused, and so are frames over the domain size and nesting limits. The
+// errors wrap domain.ErrMessageTooLarge or domain.ErrInvalidInput, so a
+// transport that returns them closes the connection with a matching code.
+func DecodeClientFrame(kind MessageKind, data []byte) (*protocol.Frame, error) {
+	if kind != TextMessage {
+		return nil, fmt.Errorf("decode frame: binary messages are not supported: %w", domain.ErrInvalidInput)
+	}
+	f, err := protocol.DecodeFrame(data, inboundLimits)
+	switch {
+	case err == nil:
+		return f, nil
+	case errors.Is(err, protocol.ErrFrameTooLarge):
+		return nil, fmt.Errorf("decode frame: %w: %w", domain.ErrMessageTooLarge, err)
+	default:
+		return nil, fmt.Errorf("decode frame: %w: %w", domain.ErrInvalidInput, err)
+	}
+}
@@ -0,0 +1,92 @@
+package app_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestDecodeClientFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     app.MessageKind
+		data     string
+		wantType protocol.FrameType
+		wantErr  error
+	}{
+		{name: "text frame", kind: app.TextMessage, data: `{"type":"ping","payload":{"timestamp":1}}`, wantType: protocol.FrameTypePing},
+		{name: "binary message", kind: app.BinaryMessage, data: `{"type":"ping"}`, wantErr: domain.ErrInvalidInput},
+		{name: "malformed", kind: app.TextMessage, data: `{"type":`, wantErr: domain.ErrInvalidInput},
+		{name: "not an object", kind: app.TextMessage, data: `"ping"`, wantErr: domain.ErrInvalidInput},
+		{name: "too deep", kind: app.TextMessage, data: `{"type":"x","payload":` + strings.Repeat("[", domain.MaxInboundFrameDepth) + strings.Repeat("]", domain.MaxInboundFrameDepth) + `}`, wantErr: domain.ErrInvalidInput},
+		{name: "too large", kind: app.TextMessage, data: `{"type":"x","payload":"` + strings.Repeat("a", domain.MaxInboundFrameSize) + `"}`, wantErr: domain.ErrMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := app.DecodeClientFrame(tt.kind, []byte(tt.data))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, f.Type)
+		})
+	}
+}
+
+func TestDecodeClientFrame_MaxSizeMessageFits(t *testing.T) {
+	// A message at the size limit whose every byte is escaped still fits.
+	content := strings.Repeat(`\n`, domain.MaxMessageSize)
+	data := `{"type":"send_message","payload":{"chat_id":"c","client_message_id":"m","content_type":"text","content":"` + content + `"}}`
+
+	f, err := app.DecodeClientFrame(app.TextMessage, []byte(data))
+
+	require.NoError(t, err)
+	var msg protocol.SendMessage
+	require.NoError(t, f.ParsePayload(&msg))
+	assert.Len(t, msg.Content, domain.MaxMessageSize)
+}
+
+// FuzzDecodeClientFrame checks that every rejected WebSocket message maps to
+// a client error, so a transport closes the connection with a client close
+// code rather than an internal error.
+func FuzzDecodeClientFrame(f *testing.F) {
+	f.Add(true, []byte(`{"type":"send_message","payload":{"chat_id":"c","content":"hi"}}`))
+	f.Add(true, []byte(`{"type":"ping","payload":{"timestamp":-1}}`))
+	f.Add(true, []byte(`{"type":"x","payload":[[[[[[[[[[[[[[[[[]]]]]]]]]]]]]]]]]}`))
+	f.Add(true, []byte(`{"type":"x","payload":"\ud800"}`))
+	f.Add(false, []byte(`{"type":"ping"}`))
+	f.Add(true, []byte{0xff, 0xfe})
+
+	f.Fuzz(func(t *testing.T, text bool, data []byte) {
+		kind := app.BinaryMessage
+		if text {
+			kind = app.TextMessage
+		}
+		frame, err := app.DecodeClientFrame(kind, data)
+		if err != nil {
+			if !domain.IsClientError(err) {
+				t.Fatalf("not a client error: %v", err)
+			}
+			if errors.Is(err, domain.ErrMessageTooLarge) && len(data) <= domain.MaxInboundFrameSize {
+				t.Fatalf("refused %d bytes as too large", len(data))
+			}
+			return
+		}
+		if !text {
+			t.Fatal("accepted a binary message")
+		}
+		if frame == nil {
+			t.Fatal("nil frame without an error")
+		}
+	})
+}
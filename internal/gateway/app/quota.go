@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var connectionQuotaRejected metric.Int64Counter
+
+func init() {
+	connectionQuotaRejected, _ = otel.Meter("gateway/app").Int64Counter("gateway_connection_quota_rejected_total",
+		metric.WithDescription("New connections refused by a concurrent connection quota, by scope (user, device, ip)"))
+}
+
+// QuotaScope names the connection quota a connection was refused under.
+type QuotaScope string
+
+// Connection quota scopes.
+const (
+	QuotaScopeUser   QuotaScope = "user"
+	QuotaScopeDevice QuotaScope = "device"
+	QuotaScopeIP     QuotaScope = "ip"
+)
+
+// ConnectionLease is one connection's entry in the fleet-wide connection
+// registry. Scopes whose field is empty are not counted: a connection
+// without a device or a known client address only counts against its user.
+type ConnectionLease struct {
+	ConnectionID string
+	UserID       string
+	DeviceID     string
+	ClientIP     string
+}
+
+// ConnectionLimits caps concurrent connections per scope.
+type ConnectionLimits struct {
+	PerUser   int
+	PerDevice int
+	PerIP     int
+}
+
+// ConnectionLeaseStore is the connection registry shared by every Gateway
+// pod. Acquire checks every scope and takes the lease in one atomic step:
+// it returns the first scope already at its limit without taking anything,
+// or "" once the lease is held. Leases expire ttl after they were last
+// acquired or renewed, so a pod that dies frees its connections' slots.
+type ConnectionLeaseStore interface {
+	Acquire(ctx context.Context, lease ConnectionLease, limits ConnectionLimits, ttl time.Duration) (QuotaScope, error)
+	Renew(ctx context.Context, leases []ConnectionLease, ttl time.Duration) error
+	Release(ctx context.Context, lease ConnectionLease) error
+}
+
+// ConnectionQuotasConfig holds the dependencies for ConnectionQuotas.
+type ConnectionQuotasConfig struct {
+	Store  ConnectionLeaseStore
+	Logger *slog.Logger
+
+	// Zero limits default to domain.MaxConnectionsPerUser,
+	// domain.MaxConnectionsPerDevice and domain.MaxConnectionsPerIP.
+	Limits ConnectionLimits
+
+	// Zero values default to domain.ConnectionTTL and
+	// domain.HeartbeatInterval.
+	TTL           time.Duration
+	RenewInterval time.Duration
+}
+
+// ConnectionQuotas enforces concurrent connection limits per user, device
+// and client address across the fleet (ADR-009 §3). Each admitted
+// connection holds a lease in the registry until it closes; this pod
+// renews its leases every renew interval. Safe for concurrent use.
+type ConnectionQuotas struct {
+	store         ConnectionLeaseStore
+	logger        *slog.Logger
+	limits        ConnectionLimits
+	ttl           time.Duration
+	renewInterval time.Duration
+
+	mu   sync.Mutex
+	held map[string]ConnectionLease // by connection ID
+}
+
+// NewConnectionQuotas creates a ConnectionQuotas with the given dependencies.
+func NewConnectionQuotas(cfg ConnectionQuotasConfig) *ConnectionQuotas {
+	q := &ConnectionQuotas{
+		store:         cfg.Store,
+		logger:        cfg.Logger,
+		limits:        cfg.Limits,
+		ttl:           cfg.TTL,
+		renewInterval: cfg.RenewInterval,
+		held:          make(map[string]ConnectionLease),
+	}
+	if q.limits.PerUser == 0 {
+		q.limits.PerUser = domain.MaxConnectionsPerUser
+	}
+	if q.limits.PerDevice == 0 {
+		q.limits.PerDevice = domain.MaxConnectionsPerDevice
+	}
+	if q.limits.PerIP == 0 {
+		q.limits.PerIP = domain.MaxConnectionsPerIP
+	}
+	if q.ttl == 0 {
+		q.ttl = domain.ConnectionTTL
+	}
+	if q.renewInterval == 0 {
+		q.renewInterval = domain.HeartbeatInterval
+	}
+	return q
+}
+
+// Acquire takes a lease for a new connection, or refuses it with the
+// domain error of the first quota it would exceed. A registry failure
+// admits the connection: quotas are abuse control, and an unreachable
+// registry must not refuse every client. The lease is still held, so the
+// next renewal registers it once the registry recovers.
+func (q *ConnectionQuotas) Acquire(ctx context.Context, lease ConnectionLease) error {
+	scope, err := q.store.Acquire(ctx, lease, q.limits, q.ttl)
+	switch {
+	case err != nil:
+		q.logger.WarnContext(ctx, "connection registry unavailable, admitting without quota check",
+			"user_id", lease.UserID, "error", err)
+	case scope != "":
+		connectionQuotaRejected.Add(ctx, 1, metric.WithAttributes(attribute.String("scope", string(scope))))
+		return q.rejection(scope)
+	}
+
+	q.mu.Lock()
+	q.held[lease.ConnectionID] = lease
+	q.mu.Unlock()
+	return nil
+}
+
+// rejection returns the error a connection refused under scope closes with.
+func (q *ConnectionQuotas) rejection(scope QuotaScope) error {
+	switch scope {
+	case QuotaScopeDevice:
+		return fmt.Errorf("%w: limit %d", domain.ErrDeviceConnectionLimit, q.limits.PerDevice)
+	case QuotaScopeIP:
+		return fmt.Errorf("%w: limit %d", domain.ErrIPConnectionLimit, q.limits.PerIP)
+	default:
+		return fmt.Errorf("%w: limit %d", domain.ErrUserConnectionLimit, q.limits.PerUser)
+	}
+}
+
+// Release gives up a closed connection's lease. A failed release is only
+// logged; the lease expires within the TTL.
+func (q *ConnectionQuotas) Release(ctx context.Context, lease ConnectionLease) {
+	q.mu.Lock()
+	delete(q.held, lease.ConnectionID)
+	q.mu.Unlock()
+
+	if err := q.store.Release(ctx, lease); err != nil {
+		q.logger.WarnContext(ctx, "connection lease release failed, expires with its TTL",
+			"connection_id", lease.ConnectionID, "error", err)
+	}
+}
+
+// Held reports the number of leases this pod holds.
+func (q *ConnectionQuotas) Held() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.held)
+}
+
+// Renew extends every lease this pod holds by the TTL.
+func (q *ConnectionQuotas) Renew(ctx context.Context) error {
+	q.mu.Lock()
+	leases := make([]ConnectionLease, 0, len(q.held))
+	for _, l := range q.held {
+		leases = append(leases, l)
+	}
+	q.mu.Unlock()
+
+	if len(leases) == 0 {
+		return nil
+	}
+	if err := q.store.Renew(ctx, leases, q.ttl); err != nil {
+		return fmt.Errorf("renew %d connection leases: %w", len(leases), err)
+	}
+	return nil
+}
+
+// Run renews the held leases every renew interval until ctx is cancelled.
+// Leases are released by their connections as they close, not here.
+func (q *ConnectionQuotas) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.Renew(ctx); err != nil {
+				q.logger.WarnContext(ctx, "connection lease renewal failed, retrying next interval", "error", err)
+			}
+		}
+	}
+}
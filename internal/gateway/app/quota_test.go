@@ -0,0 +1,234 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+// memLeaseStore implements app.ConnectionLeaseStore over maps of
+// connection IDs per scope. Leases never expire. err, when set, fails
+// every call.
+type memLeaseStore struct {
+	mu      sync.Mutex
+	scopes  map[string]map[string]bool // scope key -> connection IDs
+	renewed []app.ConnectionLease
+	err     error
+}
+
+func newMemLeaseStore() *memLeaseStore {
+	return &memLeaseStore{scopes: make(map[string]map[string]bool)}
+}
+
+func (s *memLeaseStore) keys(l app.ConnectionLease) map[app.QuotaScope]string {
+	keys := map[app.QuotaScope]string{app.QuotaScopeUser: "user/" + l.UserID}
+	if l.DeviceID != "" {
+		keys[app.QuotaScopeDevice] = "device/" + l.UserID + "/" + l.DeviceID
+	}
+	if l.ClientIP != "" {
+		keys[app.QuotaScopeIP] = "ip/" + l.ClientIP
+	}
+	return keys
+}
+
+func (s *memLeaseStore) Acquire(_ context.Context, l app.ConnectionLease, limits app.ConnectionLimits, _ time.Duration) (app.QuotaScope, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	keys := s.keys(l)
+	for _, scope := range []app.QuotaScope{app.QuotaScopeUser, app.QuotaScopeDevice, app.QuotaScopeIP} {
+		key, ok := keys[scope]
+		if !ok {
+			continue
+		}
+		limit := map[app.QuotaScope]int{
+			app.QuotaScopeUser: limits.PerUser, app.QuotaScopeDevice: limits.PerDevice, app.QuotaScopeIP: limits.PerIP,
+		}[scope]
+		if len(s.scopes[key]) >= limit {
+			return scope, nil
+		}
+	}
+	for _, key := range keys {
+		if s.scopes[key] == nil {
+			s.scopes[key] = make(map[string]bool)
+		}
+		s.scopes[key][l.ConnectionID] = true
+	}
+	return "", nil
+}
+
+func (s *memLeaseStore) Renew(_ context.Context, leases []app.ConnectionLease, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.renewed = append(s.renewed, leases...)
+	return nil
+}
+
+func (s *memLeaseStore) Release(_ context.Context, l app.ConnectionLease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, key := range s.keys(l) {
+		delete(s.scopes[key], l.ConnectionID)
+	}
+	return nil
+}
+
+func (s *memLeaseStore) count(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.scopes[key])
+}
+
+func (s *memLeaseStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func newConnectionQuotas(store *memLeaseStore) *app.ConnectionQuotas {
+	return app.NewConnectionQuotas(app.ConnectionQuotasConfig{
+		Store:  store,
+		Logger: slog.Default(),
+		Limits: app.ConnectionLimits{PerUser: 3, PerDevice: 1, PerIP: 2},
+	})
+}
+
+func TestConnectionQuotas(t *testing.T) {
+	ctx := context.Background()
+	lease := func(id, device, ip string) app.ConnectionLease {
+		return app.ConnectionLease{ConnectionID: id, UserID: "user-001", DeviceID: device, ClientIP: ip}
+	}
+
+	t.Run("each scope refuses with its own error", func(t *testing.T) {
+		quotas := newConnectionQuotas(newMemLeaseStore())
+
+		require.NoError(t, quotas.Acquire(ctx, lease("conn-1", "device-a", "10.0.0.1")))
+		assert.ErrorIs(t, quotas.Acquire(ctx, lease("conn-2", "device-a", "10.0.0.2")), domain.ErrDeviceConnectionLimit)
+		require.NoError(t, quotas.Acquire(ctx, lease("conn-3", "device-b", "10.0.0.1")))
+		assert.ErrorIs(t, quotas.Acquire(ctx, lease("conn-4", "device-c", "10.0.0.1")), domain.ErrIPConnectionLimit)
+		require.NoError(t, quotas.Acquire(ctx, lease("conn-5", "device-c", "")))
+		assert.ErrorIs(t, quotas.Acquire(ctx, lease("conn-6", "device-d", "")), domain.ErrUserConnectionLimit)
+
+		assert.Equal(t, 3, quotas.Held())
+	})
+
+	t.Run("release frees the slot", func(t *testing.T) {
+		store := newMemLeaseStore()
+		quotas := newConnectionQuotas(store)
+		first := lease("conn-1", "device-a", "")
+		require.NoError(t, quotas.Acquire(ctx, first))
+
+		quotas.Release(ctx, first)
+
+		assert.Zero(t, quotas.Held())
+		assert.Zero(t, store.count("user/user-001"))
+		assert.NoError(t, quotas.Acquire(ctx, lease("conn-2", "device-a", "")))
+	})
+
+	t.Run("an unreachable registry admits the connection and renews it later", func(t *testing.T) {
+		store := newMemLeaseStore()
+		quotas := newConnectionQuotas(store)
+		store.fail(errors.New("connection refused"))
+
+		require.NoError(t, quotas.Acquire(ctx, lease("conn-1", "device-a", "")))
+		require.Error(t, quotas.Renew(ctx))
+
+		store.fail(nil)
+		require.NoError(t, quotas.Renew(ctx))
+		require.Len(t, store.renewed, 1)
+		assert.Equal(t, "conn-1", store.renewed[0].ConnectionID)
+	})
+
+	t.Run("zero limits default to the ADR-009 limits", func(t *testing.T) {
+		quotas := app.NewConnectionQuotas(app.ConnectionQuotasConfig{Store: newMemLeaseStore(), Logger: slog.Default()})
+
+		for i := range domain.MaxConnectionsPerUser {
+			require.NoError(t, quotas.Acquire(ctx, lease(string(rune('a'+i)), "", "")))
+		}
+		assert.ErrorIs(t, quotas.Acquire(ctx, lease("one-more", "", "")), domain.ErrUserConnectionLimit)
+	})
+
+	t.Run("run renews on its interval", func(t *testing.T) {
+		store := newMemLeaseStore()
+		quotas := app.NewConnectionQuotas(app.ConnectionQuotasConfig{
+			Store:         store,
+			Logger:        slog.Default(),
+			RenewInterval: 5 * time.Millisecond,
+		})
+		require.NoError(t, quotas.Acquire(ctx, lease("conn-1", "device-a", "")))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() { defer close(done); quotas.Run(runCtx) }()
+
+		require.Eventually(t, func() bool {
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			return len(store.renewed) > 0
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+		<-done
+	})
+}
+
+// addressTransport is a fakeTransport that knows its client's address.
+type addressTransport struct {
+	*fakeTransport
+	ip string
+}
+
+func (t *addressTransport) ClientIP() string { return t.ip }
+
+func TestSessionManager_ConnectionQuotas(t *testing.T) {
+	t.Run("a connection over quota is refused before connection_ack", func(t *testing.T) {
+		store := newMemLeaseStore()
+		h := newSessionHarness()
+		h.cfg.Quotas = newConnectionQuotas(store)
+		first := newFakeTransport()
+		done := h.serve(context.Background(), first)
+		first.next(t) // ack
+
+		second := newFakeTransport()
+		err := wait(t, h.serve(context.Background(), second))
+
+		assert.ErrorIs(t, err, domain.ErrDeviceConnectionLimit)
+		assert.Empty(t, second.sent, "no frames before refusal")
+
+		first.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("the lease is counted by client address and released on close", func(t *testing.T) {
+		store := newMemLeaseStore()
+		h := newSessionHarness()
+		h.cfg.Quotas = newConnectionQuotas(store)
+		tr := &addressTransport{fakeTransport: newFakeTransport(), ip: "203.0.113.7"}
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		assert.Equal(t, 1, store.count("ip/203.0.113.7"))
+		assert.Equal(t, 1, store.count("device/user-001/device-001"))
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+		assert.Zero(t, store.count("ip/203.0.113.7"))
+		assert.Zero(t, store.count("user/user-001"))
+	})
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"context"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// ReadFunc receives each result of Transport.Recv. It returns false to stop
+// reading; after a non-nil error it is not called again.
+type ReadFunc func(f *protocol.Frame, err error) bool
+
+// ReadScheduler runs the inbound side of sessions. The default dedicates a
+// goroutine per connection, blocked in Recv; a pooled scheduler (see
+// adapter.EpollReader) instead waits for readiness on all sockets at once
+// and reads with a bounded worker pool, trading a little latency under load
+// for far less stack memory at very high connection counts.
+type ReadScheduler interface {
+	// Start begins delivering frames from t to read. It must not block. Reads
+	// stop when read returns false, Recv fails, or ctx is done.
+	Start(ctx context.Context, t Transport, read ReadFunc)
+}
+
+// GoroutineReader is the default ReadScheduler: one goroutine per connection.
+type GoroutineReader struct{}
+
+// Start runs the read loop in a new goroutine. A panic in Recv ends the
+// loop as a read error.
+func (GoroutineReader) Start(ctx context.Context, t Transport, read ReadFunc) {
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				read(nil, observability.HandlePanic(ctx, "gateway_read_loop", v))
+			}
+		}()
+		for {
+			f, err := t.Recv(ctx)
+			if !read(f, err) || err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// Compile-time interface check.
+var _ ReadScheduler = GoroutineReader{}
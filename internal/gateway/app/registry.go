@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	registryLockWait metric.Float64Histogram
+
+	// Pre-built so recording a contended wait does not allocate.
+	idIndexAttr   = metric.WithAttributes(attribute.String("index", "id"))
+	userIndexAttr = metric.WithAttributes(attribute.String("index", "user"))
+)
+
+func init() {
+	m := otel.Meter("gateway/app")
+
+	registryLockWait, _ = m.Float64Histogram("gateway_registry_lock_wait_seconds",
+		metric.WithDescription("Time spent waiting for a contended registry shard lock, by index"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1))
+}
+
+// Registry indexes the live connections on this Gateway instance by
+// connection ID and by user. Every transport registers here, so delivery and
+// shutdown treat WebSocket and gRPC stream clients alike. Safe for concurrent use.
+//
+// Both indexes are split into domain.RegistryShards independently locked
+// shards, so at 100k connections per pod delivery lookups and connection
+// churn rarely contend. A connection's ID shard and user shard differ;
+// writers always lock the ID shard first, then the user shard.
+type Registry struct {
+	seed   maphash.Seed
+	byID   []idShard
+	byUser []userShard
+	count  atomic.Int64
+}
+
+type idShard struct {
+	mu    sync.RWMutex
+	conns map[string]*Connection
+}
+
+type userShard struct {
+	mu    sync.RWMutex
+	users map[string]map[string]*Connection
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	r := &Registry{
+		seed:   maphash.MakeSeed(),
+		byID:   make([]idShard, domain.RegistryShards),
+		byUser: make([]userShard, domain.RegistryShards),
+	}
+	for i := range r.byID {
+		r.byID[i].conns = make(map[string]*Connection)
+		r.byUser[i].users = make(map[string]map[string]*Connection)
+	}
+	return r
+}
+
+// Add registers c.
+func (r *Registry) Add(c *Connection) {
+	ids := r.idShard(c.id)
+	lock(&ids.mu, idIndexAttr)
+	defer ids.mu.Unlock()
+
+	if prev, ok := ids.conns[c.id]; ok {
+		r.removeUser(prev)
+	} else {
+		r.count.Add(1)
+	}
+	ids.conns[c.id] = c
+
+	users := r.userShard(c.identity.UserID)
+	lock(&users.mu, userIndexAttr)
+	defer users.mu.Unlock()
+
+	userConns, ok := users.users[c.identity.UserID]
+	if !ok {
+		userConns = make(map[string]*Connection)
+		users.users[c.identity.UserID] = userConns
+	}
+	userConns[c.id] = c
+}
+
+// Remove unregisters c. Removing an unknown connection is a no-op.
+func (r *Registry) Remove(c *Connection) {
+	ids := r.idShard(c.id)
+	lock(&ids.mu, idIndexAttr)
+	defer ids.mu.Unlock()
+
+	if ids.conns[c.id] != c {
+		return
+	}
+	delete(ids.conns, c.id)
+	r.count.Add(-1)
+	r.removeUser(c)
+}
+
+// removeUser drops c from the user index. The caller holds c's ID shard.
+func (r *Registry) removeUser(c *Connection) {
+	users := r.userShard(c.identity.UserID)
+	lock(&users.mu, userIndexAttr)
+	defer users.mu.Unlock()
+
+	if userConns := users.users[c.identity.UserID]; userConns != nil && userConns[c.id] == c {
+		delete(userConns, c.id)
+		if len(userConns) == 0 {
+			delete(users.users, c.identity.UserID)
+		}
+	}
+}
+
+// Get returns the connection with the given ID.
+func (r *Registry) Get(connectionID string) (*Connection, bool) {
+	ids := r.idShard(connectionID)
+	rlock(&ids.mu, idIndexAttr)
+	defer ids.mu.RUnlock()
+
+	c, ok := ids.conns[connectionID]
+	return c, ok
+}
+
+// UserConnections returns a snapshot of the user's connections.
+func (r *Registry) UserConnections(userID string) []*Connection {
+	users := r.userShard(userID)
+	rlock(&users.mu, userIndexAttr)
+	defer users.mu.RUnlock()
+
+	userConns := users.users[userID]
+	out := make([]*Connection, 0, len(userConns))
+	for _, c := range userConns {
+		out = append(out, c)
+	}
+	return out
+}
+
+// Len returns the number of registered connections.
+func (r *Registry) Len() int {
+	return int(r.count.Load())
+}
+
+// Snapshot returns all registered connections. Shards are visited one at a
+// time, so connections added or removed concurrently may or may not appear.
+func (r *Registry) Snapshot() []*Connection {
+	out := make([]*Connection, 0, r.Len())
+	for i := range r.byID {
+		ids := &r.byID[i]
+		rlock(&ids.mu, idIndexAttr)
+		for _, c := range ids.conns {
+			out = append(out, c)
+		}
+		ids.mu.RUnlock()
+	}
+	return out
+}
+
+func (r *Registry) idShard(connectionID string) *idShard {
+	return &r.byID[maphash.String(r.seed, connectionID)%uint64(len(r.byID))]
+}
+
+func (r *Registry) userShard(userID string) *userShard {
+	return &r.byUser[maphash.String(r.seed, userID)%uint64(len(r.byUser))]
+}
+
+// lock takes mu, recording the wait only when the lock was contended so the
+// uncontended path stays free of clock reads.
+func lock(mu *sync.RWMutex, index metric.RecordOption) {
+	if mu.TryLock() {
+		return
+	}
+	start := time.Now()
+	mu.Lock()
+	recordLockWait(start, index)
+}
+
+// rlock is lock for readers.
+func rlock(mu *sync.RWMutex, index metric.RecordOption) {
+	if mu.TryRLock() {
+		return
+	}
+	start := time.Now()
+	mu.RLock()
+	recordLockWait(start, index)
+}
+
+func recordLockWait(start time.Time, index metric.RecordOption) {
+	registryLockWait.Record(context.Background(), time.Since(start).Seconds(), index)
+}
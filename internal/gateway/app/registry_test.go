@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	a1 := newConnection("conn-a1", Identity{UserID: "user-a"}, testStart, 1)
+	a2 := newConnection("conn-a2", Identity{UserID: "user-a"}, testStart, 1)
+	b1 := newConnection("conn-b1", Identity{UserID: "user-b"}, testStart, 1)
+
+	r.Add(a1)
+	r.Add(a2)
+	r.Add(b1)
+	assert.Equal(t, 3, r.Len())
+	assert.ElementsMatch(t, []*Connection{a1, a2}, r.UserConnections("user-a"))
+	assert.Len(t, r.Snapshot(), 3)
+
+	got, ok := r.Get("conn-b1")
+	require.True(t, ok)
+	assert.Same(t, b1, got)
+
+	r.Remove(a1)
+	assert.Equal(t, []*Connection{a2}, r.UserConnections("user-a"))
+
+	r.Remove(a2)
+	assert.Empty(t, r.UserConnections("user-a"))
+	_, ok = r.Get("conn-a2")
+	assert.False(t, ok)
+
+	// Removing a stale entry must not evict a newer connection with the same ID.
+	replacement := newConnection("conn-b1", Identity{UserID: "user-b"}, testStart, 1)
+	r.Add(replacement)
+	r.Remove(b1)
+	got, ok = r.Get("conn-b1")
+	require.True(t, ok)
+	assert.Same(t, replacement, got)
+}
+
+func TestRegistry_ConcurrentChurn(t *testing.T) {
+	r := NewRegistry()
+	const workers, perWorker = 8, 200
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				c := newConnection(fmt.Sprintf("conn-%d-%d", w, i), Identity{UserID: fmt.Sprintf("user-%d", i%10)}, testStart, 1)
+				r.Add(c)
+				_, _ = r.Get(c.id)
+				_ = r.UserConnections(c.identity.UserID)
+				if i%2 == 0 {
+					r.Remove(c)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, workers*perWorker/2, r.Len())
+	assert.Len(t, r.Snapshot(), r.Len())
+	total := 0
+	for u := range 10 {
+		total += len(r.UserConnections(fmt.Sprintf("user-%d", u)))
+	}
+	assert.Equal(t, r.Len(), total, "user index agrees with the ID index")
+}
+
+// benchConnections models a fully loaded Gateway pod.
+const benchConnections = 100_000
+
+func newBenchRegistry(b *testing.B) (*Registry, []*Connection) {
+	b.Helper()
+	r := NewRegistry()
+	conns := make([]*Connection, benchConnections)
+	for i := range conns {
+		conns[i] = newConnection(fmt.Sprintf("conn-%06d", i), Identity{UserID: fmt.Sprintf("user-%06d", i/2)}, testStart, 1)
+		r.Add(conns[i])
+	}
+	return r, conns
+}
+
+func BenchmarkRegistry_Get(b *testing.B) {
+	r, conns := newBenchRegistry(b)
+	var worker atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := worker.Add(1) * 7919 // spread workers across the key space
+		for pb.Next() {
+			n++
+			c := conns[n%benchConnections]
+			if _, ok := r.Get(c.id); !ok {
+				b.Fatal("missing connection")
+			}
+		}
+	})
+}
+
+func BenchmarkRegistry_UserConnections(b *testing.B) {
+	r, conns := newBenchRegistry(b)
+	var worker atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := worker.Add(1) * 7919
+		for pb.Next() {
+			n++
+			_ = r.UserConnections(conns[n%benchConnections].identity.UserID)
+		}
+	})
+}
+
+// BenchmarkRegistry_Churn mixes lookups with reconnects: one in ten
+// operations removes and re-adds a connection.
+func BenchmarkRegistry_Churn(b *testing.B) {
+	r, conns := newBenchRegistry(b)
+	var worker atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := worker.Add(1) * 7919
+		for pb.Next() {
+			n++
+			c := conns[n%benchConnections]
+			if n%10 == 0 {
+				r.Remove(c)
+				r.Add(c)
+				continue
+			}
+			_, _ = r.Get(c.id)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// SendRequest is a validated send_message on its way to Ingest.
+type SendRequest struct {
+	ChatID          string
+	SenderID        string
+	ClientMessageID string
+	Content         domain.MessageContent
+	ReceivedAt      domain.ServerTime
+}
+
+// SendResult is what Ingest assigned to a persisted message.
+type SendResult struct {
+	MessageID string
+	Sequence  uint64
+	CreatedAt time.Time
+	Duplicate bool // the client message ID was already persisted
+}
+
+// MessageSender persists messages through Ingest's PersistMessage
+// (ADR-004). Errors are domain errors, so they reach the client as the
+// same error frames as the Gateway's own.
+type MessageSender interface {
+	SendMessage(ctx context.Context, req SendRequest) (SendResult, error)
+}
+
+// SendHandler answers send_message frames. The content is validated here
+// with domain.NewMessageContent, so a malformed message never leaves the
+// Gateway; the message is persisted through Ingest and the sender gets a
+// send_message_ack with its sequence (ADR-001 §4). A retried client
+// message ID is acked with the original result.
+type SendHandler struct {
+	sender MessageSender
+}
+
+// NewSendHandler creates a SendHandler that persists through sender.
+func NewSendHandler(sender MessageSender) *SendHandler {
+	return &SendHandler{sender: sender}
+}
+
+// HandleFrame persists a send_message and queues its ack.
+func (h *SendHandler) HandleFrame(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	ctx, span := tracer.Start(ctx, "gateway.send_message")
+	defer span.End()
+
+	var msg protocol.SendMessage
+	if err := f.ParsePayload(&msg); err != nil {
+		return domain.NewValidationError("payload", "is not a valid send_message")
+	}
+	if msg.ChatID == "" {
+		return domain.NewValidationError("chat_id", "is required")
+	}
+	if msg.ClientMessageID == "" {
+		return domain.NewValidationError("client_message_id", "is required")
+	}
+	content, err := domain.NewMessageContent(domain.ContentType(msg.ContentType), msg.Content)
+	if err != nil {
+		return err
+	}
+	if reason, ok := SpamFlag(ctx); ok {
+		span.SetAttributes(attribute.String("spam.flag", reason))
+	}
+
+	received := ReceivedAt(ctx)
+	res, err := h.sender.SendMessage(ctx, SendRequest{
+		ChatID:          msg.ChatID,
+		SenderID:        c.Identity().UserID,
+		ClientMessageID: msg.ClientMessageID,
+		Content:         content,
+		ReceivedAt:      received,
+	})
+	if err != nil {
+		return fmt.Errorf("send to %s: %w", msg.ChatID, err)
+	}
+	span.SetAttributes(
+		attribute.Int64("message.sequence", int64(res.Sequence)), //nolint:gosec // sequences fit in int64
+		attribute.Bool("message.duplicate", res.Duplicate),
+	)
+
+	ack := protocol.SendMessageAck{
+		ClientMessageID: msg.ClientMessageID,
+		MessageID:       res.MessageID,
+		Sequence:        res.Sequence,
+		CreatedAt:       res.CreatedAt.UnixMilli(),
+	}
+	if !received.IsZero() {
+		ack.ServerReceivedAt = received.UnixMilli()
+	}
+	frame, err := protocol.NewFrame(protocol.FrameTypeSendMessageAck, ack)
+	if err != nil {
+		return fmt.Errorf("encode send_message_ack: %w", err)
+	}
+	return c.Enqueue(frame)
+}
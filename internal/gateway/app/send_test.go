@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// senderFunc adapts a function to MessageSender.
+type senderFunc func(ctx context.Context, req SendRequest) (SendResult, error)
+
+func (f senderFunc) SendMessage(ctx context.Context, req SendRequest) (SendResult, error) {
+	return f(ctx, req)
+}
+
+func TestSendHandler(t *testing.T) {
+	received := domain.ServerTimeFromMillis(testStart.UnixMilli())
+	ctx := context.WithValue(context.Background(), receivedAtKey{}, received)
+	createdAt := testStart.Add(time.Second)
+
+	t.Run("persists the message and acks it", func(t *testing.T) {
+		var got SendRequest
+		h := NewSendHandler(senderFunc(func(_ context.Context, req SendRequest) (SendResult, error) {
+			got = req
+			return SendResult{MessageID: "msg-1", Sequence: 7, CreatedAt: createdAt}, nil
+		}))
+		conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+		require.NoError(t, h.HandleFrame(ctx, conn, sendFrame(t, "hello\r\nworld")))
+
+		assert.Equal(t, "chat-001", got.ChatID)
+		assert.Equal(t, "user-001", got.SenderID)
+		assert.Equal(t, "c-1", got.ClientMessageID)
+		assert.Equal(t, "hello\nworld", got.Content.Body(), "content is normalized before it leaves the Gateway")
+		assert.Equal(t, received, got.ReceivedAt)
+
+		f, ok := conn.dequeue()
+		require.True(t, ok)
+		require.Equal(t, protocol.FrameTypeSendMessageAck, f.Type)
+		var ack protocol.SendMessageAck
+		require.NoError(t, f.ParsePayload(&ack))
+		assert.Equal(t, protocol.SendMessageAck{
+			ClientMessageID:  "c-1",
+			MessageID:        "msg-1",
+			Sequence:         7,
+			CreatedAt:        createdAt.UnixMilli(),
+			ServerReceivedAt: testStart.UnixMilli(),
+		}, ack)
+	})
+
+	t.Run("rejects invalid messages before Ingest", func(t *testing.T) {
+		h := NewSendHandler(senderFunc(func(context.Context, SendRequest) (SendResult, error) {
+			t.Fatal("sent an invalid message")
+			return SendResult{}, nil
+		}))
+		tests := []struct {
+			name    string
+			msg     protocol.SendMessage
+			wantErr error
+		}{
+			{"missing chat", protocol.SendMessage{ClientMessageID: "c-1", ContentType: "text", Content: "hi"}, domain.ErrInvalidInput},
+			{"missing client message ID", protocol.SendMessage{ChatID: "chat-001", ContentType: "text", Content: "hi"}, domain.ErrInvalidInput},
+			{"system content", protocol.SendMessage{ChatID: "chat-001", ClientMessageID: "c-1", ContentType: "system", Content: "{}"}, domain.ErrInvalidContentType},
+			{"blank content", protocol.SendMessage{ChatID: "chat-001", ClientMessageID: "c-1", ContentType: "text", Content: " \x00 "}, domain.ErrInvalidInput},
+			{"oversized content", protocol.SendMessage{
+				ChatID: "chat-001", ClientMessageID: "c-1", ContentType: "text", Content: strings.Repeat("a", domain.MaxMessageSize+1),
+			}, domain.ErrMessageTooLarge},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				f, err := protocol.NewFrame(protocol.FrameTypeSendMessage, tt.msg)
+				require.NoError(t, err)
+
+				err = h.HandleFrame(ctx, newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4), f)
+
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("returns Ingest errors without acking", func(t *testing.T) {
+		h := NewSendHandler(senderFunc(func(context.Context, SendRequest) (SendResult, error) {
+			return SendResult{}, &domain.SlowModeError{Remaining: 3 * time.Second}
+		}))
+		conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+		err := h.HandleFrame(ctx, conn, sendFrame(t, "hello"))
+
+		assert.ErrorIs(t, err, domain.ErrSlowMode)
+		_, ok := conn.dequeue()
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,745 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var tracer = otel.Tracer("gateway/app")
+
+var (
+	connectionsActive      metric.Int64UpDownCounter
+	connectionsClosedTotal metric.Int64Counter
+	batchFrames            metric.Int64Histogram
+	clockOffsets           metric.Int64Histogram
+)
+
+func init() {
+	m := otel.Meter("gateway/app")
+
+	connectionsActive, _ = m.Int64UpDownCounter("gateway_connections_active",
+		metric.WithDescription("Currently open client connections"))
+	connectionsClosedTotal, _ = m.Int64Counter("gateway_connections_closed_total",
+		metric.WithDescription("Total client connections closed, by reason"))
+	batchFrames, _ = m.Int64Histogram("gateway_batch_frames",
+		metric.WithDescription("Frames coalesced per batched write"),
+		metric.WithExplicitBucketBoundaries(2, 4, 8, 16, 32, 64))
+	clockOffsets, _ = m.Int64Histogram("gateway_client_clock_offset_ms",
+		metric.WithDescription("Absolute client clock offset estimated from client pings"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries(50, 250, 1000, 5000, 30000, 300000))
+}
+
+// receivedAtKey is the context key for the server time a frame arrived.
+type receivedAtKey struct{}
+
+// ReceivedAt returns the server time the frame being handled arrived at,
+// stamped before dispatch. Handlers use it instead of any client-supplied
+// timestamp; it is zero outside a frame handler.
+func ReceivedAt(ctx context.Context) domain.ServerTime {
+	t, _ := ctx.Value(receivedAtKey{}).(domain.ServerTime)
+	return t
+}
+
+// ErrHeartbeatTimeout closes connections that stop answering pings. It
+// matches ErrUnavailable so clients treat it as a transient failure and
+// reconnect.
+var ErrHeartbeatTimeout = fmt.Errorf("heartbeat timeout: %w", domain.ErrUnavailable)
+
+// Transport carries protocol frames for one client connection. The WebSocket
+// and gRPC stream ports implement it; everything above the transport —
+// registration, heartbeat, backpressure — is shared.
+//
+// Send is only ever called from one goroutine at a time. Recv must return
+// when ctx is done or the underlying connection ends; io.EOF signals a clean
+// close by the client.
+type Transport interface {
+	Send(ctx context.Context, f *protocol.Frame) error
+	Recv(ctx context.Context) (*protocol.Frame, error)
+}
+
+// CapabilityTransport is a Transport that carries the client's advertised
+// protocol capabilities, e.g. from the WebSocket upgrade request. Transports
+// that do not implement it get no optional features.
+type CapabilityTransport interface {
+	Transport
+	Capabilities() []string
+}
+
+// AddressTransport is a Transport that knows the client's address, e.g.
+// resolved from the WebSocket upgrade request. Connections over transports
+// that do not implement it are not counted against a per-address quota.
+type AddressTransport interface {
+	Transport
+	ClientIP() string
+}
+
+// Authenticator resolves an access token to an Identity.
+type Authenticator interface {
+	Authenticate(ctx context.Context, accessToken string) (Identity, error)
+}
+
+// FrameHandler processes one inbound frame. A returned error is reported to
+// the client as an error frame; the connection stays open.
+type FrameHandler interface {
+	HandleFrame(ctx context.Context, c *Connection, f *protocol.Frame) error
+}
+
+// FrameHandlerFunc adapts a function to FrameHandler.
+type FrameHandlerFunc func(ctx context.Context, c *Connection, f *protocol.Frame) error
+
+// HandleFrame calls fn.
+func (fn FrameHandlerFunc) HandleFrame(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	return fn(ctx, c, f)
+}
+
+// SessionManagerConfig holds the dependencies for SessionManager.
+type SessionManagerConfig struct {
+	Registry      *Registry
+	Authenticator Authenticator
+	Clock         domain.Clock
+	Logger        *slog.Logger
+
+	// Admission refuses connections and sheds ephemeral frames under load.
+	// Nil admits everything.
+	Admission *AdmissionController
+
+	// Quotas caps concurrent connections per user, device and client
+	// address across the fleet. Nil enforces no quotas.
+	Quotas *ConnectionQuotas
+
+	// Routes advertises connected users to Fanout, so their messages are
+	// delivered here. Nil leaves them unrouted; clients then only receive
+	// messages through sync.
+	Routes *DeliveryRouter
+
+	// Reader schedules inbound reads. Nil uses GoroutineReader.
+	Reader ReadScheduler
+
+	// Activity receives session activity for idle-session expiry. Nil
+	// reports nothing.
+	Activity *ActivityTracker
+
+	// Resend keeps the unacked messages of closed connections and resends
+	// them when the device reconnects to this instance. It is best effort;
+	// nil drops them, and clients catch up with sync either way.
+	Resend *ResendBuffer
+
+	// Cursors records each device's acks as its delivery cursors. Nil
+	// records nothing; syncs then start from the client's claim.
+	Cursors *CursorTracker
+
+	// Handlers routes inbound frames by type. Unknown types are logged and
+	// ignored for forward compatibility (ADR-005 §6.4). Ping and pong are
+	// handled internally; acks settle the connection's ack window before
+	// reaching any handler.
+	Handlers map[protocol.FrameType]FrameHandler
+
+	// ErrorFrame converts a handler error to an error frame payload. Nil
+	// reports every error as INTERNAL.
+	ErrorFrame func(error) protocol.Error
+
+	// Reconnect is attached to retryable error frames and offered to
+	// transports for connection_closing. Nil uses the domain defaults.
+	Reconnect *protocol.ReconnectPolicy
+
+	// AccessTokenTTL and RefreshTokenTTL are the lifetimes of the tokens
+	// RefreshTokens issues, advertised in connection_ack so clients can
+	// schedule refreshes. Zero omits them.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Zero values default to the ADR-009 limits in domain.
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+	BufferSize        int
+	AckWindow         int
+
+	// MaxBatchFrames and MaxBatchDelay bound frame coalescing for clients
+	// that negotiate protocol.CapabilityBatch. MaxBatchFrames of 1 disables
+	// batching. Zero values default to the ADR-005 limits in domain.
+	MaxBatchFrames int
+	MaxBatchDelay  time.Duration
+}
+
+// SessionManager runs client connections over any Transport.
+type SessionManager struct {
+	registry          *Registry
+	authenticator     Authenticator
+	clock             domain.Clock
+	logger            *slog.Logger
+	admission         *AdmissionController
+	quotas            *ConnectionQuotas
+	routes            *DeliveryRouter
+	reader            ReadScheduler
+	activity          *ActivityTracker
+	resend            *ResendBuffer
+	cursors           *CursorTracker
+	handlers          map[protocol.FrameType]FrameHandler
+	errorFrame        func(error) protocol.Error
+	reconnect         *protocol.ReconnectPolicy
+	accessTokenTTL    time.Duration
+	refreshTokenTTL   time.Duration
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	bufferSize        int
+	ackWindow         int
+	maxBatchFrames    int
+	maxBatchDelay     time.Duration
+}
+
+// NewSessionManager creates a SessionManager with the given dependencies.
+func NewSessionManager(cfg SessionManagerConfig) *SessionManager {
+	m := &SessionManager{
+		registry:          cfg.Registry,
+		authenticator:     cfg.Authenticator,
+		clock:             cfg.Clock,
+		logger:            cfg.Logger,
+		admission:         cfg.Admission,
+		quotas:            cfg.Quotas,
+		routes:            cfg.Routes,
+		reader:            cfg.Reader,
+		activity:          cfg.Activity,
+		resend:            cfg.Resend,
+		cursors:           cfg.Cursors,
+		handlers:          cfg.Handlers,
+		errorFrame:        cfg.ErrorFrame,
+		reconnect:         cfg.Reconnect,
+		accessTokenTTL:    cfg.AccessTokenTTL,
+		refreshTokenTTL:   cfg.RefreshTokenTTL,
+		heartbeatInterval: cfg.HeartbeatInterval,
+		heartbeatTimeout:  cfg.HeartbeatTimeout,
+		bufferSize:        cfg.BufferSize,
+		ackWindow:         cfg.AckWindow,
+		maxBatchFrames:    cfg.MaxBatchFrames,
+		maxBatchDelay:     cfg.MaxBatchDelay,
+	}
+	if m.reader == nil {
+		m.reader = GoroutineReader{}
+	}
+	if m.heartbeatInterval == 0 {
+		m.heartbeatInterval = domain.HeartbeatInterval
+	}
+	if m.heartbeatTimeout == 0 {
+		m.heartbeatTimeout = domain.HeartbeatTimeout
+	}
+	if m.bufferSize == 0 {
+		m.bufferSize = domain.OutboundBufferSize
+	}
+	if m.ackWindow == 0 {
+		m.ackWindow = domain.AckWindowSize
+	}
+	if m.maxBatchFrames == 0 {
+		m.maxBatchFrames = domain.MaxBatchFrames
+	}
+	if m.maxBatchDelay == 0 {
+		m.maxBatchDelay = domain.MaxBatchDelay
+	}
+	if m.reconnect == nil {
+		m.reconnect = &protocol.ReconnectPolicy{
+			MinBackoffMs: domain.ReconnectMinBackoff.Milliseconds(),
+			MaxBackoffMs: domain.ReconnectMaxBackoff.Milliseconds(),
+			Jitter:       domain.ReconnectJitter,
+			RetryBudget:  domain.ReconnectRetryBudget,
+		}
+	}
+	if m.errorFrame == nil {
+		m.errorFrame = func(error) protocol.Error {
+			return protocol.Error{Code: "INTERNAL", ErrorCode: int(domain.ErrorCodeInternal), Message: "internal error"}
+		}
+	}
+	return m
+}
+
+// ReconnectPolicy returns the policy transports send in connection_closing.
+func (m *SessionManager) ReconnectPolicy() *protocol.ReconnectPolicy {
+	return m.reconnect
+}
+
+// Serve authenticates the client, registers the connection, sends
+// connection_ack, and then pumps frames until the client disconnects, the
+// connection fails, or ctx is cancelled. It returns nil on a clean client
+// close and the close cause otherwise; the caller maps it to a transport
+// close code.
+func (m *SessionManager) Serve(ctx context.Context, t Transport, accessToken, deviceID string) error {
+	// Refuse before authenticating: token validation is itself CPU work.
+	if m.admission != nil {
+		if err := m.admission.AdmitConnection(ctx); err != nil {
+			return err
+		}
+	}
+
+	identity, err := m.authenticator.Authenticate(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	identity.DeviceID = deviceID
+
+	now := m.clock.Now()
+	conn := newConnection(domain.GenerateConnectionID().String(), identity, now, m.bufferSize)
+	conn.acks.size = m.ackWindow
+
+	if m.quotas != nil {
+		lease := ConnectionLease{ConnectionID: conn.id, UserID: identity.UserID, DeviceID: deviceID}
+		if at, ok := t.(AddressTransport); ok {
+			lease.ClientIP = at.ClientIP()
+		}
+		if err := m.quotas.Acquire(ctx, lease); err != nil {
+			return err
+		}
+		defer m.quotas.Release(context.WithoutCancel(ctx), lease)
+	}
+
+	ctx, span := tracer.Start(ctx, "gateway.session")
+	if m.admission != nil {
+		admitCtx := ctx // ctx is reassigned below
+		conn.admit = func(ft protocol.FrameType) bool { return m.admission.AdmitFrame(admitCtx, ft) }
+	}
+	defer span.End()
+	span.SetAttributes(attribute.String("connection.id", conn.id))
+
+	logger := observability.WithTraceID(ctx, m.logger).With("connection_id", conn.id, "user_id", identity.UserID)
+
+	ack := protocol.ConnectionAck{
+		ConnectionID:           conn.id,
+		HeartbeatIntervalMs:    int(m.heartbeatInterval.Milliseconds()),
+		AccessTokenLifetimeMs:  m.accessTokenTTL.Milliseconds(),
+		RefreshTokenLifetimeMs: m.refreshTokenTTL.Milliseconds(),
+		Capabilities:           m.negotiate(t, conn),
+	}
+	if !identity.AccessTokenExpiry.IsZero() {
+		ack.AccessTokenExpiresAt = identity.AccessTokenExpiry.UnixMilli()
+		ack.AccessTokenTTLMs = identity.AccessTokenExpiry.Sub(now).Milliseconds()
+	}
+	ackFrame, err := protocol.NewFrame(protocol.FrameTypeConnectionAck, ack)
+	if err != nil {
+		return fmt.Errorf("encode connection ack: %w", err)
+	}
+	if err := t.Send(ctx, ackFrame); err != nil {
+		return fmt.Errorf("send connection ack: %w", err)
+	}
+
+	// Resend before registering so that resent messages precede new ones.
+	m.resendUnacked(ctx, conn, now)
+	m.registry.Add(conn)
+	connectionsActive.Add(ctx, 1)
+	if m.routes != nil {
+		m.routes.Connected(ctx, identity.UserID)
+	}
+	if m.activity != nil {
+		m.activity.Touch(identity.SessionID, now)
+	}
+	logger.InfoContext(ctx, "gateway.connection_opened", "device_id", identity.DeviceID)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The reader is not joined: a transport whose Recv ignores ctx unblocks
+	// only once the caller tears the transport down after Serve returns.
+	m.reader.Start(ctx, t, m.inbound(ctx, conn, logger))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); m.writeLoop(ctx, t, conn) }()
+	go func() { defer wg.Done(); m.heartbeatLoop(ctx, conn) }()
+
+	select {
+	case <-conn.Done():
+	case <-ctx.Done():
+		conn.Close(ctx.Err())
+	}
+	cancel()
+	wg.Wait()
+
+	m.registry.Remove(conn)
+	connectionsActive.Add(context.WithoutCancel(ctx), -1)
+	if m.routes != nil {
+		m.routes.Disconnected(context.WithoutCancel(ctx), identity.UserID)
+	}
+	if m.resend != nil {
+		m.resend.park(identity, conn.acks.drain(), m.clock.Now())
+	}
+
+	cause := conn.Err()
+	connectionsClosedTotal.Add(context.WithoutCancel(ctx), 1,
+		metric.WithAttributes(attribute.String("reason", closeReason(cause))))
+	logger.InfoContext(ctx, "gateway.connection_closed",
+		"reason", closeReason(cause),
+		"duration_ms", m.clock.Now().Sub(now).Milliseconds(),
+	)
+	if cause != nil {
+		span.RecordError(cause)
+	}
+	return cause
+}
+
+// negotiate enables the optional features both sides support and returns
+// them for connection_ack.
+func (m *SessionManager) negotiate(t Transport, conn *Connection) []string {
+	ct, ok := t.(CapabilityTransport)
+	if !ok {
+		return nil
+	}
+	var enabled []string
+	for _, c := range ct.Capabilities() {
+		if c == protocol.CapabilityBatch && m.maxBatchFrames > 1 && !conn.batch {
+			conn.batch = true
+			enabled = append(enabled, c)
+		}
+		if c == protocol.CapabilityUpload && m.handlers[protocol.FrameTypeUploadBegin] != nil && !conn.upload {
+			conn.upload = true
+			enabled = append(enabled, c)
+		}
+	}
+	return enabled
+}
+
+// inbound returns the ReadFunc that dispatches conn's frames until the
+// transport fails or the connection closes.
+func (m *SessionManager) inbound(ctx context.Context, conn *Connection, logger *slog.Logger) ReadFunc {
+	return func(f *protocol.Frame, err error) bool {
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				conn.Close(nil)
+			} else {
+				conn.Close(fmt.Errorf("receive frame: %w", err))
+			}
+			return false
+		}
+		received := domain.ServerNow(m.clock)
+		conn.touch(received.Time())
+
+		switch f.Type {
+		case "":
+			// A transport-level message with no protocol frame, e.g. a
+			// WebSocket ping. It counts as activity and nothing more.
+			return true
+		case protocol.FrameTypePong:
+			var p protocol.Pong
+			if f.ParsePayload(&p) == nil {
+				conn.observePong(p, received)
+			}
+			return true
+		case protocol.FrameTypePing:
+			m.pong(ctx, conn, f, received)
+			return true
+		case protocol.FrameTypeAck:
+			var a protocol.Ack
+			if err := f.ParsePayload(&a); err != nil {
+				m.sendError(conn, domain.NewValidationError("payload", "is not a valid ack"))
+				return true
+			}
+			// Cursors only move for chats this connection was sent, and
+			// never past what it was sent.
+			acked, ok := conn.acks.ack(a.ChatID, a.Sequence)
+			if ok && m.cursors != nil {
+				m.cursors.Advance(DeliveryCursor{
+					UserID:   conn.identity.UserID,
+					DeviceID: conn.identity.DeviceID,
+					ChatID:   a.ChatID,
+					Sequence: acked,
+					At:       received.Time(),
+				})
+			}
+			if _, ok := m.handlers[f.Type]; !ok {
+				return true
+			}
+		}
+		h, ok := m.handlers[f.Type]
+		if !ok {
+			logger.WarnContext(ctx, "ignoring unknown frame type", "frame_type", string(f.Type))
+			return true
+		}
+		if err := handleFrame(context.WithValue(ctx, receivedAtKey{}, received), h, conn, f); err != nil {
+			m.sendError(conn, err)
+		}
+		return ctx.Err() == nil
+	}
+}
+
+// resendUnacked queues the messages the device's previous connection to
+// this instance closed without acking.
+func (m *SessionManager) resendUnacked(ctx context.Context, conn *Connection, now time.Time) {
+	if m.resend == nil {
+		return
+	}
+	var resent int64
+	for _, u := range m.resend.take(conn.identity, now) {
+		if conn.deliver(ctx, u) != nil {
+			break // the connection is closed; the client will sync
+		}
+		resent++
+	}
+	if resent > 0 {
+		messageFramesResent.Add(ctx, resent)
+	}
+}
+
+// pong answers a client ping with the server receive time and the client's
+// estimated clock offset.
+func (m *SessionManager) pong(ctx context.Context, conn *Connection, f *protocol.Frame, received domain.ServerTime) {
+	var p protocol.Ping
+	if err := f.ParsePayload(&p); err != nil {
+		m.sendError(conn, domain.NewValidationError("payload", "is not a valid ping"))
+		return
+	}
+	offset := conn.observePing(p, received)
+	if p.Timestamp != 0 {
+		clockOffsets.Record(ctx, max(offset, -offset).Milliseconds())
+	}
+	pong, err := protocol.NewFrame(protocol.FrameTypePong, protocol.Pong{
+		Timestamp:        p.Timestamp,
+		ServerReceivedAt: received.UnixMilli(),
+		ClockOffsetMs:    offset.Milliseconds(),
+	})
+	if err != nil {
+		return
+	}
+	_ = conn.Enqueue(pong) // a full buffer closes the connection; nothing more to do
+}
+
+// handleFrame runs h, turning a panic into an internal error so one bad
+// frame fails alone instead of taking down every session on the pod.
+func handleFrame(ctx context.Context, h FrameHandler, conn *Connection, f *protocol.Frame) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = observability.HandlePanic(ctx, "gateway_frame_handler", v,
+				slog.String("frame_type", string(f.Type)), slog.String("connection_id", conn.ID()))
+		}
+	}()
+	return h.HandleFrame(ctx, conn, f)
+}
+
+// sendError queues an error frame for a failed handler. Retryable errors
+// carry the reconnect policy so clients pace their retries.
+func (m *SessionManager) sendError(conn *Connection, err error) {
+	payload := m.errorFrame(err)
+	if payload.Reconnect == nil && domain.IsRetryable(err) {
+		payload.Reconnect = m.reconnect
+	}
+	f, encErr := protocol.NewFrame(protocol.FrameTypeError, payload)
+	if encErr != nil {
+		return
+	}
+	_ = conn.Enqueue(f) // a full buffer closes the connection; nothing more to do
+}
+
+// writeLoop drains the outbound queue to the transport in priority order.
+func (m *SessionManager) writeLoop(ctx context.Context, t Transport, conn *Connection) {
+	for {
+		f, ok := conn.dequeue()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-conn.ready:
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if conn.batch {
+			var err error
+			if f, err = m.coalesce(ctx, conn, f); err != nil {
+				conn.Close(err)
+				return
+			}
+		}
+		if err := t.Send(ctx, f); err != nil {
+			conn.Close(fmt.Errorf("send frame: %w", err))
+			return
+		}
+	}
+}
+
+// coalesce gathers frames queued behind first and returns them as one
+// batch frame. Only a burst waits: once a second frame was already queued,
+// it waits up to maxBatchDelay for more. A lone frame is returned as is,
+// without delay.
+func (m *SessionManager) coalesce(ctx context.Context, conn *Connection, first *protocol.Frame) (*protocol.Frame, error) {
+	frames := []*protocol.Frame{first}
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+collect:
+	for len(frames) < m.maxBatchFrames {
+		if f, ok := conn.dequeue(); ok {
+			frames = append(frames, f)
+			continue
+		}
+		if len(frames) == 1 {
+			break
+		}
+		if timer == nil {
+			timer = time.NewTimer(m.maxBatchDelay)
+		}
+		select {
+		case <-conn.ready:
+		case <-timer.C:
+			break collect
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	if len(frames) == 1 {
+		return first, nil
+	}
+	batch, err := protocol.NewBatchFrame(frames)
+	if err != nil {
+		return nil, fmt.Errorf("encode batch of %d frames: %w", len(frames), err)
+	}
+	batchFrames.Record(ctx, int64(len(frames)))
+	return batch, nil
+}
+
+// heartbeatLoop pings the client every interval and closes the connection if
+// nothing has been received within interval + timeout. A connection heard
+// from since the last tick counts as session activity.
+func (m *SessionManager) heartbeatLoop(ctx context.Context, conn *Connection) {
+	ticker := time.NewTicker(m.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := m.clock.Now()
+			idle := conn.idleSince(now)
+			if idle > m.heartbeatInterval+m.heartbeatTimeout {
+				conn.Close(ErrHeartbeatTimeout)
+				return
+			}
+			if m.activity != nil && idle < m.heartbeatInterval {
+				m.activity.Touch(conn.identity.SessionID, now.Add(-idle))
+			}
+			ping, err := protocol.NewFrame(protocol.FrameTypePing, protocol.Ping{Timestamp: now.UnixMilli()})
+			if err != nil {
+				continue
+			}
+			conn.lastPing.Store(now.UnixMilli())
+			if err := conn.Enqueue(ping); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeReason labels a close cause for metrics and logs.
+func closeReason(cause error) string {
+	switch {
+	case cause == nil:
+		return "client_closed"
+	case errors.Is(cause, domain.ErrSlowConsumer):
+		return "slow_consumer"
+	case errors.Is(cause, ErrHeartbeatTimeout):
+		return "heartbeat_timeout"
+	case errors.Is(cause, ErrServerShutdown):
+		return "server_shutdown"
+	case errors.Is(cause, context.Canceled), errors.Is(cause, context.DeadlineExceeded):
+		return "context_done"
+	default:
+		return "transport_error"
+	}
+}
+
+// RevocationChecker reports revoked access tokens (ADR-015 §6.2). Both
+// checks fail closed: an error comes with revoked set.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error)
+}
+
+// TokenAuthenticator authenticates clients with JWT access tokens (ADR-015).
+type TokenAuthenticator struct {
+	validator   *auth.Validator
+	cache       *TokenCache
+	revocations RevocationChecker
+}
+
+// NewTokenAuthenticator creates a TokenAuthenticator backed by validator.
+// A non-nil cache skips signature verification for tokens it has already
+// validated. Every token, cached or not, is checked against revocations,
+// both by JTI and user-wide; nil checks none.
+func NewTokenAuthenticator(validator *auth.Validator, cache *TokenCache, revocations RevocationChecker) *TokenAuthenticator {
+	return &TokenAuthenticator{validator: validator, cache: cache, revocations: revocations}
+}
+
+// Authenticate validates accessToken and returns its identity. A revoked
+// token is unauthorized; a failed revocation check is unavailable.
+func (a *TokenAuthenticator) Authenticate(ctx context.Context, accessToken string) (Identity, error) {
+	var (
+		token AccessToken
+		err   error
+	)
+	if a.cache != nil {
+		token, err = a.cache.Token(ctx, accessToken, a.validate)
+	} else {
+		token, err = a.validate(accessToken)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	if err := a.checkRevoked(ctx, token); err != nil {
+		return Identity{}, err
+	}
+	return token.Identity, nil
+}
+
+func (a *TokenAuthenticator) checkRevoked(ctx context.Context, token AccessToken) error {
+	if a.revocations == nil {
+		return nil
+	}
+	revoked, err := a.revocations.IsRevoked(ctx, token.JTI)
+	if err == nil && !revoked {
+		revoked, err = a.revocations.IsUserRevoked(ctx, token.Identity.UserID, token.IssuedAt)
+	}
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	case revoked:
+		return fmt.Errorf("access token revoked: %w", domain.ErrUnauthorized)
+	}
+	return nil
+}
+
+func (a *TokenAuthenticator) validate(accessToken string) (AccessToken, error) {
+	claims, err := a.validator.ValidateAccessToken(accessToken)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("%w: %w", domain.ErrUnauthorized, err)
+	}
+	token := AccessToken{
+		Identity: Identity{UserID: claims.Subject, SessionID: claims.SessionID},
+		JTI:      claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		token.Identity.AccessTokenExpiry = claims.ExpiresAt.Time
+	}
+	if claims.IssuedAt != nil {
+		token.IssuedAt = claims.IssuedAt.Time
+	}
+	return token, nil
+}
+
+// Compile-time interface check.
+var _ Authenticator = (*TokenAuthenticator)(nil)
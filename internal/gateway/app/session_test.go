@@ -0,0 +1,692 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// fakeTransport is an in-memory app.Transport. Frames the server sends appear
+// on sent; frames pushed to inbound are returned by Recv.
+type fakeTransport struct {
+	sent    chan *protocol.Frame
+	inbound chan *protocol.Frame
+
+	mu      sync.Mutex
+	sendErr error
+	gate    chan struct{} // when set, Send blocks until it is closed
+	held    chan struct{} // receives once per blocked Send
+
+	closeOnce sync.Once
+	eof       chan struct{}
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		sent:    make(chan *protocol.Frame, 64),
+		inbound: make(chan *protocol.Frame, 64),
+		eof:     make(chan struct{}),
+	}
+}
+
+func (t *fakeTransport) Send(_ context.Context, f *protocol.Frame) error {
+	t.mu.Lock()
+	err, gate, held := t.sendErr, t.gate, t.held
+	t.mu.Unlock()
+	if gate != nil {
+		held <- struct{}{}
+		<-gate
+	}
+	if err != nil {
+		return err
+	}
+	t.sent <- f
+	return nil
+}
+
+func (t *fakeTransport) Recv(ctx context.Context) (*protocol.Frame, error) {
+	select {
+	case f := <-t.inbound:
+		return f, nil
+	case <-t.eof:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *fakeTransport) failSends(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sendErr = err
+}
+
+// holdSends makes Send block until release is called. held receives once
+// each time a Send starts blocking.
+func (t *fakeTransport) holdSends() (held <-chan struct{}, release func()) {
+	gate, h := make(chan struct{}), make(chan struct{}, 64)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gate, t.held = gate, h
+	return h, func() { close(gate) }
+}
+
+// hangUp simulates the client closing its side cleanly.
+func (t *fakeTransport) hangUp() { t.closeOnce.Do(func() { close(t.eof) }) }
+
+func (t *fakeTransport) push(tb testing.TB, ft protocol.FrameType, payload any) {
+	tb.Helper()
+	f, err := protocol.NewFrame(ft, payload)
+	require.NoError(tb, err)
+	t.inbound <- f
+}
+
+// next waits for the next frame the server sent.
+func (t *fakeTransport) next(tb testing.TB) *protocol.Frame {
+	tb.Helper()
+	select {
+	case f := <-t.sent:
+		return f
+	case <-time.After(2 * time.Second):
+		tb.Fatal("timed out waiting for server frame")
+		return nil
+	}
+}
+
+// capabilityTransport is a fakeTransport whose client advertises caps.
+type capabilityTransport struct {
+	*fakeTransport
+	caps []string
+}
+
+func (t *capabilityTransport) Capabilities() []string { return t.caps }
+
+// panickingTransport is a fakeTransport whose Recv panics once panicNow is
+// closed, as a buggy codec would.
+type panickingTransport struct {
+	*fakeTransport
+	panicNow chan struct{}
+}
+
+func (t *panickingTransport) Recv(context.Context) (*protocol.Frame, error) {
+	<-t.panicNow
+	panic("codec bug")
+}
+
+// stubAuthenticator implements app.Authenticator with a function field.
+type stubAuthenticator struct {
+	authenticateFn func(ctx context.Context, accessToken string) (app.Identity, error)
+}
+
+func (s *stubAuthenticator) Authenticate(ctx context.Context, accessToken string) (app.Identity, error) {
+	if s.authenticateFn != nil {
+		return s.authenticateFn(ctx, accessToken)
+	}
+	return app.Identity{UserID: "user-001", SessionID: "sess-001"}, nil
+}
+
+type sessionHarness struct {
+	registry *app.Registry
+	auth     *stubAuthenticator
+	cfg      app.SessionManagerConfig
+}
+
+func newSessionHarness() *sessionHarness {
+	h := &sessionHarness{
+		registry: app.NewRegistry(),
+		auth:     &stubAuthenticator{},
+	}
+	h.cfg = app.SessionManagerConfig{
+		Registry:          h.registry,
+		Authenticator:     h.auth,
+		Clock:             domain.RealClock{},
+		Logger:            slog.Default(),
+		HeartbeatInterval: time.Hour, // tests opt in to fast heartbeats
+		HeartbeatTimeout:  time.Hour,
+	}
+	return h
+}
+
+// serve runs a session in the background and returns its result channel.
+func (h *sessionHarness) serve(ctx context.Context, t app.Transport) <-chan error {
+	done := make(chan error, 1)
+	mgr := app.NewSessionManager(h.cfg)
+	go func() { done <- mgr.Serve(ctx, t, "token", "device-001") }()
+	return done
+}
+
+func wait(tb testing.TB, done <-chan error) error {
+	tb.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		tb.Fatal("timed out waiting for session to end")
+		return nil
+	}
+}
+
+func TestSessionManager_Serve(t *testing.T) {
+	t.Run("sends connection_ack and registers the connection", func(t *testing.T) {
+		h := newSessionHarness()
+		expiry := time.Now().Add(time.Hour)
+		h.auth.authenticateFn = func(_ context.Context, token string) (app.Identity, error) {
+			assert.Equal(t, "token", token)
+			return app.Identity{UserID: "user-001", AccessTokenExpiry: expiry}, nil
+		}
+		h.cfg.AccessTokenTTL = 15 * time.Minute
+		h.cfg.RefreshTokenTTL = 30 * 24 * time.Hour
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+
+		ack := tr.next(t)
+		require.Equal(t, protocol.FrameTypeConnectionAck, ack.Type)
+		var payload protocol.ConnectionAck
+		require.NoError(t, ack.ParsePayload(&payload))
+		assert.NotEmpty(t, payload.ConnectionID)
+		assert.Equal(t, int(time.Hour.Milliseconds()), payload.HeartbeatIntervalMs)
+		assert.Equal(t, expiry.UnixMilli(), payload.AccessTokenExpiresAt)
+		assert.Positive(t, payload.AccessTokenTTLMs)
+		assert.Equal(t, (15 * time.Minute).Milliseconds(), payload.AccessTokenLifetimeMs)
+		assert.Equal(t, (30 * 24 * time.Hour).Milliseconds(), payload.RefreshTokenLifetimeMs)
+
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+		conn, ok := h.registry.Get(payload.ConnectionID)
+		require.True(t, ok)
+		assert.Equal(t, "user-001", conn.Identity().UserID)
+		assert.Equal(t, "device-001", conn.Identity().DeviceID)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+		assert.Zero(t, h.registry.Len(), "connection should be unregistered")
+	})
+
+	t.Run("authentication failure: no ack, no registration", func(t *testing.T) {
+		h := newSessionHarness()
+		h.auth.authenticateFn = func(context.Context, string) (app.Identity, error) {
+			return app.Identity{}, domain.ErrUnauthorized
+		}
+		tr := newFakeTransport()
+
+		err := wait(t, h.serve(context.Background(), tr))
+		require.ErrorIs(t, err, domain.ErrUnauthorized)
+		assert.Empty(t, tr.sent)
+		assert.Zero(t, h.registry.Len())
+	})
+
+	t.Run("enqueued frames are written in order", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+		conn := h.registry.UserConnections("user-001")[0]
+		for seq := uint64(1); seq <= 3; seq++ {
+			f, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{Sequence: seq})
+			require.NoError(t, err)
+			require.NoError(t, conn.Enqueue(f))
+		}
+		for seq := uint64(1); seq <= 3; seq++ {
+			var m protocol.Message
+			require.NoError(t, tr.next(t).ParsePayload(&m))
+			assert.Equal(t, seq, m.Sequence)
+		}
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("inbound frames are routed to handlers; unknown types ignored", func(t *testing.T) {
+		h := newSessionHarness()
+		handled := make(chan protocol.Ack, 1)
+		h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+			protocol.FrameTypeAck: app.FrameHandlerFunc(func(_ context.Context, _ *app.Connection, f *protocol.Frame) error {
+				var a protocol.Ack
+				require.NoError(t, f.ParsePayload(&a))
+				handled <- a
+				return nil
+			}),
+		}
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		tr.push(t, "typing_v2", map[string]string{"chat_id": "chat-001"})
+		tr.push(t, protocol.FrameTypeAck, protocol.Ack{ChatID: "chat-001", Sequence: 7})
+
+		select {
+		case a := <-handled:
+			assert.Equal(t, uint64(7), a.Sequence)
+		case <-time.After(2 * time.Second):
+			t.Fatal("handler not called")
+		}
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+		assert.Empty(t, tr.sent, "unknown frame types produce no response")
+	})
+
+	t.Run("handler error is reported as an error frame", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+			protocol.FrameTypeSendMessage: app.FrameHandlerFunc(func(context.Context, *app.Connection, *protocol.Frame) error {
+				return domain.ErrNotMember
+			}),
+		}
+		h.cfg.ErrorFrame = func(err error) protocol.Error {
+			assert.ErrorIs(t, err, domain.ErrNotMember)
+			return protocol.Error{Code: "NOT_MEMBER"}
+		}
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		tr.push(t, protocol.FrameTypeSendMessage, protocol.SendMessage{ChatID: "chat-001"})
+
+		f := tr.next(t)
+		require.Equal(t, protocol.FrameTypeError, f.Type)
+		var e protocol.Error
+		require.NoError(t, f.ParsePayload(&e))
+		assert.Equal(t, "NOT_MEMBER", e.Code)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("handler panic is reported as an error frame and the session survives", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+			protocol.FrameTypeSendMessage: app.FrameHandlerFunc(func(context.Context, *app.Connection, *protocol.Frame) error {
+				panic("nil map write")
+			}),
+		}
+		h.cfg.ErrorFrame = func(err error) protocol.Error {
+			assert.ErrorContains(t, err, "nil map write")
+			return protocol.Error{Code: "INTERNAL"}
+		}
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		for range 2 {
+			tr.push(t, protocol.FrameTypeSendMessage, protocol.SendMessage{ChatID: "chat-001"})
+			f := tr.next(t)
+			require.Equal(t, protocol.FrameTypeError, f.Type)
+		}
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("retryable errors carry the reconnect policy", func(t *testing.T) {
+		policy := &protocol.ReconnectPolicy{MinBackoffMs: 5000, MaxBackoffMs: 120000, Jitter: 0.8, RetryBudget: 3}
+		h := newSessionHarness()
+		h.cfg.Reconnect = policy
+		h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+			protocol.FrameTypeSendMessage: app.FrameHandlerFunc(func(_ context.Context, _ *app.Connection, f *protocol.Frame) error {
+				var m protocol.SendMessage
+				require.NoError(t, f.ParsePayload(&m))
+				if m.ChatID == "retry" {
+					return domain.ErrUnavailable
+				}
+				return domain.ErrNotMember
+			}),
+		}
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		tr.push(t, protocol.FrameTypeSendMessage, protocol.SendMessage{ChatID: "retry"})
+		var retryable protocol.Error
+		require.NoError(t, tr.next(t).ParsePayload(&retryable))
+		assert.Equal(t, policy, retryable.Reconnect)
+
+		tr.push(t, protocol.FrameTypeSendMessage, protocol.SendMessage{ChatID: "chat-001"})
+		var permanent protocol.Error
+		require.NoError(t, tr.next(t).ParsePayload(&permanent))
+		assert.Nil(t, permanent.Reconnect)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("heartbeat pings and closes silent connections", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.HeartbeatInterval = 20 * time.Millisecond
+		h.cfg.HeartbeatTimeout = 20 * time.Millisecond
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		assert.Equal(t, protocol.FrameTypePing, tr.next(t).Type)
+
+		err := wait(t, done)
+		require.ErrorIs(t, err, app.ErrHeartbeatTimeout)
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("pongs keep the connection alive", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.HeartbeatInterval = 20 * time.Millisecond
+		h.cfg.HeartbeatTimeout = 20 * time.Millisecond
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			f := tr.next(t)
+			if f.Type == protocol.FrameTypePing {
+				tr.push(t, protocol.FrameTypePong, protocol.Pong{})
+			}
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("session ended despite pongs: %v", err)
+		default:
+		}
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("client ping gets the server time and clock offset", func(t *testing.T) {
+		now := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+		h := newSessionHarness()
+		h.cfg.Clock = domaintest.NewFakeClock(now)
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		clientNow := now.Add(5 * time.Second).UnixMilli()
+		tr.push(t, protocol.FrameTypePing, protocol.Ping{Timestamp: clientNow})
+
+		f := tr.next(t)
+		require.Equal(t, protocol.FrameTypePong, f.Type)
+		var pong protocol.Pong
+		require.NoError(t, f.ParsePayload(&pong))
+		assert.Equal(t, protocol.Pong{
+			Timestamp:        clientNow,
+			ServerReceivedAt: now.UnixMilli(),
+			ClockOffsetMs:    5000,
+		}, pong)
+
+		offset, ok := h.registry.UserConnections("user-001")[0].ClockOffset()
+		assert.True(t, ok)
+		assert.Equal(t, 5*time.Second, offset)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("handlers see the server receive time", func(t *testing.T) {
+		now := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+		h := newSessionHarness()
+		h.cfg.Clock = domaintest.NewFakeClock(now)
+		received := make(chan domain.ServerTime, 1)
+		h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+			protocol.FrameTypeAck: app.FrameHandlerFunc(func(ctx context.Context, _ *app.Connection, _ *protocol.Frame) error {
+				received <- app.ReceivedAt(ctx)
+				return nil
+			}),
+		}
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		tr.push(t, protocol.FrameTypeAck, protocol.Ack{})
+		select {
+		case got := <-received:
+			assert.Equal(t, now.UnixMilli(), got.UnixMilli())
+		case <-time.After(2 * time.Second):
+			t.Fatal("handler not called")
+		}
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("transport send failure closes the session", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+		errBroken := errors.New("broken pipe")
+		tr.failSends(errBroken)
+		conn := h.registry.UserConnections("user-001")[0]
+		f, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{})
+		require.NoError(t, err)
+		require.NoError(t, conn.Enqueue(f))
+
+		require.ErrorIs(t, wait(t, done), errBroken)
+	})
+
+	t.Run("transport panic in Recv closes the session", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := &panickingTransport{fakeTransport: newFakeTransport(), panicNow: make(chan struct{})}
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		close(tr.panicNow)
+		require.ErrorContains(t, wait(t, done), "codec bug")
+		assert.Zero(t, h.registry.Len())
+	})
+
+	t.Run("context cancellation ends the session", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := h.serve(ctx, tr)
+		tr.next(t) // ack
+
+		cancel()
+		require.ErrorIs(t, wait(t, done), context.Canceled)
+		assert.Zero(t, h.registry.Len())
+	})
+}
+
+func TestSessionManager_Acks(t *testing.T) {
+	// connect serves tr and returns the live connection and a func that
+	// waits for the session end.
+	connect := func(t *testing.T, h *sessionHarness, tr *fakeTransport) (*app.Connection, func()) {
+		t.Helper()
+		done := h.serve(context.Background(), tr)
+		var ack protocol.ConnectionAck
+		require.NoError(t, tr.next(t).ParsePayload(&ack))
+		require.Eventually(t, func() bool { _, ok := h.registry.Get(ack.ConnectionID); return ok }, time.Second, 5*time.Millisecond)
+		conn, _ := h.registry.Get(ack.ConnectionID)
+		return conn, func() { require.NoError(t, wait(t, done)) }
+	}
+	message := func(seq uint64) protocol.Message {
+		return protocol.Message{MessageID: fmt.Sprintf("msg-%d", seq), ChatID: "chat-001", Sequence: seq}
+	}
+
+	t.Run("unacked messages are resent when the device reconnects", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.Resend = app.NewResendBuffer(time.Minute)
+		first := newFakeTransport()
+		conn, stop := connect(t, h, first)
+
+		for seq := uint64(1); seq <= 3; seq++ {
+			require.NoError(t, conn.Deliver(context.Background(), message(seq)))
+			first.next(t)
+		}
+		first.push(t, protocol.FrameTypeAck, protocol.Ack{ChatID: "chat-001", Sequence: 1})
+		require.Eventually(t, func() bool { return conn.Unacked() == 2 }, time.Second, 5*time.Millisecond)
+		first.hangUp()
+		stop()
+
+		second := newFakeTransport()
+		_, stop = connect(t, h, second)
+		for _, want := range []uint64{2, 3} {
+			var m protocol.Message
+			require.NoError(t, second.next(t).ParsePayload(&m))
+			assert.Equal(t, want, m.Sequence)
+		}
+
+		second.hangUp()
+		stop()
+	})
+
+	t.Run("without a resend buffer unacked messages are dropped", func(t *testing.T) {
+		h := newSessionHarness()
+		first := newFakeTransport()
+		conn, stop := connect(t, h, first)
+		require.NoError(t, conn.Deliver(context.Background(), message(1)))
+		first.next(t)
+		first.hangUp()
+		stop()
+
+		second := newFakeTransport()
+		conn, stop = connect(t, h, second)
+
+		assert.Zero(t, conn.Unacked())
+		second.hangUp()
+		stop()
+		assert.Empty(t, second.sent)
+	})
+
+	t.Run("a malformed ack is reported", func(t *testing.T) {
+		h := newSessionHarness()
+		tr := newFakeTransport()
+		_, stop := connect(t, h, tr)
+
+		tr.push(t, protocol.FrameTypeAck, "not an ack")
+
+		assert.Equal(t, protocol.FrameTypeError, tr.next(t).Type)
+		tr.hangUp()
+		stop()
+	})
+}
+
+func TestSessionManager_ReconnectPolicyDefaults(t *testing.T) {
+	mgr := app.NewSessionManager(app.SessionManagerConfig{})
+
+	assert.Equal(t, &protocol.ReconnectPolicy{
+		MinBackoffMs: domain.ReconnectMinBackoff.Milliseconds(),
+		MaxBackoffMs: domain.ReconnectMaxBackoff.Milliseconds(),
+		Jitter:       domain.ReconnectJitter,
+		RetryBudget:  domain.ReconnectRetryBudget,
+	}, mgr.ReconnectPolicy())
+}
+
+func TestSessionManager_Batching(t *testing.T) {
+	// connect serves tr, which sends through fake, and returns the live
+	// connection, the ack payload, and a func that waits for the session end.
+	connect := func(t *testing.T, h *sessionHarness, fake *fakeTransport, tr app.Transport) (*app.Connection, protocol.ConnectionAck, func()) {
+		t.Helper()
+		done := h.serve(context.Background(), tr)
+		var ack protocol.ConnectionAck
+		require.NoError(t, fake.next(t).ParsePayload(&ack))
+		require.Eventually(t, func() bool { return h.registry.Len() == 1 }, time.Second, 5*time.Millisecond)
+		conn, ok := h.registry.Get(ack.ConnectionID)
+		require.True(t, ok)
+		return conn, ack, func() { require.NoError(t, wait(t, done)) }
+	}
+	message := func(t *testing.T, seq uint64) *protocol.Frame {
+		t.Helper()
+		f, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{Sequence: seq})
+		require.NoError(t, err)
+		return f
+	}
+
+	t.Run("negotiated: queued frames are coalesced in order", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.MaxBatchFrames = 3
+		h.cfg.MaxBatchDelay = time.Minute // the batch fills before the delay
+		fake := newFakeTransport()
+		tr := &capabilityTransport{fakeTransport: fake, caps: []string{"unknown", protocol.CapabilityBatch}}
+
+		conn, ack, stop := connect(t, h, fake, tr)
+		assert.Equal(t, []string{protocol.CapabilityBatch}, ack.Capabilities)
+
+		// Hold the writer in a send so the next frames queue behind it.
+		held, release := fake.holdSends()
+		require.NoError(t, conn.Enqueue(message(t, 0)))
+		<-held
+		for seq := uint64(1); seq <= 3; seq++ {
+			require.NoError(t, conn.Enqueue(message(t, seq)))
+		}
+		release()
+		assert.Equal(t, protocol.FrameTypeMessage, fake.next(t).Type)
+
+		f := fake.next(t)
+		require.Equal(t, protocol.FrameTypeBatch, f.Type)
+		var frames []protocol.Frame
+		require.NoError(t, f.ParsePayload(&frames))
+		require.Len(t, frames, 3)
+		for i, fr := range frames {
+			var msg protocol.Message
+			require.NoError(t, fr.ParsePayload(&msg))
+			assert.Equal(t, uint64(i+1), msg.Sequence)
+		}
+
+		fake.hangUp()
+		stop()
+	})
+
+	t.Run("negotiated: a lone frame is sent unwrapped without waiting", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.MaxBatchDelay = time.Minute // next times out long before this
+		fake := newFakeTransport()
+		tr := &capabilityTransport{fakeTransport: fake, caps: []string{protocol.CapabilityBatch}}
+
+		conn, _, stop := connect(t, h, fake, tr)
+		require.NoError(t, conn.Enqueue(message(t, 1)))
+
+		assert.Equal(t, protocol.FrameTypeMessage, fake.next(t).Type)
+
+		fake.hangUp()
+		stop()
+	})
+
+	t.Run("not negotiated without client capability", func(t *testing.T) {
+		h := newSessionHarness()
+		fake := newFakeTransport()
+
+		conn, ack, stop := connect(t, h, fake, fake)
+		assert.Empty(t, ack.Capabilities)
+
+		require.NoError(t, conn.Enqueue(message(t, 1)))
+		require.NoError(t, conn.Enqueue(message(t, 2)))
+		assert.Equal(t, protocol.FrameTypeMessage, fake.next(t).Type)
+		assert.Equal(t, protocol.FrameTypeMessage, fake.next(t).Type)
+
+		fake.hangUp()
+		stop()
+	})
+
+	t.Run("disabled by a batch size of one", func(t *testing.T) {
+		h := newSessionHarness()
+		h.cfg.MaxBatchFrames = 1
+		fake := newFakeTransport()
+		tr := &capabilityTransport{fakeTransport: fake, caps: []string{protocol.CapabilityBatch}}
+
+		_, ack, stop := connect(t, h, fake, tr)
+		assert.Empty(t, ack.Capabilities)
+
+		fake.hangUp()
+		stop()
+	})
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// Signal is an activity signal about a connected user, on its way to
+// Fanout: typing or a read receipt in a chat, or a presence change. Only
+// the fields of its Kind are set.
+type Signal struct {
+	Kind   domain.PrivacySignal
+	UserID string
+
+	ChatID   string // typing and read receipts
+	Active   bool   // typing: started (true) or stopped
+	Sequence uint64 // read receipts: the last message read
+
+	Online     bool      // presence
+	LastSeenAt time.Time // presence: when the user went offline
+}
+
+// SignalPublisher hands activity signals to Fanout, which enforces the
+// users' privacy settings and delivers them. Errors are domain errors.
+type SignalPublisher interface {
+	PublishSignal(ctx context.Context, s Signal) error
+}
+
+// SignalHandler answers typing and receipt frames from clients (ADR-005
+// §3.8). Both are one-way: nothing is sent back unless the frame is
+// invalid or the sender may not signal the chat. Signals are best effort,
+// so a Fanout that is slow or down drops them. Clients send receipts only
+// for messages they have read; delivery is already known from their acks.
+type SignalHandler struct {
+	publisher SignalPublisher
+	logger    *slog.Logger
+}
+
+// NewSignalHandler creates a SignalHandler that publishes through
+// publisher.
+func NewSignalHandler(publisher SignalPublisher, logger *slog.Logger) *SignalHandler {
+	return &SignalHandler{publisher: publisher, logger: logger}
+}
+
+// HandleFrame publishes a typing or read receipt signal for the sender.
+func (h *SignalHandler) HandleFrame(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	sig := Signal{UserID: c.Identity().UserID}
+	switch f.Type {
+	case protocol.FrameTypeTyping:
+		var t protocol.Typing
+		if err := f.ParsePayload(&t); err != nil {
+			return domain.NewValidationError("payload", "is not a valid typing")
+		}
+		sig.Kind, sig.ChatID, sig.Active = domain.SignalTyping, t.ChatID, t.Active
+	case protocol.FrameTypeReceipt:
+		var r protocol.Receipt
+		if err := f.ParsePayload(&r); err != nil {
+			return domain.NewValidationError("payload", "is not a valid receipt")
+		}
+		if r.Status != protocol.ReceiptRead {
+			return domain.NewValidationError("status", "must be read")
+		}
+		if r.Sequence == 0 {
+			return domain.NewValidationError("sequence", "is required")
+		}
+		sig.Kind, sig.ChatID, sig.Sequence = domain.SignalReadReceipt, r.ChatID, r.Sequence
+	default:
+		return fmt.Errorf("signal handler: unexpected %s frame", f.Type)
+	}
+	if sig.ChatID == "" {
+		return domain.NewValidationError("chat_id", "is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, domain.SignalTimeout)
+	defer cancel()
+	if err := h.publisher.PublishSignal(ctx, sig); err != nil {
+		if domain.IsClientError(err) {
+			return err
+		}
+		h.logger.DebugContext(ctx, "signal dropped", "signal", sig.Kind.String(), "chat_id", sig.ChatID, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// recordingSignals is a SignalPublisher that records signals and fails
+// with err.
+type recordingSignals struct {
+	mu      sync.Mutex
+	signals []Signal
+	err     error
+}
+
+func (p *recordingSignals) PublishSignal(ctx context.Context, s Signal) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("publish without a deadline")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signals = append(p.signals, s)
+	return p.err
+}
+
+func TestSignalHandler(t *testing.T) {
+	ctx := context.Background()
+	conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+	frame := func(t *testing.T, ft protocol.FrameType, payload any) *protocol.Frame {
+		t.Helper()
+		f, err := protocol.NewFrame(ft, payload)
+		require.NoError(t, err)
+		return f
+	}
+
+	t.Run("typing", func(t *testing.T) {
+		signals := &recordingSignals{}
+		h := NewSignalHandler(signals, slog.Default())
+
+		require.NoError(t, h.HandleFrame(ctx, conn, frame(t, protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-1", UserID: "spoofed", Active: true})))
+
+		assert.Equal(t, []Signal{{Kind: domain.SignalTyping, UserID: "user-001", ChatID: "chat-1", Active: true}}, signals.signals,
+			"the signal is about the sender, whatever the frame says")
+	})
+
+	t.Run("read receipt", func(t *testing.T) {
+		signals := &recordingSignals{}
+		h := NewSignalHandler(signals, slog.Default())
+
+		require.NoError(t, h.HandleFrame(ctx, conn, frame(t, protocol.FrameTypeReceipt, protocol.Receipt{ChatID: "chat-1", Sequence: 12, Status: protocol.ReceiptRead})))
+
+		assert.Equal(t, []Signal{{Kind: domain.SignalReadReceipt, UserID: "user-001", ChatID: "chat-1", Sequence: 12}}, signals.signals)
+	})
+
+	t.Run("invalid frames", func(t *testing.T) {
+		h := NewSignalHandler(&recordingSignals{}, slog.Default())
+
+		for name, f := range map[string]*protocol.Frame{
+			"typing without chat":      frame(t, protocol.FrameTypeTyping, protocol.Typing{Active: true}),
+			"delivered receipt":        frame(t, protocol.FrameTypeReceipt, protocol.Receipt{ChatID: "chat-1", Sequence: 1, Status: protocol.ReceiptDelivered}),
+			"receipt without sequence": frame(t, protocol.FrameTypeReceipt, protocol.Receipt{ChatID: "chat-1", Status: protocol.ReceiptRead}),
+		} {
+			assert.ErrorIs(t, h.HandleFrame(ctx, conn, f), domain.ErrInvalidInput, name)
+		}
+	})
+
+	t.Run("client errors are returned, others dropped", func(t *testing.T) {
+		f := frame(t, protocol.FrameTypeTyping, protocol.Typing{ChatID: "chat-1", Active: true})
+
+		h := NewSignalHandler(&recordingSignals{err: domain.ErrNotMember}, slog.Default())
+		assert.ErrorIs(t, h.HandleFrame(ctx, conn, f), domain.ErrNotMember)
+
+		h = NewSignalHandler(&recordingSignals{err: errors.New("fanout unavailable")}, slog.Default())
+		assert.NoError(t, h.HandleFrame(ctx, conn, f))
+	})
+}
@@ -0,0 +1,319 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var spamDecisionsTotal metric.Int64Counter
+
+func init() {
+	spamDecisionsTotal, _ = otel.Meter("gateway/app").Int64Counter("gateway_spam_decisions_total",
+		metric.WithDescription("Sends flagged or rejected by spam heuristics, by action and reason"))
+}
+
+const (
+	// spamVelocityWindow is the window RecentSends counts over.
+	spamVelocityWindow = time.Minute
+	// spamMaxHistory bounds the sends remembered per sender, so a flood
+	// cannot grow memory without limit.
+	spamMaxHistory = 512
+	// spamSweepInterval is how often idle senders are forgotten.
+	spamSweepInterval = time.Minute
+)
+
+// spamFlagKey is the context key for a shadow-flagged send.
+type spamFlagKey struct{}
+
+// SpamFlag returns the heuristic that shadow-flagged the send being
+// handled, if any. A shadow-flagged send still goes through: the flag is
+// for review, and SendHandler records it on the send's span.
+func SpamFlag(ctx context.Context) (string, bool) {
+	reason, ok := ctx.Value(spamFlagKey{}).(string)
+	return reason, ok
+}
+
+// AccountAges reports when users created their accounts.
+type AccountAges interface {
+	CreatedAt(ctx context.Context, userID string) (time.Time, error)
+}
+
+// SpamDecision is one non-allow spam verdict, as recorded for audit. The
+// content itself is never recorded, only its fingerprint.
+type SpamDecision struct {
+	UserID       string
+	ConnectionID string
+	ChatID       string
+	Action       domain.SpamAction
+	Reason       string
+	ContentHash  string
+	At           time.Time
+}
+
+// SpamAuditor records spam decisions for later review.
+type SpamAuditor interface {
+	RecordSpamDecision(ctx context.Context, d SpamDecision)
+}
+
+// SpamGuardConfig holds the dependencies for SpamGuard.
+type SpamGuardConfig struct {
+	// Accounts enables the new-account heuristics. Nil treats every
+	// account's age as unknown.
+	Accounts AccountAges
+	// Auditor receives every non-allow decision. Nil only logs.
+	Auditor SpamAuditor
+	Clock   domain.Clock
+	Logger  *slog.Logger
+
+	// Policy is the initial thresholds. The zero value uses
+	// domain.DefaultSpamPolicy.
+	Policy domain.SpamPolicy
+}
+
+// SpamGuard screens send_message frames with per-sender heuristics:
+// repeated content, link density and new-account velocity. It shadow-flags,
+// throttles or blocks in line, before the send reaches Ingest.
+//
+// Sender history is held in memory on this pod; a sender spreading a flood
+// across pods is seen in part by each. Safe for concurrent use.
+type SpamGuard struct {
+	accounts AccountAges
+	auditor  SpamAuditor
+	clock    domain.Clock
+	logger   *slog.Logger
+	policy   atomic.Pointer[domain.SpamPolicy]
+
+	mu      sync.Mutex
+	senders map[string]*senderHistory
+}
+
+// senderHistory is what SpamGuard remembers about one sender.
+type senderHistory struct {
+	sends     []spamSend // ascending by at
+	createdAt time.Time  // zero until looked up
+}
+
+type spamSend struct {
+	hash [sha256.Size]byte
+	at   time.Time
+}
+
+// NewSpamGuard creates a SpamGuard with the given dependencies.
+func NewSpamGuard(cfg SpamGuardConfig) *SpamGuard {
+	g := &SpamGuard{
+		accounts: cfg.Accounts,
+		auditor:  cfg.Auditor,
+		clock:    cfg.Clock,
+		logger:   cfg.Logger,
+		senders:  make(map[string]*senderHistory),
+	}
+	policy := cfg.Policy
+	if policy == (domain.SpamPolicy{}) {
+		policy = domain.DefaultSpamPolicy()
+	}
+	g.SetPolicy(policy)
+	return g
+}
+
+// SetPolicy replaces the thresholds. It takes effect on the next send, so
+// operators can tune or shadow the heuristics without a restart.
+func (g *SpamGuard) SetPolicy(p domain.SpamPolicy) {
+	g.policy.Store(&p)
+}
+
+// Policy returns the thresholds in effect.
+func (g *SpamGuard) Policy() domain.SpamPolicy {
+	return *g.policy.Load()
+}
+
+// Wrap returns a FrameHandler that screens send_message frames before
+// passing them to next. Other frame types pass through untouched.
+func (g *SpamGuard) Wrap(next FrameHandler) FrameHandler {
+	return FrameHandlerFunc(func(ctx context.Context, c *Connection, f *protocol.Frame) error {
+		if f.Type != protocol.FrameTypeSendMessage {
+			return next.HandleFrame(ctx, c, f)
+		}
+		var msg protocol.SendMessage
+		if err := f.ParsePayload(&msg); err != nil {
+			return next.HandleFrame(ctx, c, f) // next reports the malformed payload
+		}
+
+		verdict := g.Check(ctx, c, msg)
+		switch verdict.Action {
+		case domain.SpamShadow:
+			ctx = context.WithValue(ctx, spamFlagKey{}, verdict.Reason)
+		case domain.SpamThrottle:
+			return fmt.Errorf("spam heuristic %s: %w", verdict.Reason, domain.ErrRateLimited)
+		case domain.SpamBlock:
+			return fmt.Errorf("spam heuristic %s: %w", verdict.Reason, domain.ErrForbidden)
+		}
+		return next.HandleFrame(ctx, c, f)
+	})
+}
+
+// Check records msg against its sender's history and returns the verdict.
+// Rejected sends are recorded too, so retrying spam escalates.
+func (g *SpamGuard) Check(ctx context.Context, c *Connection, msg protocol.SendMessage) domain.SpamVerdict {
+	userID := c.Identity().UserID
+	now := g.clock.Now()
+	policy := g.Policy()
+	hash := fingerprint(msg.ContentType, msg.Content)
+
+	// Look up the account age outside the lock; it is cached once known.
+	createdAt := g.createdAt(ctx, userID)
+
+	g.mu.Lock()
+	h := g.senders[userID]
+	if h == nil {
+		h = &senderHistory{}
+		g.senders[userID] = h
+	}
+	if !createdAt.IsZero() {
+		h.createdAt = createdAt
+	}
+	h.prune(now, max(policy.DuplicateWindow, spamVelocityWindow))
+	h.sends = append(h.sends, spamSend{hash: hash, at: now})
+	if len(h.sends) > spamMaxHistory {
+		h.sends = h.sends[len(h.sends)-spamMaxHistory:]
+	}
+	signals := h.signals(now, hash, policy.DuplicateWindow)
+	g.mu.Unlock()
+
+	signals.Links, signals.Words = domain.CountLinks(msg.Content)
+	verdict := policy.Evaluate(signals)
+	if verdict.Action != domain.SpamAllow {
+		g.record(ctx, SpamDecision{
+			UserID:       userID,
+			ConnectionID: c.ID(),
+			ChatID:       msg.ChatID,
+			Action:       verdict.Action,
+			Reason:       verdict.Reason,
+			ContentHash:  hex.EncodeToString(hash[:]),
+			At:           now,
+		})
+	}
+	return verdict
+}
+
+// Run forgets idle senders every minute until ctx is cancelled.
+func (g *SpamGuard) Run(ctx context.Context) {
+	ticker := time.NewTicker(spamSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.Sweep()
+		}
+	}
+}
+
+// Sweep drops senders with no sends inside the longest heuristic window.
+func (g *SpamGuard) Sweep() {
+	now := g.clock.Now()
+	window := max(g.Policy().DuplicateWindow, spamVelocityWindow)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for userID, h := range g.senders {
+		h.prune(now, window)
+		if len(h.sends) == 0 {
+			delete(g.senders, userID)
+		}
+	}
+}
+
+// createdAt returns the sender's cached or freshly looked-up account
+// creation time, or zero when unknown. Lookup failures are not cached and
+// never block the send: a missing age only disables its heuristics.
+func (g *SpamGuard) createdAt(ctx context.Context, userID string) time.Time {
+	if g.accounts == nil {
+		return time.Time{}
+	}
+	g.mu.Lock()
+	if h := g.senders[userID]; h != nil && !h.createdAt.IsZero() {
+		g.mu.Unlock()
+		return h.createdAt
+	}
+	g.mu.Unlock()
+
+	t, err := g.accounts.CreatedAt(ctx, userID)
+	if err != nil {
+		observability.WithTraceID(ctx, g.logger).WarnContext(ctx, "account age lookup failed, skipping new-account heuristics",
+			"user_id", userID, "error", err)
+		return time.Time{}
+	}
+	return t
+}
+
+// record counts, logs and audits a decision.
+func (g *SpamGuard) record(ctx context.Context, d SpamDecision) {
+	spamDecisionsTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("action", d.Action.String()),
+		attribute.String("reason", d.Reason),
+	))
+	observability.WithTraceID(ctx, g.logger).WarnContext(ctx, "gateway.spam_decision",
+		"user_id", d.UserID,
+		"connection_id", d.ConnectionID,
+		"chat_id", d.ChatID,
+		"action", d.Action.String(),
+		"reason", d.Reason,
+		"content_hash", d.ContentHash,
+	)
+	if g.auditor != nil {
+		g.auditor.RecordSpamDecision(ctx, d)
+	}
+}
+
+// prune drops sends older than window.
+func (h *senderHistory) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(h.sends) && !h.sends[i].at.After(cutoff) {
+		i++
+	}
+	h.sends = h.sends[i:]
+}
+
+// signals counts the history-based signals for the send just appended.
+func (h *senderHistory) signals(now time.Time, hash [sha256.Size]byte, dupWindow time.Duration) domain.SpamSignals {
+	var s domain.SpamSignals
+	dupCutoff := now.Add(-dupWindow)
+	velocityCutoff := now.Add(-spamVelocityWindow)
+	for _, send := range h.sends {
+		if send.hash == hash && send.at.After(dupCutoff) {
+			s.Duplicates++
+		}
+		if send.at.After(velocityCutoff) {
+			s.RecentSends++
+		}
+	}
+	if !h.createdAt.IsZero() {
+		s.AccountAge = now.Sub(h.createdAt)
+	}
+	return s
+}
+
+// fingerprint hashes content normalized for case and whitespace, so
+// trivially varied copies still count as duplicates.
+func fingerprint(contentType, content string) [sha256.Size]byte {
+	normalized := strings.ToLower(strings.Join(strings.Fields(content), " "))
+	return sha256.Sum256([]byte(contentType + "\x00" + normalized))
+}
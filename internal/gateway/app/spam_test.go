@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+type stubAccountAges struct {
+	created time.Time
+	err     error
+	calls   int
+}
+
+func (s *stubAccountAges) CreatedAt(context.Context, string) (time.Time, error) {
+	s.calls++
+	return s.created, s.err
+}
+
+type recordingAuditor struct{ decisions []SpamDecision }
+
+func (r *recordingAuditor) RecordSpamDecision(_ context.Context, d SpamDecision) {
+	r.decisions = append(r.decisions, d)
+}
+
+func sendFrame(t *testing.T, content string) *protocol.Frame {
+	t.Helper()
+	f, err := protocol.NewFrame(protocol.FrameTypeSendMessage, protocol.SendMessage{
+		ChatID: "chat-001", ClientMessageID: "c-1", ContentType: string(domain.ContentTypeText), Content: content,
+	})
+	require.NoError(t, err)
+	return f
+}
+
+// countingHandler counts frames that got through and the spam flag on the
+// last one.
+type countingHandler struct {
+	calls int
+	flag  string
+}
+
+func (h *countingHandler) HandleFrame(ctx context.Context, _ *Connection, _ *protocol.Frame) error {
+	h.calls++
+	h.flag, _ = SpamFlag(ctx)
+	return nil
+}
+
+func TestSpamGuard_DuplicateContent(t *testing.T) {
+	ctx := context.Background()
+	clock := domaintest.NewFakeClock(testStart)
+	auditor := &recordingAuditor{}
+	guard := NewSpamGuard(SpamGuardConfig{Auditor: auditor, Clock: clock, Logger: slog.Default()})
+	next := &countingHandler{}
+	h := guard.Wrap(next)
+	conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+	// Copies differing only in case and spacing are duplicates.
+	require.NoError(t, h.HandleFrame(ctx, conn, sendFrame(t, "buy now")))
+	require.NoError(t, h.HandleFrame(ctx, conn, sendFrame(t, "BUY  now")))
+	err := h.HandleFrame(ctx, conn, sendFrame(t, "buy now "))
+	assert.ErrorIs(t, err, domain.ErrRateLimited)
+
+	for range 2 {
+		_ = h.HandleFrame(ctx, conn, sendFrame(t, "buy now"))
+	}
+	err = h.HandleFrame(ctx, conn, sendFrame(t, "buy now"))
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	assert.Equal(t, 2, next.calls)
+
+	require.Len(t, auditor.decisions, 4)
+	d := auditor.decisions[len(auditor.decisions)-1]
+	assert.Equal(t, domain.SpamBlock, d.Action)
+	assert.Equal(t, "duplicate_content", d.Reason)
+	assert.Equal(t, "chat-001", d.ChatID)
+	assert.Len(t, d.ContentHash, 64)
+
+	// Once the window passes the sender starts clean.
+	clock.Advance(domain.SpamDuplicateWindow)
+	assert.NoError(t, h.HandleFrame(ctx, conn, sendFrame(t, "buy now")))
+}
+
+func TestSpamGuard_LinkDensityShadowFlags(t *testing.T) {
+	ctx := context.Background()
+	guard := NewSpamGuard(SpamGuardConfig{Clock: domaintest.NewFakeClock(testStart), Logger: slog.Default()})
+	next := &countingHandler{}
+	conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+	err := guard.Wrap(next).HandleFrame(ctx, conn, sendFrame(t, "https://a.example https://b.example"))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.calls)
+	assert.Equal(t, "link_density", next.flag)
+}
+
+func TestSpamGuard_NewAccountVelocity(t *testing.T) {
+	ctx := context.Background()
+	clock := domaintest.NewFakeClock(testStart)
+	accounts := &stubAccountAges{created: testStart.Add(-time.Hour)}
+	guard := NewSpamGuard(SpamGuardConfig{Accounts: accounts, Clock: clock, Logger: slog.Default()})
+	conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+	var verdict domain.SpamVerdict
+	for i := range domain.SpamNewAccountSendsPerMinute + 1 {
+		verdict = guard.Check(ctx, conn, protocol.SendMessage{Content: string(rune('a' + i))})
+	}
+
+	assert.Equal(t, domain.SpamVerdict{Action: domain.SpamThrottle, Reason: "new_account_velocity"}, verdict)
+	assert.Equal(t, 1, accounts.calls, "account age is cached per sender")
+}
+
+func TestSpamGuard_AccountLookupFailureFailsOpen(t *testing.T) {
+	guard := NewSpamGuard(SpamGuardConfig{
+		Accounts: &stubAccountAges{err: errors.New("users table unavailable")},
+		Clock:    domaintest.NewFakeClock(testStart),
+		Logger:   slog.Default(),
+	})
+	conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+	verdict := guard.Check(context.Background(), conn, protocol.SendMessage{Content: "hello"})
+
+	assert.Equal(t, domain.SpamAllow, verdict.Action)
+}
+
+func TestSpamGuard_SetPolicy(t *testing.T) {
+	ctx := context.Background()
+	guard := NewSpamGuard(SpamGuardConfig{Clock: domaintest.NewFakeClock(testStart), Logger: slog.Default()})
+	conn := newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4)
+
+	policy := guard.Policy()
+	policy.DuplicateThrottleAt = 2
+	policy.ShadowOnly = true
+	guard.SetPolicy(policy)
+
+	guard.Check(ctx, conn, protocol.SendMessage{Content: "hi"})
+	verdict := guard.Check(ctx, conn, protocol.SendMessage{Content: "hi"})
+
+	assert.Equal(t, domain.SpamVerdict{Action: domain.SpamShadow, Reason: "duplicate_content"}, verdict)
+}
+
+func TestSpamGuard_PassesOtherFrames(t *testing.T) {
+	guard := NewSpamGuard(SpamGuardConfig{Clock: domaintest.NewFakeClock(testStart), Logger: slog.Default()})
+	next := &countingHandler{}
+
+	err := guard.Wrap(next).HandleFrame(context.Background(), newConnection("conn-1", Identity{}, testStart, 4), testFrame(t))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.calls)
+}
+
+func TestSpamGuard_Sweep(t *testing.T) {
+	clock := domaintest.NewFakeClock(testStart)
+	guard := NewSpamGuard(SpamGuardConfig{Clock: clock, Logger: slog.Default()})
+	guard.Check(context.Background(), newConnection("conn-1", Identity{UserID: "user-001"}, testStart, 4), protocol.SendMessage{Content: "hi"})
+
+	clock.Advance(domain.SpamDuplicateWindow)
+	guard.Sweep()
+
+	assert.Empty(t, guard.senders)
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var (
+	syncResponses metric.Int64Counter
+
+	syncIncrementalAttr = metric.WithAttributes(attribute.String("mode", "incremental"))
+	syncSnapshotAttr    = metric.WithAttributes(attribute.String("mode", "snapshot"))
+)
+
+func init() {
+	syncResponses, _ = otel.Meter("gateway/app").Int64Counter("gateway_sync_responses_total",
+		metric.WithDescription("Sync responses sent, by mode (incremental, snapshot)"))
+}
+
+// SyncStore reads the chat state a sync response is built from.
+type SyncStore interface {
+	// Chat returns the chat's metadata and latest persisted sequence.
+	Chat(ctx context.Context, chatID string) (protocol.ChatSnapshot, error)
+	// ListAfter returns up to limit messages with sequence > after, in
+	// ascending sequence order.
+	ListAfter(ctx context.Context, chatID string, after uint64, limit int) ([]protocol.Message, error)
+}
+
+// MembershipChecker answers chat membership queries.
+type MembershipChecker interface {
+	IsMember(ctx context.Context, chatID, userID string) (bool, error)
+}
+
+// SyncHandlerConfig holds the dependencies for SyncHandler.
+type SyncHandlerConfig struct {
+	Store   SyncStore
+	Members MembershipChecker
+
+	// Cursors holds the server-side delivery cursors syncs start from. Nil
+	// trusts the client's last_acked_sequence.
+	Cursors CursorReader
+
+	// Policy decides between replay and snapshot. The zero value uses
+	// domain.DefaultSyncPolicy.
+	Policy domain.SyncPolicy
+	// PageSize bounds the messages in an incremental response. Zero
+	// defaults to domain.DefaultPageSize.
+	PageSize int
+}
+
+// SyncHandler answers sync_request frames (ADR-001 §4). A client within the
+// policy's threshold gets the messages after its last acknowledged sequence,
+// a page at a time. A client further behind gets a snapshot: chat metadata,
+// the latest messages and a gap marker for the sequences it skipped.
+type SyncHandler struct {
+	store    SyncStore
+	members  MembershipChecker
+	cursors  CursorReader
+	policy   domain.SyncPolicy
+	pageSize int
+}
+
+// NewSyncHandler creates a SyncHandler.
+func NewSyncHandler(cfg SyncHandlerConfig) *SyncHandler {
+	policy := cfg.Policy
+	if policy == (domain.SyncPolicy{}) {
+		policy = domain.DefaultSyncPolicy()
+	}
+	pageSize := cfg.PageSize
+	if pageSize <= 0 {
+		pageSize = domain.DefaultPageSize
+	}
+	return &SyncHandler{
+		store:    cfg.Store,
+		members:  cfg.Members,
+		cursors:  cfg.Cursors,
+		policy:   policy,
+		pageSize: min(pageSize, domain.MaxPageSize),
+	}
+}
+
+// HandleFrame builds the sync response for a sync_request and queues it.
+func (h *SyncHandler) HandleFrame(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	ctx, span := tracer.Start(ctx, "gateway.sync")
+	defer span.End()
+
+	var req protocol.SyncRequest
+	if err := f.ParsePayload(&req); err != nil {
+		return domain.NewValidationError("payload", "is not a valid sync_request")
+	}
+	if req.ChatID == "" {
+		return domain.NewValidationError("chat_id", "is required")
+	}
+
+	member, err := h.members.IsMember(ctx, req.ChatID, c.Identity().UserID)
+	if err != nil {
+		return fmt.Errorf("check membership: %w", err)
+	}
+	if !member {
+		return fmt.Errorf("sync %s: %w", req.ChatID, domain.ErrNotMember)
+	}
+
+	after, err := h.startAfter(ctx, c.Identity(), req)
+	if err != nil {
+		return err
+	}
+	resp, err := h.Sync(ctx, req.ChatID, after)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(
+		attribute.Bool("sync.snapshot", resp.Gap != nil),
+		attribute.Int("sync.messages", len(resp.Messages)),
+	)
+
+	frame, err := protocol.NewFrame(protocol.FrameTypeSyncResponse, resp)
+	if err != nil {
+		return fmt.Errorf("encode sync_response: %w", err)
+	}
+	if err := c.Enqueue(frame); err != nil {
+		return err
+	}
+	if n := len(resp.Messages); n > 0 {
+		c.acks.sentUpTo(req.ChatID, resp.Messages[n-1].Sequence)
+	}
+	return nil
+}
+
+// startAfter returns the sequence a sync starts after: the device's server
+// cursor, or the client's last_acked_sequence when it asks for less, e.g.
+// after losing local state. A claim beyond the cursor is not trusted; at
+// worst the client receives messages it already has and drops them by
+// message_id. Devices without a cursor start from their claim.
+func (h *SyncHandler) startAfter(ctx context.Context, identity Identity, req protocol.SyncRequest) (uint64, error) {
+	if h.cursors == nil || identity.DeviceID == "" {
+		return req.LastAckedSequence, nil
+	}
+	cursor, ok, err := h.cursors.Cursor(ctx, identity.UserID, identity.DeviceID, req.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return req.LastAckedSequence, nil
+	}
+	return min(cursor, req.LastAckedSequence), nil
+}
+
+// Sync builds the response for a client that acknowledged lastAcked in
+// chatID. Membership is the caller's concern.
+func (h *SyncHandler) Sync(ctx context.Context, chatID string, lastAcked uint64) (protocol.SyncResponse, error) {
+	chat, err := h.store.Chat(ctx, chatID)
+	if err != nil {
+		return protocol.SyncResponse{}, fmt.Errorf("sync %s: read chat: %w", chatID, err)
+	}
+
+	plan := h.policy.Plan(lastAcked, chat.LatestSequence)
+	if plan.Snapshot {
+		messages, err := h.store.ListAfter(ctx, chatID, plan.After, h.policy.SnapshotMessages)
+		if err != nil {
+			return protocol.SyncResponse{}, fmt.Errorf("sync %s: list messages: %w", chatID, err)
+		}
+		syncResponses.Add(ctx, 1, syncSnapshotAttr)
+		return protocol.SyncResponse{
+			ChatID:   chatID,
+			Messages: messages,
+			Gap:      &protocol.SyncGap{FromSequence: lastAcked + 1, ToSequence: plan.After},
+			Chat:     &chat,
+		}, nil
+	}
+
+	// Read one extra message to learn whether another page follows.
+	messages, err := h.store.ListAfter(ctx, chatID, plan.After, h.pageSize+1)
+	if err != nil {
+		return protocol.SyncResponse{}, fmt.Errorf("sync %s: list messages: %w", chatID, err)
+	}
+	hasMore := len(messages) > h.pageSize
+	if hasMore {
+		messages = messages[:h.pageSize]
+	}
+	syncResponses.Add(ctx, 1, syncIncrementalAttr)
+	return protocol.SyncResponse{ChatID: chatID, Messages: messages, HasMore: hasMore}, nil
+}
@@ -0,0 +1,283 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// fakeSyncStore holds one chat with sequences 1..latest.
+type fakeSyncStore struct {
+	latest  uint64
+	listErr error
+	listed  []uint64 // after of each ListAfter call
+}
+
+func (s *fakeSyncStore) Chat(_ context.Context, _ string) (protocol.ChatSnapshot, error) {
+	return protocol.ChatSnapshot{ChatType: "group", Name: "Team", LatestSequence: s.latest}, nil
+}
+
+func (s *fakeSyncStore) ListAfter(_ context.Context, chatID string, after uint64, limit int) ([]protocol.Message, error) {
+	s.listed = append(s.listed, after)
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	var out []protocol.Message
+	for seq := after + 1; seq <= s.latest && len(out) < limit; seq++ {
+		out = append(out, protocol.Message{ChatID: chatID, Sequence: seq})
+	}
+	return out, nil
+}
+
+type stubMembers struct{ member bool }
+
+func (s stubMembers) IsMember(context.Context, string, string) (bool, error) { return s.member, nil }
+
+func seqs(messages []protocol.Message) []uint64 {
+	out := make([]uint64, len(messages))
+	for i, m := range messages {
+		out[i] = m.Sequence
+	}
+	return out
+}
+
+func TestSyncHandler_Sync(t *testing.T) {
+	ctx := context.Background()
+	policy := domain.SyncPolicy{SnapshotThreshold: 100, SnapshotMessages: 3}
+
+	t.Run("incremental replay pages", func(t *testing.T) {
+		store := &fakeSyncStore{latest: 20}
+		h := app.NewSyncHandler(app.SyncHandlerConfig{Store: store, Policy: policy, PageSize: 4})
+
+		resp, err := h.Sync(ctx, "chat-1", 10)
+
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{11, 12, 13, 14}, seqs(resp.Messages))
+		assert.True(t, resp.HasMore)
+		assert.Nil(t, resp.Gap)
+		assert.Nil(t, resp.Chat)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		store := &fakeSyncStore{latest: 12}
+		h := app.NewSyncHandler(app.SyncHandlerConfig{Store: store, Policy: policy, PageSize: 4})
+
+		resp, err := h.Sync(ctx, "chat-1", 10)
+
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{11, 12}, seqs(resp.Messages))
+		assert.False(t, resp.HasMore)
+	})
+
+	t.Run("stale client gets a snapshot and a gap", func(t *testing.T) {
+		store := &fakeSyncStore{latest: 5000}
+		h := app.NewSyncHandler(app.SyncHandlerConfig{Store: store, Policy: policy})
+
+		resp, err := h.Sync(ctx, "chat-1", 10)
+
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{4998, 4999, 5000}, seqs(resp.Messages))
+		assert.False(t, resp.HasMore)
+		require.NotNil(t, resp.Gap)
+		assert.Equal(t, protocol.SyncGap{FromSequence: 11, ToSequence: 4997}, *resp.Gap)
+		require.NotNil(t, resp.Chat)
+		assert.Equal(t, protocol.ChatSnapshot{ChatType: "group", Name: "Team", LatestSequence: 5000}, *resp.Chat)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		store := &fakeSyncStore{latest: 20, listErr: domain.ErrUnavailable}
+		h := app.NewSyncHandler(app.SyncHandlerConfig{Store: store, Policy: policy})
+
+		_, err := h.Sync(ctx, "chat-1", 10)
+
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("zero policy uses domain defaults", func(t *testing.T) {
+		store := &fakeSyncStore{latest: domain.SyncSnapshotThreshold + 1}
+		h := app.NewSyncHandler(app.SyncHandlerConfig{Store: store})
+
+		resp, err := h.Sync(ctx, "chat-1", 0)
+
+		require.NoError(t, err)
+		assert.NotNil(t, resp.Gap)
+		assert.Len(t, resp.Messages, domain.SyncSnapshotMessages)
+	})
+}
+
+func TestSyncHandler_HandleFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		member   bool
+		payload  protocol.SyncRequest
+		wantType protocol.FrameType
+		wantErr  error
+	}{
+		{name: "member gets a sync response", member: true, payload: protocol.SyncRequest{ChatID: "chat-1", LastAckedSequence: 2}, wantType: protocol.FrameTypeSyncResponse},
+		{name: "non-member is refused", payload: protocol.SyncRequest{ChatID: "chat-1"}, wantType: protocol.FrameTypeError, wantErr: domain.ErrNotMember},
+		{name: "chat is required", member: true, wantType: protocol.FrameTypeError, wantErr: domain.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newSessionHarness()
+			h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+				protocol.FrameTypeSyncRequest: app.NewSyncHandler(app.SyncHandlerConfig{
+					Store:   &fakeSyncStore{latest: 5},
+					Members: stubMembers{member: tt.member},
+				}),
+			}
+			h.cfg.ErrorFrame = func(err error) protocol.Error {
+				if !errors.Is(err, tt.wantErr) {
+					return protocol.Error{Code: "UNEXPECTED", Message: err.Error()}
+				}
+				return protocol.Error{Code: "EXPECTED"}
+			}
+			tr := newFakeTransport()
+			done := h.serve(context.Background(), tr)
+			tr.next(t) // ack
+
+			tr.push(t, protocol.FrameTypeSyncRequest, tt.payload)
+
+			f := tr.next(t)
+			require.Equal(t, tt.wantType, f.Type)
+			if tt.wantErr != nil {
+				var e protocol.Error
+				require.NoError(t, f.ParsePayload(&e))
+				assert.Equal(t, "EXPECTED", e.Code, e.Message)
+			} else {
+				var resp protocol.SyncResponse
+				require.NoError(t, f.ParsePayload(&resp))
+				assert.Equal(t, []uint64{3, 4, 5}, seqs(resp.Messages))
+			}
+
+			tr.hangUp()
+			require.NoError(t, wait(t, done))
+		})
+	}
+}
+
+// FuzzSyncHandler_HandleFrame feeds sync_request payloads through the client
+// input path into a session. Whatever the payload, the client gets exactly
+// one reply: a sync response, or an error frame for a client error.
+func FuzzSyncHandler_HandleFrame(f *testing.F) {
+	f.Add(`{"chat_id":"chat-1","last_acked_sequence":2}`)
+	f.Add(`{"chat_id":"chat-1","last_acked_sequence":18446744073709551615}`)
+	f.Add(`{"chat_id":"chat-1","last_acked_sequence":-1}`)
+	f.Add(`{"chat_id":"chat-1","last_acked_sequence":1e300}`)
+	f.Add(`{"chat_id":""}`)
+	f.Add(`{"chat_id":["chat-1"]}`)
+	f.Add(`null`)
+	f.Add(`"chat-1"`)
+
+	f.Fuzz(func(t *testing.T, payload string) {
+		frame, err := app.DecodeClientFrame(app.TextMessage, []byte(`{"type":"sync_request","payload":`+payload+`}`))
+		if err != nil {
+			return // refused before reaching the session
+		}
+
+		h := newSessionHarness()
+		h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+			protocol.FrameTypeSyncRequest: app.NewSyncHandler(app.SyncHandlerConfig{
+				Store:   &fakeSyncStore{latest: 5000},
+				Members: stubMembers{member: true},
+			}),
+		}
+		h.cfg.ErrorFrame = func(err error) protocol.Error {
+			if domain.IsClientError(err) {
+				return protocol.Error{Code: "CLIENT"}
+			}
+			return protocol.Error{Code: "INTERNAL", Message: err.Error()}
+		}
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		tr.inbound <- frame
+
+		reply := tr.next(t)
+		switch reply.Type {
+		case protocol.FrameTypeSyncResponse:
+			var resp protocol.SyncResponse
+			require.NoError(t, reply.ParsePayload(&resp))
+			assert.LessOrEqual(t, len(resp.Messages), domain.MaxPageSize)
+		case protocol.FrameTypeError:
+			var e protocol.Error
+			require.NoError(t, reply.ParsePayload(&e))
+			assert.Equal(t, "CLIENT", e.Code, e.Message)
+		default:
+			t.Fatalf("unexpected reply %s", reply.Type)
+		}
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+}
+
+// TestSyncHandler_Sync_CoversCursorRange checks, for any chat length,
+// cursor, page size and policy, that following a sync's pages returns
+// exactly the messages after the cursor, in order and once each; a
+// snapshot instead returns a gap and the latest messages that together
+// cover the same range.
+func TestSyncHandler_Sync_CoversCursorRange(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		latest := rapid.Uint64Range(0, 500).Draw(t, "latest")
+		lastAcked := rapid.Uint64Range(0, latest+10).Draw(t, "last_acked")
+		pageSize := rapid.IntRange(1, 50).Draw(t, "page_size")
+		policy := domain.SyncPolicy{
+			SnapshotThreshold: rapid.Uint64Range(1, 200).Draw(t, "snapshot_threshold"),
+			SnapshotMessages:  rapid.IntRange(1, 60).Draw(t, "snapshot_messages"),
+		}
+		h := app.NewSyncHandler(app.SyncHandlerConfig{Store: &fakeSyncStore{latest: latest}, Policy: policy, PageSize: pageSize})
+
+		var got []uint64
+		cursor := lastAcked
+		for page := 0; ; page++ {
+			if page > int(latest)+1 {
+				t.Fatalf("sync from %d did not finish", lastAcked)
+			}
+			resp, err := h.Sync(context.Background(), "chat-1", cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Gap != nil {
+				if page > 0 {
+					t.Fatalf("snapshot after %d pages", page)
+				}
+				if resp.Gap.FromSequence != lastAcked+1 || resp.Gap.ToSequence < resp.Gap.FromSequence {
+					t.Fatalf("gap %+v after cursor %d", *resp.Gap, lastAcked)
+				}
+				for seq := resp.Gap.FromSequence; seq <= resp.Gap.ToSequence; seq++ {
+					got = append(got, seq)
+				}
+			} else if len(resp.Messages) > pageSize {
+				t.Fatalf("page of %d messages, page size %d", len(resp.Messages), pageSize)
+			}
+			got = append(got, seqs(resp.Messages)...)
+			if !resp.HasMore {
+				break
+			}
+			if len(resp.Messages) == 0 {
+				t.Fatal("empty page with more to follow")
+			}
+			cursor = resp.Messages[len(resp.Messages)-1].Sequence
+		}
+
+		var want []uint64
+		for seq := lastAcked + 1; seq <= latest; seq++ {
+			want = append(want, seq)
+		}
+		if !slices.Equal(want, got) {
+			t.Fatalf("sync from %d of %d: got %v", lastAcked, latest, got)
+		}
+	})
+}
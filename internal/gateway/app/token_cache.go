@@ -0,0 +1,157 @@
+package app
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	authCacheLookups metric.Int64Counter
+	authCacheSaved   metric.Float64Counter
+
+	authCacheHitAttr  = metric.WithAttributes(attribute.String("result", "hit"))
+	authCacheMissAttr = metric.WithAttributes(attribute.String("result", "miss"))
+)
+
+func init() {
+	m := otel.Meter("gateway/app")
+
+	authCacheLookups, _ = m.Int64Counter("gateway_auth_cache_lookups_total",
+		metric.WithDescription("Access token cache lookups, by result (hit, miss)"))
+	authCacheSaved, _ = m.Float64Counter("gateway_auth_cache_saved_seconds_total",
+		metric.WithDescription("Estimated token validation time avoided by cache hits"),
+		metric.WithUnit("s"))
+}
+
+// AccessToken is a validated access token: the identity it carries and the
+// JTI and issue time that revocations are checked against.
+type AccessToken struct {
+	Identity Identity
+	JTI      string
+	IssuedAt time.Time
+}
+
+// ValidateFunc fully validates an access token. TokenCache calls it on a
+// miss.
+type ValidateFunc func(accessToken string) (AccessToken, error)
+
+// TokenCache remembers validated access tokens so a client presenting the
+// same token again (reconnects, resumed streams) skips RS256 verification.
+// It does not remember revocation checks: TokenAuthenticator makes those on
+// every use. Entries are keyed by the token's SHA-256, never the token
+// itself, and live until the token expires or they are evicted least
+// recently used. Failed validations are not cached. Safe for concurrent use.
+type TokenCache struct {
+	clock    domain.Clock
+	capacity int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     list.List // front is most recently used; values are *tokenEntry
+	cost    time.Duration
+}
+
+type tokenEntry struct {
+	key   [sha256.Size]byte
+	token AccessToken
+}
+
+// NewTokenCache creates a TokenCache holding up to capacity tokens. Zero
+// defaults to domain.AuthCacheEntries.
+func NewTokenCache(capacity int, clock domain.Clock) *TokenCache {
+	if capacity <= 0 {
+		capacity = domain.AuthCacheEntries
+	}
+	return &TokenCache{
+		clock:    clock,
+		capacity: capacity,
+		entries:  make(map[[sha256.Size]byte]*list.Element, capacity),
+	}
+}
+
+// Token returns the cached validation of accessToken, calling validate on a
+// miss or once the cached token has expired.
+func (c *TokenCache) Token(ctx context.Context, accessToken string, validate ValidateFunc) (AccessToken, error) {
+	key := sha256.Sum256([]byte(accessToken))
+
+	token, cost, ok := c.get(key)
+	if ok {
+		authCacheLookups.Add(ctx, 1, authCacheHitAttr)
+		authCacheSaved.Add(ctx, cost.Seconds())
+		return token, nil
+	}
+	authCacheLookups.Add(ctx, 1, authCacheMissAttr)
+
+	start := time.Now()
+	token, err := validate(accessToken)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	c.put(&tokenEntry{key: key, token: token}, time.Since(start))
+	return token, nil
+}
+
+// Len returns the number of cached tokens.
+func (c *TokenCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// get returns the live entry for key and the average validation time a hit
+// saves.
+func (c *TokenCache) get(key [sha256.Size]byte) (AccessToken, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return AccessToken{}, 0, false
+	}
+	e := el.Value.(*tokenEntry)
+	if !c.clock.Now().Before(e.token.Identity.AccessTokenExpiry) {
+		c.remove(el)
+		return AccessToken{}, 0, false
+	}
+	c.lru.MoveToFront(el)
+	return e.token, c.cost, true
+}
+
+func (c *TokenCache) put(e *tokenEntry, cost time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Exponential moving average, weighting the newest sample 1/8.
+	if c.cost == 0 {
+		c.cost = cost
+	} else {
+		c.cost += (cost - c.cost) / 8
+	}
+
+	if e.token.Identity.AccessTokenExpiry.IsZero() {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	if c.lru.Len() >= c.capacity {
+		c.remove(c.lru.Back())
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+}
+
+func (c *TokenCache) remove(el *list.Element) {
+	delete(c.entries, el.Value.(*tokenEntry).key)
+	c.lru.Remove(el)
+}
@@ -0,0 +1,482 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+var (
+	uploadBytes metric.Int64Counter
+	uploadsDone metric.Int64Counter
+
+	uploadCompletedAttr = metric.WithAttributes(attribute.String("result", "completed"))
+	uploadRejectedAttr  = metric.WithAttributes(attribute.String("result", "rejected"))
+)
+
+func init() {
+	meter := otel.Meter("gateway/app")
+	uploadBytes, _ = meter.Int64Counter("gateway_upload_bytes_total",
+		metric.WithDescription("Attachment bytes received in upload_chunk frames"))
+	uploadsDone, _ = meter.Int64Counter("gateway_uploads_total",
+		metric.WithDescription("Attachment uploads committed, by result (completed, rejected)"))
+}
+
+// UploadedPart is one stored part of a multipart upload.
+type UploadedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// MultipartStore holds attachments as multipart uploads. Every part but
+// the last is exactly the handler's part size.
+type MultipartStore interface {
+	CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error)
+	UploadPart(ctx context.Context, key, multipartID string, number int, data []byte) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key, multipartID string, parts []UploadedPart) error
+	AbortMultipartUpload(ctx context.Context, key, multipartID string) error
+}
+
+// UploadSession is the durable progress of an upload: what any pod needs
+// to resume it. Offset counts the bytes stored in Parts; HashState is the
+// SHA-256 state after those bytes.
+type UploadSession struct {
+	UploadID    string         `json:"upload_id"`
+	UserID      string         `json:"user_id"`
+	ChatID      string         `json:"chat_id"`
+	Key         string         `json:"key"`
+	MultipartID string         `json:"multipart_id"`
+	FileName    string         `json:"file_name,omitempty"`
+	ContentType string         `json:"content_type"`
+	Size        int64          `json:"size"`
+	SHA256      string         `json:"sha256"`
+	Offset      int64          `json:"offset"`
+	Parts       []UploadedPart `json:"parts,omitempty"`
+	HashState   []byte         `json:"hash_state"`
+}
+
+// UploadSessionStore persists upload sessions across connections and pods.
+// Sessions expire domain.UploadSessionTTL after they were last saved.
+type UploadSessionStore interface {
+	SaveUpload(ctx context.Context, s UploadSession) error
+	// GetUpload returns false when the upload is unknown or expired.
+	GetUpload(ctx context.Context, uploadID string) (UploadSession, bool, error)
+	DeleteUpload(ctx context.Context, uploadID string) error
+}
+
+// AttachmentLimiter returns the largest attachment a user may upload.
+type AttachmentLimiter interface {
+	MaxAttachmentSize(ctx context.Context, userID string) (int64, error)
+}
+
+// UploadHandlerConfig holds the dependencies for UploadHandler.
+type UploadHandlerConfig struct {
+	Store    MultipartStore
+	Sessions UploadSessionStore
+	Members  MembershipChecker
+	Logger   *slog.Logger
+
+	// Limits caps uploads by entitlement. Nil caps every user at
+	// domain.MaxAttachmentSize.
+	Limits AttachmentLimiter
+
+	// Zero values default to domain.UploadPartSize and
+	// domain.MaxUploadsPerConnection.
+	PartSize   int
+	MaxUploads int
+}
+
+// UploadHandler assembles attachments sent over the connection in
+// upload_begin, upload_chunk and upload_commit frames into multipart
+// uploads (ADR-005 §3.13). Chunks are buffered until a part is full; each
+// stored part is saved to the session store, so a client that reconnects,
+// to this pod or another, resumes from the last stored part. Every frame
+// is answered with an upload_status. Safe for concurrent use.
+type UploadHandler struct {
+	store      MultipartStore
+	sessions   UploadSessionStore
+	members    MembershipChecker
+	limits     AttachmentLimiter
+	logger     *slog.Logger
+	partSize   int
+	maxUploads int
+
+	mu     sync.Mutex
+	active map[string]*activeUpload // by upload ID
+}
+
+// activeUpload is an upload in progress on one connection. buf holds the
+// bytes after session.Offset not yet stored as a part.
+type activeUpload struct {
+	mu      sync.Mutex
+	conn    *Connection
+	session UploadSession
+	hash    hash.Hash
+	buf     []byte
+}
+
+// NewUploadHandler creates an UploadHandler.
+func NewUploadHandler(cfg UploadHandlerConfig) *UploadHandler {
+	h := &UploadHandler{
+		store:      cfg.Store,
+		sessions:   cfg.Sessions,
+		members:    cfg.Members,
+		limits:     cfg.Limits,
+		logger:     cfg.Logger,
+		partSize:   cfg.PartSize,
+		maxUploads: cfg.MaxUploads,
+		active:     make(map[string]*activeUpload),
+	}
+	if h.partSize <= 0 {
+		h.partSize = domain.UploadPartSize
+	}
+	if h.maxUploads <= 0 {
+		h.maxUploads = domain.MaxUploadsPerConnection
+	}
+	return h
+}
+
+// HandleFrame dispatches the upload frame types. Register it under each of
+// them.
+func (h *UploadHandler) HandleFrame(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	if !c.upload {
+		return domain.NewValidationError("type", "requires the upload capability")
+	}
+	switch f.Type {
+	case protocol.FrameTypeUploadBegin:
+		return h.begin(ctx, c, f)
+	case protocol.FrameTypeUploadChunk:
+		return h.chunk(ctx, c, f)
+	case protocol.FrameTypeUploadCommit:
+		return h.commit(ctx, c, f)
+	default:
+		return domain.NewValidationError("type", "is not an upload frame")
+	}
+}
+
+// Active reports the uploads in progress on this pod.
+func (h *UploadHandler) Active() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.active)
+}
+
+func (h *UploadHandler) begin(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	ctx, span := tracer.Start(ctx, "gateway.upload.begin")
+	defer span.End()
+
+	var req protocol.UploadBegin
+	if err := f.ParsePayload(&req); err != nil {
+		return domain.NewValidationError("payload", "is not a valid upload_begin")
+	}
+	if h.inProgress(c) >= h.maxUploads {
+		return fmt.Errorf("%w: %d uploads in progress on this connection", domain.ErrRateLimited, h.maxUploads)
+	}
+
+	var (
+		session UploadSession
+		err     error
+	)
+	if req.UploadID != "" {
+		session, err = h.resume(ctx, c, req.UploadID)
+	} else {
+		session, err = h.create(ctx, c, req)
+	}
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(
+		attribute.String("upload.id", session.UploadID),
+		attribute.Int64("upload.offset", session.Offset),
+	)
+
+	digest := sha256.New()
+	if len(session.HashState) > 0 {
+		if err := digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(session.HashState); err != nil {
+			return fmt.Errorf("restore upload %s hash: %w", session.UploadID, err)
+		}
+	}
+	u := &activeUpload{conn: c, session: session, hash: digest}
+
+	// A resumed upload moves to this connection; chunks still arriving on
+	// the old one are refused.
+	h.mu.Lock()
+	h.active[session.UploadID] = u
+	h.mu.Unlock()
+	go func() {
+		<-c.Done()
+		h.drop(session.UploadID, u)
+	}()
+
+	return h.status(c, session.UploadID, session.Offset, "")
+}
+
+// create validates a new upload and starts its multipart upload.
+func (h *UploadHandler) create(ctx context.Context, c *Connection, req protocol.UploadBegin) (UploadSession, error) {
+	switch {
+	case req.ChatID == "":
+		return UploadSession{}, domain.NewValidationError("chat_id", "is required")
+	case req.ContentType == "":
+		return UploadSession{}, domain.NewValidationError("content_type", "is required")
+	case req.Size <= 0:
+		return UploadSession{}, domain.NewValidationError("size", "must be positive")
+	case !validSHA256(req.SHA256):
+		return UploadSession{}, domain.NewValidationError("sha256", "must be a hex SHA-256 digest")
+	}
+
+	userID := c.Identity().UserID
+	limit := int64(domain.MaxAttachmentSize)
+	if h.limits != nil {
+		var err error
+		if limit, err = h.limits.MaxAttachmentSize(ctx, userID); err != nil {
+			return UploadSession{}, fmt.Errorf("attachment limit: %w", err)
+		}
+	}
+	if req.Size > limit {
+		return UploadSession{}, fmt.Errorf("%w: attachment of %d bytes, limit %d", domain.ErrMessageTooLarge, req.Size, limit)
+	}
+
+	member, err := h.members.IsMember(ctx, req.ChatID, userID)
+	if err != nil {
+		return UploadSession{}, fmt.Errorf("check membership: %w", err)
+	}
+	if !member {
+		return UploadSession{}, fmt.Errorf("upload to %s: %w", req.ChatID, domain.ErrNotMember)
+	}
+
+	uploadID := domain.GenerateUploadID()
+	key := "attachments/" + req.ChatID + "/" + uploadID
+	multipartID, err := h.store.CreateMultipartUpload(ctx, key, req.ContentType)
+	if err != nil {
+		return UploadSession{}, fmt.Errorf("create multipart upload: %w", err)
+	}
+	session := UploadSession{
+		UploadID:    uploadID,
+		UserID:      userID,
+		ChatID:      req.ChatID,
+		Key:         key,
+		MultipartID: multipartID,
+		FileName:    req.FileName,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		SHA256:      req.SHA256,
+	}
+	if err := h.sessions.SaveUpload(ctx, session); err != nil {
+		h.abort(ctx, session)
+		return UploadSession{}, fmt.Errorf("save upload %s: %w", uploadID, err)
+	}
+	return session, nil
+}
+
+// resume loads an upload begun earlier by the same user.
+func (h *UploadHandler) resume(ctx context.Context, c *Connection, uploadID string) (UploadSession, error) {
+	session, ok, err := h.sessions.GetUpload(ctx, uploadID)
+	if err != nil {
+		return UploadSession{}, fmt.Errorf("load upload %s: %w", uploadID, err)
+	}
+	if !ok || session.UserID != c.Identity().UserID {
+		return UploadSession{}, fmt.Errorf("upload %s: %w", uploadID, domain.ErrNotFound)
+	}
+	return session, nil
+}
+
+func (h *UploadHandler) chunk(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	ctx, span := tracer.Start(ctx, "gateway.upload.chunk")
+	defer span.End()
+
+	var req protocol.UploadChunk
+	if err := f.ParsePayload(&req); err != nil {
+		return domain.NewValidationError("payload", "is not a valid upload_chunk")
+	}
+	switch {
+	case len(req.Data) == 0:
+		return domain.NewValidationError("data", "is required")
+	case len(req.Data) > domain.UploadChunkSize:
+		return fmt.Errorf("%w: chunk of %d bytes, limit %d", domain.ErrMessageTooLarge, len(req.Data), domain.UploadChunkSize)
+	case !checksumMatches(req.Data, req.SHA256):
+		return domain.NewValidationError("sha256", "does not match data")
+	}
+
+	u, err := h.lookup(c, req.UploadID)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// A chunk from any other offset was lost or duplicated on the way;
+	// the client resends from the offset in the reply.
+	next := u.session.Offset + int64(len(u.buf))
+	if req.Offset != next {
+		return h.status(c, req.UploadID, next, "")
+	}
+	if next+int64(len(req.Data)) > u.session.Size {
+		return domain.NewValidationError("data", "extends past the declared size")
+	}
+	u.buf = append(u.buf, req.Data...)
+	uploadBytes.Add(ctx, int64(len(req.Data)))
+
+	for len(u.buf) >= h.partSize {
+		if err := h.storePart(ctx, u, h.partSize); err != nil {
+			return err
+		}
+	}
+	return h.status(c, req.UploadID, u.session.Offset+int64(len(u.buf)), "")
+}
+
+func (h *UploadHandler) commit(ctx context.Context, c *Connection, f *protocol.Frame) error {
+	ctx, span := tracer.Start(ctx, "gateway.upload.commit")
+	defer span.End()
+
+	var req protocol.UploadCommit
+	if err := f.ParsePayload(&req); err != nil {
+		return domain.NewValidationError("payload", "is not a valid upload_commit")
+	}
+	u, err := h.lookup(c, req.UploadID)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if received := u.session.Offset + int64(len(u.buf)); received != u.session.Size {
+		return domain.NewValidationError("upload_id", fmt.Sprintf("has %d of %d bytes", received, u.session.Size))
+	}
+	if len(u.buf) > 0 {
+		if err := h.storePart(ctx, u, len(u.buf)); err != nil {
+			return err
+		}
+	}
+
+	h.drop(req.UploadID, u)
+	if hex.EncodeToString(u.hash.Sum(nil)) != u.session.SHA256 {
+		h.abort(ctx, u.session)
+		h.deleteSession(ctx, u.session.UploadID)
+		uploadsDone.Add(ctx, 1, uploadRejectedAttr)
+		return domain.NewValidationError("sha256", "does not match the uploaded file")
+	}
+	if err := h.store.CompleteMultipartUpload(ctx, u.session.Key, u.session.MultipartID, u.session.Parts); err != nil {
+		return fmt.Errorf("complete upload %s: %w", req.UploadID, err)
+	}
+	h.deleteSession(ctx, u.session.UploadID)
+	uploadsDone.Add(ctx, 1, uploadCompletedAttr)
+
+	return h.status(c, req.UploadID, u.session.Size, u.session.Key)
+}
+
+// storePart uploads the first n buffered bytes as the next part and saves
+// the session. On failure the bytes stay buffered and the next chunk or
+// commit retries them.
+func (h *UploadHandler) storePart(ctx context.Context, u *activeUpload, n int) error {
+	s := &u.session
+	number := len(s.Parts) + 1
+	etag, err := h.store.UploadPart(ctx, s.Key, s.MultipartID, number, u.buf[:n])
+	if err != nil {
+		return fmt.Errorf("upload %s part %d: %w", s.UploadID, number, err)
+	}
+
+	u.hash.Write(u.buf[:n])
+	state, err := u.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("snapshot upload %s hash: %w", s.UploadID, err)
+	}
+	s.Parts = append(s.Parts, UploadedPart{Number: number, ETag: etag})
+	s.Offset += int64(n)
+	s.HashState = state
+	u.buf = append(u.buf[:0], u.buf[n:]...)
+
+	// The part is stored either way; an unsaved session only means a
+	// client resuming elsewhere resends it.
+	if err := h.sessions.SaveUpload(ctx, *s); err != nil {
+		h.logger.WarnContext(ctx, "upload session save failed",
+			"upload_id", s.UploadID, "offset", s.Offset, "error", err)
+	}
+	return nil
+}
+
+// lookup returns the upload begun on c under uploadID.
+func (h *UploadHandler) lookup(c *Connection, uploadID string) (*activeUpload, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u, ok := h.active[uploadID]
+	if !ok || u.conn != c {
+		return nil, fmt.Errorf("upload %s not begun on this connection: %w", uploadID, domain.ErrNotFound)
+	}
+	return u, nil
+}
+
+// inProgress counts the uploads begun on c.
+func (h *UploadHandler) inProgress(c *Connection) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, u := range h.active {
+		if u.conn == c {
+			n++
+		}
+	}
+	return n
+}
+
+// drop forgets u unless the upload has since moved to another connection.
+// Buffered bytes are lost; the client resends them from the stored offset.
+func (h *UploadHandler) drop(uploadID string, u *activeUpload) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.active[uploadID] == u {
+		delete(h.active, uploadID)
+	}
+}
+
+// abort discards s's stored parts. Failures are only logged; the bucket's
+// lifecycle rule removes incomplete uploads.
+func (h *UploadHandler) abort(ctx context.Context, s UploadSession) {
+	if err := h.store.AbortMultipartUpload(ctx, s.Key, s.MultipartID); err != nil {
+		h.logger.WarnContext(ctx, "multipart upload abort failed", "upload_id", s.UploadID, "error", err)
+	}
+}
+
+// deleteSession removes a finished upload's session. Failures are only
+// logged; the session expires with its TTL.
+func (h *UploadHandler) deleteSession(ctx context.Context, uploadID string) {
+	if err := h.sessions.DeleteUpload(ctx, uploadID); err != nil {
+		h.logger.WarnContext(ctx, "upload session delete failed", "upload_id", uploadID, "error", err)
+	}
+}
+
+// status queues an upload_status. A non-empty key marks the upload complete.
+func (h *UploadHandler) status(c *Connection, uploadID string, offset int64, key string) error {
+	frame, err := protocol.NewFrame(protocol.FrameTypeUploadStatus, protocol.UploadStatus{
+		UploadID:      uploadID,
+		Offset:        offset,
+		MaxChunkBytes: domain.UploadChunkSize,
+		Complete:      key != "",
+		AttachmentKey: key,
+	})
+	if err != nil {
+		return fmt.Errorf("encode upload_status: %w", err)
+	}
+	return c.Enqueue(frame)
+}
+
+func validSHA256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+func checksumMatches(data []byte, want string) bool {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) == want
+}
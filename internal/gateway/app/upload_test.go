@@ -0,0 +1,395 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// memMultipart implements app.MultipartStore for a single object.
+type memMultipart struct {
+	mu        sync.Mutex
+	parts     map[int][]byte
+	completed []byte
+	aborted   bool
+	failParts bool
+}
+
+func newMemMultipart() *memMultipart {
+	return &memMultipart{parts: make(map[int][]byte)}
+}
+
+func (m *memMultipart) CreateMultipartUpload(context.Context, string, string) (string, error) {
+	return "mp-1", nil
+}
+
+func (m *memMultipart) UploadPart(_ context.Context, _, _ string, number int, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failParts {
+		return "", errors.New("s3 unavailable")
+	}
+	m.parts[number] = bytes.Clone(data)
+	return fmt.Sprintf(`"etag-%d"`, number), nil
+}
+
+func (m *memMultipart) CompleteMultipartUpload(_ context.Context, _, _ string, parts []app.UploadedPart) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var object []byte
+	for i, p := range parts {
+		if p.Number != i+1 || p.ETag != fmt.Sprintf(`"etag-%d"`, p.Number) {
+			return fmt.Errorf("bad part %+v", p)
+		}
+		object = append(object, m.parts[p.Number]...)
+	}
+	m.completed = object
+	return nil
+}
+
+func (m *memMultipart) AbortMultipartUpload(context.Context, string, string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aborted = true
+	return nil
+}
+
+// memUploadSessions implements app.UploadSessionStore over a map.
+type memUploadSessions struct {
+	mu       sync.Mutex
+	sessions map[string]app.UploadSession
+}
+
+func newMemUploadSessions() *memUploadSessions {
+	return &memUploadSessions{sessions: make(map[string]app.UploadSession)}
+}
+
+func (s *memUploadSessions) SaveUpload(_ context.Context, u app.UploadSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[u.UploadID] = u
+	return nil
+}
+
+func (s *memUploadSessions) GetUpload(_ context.Context, id string) (app.UploadSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.sessions[id]
+	return u, ok, nil
+}
+
+func (s *memUploadSessions) DeleteUpload(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *memUploadSessions) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// uploadErrorFrame names the domain errors upload tests expect.
+func uploadErrorFrame(err error) protocol.Error {
+	for code, sentinel := range map[string]error{
+		"INVALID_INPUT":     domain.ErrInvalidInput,
+		"NOT_FOUND":         domain.ErrNotFound,
+		"NOT_MEMBER":        domain.ErrNotMember,
+		"MESSAGE_TOO_LARGE": domain.ErrMessageTooLarge,
+		"RATE_LIMITED":      domain.ErrRateLimited,
+	} {
+		if errors.Is(err, sentinel) {
+			return protocol.Error{Code: code, Message: err.Error()}
+		}
+	}
+	return protocol.Error{Code: "INTERNAL", Message: err.Error()}
+}
+
+type uploadHarness struct {
+	*sessionHarness
+	store    *memMultipart
+	sessions *memUploadSessions
+}
+
+// newUploadHarness serves sessions with an UploadHandler storing 4-byte
+// parts.
+func newUploadHarness(members bool) *uploadHarness {
+	h := &uploadHarness{
+		sessionHarness: newSessionHarness(),
+		store:          newMemMultipart(),
+		sessions:       newMemUploadSessions(),
+	}
+	handler := app.NewUploadHandler(app.UploadHandlerConfig{
+		Store:    h.store,
+		Sessions: h.sessions,
+		Members:  stubMembers{member: members},
+		Logger:   slog.Default(),
+		PartSize: 4,
+	})
+	h.cfg.Handlers = map[protocol.FrameType]app.FrameHandler{
+		protocol.FrameTypeUploadBegin:  handler,
+		protocol.FrameTypeUploadChunk:  handler,
+		protocol.FrameTypeUploadCommit: handler,
+	}
+	h.cfg.ErrorFrame = uploadErrorFrame
+	return h
+}
+
+// connect serves an upload-capable transport and consumes connection_ack.
+func (h *uploadHarness) connect(t *testing.T) (*fakeTransport, <-chan error) {
+	t.Helper()
+	fake := newFakeTransport()
+	done := h.serve(context.Background(), &capabilityTransport{fakeTransport: fake, caps: []string{protocol.CapabilityUpload}})
+	var ack protocol.ConnectionAck
+	require.NoError(t, fake.next(t).ParsePayload(&ack))
+	require.Equal(t, []string{protocol.CapabilityUpload}, ack.Capabilities)
+	return fake, done
+}
+
+func nextUploadStatus(t *testing.T, tr *fakeTransport) protocol.UploadStatus {
+	t.Helper()
+	f := tr.next(t)
+	require.Equal(t, protocol.FrameTypeUploadStatus, f.Type, "frame: %s", f.Payload)
+	var s protocol.UploadStatus
+	require.NoError(t, f.ParsePayload(&s))
+	return s
+}
+
+func nextUploadError(t *testing.T, tr *fakeTransport) protocol.Error {
+	t.Helper()
+	f := tr.next(t)
+	require.Equal(t, protocol.FrameTypeError, f.Type, "frame: %s", f.Payload)
+	var e protocol.Error
+	require.NoError(t, f.ParsePayload(&e))
+	return e
+}
+
+func pushChunk(t *testing.T, tr *fakeTransport, uploadID string, offset int64, data []byte) {
+	t.Helper()
+	tr.push(t, protocol.FrameTypeUploadChunk, protocol.UploadChunk{
+		UploadID: uploadID, Offset: offset, Data: data, SHA256: hexSHA256(data),
+	})
+}
+
+func TestUploadHandler(t *testing.T) {
+	file := []byte("0123456789")
+	begin := protocol.UploadBegin{
+		ChatID: "chat-001", ContentType: "text/plain", Size: int64(len(file)), SHA256: hexSHA256(file),
+	}
+
+	t.Run("assembles chunks into parts and completes", func(t *testing.T) {
+		h := newUploadHarness(true)
+		tr, done := h.connect(t)
+
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+		st := nextUploadStatus(t, tr)
+		require.NotEmpty(t, st.UploadID)
+		assert.Zero(t, st.Offset)
+		assert.Equal(t, domain.UploadChunkSize, st.MaxChunkBytes)
+
+		for off := 0; off < len(file); off += 3 {
+			pushChunk(t, tr, st.UploadID, int64(off), file[off:min(off+3, len(file))])
+			assert.Equal(t, int64(min(off+3, len(file))), nextUploadStatus(t, tr).Offset)
+		}
+		tr.push(t, protocol.FrameTypeUploadCommit, protocol.UploadCommit{UploadID: st.UploadID})
+
+		final := nextUploadStatus(t, tr)
+		assert.True(t, final.Complete)
+		assert.Equal(t, "attachments/chat-001/"+st.UploadID, final.AttachmentKey)
+		assert.Equal(t, file, h.store.completed)
+		assert.Len(t, h.store.parts, 3, "4 + 4 + 2 bytes")
+		assert.Zero(t, h.sessions.len())
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("a chunk from the wrong offset is answered with the expected one", func(t *testing.T) {
+		h := newUploadHarness(true)
+		tr, done := h.connect(t)
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+		id := nextUploadStatus(t, tr).UploadID
+
+		pushChunk(t, tr, id, 0, file[:3])
+		nextUploadStatus(t, tr)
+		pushChunk(t, tr, id, 6, file[6:9])
+
+		assert.Equal(t, int64(3), nextUploadStatus(t, tr).Offset)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("resumes on a new connection from the last stored part", func(t *testing.T) {
+		h := newUploadHarness(true)
+		first, firstDone := h.connect(t)
+		first.push(t, protocol.FrameTypeUploadBegin, begin)
+		id := nextUploadStatus(t, first).UploadID
+		pushChunk(t, first, id, 0, file[:5])
+		nextUploadStatus(t, first)
+		first.hangUp()
+		require.NoError(t, wait(t, firstDone))
+
+		second, secondDone := h.connect(t)
+		second.push(t, protocol.FrameTypeUploadBegin, protocol.UploadBegin{UploadID: id})
+		st := nextUploadStatus(t, second)
+		require.Equal(t, int64(4), st.Offset, "the buffered fifth byte was lost")
+
+		pushChunk(t, second, id, 4, file[4:])
+		nextUploadStatus(t, second)
+		second.push(t, protocol.FrameTypeUploadCommit, protocol.UploadCommit{UploadID: id})
+
+		assert.True(t, nextUploadStatus(t, second).Complete)
+		assert.Equal(t, file, h.store.completed)
+
+		second.hangUp()
+		require.NoError(t, wait(t, secondDone))
+	})
+
+	t.Run("a file that does not match its checksum is aborted", func(t *testing.T) {
+		h := newUploadHarness(true)
+		tr, done := h.connect(t)
+		wrong := begin
+		wrong.SHA256 = hexSHA256([]byte("something else"))
+		tr.push(t, protocol.FrameTypeUploadBegin, wrong)
+		id := nextUploadStatus(t, tr).UploadID
+		pushChunk(t, tr, id, 0, file)
+		nextUploadStatus(t, tr)
+
+		tr.push(t, protocol.FrameTypeUploadCommit, protocol.UploadCommit{UploadID: id})
+
+		assert.Equal(t, "INVALID_INPUT", nextUploadError(t, tr).Code)
+		assert.True(t, h.store.aborted)
+		assert.Nil(t, h.store.completed)
+		assert.Zero(t, h.sessions.len())
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("a failed part is retried by the next chunk", func(t *testing.T) {
+		h := newUploadHarness(true)
+		tr, done := h.connect(t)
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+		id := nextUploadStatus(t, tr).UploadID
+
+		h.store.mu.Lock()
+		h.store.failParts = true
+		h.store.mu.Unlock()
+		pushChunk(t, tr, id, 0, file[:5])
+		assert.Equal(t, "INTERNAL", nextUploadError(t, tr).Code)
+
+		h.store.mu.Lock()
+		h.store.failParts = false
+		h.store.mu.Unlock()
+		pushChunk(t, tr, id, 5, file[5:])
+		assert.Equal(t, int64(len(file)), nextUploadStatus(t, tr).Offset)
+		tr.push(t, protocol.FrameTypeUploadCommit, protocol.UploadCommit{UploadID: id})
+		assert.True(t, nextUploadStatus(t, tr).Complete)
+		assert.Equal(t, file, h.store.completed)
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("refuses invalid frames", func(t *testing.T) {
+		h := newUploadHarness(true)
+		tr, done := h.connect(t)
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+		id := nextUploadStatus(t, tr).UploadID
+
+		tr.push(t, protocol.FrameTypeUploadChunk, protocol.UploadChunk{UploadID: id, Data: file[:3], SHA256: hexSHA256(file)})
+		assert.Equal(t, "INVALID_INPUT", nextUploadError(t, tr).Code, "chunk checksum")
+
+		pushChunk(t, tr, id, 0, append(bytes.Clone(file), 'x'))
+		assert.Equal(t, "INVALID_INPUT", nextUploadError(t, tr).Code, "past the declared size")
+
+		tr.push(t, protocol.FrameTypeUploadCommit, protocol.UploadCommit{UploadID: id})
+		assert.Equal(t, "INVALID_INPUT", nextUploadError(t, tr).Code, "commit before every byte")
+
+		pushChunk(t, tr, "unknown", 0, file[:3])
+		assert.Equal(t, "NOT_FOUND", nextUploadError(t, tr).Code)
+
+		tooBig := begin
+		tooBig.Size = domain.MaxAttachmentSize + 1
+		tr.push(t, protocol.FrameTypeUploadBegin, tooBig)
+		assert.Equal(t, "MESSAGE_TOO_LARGE", nextUploadError(t, tr).Code)
+
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+		nextUploadStatus(t, tr)
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+		assert.Equal(t, "RATE_LIMITED", nextUploadError(t, tr).Code, "third upload on the connection")
+
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("only members upload to a chat", func(t *testing.T) {
+		h := newUploadHarness(false)
+		tr, done := h.connect(t)
+
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+
+		assert.Equal(t, "NOT_MEMBER", nextUploadError(t, tr).Code)
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("another user cannot resume an upload", func(t *testing.T) {
+		h := newUploadHarness(true)
+		require.NoError(t, h.sessions.SaveUpload(context.Background(), app.UploadSession{UploadID: "upload-x", UserID: "user-002"}))
+		tr, done := h.connect(t)
+
+		tr.push(t, protocol.FrameTypeUploadBegin, protocol.UploadBegin{UploadID: "upload-x"})
+
+		assert.Equal(t, "NOT_FOUND", nextUploadError(t, tr).Code)
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+
+	t.Run("clients that did not negotiate uploads are refused", func(t *testing.T) {
+		h := newUploadHarness(true)
+		tr := newFakeTransport()
+		done := h.serve(context.Background(), tr)
+		tr.next(t) // ack
+
+		tr.push(t, protocol.FrameTypeUploadBegin, begin)
+
+		assert.Equal(t, "INVALID_INPUT", nextUploadError(t, tr).Code)
+		tr.hangUp()
+		require.NoError(t, wait(t, done))
+	})
+}
+
+func TestSessionManager_NegotiatesUploadOnlyWithAHandler(t *testing.T) {
+	h := newSessionHarness()
+	fake := newFakeTransport()
+	done := h.serve(context.Background(), &capabilityTransport{fakeTransport: fake, caps: []string{protocol.CapabilityUpload}})
+
+	var ack protocol.ConnectionAck
+	require.NoError(t, fake.next(t).ParsePayload(&ack))
+	assert.Empty(t, ack.Capabilities)
+
+	fake.hangUp()
+	require.NoError(t, wait(t, done))
+}
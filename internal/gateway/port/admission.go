@@ -0,0 +1,27 @@
+package port
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// connectionAdmitter is a narrow, consumer-defined interface for admission
+// control. The *app.AdmissionController satisfies this.
+type connectionAdmitter interface {
+	AdmitConnection(ctx context.Context) error
+}
+
+// AdmissionMiddleware refuses WebSocket upgrades while the Gateway is
+// overloaded, answering 503 problem+json with Retry-After before any
+// upgrade or token validation work is done.
+func AdmissionMiddleware(admission connectionAdmitter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := admission.AdmitConnection(r.Context()); err != nil {
+			errmap.WriteProblem(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+)
+
+type stubAdmitter struct{ err error }
+
+func (s stubAdmitter) AdmitConnection(context.Context) error { return s.err }
+
+func TestAdmissionMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+
+	t.Run("admits when not overloaded", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/ws", nil)
+
+		AdmissionMiddleware(stubAdmitter{}, next).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusSwitchingProtocols, rec.Code)
+	})
+
+	t.Run("overloaded: 503 with retry-after", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/ws", nil)
+
+		AdmissionMiddleware(stubAdmitter{err: app.ErrOverloaded}, next).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, errmap.ProblemContentType, rec.Header().Get("Content-Type"))
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	})
+}
@@ -0,0 +1,331 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// connectStream is the server side of ConnectionService.Connect.
+type connectStream = grpc.BidiStreamingServer[messagingv1.ConnectRequest, messagingv1.ConnectResponse]
+
+// sessionServer is a narrow, consumer-defined interface for running client
+// sessions. The *app.SessionManager satisfies this.
+type sessionServer interface {
+	Serve(ctx context.Context, t app.Transport, accessToken, deviceID string) error
+	ReconnectPolicy() *protocol.ReconnectPolicy
+}
+
+// ConnectHandler implements the gRPC ConnectionServiceServer interface, the
+// streaming alternative to the WebSocket endpoint.
+type ConnectHandler struct {
+	messagingv1.UnimplementedConnectionServiceServer
+	sessions sessionServer
+}
+
+// NewConnectHandler creates a ConnectHandler backed by the given SessionManager.
+func NewConnectHandler(sessions *app.SessionManager) *ConnectHandler {
+	return &ConnectHandler{sessions: sessions}
+}
+
+// Connect runs one client session over a bidirectional stream.
+func (h *ConnectHandler) Connect(stream connectStream) error {
+	ctx := stream.Context()
+
+	err := h.sessions.Serve(ctx, &streamTransport{stream: stream}, extractBearerToken(ctx), extractDeviceID(ctx))
+	if err == nil {
+		return nil
+	}
+
+	// Mirror the WebSocket close handshake so clients share one close path.
+	// The session has stopped writing, so this is the only sender.
+	if ctx.Err() == nil {
+		closing := closeFor(err)
+		_ = stream.Send(&messagingv1.ConnectResponse{
+			Frame: &messagingv1.ConnectResponse_ConnectionClosing{
+				ConnectionClosing: &messagingv1.ConnectionClosingFrame{
+					Reason:    closing.Reason,
+					Code:      int32(closing.Code), //nolint:gosec // close codes are < 5000
+					Reconnect: reconnectToProto(h.sessions.ReconnectPolicy()),
+				},
+			},
+		})
+	}
+	return errmap.ToGRPCError(err)
+}
+
+// closeFor returns the close code and reason for the error that ended a
+// session.
+func closeFor(err error) errmap.WebSocketClose {
+	if errors.Is(err, app.ErrServerShutdown) {
+		return errmap.CloseServerShutdown
+	}
+	return errmap.ToWebSocketClose(err)
+}
+
+// streamTransport adapts a Connect stream to app.Transport. gRPC stream
+// operations are bound to the stream context, which ends when Connect returns.
+type streamTransport struct {
+	stream connectStream
+}
+
+// Send writes f to the stream. A batch frame is unpacked and its frames
+// sent one by one. Frames the stream has no encoding for are dropped rather
+// than failing the session: typing and presence are best effort, a receipt
+// is cumulative so the next one supersedes it, and upload_status only
+// answers upload frames, which stream clients cannot send.
+func (t *streamTransport) Send(ctx context.Context, f *protocol.Frame) error {
+	switch f.Type {
+	case protocol.FrameTypeBatch:
+		var frames []*protocol.Frame
+		if err := f.ParsePayload(&frames); err != nil {
+			return fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		for _, inner := range frames {
+			if err := t.Send(ctx, inner); err != nil {
+				return err
+			}
+		}
+		return nil
+	case protocol.FrameTypeTyping, protocol.FrameTypePresence, protocol.FrameTypeReceipt, protocol.FrameTypeUploadStatus:
+		return nil
+	}
+
+	resp, err := toConnectResponse(f)
+	if err != nil {
+		return err
+	}
+	return t.stream.Send(resp)
+}
+
+func (t *streamTransport) Recv(_ context.Context) (*protocol.Frame, error) {
+	req, err := t.stream.Recv()
+	if err != nil {
+		return nil, err // io.EOF on client half-close
+	}
+	return fromConnectRequest(req)
+}
+
+// fromConnectRequest converts a proto client frame to a protocol frame. An
+// unset or unknown oneof yields a frame with an empty type, which the session
+// ignores for forward compatibility.
+func fromConnectRequest(req *messagingv1.ConnectRequest) (*protocol.Frame, error) {
+	switch f := req.GetFrame().(type) {
+	case *messagingv1.ConnectRequest_Pong:
+		return protocol.NewFrame(protocol.FrameTypePong, protocol.Pong{Timestamp: f.Pong.GetTimestamp()})
+	case *messagingv1.ConnectRequest_Ping:
+		return protocol.NewFrame(protocol.FrameTypePing, protocol.Ping{Timestamp: f.Ping.GetTimestamp()})
+	case *messagingv1.ConnectRequest_SendMessage:
+		return protocol.NewFrame(protocol.FrameTypeSendMessage, protocol.SendMessage{
+			ChatID:          f.SendMessage.GetChatId(),
+			ClientMessageID: f.SendMessage.GetClientMessageId(),
+			ContentType:     f.SendMessage.GetContentType(),
+			Content:         f.SendMessage.GetContent(),
+			ClientTimestamp: f.SendMessage.GetClientTimestamp(),
+		})
+	case *messagingv1.ConnectRequest_Ack:
+		return protocol.NewFrame(protocol.FrameTypeAck, protocol.Ack{
+			ChatID:   f.Ack.GetChatId(),
+			Sequence: f.Ack.GetSequence(),
+		})
+	case *messagingv1.ConnectRequest_SyncRequest:
+		return protocol.NewFrame(protocol.FrameTypeSyncRequest, protocol.SyncRequest{
+			ChatID:            f.SyncRequest.GetChatId(),
+			LastAckedSequence: f.SyncRequest.GetLastAckedSequence(),
+		})
+	default:
+		return &protocol.Frame{}, nil
+	}
+}
+
+// errUnsupportedFrame is returned for server frame types with no proto mapping.
+var errUnsupportedFrame = errors.New("frame type has no stream encoding")
+
+// toConnectResponse converts a protocol frame to a proto server frame.
+func toConnectResponse(f *protocol.Frame) (*messagingv1.ConnectResponse, error) {
+	switch f.Type {
+	case protocol.FrameTypeConnectionAck:
+		var p protocol.ConnectionAck
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_ConnectionAck{
+			ConnectionAck: &messagingv1.ConnectionAckFrame{
+				ConnectionId:         p.ConnectionID,
+				HeartbeatIntervalMs:  int64(p.HeartbeatIntervalMs),
+				AccessTokenExpiresAt: p.AccessTokenExpiresAt,
+				AccessTokenTtlMs:     p.AccessTokenTTLMs,
+			},
+		}}, nil
+
+	case protocol.FrameTypeConnectionClosing:
+		var p protocol.ConnectionClosing
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_ConnectionClosing{
+			ConnectionClosing: &messagingv1.ConnectionClosingFrame{
+				Reason:    p.Reason,
+				Code:      int32(p.Code), //nolint:gosec // close codes are < 5000
+				Reconnect: reconnectToProto(p.Reconnect),
+			},
+		}}, nil
+
+	case protocol.FrameTypePing:
+		var p protocol.Ping
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_Ping{
+			Ping: &messagingv1.PingFrame{Timestamp: p.Timestamp},
+		}}, nil
+
+	case protocol.FrameTypePong:
+		var p protocol.Pong
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_Pong{
+			Pong: &messagingv1.PongFrame{
+				Timestamp:        p.Timestamp,
+				ServerReceivedAt: p.ServerReceivedAt,
+				ClockOffsetMs:    p.ClockOffsetMs,
+			},
+		}}, nil
+
+	case protocol.FrameTypeSendMessageAck:
+		var p protocol.SendMessageAck
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_SendMessageAck{
+			SendMessageAck: &messagingv1.SendMessageAckFrame{
+				ClientMessageId:  p.ClientMessageID,
+				MessageId:        p.MessageID,
+				Sequence:         p.Sequence,
+				CreatedAt:        p.CreatedAt,
+				ServerReceivedAt: p.ServerReceivedAt,
+			},
+		}}, nil
+
+	case protocol.FrameTypeMessage:
+		var p protocol.Message
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_Message{
+			Message: messageToProto(p),
+		}}, nil
+
+	case protocol.FrameTypeSyncResponse:
+		var p protocol.SyncResponse
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		msgs := make([]*messagingv1.MessageFrame, 0, len(p.Messages))
+		for _, m := range p.Messages {
+			msgs = append(msgs, messageToProto(m))
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_SyncResponse{
+			SyncResponse: &messagingv1.SyncResponseFrame{
+				ChatId:   p.ChatID,
+				Messages: msgs,
+				HasMore:  p.HasMore,
+			},
+		}}, nil
+
+	case protocol.FrameTypeNotification:
+		var p protocol.Notification
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_Notification{
+			Notification: &messagingv1.NotificationFrame{
+				NotificationId: p.NotificationID,
+				Kind:           p.Kind,
+				ChatId:         p.ChatID,
+				ActorId:        p.ActorID,
+				Params:         p.Params,
+				CreatedAt:      p.CreatedAt,
+			},
+		}}, nil
+
+	case protocol.FrameTypeError:
+		var p protocol.Error
+		if err := f.ParsePayload(&p); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", f.Type, err)
+		}
+		return &messagingv1.ConnectResponse{Frame: &messagingv1.ConnectResponse_Error{
+			Error: &messagingv1.ErrorFrame{
+				Code:      p.Code,
+				Message:   p.Message,
+				Details:   p.Details,
+				Reconnect: reconnectToProto(p.Reconnect),
+			},
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("%s: %w", f.Type, errUnsupportedFrame)
+	}
+}
+
+func messageToProto(m protocol.Message) *messagingv1.MessageFrame {
+	return &messagingv1.MessageFrame{
+		MessageId:        m.MessageID,
+		ChatId:           m.ChatID,
+		SenderId:         m.SenderID,
+		ClientMessageId:  m.ClientMessageID,
+		Sequence:         m.Sequence,
+		ContentType:      m.ContentType,
+		Content:          m.Content,
+		CreatedAt:        m.CreatedAt,
+		ServerReceivedAt: m.ServerReceivedAt,
+	}
+}
+
+func reconnectToProto(p *protocol.ReconnectPolicy) *messagingv1.ReconnectPolicy {
+	if p == nil {
+		return nil
+	}
+	return &messagingv1.ReconnectPolicy{
+		MinBackoffMs: p.MinBackoffMs,
+		MaxBackoffMs: p.MaxBackoffMs,
+		Jitter:       p.Jitter,
+		RetryBudget:  int32(p.RetryBudget), //nolint:gosec // bounded by config validation
+	}
+}
+
+// extractBearerToken extracts the bearer token from the gRPC "authorization" metadata.
+func extractBearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	vals := md.Get("authorization")
+	if len(vals) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(vals[0], "Bearer ")
+}
+
+// extractDeviceID extracts the device ID from the gRPC "x-device-id" metadata.
+func extractDeviceID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	vals := md.Get("x-device-id")
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
@@ -0,0 +1,268 @@
+package port
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// ---------------------------------------------------------------------------
+// Stubs
+// ---------------------------------------------------------------------------
+
+type stubSessionServer struct {
+	serveFn   func(ctx context.Context, t app.Transport, accessToken, deviceID string) error
+	reconnect *protocol.ReconnectPolicy
+}
+
+func (s *stubSessionServer) Serve(ctx context.Context, t app.Transport, accessToken, deviceID string) error {
+	return s.serveFn(ctx, t, accessToken, deviceID)
+}
+
+func (s *stubSessionServer) ReconnectPolicy() *protocol.ReconnectPolicy { return s.reconnect }
+
+var _ sessionServer = (*stubSessionServer)(nil)
+
+// fakeConnectStream replays queued client frames and captures server frames.
+type fakeConnectStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests []*messagingv1.ConnectRequest
+	sent     []*messagingv1.ConnectResponse
+}
+
+func (f *fakeConnectStream) Context() context.Context { return f.ctx }
+
+func (f *fakeConnectStream) Recv() (*messagingv1.ConnectRequest, error) {
+	if len(f.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := f.requests[0]
+	f.requests = f.requests[1:]
+	return req, nil
+}
+
+func (f *fakeConnectStream) Send(resp *messagingv1.ConnectResponse) error {
+	f.sent = append(f.sent, resp)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Tests — Connect
+// ---------------------------------------------------------------------------
+
+func TestConnectHandler_Connect(t *testing.T) {
+	t.Run("passes credentials and bridges frames both ways", func(t *testing.T) {
+		stub := &stubSessionServer{
+			serveFn: func(ctx context.Context, tr app.Transport, accessToken, deviceID string) error {
+				assert.Equal(t, "my-token", accessToken)
+				assert.Equal(t, "device-001", deviceID)
+
+				ack, err := protocol.NewFrame(protocol.FrameTypeConnectionAck, protocol.ConnectionAck{ConnectionID: "conn-1", HeartbeatIntervalMs: 30000})
+				require.NoError(t, err)
+				require.NoError(t, tr.Send(ctx, ack))
+
+				f, err := tr.Recv(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, protocol.FrameTypeAck, f.Type)
+				var a protocol.Ack
+				require.NoError(t, f.ParsePayload(&a))
+				assert.Equal(t, uint64(42), a.Sequence)
+
+				_, err = tr.Recv(ctx)
+				assert.ErrorIs(t, err, io.EOF)
+				return nil
+			},
+		}
+		handler := &ConnectHandler{sessions: stub}
+		stream := &fakeConnectStream{
+			ctx: metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs("authorization", "Bearer my-token", "x-device-id", "device-001")),
+			requests: []*messagingv1.ConnectRequest{{
+				Frame: &messagingv1.ConnectRequest_Ack{Ack: &messagingv1.AckFrame{ChatId: "chat-001", Sequence: 42}},
+			}},
+		}
+
+		require.NoError(t, handler.Connect(stream))
+		require.Len(t, stream.sent, 1)
+		assert.Equal(t, "conn-1", stream.sent[0].GetConnectionAck().GetConnectionId())
+		assert.Equal(t, int64(30000), stream.sent[0].GetConnectionAck().GetHeartbeatIntervalMs())
+	})
+
+	t.Run("session error sends connection_closing and maps status", func(t *testing.T) {
+		stub := &stubSessionServer{
+			serveFn: func(context.Context, app.Transport, string, string) error {
+				return domain.ErrSlowConsumer
+			},
+			reconnect: &protocol.ReconnectPolicy{MinBackoffMs: 2000, MaxBackoffMs: 60000, Jitter: 0.5, RetryBudget: 5},
+		}
+		handler := &ConnectHandler{sessions: stub}
+		stream := &fakeConnectStream{ctx: context.Background()}
+
+		err := handler.Connect(stream)
+
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		require.Len(t, stream.sent, 1)
+		closing := stream.sent[0].GetConnectionClosing()
+		require.NotNil(t, closing)
+		assert.Equal(t, "slow_consumer", closing.GetReason())
+		assert.Equal(t, int64(2000), closing.GetReconnect().GetMinBackoffMs())
+		assert.Equal(t, int64(60000), closing.GetReconnect().GetMaxBackoffMs())
+		assert.InDelta(t, 0.5, closing.GetReconnect().GetJitter(), 1e-9)
+		assert.Equal(t, int32(5), closing.GetReconnect().GetRetryBudget())
+	})
+
+	t.Run("shutdown uses the going-away close reason", func(t *testing.T) {
+		stub := &stubSessionServer{
+			serveFn: func(context.Context, app.Transport, string, string) error {
+				return app.ErrServerShutdown
+			},
+		}
+		handler := &ConnectHandler{sessions: stub}
+		stream := &fakeConnectStream{ctx: context.Background()}
+
+		err := handler.Connect(stream)
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		require.Len(t, stream.sent, 1)
+		assert.Equal(t, "server_shutdown", stream.sent[0].GetConnectionClosing().GetReason())
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		stub := &stubSessionServer{
+			serveFn: func(context.Context, app.Transport, string, string) error {
+				return domain.ErrUnauthorized
+			},
+		}
+		handler := &ConnectHandler{sessions: stub}
+
+		err := handler.Connect(&fakeConnectStream{ctx: context.Background()})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — frame conversion
+// ---------------------------------------------------------------------------
+
+func TestFromConnectRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *messagingv1.ConnectRequest
+		wantType protocol.FrameType
+	}{
+		{"pong", &messagingv1.ConnectRequest{Frame: &messagingv1.ConnectRequest_Pong{Pong: &messagingv1.PongFrame{Timestamp: 1}}}, protocol.FrameTypePong},
+		{"ping", &messagingv1.ConnectRequest{Frame: &messagingv1.ConnectRequest_Ping{Ping: &messagingv1.PingFrame{Timestamp: 1}}}, protocol.FrameTypePing},
+		{"send_message", &messagingv1.ConnectRequest{Frame: &messagingv1.ConnectRequest_SendMessage{SendMessage: &messagingv1.SendMessageFrame{ChatId: "chat-001"}}}, protocol.FrameTypeSendMessage},
+		{"ack", &messagingv1.ConnectRequest{Frame: &messagingv1.ConnectRequest_Ack{Ack: &messagingv1.AckFrame{}}}, protocol.FrameTypeAck},
+		{"sync_request", &messagingv1.ConnectRequest{Frame: &messagingv1.ConnectRequest_SyncRequest{SyncRequest: &messagingv1.SyncRequestFrame{}}}, protocol.FrameTypeSyncRequest},
+		{"unset oneof is ignored by the session", &messagingv1.ConnectRequest{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := fromConnectRequest(tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, f.Type)
+		})
+	}
+}
+
+func TestToConnectResponse(t *testing.T) {
+	t.Run("message frame round-trips every field", func(t *testing.T) {
+		msg := protocol.Message{
+			MessageID:        "msg-001",
+			ChatID:           "chat-001",
+			SenderID:         "user-001",
+			ClientMessageID:  "client-001",
+			Sequence:         9,
+			ContentType:      "text",
+			Content:          "hello",
+			CreatedAt:        1700000000000,
+			ServerReceivedAt: 1699999999990,
+		}
+		f, err := protocol.NewFrame(protocol.FrameTypeMessage, msg)
+		require.NoError(t, err)
+
+		resp, err := toConnectResponse(f)
+		require.NoError(t, err)
+		got := resp.GetMessage()
+		assert.Equal(t, msg.MessageID, got.GetMessageId())
+		assert.Equal(t, msg.ChatID, got.GetChatId())
+		assert.Equal(t, msg.SenderID, got.GetSenderId())
+		assert.Equal(t, msg.ClientMessageID, got.GetClientMessageId())
+		assert.Equal(t, msg.Sequence, got.GetSequence())
+		assert.Equal(t, msg.ContentType, got.GetContentType())
+		assert.Equal(t, msg.Content, got.GetContent())
+		assert.Equal(t, msg.CreatedAt, got.GetCreatedAt())
+		assert.Equal(t, msg.ServerReceivedAt, got.GetServerReceivedAt())
+	})
+
+	t.Run("each server frame type has an encoding", func(t *testing.T) {
+		for _, ft := range []protocol.FrameType{
+			protocol.FrameTypeConnectionAck,
+			protocol.FrameTypeConnectionClosing,
+			protocol.FrameTypePing,
+			protocol.FrameTypePong,
+			protocol.FrameTypeSendMessageAck,
+			protocol.FrameTypeMessage,
+			protocol.FrameTypeSyncResponse,
+			protocol.FrameTypeNotification,
+			protocol.FrameTypeError,
+		} {
+			resp, err := toConnectResponse(&protocol.Frame{Type: ft})
+			require.NoError(t, err, ft)
+			assert.NotNil(t, resp.GetFrame(), ft)
+		}
+	})
+
+	t.Run("client-only frame type is rejected", func(t *testing.T) {
+		_, err := toConnectResponse(&protocol.Frame{Type: protocol.FrameTypeAck})
+		assert.ErrorIs(t, err, errUnsupportedFrame)
+	})
+}
+
+func TestStreamTransport_Send(t *testing.T) {
+	ping, err := protocol.NewFrame(protocol.FrameTypePing, protocol.Ping{Timestamp: 1})
+	require.NoError(t, err)
+	pong, err := protocol.NewFrame(protocol.FrameTypePong, protocol.Pong{Timestamp: 2})
+	require.NoError(t, err)
+
+	t.Run("batch is unpacked into its frames", func(t *testing.T) {
+		stream := &fakeConnectStream{ctx: context.Background()}
+		batch, err := protocol.NewBatchFrame([]*protocol.Frame{ping, pong})
+		require.NoError(t, err)
+
+		require.NoError(t, (&streamTransport{stream: stream}).Send(context.Background(), batch))
+
+		require.Len(t, stream.sent, 2)
+		assert.Equal(t, int64(1), stream.sent[0].GetPing().GetTimestamp())
+		assert.Equal(t, int64(2), stream.sent[1].GetPong().GetTimestamp())
+	})
+
+	t.Run("frames without a stream encoding are dropped", func(t *testing.T) {
+		stream := &fakeConnectStream{ctx: context.Background()}
+		tr := &streamTransport{stream: stream}
+
+		for _, ft := range []protocol.FrameType{
+			protocol.FrameTypeTyping,
+			protocol.FrameTypePresence,
+			protocol.FrameTypeReceipt,
+			protocol.FrameTypeUploadStatus,
+		} {
+			require.NoError(t, tr.Send(context.Background(), &protocol.Frame{Type: ft}), ft)
+		}
+		assert.Empty(t, stream.sent)
+	})
+}
@@ -0,0 +1,119 @@
+package port
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// connectClient serves a ConnectHandler over an in-memory listener, with the
+// production interceptors, and returns a generated client for it.
+func connectClient(t *testing.T, sessions sessionServer) messagingv1.ConnectionServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainStreamInterceptor(server.RecoveryStreamInterceptor()))
+	messagingv1.RegisterConnectionServiceServer(srv, &ConnectHandler{sessions: sessions})
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.Stop()
+	})
+	return messagingv1.NewConnectionServiceClient(conn)
+}
+
+func TestContract_Connect(t *testing.T) {
+	t.Run("credentials and frames cross the stream", func(t *testing.T) {
+		client := connectClient(t, &stubSessionServer{
+			serveFn: func(ctx context.Context, tr app.Transport, accessToken, deviceID string) error {
+				if accessToken != "my-token" || deviceID != "device-001" {
+					return domain.ErrUnauthorized
+				}
+				ack, err := protocol.NewFrame(protocol.FrameTypeConnectionAck, protocol.ConnectionAck{ConnectionID: "conn-1"})
+				if err != nil {
+					return err
+				}
+				if err := tr.Send(ctx, ack); err != nil {
+					return err
+				}
+				f, err := tr.Recv(ctx)
+				if err != nil {
+					return err
+				}
+				var a protocol.Ack
+				if err := f.ParsePayload(&a); err != nil || a.Sequence != 42 {
+					return domain.ErrInvalidInput
+				}
+				return nil
+			},
+		})
+
+		ctx := metadata.NewOutgoingContext(context.Background(),
+			metadata.Pairs("authorization", "Bearer my-token", "x-device-id", "device-001"))
+		stream, err := client.Connect(ctx)
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "conn-1", resp.GetConnectionAck().GetConnectionId())
+
+		require.NoError(t, stream.Send(&messagingv1.ConnectRequest{
+			Frame: &messagingv1.ConnectRequest_Ack{Ack: &messagingv1.AckFrame{ChatId: "chat-001", Sequence: 42}},
+		}))
+		require.NoError(t, stream.CloseSend())
+
+		_, err = stream.Recv()
+		assert.ErrorIs(t, err, io.EOF, "the session ended cleanly")
+	})
+
+	tests := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{"unauthenticated", domain.ErrUnauthorized, codes.Unauthenticated, "unauthorized"},
+		{"slow consumer", domain.ErrSlowConsumer, codes.ResourceExhausted, "slow_consumer"},
+		{"shutdown", app.ErrServerShutdown, codes.Unavailable, "server_shutdown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" closes with a reason and a status", func(t *testing.T) {
+			client := connectClient(t, &stubSessionServer{
+				serveFn: func(context.Context, app.Transport, string, string) error { return tt.err },
+			})
+
+			stream, err := client.Connect(context.Background())
+			require.NoError(t, err)
+
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, tt.reason, resp.GetConnectionClosing().GetReason())
+
+			_, err = stream.Recv()
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
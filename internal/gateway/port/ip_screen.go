@@ -0,0 +1,30 @@
+package port
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// ipScreener is a narrow, consumer-defined interface for client address
+// screening. The *ipscreen.Screener satisfies this.
+type ipScreener interface {
+	Screen(ctx context.Context, surface, clientIP string) error
+}
+
+// IPScreenMiddleware refuses WebSocket upgrades from blocked address
+// ranges, disreputable addresses and blocked countries, answering 403
+// problem+json before any upgrade or token validation work is done.
+// clientIPs decides which X-Forwarded-For hops to believe; nil trusts none.
+func IPScreenMiddleware(screener ipScreener, clientIPs *domain.ClientIPResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := clientIPs.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+		if err := screener.Screen(r.Context(), "gateway_upgrade", clientIP); err != nil {
+			errmap.WriteProblem(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
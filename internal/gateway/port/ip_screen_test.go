@@ -0,0 +1,77 @@
+package port
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+type stubIPScreener struct {
+	err    error
+	gotIP  string
+	gotFor string
+}
+
+func (s *stubIPScreener) Screen(_ context.Context, surface, clientIP string) error {
+	s.gotFor, s.gotIP = surface, clientIP
+	return s.err
+}
+
+func TestIPScreenMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+
+	t.Run("admits a clean address", func(t *testing.T) {
+		screener := &stubIPScreener{}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/ws", nil)
+		req.RemoteAddr = "192.0.2.1:51234"
+
+		IPScreenMiddleware(screener, nil, next).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusSwitchingProtocols, rec.Code)
+		assert.Equal(t, "gateway_upgrade", screener.gotFor)
+		assert.Equal(t, "192.0.2.1", screener.gotIP)
+	})
+
+	t.Run("blocked: 403 before upgrade", func(t *testing.T) {
+		screener := &stubIPScreener{err: fmt.Errorf("blocked range: %w", domain.ErrForbidden)}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/ws", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.1")
+
+		IPScreenMiddleware(screener, trustedProxies(t), next).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "203.0.113.5", screener.gotIP)
+	})
+
+	t.Run("forwarded-for from an untrusted peer is ignored", func(t *testing.T) {
+		screener := &stubIPScreener{}
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/ws", nil)
+		req.RemoteAddr = "198.51.100.9:51234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.5")
+
+		IPScreenMiddleware(screener, trustedProxies(t), next).ServeHTTP(rec, req)
+
+		assert.Equal(t, "198.51.100.9", screener.gotIP)
+	})
+}
+
+// trustedProxies trusts httptest's default peer and the 10/8 load balancer
+// range.
+func trustedProxies(t *testing.T) *domain.ClientIPResolver {
+	t.Helper()
+	resolver, err := domain.NewClientIPResolver([]string{"192.0.2.0/24", "10.0.0.0/8"})
+	require.NoError(t, err)
+	return resolver
+}
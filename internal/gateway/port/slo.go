@@ -0,0 +1,21 @@
+package port
+
+import (
+	"context"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// MeasureSLO records every frame next handles against operation's
+// objective (ADR-012 §2.2): a frame is good when it is handled within the
+// objective's latency and fails, if at all, only because of the client.
+func MeasureSLO(operation string, next app.FrameHandler) app.FrameHandler {
+	return app.FrameHandlerFunc(func(ctx context.Context, c *app.Connection, f *protocol.Frame) error {
+		done := slo.Start(ctx, operation)
+		err := next.HandleFrame(ctx, c, f)
+		done(err)
+		return err
+	})
+}
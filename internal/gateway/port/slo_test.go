@@ -0,0 +1,42 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func TestMeasureSLO(t *testing.T) {
+	recorder, err := slo.NewRecorder(slo.RecorderConfig{
+		Objectives: []slo.Objective{{Operation: slo.SendMessage, Target: 0.5}},
+		Clock:      domain.RealClock{},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, recorder.Close()) })
+	slo.SetDefault(recorder)
+	t.Cleanup(func() { slo.SetDefault(nil) })
+
+	errs := []error{nil, domain.NewValidationError("chat_id", "is required"), errors.New("ingest unavailable")}
+	var i int
+	handler := MeasureSLO(slo.SendMessage, app.FrameHandlerFunc(func(context.Context, *app.Connection, *protocol.Frame) error {
+		err := errs[i]
+		i++
+		return err
+	}))
+	for _, want := range errs {
+		assert.Equal(t, want, handler.HandleFrame(context.Background(), nil, &protocol.Frame{}))
+	}
+
+	// One bad event in three against a 50% error budget.
+	assert.InDelta(t, 2.0/3, recorder.BurnRate(slo.SendMessage, time.Hour), 0.001,
+		"client errors are good, server errors bad")
+}
@@ -0,0 +1,311 @@
+package port
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint:gosec // RFC 6455 derives the accept key with SHA-1
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// WebSocketPath is the WebSocket endpoint (ADR-005 §1.1).
+const WebSocketPath = "/v1/ws"
+
+// websocketGUID is appended to Sec-WebSocket-Key to derive the accept key.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketHandler upgrades client connections and runs one session over
+// each (ADR-005 §1.1). Clients authenticate with an Authorization bearer
+// token and identify the device with X-Device-ID; clients that cannot set
+// headers may send the token and device_id query parameters instead. The
+// optional features a client supports are listed, comma separated, in
+// X-Capabilities or the capabilities query parameter.
+type WebSocketHandler struct {
+	sessions  sessionServer
+	clientIPs *domain.ClientIPResolver
+}
+
+// NewWebSocketHandler creates a WebSocketHandler backed by the given
+// SessionManager. clientIPs decides which X-Forwarded-For hops to believe
+// for per-address quotas; nil trusts none.
+func NewWebSocketHandler(sessions *app.SessionManager, clientIPs *domain.ClientIPResolver) *WebSocketHandler {
+	return &WebSocketHandler{sessions: sessions, clientIPs: clientIPs}
+}
+
+// ServeHTTP validates the upgrade request, switches protocols and serves
+// the session until it ends. Requests that cannot be upgraded are answered
+// with problem+json before any session work is done.
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept, err := acceptKey(r)
+	if err != nil {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		errmap.WriteProblem(w, r, err)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		errmap.WriteProblem(w, r, fmt.Errorf("websocket: missing access token: %w", domain.ErrUnauthorized))
+		return
+	}
+	deviceID := r.Header.Get("X-Device-ID")
+	if deviceID == "" {
+		deviceID = r.URL.Query().Get("device_id")
+	}
+	if deviceID == "" {
+		errmap.WriteProblem(w, r, fmt.Errorf("websocket: missing device ID: %w", domain.ErrInvalidInput))
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		errmap.WriteProblem(w, r, fmt.Errorf("websocket: hijack: %w", err))
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The server's read and write deadlines outlive the hijack.
+	_ = conn.SetDeadline(time.Time{})
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	ws := &wsTransport{
+		conn:     conn,
+		br:       rw.Reader,
+		caps:     requestCapabilities(r),
+		clientIP: h.clientIPs.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For")),
+	}
+	var t app.Transport = ws
+	if _, ok := conn.(*net.TCPConn); ok {
+		// Only a plain socket can be polled: TLS buffers records the
+		// reader's bufio.Reader cannot see.
+		t = &pollableWSTransport{ws}
+	}
+	err = h.sessions.Serve(r.Context(), t, token, deviceID)
+	ws.close(err, h.sessions.ReconnectPolicy())
+}
+
+// acceptKey checks that r is a WebSocket version 13 upgrade and returns
+// the Sec-WebSocket-Accept value for it.
+func acceptKey(r *http.Request) (string, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return "", fmt.Errorf("websocket: not an upgrade request: %w", domain.ErrInvalidInput)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", fmt.Errorf("websocket: unsupported version %q: %w", r.Header.Get("Sec-WebSocket-Version"), domain.ErrInvalidInput)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		return "", fmt.Errorf("websocket: malformed Sec-WebSocket-Key: %w", domain.ErrInvalidInput)
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID)) //nolint:gosec // see import
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// headerHasToken reports whether the comma-separated header name lists
+// token, case-insensitively.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestCapabilities returns the capabilities the client listed.
+func requestCapabilities(r *http.Request) []string {
+	list := r.Header.Get("X-Capabilities")
+	if list == "" {
+		list = r.URL.Query().Get("capabilities")
+	}
+	var caps []string
+	for c := range strings.SplitSeq(list, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// Compile-time checks: the WebSocket transports carry the client's
+// capabilities and address, and plain sockets can be polled.
+var (
+	_ app.CapabilityTransport = (*wsTransport)(nil)
+	_ app.AddressTransport    = (*wsTransport)(nil)
+	_ pollableTransport       = (*pollableWSTransport)(nil)
+)
+
+// pollableTransport mirrors adapter.PollableTransport, which the port
+// layer may not import.
+type pollableTransport interface {
+	app.Transport
+	Fd() int
+	Buffered() bool
+	SetReadDeadline(t time.Time) error
+}
+
+// wsTransport adapts a hijacked connection to app.Transport. Every server
+// frame is one text message. Recv does not watch ctx: it returns once
+// ServeHTTP closes the connection after the session ends.
+type wsTransport struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	caps     []string
+	clientIP string
+
+	enc []byte // Send's encoding buffer; Send is never concurrent
+
+	mu  sync.Mutex // serializes writes: Send, and control replies from Recv
+	out []byte
+
+	peerClosed atomic.Bool
+}
+
+// Send writes f as one text message.
+func (t *wsTransport) Send(_ context.Context, f *protocol.Frame) error {
+	var err error
+	if t.enc, err = protocol.AppendFrame(t.enc[:0], f); err != nil {
+		return fmt.Errorf("encode %s: %w", f.Type, err)
+	}
+	return t.write(opText, t.enc)
+}
+
+// Recv returns the next client frame. Pings are answered; a ping or pong
+// between messages yields an empty frame, which the session ignores, so
+// Recv never waits for a message the client has not started. A close frame
+// from the client ends the session with io.EOF. Fragmented messages are
+// reassembled up to domain.MaxInboundFrameSize.
+func (t *wsTransport) Recv(_ context.Context) (*protocol.Frame, error) {
+	var (
+		kind app.MessageKind
+		msg  []byte
+	)
+	for {
+		f, err := readWSFrame(t.br, domain.MaxInboundFrameSize-len(msg))
+		if err != nil {
+			return nil, err
+		}
+		switch f.opcode {
+		case opPing, opPong:
+			if f.opcode == opPing {
+				if err := t.write(opPong, f.payload); err != nil {
+					return nil, err
+				}
+			}
+			if kind == 0 {
+				return &protocol.Frame{}, nil
+			}
+			continue
+		case opClose:
+			t.peerClosed.Store(true)
+			return nil, io.EOF
+		case opText, opBinary:
+			if kind != 0 {
+				return nil, fmt.Errorf("%w: data frame inside a fragmented message", errProtocolViolation)
+			}
+			kind = app.TextMessage
+			if f.opcode == opBinary {
+				kind = app.BinaryMessage
+			}
+		case opContinuation:
+			if kind == 0 {
+				return nil, fmt.Errorf("%w: continuation outside a message", errProtocolViolation)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %#x", errProtocolViolation, f.opcode)
+		}
+		msg = append(msg, f.payload...)
+		if f.fin {
+			return app.DecodeClientFrame(kind, msg)
+		}
+	}
+}
+
+// Capabilities implements app.CapabilityTransport.
+func (t *wsTransport) Capabilities() []string { return t.caps }
+
+// ClientIP implements app.AddressTransport.
+func (t *wsTransport) ClientIP() string { return t.clientIP }
+
+// write sends one frame, bounded by domain.WebSocketWriteTimeout.
+func (t *wsTransport) write(opcode byte, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.out = appendWSFrame(t.out[:0], opcode, payload)
+	if err := t.conn.SetWriteDeadline(time.Now().Add(domain.WebSocketWriteTimeout)); err != nil {
+		return err
+	}
+	_, err := t.conn.Write(t.out)
+	return err
+}
+
+// close ends the session the way ConnectHandler does: unless the client
+// left first, a connection_closing frame carries the reason and reconnect
+// policy, then a close frame carries the code.
+func (t *wsTransport) close(err error, reconnect *protocol.ReconnectPolicy) {
+	closing := closeFor(err)
+	if errors.Is(err, errProtocolViolation) {
+		closing = errmap.CloseProtocolViolation
+	}
+	if err != nil && !t.peerClosed.Load() {
+		f, ferr := protocol.NewFrame(protocol.FrameTypeConnectionClosing, protocol.ConnectionClosing{
+			Reason:    closing.Reason,
+			Code:      closing.Code,
+			Reconnect: reconnect,
+		})
+		if ferr == nil {
+			_ = t.Send(context.Background(), f)
+		}
+	}
+	_ = t.write(opClose, closePayload(closing.Code, closing.Reason))
+}
+
+// pollableWSTransport is a wsTransport over a plain TCP socket, which
+// adapter.EpollReader can watch instead of parking a goroutine in Recv.
+type pollableWSTransport struct {
+	*wsTransport
+}
+
+// Fd returns the socket descriptor, or -1 if it cannot be obtained.
+func (t *pollableWSTransport) Fd() int {
+	sc, ok := t.conn.(syscall.Conn)
+	if !ok {
+		return -1
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return -1
+	}
+	fd := -1
+	_ = raw.Control(func(s uintptr) { fd = int(s) }) //nolint:gosec // descriptors fit an int
+	return fd
+}
+
+// Buffered reports whether bytes already read off the socket are waiting.
+func (t *pollableWSTransport) Buffered() bool { return t.br.Buffered() > 0 }
+
+// SetReadDeadline bounds the reads of the next Recv.
+func (t *pollableWSTransport) SetReadDeadline(d time.Time) error { return t.conn.SetReadDeadline(d) }
@@ -0,0 +1,111 @@
+package port
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// WebSocket opcodes (RFC 6455 §5.2).
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// maxControlPayload is the longest payload a control frame may carry.
+const maxControlPayload = 125
+
+// errProtocolViolation marks peer frames that break RFC 6455. A transport
+// returning it closes the connection with a protocol error.
+var errProtocolViolation = fmt.Errorf("websocket protocol violation: %w", domain.ErrInvalidInput)
+
+// wsFrame is one WebSocket frame as read from a client.
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readWSFrame reads one client frame. Client frames must be masked; the
+// payload is unmasked in place. A data frame longer than maxPayload is
+// refused without reading its payload.
+func readWSFrame(r *bufio.Reader, maxPayload int) (wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{fin: head[0]&0x80 != 0, opcode: head[0] & 0x0F}
+	if head[0]&0x70 != 0 {
+		return wsFrame{}, fmt.Errorf("%w: reserved bits set", errProtocolViolation)
+	}
+	if head[1]&0x80 == 0 {
+		return wsFrame{}, fmt.Errorf("%w: unmasked client frame", errProtocolViolation)
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return wsFrame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return wsFrame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if f.opcode >= opClose {
+		if !f.fin || length > maxControlPayload {
+			return wsFrame{}, fmt.Errorf("%w: fragmented or oversized control frame", errProtocolViolation)
+		}
+	} else if length > uint64(maxPayload) { //nolint:gosec // maxPayload is positive
+		return wsFrame{}, fmt.Errorf("websocket frame of %d bytes, limit %d: %w", length, maxPayload, domain.ErrMessageTooLarge)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return wsFrame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// appendWSFrame appends an unmasked, unfragmented server frame to dst.
+func appendWSFrame(dst []byte, opcode byte, payload []byte) []byte {
+	dst = append(dst, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		dst = append(dst, byte(n))
+	case n <= 0xFFFF:
+		dst = append(dst, 126)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(n))
+	default:
+		dst = append(dst, 127)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(n))
+	}
+	return append(dst, payload...)
+}
+
+// closePayload encodes a close frame body: the status code and a reason
+// trimmed to fit a control frame.
+func closePayload(code int, reason string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(code)) //nolint:gosec // close codes are < 5000
+	return append(b, reason[:min(len(reason), maxControlPayload-2)]...)
+}
@@ -0,0 +1,320 @@
+package port
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+// wsClient is a minimal RFC 6455 client: it masks what it sends and reads
+// the server's unmasked frames.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialWS upgrades a connection to srv with the given extra headers and
+// fails the test unless the server switches protocols.
+func dialWS(t *testing.T, srv *httptest.Server, header http.Header) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+WebSocketPath, nil)
+	require.NoError(t, err)
+	req.Header = upgradeHeader()
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	require.NoError(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	// The accept key for the RFC 6455 §1.3 sample nonce.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	return &wsClient{conn: conn, br: br}
+}
+
+func upgradeHeader() http.Header {
+	return http.Header{
+		"Connection":            {"keep-alive, Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+		"Authorization":         {"Bearer my-token"},
+		"X-Device-Id":           {"device-001"},
+	}
+}
+
+// write sends one masked frame.
+func (c *wsClient) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, 0x80|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, 0x80|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0x80|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	buf = append(buf, mask[:]...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+	_, err := c.conn.Write(buf)
+	require.NoError(t, err)
+}
+
+// read returns the next server frame's opcode and payload.
+func (c *wsClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	_, err := io.ReadFull(c.br, head[:])
+	require.NoError(t, err)
+	require.Zero(t, head[1]&0x80, "server frames are unmasked")
+	n := int(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		require.NoError(t, err)
+		n = int(binary.BigEndian.Uint64(ext[:])) //nolint:gosec // test frames are small
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(c.br, payload)
+	require.NoError(t, err)
+	return head[0] & 0x0F, payload
+}
+
+// readFrame reads a text message and decodes it as a protocol frame.
+func (c *wsClient) readFrame(t *testing.T) *protocol.Frame {
+	t.Helper()
+	op, payload := c.read(t)
+	require.Equal(t, byte(opText), op)
+	f, err := protocol.DecodeFrame(payload, protocol.DecodeLimits{})
+	require.NoError(t, err)
+	return f
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	serve := func(t *testing.T, stub *stubSessionServer) *httptest.Server {
+		t.Helper()
+		srv := httptest.NewServer(&WebSocketHandler{sessions: stub})
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	t.Run("bridges frames and carries the handshake to the session", func(t *testing.T) {
+		stub := &stubSessionServer{
+			serveFn: func(ctx context.Context, tr app.Transport, accessToken, deviceID string) error {
+				assert.Equal(t, "my-token", accessToken)
+				assert.Equal(t, "device-001", deviceID)
+				ct, ok := tr.(app.CapabilityTransport)
+				require.True(t, ok)
+				assert.Equal(t, []string{protocol.CapabilityBatch, protocol.CapabilityUpload}, ct.Capabilities())
+				at, ok := tr.(app.AddressTransport)
+				require.True(t, ok)
+				assert.Equal(t, "127.0.0.1", at.ClientIP())
+				_, ok = tr.(pollableTransport)
+				assert.True(t, ok, "plain TCP connections can be polled")
+
+				ack, err := protocol.NewFrame(protocol.FrameTypeConnectionAck, protocol.ConnectionAck{ConnectionID: "conn-1"})
+				require.NoError(t, err)
+				require.NoError(t, tr.Send(ctx, ack))
+
+				// A ping between messages yields an empty frame.
+				f, err := tr.Recv(ctx)
+				require.NoError(t, err)
+				assert.Empty(t, f.Type)
+
+				f, err = tr.Recv(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, protocol.FrameTypeAck, f.Type)
+
+				_, err = tr.Recv(ctx)
+				assert.ErrorIs(t, err, io.EOF)
+				return nil
+			},
+		}
+		c := dialWS(t, serve(t, stub), http.Header{"X-Capabilities": {"batch, upload"}})
+
+		assert.Equal(t, protocol.FrameTypeConnectionAck, c.readFrame(t).Type)
+
+		c.write(t, true, opPing, []byte("idle"))
+		op, payload := c.read(t)
+		assert.Equal(t, byte(opPong), op)
+		assert.Equal(t, []byte("idle"), payload)
+
+		// A fragmented message with a ping between its fragments.
+		ack := []byte(`{"type":"ack","payload":{"chat_id":"chat-1","sequence":5}}`)
+		c.write(t, false, opText, ack[:10])
+		c.write(t, true, opPing, []byte("hi"))
+		c.write(t, true, opContinuation, ack[10:])
+		op, payload = c.read(t)
+		assert.Equal(t, byte(opPong), op)
+		assert.Equal(t, []byte("hi"), payload)
+
+		// The client closes; the session ends cleanly and the server
+		// answers with a normal closure.
+		c.write(t, true, opClose, closePayload(errmap.CloseNormalClosure, ""))
+		op, payload = c.read(t)
+		require.Equal(t, byte(opClose), op)
+		assert.Equal(t, uint16(errmap.CloseNormalClosure), binary.BigEndian.Uint16(payload))
+	})
+
+	t.Run("query parameters stand in for headers", func(t *testing.T) {
+		served := make(chan struct{})
+		stub := &stubSessionServer{
+			serveFn: func(_ context.Context, tr app.Transport, accessToken, deviceID string) error {
+				defer close(served)
+				assert.Equal(t, "query-token", accessToken)
+				assert.Equal(t, "device-q", deviceID)
+				assert.Equal(t, []string{"batch"}, tr.(app.CapabilityTransport).Capabilities())
+				return nil
+			},
+		}
+		srv := serve(t, stub)
+		conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+WebSocketPath+"?token=query-token&device_id=device-q&capabilities=batch", nil)
+		require.NoError(t, err)
+		req.Header = upgradeHeader()
+		req.Header.Del("Authorization")
+		req.Header.Del("X-Device-Id")
+		require.NoError(t, req.Write(conn))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		<-served
+	})
+
+	t.Run("session error sends connection_closing then the close code", func(t *testing.T) {
+		stub := &stubSessionServer{
+			serveFn: func(context.Context, app.Transport, string, string) error {
+				return app.ErrServerShutdown
+			},
+			reconnect: &protocol.ReconnectPolicy{MinBackoffMs: 1000},
+		}
+		c := dialWS(t, serve(t, stub), nil)
+
+		f := c.readFrame(t)
+		require.Equal(t, protocol.FrameTypeConnectionClosing, f.Type)
+		var closing protocol.ConnectionClosing
+		require.NoError(t, f.ParsePayload(&closing))
+		assert.Equal(t, errmap.CloseServerShutdown.Reason, closing.Reason)
+		assert.Equal(t, errmap.CloseServerShutdown.Code, closing.Code)
+		require.NotNil(t, closing.Reconnect)
+		assert.Equal(t, int64(1000), closing.Reconnect.MinBackoffMs)
+
+		op, payload := c.read(t)
+		require.Equal(t, byte(opClose), op)
+		assert.Equal(t, uint16(errmap.CloseGoingAway), binary.BigEndian.Uint16(payload))
+	})
+
+	t.Run("oversized and malformed client frames", func(t *testing.T) {
+		for _, tt := range []struct {
+			name  string
+			write func(c *wsClient)
+			want  error
+		}{
+			{
+				name:  "over the inbound size limit",
+				write: func(c *wsClient) { c.write(t, true, opText, bytes.Repeat([]byte("x"), domain.MaxInboundFrameSize+1)) },
+				want:  domain.ErrMessageTooLarge,
+			},
+			{
+				name:  "binary message",
+				write: func(c *wsClient) { c.write(t, true, opBinary, []byte(`{}`)) },
+				want:  domain.ErrInvalidInput,
+			},
+			{
+				name:  "continuation outside a message",
+				write: func(c *wsClient) { c.write(t, true, opContinuation, []byte(`{}`)) },
+				want:  errProtocolViolation,
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				errs := make(chan error, 1)
+				stub := &stubSessionServer{
+					serveFn: func(ctx context.Context, tr app.Transport, _, _ string) error {
+						_, err := tr.Recv(ctx)
+						errs <- err
+						return err
+					},
+				}
+				c := dialWS(t, serve(t, stub), nil)
+				tt.write(c)
+				select {
+				case err := <-errs:
+					assert.ErrorIs(t, err, tt.want)
+				case <-time.After(5 * time.Second):
+					t.Fatal("timed out waiting for Recv")
+				}
+			})
+		}
+	})
+
+	t.Run("refuses requests that cannot be upgraded", func(t *testing.T) {
+		srv := serve(t, &stubSessionServer{serveFn: func(context.Context, app.Transport, string, string) error {
+			t.Error("session must not start")
+			return nil
+		}})
+		for _, tt := range []struct {
+			name   string
+			edit   func(h http.Header)
+			status int
+		}{
+			{"not an upgrade", func(h http.Header) { h.Del("Upgrade") }, http.StatusBadRequest},
+			{"wrong version", func(h http.Header) { h.Set("Sec-Websocket-Version", "8") }, http.StatusBadRequest},
+			{"malformed key", func(h http.Header) { h.Set("Sec-Websocket-Key", "short") }, http.StatusBadRequest},
+			{"no token", func(h http.Header) { h.Del("Authorization") }, http.StatusUnauthorized},
+			{"no device", func(h http.Header) { h.Del("X-Device-Id") }, http.StatusBadRequest},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := http.NewRequest(http.MethodGet, srv.URL+WebSocketPath, nil)
+				require.NoError(t, err)
+				req.Header = upgradeHeader()
+				tt.edit(req.Header)
+				resp, err := srv.Client().Do(req)
+				require.NoError(t, err)
+				defer func() { _ = resp.Body.Close() }()
+				assert.Equal(t, tt.status, resp.StatusCode)
+			})
+		}
+	})
+}
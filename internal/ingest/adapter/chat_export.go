@@ -0,0 +1,398 @@
+package adapter
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// Chat export formats.
+const (
+	ExportFormatWhatsApp = "whatsapp"
+	ExportFormatTelegram = "telegram"
+)
+
+// Date orders for WhatsApp exports, whose dates follow the exporting
+// phone's locale.
+const (
+	DateOrderAuto = "auto" // infer from dates whose day is over 12
+	DateOrderDMY  = "dmy"
+	DateOrderMDY  = "mdy"
+)
+
+// ChatExportOptions controls how chat exports are read.
+type ChatExportOptions struct {
+	// Location is the time zone of exports that record local times without
+	// an offset (WhatsApp, Telegram's date field). Nil means UTC.
+	Location *time.Location
+	// DateOrder is the WhatsApp date order; empty means DateOrderAuto.
+	DateOrder string
+}
+
+func (o ChatExportOptions) location() *time.Location {
+	if o.Location == nil {
+		return time.UTC
+	}
+	return o.Location
+}
+
+// ReadChatExport reads a chat export from the file at name: a WhatsApp
+// "Export chat" archive or text file, or a Telegram Desktop result.json or
+// an archive of one. The format is detected from the file names. Exports
+// over domain.MaxChatExportBytes uncompressed are rejected.
+func ReadChatExport(name string, opts ChatExportOptions) (app.ChatExport, error) {
+	if zr, err := zip.OpenReader(name); err == nil {
+		defer func() { _ = zr.Close() }()
+		return readChatExportArchive(&zr.Reader, opts)
+	}
+
+	f, err := os.Open(name) //nolint:gosec // operator-supplied export path
+	if err != nil {
+		return app.ChatExport{}, fmt.Errorf("chat export: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if strings.EqualFold(path.Ext(name), ".json") {
+		return ParseTelegramExport(f, opts)
+	}
+	export, err := ParseWhatsAppExport(f, opts)
+	if err == nil && export.Title == "" {
+		export.Title = whatsAppTitle(name)
+	}
+	return export, err
+}
+
+// readChatExportArchive reads the chat from a zip archive, ignoring media
+// files.
+func readChatExportArchive(zr *zip.Reader, opts ChatExportOptions) (app.ChatExport, error) {
+	var chat *zip.File
+	for _, f := range zr.File {
+		base := path.Base(f.Name)
+		switch {
+		case base == "result.json":
+			rc, err := f.Open()
+			if err != nil {
+				return app.ChatExport{}, fmt.Errorf("chat export: open %s: %w", f.Name, err)
+			}
+			defer func() { _ = rc.Close() }()
+			return ParseTelegramExport(rc, opts)
+		case base == "_chat.txt":
+			chat = f
+		case chat == nil && strings.EqualFold(path.Ext(base), ".txt"):
+			chat = f
+		}
+	}
+	if chat == nil {
+		return app.ChatExport{}, fmt.Errorf("chat export: archive has no _chat.txt or result.json: %w", domain.ErrInvalidInput)
+	}
+
+	rc, err := chat.Open()
+	if err != nil {
+		return app.ChatExport{}, fmt.Errorf("chat export: open %s: %w", chat.Name, err)
+	}
+	defer func() { _ = rc.Close() }()
+	export, err := ParseWhatsAppExport(rc, opts)
+	if err == nil && export.Title == "" {
+		export.Title = whatsAppTitle(chat.Name)
+	}
+	return export, err
+}
+
+// whatsAppTitle derives the chat name from an export file name such as
+// "WhatsApp Chat with Family.txt".
+func whatsAppTitle(name string) string {
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if title, ok := strings.CutPrefix(base, "WhatsApp Chat with "); ok {
+		return title
+	}
+	return ""
+}
+
+// limitExport reads r up to domain.MaxChatExportBytes.
+func limitExport(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, domain.MaxChatExportBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if len(data) > domain.MaxChatExportBytes {
+		return nil, fmt.Errorf("export exceeds %d bytes: %w", domain.MaxChatExportBytes, domain.ErrInvalidInput)
+	}
+	return data, nil
+}
+
+// whatsAppLine matches the first line of a WhatsApp message in both the
+// Android ("31/12/2023, 21:41 - Alice: Hi") and iOS ("[31/12/2023,
+// 21:41:05] Alice: Hi") layouts, with 12- or 24-hour times.
+var whatsAppLine = regexp.MustCompile(
+	`^\[?(\d{1,2})[/.](\d{1,2})[/.](\d{2,4}),? (\d{1,2}):(\d{2})(?::(\d{2}))?(?: ?([AaPp])\.? ?[Mm]\.?)?\]?(?: -)? (.*)$`)
+
+// whatsAppOmitted are bodies WhatsApp writes in place of content it leaves
+// out of an export.
+var whatsAppOmitted = []string{
+	"<Media omitted>",
+	"image omitted",
+	"video omitted",
+	"audio omitted",
+	"sticker omitted",
+	"GIF omitted",
+	"document omitted",
+	"Contact card omitted",
+	"This message was deleted",
+	"You deleted this message",
+	"<attached: ",
+}
+
+// whatsAppHeader is a parsed first line of a WhatsApp message.
+type whatsAppHeader struct {
+	a, b, year, hour, minute, second int
+	pm, twelveHour                   bool
+}
+
+type whatsAppEntry struct {
+	header whatsAppHeader
+	sender string
+	text   []string
+}
+
+// ParseWhatsAppExport parses the text of a WhatsApp chat export. Lines
+// without a sender, such as "Messages and calls are end-to-end encrypted",
+// are dropped; media and deleted messages are kept with empty text.
+func ParseWhatsAppExport(r io.Reader, opts ChatExportOptions) (app.ChatExport, error) {
+	data, err := limitExport(r)
+	if err != nil {
+		return app.ChatExport{}, fmt.Errorf("whatsapp export: %w", err)
+	}
+
+	var (
+		entries []whatsAppEntry
+		cur     *whatsAppEntry
+		dayOver = [2]bool{} // first or second date field above 12
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1) // the export is in memory; allow any line length
+	for sc.Scan() {
+		line := normalizeWhatsAppLine(sc.Text())
+		m := whatsAppLine.FindStringSubmatch(line)
+		if m == nil {
+			if cur != nil {
+				cur.text = append(cur.text, line)
+			}
+			continue
+		}
+
+		h := whatsAppHeader{
+			a: atoi(m[1]), b: atoi(m[2]), year: atoi(m[3]),
+			hour: atoi(m[4]), minute: atoi(m[5]), second: atoi(m[6]),
+			twelveHour: m[7] != "", pm: strings.EqualFold(m[7], "p"),
+		}
+		dayOver[0] = dayOver[0] || h.a > 12
+		dayOver[1] = dayOver[1] || h.b > 12
+
+		sender, text, ok := strings.Cut(m[8], ": ")
+		if !ok {
+			cur = nil // a system line; continuation lines belong to it
+			continue
+		}
+		entries = append(entries, whatsAppEntry{header: h, sender: sender, text: []string{text}})
+		cur = &entries[len(entries)-1]
+	}
+	if err := sc.Err(); err != nil {
+		return app.ChatExport{}, fmt.Errorf("whatsapp export: %w", err)
+	}
+
+	dayFirst, err := whatsAppDayFirst(opts.DateOrder, dayOver)
+	if err != nil {
+		return app.ChatExport{}, fmt.Errorf("whatsapp export: %w", err)
+	}
+	export := app.ChatExport{Format: ExportFormatWhatsApp, Messages: make([]app.ExportedMessage, 0, len(entries))}
+	for i, e := range entries {
+		sentAt, err := e.header.time(dayFirst, opts.location())
+		if err != nil {
+			return app.ChatExport{}, fmt.Errorf("whatsapp export: message %d: %w", i+1, err)
+		}
+		export.Messages = append(export.Messages, app.ExportedMessage{
+			Sender: e.sender,
+			SentAt: sentAt,
+			Text:   whatsAppText(strings.Join(e.text, "\n")),
+		})
+	}
+	return export, nil
+}
+
+// normalizeWhatsAppLine drops the direction marks iOS exports insert and
+// turns the no-break spaces newer exports put before AM/PM into spaces.
+func normalizeWhatsAppLine(line string) string {
+	return strings.NewReplacer("\u200e", "", "\u200f", "", "\u202f", " ", "\u00a0", " ").Replace(line)
+}
+
+// whatsAppText returns text, or "" if it stands in for omitted content.
+func whatsAppText(text string) string {
+	for _, omitted := range whatsAppOmitted {
+		if strings.HasPrefix(text, omitted) {
+			return ""
+		}
+	}
+	return text
+}
+
+// whatsAppDayFirst resolves the date order.
+func whatsAppDayFirst(order string, dayOver [2]bool) (bool, error) {
+	switch order {
+	case DateOrderDMY:
+		return true, nil
+	case DateOrderMDY:
+		return false, nil
+	case "", DateOrderAuto:
+		if dayOver[0] && dayOver[1] {
+			return false, fmt.Errorf("dates are neither day/month nor month/day: %w", domain.ErrInvalidInput)
+		}
+		// Ambiguous exports (every day <= 12) default to day first, the
+		// more common locale order.
+		return !dayOver[1], nil
+	default:
+		return false, fmt.Errorf("unknown date order %q: %w", order, domain.ErrInvalidInput)
+	}
+}
+
+func (h whatsAppHeader) time(dayFirst bool, loc *time.Location) (time.Time, error) {
+	day, month := h.a, h.b
+	if !dayFirst {
+		day, month = h.b, h.a
+	}
+	year := h.year
+	if year < 100 {
+		year += 2000
+	}
+	hour := h.hour
+	if h.twelveHour {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, fmt.Errorf("hour %d out of range: %w", hour, domain.ErrInvalidInput)
+		}
+		hour %= 12
+		if h.pm {
+			hour += 12
+		}
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || h.minute > 59 || h.second > 59 {
+		return time.Time{}, fmt.Errorf("invalid date %d-%02d-%02d %02d:%02d: %w", year, month, day, hour, h.minute, domain.ErrInvalidInput)
+	}
+	return time.Date(year, time.Month(month), day, hour, h.minute, h.second, 0, loc), nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s) // callers pass regexp digit groups or ""
+	return n
+}
+
+// telegramExport is the subset of a Telegram Desktop single-chat JSON
+// export the importer reads.
+type telegramExport struct {
+	Name     string            `json:"name"`
+	Messages []telegramMessage `json:"messages"`
+	Chats    json.RawMessage   `json:"chats"` // set only in full-account exports
+}
+
+type telegramMessage struct {
+	Type         string       `json:"type"`
+	Date         string       `json:"date"`
+	DateUnixtime string       `json:"date_unixtime"`
+	From         *string      `json:"from"`
+	Text         telegramText `json:"text"`
+}
+
+// telegramText is a message body: a string, or an array of strings and
+// formatted {"type", "text"} spans.
+type telegramText string
+
+func (t *telegramText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = telegramText(s)
+		return nil
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("text is neither a string nor an array: %w", err)
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		var span struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(p, &s); err == nil {
+			b.WriteString(s)
+		} else if err := json.Unmarshal(p, &span); err == nil {
+			b.WriteString(span.Text)
+		} else {
+			return fmt.Errorf("text span: %w", err)
+		}
+	}
+	*t = telegramText(b.String())
+	return nil
+}
+
+// ParseTelegramExport parses a Telegram Desktop single-chat JSON export
+// (result.json). Service messages are dropped; media-only messages are
+// kept with empty text.
+func ParseTelegramExport(r io.Reader, opts ChatExportOptions) (app.ChatExport, error) {
+	data, err := limitExport(r)
+	if err != nil {
+		return app.ChatExport{}, fmt.Errorf("telegram export: %w", err)
+	}
+	var in telegramExport
+	if err := json.Unmarshal(data, &in); err != nil {
+		return app.ChatExport{}, fmt.Errorf("telegram export: decode: %w: %w", domain.ErrInvalidInput, err)
+	}
+	if len(in.Chats) > 0 && in.Messages == nil {
+		return app.ChatExport{}, fmt.Errorf("telegram export: full account exports are not supported, export a single chat: %w", domain.ErrInvalidInput)
+	}
+
+	export := app.ChatExport{Format: ExportFormatTelegram, Title: in.Name, Messages: make([]app.ExportedMessage, 0, len(in.Messages))}
+	for i, m := range in.Messages {
+		if m.Type != "message" {
+			continue
+		}
+		sentAt, err := telegramTime(m, opts.location())
+		if err != nil {
+			return app.ChatExport{}, fmt.Errorf("telegram export: message %d: %w", i+1, err)
+		}
+		sender := "Deleted Account" // Telegram exports null for deleted accounts
+		if m.From != nil {
+			sender = *m.From
+		}
+		export.Messages = append(export.Messages, app.ExportedMessage{
+			Sender: sender,
+			SentAt: sentAt,
+			Text:   string(m.Text),
+		})
+	}
+	return export, nil
+}
+
+// telegramTime prefers date_unixtime, which newer exports include, over
+// the local-time date field.
+func telegramTime(m telegramMessage, loc *time.Location) (time.Time, error) {
+	if m.DateUnixtime != "" {
+		sec, err := strconv.ParseInt(m.DateUnixtime, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date_unixtime %q: %w", m.DateUnixtime, domain.ErrInvalidInput)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", m.Date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q: %w: %w", m.Date, domain.ErrInvalidInput, err)
+	}
+	return t, nil
+}
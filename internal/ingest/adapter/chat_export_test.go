@@ -0,0 +1,151 @@
+package adapter
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+func TestParseWhatsAppExport(t *testing.T) {
+	t.Run("android, day first, 24-hour", func(t *testing.T) {
+		export, err := ParseWhatsAppExport(strings.NewReader(
+			"31/12/2023, 21:40 - Messages and calls are end-to-end encrypted.\n"+
+				"31/12/2023, 21:41 - Alice: Happy new year\n"+
+				"almost!\n"+
+				"01/01/2024, 00:00 - Bob: <Media omitted>\n"+
+				"01/01/2024, 00:01 - Alice added Carol\n",
+		), ChatExportOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, app.ChatExport{Format: ExportFormatWhatsApp, Messages: []app.ExportedMessage{
+			{Sender: "Alice", SentAt: time.Date(2023, 12, 31, 21, 41, 0, 0, time.UTC), Text: "Happy new year\nalmost!"},
+			{Sender: "Bob", SentAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}}, export)
+	})
+
+	t.Run("ios, month first, 12-hour", func(t *testing.T) {
+		loc := time.FixedZone("UTC-5", -5*60*60)
+		export, err := ParseWhatsAppExport(strings.NewReader(
+			"\u200e[12/31/23, 9:41:05\u202fPM] Alice: Hi\n"+
+				"[1/2/24, 12:05:00 AM] Bob: \u200eimage omitted\n",
+		), ChatExportOptions{Location: loc})
+		require.NoError(t, err)
+
+		require.Len(t, export.Messages, 2)
+		assert.Equal(t, time.Date(2023, 12, 31, 21, 41, 5, 0, loc), export.Messages[0].SentAt)
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 5, 0, 0, loc), export.Messages[1].SentAt, "month first, 12 AM is midnight")
+		assert.Empty(t, export.Messages[1].Text)
+	})
+
+	t.Run("explicit date order overrides inference", func(t *testing.T) {
+		export, err := ParseWhatsAppExport(strings.NewReader("02/03/2024, 10:00 - Alice: Hi\n"), ChatExportOptions{DateOrder: DateOrderMDY})
+		require.NoError(t, err)
+		assert.Equal(t, time.February, export.Messages[0].SentAt.Month())
+	})
+
+	t.Run("rejects inconsistent dates", func(t *testing.T) {
+		_, err := ParseWhatsAppExport(strings.NewReader(
+			"13/01/2024, 10:00 - Alice: Hi\n01/13/2024, 10:00 - Bob: Hi\n"), ChatExportOptions{})
+		require.ErrorIs(t, err, domain.ErrInvalidInput)
+
+		_, err = ParseWhatsAppExport(strings.NewReader("01/01/2024, 10:00 - Alice: Hi\n"), ChatExportOptions{DateOrder: "ymd"})
+		require.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestParseTelegramExport(t *testing.T) {
+	export, err := ParseTelegramExport(strings.NewReader(`{
+		"name": "Family",
+		"type": "private_group",
+		"messages": [
+			{"id": 1, "type": "service", "date": "2024-01-01T00:00:00", "actor": "Alice", "action": "create_group", "text": ""},
+			{"id": 2, "type": "message", "date": "2024-01-01T10:00:00", "date_unixtime": "1704103200", "from": "Alice", "text": "Hi"},
+			{"id": 3, "type": "message", "date": "2024-01-01T10:01:00", "from": null,
+			 "text": ["see ", {"type": "bold", "text": "this"}, "!"]},
+			{"id": 4, "type": "message", "date": "2024-01-01T10:02:00", "from": "Bob", "photo": "photos/1.jpg", "text": ""}
+		]
+	}`), ChatExportOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, app.ChatExport{Format: ExportFormatTelegram, Title: "Family", Messages: []app.ExportedMessage{
+		{Sender: "Alice", SentAt: time.Unix(1704103200, 0).UTC(), Text: "Hi"},
+		{Sender: "Deleted Account", SentAt: time.Date(2024, 1, 1, 10, 1, 0, 0, time.UTC), Text: "see this!"},
+		{Sender: "Bob", SentAt: time.Date(2024, 1, 1, 10, 2, 0, 0, time.UTC)},
+	}}, export)
+
+	_, err = ParseTelegramExport(strings.NewReader(`{"about": "", "chats": {"list": []}}`), ChatExportOptions{})
+	require.ErrorIs(t, err, domain.ErrInvalidInput, "full account export")
+
+	_, err = ParseTelegramExport(strings.NewReader(`{"messages": [`), ChatExportOptions{})
+	require.ErrorIs(t, err, domain.ErrInvalidInput)
+}
+
+func TestReadChatExport(t *testing.T) {
+	dir := t.TempDir()
+	writeZip := func(name string, files map[string]string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		f, err := os.Create(p)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		for n, body := range files {
+			w, err := zw.Create(n)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(body))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+		return p
+	}
+
+	t.Run("whatsapp archive", func(t *testing.T) {
+		p := writeZip("wa.zip", map[string]string{
+			"WhatsApp Chat with Family.txt": "31/12/2023, 21:41 - Alice: Hi\n",
+			"IMG-0001.jpg":                  "\xff\xd8",
+		})
+		export, err := ReadChatExport(p, ChatExportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ExportFormatWhatsApp, export.Format)
+		assert.Equal(t, "Family", export.Title)
+		assert.Len(t, export.Messages, 1)
+	})
+
+	t.Run("telegram archive", func(t *testing.T) {
+		p := writeZip("tg.zip", map[string]string{
+			"ChatExport_2024-01-01/result.json": `{"name": "Family", "messages": []}`,
+		})
+		export, err := ReadChatExport(p, ChatExportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ExportFormatTelegram, export.Format)
+	})
+
+	t.Run("plain files", func(t *testing.T) {
+		txt := filepath.Join(dir, "WhatsApp Chat with Team.txt")
+		require.NoError(t, os.WriteFile(txt, []byte("31/12/2023, 21:41 - Alice: Hi\n"), 0o600))
+		export, err := ReadChatExport(txt, ChatExportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "Team", export.Title)
+
+		js := filepath.Join(dir, "result.json")
+		require.NoError(t, os.WriteFile(js, []byte(`{"name": "Family", "messages": []}`), 0o600))
+		export, err = ReadChatExport(js, ChatExportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, ExportFormatTelegram, export.Format)
+	})
+
+	t.Run("archive without a chat", func(t *testing.T) {
+		p := writeZip("empty.zip", map[string]string{"IMG-0001.jpg": "\xff\xd8"})
+		_, err := ReadChatExport(p, ChatExportOptions{})
+		require.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
@@ -1,3 +1,7 @@
 // Package adapter contains implementations of interfaces defined in app.
 // DynamoDB and Kafka adapters live here.
 package adapter
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("ingest/adapter")
@@ -0,0 +1,101 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// Compile-time check: ChatPolicyStore satisfies app.ChatPolicyReader.
+var _ app.ChatPolicyReader = (*ChatPolicyStore)(nil)
+
+// chatPolicyDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the chat policy store.
+type chatPolicyDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+}
+
+// ChatPolicyStore reads slow mode from the chats table and the sender's
+// role from chat_memberships. Reads are eventually consistent: a settings
+// or role change takes effect for messages after it propagates.
+type ChatPolicyStore struct {
+	db               chatPolicyDynamoDB
+	chatsTable       string
+	membershipsTable string
+}
+
+// NewChatPolicyStore creates a ChatPolicyStore backed by the given
+// DynamoDB client.
+func NewChatPolicyStore(db chatPolicyDynamoDB, chatsTable, membershipsTable string) *ChatPolicyStore {
+	return &ChatPolicyStore{db: db, chatsTable: chatsTable, membershipsTable: membershipsTable}
+}
+
+// ChatPolicy returns the chat's slow mode interval and senderID's role. An
+// unknown chat has slow mode off; a sender without a membership has no
+// role, so slow mode applies to them.
+func (s *ChatPolicyStore) ChatPolicy(ctx context.Context, chatID domain.ChatID, senderID domain.UserID) (app.ChatPolicy, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chats.get_policy")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	var chat struct {
+		SlowModeSeconds int `dynamodbav:"slow_mode_seconds"`
+	}
+	chatProjection := "slow_mode_seconds"
+	if err := s.get(ctx, &dynamo.GetItemInput{
+		TableName: &s.chatsTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID.String()},
+		},
+		ProjectionExpression: &chatProjection,
+	}, &chat); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.ChatPolicy{}, fmt.Errorf("chat policy store: get chat: %w", err)
+	}
+	if chat.SlowModeSeconds == 0 {
+		return app.ChatPolicy{}, nil // role is irrelevant without slow mode
+	}
+
+	var member struct {
+		Role string `dynamodbav:"role"`
+	}
+	memberProjection := "#role"
+	if err := s.get(ctx, &dynamo.GetItemInput{
+		TableName: &s.membershipsTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID.String()},
+			"user_id": &dynamo.AttributeValueMemberS{Value: senderID.String()},
+		},
+		ProjectionExpression:     &memberProjection,
+		ExpressionAttributeNames: map[string]string{"#role": "role"}, // reserved word
+	}, &member); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return app.ChatPolicy{}, fmt.Errorf("chat policy store: get membership: %w", err)
+	}
+
+	return app.ChatPolicy{SlowModeSeconds: chat.SlowModeSeconds, Role: domain.MemberRole(member.Role)}, nil
+}
+
+// get reads one item into out, leaving out unchanged if the item does not
+// exist.
+func (s *ChatPolicyStore) get(ctx context.Context, in *dynamo.GetItemInput, out any) error {
+	res, err := s.db.GetItem(ctx, in)
+	if err != nil {
+		return err
+	}
+	if res.Item == nil {
+		return nil
+	}
+	return dynamo.UnmarshalMap(res.Item, out)
+}
@@ -0,0 +1,92 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements chatPolicyDynamoDB from per-table items.
+// ---------------------------------------------------------------------------
+
+const membershipsTable = "chat_memberships"
+
+type stubChatPolicyDynamo struct {
+	items map[string]map[string]dynamo.AttributeValue // table -> item
+	err   error
+	calls []string
+}
+
+func (s *stubChatPolicyDynamo) GetItem(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	s.calls = append(s.calls, *params.TableName)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &dynamo.GetItemOutput{Item: s.items[*params.TableName]}, nil
+}
+
+var _ chatPolicyDynamoDB = (*stubChatPolicyDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestChatPolicyStore_ChatPolicy(t *testing.T) {
+	ctx := context.Background()
+	chat, sender := domain.GenerateChatID(), domain.GenerateUserID()
+
+	t.Run("reads slow mode and role", func(t *testing.T) {
+		db := &stubChatPolicyDynamo{items: map[string]map[string]dynamo.AttributeValue{
+			chatsTable:       {"slow_mode_seconds": &dynamo.AttributeValueMemberN{Value: "30"}},
+			membershipsTable: {"role": &dynamo.AttributeValueMemberS{Value: "admin"}},
+		}}
+		store := NewChatPolicyStore(db, chatsTable, membershipsTable)
+
+		got, err := store.ChatPolicy(ctx, chat, sender)
+
+		require.NoError(t, err)
+		assert.Equal(t, app.ChatPolicy{SlowModeSeconds: 30, Role: domain.MemberRoleAdmin}, got)
+	})
+
+	t.Run("slow mode off skips the membership read", func(t *testing.T) {
+		db := &stubChatPolicyDynamo{items: map[string]map[string]dynamo.AttributeValue{
+			chatsTable: {},
+		}}
+		store := NewChatPolicyStore(db, chatsTable, membershipsTable)
+
+		got, err := store.ChatPolicy(ctx, chat, sender)
+
+		require.NoError(t, err)
+		assert.Zero(t, got)
+		assert.Equal(t, []string{chatsTable}, db.calls)
+	})
+
+	t.Run("unknown sender has no role", func(t *testing.T) {
+		db := &stubChatPolicyDynamo{items: map[string]map[string]dynamo.AttributeValue{
+			chatsTable: {"slow_mode_seconds": &dynamo.AttributeValueMemberN{Value: "30"}},
+		}}
+		store := NewChatPolicyStore(db, chatsTable, membershipsTable)
+
+		got, err := store.ChatPolicy(ctx, chat, sender)
+
+		require.NoError(t, err)
+		assert.Equal(t, 30, got.SlowModeSeconds)
+		assert.Empty(t, got.Role)
+	})
+
+	t.Run("dynamo error", func(t *testing.T) {
+		store := NewChatPolicyStore(&stubChatPolicyDynamo{err: errors.New("throttled")}, chatsTable, membershipsTable)
+
+		_, err := store.ChatPolicy(ctx, chat, sender)
+
+		assert.ErrorContains(t, err, "throttled")
+	})
+}
@@ -0,0 +1,149 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// Compile-time check: ImportedMessageStore satisfies app.ImportedMessageStore.
+var _ app.ImportedMessageStore = (*ImportedMessageStore)(nil)
+
+// ImportedMessageStore writes imported conversations to the sharded
+// messages_v2 table, claiming their sequences on chat_counters so Ingest
+// allocates after them.
+type ImportedMessageStore struct {
+	db            messageDynamoDB
+	messages      *MessageStore
+	messagesTable string
+	countersTable string
+	clock         domain.Clock
+
+	// shards caches each chat's shard count for the length of an import;
+	// a chat's count cannot change once messages are written.
+	mu     sync.Mutex
+	shards map[string]int
+}
+
+// NewImportedMessageStore creates an ImportedMessageStore backed by the
+// given DynamoDB client. Content is sealed with sealer, or stored in
+// plaintext if it is nil.
+func NewImportedMessageStore(db messageDynamoDB, messagesTable, chatsTable, countersTable string, sealer msgcrypt.Sealer, clock domain.Clock) *ImportedMessageStore {
+	return &ImportedMessageStore{
+		db:            db,
+		messages:      NewMessageStore(db, messagesTable, chatsTable, sealer),
+		messagesTable: messagesTable,
+		countersTable: countersTable,
+		clock:         clock,
+		shards:        make(map[string]int),
+	}
+}
+
+// ReserveSequences sets the chat's sequence counter to n if it has no
+// counter yet or already holds n, so re-running an import of n messages
+// succeeds. Any other counter means the chat has messages of its own, and
+// returns domain.ErrAlreadyExists.
+func (s *ImportedMessageStore) ReserveSequences(ctx context.Context, chatID domain.ChatID, n uint64) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_counters.reserve_import")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+		attribute.Int64("messages.count", int64(n)), //nolint:gosec // bounded by domain.MaxConversationImportMessages
+	)
+
+	updateExpr := "SET sequence_counter = :n, updated_at = :now, created_at = if_not_exists(created_at, :now)"
+	condExpr := "attribute_not_exists(sequence_counter) OR sequence_counter = :n"
+	_, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.countersTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID.String()},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":n":   &dynamo.AttributeValueMemberN{Value: strconv.FormatUint(n, 10)},
+			":now": &dynamo.AttributeValueMemberS{Value: s.clock.Now().UTC().Format(time.RFC3339Nano)},
+		},
+	})
+	if dynamo.IsConditionalCheckFailed(err) {
+		err = fmt.Errorf("imported message store: chat %s already has messages: %w", chatID, domain.ErrAlreadyExists)
+	} else if err != nil {
+		err = fmt.Errorf("imported message store: reserve sequences: %w", err)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// PutImported writes msg to its shard, flagged as imported. Returns
+// domain.ErrAlreadyExists when the sequence is already stored.
+func (s *ImportedMessageStore) PutImported(ctx context.Context, msg app.ImportedMessage) error {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.put_imported")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	chatID := msg.ChatID.String()
+	shards, err := s.chatShards(ctx, chatID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	item := messageItem{
+		PK:              domain.MessagePartitionKey(chatID, domain.MessageShard(msg.Sequence, shards)),
+		Sequence:        msg.Sequence,
+		ChatID:          chatID,
+		MessageID:       msg.MessageID.String(),
+		SenderID:        msg.SenderID.String(),
+		ClientMessageID: msg.ClientMessageID,
+		Content:         msg.Content.Body(),
+		ContentType:     string(msg.Content.ContentType()),
+		CreatedAt:       msg.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Imported:        true,
+		ImportedSender:  msg.SenderName,
+	}
+	err = sealMessageItem(ctx, s.messages.sealer, &item)
+	if err == nil {
+		err = putMessageItem(ctx, s.db, s.messagesTable, item)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("imported message store: %w", err)
+	}
+	return nil
+}
+
+func (s *ImportedMessageStore) chatShards(ctx context.Context, chatID string) (int, error) {
+	s.mu.Lock()
+	n, ok := s.shards[chatID]
+	s.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+
+	n, err := s.messages.Shards(ctx, chatID)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	s.shards[chatID] = n
+	s.mu.Unlock()
+	return n, nil
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// fakeCounterDynamo adds an in-memory chat_counters table to
+// fakeMessageDynamo.
+type fakeCounterDynamo struct {
+	*fakeMessageDynamo
+	counters map[string]uint64
+	updates  []*dynamo.UpdateItemInput
+}
+
+func (f *fakeCounterDynamo) UpdateItem(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	f.updates = append(f.updates, params)
+	chatID := params.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+	n, err := strconv.ParseUint(params.ExpressionAttributeValues[":n"].(*dynamo.AttributeValueMemberN).Value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if cur, ok := f.counters[chatID]; ok && cur != n {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	f.counters[chatID] = n
+	return &dynamo.UpdateItemOutput{}, nil
+}
+
+func TestImportedMessageStore(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeCounterDynamo{fakeMessageDynamo: newFakeMessageDynamo(), counters: map[string]uint64{}}
+	clock := domaintest.NewFakeClock(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+	store := NewImportedMessageStore(db, messagesV2, chatsTable, "chat_counters", nil, clock)
+	chatID := domain.MustChatID("11111111-1111-4111-8111-111111111111")
+	db.shards[chatID.String()] = 2
+
+	t.Run("reserve claims the counter once", func(t *testing.T) {
+		require.NoError(t, store.ReserveSequences(ctx, chatID, 3))
+		require.NoError(t, store.ReserveSequences(ctx, chatID, 3), "re-running the same import")
+		assert.Equal(t, uint64(3), db.counters[chatID.String()])
+		assert.Equal(t, "chat_counters", *db.updates[0].TableName)
+		assert.Equal(t, "attribute_not_exists(sequence_counter) OR sequence_counter = :n", *db.updates[0].ConditionExpression)
+
+		err := store.ReserveSequences(ctx, chatID, 4)
+		require.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+
+	t.Run("put writes to the sequence's shard, flagged as imported", func(t *testing.T) {
+		sentAt := time.Date(2019, 5, 4, 8, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+		msg := app.ImportedMessage{
+			ChatID:          chatID,
+			Sequence:        3,
+			MessageID:       domain.GenerateMessageID(),
+			SenderID:        domain.PlaceholderUserID(chatID, "Alice"),
+			SenderName:      "Alice",
+			ClientMessageID: "sys:import:3",
+			Content:         domain.MustMessageContent(domain.ContentTypeText, "hello from 2019"),
+			CreatedAt:       sentAt,
+		}
+		require.NoError(t, store.PutImported(ctx, msg))
+
+		item := db.v2[domain.MessagePartitionKey(chatID.String(), 1)]["3"]
+		assert.True(t, item.Imported)
+		assert.Equal(t, "Alice", item.ImportedSender)
+		assert.Equal(t, "2019-05-04T06:30:00Z", item.CreatedAt)
+		assert.Equal(t, "hello from 2019", item.Content)
+
+		err := store.PutImported(ctx, msg)
+		require.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+}
@@ -0,0 +1,201 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// messageDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the message store and migrator.
+type messageDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+	UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error)
+}
+
+// messageItem is the DynamoDB item shape for the messages_v2 table. PK is
+// domain.MessagePartitionKey of the chat's shard for Sequence. The v1
+// messages table has the same attributes without PK, keyed by chat_id.
+type messageItem struct {
+	PK              string `dynamodbav:"pk,omitempty"`
+	Sequence        uint64 `dynamodbav:"sequence"`
+	ChatID          string `dynamodbav:"chat_id"`
+	MessageID       string `dynamodbav:"message_id"`
+	SenderID        string `dynamodbav:"sender_id"`
+	ClientMessageID string `dynamodbav:"client_message_id"`
+	Content         string `dynamodbav:"content,omitempty"`
+	ContentType     string `dynamodbav:"content_type"`
+	CreatedAt       string `dynamodbav:"created_at"`
+	ReceivedAt      string `dynamodbav:"server_received_at,omitempty"`
+
+	// ContentKey and SealedContent replace Content on encrypted messages:
+	// the chat key version and the content sealed with it (msgcrypt).
+	ContentKey    int    `dynamodbav:"content_key,omitempty"`
+	SealedContent []byte `dynamodbav:"sealed_content,omitempty"`
+
+	// Imported marks messages written by a conversation import, whose
+	// CreatedAt is the original send time; ImportedSender is the sender's
+	// name in the export.
+	Imported       bool   `dynamodbav:"imported,omitempty"`
+	ImportedSender string `dynamodbav:"imported_sender,omitempty"`
+}
+
+// toMessageItem converts a persisted message to the messages_v2 item shape.
+func toMessageItem(req app.PersistRequest, res app.PersistResult, shards int) messageItem {
+	chatID := req.ChatID.String()
+	return messageItem{
+		PK:              domain.MessagePartitionKey(chatID, domain.MessageShard(res.Sequence, shards)),
+		Sequence:        res.Sequence,
+		ChatID:          chatID,
+		MessageID:       res.MessageID.String(),
+		SenderID:        req.SenderID.String(),
+		ClientMessageID: req.ClientMessageID,
+		Content:         req.Content.Body(),
+		ContentType:     string(req.Content.ContentType()),
+		CreatedAt:       res.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ReceivedAt:      formatServerTime(req.ReceivedAt),
+	}
+}
+
+// sealMessageItem replaces item's content with its sealed form. A nil
+// sealer leaves it in plaintext.
+func sealMessageItem(ctx context.Context, sealer msgcrypt.Sealer, item *messageItem) error {
+	if sealer == nil {
+		return nil
+	}
+	sealed, err := sealer.Seal(ctx, item.ChatID, item.MessageID, []byte(item.Content))
+	if err != nil {
+		return err
+	}
+	item.Content = ""
+	item.ContentKey = sealed.KeyVersion
+	item.SealedContent = sealed.Ciphertext
+	return nil
+}
+
+// formatServerTime renders t for storage; an unset time is omitted.
+func formatServerTime(t domain.ServerTime) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Time().Format(time.RFC3339Nano)
+}
+
+// MessageStore writes messages to the sharded messages_v2 table. A chat's
+// shard count comes from the message_shards attribute of its chats record;
+// a chat without one has a single shard.
+type MessageStore struct {
+	db            messageDynamoDB
+	messagesTable string
+	chatsTable    string
+	sealer        msgcrypt.Sealer
+}
+
+// NewMessageStore creates a MessageStore backed by the given DynamoDB
+// client. Content is sealed with sealer, or stored in plaintext if it is
+// nil.
+func NewMessageStore(db messageDynamoDB, messagesTable, chatsTable string, sealer msgcrypt.Sealer) *MessageStore {
+	return &MessageStore{
+		db:            db,
+		messagesTable: messagesTable,
+		chatsTable:    chatsTable,
+		sealer:        sealer,
+	}
+}
+
+// Put writes a message with its allocated sequence to the chat's shard for
+// that sequence. Returns domain.ErrAlreadyExists when the sequence is
+// already stored.
+func (s *MessageStore) Put(ctx context.Context, req app.PersistRequest, res app.PersistResult) error {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.put")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem+PutItem"),
+	)
+
+	shards, err := s.Shards(ctx, req.ChatID.String())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	item := toMessageItem(req, res, shards)
+	if err := sealMessageItem(ctx, s.sealer, &item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("message store: %w", err)
+	}
+	if err := putMessageItem(ctx, s.db, s.messagesTable, item); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("message store: %w", err)
+	}
+	return nil
+}
+
+// Shards returns the chat's message shard count.
+func (s *MessageStore) Shards(ctx context.Context, chatID string) (int, error) {
+	projection := "message_shards"
+	consistentRead := true
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.chatsTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+		ProjectionExpression: &projection,
+		ConsistentRead:       &consistentRead,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("message store: get shard count: %w", err)
+	}
+
+	var chat struct {
+		MessageShards int `dynamodbav:"message_shards"`
+	}
+	if err := dynamo.UnmarshalMap(out.Item, &chat); err != nil {
+		return 0, fmt.Errorf("message store: unmarshal shard count: %w", err)
+	}
+	if chat.MessageShards == 0 {
+		return 1, nil
+	}
+	if !domain.IsValidMessageShards(chat.MessageShards) {
+		return 0, fmt.Errorf("message store: chat %s has %d message shards, want [1, %d]",
+			chatID, chat.MessageShards, domain.MaxMessageShards)
+	}
+	return chat.MessageShards, nil
+}
+
+// putMessageItem writes item unless its key is already taken, in which case
+// it returns domain.ErrAlreadyExists.
+func putMessageItem(ctx context.Context, db messageDynamoDB, table string, item messageItem) error {
+	av, err := dynamo.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("marshal message: %w", err)
+	}
+
+	condExpr := "attribute_not_exists(pk)"
+	_, err = db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &table,
+		Item:                av,
+		ConditionExpression: &condExpr,
+	})
+	if dynamo.IsConditionalCheckFailed(err) {
+		return fmt.Errorf("put message %s/%d: %w", item.ChatID, item.Sequence, domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("put message: %w", err)
+	}
+	return nil
+}
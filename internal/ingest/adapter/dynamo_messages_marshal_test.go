@@ -0,0 +1,50 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/allocbudget"
+)
+
+// testMessageItem is a typical plaintext message as Persist writes it.
+// Every message Ingest persists goes through MarshalMap once.
+var testMessageItem = messageItem{
+	PK:              "01HQX5K3Z9Y8W7V6U5T4S3R2Q0#shard0",
+	Sequence:        4242,
+	ChatID:          "01HQX5K3Z9Y8W7V6U5T4S3R2Q0",
+	MessageID:       "01HQX5K3Z9Y8W7V6U5T4S3R2Q1",
+	SenderID:        "user-001",
+	ClientMessageID: "5f1c7f3e-8a8e-4b7a-9d51-0c5b8c2c9d3e",
+	Content:         "see you at eight",
+	ContentType:     "text/plain",
+	CreatedAt:       "2026-01-15T12:00:00.000Z",
+	ReceivedAt:      "2026-01-15T12:00:00.000Z",
+}
+
+func TestMessageItem_RoundTrip(t *testing.T) {
+	av, err := dynamo.MarshalMap(testMessageItem)
+	require.NoError(t, err)
+
+	var got messageItem
+	require.NoError(t, dynamo.UnmarshalMap(av, &got))
+	assert.Equal(t, testMessageItem, got)
+}
+
+func TestMessageItem_MarshalAllocBudget(t *testing.T) {
+	allocbudget.Check(t, allocbudget.Budget{Allocs: 20, RaceAllocs: 20}, func() {
+		_, _ = dynamo.MarshalMap(testMessageItem)
+	})
+}
+
+func BenchmarkMarshalMessage(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := dynamo.MarshalMap(testMessageItem); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
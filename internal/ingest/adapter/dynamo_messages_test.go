@@ -0,0 +1,361 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — in-memory chats, messages (v1) and messages_v2 tables.
+// ---------------------------------------------------------------------------
+
+const (
+	chatsTable    = "chats"
+	messagesTable = "messages"
+	messagesV2    = "messages_v2"
+	pageSize      = 2 // v1 Query page size, to exercise pagination
+)
+
+type fakeMessageDynamo struct {
+	shards  map[string]int                    // chat_id -> message_shards
+	v1      []messageItem                     // ascending sequence, one chat
+	v2      map[string]map[string]messageItem // pk -> sequence -> item
+	putErr  error
+	queries int
+	updates int // messages_v2 updates
+}
+
+func newFakeMessageDynamo() *fakeMessageDynamo {
+	return &fakeMessageDynamo{shards: map[string]int{}, v2: map[string]map[string]messageItem{}}
+}
+
+func (f *fakeMessageDynamo) GetItem(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	chatID := params.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+	n, ok := f.shards[chatID]
+	if !ok {
+		return &dynamo.GetItemOutput{}, nil
+	}
+	return &dynamo.GetItemOutput{Item: map[string]dynamo.AttributeValue{
+		"message_shards": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(n)},
+	}}, nil
+}
+
+func (f *fakeMessageDynamo) PutItem(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	var item messageItem
+	if err := dynamo.UnmarshalMap(params.Item, &item); err != nil {
+		return nil, err
+	}
+	seq := strconv.FormatUint(item.Sequence, 10)
+	if _, ok := f.v2[item.PK][seq]; ok {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	if f.v2[item.PK] == nil {
+		f.v2[item.PK] = map[string]messageItem{}
+	}
+	f.v2[item.PK][seq] = item
+	return &dynamo.PutItemOutput{}, nil
+}
+
+func (f *fakeMessageDynamo) Query(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	f.queries++
+	if *params.TableName == messagesV2 {
+		return f.queryV2(params)
+	}
+	start := 0
+	if params.ExclusiveStartKey != nil {
+		n, err := strconv.Atoi(params.ExclusiveStartKey["sequence"].(*dynamo.AttributeValueMemberN).Value)
+		if err != nil {
+			return nil, err
+		}
+		start = n // sequences are 1-based and contiguous
+	}
+
+	var out dynamo.QueryOutput
+	end := min(start+pageSize, len(f.v1))
+	for _, item := range f.v1[start:end] {
+		av, err := dynamo.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, av)
+	}
+	if end < len(f.v1) {
+		out.LastEvaluatedKey = map[string]dynamo.AttributeValue{
+			"chat_id":  &dynamo.AttributeValueMemberS{Value: f.v1[end-1].ChatID},
+			"sequence": &dynamo.AttributeValueMemberN{Value: strconv.FormatUint(f.v1[end-1].Sequence, 10)},
+		}
+	}
+	return &out, nil
+}
+
+// queryV2 returns a messages_v2 partition in one page, keeping only
+// plaintext items as MessageEncryptor's filter does.
+func (f *fakeMessageDynamo) queryV2(params *dynamo.QueryInput) (*dynamo.QueryOutput, error) {
+	pk := params.ExpressionAttributeValues[":pk"].(*dynamo.AttributeValueMemberS).Value
+	var out dynamo.QueryOutput
+	for _, seq := range slices.Sorted(maps.Keys(f.v2[pk])) {
+		item := f.v2[pk][seq]
+		if item.ContentKey != 0 {
+			continue
+		}
+		av, err := dynamo.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, av)
+	}
+	return &out, nil
+}
+
+func (f *fakeMessageDynamo) UpdateItem(_ context.Context, params *dynamo.UpdateItemInput, _ ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	if *params.TableName == messagesV2 {
+		return f.sealV2(params)
+	}
+	chatID := params.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+	n, err := strconv.Atoi(params.ExpressionAttributeValues[":n"].(*dynamo.AttributeValueMemberN).Value)
+	if err != nil {
+		return nil, err
+	}
+	if cur, ok := f.shards[chatID]; ok && cur != n {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	f.shards[chatID] = n
+	return &dynamo.UpdateItemOutput{}, nil
+}
+
+// sealV2 applies MessageEncryptor's update to a messages_v2 item.
+func (f *fakeMessageDynamo) sealV2(params *dynamo.UpdateItemInput) (*dynamo.UpdateItemOutput, error) {
+	f.updates++
+	pk := params.Key["pk"].(*dynamo.AttributeValueMemberS).Value
+	seq := params.Key["sequence"].(*dynamo.AttributeValueMemberN).Value
+	item, ok := f.v2[pk][seq]
+	values := params.ExpressionAttributeValues
+	if !ok || item.ContentKey != 0 || item.Content != values[":plain"].(*dynamo.AttributeValueMemberS).Value {
+		return nil, dynamo.ErrConditionalCheckFailed()
+	}
+	key, err := strconv.Atoi(values[":key"].(*dynamo.AttributeValueMemberN).Value)
+	if err != nil {
+		return nil, err
+	}
+	item.Content = ""
+	item.ContentKey = key
+	item.SealedContent = values[":sealed"].(*dynamo.AttributeValueMemberB).Value
+	f.v2[pk][seq] = item
+	return &dynamo.UpdateItemOutput{}, nil
+}
+
+var _ messageDynamoDB = (*fakeMessageDynamo)(nil)
+
+// stubSealer "seals" content by prefixing it with the chat and message IDs,
+// under key version 1.
+type stubSealer struct{ err error }
+
+func (s stubSealer) Seal(_ context.Context, chatID, messageID string, plaintext []byte) (msgcrypt.Sealed, error) {
+	if s.err != nil {
+		return msgcrypt.Sealed{}, s.err
+	}
+	return msgcrypt.Sealed{KeyVersion: 1, Ciphertext: []byte(chatID + "/" + messageID + ":" + string(plaintext))}, nil
+}
+
+// count returns the number of messages_v2 items.
+func (f *fakeMessageDynamo) count() int {
+	n := 0
+	for _, items := range f.v2 {
+		n += len(items)
+	}
+	return n
+}
+
+// ---------------------------------------------------------------------------
+// Tests — MessageStore
+// ---------------------------------------------------------------------------
+
+// put stores a message with sequence seq in chatID.
+func put(ctx context.Context, store *MessageStore, chatID domain.ChatID, seq uint64) error {
+	return store.Put(ctx, app.PersistRequest{
+		ChatID:          chatID,
+		SenderID:        domain.GenerateUserID(),
+		ClientMessageID: "client-" + strconv.FormatUint(seq, 10),
+		Content:         domain.MustMessageContent(domain.ContentTypeText, "hello"),
+		ReceivedAt:      domain.ServerTimeFromMillis(time.Date(2026, 2, 10, 11, 59, 59, 0, time.UTC).UnixMilli()),
+	}, app.PersistResult{
+		MessageID: domain.GenerateMessageID(),
+		Sequence:  seq,
+		CreatedAt: time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC),
+	})
+}
+
+func TestMessageStore_Put(t *testing.T) {
+	ctx := context.Background()
+	chatID := domain.GenerateChatID()
+
+	t.Run("spreads sequences over shards", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		db.shards[chatID.String()] = 3
+		store := NewMessageStore(db, messagesV2, chatsTable, nil)
+
+		for seq := uint64(1); seq <= 6; seq++ {
+			require.NoError(t, put(ctx, store, chatID, seq))
+		}
+
+		require.Len(t, db.v2, 3)
+		for shard := range 3 {
+			assert.Len(t, db.v2[domain.MessagePartitionKey(chatID.String(), shard)], 2)
+		}
+		item := db.v2[domain.MessagePartitionKey(chatID.String(), 1)]["4"]
+		assert.Equal(t, chatID.String(), item.ChatID)
+		assert.Equal(t, "client-4", item.ClientMessageID)
+		assert.Equal(t, "2026-02-10T12:00:00Z", item.CreatedAt)
+		assert.Equal(t, "2026-02-10T11:59:59Z", item.ReceivedAt)
+	})
+
+	t.Run("unsharded chat uses shard 0", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		store := NewMessageStore(db, messagesV2, chatsTable, nil)
+
+		require.NoError(t, put(ctx, store, chatID, 7))
+
+		assert.Contains(t, db.v2, domain.MessagePartitionKey(chatID.String(), 0))
+	})
+
+	t.Run("existing sequence", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		store := NewMessageStore(db, messagesV2, chatsTable, nil)
+		require.NoError(t, put(ctx, store, chatID, 7))
+
+		err := put(ctx, store, chatID, 7)
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+
+	t.Run("seals content", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		store := NewMessageStore(db, messagesV2, chatsTable, stubSealer{})
+
+		require.NoError(t, put(ctx, store, chatID, 1))
+
+		item := db.v2[domain.MessagePartitionKey(chatID.String(), 0)]["1"]
+		assert.Empty(t, item.Content)
+		assert.Equal(t, 1, item.ContentKey)
+		assert.Equal(t, chatID.String()+"/"+item.MessageID+":hello", string(item.SealedContent))
+	})
+
+	t.Run("seal failure", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		store := NewMessageStore(db, messagesV2, chatsTable, stubSealer{err: errors.New("kms unavailable")})
+
+		err := put(ctx, store, chatID, 1)
+
+		require.ErrorContains(t, err, "kms unavailable")
+		assert.Zero(t, db.count())
+	})
+
+	t.Run("shard count out of range", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		db.shards[chatID.String()] = domain.MaxMessageShards + 1
+		store := NewMessageStore(db, messagesV2, chatsTable, nil)
+
+		err := put(ctx, store, chatID, 1)
+
+		require.Error(t, err)
+		assert.Zero(t, db.count())
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Tests — MessageMigrator
+// ---------------------------------------------------------------------------
+
+func seedV1(db *fakeMessageDynamo, chatID string, count uint64) {
+	for seq := uint64(1); seq <= count; seq++ {
+		db.v1 = append(db.v1, messageItem{
+			Sequence:    seq,
+			ChatID:      chatID,
+			MessageID:   "msg-" + strconv.FormatUint(seq, 10),
+			ContentType: string(domain.ContentTypeText),
+			Content:     "hello",
+			CreatedAt:   "2026-02-10T12:00:00Z",
+		})
+	}
+}
+
+func TestMessageMigrator_MigrateChat(t *testing.T) {
+	ctx := context.Background()
+	const chatID = "chat-1"
+
+	t.Run("copies every page onto shards", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		seedV1(db, chatID, 5)
+		m := NewMessageMigrator(db, messagesTable, messagesV2, chatsTable)
+
+		copied, err := m.MigrateChat(ctx, chatID, 4)
+
+		require.NoError(t, err)
+		assert.Equal(t, 5, copied)
+		assert.Equal(t, 3, db.queries, "5 messages in pages of 2")
+		assert.Equal(t, 4, db.shards[chatID])
+		assert.Contains(t, db.v2[domain.MessagePartitionKey(chatID, 1)], "5")
+	})
+
+	t.Run("re-run skips copied messages", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		seedV1(db, chatID, 5)
+		m := NewMessageMigrator(db, messagesTable, messagesV2, chatsTable)
+		_, err := m.MigrateChat(ctx, chatID, 2)
+		require.NoError(t, err)
+
+		copied, err := m.MigrateChat(ctx, chatID, 2)
+
+		require.NoError(t, err)
+		assert.Zero(t, copied)
+		assert.Equal(t, 5, db.count())
+	})
+
+	t.Run("different shard count", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		seedV1(db, chatID, 5)
+		db.shards[chatID] = 2
+		m := NewMessageMigrator(db, messagesTable, messagesV2, chatsTable)
+
+		_, err := m.MigrateChat(ctx, chatID, 4)
+
+		require.ErrorIs(t, err, domain.ErrAlreadyExists)
+		assert.Zero(t, db.count())
+	})
+
+	t.Run("invalid shard count", func(t *testing.T) {
+		m := NewMessageMigrator(newFakeMessageDynamo(), messagesTable, messagesV2, chatsTable)
+
+		_, err := m.MigrateChat(ctx, chatID, 0)
+
+		require.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("write failure", func(t *testing.T) {
+		db := newFakeMessageDynamo()
+		seedV1(db, chatID, 5)
+		db.putErr = errors.New("throttled")
+		m := NewMessageMigrator(db, messagesTable, messagesV2, chatsTable)
+
+		copied, err := m.MigrateChat(ctx, chatID, 2)
+
+		require.Error(t, err)
+		assert.Zero(t, copied)
+	})
+}
@@ -0,0 +1,239 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// Compile-time checks: PersistStore backs every store step of
+// app.PersistService.
+var (
+	_ app.IdempotencyStore  = (*PersistStore)(nil)
+	_ app.SequenceAllocator = (*PersistStore)(nil)
+	_ app.MessageWriter     = (*PersistStore)(nil)
+)
+
+// persistDynamoDB is messageDynamoDB plus the transactional write that
+// stores a message together with its idempotency record.
+type persistDynamoDB interface {
+	messageDynamoDB
+	TransactWriteItems(ctx context.Context, params *dynamo.TransactWriteItemsInput, optFns ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error)
+}
+
+// idempotencyItem is the DynamoDB item shape for the idempotency_keys
+// table (PK chat_id, SK client_message_id). It holds what PersistMessage
+// returned, so a retry gets the same answer; TTL expires it after
+// domain.IdempotencyKeyTTL.
+type idempotencyItem struct {
+	ChatID          string `dynamodbav:"chat_id"`
+	ClientMessageID string `dynamodbav:"client_message_id"`
+	MessageID       string `dynamodbav:"message_id"`
+	Sequence        uint64 `dynamodbav:"sequence"`
+	CreatedAt       string `dynamodbav:"created_at"`
+	TTL             int64  `dynamodbav:"ttl"`
+}
+
+// PersistStore is the DynamoDB side of the ADR-004 persist flow: the
+// idempotency_keys lookup, sequence allocation on chat_counters, and the
+// transactional write of the messages_v2 item with its idempotency record.
+type PersistStore struct {
+	db               persistDynamoDB
+	messages         *MessageStore
+	messagesTable    string
+	countersTable    string
+	idempotencyTable string
+	sealer           msgcrypt.Sealer
+	clock            domain.Clock
+}
+
+// NewPersistStore creates a PersistStore. Content is sealed with sealer,
+// or stored in plaintext if it is nil.
+func NewPersistStore(db persistDynamoDB, messagesTable, chatsTable, countersTable, idempotencyTable string, sealer msgcrypt.Sealer, clock domain.Clock) *PersistStore {
+	return &PersistStore{
+		db:               db,
+		messages:         NewMessageStore(db, messagesTable, chatsTable, sealer),
+		messagesTable:    messagesTable,
+		countersTable:    countersTable,
+		idempotencyTable: idempotencyTable,
+		sealer:           sealer,
+		clock:            clock,
+	}
+}
+
+// Lookup returns the result stored for clientMessageID. The read is
+// strongly consistent so a retry racing the original write sees it once
+// the transaction commits.
+func (s *PersistStore) Lookup(ctx context.Context, chatID domain.ChatID, clientMessageID string) (app.PersistResult, bool, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.idempotency_keys.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	res, ok, err := s.lookup(ctx, chatID, clientMessageID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, ok, err
+}
+
+func (s *PersistStore) lookup(ctx context.Context, chatID domain.ChatID, clientMessageID string) (app.PersistResult, bool, error) {
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.idempotencyTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id":           &dynamo.AttributeValueMemberS{Value: chatID.String()},
+			"client_message_id": &dynamo.AttributeValueMemberS{Value: clientMessageID},
+		},
+		ConsistentRead: dynamo.Bool(true),
+	})
+	if err != nil {
+		return app.PersistResult{}, false, fmt.Errorf("persist store: get idempotency key: %w", err)
+	}
+	if out.Item == nil {
+		return app.PersistResult{}, false, nil
+	}
+
+	var item idempotencyItem
+	if err := dynamo.UnmarshalMap(out.Item, &item); err != nil {
+		return app.PersistResult{}, false, fmt.Errorf("persist store: unmarshal idempotency key: %w", err)
+	}
+	messageID, err := domain.NewMessageID(item.MessageID)
+	if err != nil {
+		return app.PersistResult{}, false, fmt.Errorf("persist store: idempotency key: %w", err)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	if err != nil {
+		return app.PersistResult{}, false, fmt.Errorf("persist store: idempotency key created_at: %w", err)
+	}
+	return app.PersistResult{MessageID: messageID, Sequence: item.Sequence, CreatedAt: createdAt}, true, nil
+}
+
+// Allocate increments the chat's sequence counter and returns the new
+// value. The counter item is created on the chat's first message.
+func (s *PersistStore) Allocate(ctx context.Context, chatID domain.ChatID) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_counters.allocate")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem"),
+	)
+
+	seq, err := s.allocate(ctx, chatID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return seq, err
+}
+
+func (s *PersistStore) allocate(ctx context.Context, chatID domain.ChatID) (uint64, error) {
+	updateExpr := "ADD sequence_counter :one SET updated_at = :now, created_at = if_not_exists(created_at, :now)"
+	out, err := s.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &s.countersTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID.String()},
+		},
+		UpdateExpression: &updateExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":one": &dynamo.AttributeValueMemberN{Value: "1"},
+			":now": &dynamo.AttributeValueMemberS{Value: s.clock.Now().UTC().Format(time.RFC3339Nano)},
+		},
+		ReturnValues: dynamo.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("persist store: allocate sequence: %w", err)
+	}
+	counter, ok := out.Attributes["sequence_counter"].(*dynamo.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("persist store: allocate sequence: no sequence_counter returned")
+	}
+	seq, err := strconv.ParseUint(counter.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("persist store: allocate sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// Write stores the message in its shard and its idempotency record in one
+// transaction. Returns domain.ErrDuplicateMessage when the client message
+// ID was stored first, and domain.ErrAlreadyExists when the sequence was.
+func (s *PersistStore) Write(ctx context.Context, req app.PersistRequest, res app.PersistResult) error {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.write")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem+TransactWriteItems"),
+	)
+
+	if err := s.write(ctx, req, res); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s *PersistStore) write(ctx context.Context, req app.PersistRequest, res app.PersistResult) error {
+	shards, err := s.messages.Shards(ctx, req.ChatID.String())
+	if err != nil {
+		return fmt.Errorf("persist store: %w", err)
+	}
+	msg := toMessageItem(req, res, shards)
+	if err := sealMessageItem(ctx, s.sealer, &msg); err != nil {
+		return fmt.Errorf("persist store: %w", err)
+	}
+	msgAV, err := dynamo.MarshalMap(msg)
+	if err != nil {
+		return fmt.Errorf("persist store: marshal message: %w", err)
+	}
+	keyAV, err := dynamo.MarshalMap(idempotencyItem{
+		ChatID:          msg.ChatID,
+		ClientMessageID: req.ClientMessageID,
+		MessageID:       msg.MessageID,
+		Sequence:        res.Sequence,
+		CreatedAt:       msg.CreatedAt,
+		TTL:             s.clock.Now().Add(domain.IdempotencyKeyTTL).Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("persist store: marshal idempotency key: %w", err)
+	}
+
+	_, err = s.db.TransactWriteItems(ctx, &dynamo.TransactWriteItemsInput{
+		TransactItems: []dynamo.TransactWriteItem{
+			{Put: &dynamo.Put{
+				TableName:           &s.idempotencyTable,
+				Item:                keyAV,
+				ConditionExpression: dynamo.String("attribute_not_exists(client_message_id)"),
+			}},
+			{Put: &dynamo.Put{
+				TableName:           &s.messagesTable,
+				Item:                msgAV,
+				ConditionExpression: dynamo.String("attribute_not_exists(pk)"),
+			}},
+		},
+	})
+	if reasons, ok := dynamo.IsTransactionCanceledException(err); ok {
+		switch {
+		case len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed":
+			return fmt.Errorf("persist store: %s/%s: %w", msg.ChatID, req.ClientMessageID, domain.ErrDuplicateMessage)
+		case len(reasons) > 1 && reasons[1] == "ConditionalCheckFailed":
+			return fmt.Errorf("persist store: message %s/%d: %w", msg.ChatID, msg.Sequence, domain.ErrAlreadyExists)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("persist store: write message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// ---------------------------------------------------------------------------
+// Fake — fakeMessageDynamo plus chat_counters and idempotency_keys.
+// ---------------------------------------------------------------------------
+
+const (
+	countersTable    = "chat_counters"
+	idempotencyTable = "idempotency_keys"
+)
+
+type fakePersistDynamo struct {
+	*fakeMessageDynamo
+	counters map[string]uint64                     // chat_id -> sequence_counter
+	keys     map[string]map[string]idempotencyItem // chat_id -> client_message_id -> item
+	txErr    error
+}
+
+func newFakePersistDynamo() *fakePersistDynamo {
+	return &fakePersistDynamo{
+		fakeMessageDynamo: newFakeMessageDynamo(),
+		counters:          map[string]uint64{},
+		keys:              map[string]map[string]idempotencyItem{},
+	}
+}
+
+func (f *fakePersistDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	if *params.TableName != idempotencyTable {
+		return f.fakeMessageDynamo.GetItem(ctx, params, optFns...)
+	}
+	chatID := params.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+	clientMessageID := params.Key["client_message_id"].(*dynamo.AttributeValueMemberS).Value
+	item, ok := f.keys[chatID][clientMessageID]
+	if !ok {
+		return &dynamo.GetItemOutput{}, nil
+	}
+	av, err := dynamo.MarshalMap(item)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamo.GetItemOutput{Item: av}, nil
+}
+
+func (f *fakePersistDynamo) UpdateItem(ctx context.Context, params *dynamo.UpdateItemInput, optFns ...func(*dynamo.Options)) (*dynamo.UpdateItemOutput, error) {
+	if *params.TableName != countersTable {
+		return f.fakeMessageDynamo.UpdateItem(ctx, params, optFns...)
+	}
+	chatID := params.Key["chat_id"].(*dynamo.AttributeValueMemberS).Value
+	f.counters[chatID]++
+	return &dynamo.UpdateItemOutput{Attributes: map[string]dynamo.AttributeValue{
+		"sequence_counter": &dynamo.AttributeValueMemberN{Value: strconv.FormatUint(f.counters[chatID], 10)},
+	}}, nil
+}
+
+func (f *fakePersistDynamo) TransactWriteItems(_ context.Context, params *dynamo.TransactWriteItemsInput, _ ...func(*dynamo.Options)) (*dynamo.TransactWriteItemsOutput, error) {
+	if f.txErr != nil {
+		return nil, f.txErr
+	}
+	var key idempotencyItem
+	if err := dynamo.UnmarshalMap(params.TransactItems[0].Put.Item, &key); err != nil {
+		return nil, err
+	}
+	var msg messageItem
+	if err := dynamo.UnmarshalMap(params.TransactItems[1].Put.Item, &msg); err != nil {
+		return nil, err
+	}
+	seq := strconv.FormatUint(msg.Sequence, 10)
+	_, keyTaken := f.keys[key.ChatID][key.ClientMessageID]
+	_, seqTaken := f.v2[msg.PK][seq]
+	if keyTaken || seqTaken {
+		codes := []string{"None", "None"}
+		if keyTaken {
+			codes[0] = "ConditionalCheckFailed"
+		}
+		if seqTaken {
+			codes[1] = "ConditionalCheckFailed"
+		}
+		return nil, dynamo.ErrTransactionCanceled(codes...)
+	}
+	if f.keys[key.ChatID] == nil {
+		f.keys[key.ChatID] = map[string]idempotencyItem{}
+	}
+	f.keys[key.ChatID][key.ClientMessageID] = key
+	if f.v2[msg.PK] == nil {
+		f.v2[msg.PK] = map[string]messageItem{}
+	}
+	f.v2[msg.PK][seq] = msg
+	return &dynamo.TransactWriteItemsOutput{}, nil
+}
+
+var _ persistDynamoDB = (*fakePersistDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests — PersistStore
+// ---------------------------------------------------------------------------
+
+func TestPersistStore(t *testing.T) {
+	ctx := context.Background()
+	clock := domaintest.NewFakeClock(time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC))
+	chatID := domain.GenerateChatID()
+	req := app.PersistRequest{
+		ChatID:          chatID,
+		SenderID:        domain.GenerateUserID(),
+		ClientMessageID: "client-1",
+		Content:         domain.MustMessageContent(domain.ContentTypeText, "hello"),
+	}
+	result := func(seq uint64) app.PersistResult {
+		return app.PersistResult{
+			MessageID: domain.GenerateMessageID(),
+			Sequence:  seq,
+			CreatedAt: clock.Now(),
+		}
+	}
+
+	t.Run("allocates consecutive sequences per chat", func(t *testing.T) {
+		db := newFakePersistDynamo()
+		store := NewPersistStore(db, messagesV2, chatsTable, countersTable, idempotencyTable, nil, clock)
+
+		first, err := store.Allocate(ctx, chatID)
+		require.NoError(t, err)
+		second, err := store.Allocate(ctx, chatID)
+		require.NoError(t, err)
+		other, err := store.Allocate(ctx, domain.GenerateChatID())
+		require.NoError(t, err)
+
+		assert.Equal(t, []uint64{1, 2, 1}, []uint64{first, second, other})
+	})
+
+	t.Run("write is found by lookup", func(t *testing.T) {
+		db := newFakePersistDynamo()
+		db.shards[chatID.String()] = 2
+		store := NewPersistStore(db, messagesV2, chatsTable, countersTable, idempotencyTable, nil, clock)
+		res := result(3)
+
+		require.NoError(t, store.Write(ctx, req, res))
+		got, ok, err := store.Lookup(ctx, chatID, "client-1")
+
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, res, got)
+		item := db.v2[domain.MessagePartitionKey(chatID.String(), 1)]["3"]
+		assert.Equal(t, "hello", item.Content)
+		assert.Equal(t, clock.Now().Add(domain.IdempotencyKeyTTL).Unix(), db.keys[chatID.String()]["client-1"].TTL)
+	})
+
+	t.Run("lookup miss", func(t *testing.T) {
+		store := NewPersistStore(newFakePersistDynamo(), messagesV2, chatsTable, countersTable, idempotencyTable, nil, clock)
+
+		_, ok, err := store.Lookup(ctx, chatID, "client-1")
+
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("client message ID stored first", func(t *testing.T) {
+		db := newFakePersistDynamo()
+		store := NewPersistStore(db, messagesV2, chatsTable, countersTable, idempotencyTable, nil, clock)
+		require.NoError(t, store.Write(ctx, req, result(1)))
+
+		err := store.Write(ctx, req, result(2))
+
+		require.ErrorIs(t, err, domain.ErrDuplicateMessage)
+		assert.Equal(t, 1, db.count())
+	})
+
+	t.Run("sequence stored first", func(t *testing.T) {
+		db := newFakePersistDynamo()
+		store := NewPersistStore(db, messagesV2, chatsTable, countersTable, idempotencyTable, nil, clock)
+		require.NoError(t, store.Write(ctx, req, result(1)))
+		retry := req
+		retry.ClientMessageID = "client-2"
+
+		err := store.Write(ctx, retry, result(1))
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+	})
+
+	t.Run("seals content", func(t *testing.T) {
+		db := newFakePersistDynamo()
+		store := NewPersistStore(db, messagesV2, chatsTable, countersTable, idempotencyTable, stubSealer{}, clock)
+
+		require.NoError(t, store.Write(ctx, req, result(1)))
+
+		item := db.v2[domain.MessagePartitionKey(chatID.String(), 0)]["1"]
+		assert.Empty(t, item.Content)
+		assert.Equal(t, 1, item.ContentKey)
+	})
+
+	t.Run("transaction failure", func(t *testing.T) {
+		db := newFakePersistDynamo()
+		db.txErr = errors.New("throttled")
+		store := NewPersistStore(db, messagesV2, chatsTable, countersTable, idempotencyTable, nil, clock)
+
+		err := store.Write(ctx, req, result(1))
+
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrDuplicateMessage)
+	})
+}
@@ -0,0 +1,152 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// MessageEncryptor seals the content of messages_v2 items written before
+// encryption at rest was turned on. It is safe to re-run, and to run while
+// the chat is live: items already sealed are skipped.
+type MessageEncryptor struct {
+	db            messageDynamoDB
+	messages      *MessageStore
+	messagesTable string
+	sealer        msgcrypt.Sealer
+}
+
+// NewMessageEncryptor creates a MessageEncryptor backed by the given
+// DynamoDB client, sealing with sealer.
+func NewMessageEncryptor(db messageDynamoDB, messagesTable, chatsTable string, sealer msgcrypt.Sealer) *MessageEncryptor {
+	return &MessageEncryptor{
+		db:            db,
+		messages:      NewMessageStore(db, messagesTable, chatsTable, sealer),
+		messagesTable: messagesTable,
+		sealer:        sealer,
+	}
+}
+
+// EncryptChat seals every plaintext message in the chat, across all of its
+// shards. It returns the number of messages sealed.
+func (e *MessageEncryptor) EncryptChat(ctx context.Context, chatID string) (int, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.encrypt_chat")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query+UpdateItem"),
+	)
+
+	if e.sealer == nil {
+		return 0, errors.New("message encryptor: no sealer configured")
+	}
+	shards, err := e.messages.Shards(ctx, chatID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	sealed := 0
+	for shard := range shards {
+		n, err := e.encryptShard(ctx, chatID, domain.MessagePartitionKey(chatID, shard))
+		sealed += n
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return sealed, fmt.Errorf("message encryptor: %w", err)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("messages.sealed", sealed))
+	return sealed, nil
+}
+
+func (e *MessageEncryptor) encryptShard(ctx context.Context, chatID, pk string) (int, error) {
+	keyExpr := "pk = :pk"
+	filterExpr := "attribute_not_exists(content_key)"
+	consistentRead := true
+	sealed := 0
+	var startKey map[string]dynamo.AttributeValue
+	for {
+		out, err := e.db.Query(ctx, &dynamo.QueryInput{
+			TableName:              &e.messagesTable,
+			KeyConditionExpression: &keyExpr,
+			FilterExpression:       &filterExpr,
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":pk": &dynamo.AttributeValueMemberS{Value: pk},
+			},
+			ExclusiveStartKey: startKey,
+			ConsistentRead:    &consistentRead,
+		})
+		if err != nil {
+			return sealed, fmt.Errorf("query %s: %w", pk, err)
+		}
+
+		for _, av := range out.Items {
+			var item messageItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return sealed, fmt.Errorf("unmarshal message: %w", err)
+			}
+			ok, err := e.sealItem(ctx, item)
+			if err != nil {
+				return sealed, fmt.Errorf("seal message %s/%d: %w", chatID, item.Sequence, err)
+			}
+			if ok {
+				sealed++
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return sealed, nil
+		}
+		startKey = out.LastEvaluatedKey
+
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return sealed, err
+		}
+	}
+}
+
+// sealItem replaces item's plaintext content with its sealed form. It
+// reports false if the item changed since it was read, e.g. a concurrent
+// run sealed it first.
+func (e *MessageEncryptor) sealItem(ctx context.Context, item messageItem) (bool, error) {
+	plaintext := item.Content
+	if err := sealMessageItem(ctx, e.sealer, &item); err != nil {
+		return false, err
+	}
+
+	updateExpr := "SET content_key = :key, sealed_content = :sealed REMOVE content"
+	condExpr := "attribute_not_exists(content_key) AND content = :plain"
+	_, err := e.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &e.messagesTable,
+		Key: map[string]dynamo.AttributeValue{
+			"pk":       &dynamo.AttributeValueMemberS{Value: item.PK},
+			"sequence": &dynamo.AttributeValueMemberN{Value: strconv.FormatUint(item.Sequence, 10)},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":key":    &dynamo.AttributeValueMemberN{Value: strconv.Itoa(item.ContentKey)},
+			":sealed": &dynamo.AttributeValueMemberB{Value: item.SealedContent},
+			":plain":  &dynamo.AttributeValueMemberS{Value: plaintext},
+		},
+	})
+	if dynamo.IsConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("update: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+func TestMessageEncryptor_EncryptChat(t *testing.T) {
+	ctx := context.Background()
+	chatID := domain.GenerateChatID()
+
+	// seed writes five plaintext messages over three shards, and one
+	// message that is already sealed.
+	seed := func(t *testing.T) *fakeMessageDynamo {
+		t.Helper()
+		db := newFakeMessageDynamo()
+		db.shards[chatID.String()] = 3
+		plain := NewMessageStore(db, messagesV2, chatsTable, nil)
+		for seq := uint64(1); seq <= 5; seq++ {
+			require.NoError(t, put(ctx, plain, chatID, seq))
+		}
+		require.NoError(t, put(ctx, NewMessageStore(db, messagesV2, chatsTable, stubSealer{}), chatID, 6))
+		return db
+	}
+
+	t.Run("seals plaintext messages on every shard", func(t *testing.T) {
+		db := seed(t)
+		e := NewMessageEncryptor(db, messagesV2, chatsTable, stubSealer{})
+
+		sealed, err := e.EncryptChat(ctx, chatID.String())
+
+		require.NoError(t, err)
+		assert.Equal(t, 5, sealed)
+		assert.Equal(t, 3, db.queries, "one query per shard")
+		for _, items := range db.v2 {
+			for _, item := range items {
+				assert.Empty(t, item.Content)
+				assert.Equal(t, 1, item.ContentKey)
+				assert.Equal(t, chatID.String()+"/"+item.MessageID+":hello", string(item.SealedContent))
+			}
+		}
+
+		sealed, err = e.EncryptChat(ctx, chatID.String())
+		require.NoError(t, err)
+		assert.Zero(t, sealed, "re-run finds nothing to seal")
+	})
+
+	t.Run("seal failure", func(t *testing.T) {
+		db := seed(t)
+		e := NewMessageEncryptor(db, messagesV2, chatsTable, stubSealer{err: errors.New("kms unavailable")})
+
+		sealed, err := e.EncryptChat(ctx, chatID.String())
+
+		require.ErrorContains(t, err, "kms unavailable")
+		assert.Zero(t, sealed)
+		assert.Zero(t, db.updates)
+	})
+
+	t.Run("requires a sealer", func(t *testing.T) {
+		_, err := NewMessageEncryptor(seed(t), messagesV2, chatsTable, nil).EncryptChat(ctx, chatID.String())
+
+		require.Error(t, err)
+	})
+}
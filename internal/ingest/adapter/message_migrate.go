@@ -0,0 +1,138 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// MessageMigrator copies chats from the v1 messages table (PK chat_id) into
+// the sharded messages_v2 table. It is safe to re-run: messages already in
+// messages_v2, whether copied earlier or written there directly, are
+// skipped.
+type MessageMigrator struct {
+	db          messageDynamoDB
+	sourceTable string
+	targetTable string
+	chatsTable  string
+}
+
+// NewMessageMigrator creates a MessageMigrator backed by the given DynamoDB client.
+func NewMessageMigrator(db messageDynamoDB, sourceTable, targetTable, chatsTable string) *MessageMigrator {
+	return &MessageMigrator{
+		db:          db,
+		sourceTable: sourceTable,
+		targetTable: targetTable,
+		chatsTable:  chatsTable,
+	}
+}
+
+// MigrateChat fixes the chat's shard count at shards and copies its v1
+// messages into messages_v2. It returns the number of messages copied.
+//
+// A chat's shard count cannot change once set: existing messages_v2 items
+// would be on the wrong shards. Returns domain.ErrAlreadyExists when the
+// chat already has a different count.
+func (m *MessageMigrator) MigrateChat(ctx context.Context, chatID string, shards int) (int, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.messages.migrate_chat")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "UpdateItem+Query+PutItem"),
+		attribute.Int("messages.shards", shards),
+	)
+
+	if !domain.IsValidMessageShards(shards) {
+		return 0, domain.NewValidationError("shards", fmt.Sprintf("must be in [1, %d]", domain.MaxMessageShards))
+	}
+	if err := m.setShards(ctx, chatID, shards); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	keyExpr := "chat_id = :cid"
+	consistentRead := true
+	copied := 0
+	var startKey map[string]dynamo.AttributeValue
+	for {
+		out, err := m.db.Query(ctx, &dynamo.QueryInput{
+			TableName:              &m.sourceTable,
+			KeyConditionExpression: &keyExpr,
+			ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+				":cid": &dynamo.AttributeValueMemberS{Value: chatID},
+			},
+			ExclusiveStartKey: startKey,
+			ConsistentRead:    &consistentRead,
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return copied, fmt.Errorf("message migrator: query %s: %w", chatID, err)
+		}
+
+		for _, av := range out.Items {
+			var item messageItem
+			if err := dynamo.UnmarshalMap(av, &item); err != nil {
+				return copied, fmt.Errorf("message migrator: unmarshal message: %w", err)
+			}
+			item.PK = domain.MessagePartitionKey(chatID, domain.MessageShard(item.Sequence, shards))
+
+			err := putMessageItem(ctx, m.db, m.targetTable, item)
+			switch {
+			case errors.Is(err, domain.ErrAlreadyExists):
+			case err != nil:
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return copied, fmt.Errorf("message migrator: %w", err)
+			default:
+				copied++
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = out.LastEvaluatedKey
+
+		// Check context between pages per 04_CONTEXT.
+		if err := ctx.Err(); err != nil {
+			return copied, fmt.Errorf("message migrator: %w", err)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("messages.copied", copied))
+	return copied, nil
+}
+
+// setShards records shards on the chat unless it already has another count.
+func (m *MessageMigrator) setShards(ctx context.Context, chatID string, shards int) error {
+	updateExpr := "SET message_shards = :n"
+	condExpr := "attribute_not_exists(message_shards) OR message_shards = :n"
+
+	_, err := m.db.UpdateItem(ctx, &dynamo.UpdateItemInput{
+		TableName: &m.chatsTable,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+		UpdateExpression:    &updateExpr,
+		ConditionExpression: &condExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":n": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(shards)},
+		},
+	})
+	if dynamo.IsConditionalCheckFailed(err) {
+		return fmt.Errorf("message migrator: chat %s has a different shard count: %w", chatID, domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("message migrator: set shard count: %w", err)
+	}
+	return nil
+}
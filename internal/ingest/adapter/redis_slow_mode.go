@@ -0,0 +1,110 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+// slowModeClaimScript records a member's post time unless their previous
+// post is within the interval. The value is "<post ms>|<client_message_id>"
+// and expires with the interval, so idle members cost nothing. A repeated
+// claim for the same client_message_id succeeds without moving the time.
+// Returns 0 when claimed, otherwise the remaining wait in ms.
+//
+//	ARGV[1] = now (ms), ARGV[2] = interval (ms), ARGV[3] = client_message_id
+const slowModeClaimScript = `
+local v = redis.call('GET', KEYS[1])
+if v then
+  local sep = string.find(v, '|', 1, true)
+  if string.sub(v, sep + 1) == ARGV[3] then
+    return 0
+  end
+  local remaining = tonumber(string.sub(v, 1, sep - 1)) + tonumber(ARGV[2]) - tonumber(ARGV[1])
+  if remaining > 0 then
+    return remaining
+  end
+end
+redis.call('SET', KEYS[1], ARGV[1] .. '|' .. ARGV[3], 'PX', ARGV[2])
+return 0
+`
+
+// slowModeReleaseScript deletes the key only if it still holds the given
+// claim, so a release never erases a later post.
+const slowModeReleaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// Compile-time check: SlowModeStore satisfies app.SlowModeStore.
+var _ app.SlowModeStore = (*SlowModeStore)(nil)
+
+// SlowModeStore keeps each member's last post time per chat in Redis.
+type SlowModeStore struct {
+	cmd redisclient.Cmdable
+}
+
+// NewSlowModeStore creates a SlowModeStore that uses cmd for Redis operations.
+func NewSlowModeStore(cmd redisclient.Cmdable) *SlowModeStore {
+	return &SlowModeStore{cmd: cmd}
+}
+
+// Claim records a post at now unless the sender's previous post is within
+// interval, in which case it returns the remaining wait.
+func (s *SlowModeStore) Claim(
+	ctx context.Context, chatID domain.ChatID, senderID domain.UserID, clientMessageID string, interval time.Duration, now time.Time,
+) (time.Duration, error) {
+	ctx, span := tracer.Start(ctx, "redis.slow_mode.claim")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	remainingMs, err := s.cmd.Eval(ctx, slowModeClaimScript, []string{slowModeKey(chatID, senderID)},
+		now.UnixMilli(), interval.Milliseconds(), clientMessageID,
+	).Int64()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("slow mode claim: %w", err)
+	}
+	return time.Duration(remainingMs) * time.Millisecond, nil
+}
+
+// Release removes the claim made at now for clientMessageID if no later
+// post has replaced it.
+func (s *SlowModeStore) Release(
+	ctx context.Context, chatID domain.ChatID, senderID domain.UserID, clientMessageID string, now time.Time,
+) error {
+	ctx, span := tracer.Start(ctx, "redis.slow_mode.release")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", "EVAL"),
+	)
+
+	claim := strconv.FormatInt(now.UnixMilli(), 10) + "|" + clientMessageID
+	if err := s.cmd.Eval(ctx, slowModeReleaseScript, []string{slowModeKey(chatID, senderID)}, claim).Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("slow mode release: %w", err)
+	}
+	return nil
+}
+
+// slowModeKey is the per-(chat, member) key. The chat ID is the hash tag so
+// a chat's keys share a cluster slot.
+func slowModeKey(chatID domain.ChatID, senderID domain.UserID) string {
+	return "slowmode:{" + chatID.String() + "}:" + senderID.String()
+}
@@ -0,0 +1,130 @@
+package adapter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/adapter"
+	redisclient "github.com/aelexs/realtime-messaging-platform/internal/redis"
+)
+
+func newTestSlowModeStore(t *testing.T) (*adapter.SlowModeStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redisclient.NewClient(redisclient.Config{
+		Addr:         mr.Addr(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	return adapter.NewSlowModeStore(client.RDB), mr
+}
+
+func TestSlowModeStore_Claim(t *testing.T) {
+	ctx := context.Background()
+	chat, sender := domain.GenerateChatID(), domain.GenerateUserID()
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	interval := 30 * time.Second
+
+	t.Run("throttles within the interval", func(t *testing.T) {
+		store, _ := newTestSlowModeStore(t)
+
+		remaining, err := store.Claim(ctx, chat, sender, "m1", interval, start)
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+
+		remaining, err = store.Claim(ctx, chat, sender, "m2", interval, start.Add(12*time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 18*time.Second, remaining)
+
+		remaining, err = store.Claim(ctx, chat, sender, "m2", interval, start.Add(interval))
+		require.NoError(t, err)
+		assert.Zero(t, remaining, "allowed at the end of the interval")
+	})
+
+	t.Run("same client message id is not throttled", func(t *testing.T) {
+		store, _ := newTestSlowModeStore(t)
+
+		_, err := store.Claim(ctx, chat, sender, "m1", interval, start)
+		require.NoError(t, err)
+		remaining, err := store.Claim(ctx, chat, sender, "m1", interval, start.Add(time.Second))
+
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+	})
+
+	t.Run("members and chats are independent", func(t *testing.T) {
+		store, _ := newTestSlowModeStore(t)
+
+		_, err := store.Claim(ctx, chat, sender, "m1", interval, start)
+		require.NoError(t, err)
+
+		remaining, err := store.Claim(ctx, chat, domain.GenerateUserID(), "m2", interval, start)
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+		remaining, err = store.Claim(ctx, domain.GenerateChatID(), sender, "m3", interval, start)
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+	})
+
+	t.Run("key expires with the interval", func(t *testing.T) {
+		store, mr := newTestSlowModeStore(t)
+
+		_, err := store.Claim(ctx, chat, sender, "m1", interval, start)
+		require.NoError(t, err)
+
+		keys := mr.Keys()
+		require.Len(t, keys, 1)
+		assert.Equal(t, interval, mr.TTL(keys[0]))
+	})
+
+	t.Run("redis failure", func(t *testing.T) {
+		store, mr := newTestSlowModeStore(t)
+		mr.Close()
+
+		_, err := store.Claim(ctx, chat, sender, "m1", interval, start)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestSlowModeStore_Release(t *testing.T) {
+	ctx := context.Background()
+	chat, sender := domain.GenerateChatID(), domain.GenerateUserID()
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	interval := 30 * time.Second
+
+	t.Run("releases its own claim", func(t *testing.T) {
+		store, _ := newTestSlowModeStore(t)
+		_, err := store.Claim(ctx, chat, sender, "m1", interval, start)
+		require.NoError(t, err)
+
+		require.NoError(t, store.Release(ctx, chat, sender, "m1", start))
+
+		remaining, err := store.Claim(ctx, chat, sender, "m2", interval, start.Add(time.Second))
+		require.NoError(t, err)
+		assert.Zero(t, remaining)
+	})
+
+	t.Run("does not release a later claim", func(t *testing.T) {
+		store, _ := newTestSlowModeStore(t)
+		_, err := store.Claim(ctx, chat, sender, "m2", interval, start.Add(time.Second))
+		require.NoError(t, err)
+
+		require.NoError(t, store.Release(ctx, chat, sender, "m1", start))
+
+		remaining, err := store.Claim(ctx, chat, sender, "m3", interval, start.Add(2*time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 29*time.Second, remaining)
+	})
+}
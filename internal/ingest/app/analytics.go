@@ -0,0 +1,38 @@
+package app
+
+import "context"
+
+// MessageAnalytics records product analytics for persisted messages. It
+// is implemented by analytics.Emitter, which pseudonymizes the IDs and
+// never blocks.
+type MessageAnalytics interface {
+	MessageSent(ctx context.Context, senderID, chatID, contentType string, size int)
+}
+
+// AnalyticsPersister persists through Next and reports each newly
+// persisted message to Analytics. Duplicates, failures and system
+// messages are not reported, so a retried send counts once. Only the
+// content type and size leave ingest; the body never does.
+type AnalyticsPersister struct {
+	next      Persister
+	analytics MessageAnalytics
+}
+
+// NewAnalyticsPersister creates an AnalyticsPersister.
+func NewAnalyticsPersister(next Persister, analytics MessageAnalytics) *AnalyticsPersister {
+	return &AnalyticsPersister{next: next, analytics: analytics}
+}
+
+// Persist persists req through Next and reports it if it was new.
+func (p *AnalyticsPersister) Persist(ctx context.Context, req PersistRequest) (PersistResult, error) {
+	res, err := p.next.Persist(ctx, req)
+	if err != nil || res.Duplicate || req.SenderID.IsZero() {
+		return res, err
+	}
+	p.analytics.MessageSent(ctx, req.SenderID.String(), req.ChatID.String(),
+		string(req.Content.ContentType()), req.Content.Size())
+	return res, nil
+}
+
+// Compile-time interface check.
+var _ Persister = (*AnalyticsPersister)(nil)
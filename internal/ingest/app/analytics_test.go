@@ -0,0 +1,62 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+type recordedMessage struct {
+	sender, chat, contentType string
+	size                      int
+}
+
+type fakeMessageAnalytics struct {
+	sent []recordedMessage
+}
+
+func (f *fakeMessageAnalytics) MessageSent(_ context.Context, senderID, chatID, contentType string, size int) {
+	f.sent = append(f.sent, recordedMessage{senderID, chatID, contentType, size})
+}
+
+func TestAnalyticsPersister(t *testing.T) {
+	ctx := context.Background()
+	chat := domain.GenerateChatID()
+
+	t.Run("reports new messages", func(t *testing.T) {
+		events := &fakeMessageAnalytics{}
+		req := request(chat)
+
+		_, err := app.NewAnalyticsPersister(sequencer(1), events).Persist(ctx, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, []recordedMessage{{req.SenderID.String(), chat.String(), "text", 5}}, events.sent)
+	})
+
+	t.Run("skips duplicates, failures and system messages", func(t *testing.T) {
+		events := &fakeMessageAnalytics{}
+		duplicate := persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			return app.PersistResult{Sequence: 1, Duplicate: true}, nil
+		})
+		failing := persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			return app.PersistResult{}, errors.New("throttled")
+		})
+		system := request(chat)
+		system.SenderID = domain.UserID{}
+
+		_, err := app.NewAnalyticsPersister(duplicate, events).Persist(ctx, request(chat))
+		require.NoError(t, err)
+		_, err = app.NewAnalyticsPersister(failing, events).Persist(ctx, request(chat))
+		require.Error(t, err)
+		_, err = app.NewAnalyticsPersister(sequencer(2), events).Persist(ctx, system)
+		require.NoError(t, err)
+
+		assert.Empty(t, events.sent)
+	})
+}
@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var importedMessagesTotal metric.Int64Counter
+
+func init() {
+	importedMessagesTotal, _ = otel.Meter("ingest/app").Int64Counter("ingest_imported_messages_total",
+		metric.WithDescription("Messages written by conversation imports from chat exports"))
+}
+
+// ExportedMessage is one message read from a third-party chat export.
+type ExportedMessage struct {
+	Sender string // the sender's name as the export shows it
+	SentAt time.Time
+	Text   string
+}
+
+// ChatExport is a conversation read from a third-party chat export, in the
+// order the export lists it.
+type ChatExport struct {
+	Format   string // e.g. "whatsapp", "telegram"
+	Title    string
+	Messages []ExportedMessage
+}
+
+// ImportedMessage is an exported message ready to store. It keeps the
+// original send time as CreatedAt and the name the export showed for the
+// sender.
+type ImportedMessage struct {
+	ChatID          domain.ChatID
+	Sequence        uint64
+	MessageID       domain.MessageID
+	SenderID        domain.UserID
+	SenderName      string
+	ClientMessageID string
+	Content         domain.MessageContent
+	CreatedAt       time.Time
+}
+
+// ImportedMessageStore writes imported conversations.
+type ImportedMessageStore interface {
+	// ReserveSequences claims sequences 1 through n of chatID for an import.
+	// It succeeds if the chat has no messages, or if an earlier import of n
+	// messages claimed them, and returns domain.ErrAlreadyExists otherwise.
+	ReserveSequences(ctx context.Context, chatID domain.ChatID, n uint64) error
+	// PutImported writes msg, and returns domain.ErrAlreadyExists if its
+	// sequence is already stored.
+	PutImported(ctx context.Context, msg ImportedMessage) error
+}
+
+// ConversationImporterConfig holds configuration for creating a
+// ConversationImporter.
+type ConversationImporterConfig struct {
+	Store  ImportedMessageStore
+	Clock  domain.Clock // nil defaults to domain.RealClock
+	Logger *slog.Logger // nil uses slog.Default
+
+	// MessageIDs generates the imported messages' IDs. Nil defaults to
+	// UUIDs (domain.MessageIDSchemeUUID).
+	MessageIDs domain.MessageIDGenerator
+
+	// RatePerSecond caps messages written per second. Zero defaults to
+	// domain.ConversationImportRatePerSecond.
+	RatePerSecond int
+}
+
+// ConversationImportResult summarizes an import.
+type ConversationImportResult struct {
+	Exported int // messages in the export
+	Written  int
+	Existing int // written by an earlier run of the same import
+	// Skipped counts messages with no importable text: media, deleted
+	// messages, or text over domain.MaxMessageSize.
+	Skipped int
+	// Placeholders are the export names mapped to placeholder senders,
+	// sorted.
+	Placeholders []string
+}
+
+// ConversationImporter writes a chat export into a platform chat with the
+// messages' original timestamps. Imported messages take sequences 1..N in
+// export order, so the chat must have no messages of its own; Ingest
+// continues from N+1 once the import has claimed its range. Messages are
+// stored directly, without publishing to Kafka: members see the history
+// when they next sync, not as new messages.
+//
+// Re-running the same import finishes an interrupted one: messages already
+// stored are left as they are.
+type ConversationImporter struct {
+	store    ImportedMessageStore
+	clock    domain.Clock
+	logger   *slog.Logger
+	ids      domain.MessageIDGenerator
+	interval time.Duration
+}
+
+// NewConversationImporter creates a ConversationImporter.
+func NewConversationImporter(cfg ConversationImporterConfig) *ConversationImporter {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ids := cfg.MessageIDs
+	if ids == nil {
+		ids, _ = domain.NewMessageIDGenerator(domain.MessageIDSchemeUUID, clock)
+	}
+	rate := cfg.RatePerSecond
+	if rate <= 0 {
+		rate = domain.ConversationImportRatePerSecond
+	}
+	return &ConversationImporter{
+		store:    cfg.Store,
+		clock:    clock,
+		logger:   logger,
+		ids:      ids,
+		interval: time.Second / time.Duration(rate),
+	}
+}
+
+// Plan converts export into the messages Import would write to chatID.
+// participants maps export names to platform users; any other sender gets
+// a placeholder ID (domain.PlaceholderUserID). It writes nothing, so it
+// doubles as a dry run.
+func (i *ConversationImporter) Plan(chatID domain.ChatID, export ChatExport, participants map[string]domain.UserID) ([]ImportedMessage, ConversationImportResult, error) {
+	res := ConversationImportResult{Exported: len(export.Messages)}
+	if len(export.Messages) > domain.MaxConversationImportMessages {
+		return nil, res, domain.NewValidationError("export",
+			fmt.Sprintf("has %d messages, max %d", len(export.Messages), domain.MaxConversationImportMessages))
+	}
+
+	msgs := make([]ImportedMessage, 0, len(export.Messages))
+	placeholders := make(map[string]bool)
+	for _, m := range export.Messages {
+		content, err := domain.NewMessageContent(domain.ContentTypeText, m.Text)
+		if err != nil {
+			res.Skipped++
+			continue
+		}
+		senderID, ok := participants[m.Sender]
+		if !ok {
+			senderID = domain.PlaceholderUserID(chatID, m.Sender)
+			placeholders[m.Sender] = true
+		}
+		seq := uint64(len(msgs) + 1)
+		msgs = append(msgs, ImportedMessage{
+			ChatID:          chatID,
+			Sequence:        seq,
+			MessageID:       i.ids.NewMessageID(),
+			SenderID:        senderID,
+			SenderName:      m.Sender,
+			ClientMessageID: domain.SystemClientMessageID("import:" + strconv.FormatUint(seq, 10)),
+			Content:         content,
+			CreatedAt:       m.SentAt.UTC(),
+		})
+	}
+	for name := range placeholders {
+		res.Placeholders = append(res.Placeholders, name)
+	}
+	slices.Sort(res.Placeholders)
+	return msgs, res, nil
+}
+
+// Import writes export into chatID at the configured rate. See Plan for
+// how senders are mapped. It returns domain.ErrAlreadyExists if the chat
+// already has messages other than an earlier run of this import.
+func (i *ConversationImporter) Import(ctx context.Context, chatID domain.ChatID, export ChatExport, participants map[string]domain.UserID) (ConversationImportResult, error) {
+	msgs, res, err := i.Plan(chatID, export, participants)
+	if err != nil {
+		return res, fmt.Errorf("import conversation: %w", err)
+	}
+	if len(msgs) == 0 {
+		return res, nil
+	}
+	if err := i.store.ReserveSequences(ctx, chatID, uint64(len(msgs))); err != nil {
+		return res, fmt.Errorf("import conversation: reserve %d sequences: %w", len(msgs), err)
+	}
+
+	i.logger.InfoContext(ctx, "conversation_import.started",
+		"chat_id", chatID.String(), "format", export.Format, "messages", len(msgs), "placeholders", len(res.Placeholders))
+	started := i.clock.Now()
+
+	pace := time.NewTicker(i.interval)
+	defer pace.Stop()
+	for _, m := range msgs {
+		select {
+		case <-ctx.Done():
+		case <-pace.C:
+		}
+		if err := ctx.Err(); err != nil {
+			return res, fmt.Errorf("import conversation: stopped at sequence %d: %w", m.Sequence, err)
+		}
+
+		err := i.store.PutImported(ctx, m)
+		switch {
+		case errors.Is(err, domain.ErrAlreadyExists):
+			res.Existing++
+		case err != nil:
+			return res, fmt.Errorf("import conversation: write sequence %d: %w", m.Sequence, err)
+		default:
+			res.Written++
+			importedMessagesTotal.Add(ctx, 1)
+		}
+	}
+
+	i.logger.InfoContext(ctx, "conversation_import.finished",
+		"chat_id", chatID.String(),
+		"written", res.Written,
+		"existing", res.Existing,
+		"skipped", res.Skipped,
+		"duration", i.clock.Now().Sub(started),
+	)
+	return res, nil
+}
@@ -0,0 +1,185 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// memImportedMessages is an in-memory ImportedMessageStore.
+type memImportedMessages struct {
+	mu       sync.Mutex
+	counters map[domain.ChatID]uint64
+	messages map[uint64]app.ImportedMessage
+	putErr   error
+}
+
+func newMemImportedMessages() *memImportedMessages {
+	return &memImportedMessages{counters: map[domain.ChatID]uint64{}, messages: map[uint64]app.ImportedMessage{}}
+}
+
+func (s *memImportedMessages) ReserveSequences(_ context.Context, chatID domain.ChatID, n uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.counters[chatID]; ok && cur != n {
+		return domain.ErrAlreadyExists
+	}
+	s.counters[chatID] = n
+	return nil
+}
+
+func (s *memImportedMessages) PutImported(_ context.Context, msg app.ImportedMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.putErr != nil {
+		return s.putErr
+	}
+	if _, ok := s.messages[msg.Sequence]; ok {
+		return domain.ErrAlreadyExists
+	}
+	s.messages[msg.Sequence] = msg
+	return nil
+}
+
+func testChatExport() app.ChatExport {
+	at := func(min int) time.Time { return time.Date(2019, 5, 4, 8, min, 0, 0, time.FixedZone("UTC+2", 2*60*60)) }
+	return app.ChatExport{Format: "whatsapp", Title: "Family", Messages: []app.ExportedMessage{
+		{Sender: "Alice", SentAt: at(0), Text: "Hi all"},
+		{Sender: "Bob", SentAt: at(1)}, // media
+		{Sender: "Carol", SentAt: at(2), Text: "Hello"},
+		{Sender: "Bob", SentAt: at(3), Text: "Hey"},
+		{Sender: "Alice", SentAt: at(4), Text: strings.Repeat("x", domain.MaxMessageSize+1)},
+	}}
+}
+
+func TestConversationImporter_Import(t *testing.T) {
+	ctx := context.Background()
+	chatID := domain.GenerateChatID()
+	alice := domain.GenerateUserID()
+	participants := map[string]domain.UserID{"Alice": alice}
+
+	t.Run("writes messages in export order with original timestamps", func(t *testing.T) {
+		store := newMemImportedMessages()
+		imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store, RatePerSecond: 1_000_000})
+
+		res, err := imp.Import(ctx, chatID, testChatExport(), participants)
+
+		require.NoError(t, err)
+		assert.Equal(t, app.ConversationImportResult{
+			Exported: 5, Written: 3, Skipped: 2, Placeholders: []string{"Bob", "Carol"},
+		}, res)
+		assert.Equal(t, uint64(3), store.counters[chatID])
+
+		first := store.messages[1]
+		assert.Equal(t, alice, first.SenderID)
+		assert.Equal(t, "Hi all", first.Content.Body())
+		assert.Equal(t, time.Date(2019, 5, 4, 6, 0, 0, 0, time.UTC), first.CreatedAt)
+		assert.Equal(t, domain.SystemClientMessageID("import:1"), first.ClientMessageID)
+
+		assert.Equal(t, domain.PlaceholderUserID(chatID, "Carol"), store.messages[2].SenderID)
+		assert.Equal(t, "Carol", store.messages[2].SenderName)
+		assert.Equal(t, domain.PlaceholderUserID(chatID, "Bob"), store.messages[3].SenderID)
+	})
+
+	t.Run("re-running finishes an interrupted import", func(t *testing.T) {
+		store := newMemImportedMessages()
+		imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store, RatePerSecond: 1_000_000})
+		store.putErr = errors.New("throttled")
+		_, err := imp.Import(ctx, chatID, testChatExport(), participants)
+		require.Error(t, err)
+
+		store.putErr = nil
+		res, err := imp.Import(ctx, chatID, testChatExport(), participants)
+		require.NoError(t, err)
+		assert.Equal(t, 3, res.Written)
+
+		_, err = imp.Import(ctx, chatID, app.ChatExport{Messages: testChatExport().Messages[:1]}, participants)
+		require.ErrorIs(t, err, domain.ErrAlreadyExists, "a different export cannot reuse the claimed range")
+	})
+
+	t.Run("a chat with its own messages is rejected", func(t *testing.T) {
+		store := newMemImportedMessages()
+		store.counters[chatID] = 42
+		imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store, RatePerSecond: 1_000_000})
+
+		_, err := imp.Import(ctx, chatID, testChatExport(), participants)
+
+		require.ErrorIs(t, err, domain.ErrAlreadyExists)
+		assert.Empty(t, store.messages)
+	})
+
+	t.Run("existing messages are counted, not rewritten", func(t *testing.T) {
+		store := newMemImportedMessages()
+		imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store, RatePerSecond: 1_000_000})
+		_, err := imp.Import(ctx, chatID, testChatExport(), participants)
+		require.NoError(t, err)
+
+		res, err := imp.Import(ctx, chatID, testChatExport(), participants)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, res.Written)
+		assert.Equal(t, 3, res.Existing)
+	})
+
+	t.Run("canceled import stops before writing", func(t *testing.T) {
+		store := newMemImportedMessages()
+		imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store, RatePerSecond: 1_000_000})
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := imp.Import(cctx, chatID, testChatExport(), participants)
+
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, store.messages)
+	})
+
+	t.Run("writes are paced", func(t *testing.T) {
+		store := newMemImportedMessages()
+		imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store, RatePerSecond: 50})
+
+		start := time.Now()
+		_, err := imp.Import(ctx, chatID, testChatExport(), participants)
+
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond, "three messages at 50/s take at least three 20ms ticks")
+	})
+}
+
+func TestConversationImporter_Plan(t *testing.T) {
+	chatID := domain.GenerateChatID()
+	store := newMemImportedMessages()
+	imp := app.NewConversationImporter(app.ConversationImporterConfig{Store: store})
+
+	msgs, res, err := imp.Plan(chatID, testChatExport(), nil)
+
+	require.NoError(t, err)
+	assert.Len(t, msgs, 3)
+	assert.Equal(t, []string{"Alice", "Bob", "Carol"}, res.Placeholders)
+	assert.Empty(t, store.counters, "planning writes nothing")
+
+	_, _, err = imp.Plan(chatID, app.ChatExport{Messages: make([]app.ExportedMessage, domain.MaxConversationImportMessages+1)}, nil)
+	require.ErrorIs(t, err, domain.ErrInvalidInput)
+}
+
+func TestConversationImporter_PlanUsesMessageIDGenerator(t *testing.T) {
+	ids, err := domain.NewMessageIDGenerator(domain.MessageIDSchemeULID, domain.RealClock{})
+	require.NoError(t, err)
+	imp := app.NewConversationImporter(app.ConversationImporterConfig{MessageIDs: ids})
+
+	msgs, _, err := imp.Plan(domain.GenerateChatID(), testChatExport(), nil)
+
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	for i := 1; i < len(msgs); i++ {
+		assert.Less(t, msgs[i-1].MessageID.String(), msgs[i].MessageID.String(), "ULIDs follow import order")
+	}
+}
@@ -0,0 +1,248 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var tracer = otel.Tracer("ingest/app")
+
+var (
+	persistDuration    metric.Float64Histogram
+	sequencesAllocated metric.Int64Counter
+	idempotencyHits    metric.Int64Counter
+)
+
+func init() {
+	m := otel.Meter("ingest/app")
+	persistDuration, _ = m.Float64Histogram("durability_persist_duration_seconds",
+		metric.WithDescription("Time to persist a message, by outcome (persisted, duplicate, error)"),
+		metric.WithUnit("s"))
+	sequencesAllocated, _ = m.Int64Counter("durability_sequence_allocated_total",
+		metric.WithDescription("Chat sequences allocated; allocations never written show up as gaps"))
+	idempotencyHits, _ = m.Int64Counter("durability_idempotency_hit_total",
+		metric.WithDescription("Persist requests answered from an earlier result for the same client message ID"))
+}
+
+// PersistRequest is a message to persist, as received by PersistMessage.
+// ReceivedAt is the gateway's receive time; there is deliberately no client
+// timestamp, since messages are ordered by the sequence persistence assigns.
+type PersistRequest struct {
+	ChatID          domain.ChatID
+	SenderID        domain.UserID
+	ClientMessageID string // idempotency key, unique per chat for the client
+	Content         domain.MessageContent
+	ReceivedAt      domain.ServerTime
+}
+
+// PersistResult is the outcome of persisting a message.
+type PersistResult struct {
+	MessageID domain.MessageID
+	Sequence  uint64 // per-chat, monotonically increasing
+	CreatedAt time.Time
+	Duplicate bool // an earlier request with the same ClientMessageID won
+}
+
+// Persister stores a message and assigns its sequence (ADR-004). Adapters
+// implement it per storage schema; ShadowPersister composes two of them.
+type Persister interface {
+	Persist(ctx context.Context, req PersistRequest) (PersistResult, error)
+}
+
+// IdempotencyStore holds the result of every persisted client message ID
+// (ADR-001 §3).
+type IdempotencyStore interface {
+	// Lookup returns the result stored for clientMessageID in chatID. ok
+	// is false if there is none.
+	Lookup(ctx context.Context, chatID domain.ChatID, clientMessageID string) (res PersistResult, ok bool, err error)
+}
+
+// MembershipReader checks that a sender may post to a chat. Reads must be
+// strongly consistent: Ingest is the write authority (ADR-013).
+type MembershipReader interface {
+	IsMember(ctx context.Context, chatID domain.ChatID, userID domain.UserID) (bool, error)
+}
+
+// SequenceAllocator hands out a chat's sequences (ADR-004). An allocated
+// sequence that is never written is a gap, which clients tolerate.
+type SequenceAllocator interface {
+	Allocate(ctx context.Context, chatID domain.ChatID) (uint64, error)
+}
+
+// MessageWriter stores a message together with its idempotency record, in
+// one transaction. It returns domain.ErrDuplicateMessage if the client
+// message ID was stored first by a concurrent request.
+type MessageWriter interface {
+	Write(ctx context.Context, req PersistRequest, res PersistResult) error
+}
+
+// PersistedPublisher publishes a newly persisted message to
+// messages.persisted for Fanout.
+type PersistedPublisher interface {
+	Publish(ctx context.Context, req PersistRequest, res PersistResult) error
+}
+
+// PersistServiceConfig holds the dependencies for PersistService.
+type PersistServiceConfig struct {
+	Idempotency IdempotencyStore
+	Members     MembershipReader
+	Sequences   SequenceAllocator
+	Messages    MessageWriter
+	Publisher   PersistedPublisher // nil skips publication, as on a shadow path
+	Clock       domain.Clock       // nil defaults to domain.RealClock
+
+	// MessageIDs generates new messages' IDs. Nil defaults to UUIDs
+	// (domain.MessageIDSchemeUUID).
+	MessageIDs domain.MessageIDGenerator
+}
+
+// PersistService is the ADR-004 persist flow: idempotency check,
+// membership check, sequence allocation, a transactional write of the
+// message and its idempotency record, then publication to Kafka. It is the
+// Persister at the bottom of Ingest's chain.
+//
+// A message is published only once it is stored, so an event never
+// precedes its record. If publishing fails the error is returned, but the
+// message stays persisted: the sender's retry is a duplicate and is not
+// published again, and members pick the message up on sync.
+type PersistService struct {
+	idempotency IdempotencyStore
+	members     MembershipReader
+	sequences   SequenceAllocator
+	messages    MessageWriter
+	publisher   PersistedPublisher
+	clock       domain.Clock
+	ids         domain.MessageIDGenerator
+}
+
+// NewPersistService creates a PersistService.
+func NewPersistService(cfg PersistServiceConfig) *PersistService {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	ids := cfg.MessageIDs
+	if ids == nil {
+		ids, _ = domain.NewMessageIDGenerator(domain.MessageIDSchemeUUID, clock)
+	}
+	return &PersistService{
+		idempotency: cfg.Idempotency,
+		members:     cfg.Members,
+		sequences:   cfg.Sequences,
+		messages:    cfg.Messages,
+		publisher:   cfg.Publisher,
+		clock:       clock,
+		ids:         ids,
+	}
+}
+
+// Persist runs the five steps for req. A client message ID that was
+// already persisted returns the original result with Duplicate set. System
+// messages (no sender) skip the membership check.
+func (s *PersistService) Persist(ctx context.Context, req PersistRequest) (PersistResult, error) {
+	ctx, span := tracer.Start(ctx, "persist_message")
+	defer span.End()
+	start := s.clock.Now()
+
+	res, err := s.persist(ctx, req)
+	persistDuration.Record(ctx, s.clock.Now().Sub(start).Seconds(),
+		metric.WithAttributes(attribute.String("outcome", persistOutcome(res, err))))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return PersistResult{}, err
+	}
+	span.SetAttributes(
+		attribute.Int64("message.sequence", int64(res.Sequence)), //nolint:gosec // sequences fit in int64
+		attribute.Bool("message.duplicate", res.Duplicate),
+	)
+	return res, nil
+}
+
+func (s *PersistService) persist(ctx context.Context, req PersistRequest) (PersistResult, error) {
+	// 1. Idempotency check.
+	if res, ok, err := s.duplicate(ctx, req); err != nil || ok {
+		return res, err
+	}
+
+	// 2. Membership check.
+	if !req.SenderID.IsZero() {
+		ok, err := s.members.IsMember(ctx, req.ChatID, req.SenderID)
+		if err != nil {
+			return PersistResult{}, fmt.Errorf("persist: membership: %w", err)
+		}
+		if !ok {
+			return PersistResult{}, fmt.Errorf("persist to %s: %w", req.ChatID, domain.ErrNotMember)
+		}
+	}
+
+	// 3. Sequence allocation.
+	seq, err := s.sequences.Allocate(ctx, req.ChatID)
+	if err != nil {
+		return PersistResult{}, fmt.Errorf("persist: allocate sequence: %w", err)
+	}
+	sequencesAllocated.Add(ctx, 1)
+
+	// 4. Message and idempotency record, atomically. Losing the race to a
+	// concurrent retry leaves seq as a gap and returns the winner.
+	res := PersistResult{
+		MessageID: s.ids.NewMessageID(),
+		Sequence:  seq,
+		CreatedAt: domain.FromMillis(domain.NowUTCMillis(s.clock)),
+	}
+	if err := s.messages.Write(ctx, req, res); err != nil {
+		if errors.Is(err, domain.ErrDuplicateMessage) {
+			if res, ok, lookupErr := s.duplicate(ctx, req); lookupErr != nil || ok {
+				return res, lookupErr
+			}
+		}
+		return PersistResult{}, fmt.Errorf("persist: write: %w", err)
+	}
+
+	// 5. Publication.
+	if s.publisher == nil {
+		return res, nil
+	}
+	if err := s.publisher.Publish(ctx, req, res); err != nil {
+		return PersistResult{}, fmt.Errorf("persist: publish: %w", err)
+	}
+	return res, nil
+}
+
+// duplicate returns the stored result for req's client message ID, if any.
+func (s *PersistService) duplicate(ctx context.Context, req PersistRequest) (PersistResult, bool, error) {
+	res, ok, err := s.idempotency.Lookup(ctx, req.ChatID, req.ClientMessageID)
+	if err != nil {
+		return PersistResult{}, false, fmt.Errorf("persist: idempotency lookup: %w", err)
+	}
+	if !ok {
+		return PersistResult{}, false, nil
+	}
+	idempotencyHits.Add(ctx, 1)
+	res.Duplicate = true
+	return res, true, nil
+}
+
+// persistOutcome labels a Persist call for metrics.
+func persistOutcome(res PersistResult, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case res.Duplicate:
+		return "duplicate"
+	default:
+		return "persisted"
+	}
+}
+
+// Compile-time interface check.
+var _ Persister = (*PersistService)(nil)
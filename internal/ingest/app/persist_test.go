@@ -0,0 +1,421 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// memoryPersister allocates sequences the way the chat_counters table does
+// (ADR-004): one counter per chat, claimed together with the idempotency
+// record, so a retried client message ID returns the original result.
+type memoryPersister struct {
+	mu      sync.Mutex
+	counter map[domain.ChatID]uint64
+	results map[string]app.PersistResult // by chat and client message ID
+}
+
+func newMemoryPersister() *memoryPersister {
+	return &memoryPersister{counter: map[domain.ChatID]uint64{}, results: map[string]app.PersistResult{}}
+}
+
+func (p *memoryPersister) Persist(_ context.Context, req app.PersistRequest) (app.PersistResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := req.ChatID.String() + "/" + req.ClientMessageID
+	if res, ok := p.results[key]; ok {
+		res.Duplicate = true
+		return res, nil
+	}
+	p.counter[req.ChatID]++
+	res := app.PersistResult{
+		MessageID: domain.GenerateMessageID(),
+		Sequence:  p.counter[req.ChatID],
+		CreatedAt: req.ReceivedAt.Time(),
+	}
+	p.results[key] = res
+	return res, nil
+}
+
+// send is one scripted Persist call.
+type send struct {
+	chat, sender, message int
+	fail                  bool // the store fails the write
+}
+
+// sendOutcome is the result of one send.
+type sendOutcome struct {
+	send
+	res app.PersistResult
+	err error
+}
+
+// TestPersister_SequencesUniqueAndGapFree runs concurrent senders, with
+// retries, slow mode and store failures, through the SlowModePersister in
+// front of the sequence allocator. Whatever the interleaving, every chat's
+// persisted messages hold the sequences 1..n exactly once, every retry gets
+// its original result, and neither a rejected nor a failed send takes a
+// sequence.
+func TestPersister_SequencesUniqueAndGapFree(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		chats := make([]domain.ChatID, rapid.IntRange(1, 3).Draw(t, "chats"))
+		slowMode := make([]int, len(chats))
+		for i := range chats {
+			chats[i] = domain.GenerateChatID()
+			slowMode[i] = rapid.SampledFrom([]int{0, 30}).Draw(t, fmt.Sprintf("slow_mode_%d", i))
+		}
+		senders := make([]domain.UserID, rapid.IntRange(1, 6).Draw(t, "senders"))
+		roles := make([]domain.MemberRole, len(senders))
+		for i := range senders {
+			senders[i] = domain.GenerateUserID()
+			roles[i] = rapid.SampledFrom([]domain.MemberRole{domain.MemberRoleMember, domain.MemberRoleAdmin}).Draw(t, fmt.Sprintf("role_%d", i))
+		}
+		scripts := make([][]send, len(senders))
+		for s := range senders {
+			scripts[s] = rapid.SliceOfN(rapid.Custom(func(t *rapid.T) send {
+				return send{
+					chat:    rapid.IntRange(0, len(chats)-1).Draw(t, "chat"),
+					sender:  s,
+					message: rapid.IntRange(0, 4).Draw(t, "message"),
+					fail:    rapid.IntRange(0, 9).Draw(t, "fail") == 0,
+				}
+			}), 1, 20).Draw(t, fmt.Sprintf("script_%d", s))
+		}
+
+		chatIndex := map[domain.ChatID]int{}
+		for i, c := range chats {
+			chatIndex[c] = i
+		}
+		senderIndex := map[domain.UserID]int{}
+		for i, s := range senders {
+			senderIndex[s] = i
+		}
+		policy := policyFunc(func(_ context.Context, chatID domain.ChatID, senderID domain.UserID) (app.ChatPolicy, error) {
+			return app.ChatPolicy{SlowModeSeconds: slowMode[chatIndex[chatID]], Role: roles[senderIndex[senderID]]}, nil
+		})
+		allocator := newMemoryPersister()
+		store := persisterFunc(func(ctx context.Context, req app.PersistRequest) (app.PersistResult, error) {
+			if req.Content.Body() == "fail" {
+				return app.PersistResult{}, domain.ErrUnavailable
+			}
+			return allocator.Persist(ctx, req)
+		})
+		p, _ := newSlowModePersister(store, policy, newMemSlowModeStore())
+
+		// Every send is received a millisecond after the previous one, all
+		// within one slow mode interval.
+		start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC).UnixMilli()
+		var clock atomic.Int64
+		outcomes := make([][]sendOutcome, len(scripts))
+		var wg sync.WaitGroup
+		for s, script := range scripts {
+			wg.Go(func() {
+				for _, sd := range script {
+					body := "hello"
+					if sd.fail {
+						body = "fail"
+					}
+					res, err := p.Persist(context.Background(), app.PersistRequest{
+						ChatID:          chats[sd.chat],
+						SenderID:        senders[sd.sender],
+						ClientMessageID: fmt.Sprintf("s%d-m%d", sd.sender, sd.message),
+						Content:         domain.MustMessageContent(domain.ContentTypeText, body),
+						ReceivedAt:      domain.ServerTimeFromMillis(start + clock.Add(1)),
+					})
+					outcomes[s] = append(outcomes[s], sendOutcome{send: sd, res: res, err: err})
+				}
+			})
+		}
+		wg.Wait()
+
+		type key struct{ chat, sender, message int }
+		first := map[key]app.PersistResult{}
+		originals := map[key]int{}
+		sequences := make([]map[uint64]bool, len(chats))
+		for i := range sequences {
+			sequences[i] = map[uint64]bool{}
+		}
+		persistedBy := map[[2]int]map[int]bool{} // chat, sender -> messages
+		for _, script := range outcomes {
+			for _, o := range script {
+				switch {
+				case o.err == nil:
+				case errors.Is(o.err, domain.ErrSlowMode) && slowMode[o.chat] > 0 && roles[o.sender] == domain.MemberRoleMember:
+					continue
+				case errors.Is(o.err, domain.ErrUnavailable) && o.fail:
+					continue
+				default:
+					t.Fatalf("unexpected error: %v", o.err)
+				}
+
+				k := key{o.chat, o.sender, o.message}
+				if prev, ok := first[k]; ok {
+					if prev.Sequence != o.res.Sequence || prev.MessageID != o.res.MessageID {
+						t.Fatalf("retry of %v got sequence %d, first got %d", k, o.res.Sequence, prev.Sequence)
+					}
+				} else {
+					first[k] = o.res
+				}
+				if !o.res.Duplicate {
+					originals[k]++
+					if sequences[o.chat][o.res.Sequence] {
+						t.Fatalf("chat %d: sequence %d allocated twice", o.chat, o.res.Sequence)
+					}
+					sequences[o.chat][o.res.Sequence] = true
+				}
+				cs := [2]int{o.chat, o.sender}
+				if persistedBy[cs] == nil {
+					persistedBy[cs] = map[int]bool{}
+				}
+				persistedBy[cs][o.message] = true
+			}
+		}
+
+		for k, n := range originals {
+			if n != 1 {
+				t.Fatalf("%v persisted %d times", k, n)
+			}
+		}
+		for c, seqs := range sequences {
+			for seq := uint64(1); seq <= uint64(len(seqs)); seq++ {
+				if !seqs[seq] {
+					t.Fatalf("chat %d: gap at sequence %d of %d", c, seq, len(seqs))
+				}
+			}
+		}
+		for cs, messages := range persistedBy {
+			if slowMode[cs[0]] > 0 && roles[cs[1]] == domain.MemberRoleMember && len(messages) > 1 {
+				t.Fatalf("chat %d: sender %d persisted %d messages within one slow mode interval", cs[0], cs[1], len(messages))
+			}
+		}
+	})
+}
+
+// persistStore is the DynamoDB side of the persist flow in memory: the
+// chat counters, and the messages with their idempotency records.
+type persistStore struct {
+	mu       sync.Mutex
+	counters map[domain.ChatID]uint64
+	results  map[string]app.PersistResult // by chat and client message ID
+	written  []app.PersistResult
+	members  map[domain.UserID]bool
+
+	// beforeWrite runs before a Write stores its records, to interleave a
+	// concurrent request.
+	beforeWrite func()
+}
+
+func newPersistStore(members ...domain.UserID) *persistStore {
+	s := &persistStore{
+		counters: map[domain.ChatID]uint64{},
+		results:  map[string]app.PersistResult{},
+		members:  map[domain.UserID]bool{},
+	}
+	for _, m := range members {
+		s.members[m] = true
+	}
+	return s
+}
+
+func (s *persistStore) Lookup(_ context.Context, chatID domain.ChatID, clientMessageID string) (app.PersistResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.results[chatID.String()+"/"+clientMessageID]
+	return res, ok, nil
+}
+
+func (s *persistStore) IsMember(_ context.Context, _ domain.ChatID, userID domain.UserID) (bool, error) {
+	return s.members[userID], nil
+}
+
+func (s *persistStore) Allocate(_ context.Context, chatID domain.ChatID) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[chatID]++
+	return s.counters[chatID], nil
+}
+
+func (s *persistStore) Write(_ context.Context, req app.PersistRequest, res app.PersistResult) error {
+	if s.beforeWrite != nil {
+		s.beforeWrite()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := req.ChatID.String() + "/" + req.ClientMessageID
+	if _, ok := s.results[key]; ok {
+		return domain.ErrDuplicateMessage
+	}
+	s.results[key] = res
+	s.written = append(s.written, res)
+	return nil
+}
+
+// publisherFunc adapts a function to app.PersistedPublisher.
+type publisherFunc func(ctx context.Context, req app.PersistRequest, res app.PersistResult) error
+
+func (f publisherFunc) Publish(ctx context.Context, req app.PersistRequest, res app.PersistResult) error {
+	return f(ctx, req, res)
+}
+
+func newPersistService(store *persistStore, publish publisherFunc) *app.PersistService {
+	return app.NewPersistService(app.PersistServiceConfig{
+		Idempotency: store,
+		Members:     store,
+		Sequences:   store,
+		Messages:    store,
+		Publisher:   publish,
+	})
+}
+
+func TestPersistService_Persist(t *testing.T) {
+	ctx := context.Background()
+	chat := domain.GenerateChatID()
+
+	t.Run("persists and publishes a new message", func(t *testing.T) {
+		req := request(chat)
+		store := newPersistStore(req.SenderID)
+		var published []app.PersistResult
+		svc := newPersistService(store, func(_ context.Context, _ app.PersistRequest, res app.PersistResult) error {
+			published = append(published, res)
+			return nil
+		})
+
+		first, err := svc.Persist(ctx, req)
+		require.NoError(t, err)
+		second, err := svc.Persist(ctx, app.PersistRequest{
+			ChatID: chat, SenderID: req.SenderID, ClientMessageID: "client-msg-2", Content: req.Content,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(1), first.Sequence)
+		assert.Equal(t, uint64(2), second.Sequence)
+		assert.False(t, first.MessageID.IsZero())
+		assert.False(t, first.Duplicate)
+		assert.Equal(t, []app.PersistResult{first, second}, published)
+	})
+
+	t.Run("a retried client message ID returns the original without publishing", func(t *testing.T) {
+		req := request(chat)
+		store := newPersistStore(req.SenderID)
+		publishes := 0
+		svc := newPersistService(store, func(context.Context, app.PersistRequest, app.PersistResult) error {
+			publishes++
+			return nil
+		})
+
+		first, err := svc.Persist(ctx, req)
+		require.NoError(t, err)
+		retry, err := svc.Persist(ctx, req)
+		require.NoError(t, err)
+
+		assert.True(t, retry.Duplicate)
+		assert.Equal(t, first.MessageID, retry.MessageID)
+		assert.Equal(t, first.Sequence, retry.Sequence)
+		assert.Equal(t, 1, publishes)
+		assert.Len(t, store.written, 1)
+	})
+
+	t.Run("rejects a sender who is not a member", func(t *testing.T) {
+		store := newPersistStore()
+		svc := newPersistService(store, func(context.Context, app.PersistRequest, app.PersistResult) error {
+			t.Fatal("published a rejected message")
+			return nil
+		})
+
+		_, err := svc.Persist(ctx, request(chat))
+
+		require.ErrorIs(t, err, domain.ErrNotMember)
+		assert.Zero(t, store.counters[chat], "no sequence allocated")
+	})
+
+	t.Run("system messages skip the membership check", func(t *testing.T) {
+		store := newPersistStore()
+		svc := newPersistService(store, func(context.Context, app.PersistRequest, app.PersistResult) error { return nil })
+		req := request(chat)
+		req.SenderID = domain.UserID{}
+
+		res, err := svc.Persist(ctx, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), res.Sequence)
+	})
+
+	t.Run("losing the write race returns the winner and leaves a gap", func(t *testing.T) {
+		req := request(chat)
+		store := newPersistStore(req.SenderID)
+		winner := app.PersistResult{MessageID: domain.GenerateMessageID(), Sequence: 1}
+		store.beforeWrite = func() {
+			store.beforeWrite = nil
+			store.counters[chat]++ // the winner's allocation
+			require.NoError(t, store.Write(ctx, req, winner))
+		}
+		svc := newPersistService(store, func(context.Context, app.PersistRequest, app.PersistResult) error {
+			t.Fatal("published the losing request")
+			return nil
+		})
+
+		res, err := svc.Persist(ctx, req)
+
+		require.NoError(t, err)
+		assert.True(t, res.Duplicate)
+		assert.Equal(t, winner.MessageID, res.MessageID)
+		assert.Equal(t, uint64(2), store.counters[chat], "the loser's sequence is a gap")
+	})
+
+	t.Run("a publish failure is returned and the message stays persisted", func(t *testing.T) {
+		req := request(chat)
+		store := newPersistStore(req.SenderID)
+		svc := newPersistService(store, func(context.Context, app.PersistRequest, app.PersistResult) error {
+			return domain.ErrUnavailable
+		})
+
+		_, err := svc.Persist(ctx, req)
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+
+		retry, err := svc.Persist(ctx, req)
+		require.NoError(t, err)
+		assert.True(t, retry.Duplicate)
+	})
+
+	t.Run("a nil publisher only persists", func(t *testing.T) {
+		req := request(chat)
+		store := newPersistStore(req.SenderID)
+		svc := app.NewPersistService(app.PersistServiceConfig{
+			Idempotency: store, Members: store, Sequences: store, Messages: store,
+		})
+
+		res, err := svc.Persist(ctx, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, []app.PersistResult{res}, store.written)
+	})
+
+	t.Run("uses the configured message ID scheme", func(t *testing.T) {
+		req := request(chat)
+		store := newPersistStore(req.SenderID)
+		ids, err := domain.NewMessageIDGenerator(domain.MessageIDSchemeULID, domain.RealClock{})
+		require.NoError(t, err)
+		svc := app.NewPersistService(app.PersistServiceConfig{
+			Idempotency: store, Members: store, Sequences: store, Messages: store,
+			Publisher:  publisherFunc(func(context.Context, app.PersistRequest, app.PersistResult) error { return nil }),
+			MessageIDs: ids,
+		})
+
+		res, err := svc.Persist(ctx, req)
+
+		require.NoError(t, err)
+		_, ok := res.MessageID.Time()
+		assert.True(t, ok, "ULID message IDs carry their creation time")
+	})
+}
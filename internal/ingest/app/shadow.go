@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	shadowComparisons metric.Int64Counter
+
+	shadowMatchAttr    = metric.WithAttributes(attribute.String("result", "match"))
+	shadowDivergedAttr = metric.WithAttributes(attribute.String("result", "diverged"))
+	shadowErrorAttr    = metric.WithAttributes(attribute.String("result", "error"))
+	shadowSkippedAttr  = metric.WithAttributes(attribute.String("result", "skipped"))
+)
+
+func init() {
+	shadowComparisons, _ = otel.Meter("ingest/app").Int64Counter("ingest_shadow_comparisons_total",
+		metric.WithDescription("Shadow writes compared with the primary, by result (match, diverged, error, skipped)"))
+}
+
+// CompareFunc describes how a shadow result differs from the primary result,
+// or returns "" when they agree.
+type CompareFunc func(primary, shadow PersistResult) string
+
+// CompareSequence is the default CompareFunc. It compares the assigned
+// sequence and the idempotency outcome; message IDs and timestamps are
+// assigned independently by each path and are ignored.
+func CompareSequence(primary, shadow PersistResult) string {
+	switch {
+	case primary.Sequence != shadow.Sequence:
+		return fmt.Sprintf("sequence %d != %d", primary.Sequence, shadow.Sequence)
+	case primary.Duplicate != shadow.Duplicate:
+		return fmt.Sprintf("duplicate %t != %t", primary.Duplicate, shadow.Duplicate)
+	}
+	return ""
+}
+
+// ShadowPersisterConfig holds configuration for creating a ShadowPersister.
+type ShadowPersisterConfig struct {
+	Primary Persister
+	Shadow  Persister
+	// Percent is the share of chats, in [0, 100], whose writes are also
+	// sent to Shadow.
+	Percent     float64
+	Timeout     time.Duration // zero defaults to domain.ShadowTimeout
+	MaxInFlight int           // zero defaults to domain.ShadowMaxInFlight
+	Compare     CompareFunc   // nil defaults to CompareSequence
+	Logger      *slog.Logger
+}
+
+// ShadowPersister persists through Primary and, for a sampled share of
+// chats, repeats each successful write against Shadow in the background and
+// compares the results. Callers only ever see the primary result: shadow
+// writes cannot fail, slow down or cancel a request. Sampling is by chat,
+// not by message, so the shadow path sees every message of a sampled chat
+// and assigns comparable sequences. When MaxInFlight shadow writes are
+// already running, further ones are skipped rather than queued.
+type ShadowPersister struct {
+	primary   Persister
+	shadow    Persister
+	threshold uint64 // chats hashing below this, out of shadowBuckets, are sampled
+	timeout   time.Duration
+	compare   CompareFunc
+	logger    *slog.Logger
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// shadowBuckets is the sampling resolution: 0.01% of chats.
+const shadowBuckets = 10_000
+
+// NewShadowPersister creates a ShadowPersister.
+func NewShadowPersister(cfg ShadowPersisterConfig) *ShadowPersister {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = domain.ShadowTimeout
+	}
+	maxInFlight := cfg.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = domain.ShadowMaxInFlight
+	}
+	compare := cfg.Compare
+	if compare == nil {
+		compare = CompareSequence
+	}
+	return &ShadowPersister{
+		primary:   cfg.Primary,
+		shadow:    cfg.Shadow,
+		threshold: uint64(min(max(cfg.Percent, 0), 100) * shadowBuckets / 100),
+		timeout:   timeout,
+		compare:   compare,
+		logger:    cfg.Logger,
+		slots:     make(chan struct{}, maxInFlight),
+	}
+}
+
+// Persist persists req through the primary path and returns its result.
+func (p *ShadowPersister) Persist(ctx context.Context, req PersistRequest) (PersistResult, error) {
+	res, err := p.primary.Persist(ctx, req)
+	if err != nil || !p.sampled(req.ChatID) {
+		return res, err
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		shadowComparisons.Add(ctx, 1, shadowSkippedAttr)
+		return res, nil
+	}
+	p.wg.Add(1)
+	go p.runShadow(context.WithoutCancel(ctx), req, res)
+	return res, nil
+}
+
+// Close waits for in-flight shadow writes to finish.
+func (p *ShadowPersister) Close() {
+	p.wg.Wait()
+}
+
+func (p *ShadowPersister) sampled(chatID domain.ChatID) bool {
+	if p.threshold == 0 {
+		return false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(chatID.String()))
+	return h.Sum64()%shadowBuckets < p.threshold
+}
+
+func (p *ShadowPersister) runShadow(ctx context.Context, req PersistRequest, primary PersistResult) {
+	defer p.wg.Done()
+	defer func() { <-p.slots }()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	attrs := []slog.Attr{
+		slog.String("chat_id", req.ChatID.String()),
+		slog.String("client_message_id", req.ClientMessageID),
+	}
+	shadow, err := p.shadow.Persist(ctx, req)
+	if err != nil {
+		shadowComparisons.Add(ctx, 1, shadowErrorAttr)
+		p.logger.LogAttrs(ctx, slog.LevelWarn, "shadow write failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+	if diff := p.compare(primary, shadow); diff != "" {
+		shadowComparisons.Add(ctx, 1, shadowDivergedAttr)
+		p.logger.LogAttrs(ctx, slog.LevelWarn, "shadow write diverged", append(attrs, slog.String("diff", diff))...)
+		return
+	}
+	shadowComparisons.Add(ctx, 1, shadowMatchAttr)
+}
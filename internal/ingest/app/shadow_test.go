@@ -0,0 +1,207 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// persisterFunc adapts a function to app.Persister.
+type persisterFunc func(ctx context.Context, req app.PersistRequest) (app.PersistResult, error)
+
+func (f persisterFunc) Persist(ctx context.Context, req app.PersistRequest) (app.PersistResult, error) {
+	return f(ctx, req)
+}
+
+func sequencer(seq uint64) persisterFunc {
+	return func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+		return app.PersistResult{MessageID: domain.GenerateMessageID(), Sequence: seq}, nil
+	}
+}
+
+// countingShadow returns seq from every call and counts the calls.
+func countingShadow(seq uint64, calls *atomic.Int64) persisterFunc {
+	return func(ctx context.Context, req app.PersistRequest) (app.PersistResult, error) {
+		calls.Add(1)
+		return sequencer(seq)(ctx, req)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of shadow
+// goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newShadowPersister(cfg app.ShadowPersisterConfig) (*app.ShadowPersister, *syncBuffer) {
+	logs := &syncBuffer{}
+	cfg.Logger = slog.New(slog.NewTextHandler(logs, nil))
+	return app.NewShadowPersister(cfg), logs
+}
+
+func request(chat domain.ChatID) app.PersistRequest {
+	return app.PersistRequest{
+		ChatID:          chat,
+		SenderID:        domain.GenerateUserID(),
+		ClientMessageID: "client-msg-1",
+		Content:         domain.MustMessageContent(domain.ContentTypeText, "hello"),
+	}
+}
+
+func TestShadowPersister_Compares(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		shadow   persisterFunc
+		wantLogs string
+	}{
+		{name: "match", shadow: sequencer(7)},
+		{name: "diverged", shadow: sequencer(8), wantLogs: "shadow write diverged"},
+		{
+			name: "shadow error",
+			shadow: func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+				return app.PersistResult{}, errors.New("table missing")
+			},
+			wantLogs: "shadow write failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, logs := newShadowPersister(app.ShadowPersisterConfig{
+				Primary: sequencer(7),
+				Shadow:  tt.shadow,
+				Percent: 100,
+			})
+
+			chat := domain.GenerateChatID()
+			res, err := p.Persist(ctx, request(chat))
+			p.Close()
+
+			require.NoError(t, err)
+			assert.Equal(t, uint64(7), res.Sequence, "caller sees the primary result")
+			if tt.wantLogs == "" {
+				assert.Empty(t, logs.String())
+				return
+			}
+			assert.Contains(t, logs.String(), tt.wantLogs)
+			assert.Contains(t, logs.String(), "chat_id="+chat.String())
+		})
+	}
+}
+
+func TestShadowPersister_PrimaryErrorIsNotShadowed(t *testing.T) {
+	var calls atomic.Int64
+	p, _ := newShadowPersister(app.ShadowPersisterConfig{
+		Primary: persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			return app.PersistResult{}, domain.ErrUnavailable
+		}),
+		Shadow:  countingShadow(1, &calls),
+		Percent: 100,
+	})
+
+	_, err := p.Persist(context.Background(), request(domain.GenerateChatID()))
+	p.Close()
+
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Zero(t, calls.Load())
+}
+
+func TestShadowPersister_SamplesByChat(t *testing.T) {
+	ctx := context.Background()
+
+	for _, percent := range []float64{0, 25, 100} {
+		t.Run(fmt.Sprint(percent), func(t *testing.T) {
+			var calls atomic.Int64
+			p, _ := newShadowPersister(app.ShadowPersisterConfig{
+				Primary: sequencer(1),
+				Shadow:  countingShadow(1, &calls),
+				Percent: percent,
+			})
+
+			const chats = 2000
+			sampled := make(map[domain.ChatID]bool)
+			for range chats {
+				chat := domain.GenerateChatID()
+				before := calls.Load()
+				_, err := p.Persist(ctx, request(chat))
+				require.NoError(t, err)
+				p.Close()
+				sampled[chat] = calls.Load() > before
+			}
+			assert.InDelta(t, percent/100*chats, float64(calls.Load()), chats*0.05)
+
+			// Every message of a sampled chat is shadowed, and none of an
+			// unsampled one.
+			for chat, want := range sampled {
+				before := calls.Load()
+				_, err := p.Persist(ctx, request(chat))
+				require.NoError(t, err)
+				p.Close()
+				assert.Equal(t, want, calls.Load() > before, chat.String())
+			}
+		})
+	}
+}
+
+func TestShadowPersister_IsolatedFromRequest(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int64
+	var deadlineSet, canceled atomic.Bool
+	p, logs := newShadowPersister(app.ShadowPersisterConfig{
+		Primary: sequencer(1),
+		Shadow: persisterFunc(func(ctx context.Context, _ app.PersistRequest) (app.PersistResult, error) {
+			calls.Add(1)
+			_, ok := ctx.Deadline()
+			deadlineSet.Store(ok)
+			<-release
+			canceled.Store(ctx.Err() != nil)
+			return app.PersistResult{Sequence: 1}, nil
+		}),
+		Percent:     100,
+		MaxInFlight: 1,
+	})
+
+	chat := domain.GenerateChatID()
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := p.Persist(ctx, request(chat))
+	require.NoError(t, err)
+	cancel()
+
+	// The only slot is taken, so this write is not shadowed.
+	_, err = p.Persist(context.Background(), request(chat))
+	require.NoError(t, err)
+
+	close(release)
+	p.Close()
+
+	assert.Equal(t, int64(1), calls.Load())
+	assert.True(t, deadlineSet.Load(), "shadow writes are bounded by the shadow timeout")
+	assert.False(t, canceled.Load(), "canceling the request does not cancel its shadow")
+	assert.Empty(t, logs.String())
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var slowModeRejections metric.Int64Counter
+
+func init() {
+	slowModeRejections, _ = otel.Meter("ingest/app").Int64Counter("ingest_slow_mode_rejections_total",
+		metric.WithDescription("Messages rejected because the sender posted within the chat's slow mode interval"))
+}
+
+// ChatPolicy is what ingest needs to know about a chat and its sender to
+// enforce slow mode.
+type ChatPolicy struct {
+	SlowModeSeconds int
+	Role            domain.MemberRole // the sender's role in the chat
+}
+
+// ChatPolicyReader reads a chat's slow mode setting and the sender's role.
+type ChatPolicyReader interface {
+	ChatPolicy(ctx context.Context, chatID domain.ChatID, senderID domain.UserID) (ChatPolicy, error)
+}
+
+// SlowModeStore records when each member of a chat last posted.
+type SlowModeStore interface {
+	// Claim records a post by senderID at now unless their previous post
+	// was less than interval ago, in which case it returns the remaining
+	// wait and records nothing. A claim repeated with the same
+	// clientMessageID succeeds, so a retried send is not throttled by its
+	// own first attempt.
+	Claim(ctx context.Context, chatID domain.ChatID, senderID domain.UserID, clientMessageID string, interval time.Duration, now time.Time) (time.Duration, error)
+	// Release undoes a Claim made at now for clientMessageID, if it is
+	// still the latest.
+	Release(ctx context.Context, chatID domain.ChatID, senderID domain.UserID, clientMessageID string, now time.Time) error
+}
+
+// SlowModePersisterConfig holds configuration for creating a
+// SlowModePersister.
+type SlowModePersisterConfig struct {
+	Next     Persister
+	Policies ChatPolicyReader
+	Store    SlowModeStore
+	Logger   *slog.Logger
+}
+
+// SlowModePersister enforces per-chat slow mode in front of Next: a member
+// who posted less than the chat's SlowModeSeconds ago is rejected with a
+// *domain.SlowModeError carrying the remaining wait. Admins, the owner and
+// system messages are exempt.
+//
+// Slow mode is a pacing control, not a security boundary, so it fails
+// open: if the policy or the store cannot be read, the message goes
+// through and the failure is logged.
+type SlowModePersister struct {
+	next     Persister
+	policies ChatPolicyReader
+	store    SlowModeStore
+	logger   *slog.Logger
+}
+
+// NewSlowModePersister creates a SlowModePersister.
+func NewSlowModePersister(cfg SlowModePersisterConfig) *SlowModePersister {
+	return &SlowModePersister{
+		next:     cfg.Next,
+		policies: cfg.Policies,
+		store:    cfg.Store,
+		logger:   cfg.Logger,
+	}
+}
+
+// Persist checks slow mode and, if the sender may post, persists through
+// Next. A message that fails to persist gives back its claim so the
+// sender's retry is not throttled.
+func (p *SlowModePersister) Persist(ctx context.Context, req PersistRequest) (PersistResult, error) {
+	claimed, err := p.claim(ctx, req)
+	if err != nil {
+		return PersistResult{}, err
+	}
+
+	res, err := p.next.Persist(ctx, req)
+	if err != nil && claimed {
+		if relErr := p.store.Release(ctx, req.ChatID, req.SenderID, req.ClientMessageID, req.ReceivedAt.Time()); relErr != nil {
+			p.logger.WarnContext(ctx, "slow_mode.release_failed", "chat_id", req.ChatID.String(), "error", relErr)
+		}
+	}
+	return res, err
+}
+
+// claim reports whether a slow mode claim was recorded for req, or returns
+// a *domain.SlowModeError if the sender must wait.
+func (p *SlowModePersister) claim(ctx context.Context, req PersistRequest) (bool, error) {
+	if req.SenderID.IsZero() {
+		return false, nil // system message
+	}
+
+	policy, err := p.policies.ChatPolicy(ctx, req.ChatID, req.SenderID)
+	if err != nil {
+		p.logger.WarnContext(ctx, "slow_mode.policy_unavailable", "chat_id", req.ChatID.String(), "error", err)
+		return false, nil
+	}
+	if !domain.SlowModeApplies(policy.SlowModeSeconds, policy.Role) {
+		return false, nil
+	}
+
+	interval := time.Duration(policy.SlowModeSeconds) * time.Second
+	remaining, err := p.store.Claim(ctx, req.ChatID, req.SenderID, req.ClientMessageID, interval, req.ReceivedAt.Time())
+	if err != nil {
+		p.logger.WarnContext(ctx, "slow_mode.store_unavailable", "chat_id", req.ChatID.String(), "error", err)
+		return false, nil
+	}
+	if remaining > 0 {
+		slowModeRejections.Add(ctx, 1)
+		return false, &domain.SlowModeError{Remaining: remaining}
+	}
+	return true, nil
+}
+
+// Compile-time interface check.
+var _ Persister = (*SlowModePersister)(nil)
@@ -0,0 +1,195 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// policyFunc adapts a function to app.ChatPolicyReader.
+type policyFunc func(ctx context.Context, chatID domain.ChatID, senderID domain.UserID) (app.ChatPolicy, error)
+
+func (f policyFunc) ChatPolicy(ctx context.Context, chatID domain.ChatID, senderID domain.UserID) (app.ChatPolicy, error) {
+	return f(ctx, chatID, senderID)
+}
+
+func fixedPolicy(seconds int, role domain.MemberRole) policyFunc {
+	return func(context.Context, domain.ChatID, domain.UserID) (app.ChatPolicy, error) {
+		return app.ChatPolicy{SlowModeSeconds: seconds, Role: role}, nil
+	}
+}
+
+// memSlowModeStore implements app.SlowModeStore with the same semantics as
+// the Redis adapter. Safe for concurrent use.
+type memSlowModeStore struct {
+	mu     sync.Mutex
+	last   map[string]slowModeClaim
+	err    error
+	claims int
+}
+
+type slowModeClaim struct {
+	at              time.Time
+	clientMessageID string
+	interval        time.Duration
+}
+
+func newMemSlowModeStore() *memSlowModeStore {
+	return &memSlowModeStore{last: map[string]slowModeClaim{}}
+}
+
+func (s *memSlowModeStore) Claim(_ context.Context, chatID domain.ChatID, senderID domain.UserID, clientMessageID string, interval time.Duration, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims++
+	if s.err != nil {
+		return 0, s.err
+	}
+	key := chatID.String() + ":" + senderID.String()
+	if prev, ok := s.last[key]; ok {
+		if prev.clientMessageID == clientMessageID {
+			return 0, nil
+		}
+		if remaining := prev.at.Add(prev.interval).Sub(now); remaining > 0 {
+			return remaining, nil
+		}
+	}
+	s.last[key] = slowModeClaim{at: now, clientMessageID: clientMessageID, interval: interval}
+	return 0, nil
+}
+
+func (s *memSlowModeStore) Release(_ context.Context, chatID domain.ChatID, senderID domain.UserID, clientMessageID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := chatID.String() + ":" + senderID.String()
+	if prev, ok := s.last[key]; ok && prev.clientMessageID == clientMessageID && prev.at.Equal(now) {
+		delete(s.last, key)
+	}
+	return nil
+}
+
+func newSlowModePersister(next app.Persister, policies app.ChatPolicyReader, store app.SlowModeStore) (*app.SlowModePersister, *syncBuffer) {
+	logs := &syncBuffer{}
+	return app.NewSlowModePersister(app.SlowModePersisterConfig{
+		Next:     next,
+		Policies: policies,
+		Store:    store,
+		Logger:   slog.New(slog.NewTextHandler(logs, nil)),
+	}), logs
+}
+
+func TestSlowModePersister_Persist(t *testing.T) {
+	ctx := context.Background()
+	chat := domain.GenerateChatID()
+	sender := domain.GenerateUserID()
+	clock := domaintest.NewFakeClock(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+
+	send := func(p *app.SlowModePersister, clientMessageID string) error {
+		req := request(chat)
+		req.SenderID = sender
+		req.ClientMessageID = clientMessageID
+		req.ReceivedAt = domain.ServerNow(clock)
+		_, err := p.Persist(ctx, req)
+		return err
+	}
+
+	t.Run("rejects a second message within the interval", func(t *testing.T) {
+		p, _ := newSlowModePersister(sequencer(1), fixedPolicy(30, domain.MemberRoleMember), newMemSlowModeStore())
+
+		require.NoError(t, send(p, "m1"))
+		clock.Advance(10 * time.Second)
+		err := send(p, "m2")
+
+		require.ErrorIs(t, err, domain.ErrSlowMode)
+		remaining, ok := domain.SlowModeRemaining(err)
+		require.True(t, ok)
+		assert.Equal(t, 20*time.Second, remaining)
+
+		clock.Advance(20 * time.Second)
+		assert.NoError(t, send(p, "m3"), "allowed once the interval has passed")
+	})
+
+	t.Run("a retried message is not throttled by its first attempt", func(t *testing.T) {
+		p, _ := newSlowModePersister(sequencer(1), fixedPolicy(30, domain.MemberRoleMember), newMemSlowModeStore())
+
+		require.NoError(t, send(p, "m1"))
+		clock.Advance(time.Second)
+
+		assert.NoError(t, send(p, "m1"))
+	})
+
+	t.Run("a failed persist gives back the claim", func(t *testing.T) {
+		failing := persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			return app.PersistResult{}, domain.ErrUnavailable
+		})
+		store := newMemSlowModeStore()
+		p, _ := newSlowModePersister(failing, fixedPolicy(30, domain.MemberRoleMember), store)
+		require.ErrorIs(t, send(p, "m1"), domain.ErrUnavailable)
+
+		ok, _ := newSlowModePersister(sequencer(1), fixedPolicy(30, domain.MemberRoleMember), store)
+		assert.NoError(t, send(ok, "m2"))
+	})
+
+	t.Run("exemptions", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			policy policyFunc
+		}{
+			{"slow mode off", fixedPolicy(0, domain.MemberRoleMember)},
+			{"admin", fixedPolicy(30, domain.MemberRoleAdmin)},
+			{"owner", fixedPolicy(30, domain.MemberRoleOwner)},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				store := newMemSlowModeStore()
+				p, _ := newSlowModePersister(sequencer(1), tt.policy, store)
+
+				require.NoError(t, send(p, "m1"))
+				assert.NoError(t, send(p, "m2"))
+				assert.Zero(t, store.claims)
+			})
+		}
+	})
+
+	t.Run("system messages are exempt", func(t *testing.T) {
+		store := newMemSlowModeStore()
+		p, _ := newSlowModePersister(sequencer(1), fixedPolicy(30, domain.MemberRoleMember), store)
+		req := request(chat)
+		req.SenderID = domain.UserID{}
+
+		_, err := p.Persist(ctx, req)
+
+		require.NoError(t, err)
+		assert.Zero(t, store.claims)
+	})
+
+	t.Run("fails open when the policy is unavailable", func(t *testing.T) {
+		policy := policyFunc(func(context.Context, domain.ChatID, domain.UserID) (app.ChatPolicy, error) {
+			return app.ChatPolicy{}, errors.New("dynamo: timeout")
+		})
+		p, logs := newSlowModePersister(sequencer(1), policy, newMemSlowModeStore())
+
+		assert.NoError(t, send(p, "m1"))
+		assert.Contains(t, logs.String(), "slow_mode.policy_unavailable")
+	})
+
+	t.Run("fails open when the store is unavailable", func(t *testing.T) {
+		store := newMemSlowModeStore()
+		store.err = errors.New("redis: connection refused")
+		p, logs := newSlowModePersister(sequencer(1), fixedPolicy(30, domain.MemberRoleMember), store)
+
+		require.NoError(t, send(p, "m1"))
+		assert.NoError(t, send(p, "m2"))
+		assert.Contains(t, logs.String(), "slow_mode.store_unavailable")
+	})
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// SystemPosterConfig holds configuration for creating a SystemPoster.
+type SystemPosterConfig struct {
+	Persister Persister
+	Clock     domain.Clock // nil defaults to domain.RealClock
+}
+
+// SystemPoster writes server-generated system messages into a chat. They go
+// through the same Persister as user messages, so they get a sequence and
+// reach members through normal delivery and sync.
+type SystemPoster struct {
+	persister Persister
+	clock     domain.Clock
+}
+
+// NewSystemPoster creates a SystemPoster.
+func NewSystemPoster(cfg SystemPosterConfig) *SystemPoster {
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	return &SystemPoster{persister: cfg.Persister, clock: clock}
+}
+
+// Post persists msg in chatID. key identifies the change that produced the
+// message (e.g. the membership event ID) and becomes its idempotency key,
+// so a redelivered change yields PersistResult.Duplicate instead of a
+// second message. System messages have no sender.
+func (p *SystemPoster) Post(ctx context.Context, chatID domain.ChatID, key string, msg domain.SystemMessage) (PersistResult, error) {
+	if key == "" {
+		return PersistResult{}, fmt.Errorf("post system message: %w", domain.NewValidationError("key", "is required"))
+	}
+	content, err := msg.Content()
+	if err != nil {
+		return PersistResult{}, fmt.Errorf("post system message: %w", err)
+	}
+
+	res, err := p.persister.Persist(ctx, PersistRequest{
+		ChatID:          chatID,
+		ClientMessageID: domain.SystemClientMessageID(key),
+		Content:         content,
+		ReceivedAt:      domain.ServerNow(p.clock),
+	})
+	if err != nil {
+		return PersistResult{}, fmt.Errorf("post system message: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+func TestSystemPoster_Post(t *testing.T) {
+	ctx := context.Background()
+	chat := domain.GenerateChatID()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	msg := domain.SystemMessage{Event: domain.SystemEventMemberJoined, ActorID: "user-001", TargetID: "user-002"}
+
+	t.Run("persists a system message with a reserved key", func(t *testing.T) {
+		var got app.PersistRequest
+		poster := app.NewSystemPoster(app.SystemPosterConfig{
+			Persister: persisterFunc(func(_ context.Context, req app.PersistRequest) (app.PersistResult, error) {
+				got = req
+				return app.PersistResult{Sequence: 7}, nil
+			}),
+			Clock: domaintest.NewFakeClock(now),
+		})
+
+		res, err := poster.Post(ctx, chat, "membership-evt-1", msg)
+
+		require.NoError(t, err)
+		assert.Equal(t, uint64(7), res.Sequence)
+		assert.Equal(t, chat, got.ChatID)
+		assert.True(t, got.SenderID.IsZero())
+		assert.Equal(t, domain.SystemClientMessageID("membership-evt-1"), got.ClientMessageID)
+		assert.Equal(t, domain.ContentTypeSystem, got.Content.ContentType())
+		assert.Equal(t, now, got.ReceivedAt.Time())
+		decoded, err := domain.ParseSystemMessage(got.Content.Body())
+		require.NoError(t, err)
+		assert.Equal(t, msg, decoded)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		poster := app.NewSystemPoster(app.SystemPosterConfig{Persister: sequencer(1)})
+
+		_, err := poster.Post(ctx, chat, "", msg)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("invalid message is not persisted", func(t *testing.T) {
+		var calls int
+		poster := app.NewSystemPoster(app.SystemPosterConfig{
+			Persister: persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+				calls++
+				return app.PersistResult{}, nil
+			}),
+		})
+
+		_, err := poster.Post(ctx, chat, "evt", domain.SystemMessage{Event: "unknown"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		assert.Zero(t, calls)
+	})
+
+	t.Run("persist failure", func(t *testing.T) {
+		poster := app.NewSystemPoster(app.SystemPosterConfig{
+			Persister: persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+				return app.PersistResult{}, errors.New("throttled")
+			}),
+		})
+
+		_, err := poster.Post(ctx, chat, "evt", msg)
+
+		assert.Error(t, err)
+	})
+}
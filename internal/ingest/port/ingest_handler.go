@@ -0,0 +1,111 @@
+package port
+
+import (
+	"context"
+	"fmt"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// IngestHandler implements the gRPC IngestServiceServer interface.
+type IngestHandler struct {
+	messagingv1.UnimplementedIngestServiceServer
+	persister app.Persister
+	system    *app.SystemPoster
+}
+
+// NewIngestHandler creates an IngestHandler that persists through
+// persister, the head of Ingest's persister chain. System messages take
+// the same chain.
+func NewIngestHandler(persister app.Persister) *IngestHandler {
+	return &IngestHandler{
+		persister: persister,
+		system:    app.NewSystemPoster(app.SystemPosterConfig{Persister: persister}),
+	}
+}
+
+// PersistMessage persists a user's message. Every message needs a sender;
+// system messages are posted through PostSystemMessage.
+func (h *IngestHandler) PersistMessage(
+	ctx context.Context, req *messagingv1.PersistMessageRequest,
+) (*messagingv1.PersistMessageResponse, error) {
+	preq, err := persistRequestFromProto(req)
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	res, err := h.persister.Persist(ctx, preq)
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.PersistMessageResponse{
+		MessageId:   res.MessageID.String(),
+		Sequence:    res.Sequence,
+		CreatedAt:   &messagingv1.Timestamp{Millis: res.CreatedAt.UnixMilli()},
+		IsDuplicate: res.Duplicate,
+	}, nil
+}
+
+// PostSystemMessage persists a system message for the change req.Key
+// identifies.
+func (h *IngestHandler) PostSystemMessage(
+	ctx context.Context, req *messagingv1.PostSystemMessageRequest,
+) (*messagingv1.PostSystemMessageResponse, error) {
+	chatID, err := domain.NewChatID(req.GetChatId())
+	if err != nil {
+		return nil, errmap.ToGRPCError(fmt.Errorf("chat_id: %w", err))
+	}
+	m := req.GetMessage()
+	if m == nil {
+		return nil, errmap.ToGRPCError(domain.NewValidationError("message", "is required"))
+	}
+	res, err := h.system.Post(ctx, chatID, req.GetKey(), domain.SystemMessage{
+		Event:    domain.SystemEvent(m.GetEvent()),
+		ActorID:  m.GetActorId(),
+		TargetID: m.GetTargetId(),
+		Params:   m.GetParams(),
+	})
+	if err != nil {
+		return nil, errmap.ToGRPCError(err)
+	}
+	return &messagingv1.PostSystemMessageResponse{
+		MessageId:   res.MessageID.String(),
+		Sequence:    res.Sequence,
+		IsDuplicate: res.Duplicate,
+	}, nil
+}
+
+// persistRequestFromProto validates req into an app.PersistRequest.
+func persistRequestFromProto(req *messagingv1.PersistMessageRequest) (app.PersistRequest, error) {
+	chatID, err := domain.NewChatID(req.GetChatId())
+	if err != nil {
+		return app.PersistRequest{}, fmt.Errorf("chat_id: %w", err)
+	}
+	senderID, err := domain.NewUserID(req.GetSenderId())
+	if err != nil {
+		return app.PersistRequest{}, fmt.Errorf("sender_id: %w", err)
+	}
+	if req.GetClientMessageId() == "" {
+		return app.PersistRequest{}, domain.NewValidationError("client_message_id", "is required")
+	}
+	if req.GetContentType() != messagingv1.ContentType_CONTENT_TYPE_TEXT {
+		return app.PersistRequest{}, fmt.Errorf("content_type %s: %w", req.GetContentType(), domain.ErrInvalidContentType)
+	}
+	content, err := domain.NewMessageContent(domain.ContentTypeText, req.GetContent())
+	if err != nil {
+		return app.PersistRequest{}, err
+	}
+	var received domain.ServerTime
+	if ts := req.GetServerReceivedAt(); ts != nil {
+		received = domain.ServerTimeFromMillis(ts.GetMillis())
+	}
+	return app.PersistRequest{
+		ChatID:          chatID,
+		SenderID:        senderID,
+		ClientMessageID: req.GetClientMessageId(),
+		Content:         content,
+		ReceivedAt:      received,
+	}, nil
+}
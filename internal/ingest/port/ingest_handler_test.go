@@ -0,0 +1,173 @@
+package port
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	messagingv1 "github.com/aelexs/realtime-messaging-platform/gen/messaging/v1"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ingest/app"
+)
+
+// ---------------------------------------------------------------------------
+// Stubs
+// ---------------------------------------------------------------------------
+
+type persisterFunc func(ctx context.Context, req app.PersistRequest) (app.PersistResult, error)
+
+func (f persisterFunc) Persist(ctx context.Context, req app.PersistRequest) (app.PersistResult, error) {
+	return f(ctx, req)
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestIngestHandler_PersistMessage(t *testing.T) {
+	ctx := context.Background()
+	chatID := domain.GenerateChatID()
+	senderID := domain.GenerateUserID()
+	messageID := domain.GenerateMessageID()
+	createdAt := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	valid := func() *messagingv1.PersistMessageRequest {
+		return &messagingv1.PersistMessageRequest{
+			ChatId:           chatID.String(),
+			SenderId:         senderID.String(),
+			ClientMessageId:  "client-1",
+			ContentType:      messagingv1.ContentType_CONTENT_TYPE_TEXT,
+			Content:          "hello",
+			ServerReceivedAt: &messagingv1.Timestamp{Millis: createdAt.Add(-time.Second).UnixMilli()},
+		}
+	}
+
+	t.Run("persists a valid message", func(t *testing.T) {
+		var got app.PersistRequest
+		h := NewIngestHandler(persisterFunc(func(_ context.Context, req app.PersistRequest) (app.PersistResult, error) {
+			got = req
+			return app.PersistResult{MessageID: messageID, Sequence: 7, CreatedAt: createdAt, Duplicate: true}, nil
+		}))
+
+		resp, err := h.PersistMessage(ctx, valid())
+
+		require.NoError(t, err)
+		assert.Equal(t, messageID.String(), resp.GetMessageId())
+		assert.Equal(t, uint64(7), resp.GetSequence())
+		assert.Equal(t, createdAt.UnixMilli(), resp.GetCreatedAt().GetMillis())
+		assert.True(t, resp.GetIsDuplicate())
+		assert.Equal(t, chatID, got.ChatID)
+		assert.Equal(t, senderID, got.SenderID)
+		assert.Equal(t, "client-1", got.ClientMessageID)
+		assert.Equal(t, "hello", got.Content.Body())
+		assert.Equal(t, createdAt.Add(-time.Second).UnixMilli(), got.ReceivedAt.UnixMilli())
+	})
+
+	t.Run("rejects invalid requests before persisting", func(t *testing.T) {
+		h := NewIngestHandler(persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			t.Fatal("persisted an invalid request")
+			return app.PersistResult{}, nil
+		}))
+		tests := []struct {
+			name   string
+			mutate func(*messagingv1.PersistMessageRequest)
+		}{
+			{"missing chat", func(r *messagingv1.PersistMessageRequest) { r.ChatId = "" }},
+			{"missing sender", func(r *messagingv1.PersistMessageRequest) { r.SenderId = "" }},
+			{"missing client message ID", func(r *messagingv1.PersistMessageRequest) { r.ClientMessageId = "" }},
+			{"system content type", func(r *messagingv1.PersistMessageRequest) {
+				r.ContentType = messagingv1.ContentType_CONTENT_TYPE_SYSTEM
+			}},
+			{"blank content", func(r *messagingv1.PersistMessageRequest) { r.Content = "   " }},
+			{"oversized content", func(r *messagingv1.PersistMessageRequest) {
+				r.Content = strings.Repeat("a", domain.MaxMessageSize+1)
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := valid()
+				tt.mutate(req)
+
+				_, err := h.PersistMessage(ctx, req)
+
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			})
+		}
+	})
+
+	t.Run("maps persist errors", func(t *testing.T) {
+		h := NewIngestHandler(persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			return app.PersistResult{}, domain.ErrNotMember
+		}))
+
+		_, err := h.PersistMessage(ctx, valid())
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestIngestHandler_PostSystemMessage(t *testing.T) {
+	ctx := context.Background()
+	chatID := domain.GenerateChatID()
+	messageID := domain.GenerateMessageID()
+	valid := func() *messagingv1.PostSystemMessageRequest {
+		return &messagingv1.PostSystemMessageRequest{
+			ChatId: chatID.String(),
+			Key:    "settings:1",
+			Message: &messagingv1.SystemMessage{
+				Event:   string(domain.SystemEventChatRenamed),
+				ActorId: "user-1",
+				Params:  map[string]string{"name": "Team"},
+			},
+		}
+	}
+
+	t.Run("persists a system message without a sender", func(t *testing.T) {
+		var got app.PersistRequest
+		h := NewIngestHandler(persisterFunc(func(_ context.Context, req app.PersistRequest) (app.PersistResult, error) {
+			got = req
+			return app.PersistResult{MessageID: messageID, Sequence: 4}, nil
+		}))
+
+		resp, err := h.PostSystemMessage(ctx, valid())
+
+		require.NoError(t, err)
+		assert.Equal(t, messageID.String(), resp.GetMessageId())
+		assert.Equal(t, uint64(4), resp.GetSequence())
+		assert.Equal(t, chatID, got.ChatID)
+		assert.True(t, got.SenderID.IsZero())
+		assert.Equal(t, domain.SystemClientMessageID("settings:1"), got.ClientMessageID)
+		msg, err := domain.ParseSystemMessage(got.Content.Body())
+		require.NoError(t, err)
+		assert.Equal(t, domain.SystemMessage{
+			Event:   domain.SystemEventChatRenamed,
+			ActorID: "user-1",
+			Params:  map[string]string{"name": "Team"},
+		}, msg)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		h := NewIngestHandler(persisterFunc(func(context.Context, app.PersistRequest) (app.PersistResult, error) {
+			t.Fatal("invalid requests are not persisted")
+			return app.PersistResult{}, nil
+		}))
+		for name, mutate := range map[string]func(*messagingv1.PostSystemMessageRequest){
+			"no key":        func(r *messagingv1.PostSystemMessageRequest) { r.Key = "" },
+			"no message":    func(r *messagingv1.PostSystemMessageRequest) { r.Message = nil },
+			"unknown event": func(r *messagingv1.PostSystemMessageRequest) { r.Message.Event = "party" },
+			"bad chat":      func(r *messagingv1.PostSystemMessageRequest) { r.ChatId = "" },
+		} {
+			req := valid()
+			mutate(req)
+
+			_, err := h.PostSystemMessage(ctx, req)
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Package ipscreen screens client addresses before abusable work: Gateway
+// upgrades and OTP requests. Addresses are checked against the static
+// domain.IPPolicy and, optionally, an external reputation provider that
+// also supplies the country for geo-blocking. Provider failures fail open;
+// the static policy still applies.
+package ipscreen
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// DefaultProviderTimeout bounds a reputation lookup so a slow provider
+// cannot stall logins and connects.
+const DefaultProviderTimeout = 200 * time.Millisecond
+
+var decisionsTotal metric.Int64Counter
+
+func init() {
+	decisionsTotal, _ = otel.Meter("ipscreen").Int64Counter("ip_screen_decisions_total",
+		metric.WithDescription("Client address screening decisions, by surface, decision and reason"))
+}
+
+// Reputation is what an external provider knows about an address.
+type Reputation struct {
+	// Country is the ISO 3166-1 alpha-2 region, or "" when unknown.
+	Country string
+	// Malicious marks addresses the provider considers abusive.
+	Malicious bool
+	// Category describes a malicious address, e.g. "botnet" or "proxy".
+	Category string
+}
+
+// ReputationProvider looks up an address's reputation. Implementations
+// should cache; Screen calls it on every screened request.
+type ReputationProvider interface {
+	Lookup(ctx context.Context, addr netip.Addr) (Reputation, error)
+}
+
+// Config holds the dependencies for Screener.
+type Config struct {
+	// Policy is the initial static policy. Nil permits every address.
+	Policy *domain.IPPolicy
+	// Provider enables reputation and country checks. Nil skips them.
+	Provider ReputationProvider
+	// ProviderTimeout bounds each lookup. Zero uses DefaultProviderTimeout.
+	ProviderTimeout time.Duration
+	Logger          *slog.Logger
+}
+
+// Screener applies the IP policy and reputation provider. Safe for
+// concurrent use.
+type Screener struct {
+	policy          atomic.Pointer[domain.IPPolicy]
+	provider        ReputationProvider
+	providerTimeout time.Duration
+	logger          *slog.Logger
+}
+
+// New creates a Screener with the given dependencies.
+func New(cfg Config) *Screener {
+	s := &Screener{
+		provider:        cfg.Provider,
+		providerTimeout: cfg.ProviderTimeout,
+		logger:          cfg.Logger,
+	}
+	if s.providerTimeout == 0 {
+		s.providerTimeout = DefaultProviderTimeout
+	}
+	s.SetPolicy(cfg.Policy)
+	return s
+}
+
+// SetPolicy replaces the static policy, e.g. to block a country during an
+// SMS-pumping attack. Safe to call while requests are in flight. Country
+// rules without a provider cannot be enforced; SetPolicy logs a warning
+// so the gap shows at startup rather than during an attack.
+func (s *Screener) SetPolicy(p *domain.IPPolicy) {
+	if p.BlocksCountries() && s.provider == nil {
+		s.logger.Warn("ip policy blocks countries but no reputation provider is configured to locate addresses; country rules are not enforced")
+	}
+	s.policy.Store(p)
+}
+
+// Screen returns an error wrapping domain.ErrForbidden if clientIP must be
+// refused on surface ("gateway_upgrade", "request_otp"). An empty or
+// unparsable clientIP is allowed: the caller could not attribute the
+// request, and refusing it would block every client behind a broken proxy.
+func (s *Screener) Screen(ctx context.Context, surface, clientIP string) error {
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		s.count(ctx, surface, "allow", "unattributed")
+		return nil
+	}
+	addr = addr.Unmap()
+	policy := s.policy.Load()
+
+	if policy.Exempt(addr) {
+		s.count(ctx, surface, "allow", "exempt")
+		return nil
+	}
+	if err := policy.CheckAddr(addr); err != nil {
+		return s.block(ctx, surface, "cidr", addr, err)
+	}
+
+	if s.provider == nil {
+		s.count(ctx, surface, "allow", "static")
+		return nil
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, s.providerTimeout)
+	rep, err := s.provider.Lookup(lookupCtx, addr)
+	cancel()
+	if err != nil {
+		s.logger.WarnContext(ctx, "ip reputation lookup failed, proceeding (fail-open)",
+			"surface", surface, "client_ip", addr.String(), "error", err)
+		s.count(ctx, surface, "allow", "provider_error")
+		return nil
+	}
+	if rep.Malicious {
+		return s.block(ctx, surface, "reputation", addr,
+			fmt.Errorf("client address has a bad reputation (%s): %w", rep.Category, domain.ErrForbidden))
+	}
+	if err := policy.CheckCountry(rep.Country); err != nil {
+		return s.block(ctx, surface, "country", addr, err)
+	}
+
+	s.count(ctx, surface, "allow", "reputation")
+	return nil
+}
+
+func (s *Screener) block(ctx context.Context, surface, reason string, addr netip.Addr, err error) error {
+	s.count(ctx, surface, "block", reason)
+	s.logger.InfoContext(ctx, "ipscreen.blocked",
+		"surface", surface, "reason", reason, "client_ip", addr.String())
+	return err
+}
+
+func (s *Screener) count(ctx context.Context, surface, decision, reason string) {
+	decisionsTotal.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("surface", surface),
+		attribute.String("decision", decision),
+		attribute.String("reason", reason),
+	))
+}
@@ -0,0 +1,111 @@
+package ipscreen_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/ipscreen"
+)
+
+type stubProvider struct {
+	rep   ipscreen.Reputation
+	err   error
+	calls int
+	wait  bool
+}
+
+func (p *stubProvider) Lookup(ctx context.Context, _ netip.Addr) (ipscreen.Reputation, error) {
+	p.calls++
+	if p.wait {
+		<-ctx.Done()
+		return ipscreen.Reputation{}, ctx.Err()
+	}
+	return p.rep, p.err
+}
+
+func mustPolicy(t *testing.T, cfg domain.IPPolicyConfig) *domain.IPPolicy {
+	t.Helper()
+	p, err := domain.NewIPPolicy(cfg)
+	require.NoError(t, err)
+	return p
+}
+
+func TestScreener_Screen(t *testing.T) {
+	ctx := context.Background()
+	policy := mustPolicy(t, domain.IPPolicyConfig{
+		Allow:          []string{"198.51.100.7"},
+		Block:          []string{"203.0.113.0/24"},
+		BlockCountries: []string{"KP"},
+	})
+
+	tests := []struct {
+		name      string
+		ip        string
+		provider  *stubProvider
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "clean address", ip: "192.0.2.1", provider: &stubProvider{rep: ipscreen.Reputation{Country: "US"}}, wantCalls: 1},
+		{name: "blocked range skips provider", ip: "203.0.113.5", provider: &stubProvider{}, wantErr: true},
+		{name: "exempt skips provider", ip: "198.51.100.7", provider: &stubProvider{rep: ipscreen.Reputation{Malicious: true}}},
+		{name: "bad reputation", ip: "192.0.2.1", provider: &stubProvider{rep: ipscreen.Reputation{Malicious: true, Category: "botnet"}}, wantErr: true, wantCalls: 1},
+		{name: "blocked country", ip: "192.0.2.1", provider: &stubProvider{rep: ipscreen.Reputation{Country: "kp"}}, wantErr: true, wantCalls: 1},
+		{name: "provider failure fails open", ip: "192.0.2.1", provider: &stubProvider{err: errors.New("timeout")}, wantCalls: 1},
+		{name: "unattributed request", ip: "", provider: &stubProvider{}},
+		{name: "ipv4-mapped address", ip: "::ffff:203.0.113.5", provider: &stubProvider{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ipscreen.New(ipscreen.Config{Policy: policy, Provider: tt.provider, Logger: slog.Default()})
+
+			err := s.Screen(ctx, "request_otp", tt.ip)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrForbidden)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, tt.provider.calls)
+		})
+	}
+}
+
+func TestScreener_ProviderTimeout(t *testing.T) {
+	s := ipscreen.New(ipscreen.Config{
+		Provider:        &stubProvider{wait: true},
+		ProviderTimeout: time.Millisecond,
+		Logger:          slog.Default(),
+	})
+
+	assert.NoError(t, s.Screen(context.Background(), "gateway_upgrade", "192.0.2.1"))
+}
+
+func TestScreener_SetPolicy(t *testing.T) {
+	s := ipscreen.New(ipscreen.Config{Logger: slog.Default()})
+	require.NoError(t, s.Screen(context.Background(), "request_otp", "203.0.113.5"))
+
+	s.SetPolicy(mustPolicy(t, domain.IPPolicyConfig{Block: []string{"203.0.113.0/24"}}))
+
+	assert.ErrorIs(t, s.Screen(context.Background(), "request_otp", "203.0.113.5"), domain.ErrForbidden)
+}
+
+func TestScreener_WarnsOnUnenforceableCountryRules(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	policy := mustPolicy(t, domain.IPPolicyConfig{BlockCountries: []string{"KP"}})
+
+	ipscreen.New(ipscreen.Config{Policy: policy, Provider: &stubProvider{}, Logger: logger})
+	assert.Empty(t, logs.String())
+
+	ipscreen.New(ipscreen.Config{Policy: policy, Logger: logger})
+	assert.Contains(t, logs.String(), "country rules are not enforced")
+}
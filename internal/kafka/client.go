@@ -0,0 +1,276 @@
+// Package kafka provides a shared Kafka client factory.
+// Only this package may import franz-go — adapters in other packages use the
+// Producer and Consumer interfaces and the re-exported types defined here.
+// See CONTRIBUTING.md: "Only internal/kafka/ may import franz-go".
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Config holds Kafka connection parameters.
+type Config struct {
+	// Brokers are the seed brokers (e.g. "redpanda:9092").
+	Brokers []string
+
+	// ClientID identifies this client in broker logs and quotas.
+	ClientID string
+
+	// ProduceTimeout bounds how long a produced record may wait for
+	// delivery, retries included. Zero uses the franz-go default.
+	ProduceTimeout time.Duration
+
+	// Group and Topics configure the consumer side. Leave both empty for a
+	// producer-only client. Offsets are never auto-committed; consumers
+	// call Commit once a record is processed (ADR-011).
+	Group  string
+	Topics []string
+
+	// Clock times consumer lag; nil uses the wall clock.
+	Clock domain.Clock
+}
+
+// Producer publishes records. Produce returns once every record is
+// acknowledged by all in-sync replicas, or with the first failure.
+type Producer interface {
+	Produce(ctx context.Context, records ...*Record) error
+}
+
+// Consumer reads records as a member of a consumer group.
+type Consumer interface {
+	// Poll blocks until records are available or ctx is done.
+	Poll(ctx context.Context) ([]*Record, error)
+	// Commit marks records as processed.
+	Commit(ctx context.Context, records ...*Record) error
+}
+
+// Compile-time checks: Client satisfies both Producer and Consumer.
+var (
+	_ Producer = (*Client)(nil)
+	_ Consumer = (*Client)(nil)
+)
+
+// Client wraps the franz-go client with tracing, trace-context header
+// propagation and metrics. Safe for concurrent use.
+type Client struct {
+	kc     *kgo.Client
+	status *statusTracker
+}
+
+// NewClient creates a Kafka client configured from cfg. No connection is
+// opened until first use; call Ping as a warmup step to fail fast.
+func NewClient(cfg Config) (*Client, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka: no brokers configured")
+	}
+	if (cfg.Group == "") != (len(cfg.Topics) == 0) {
+		return nil, errors.New("kafka: group and topics must be set together")
+	}
+
+	// Producer settings per ADR-011 §3.1. franz-go enables the idempotent
+	// producer by default. Keyed records are partitioned with murmur2 like
+	// the Java client, which per-chat ordering depends on (ADR-011 §2.2).
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.ProducerBatchCompression(kgo.Lz4Compression()),
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+	}
+	if cfg.ClientID != "" {
+		opts = append(opts, kgo.ClientID(cfg.ClientID))
+	}
+	if cfg.ProduceTimeout > 0 {
+		opts = append(opts, kgo.RecordDeliveryTimeout(cfg.ProduceTimeout))
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = domain.RealClock{}
+	}
+	status := newStatusTracker(cfg.Group, clock)
+	if cfg.Group != "" {
+		opts = append(opts,
+			kgo.ConsumerGroup(cfg.Group),
+			kgo.ConsumeTopics(cfg.Topics...),
+			kgo.DisableAutoCommit(),
+			kgo.OnPartitionsAssigned(func(_ context.Context, _ *kgo.Client, m map[string][]int32) { status.assigned(m) }),
+			kgo.OnPartitionsRevoked(func(_ context.Context, _ *kgo.Client, m map[string][]int32) { status.revoked(m) }),
+			kgo.OnPartitionsLost(func(_ context.Context, _ *kgo.Client, m map[string][]int32) { status.lost(m) }),
+		)
+	}
+
+	kc, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka client: %w", err)
+	}
+	return &Client{kc: kc, status: status}, nil
+}
+
+// Ping round-trips to a broker. Use it as a startup warmup step.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.kc.Ping(ctx)
+}
+
+// Close leaves the consumer group, if any, and releases all connections.
+// Records not yet acknowledged fail with ErrClientClosed.
+func (c *Client) Close() {
+	c.kc.Close()
+}
+
+// Produce writes records synchronously. Each record carries the trace
+// context of the produce span in its headers, so consumers continue the
+// trace (see StartConsumeSpan).
+func (c *Client) Produce(ctx context.Context, records ...*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "kafka.produce", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.operation", "publish"),
+		attribute.Int("messaging.batch.message_count", len(records)),
+	)
+	if topic := records[0].Topic; allSameTopic(records) {
+		span.SetAttributes(attribute.String("messaging.destination.name", topic))
+	}
+
+	for _, r := range records {
+		InjectTraceContext(ctx, r)
+	}
+
+	results := c.kc.ProduceSync(ctx, records...)
+	for _, res := range results {
+		result := "ok"
+		if res.Err != nil {
+			result = "error"
+		}
+		recordsProducedTotal.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("topic", res.Record.Topic),
+			attribute.String("result", result),
+		))
+	}
+
+	if err := results.FirstErr(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("kafka produce: %w", err)
+	}
+	return nil
+}
+
+// Poll returns the next batch of fetched records. Partition errors are
+// returned joined alongside whatever records were fetched, so callers
+// process the records before handling the error.
+func (c *Client) Poll(ctx context.Context) ([]*Record, error) {
+	fetches := c.kc.PollFetches(ctx)
+	if fetches.IsClientClosed() {
+		return nil, ErrClientClosed
+	}
+
+	var errs []error
+	fetches.EachError(func(topic string, partition int32, err error) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		errs = append(errs, fmt.Errorf("%s[%d]: %w", topic, partition, err))
+	})
+
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		if p.Err == nil {
+			c.status.fetched(p.Topic, p.Partition, p.HighWatermark, p.Records)
+		}
+	})
+
+	records := fetches.Records()
+	for _, r := range records {
+		recordsConsumedTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("topic", r.Topic)))
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && len(records) == 0 {
+		return nil, ctxErr
+	}
+	if err := errors.Join(errs...); err != nil {
+		return records, fmt.Errorf("kafka poll: %w", err)
+	}
+	return records, nil
+}
+
+// Commit synchronously commits the offsets following records. Only the
+// highest offset per partition matters; committing out of order is safe.
+func (c *Client) Commit(ctx context.Context, records ...*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := c.kc.CommitRecords(ctx, records...); err != nil {
+		return fmt.Errorf("kafka commit: %w", err)
+	}
+	c.status.committed(records)
+	return nil
+}
+
+// ConsumerStatus returns this member's assigned partitions with their lag,
+// and its rebalance counts. A producer-only client reports no partitions.
+func (c *Client) ConsumerStatus() ConsumerStatus {
+	return c.status.status()
+}
+
+func allSameTopic(records []*Record) bool {
+	for _, r := range records[1:] {
+		if r.Topic != records[0].Topic {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------------------------------------------------
+// Type aliases — adapters import kafka.Record instead of franz-go.
+// ---------------------------------------------------------------------------
+
+// Record types.
+type (
+	Record       = kgo.Record
+	RecordHeader = kgo.RecordHeader
+)
+
+// ErrClientClosed is returned by Poll and Produce once the client is closed.
+// Consumer loops treat it as the signal to stop.
+var ErrClientClosed = kgo.ErrClientClosed
+
+// ---------------------------------------------------------------------------
+// Record helpers.
+// ---------------------------------------------------------------------------
+
+// Header returns the value of the first header named key.
+func Header(r *Record, key string) (string, bool) {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// SetHeader sets header key to value, replacing any existing headers with
+// that key.
+func SetHeader(r *Record, key, value string) {
+	kept := r.Headers[:0]
+	for _, h := range r.Headers {
+		if h.Key != key {
+			kept = append(kept, h)
+		}
+	}
+	r.Headers = append(kept, RecordHeader{Key: key, Value: []byte(value)})
+}
@@ -0,0 +1,83 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     kafka.Config
+		wantErr string
+	}{
+		{name: "producer only", cfg: kafka.Config{Brokers: []string{"localhost:19092"}, ClientID: "ingest"}},
+		{name: "consumer", cfg: kafka.Config{Brokers: []string{"localhost:19092"}, Group: "fanout", Topics: []string{"messages"}}},
+		{name: "no brokers", cfg: kafka.Config{}, wantErr: "no brokers"},
+		{name: "group without topics", cfg: kafka.Config{Brokers: []string{"localhost:19092"}, Group: "fanout"}, wantErr: "set together"},
+		{name: "topics without group", cfg: kafka.Config{Brokers: []string{"localhost:19092"}, Topics: []string{"messages"}}, wantErr: "set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := kafka.NewClient(tt.cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.cfg.Group, client.ConsumerStatus().Group)
+			assert.Empty(t, client.ConsumerStatus().Partitions)
+			client.Close()
+		})
+	}
+}
+
+func TestClientProduce_Unreachable(t *testing.T) {
+	client, err := kafka.NewClient(kafka.Config{Brokers: []string{"127.0.0.1:1"}})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err = client.Produce(ctx, &kafka.Record{Topic: "messages", Value: []byte("hi")})
+
+	assert.ErrorContains(t, err, "kafka produce")
+}
+
+func TestClientPoll_Closed(t *testing.T) {
+	client, err := kafka.NewClient(kafka.Config{Brokers: []string{"127.0.0.1:1"}, Group: "g", Topics: []string{"t"}})
+	require.NoError(t, err)
+	client.Close()
+
+	_, err = client.Poll(context.Background())
+
+	assert.ErrorIs(t, err, kafka.ErrClientClosed)
+}
+
+func TestHeaders(t *testing.T) {
+	r := &kafka.Record{Headers: []kafka.RecordHeader{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2")},
+		{Key: "a", Value: []byte("3")},
+	}}
+
+	v, ok := kafka.Header(r, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+
+	kafka.SetHeader(r, "a", "4")
+	assert.Equal(t, []kafka.RecordHeader{
+		{Key: "b", Value: []byte("2")},
+		{Key: "a", Value: []byte("4")},
+	}, r.Headers)
+
+	_, ok = kafka.Header(r, "missing")
+	assert.False(t, ok)
+}
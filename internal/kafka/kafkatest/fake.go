@@ -0,0 +1,151 @@
+// Package kafkatest provides in-memory test doubles for the kafka package.
+package kafkatest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+// Producer is an in-memory kafka.Producer that keeps every record it is
+// given. Like the real client it writes trace context into record headers.
+// Safe for concurrent use.
+type Producer struct {
+	mu      sync.Mutex
+	records []*kafka.Record
+	err     error
+}
+
+var _ kafka.Producer = (*Producer)(nil)
+
+// NewProducer creates an empty Producer.
+func NewProducer() *Producer {
+	return &Producer{}
+}
+
+// Produce stores records, or returns the error set with FailWith without
+// storing anything.
+func (p *Producer) Produce(ctx context.Context, records ...*kafka.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	for _, r := range records {
+		kafka.InjectTraceContext(ctx, r)
+	}
+	p.records = append(p.records, records...)
+	return nil
+}
+
+// FailWith makes subsequent Produce calls return err. A nil err restores
+// success.
+func (p *Producer) FailWith(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Records returns the records produced so far, in order.
+func (p *Producer) Records() []*kafka.Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*kafka.Record(nil), p.records...)
+}
+
+// Consumer is an in-memory kafka.Consumer. Records added with Push are
+// returned by Poll in order. Safe for concurrent use.
+type Consumer struct {
+	mu        sync.Mutex
+	queue     []*kafka.Record
+	committed []*kafka.Record
+	offsets   map[string]map[int32]int64 // next offset per topic/partition
+	ready     chan struct{}              // closed when queue is non-empty
+	closed    bool
+}
+
+var _ kafka.Consumer = (*Consumer)(nil)
+
+// NewConsumer creates an empty Consumer.
+func NewConsumer() *Consumer {
+	return &Consumer{
+		offsets: make(map[string]map[int32]int64),
+		ready:   make(chan struct{}),
+	}
+}
+
+// Push queues records for Poll. Offsets are assigned per topic and
+// partition in push order, as a broker would.
+func (c *Consumer) Push(records ...*kafka.Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range records {
+		parts, ok := c.offsets[r.Topic]
+		if !ok {
+			parts = make(map[int32]int64)
+			c.offsets[r.Topic] = parts
+		}
+		r.Offset = parts[r.Partition]
+		parts[r.Partition]++
+	}
+	if len(c.queue) == 0 && len(records) > 0 && !c.closed {
+		close(c.ready)
+	}
+	c.queue = append(c.queue, records...)
+}
+
+// Poll returns all queued records, blocking until there are some, the
+// consumer is closed, or ctx is done.
+func (c *Consumer) Poll(ctx context.Context) ([]*kafka.Record, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, kafka.ErrClientClosed
+		}
+		if len(c.queue) > 0 {
+			records := c.queue
+			c.queue = nil
+			c.ready = make(chan struct{})
+			c.mu.Unlock()
+			return records, nil
+		}
+		ready := c.ready
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ready:
+		}
+	}
+}
+
+// Commit records the committed records.
+func (c *Consumer) Commit(_ context.Context, records ...*kafka.Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.committed = append(c.committed, records...)
+	return nil
+}
+
+// Committed returns every record passed to Commit, in order.
+func (c *Consumer) Committed() []*kafka.Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*kafka.Record(nil), c.committed...)
+}
+
+// Close makes pending and later Poll calls return kafka.ErrClientClosed.
+func (c *Consumer) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if len(c.queue) == 0 {
+		close(c.ready)
+	}
+}
@@ -0,0 +1,82 @@
+package kafkatest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka/kafkatest"
+)
+
+func TestProducer(t *testing.T) {
+	p := kafkatest.NewProducer()
+
+	require.NoError(t, p.Produce(context.Background(), &kafka.Record{Topic: "a"}, &kafka.Record{Topic: "b"}))
+	p.FailWith(errors.New("broker down"))
+	require.Error(t, p.Produce(context.Background(), &kafka.Record{Topic: "c"}))
+
+	records := p.Records()
+	require.Len(t, records, 2)
+	assert.Equal(t, "a", records[0].Topic)
+	assert.Equal(t, "b", records[1].Topic)
+}
+
+func TestConsumer(t *testing.T) {
+	t.Run("poll returns pushed records with per-partition offsets", func(t *testing.T) {
+		c := kafkatest.NewConsumer()
+		c.Push(
+			&kafka.Record{Topic: "messages", Partition: 0},
+			&kafka.Record{Topic: "messages", Partition: 1},
+			&kafka.Record{Topic: "messages", Partition: 0},
+		)
+
+		records, err := c.Poll(context.Background())
+
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []int64{0, 0, 1}, []int64{records[0].Offset, records[1].Offset, records[2].Offset})
+
+		require.NoError(t, c.Commit(context.Background(), records[2]))
+		assert.Equal(t, []*kafka.Record{records[2]}, c.Committed())
+	})
+
+	t.Run("poll blocks until a push", func(t *testing.T) {
+		c := kafkatest.NewConsumer()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			c.Push(&kafka.Record{Topic: "messages"})
+		}()
+
+		records, err := c.Poll(context.Background())
+
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	})
+
+	t.Run("poll stops on context cancellation", func(t *testing.T) {
+		c := kafkatest.NewConsumer()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.Poll(ctx)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("close unblocks poll", func(t *testing.T) {
+		c := kafkatest.NewConsumer()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			c.Close()
+		}()
+
+		_, err := c.Poll(context.Background())
+
+		assert.ErrorIs(t, err, kafka.ErrClientClosed)
+	})
+}
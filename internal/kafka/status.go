@@ -0,0 +1,199 @@
+package kafka
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// PartitionStatus is this member's view of one assigned partition.
+type PartitionStatus struct {
+	Topic     string
+	Partition int32
+
+	// HighWatermark is the offset after the last record on the broker, as
+	// of the latest fetch; -1 before the first fetch.
+	HighWatermark int64
+	// Committed is the next offset to consume per the last commit; -1
+	// before the first commit.
+	Committed int64
+	// Lag is the records between the consumer's position and the high
+	// watermark. The position is Committed, or the first record fetched
+	// before any commit.
+	Lag int64
+	// LagAge is how long the oldest fetched but uncommitted record has
+	// waited since it was produced; zero when everything fetched is
+	// committed.
+	LagAge time.Duration
+}
+
+// ConsumerStatus is a snapshot of a consumer group member.
+type ConsumerStatus struct {
+	Group      string
+	Partitions []PartitionStatus // sorted by topic, then partition
+
+	// Rebalances counts partition assignments received since the client
+	// started; PartitionsLost counts partitions lost without a clean
+	// revoke (session timeout, fenced member).
+	Rebalances     int64
+	PartitionsLost int64
+	LastRebalance  time.Time
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// fetchedBatch is one poll's records for a partition.
+type fetchedBatch struct {
+	lastOffset int64
+	firstTime  time.Time
+}
+
+type partitionState struct {
+	highWatermark int64
+	committed     int64
+	firstFetched  int64 // offset of the first record fetched; -1 if none
+	pending       []fetchedBatch
+}
+
+// statusTracker maintains ConsumerStatus from fetches, commits and group
+// callbacks. Safe for concurrent use.
+type statusTracker struct {
+	group string
+	clock domain.Clock
+
+	mu             sync.Mutex
+	partitions     map[topicPartition]*partitionState
+	rebalances     int64
+	partitionsLost int64
+	lastRebalance  time.Time
+}
+
+func newStatusTracker(group string, clock domain.Clock) *statusTracker {
+	return &statusTracker{
+		group:      group,
+		clock:      clock,
+		partitions: make(map[topicPartition]*partitionState),
+	}
+}
+
+// assigned starts tracking newly assigned partitions.
+func (t *statusTracker) assigned(assigned map[string][]int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for topic, parts := range assigned {
+		for _, p := range parts {
+			key := topicPartition{topic, p}
+			if _, ok := t.partitions[key]; !ok {
+				t.partitions[key] = &partitionState{highWatermark: -1, committed: -1, firstFetched: -1}
+			}
+		}
+	}
+	t.rebalances++
+	t.lastRebalance = t.clock.Now()
+}
+
+// revoked stops tracking partitions handed to another member.
+func (t *statusTracker) revoked(revoked map[string][]int32) {
+	t.remove(revoked, false)
+}
+
+// lost stops tracking partitions lost without a clean revoke.
+func (t *statusTracker) lost(lost map[string][]int32) {
+	t.remove(lost, true)
+}
+
+func (t *statusTracker) remove(parts map[string][]int32, lost bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for topic, ps := range parts {
+		for _, p := range ps {
+			delete(t.partitions, topicPartition{topic, p})
+			if lost {
+				t.partitionsLost++
+			}
+		}
+	}
+}
+
+// fetched records a partition's high watermark and the records polled
+// from it.
+func (t *statusTracker) fetched(topic string, partition int32, highWatermark int64, records []*Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.partitions[topicPartition{topic, partition}]
+	if !ok {
+		return
+	}
+	st.highWatermark = max(st.highWatermark, highWatermark)
+	if len(records) == 0 {
+		return
+	}
+	if st.firstFetched < 0 {
+		st.firstFetched = records[0].Offset
+	}
+	st.pending = append(st.pending, fetchedBatch{
+		lastOffset: records[len(records)-1].Offset,
+		firstTime:  records[0].Timestamp,
+	})
+}
+
+// committed records a successful commit of records.
+func (t *statusTracker) committed(records []*Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range records {
+		st, ok := t.partitions[topicPartition{r.Topic, r.Partition}]
+		if !ok || r.Offset+1 <= st.committed {
+			continue
+		}
+		st.committed = r.Offset + 1
+		i := 0
+		for i < len(st.pending) && st.pending[i].lastOffset < st.committed {
+			i++
+		}
+		st.pending = st.pending[i:]
+	}
+}
+
+func (t *statusTracker) status() ConsumerStatus {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := ConsumerStatus{
+		Group:          t.group,
+		Partitions:     make([]PartitionStatus, 0, len(t.partitions)),
+		Rebalances:     t.rebalances,
+		PartitionsLost: t.partitionsLost,
+		LastRebalance:  t.lastRebalance,
+	}
+	for key, st := range t.partitions {
+		ps := PartitionStatus{
+			Topic:         key.topic,
+			Partition:     key.partition,
+			HighWatermark: st.highWatermark,
+			Committed:     st.committed,
+		}
+		position := st.committed
+		if position < 0 {
+			position = st.firstFetched
+		}
+		if st.highWatermark >= 0 && position >= 0 {
+			ps.Lag = max(st.highWatermark-position, 0)
+		}
+		if len(st.pending) > 0 {
+			ps.LagAge = max(now.Sub(st.pending[0].firstTime), 0)
+		}
+		out.Partitions = append(out.Partitions, ps)
+	}
+	slices.SortFunc(out.Partitions, func(a, b PartitionStatus) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	return out
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+)
+
+func TestStatusTracker(t *testing.T) {
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	clock := domaintest.NewFakeClock(start)
+	tr := newStatusTracker("fanout", clock)
+
+	rec := func(partition int32, offset int64, produced time.Time) *Record {
+		return &Record{Topic: "messages", Partition: partition, Offset: offset, Timestamp: produced}
+	}
+
+	tr.assigned(map[string][]int32{"messages": {1, 0}})
+	s := tr.status()
+	assert.Equal(t, "fanout", s.Group)
+	assert.Equal(t, int64(1), s.Rebalances)
+	assert.Equal(t, start, s.LastRebalance)
+	require.Len(t, s.Partitions, 2)
+	assert.Equal(t, PartitionStatus{Topic: "messages", Partition: 0, HighWatermark: -1, Committed: -1}, s.Partitions[0])
+
+	t.Run("lag before the first commit counts from the first fetch", func(t *testing.T) {
+		tr.fetched("messages", 0, 110, []*Record{rec(0, 100, start), rec(0, 101, start)})
+		clock.Advance(3 * time.Second)
+
+		p := tr.status().Partitions[0]
+		assert.Equal(t, int64(110), p.HighWatermark)
+		assert.Equal(t, int64(10), p.Lag)
+		assert.Equal(t, 3*time.Second, p.LagAge)
+	})
+
+	t.Run("commit advances the position and clears its batches", func(t *testing.T) {
+		tr.fetched("messages", 0, 112, []*Record{rec(0, 102, clock.Now())})
+		tr.committed([]*Record{rec(0, 101, start)})
+
+		p := tr.status().Partitions[0]
+		assert.Equal(t, int64(102), p.Committed)
+		assert.Equal(t, int64(10), p.Lag)
+		assert.Zero(t, p.LagAge, "the remaining batch was produced just now")
+
+		tr.committed([]*Record{rec(0, 102, start), rec(0, 50, start)})
+		p = tr.status().Partitions[0]
+		assert.Equal(t, int64(103), p.Committed, "older commits do not move the position back")
+		assert.Zero(t, p.LagAge)
+	})
+
+	t.Run("unassigned partitions are ignored", func(t *testing.T) {
+		tr.fetched("messages", 7, 10, []*Record{rec(7, 0, start)})
+		tr.committed([]*Record{rec(7, 0, start)})
+		assert.Len(t, tr.status().Partitions, 2)
+	})
+
+	t.Run("revoke and loss", func(t *testing.T) {
+		tr.revoked(map[string][]int32{"messages": {0}})
+		tr.lost(map[string][]int32{"messages": {1}})
+		tr.assigned(map[string][]int32{"messages": {2}})
+
+		s := tr.status()
+		require.Len(t, s.Partitions, 1)
+		assert.Equal(t, int32(2), s.Partitions[0].Partition)
+		assert.Equal(t, int64(2), s.Rebalances)
+		assert.Equal(t, int64(1), s.PartitionsLost)
+	})
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("kafka")
+
+var (
+	recordsProducedTotal metric.Int64Counter
+	recordsConsumedTotal metric.Int64Counter
+)
+
+func init() {
+	m := otel.Meter("kafka")
+	recordsProducedTotal, _ = m.Int64Counter("kafka_records_produced_total",
+		metric.WithDescription("Records produced, by topic and result"))
+	recordsConsumedTotal, _ = m.Int64Counter("kafka_records_consumed_total",
+		metric.WithDescription("Records fetched by consumers, by topic"))
+}
+
+// headerCarrier adapts record headers to propagation.TextMapCarrier.
+type headerCarrier struct {
+	r *Record
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := Header(c.r, key)
+	return v
+}
+
+func (c headerCarrier) Set(key, value string) {
+	SetHeader(c.r, key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.r.Headers))
+	for i, h := range c.r.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// InjectTraceContext writes the trace context of ctx into r's headers
+// using the global propagator (W3C traceparent and baggage). Produce does
+// this for every record; fakes call it to behave the same.
+func InjectTraceContext(ctx context.Context, r *Record) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{r: r})
+}
+
+// ExtractTraceContext returns ctx carrying the trace context found in r's
+// headers.
+func ExtractTraceContext(ctx context.Context, r *Record) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{r: r})
+}
+
+// StartConsumeSpan starts the span for processing r, parented to the
+// producer's span from the record headers. The caller must end the span.
+func StartConsumeSpan(ctx context.Context, r *Record) (context.Context, trace.Span) {
+	ctx = ExtractTraceContext(ctx, r)
+	return tracer.Start(ctx, "kafka.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation", "process"),
+			attribute.String("messaging.destination.name", r.Topic),
+			attribute.Int("messaging.kafka.destination.partition", int(r.Partition)),
+			attribute.Int64("messaging.kafka.message.offset", r.Offset),
+		),
+	)
+}
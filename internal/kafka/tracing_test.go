@@ -0,0 +1,67 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/kafka"
+)
+
+func useTraceContext(t *testing.T) {
+	t.Helper()
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+}
+
+func TestTraceContextRoundTrip(t *testing.T) {
+	useTraceContext(t)
+	tp := sdktrace.NewTracerProvider()
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "send")
+	defer span.End()
+
+	r := &kafka.Record{Topic: "messages", Partition: 3, Offset: 42}
+	kafka.InjectTraceContext(ctx, r)
+
+	_, ok := kafka.Header(r, "traceparent")
+	require.True(t, ok, "traceparent header must be written")
+
+	got := trace.SpanContextFromContext(kafka.ExtractTraceContext(context.Background(), r))
+	assert.Equal(t, span.SpanContext().TraceID(), got.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), got.SpanID())
+	assert.True(t, got.IsRemote())
+}
+
+func TestStartConsumeSpan_ContinuesProducerTrace(t *testing.T) {
+	useTraceContext(t)
+	tp := sdktrace.NewTracerProvider()
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	ctx, producer := tp.Tracer("test").Start(context.Background(), "send")
+	r := &kafka.Record{Topic: "messages"}
+	kafka.InjectTraceContext(ctx, r)
+	producer.End()
+
+	consumeCtx, span := kafka.StartConsumeSpan(context.Background(), r)
+	defer span.End()
+
+	assert.Equal(t, producer.SpanContext().TraceID(), trace.SpanContextFromContext(consumeCtx).TraceID())
+}
+
+func TestStartConsumeSpan_NoHeaders(t *testing.T) {
+	useTraceContext(t)
+
+	_, span := kafka.StartConsumeSpan(context.Background(), &kafka.Record{Topic: "messages"})
+	defer span.End()
+
+	assert.False(t, span.SpanContext().TraceID().IsValid())
+}
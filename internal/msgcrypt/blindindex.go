@@ -0,0 +1,34 @@
+package msgcrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// BlindIndex derives the tokens an index stores in place of the values it
+// finds: an HMAC-SHA256 of the value under a secret pepper. Equal values
+// share a token, but without the pepper a token cannot be matched to its
+// value, not even by hashing every phone number.
+//
+// Tokens do not change when data keys rotate. Changing the pepper changes
+// every token, so it needs a full re-index.
+type BlindIndex struct {
+	pepper []byte
+}
+
+// NewBlindIndex creates a BlindIndex keyed by pepper.
+func NewBlindIndex(pepper []byte) *BlindIndex {
+	return &BlindIndex{pepper: pepper}
+}
+
+// Token returns the hex-encoded token of value for attribute field. The
+// field is bound in, so equal values of different attributes do not share
+// a token.
+func (b *BlindIndex) Token(field, value string) string {
+	mac := hmac.New(sha256.New, b.pepper)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,22 @@
+package msgcrypt_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+func TestBlindIndex_Token(t *testing.T) {
+	idx := msgcrypt.NewBlindIndex([]byte("pepper-0123456789abcdef-0123456789"))
+
+	token := idx.Token("phone_number", "+14155550100")
+
+	assert.Len(t, token, 64)
+	assert.NotContains(t, token, "4155550100")
+	assert.Equal(t, token, idx.Token("phone_number", "+14155550100"), "equal values share a token")
+	assert.NotEqual(t, token, idx.Token("phone_number", "+14155550101"))
+	assert.NotEqual(t, token, idx.Token("display_name", "+14155550100"), "fields are bound in")
+	assert.NotEqual(t, token, msgcrypt.NewBlindIndex([]byte("another pepper")).Token("phone_number", "+14155550100"))
+}
@@ -0,0 +1,167 @@
+package msgcrypt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: DynamoKeyStore satisfies KeyStore.
+var _ KeyStore = (*DynamoKeyStore)(nil)
+
+// keyDynamoDB is a narrow, consumer-defined interface for DynamoDB
+// operations required by the key store.
+type keyDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+// chatKeyItem is a chat_keys item (PK chat_id, SK version).
+type chatKeyItem struct {
+	ChatID     string `dynamodbav:"chat_id"`
+	Version    int    `dynamodbav:"version"`
+	WrappedKey []byte `dynamodbav:"wrapped_key"`
+	CreatedAt  string `dynamodbav:"created_at"`
+}
+
+func (item chatKeyItem) wrappedKey() (WrappedKey, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	if err != nil {
+		return WrappedKey{}, fmt.Errorf("parse created_at: %w", err)
+	}
+	return WrappedKey{Version: item.Version, Wrapped: item.WrappedKey, CreatedAt: createdAt}, nil
+}
+
+// DynamoKeyStore keeps chats' wrapped data keys in the chat_keys table.
+// Keys are never updated or deleted: deleting a version would make the
+// messages sealed under it unreadable.
+type DynamoKeyStore struct {
+	db    keyDynamoDB
+	table string
+}
+
+// NewDynamoKeyStore creates a DynamoKeyStore backed by the given DynamoDB client.
+func NewDynamoKeyStore(db keyDynamoDB, table string) *DynamoKeyStore {
+	return &DynamoKeyStore{db: db, table: table}
+}
+
+// LatestKey returns the chat's highest key version, or domain.ErrNotFound
+// when the chat has none.
+func (s *DynamoKeyStore) LatestKey(ctx context.Context, chatID string) (WrappedKey, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_keys.latest")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "Query"),
+	)
+
+	keyExpr := "chat_id = :cid"
+	limit := int32(1)
+	out, err := s.db.Query(ctx, &dynamo.QueryInput{
+		TableName:              &s.table,
+		KeyConditionExpression: &keyExpr,
+		ExpressionAttributeValues: map[string]dynamo.AttributeValue{
+			":cid": &dynamo.AttributeValueMemberS{Value: chatID},
+		},
+		ScanIndexForward: dynamo.Bool(false),
+		Limit:            &limit,
+		ConsistentRead:   dynamo.Bool(true),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return WrappedKey{}, fmt.Errorf("key store: query %s: %w", chatID, err)
+	}
+	if len(out.Items) == 0 {
+		return WrappedKey{}, fmt.Errorf("key store: chat %s: %w", chatID, domain.ErrNotFound)
+	}
+	return unmarshalChatKey(out.Items[0])
+}
+
+// Key returns one version of the chat's key.
+func (s *DynamoKeyStore) Key(ctx context.Context, chatID string, version int) (WrappedKey, error) {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_keys.get")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "GetItem"),
+	)
+
+	out, err := s.db.GetItem(ctx, &dynamo.GetItemInput{
+		TableName: &s.table,
+		Key: map[string]dynamo.AttributeValue{
+			"chat_id": &dynamo.AttributeValueMemberS{Value: chatID},
+			"version": &dynamo.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		},
+		ConsistentRead: dynamo.Bool(true),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return WrappedKey{}, fmt.Errorf("key store: get %s/%d: %w", chatID, version, err)
+	}
+	if len(out.Item) == 0 {
+		return WrappedKey{}, fmt.Errorf("key store: chat %s key %d: %w", chatID, version, domain.ErrNotFound)
+	}
+	return unmarshalChatKey(out.Item)
+}
+
+// PutKey stores a new key version. Returns domain.ErrAlreadyExists when
+// another writer stored that version first.
+func (s *DynamoKeyStore) PutKey(ctx context.Context, chatID string, key WrappedKey) error {
+	ctx, span := tracer.Start(ctx, "dynamo.chat_keys.put")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", "PutItem"),
+	)
+
+	av, err := dynamo.MarshalMap(chatKeyItem{
+		ChatID:     chatID,
+		Version:    key.Version,
+		WrappedKey: key.Wrapped,
+		CreatedAt:  key.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return fmt.Errorf("key store: marshal: %w", err)
+	}
+
+	condExpr := "attribute_not_exists(chat_id)"
+	_, err = s.db.PutItem(ctx, &dynamo.PutItemInput{
+		TableName:           &s.table,
+		Item:                av,
+		ConditionExpression: &condExpr,
+	})
+	if dynamo.IsConditionalCheckFailed(err) {
+		return fmt.Errorf("key store: chat %s key %d: %w", chatID, key.Version, domain.ErrAlreadyExists)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("key store: put %s/%d: %w", chatID, key.Version, err)
+	}
+	return nil
+}
+
+func unmarshalChatKey(av map[string]dynamo.AttributeValue) (WrappedKey, error) {
+	var item chatKeyItem
+	if err := dynamo.UnmarshalMap(av, &item); err != nil {
+		return WrappedKey{}, fmt.Errorf("key store: unmarshal: %w", err)
+	}
+	key, err := item.wrappedKey()
+	if err != nil {
+		return WrappedKey{}, fmt.Errorf("key store: %w", err)
+	}
+	if key.Version < 1 || len(key.Wrapped) == 0 {
+		return WrappedKey{}, fmt.Errorf("key store: chat %s key %d is malformed", item.ChatID, item.Version)
+	}
+	return key, nil
+}
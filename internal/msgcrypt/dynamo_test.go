@@ -0,0 +1,100 @@
+package msgcrypt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// ---------------------------------------------------------------------------
+// Stub — implements keyDynamoDB for unit tests.
+// ---------------------------------------------------------------------------
+
+type stubKeyDynamo struct {
+	getItemFn func(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error)
+	putItemFn func(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error)
+	queryFn   func(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error)
+}
+
+func (s *stubKeyDynamo) GetItem(ctx context.Context, params *dynamo.GetItemInput, optFns ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+	return s.getItemFn(ctx, params, optFns...)
+}
+
+func (s *stubKeyDynamo) PutItem(ctx context.Context, params *dynamo.PutItemInput, optFns ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+	return s.putItemFn(ctx, params, optFns...)
+}
+
+func (s *stubKeyDynamo) Query(ctx context.Context, params *dynamo.QueryInput, optFns ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+	return s.queryFn(ctx, params, optFns...)
+}
+
+var _ keyDynamoDB = (*stubKeyDynamo)(nil)
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+func TestDynamoKeyStore_RoundTrip(t *testing.T) {
+	key := WrappedKey{Version: 2, Wrapped: []byte("wrapped"), CreatedAt: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)}
+
+	var stored map[string]dynamo.AttributeValue
+	store := NewDynamoKeyStore(&stubKeyDynamo{
+		putItemFn: func(_ context.Context, params *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+			assert.Equal(t, "chat_keys", *params.TableName)
+			assert.Equal(t, "attribute_not_exists(chat_id)", *params.ConditionExpression)
+			stored = params.Item
+			return &dynamo.PutItemOutput{}, nil
+		},
+		getItemFn: func(_ context.Context, params *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			assert.Equal(t, &dynamo.AttributeValueMemberN{Value: "2"}, params.Key["version"])
+			return &dynamo.GetItemOutput{Item: stored}, nil
+		},
+		queryFn: func(_ context.Context, params *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			assert.False(t, *params.ScanIndexForward, "newest version first")
+			assert.Equal(t, int32(1), *params.Limit)
+			return &dynamo.QueryOutput{Items: []map[string]dynamo.AttributeValue{stored}}, nil
+		},
+	}, "chat_keys")
+	ctx := context.Background()
+
+	require.NoError(t, store.PutKey(ctx, "chat-1", key))
+
+	got, err := store.Key(ctx, "chat-1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	got, err = store.LatestKey(ctx, "chat-1")
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+}
+
+func TestDynamoKeyStore_Errors(t *testing.T) {
+	store := NewDynamoKeyStore(&stubKeyDynamo{
+		putItemFn: func(_ context.Context, _ *dynamo.PutItemInput, _ ...func(*dynamo.Options)) (*dynamo.PutItemOutput, error) {
+			return nil, dynamo.ErrConditionalCheckFailed()
+		},
+		getItemFn: func(_ context.Context, _ *dynamo.GetItemInput, _ ...func(*dynamo.Options)) (*dynamo.GetItemOutput, error) {
+			return &dynamo.GetItemOutput{}, nil
+		},
+		queryFn: func(_ context.Context, _ *dynamo.QueryInput, _ ...func(*dynamo.Options)) (*dynamo.QueryOutput, error) {
+			return nil, errors.New("throttled")
+		},
+	}, "chat_keys")
+	ctx := context.Background()
+
+	err := store.PutKey(ctx, "chat-1", WrappedKey{Version: 1, Wrapped: []byte("w"), CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, domain.ErrAlreadyExists)
+
+	_, err = store.Key(ctx, "chat-1", 1)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	_, err = store.LatestKey(ctx, "chat-1")
+	assert.ErrorContains(t, err, "throttled")
+}
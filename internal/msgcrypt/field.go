@@ -0,0 +1,201 @@
+package msgcrypt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UsersKeyScope is the key scope of the users table's personal data. It
+// shares the chat_keys table with chats, whose IDs are UUIDs.
+const UsersKeyScope = "pii#users"
+
+// fieldPrefix marks an encrypted field value: "enc:<version>:<base64>".
+// Plaintext values written before encryption was turned on have no
+// prefix; phone numbers start with "+", and a plaintext display name
+// starting with it reads as a malformed encrypted value.
+const fieldPrefix = "enc:"
+
+// FieldCipher encrypts single string attributes under the versioned keys
+// of one key scope, e.g. the phone numbers and display names in the users
+// table. Searchable values encrypt deterministically, so an index on the
+// encrypted attribute still finds them; other values use a fresh nonce.
+//
+// A nil *FieldCipher stores plaintext: its Encrypt methods return their
+// input and it reads only plaintext values.
+type FieldCipher struct {
+	ring  *Keyring
+	scope string
+}
+
+// NewFieldCipher creates a FieldCipher using ring's keys for scope. The
+// ring should rotate only on Rotate (Config.RotateAfter < 0): every
+// version in use costs searches an extra query until re-encryption moves
+// values to the newest.
+func NewFieldCipher(ring *Keyring, scope string) *FieldCipher {
+	return &FieldCipher{ring: ring, scope: scope}
+}
+
+// IsEncrypted reports whether value is an encrypted field value.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, fieldPrefix)
+}
+
+// Encrypt encrypts plaintext for attribute field with a fresh nonce. An
+// empty plaintext stays empty, so optional attributes stay absent.
+func (c *FieldCipher) Encrypt(ctx context.Context, field, plaintext string) (string, error) {
+	return c.encrypt(ctx, field, plaintext, false)
+}
+
+// EncryptSearchable encrypts plaintext for attribute field so that equal
+// plaintexts under the same key version encrypt equally.
+func (c *FieldCipher) EncryptSearchable(ctx context.Context, field, plaintext string) (string, error) {
+	return c.encrypt(ctx, field, plaintext, true)
+}
+
+func (c *FieldCipher) encrypt(ctx context.Context, field, plaintext string, searchable bool) (string, error) {
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	if c.ring.keyID == "" {
+		return "", errors.New("msgcrypt: encrypt field: no KMS key configured")
+	}
+	key, err := c.ring.current(ctx, c.scope)
+	if err != nil {
+		return "", fmt.Errorf("msgcrypt: encrypt %s: %w", field, err)
+	}
+	value, err := c.seal(key, field, plaintext, searchable)
+	if err != nil {
+		return "", fmt.Errorf("msgcrypt: encrypt %s: %w", field, err)
+	}
+	return value, nil
+}
+
+// SearchValues returns the values attribute field may hold for plaintext:
+// its searchable encryption under each key version, newest first, then
+// plaintext itself for rows not yet encrypted.
+func (c *FieldCipher) SearchValues(ctx context.Context, field, plaintext string) ([]string, error) {
+	if c == nil || plaintext == "" {
+		return []string{plaintext}, nil
+	}
+	newest, err := c.CurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, newest+1)
+	for version := newest; version >= 1; version-- {
+		key, err := c.ring.version(ctx, c.scope, version)
+		if err != nil {
+			return nil, fmt.Errorf("msgcrypt: search %s: %w", field, err)
+		}
+		value, err := c.seal(key, field, plaintext, true)
+		if err != nil {
+			return nil, fmt.Errorf("msgcrypt: search %s: %w", field, err)
+		}
+		values = append(values, value)
+	}
+	return append(values, plaintext), nil
+}
+
+// Decrypt returns the plaintext of attribute field's value. Plaintext
+// values are returned as they are.
+func (c *FieldCipher) Decrypt(ctx context.Context, field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("msgcrypt: decrypt %s: value is encrypted and no keyring is configured", field)
+	}
+	version, data, err := parseField(value)
+	if err != nil {
+		return "", fmt.Errorf("msgcrypt: decrypt %s: %w", field, err)
+	}
+	key, err := c.ring.version(ctx, c.scope, version)
+	if err != nil {
+		return "", fmt.Errorf("msgcrypt: decrypt %s: %w", field, err)
+	}
+	size := key.aead.NonceSize()
+	if len(data) < size+key.aead.Overhead() {
+		return "", fmt.Errorf("msgcrypt: decrypt %s: ciphertext too short", field)
+	}
+	plaintext, err := key.aead.Open(nil, data[:size], data[size:], c.additionalData(field))
+	if err != nil {
+		return "", fmt.Errorf("msgcrypt: decrypt %s: value does not authenticate", field)
+	}
+	return string(plaintext), nil
+}
+
+// CurrentVersion returns the key version new values are encrypted with,
+// creating the scope's first key if it has none. c must not be nil.
+func (c *FieldCipher) CurrentVersion(ctx context.Context) (int, error) {
+	if c.ring.keyID == "" {
+		return 0, errors.New("msgcrypt: field key: no KMS key configured")
+	}
+	key, err := c.ring.current(ctx, c.scope)
+	if err != nil {
+		return 0, fmt.Errorf("msgcrypt: field key: %w", err)
+	}
+	return key.key.version, nil
+}
+
+// Version returns the key version value is encrypted with, or 0 for a
+// plaintext value.
+func Version(value string) (int, error) {
+	if !IsEncrypted(value) {
+		return 0, nil
+	}
+	version, _, err := parseField(value)
+	return version, err
+}
+
+// Rotate gives the scope a new key version. Values keep their version
+// until re-encrypted.
+func (c *FieldCipher) Rotate(ctx context.Context) (int, error) {
+	return c.ring.Rotate(ctx, c.scope)
+}
+
+func (c *FieldCipher) seal(key *cachedKey, field, plaintext string, searchable bool) (string, error) {
+	size := key.aead.NonceSize()
+	nonce := make([]byte, size, size+len(plaintext)+key.aead.Overhead())
+	if searchable {
+		// A synthetic nonce: a MAC of the plaintext, so equal plaintexts
+		// share one and distinct ones do not.
+		mac := hmac.New(sha256.New, key.nonceKey)
+		mac.Write(c.additionalData(field))
+		mac.Write([]byte{0})
+		mac.Write([]byte(plaintext))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+	data := key.aead.Seal(nonce, nonce, []byte(plaintext), c.additionalData(field))
+	return fieldPrefix + strconv.Itoa(key.key.version) + ":" + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// additionalData binds a value to its scope and attribute, so one copied
+// into another attribute fails to decrypt.
+func (c *FieldCipher) additionalData(field string) []byte {
+	return []byte(c.scope + "\x00" + field)
+}
+
+func parseField(value string) (int, []byte, error) {
+	version, data, ok := strings.Cut(strings.TrimPrefix(value, fieldPrefix), ":")
+	if !ok {
+		return 0, nil, errors.New("malformed encrypted value")
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 {
+		return 0, nil, errors.New("malformed encrypted value version")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return 0, nil, errors.New("malformed encrypted value encoding")
+	}
+	return v, raw, nil
+}
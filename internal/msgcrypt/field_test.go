@@ -0,0 +1,100 @@
+package msgcrypt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt/msgcrypttest"
+)
+
+func TestFieldCipher(t *testing.T) {
+	c := msgcrypttest.NewFieldCipher(t)
+	ctx := context.Background()
+
+	phone, err := c.EncryptSearchable(ctx, "phone_number", "+14155550100")
+	require.NoError(t, err)
+	assert.True(t, msgcrypt.IsEncrypted(phone))
+	assert.NotContains(t, phone, "4155550100")
+
+	again, err := c.EncryptSearchable(ctx, "phone_number", "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, phone, again, "searchable values encrypt deterministically")
+	other, err := c.EncryptSearchable(ctx, "phone_number", "+14155550101")
+	require.NoError(t, err)
+	assert.NotEqual(t, phone, other)
+
+	name, err := c.Encrypt(ctx, "display_name", "Alice")
+	require.NoError(t, err)
+	again, err = c.Encrypt(ctx, "display_name", "Alice")
+	require.NoError(t, err)
+	assert.NotEqual(t, name, again, "other values use a fresh nonce")
+
+	got, err := c.Decrypt(ctx, "phone_number", phone)
+	require.NoError(t, err)
+	assert.Equal(t, "+14155550100", got)
+	got, err = c.Decrypt(ctx, "display_name", name)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", got)
+
+	t.Run("plaintext and empty values pass through", func(t *testing.T) {
+		got, err := c.Decrypt(ctx, "phone_number", "+14155550100")
+		require.NoError(t, err)
+		assert.Equal(t, "+14155550100", got)
+
+		empty, err := c.EncryptSearchable(ctx, "phone_number", "")
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
+
+	t.Run("values are bound to their attribute", func(t *testing.T) {
+		_, err := c.Decrypt(ctx, "display_name", phone)
+		assert.ErrorContains(t, err, "does not authenticate")
+
+		_, err = c.Decrypt(ctx, "phone_number", "enc:x:abc")
+		assert.ErrorContains(t, err, "malformed")
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		version, err := c.Rotate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, version)
+
+		rotated, err := c.EncryptSearchable(ctx, "phone_number", "+14155550100")
+		require.NoError(t, err)
+		assert.NotEqual(t, phone, rotated)
+		v, err := msgcrypt.Version(rotated)
+		require.NoError(t, err)
+		assert.Equal(t, 2, v)
+
+		values, err := c.SearchValues(ctx, "phone_number", "+14155550100")
+		require.NoError(t, err)
+		assert.Equal(t, []string{rotated, phone, "+14155550100"}, values,
+			"searches find every version and plaintext rows")
+
+		got, err := c.Decrypt(ctx, "phone_number", phone)
+		require.NoError(t, err, "old versions stay readable")
+		assert.Equal(t, "+14155550100", got)
+	})
+}
+
+func TestFieldCipher_Nil(t *testing.T) {
+	var c *msgcrypt.FieldCipher
+	ctx := context.Background()
+
+	got, err := c.EncryptSearchable(ctx, "phone_number", "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, "+14155550100", got)
+
+	values, err := c.SearchValues(ctx, "phone_number", "+14155550100")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+14155550100"}, values)
+
+	encrypted, err := msgcrypttest.NewFieldCipher(t).Encrypt(ctx, "display_name", "Alice")
+	require.NoError(t, err)
+	_, err = c.Decrypt(ctx, "display_name", encrypted)
+	assert.ErrorContains(t, err, "no keyring")
+}
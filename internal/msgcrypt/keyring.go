@@ -0,0 +1,415 @@
+// Package msgcrypt encrypts message content at rest. Each chat has its own
+// data keys, generated and wrapped by KMS. The wrapped keys live in the
+// chat_keys table, and only unwrapped copies, cached in memory for a few
+// minutes, can read a chat's messages. A dump of the messages table is
+// ciphertext, and an exposed data key reveals one chat rather than all of
+// them.
+//
+// Content is sealed with AES-256-GCM, authenticating the chat and message
+// IDs with it, so sealed content copied onto another message fails to
+// open. Keys rotate by age: a chat gets a new key version once its current
+// one is old, and earlier versions stay readable.
+//
+// FieldCipher applies the same keys to single attributes, such as users'
+// phone numbers, under a key scope in place of a chat ID. BlindIndex
+// derives the tokens that let such attributes be looked up.
+package msgcrypt
+
+import (
+	"container/list"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+var (
+	tracer = otel.Tracer("msgcrypt")
+
+	keyCacheLookups metric.Int64Counter
+	keysCreated     metric.Int64Counter
+
+	keyCacheHitAttr  = metric.WithAttributes(attribute.String("result", "hit"))
+	keyCacheMissAttr = metric.WithAttributes(attribute.String("result", "miss"))
+)
+
+func init() {
+	m := otel.Meter("msgcrypt")
+
+	keyCacheLookups, _ = m.Int64Counter("msgcrypt_key_cache_lookups_total",
+		metric.WithDescription("Chat data key cache lookups, by result (hit, miss)"))
+	keysCreated, _ = m.Int64Counter("msgcrypt_keys_created_total",
+		metric.WithDescription("Chat data keys generated, by reason (first, rotation, manual)"))
+}
+
+const (
+	// dataKeyBytes is the length of an AES-256 data key.
+	dataKeyBytes = 32
+
+	// nonceKeyInfo labels the HKDF derivation of a data key's nonce key,
+	// keeping it independent of the AES key itself.
+	nonceKeyInfo = "msgcrypt synthetic nonce"
+)
+
+// KMS generates data keys wrapped under a master key and unwraps them.
+// Wrapping binds the encryption context, which must be presented again to
+// unwrap.
+type KMS interface {
+	GenerateDataKey(ctx context.Context, keyID string, encryptionContext map[string]string) (plaintext, wrapped []byte, err error)
+	Decrypt(ctx context.Context, wrapped []byte, encryptionContext map[string]string) ([]byte, error)
+}
+
+// KeyStore persists chats' wrapped data keys by version.
+type KeyStore interface {
+	// LatestKey returns the chat's highest version, or domain.ErrNotFound.
+	LatestKey(ctx context.Context, chatID string) (WrappedKey, error)
+	Key(ctx context.Context, chatID string, version int) (WrappedKey, error)
+	// PutKey returns domain.ErrAlreadyExists if the version is taken.
+	PutKey(ctx context.Context, chatID string, key WrappedKey) error
+}
+
+// WrappedKey is one version of a chat's data key, as wrapped by KMS.
+type WrappedKey struct {
+	Version   int
+	Wrapped   []byte
+	CreatedAt time.Time
+}
+
+// Sealer seals message content. *Keyring implements it; stores take a nil
+// Sealer to write plaintext.
+type Sealer interface {
+	Seal(ctx context.Context, chatID, messageID string, plaintext []byte) (Sealed, error)
+}
+
+// Opener opens sealed message content. *Keyring implements it.
+type Opener interface {
+	Open(ctx context.Context, chatID, messageID string, sealed Sealed) ([]byte, error)
+}
+
+// Sealed is encrypted content and the chat key version that opens it.
+// Ciphertext starts with the GCM nonce.
+type Sealed struct {
+	KeyVersion int
+	Ciphertext []byte
+}
+
+// Config holds the dependencies for Keyring.
+type Config struct {
+	KMS  KMS
+	Keys KeyStore
+	// KeyID is the KMS key wrapping new data keys. A Keyring without one
+	// opens content but cannot seal it.
+	KeyID        string
+	RotateAfter  time.Duration // zero is domain.ChatKeyRotationPeriod; negative rotates only on Rotate
+	CacheTTL     time.Duration // zero is domain.ChatKeyCacheTTL
+	CacheEntries int           // zero is domain.ChatKeyCacheEntries
+	Clock        domain.Clock
+}
+
+// Compile-time check: Keyring satisfies Sealer and Opener.
+var (
+	_ Sealer = (*Keyring)(nil)
+	_ Opener = (*Keyring)(nil)
+)
+
+// Keyring seals and opens message content with per-chat data keys. Safe
+// for concurrent use.
+type Keyring struct {
+	kms         KMS
+	keys        KeyStore
+	keyID       string
+	rotateAfter time.Duration
+	cacheTTL    time.Duration
+	capacity    int
+	clock       domain.Clock
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	latest  map[string]int // newest cached version per chat
+	lru     list.List      // front is most recently used; values are *cachedKey
+}
+
+type cacheKey struct {
+	chatID  string
+	version int
+}
+
+type cachedKey struct {
+	key       cacheKey
+	aead      cipher.AEAD
+	nonceKey  []byte // derives synthetic nonces for searchable fields
+	createdAt time.Time
+	expires   time.Time
+}
+
+// New creates a Keyring.
+func New(cfg Config) (*Keyring, error) {
+	if cfg.KMS == nil || cfg.Keys == nil {
+		return nil, errors.New("msgcrypt: KMS and Keys are required")
+	}
+	if cfg.RotateAfter == 0 {
+		cfg.RotateAfter = domain.ChatKeyRotationPeriod
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = domain.ChatKeyCacheTTL
+	}
+	if cfg.CacheEntries <= 0 {
+		cfg.CacheEntries = domain.ChatKeyCacheEntries
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = domain.RealClock{}
+	}
+	return &Keyring{
+		kms:         cfg.KMS,
+		keys:        cfg.Keys,
+		keyID:       cfg.KeyID,
+		rotateAfter: cfg.RotateAfter,
+		cacheTTL:    cfg.CacheTTL,
+		capacity:    cfg.CacheEntries,
+		clock:       cfg.Clock,
+		entries:     make(map[cacheKey]*list.Element),
+		latest:      make(map[string]int),
+	}, nil
+}
+
+// Seal encrypts the content of message messageID in chat chatID under the
+// chat's current key, creating the chat's first key or rotating an old one
+// as needed.
+func (k *Keyring) Seal(ctx context.Context, chatID, messageID string, plaintext []byte) (Sealed, error) {
+	if k.keyID == "" {
+		return Sealed{}, errors.New("msgcrypt: seal: no KMS key configured")
+	}
+	key, err := k.current(ctx, chatID)
+	if err != nil {
+		return Sealed{}, fmt.Errorf("msgcrypt: seal %s/%s: %w", chatID, messageID, err)
+	}
+
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return Sealed{}, fmt.Errorf("msgcrypt: seal: nonce: %w", err)
+	}
+	return Sealed{
+		KeyVersion: key.key.version,
+		Ciphertext: key.aead.Seal(nonce, nonce, plaintext, additionalData(chatID, messageID)),
+	}, nil
+}
+
+// Open decrypts content sealed for message messageID in chat chatID.
+func (k *Keyring) Open(ctx context.Context, chatID, messageID string, sealed Sealed) ([]byte, error) {
+	key, err := k.version(ctx, chatID, sealed.KeyVersion)
+	if err != nil {
+		return nil, fmt.Errorf("msgcrypt: open %s/%s: %w", chatID, messageID, err)
+	}
+
+	size := key.aead.NonceSize()
+	if len(sealed.Ciphertext) < size+key.aead.Overhead() {
+		return nil, fmt.Errorf("msgcrypt: open %s/%s: ciphertext too short", chatID, messageID)
+	}
+	plaintext, err := key.aead.Open(nil, sealed.Ciphertext[:size], sealed.Ciphertext[size:], additionalData(chatID, messageID))
+	if err != nil {
+		return nil, fmt.Errorf("msgcrypt: open %s/%s: content does not authenticate", chatID, messageID)
+	}
+	return plaintext, nil
+}
+
+// Rotate gives the chat a new key version now rather than when its current
+// one ages out, e.g. after a suspected exposure. Content already sealed
+// keeps its version. Returns the version new content is sealed with.
+func (k *Keyring) Rotate(ctx context.Context, chatID string) (int, error) {
+	if k.keyID == "" {
+		return 0, errors.New("msgcrypt: rotate: no KMS key configured")
+	}
+	next := 1
+	latest, err := k.keys.LatestKey(ctx, chatID)
+	switch {
+	case err == nil:
+		next = latest.Version + 1
+	case !errors.Is(err, domain.ErrNotFound):
+		return 0, fmt.Errorf("msgcrypt: rotate %s: %w", chatID, err)
+	}
+	key, err := k.create(ctx, chatID, next, "manual")
+	if err != nil {
+		return 0, fmt.Errorf("msgcrypt: rotate %s: %w", chatID, err)
+	}
+	return key.key.version, nil
+}
+
+// current returns the chat's newest key, creating a new version when the
+// chat has none or its newest is due for rotation.
+func (k *Keyring) current(ctx context.Context, chatID string) (*cachedKey, error) {
+	now := k.clock.Now()
+	if key := k.cachedLatest(chatID, now); key != nil && !k.due(key.createdAt, now) {
+		keyCacheLookups.Add(ctx, 1, keyCacheHitAttr)
+		return key, nil
+	}
+	keyCacheLookups.Add(ctx, 1, keyCacheMissAttr)
+
+	latest, err := k.keys.LatestKey(ctx, chatID)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return k.create(ctx, chatID, 1, "first")
+	case err != nil:
+		return nil, err
+	case k.due(latest.CreatedAt, now):
+		return k.create(ctx, chatID, latest.Version+1, "rotation")
+	}
+	return k.unwrap(ctx, chatID, latest)
+}
+
+// due reports whether a key created at createdAt is due for rotation.
+func (k *Keyring) due(createdAt, now time.Time) bool {
+	return k.rotateAfter > 0 && now.Sub(createdAt) >= k.rotateAfter
+}
+
+// version returns one version of the chat's key.
+func (k *Keyring) version(ctx context.Context, chatID string, version int) (*cachedKey, error) {
+	if key := k.cached(cacheKey{chatID, version}, k.clock.Now()); key != nil {
+		keyCacheLookups.Add(ctx, 1, keyCacheHitAttr)
+		return key, nil
+	}
+	keyCacheLookups.Add(ctx, 1, keyCacheMissAttr)
+
+	wrapped, err := k.keys.Key(ctx, chatID, version)
+	if err != nil {
+		return nil, err
+	}
+	return k.unwrap(ctx, chatID, wrapped)
+}
+
+// create generates and stores the chat's key version. If another writer
+// stored that version first, its key is used instead.
+func (k *Keyring) create(ctx context.Context, chatID string, version int, reason string) (*cachedKey, error) {
+	plaintext, wrapped, err := k.kms.GenerateDataKey(ctx, k.keyID, encryptionContext(chatID, version))
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plaintext)
+
+	key := WrappedKey{Version: version, Wrapped: wrapped, CreatedAt: k.clock.Now().UTC()}
+	err = k.keys.PutKey(ctx, chatID, key)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		theirs, err := k.keys.Key(ctx, chatID, version)
+		if err != nil {
+			return nil, err
+		}
+		return k.unwrap(ctx, chatID, theirs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keysCreated.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	return k.add(chatID, key, plaintext)
+}
+
+// unwrap has KMS unwrap key and caches it.
+func (k *Keyring) unwrap(ctx context.Context, chatID string, key WrappedKey) (*cachedKey, error) {
+	plaintext, err := k.kms.Decrypt(ctx, key.Wrapped, encryptionContext(chatID, key.Version))
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plaintext)
+	return k.add(chatID, key, plaintext)
+}
+
+// add builds the cipher for an unwrapped key and caches it, evicting the
+// least recently used keys beyond capacity.
+func (k *Keyring) add(chatID string, key WrappedKey, plaintext []byte) (*cachedKey, error) {
+	if len(plaintext) != dataKeyBytes {
+		return nil, fmt.Errorf("data key %d is %d bytes, want %d", key.Version, len(plaintext), dataKeyBytes)
+	}
+	block, err := aes.NewCipher(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceKey, err := hkdf.Key(sha256.New, plaintext, nil, nonceKeyInfo, dataKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cachedKey{
+		key:       cacheKey{chatID, key.Version},
+		aead:      aead,
+		nonceKey:  nonceKey,
+		createdAt: key.CreatedAt,
+		expires:   k.clock.Now().Add(k.cacheTTL),
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if el, ok := k.entries[entry.key]; ok {
+		k.remove(el)
+	}
+	k.entries[entry.key] = k.lru.PushFront(entry)
+	if v, ok := k.latest[chatID]; !ok || key.Version >= v {
+		k.latest[chatID] = key.Version
+	}
+	for k.lru.Len() > k.capacity {
+		k.remove(k.lru.Back())
+	}
+	return entry, nil
+}
+
+// cachedLatest returns the chat's newest cached key, if still fresh.
+func (k *Keyring) cachedLatest(chatID string, now time.Time) *cachedKey {
+	k.mu.Lock()
+	version, ok := k.latest[chatID]
+	k.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return k.cached(cacheKey{chatID, version}, now)
+}
+
+// cached returns the cached key, if still fresh.
+func (k *Keyring) cached(key cacheKey, now time.Time) *cachedKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	el, ok := k.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*cachedKey)
+	if !now.Before(entry.expires) {
+		k.remove(el)
+		return nil
+	}
+	k.lru.MoveToFront(el)
+	return entry
+}
+
+// remove drops an entry. Callers hold mu.
+func (k *Keyring) remove(el *list.Element) {
+	entry := k.lru.Remove(el).(*cachedKey)
+	delete(k.entries, entry.key)
+	if k.latest[entry.key.chatID] == entry.key.version {
+		delete(k.latest, entry.key.chatID)
+	}
+}
+
+// encryptionContext binds a wrapped key to its chat and version, so KMS
+// refuses to unwrap it for any other.
+func encryptionContext(chatID string, version int) map[string]string {
+	return map[string]string{"chat_id": chatID, "key_version": strconv.Itoa(version)}
+}
+
+// additionalData authenticates the message sealed content belongs to.
+func additionalData(chatID, messageID string) []byte {
+	return []byte(chatID + "\x00" + messageID)
+}
@@ -0,0 +1,161 @@
+package msgcrypt_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt/msgcrypttest"
+)
+
+var testStart = time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+type keyringHarness struct {
+	kms   *msgcrypttest.KMS
+	store *msgcrypttest.KeyStore
+	clock *domaintest.FakeClock
+	ring  *msgcrypt.Keyring
+}
+
+func newKeyringHarness(t *testing.T, keyID string) *keyringHarness {
+	t.Helper()
+	h := &keyringHarness{kms: msgcrypttest.NewKMS(), store: msgcrypttest.NewKeyStore(), clock: domaintest.NewFakeClock(testStart)}
+	h.ring = h.newKeyring(t, keyID)
+	return h
+}
+
+// newKeyring returns another process's keyring over the same KMS and store.
+func (h *keyringHarness) newKeyring(t *testing.T, keyID string) *msgcrypt.Keyring {
+	t.Helper()
+	ring, err := msgcrypt.New(msgcrypt.Config{
+		KMS:         h.kms,
+		Keys:        h.store,
+		KeyID:       keyID,
+		RotateAfter: 24 * time.Hour,
+		CacheTTL:    time.Minute,
+		Clock:       h.clock,
+	})
+	require.NoError(t, err)
+	return ring
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	h := newKeyringHarness(t, "alias/messages")
+	ctx := context.Background()
+
+	sealed, err := h.ring.Seal(ctx, "chat-1", "msg-1", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sealed.KeyVersion)
+	assert.NotContains(t, string(sealed.Ciphertext), "hello")
+
+	again, err := h.ring.Seal(ctx, "chat-1", "msg-1", []byte("hello"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed.Ciphertext, again.Ciphertext, "every seal uses a fresh nonce")
+
+	got, err := h.ring.Open(ctx, "chat-1", "msg-1", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(got))
+
+	reader := h.newKeyring(t, "")
+	got, err = reader.Open(ctx, "chat-1", "msg-1", sealed)
+	require.NoError(t, err, "a keyring without a KMS key can still open")
+	assert.Equal(t, "hello", string(got))
+	_, err = reader.Seal(ctx, "chat-1", "msg-2", []byte("hi"))
+	assert.Error(t, err)
+
+	t.Run("content is bound to its message", func(t *testing.T) {
+		_, err := h.ring.Open(ctx, "chat-1", "msg-2", sealed)
+		assert.ErrorContains(t, err, "does not authenticate")
+	})
+
+	t.Run("tampered content", func(t *testing.T) {
+		tampered := msgcrypt.Sealed{KeyVersion: 1, Ciphertext: append([]byte(nil), sealed.Ciphertext...)}
+		tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+		_, err := h.ring.Open(ctx, "chat-1", "msg-1", tampered)
+		assert.Error(t, err)
+
+		_, err = h.ring.Open(ctx, "chat-1", "msg-1", msgcrypt.Sealed{KeyVersion: 1, Ciphertext: []byte("short")})
+		assert.ErrorContains(t, err, "too short")
+	})
+
+	t.Run("another chat's key cannot open it", func(t *testing.T) {
+		_, err := h.ring.Seal(ctx, "chat-2", "msg-1", []byte("other"))
+		require.NoError(t, err)
+		_, err = h.ring.Open(ctx, "chat-2", "msg-1", sealed)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyring_Cache(t *testing.T) {
+	h := newKeyringHarness(t, "alias/messages")
+	ctx := context.Background()
+
+	for i := range 10 {
+		_, err := h.ring.Seal(ctx, "chat-1", fmt.Sprint("msg-", i), []byte("hello"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, h.kms.Generated())
+	assert.Zero(t, h.kms.Decrypted(), "the generated key is cached")
+
+	h.clock.Advance(time.Minute)
+	sealed, err := h.ring.Seal(ctx, "chat-1", "msg-10", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sealed.KeyVersion)
+	assert.Equal(t, 1, h.kms.Generated())
+	assert.Equal(t, 1, h.kms.Decrypted(), "an expired key is unwrapped again")
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	h := newKeyringHarness(t, "alias/messages")
+	ctx := context.Background()
+
+	old, err := h.ring.Seal(ctx, "chat-1", "msg-1", []byte("before"))
+	require.NoError(t, err)
+
+	h.clock.Advance(24 * time.Hour)
+	rotated, err := h.ring.Seal(ctx, "chat-1", "msg-2", []byte("after"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotated.KeyVersion)
+
+	got, err := h.newKeyring(t, "").Open(ctx, "chat-1", "msg-1", old)
+	require.NoError(t, err, "old versions stay readable")
+	assert.Equal(t, "before", string(got))
+
+	version, err := h.ring.Rotate(ctx, "chat-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+	sealed, err := h.newKeyring(t, "alias/messages").Seal(ctx, "chat-1", "msg-3", []byte("now"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, sealed.KeyVersion)
+}
+
+func TestKeyring_ConcurrentFirstKey(t *testing.T) {
+	h := newKeyringHarness(t, "alias/messages")
+	ctx := context.Background()
+	other := h.newKeyring(t, "alias/messages")
+
+	// other stores version 1 after h has found the chat without keys.
+	_, err := other.Seal(ctx, "chat-1", "msg-1", []byte("first"))
+	require.NoError(t, err)
+	h.store.StaleLatest = true
+
+	sealed, err := h.ring.Seal(ctx, "chat-1", "msg-2", []byte("second"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sealed.KeyVersion)
+	assert.Equal(t, 1, h.store.Versions("chat-1"))
+
+	got, err := other.Open(ctx, "chat-1", "msg-2", sealed)
+	require.NoError(t, err, "the losing writer uses the stored key")
+	assert.Equal(t, "second", string(got))
+}
+
+func TestNew_RequiresDependencies(t *testing.T) {
+	_, err := msgcrypt.New(msgcrypt.Config{KMS: msgcrypttest.NewKMS()})
+	assert.Error(t, err)
+}
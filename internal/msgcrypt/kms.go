@@ -0,0 +1,109 @@
+package msgcrypt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/dynamo"
+)
+
+// Compile-time check: KMSClient satisfies KMS.
+var _ KMS = (*KMSClient)(nil)
+
+// kmsAPI is a narrow, consumer-defined interface for the subset of KMS
+// operations required by the keyring. The real *kms.Client satisfies it.
+type kmsAPI interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// KMSClient wraps and unwraps data keys with AWS KMS.
+type KMSClient struct {
+	client kmsAPI
+}
+
+// NewKMSClient creates a KMSClient.
+func NewKMSClient(client kmsAPI) *KMSClient {
+	return &KMSClient{client: client}
+}
+
+// GenerateDataKey returns a new AES-256 data key and its copy wrapped by
+// keyID, bound to encryptionContext.
+func (c *KMSClient) GenerateDataKey(ctx context.Context, keyID string, encryptionContext map[string]string) (plaintext, wrapped []byte, err error) {
+	ctx, span := tracer.Start(ctx, "kms.generate_data_key")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("rpc.system", "aws-api"),
+		attribute.String("rpc.service", "KMS"),
+		attribute.String("rpc.method", "GenerateDataKey"),
+	)
+
+	out, err := c.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(keyID),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, fmt.Errorf("kms: generate data key: %w", err)
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// Decrypt unwraps a data key. KMS refuses it unless encryptionContext
+// matches the one it was wrapped with.
+func (c *KMSClient) Decrypt(ctx context.Context, wrapped []byte, encryptionContext map[string]string) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "kms.decrypt")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("rpc.system", "aws-api"),
+		attribute.String("rpc.service", "KMS"),
+		attribute.String("rpc.method", "Decrypt"),
+	)
+
+	out, err := c.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    wrapped,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("kms: decrypt: %w", err)
+	}
+	return out.Plaintext, nil
+}
+
+// NewAWS creates a Keyring keeping wrapped keys in the keysTable DynamoDB
+// table and reaching KMS with the DynamoDB client's region, credentials
+// and endpoint. cfg's KMS and Keys are replaced.
+func NewAWS(client *dynamo.Client, keysTable string, cfg Config) (*Keyring, error) {
+	opts := client.DB.Options()
+	cfg.KMS = NewKMSClient(kms.NewFromConfig(aws.Config{
+		Region:       opts.Region,
+		Credentials:  opts.Credentials,
+		BaseEndpoint: opts.BaseEndpoint,
+		HTTPClient:   &http.Client{Timeout: domain.KMSRequestTimeout},
+	}))
+	cfg.Keys = NewDynamoKeyStore(client.DB, keysTable)
+	return New(cfg)
+}
+
+// NewAWSFieldCipher creates a FieldCipher for scope whose keys are kept as
+// NewAWS keeps them, wrapped by keyID. Its keys rotate only on Rotate.
+func NewAWSFieldCipher(client *dynamo.Client, keysTable, scope, keyID string, cacheTTL time.Duration) (*FieldCipher, error) {
+	ring, err := NewAWS(client, keysTable, Config{KeyID: keyID, RotateAfter: -1, CacheTTL: cacheTTL})
+	if err != nil {
+		return nil, err
+	}
+	return NewFieldCipher(ring, scope), nil
+}
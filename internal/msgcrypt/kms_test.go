@@ -0,0 +1,71 @@
+package msgcrypt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingKMS records the last input of each call and fails with err.
+type recordingKMS struct {
+	generate *kms.GenerateDataKeyInput
+	decrypt  *kms.DecryptInput
+	err      error
+}
+
+func (k *recordingKMS) GenerateDataKey(_ context.Context, in *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	k.generate = in
+	if k.err != nil {
+		return nil, k.err
+	}
+	return &kms.GenerateDataKeyOutput{Plaintext: []byte("key"), CiphertextBlob: []byte("wrapped")}, nil
+}
+
+func (k *recordingKMS) Decrypt(_ context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	k.decrypt = in
+	if k.err != nil {
+		return nil, k.err
+	}
+	return &kms.DecryptOutput{Plaintext: []byte("key")}, nil
+}
+
+func TestKMSClient_GenerateDataKey(t *testing.T) {
+	api := &recordingKMS{}
+
+	plaintext, wrapped, err := NewKMSClient(api).GenerateDataKey(context.Background(), "alias/messages", encryptionContext("chat-1", 1))
+
+	require.NoError(t, err)
+	assert.Equal(t, "key", string(plaintext))
+	assert.Equal(t, "wrapped", string(wrapped))
+	assert.Equal(t, "alias/messages", *api.generate.KeyId)
+	assert.Equal(t, kmstypes.DataKeySpecAes256, api.generate.KeySpec)
+	assert.Equal(t, map[string]string{"chat_id": "chat-1", "key_version": "1"}, api.generate.EncryptionContext)
+}
+
+func TestKMSClient_Decrypt(t *testing.T) {
+	t.Run("unwraps", func(t *testing.T) {
+		api := &recordingKMS{}
+
+		plaintext, err := NewKMSClient(api).Decrypt(context.Background(), []byte("wrapped"), encryptionContext("chat-1", 2))
+
+		require.NoError(t, err)
+		assert.Equal(t, "key", string(plaintext))
+		assert.Equal(t, "wrapped", string(api.decrypt.CiphertextBlob))
+		assert.Equal(t, "2", api.decrypt.EncryptionContext["key_version"])
+	})
+
+	t.Run("surfaces service errors", func(t *testing.T) {
+		apiErr := &kmstypes.InvalidCiphertextException{Message: aws.String("context mismatch")}
+
+		_, err := NewKMSClient(&recordingKMS{err: apiErr}).Decrypt(context.Background(), []byte("wrapped"), encryptionContext("chat-2", 1))
+
+		var invalid *kmstypes.InvalidCiphertextException
+		require.ErrorAs(t, err, &invalid)
+		assert.Contains(t, err.Error(), "kms: decrypt:")
+	})
+}
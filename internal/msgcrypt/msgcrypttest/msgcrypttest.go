@@ -0,0 +1,141 @@
+// Package msgcrypttest provides test doubles for the msgcrypt package.
+package msgcrypttest
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/msgcrypt"
+)
+
+// KMS "wraps" a data key by remembering it under an opaque handle, and
+// refuses to unwrap it with a different encryption context.
+type KMS struct {
+	mu        sync.Mutex
+	keys      map[string]wrapped
+	generated int
+	decrypted int
+}
+
+type wrapped struct {
+	key []byte
+	ctx map[string]string
+}
+
+// NewKMS creates a KMS with no keys.
+func NewKMS() *KMS { return &KMS{keys: map[string]wrapped{}} }
+
+// GenerateDataKey returns a random 32-byte key and its handle.
+func (f *KMS) GenerateDataKey(_ context.Context, keyID string, encCtx map[string]string) ([]byte, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generated++
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	handle := fmt.Sprintf("%s/%d", keyID, f.generated)
+	f.keys[handle] = wrapped{key: append([]byte(nil), key...), ctx: maps.Clone(encCtx)}
+	return key, []byte(handle), nil
+}
+
+// Decrypt returns the key behind a handle.
+func (f *KMS) Decrypt(_ context.Context, handle []byte, encCtx map[string]string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.decrypted++
+	w, ok := f.keys[string(handle)]
+	if !ok || !maps.Equal(w.ctx, encCtx) {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	return append([]byte(nil), w.key...), nil
+}
+
+// Generated returns the number of data keys generated.
+func (f *KMS) Generated() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.generated
+}
+
+// Decrypted returns the number of Decrypt calls.
+func (f *KMS) Decrypted() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.decrypted
+}
+
+// KeyStore is an in-memory msgcrypt.KeyStore.
+type KeyStore struct {
+	mu   sync.Mutex
+	keys map[string][]msgcrypt.WrappedKey // by scope, in version order
+
+	// StaleLatest makes LatestKey find nothing, as if racing a writer.
+	StaleLatest bool
+}
+
+// NewKeyStore creates an empty KeyStore.
+func NewKeyStore() *KeyStore { return &KeyStore{keys: map[string][]msgcrypt.WrappedKey{}} }
+
+// LatestKey returns the scope's highest version.
+func (s *KeyStore) LatestKey(_ context.Context, chatID string) (msgcrypt.WrappedKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.keys[chatID]
+	if len(keys) == 0 || s.StaleLatest {
+		return msgcrypt.WrappedKey{}, domain.ErrNotFound
+	}
+	return keys[len(keys)-1], nil
+}
+
+// Key returns one version of the scope's key.
+func (s *KeyStore) Key(_ context.Context, chatID string, version int) (msgcrypt.WrappedKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys[chatID] {
+		if k.Version == version {
+			return k, nil
+		}
+	}
+	return msgcrypt.WrappedKey{}, domain.ErrNotFound
+}
+
+// PutKey stores a version unless it is taken.
+func (s *KeyStore) PutKey(_ context.Context, chatID string, key msgcrypt.WrappedKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys[chatID] {
+		if k.Version == key.Version {
+			return domain.ErrAlreadyExists
+		}
+	}
+	s.keys[chatID] = append(s.keys[chatID], key)
+	return nil
+}
+
+// Versions returns the number of key versions stored for the scope.
+func (s *KeyStore) Versions(chatID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys[chatID])
+}
+
+// NewFieldCipher returns a FieldCipher for msgcrypt.UsersKeyScope on a
+// fake KMS and in-memory key store, rotating only on Rotate.
+func NewFieldCipher(t *testing.T) *msgcrypt.FieldCipher {
+	t.Helper()
+	ring, err := msgcrypt.New(msgcrypt.Config{
+		KMS:         NewKMS(),
+		Keys:        NewKeyStore(),
+		KeyID:       "alias/pii",
+		RotateAfter: -1,
+	})
+	if err != nil {
+		t.Fatalf("msgcrypttest: %v", err)
+	}
+	return msgcrypt.NewFieldCipher(ring, msgcrypt.UsersKeyScope)
+}
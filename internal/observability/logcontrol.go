@@ -0,0 +1,219 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var logRecordsDropped metric.Int64Counter
+
+func init() {
+	logRecordsDropped, _ = otel.Meter("observability").Int64Counter("log_records_dropped_total",
+		metric.WithDescription("Log records dropped by sampling, by subsystem"))
+}
+
+// SubsystemKey is the log attribute naming the package or component a
+// logger belongs to. Level overrides and sampling are keyed by it.
+const SubsystemKey = "subsystem"
+
+// Subsystem returns logger tagged with name, conventionally the package
+// path below internal/ (e.g. "gateway/app").
+func Subsystem(logger *slog.Logger, name string) *slog.Logger {
+	return logger.With(slog.String(SubsystemKey, name))
+}
+
+// LogControl adjusts logging while the process runs: the default level,
+// per-subsystem level overrides, and sampling of repetitive records. Safe
+// for concurrent use.
+type LogControl struct {
+	level     slog.LevelVar
+	overrides atomic.Pointer[map[string]slog.Level] // copy-on-write
+	mu        sync.Mutex                            // serializes override writes
+
+	perSecond int64
+	buckets   sync.Map // subsystem + "\x00" + message -> *sampleBucket
+}
+
+type sampleBucket struct {
+	second atomic.Int64
+	count  atomic.Int64
+}
+
+// NewLogControl creates a LogControl at level that keeps at most perSecond
+// records per second for each subsystem and message at info level and
+// below. Warnings and errors are never sampled. Zero disables sampling.
+func NewLogControl(level slog.Level, perSecond int) *LogControl {
+	c := &LogControl{perSecond: int64(perSecond)}
+	c.level.Set(level)
+	c.overrides.Store(&map[string]slog.Level{})
+	return c
+}
+
+// Handler wraps inner so its records are filtered by c. Level filtering in
+// inner itself should be disabled; c decides.
+func (c *LogControl) Handler(inner slog.Handler) slog.Handler {
+	return &controlHandler{inner: inner, ctl: c}
+}
+
+// SetLevel sets the level for subsystem, or the default level when
+// subsystem is empty.
+func (c *LogControl) SetLevel(subsystem string, level slog.Level) {
+	if subsystem == "" {
+		c.level.Set(level)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := maps.Clone(*c.overrides.Load())
+	next[subsystem] = level
+	c.overrides.Store(&next)
+}
+
+// ResetLevel removes subsystem's override so it follows the default level.
+func (c *LogControl) ResetLevel(subsystem string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := maps.Clone(*c.overrides.Load())
+	delete(next, subsystem)
+	c.overrides.Store(&next)
+}
+
+// Levels returns the default level and a copy of the overrides.
+func (c *LogControl) Levels() (slog.Level, map[string]slog.Level) {
+	return c.level.Level(), maps.Clone(*c.overrides.Load())
+}
+
+func (c *LogControl) levelFor(subsystem string) slog.Level {
+	if l, ok := (*c.overrides.Load())[subsystem]; ok {
+		return l
+	}
+	return c.level.Level()
+}
+
+// sample reports whether a record may be written. Buckets reset on the
+// first record of each second, so the limit is approximate under
+// concurrency.
+func (c *LogControl) sample(subsystem string, r slog.Record) bool {
+	if c.perSecond <= 0 || r.Level >= slog.LevelWarn {
+		return true
+	}
+	key := subsystem + "\x00" + r.Message
+	v, ok := c.buckets.Load(key)
+	if !ok {
+		v, _ = c.buckets.LoadOrStore(key, &sampleBucket{})
+	}
+	b := v.(*sampleBucket)
+
+	sec := r.Time.Unix()
+	if prev := b.second.Load(); prev != sec && b.second.CompareAndSwap(prev, sec) {
+		b.count.Store(0)
+	}
+	return b.count.Add(1) <= c.perSecond
+}
+
+// levelRequest is the body of a PUT to the admin endpoint. An empty level
+// removes the subsystem's override.
+type levelRequest struct {
+	Subsystem string `json:"subsystem"`
+	Level     string `json:"level"`
+}
+
+type levelResponse struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
+
+// AdminHandler serves the current levels on GET and changes one on PUT:
+//
+//	PUT /admin/loglevel {"subsystem": "gateway/app", "level": "debug"}
+//
+// Omit subsystem to change the default level. Changes are not persisted and
+// apply to this process only.
+func (c *LogControl) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("decode request: %v", err), http.StatusBadRequest)
+				return
+			}
+			if req.Level == "" && req.Subsystem != "" {
+				c.ResetLevel(req.Subsystem)
+				break
+			}
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			c.SetLevel(req.Subsystem, level)
+			LoggerFromContext(r.Context()).InfoContext(r.Context(), "log level changed",
+				slog.String(SubsystemKey, req.Subsystem), slog.String("level", level.String()))
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		level, overrides := c.Levels()
+		resp := levelResponse{Level: level.String(), Subsystems: make(map[string]string, len(overrides))}
+		for s, l := range overrides {
+			resp.Subsystems[s] = l.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// controlHandler applies a LogControl's levels and sampling in front of
+// another handler. It learns its subsystem from the SubsystemKey attribute.
+type controlHandler struct {
+	inner     slog.Handler
+	ctl       *LogControl
+	subsystem string
+}
+
+func (h *controlHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.ctl.levelFor(h.subsystem)
+}
+
+func (h *controlHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.ctl.sample(h.subsystem, r) {
+		logRecordsDropped.Add(ctx, 1, metric.WithAttributes(attribute.String(SubsystemKey, h.subsystem)))
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *controlHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	subsystem := h.subsystem
+	for _, a := range attrs {
+		if a.Key == SubsystemKey {
+			subsystem = a.Value.String()
+		}
+	}
+	return &controlHandler{inner: h.inner.WithAttrs(attrs), ctl: h.ctl, subsystem: subsystem}
+}
+
+func (h *controlHandler) WithGroup(name string) slog.Handler {
+	return &controlHandler{inner: h.inner.WithGroup(name), ctl: h.ctl, subsystem: h.subsystem}
+}
+
+// allLevels is a Leveler that enables everything, for handlers whose level
+// is decided by a LogControl in front of them.
+type allLevels struct{}
+
+func (allLevels) Level() slog.Level { return math.MinInt }
@@ -0,0 +1,116 @@
+package observability_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// newControlledLogger returns a logger filtered by ctl and the buffer it
+// writes JSON lines to.
+func newControlledLogger(ctl *observability.LogControl) (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	inner := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.Level(-100)})
+	return slog.New(ctl.Handler(inner)), &buf
+}
+
+func lines(buf *bytes.Buffer) int {
+	return strings.Count(buf.String(), "\n")
+}
+
+func TestLogControl_Levels(t *testing.T) {
+	ctl := observability.NewLogControl(slog.LevelInfo, 0)
+	logger, buf := newControlledLogger(ctl)
+	gateway := observability.Subsystem(logger, "gateway/app")
+	auth := observability.Subsystem(logger, "chatmgmt/auth")
+
+	gateway.Debug("frame received")
+	assert.Zero(t, lines(buf), "debug is below the default level")
+
+	ctl.SetLevel("gateway/app", slog.LevelDebug)
+	gateway.Debug("frame received")
+	auth.Debug("otp checked")
+	assert.Equal(t, 1, lines(buf), "only the overridden subsystem logs debug")
+
+	ctl.SetLevel("", slog.LevelError)
+	auth.Info("otp sent")
+	gateway.Debug("frame received")
+	assert.Equal(t, 2, lines(buf), "overrides outrank the default")
+
+	ctl.ResetLevel("gateway/app")
+	gateway.Warn("slow consumer")
+	assert.Equal(t, 2, lines(buf), "reset subsystem follows the default again")
+}
+
+func TestLogControl_Sampling(t *testing.T) {
+	ctl := observability.NewLogControl(slog.LevelDebug, 3)
+	logger, buf := newControlledLogger(ctl)
+	gateway := observability.Subsystem(logger, "gateway/app")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	write := func(level slog.Level, msg string, at time.Time) {
+		r := slog.NewRecord(at, level, msg, 0)
+		require.NoError(t, gateway.Handler().Handle(context.Background(), r))
+	}
+
+	for range 10 {
+		write(slog.LevelDebug, "frame received", now)
+	}
+	assert.Equal(t, 3, lines(buf), "capped per message per second")
+
+	write(slog.LevelDebug, "frame sent", now)
+	assert.Equal(t, 4, lines(buf), "messages are sampled independently")
+
+	for range 5 {
+		write(slog.LevelError, "frame received", now)
+	}
+	assert.Equal(t, 9, lines(buf), "errors are never sampled")
+
+	write(slog.LevelDebug, "frame received", now.Add(time.Second))
+	assert.Equal(t, 10, lines(buf), "the budget resets each second")
+}
+
+func TestLogControl_AdminHandler(t *testing.T) {
+	ctl := observability.NewLogControl(slog.LevelInfo, 0)
+	h := ctl.AdminHandler()
+
+	do := func(method, body string) (int, map[string]any) {
+		req := httptest.NewRequestWithContext(context.Background(), method, "/admin/loglevel", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var out map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out
+	}
+
+	code, out := do(http.MethodPut, `{"subsystem":"gateway/app","level":"debug"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "INFO", out["level"])
+	assert.Equal(t, map[string]any{"gateway/app": "DEBUG"}, out["subsystems"])
+
+	code, _ = do(http.MethodPut, `{"level":"warn"}`)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do(http.MethodPut, `{"subsystem":"gateway/app"}`)
+	require.Equal(t, http.StatusOK, code)
+
+	code, out = do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "WARN", out["level"])
+	assert.Empty(t, out["subsystems"])
+
+	code, _ = do(http.MethodPut, `{"level":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodPost, `{}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
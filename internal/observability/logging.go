@@ -14,6 +14,10 @@ type LogConfig struct {
 	Format      string // "json" or "text"
 	ServiceName string
 	Environment string
+
+	// Control, when set, takes over level filtering so levels can change at
+	// runtime and repetitive records are sampled. Level sets its default.
+	Control *LogControl
 }
 
 // sensitivePatterns contains field name patterns that should be redacted.
@@ -53,6 +57,10 @@ func InitLogger(cfg LogConfig) *slog.Logger {
 		Level:       level,
 		ReplaceAttr: redactSecrets,
 	}
+	if cfg.Control != nil {
+		cfg.Control.SetLevel("", level)
+		opts.Level = allLevels{}
+	}
 
 	var handler slog.Handler
 	if strings.ToLower(cfg.Format) == "text" {
@@ -60,6 +68,9 @@ func InitLogger(cfg LogConfig) *slog.Logger {
 	} else {
 		handler = slog.NewJSONHandler(os.Stdout, opts)
 	}
+	if cfg.Control != nil {
+		handler = cfg.Control.Handler(handler)
+	}
 
 	// Add service context to all log entries
 	logger := slog.New(handler).With(
@@ -0,0 +1,70 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var panicsRecovered metric.Int64Counter
+
+func init() {
+	panicsRecovered, _ = otel.Meter("observability").Int64Counter("panics_recovered_total",
+		metric.WithDescription("Panics recovered without crashing the process, by component"))
+}
+
+// CrashReporter forwards recovered panics to an external error tracker such
+// as Sentry. Implementations must be safe for concurrent use and should not
+// block; ReportPanic runs on the goroutine that panicked.
+type CrashReporter interface {
+	ReportPanic(ctx context.Context, component string, value any, stack []byte)
+}
+
+var crashReporter atomic.Pointer[CrashReporter]
+
+// SetCrashReporter installs r as the process-wide crash reporter. Nil
+// disables reporting. Call it once at startup.
+func SetCrashReporter(r CrashReporter) {
+	if r == nil {
+		crashReporter.Store(nil)
+		return
+	}
+	crashReporter.Store(&r)
+}
+
+// Recover stops a panic in progress and handles it with HandlePanic. It must
+// be deferred directly:
+//
+//	defer observability.Recover(ctx, "kafka_consumer")
+func Recover(ctx context.Context, component string) {
+	if v := recover(); v != nil {
+		_ = HandlePanic(ctx, component, v)
+	}
+}
+
+// HandlePanic records a value returned by recover: it logs the stack with the
+// trace ID from ctx, increments panics_recovered_total, and forwards the
+// panic to the crash reporter, if any. attrs add request context to the log
+// line. It returns an error describing the panic for callers that must fail
+// the current request.
+func HandlePanic(ctx context.Context, component string, v any, attrs ...slog.Attr) error {
+	stack := debug.Stack()
+
+	attrs = append(attrs,
+		slog.String("component", component),
+		slog.String("panic", fmt.Sprint(v)),
+		slog.String("stack", string(stack)),
+	)
+	LoggerFromContext(ctx).LogAttrs(ctx, slog.LevelError, "recovered from panic", attrs...)
+	panicsRecovered.Add(ctx, 1, metric.WithAttributes(attribute.String("component", component)))
+	if r := crashReporter.Load(); r != nil {
+		(*r).ReportPanic(ctx, component, v, stack)
+	}
+	return fmt.Errorf("%s: panic: %v", component, v)
+}
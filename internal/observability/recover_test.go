@@ -0,0 +1,61 @@
+package observability_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+type recordingReporter struct {
+	component string
+	value     any
+	stack     []byte
+}
+
+func (r *recordingReporter) ReportPanic(_ context.Context, component string, value any, stack []byte) {
+	r.component, r.value, r.stack = component, value, stack
+}
+
+func TestHandlePanic(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	reporter := &recordingReporter{}
+	observability.SetCrashReporter(reporter)
+	t.Cleanup(func() { observability.SetCrashReporter(nil) })
+
+	err := observability.HandlePanic(context.Background(), "kafka_consumer", "boom", slog.String("topic", "messages"))
+
+	require.EqualError(t, err, "kafka_consumer: panic: boom")
+	assert.Equal(t, "kafka_consumer", reporter.component)
+	assert.Equal(t, "boom", reporter.value)
+	assert.Contains(t, string(reporter.stack), "TestHandlePanic")
+
+	out := buf.String()
+	assert.Contains(t, out, `"msg":"recovered from panic"`)
+	assert.Contains(t, out, `"component":"kafka_consumer"`)
+	assert.Contains(t, out, `"topic":"messages"`)
+	assert.Contains(t, out, `"stack":"goroutine`)
+}
+
+func TestRecover(t *testing.T) {
+	reporter := &recordingReporter{}
+	observability.SetCrashReporter(reporter)
+	t.Cleanup(func() { observability.SetCrashReporter(nil) })
+
+	func() {
+		defer observability.Recover(context.Background(), "worker")
+		panic("worker bug")
+	}()
+
+	assert.Equal(t, "worker", reporter.component)
+	assert.Equal(t, "worker bug", reporter.value)
+}
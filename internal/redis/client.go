@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -43,3 +44,9 @@ func NewClient(cfg Config) *Client {
 func (c *Client) Close() error {
 	return c.RDB.Close()
 }
+
+// Ping round-trips to Redis, leaving an open connection in the pool. Use it
+// as a startup warmup step.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.RDB.Ping(ctx).Err()
+}
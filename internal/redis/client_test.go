@@ -1,6 +1,7 @@
 package redis_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -32,3 +33,14 @@ func TestNewClient(t *testing.T) {
 	// Verify that RDB satisfies the Cmdable interface.
 	var _ iredis.Cmdable = client.RDB
 }
+
+func TestClientPing(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := iredis.NewClient(iredis.Config{Addr: mr.Addr()})
+	t.Cleanup(func() { require.NoError(t, client.Close()) })
+
+	require.NoError(t, client.Ping(context.Background()))
+
+	mr.Close()
+	require.Error(t, client.Ping(context.Background()))
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+)
+
+// AdminPathPrefix is the path prefix of the operator endpoints AdminAuth
+// protects.
+const AdminPathPrefix = "/admin/"
+
+// AdminAuth requires "Authorization: Bearer <token>" on requests under
+// AdminPathPrefix and passes every other request through. An empty token
+// fails closed: every admin request is refused. Tokens are compared with
+// auth.ConstantTimeEqualSecret, which takes the same time whatever the
+// presented token's length.
+func AdminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, AdminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || !auth.ConstantTimeEqualSecret(presented, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
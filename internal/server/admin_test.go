@@ -0,0 +1,50 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		name   string
+		token  string
+		path   string
+		header string
+		want   int
+	}{
+		{name: "admin path with token", token: "s3cret", path: "/admin/loglevel", header: "Bearer s3cret", want: http.StatusNoContent},
+		{name: "admin path without header", token: "s3cret", path: "/admin/loglevel", want: http.StatusUnauthorized},
+		{name: "admin path with wrong token", token: "s3cret", path: "/admin/loglevel", header: "Bearer s3cre", want: http.StatusUnauthorized},
+		{name: "admin path with basic scheme", token: "s3cret", path: "/admin/keys", header: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "service path is not checked", token: "s3cret", path: "/v1/chats", want: http.StatusNoContent},
+		{name: "health is not checked", token: "s3cret", path: "/healthz", want: http.StatusNoContent},
+		{name: "empty token refuses admin paths", path: "/admin/loglevel", want: http.StatusUnauthorized},
+		{name: "empty token refuses an empty bearer", path: "/admin/loglevel", header: "Bearer ", want: http.StatusUnauthorized},
+		{name: "empty token still passes service paths", path: "/v1/chats", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			server.AdminAuth(tt.token, ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate challenge")
+			}
+		})
+	}
+}
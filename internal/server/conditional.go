@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// ConditionalGET answers GET and HEAD requests with 304 Not Modified when
+// the handler's 200 response carries an ETag matching If-None-Match, so a
+// client polling an unchanged resource does not download it again. The
+// handler still runs; only its body is discarded. Handlers opt in by
+// setting ETag (see domain.WeakETag) before writing the status.
+func ConditionalGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch := strings.Join(r.Header.Values("If-None-Match"), ",")
+		if ifNoneMatch == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w, ifNoneMatch: ifNoneMatch}, r)
+	})
+}
+
+// conditionalWriter swaps a 200 for a 304 when the response's ETag
+// matches ifNoneMatch, and then drops the body.
+type conditionalWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if code == http.StatusOK && domain.ETagMatches(w.ifNoneMatch, h.Get("ETag")) {
+		w.notModified = true
+		// RFC 9110 §15.4.5: a 304 has no content, so no content headers.
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,81 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func TestConditionalGET(t *testing.T) {
+	const etag = `W/"3-abc"`
+	tagged := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"chats":[]}`)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		handler     http.Handler
+		wantCode    int
+		wantBody    string
+	}{
+		{"matching tag", http.MethodGet, etag, tagged, http.StatusNotModified, ""},
+		{"matching strong form", http.MethodGet, `"3-abc"`, tagged, http.StatusNotModified, ""},
+		{"stale tag", http.MethodGet, `W/"2-abc"`, tagged, http.StatusOK, `{"chats":[]}`},
+		{"no precondition", http.MethodGet, "", tagged, http.StatusOK, `{"chats":[]}`},
+		{"unsafe method", http.MethodPost, etag, tagged, http.StatusOK, `{"chats":[]}`},
+		{"untagged response", http.MethodGet, "*", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}), http.StatusOK, "ok"},
+		{"error response", http.MethodGet, etag, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("ETag", etag)
+			http.Error(w, "gone", http.StatusNotFound)
+		}), http.StatusNotFound, "gone\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(context.Background(), tt.method, "/v1/chats", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ConditionalGET(tt.handler).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if body := rec.Body.String(); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if tt.wantCode == http.StatusNotModified {
+				if got := rec.Header().Get("ETag"); got != etag {
+					t.Errorf("etag = %q, want it kept on the 304", got)
+				}
+				if got := rec.Header().Get("Content-Type"); got != "" {
+					t.Errorf("content-type = %q, want none on the 304", got)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionalGETKeepsResponseController(t *testing.T) {
+	h := server.ConditionalGET(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("ETag", `W/"1-a"`)
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush through the wrapper: %v", err)
+		}
+	}))
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `W/"0-z"`)
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
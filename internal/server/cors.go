@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+)
+
+// corsMethods are the methods a preflight may approve.
+const corsMethods = "GET, POST, PUT, PATCH, DELETE"
+
+// corsExposedHeaders are the response headers browser code may read
+// besides the CORS-safelisted ones.
+const corsExposedHeaders = "ETag, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset"
+
+// CORS lets browsers on the configured origins call next. Preflight
+// requests are answered here, before AdminAuth and the routes, since
+// browsers send them without credentials. Requests from other origins are
+// served without CORS headers, so the browser withholds the response. A
+// config without origins returns next unchanged.
+func CORS(c config.CORSConfig, next http.Handler) http.Handler {
+	if !c.Enabled() {
+		return next
+	}
+	anyOrigin := slices.Contains(c.Origins, "*")
+	headers := strings.Join(c.Headers, ", ")
+	maxAge := strconv.Itoa(int(c.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := anyOrigin || slices.ContainsFunc(c.Origins, func(o string) bool {
+			return strings.EqualFold(o, origin)
+		})
+		if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if !preflight {
+			if allowed {
+				h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		if allowed {
+			h.Set("Access-Control-Allow-Methods", corsMethods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
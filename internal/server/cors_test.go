@@ -0,0 +1,138 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	cors := config.CORSConfig{
+		Origins: []string{"https://app.example.com"},
+		Headers: []string{"Authorization", "Content-Type"},
+		MaxAge:  10 * time.Minute,
+	}
+
+	send := func(c config.CORSConfig, method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequestWithContext(context.Background(), method, "/v1/devices", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		server.CORS(c, ok).ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("allowed origin", func(t *testing.T) {
+		rec := send(cors, http.MethodGet, "https://app.example.com", false)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("allow-origin = %q, want the request origin", got)
+		}
+		if rec.Header().Get("Access-Control-Expose-Headers") == "" {
+			t.Error("rate limit headers are not exposed")
+		}
+		if rec.Header().Get("Vary") != "Origin" {
+			t.Errorf("vary = %q, want Origin", rec.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("other origin is served without cors headers", func(t *testing.T) {
+		rec := send(cors, http.MethodGet, "https://evil.example.com", false)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("allow-origin = %q, want none", got)
+		}
+	})
+
+	t.Run("preflight is answered without the handler", func(t *testing.T) {
+		rec := send(cors, http.MethodOptions, "https://app.example.com", true)
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204", rec.Code)
+		}
+		for header, want := range map[string]string{
+			"Access-Control-Allow-Origin":  "https://app.example.com",
+			"Access-Control-Allow-Headers": "Authorization, Content-Type",
+			"Access-Control-Max-Age":       "600",
+		} {
+			if got := rec.Header().Get(header); got != want {
+				t.Errorf("%s = %q, want %q", header, got, want)
+			}
+		}
+		if rec.Header().Get("Access-Control-Allow-Methods") == "" {
+			t.Error("preflight does not list methods")
+		}
+	})
+
+	t.Run("preflight from other origin is not approved", func(t *testing.T) {
+		rec := send(cors, http.MethodOptions, "https://evil.example.com", true)
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204", rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "" {
+			t.Errorf("allow-methods = %q, want none", got)
+		}
+	})
+
+	t.Run("wildcard allows any origin", func(t *testing.T) {
+		rec := send(config.CORSConfig{Origins: []string{"*"}}, http.MethodGet, "http://localhost:5173", false)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+			t.Errorf("allow-origin = %q, want the request origin", got)
+		}
+	})
+
+	t.Run("disabled config adds nothing", func(t *testing.T) {
+		rec := send(config.CORSConfig{}, http.MethodOptions, "https://app.example.com", true)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want the handler's 200", rec.Code)
+		}
+		if len(rec.Header()) != 0 {
+			t.Errorf("headers = %v, want none", rec.Header())
+		}
+	})
+}
+
+func TestRunAnswersCORSPreflightBeforeAdminAuth(t *testing.T) {
+	t.Setenv("HTTP_CORS_ORIGINS", "https://app.example.com")
+	t.Setenv("ADMINTOKEN", "s3cret")
+
+	addr := runServer(t, testParams())
+	waitForHealthy(t, addr)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodOptions,
+		"http://"+addr+"/admin/loglevel", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("preflight: %v", err)
+	}
+	resp.Body.Close()
+	http.DefaultClient.CloseIdleConnections()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want 204 without admin credentials", resp.StatusCode)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+)
+
+// newHTTPServer builds the HTTP server for handler. HTTP/1.1 is always
+// served. With a certificate configured the server speaks TLS and
+// negotiates HTTP/2 through ALPN; with H2C it also accepts HTTP/2 in
+// cleartext, so the grpc-gateway bridge and streaming responses can
+// multiplex over one connection from a load balancer that speaks HTTP/2
+// to its targets.
+func newHTTPServer(c config.HTTPConfig, handler http.Handler) (*http.Server, error) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(c.TLS.Enabled())
+	protocols.SetUnencryptedHTTP2(c.H2C)
+
+	srv := &http.Server{
+		Handler:           handler,
+		Protocols:         &protocols,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}
+	if c.TLS.Enabled() {
+		// Loading here rather than in ServeTLS fails startup, not the
+		// serve goroutine, on a bad certificate.
+		cert, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load tls certificate: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	return srv, nil
+}
+
+// serveHTTP serves srv on ln, over TLS when srv has a certificate.
+func serveHTTP(srv *http.Server, ln net.Listener) error {
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
+}
@@ -0,0 +1,185 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func TestRunServesH2C(t *testing.T) {
+	t.Setenv("HTTP_H2C", "true")
+	t.Setenv("HTTP_WRITETIMEOUT", "100ms")
+
+	slow := func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	}
+	p := testParams()
+	p.Setup = func(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+		deps.HTTPMux.Handle("/slow", http.HandlerFunc(slow))
+		deps.Route("/stream", deps.Limits.Streaming, http.HandlerFunc(slow))
+		return nil, nil
+	}
+	addr := runServer(t, p)
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	transport := &http.Transport{Protocols: &protocols}
+	t.Cleanup(transport.CloseIdleConnections)
+	client := &http.Client{Transport: transport}
+
+	resp, err := get(client, fmt.Sprintf("http://%s/healthz", addr))
+	if err != nil {
+		t.Fatalf("h2c request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("proto = %s, want HTTP/2", resp.Proto)
+	}
+
+	t.Run("write timeout applies to ordinary routes", func(t *testing.T) {
+		resp, err := get(client, fmt.Sprintf("http://%s/slow", addr))
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if err == nil {
+			t.Error("slow response outlived the write timeout")
+		}
+	})
+
+	t.Run("streaming routes lift the write timeout", func(t *testing.T) {
+		resp, err := get(client, fmt.Sprintf("http://%s/stream", addr))
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil || string(body) != "done" {
+			t.Errorf("body = %q, err = %v; want done", body, err)
+		}
+	})
+}
+
+func TestRunServesTLSWithHTTP2(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	t.Setenv("HTTP_TLS_CERT", certFile)
+	t.Setenv("HTTP_TLS_KEY", keyFile)
+
+	addr := runServer(t, testParams())
+
+	transport := &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // self-signed test certificate
+		ForceAttemptHTTP2: true,
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+	client := &http.Client{Transport: transport}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := get(client, fmt.Sprintf("https://%s/healthz", addr))
+		if err == nil {
+			resp.Body.Close()
+			if resp.ProtoMajor != 2 {
+				t.Errorf("proto = %s, want HTTP/2 through ALPN", resp.Proto)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tls request: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRunRejectsUnreadableCertificate(t *testing.T) {
+	t.Setenv("HTTP_TLS_CERT", filepath.Join(t.TempDir(), "missing.pem"))
+	t.Setenv("HTTP_TLS_KEY", filepath.Join(t.TempDir(), "missing.key"))
+
+	ln := newTestListener(t)
+	defer ln.Close()
+
+	err := server.Run(context.Background(), testParams(), server.Listeners{HTTP: ln})
+	if err == nil {
+		t.Fatal("Run started without a readable certificate")
+	}
+}
+
+// runServer starts Run on a port-0 listener and stops it when the test
+// ends, returning the HTTP address.
+func runServer(t *testing.T, p server.Params) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	ln := newTestListener(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(ctx, p, server.Listeners{HTTP: ln})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Errorf("run: %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+func get(client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key as
+// PEM files, returning their paths.
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
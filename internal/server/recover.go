@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"google.golang.org/grpc"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+)
+
+// RecoverHTTP turns a handler panic into a 500 response so one bad request
+// cannot take down the process. http.ErrAbortHandler is re-raised: net/http
+// uses it to abort a response deliberately.
+func RecoverHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler { //nolint:errorlint // panic value, not a wrapped error
+				panic(v)
+			}
+			he := errmap.ToHTTPError(observability.HandlePanic(r.Context(), "http", v,
+				slog.String("method", r.Method), slog.String("path", r.URL.Path)))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(he.StatusCode)
+			_ = json.NewEncoder(w).Encode(he)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RecoveryUnaryInterceptor turns a handler panic into an INTERNAL status.
+func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				resp, err = nil, errmap.ToGRPCError(observability.HandlePanic(ctx, "grpc", v, slog.String("method", info.FullMethod)))
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor turns a stream handler panic into an INTERNAL
+// status.
+func RecoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = errmap.ToGRPCError(observability.HandlePanic(ss.Context(), "grpc", v, slog.String("method", info.FullMethod)))
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
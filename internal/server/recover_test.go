@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+func TestRecoverHTTP(t *testing.T) {
+	h := server.RecoverHTTP(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("handler bug")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/v1/chats", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"code":"INTERNAL"`) {
+		t.Errorf("body = %q, want INTERNAL error", body)
+	}
+}
+
+func TestRecoverHTTPRepanicsAbortHandler(t *testing.T) {
+	h := server.RecoverHTTP(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler { //nolint:errorlint // panic value, not a wrapped error
+			t.Errorf("recovered %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil))
+}
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	interceptor := server.RecoveryUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/messaging.v1.AuthService/Logout"}
+
+	resp, err := interceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		panic("handler bug")
+	})
+
+	if resp != nil {
+		t.Errorf("resp = %v, want nil", resp)
+	}
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("code = %v, want Internal", code)
+	}
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	interceptor := server.RecoveryStreamInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/messaging.v1.GatewayService/Connect"}
+
+	err := interceptor(nil, &stubServerStream{}, info, func(any, grpc.ServerStream) error {
+		panic("stream bug")
+	})
+
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("code = %v, want Internal", code)
+	}
+}
+
+type stubServerStream struct {
+	grpc.ServerStream
+}
+
+func (*stubServerStream) Context() context.Context { return context.Background() }
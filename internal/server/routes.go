@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/config"
+)
+
+// RouteLimits bounds the requests of one route.
+type RouteLimits struct {
+	// Timeout bounds the handler: its context ends, and reads and writes
+	// fail, Timeout after the request starts. Zero lifts the server read
+	// and write timeouts and leaves the context unbounded, for responses
+	// that stay open as long as the client listens.
+	Timeout time.Duration
+	// MaxBody caps the request body in bytes. A request declaring a
+	// larger Content-Length is refused with 413; reads past the cap fail
+	// with *http.MaxBytesError. Zero leaves the body uncapped.
+	MaxBody int64
+}
+
+// RouteClasses holds the limits of each route class, from the HTTP
+// config. Routes not registered through SetupDeps.Route run as Standard.
+type RouteClasses struct {
+	// Standard covers request/response endpoints.
+	Standard RouteLimits
+	// LongPoll covers endpoints that hold the request open until
+	// something happens or the poll times out.
+	LongPoll RouteLimits
+	// Streaming covers SSE, WebSocket upgrades and NDJSON progress
+	// streams.
+	Streaming RouteLimits
+}
+
+// routeClasses derives the route classes from c. Every class keeps the
+// configured body cap.
+func routeClasses(c config.HTTPConfig) RouteClasses {
+	return RouteClasses{
+		Standard:  RouteLimits{Timeout: c.WriteTimeout, MaxBody: c.MaxBodyBytes},
+		LongPoll:  RouteLimits{Timeout: c.LongPollTimeout, MaxBody: c.MaxBodyBytes},
+		Streaming: RouteLimits{MaxBody: c.MaxBodyBytes},
+	}
+}
+
+// Limit applies l to next.
+func Limit(l RouteLimits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.MaxBody > 0 {
+			if r.ContentLength > l.MaxBody {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, l.MaxBody)
+		}
+
+		// A writer without deadline support (http.ErrNotSupported) keeps
+		// the server timeouts.
+		var deadline time.Time
+		if l.Timeout > 0 {
+			deadline = time.Now().Add(l.Timeout)
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeLimiter applies to each request the limits its route was
+// registered with, or the default.
+type routeLimiter struct {
+	mux    *http.ServeMux
+	def    http.Handler
+	routes map[string]http.Handler // limited mux, by pattern
+}
+
+func newRouteLimiter(mux *http.ServeMux, def RouteLimits) *routeLimiter {
+	return &routeLimiter{mux: mux, def: Limit(def, mux), routes: make(map[string]http.Handler)}
+}
+
+// handle registers h for pattern on the mux, limited by l.
+func (rl *routeLimiter) handle(pattern string, l RouteLimits, h http.Handler) {
+	rl.mux.Handle(pattern, h)
+	rl.routes[pattern] = Limit(l, rl.mux)
+}
+
+func (rl *routeLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rl.mux.Handler(r); pattern != "" {
+		if limited, ok := rl.routes[pattern]; ok {
+			limited.ServeHTTP(w, r)
+			return
+		}
+	}
+	rl.def.ServeHTTP(w, r)
+}
@@ -0,0 +1,136 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+// readBody reports what reading the request body produced.
+var readBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, "capped", http.StatusRequestEntityTooLarge)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		fmt.Fprintf(w, "read %d", len(body))
+	}
+})
+
+func TestLimit(t *testing.T) {
+	t.Run("declared body over the cap is refused before the handler", func(t *testing.T) {
+		called := false
+		h := server.Limit(server.RouteLimits{MaxBody: 4}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			called = true
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/", strings.NewReader("12345")))
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413", rec.Code)
+		}
+		if called {
+			t.Error("handler ran for an oversized body")
+		}
+	})
+
+	t.Run("undeclared body is capped while reading", func(t *testing.T) {
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/", strings.NewReader("12345"))
+		req.ContentLength = -1
+
+		rec := httptest.NewRecorder()
+		server.Limit(server.RouteLimits{MaxBody: 4}, readBody).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413", rec.Code)
+		}
+	})
+
+	t.Run("body within the cap is read", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		server.Limit(server.RouteLimits{MaxBody: 5}, readBody).ServeHTTP(rec,
+			httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/", strings.NewReader("12345")))
+
+		if body := rec.Body.String(); body != "read 5" {
+			t.Errorf("body = %q, want read 5", body)
+		}
+	})
+
+	t.Run("timeout bounds the handler context", func(t *testing.T) {
+		var deadline time.Time
+		var ok bool
+		h := server.Limit(server.RouteLimits{Timeout: time.Minute}, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			deadline, ok = r.Context().Deadline()
+		}))
+
+		start := time.Now()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil))
+
+		if !ok || deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+			t.Errorf("deadline = %v (set %v), want a minute from the request", deadline, ok)
+		}
+	})
+
+	t.Run("zero timeout leaves the context unbounded", func(t *testing.T) {
+		var ok bool
+		h := server.Limit(server.RouteLimits{}, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			_, ok = r.Context().Deadline()
+		}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/", nil))
+
+		if ok {
+			t.Error("streaming route context has a deadline")
+		}
+	})
+}
+
+func TestRunAppliesRouteLimits(t *testing.T) {
+	t.Setenv("HTTP_MAXBODYBYTES", "8")
+
+	p := testParams()
+	p.Setup = func(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+		if deps.Limits.Standard.MaxBody != 8 || deps.Limits.Streaming.Timeout != 0 {
+			t.Errorf("limits = %+v, want the configured body cap and unbounded streaming", deps.Limits)
+		}
+		deps.HTTPMux.Handle("POST /standard", readBody)
+		deps.Route("POST /upload", server.RouteLimits{Timeout: time.Minute, MaxBody: 64}, readBody)
+		return nil, nil
+	}
+	addr := runServer(t, p)
+	waitForHealthy(t, addr)
+
+	post := func(path string) int {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+			fmt.Sprintf("http://%s%s", addr, path), strings.NewReader(strings.Repeat("x", 16)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("/standard"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unregistered route status = %d, want 413 under the standard cap", code)
+	}
+	if code := post("/upload"); code != http.StatusOK {
+		t.Errorf("registered route status = %d, want 200 under its own cap", code)
+	}
+	http.DefaultClient.CloseIdleConnections()
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/aelexs/realtime-messaging-platform/internal/config"
 	"github.com/aelexs/realtime-messaging-platform/internal/domain"
 	"github.com/aelexs/realtime-messaging-platform/internal/observability"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
 )
 
 // Params configures a service's lifecycle runner.
@@ -54,6 +55,31 @@ type SetupDeps struct {
 	Logger     *slog.Logger
 	HTTPMux    *http.ServeMux
 	GRPCServer *grpc.Server // nil if GRPCPortFromConfig is nil
+
+	// OnDrain registers fn to run at the start of graceful shutdown, after
+	// health checks fail and before the servers stop. Services holding
+	// long-lived connections close them here; gRPC GracefulStop would
+	// otherwise wait on open streams for the whole shutdown budget.
+	OnDrain func(fn func(context.Context) error)
+	// OnWarmup registers fn to run after the servers start listening and
+	// before /readyz reports ready. Steps run in registration order, each
+	// bounded by timeout (zero uses domain.WarmupStepTimeout). Use it to
+	// open connection pools, prime caches, or join consumer groups. A
+	// failing step stops the service.
+	OnWarmup func(name string, timeout time.Duration, fn func(context.Context) error)
+	// Route registers h on HTTPMux for pattern under l rather than
+	// Limits.Standard, e.g. deps.Route("/v1/events", deps.Limits.Streaming, h).
+	// Routes registered directly on HTTPMux run as standard.
+	Route func(pattern string, l RouteLimits, h http.Handler)
+	// Limits are the configured limits of each route class.
+	Limits RouteClasses
+}
+
+// warmup is a step registered through SetupDeps.OnWarmup.
+type warmup struct {
+	name    string
+	timeout time.Duration
+	fn      func(context.Context) error
 }
 
 // Listeners holds optional pre-created listeners for testing (port-0).
@@ -65,7 +91,7 @@ type Listeners struct {
 
 // Run executes the full service lifecycle: signal handling, config loading,
 // observability initialization, HTTP server with health checks, optional gRPC
-// server, and graceful shutdown. Listeners fields override config-based
+// server, warmup, and graceful shutdown. Listeners fields override config-based
 // listener creation (enables port-0 testing).
 func Run(ctx context.Context, p Params, lns Listeners) error {
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
@@ -76,11 +102,17 @@ func Run(ctx context.Context, p Params, lns Listeners) error {
 		return fmt.Errorf("load config: %w", err)
 	}
 
+	logControl := observability.NewLogControl(slog.LevelInfo, cfg.Logging.SampleRate)
+	for _, entry := range cfg.Logging.Levels {
+		subsystem, level, _ := config.ParseLevelOverride(entry) // validated by config.Load
+		logControl.SetLevel(subsystem, level)
+	}
 	logger := observability.InitLogger(observability.LogConfig{
 		Level:       cfg.LogLevel,
 		Format:      cfg.LogFormat,
 		ServiceName: p.Name,
 		Environment: cfg.Environment,
+		Control:     logControl,
 	})
 
 	tp, mp, err := initOTEL(ctx, p.Name, cfg)
@@ -88,13 +120,30 @@ func Run(ctx context.Context, p Params, lns Listeners) error {
 		return err
 	}
 
-	var shuttingDown atomic.Bool
+	sloRecorder, err := slo.NewRecorder(slo.RecorderConfig{
+		Objectives: slo.DefaultObjectives(),
+		OnAlert:    sloAlertLogger(logger),
+		Clock:      domain.RealClock{},
+	})
+	if err != nil {
+		return fmt.Errorf("create slo recorder: %w", err)
+	}
+	slo.SetDefault(sloRecorder)
+
+	var shuttingDown, ready atomic.Bool
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", healthHandler(&shuttingDown, p.Name))
+	mux.HandleFunc("/readyz", readyHandler(&ready, &shuttingDown, p.Name))
+	mux.Handle("/admin/loglevel", logControl.AdminHandler())
+
+	classes := routeClasses(cfg.HTTP)
+	routes := newRouteLimiter(mux, classes.Standard)
 
 	grpcServer := newGRPCServerIfConfigured(p)
 
 	var cleanupFn func(context.Context) error
+	var drainFns []func(context.Context) error
+	var warmups []warmup
 	if p.Setup != nil {
 		var setupErr error
 		cleanupFn, setupErr = p.Setup(ctx, SetupDeps{
@@ -102,6 +151,12 @@ func Run(ctx context.Context, p Params, lns Listeners) error {
 			Logger:     logger,
 			HTTPMux:    mux,
 			GRPCServer: grpcServer,
+			OnDrain:    func(fn func(context.Context) error) { drainFns = append(drainFns, fn) },
+			OnWarmup: func(name string, timeout time.Duration, fn func(context.Context) error) {
+				warmups = append(warmups, warmup{name: name, timeout: timeout, fn: fn})
+			},
+			Route:  routes.handle,
+			Limits: classes,
 		})
 		if setupErr != nil {
 			return fmt.Errorf("setup: %w", setupErr)
@@ -113,11 +168,18 @@ func Run(ctx context.Context, p Params, lns Listeners) error {
 		return err
 	}
 
-	httpSrv := &http.Server{
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// Only local development may leave /admin open; anywhere else a
+	// missing token refuses every admin request.
+	handler := ConditionalGET(routes)
+	if cfg.AdminToken != "" || !cfg.IsLocal() {
+		if cfg.AdminToken == "" {
+			logger.Warn("ADMINTOKEN is not set; /admin endpoints are disabled")
+		}
+		handler = AdminAuth(cfg.AdminToken, handler)
+	}
+	httpSrv, err := newHTTPServer(cfg.HTTP, RecoverHTTP(CORS(cfg.HTTP.CORS, handler)))
+	if err != nil {
+		return err
 	}
 
 	grpcLn, err := resolveGRPCListener(ctx, lns.GRPC, p, cfg, grpcServer)
@@ -127,7 +189,18 @@ func Run(ctx context.Context, p Params, lns Listeners) error {
 
 	g, ctx := errgroup.WithContext(ctx)
 	startServers(g, logger, httpSrv, httpLn, grpcServer, grpcLn, cfg.Environment)
-	g.Go(shutdownFunc(ctx, logger, &shuttingDown, httpSrv, grpcServer, cleanupFn, tp, mp))
+	g.Go(func() error {
+		if err := runWarmups(ctx, logger, warmups); err != nil {
+			return err
+		}
+		ready.Store(true)
+		return nil
+	})
+	g.Go(func() error {
+		sloRecorder.Run(ctx, domain.SLOEvaluateInterval)
+		return nil
+	})
+	g.Go(shutdownFunc(ctx, logger, &shuttingDown, httpSrv, grpcServer, drainFns, cleanupFn, tp, mp))
 
 	return g.Wait()
 }
@@ -173,12 +246,94 @@ func healthHandler(shuttingDown *atomic.Bool, name string) http.HandlerFunc {
 	}
 }
 
+// readyHandler returns an HTTP handler for the /readyz endpoint. Unlike
+// /healthz it also fails until warmup has finished.
+func readyHandler(ready, shuttingDown *atomic.Bool, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case shuttingDown.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, `{"status":"shutting_down","service":%q}`, name)
+		case !ready.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, `{"status":"warming_up","service":%q}`, name)
+		default:
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"status":"ready","service":%q}`, name)
+		}
+	}
+}
+
+// sloAlertLogger logs burn rate alerts so log-based alerting can page on
+// them; the slo_burn_rate gauge carries the same signal for metric rules.
+func sloAlertLogger(logger *slog.Logger) func(context.Context, slo.Alert) {
+	return func(ctx context.Context, a slo.Alert) {
+		level, msg := slog.LevelError, "slo burn rate alert firing"
+		if !a.Firing {
+			level, msg = slog.LevelInfo, "slo burn rate alert resolved"
+		}
+		logger.Log(ctx, level, msg,
+			slog.String("operation", a.Operation),
+			slog.String("severity", a.Rule.Severity),
+			slog.Duration("window", a.Rule.Window),
+			slog.Float64("burn_rate", a.BurnRate),
+			slog.Float64("threshold", a.Rule.BurnRate),
+		)
+	}
+}
+
+// runWarmups runs each warmup step in order with its own timeout, logging
+// progress. It stops at the first failure or when ctx is done.
+func runWarmups(ctx context.Context, logger *slog.Logger, warmups []warmup) error {
+	start := time.Now()
+	for i, w := range warmups {
+		timeout := w.timeout
+		if timeout <= 0 {
+			timeout = domain.WarmupStepTimeout
+		}
+		logger.InfoContext(ctx, "warmup step started",
+			slog.String("step", w.name),
+			slog.Int("index", i+1),
+			slog.Int("total", len(warmups)),
+			slog.Duration("timeout", timeout),
+		)
+
+		stepStart := time.Now()
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := w.fn(stepCtx)
+		cancel()
+		if err != nil {
+			logger.ErrorContext(ctx, "warmup step failed",
+				slog.String("step", w.name),
+				slog.Duration("elapsed", time.Since(stepStart)),
+				slog.String("error", err.Error()),
+			)
+			return fmt.Errorf("warmup %s: %w", w.name, err)
+		}
+		logger.InfoContext(ctx, "warmup step complete",
+			slog.String("step", w.name),
+			slog.Duration("elapsed", time.Since(stepStart)),
+		)
+	}
+	logger.InfoContext(ctx, "warmup complete",
+		slog.Int("steps", len(warmups)),
+		slog.Duration("elapsed", time.Since(start)),
+	)
+	return nil
+}
+
 // newGRPCServerIfConfigured creates a gRPC server when GRPCPortFromConfig is set.
+// Handler panics become INTERNAL errors, and requests are validated against
+// their proto constraints before reaching handlers.
 func newGRPCServerIfConfigured(p Params) *grpc.Server {
 	if p.GRPCPortFromConfig == nil {
 		return nil
 	}
-	return grpc.NewServer()
+	return grpc.NewServer(
+		grpc.ChainUnaryInterceptor(RecoveryUnaryInterceptor(), ValidationUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(RecoveryStreamInterceptor(), ValidationStreamInterceptor()),
+	)
 }
 
 // resolveListener returns the injected listener or creates one from config.
@@ -218,8 +373,10 @@ func startServers(
 		logger.Info("starting HTTP server",
 			slog.String("addr", httpLn.Addr().String()),
 			slog.String("environment", environment),
+			slog.Bool("tls", httpSrv.TLSConfig != nil),
+			slog.Bool("h2c", httpSrv.Protocols.UnencryptedHTTP2()),
 		)
-		if serveErr := httpSrv.Serve(httpLn); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		if serveErr := serveHTTP(httpSrv, httpLn); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
 			return serveErr
 		}
 		return nil
@@ -239,10 +396,12 @@ func startServers(
 }
 
 // shutdownFunc returns the errgroup function that orchestrates graceful shutdown.
-// Shutdown order: gRPC GracefulStop -> HTTP Shutdown -> service cleanup -> OTEL flush.
+// Shutdown order: connection drain -> gRPC GracefulStop -> HTTP Shutdown ->
+// service cleanup -> OTEL flush.
 func shutdownFunc(
 	ctx context.Context, logger *slog.Logger, shuttingDown *atomic.Bool,
 	httpSrv *http.Server, grpcServer *grpc.Server,
+	drainFns []func(context.Context) error,
 	cleanupFn func(context.Context) error,
 	tp *observability.TracerProvider, mp *observability.MetricsProvider,
 ) func() error {
@@ -253,6 +412,16 @@ func shutdownFunc(
 		shuttingDown.Store(true)
 		time.Sleep(domain.ShutdownDrainDelay)
 
+		if len(drainFns) > 0 {
+			drainCtx, drainCancel := context.WithTimeout(context.Background(), domain.ShutdownConnDrainTimeout)
+			for _, fn := range drainFns {
+				if drainErr := fn(drainCtx); drainErr != nil {
+					logger.Error("connection drain error", slog.String("error", drainErr.Error()))
+				}
+			}
+			drainCancel()
+		}
+
 		if grpcServer != nil {
 			grpcServer.GracefulStop()
 			logger.Info("gRPC server stopped")
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -277,6 +278,147 @@ func TestRunCleanupCalledDuringShutdown(t *testing.T) {
 	<-errCh
 }
 
+func TestRunDrainRunsBeforeCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ln := newTestListener(t)
+	addr := ln.Addr().String()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, step)
+	}
+
+	params := server.Params{
+		Name:           "testservice",
+		PortFromConfig: func(_ *config.Config) int { return 0 },
+		Setup: func(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+			deps.OnDrain(func(drainCtx context.Context) error {
+				if _, ok := drainCtx.Deadline(); !ok {
+					t.Error("drain context has no deadline")
+				}
+				record("drain")
+				return nil
+			})
+			return func(_ context.Context) error {
+				record("cleanup")
+				return nil
+			}, nil
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(ctx, params, server.Listeners{HTTP: ln})
+	}()
+
+	waitForHealthy(t, addr)
+	cancel()
+
+	select {
+	case <-errCh:
+	case <-time.After(domain.GracefulShutdownTimeout + 5*time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 || order[0] != "drain" || order[1] != "cleanup" {
+		t.Errorf("shutdown order = %v, want [drain cleanup]", order)
+	}
+}
+
+func TestReadyzWaitsForWarmup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ln := newTestListener(t)
+	addr := ln.Addr().String()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var steps []string
+
+	params := server.Params{
+		Name:           "testservice",
+		PortFromConfig: func(_ *config.Config) int { return 0 },
+		Setup: func(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+			deps.OnWarmup("pool", 0, func(stepCtx context.Context) error {
+				if _, ok := stepCtx.Deadline(); !ok {
+					t.Error("warmup context has no deadline")
+				}
+				<-release
+				mu.Lock()
+				defer mu.Unlock()
+				steps = append(steps, "pool")
+				return nil
+			})
+			deps.OnWarmup("cache", time.Second, func(context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				steps = append(steps, "cache")
+				return nil
+			})
+			return nil, nil
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(ctx, params, server.Listeners{HTTP: ln})
+	}()
+
+	// Live but not ready while warmup is blocked.
+	waitForHealthy(t, addr)
+	if code := getStatus(t, addr, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz during warmup = %d, want 503", code)
+	}
+
+	close(release)
+	eventually(t, 2*time.Second, func() bool {
+		return getStatus(t, addr, "/readyz") == http.StatusOK
+	})
+
+	mu.Lock()
+	if len(steps) != 2 || steps[0] != "pool" || steps[1] != "cache" {
+		t.Errorf("warmup order = %v, want [pool cache]", steps)
+	}
+	mu.Unlock()
+
+	cancel()
+	<-errCh
+}
+
+func TestRunWarmupFailureStopsService(t *testing.T) {
+	ln := newTestListener(t)
+
+	warmupErr := errors.New("redis unreachable")
+	params := server.Params{
+		Name:           "testservice",
+		PortFromConfig: func(_ *config.Config) int { return 0 },
+		Setup: func(_ context.Context, deps server.SetupDeps) (func(context.Context) error, error) {
+			deps.OnWarmup("redis", time.Second, func(context.Context) error { return warmupErr })
+			return nil, nil
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(context.Background(), params, server.Listeners{HTTP: ln})
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, warmupErr) {
+			t.Errorf("expected warmup error to be wrapped, got: %v", err)
+		}
+	case <-time.After(domain.GracefulShutdownTimeout + 5*time.Second):
+		t.Fatal("server did not stop after warmup failure")
+	}
+}
+
 // stubHealthServer implements the gRPC Health service for testing.
 type stubHealthServer struct {
 	healthpb.UnimplementedHealthServer
@@ -313,6 +455,17 @@ func waitForHealthy(t *testing.T, addr string) {
 	t.Fatalf("server at %s not healthy within 5s", addr)
 }
 
+// getStatus returns the status code of GET path, or 0 if the request fails.
+func getStatus(t *testing.T, addr, path string) int {
+	t.Helper()
+	resp, err := httpGet(t, fmt.Sprintf("http://%s%s", addr, path))
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
 // httpGet performs an HTTP GET with a background context (satisfies noctx linter).
 func httpGet(t *testing.T, url string) (*http.Response, error) {
 	t.Helper()
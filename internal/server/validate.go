@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// The interfaces below match the methods protoc-gen-validate generates, so
+// the interceptor needs no dependency on the generated packages.
+
+// allValidator is implemented by messages with generated validation that
+// reports every violation at once.
+type allValidator interface {
+	ValidateAll() error
+}
+
+// validator is implemented by messages with generated validation that stops
+// at the first violation.
+type validator interface {
+	Validate() error
+}
+
+// multiError is the aggregate error returned by ValidateAll.
+type multiError interface {
+	AllErrors() []error
+}
+
+// fieldError is a single generated rule violation. For nested messages the
+// Cause is the violation inside the embedded message.
+type fieldError interface {
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// ValidationUnaryInterceptor runs proto-generated validation before the
+// handler. Violations are returned as INVALID_ARGUMENT with a BadRequest
+// detail listing each offending field path. Requests without generated
+// validation pass through unchanged.
+func ValidationUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := validateRequest(req); err != nil {
+			return nil, errmap.ToGRPCError(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ValidationStreamInterceptor is the streaming counterpart of
+// ValidationUnaryInterceptor: each message the handler receives is
+// validated, and a violation is returned to the handler from RecvMsg as
+// INVALID_ARGUMENT, which ends the stream when the handler returns it.
+func ValidationStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss})
+	}
+}
+
+// validatingStream validates every message received on the stream.
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if err := validateRequest(m); err != nil {
+		return errmap.ToGRPCError(err)
+	}
+	return nil
+}
+
+// validateRequest returns a *domain.ValidationError when req fails its
+// generated constraints.
+func validateRequest(req any) error {
+	var err error
+	switch v := req.(type) {
+	case allValidator:
+		err = v.ValidateAll()
+	case validator:
+		err = v.Validate()
+	default:
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("validate request: %w", &domain.ValidationError{Violations: violations("", err)})
+}
+
+// violations flattens generated validation errors into dotted field paths.
+// Embedded message failures are expanded to the innermost violations; errors
+// of unknown shape are reported against the enclosing path.
+func violations(prefix string, err error) []domain.FieldViolation {
+	var multi multiError
+	if errors.As(err, &multi) {
+		var out []domain.FieldViolation
+		for _, e := range multi.AllErrors() {
+			out = append(out, violations(prefix, e)...)
+		}
+		return out
+	}
+
+	var fe fieldError
+	if !errors.As(err, &fe) {
+		return []domain.FieldViolation{{Field: prefix, Description: err.Error()}}
+	}
+	field := fe.Field()
+	if prefix != "" {
+		field = prefix + "." + field
+	}
+	if cause := fe.Cause(); cause != nil {
+		var nested fieldError
+		if errors.As(cause, &nested) || errors.As(cause, &multi) {
+			return violations(field, cause)
+		}
+	}
+	return []domain.FieldViolation{{Field: field, Description: fe.Reason()}}
+}
@@ -0,0 +1,182 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/server"
+)
+
+// fieldErr mirrors the <Message>ValidationError type protoc-gen-validate emits.
+type fieldErr struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e fieldErr) Field() string  { return e.field }
+func (e fieldErr) Reason() string { return e.reason }
+func (e fieldErr) Cause() error   { return e.cause }
+func (e fieldErr) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+// multiErr mirrors the <Message>MultiError type protoc-gen-validate emits.
+type multiErr []error
+
+func (m multiErr) AllErrors() []error { return m }
+func (m multiErr) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, e := range m {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type validateAllReq struct{ err error }
+
+func (r validateAllReq) ValidateAll() error { return r.err }
+func (r validateAllReq) Validate() error {
+	panic("Validate must not be called when ValidateAll exists")
+}
+
+type validateReq struct{ err error }
+
+func (r validateReq) Validate() error { return r.err }
+
+func TestValidationUnaryInterceptor(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        any
+		wantCalled bool
+		wantFields map[string]string
+	}{
+		{
+			name:       "request without generated validation passes through",
+			req:        struct{}{},
+			wantCalled: true,
+		},
+		{
+			name:       "valid request reaches handler",
+			req:        validateAllReq{},
+			wantCalled: true,
+		},
+		{
+			name: "all violations are reported",
+			req: validateAllReq{err: multiErr{
+				fieldErr{field: "otp", reason: "value does not match regex pattern"},
+				fieldErr{field: "device_id", reason: "value length must be at least 1 runes"},
+			}},
+			wantFields: map[string]string{
+				"otp":       "value does not match regex pattern",
+				"device_id": "value length must be at least 1 runes",
+			},
+		},
+		{
+			name: "first violation from Validate",
+			req:  validateReq{err: fieldErr{field: "chat_id", reason: "value length must be at least 1 runes"}},
+			wantFields: map[string]string{
+				"chat_id": "value length must be at least 1 runes",
+			},
+		},
+		{
+			name: "embedded message violations use dotted paths",
+			req: validateAllReq{err: multiErr{
+				fieldErr{field: "page", reason: "embedded message failed validation", cause: multiErr{
+					fieldErr{field: "page_size", reason: "value must be less than or equal to 100"},
+				}},
+			}},
+			wantFields: map[string]string{
+				"page.page_size": "value must be less than or equal to 100",
+			},
+		},
+		{
+			name: "unrecognized error shape is reported without a field",
+			req:  validateReq{err: errors.New("boom")},
+			wantFields: map[string]string{
+				"": "boom",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := func(context.Context, any) (any, error) {
+				called = true
+				return "ok", nil
+			}
+
+			resp, err := server.ValidationUnaryInterceptor()(context.Background(), tt.req, &grpc.UnaryServerInfo{}, handler)
+
+			assert.Equal(t, tt.wantCalled, called)
+			if tt.wantCalled {
+				require.NoError(t, err)
+				assert.Equal(t, "ok", resp)
+				return
+			}
+
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+
+			got := map[string]string{}
+			for _, d := range st.Details() {
+				if br, ok := d.(*errdetails.BadRequest); ok {
+					for _, fv := range br.GetFieldViolations() {
+						got[fv.GetField()] = fv.GetDescription()
+					}
+				}
+			}
+			assert.Equal(t, tt.wantFields, got)
+		})
+	}
+}
+
+// recvStream is a grpc.ServerStream whose RecvMsg copies the next queued
+// message into the request.
+type recvStream struct {
+	grpc.ServerStream
+	next validateAllReq
+}
+
+func (s *recvStream) Context() context.Context { return context.Background() }
+
+func (s *recvStream) RecvMsg(m any) error {
+	*m.(*validateAllReq) = s.next
+	return nil
+}
+
+func TestValidationStreamInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		next     validateAllReq
+		wantCode codes.Code
+	}{
+		{name: "valid message is received", next: validateAllReq{}, wantCode: codes.OK},
+		{
+			name:     "invalid message fails RecvMsg",
+			next:     validateAllReq{err: fieldErr{field: "chat_id", reason: "value length must be at least 1 runes"}},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(_ any, ss grpc.ServerStream) error {
+				var req validateAllReq
+				return ss.RecvMsg(&req)
+			}
+
+			err := server.ValidationStreamInterceptor()(nil, &recvStream{next: tt.next}, &grpc.StreamServerInfo{}, handler)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package slo
+
+import (
+	"context"
+	"time"
+)
+
+// Rule fires when an operation's burn rate over Window reaches BurnRate.
+type Rule struct {
+	Severity string
+	Window   time.Duration
+	BurnRate float64
+}
+
+// DefaultRules returns the burn rate alerts from ADR-012 §2.4.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Severity: "SEV-1", Window: time.Hour, BurnRate: 14.4},
+		{Severity: "SEV-2", Window: 6 * time.Hour, BurnRate: 6},
+		{Severity: "SEV-3", Window: 24 * time.Hour, BurnRate: 3},
+		{Severity: "SEV-4", Window: 72 * time.Hour, BurnRate: 1},
+	}
+}
+
+// Alert reports a rule starting or stopping firing for an operation.
+type Alert struct {
+	Operation string
+	Rule      Rule
+	BurnRate  float64
+	Firing    bool // false when the alert resolves
+}
+
+type alertKey struct {
+	operation string
+	severity  string
+}
+
+// Evaluate checks every rule for every operation and calls OnAlert for each
+// rule that started or stopped firing since the last call.
+func (r *Recorder) Evaluate(ctx context.Context) {
+	now := r.clock.Now()
+	var changed []Alert
+
+	r.mu.Lock()
+	for name, op := range r.ops {
+		for _, rule := range r.rules {
+			w := op.windows[rule.Window]
+			total, _ := w.counts(now)
+			rate := op.burnRate(w, now)
+			firing := total >= r.minEvents && rate >= rule.BurnRate
+
+			key := alertKey{operation: name, severity: rule.Severity}
+			if r.firing[key] != firing {
+				r.firing[key] = firing
+				changed = append(changed, Alert{Operation: name, Rule: rule, BurnRate: rate, Firing: firing})
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	if r.onAlert == nil {
+		return
+	}
+	for _, a := range changed {
+		r.onAlert(ctx, a)
+	}
+}
+
+// Run calls Evaluate every interval until ctx is done.
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Evaluate(ctx)
+		}
+	}
+}
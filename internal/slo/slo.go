@@ -0,0 +1,229 @@
+// Package slo records whether operations meet their service level
+// objectives and exports error budget burn rates (ADR-012 §2). Each
+// operation is declared once as an Objective; the alerting rules in
+// ADR-012 §2.4 then apply to every operation without per-operation setup.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/errmap"
+)
+
+// Operation names with objectives in DefaultObjectives.
+const (
+	VerifyOTP   = "verify_otp"
+	SendMessage = "send_message"
+	Deliver     = "deliver"
+)
+
+// Objective is the target for one operation. An event is good when it
+// succeeds, or fails only because of the caller (a 4xx-class error), within
+// Latency.
+type Objective struct {
+	Operation string
+	Target    float64       // fraction of good events, e.g. 0.999
+	Latency   time.Duration // slower events are bad; zero checks success only
+}
+
+// DefaultObjectives returns the objectives from ADR-012 §2.2.
+func DefaultObjectives() []Objective {
+	return []Objective{
+		{Operation: SendMessage, Target: 0.999, Latency: 500 * time.Millisecond},
+		{Operation: Deliver, Target: 0.999, Latency: 2 * time.Second},
+		{Operation: VerifyOTP, Target: 0.999, Latency: time.Second},
+	}
+}
+
+// RecorderConfig holds configuration for creating a Recorder.
+type RecorderConfig struct {
+	Objectives []Objective
+	// Rules default to DefaultRules. Their windows are the burn rate
+	// windows tracked and exported.
+	Rules []Rule
+	// OnAlert is called when a rule starts or stops firing. Optional.
+	OnAlert func(ctx context.Context, a Alert)
+	// MinEvents is the fewest events in a window for its rules to fire, so
+	// a single failure at a quiet hour does not page. Zero defaults to
+	// defaultMinEvents.
+	MinEvents int64
+	Clock     domain.Clock
+}
+
+const defaultMinEvents = 100
+
+// Recorder counts good and bad events per operation and derives burn
+// rates over each rule window. Safe for concurrent use.
+type Recorder struct {
+	ops       map[string]*operation
+	rules     []Rule
+	onAlert   func(ctx context.Context, a Alert)
+	minEvents int64
+	clock     domain.Clock
+
+	events       metric.Int64Counter
+	registration metric.Registration
+
+	mu     sync.Mutex
+	firing map[alertKey]bool
+}
+
+// operation holds one objective's windows, keyed by duration.
+type operation struct {
+	objective Objective
+	windows   map[time.Duration]*window
+	goodAttr  metric.AddOption
+	badAttr   metric.AddOption
+}
+
+// NewRecorder creates a Recorder and registers its metrics:
+// slo_events_total{operation, result} and slo_burn_rate{operation, window}.
+func NewRecorder(cfg RecorderConfig) (*Recorder, error) {
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	minEvents := cfg.MinEvents
+	if minEvents <= 0 {
+		minEvents = defaultMinEvents
+	}
+
+	r := &Recorder{
+		ops:       make(map[string]*operation, len(cfg.Objectives)),
+		rules:     rules,
+		onAlert:   cfg.OnAlert,
+		minEvents: minEvents,
+		clock:     cfg.Clock,
+		firing:    make(map[alertKey]bool),
+	}
+	for _, o := range cfg.Objectives {
+		if o.Target <= 0 || o.Target >= 1 {
+			return nil, fmt.Errorf("%w: slo %s target %g not in (0, 1)", domain.ErrConfigInvalid, o.Operation, o.Target)
+		}
+		op := &operation{
+			objective: o,
+			windows:   make(map[time.Duration]*window, len(rules)),
+			goodAttr:  metric.WithAttributes(attribute.String("operation", o.Operation), attribute.String("result", "good")),
+			badAttr:   metric.WithAttributes(attribute.String("operation", o.Operation), attribute.String("result", "bad")),
+		}
+		for _, rule := range rules {
+			op.windows[rule.Window] = newWindow(rule.Window)
+		}
+		r.ops[o.Operation] = op
+	}
+
+	m := otel.Meter("slo")
+	var err error
+	r.events, err = m.Int64Counter("slo_events_total",
+		metric.WithDescription("Events measured against an SLO, by operation and result (good, bad)"))
+	if err != nil {
+		return nil, fmt.Errorf("create slo_events_total: %w", err)
+	}
+	burnRate, err := m.Float64ObservableGauge("slo_burn_rate",
+		metric.WithDescription("Error budget burn rate by operation and window; 1 spends the budget exactly over the SLO period"))
+	if err != nil {
+		return nil, fmt.Errorf("create slo_burn_rate: %w", err)
+	}
+	r.registration, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		now := r.clock.Now()
+		for name, op := range r.ops {
+			for span, w := range op.windows {
+				o.ObserveFloat64(burnRate, op.burnRate(w, now), metric.WithAttributes(
+					attribute.String("operation", name), attribute.String("window", span.String())))
+			}
+		}
+		return nil
+	}, burnRate)
+	if err != nil {
+		return nil, fmt.Errorf("register slo_burn_rate: %w", err)
+	}
+	return r, nil
+}
+
+// Record counts one event for operation. err is the operation's result;
+// errors that map to a 4xx status are the caller's and count as good.
+// Operations without an objective are ignored.
+func (r *Recorder) Record(ctx context.Context, operation string, elapsed time.Duration, err error) {
+	op, ok := r.ops[operation]
+	if !ok {
+		return
+	}
+	good := (err == nil || errmap.ToHTTPStatusCode(err) < http.StatusInternalServerError) &&
+		(op.objective.Latency == 0 || elapsed <= op.objective.Latency)
+
+	now := r.clock.Now()
+	for _, w := range op.windows {
+		w.add(now, good)
+	}
+	if good {
+		r.events.Add(ctx, 1, op.goodAttr)
+	} else {
+		r.events.Add(ctx, 1, op.badAttr)
+	}
+}
+
+// Start returns a func that records operation with the time since Start.
+//
+//	done := recorder.Start(ctx, slo.VerifyOTP)
+//	result, err := svc.VerifyOTP(ctx, ...)
+//	done(err)
+func (r *Recorder) Start(ctx context.Context, operation string) func(err error) {
+	start := r.clock.Now()
+	return func(err error) {
+		r.Record(ctx, operation, r.clock.Now().Sub(start), err)
+	}
+}
+
+// BurnRate returns operation's current burn rate over window: the bad
+// fraction divided by the error budget (1 - Target).
+func (r *Recorder) BurnRate(operation string, window time.Duration) float64 {
+	op, ok := r.ops[operation]
+	if !ok {
+		return 0
+	}
+	w, ok := op.windows[window]
+	if !ok {
+		return 0
+	}
+	return op.burnRate(w, r.clock.Now())
+}
+
+// Close unregisters the burn rate gauge.
+func (r *Recorder) Close() error {
+	return r.registration.Unregister()
+}
+
+func (op *operation) burnRate(w *window, now time.Time) float64 {
+	total, bad := w.counts(now)
+	if total == 0 {
+		return 0
+	}
+	return float64(bad) / float64(total) / (1 - op.objective.Target)
+}
+
+var defaultRecorder atomic.Pointer[Recorder]
+
+// SetDefault installs r as the recorder used by the package-level Start.
+// server.Run installs one per service.
+func SetDefault(r *Recorder) {
+	defaultRecorder.Store(r)
+}
+
+// Start is Recorder.Start on the default recorder. Without one it returns
+// a no-op.
+func Start(ctx context.Context, operation string) func(err error) {
+	if r := defaultRecorder.Load(); r != nil {
+		return r.Start(ctx, operation)
+	}
+	return func(error) {}
+}
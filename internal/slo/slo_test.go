@@ -0,0 +1,169 @@
+package slo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/domain/domaintest"
+	"github.com/aelexs/realtime-messaging-platform/internal/slo"
+)
+
+var (
+	epoch    = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fastRule = slo.Rule{Severity: "SEV-1", Window: time.Hour, BurnRate: 14.4}
+)
+
+func newRecorder(t *testing.T, cfg slo.RecorderConfig) (*slo.Recorder, *domaintest.FakeClock) {
+	t.Helper()
+	clock := domaintest.NewFakeClock(epoch)
+	cfg.Clock = clock
+	if cfg.Objectives == nil {
+		cfg.Objectives = []slo.Objective{{Operation: slo.SendMessage, Target: 0.99, Latency: 500 * time.Millisecond}}
+	}
+	r, err := slo.NewRecorder(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, r.Close()) })
+	return r, clock
+}
+
+// record adds good and bad send_message events.
+func record(r *slo.Recorder, good, bad int) {
+	ctx := context.Background()
+	for range good {
+		r.Record(ctx, slo.SendMessage, 10*time.Millisecond, nil)
+	}
+	for range bad {
+		r.Record(ctx, slo.SendMessage, 10*time.Millisecond, domain.ErrUnavailable)
+	}
+}
+
+func TestRecorder_BurnRate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bad fraction over the error budget", func(t *testing.T) {
+		r, _ := newRecorder(t, slo.RecorderConfig{Rules: []slo.Rule{fastRule}})
+		record(r, 95, 5) // 5% bad against a 1% budget
+
+		assert.InDelta(t, 5.0, r.BurnRate(slo.SendMessage, time.Hour), 1e-9)
+	})
+
+	t.Run("classifies events", func(t *testing.T) {
+		r, _ := newRecorder(t, slo.RecorderConfig{Rules: []slo.Rule{fastRule}})
+
+		r.Record(ctx, slo.SendMessage, time.Second, nil)                      // too slow
+		r.Record(ctx, slo.SendMessage, time.Millisecond, domain.ErrNotMember) // caller's fault
+		r.Record(ctx, slo.SendMessage, time.Millisecond, errors.New("boom"))  // server error
+		r.Record(ctx, slo.SendMessage, time.Millisecond, nil)
+		r.Record(ctx, "unknown_operation", time.Hour, errors.New("ignored"))
+
+		assert.InDelta(t, 50.0, r.BurnRate(slo.SendMessage, time.Hour), 1e-9, "2 of 4 bad against a 1% budget")
+	})
+
+	t.Run("events age out of the window", func(t *testing.T) {
+		r, clock := newRecorder(t, slo.RecorderConfig{Rules: []slo.Rule{fastRule}})
+		record(r, 0, 10)
+
+		clock.Advance(30 * time.Minute)
+		record(r, 10, 0)
+		assert.InDelta(t, 50.0, r.BurnRate(slo.SendMessage, time.Hour), 1e-9)
+
+		clock.Advance(31 * time.Minute)
+		assert.Zero(t, r.BurnRate(slo.SendMessage, time.Hour))
+	})
+
+	t.Run("start measures elapsed time", func(t *testing.T) {
+		r, clock := newRecorder(t, slo.RecorderConfig{Rules: []slo.Rule{fastRule}})
+
+		done := r.Start(ctx, slo.SendMessage)
+		clock.Advance(time.Second)
+		done(nil)
+
+		assert.InDelta(t, 100.0, r.BurnRate(slo.SendMessage, time.Hour), 1e-9)
+	})
+}
+
+func TestNewRecorder_RejectsBadTarget(t *testing.T) {
+	for _, target := range []float64{0, 1, 1.5} {
+		t.Run(fmt.Sprint(target), func(t *testing.T) {
+			_, err := slo.NewRecorder(slo.RecorderConfig{
+				Objectives: []slo.Objective{{Operation: slo.Deliver, Target: target}},
+				Clock:      domain.RealClock{},
+			})
+			assert.ErrorIs(t, err, domain.ErrConfigInvalid)
+		})
+	}
+}
+
+func TestRecorder_Evaluate(t *testing.T) {
+	ctx := context.Background()
+
+	var alerts []slo.Alert
+	onAlert := func(_ context.Context, a slo.Alert) { alerts = append(alerts, a) }
+
+	t.Run("fires once and resolves", func(t *testing.T) {
+		alerts = nil
+		r, clock := newRecorder(t, slo.RecorderConfig{Rules: []slo.Rule{fastRule}, OnAlert: onAlert, MinEvents: 10})
+
+		record(r, 80, 20) // burn rate 20
+		r.Evaluate(ctx)
+		r.Evaluate(ctx)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, slo.SendMessage, alerts[0].Operation)
+		assert.Equal(t, "SEV-1", alerts[0].Rule.Severity)
+		assert.True(t, alerts[0].Firing)
+		assert.InDelta(t, 20.0, alerts[0].BurnRate, 1e-9)
+
+		clock.Advance(2 * time.Hour)
+		r.Evaluate(ctx)
+		require.Len(t, alerts, 2)
+		assert.False(t, alerts[1].Firing)
+	})
+
+	t.Run("too few events do not fire", func(t *testing.T) {
+		alerts = nil
+		r, _ := newRecorder(t, slo.RecorderConfig{Rules: []slo.Rule{fastRule}, OnAlert: onAlert})
+
+		record(r, 0, 5) // 100% bad, but below the default minimum
+		r.Evaluate(ctx)
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("rules per window apply to every operation", func(t *testing.T) {
+		alerts = nil
+		r, _ := newRecorder(t, slo.RecorderConfig{
+			Objectives: slo.DefaultObjectives(),
+			OnAlert:    onAlert,
+			MinEvents:  1,
+		})
+
+		// 0.5% bad: 5x burn with a 0.1% budget fires SEV-3 (3x, 1d) and
+		// SEV-4 (1x, 3d) but not SEV-1 (14.4x) or SEV-2 (6x).
+		for i := range 1000 {
+			var err error
+			if i%200 == 0 {
+				err = domain.ErrUnavailable
+			}
+			r.Record(ctx, slo.Deliver, time.Millisecond, err)
+		}
+		r.Evaluate(ctx)
+
+		var severities []string
+		for _, a := range alerts {
+			assert.Equal(t, slo.Deliver, a.Operation)
+			severities = append(severities, a.Rule.Severity)
+		}
+		assert.ElementsMatch(t, []string{"SEV-3", "SEV-4"}, severities)
+	})
+}
+
+func TestStart_WithoutDefaultIsNoop(t *testing.T) {
+	slo.SetDefault(nil)
+	slo.Start(context.Background(), slo.VerifyOTP)(errors.New("ignored"))
+}
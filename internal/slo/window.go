@@ -0,0 +1,57 @@
+package slo
+
+import (
+	"sync"
+	"time"
+)
+
+// windowBuckets is the resolution of a sliding window: events age out one
+// bucket (1/60th of the window) at a time.
+const windowBuckets = 60
+
+// window counts events over a sliding span of time.
+type window struct {
+	width time.Duration
+
+	mu      sync.Mutex
+	buckets [windowBuckets]bucket
+}
+
+type bucket struct {
+	index      int64 // time / width of the bucket's start; identifies stale slots
+	total, bad int64
+}
+
+func newWindow(span time.Duration) *window {
+	return &window{width: max(span/windowBuckets, time.Nanosecond)}
+}
+
+func (w *window) add(now time.Time, good bool) {
+	idx := now.UnixNano() / int64(w.width)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[idx%windowBuckets]
+	if b.index != idx {
+		*b = bucket{index: idx}
+	}
+	b.total++
+	if !good {
+		b.bad++
+	}
+}
+
+// counts sums the buckets still inside the window at now.
+func (w *window) counts(now time.Time) (total, bad int64) {
+	idx := now.UnixNano() / int64(w.width)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, b := range w.buckets {
+		if b.index > idx-windowBuckets && b.index <= idx {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	return total, bad
+}
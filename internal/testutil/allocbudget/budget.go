@@ -0,0 +1,27 @@
+// Package allocbudget fails tests when a hot path allocates more than its
+// budget, so allocation regressions surface in CI rather than in profiles.
+package allocbudget
+
+import "testing"
+
+// Budget is the maximum average allocations per call. The race detector
+// makes more values escape to the heap, so it has its own budget.
+type Budget struct {
+	Allocs     float64
+	RaceAllocs float64
+}
+
+// runs is enough iterations to average out pool refills and GC noise.
+const runs = 200
+
+// Check fails t when fn allocates more than b on average.
+func Check(t testing.TB, b Budget, fn func()) {
+	t.Helper()
+	limit := b.Allocs
+	if raceEnabled {
+		limit = b.RaceAllocs
+	}
+	if got := testing.AllocsPerRun(runs, fn); got > limit {
+		t.Errorf("allocations per call = %v, budget %v (race detector: %v)", got, limit, raceEnabled)
+	}
+}
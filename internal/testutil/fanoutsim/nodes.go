@@ -0,0 +1,348 @@
+package fanoutsim
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	gatewayapp "github.com/aelexs/realtime-messaging-platform/internal/gateway/app"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+)
+
+func chatName(chat int) string { return "chat" + strconv.Itoa(chat) }
+
+func chatIndex(name string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(name, "chat"))
+	return i
+}
+
+func clientName(client int) string { return "c" + strconv.Itoa(client) }
+
+func clientIndex(name string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(name, "c"))
+	return i
+}
+
+// ingest allocates sequences per chat and records each client message ID
+// with its result, so a retried send is acknowledged with the original
+// sequence and not published again (ADR-004).
+type ingest struct {
+	s    *sim
+	log  [][]protocol.Message
+	seen []map[string]uint64 // client message ID -> sequence, per chat
+}
+
+func newIngest(s *sim) *ingest {
+	in := &ingest{s: s, log: make([][]protocol.Message, len(s.cfg.Chats)), seen: make([]map[string]uint64, len(s.cfg.Chats))}
+	for i := range in.seen {
+		in.seen[i] = map[string]uint64{}
+	}
+	return in
+}
+
+func (in *ingest) persist(client int, req protocol.SendMessage) {
+	chat := chatIndex(req.ChatID)
+	seq, dup := in.seen[chat][req.ClientMessageID]
+	if !dup {
+		seq = uint64(len(in.log[chat]) + 1)
+		in.seen[chat][req.ClientMessageID] = seq
+		in.log[chat] = append(in.log[chat], protocol.Message{
+			MessageID:       fmt.Sprintf("%s-%d", req.ChatID, seq),
+			ChatID:          req.ChatID,
+			SenderID:        clientName(client),
+			ClientMessageID: req.ClientMessageID,
+			Sequence:        seq,
+			ContentType:     req.ContentType,
+			Content:         req.Content,
+			CreatedAt:       in.s.clock.Now().UnixMilli(),
+		})
+		in.s.logf("ingest %s seq %d <- c%d %s", req.ChatID, seq, client, req.ClientMessageID)
+		in.publish(chat, seq)
+	} else {
+		in.s.logf("ingest %s seq %d <- c%d %s (duplicate)", req.ChatID, seq, client, req.ClientMessageID)
+	}
+
+	ack := protocol.SendMessageAck{ClientMessageID: req.ClientMessageID, Sequence: seq}
+	in.s.transmit(LinkAck, client, req.ClientMessageID, func() { in.s.clients[client].acked(ack) })
+}
+
+// publish hands the message to Fanout over the bus. The bus keeps every
+// record but is at-least-once: Fanout may process a record again.
+func (in *ingest) publish(chat int, seq uint64) {
+	msg := in.log[chat][seq-1]
+	in.s.transmit(LinkBus, -1, msg.MessageID, func() { in.s.fanout.process(chat, msg) })
+	if in.s.faulty() && in.s.rng.Float64() < in.s.cfg.Faults.Redeliver {
+		in.s.logf("bus %s: redelivered", msg.MessageID)
+		in.s.transmit(LinkBus, -1, msg.MessageID, func() { in.s.fanout.process(chat, msg) })
+	}
+}
+
+func (in *ingest) messages() [][]Message {
+	out := make([][]Message, len(in.log))
+	for c, msgs := range in.log {
+		for _, m := range msgs {
+			out[c] = append(out[c], toMessage(m))
+		}
+	}
+	return out
+}
+
+// Chat implements gatewayapp.SyncStore.
+func (in *ingest) Chat(_ context.Context, chatID string) (protocol.ChatSnapshot, error) {
+	return protocol.ChatSnapshot{ChatType: "group", LatestSequence: uint64(len(in.log[chatIndex(chatID)]))}, nil
+}
+
+// ListAfter implements gatewayapp.SyncStore.
+func (in *ingest) ListAfter(_ context.Context, chatID string, after uint64, limit int) ([]protocol.Message, error) {
+	log := in.log[chatIndex(chatID)]
+	if after >= uint64(len(log)) {
+		return nil, nil
+	}
+	return slices.Clone(log[after:min(uint64(len(log)), after+uint64(limit))]), nil
+}
+
+var _ gatewayapp.SyncStore = (*ingest)(nil)
+
+// delivery is one encoded frame bound for a member's connection.
+type delivery struct {
+	userID  string
+	chatID  string
+	payload []byte
+}
+
+// fanout resolves a message's members and dispatches one delivery per
+// member through a bounded priority queue.
+type fanout struct {
+	s     *sim
+	queue *domain.PriorityQueue[delivery]
+}
+
+func newFanout(s *sim) *fanout {
+	capacity := s.cfg.QueueCapacity
+	if capacity <= 0 {
+		capacity = domain.OutboundBufferSize
+	}
+	return &fanout{s: s, queue: domain.NewPriorityQueue[delivery](capacity)}
+}
+
+func (f *fanout) process(chat int, msg protocol.Message) {
+	frame, err := protocol.NewFrame(protocol.FrameTypeMessage, msg)
+	if err != nil {
+		panic(fmt.Sprintf("fanoutsim: encode message: %v", err))
+	}
+	payload, err := protocol.AppendFrame(nil, frame)
+	if err != nil {
+		panic(fmt.Sprintf("fanoutsim: encode message: %v", err))
+	}
+	for _, member := range f.s.cfg.Chats[chat] {
+		d := delivery{userID: clientName(member), chatID: msg.ChatID, payload: payload}
+		if !f.queue.Push(domain.PriorityMessage, d) {
+			// A full lane pauses the partition; the record is retried.
+			f.s.logf("fanout %s: message lane full, retrying", msg.MessageID)
+			f.s.after(f.s.cfg.AckTimeout, func() { f.process(chat, msg) })
+			return
+		}
+	}
+	for d, ok := f.queue.Pop(); ok; d, ok = f.queue.Pop() {
+		f.s.gateway.deliver(d)
+	}
+}
+
+// gateway forwards deliveries to connections and answers sync requests with
+// the production sync handler.
+type gateway struct {
+	s    *sim
+	sync *gatewayapp.SyncHandler
+}
+
+func newGateway(s *sim) *gateway {
+	return &gateway{s: s, sync: gatewayapp.NewSyncHandler(gatewayapp.SyncHandlerConfig{
+		Store:    s.ingest,
+		Policy:   s.cfg.Policy,
+		PageSize: s.cfg.PageSize,
+	})}
+}
+
+func (g *gateway) deliver(d delivery) {
+	client := clientIndex(d.userID)
+	g.s.transmit(LinkDeliver, client, d.chatID, func() { g.s.clients[client].receive(d.payload) })
+}
+
+func (g *gateway) handleSync(client int, req protocol.SyncRequest) {
+	resp, err := g.sync.Sync(context.Background(), req.ChatID, req.LastAckedSequence)
+	if err != nil {
+		g.s.logf("gateway sync %s for c%d: %v", req.ChatID, client, err)
+		return
+	}
+	g.s.transmit(LinkSync, client, req.ChatID+" response", func() { g.s.clients[client].synced(resp) })
+}
+
+// client holds its view of each chat it is a member of: the messages it has
+// received, deduplicated by sequence, and the contiguous prefix it has
+// acknowledged. A hole in the sequence, or the periodic tick, makes it
+// sync the chat from its acknowledged sequence.
+type client struct {
+	s       *sim
+	id      int
+	chats   map[int]*chatView
+	pending map[string]Send // unacknowledged sends by client message ID
+	sent    int
+}
+
+type chatView struct {
+	acked   uint64
+	held    map[uint64]protocol.Message
+	skipped map[uint64]bool
+	syncing time.Duration // when the in-flight sync was sent; negative when idle
+}
+
+func newClient(s *sim, id int) *client {
+	c := &client{s: s, id: id, chats: map[int]*chatView{}, pending: map[string]Send{}}
+	for chat, members := range s.cfg.Chats {
+		if slices.Contains(members, id) {
+			c.chats[chat] = &chatView{held: map[uint64]protocol.Message{}, skipped: map[uint64]bool{}, syncing: -1}
+		}
+	}
+	return c
+}
+
+func (c *client) send(sd Send) {
+	c.sent++
+	cmid := fmt.Sprintf("c%d-%d", c.id, c.sent)
+	c.pending[cmid] = sd
+	c.transmitSend(cmid, sd)
+}
+
+func (c *client) transmitSend(cmid string, sd Send) {
+	req := protocol.SendMessage{
+		ChatID:          chatName(sd.Chat),
+		ClientMessageID: cmid,
+		ContentType:     string(domain.ContentTypeText),
+		Content:         sd.Text,
+	}
+	c.s.transmit(LinkSend, c.id, cmid, func() { c.s.ingest.persist(c.id, req) })
+	c.s.after(c.s.cfg.AckTimeout, func() {
+		if _, ok := c.pending[cmid]; ok {
+			c.s.logf("c%d resend %s", c.id, cmid)
+			c.transmitSend(cmid, sd)
+		}
+	})
+}
+
+func (c *client) acked(ack protocol.SendMessageAck) {
+	delete(c.pending, ack.ClientMessageID)
+}
+
+func (c *client) receive(data []byte) {
+	f, err := protocol.DecodeFrame(data, protocol.DecodeLimits{})
+	if err != nil {
+		panic(fmt.Sprintf("fanoutsim: decode delivery: %v", err))
+	}
+	var msg protocol.Message
+	if err := f.ParsePayload(&msg); err != nil {
+		panic(fmt.Sprintf("fanoutsim: decode delivery: %v", err))
+	}
+	c.accept(msg)
+}
+
+// accept adds msg to its chat's view, ignoring one already held, and syncs
+// when it leaves a hole.
+func (c *client) accept(msg protocol.Message) {
+	chat := chatIndex(msg.ChatID)
+	v := c.chats[chat]
+	if _, ok := v.held[msg.Sequence]; ok || v.skipped[msg.Sequence] {
+		c.s.logf("c%d %s seq %d: already held", c.id, msg.ChatID, msg.Sequence)
+		return
+	}
+	v.held[msg.Sequence] = msg
+	v.advance()
+	if msg.Sequence > v.acked {
+		c.s.logf("c%d %s seq %d: gap after %d", c.id, msg.ChatID, msg.Sequence, v.acked)
+		c.syncChat(chat)
+	}
+}
+
+func (v *chatView) advance() {
+	for {
+		next := v.acked + 1
+		if _, ok := v.held[next]; !ok && !v.skipped[next] {
+			return
+		}
+		v.acked = next
+	}
+}
+
+// syncAll is the periodic tick: it syncs every chat, catching messages lost
+// at the tail of a chat where no later message reveals the hole.
+func (c *client) syncAll() {
+	for chat := range len(c.s.cfg.Chats) {
+		if _, ok := c.chats[chat]; ok {
+			c.syncChat(chat)
+		}
+	}
+}
+
+// syncChat requests the messages after the acknowledged sequence unless a
+// sync is already in flight. A sync lost on the network is abandoned after
+// the ack timeout.
+func (c *client) syncChat(chat int) {
+	v := c.chats[chat]
+	if v.syncing >= 0 && c.s.now()-v.syncing < c.s.cfg.AckTimeout {
+		return
+	}
+	v.syncing = c.s.now()
+	req := protocol.SyncRequest{ChatID: chatName(chat), LastAckedSequence: v.acked}
+	c.s.transmit(LinkSync, c.id, fmt.Sprintf("%s after %d", req.ChatID, req.LastAckedSequence), func() {
+		c.s.gateway.handleSync(c.id, req)
+	})
+}
+
+func (c *client) synced(resp protocol.SyncResponse) {
+	chat := chatIndex(resp.ChatID)
+	v := c.chats[chat]
+	v.syncing = -1
+	if resp.Gap != nil {
+		for seq := resp.Gap.FromSequence; seq <= resp.Gap.ToSequence; seq++ {
+			if _, ok := v.held[seq]; !ok {
+				v.skipped[seq] = true
+			}
+		}
+		c.s.logf("c%d %s: skipped %d-%d", c.id, resp.ChatID, resp.Gap.FromSequence, resp.Gap.ToSequence)
+	}
+	for _, msg := range resp.Messages {
+		if _, ok := v.held[msg.Sequence]; !ok && !v.skipped[msg.Sequence] {
+			v.held[msg.Sequence] = msg
+		}
+	}
+	v.advance()
+	c.s.logf("c%d %s: synced to %d", c.id, resp.ChatID, v.acked)
+	if resp.HasMore {
+		c.syncChat(chat)
+	}
+}
+
+func (c *client) views() []View {
+	out := make([]View, len(c.s.cfg.Chats))
+	for chat, v := range c.chats {
+		view := View{Member: true, Acked: v.acked, Skipped: len(v.skipped)}
+		for _, m := range v.held {
+			view.Messages = append(view.Messages, toMessage(m))
+		}
+		slices.SortFunc(view.Messages, func(a, b Message) int { return int(a.Sequence) - int(b.Sequence) })
+		out[chat] = view
+	}
+	return out
+}
+
+func toMessage(m protocol.Message) Message {
+	return Message{
+		Sequence:        m.Sequence,
+		Sender:          clientIndex(m.SenderID),
+		ClientMessageID: m.ClientMessageID,
+		Text:            m.Content,
+	}
+}
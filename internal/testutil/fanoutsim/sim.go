@@ -0,0 +1,381 @@
+// Package fanoutsim runs the message pipeline from Ingest through Fanout and
+// the Gateway to clients as a deterministic simulation. Every node runs on
+// one goroutine against a virtual clock, and the network between them drops,
+// delays, reorders and duplicates traffic by a seeded random source and
+// scripted outages. A seed therefore replays the same run event for event,
+// which makes a delivery edge case found once debuggable forever.
+//
+// The simulation uses the production priority lanes, the Gateway sync
+// handler and the protocol codec; sequence allocation and the clients' view
+// of each chat are modelled after ADR-004 and the client protocol contract.
+package fanoutsim
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+)
+
+// Link is a network hop that faults apply to.
+type Link int
+
+const (
+	// LinkSend carries send_message from a client to Ingest.
+	LinkSend Link = iota + 1
+	// LinkAck carries send_message_ack from Ingest back to the sender.
+	LinkAck
+	// LinkDeliver carries messages from Fanout through the Gateway to a
+	// client.
+	LinkDeliver
+	// LinkSync carries sync requests and responses between a client and
+	// the Gateway.
+	LinkSync
+	// LinkBus is the Kafka topic from Ingest to Fanout. It never loses a
+	// record, but Faults.Redeliver makes Fanout process one again.
+	LinkBus
+)
+
+func (l Link) String() string {
+	switch l {
+	case LinkSend:
+		return "send"
+	case LinkAck:
+		return "ack"
+	case LinkDeliver:
+		return "deliver"
+	case LinkSync:
+		return "sync"
+	case LinkBus:
+		return "bus"
+	}
+	return fmt.Sprintf("link(%d)", int(l))
+}
+
+// Faults is how the network misbehaves until Config.FaultsUntil.
+type Faults struct {
+	Drop      float64 // chance a client hop loses a message
+	Duplicate float64 // chance a client hop delivers a message twice
+	Redeliver float64 // chance Fanout processes a bus record again
+	// MinDelay and MaxDelay bound the latency of every hop. Latency is
+	// drawn per message, so messages overtake each other.
+	MinDelay, MaxDelay time.Duration
+	Outages            []Outage
+}
+
+// Outage loses everything on Link to and from Client, or every client when
+// Client is negative, between From and To.
+type Outage struct {
+	Link     Link
+	Client   int
+	From, To time.Duration
+}
+
+// Send is a scripted message: at At, Client sends Text to Chat.
+type Send struct {
+	At     time.Duration
+	Client int
+	Chat   int
+	Text   string
+}
+
+// Config describes a simulation run. Times are offsets from its start.
+type Config struct {
+	Seed uint64
+	// Chats lists each chat's members as client indexes. Clients is the
+	// number of clients; every client index in Chats must be below it.
+	Chats   [][]int
+	Clients int
+	Sends   []Send
+	Faults  Faults
+	// FaultsUntil is when the network heals: afterwards no hop loses or
+	// duplicates a message and every client view must converge.
+	FaultsUntil time.Duration
+	// Deadline ends a run that has not converged. Zero defaults to one
+	// minute after FaultsUntil.
+	Deadline time.Duration
+
+	AckTimeout   time.Duration // resend an unacknowledged message; zero defaults to 500ms
+	SyncInterval time.Duration // clients sync every chat this often; zero defaults to 2s
+
+	Policy        domain.SyncPolicy // zero uses domain.DefaultSyncPolicy
+	PageSize      int               // zero uses domain.DefaultPageSize
+	QueueCapacity int               // per lane; zero uses domain.OutboundBufferSize
+}
+
+// Result is the end state of a run.
+type Result struct {
+	// Log is each chat's persisted messages; Log[c][i] has sequence i+1.
+	Log [][]Message
+	// Views is what each client holds of each chat, indexed by client and
+	// chat. A client that is not a member of a chat has a zero view.
+	Views [][]View
+	// Converged reports whether every view matched the log, with every send
+	// acknowledged, before the deadline. End is when the run stopped.
+	Converged bool
+	End       time.Duration
+	// Trace is every event of the run, in order. Two runs of one Config
+	// have the same trace.
+	Trace []string
+}
+
+// Message is a persisted message as a client sees it.
+type Message struct {
+	Sequence        uint64
+	Sender          int
+	ClientMessageID string
+	Text            string
+}
+
+// View is a client's copy of one chat.
+type View struct {
+	// Member is set when the client is a member of the chat; other views
+	// are empty.
+	Member bool
+	// Acked is the highest sequence below which the client holds or has
+	// skipped every message.
+	Acked uint64
+	// Messages are the messages the client holds, by sequence.
+	Messages []Message
+	// Skipped counts sequences a sync snapshot told the client to skip.
+	Skipped int
+}
+
+// start is the fixed wall time a simulation begins at, so traces and
+// timestamps do not depend on when the run happens.
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Run simulates cfg to convergence or its deadline.
+func Run(cfg Config) *Result {
+	s := newSim(cfg)
+	for _, send := range cfg.Sends {
+		s.at(send.At, func() { s.clients[send.Client].send(send) })
+	}
+	s.at(cfg.FaultsUntil, s.checkConvergence)
+	for i := range s.clients {
+		s.every(s.cfg.SyncInterval, s.clients[i].syncAll)
+	}
+	s.loop()
+	return s.result()
+}
+
+// sim is the event loop shared by every node.
+type sim struct {
+	cfg     Config
+	rng     *rand.Rand
+	clock   *virtualClock
+	events  eventQueue
+	nextID  uint64
+	stopped bool
+	trace   []string
+
+	ingest  *ingest
+	fanout  *fanout
+	gateway *gateway
+	clients []*client
+}
+
+func newSim(cfg Config) *sim {
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = 500 * time.Millisecond
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = 2 * time.Second
+	}
+	if cfg.Deadline <= 0 {
+		cfg.Deadline = cfg.FaultsUntil + time.Minute
+	}
+	if cfg.Faults.MaxDelay < cfg.Faults.MinDelay {
+		cfg.Faults.MaxDelay = cfg.Faults.MinDelay
+	}
+	s := &sim{
+		cfg:   cfg,
+		rng:   rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15)),
+		clock: &virtualClock{},
+	}
+	s.ingest = newIngest(s)
+	s.gateway = newGateway(s)
+	s.fanout = newFanout(s)
+	for i := range cfg.Clients {
+		s.clients = append(s.clients, newClient(s, i))
+	}
+	return s
+}
+
+// now is the simulated time since the start of the run.
+func (s *sim) now() time.Duration { return s.clock.elapsed }
+
+// at schedules fn at simulated time t.
+func (s *sim) at(t time.Duration, fn func()) {
+	s.nextID++
+	heap.Push(&s.events, &event{at: t, id: s.nextID, fn: fn})
+}
+
+// after schedules fn d after now.
+func (s *sim) after(d time.Duration, fn func()) { s.at(s.now()+d, fn) }
+
+// every runs fn at each multiple of d until the run stops.
+func (s *sim) every(d time.Duration, fn func()) {
+	var tick func()
+	tick = func() {
+		fn()
+		s.after(d, tick)
+	}
+	s.after(d, tick)
+}
+
+func (s *sim) loop() {
+	for !s.stopped && s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(*event)
+		if e.at > s.cfg.Deadline {
+			s.clock.elapsed = s.cfg.Deadline
+			s.logf("deadline")
+			return
+		}
+		s.clock.elapsed = e.at
+		e.fn()
+	}
+}
+
+func (s *sim) logf(format string, args ...any) {
+	s.trace = append(s.trace, fmt.Sprintf("%9s ", s.now())+fmt.Sprintf(format, args...))
+}
+
+// faulty reports whether the network still misbehaves.
+func (s *sim) faulty() bool { return s.now() < s.cfg.FaultsUntil }
+
+// transmit carries a message over link to or from client and runs arrive
+// when it lands, subject to the faults in force.
+func (s *sim) transmit(link Link, client int, what string, arrive func()) {
+	if s.faulty() {
+		if s.inOutage(link, client) {
+			s.logf("%s c%d %s: lost in outage", link, client, what)
+			return
+		}
+		if link != LinkBus && s.rng.Float64() < s.cfg.Faults.Drop {
+			s.logf("%s c%d %s: dropped", link, client, what)
+			return
+		}
+	}
+	s.after(s.delay(), arrive)
+	if s.faulty() && link != LinkBus && s.rng.Float64() < s.cfg.Faults.Duplicate {
+		s.logf("%s c%d %s: duplicated", link, client, what)
+		s.after(s.delay(), arrive)
+	}
+}
+
+func (s *sim) delay() time.Duration {
+	spread := s.cfg.Faults.MaxDelay - s.cfg.Faults.MinDelay
+	if spread <= 0 {
+		return s.cfg.Faults.MinDelay
+	}
+	return s.cfg.Faults.MinDelay + time.Duration(s.rng.Int64N(int64(spread)+1))
+}
+
+func (s *sim) inOutage(link Link, client int) bool {
+	for _, o := range s.cfg.Faults.Outages {
+		if o.Link == link && (o.Client < 0 || o.Client == client) && s.now() >= o.From && s.now() < o.To {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConvergence stops the run once the network has healed, every send
+// is acknowledged and every view matches the log; until then it checks
+// again every sync interval.
+func (s *sim) checkConvergence() {
+	if s.converged() {
+		s.logf("converged")
+		s.stopped = true
+		return
+	}
+	s.after(s.cfg.SyncInterval, s.checkConvergence)
+}
+
+func (s *sim) converged() bool {
+	for _, c := range s.clients {
+		if len(c.pending) > 0 {
+			return false
+		}
+	}
+	r := s.result()
+	return len(r.Divergence()) == 0
+}
+
+func (s *sim) result() *Result {
+	r := &Result{
+		Log:   s.ingest.messages(),
+		Views: make([][]View, len(s.clients)),
+		End:   s.now(),
+		Trace: s.trace,
+	}
+	for i, c := range s.clients {
+		r.Views[i] = c.views()
+	}
+	r.Converged = s.stopped
+	return r
+}
+
+// Divergence describes every way a member's view differs from the chat
+// log, in client and chat order. It is empty when the views converged.
+func (r *Result) Divergence() []string {
+	var out []string
+	for cl, views := range r.Views {
+		for ch, v := range views {
+			if !v.Member {
+				continue
+			}
+			log := r.Log[ch]
+			if v.Acked != uint64(len(log)) {
+				out = append(out, fmt.Sprintf("c%d chat%d: acked %d of %d", cl, ch, v.Acked, len(log)))
+			}
+			for _, m := range v.Messages {
+				if m.Sequence == 0 || m.Sequence > uint64(len(log)) || log[m.Sequence-1] != m {
+					out = append(out, fmt.Sprintf("c%d chat%d: holds %+v, not in the log", cl, ch, m))
+				}
+			}
+			if n := len(v.Messages) + v.Skipped; n > len(log) {
+				out = append(out, fmt.Sprintf("c%d chat%d: holds %d and skipped %d of %d", cl, ch, len(v.Messages), v.Skipped, len(log)))
+			}
+		}
+	}
+	return out
+}
+
+// event is a scheduled step. Events at the same time run in the order they
+// were scheduled.
+type event struct {
+	at time.Duration
+	id uint64
+	fn func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].id < q[j].id
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// virtualClock is the simulation's domain.Clock.
+type virtualClock struct {
+	elapsed time.Duration
+}
+
+func (c *virtualClock) Now() time.Time { return start.Add(c.elapsed) }
+
+var _ domain.Clock = (*virtualClock)(nil)
@@ -0,0 +1,129 @@
+package fanoutsim_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/domain"
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/fanoutsim"
+)
+
+// faultyConfig is three clients in two overlapping chats sending through a
+// lossy, duplicating, reordering network for ten seconds.
+func faultyConfig(seed uint64) fanoutsim.Config {
+	var sends []fanoutsim.Send
+	for i := range 40 {
+		client := i % 3
+		chat := 0
+		if client == 2 || (client == 1 && i%2 == 0) {
+			chat = 1
+		}
+		sends = append(sends, fanoutsim.Send{
+			At:     time.Duration(i) * 200 * time.Millisecond,
+			Client: client,
+			Chat:   chat,
+			Text:   fmt.Sprintf("message %d", i),
+		})
+	}
+	return fanoutsim.Config{
+		Seed:    seed,
+		Clients: 3,
+		Chats:   [][]int{{0, 1}, {1, 2}},
+		Sends:   sends,
+		Faults: fanoutsim.Faults{
+			Drop:      0.2,
+			Duplicate: 0.2,
+			Redeliver: 0.3,
+			MinDelay:  time.Millisecond,
+			MaxDelay:  300 * time.Millisecond,
+			Outages: []fanoutsim.Outage{
+				{Link: fanoutsim.LinkDeliver, Client: 1, From: 2 * time.Second, To: 5 * time.Second},
+				{Link: fanoutsim.LinkAck, Client: -1, From: 6 * time.Second, To: 7 * time.Second},
+			},
+		},
+		FaultsUntil: 10 * time.Second,
+	}
+}
+
+func TestRun_ReproducibleBySeed(t *testing.T) {
+	first := fanoutsim.Run(faultyConfig(7))
+	again := fanoutsim.Run(faultyConfig(7))
+	other := fanoutsim.Run(faultyConfig(8))
+
+	assert.Equal(t, first.Trace, again.Trace)
+	assert.Equal(t, first.Views, again.Views)
+	assert.NotEqual(t, first.Trace, other.Trace)
+}
+
+func TestRun_ConvergesUnderFaults(t *testing.T) {
+	for seed := range uint64(50) {
+		cfg := faultyConfig(seed)
+		r := fanoutsim.Run(cfg)
+
+		require.True(t, r.Converged, "seed %d: %v", seed, r.Divergence())
+		assert.Empty(t, r.Divergence(), "seed %d", seed)
+
+		// Every send is persisted exactly once, whatever the retries and
+		// duplicates on the way.
+		persisted := map[string]int{}
+		for _, log := range r.Log {
+			for _, m := range log {
+				persisted[m.Text]++
+			}
+		}
+		require.Len(t, persisted, len(cfg.Sends), "seed %d", seed)
+		for text, n := range persisted {
+			assert.Equal(t, 1, n, "seed %d: %q persisted %d times", seed, text, n)
+		}
+	}
+}
+
+func TestRun_SnapshotAfterLongOutage(t *testing.T) {
+	var sends []fanoutsim.Send
+	for i := range 30 {
+		sends = append(sends, fanoutsim.Send{At: time.Duration(i) * 100 * time.Millisecond, Client: 0, Chat: 0, Text: fmt.Sprintf("m%d", i)})
+	}
+	r := fanoutsim.Run(fanoutsim.Config{
+		Seed:    1,
+		Clients: 2,
+		Chats:   [][]int{{0, 1}},
+		Sends:   sends,
+		Faults: fanoutsim.Faults{
+			MinDelay: time.Millisecond,
+			MaxDelay: 20 * time.Millisecond,
+			Outages: []fanoutsim.Outage{
+				{Link: fanoutsim.LinkDeliver, Client: 1, From: 0, To: 5 * time.Second},
+				{Link: fanoutsim.LinkSync, Client: 1, From: 0, To: 5 * time.Second},
+			},
+		},
+		FaultsUntil:  5 * time.Second,
+		SyncInterval: 10 * time.Second,
+		Policy:       domain.SyncPolicy{SnapshotThreshold: 10, SnapshotMessages: 5},
+	})
+
+	require.True(t, r.Converged, r.Divergence())
+	offline := r.Views[1][0]
+	assert.Equal(t, uint64(30), offline.Acked)
+	assert.Equal(t, 25, offline.Skipped, "the snapshot skips to the latest messages")
+	require.Len(t, offline.Messages, 5)
+	assert.Equal(t, r.Log[0][25:], offline.Messages)
+}
+
+func TestRun_DeadlineBeforeConvergence(t *testing.T) {
+	cfg := faultyConfig(1)
+	cfg.Faults.Outages = []fanoutsim.Outage{
+		{Link: fanoutsim.LinkDeliver, Client: -1, From: 0, To: cfg.FaultsUntil},
+		{Link: fanoutsim.LinkSync, Client: -1, From: 0, To: cfg.FaultsUntil},
+	}
+	cfg.Deadline = 5 * time.Second
+
+	r := fanoutsim.Run(cfg)
+
+	assert.False(t, r.Converged)
+	assert.Equal(t, 5*time.Second, r.End)
+	assert.NotEmpty(t, r.Divergence())
+}
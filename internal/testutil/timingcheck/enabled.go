@@ -0,0 +1,5 @@
+//go:build timing
+
+package timingcheck
+
+const enabled = true
@@ -0,0 +1,107 @@
+// Package timingcheck fails tests when an operation's running time depends
+// on its input, so a comparison that returns at the first differing byte
+// is caught before it ships.
+//
+// It follows dudect: op runs on two classes of input, chosen at random per
+// sample so drift in the machine hits both equally, the slowest samples
+// are cropped as scheduler noise, and Welch's t-test decides whether the
+// two timing distributions differ.
+//
+// The check is statistical and needs a quiet, single-tenant CPU: under
+// -race or beside other packages' tests it reports noise as a leak. It
+// therefore runs only when built with -tags=timing (make test-timing) and
+// is skipped everywhere else, so it never gates the ordinary test run.
+package timingcheck
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	// samples is the number of timed batches, split between the classes.
+	samples = 20000
+	// batch is the number of calls per sample, enough to rise well above
+	// the clock's resolution for a hash comparison.
+	batch = 32
+	// cropPercentile drops the slowest samples, which are preemptions and
+	// GC pauses rather than the operation.
+	cropPercentile = 0.9
+	// Threshold is the |t| above which the classes are judged to differ.
+	// dudect uses 4.5 on a quiet machine; CI runners are not quiet.
+	Threshold = 10
+)
+
+// T returns Welch's t statistic between op's running times for class 0
+// and class 1. Values near zero mean the timings are indistinguishable.
+func T(op func(class int)) float64 {
+	for range samples / 10 { // warm caches and the branch predictor
+		op(rand.IntN(2))
+	}
+	var times [2][]float64
+	for range samples {
+		class := rand.IntN(2)
+		start := time.Now()
+		for range batch {
+			op(class)
+		}
+		times[class] = append(times[class], float64(time.Since(start)))
+	}
+	cutoff := percentile(append(times[0], times[1]...), cropPercentile)
+	for class := range times {
+		times[class] = slices.DeleteFunc(times[class], func(d float64) bool { return d > cutoff })
+	}
+	return welch(times[0], times[1])
+}
+
+// AssertConstantTime fails t when op's running time depends on its class.
+// It takes a few seconds, so it is skipped under -short and without the
+// timing build tag.
+func AssertConstantTime(t testing.TB, op func(class int)) {
+	t.Helper()
+	Require(t)
+	if got := T(op); math.Abs(got) > Threshold {
+		t.Errorf("running time depends on input: |t| = %.1f, threshold %d", math.Abs(got), Threshold)
+	}
+}
+
+// Require skips t unless timing checks are enabled: built with
+// -tags=timing and not running under -short.
+func Require(t testing.TB) {
+	t.Helper()
+	if !enabled {
+		t.Skip("timing check needs -tags=timing")
+	}
+	if testing.Short() {
+		t.Skip("timing check skipped in short mode")
+	}
+}
+
+func percentile(xs []float64, p float64) float64 {
+	sorted := slices.Sorted(slices.Values(xs))
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func welch(a, b []float64) float64 {
+	meanA, varA := meanVar(a)
+	meanB, varB := meanVar(b)
+	se := math.Sqrt(varA/float64(len(a)) + varB/float64(len(b)))
+	if se == 0 {
+		return 0
+	}
+	return (meanA - meanB) / se
+}
+
+func meanVar(xs []float64) (mean, variance float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, variance / float64(len(xs)-1)
+}
@@ -0,0 +1,40 @@
+package timingcheck_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/auth"
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/timingcheck"
+)
+
+// leakyEqual returns at the first differing byte, as == on strings does.
+func leakyEqual(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range len(a) {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestT(t *testing.T) {
+	timingcheck.Require(t)
+	secret := strings.Repeat("a", 4096)
+	inputs := [2]string{"b" + secret[1:], secret[:4095] + "b"}
+
+	t.Run("detects an early return", func(t *testing.T) {
+		got := timingcheck.T(func(class int) { leakyEqual(inputs[class], secret) })
+		assert.Greater(t, math.Abs(got), float64(timingcheck.Threshold))
+	})
+
+	t.Run("passes a constant-time comparison", func(t *testing.T) {
+		timingcheck.AssertConstantTime(t, func(class int) { auth.ConstantTimeEqualHash(inputs[class], secret) })
+	})
+}
@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Decoding errors. DecodeFrame wraps every failure in one of them.
+var (
+	ErrFrameTooLarge  = errors.New("frame exceeds size limit")
+	ErrFrameTooDeep   = errors.New("frame exceeds nesting limit")
+	ErrMalformedFrame = errors.New("malformed frame")
+)
+
+// DecodeLimits bounds the frames DecodeFrame accepts. A zero field is not
+// enforced.
+type DecodeLimits struct {
+	// MaxBytes bounds the encoded frame.
+	MaxBytes int
+	// MaxDepth bounds the nesting of JSON objects and arrays, counting the
+	// frame object itself.
+	MaxDepth int
+}
+
+// DecodeFrame decodes one frame received from a peer. It checks the limits
+// before parsing, so an oversized or deeply nested frame costs a single
+// pass over its bytes, and rejects anything but a JSON object. The payload
+// is kept raw; handlers parse it with ParsePayload.
+func DecodeFrame(data []byte, limits DecodeLimits) (*Frame, error) {
+	if limits.MaxBytes > 0 && len(data) > limits.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrFrameTooLarge, len(data), limits.MaxBytes)
+	}
+	depth, first := scanDepth(data)
+	if first != '{' {
+		return nil, fmt.Errorf("%w: not a JSON object", ErrMalformedFrame)
+	}
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return nil, fmt.Errorf("%w: depth %d, limit %d", ErrFrameTooDeep, depth, limits.MaxDepth)
+	}
+
+	var f Frame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedFrame, err)
+	}
+	if string(f.Payload) == "null" {
+		f.Payload = nil
+	}
+	return &f, nil
+}
+
+// scanDepth returns the deepest nesting of objects and arrays in data,
+// ignoring brackets inside strings, and the first non-space byte. It does
+// not validate the JSON; json.Unmarshal does that afterwards.
+func scanDepth(data []byte) (maxDepth int, first byte) {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if first == 0 && c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			first = c
+		}
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			maxDepth = max(maxDepth, depth)
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return maxDepth, first
+}
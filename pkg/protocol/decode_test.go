@@ -0,0 +1,114 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimits = protocol.DecodeLimits{MaxBytes: 1024, MaxDepth: 4}
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantType    protocol.FrameType
+		wantPayload string
+		wantErr     error
+	}{
+		{name: "frame", data: `{"type":"ping","payload":{"timestamp":1}}`, wantType: protocol.FrameTypePing, wantPayload: `{"timestamp":1}`},
+		{name: "no payload", data: `{"type":"ping"}`, wantType: protocol.FrameTypePing},
+		{name: "null payload", data: `{"type":"ping","payload":null}`, wantType: protocol.FrameTypePing},
+		{name: "leading whitespace", data: " \n{\"type\":\"ack\"}", wantType: protocol.FrameTypeAck},
+		{name: "unknown type is kept", data: `{"type":"future"}`, wantType: "future"},
+		{name: "brackets in strings do not nest", data: `{"type":"send_message","payload":{"content":"[[[[{{{{\"]]"}}`, wantType: protocol.FrameTypeSendMessage, wantPayload: `{"content":"[[[[{{{{\"]]"}`},
+		{name: "at the depth limit", data: `{"type":"x","payload":{"a":{"b":[1]}}}`, wantType: "x", wantPayload: `{"a":{"b":[1]}}`},
+		{name: "too deep", data: `{"type":"x","payload":{"a":{"b":[[1]]}}}`, wantErr: protocol.ErrFrameTooDeep},
+		{name: "too large", data: `{"type":"x","payload":"` + strings.Repeat("a", 1024) + `"}`, wantErr: protocol.ErrFrameTooLarge},
+		{name: "empty", data: ``, wantErr: protocol.ErrMalformedFrame},
+		{name: "array", data: `[{"type":"ping"}]`, wantErr: protocol.ErrMalformedFrame},
+		{name: "null", data: `null`, wantErr: protocol.ErrMalformedFrame},
+		{name: "truncated", data: `{"type":"ping"`, wantErr: protocol.ErrMalformedFrame},
+		{name: "trailing data", data: `{"type":"ping"}{}`, wantErr: protocol.ErrMalformedFrame},
+		{name: "type not a string", data: `{"type":7}`, wantErr: protocol.ErrMalformedFrame},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := protocol.DecodeFrame([]byte(tt.data), testLimits)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, f)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, f.Type)
+			assert.Equal(t, tt.wantPayload, string(f.Payload))
+		})
+	}
+}
+
+func TestDecodeFrame_ZeroLimitsAreNotEnforced(t *testing.T) {
+	data := `{"type":"x","payload":` + strings.Repeat("[", 100) + strings.Repeat("]", 100) + `}`
+
+	f, err := protocol.DecodeFrame([]byte(data), protocol.DecodeLimits{})
+
+	require.NoError(t, err)
+	assert.Equal(t, protocol.FrameType("x"), f.Type)
+}
+
+// FuzzDecodeFrame checks that DecodeFrame never panics, fails only with its
+// own errors, stays within its limits, and that every frame it accepts
+// re-encodes to a frame that decodes the same.
+func FuzzDecodeFrame(f *testing.F) {
+	for _, seed := range []string{
+		`{"type":"ping","payload":{"timestamp":1700000000000}}`,
+		`{"type":"send_message","payload":{"chat_id":"c","client_message_id":"m","content_type":"text","content":"hi"}}`,
+		`{"type":"sync_request","payload":{"chat_id":"c","last_acked_sequence":18446744073709551615}}`,
+		`{"type":"ack","payload":null}`,
+		`{"type":"x","payload":"\"}]"}`,
+		`{"type":"x","payload":[[[[[]]]]]}`,
+		`{"type":"<"}`,
+		`[]`,
+		`{`,
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := protocol.DecodeFrame(data, testLimits)
+		if err != nil {
+			if !errors.Is(err, protocol.ErrFrameTooLarge) && !errors.Is(err, protocol.ErrFrameTooDeep) &&
+				!errors.Is(err, protocol.ErrMalformedFrame) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+		if len(data) > testLimits.MaxBytes {
+			t.Fatalf("accepted %d bytes", len(data))
+		}
+
+		encoded, err := protocol.AppendFrame(nil, frame)
+		require.NoError(t, err)
+		again, err := protocol.DecodeFrame(encoded, protocol.DecodeLimits{MaxDepth: testLimits.MaxDepth})
+		require.NoError(t, err, "re-encoded %q", encoded)
+		assert.Equal(t, frame.Type, again.Type)
+		assert.True(t, jsonEqual(frame.Payload, again.Payload), "payload %q became %q", frame.Payload, again.Payload)
+	})
+}
+
+func jsonEqual(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
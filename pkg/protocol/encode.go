@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// maxPooledBuffer caps the buffers returned to the pool so one large sync
+// response does not pin its memory for the life of the process.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// AppendFrame appends the JSON encoding of f to dst. The output matches
+// json.Marshal for frames built with NewFrame, without its reflection and
+// intermediate allocations. The payload is written verbatim and must be
+// valid, compact JSON; frames prepared with Prepare reuse their encoding.
+func AppendFrame(dst []byte, f *Frame) ([]byte, error) {
+	if f.encoded != nil {
+		return append(dst, f.encoded...), nil
+	}
+
+	dst = append(dst, `{"type":`...)
+	if isPlainString(string(f.Type)) {
+		dst = append(dst, '"')
+		dst = append(dst, f.Type...)
+		dst = append(dst, '"')
+	} else {
+		quoted, err := json.Marshal(string(f.Type))
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, quoted...)
+	}
+	if len(f.Payload) > 0 {
+		dst = append(dst, `,"payload":`...)
+		dst = append(dst, f.Payload...)
+	}
+	return append(dst, '}'), nil
+}
+
+// WriteFrame encodes f into a pooled buffer and writes it to w in a single
+// Write call.
+func WriteFrame(w io.Writer, f *Frame) error {
+	bp := bufferPool.Get().(*[]byte)
+	defer func() {
+		if cap(*bp) <= maxPooledBuffer {
+			*bp = (*bp)[:0]
+			bufferPool.Put(bp)
+		}
+	}()
+
+	b, err := AppendFrame((*bp)[:0], f)
+	*bp = b
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Prepare encodes f once so every later AppendFrame or WriteFrame reuses the
+// bytes. Call it before broadcasting one frame to many connections; f must
+// not be modified afterwards.
+func (f *Frame) Prepare() error {
+	if f.encoded != nil {
+		return nil
+	}
+	b, err := AppendFrame(nil, f)
+	if err != nil {
+		return err
+	}
+	f.encoded = b
+	return nil
+}
+
+// isPlainString reports whether s can be written between quotes without
+// escaping, using the same rules as encoding/json (which also escapes
+// <, > and & for HTML safety).
+func isPlainString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x80 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			return false
+		}
+	}
+	return true
+}
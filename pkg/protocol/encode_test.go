@@ -0,0 +1,202 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aelexs/realtime-messaging-platform/internal/testutil/allocbudget"
+	"github.com/aelexs/realtime-messaging-platform/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage() protocol.Message {
+	return protocol.Message{
+		MessageID:       "01HQX5K3Z9Y8W7V6U5T4S3R2Q1",
+		ChatID:          "01HQX5K3Z9Y8W7V6U5T4S3R2Q0",
+		SenderID:        "user-001",
+		ClientMessageID: "5f1c7f3e-8a8e-4b7a-9d51-0c5b8c2c9d3e",
+		Sequence:        4242,
+		ContentType:     "text",
+		Content:         "see you at <8> & bring \"snacks\"",
+		CreatedAt:       1700000000000,
+	}
+}
+
+func TestAppendFrame_MatchesJSONMarshal(t *testing.T) {
+	msg, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(t, err)
+	ping, err := protocol.NewFrame(protocol.FrameTypePing, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		frame *protocol.Frame
+	}{
+		{name: "message", frame: msg},
+		{name: "nil payload", frame: ping},
+		{name: "type needing escapes", frame: &protocol.Frame{Type: `we"ird<type>`, Payload: json.RawMessage(`{}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := json.Marshal(tt.frame)
+			require.NoError(t, err)
+
+			got, err := protocol.AppendFrame(nil, tt.frame)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestFrame_Prepare(t *testing.T) {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(t, err)
+	want, err := protocol.AppendFrame(nil, f)
+	require.NoError(t, err)
+
+	require.NoError(t, f.Prepare())
+	require.NoError(t, f.Prepare(), "preparing twice is a no-op")
+
+	var buf bytes.Buffer
+	require.NoError(t, protocol.WriteFrame(&buf, f))
+	assert.Equal(t, string(want), buf.String())
+
+	batch, err := protocol.NewBatchFrame([]*protocol.Frame{f, f})
+	require.NoError(t, err)
+	var frames []protocol.Frame
+	require.NoError(t, batch.ParsePayload(&frames))
+	assert.Len(t, frames, 2)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestWriteFrame_PropagatesWriteError(t *testing.T) {
+	f, err := protocol.NewFrame(protocol.FrameTypePing, protocol.Ping{Timestamp: 1})
+	require.NoError(t, err)
+
+	assert.EqualError(t, protocol.WriteFrame(failingWriter{}, f), "broken pipe")
+}
+
+// TestAllocBudgets pins allocations on the per-frame hot paths. Raise a
+// budget only with a benchmark showing why.
+func TestAllocBudgets(t *testing.T) {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(t, err)
+	data, err := json.Marshal(f)
+	require.NoError(t, err)
+	buf := make([]byte, 0, 1024)
+
+	tests := []struct {
+		name   string
+		budget allocbudget.Budget
+		fn     func()
+	}{
+		{
+			name:   "AppendFrame into a reused buffer",
+			budget: allocbudget.Budget{Allocs: 0, RaceAllocs: 0},
+			fn:     func() { buf, _ = protocol.AppendFrame(buf[:0], f) },
+		},
+		{
+			// The race detector randomly drops pooled buffers.
+			name:   "WriteFrame",
+			budget: allocbudget.Budget{Allocs: 0, RaceAllocs: 1},
+			fn:     func() { _ = protocol.WriteFrame(io.Discard, f) },
+		},
+		{
+			name:   "NewFrame",
+			budget: allocbudget.Budget{Allocs: 4, RaceAllocs: 6},
+			fn:     func() { _, _ = protocol.NewFrame(protocol.FrameTypeMessage, testMessage()) },
+		},
+		{
+			name:   "decode frame and payload",
+			budget: allocbudget.Budget{Allocs: 8, RaceAllocs: 12},
+			fn:     func() { _ = decodeMessage(data) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocbudget.Check(t, tt.budget, tt.fn)
+		})
+	}
+}
+
+// decodeMessage is the inbound path: envelope first, then the typed payload.
+func decodeMessage(data []byte) error {
+	var f protocol.Frame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	var m protocol.Message
+	return f.ParsePayload(&m)
+}
+
+func BenchmarkNewFrame(b *testing.B) {
+	msg := testMessage()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := protocol.NewFrame(protocol.FrameTypeMessage, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeFrame(b *testing.B) {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(b, err)
+	data, err := json.Marshal(f)
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := decodeMessage(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Broadcast benchmarks model one message delivered to many connections: the
+// baseline marshals the frame per connection, the pooled path encodes into a
+// reused buffer, and the prepared path encodes once and copies bytes.
+
+func BenchmarkBroadcast_JSONMarshal(b *testing.B) {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for b.Loop() {
+		data, err := json.Marshal(f)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = io.Discard.Write(data)
+	}
+}
+
+func BenchmarkBroadcast_WriteFrame(b *testing.B) {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := protocol.WriteFrame(io.Discard, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBroadcast_WriteFramePrepared(b *testing.B) {
+	f, err := protocol.NewFrame(protocol.FrameTypeMessage, testMessage())
+	require.NoError(b, err)
+	require.NoError(b, f.Prepare())
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := protocol.WriteFrame(io.Discard, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -22,40 +22,108 @@ const (
 	FrameTypeMessage        FrameType = "message"
 	FrameTypeAck            FrameType = "ack"
 
+	// Receipts: a recipient's device received or read a message.
+	FrameTypeReceipt FrameType = "receipt"
+
 	// Sync (PR-6)
 	FrameTypeSyncRequest  FrameType = "sync_request"
 	FrameTypeSyncResponse FrameType = "sync_response"
 
+	// Ephemeral signals. Best-effort: the server may drop them under load.
+	FrameTypeTyping   FrameType = "typing"
+	FrameTypePresence FrameType = "presence"
+
+	// Notification feed: a new entry in the recipient's notifications.
+	FrameTypeNotification FrameType = "notification"
+
+	// Chunked attachment upload. Offered to clients that negotiated
+	// CapabilityUpload.
+	FrameTypeUploadBegin  FrameType = "upload_begin"
+	FrameTypeUploadChunk  FrameType = "upload_chunk"
+	FrameTypeUploadCommit FrameType = "upload_commit"
+	FrameTypeUploadStatus FrameType = "upload_status"
+
 	// Errors
 	FrameTypeError FrameType = "error"
+
+	// Batch carries several server frames in one WebSocket message. Sent
+	// only to clients that negotiated CapabilityBatch.
+	FrameTypeBatch FrameType = "batch"
+)
+
+// Capabilities are optional protocol features. The client lists the ones it
+// supports when connecting; the server echoes the ones it enabled in
+// connection_ack.
+const (
+	// CapabilityBatch lets the server coalesce frames into a batch frame
+	// whose payload is a JSON array of frames, delivered in order.
+	CapabilityBatch = "batch"
+
+	// CapabilityUpload lets the client upload attachments over the
+	// connection with upload_begin, upload_chunk and upload_commit.
+	CapabilityUpload = "upload"
 )
 
 // Frame is the base structure for all WebSocket frames.
 type Frame struct {
 	Type    FrameType       `json:"type"`
 	Payload json.RawMessage `json:"payload,omitempty"`
+
+	encoded []byte // set by Prepare
 }
 
 // ConnectionAck is sent by the server after successful WebSocket upgrade.
+// The token fields tell the client when to call RefreshTokens:
+// AccessTokenExpiresAt and AccessTokenTTLMs describe the access token the
+// connection authenticated with, and AccessTokenLifetimeMs and
+// RefreshTokenLifetimeMs are the configured lifetimes of the tokens the
+// next refresh issues. Each is omitted when the server does not know it.
+// Capabilities lists the optional features enabled for this connection.
 type ConnectionAck struct {
-	ConnectionID        string `json:"connection_id"`
-	HeartbeatIntervalMs int    `json:"heartbeat_interval_ms"`
+	ConnectionID           string   `json:"connection_id"`
+	HeartbeatIntervalMs    int      `json:"heartbeat_interval_ms"`
+	AccessTokenExpiresAt   int64    `json:"access_token_expires_at,omitempty"` // Unix millis
+	AccessTokenTTLMs       int64    `json:"access_token_ttl_ms,omitempty"`
+	AccessTokenLifetimeMs  int64    `json:"access_token_lifetime_ms,omitempty"`
+	RefreshTokenLifetimeMs int64    `json:"refresh_token_lifetime_ms,omitempty"`
+	Capabilities           []string `json:"capabilities,omitempty"`
 }
 
 // ConnectionClosing is sent by the server before closing the connection.
+// Reconnect, when present, overrides the client's built-in backoff.
 type ConnectionClosing struct {
-	Reason string `json:"reason"`
-	Code   int    `json:"code"`
+	Reason    string           `json:"reason"`
+	Code      int              `json:"code"`
+	Reconnect *ReconnectPolicy `json:"reconnect,omitempty"`
 }
 
-// Ping is sent by the server to check client liveness.
+// ReconnectPolicy tells the client how to pace reconnects and retries. The
+// client waits min(MaxBackoffMs, MinBackoffMs * 2^attempt), randomized by
+// ±Jitter of that delay, and gives up after RetryBudget attempts until the
+// user acts again. Operators tune it server-side to spread out reconnect
+// storms during incidents.
+type ReconnectPolicy struct {
+	MinBackoffMs int64   `json:"min_backoff_ms"`
+	MaxBackoffMs int64   `json:"max_backoff_ms"`
+	Jitter       float64 `json:"jitter"`       // Fraction of the delay, 0..1
+	RetryBudget  int     `json:"retry_budget"` // Attempts before giving up; 0 is unlimited
+}
+
+// Ping checks liveness. The server sends one every heartbeat interval; a
+// client may send its own to estimate its clock offset. Timestamp is the
+// sender's clock, in Unix millis.
 type Ping struct {
 	Timestamp int64 `json:"timestamp"`
 }
 
-// Pong is sent by the client in response to Ping.
+// Pong answers a Ping and echoes its Timestamp. A server pong also carries
+// the server clock when the ping arrived and the server's estimate of the
+// client clock's offset (client minus server), so the client can correct
+// displayed times. Neither is a basis for ordering: order by sequence.
 type Pong struct {
-	Timestamp int64 `json:"timestamp"`
+	Timestamp        int64 `json:"timestamp"`
+	ServerReceivedAt int64 `json:"server_received_at,omitempty"` // Unix millis, server pongs only
+	ClockOffsetMs    int64 `json:"clock_offset_ms,omitempty"`    // Server pongs only
 }
 
 // SendMessage is sent by the client to send a message.
@@ -64,26 +132,32 @@ type SendMessage struct {
 	ClientMessageID string `json:"client_message_id"`
 	ContentType     string `json:"content_type"`
 	Content         string `json:"content"`
+	ClientTimestamp int64  `json:"client_timestamp,omitempty"` // Unix millis; informational only
 }
 
 // SendMessageAck is sent by the server after message persistence.
+// ServerReceivedAt is when the gateway received the send_message.
 type SendMessageAck struct {
-	ClientMessageID string `json:"client_message_id"`
-	MessageID       string `json:"message_id"`
-	Sequence        uint64 `json:"sequence"`
-	CreatedAt       int64  `json:"created_at"`
+	ClientMessageID  string `json:"client_message_id"`
+	MessageID        string `json:"message_id"`
+	Sequence         uint64 `json:"sequence"`
+	CreatedAt        int64  `json:"created_at"`
+	ServerReceivedAt int64  `json:"server_received_at,omitempty"`
 }
 
 // Message is sent by the server to deliver a message to a recipient.
+// CreatedAt and ServerReceivedAt are server clock readings; the sender's
+// own clock is never delivered.
 type Message struct {
-	MessageID       string `json:"message_id"`
-	ChatID          string `json:"chat_id"`
-	SenderID        string `json:"sender_id"`
-	ClientMessageID string `json:"client_message_id"`
-	Sequence        uint64 `json:"sequence"`
-	ContentType     string `json:"content_type"`
-	Content         string `json:"content"`
-	CreatedAt       int64  `json:"created_at"`
+	MessageID        string `json:"message_id"`
+	ChatID           string `json:"chat_id"`
+	SenderID         string `json:"sender_id"`
+	ClientMessageID  string `json:"client_message_id"`
+	Sequence         uint64 `json:"sequence"`
+	ContentType      string `json:"content_type"`
+	Content          string `json:"content"`
+	CreatedAt        int64  `json:"created_at"`
+	ServerReceivedAt int64  `json:"server_received_at,omitempty"`
 }
 
 // Ack is sent by the client to acknowledge message receipt.
@@ -92,24 +166,129 @@ type Ack struct {
 	Sequence uint64 `json:"sequence"`
 }
 
+// Receipt statuses.
+const (
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+)
+
+// Receipt is sent by the server to a message's author when a recipient
+// received or read the message. Receipts are cumulative: Sequence covers
+// every earlier message in the chat as well.
+type Receipt struct {
+	ChatID   string `json:"chat_id"`
+	UserID   string `json:"user_id"` // The recipient
+	Sequence uint64 `json:"sequence"`
+	Status   string `json:"status"` // ReceiptDelivered or ReceiptRead
+}
+
 // SyncRequest is sent by the client to request missed messages.
 type SyncRequest struct {
 	ChatID            string `json:"chat_id"`
 	LastAckedSequence uint64 `json:"last_acked_sequence"`
 }
 
-// SyncResponse is sent by the server with missed messages.
+// SyncResponse is sent by the server with missed messages. When the client
+// is too far behind to replay, the server sends a snapshot instead: Chat is
+// set, Messages holds the latest messages, and Gap marks the sequences that
+// were skipped.
 type SyncResponse struct {
-	ChatID   string    `json:"chat_id"`
-	Messages []Message `json:"messages"`
-	HasMore  bool      `json:"has_more"`
+	ChatID   string        `json:"chat_id"`
+	Messages []Message     `json:"messages"`
+	HasMore  bool          `json:"has_more"`
+	Gap      *SyncGap      `json:"gap,omitempty"`
+	Chat     *ChatSnapshot `json:"chat,omitempty"`
 }
 
-// Error is sent by the server to report an error.
+// SyncGap is an inclusive range of sequences a snapshot skipped. Clients
+// treat the range as not yet loaded, not as deleted, and may backfill it
+// through the history API.
+type SyncGap struct {
+	FromSequence uint64 `json:"from_sequence"`
+	ToSequence   uint64 `json:"to_sequence"`
+}
+
+// ChatSnapshot is chat state sent with a snapshot sync.
+type ChatSnapshot struct {
+	ChatType       string `json:"chat_type"`
+	Name           string `json:"name,omitempty"`
+	LatestSequence uint64 `json:"latest_sequence"`
+}
+
+// Typing is sent by the server when a chat member starts or stops typing.
+type Typing struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+	Active bool   `json:"active"`
+}
+
+// Presence is sent by the server when a contact goes online or offline.
+type Presence struct {
+	UserID     string `json:"user_id"`
+	Online     bool   `json:"online"`
+	LastSeenAt int64  `json:"last_seen_at,omitempty"` // Unix millis; set when offline
+}
+
+// Notification is sent by the server when an entry is added to the user's
+// notification feed. Clients render it from Kind and Params, so the server
+// never sends localized text.
+type Notification struct {
+	NotificationID string            `json:"notification_id"`
+	Kind           string            `json:"kind"`
+	ChatID         string            `json:"chat_id,omitempty"`
+	ActorID        string            `json:"actor_id,omitempty"`
+	Params         map[string]string `json:"params,omitempty"`
+	CreatedAt      int64             `json:"created_at"` // Unix millis
+}
+
+// UploadBegin is sent by the client to start an attachment upload, or to
+// resume one by UploadID after reconnecting. SHA256 is the hex digest of
+// the whole file, checked on commit.
+type UploadBegin struct {
+	UploadID    string `json:"upload_id,omitempty"` // set to resume
+	ChatID      string `json:"chat_id"`
+	FileName    string `json:"file_name,omitempty"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// UploadChunk carries the file bytes from Offset. SHA256 is the hex digest
+// of Data. Data is base64 in JSON.
+type UploadChunk struct {
+	UploadID string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"`
+	SHA256   string `json:"sha256"`
+}
+
+// UploadCommit is sent by the client once every byte has been sent.
+type UploadCommit struct {
+	UploadID string `json:"upload_id"`
+}
+
+// UploadStatus answers every upload frame. Offset is the next byte the
+// server expects: after upload_begin it is where a resumed upload picks up,
+// and a chunk sent from any other offset is answered with the expected one.
+// AttachmentKey is set once the upload is complete.
+type UploadStatus struct {
+	UploadID      string `json:"upload_id"`
+	Offset        int64  `json:"offset"`
+	MaxChunkBytes int    `json:"max_chunk_bytes"`
+	Complete      bool   `json:"complete,omitempty"`
+	AttachmentKey string `json:"attachment_key,omitempty"`
+}
+
+// Error is sent by the server to report an error. ErrorCode is the error's
+// stable number in the server's error catalog; clients should branch on it
+// rather than on Message. Reconnect is set on retryable errors so clients
+// pace their retries by the server's policy.
 type Error struct {
-	Code    string            `json:"code"`
-	Message string            `json:"message"`
-	Details map[string]string `json:"details,omitempty"`
+	Code      string            `json:"code"`
+	ErrorCode int               `json:"error_code,omitempty"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Reconnect *ReconnectPolicy  `json:"reconnect,omitempty"`
 }
 
 // NewFrame creates a Frame with the given type and payload.
@@ -128,6 +307,22 @@ func NewFrame(frameType FrameType, payload interface{}) (*Frame, error) {
 	}, nil
 }
 
+// NewBatchFrame wraps frames in a single batch frame. Prepared frames are
+// copied in without re-encoding.
+func NewBatchFrame(frames []*Frame) (*Frame, error) {
+	payload := append(make([]byte, 0, 256*len(frames)), '[')
+	for i, f := range frames {
+		if i > 0 {
+			payload = append(payload, ',')
+		}
+		var err error
+		if payload, err = AppendFrame(payload, f); err != nil {
+			return nil, err
+		}
+	}
+	return &Frame{Type: FrameTypeBatch, Payload: append(payload, ']')}, nil
+}
+
 // ParsePayload unmarshals the frame payload into the given struct.
 func (f *Frame) ParsePayload(v interface{}) error {
 	if f.Payload == nil {
@@ -23,8 +23,12 @@ func TestNewFrame_AllTypes(t *testing.T) {
 		{name: "SendMessageAck", frameType: protocol.FrameTypeSendMessageAck, payload: protocol.SendMessageAck{ClientMessageID: "cmid-1", MessageID: "msg-1", Sequence: 42, CreatedAt: 1234567890}},
 		{name: "Message", frameType: protocol.FrameTypeMessage, payload: protocol.Message{MessageID: "msg-1", ChatID: "chat-1", SenderID: "user-1", ClientMessageID: "cmid-1", Sequence: 1, ContentType: "text", Content: "hello", CreatedAt: 1234567890}},
 		{name: "Ack", frameType: protocol.FrameTypeAck, payload: protocol.Ack{ChatID: "chat-1", Sequence: 5}},
+		{name: "Receipt", frameType: protocol.FrameTypeReceipt, payload: protocol.Receipt{ChatID: "chat-1", UserID: "user-2", Sequence: 5, Status: protocol.ReceiptRead}},
 		{name: "SyncRequest", frameType: protocol.FrameTypeSyncRequest, payload: protocol.SyncRequest{ChatID: "chat-1", LastAckedSequence: 10}},
 		{name: "SyncResponse", frameType: protocol.FrameTypeSyncResponse, payload: protocol.SyncResponse{ChatID: "chat-1", HasMore: true}},
+		{name: "Typing", frameType: protocol.FrameTypeTyping, payload: protocol.Typing{ChatID: "chat-1", UserID: "user-1", Active: true}},
+		{name: "Presence", frameType: protocol.FrameTypePresence, payload: protocol.Presence{UserID: "user-1", Online: false, LastSeenAt: 1234567890}},
+		{name: "Notification", frameType: protocol.FrameTypeNotification, payload: protocol.Notification{NotificationID: "n-1", Kind: "mention", ChatID: "chat-1", CreatedAt: 1234567890}},
 		{name: "Error", frameType: protocol.FrameTypeError, payload: protocol.Error{Code: "INVALID_INPUT", Message: "bad request"}},
 	}
 
@@ -58,13 +62,24 @@ func TestParsePayload_RoundTrip(t *testing.T) {
 		{
 			name:      "ConnectionAck",
 			frameType: protocol.FrameTypeConnectionAck,
-			payload:   protocol.ConnectionAck{ConnectionID: "conn-1", HeartbeatIntervalMs: 30000},
-			target:    &protocol.ConnectionAck{},
+			payload: protocol.ConnectionAck{
+				ConnectionID:           "conn-1",
+				HeartbeatIntervalMs:    30000,
+				AccessTokenExpiresAt:   1700003600000,
+				AccessTokenTTLMs:       3600000,
+				AccessTokenLifetimeMs:  3600000,
+				RefreshTokenLifetimeMs: 2592000000,
+			},
+			target: &protocol.ConnectionAck{},
 			assert: func(t *testing.T, target interface{}) {
 				t.Helper()
 				got := target.(*protocol.ConnectionAck)
 				assert.Equal(t, "conn-1", got.ConnectionID)
 				assert.Equal(t, 30000, got.HeartbeatIntervalMs)
+				assert.Equal(t, int64(1700003600000), got.AccessTokenExpiresAt)
+				assert.Equal(t, int64(3600000), got.AccessTokenTTLMs)
+				assert.Equal(t, int64(3600000), got.AccessTokenLifetimeMs)
+				assert.Equal(t, int64(2592000000), got.RefreshTokenLifetimeMs)
 			},
 		},
 		{
@@ -77,6 +92,24 @@ func TestParsePayload_RoundTrip(t *testing.T) {
 				got := target.(*protocol.ConnectionClosing)
 				assert.Equal(t, "going away", got.Reason)
 				assert.Equal(t, 1001, got.Code)
+				assert.Nil(t, got.Reconnect)
+			},
+		},
+		{
+			name:      "ConnectionClosing with reconnect policy",
+			frameType: protocol.FrameTypeConnectionClosing,
+			payload: protocol.ConnectionClosing{Reason: "server_shutdown", Code: 1001, Reconnect: &protocol.ReconnectPolicy{
+				MinBackoffMs: 1000, MaxBackoffMs: 30000, Jitter: 0.5, RetryBudget: 10,
+			}},
+			target: &protocol.ConnectionClosing{},
+			assert: func(t *testing.T, target interface{}) {
+				t.Helper()
+				got := target.(*protocol.ConnectionClosing)
+				require.NotNil(t, got.Reconnect)
+				assert.Equal(t, int64(1000), got.Reconnect.MinBackoffMs)
+				assert.Equal(t, int64(30000), got.Reconnect.MaxBackoffMs)
+				assert.InDelta(t, 0.5, got.Reconnect.Jitter, 1e-9)
+				assert.Equal(t, 10, got.Reconnect.RetryBudget)
 			},
 		},
 		{
@@ -101,6 +134,18 @@ func TestParsePayload_RoundTrip(t *testing.T) {
 				assert.Equal(t, int64(1234567890), got.Timestamp)
 			},
 		},
+		{
+			name:      "Pong with clock estimate",
+			frameType: protocol.FrameTypePong,
+			payload:   protocol.Pong{Timestamp: 1234567890, ServerReceivedAt: 1234562000, ClockOffsetMs: 5840},
+			target:    &protocol.Pong{},
+			assert: func(t *testing.T, target interface{}) {
+				t.Helper()
+				got := target.(*protocol.Pong)
+				assert.Equal(t, int64(1234562000), got.ServerReceivedAt)
+				assert.Equal(t, int64(5840), got.ClockOffsetMs)
+			},
+		},
 		{
 			name:      "SendMessage",
 			frameType: protocol.FrameTypeSendMessage,
@@ -183,6 +228,42 @@ func TestParsePayload_RoundTrip(t *testing.T) {
 				require.Len(t, got.Messages, 1)
 				assert.Equal(t, "msg-1", got.Messages[0].MessageID)
 				assert.True(t, got.HasMore)
+				assert.Nil(t, got.Gap)
+				assert.Nil(t, got.Chat)
+			},
+		},
+		{
+			name:      "SyncResponse snapshot",
+			frameType: protocol.FrameTypeSyncResponse,
+			payload: protocol.SyncResponse{
+				ChatID:   "chat-1",
+				Messages: []protocol.Message{{MessageID: "msg-5000", Sequence: 5000}},
+				Gap:      &protocol.SyncGap{FromSequence: 11, ToSequence: 4999},
+				Chat:     &protocol.ChatSnapshot{ChatType: "group", Name: "Team", LatestSequence: 5000},
+			},
+			target: &protocol.SyncResponse{},
+			assert: func(t *testing.T, target interface{}) {
+				t.Helper()
+				got := target.(*protocol.SyncResponse)
+				require.NotNil(t, got.Gap)
+				assert.Equal(t, protocol.SyncGap{FromSequence: 11, ToSequence: 4999}, *got.Gap)
+				require.NotNil(t, got.Chat)
+				assert.Equal(t, uint64(5000), got.Chat.LatestSequence)
+				assert.Equal(t, "Team", got.Chat.Name)
+			},
+		},
+		{
+			name:      "Notification",
+			frameType: protocol.FrameTypeNotification,
+			payload:   protocol.Notification{NotificationID: "n-1", Kind: "invite", ActorID: "user-2", Params: map[string]string{"chat_name": "Team"}, CreatedAt: 1234567890},
+			target:    &protocol.Notification{},
+			assert: func(t *testing.T, target interface{}) {
+				t.Helper()
+				got := target.(*protocol.Notification)
+				assert.Equal(t, "n-1", got.NotificationID)
+				assert.Equal(t, "invite", got.Kind)
+				assert.Equal(t, "Team", got.Params["chat_name"])
+				assert.Empty(t, got.ChatID)
 			},
 		},
 		{
@@ -255,3 +336,39 @@ func TestFrameJSONStructure(t *testing.T) {
 	assert.Contains(t, raw, "type")
 	assert.Contains(t, raw, "payload")
 }
+
+func TestConnectionAck_OmitsTokenFieldsWhenUnset(t *testing.T) {
+	data, err := json.Marshal(protocol.ConnectionAck{ConnectionID: "conn-1", HeartbeatIntervalMs: 30000})
+	require.NoError(t, err)
+
+	var raw map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &raw))
+
+	assert.NotContains(t, raw, "access_token_expires_at")
+	assert.NotContains(t, raw, "access_token_ttl_ms")
+	assert.NotContains(t, raw, "access_token_lifetime_ms")
+	assert.NotContains(t, raw, "refresh_token_lifetime_ms")
+}
+
+func TestNewBatchFrame(t *testing.T) {
+	first, err := protocol.NewFrame(protocol.FrameTypeMessage, protocol.Message{MessageID: "msg-1"})
+	require.NoError(t, err)
+	second, err := protocol.NewFrame(protocol.FrameTypePing, protocol.Ping{Timestamp: 1234567890})
+	require.NoError(t, err)
+
+	batch, err := protocol.NewBatchFrame([]*protocol.Frame{first, second})
+	require.NoError(t, err)
+	assert.Equal(t, protocol.FrameTypeBatch, batch.Type)
+
+	data, err := json.Marshal(batch)
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"type":"batch","payload":[{"type":"message","payload":`+string(first.Payload)+`},{"type":"ping","payload":{"timestamp":1234567890}}]}`,
+		string(data))
+
+	var frames []protocol.Frame
+	require.NoError(t, batch.ParsePayload(&frames))
+	require.Len(t, frames, 2)
+	assert.Equal(t, protocol.FrameTypeMessage, frames[0].Type)
+	assert.Equal(t, protocol.FrameTypePing, frames[1].Type)
+}
@@ -42,27 +42,43 @@ awslocal dynamodb update-time-to-live \
     --time-to-live-specification Enabled=true,AttributeName=ttl \
     2>/dev/null || true
 
-# users: PK=user_id, GSI phone_number-index (PK=phone_number).
+# users: PK=user_id, GSI phone_index-index (PK=phone_index, the phone's
+# blind index), the legacy phone_number-index (PK=phone_number) read until
+# userencrypt has indexed every user, and the admin user directory GSIs
+# created_month-index, phone_country-index and sparse flagged-index
+# (SK=created_at).
 awslocal dynamodb create-table \
     --table-name users \
     --attribute-definitions \
         AttributeName=user_id,AttributeType=S \
         AttributeName=phone_number,AttributeType=S \
+        AttributeName=phone_index,AttributeType=S \
+        AttributeName=created_at,AttributeType=S \
+        AttributeName=created_month,AttributeType=S \
+        AttributeName=phone_country,AttributeType=S \
+        AttributeName=flag_status,AttributeType=S \
     --key-schema AttributeName=user_id,KeyType=HASH \
     --global-secondary-indexes \
+        'IndexName=phone_index-index,KeySchema=[{AttributeName=phone_index,KeyType=HASH}],Projection={ProjectionType=KEYS_ONLY},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
         'IndexName=phone_number-index,KeySchema=[{AttributeName=phone_number,KeyType=HASH}],Projection={ProjectionType=KEYS_ONLY},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
+        'IndexName=created_month-index,KeySchema=[{AttributeName=created_month,KeyType=HASH},{AttributeName=created_at,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
+        'IndexName=phone_country-index,KeySchema=[{AttributeName=phone_country,KeyType=HASH},{AttributeName=created_at,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
+        'IndexName=flagged-index,KeySchema=[{AttributeName=flag_status,KeyType=HASH},{AttributeName=created_at,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
     --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
     2>/dev/null || echo "users table already exists"
 
-# sessions: PK=session_id, GSI user_sessions-index (PK=user_id), TTL on ttl.
+# sessions: PK=session_id, GSI user_sessions-index (PK=user_id), GSI
+# user_device-index (PK=user_id, SK=device_id), TTL on ttl.
 awslocal dynamodb create-table \
     --table-name sessions \
     --attribute-definitions \
         AttributeName=session_id,AttributeType=S \
         AttributeName=user_id,AttributeType=S \
+        AttributeName=device_id,AttributeType=S \
     --key-schema AttributeName=session_id,KeyType=HASH \
     --global-secondary-indexes \
         'IndexName=user_sessions-index,KeySchema=[{AttributeName=user_id,KeyType=HASH}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
+        'IndexName=user_device-index,KeySchema=[{AttributeName=user_id,KeyType=HASH},{AttributeName=device_id,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
     --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
     2>/dev/null || echo "sessions table already exists"
 
@@ -71,6 +87,195 @@ awslocal dynamodb update-time-to-live \
     --time-to-live-specification Enabled=true,AttributeName=ttl \
     2>/dev/null || true
 
+# token_lineage: PK=family_id, SK=generation, GSI user_lineage-index
+# (PK=user_id), TTL on ttl. Outlives sessions for reuse forensics.
+awslocal dynamodb create-table \
+    --table-name token_lineage \
+    --attribute-definitions \
+        AttributeName=family_id,AttributeType=S \
+        AttributeName=generation,AttributeType=N \
+        AttributeName=user_id,AttributeType=S \
+    --key-schema AttributeName=family_id,KeyType=HASH AttributeName=generation,KeyType=RANGE \
+    --global-secondary-indexes \
+        'IndexName=user_lineage-index,KeySchema=[{AttributeName=user_id,KeyType=HASH}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "token_lineage table already exists"
+
+awslocal dynamodb update-time-to-live \
+    --table-name token_lineage \
+    --time-to-live-specification Enabled=true,AttributeName=ttl \
+    2>/dev/null || true
+
+# device_tokens: PK=user_id, SK=device_id. One push token per device.
+awslocal dynamodb create-table \
+    --table-name device_tokens \
+    --attribute-definitions \
+        AttributeName=user_id,AttributeType=S \
+        AttributeName=device_id,AttributeType=S \
+    --key-schema AttributeName=user_id,KeyType=HASH AttributeName=device_id,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "device_tokens table already exists"
+
+# delivery_state: PK=user_id, SK=cursor (<device_id>#<chat_id>). Each
+# device's last acked sequence per chat, checkpointed by the Gateway.
+awslocal dynamodb create-table \
+    --table-name delivery_state \
+    --attribute-definitions \
+        AttributeName=user_id,AttributeType=S \
+        AttributeName=cursor,AttributeType=S \
+    --key-schema AttributeName=user_id,KeyType=HASH AttributeName=cursor,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "delivery_state table already exists"
+
+# notifications: PK=user_id, SK=notification_id (UUIDv7, time-ordered).
+awslocal dynamodb create-table \
+    --table-name notifications \
+    --attribute-definitions \
+        AttributeName=user_id,AttributeType=S \
+        AttributeName=notification_id,AttributeType=S \
+    --key-schema AttributeName=user_id,KeyType=HASH AttributeName=notification_id,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "notifications table already exists"
+
+awslocal dynamodb update-time-to-live \
+    --table-name notifications \
+    --time-to-live-specification Enabled=true,AttributeName=ttl \
+    2>/dev/null || true
+
+# entitlements: PK=subject (user#<id> or workspace#<id>). Billing plan and
+# limits, written by the payment provider webhook.
+awslocal dynamodb create-table \
+    --table-name entitlements \
+    --attribute-definitions AttributeName=subject,AttributeType=S \
+    --key-schema AttributeName=subject,KeyType=HASH \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "entitlements table already exists"
+
+# sso_connections: PK=workspace_id. Each workspace's OpenID Connect IdP.
+awslocal dynamodb create-table \
+    --table-name sso_connections \
+    --attribute-definitions AttributeName=workspace_id,AttributeType=S \
+    --key-schema AttributeName=workspace_id,KeyType=HASH \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "sso_connections table already exists"
+
+# sso_identities: PK=identity (<workspace_id>#<subject>). Links an IdP
+# subject to the phone-less user provisioned on its first sign-in.
+awslocal dynamodb create-table \
+    --table-name sso_identities \
+    --attribute-definitions AttributeName=identity,AttributeType=S \
+    --key-schema AttributeName=identity,KeyType=HASH \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "sso_identities table already exists"
+
+# scim_resources: PK=workspace_id, SK=resource (user#<id>, username#<name>,
+# group#<id>). Members and groups a workspace's IdP provisions over SCIM.
+awslocal dynamodb create-table \
+    --table-name scim_resources \
+    --attribute-definitions \
+        AttributeName=workspace_id,AttributeType=S \
+        AttributeName=resource,AttributeType=S \
+    --key-schema \
+        AttributeName=workspace_id,KeyType=HASH \
+        AttributeName=resource,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "scim_resources table already exists"
+
+# chat_keys: PK=chat_id, SK=version. KMS-wrapped data keys per chat, and
+# under pii#users for users' phone numbers and display names.
+awslocal dynamodb create-table \
+    --table-name chat_keys \
+    --attribute-definitions \
+        AttributeName=chat_id,AttributeType=S \
+        AttributeName=version,AttributeType=N \
+    --key-schema \
+        AttributeName=chat_id,KeyType=HASH \
+        AttributeName=version,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "chat_keys table already exists"
+
+# chats: PK=chat_id. Chat settings and the message shard count.
+awslocal dynamodb create-table \
+    --table-name chats \
+    --attribute-definitions AttributeName=chat_id,AttributeType=S \
+    --key-schema AttributeName=chat_id,KeyType=HASH \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "chats table already exists"
+
+# chat_memberships: PK=chat_id, SK=user_id. Members and their roles, and
+# pending join requests (status=pending). user_chats-index lists a user's
+# chats.
+awslocal dynamodb create-table \
+    --table-name chat_memberships \
+    --attribute-definitions \
+        AttributeName=chat_id,AttributeType=S \
+        AttributeName=user_id,AttributeType=S \
+    --key-schema \
+        AttributeName=chat_id,KeyType=HASH \
+        AttributeName=user_id,KeyType=RANGE \
+    --global-secondary-indexes \
+        'IndexName=user_chats-index,KeySchema=[{AttributeName=user_id,KeyType=HASH},{AttributeName=chat_id,KeyType=RANGE}],Projection={ProjectionType=ALL},ProvisionedThroughput={ReadCapacityUnits=5,WriteCapacityUnits=5}' \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "chat_memberships table already exists"
+
+awslocal dynamodb update-time-to-live \
+    --table-name chat_memberships \
+    --time-to-live-specification Enabled=true,AttributeName=ttl \
+    2>/dev/null || true
+
+# messages_v2: PK=pk (<chat_id>#shard<n>), SK=sequence. Chat messages,
+# sharded per chat by the chats item's message_shards.
+awslocal dynamodb create-table \
+    --table-name messages_v2 \
+    --attribute-definitions \
+        AttributeName=pk,AttributeType=S \
+        AttributeName=sequence,AttributeType=N \
+    --key-schema \
+        AttributeName=pk,KeyType=HASH \
+        AttributeName=sequence,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "messages_v2 table already exists"
+
+# chat_counters: PK=chat_id. Each chat's last allocated message sequence.
+awslocal dynamodb create-table \
+    --table-name chat_counters \
+    --attribute-definitions AttributeName=chat_id,AttributeType=S \
+    --key-schema AttributeName=chat_id,KeyType=HASH \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "chat_counters table already exists"
+
+# idempotency_keys: PK=chat_id, SK=client_message_id, TTL on ttl. The
+# result of every persisted client message ID, kept for retries.
+awslocal dynamodb create-table \
+    --table-name idempotency_keys \
+    --attribute-definitions \
+        AttributeName=chat_id,AttributeType=S \
+        AttributeName=client_message_id,AttributeType=S \
+    --key-schema \
+        AttributeName=chat_id,KeyType=HASH \
+        AttributeName=client_message_id,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "idempotency_keys table already exists"
+
+awslocal dynamodb update-time-to-live \
+    --table-name idempotency_keys \
+    --time-to-live-specification Enabled=true,AttributeName=ttl \
+    2>/dev/null || true
+
+# chat_events: PK=chat_id, SK=event_id (ULID, time-ordered). Append-only log
+# of settings and membership changes; events past retention are compacted
+# into a snapshot.
+awslocal dynamodb create-table \
+    --table-name chat_events \
+    --attribute-definitions \
+        AttributeName=chat_id,AttributeType=S \
+        AttributeName=event_id,AttributeType=S \
+    --key-schema \
+        AttributeName=chat_id,KeyType=HASH \
+        AttributeName=event_id,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
+    2>/dev/null || echo "chat_events table already exists"
+
 # --- PR-1: Secrets Manager (JWT signing key for local dev) ---
 
 echo "Creating Secrets Manager secrets..."
@@ -102,4 +307,11 @@ awslocal ssm put-parameter \
     --overwrite \
     2>/dev/null
 
+# --- S3 (chunked attachment uploads) ---
+
+echo "Creating S3 buckets..."
+
+awslocal s3api create-bucket --bucket attachments \
+    2>/dev/null || echo "attachments bucket already exists"
+
 echo "LocalStack initialization complete."
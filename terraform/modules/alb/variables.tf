@@ -72,7 +72,7 @@ variable "chatmgmt_port" {
 }
 
 variable "health_check_path" {
-  description = "Health check path for target groups"
+  description = "Health check path for target groups. /readyz also fails until service warmup finishes."
   type        = string
-  default     = "/healthz"
+  default     = "/readyz"
 }
@@ -1,4 +1,5 @@
-# DynamoDB auth tables — users, sessions, otp_requests
+# DynamoDB auth tables — users, sessions, token_lineage, otp_requests,
+# entitlements, sso_connections, sso_identities, scim_resources
 #
 # Implements TBD-TF1-2. All tables use On-Demand capacity, PITR, and
 # AWS-managed SSE. Deletion protection is environment-gated.
@@ -8,7 +9,9 @@ locals {
 }
 
 # -----------------------------------------------------------------------------
-# users — PK: user_id, GSI: phone_number-index (KEYS_ONLY)
+# users — PK: user_id, GSIs: phone_index-index (KEYS_ONLY), legacy
+#         phone_number-index (KEYS_ONLY), created_month-index,
+#         phone_country-index, flagged-index (ALL, sparse)
 # ADR-007 §2.4
 # -----------------------------------------------------------------------------
 
@@ -30,6 +33,46 @@ resource "aws_dynamodb_table" "users" {
     type = "S"
   }
 
+  attribute {
+    name = "phone_index"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_month"
+    type = "S"
+  }
+
+  attribute {
+    name = "phone_country"
+    type = "S"
+  }
+
+  attribute {
+    name = "flag_status"
+    type = "S"
+  }
+
+  # Phone lookups query the phone's blind index, an HMAC under the phone
+  # index pepper, so no searchable plaintext phone is indexed.
+  global_secondary_index {
+    name            = "phone_index-index"
+    projection_type = "KEYS_ONLY"
+
+    key_schema {
+      attribute_name = "phone_index"
+      key_type       = "HASH"
+    }
+  }
+
+  # Legacy phone lookup, read while PII_LEGACYPHONELOOKUP is on. Remove it,
+  # and the phone_number attribute above, once userencrypt has indexed every
+  # user and the lookup is off.
   global_secondary_index {
     name            = "phone_number-index"
     projection_type = "KEYS_ONLY"
@@ -40,6 +83,54 @@ resource "aws_dynamodb_table" "users" {
     }
   }
 
+  # Admin user directory (ADR-007 §2.4). Projected ALL so a listing page is
+  # one query; only support tooling reads these indexes.
+  global_secondary_index {
+    name            = "created_month-index"
+    projection_type = "ALL"
+
+    key_schema {
+      attribute_name = "created_month"
+      key_type       = "HASH"
+    }
+
+    key_schema {
+      attribute_name = "created_at"
+      key_type       = "RANGE"
+    }
+  }
+
+  global_secondary_index {
+    name            = "phone_country-index"
+    projection_type = "ALL"
+
+    key_schema {
+      attribute_name = "phone_country"
+      key_type       = "HASH"
+    }
+
+    key_schema {
+      attribute_name = "created_at"
+      key_type       = "RANGE"
+    }
+  }
+
+  # Sparse: only flagged users carry flag_status.
+  global_secondary_index {
+    name            = "flagged-index"
+    projection_type = "ALL"
+
+    key_schema {
+      attribute_name = "flag_status"
+      key_type       = "HASH"
+    }
+
+    key_schema {
+      attribute_name = "created_at"
+      key_type       = "RANGE"
+    }
+  }
+
   point_in_time_recovery {
     enabled = true
   }
@@ -50,7 +141,8 @@ resource "aws_dynamodb_table" "users" {
 }
 
 # -----------------------------------------------------------------------------
-# sessions — PK: session_id, GSI: user_sessions-index (ALL), TTL on ttl
+# sessions — PK: session_id, GSIs: user_sessions-index (ALL),
+# user_device-index (ALL), TTL on ttl
 # ADR-007 §2.8
 # -----------------------------------------------------------------------------
 
@@ -72,6 +164,11 @@ resource "aws_dynamodb_table" "sessions" {
     type = "S"
   }
 
+  attribute {
+    name = "device_id"
+    type = "S"
+  }
+
   global_secondary_index {
     name            = "user_sessions-index"
     projection_type = "ALL"
@@ -82,6 +179,22 @@ resource "aws_dynamodb_table" "sessions" {
     }
   }
 
+  # A login's device-conflict check: the user's sessions on one device.
+  global_secondary_index {
+    name            = "user_device-index"
+    projection_type = "ALL"
+
+    key_schema {
+      attribute_name = "user_id"
+      key_type       = "HASH"
+    }
+
+    key_schema {
+      attribute_name = "device_id"
+      key_type       = "RANGE"
+    }
+  }
+
   ttl {
     attribute_name = "ttl"
     enabled        = true
@@ -96,6 +209,60 @@ resource "aws_dynamodb_table" "sessions" {
   }
 }
 
+# -----------------------------------------------------------------------------
+# token_lineage — PK: family_id, SK: generation, GSI: user_lineage-index (ALL),
+# TTL on ttl. Refresh token generations, kept past session deletion for
+# reuse forensics.
+# -----------------------------------------------------------------------------
+
+resource "aws_dynamodb_table" "token_lineage" {
+  name         = "${local.name}-token-lineage"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "family_id"
+  range_key    = "generation"
+  table_class  = "STANDARD"
+
+  deletion_protection_enabled = var.enable_deletion_protection
+
+  attribute {
+    name = "family_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "generation"
+    type = "N"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "user_lineage-index"
+    projection_type = "ALL"
+
+    key_schema {
+      attribute_name = "user_id"
+      key_type       = "HASH"
+    }
+  }
+
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${local.name}-token-lineage"
+  }
+}
+
 # -----------------------------------------------------------------------------
 # otp_requests — PK: phone_hash, TTL on ttl, no GSI
 # ADR-015 Appendix A
@@ -127,3 +294,132 @@ resource "aws_dynamodb_table" "otp_requests" {
     Name = "${local.name}-otp-requests"
   }
 }
+
+# -----------------------------------------------------------------------------
+# entitlements — PK: subject (user#<id> or workspace#<id>), no GSI. Billing
+# plan and limits, written only by the payment provider webhook.
+# -----------------------------------------------------------------------------
+
+resource "aws_dynamodb_table" "entitlements" {
+  name         = "${local.name}-entitlements"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "subject"
+  table_class  = "STANDARD"
+
+  deletion_protection_enabled = var.enable_deletion_protection
+
+  attribute {
+    name = "subject"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${local.name}-entitlements"
+  }
+}
+
+# -----------------------------------------------------------------------------
+# sso_connections — PK: workspace_id, no GSI. Each workspace's OpenID Connect
+# identity provider and session policy, set through /admin/workspaces/sso.
+# Also records the workspace's data region and holds no personal data, so it
+# may be replicated to the other regions' deployments (a global table) to
+# let them point misrouted clients at the right endpoint.
+# -----------------------------------------------------------------------------
+
+resource "aws_dynamodb_table" "sso_connections" {
+  name         = "${local.name}-sso-connections"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "workspace_id"
+  table_class  = "STANDARD"
+
+  deletion_protection_enabled = var.enable_deletion_protection
+
+  attribute {
+    name = "workspace_id"
+    type = "S"
+  }
+
+  # Global tables replicate through streams.
+  stream_enabled   = length(var.sso_connections_replica_regions) > 0
+  stream_view_type = length(var.sso_connections_replica_regions) > 0 ? "NEW_AND_OLD_IMAGES" : null
+
+  dynamic "replica" {
+    for_each = var.sso_connections_replica_regions
+    content {
+      region_name = replica.value
+    }
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${local.name}-sso-connections"
+  }
+}
+
+# -----------------------------------------------------------------------------
+# sso_identities — PK: identity (<workspace_id>#<subject>), no GSI. Links an
+# IdP subject to its user; written with the user in one transaction.
+# -----------------------------------------------------------------------------
+
+resource "aws_dynamodb_table" "sso_identities" {
+  name         = "${local.name}-sso-identities"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "identity"
+  table_class  = "STANDARD"
+
+  deletion_protection_enabled = var.enable_deletion_protection
+
+  attribute {
+    name = "identity"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${local.name}-sso-identities"
+  }
+}
+
+# -----------------------------------------------------------------------------
+# scim_resources — PK: workspace_id, SK: resource, no GSI. Members
+# (user#<id>), their reserved userNames (username#<name>) and groups
+# (group#<id>) each workspace's IdP provisions over SCIM.
+# -----------------------------------------------------------------------------
+
+resource "aws_dynamodb_table" "scim_resources" {
+  name         = "${local.name}-scim-resources"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "workspace_id"
+  range_key    = "resource"
+  table_class  = "STANDARD"
+
+  deletion_protection_enabled = var.enable_deletion_protection
+
+  attribute {
+    name = "workspace_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "resource"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  tags = {
+    Name = "${local.name}-scim-resources"
+  }
+}
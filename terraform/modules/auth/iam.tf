@@ -135,7 +135,23 @@ resource "aws_iam_role_policy" "chatmgmt_auth" {
           "${aws_dynamodb_table.users.arn}/index/*",
           aws_dynamodb_table.sessions.arn,
           "${aws_dynamodb_table.sessions.arn}/index/*",
+          aws_dynamodb_table.token_lineage.arn,
+          "${aws_dynamodb_table.token_lineage.arn}/index/*",
           aws_dynamodb_table.otp_requests.arn,
+          aws_dynamodb_table.entitlements.arn,
+          aws_dynamodb_table.sso_connections.arn,
+          aws_dynamodb_table.sso_identities.arn,
+          aws_dynamodb_table.scim_resources.arn,
+        ]
+      },
+      {
+        Sid    = "DynamoDBIdleSessionSweep"
+        Effect = "Allow"
+        Action = [
+          "dynamodb:Scan",
+        ]
+        Resource = [
+          aws_dynamodb_table.sessions.arn,
         ]
       },
       {
@@ -185,8 +201,30 @@ resource "aws_iam_role_policy" "chatmgmt_auth" {
   })
 }
 
+# Bulk user import reads manifests from S3 (POST /admin/users/import).
+resource "aws_iam_role_policy" "chatmgmt_user_import" {
+  count = var.user_import_bucket_name == "" ? 0 : 1
+
+  name = "user-import-policy"
+  role = aws_iam_role.chatmgmt_task.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Sid    = "S3UserImportManifests"
+        Effect = "Allow"
+        Action = [
+          "s3:GetObject",
+        ]
+        Resource = "arn:aws:s3:::${var.user_import_bucket_name}/*"
+      },
+    ]
+  })
+}
+
 # -----------------------------------------------------------------------------
-# Gateway Task Role — JWT validation only (SSM)
+# Gateway Task Role — JWT validation (SSM) + session last_active_at writes
 # -----------------------------------------------------------------------------
 
 resource "aws_iam_role" "gateway_task" {
@@ -225,6 +263,16 @@ resource "aws_iam_role_policy" "gateway_auth" {
   policy = jsonencode({
     Version = "2012-10-17"
     Statement = [
+      {
+        Sid    = "DynamoDBSessionActivity"
+        Effect = "Allow"
+        Action = [
+          "dynamodb:UpdateItem",
+        ]
+        Resource = [
+          aws_dynamodb_table.sessions.arn,
+        ]
+      },
       {
         Sid    = "SSM"
         Effect = "Allow"
@@ -10,13 +10,43 @@ output "sessions_table_arn" {
   value       = aws_dynamodb_table.sessions.arn
 }
 
+output "token_lineage_table_arn" {
+  description = "ARN of the token_lineage DynamoDB table"
+  value       = aws_dynamodb_table.token_lineage.arn
+}
+
 output "otp_requests_table_arn" {
   description = "ARN of the otp_requests DynamoDB table"
   value       = aws_dynamodb_table.otp_requests.arn
 }
 
+output "entitlements_table_arn" {
+  description = "ARN of the entitlements DynamoDB table"
+  value       = aws_dynamodb_table.entitlements.arn
+}
+
+output "sso_connections_table_arn" {
+  description = "ARN of the sso_connections DynamoDB table"
+  value       = aws_dynamodb_table.sso_connections.arn
+}
+
+output "sso_identities_table_arn" {
+  description = "ARN of the sso_identities DynamoDB table"
+  value       = aws_dynamodb_table.sso_identities.arn
+}
+
+output "scim_resources_table_arn" {
+  description = "ARN of the scim_resources DynamoDB table"
+  value       = aws_dynamodb_table.scim_resources.arn
+}
+
 output "users_phone_index_arn" {
-  description = "ARN of the users phone_number-index GSI"
+  description = "ARN of the users phone_index-index GSI"
+  value       = "${aws_dynamodb_table.users.arn}/index/phone_index-index"
+}
+
+output "users_legacy_phone_index_arn" {
+  description = "ARN of the users phone_number-index GSI, read until phones are backfilled into phone_index"
   value       = "${aws_dynamodb_table.users.arn}/index/phone_number-index"
 }
 
@@ -25,6 +55,11 @@ output "sessions_user_index_arn" {
   value       = "${aws_dynamodb_table.sessions.arn}/index/user_sessions-index"
 }
 
+output "sessions_device_index_arn" {
+  description = "ARN of the sessions user_device-index GSI"
+  value       = "${aws_dynamodb_table.sessions.arn}/index/user_device-index"
+}
+
 # DynamoDB Tables — Names
 
 output "users_table_name" {
@@ -37,11 +72,36 @@ output "sessions_table_name" {
   value       = aws_dynamodb_table.sessions.name
 }
 
+output "token_lineage_table_name" {
+  description = "Name of the token_lineage DynamoDB table"
+  value       = aws_dynamodb_table.token_lineage.name
+}
+
 output "otp_requests_table_name" {
   description = "Name of the otp_requests DynamoDB table"
   value       = aws_dynamodb_table.otp_requests.name
 }
 
+output "entitlements_table_name" {
+  description = "Name of the entitlements DynamoDB table"
+  value       = aws_dynamodb_table.entitlements.name
+}
+
+output "sso_connections_table_name" {
+  description = "Name of the sso_connections DynamoDB table"
+  value       = aws_dynamodb_table.sso_connections.name
+}
+
+output "sso_identities_table_name" {
+  description = "Name of the sso_identities DynamoDB table"
+  value       = aws_dynamodb_table.sso_identities.name
+}
+
+output "scim_resources_table_name" {
+  description = "Name of the scim_resources DynamoDB table"
+  value       = aws_dynamodb_table.scim_resources.name
+}
+
 # KMS Keys
 
 output "auth_secrets_kms_key_arn" {
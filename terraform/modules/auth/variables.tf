@@ -32,3 +32,15 @@ variable "secret_recovery_window_days" {
   type        = number
   default     = 0
 }
+
+variable "user_import_bucket_name" {
+  description = "S3 bucket holding legacy user import manifests; chatmgmt gets read access when set (empty disables S3 manifests)"
+  type        = string
+  default     = ""
+}
+
+variable "sso_connections_replica_regions" {
+  description = "AWS regions of the other data-residency deployments to replicate sso_connections to (empty keeps it regional). Other tables never leave the region"
+  type        = list(string)
+  default     = []
+}
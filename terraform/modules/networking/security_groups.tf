@@ -107,6 +107,15 @@ resource "aws_vpc_security_group_egress_rule" "gateway_to_ingest" {
   referenced_security_group_id = aws_security_group.ingest.id
 }
 
+resource "aws_vpc_security_group_egress_rule" "gateway_to_fanout" {
+  security_group_id            = aws_security_group.gateway.id
+  description                  = "gRPC PublishSignal to Fanout"
+  ip_protocol                  = "tcp"
+  from_port                    = 9094
+  to_port                      = 9094
+  referenced_security_group_id = aws_security_group.fanout.id
+}
+
 resource "aws_vpc_security_group_egress_rule" "gateway_to_redis" {
   security_group_id            = aws_security_group.gateway.id
   description                  = "Redis (presence, routing, revocation)"
@@ -143,6 +152,24 @@ resource "aws_vpc_security_group_ingress_rule" "ingest_from_gateway" {
   referenced_security_group_id = aws_security_group.gateway.id
 }
 
+resource "aws_vpc_security_group_ingress_rule" "ingest_from_chatmgmt" {
+  security_group_id            = aws_security_group.ingest.id
+  description                  = "gRPC PostSystemMessage from ChatMgmt"
+  ip_protocol                  = "tcp"
+  from_port                    = 9091
+  to_port                      = 9091
+  referenced_security_group_id = aws_security_group.chatmgmt.id
+}
+
+resource "aws_vpc_security_group_ingress_rule" "ingest_from_fanout" {
+  security_group_id            = aws_security_group.ingest.id
+  description                  = "gRPC PersistMessage from Fanout (SMS replies)"
+  ip_protocol                  = "tcp"
+  from_port                    = 9091
+  to_port                      = 9091
+  referenced_security_group_id = aws_security_group.fanout.id
+}
+
 resource "aws_vpc_security_group_egress_rule" "ingest_to_dynamodb" {
   security_group_id = aws_security_group.ingest.id
   description       = "DynamoDB via VPC Gateway Endpoint"
@@ -163,12 +190,13 @@ resource "aws_vpc_security_group_egress_rule" "ingest_to_msk" {
 
 # -----------------------------------------------------------------------------
 # sg-fanout — Fanout Worker (Fanout Plane)
-# No inbound rules — pulls from Kafka, pushes to Redis and Gateway.
+# Inbound only for activity signals from Gateway — pulls from Kafka, pushes to
+# Redis and Gateway.
 # -----------------------------------------------------------------------------
 
 resource "aws_security_group" "fanout" {
   name_prefix = "${local.name}-fanout-"
-  description = "Fanout: no inbound, outbound to MSK, Redis, and Gateway"
+  description = "Fanout: inbound from Gateway, outbound to MSK, Redis, and Gateway"
   vpc_id      = aws_vpc.main.id
 
   lifecycle {
@@ -180,6 +208,24 @@ resource "aws_security_group" "fanout" {
   }
 }
 
+resource "aws_vpc_security_group_ingress_rule" "fanout_from_gateway" {
+  security_group_id            = aws_security_group.fanout.id
+  description                  = "gRPC PublishSignal from Gateway"
+  ip_protocol                  = "tcp"
+  from_port                    = 9094
+  to_port                      = 9094
+  referenced_security_group_id = aws_security_group.gateway.id
+}
+
+resource "aws_vpc_security_group_ingress_rule" "fanout_from_chatmgmt" {
+  security_group_id            = aws_security_group.fanout.id
+  description                  = "gRPC PublishNotification from ChatMgmt"
+  ip_protocol                  = "tcp"
+  from_port                    = 9094
+  to_port                      = 9094
+  referenced_security_group_id = aws_security_group.chatmgmt.id
+}
+
 resource "aws_vpc_security_group_egress_rule" "fanout_to_msk" {
   security_group_id            = aws_security_group.fanout.id
   description                  = "Kafka consume from MSK"
@@ -198,6 +244,15 @@ resource "aws_vpc_security_group_egress_rule" "fanout_to_redis" {
   referenced_security_group_id = aws_security_group.redis.id
 }
 
+resource "aws_vpc_security_group_egress_rule" "fanout_to_ingest" {
+  security_group_id            = aws_security_group.fanout.id
+  description                  = "gRPC PersistMessage to Ingest (SMS replies)"
+  ip_protocol                  = "tcp"
+  from_port                    = 9091
+  to_port                      = 9091
+  referenced_security_group_id = aws_security_group.ingest.id
+}
+
 resource "aws_vpc_security_group_egress_rule" "fanout_to_gateway" {
   security_group_id            = aws_security_group.fanout.id
   description                  = "gRPC DeliverMessage to Gateway"
@@ -245,13 +300,31 @@ resource "aws_vpc_security_group_egress_rule" "chatmgmt_to_dynamodb" {
 
 resource "aws_vpc_security_group_egress_rule" "chatmgmt_to_msk" {
   security_group_id            = aws_security_group.chatmgmt.id
-  description                  = "Kafka produce to MSK"
+  description                  = "Kafka produce to and consume from MSK"
   ip_protocol                  = "tcp"
   from_port                    = 9098
   to_port                      = 9098
   referenced_security_group_id = aws_security_group.msk.id
 }
 
+resource "aws_vpc_security_group_egress_rule" "chatmgmt_to_fanout" {
+  security_group_id            = aws_security_group.chatmgmt.id
+  description                  = "gRPC PublishNotification to Fanout"
+  ip_protocol                  = "tcp"
+  from_port                    = 9094
+  to_port                      = 9094
+  referenced_security_group_id = aws_security_group.fanout.id
+}
+
+resource "aws_vpc_security_group_egress_rule" "chatmgmt_to_ingest" {
+  security_group_id            = aws_security_group.chatmgmt.id
+  description                  = "gRPC PostSystemMessage to Ingest"
+  ip_protocol                  = "tcp"
+  from_port                    = 9091
+  to_port                      = 9091
+  referenced_security_group_id = aws_security_group.ingest.id
+}
+
 resource "aws_vpc_security_group_egress_rule" "chatmgmt_to_redis" {
   security_group_id            = aws_security_group.chatmgmt.id
   description                  = "Redis (rate limiting, session revocation)"